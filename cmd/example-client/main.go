@@ -1,304 +1,80 @@
 package main
 
 import (
+	"bufio"
 	"context"
-	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
-	"net"
-	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
-	"github.com/google/uuid"
-	"github.com/gorilla/websocket"
+	"github.com/codefionn/go-matter-server/pkg/client"
 )
 
-// Message types matching the server's protocol
-type CommandMessage struct {
-	MessageID string                 `json:"message_id"`
-	Command   string                 `json:"command"`
-	Args      map[string]interface{} `json:"args,omitempty"`
-}
-
-type ResultMessage struct {
-	MessageID string      `json:"message_id"`
-	Result    interface{} `json:"result,omitempty"`
-	ErrorCode int         `json:"error_code,omitempty"`
-	Details   *string     `json:"details,omitempty"`
-}
-
-type EventMessage struct {
-	Event string      `json:"event"`
-	Data  interface{} `json:"data"`
-}
-
-// mDNS discovery for finding matter-server
-func discoverMatterServer(ctx context.Context, timeout time.Duration) (string, error) {
+// discoverServer finds the servers advertising the _matter-server._tcp
+// service via mDNS and lets the user pick one if there are several. Without
+// a server on the network localhost is used.
+func discoverServer(ctx context.Context, timeout time.Duration) (string, error) {
 	fmt.Println("🔍 Discovering matter-server via mDNS...")
 
-	// Listen on mDNS multicast address
-	conn, err := net.ListenMulticastUDP("udp4", nil, &net.UDPAddr{
-		IP:   net.IPv4(224, 0, 0, 251),
-		Port: 5353,
-	})
-	if err != nil {
-		return "", fmt.Errorf("failed to listen on mDNS: %w", err)
-	}
-	defer conn.Close()
-
-	// Query for matter-server.local
-	query := buildDNSQuery("matter-server.local", 1) // A record
-
-	// Send query
-	_, err = conn.WriteToUDP(query, &net.UDPAddr{
-		IP:   net.IPv4(224, 0, 0, 251),
-		Port: 5353,
-	})
+	discoverCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	servers, err := client.Discover(discoverCtx)
 	if err != nil {
-		return "", fmt.Errorf("failed to send mDNS query: %w", err)
+		return "", err
 	}
 
-	fmt.Println("📡 Sent mDNS query for matter-server.local...")
-
-	// Listen for responses with timeout
-	conn.SetReadDeadline(time.Now().Add(timeout))
-	buf := make([]byte, 1500)
-
-	for {
-		n, addr, err := conn.ReadFromUDP(buf)
-		if err != nil {
-			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-				break
-			}
-			continue
-		}
-
-		// Parse DNS response and look for A records
-		if ip := parseDNSResponse(buf[:n], "matter-server.local"); ip != "" {
-			fmt.Printf("✅ Found matter-server at %s (from %s)\n", ip, addr.IP)
-			return ip, nil
-		}
+	switch len(servers) {
+	case 0:
+		fmt.Println("⚠️ No matter-server found, trying localhost...")
+		return "ws://127.0.0.1:5580/ws", nil
+	case 1:
+		fmt.Printf("✅ Found %s\n", describeServer(servers[0]))
+		return servers[0].URL, nil
 	}
 
-	// Fallback: try localhost
-	fmt.Println("⚠️ No mDNS response received, trying localhost...")
-	return "127.0.0.1", nil
-}
-
-// Simplified DNS query builder
-func buildDNSQuery(hostname string, recordType uint16) []byte {
-	query := make([]byte, 0, 256)
-
-	// DNS header
-	query = append(query, 0x00, 0x00) // ID
-	query = append(query, 0x01, 0x00) // Flags (standard query)
-	query = append(query, 0x00, 0x01) // Questions
-	query = append(query, 0x00, 0x00) // Answers
-	query = append(query, 0x00, 0x00) // Authority RRs
-	query = append(query, 0x00, 0x00) // Additional RRs
-
-	// Question section
-	parts := strings.Split(hostname, ".")
-	for _, part := range parts {
-		if part != "" {
-			query = append(query, byte(len(part)))
-			query = append(query, []byte(part)...)
-		}
+	fmt.Printf("✅ Found %d servers:\n", len(servers))
+	for i, server := range servers {
+		fmt.Printf("   %d) %s\n", i+1, describeServer(server))
 	}
-	query = append(query, 0x00) // End of name
-
-	// Query type and class
-	query = append(query, byte(recordType>>8), byte(recordType)) // Type A
-	query = append(query, 0x00, 0x01)                            // Class IN
-
-	return query
-}
-
-// Simplified DNS response parser
-func parseDNSResponse(buf []byte, hostname string) string {
-	if len(buf) < 12 {
-		return ""
-	}
-
-	// Check if it's a response
-	if buf[2]&0x80 == 0 {
-		return ""
-	}
-
-	answerCount := uint16(buf[6])<<8 | uint16(buf[7])
-	if answerCount == 0 {
-		return ""
-	}
-
-	// Skip header and questions to get to answers
-	offset := 12
-
-	// Skip questions
-	questionCount := uint16(buf[4])<<8 | uint16(buf[5])
-	for i := uint16(0); i < questionCount; i++ {
-		// Skip name
-		for offset < len(buf) && buf[offset] != 0 {
-			if buf[offset]&0xc0 == 0xc0 {
-				offset += 2
-				break
-			}
-			offset += int(buf[offset]) + 1
-		}
-		if offset < len(buf) && buf[offset] == 0 {
-			offset++
-		}
-		offset += 4 // Skip type and class
-	}
-
-	// Parse answers
-	for i := uint16(0); i < answerCount && offset+10 < len(buf); i++ {
-		// Skip name (could be compressed)
-		if buf[offset]&0xc0 == 0xc0 {
-			offset += 2
-		} else {
-			for offset < len(buf) && buf[offset] != 0 {
-				offset += int(buf[offset]) + 1
-			}
-			if offset < len(buf) {
-				offset++ // Skip null terminator
-			}
-		}
-
-		if offset+10 > len(buf) {
-			break
-		}
-
-		recordType := uint16(buf[offset])<<8 | uint16(buf[offset+1])
-		dataLen := uint16(buf[offset+8])<<8 | uint16(buf[offset+9])
-		offset += 10
-
-		if recordType == 1 && dataLen == 4 && offset+4 <= len(buf) { // A record
-			ip := net.IP(buf[offset : offset+4])
-			return ip.String()
-		}
-		offset += int(dataLen)
-	}
-
-	return ""
-}
-
-// WebSocket client for communicating with matter-server
-type MatterClient struct {
-	conn   *websocket.Conn
-	url    string
-	logger func(string, ...interface{})
-}
-
-func NewMatterClient(serverIP string, port int) *MatterClient {
-	return &MatterClient{
-		url: fmt.Sprintf("ws://%s:%d/ws", serverIP, port),
-		logger: func(format string, args ...interface{}) {
-			log.Printf(format, args...)
-		},
-	}
-}
-
-func (mc *MatterClient) Connect(ctx context.Context) error {
-	mc.logger("🔌 Connecting to matter-server at %s", mc.url)
-
-	u, err := url.Parse(mc.url)
-	if err != nil {
-		return fmt.Errorf("invalid URL: %w", err)
-	}
-
-	conn, _, err := websocket.DefaultDialer.DialContext(ctx, u.String(), nil)
-	if err != nil {
-		return fmt.Errorf("failed to connect to WebSocket: %w", err)
-	}
-
-	mc.conn = conn
-	mc.logger("✅ Connected to matter-server WebSocket")
-
-	// Start message reader
-	go mc.readMessages(ctx)
-
-	return nil
-}
-
-func (mc *MatterClient) readMessages(ctx context.Context) {
-	defer mc.conn.Close()
-
+	reader := bufio.NewReader(os.Stdin)
 	for {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-			var msg json.RawMessage
-			err := mc.conn.ReadJSON(&msg)
-			if err != nil {
-				if !websocket.IsCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
-					mc.logger("❌ Error reading message: %v", err)
-				}
-				return
-			}
-
-			mc.handleMessage(msg)
-		}
-	}
-}
-
-func (mc *MatterClient) handleMessage(rawMsg json.RawMessage) {
-	// Try to determine message type
-	var msgType map[string]interface{}
-	if err := json.Unmarshal(rawMsg, &msgType); err != nil {
-		mc.logger("❌ Failed to parse message: %v", err)
-		return
-	}
-
-	if _, hasResult := msgType["result"]; hasResult || msgType["error_code"] != nil {
-		// Result message
-		var result ResultMessage
-		if err := json.Unmarshal(rawMsg, &result); err == nil {
-			if result.ErrorCode != 0 {
-				details := "unknown error"
-				if result.Details != nil {
-					details = *result.Details
-				}
-				mc.logger("❌ Command failed [%s]: error %d - %s", result.MessageID, result.ErrorCode, details)
-			} else {
-				mc.logger("✅ Command success [%s]: %v", result.MessageID, result.Result)
-			}
+		fmt.Printf("Select a server [1-%d]: ", len(servers))
+		line, err := reader.ReadString('\n')
+		if choice, convErr := strconv.Atoi(strings.TrimSpace(line)); convErr == nil && choice >= 1 && choice <= len(servers) {
+			return servers[choice-1].URL, nil
 		}
-	} else if _, hasEvent := msgType["event"]; hasEvent {
-		// Event message
-		var eventMsg EventMessage
-		if err := json.Unmarshal(rawMsg, &eventMsg); err == nil {
-			mc.logger("📢 Event: %s - %v", eventMsg.Event, eventMsg.Data)
+		if err != nil {
+			return "", fmt.Errorf("no server selected: %w", err)
 		}
-	} else {
-		mc.logger("📨 Raw message: %s", string(rawMsg))
 	}
 }
 
-func (mc *MatterClient) SendCommand(command string, args map[string]interface{}) error {
-	cmd := CommandMessage{
-		MessageID: uuid.New().String(),
-		Command:   command,
-		Args:      args,
+// describeServer formats a discovered server with its schema versions,
+// noting servers this client can't talk to
+func describeServer(server client.DiscoveredServer) string {
+	description := fmt.Sprintf("%s (%s)", server.Host, server.URL)
+	if server.SchemaVersion == 0 {
+		return description
 	}
-
-	mc.logger("📤 Sending command: %s [%s]", command, cmd.MessageID)
-
-	return mc.conn.WriteJSON(cmd)
-}
-
-func (mc *MatterClient) Close() error {
-	if mc.conn != nil {
-		return mc.conn.Close()
+	description += fmt.Sprintf(", schema versions %d-%d", server.MinSchemaVersion, server.SchemaVersion)
+	if client.SchemaVersion < server.MinSchemaVersion {
+		description += " - too new for this client"
 	}
-	return nil
+	return description
 }
 
 func main() {
+	msgpack := flag.Bool("msgpack", false, "Use MessagePack instead of JSON on the WebSocket")
+	serverURL := flag.String("url", "", "WebSocket URL of the server, discovered via mDNS if empty")
+	flag.Parse()
+
 	fmt.Println("🚀 Matter Server Example Client")
 	fmt.Println("===============================")
 
@@ -314,69 +90,86 @@ func main() {
 		cancel()
 	}()
 
-	// Discover matter-server via mDNS
-	serverIP, err := discoverMatterServer(ctx, 5*time.Second)
-	if err != nil {
-		log.Fatalf("❌ Failed to discover matter-server: %v", err)
+	// Discover matter-server via mDNS unless given
+	url := *serverURL
+	if url == "" {
+		var err error
+		url, err = discoverServer(ctx, 3*time.Second)
+		if err != nil {
+			log.Fatalf("❌ Failed to discover matter-server: %v", err)
+		}
 	}
 
 	// Connect to matter-server
-	client := NewMatterClient(serverIP, 5580)
-	if err := client.Connect(ctx); err != nil {
+	codec := client.JSON
+	if *msgpack {
+		codec = client.MessagePack
+	}
+	fmt.Printf("🔌 Connecting to matter-server at %s\n", url)
+	// Reconnect when the server restarts, resending start_listening
+	c, err := client.Dial(ctx, url,
+		client.WithCodec(codec),
+		client.WithTimeout(30*time.Second),
+		client.WithReconnect(client.ReconnectOptions{}),
+	)
+	if err != nil {
 		log.Fatalf("❌ Failed to connect to matter-server: %v", err)
 	}
-	defer client.Close()
+	defer c.Close()
 
-	// Wait a moment for connection to stabilize
-	time.Sleep(1 * time.Second)
+	info := c.ServerInfo()
+	fmt.Printf("✅ Connected (fabric %d, schema version %d, SDK %s)\n", info.FabricID, info.SchemaVersion, info.SDKVersion)
 
-	// Send example commands
-	examples := []struct {
-		name    string
-		command string
-		args    map[string]interface{}
-	}{
-		{
-			name:    "Get Server Info",
-			command: "server_info",
-			args:    nil,
-		},
-		{
-			name:    "Get Server Diagnostics",
-			command: "diagnostics",
-			args:    nil,
-		},
-		{
-			name:    "Get All Nodes",
-			command: "get_nodes",
-			args:    nil,
-		},
-		{
-			name:    "Start Listening",
-			command: "start_listening",
-			args:    nil,
-		},
-		{
-			name:    "Discover Devices",
-			command: "discover",
-			args:    map[string]interface{}{},
-		},
-	}
+	// Register event callbacks before subscribing to not miss events
+	c.OnAttributeUpdated(func(update client.AttributeUpdate) {
+		fmt.Printf("📢 Node %d: %s = %v\n", update.NodeID, update.Path, update.Value)
+	})
+	c.OnNodeAdded(func(node *client.Node) {
+		fmt.Printf("📢 Node %d added\n", node.NodeID)
+	})
+	c.OnNodeRemoved(func(nodeID int) {
+		fmt.Printf("📢 Node %d removed\n", nodeID)
+	})
+	c.OnDisconnect(func(err error) {
+		fmt.Printf("⚠️ Connection lost (%v), reconnecting...\n", err)
+	})
+	c.OnReconnect(func() {
+		fmt.Println("🔌 Reconnected, resynchronizing nodes")
+	})
 
 	fmt.Println("\n📋 Sending example commands...")
-	for i, example := range examples {
-		fmt.Printf("\n%d. %s\n", i+1, example.name)
-		if err := client.SendCommand(example.command, example.args); err != nil {
-			log.Printf("❌ Failed to send %s command: %v", example.command, err)
-		}
-		time.Sleep(1 * time.Second) // Wait between commands
+
+	// Commands return their decoded result
+	diagnostics, err := client.SendCommand[map[string]interface{}](ctx, c, "diagnostics", nil)
+	if err != nil {
+		log.Printf("❌ diagnostics failed: %v", err)
+	} else {
+		fmt.Printf("✅ Diagnostics: %d sections\n", len(diagnostics))
+	}
+
+	// Errors reported by the server are returned as *client.Error
+	var apiErr *client.Error
+	if _, err := c.GetNode(ctx, 999999); errors.As(err, &apiErr) {
+		fmt.Printf("✅ get_node of an unknown node failed: error %d - %s\n", apiErr.Code, apiErr.Details)
+	} else if err != nil {
+		log.Printf("❌ get_node failed: %v", err)
+	}
+
+	nodes, err := c.Subscribe(ctx, client.SubscribeOptions{})
+	if err != nil {
+		log.Fatalf("❌ Failed to start listening: %v", err)
+	}
+	fmt.Printf("✅ Listening, %d nodes:\n", len(nodes))
+	for _, node := range nodes {
+		fmt.Printf("   • Node %d (available: %t)\n", node.NodeID, node.Available)
 	}
 
-	fmt.Println("\n⏳ Listening for responses and events for 10 seconds...")
+	fmt.Println("\n⏳ Listening for events for 10 seconds...")
 
-	// Listen for responses and events
 	select {
 	case <-ctx.Done():
+	case <-c.Done():
+		fmt.Printf("❌ Connection closed: %v\n", c.Err())
 	case <-time.After(10 * time.Second):
 		fmt.Println("⏰ Timeout reached")
 	}
@@ -0,0 +1,362 @@
+// Command loadtest measures the WebSocket event fan-out of the server. It
+// runs a server in-process, connects clients that listen for
+// attribute_updated events and emits synthetic attribute updates at a fixed
+// rate. It reports the latency from emitting an update to a client receiving
+// it, and the updates clients missed because the server coalesced or dropped
+// them.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/config"
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/server"
+	"github.com/codefionn/go-matter-server/pkg/client"
+)
+
+// options are the parameters of a load test
+type options struct {
+	clients    int
+	rate       float64
+	duration   time.Duration
+	nodes      int
+	attributes int
+	msgpack    bool
+
+	queueSize      int
+	overflowPolicy string
+	eventQueueSize int
+	flushInterval  time.Duration
+	logLevel       string
+}
+
+// receiver is a client counting the updates it receives
+type receiver struct {
+	client *client.Client
+
+	mu        sync.Mutex
+	latencies []time.Duration
+	received  int
+	// disconnected is set when the server closed the connection, e.g. as a
+	// slow consumer
+	disconnected atomic.Bool
+}
+
+// updates holds the emit time of every update, indexed by its sequence
+// number, which the updates carry as value
+type updates struct {
+	sent  []atomic.Int64
+	count atomic.Int64
+}
+
+func main() {
+	var opts options
+	flag.IntVar(&opts.clients, "clients", 100, "Number of WebSocket clients")
+	flag.Float64Var(&opts.rate, "rate", 500, "Attribute updates emitted per second")
+	flag.DurationVar(&opts.duration, "duration", 10*time.Second, "Duration of emitting updates")
+	flag.IntVar(&opts.nodes, "nodes", 10, "Number of nodes the updates are spread across")
+	flag.IntVar(&opts.attributes, "attributes", 20, "Number of attribute paths per node; updates of the same path may be coalesced")
+	flag.BoolVar(&opts.msgpack, "msgpack", false, "Use MessagePack instead of JSON on the WebSocket")
+	flag.IntVar(&opts.queueSize, "queue-size", 0, "server.websocket_queue_size, the default if 0")
+	flag.StringVar(&opts.overflowPolicy, "overflow-policy", "", "server.websocket_overflow_policy, the default if empty")
+	flag.IntVar(&opts.eventQueueSize, "event-queue-size", 0, "server.event_queue_size, the default if 0")
+	flag.DurationVar(&opts.flushInterval, "flush-interval", 0, "storage.flush_interval; the event history is written on every update if 0")
+	flag.StringVar(&opts.logLevel, "log-level", "error", "Log level of the server")
+	flag.Parse()
+
+	if opts.clients <= 0 || opts.rate <= 0 || opts.duration <= 0 || opts.nodes <= 0 || opts.attributes <= 0 {
+		fmt.Fprintln(os.Stderr, "clients, rate, duration, nodes and attributes must be positive")
+		os.Exit(2)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	if err := run(ctx, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "Load test failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, opts options) error {
+	srv, url, stop, err := startServer(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	codec := client.JSON
+	if opts.msgpack {
+		codec = client.MessagePack
+	}
+	fmt.Printf("Connecting %d clients to %s\n", opts.clients, url)
+	receivers := make([]*receiver, 0, opts.clients)
+	defer func() {
+		for _, r := range receivers {
+			r.client.Close()
+		}
+	}()
+
+	total := int(opts.rate * opts.duration.Seconds())
+	sent := &updates{sent: make([]atomic.Int64, total)}
+	for i := 0; i < opts.clients; i++ {
+		r, err := connect(ctx, url, codec, sent)
+		if err != nil {
+			return fmt.Errorf("client %d: %w", i+1, err)
+		}
+		receivers = append(receivers, r)
+	}
+
+	fmt.Printf("Emitting %d updates at %.0f/s for %s\n", total, opts.rate, opts.duration)
+	start := time.Now()
+	emit(ctx, srv, sent, opts)
+	elapsed := time.Since(start)
+	emitted := int(sent.count.Load())
+	fmt.Printf("Emitted %d updates in %s (%.0f/s)\n", emitted, elapsed.Round(time.Millisecond), float64(emitted)/elapsed.Seconds())
+
+	// Give the clients time to receive the last updates
+	settle(ctx, receivers, emitted)
+
+	diagnostics, err := srv.HandleCommand(ctx, models.CommandMessage{
+		MessageID: "loadtest",
+		Command:   string(models.APICommandServerDiagnostics),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get diagnostics: %w", err)
+	}
+	report(receivers, emitted, diagnostics.(models.ServerDiagnostics))
+	return nil
+}
+
+// startServer runs a server with temporary storage on a free loopback port
+// and waits until it is ready. stop shuts it down.
+func startServer(ctx context.Context, opts options) (*server.Server, string, func(), error) {
+	cfg, err := config.Defaults()
+	if err != nil {
+		return nil, "", nil, err
+	}
+	storagePath, err := os.MkdirTemp("", "matter-loadtest-")
+	if err != nil {
+		return nil, "", nil, err
+	}
+	port, err := freePort()
+	if err != nil {
+		os.RemoveAll(storagePath)
+		return nil, "", nil, err
+	}
+	cfg.Storage.Path = storagePath
+	cfg.Storage.AuditLog = false
+	cfg.Storage.FlushInterval = opts.flushInterval
+	cfg.Log.Level = opts.logLevel
+	cfg.Server.Port = port
+	cfg.MDNS.Enabled = false
+	if opts.queueSize > 0 {
+		cfg.Server.WebSocketQueueSize = opts.queueSize
+	}
+	if opts.overflowPolicy != "" {
+		cfg.Server.WebSocketOverflowPolicy = opts.overflowPolicy
+	}
+	if opts.eventQueueSize > 0 {
+		cfg.Server.EventQueueSize = opts.eventQueueSize
+	}
+
+	level, err := logger.ParseLogLevel(opts.logLevel)
+	if err != nil {
+		os.RemoveAll(storagePath)
+		return nil, "", nil, err
+	}
+	srv, err := server.New(cfg, logger.NewConsoleLogger(level))
+	if err != nil {
+		os.RemoveAll(storagePath)
+		return nil, "", nil, err
+	}
+
+	serverCtx, cancel := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() { done <- srv.Run(serverCtx) }()
+	stop := func() {
+		cancel()
+		<-done
+		os.RemoveAll(storagePath)
+	}
+
+	base := fmt.Sprintf("http://127.0.0.1:%d", port)
+	for {
+		resp, err := http.Get(base + "/health/ready")
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return srv, fmt.Sprintf("ws://127.0.0.1:%d/ws", port), stop, nil
+			}
+		}
+		select {
+		case err := <-done:
+			cancel()
+			os.RemoveAll(storagePath)
+			return nil, "", nil, fmt.Errorf("server stopped: %w", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// freePort returns a TCP port that is free on loopback right now
+func freePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// connect dials a client that records the latency of the updates it
+// receives
+func connect(ctx context.Context, url string, codec client.Codec, sent *updates) (*receiver, error) {
+	c, err := client.Dial(ctx, url, client.WithCodec(codec), client.WithTimeout(30*time.Second))
+	if err != nil {
+		return nil, err
+	}
+	r := &receiver{client: c}
+	c.OnAttributeUpdated(func(update client.AttributeUpdate) {
+		received := time.Now()
+		seq, ok := update.Value.(float64)
+		if !ok || seq < 0 || int(seq) >= len(sent.sent) {
+			return
+		}
+		r.mu.Lock()
+		r.received++
+		r.latencies = append(r.latencies, received.Sub(time.Unix(0, sent.sent[int(seq)].Load())))
+		r.mu.Unlock()
+	})
+	c.OnDisconnect(func(error) {
+		r.disconnected.Store(true)
+	})
+
+	if _, err := c.Subscribe(ctx, client.SubscribeOptions{Events: []client.EventType{models.EventTypeAttributeUpdated}}); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// emit emits the updates at the configured rate. Updates are emitted in
+// batches every millisecond to keep up with rates beyond the timer
+// resolution.
+func emit(ctx context.Context, srv *server.Server, sent *updates, opts options) {
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+
+	start := time.Now()
+	seq := 0
+	for seq < len(sent.sent) {
+		due := int(time.Since(start).Seconds() * opts.rate)
+		for ; seq < due && seq < len(sent.sent); seq++ {
+			node := seq%opts.nodes + 1
+			path := fmt.Sprintf("1/6/%d", (seq/opts.nodes)%opts.attributes)
+			sent.sent[seq].Store(time.Now().UnixNano())
+			sent.count.Store(int64(seq + 1))
+			srv.EmitEvent(models.EventTypeAttributeUpdated, []interface{}{node, path, seq})
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// settle waits until all clients received every update or no client
+// received any for a second
+func settle(ctx context.Context, receivers []*receiver, emitted int) {
+	last, idle := -1, 0
+	for idle < 10 {
+		received := 0
+		for _, r := range receivers {
+			r.mu.Lock()
+			received += r.received
+			r.mu.Unlock()
+		}
+		if received == emitted*len(receivers) {
+			return
+		}
+		if received == last {
+			idle++
+		} else {
+			last, idle = received, 0
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// report prints the latency percentiles and the delivery counts
+func report(receivers []*receiver, emitted int, diagnostics models.ServerDiagnostics) {
+	var latencies []time.Duration
+	received, disconnected := 0, 0
+	for _, r := range receivers {
+		r.mu.Lock()
+		latencies = append(latencies, r.latencies...)
+		received += r.received
+		r.mu.Unlock()
+		if r.disconnected.Load() {
+			disconnected++
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	expected := emitted * len(receivers)
+	fmt.Println()
+	fmt.Printf("Delivered:     %d of %d (%.2f%%)\n", received, expected, percent(received, expected))
+	fmt.Printf("Missed:        %d\n", expected-received)
+	fmt.Printf("Disconnected:  %d of %d clients\n", disconnected, len(receivers))
+	if len(latencies) > 0 {
+		fmt.Printf("Latency:       p50 %s  p90 %s  p99 %s  p99.9 %s  max %s\n",
+			percentile(latencies, 0.50), percentile(latencies, 0.90), percentile(latencies, 0.99),
+			percentile(latencies, 0.999), latencies[len(latencies)-1].Round(time.Microsecond))
+	}
+
+	if ws := diagnostics.WebSocket; ws != nil {
+		fmt.Printf("Server:        %d coalesced, %d dropped, %d slow consumer disconnects\n",
+			ws.CoalescedEvents, ws.DroppedEvents, ws.SlowConsumerDisconnects)
+	}
+	for _, subscriber := range diagnostics.EventSubscribers {
+		if subscriber.Dropped > 0 {
+			fmt.Printf("Subscriber %s dropped %d events\n", subscriber.ID, subscriber.Dropped)
+		}
+	}
+}
+
+// percentile returns the latency below which the fraction p of the sorted
+// latencies are
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(p * float64(len(sorted)))
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i].Round(time.Microsecond)
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return 100 * float64(n) / float64(total)
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/codefionn/go-matter-server/pkg/client"
+)
+
+func (c *cli) nodesCmd() *cobra.Command {
+	nodesCmd := &cobra.Command{
+		Use:   "nodes",
+		Short: "Manage the commissioned nodes",
+	}
+	nodesCmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List the commissioned nodes",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conn, err := c.connect(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+
+			nodes, err := conn.GetNodes(cmd.Context())
+			if err != nil {
+				return err
+			}
+			return writeNodes(cmd.OutOrStdout(), c.output, nodes)
+		},
+	})
+	return nodesCmd
+}
+
+func (c *cli) nodeCmd() *cobra.Command {
+	nodeCmd := &cobra.Command{
+		Use:   "node",
+		Short: "Inspect, rename and remove a node",
+	}
+	nodeCmd.AddCommand(&cobra.Command{
+		Use:   "get <node-id>",
+		Short: "Show a node with its attributes",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			nodeID, err := parseNodeID(args[0])
+			if err != nil {
+				return err
+			}
+			conn, err := c.connect(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+
+			node, err := conn.GetNode(cmd.Context(), nodeID)
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			if c.output == outputJSON {
+				return writeJSON(out, node)
+			}
+			if err := writeNodes(out, c.output, []*client.Node{node}); err != nil {
+				return err
+			}
+			fmt.Fprintln(out)
+			return writeAttributes(out, c.output, node.Attributes, node.AttributeNames)
+		},
+	}, &cobra.Command{
+		Use:   "rename <node-id> <name>",
+		Short: "Set the name of a node (an empty name clears it)",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			nodeID, err := parseNodeID(args[0])
+			if err != nil {
+				return err
+			}
+			conn, err := c.connect(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+
+			node, err := conn.SetNodeName(cmd.Context(), nodeID, args[1])
+			if err != nil {
+				return err
+			}
+			return writeNodes(cmd.OutOrStdout(), c.output, []*client.Node{node})
+		},
+	}, &cobra.Command{
+		Use:   "remove <node-id>",
+		Short: "Decommission a node",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			nodeID, err := parseNodeID(args[0])
+			if err != nil {
+				return err
+			}
+			conn, err := c.connect(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+
+			if err := conn.RemoveNode(cmd.Context(), nodeID); err != nil {
+				return err
+			}
+			return writeResult(cmd.OutOrStdout(), nil)
+		},
+	})
+	return nodeCmd
+}
+
+func (c *cli) commissionCmd() *cobra.Command {
+	var code string
+	var fabricID int
+	commissionCmd := &cobra.Command{
+		Use:   "commission",
+		Short: "Commission a device with its setup code",
+		Example: "  matter-cli commission --code MT:Y.K9042C00KA0648G00\n" +
+			"  matter-cli commission --code 34970112332 --fabric-id 2",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conn, err := c.connect(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+
+			node, err := conn.CommissionOnFabric(cmd.Context(), code, fabricID)
+			if err != nil {
+				return err
+			}
+			return writeNodes(cmd.OutOrStdout(), c.output, []*client.Node{node})
+		},
+	}
+	commissionCmd.Flags().StringVar(&code, "code", "", "QR code payload (MT:...) or manual pairing code")
+	commissionCmd.Flags().IntVar(&fabricID, "fabric-id", 0, "Fabric to commission into (default: the server's default fabric)")
+	commissionCmd.MarkFlagRequired("code")
+	return commissionCmd
+}
+
+func (c *cli) deviceCommandCmd() *cobra.Command {
+	var request client.DeviceCommand
+	var payload string
+	deviceCmd := &cobra.Command{
+		Use:     "device-command",
+		Short:   "Send a cluster command to an endpoint of a node",
+		Example: `  matter-cli device-command --node 5 --endpoint 1 --cluster LevelControl --command MoveToLevel --payload '{"level": 128}'`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if payload != "" {
+				if err := json.Unmarshal([]byte(payload), &request.Payload); err != nil {
+					return fmt.Errorf("invalid payload: %w", err)
+				}
+			}
+			conn, err := c.connect(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+
+			result, err := conn.DeviceCommand(cmd.Context(), request)
+			if err != nil {
+				return err
+			}
+			return writeResult(cmd.OutOrStdout(), result)
+		},
+	}
+	flags := deviceCmd.Flags()
+	flags.IntVar(&request.NodeID, "node", 0, "Node ID")
+	flags.IntVar(&request.EndpointID, "endpoint", 1, "Endpoint ID")
+	flags.StringVar(&request.Cluster, "cluster", "", "Cluster ID or name, e.g. OnOff")
+	flags.StringVar(&request.Command, "command", "", "Command ID or name, e.g. Toggle")
+	flags.StringVar(&payload, "payload", "", "Command fields as a JSON object")
+	flags.DurationVar(&request.TimedRequestTimeout, "timed", 0, "Send as a timed interaction with this timeout, e.g. 1s")
+	for _, name := range []string{"node", "cluster", "command"} {
+		deviceCmd.MarkFlagRequired(name)
+	}
+	return deviceCmd
+}
+
+func (c *cli) attributeCmd() *cobra.Command {
+	attributeCmd := &cobra.Command{
+		Use:   "attribute",
+		Short: "Read and write attributes of a node",
+		Long: "Attribute paths are \"endpoint/cluster/attribute\" with numeric IDs. " +
+			"Reading returns the values kept up to date by the server's subscriptions; any part may be \"*\".",
+	}
+	attributeCmd.AddCommand(&cobra.Command{
+		Use:     "read <node-id> <path>",
+		Short:   "Read attribute values",
+		Example: "  matter-cli attribute read 5 1/6/0\n  matter-cli attribute read 5 '0/40/*'",
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			nodeID, err := parseNodeID(args[0])
+			if err != nil {
+				return err
+			}
+			pattern := args[1]
+			if len(strings.Split(pattern, "/")) != 3 {
+				return fmt.Errorf("invalid attribute path %q: expected endpoint/cluster/attribute", pattern)
+			}
+			conn, err := c.connect(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+
+			node, err := conn.GetNode(cmd.Context(), nodeID)
+			if err != nil {
+				return err
+			}
+			attributes := make(map[string]interface{})
+			for path, value := range node.Attributes {
+				if matchAttributePath(pattern, path) {
+					attributes[path] = value
+				}
+			}
+			if len(attributes) == 0 {
+				return fmt.Errorf("node %d has no attribute %s", nodeID, pattern)
+			}
+			return writeAttributes(cmd.OutOrStdout(), c.output, attributes, node.AttributeNames)
+		},
+	}, &cobra.Command{
+		Use:   "write <node-id> <path> <value>",
+		Short: "Write an attribute value",
+		Long: "Write an attribute value. The value is parsed as JSON, e.g. 42, true or {\"a\": 1}; " +
+			"anything else is written as a string.",
+		Example: "  matter-cli attribute write 5 0/40/5 Kitchen\n  matter-cli attribute write 5 1/6/16385 300",
+		Args:    cobra.ExactArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			nodeID, err := parseNodeID(args[0])
+			if err != nil {
+				return err
+			}
+			var value interface{}
+			if err := json.Unmarshal([]byte(args[2]), &value); err != nil {
+				value = args[2]
+			}
+			conn, err := c.connect(cmd.Context(), false)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+
+			if err := conn.WriteAttribute(cmd.Context(), nodeID, args[1], value); err != nil {
+				return err
+			}
+			return writeResult(cmd.OutOrStdout(), nil)
+		},
+	})
+	return attributeCmd
+}
+
+func (c *cli) eventsCmd() *cobra.Command {
+	var opts client.SubscribeOptions
+	var events []string
+	eventsCmd := &cobra.Command{
+		Use:   "events",
+		Short: "Watch server events",
+	}
+	watchCmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Print events until interrupted",
+		Long: "Print events until interrupted, one per line. The JSON output writes one object per line. " +
+			"The connection is reestablished when lost.",
+		Example: "  matter-cli events watch --event attribute_updated --attribute '*/6/0'",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			for _, event := range events {
+				opts.Events = append(opts.Events, client.EventType(event))
+			}
+			conn, err := c.connect(ctx, true)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+
+			out := cmd.OutOrStdout()
+			errOut := cmd.ErrOrStderr()
+			conn.OnEvent(func(event client.Event) {
+				if c.output == outputJSON {
+					data, _ := json.Marshal(map[string]interface{}{
+						"timestamp": time.Now().UTC(),
+						"event":     event.Type,
+						"data":      event.Data,
+					})
+					fmt.Fprintln(out, string(data))
+					return
+				}
+				fmt.Fprintf(out, "%s  %-20s  %s\n", time.Now().Format("15:04:05.000"), event.Type, event.Data)
+			})
+			conn.OnDisconnect(func(err error) {
+				fmt.Fprintf(errOut, "Connection lost (%v), reconnecting\n", err)
+			})
+			conn.OnReconnect(func() {
+				fmt.Fprintln(errOut, "Reconnected")
+			})
+
+			if _, err := conn.Subscribe(ctx, opts); err != nil {
+				return err
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-conn.Done():
+				return conn.Err()
+			}
+		},
+	}
+	flags := watchCmd.Flags()
+	flags.StringSliceVar(&events, "event", nil, "Event types to print, e.g. attribute_updated (default all)")
+	flags.IntSliceVar(&opts.NodeIDs, "node", nil, "Only events of these nodes")
+	flags.StringSliceVar(&opts.AttributePaths, "attribute", nil, "Only attribute updates of these paths, e.g. */6/0")
+	eventsCmd.AddCommand(watchCmd)
+	return eventsCmd
+}
+
+func parseNodeID(arg string) (int, error) {
+	nodeID, err := strconv.Atoi(arg)
+	if err != nil || nodeID < 0 {
+		return 0, fmt.Errorf("invalid node ID %q", arg)
+	}
+	return nodeID, nil
+}
+
+// matchAttributePath reports whether an attribute path matches a pattern,
+// whose parts may be "*"
+func matchAttributePath(pattern, path string) bool {
+	patternParts := strings.Split(pattern, "/")
+	pathParts := strings.Split(path, "/")
+	if len(patternParts) != len(pathParts) {
+		return false
+	}
+	for i, part := range patternParts {
+		if part != "*" && part != pathParts[i] {
+			return false
+		}
+	}
+	return true
+}
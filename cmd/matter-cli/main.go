@@ -0,0 +1,96 @@
+// Command matter-cli administers a running Matter server over its WebSocket
+// API.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/codefionn/go-matter-server/pkg/client"
+)
+
+// Set by the build, like for matter-server
+var (
+	version = "dev"
+	commit  = "unknown"
+)
+
+// cli holds the global flags
+type cli struct {
+	url     string
+	output  string
+	timeout time.Duration
+	origin  string
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := newRootCmd().ExecuteContext(ctx); err != nil {
+		os.Exit(1)
+	}
+}
+
+func newRootCmd() *cobra.Command {
+	c := &cli{}
+	rootCmd := &cobra.Command{
+		Use:     "matter-cli",
+		Short:   "Administer a Matter server over its WebSocket API",
+		Version: fmt.Sprintf("%s (%s)", version, commit),
+		// Errors are about the server or the arguments, usage is shown for
+		// flag errors only
+		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if c.output != outputTable && c.output != outputJSON {
+				return fmt.Errorf("invalid output %q: expected %s or %s", c.output, outputTable, outputJSON)
+			}
+			return nil
+		},
+	}
+
+	url := os.Getenv("MATTER_SERVER_URL")
+	if url == "" {
+		url = "ws://localhost:5580/ws"
+	}
+	rootCmd.PersistentFlags().StringVar(&c.url, "url", url, "WebSocket URL of the server (MATTER_SERVER_URL)")
+	rootCmd.PersistentFlags().StringVarP(&c.output, "output", "o", outputTable, "Output format: table or json")
+	rootCmd.PersistentFlags().DurationVar(&c.timeout, "timeout", 30*time.Second, "Time a command may take")
+	rootCmd.PersistentFlags().StringVar(&c.origin, "origin", "", "Origin header, if the server restricts origins")
+
+	rootCmd.AddCommand(
+		c.nodesCmd(),
+		c.nodeCmd(),
+		c.commissionCmd(),
+		c.deviceCommandCmd(),
+		c.attributeCmd(),
+		c.eventsCmd(),
+	)
+	return rootCmd
+}
+
+// connect opens a connection to the server. Long running commands
+// reconnect when the connection is lost.
+func (c *cli) connect(ctx context.Context, reconnect bool) (*client.Client, error) {
+	opts := []client.Option{client.WithTimeout(c.timeout)}
+	if c.origin != "" {
+		opts = append(opts, client.WithHeader(map[string][]string{"Origin": {c.origin}}))
+	}
+	if reconnect {
+		opts = append(opts, client.WithReconnect(client.ReconnectOptions{}))
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	conn, err := client.Dial(dialCtx, c.url, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", c.url, err)
+	}
+	return conn, nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/codefionn/go-matter-server/pkg/client"
+)
+
+// Output formats
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// Attributes of the Basic Information cluster shown in node tables
+const (
+	attributeVendorName  = "0/40/1"
+	attributeProductName = "0/40/3"
+	attributeNodeLabel   = "0/40/5"
+)
+
+// writeJSON writes v as indented JSON
+func writeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// writeTable writes rows aligned in columns below header
+func writeTable(w io.Writer, header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// formatValue formats an attribute value for a table cell
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return v
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+// nodeName returns the name users gave a node, else its label or product
+// name
+func nodeName(node *client.Node) string {
+	if node.Metadata != nil && node.Metadata.Name != "" {
+		return node.Metadata.Name
+	}
+	for _, path := range []string{attributeNodeLabel, attributeProductName} {
+		if name, ok := node.Attributes[path].(string); ok && name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+func writeNodes(w io.Writer, output string, nodes []*client.Node) error {
+	if output == outputJSON {
+		return writeJSON(w, nodes)
+	}
+
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].NodeID < nodes[j].NodeID })
+	rows := make([][]string, len(nodes))
+	for i, node := range nodes {
+		vendor, _ := node.Attributes[attributeVendorName].(string)
+		rows[i] = []string{
+			strconv.Itoa(node.NodeID),
+			nodeName(node),
+			vendor,
+			strconv.FormatBool(node.Available),
+			strconv.FormatBool(node.IsBridge),
+		}
+	}
+	return writeTable(w, []string{"NODE", "NAME", "VENDOR", "AVAILABLE", "BRIDGE"}, rows)
+}
+
+// writeAttributes writes attribute values keyed by path, in the order of
+// their endpoint, cluster and attribute IDs
+func writeAttributes(w io.Writer, output string, attributes map[string]interface{}, names map[string]string) error {
+	if output == outputJSON {
+		return writeJSON(w, attributes)
+	}
+
+	paths := make([]string, 0, len(attributes))
+	for path := range attributes {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool { return comparePaths(paths[i], paths[j]) < 0 })
+
+	rows := make([][]string, len(paths))
+	for i, path := range paths {
+		rows[i] = []string{path, names[path], formatValue(attributes[path])}
+	}
+	return writeTable(w, []string{"PATH", "NAME", "VALUE"}, rows)
+}
+
+// comparePaths orders attribute paths by their numeric parts
+func comparePaths(a, b string) int {
+	partsA, partsB := strings.Split(a, "/"), strings.Split(b, "/")
+	for i := 0; i < len(partsA) && i < len(partsB); i++ {
+		x, errX := strconv.Atoi(partsA[i])
+		y, errY := strconv.Atoi(partsB[i])
+		if errX != nil || errY != nil {
+			if c := strings.Compare(partsA[i], partsB[i]); c != 0 {
+				return c
+			}
+			continue
+		}
+		if x != y {
+			return x - y
+		}
+	}
+	return len(partsA) - len(partsB)
+}
+
+// writeResult writes the result of a command. Tables have no columns for
+// arbitrary results, so they are written as JSON too.
+func writeResult(w io.Writer, result json.RawMessage) error {
+	if len(result) == 0 || string(result) == "null" {
+		_, err := fmt.Fprintln(w, "OK")
+		return err
+	}
+	var v interface{}
+	if err := json.Unmarshal(result, &v); err != nil {
+		return err
+	}
+	return writeJSON(w, v)
+}
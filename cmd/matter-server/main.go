@@ -3,15 +3,20 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/codefionn/go-matter-server/internal/config"
 	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/migrate"
 	"github.com/codefionn/go-matter-server/internal/server"
+	"github.com/codefionn/go-matter-server/internal/storage"
 )
 
 var (
@@ -19,9 +24,21 @@ var (
 	commit  = "unknown"
 )
 
+// logIdentifier is the program name of syslog and journald entries
+const logIdentifier = "matter-server"
+
+// healthcheckTimeout is the default time the healthcheck waits for the
+// readiness probe
+const healthcheckTimeout = 5 * time.Second
+
 func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
+	// A second signal kills the server instead of waiting for the shutdown
+	go func() {
+		<-ctx.Done()
+		cancel()
+	}()
 
 	if err := run(ctx, os.Args[1:]); err != nil {
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
@@ -35,44 +52,131 @@ func run(ctx context.Context, args []string) error {
 		Short:   "Go Matter Server - WebSocket-based Matter controller server",
 		Version: fmt.Sprintf("%s (%s)", version, commit),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if printDefaults, _ := cmd.Flags().GetBool("print-default-config"); printDefaults {
+				return runPrintDefaultConfig(cmd)
+			}
 			return runServer(ctx, cmd)
 		},
 	}
+	rootCmd.Flags().Bool("print-default-config", false, "Print the default configuration in the format of the config file and exit")
 
 	// Global flags
 	rootCmd.PersistentFlags().String("config", "", "config file (default is $HOME/.matter_server/config.yaml)")
 	rootCmd.PersistentFlags().String("env-file", "", "env file to load environment variables from (e.g., .env)")
 	rootCmd.PersistentFlags().String("log-level", "info", "log level (debug, info, warn, error)")
 	rootCmd.PersistentFlags().String("log-format", "console", "log format (console, json)")
+	rootCmd.PersistentFlags().String("log-output", "", "log output (stdout, file, syslog, journald; default: file with --log-file, else stdout)")
+	rootCmd.PersistentFlags().String("log-file", "", "log file, rotated by size (default: stdout)")
 
 	// Server specific flags
-	rootCmd.Flags().IntP("port", "p", 5580, "WebSocket server port")
-	rootCmd.Flags().StringSliceP("listen", "l", []string{}, "Listen addresses (default: all interfaces)")
-	rootCmd.Flags().String("storage-path", "", "Storage path for persistent data (default: $HOME/.matter_server)")
-	rootCmd.Flags().Int("vendor-id", 0xFFF1, "Vendor ID for the Fabric")
-	rootCmd.Flags().Int("fabric-id", 1, "Fabric ID for the Fabric")
-	rootCmd.Flags().String("primary-interface", "", "Primary network interface for link-local addresses")
-	rootCmd.Flags().String("paa-root-cert-dir", "", "Directory where PAA root certificates are stored")
-	rootCmd.Flags().Bool("enable-test-net-dcl", false, "Enable PAA root certificates from test-net DCL")
-	rootCmd.Flags().Int("bluetooth-adapter", -1, "Bluetooth adapter ID for direct commissioning support")
-	rootCmd.Flags().String("ota-provider-dir", "", "Directory for OTA Provider software updates")
-	rootCmd.Flags().Bool("disable-server-interactions", false, "Disable server cluster interactions")
-	rootCmd.Flags().Bool("mdns-enabled", true, "Enable mDNS hostname advertisement")
-	rootCmd.Flags().String("mdns-hostname", "", "Hostname to advertise via mDNS (default: system hostname)")
+	addServerFlags(rootCmd)
+
+	migrateCmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Import the storage of another Matter server",
+		Long: "Import nodes and the fabric root CA of python-matter-server, so devices don't need to be " +
+			"commissioned again. Stop the other server first; both must not control the fabric at the same time.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runMigrate(cmd)
+		},
+	}
+	migrateCmd.Flags().String("from-python", "", "python-matter-server chip.json or node storage file to import")
+	migrateCmd.MarkFlagRequired("from-python")
+	migrateCmd.Flags().String("storage-path", "", "Storage path for persistent data (default: $HOME/.matter_server)")
+	migrateCmd.Flags().Int("fabric-id", 1, "Fabric ID used by python-matter-server")
+	migrateCmd.Flags().Bool("force", false, "Overwrite existing nodes and fabric credentials")
+	rootCmd.AddCommand(migrateCmd)
+
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Check the configuration",
+	}
+	validateCmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Load and validate the configuration from flags, environment and config file",
+		Args:  cobra.NoArgs,
+		// Errors are about the configuration, not the usage
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runConfigValidate(cmd)
+		},
+	}
+	printCmd := &cobra.Command{
+		Use:   "print-effective",
+		Short: "Print the resolved configuration with secrets masked",
+		Long: "Print the configuration the server would run with, after applying defaults, config file, " +
+			"environment variables and flags, in the format of the config file. Secrets are masked.",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runConfigPrint(cmd)
+		},
+	}
+	// The server flags take part in the resolution like when starting the
+	// server
+	addServerFlags(validateCmd)
+	addServerFlags(printCmd)
+	configCmd.AddCommand(validateCmd, printCmd)
+	rootCmd.AddCommand(configCmd)
+
+	healthcheckCmd := &cobra.Command{
+		Use:   "healthcheck",
+		Short: "Check the readiness of a running server",
+		Long: "Query the readiness probe of the server on this host and exit with a non-zero status unless it " +
+			"is ready, e.g. as the HEALTHCHECK of a container. The port is resolved like when starting the server.",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runHealthcheck(cmd)
+		},
+	}
+	healthcheckCmd.Flags().IntP("port", "p", 5580, "WebSocket server port")
+	healthcheckCmd.Flags().String("url", "", "Readiness probe URL (default: http://127.0.0.1:<port>/health/ready)")
+	healthcheckCmd.Flags().Duration("timeout", healthcheckTimeout, "Time to wait for the answer")
+	rootCmd.AddCommand(healthcheckCmd)
 
 	return rootCmd.ExecuteContext(ctx)
 }
 
+// addServerFlags declares the flags configuring the server
+func addServerFlags(cmd *cobra.Command) {
+	cmd.Flags().IntP("port", "p", 5580, "WebSocket server port")
+	cmd.Flags().StringSliceP("listen", "l", []string{}, "Listen addresses (default: all interfaces)")
+	cmd.Flags().String("ready-file", "", "Write a JSON ready notification to this path once the server is listening")
+	cmd.Flags().StringSlice("allowed-origins", []string{}, "Origins allowed for CORS and WebSocket connections (\"*\" allows any)")
+	cmd.Flags().Bool("serve-static", false, "Serve the web dashboard at /")
+	cmd.Flags().String("static-dir", "", "Directory with dashboard files overriding the embedded ones")
+	cmd.Flags().Int("debug-port", 0, "Serve pprof and expvar on this loopback port (0 disables)")
+	cmd.Flags().Int("grpc-port", 0, "Serve the gRPC API on this port (0 disables)")
+	cmd.Flags().String("storage-path", "", "Storage path for persistent data (default: $HOME/.matter_server)")
+	cmd.Flags().Int("vendor-id", 0xFFF1, "Vendor ID for the Fabric")
+	cmd.Flags().Int("fabric-id", 1, "Fabric ID for the Fabric")
+	cmd.Flags().String("primary-interface", "", "Primary network interface for link-local addresses")
+	cmd.Flags().String("paa-root-cert-dir", "", "Directory where PAA root certificates are stored")
+	cmd.Flags().Bool("enable-test-net-dcl", false, "Enable PAA root certificates from test-net DCL")
+	cmd.Flags().Int("bluetooth-adapter", -1, "Bluetooth adapter ID for direct commissioning support")
+	cmd.Flags().String("ota-provider-dir", "", "Directory for OTA Provider software updates")
+	cmd.Flags().Bool("disable-server-interactions", false, "Disable server cluster interactions")
+	cmd.Flags().Bool("allow-untrusted-devices", false, "Accept devices failing attestation (e.g. test devices) during commissioning")
+	cmd.Flags().Bool("mdns-enabled", true, "Enable mDNS hostname advertisement")
+	cmd.Flags().String("mdns-hostname", "", "Hostname to advertise via mDNS (default: system hostname)")
+	cmd.Flags().String("ntp-server", "", "NTP server to compare the system clock against (default: none)")
+}
+
 func runServer(ctx context.Context, cmd *cobra.Command) error {
 	cfg, err := config.Load(cmd)
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	log, err := setupLogger(cfg.Log.Level, cfg.Log.Format)
+	log, closeLog, err := setupLogger(cfg.Log)
 	if err != nil {
 		return fmt.Errorf("failed to setup logger: %w", err)
 	}
+	defer closeLog()
+
+	server.Version = fmt.Sprintf("%s (%s)", version, commit)
 
 	srv, err := server.New(cfg, log)
 	if err != nil {
@@ -82,25 +186,194 @@ func runServer(ctx context.Context, cmd *cobra.Command) error {
 	return srv.Run(ctx)
 }
 
-func setupLogger(levelStr, formatStr string) (*logger.Logger, error) {
-	level, err := logger.ParseLogLevel(levelStr)
+func runMigrate(cmd *cobra.Command) error {
+	cfg, err := config.Load(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	log, closeLog, err := setupLogger(cfg.Log)
+	if err != nil {
+		return fmt.Errorf("failed to setup logger: %w", err)
+	}
+	defer closeLog()
+
+	current, previous, err := cfg.Storage.EncryptionKeys()
+	if err != nil {
+		return err
+	}
+	cipher, err := storage.NewCipher(current, previous...)
 	if err != nil {
-		return nil, fmt.Errorf("invalid log level: %w", err)
+		return err
+	}
+
+	source, _ := cmd.Flags().GetString("from-python")
+	force, _ := cmd.Flags().GetBool("force")
+	result, err := migrate.FromPython(migrate.Options{
+		Source:      source,
+		StoragePath: cfg.Storage.Path,
+		FabricID:    uint64(cfg.Matter.FabricID),
+		Force:       force,
+		Cipher:      cipher,
+		Logger:      log,
+	})
+	if err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+
+	for _, warning := range result.Warnings {
+		log.Warn(warning)
+	}
+	fields := []logger.Field{
+		logger.String("storage", cfg.Storage.Path),
+		logger.Int("nodes", result.Nodes),
+		logger.Int("vendors", result.Vendors),
+		logger.Bool("credentials", result.CredentialsImported),
+	}
+	if result.CredentialsImported {
+		fields = append(fields, logger.String("compressed_fabric_id", fmt.Sprintf("%016X", result.CompressedFabricID)))
+	}
+	log.Info("Migration from python-matter-server complete", fields...)
+	return nil
+}
+
+func runConfigValidate(cmd *cobra.Command) error {
+	cfg, err := config.Load(cmd)
+	if err != nil {
+		return err
+	}
+
+	source := cfg.File()
+	if source == "" {
+		source = "no config file"
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Configuration is valid (%s)\n", source)
+	return nil
+}
+
+func runConfigPrint(cmd *cobra.Command) error {
+	cfg, err := config.Load(cmd)
+	if err != nil {
+		return err
+	}
+
+	data, err := cfg.RedactedYAML()
+	if err != nil {
+		return fmt.Errorf("failed to print config: %w", err)
+	}
+	out := cmd.OutOrStdout()
+	if cfg.File() != "" {
+		fmt.Fprintf(out, "# Read from %s\n", cfg.File())
+	}
+	_, err = out.Write(data)
+	return err
+}
+
+func runPrintDefaultConfig(cmd *cobra.Command) error {
+	cfg, err := config.Defaults()
+	if err != nil {
+		return err
+	}
+
+	data, err := cfg.RedactedYAML()
+	if err != nil {
+		return fmt.Errorf("failed to print config: %w", err)
+	}
+	_, err = cmd.OutOrStdout().Write(data)
+	return err
+}
+
+// runHealthcheck fails unless the readiness probe of the server answers
+// with 200 OK
+func runHealthcheck(cmd *cobra.Command) error {
+	url, _ := cmd.Flags().GetString("url")
+	if url == "" {
+		cfg, err := config.Load(cmd)
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		url = fmt.Sprintf("http://127.0.0.1:%d/health/ready", cfg.Server.Port)
+	}
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return fmt.Errorf("server unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode != http.StatusOK {
+		cmd.OutOrStdout().Write(body)
+		return fmt.Errorf("server not ready: %s", resp.Status)
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), "ready")
+	return nil
+}
+
+// setupLogger creates the logger and returns a function closing its log
+// file
+func setupLogger(cfg config.LogConfig) (*logger.Logger, func(), error) {
+	level, err := logger.ParseLogLevel(cfg.Level)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid log level: %w", err)
 	}
 
 	var format logger.LogFormat
-	switch formatStr {
+	switch cfg.Format {
 	case "console":
 		format = logger.ConsoleFormat
 	case "json":
 		format = logger.JSONFormat
 	default:
-		return nil, fmt.Errorf("invalid log format: %s", formatStr)
+		return nil, nil, fmt.Errorf("invalid log format: %s", cfg.Format)
+	}
+
+	output := cfg.Output
+	if output == "" && cfg.File != "" {
+		output = "file"
+	}
+
+	logConfig := logger.Config{
+		Level:       level,
+		Format:      format,
+		HistorySize: cfg.HistorySize,
+	}
+	var closer io.Closer
+	switch output {
+	case "file":
+		file, err := logger.OpenRotatingFile(logger.RotateConfig{
+			Path:       cfg.File,
+			MaxSize:    int64(cfg.MaxSizeMB) << 20,
+			MaxBackups: cfg.MaxBackups,
+			MaxAge:     time.Duration(cfg.MaxAgeDays) * 24 * time.Hour,
+			Compress:   cfg.Compress,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		logConfig.Output, closer = file, file
+	case "syslog":
+		target, err := logger.NewSyslogTarget(logIdentifier)
+		if err != nil {
+			return nil, nil, err
+		}
+		logConfig.Target, closer = target, target
+	case "journald":
+		target, err := logger.NewJournalTarget(logIdentifier)
+		if err != nil {
+			return nil, nil, err
+		}
+		logConfig.Target, closer = target, target
+	default:
+		logConfig.Output = os.Stdout
+		logConfig.UseColors = format == logger.ConsoleFormat
 	}
 
-	return logger.New(logger.Config{
-		Level:     level,
-		Format:    format,
-		UseColors: format == logger.ConsoleFormat,
-	}), nil
+	closeLog := func() {}
+	if closer != nil {
+		closeLog = func() { closer.Close() }
+	}
+	return logger.New(logConfig), closeLog, nil
 }
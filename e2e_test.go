@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -62,6 +63,7 @@ func TestE2EHTTPEndpoints(t *testing.T) {
 	tempDir := t.TempDir()
 	cfg := createTestConfig(tempDir, 18080)           // Use specific port for testing
 	log := logger.NewConsoleLogger(logger.ErrorLevel) // Reduce log noise
+	cfg.Server.AllowedOrigins = []string{"*"}
 
 	srv, err := server.New(cfg, log)
 	if err != nil {
@@ -69,10 +71,7 @@ func TestE2EHTTPEndpoints(t *testing.T) {
 	}
 
 	// Start server
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	go srv.Run(ctx)
+	runTestServer(t, srv)
 
 	// Wait for server to start
 	time.Sleep(200 * time.Millisecond)
@@ -218,13 +217,11 @@ func TestE2EStoragePersistence(t *testing.T) {
 		t.Fatalf("Failed to create first server: %v", err)
 	}
 
-	ctx1, cancel1 := context.WithCancel(context.Background())
-	go srv1.Run(ctx1)
+	stop1 := runTestServer(t, srv1)
 	time.Sleep(100 * time.Millisecond)
 
 	// Stop first server
-	cancel1()
-	time.Sleep(100 * time.Millisecond)
+	stop1()
 
 	// Create and start second server instance with same storage
 	srv2, err := server.New(cfg, log)
@@ -232,10 +229,7 @@ func TestE2EStoragePersistence(t *testing.T) {
 		t.Fatalf("Failed to create second server: %v", err)
 	}
 
-	ctx2, cancel2 := context.WithCancel(context.Background())
-	defer cancel2()
-
-	go srv2.Run(ctx2)
+	runTestServer(t, srv2)
 	time.Sleep(100 * time.Millisecond)
 
 	// Both servers should have started successfully, indicating storage persistence works
@@ -263,11 +257,9 @@ func TestE2ELogging(t *testing.T) {
 		t.Fatalf("Failed to create server: %v", err)
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	go srv.Run(ctx)
-	time.Sleep(100 * time.Millisecond)
-	cancel()
+	stop := runTestServer(t, srv)
 	time.Sleep(100 * time.Millisecond)
+	stop()
 
 	// Check that logs were written
 	logOutput := logBuffer.String()
@@ -285,6 +277,28 @@ func TestE2ELogging(t *testing.T) {
 	}
 }
 
+// runTestServer runs srv in the background. The returned function (also
+// registered as test cleanup) stops the server and waits for its shutdown
+// so storage writes finish before the temp dir is removed.
+func runTestServer(t *testing.T, srv *server.Server) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		srv.Run(ctx)
+	}()
+
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			cancel()
+			<-done
+		})
+	}
+	t.Cleanup(stop)
+	return stop
+}
+
 // Helper function to create test configuration
 func createTestConfig(storageDir string, port int) *config.Config {
 	if port == 0 {
@@ -8,6 +8,7 @@ require (
     github.com/gorilla/websocket v1.5.0
     github.com/spf13/cobra v1.8.0
     github.com/spf13/viper v1.18.0
+    gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -32,5 +33,4 @@ require (
 	golang.org/x/sys v0.15.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
 	gopkg.in/ini.v1 v1.67.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,116 @@
+package attestation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+)
+
+// Distributed Compliance Ledger REST endpoints
+const (
+	MainNetDCLURL = "https://on.dcl.csa-iot.org"
+	TestNetDCLURL = "https://on.test-net.dcl.csa-iot.org"
+)
+
+type dclRootCertificates struct {
+	ApprovedRootCertificates struct {
+		Certs []struct {
+			Subject      string `json:"subject"`
+			SubjectKeyID string `json:"subjectKeyId"`
+		} `json:"certs"`
+	} `json:"approvedRootCertificates"`
+}
+
+type dclCertificates struct {
+	ApprovedCertificates struct {
+		Certs []struct {
+			PEMCert string `json:"pemCert"`
+			IsRoot  bool   `json:"isRoot"`
+		} `json:"certs"`
+	} `json:"approvedCertificates"`
+}
+
+// FetchFromDCL downloads all approved PAA root certificates from the DCL at
+// baseURL and adds them to the store. It returns the number of new
+// certificates.
+func (s *Store) FetchFromDCL(ctx context.Context, client *http.Client, baseURL string) (int, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	var roots dclRootCertificates
+	if err := getJSON(ctx, client, baseURL+"/dcl/pki/root-certificates", &roots); err != nil {
+		return 0, fmt.Errorf("failed to list DCL root certificates: %w", err)
+	}
+
+	added := 0
+	for _, ref := range roots.ApprovedRootCertificates.Certs {
+		certURL := fmt.Sprintf("%s/dcl/pki/certificates/%s/%s", baseURL,
+			url.PathEscape(ref.Subject), url.PathEscape(ref.SubjectKeyID))
+
+		var certs dclCertificates
+		if err := getJSON(ctx, client, certURL, &certs); err != nil {
+			s.logger.Warn("Failed to fetch DCL certificate",
+				logger.String("subject_key_id", ref.SubjectKeyID),
+				logger.ErrorField(err),
+			)
+			continue
+		}
+
+		for _, c := range certs.ApprovedCertificates.Certs {
+			if !c.IsRoot {
+				continue
+			}
+
+			parsed, err := parseCertificates([]byte(c.PEMCert))
+			if err != nil {
+				s.logger.Warn("Invalid certificate in DCL",
+					logger.String("subject_key_id", ref.SubjectKeyID),
+					logger.ErrorField(err),
+				)
+				continue
+			}
+
+			for _, cert := range parsed {
+				isNew, err := s.Add(cert)
+				if err != nil {
+					s.logger.Warn("Failed to persist PAA certificate", logger.ErrorField(err))
+				}
+				if isNew {
+					added++
+				}
+			}
+		}
+	}
+
+	s.logger.Info("Fetched PAA root certificates from DCL",
+		logger.String("url", baseURL),
+		logger.Int("new", added),
+	)
+
+	return added, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, target interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(target)
+}
@@ -0,0 +1,68 @@
+package attestation
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+)
+
+func TestFetchFromDCL(t *testing.T) {
+	pki := newTestPKI(t)
+	pemCert := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: pki.paa.Raw}))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/dcl/pki/root-certificates", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"approvedRootCertificates": map[string]interface{}{
+				"certs": []map[string]string{
+					{"subject": "MDEx/Kw==", "subjectKeyId": "AA:BB"},
+					{"subject": "missing", "subjectKeyId": "CC:DD"},
+				},
+			},
+		})
+	})
+	mux.HandleFunc("/dcl/pki/certificates/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/dcl/pki/certificates/MDEx/Kw==/AA:BB" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"approvedCertificates": map[string]interface{}{
+				"certs": []map[string]interface{}{
+					{"pemCert": pemCert, "isRoot": true},
+				},
+			},
+		})
+	})
+
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	store := NewStore(t.TempDir(), logger.NewConsoleLogger(logger.FatalLevel))
+	added, err := store.FetchFromDCL(context.Background(), ts.Client(), ts.URL)
+	if err != nil {
+		t.Fatalf("FetchFromDCL failed: %v", err)
+	}
+
+	if added != 1 {
+		t.Errorf("Expected 1 new certificate, got %d", added)
+	}
+	if store.Count() != 1 {
+		t.Errorf("Expected 1 certificate in store, got %d", store.Count())
+	}
+}
+
+func TestFetchFromDCLUnavailable(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+
+	store := NewStore("", logger.NewConsoleLogger(logger.FatalLevel))
+	if _, err := store.FetchFromDCL(context.Background(), ts.Client(), ts.URL); err == nil {
+		t.Error("Expected error when DCL is unavailable")
+	}
+}
@@ -0,0 +1,215 @@
+package attestation
+
+import (
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+)
+
+// Store holds the trusted Product Attestation Authority (PAA) root
+// certificates used to validate device attestation certificate chains.
+type Store struct {
+	dir    string
+	logger *logger.Logger
+	mu     sync.RWMutex
+	certs  map[string]*x509.Certificate // keyed by hex subject key ID
+}
+
+// NewStore creates a new PAA store backed by dir. An empty dir keeps the
+// store in memory only.
+func NewStore(dir string, log *logger.Logger) *Store {
+	return &Store{
+		dir:    dir,
+		logger: log,
+		certs:  make(map[string]*x509.Certificate),
+	}
+}
+
+// Load reads all PEM (.pem, .crt) and DER (.der) certificates from the store
+// directory. A missing directory is not an error.
+func (s *Store) Load() error {
+	if s.dir == "" {
+		return nil
+	}
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read PAA directory %s: %w", s.dir, err)
+	}
+
+	loaded := 0
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if ext != ".pem" && ext != ".crt" && ext != ".der" {
+			continue
+		}
+
+		path := filepath.Join(s.dir, entry.Name())
+		certs, err := readCertificates(path)
+		if err != nil {
+			s.logger.Warn("Skipping invalid PAA certificate", logger.String("file", path), logger.ErrorField(err))
+			continue
+		}
+
+		for _, cert := range certs {
+			if s.add(cert) {
+				loaded++
+			}
+		}
+	}
+
+	s.logger.Info("Loaded PAA root certificates",
+		logger.String("path", s.dir),
+		logger.Int("count", loaded),
+	)
+
+	return nil
+}
+
+// Add adds a PAA certificate to the store and persists it to the store
+// directory. It returns false if the certificate was already known.
+func (s *Store) Add(cert *x509.Certificate) (bool, error) {
+	if !s.add(cert) {
+		return false, nil
+	}
+
+	if s.dir == "" {
+		return true, nil
+	}
+
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return true, fmt.Errorf("failed to create PAA directory: %w", err)
+	}
+
+	path := filepath.Join(s.dir, certificateKey(cert)+".pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return true, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	return true, nil
+}
+
+// Pool returns a certificate pool with all known PAA certificates
+func (s *Store) Pool() *x509.CertPool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	pool := x509.NewCertPool()
+	for _, cert := range s.certs {
+		pool.AddCert(cert)
+	}
+	return pool
+}
+
+// Count returns the number of known PAA certificates
+func (s *Store) Count() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.certs)
+}
+
+func (s *Store) add(cert *x509.Certificate) bool {
+	key := certificateKey(cert)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.certs[key]; exists {
+		return false
+	}
+	s.certs[key] = cert
+	return true
+}
+
+// LoadCertificates reads all PEM and DER certificates from dir. It is used
+// for auxiliary trust anchors such as Certification Declaration signers.
+func LoadCertificates(dir string) ([]*x509.Certificate, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+
+	var certs []*x509.Certificate
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".pem" && ext != ".crt" && ext != ".der") {
+			continue
+		}
+
+		parsed, err := readCertificates(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", entry.Name(), err)
+		}
+		certs = append(certs, parsed...)
+	}
+
+	return certs, nil
+}
+
+func certificateKey(cert *x509.Certificate) string {
+	if len(cert.SubjectKeyId) > 0 {
+		return strings.ToUpper(hex.EncodeToString(cert.SubjectKeyId))
+	}
+	return strings.ToUpper(hex.EncodeToString(cert.Signature[:min(len(cert.Signature), 20)]))
+}
+
+func readCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseCertificates(data)
+}
+
+// parseCertificates parses one or more PEM certificates, or a single DER
+// certificate if the data is not PEM encoded.
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+
+	rest := data
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) > 0 {
+		return certs, nil
+	}
+
+	cert, err := x509.ParseCertificate(data)
+	if err != nil {
+		return nil, err
+	}
+	return []*x509.Certificate{cert}, nil
+}
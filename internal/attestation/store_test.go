@@ -0,0 +1,71 @@
+package attestation
+
+import (
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+)
+
+func TestStoreLoadDirectory(t *testing.T) {
+	dir := t.TempDir()
+	pki := newTestPKI(t)
+	other := newTestPKI(t)
+
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: pki.paa.Raw})
+	if err := os.WriteFile(filepath.Join(dir, "paa.pem"), pemData, 0644); err != nil {
+		t.Fatalf("Failed to write PEM: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "paa.der"), other.paa.Raw, 0644); err != nil {
+		t.Fatalf("Failed to write DER: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "broken.pem"), []byte("not a cert"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.txt"), []byte("ignored"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	store := NewStore(dir, logger.NewConsoleLogger(logger.FatalLevel))
+	if err := store.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if store.Count() != 2 {
+		t.Errorf("Expected 2 certificates, got %d", store.Count())
+	}
+}
+
+func TestStoreLoadMissingDirectory(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "missing"), logger.NewConsoleLogger(logger.FatalLevel))
+	if err := store.Load(); err != nil {
+		t.Errorf("Expected missing directory to be ignored, got %v", err)
+	}
+}
+
+func TestStoreAddPersists(t *testing.T) {
+	dir := t.TempDir()
+	pki := newTestPKI(t)
+	log := logger.NewConsoleLogger(logger.FatalLevel)
+
+	store := NewStore(dir, log)
+	added, err := store.Add(pki.paa)
+	if err != nil || !added {
+		t.Fatalf("Expected certificate to be added, got added=%v err=%v", added, err)
+	}
+
+	added, _ = store.Add(pki.paa)
+	if added {
+		t.Error("Expected duplicate certificate to be ignored")
+	}
+
+	reloaded := NewStore(dir, log)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if reloaded.Count() != 1 {
+		t.Errorf("Expected 1 persisted certificate, got %d", reloaded.Count())
+	}
+}
@@ -0,0 +1,289 @@
+package attestation
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"strconv"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/tlv"
+)
+
+// Matter DN attribute OIDs for vendor and product IDs
+var (
+	oidMatterVendorID  = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 37244, 2, 1}
+	oidMatterProductID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 37244, 2, 2}
+)
+
+// Certification Declaration TLV context tags
+const (
+	cdTagVendorID       = 1
+	cdTagProductIDArray = 2
+)
+
+// Result describes the outcome of a device attestation
+type Result struct {
+	VendorID  uint16 `json:"vendor_id"`
+	ProductID uint16 `json:"product_id"`
+	Trusted   bool   `json:"trusted"`
+	PAA       string `json:"paa,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// Verifier performs device attestation verification
+type Verifier struct {
+	store          *Store
+	cdSigners      []*x509.Certificate
+	allowUntrusted bool
+	logger         *logger.Logger
+}
+
+// NewVerifier creates a new device attestation verifier. When allowUntrusted
+// is set, attestation failures are logged but do not reject the device.
+func NewVerifier(store *Store, cdSigners []*x509.Certificate, allowUntrusted bool, log *logger.Logger) *Verifier {
+	return &Verifier{
+		store:          store,
+		cdSigners:      cdSigners,
+		allowUntrusted: allowUntrusted,
+		logger:         log,
+	}
+}
+
+// Verify validates the DAC -> PAI -> PAA chain and, if provided, the
+// Certification Declaration of a device. dacDER and paiDER are the DER
+// encoded Device Attestation and Product Attestation Intermediate
+// certificates; cd is the CMS signed Certification Declaration.
+func (v *Verifier) Verify(dacDER, paiDER, cd []byte) (*Result, error) {
+	result, err := v.verify(dacDER, paiDER, cd)
+	if err == nil {
+		return result, nil
+	}
+
+	if !v.allowUntrusted {
+		return nil, err
+	}
+
+	v.logger.Warn("Device attestation failed, accepting untrusted device", logger.ErrorField(err))
+	if result == nil {
+		result = &Result{}
+	}
+	result.Trusted = false
+	result.Reason = err.Error()
+	return result, nil
+}
+
+func (v *Verifier) verify(dacDER, paiDER, cd []byte) (*Result, error) {
+	dac, err := x509.ParseCertificate(dacDER)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DAC: %w", err)
+	}
+
+	pai, err := x509.ParseCertificate(paiDER)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PAI: %w", err)
+	}
+
+	vid, pid, err := vendorProduct(dac.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DAC subject: %w", err)
+	}
+	if pid == nil {
+		return nil, fmt.Errorf("DAC does not contain a product ID")
+	}
+
+	result := &Result{VendorID: *vid, ProductID: *pid}
+
+	paiVID, paiPID, err := vendorProduct(pai.Subject)
+	if err != nil {
+		return result, fmt.Errorf("invalid PAI subject: %w", err)
+	}
+	if paiVID != nil && *paiVID != *vid {
+		return result, fmt.Errorf("PAI vendor ID 0x%04X does not match DAC vendor ID 0x%04X", *paiVID, *vid)
+	}
+	if paiPID != nil && *paiPID != *pid {
+		return result, fmt.Errorf("PAI product ID 0x%04X does not match DAC product ID 0x%04X", *paiPID, *pid)
+	}
+
+	intermediates := x509.NewCertPool()
+	intermediates.AddCert(pai)
+
+	chains, err := dac.Verify(x509.VerifyOptions{
+		Roots:         v.store.Pool(),
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return result, fmt.Errorf("DAC chain validation failed: %w", err)
+	}
+
+	paa := chains[0][len(chains[0])-1]
+	result.PAA = paa.Subject.String()
+
+	paaVID, _, err := vendorProduct(paa.Subject)
+	if err == nil && paaVID != nil && *paaVID != *vid {
+		return result, fmt.Errorf("PAA vendor ID 0x%04X does not match DAC vendor ID 0x%04X", *paaVID, *vid)
+	}
+
+	if cd != nil {
+		if err := v.verifyCertificationDeclaration(cd, *vid, *pid); err != nil {
+			return result, err
+		}
+	}
+
+	result.Trusted = true
+	return result, nil
+}
+
+func (v *Verifier) verifyCertificationDeclaration(cd []byte, vid, pid uint16) error {
+	content, skid, signature, err := parseSignedData(cd)
+	if err != nil {
+		return fmt.Errorf("invalid certification declaration: %w", err)
+	}
+
+	var signer *x509.Certificate
+	for _, cert := range v.cdSigners {
+		if bytes.Equal(cert.SubjectKeyId, skid) {
+			signer = cert
+			break
+		}
+	}
+	if signer == nil {
+		return fmt.Errorf("certification declaration signed by unknown key %X", skid)
+	}
+
+	pub, ok := signer.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("certification declaration signer key is not ECDSA")
+	}
+
+	digest := sha256.Sum256(content)
+	if !ecdsa.VerifyASN1(pub, digest[:], signature) {
+		return fmt.Errorf("certification declaration signature is invalid")
+	}
+
+	decl, err := tlv.Decode(content)
+	if err != nil {
+		return fmt.Errorf("invalid certification declaration content: %w", err)
+	}
+
+	vidField, ok := decl.Field(cdTagVendorID)
+	if cdVID, _ := vidField.Uint(); !ok || cdVID != uint64(vid) {
+		return fmt.Errorf("certification declaration vendor ID does not match DAC")
+	}
+
+	pids, ok := decl.Field(cdTagProductIDArray)
+	if !ok {
+		return fmt.Errorf("certification declaration has no product IDs")
+	}
+	for _, p := range pids.Elements {
+		if cdPID, _ := p.Uint(); cdPID == uint64(pid) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("product ID 0x%04X not in certification declaration", pid)
+}
+
+// vendorProduct extracts the Matter vendor and product IDs from a subject
+func vendorProduct(name pkix.Name) (*uint16, *uint16, error) {
+	var vid, pid *uint16
+
+	for _, attr := range name.Names {
+		var target **uint16
+		switch {
+		case attr.Type.Equal(oidMatterVendorID):
+			target = &vid
+		case attr.Type.Equal(oidMatterProductID):
+			target = &pid
+		default:
+			continue
+		}
+
+		s, ok := attr.Value.(string)
+		if !ok {
+			return nil, nil, fmt.Errorf("attribute %s is not a string", attr.Type)
+		}
+		n, err := strconv.ParseUint(s, 16, 16)
+		if err != nil || len(s) != 4 {
+			return nil, nil, fmt.Errorf("invalid attribute %s value %q", attr.Type, s)
+		}
+		id := uint16(n)
+		*target = &id
+	}
+
+	if vid == nil {
+		return nil, nil, fmt.Errorf("missing vendor ID")
+	}
+
+	return vid, pid, nil
+}
+
+// CMS structures (RFC 5652) needed to verify a Certification Declaration
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo encapContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type encapContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     []byte `asn1:"explicit,optional,tag:0"`
+}
+
+type signerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+}
+
+var oidSignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+
+// parseSignedData returns the encapsulated content, the signer subject key
+// identifier and the signature of a single-signer CMS SignedData message.
+func parseSignedData(data []byte) ([]byte, []byte, []byte, error) {
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(data, &ci); err != nil {
+		return nil, nil, nil, err
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, nil, nil, fmt.Errorf("content type %s is not signed data", ci.ContentType)
+	}
+
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, nil, nil, err
+	}
+
+	if len(sd.SignerInfos) != 1 {
+		return nil, nil, nil, fmt.Errorf("expected exactly one signer, got %d", len(sd.SignerInfos))
+	}
+	si := sd.SignerInfos[0]
+
+	if len(si.SignedAttrs.FullBytes) > 0 {
+		return nil, nil, nil, fmt.Errorf("signed attributes are not supported")
+	}
+	if si.SID.Class != asn1.ClassContextSpecific || si.SID.Tag != 0 {
+		return nil, nil, nil, fmt.Errorf("signer must be identified by subject key identifier")
+	}
+	if len(sd.EncapContentInfo.EContent) == 0 {
+		return nil, nil, nil, fmt.Errorf("missing encapsulated content")
+	}
+
+	return sd.EncapContentInfo.EContent, si.SID.Bytes, si.Signature, nil
+}
@@ -0,0 +1,224 @@
+package attestation
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+)
+
+type testPKI struct {
+	paa    *x509.Certificate
+	pai    *x509.Certificate
+	dac    *x509.Certificate
+	cdCert *x509.Certificate
+	cdKey  *ecdsa.PrivateKey
+}
+
+func matterName(cn string, vid, pid string) pkix.Name {
+	name := pkix.Name{CommonName: cn}
+	if vid != "" {
+		name.ExtraNames = append(name.ExtraNames, pkix.AttributeTypeAndValue{Type: oidMatterVendorID, Value: vid})
+	}
+	if pid != "" {
+		name.ExtraNames = append(name.ExtraNames, pkix.AttributeTypeAndValue{Type: oidMatterProductID, Value: pid})
+	}
+	return name
+}
+
+func issue(t *testing.T, subject pkix.Name, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               subject,
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+	}
+	if isCA {
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	}
+
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return cert, key
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	paa, paaKey := issue(t, matterName("Test PAA", "FFF1", ""), true, nil, nil)
+	pai, paiKey := issue(t, matterName("Test PAI", "FFF1", "8000"), true, paa, paaKey)
+	dac, _ := issue(t, matterName("Test DAC", "FFF1", "8000"), false, pai, paiKey)
+	cdCert, cdKey := issue(t, pkix.Name{CommonName: "CD Signer"}, false, nil, nil)
+
+	return &testPKI{paa: paa, pai: pai, dac: dac, cdCert: cdCert, cdKey: cdKey}
+}
+
+// signCD wraps TLV content into a CMS SignedData message
+func signCD(t *testing.T, content []byte, cert *x509.Certificate, key *ecdsa.PrivateKey) []byte {
+	t.Helper()
+
+	digest := sha256.Sum256(content)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatalf("Failed to sign CD: %v", err)
+	}
+
+	sha256OID := pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}}
+	sd := signedData{
+		Version:          3,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{sha256OID},
+		EncapContentInfo: encapContentInfo{
+			EContentType: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1},
+			EContent:     content,
+		},
+		SignerInfos: []signerInfo{{
+			Version:            3,
+			SID:                asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, Bytes: cert.SubjectKeyId},
+			DigestAlgorithm:    sha256OID,
+			SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}},
+			Signature:          sig,
+		}},
+	}
+
+	sdDER, err := asn1.Marshal(sd)
+	if err != nil {
+		t.Fatalf("Failed to marshal signed data: %v", err)
+	}
+
+	out, err := asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sdDER},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal content info: %v", err)
+	}
+	return out
+}
+
+// cdContent encodes a minimal Certification Declaration TLV for vid/pid
+func cdContent(vid, pid uint16) []byte {
+	return []byte{
+		0x15,
+		0x24, 0x00, 0x01,
+		0x25, 0x01, byte(vid), byte(vid >> 8),
+		0x36, 0x02, 0x05, byte(pid), byte(pid >> 8), 0x18,
+		0x18,
+	}
+}
+
+func newTestVerifier(pki *testPKI, allowUntrusted bool) *Verifier {
+	log := logger.NewConsoleLogger(logger.FatalLevel)
+	store := NewStore("", log)
+	store.add(pki.paa)
+	return NewVerifier(store, []*x509.Certificate{pki.cdCert}, allowUntrusted, log)
+}
+
+func TestVerifyValidChain(t *testing.T) {
+	pki := newTestPKI(t)
+	v := newTestVerifier(pki, false)
+
+	cd := signCD(t, cdContent(0xFFF1, 0x8000), pki.cdCert, pki.cdKey)
+
+	result, err := v.Verify(pki.dac.Raw, pki.pai.Raw, cd)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !result.Trusted {
+		t.Error("Expected trusted result")
+	}
+	if result.VendorID != 0xFFF1 || result.ProductID != 0x8000 {
+		t.Errorf("Unexpected VID/PID: 0x%04X/0x%04X", result.VendorID, result.ProductID)
+	}
+}
+
+func TestVerifyUnknownPAA(t *testing.T) {
+	pki := newTestPKI(t)
+	other := newTestPKI(t)
+	v := newTestVerifier(other, false)
+
+	if _, err := v.Verify(pki.dac.Raw, pki.pai.Raw, nil); err == nil {
+		t.Error("Expected error for DAC chained to unknown PAA")
+	}
+}
+
+func TestVerifyAllowUntrusted(t *testing.T) {
+	pki := newTestPKI(t)
+	other := newTestPKI(t)
+	v := newTestVerifier(other, true)
+
+	result, err := v.Verify(pki.dac.Raw, pki.pai.Raw, nil)
+	if err != nil {
+		t.Fatalf("Expected untrusted device to be accepted, got %v", err)
+	}
+	if result.Trusted {
+		t.Error("Expected untrusted result")
+	}
+	if result.Reason == "" {
+		t.Error("Expected failure reason")
+	}
+}
+
+func TestVerifyCertificationDeclarationMismatch(t *testing.T) {
+	pki := newTestPKI(t)
+	v := newTestVerifier(pki, false)
+
+	tests := []struct {
+		name string
+		cd   []byte
+	}{
+		{"Wrong product", signCD(t, cdContent(0xFFF1, 0x8001), pki.cdCert, pki.cdKey)},
+		{"Wrong vendor", signCD(t, cdContent(0xFFF2, 0x8000), pki.cdCert, pki.cdKey)},
+		{"Unknown signer", signCD(t, cdContent(0xFFF1, 0x8000), pki.paa, pki.cdKey)},
+		{"Garbage", []byte{0x01, 0x02}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := v.Verify(pki.dac.Raw, pki.pai.Raw, tt.cd); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func TestVerifyPAIVendorMismatch(t *testing.T) {
+	paa, paaKey := issue(t, matterName("PAA", "FFF1", ""), true, nil, nil)
+	pai, paiKey := issue(t, matterName("PAI", "FFF2", ""), true, paa, paaKey)
+	dac, _ := issue(t, matterName("DAC", "FFF1", "8000"), false, pai, paiKey)
+
+	log := logger.NewConsoleLogger(logger.FatalLevel)
+	store := NewStore("", log)
+	store.add(paa)
+	v := NewVerifier(store, nil, false, log)
+
+	if _, err := v.Verify(dac.Raw, pai.Raw, nil); err == nil {
+		t.Error("Expected error for PAI/DAC vendor mismatch")
+	}
+}
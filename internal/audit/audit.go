@@ -0,0 +1,190 @@
+// Package audit records state-changing commands with the client that sent
+// them in an append-only file of JSON lines.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+type clientKey struct{}
+
+// Client identifies who sent a command
+type Client struct {
+	// ConnectionID is the WebSocket connection, empty for REST requests
+	ConnectionID  string
+	RemoteAddress string
+
+	// BearerToken is the token the client authenticated with, empty if
+	// none. It authorizes commands requiring a token and isn't recorded.
+	BearerToken string
+}
+
+// WithClient returns a context for handling a command sent by client
+func WithClient(ctx context.Context, client Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// ClientFromContext returns the client of a command, the zero Client if
+// unknown
+func ClientFromContext(ctx context.Context) Client {
+	client, _ := ctx.Value(clientKey{}).(Client)
+	return client
+}
+
+// Log is an append-only audit log. Each entry is a line of JSON, so a line
+// torn by a crash only loses that entry.
+type Log struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// Open opens the audit log at path, creating it if needed
+func Open(path string) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	// Arguments may identify devices and networks, keep them private
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	if err := terminateLine(path, file); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &Log{path: path, file: file}, nil
+}
+
+// terminateLine ends a line torn by a crash, so the next entry doesn't
+// become part of it
+func terminateLine(path string, file *os.File) error {
+	reader, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer reader.Close()
+
+	info, err := reader.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+	last := make([]byte, 1)
+	if _, err := reader.ReadAt(last, info.Size()-1); err != nil {
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+	if last[0] != '\n' {
+		if _, err := file.Write([]byte{'\n'}); err != nil {
+			return fmt.Errorf("failed to write audit log: %w", err)
+		}
+	}
+	return nil
+}
+
+// Record appends an entry
+func (l *Log) Record(entry models.AuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return os.ErrClosed
+	}
+	if _, err := l.file.Write(data); err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	return nil
+}
+
+// Filter selects audit entries. Zero values match all entries.
+type Filter struct {
+	Since        time.Time
+	Commands     []string
+	ConnectionID string
+	// Limit keeps the newest entries only
+	Limit int
+}
+
+func (f Filter) matches(entry models.AuditEntry) bool {
+	if !f.Since.IsZero() && entry.Timestamp.Before(f.Since) {
+		return false
+	}
+	if f.ConnectionID != "" && entry.ConnectionID != f.ConnectionID {
+		return false
+	}
+	if len(f.Commands) == 0 {
+		return true
+	}
+	for _, command := range f.Commands {
+		if entry.Command == command {
+			return true
+		}
+	}
+	return false
+}
+
+// Entries returns the entries matching filter, oldest first. Lines that
+// aren't valid entries, e.g. torn by a crash, are skipped.
+func (l *Log) Entries(filter Filter) ([]models.AuditEntry, error) {
+	file, err := os.Open(l.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	entries := []models.AuditEntry{}
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			var entry models.AuditEntry
+			if json.Unmarshal(line, &entry) == nil && filter.matches(entry) {
+				entries = append(entries, entry)
+				if filter.Limit > 0 && len(entries) > 2*filter.Limit {
+					// Bound the memory of reading a long log
+					entries = append(entries[:0], entries[len(entries)-filter.Limit:]...)
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read audit log: %w", err)
+		}
+	}
+
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[len(entries)-filter.Limit:]
+	}
+	return entries, nil
+}
+
+// Close closes the audit log. Later entries are rejected.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
@@ -0,0 +1,93 @@
+package audit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+func TestLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage", "audit.jsonl")
+	log, err := Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open audit log: %v", err)
+	}
+
+	start := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	for i, command := range []string{"device_command", "write_attribute", "device_command", "remove_node"} {
+		entry := models.AuditEntry{
+			Timestamp:    start.Add(time.Duration(i) * time.Minute),
+			Command:      command,
+			ConnectionID: "conn-a",
+			Outcome:      models.AuditOutcomeSuccess,
+		}
+		if i == 3 {
+			entry.ConnectionID = "conn-b"
+		}
+		if err := log.Record(entry); err != nil {
+			t.Fatalf("Failed to record: %v", err)
+		}
+	}
+	log.Close()
+	if err := log.Record(models.AuditEntry{}); err == nil {
+		t.Error("Expected an error recording to a closed log")
+	}
+
+	// A line torn by a crash
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	file.WriteString(`{"command":"remove_no`)
+	file.Close()
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []time.Duration
+	}{
+		{"All", Filter{}, []time.Duration{0, time.Minute, 2 * time.Minute, 3 * time.Minute}},
+		{"Command", Filter{Commands: []string{"device_command"}}, []time.Duration{0, 2 * time.Minute}},
+		{"Connection", Filter{ConnectionID: "conn-b"}, []time.Duration{3 * time.Minute}},
+		{"Since", Filter{Since: start.Add(90 * time.Second)}, []time.Duration{2 * time.Minute, 3 * time.Minute}},
+		{"Limit", Filter{Limit: 1}, []time.Duration{3 * time.Minute}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := log.Entries(tt.filter)
+			if err != nil {
+				t.Fatalf("Failed to read entries: %v", err)
+			}
+			if len(entries) != len(tt.want) {
+				t.Fatalf("Expected %d entries, got %+v", len(tt.want), entries)
+			}
+			for i, offset := range tt.want {
+				if !entries[i].Timestamp.Equal(start.Add(offset)) {
+					t.Errorf("Expected the entry at %s, got %s", start.Add(offset), entries[i].Timestamp)
+				}
+			}
+		})
+	}
+
+	// Reopening appends
+	log, err = Open(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen audit log: %v", err)
+	}
+	defer log.Close()
+	log.Record(models.AuditEntry{Command: "create_group", Outcome: models.AuditOutcomeError})
+	if entries, _ := log.Entries(Filter{}); len(entries) != 5 || entries[4].Command != "create_group" {
+		t.Errorf("Expected the appended entry last, got %+v", entries)
+	}
+}
+
+func TestClientFromContext(t *testing.T) {
+	if client := ClientFromContext(context.Background()); client != (Client{}) {
+		t.Errorf("Expected no client, got %+v", client)
+	}
+	client := Client{ConnectionID: "conn-a", RemoteAddress: "192.0.2.1:5000"}
+	if got := ClientFromContext(WithClient(context.Background(), client)); got != client {
+		t.Errorf("Expected %+v, got %+v", client, got)
+	}
+}
@@ -0,0 +1,270 @@
+// Package availability periodically probes commissioned nodes and tracks
+// whether they are online.
+package availability
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+
+	powerSourceClusterID   = 0x002F
+	icdManagementClusterID = 0x0046
+	featureMapAttributeID  = 0xFFFC
+
+	powerSourceFeatureWired   = 1 << 0
+	powerSourceFeatureBattery = 1 << 1
+)
+
+// ProbeFunc checks whether a node is reachable. An error means reachability
+// could not be determined (e.g. no controller) and leaves the node as is.
+type ProbeFunc func(ctx context.Context, nodeID int) (bool, error)
+
+// Config holds configuration for the availability monitor
+type Config struct {
+	// MainsInterval and BatteryInterval are the probe intervals for mains
+	// and battery powered nodes. Zero disables monitoring for that type.
+	MainsInterval   time.Duration
+	BatteryInterval time.Duration
+	Timeout         time.Duration
+
+	ListNodes    func() []*models.MatterNodeData
+	SetAvailable func(nodeID int, available bool) error
+	Probe        ProbeFunc
+	Logger       *logger.Logger
+}
+
+// Monitor periodically probes nodes and updates their availability
+type Monitor struct {
+	config Config
+	logger *logger.Logger
+	now    func() time.Time
+
+	mu        sync.Mutex
+	lastProbe map[int]time.Time
+
+	// runMu serializes Start, Stop and SetIntervals
+	runMu  sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewMonitor creates a new availability monitor
+func NewMonitor(config Config) *Monitor {
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	if config.Logger == nil {
+		config.Logger = logger.NewConsoleLogger(logger.InfoLevel)
+	}
+
+	return &Monitor{
+		config:    config,
+		logger:    config.Logger,
+		now:       time.Now,
+		lastProbe: make(map[int]time.Time),
+	}
+}
+
+// Start begins periodic probing. It does nothing if both intervals are zero.
+func (m *Monitor) Start(ctx context.Context) {
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+	m.ctx = ctx
+	m.start()
+}
+
+func (m *Monitor) start() {
+	tick := m.tickInterval()
+	if tick <= 0 {
+		m.logger.Info("Node availability monitoring disabled")
+		return
+	}
+
+	var ctx context.Context
+	ctx, m.cancel = context.WithCancel(m.ctx)
+	m.done = make(chan struct{})
+
+	go func() {
+		defer close(m.done)
+
+		ticker := time.NewTicker(tick)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.CheckDue(ctx)
+			}
+		}
+	}()
+}
+
+// Stop stops periodic probing
+func (m *Monitor) Stop() {
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+	m.stop()
+	m.ctx = nil
+}
+
+func (m *Monitor) stop() {
+	if m.cancel == nil {
+		return
+	}
+	m.cancel()
+	<-m.done
+	m.cancel = nil
+}
+
+// SetIntervals changes the probe intervals, restarting periodic probing if
+// it was started
+func (m *Monitor) SetIntervals(mains, battery time.Duration) {
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+
+	m.mu.Lock()
+	m.config.MainsInterval, m.config.BatteryInterval = mains, battery
+	m.mu.Unlock()
+
+	if m.ctx != nil {
+		m.stop()
+		m.start()
+	}
+}
+
+// Intervals returns the probe intervals for mains and battery powered nodes
+func (m *Monitor) Intervals() (mains, battery time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.config.MainsInterval, m.config.BatteryInterval
+}
+
+// CheckDue probes all nodes whose interval has elapsed since their last probe
+func (m *Monitor) CheckDue(ctx context.Context) {
+	now := m.now()
+	mains, battery := m.Intervals()
+
+	for _, node := range m.config.ListNodes() {
+		// Sleepy nodes don't answer between check-ins
+		if node.Sleepy {
+			continue
+		}
+
+		interval := mains
+		if IsBatteryPowered(node.Attributes) {
+			interval = battery
+		}
+		if interval <= 0 {
+			continue
+		}
+
+		m.mu.Lock()
+		last, probed := m.lastProbe[node.NodeID]
+		due := !probed || now.Sub(last) >= interval
+		if due {
+			m.lastProbe[node.NodeID] = now
+		}
+		m.mu.Unlock()
+
+		if due {
+			m.CheckNode(ctx, node)
+		}
+	}
+}
+
+// CheckNode probes a single node and updates its availability if it changed
+func (m *Monitor) CheckNode(ctx context.Context, node *models.MatterNodeData) {
+	probeCtx, cancel := context.WithTimeout(ctx, m.config.Timeout)
+	defer cancel()
+
+	available, err := m.config.Probe(probeCtx, node.NodeID)
+	if err != nil {
+		m.logger.Debug("Skipping availability check", logger.Int("node_id", node.NodeID), logger.ErrorField(err))
+		return
+	}
+	if available == node.Available {
+		return
+	}
+
+	if available {
+		m.logger.Info("Node is back online", logger.Int("node_id", node.NodeID))
+	} else {
+		m.logger.Warn("Node went offline", logger.Int("node_id", node.NodeID))
+	}
+
+	if err := m.config.SetAvailable(node.NodeID, available); err != nil {
+		m.logger.Error("Failed to update node availability", logger.Int("node_id", node.NodeID), logger.ErrorField(err))
+	}
+}
+
+func (m *Monitor) tickInterval() time.Duration {
+	tick, battery := m.Intervals()
+	if tick <= 0 || (battery > 0 && battery < tick) {
+		tick = battery
+	}
+	return tick
+}
+
+// IsBatteryPowered reports whether a node runs on battery, based on its
+// Power Source feature maps (battery without a wired source) or the presence
+// of the ICD Management cluster.
+func IsBatteryPowered(attributes map[string]interface{}) bool {
+	battery, wired := false, false
+
+	for path, value := range attributes {
+		parts := strings.Split(path, "/")
+		if len(parts) != 3 {
+			continue
+		}
+		cluster, err := strconv.ParseUint(parts[1], 10, 32)
+		if err != nil {
+			continue
+		}
+
+		switch cluster {
+		case icdManagementClusterID:
+			return true
+		case powerSourceClusterID:
+			if parts[2] != strconv.Itoa(featureMapAttributeID) {
+				continue
+			}
+			features, ok := featureMap(value)
+			if !ok {
+				continue
+			}
+			if features&powerSourceFeatureWired != 0 {
+				wired = true
+			}
+			if features&powerSourceFeatureBattery != 0 {
+				battery = true
+			}
+		}
+	}
+
+	return battery && !wired
+}
+
+func featureMap(v interface{}) (uint64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return uint64(n), true
+	case int:
+		return uint64(n), true
+	case uint64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
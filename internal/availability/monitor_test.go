@@ -0,0 +1,209 @@
+package availability
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+type fakeNodes struct {
+	mu      sync.Mutex
+	nodes   map[int]*models.MatterNodeData
+	changes []bool
+	probes  []int
+	online  map[int]bool
+	err     error
+}
+
+func newFakeNodes(nodes ...*models.MatterNodeData) *fakeNodes {
+	f := &fakeNodes{nodes: make(map[int]*models.MatterNodeData), online: make(map[int]bool)}
+	for _, n := range nodes {
+		f.nodes[n.NodeID] = n
+	}
+	return f
+}
+
+func (f *fakeNodes) list() []*models.MatterNodeData {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]*models.MatterNodeData, 0, len(f.nodes))
+	for _, n := range f.nodes {
+		c := *n
+		out = append(out, &c)
+	}
+	return out
+}
+
+func (f *fakeNodes) setAvailable(nodeID int, available bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nodes[nodeID].Available = available
+	f.changes = append(f.changes, available)
+	return nil
+}
+
+func (f *fakeNodes) probe(ctx context.Context, nodeID int) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.probes = append(f.probes, nodeID)
+	return f.online[nodeID], f.err
+}
+
+func newTestMonitor(f *fakeNodes, mains, battery time.Duration) *Monitor {
+	return NewMonitor(Config{
+		MainsInterval:   mains,
+		BatteryInterval: battery,
+		ListNodes:       f.list,
+		SetAvailable:    f.setAvailable,
+		Probe:           f.probe,
+		Logger:          logger.NewConsoleLogger(logger.FatalLevel),
+	})
+}
+
+func TestCheckNodeTransitions(t *testing.T) {
+	f := newFakeNodes(&models.MatterNodeData{NodeID: 1, Available: true})
+	m := newTestMonitor(f, time.Minute, time.Hour)
+
+	f.online[1] = false
+	m.CheckNode(context.Background(), f.list()[0])
+	if f.nodes[1].Available {
+		t.Error("Expected node to be marked offline")
+	}
+
+	// No change, no update
+	m.CheckNode(context.Background(), f.list()[0])
+	if len(f.changes) != 1 {
+		t.Errorf("Expected 1 availability change, got %d", len(f.changes))
+	}
+
+	f.online[1] = true
+	m.CheckNode(context.Background(), f.list()[0])
+	if !f.nodes[1].Available || len(f.changes) != 2 {
+		t.Errorf("Expected node back online after 2 changes, got %v", f.changes)
+	}
+}
+
+func TestCheckNodeProbeError(t *testing.T) {
+	f := newFakeNodes(&models.MatterNodeData{NodeID: 1, Available: true})
+	f.err = errors.New("no controller")
+	m := newTestMonitor(f, time.Minute, time.Hour)
+
+	m.CheckNode(context.Background(), f.list()[0])
+	if !f.nodes[1].Available || len(f.changes) != 0 {
+		t.Error("Expected probe errors to leave availability unchanged")
+	}
+}
+
+func TestCheckDueIntervals(t *testing.T) {
+	mains := &models.MatterNodeData{NodeID: 1, Attributes: map[string]interface{}{"0/47/65532": float64(powerSourceFeatureWired)}}
+	battery := &models.MatterNodeData{NodeID: 2, Attributes: map[string]interface{}{"1/47/65532": float64(powerSourceFeatureBattery)}}
+	f := newFakeNodes(mains, battery)
+	m := newTestMonitor(f, time.Minute, 10*time.Minute)
+
+	now := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+
+	m.CheckDue(context.Background())
+	if len(f.probes) != 2 {
+		t.Fatalf("Expected both nodes probed initially, got %v", f.probes)
+	}
+
+	now = now.Add(2 * time.Minute)
+	f.probes = nil
+	m.CheckDue(context.Background())
+	if len(f.probes) != 1 || f.probes[0] != 1 {
+		t.Errorf("Expected only mains node probed after 2m, got %v", f.probes)
+	}
+
+	now = now.Add(10 * time.Minute)
+	f.probes = nil
+	m.CheckDue(context.Background())
+	if len(f.probes) != 2 {
+		t.Errorf("Expected both nodes probed after 12m, got %v", f.probes)
+	}
+}
+
+func TestCheckDueDisabledType(t *testing.T) {
+	battery := &models.MatterNodeData{NodeID: 2, Attributes: map[string]interface{}{"0/70/0": float64(300)}}
+	f := newFakeNodes(battery)
+	m := newTestMonitor(f, time.Minute, 0)
+
+	m.CheckDue(context.Background())
+	if len(f.probes) != 0 {
+		t.Errorf("Expected battery node not to be probed, got %v", f.probes)
+	}
+}
+
+func TestCheckDueSleepy(t *testing.T) {
+	sleepy := &models.MatterNodeData{NodeID: 3, Available: true, Sleepy: true, Attributes: map[string]interface{}{"0/70/0": float64(3600)}}
+	f := newFakeNodes(sleepy)
+	m := newTestMonitor(f, time.Minute, time.Minute)
+
+	m.CheckDue(context.Background())
+	if len(f.probes) != 0 || !f.nodes[3].Available {
+		t.Errorf("Expected sleepy node not to be probed, got %v", f.probes)
+	}
+}
+
+func TestIsBatteryPowered(t *testing.T) {
+	tests := []struct {
+		name       string
+		attributes map[string]interface{}
+		expected   bool
+	}{
+		{"no power source", map[string]interface{}{"0/40/0": float64(17)}, false},
+		{"wired", map[string]interface{}{"0/47/65532": float64(1)}, false},
+		{"battery", map[string]interface{}{"1/47/65532": float64(2)}, true},
+		{"battery and wired", map[string]interface{}{"1/47/65532": float64(2), "2/47/65532": float64(1)}, false},
+		{"icd", map[string]interface{}{"0/70/0": float64(300)}, true},
+		{"integer feature map", map[string]interface{}{"1/47/65532": 6}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if actual := IsBatteryPowered(tt.attributes); actual != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestStartStopDisabled(t *testing.T) {
+	m := newTestMonitor(newFakeNodes(), 0, 0)
+	m.Start(context.Background())
+	m.Stop()
+}
+
+func TestSetIntervals(t *testing.T) {
+	f := newFakeNodes(&models.MatterNodeData{NodeID: 1, Available: true})
+	f.online[1] = true
+	m := newTestMonitor(f, 0, 0)
+	m.Start(context.Background())
+	defer m.Stop()
+
+	// Enabling monitoring at runtime starts probing
+	m.SetIntervals(5*time.Millisecond, 0)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		f.mu.Lock()
+		probes := len(f.probes)
+		f.mu.Unlock()
+		if probes > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for a probe")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	m.SetIntervals(0, 0)
+	if mains, battery := m.Intervals(); mains != 0 || battery != 0 {
+		t.Errorf("Expected monitoring disabled, got %v and %v", mains, battery)
+	}
+}
@@ -0,0 +1,456 @@
+package bluetooth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+const (
+	bluezService        = "org.bluez"
+	bluezAdapter        = "org.bluez.Adapter1"
+	bluezDevice         = "org.bluez.Device1"
+	bluezCharacteristic = "org.bluez.GattCharacteristic1"
+	objectManager       = "org.freedesktop.DBus.ObjectManager"
+	errorServiceUnknown = "org.freedesktop.DBus.Error.ServiceUnknown"
+	errorNameHasNoOwner = "org.freedesktop.DBus.Error.NameHasNoOwner"
+)
+
+// UUIDs of the BTP characteristics C1, written by the client, and C2,
+// indicated by the device
+const (
+	btpC1UUID = "18ee2ef5-263d-4559-959f-4f9c429f9d11"
+	btpC2UUID = "18ee2ef5-263d-4559-959f-4f9c429f9d12"
+)
+
+// Adapters are registered and removed by BlueZ with the ObjectManager
+// signals, and report being powered on and off with PropertiesChanged. BlueZ
+// itself restarting shows up as owner change of its name.
+var matchRules = []string{
+	"type='signal',sender='org.bluez',interface='org.freedesktop.DBus.ObjectManager'",
+	"type='signal',sender='org.bluez',interface='org.freedesktop.DBus.Properties',member='PropertiesChanged',arg0='org.bluez.Adapter1'",
+	"type='signal',sender='org.freedesktop.DBus',interface='org.freedesktop.DBus',member='NameOwnerChanged',arg0='org.bluez'",
+}
+
+// Devices found and updated during discovery
+var scanMatchRules = []string{
+	"type='signal',sender='org.bluez',interface='org.freedesktop.DBus.ObjectManager',member='InterfacesAdded'",
+	"type='signal',sender='org.bluez',interface='org.freedesktop.DBus.Properties',member='PropertiesChanged',arg0='org.bluez.Device1'",
+}
+
+// errNoBlueZ is returned by listAdapters while BlueZ isn't running
+var errNoBlueZ = errors.New("BlueZ is not running")
+
+// bluezBackend accesses the adapters of BlueZ on the system bus
+type bluezBackend struct{}
+
+// NewBlueZBackend returns the backend using BlueZ on the system bus
+func NewBlueZBackend() Backend {
+	return bluezBackend{}
+}
+
+// dialBlueZ connects to the system bus and subscribes to signals
+func dialBlueZ(rules []string) (*busConn, error) {
+	conn, err := dialBus(systemBusAddress())
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		if _, err := conn.call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "AddMatch", "s", rule); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// Watch lists the adapters again on each signal about them, until the
+// connection fails or ctx is done
+func (bluezBackend) Watch(ctx context.Context, update func([]models.BluetoothAdapter, string)) error {
+	conn, err := dialBlueZ(matchRules)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	for {
+		adapters, err := listAdapters(conn)
+		switch {
+		case errors.Is(err, errNoBlueZ):
+			// BlueZ starting shows up as NameOwnerChanged
+			update(nil, models.BluetoothReasonBlueZUnavailable)
+		case err != nil:
+			return err
+		default:
+			update(adapters, "")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-conn.done:
+			return conn.Err()
+		case <-conn.signaled:
+			conn.takeSignals()
+		}
+	}
+}
+
+// managedObjects returns the objects of BlueZ as a{oa{sa{sv}}}
+func managedObjects(conn *busConn) ([]interface{}, error) {
+	reply, err := conn.call(bluezService, "/", objectManager, "GetManagedObjects", "")
+	var dbusErr *dbusError
+	if errors.As(err, &dbusErr) && (dbusErr.name == errorServiceUnknown || dbusErr.name == errorNameHasNoOwner) {
+		return nil, errNoBlueZ
+	}
+	if err != nil {
+		return nil, err
+	}
+	if reply.sig != "a{oa{sa{sv}}}" {
+		return nil, errors.New("unexpected GetManagedObjects reply " + reply.sig)
+	}
+	return reply.body[0].([]interface{}), nil
+}
+
+// listAdapters returns the adapters BlueZ manages with their properties
+func listAdapters(conn *busConn) ([]models.BluetoothAdapter, error) {
+	objects, err := managedObjects(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	var adapters []models.BluetoothAdapter
+	for _, object := range objects {
+		entry := object.([]interface{})
+		for _, i := range entry[1].([]interface{}) {
+			iface := i.([]interface{})
+			if iface[0] != bluezAdapter {
+				continue
+			}
+			adapter := models.BluetoothAdapter{ID: path.Base(string(entry[0].(objectPath)))}
+			for _, p := range iface[1].([]interface{}) {
+				property := p.([]interface{})
+				switch value := property[1].(variant).value; property[0] {
+				case "Address":
+					adapter.Address, _ = value.(string)
+				case "Alias":
+					adapter.Name, _ = value.(string)
+				case "Powered":
+					adapter.Powered, _ = value.(bool)
+				case "Discovering":
+					adapter.Discovering, _ = value.(bool)
+				}
+			}
+			adapters = append(adapters, adapter)
+		}
+	}
+	return adapters, nil
+}
+
+// scannedDevice holds the properties of a device, which BlueZ reports in
+// parts
+type scannedDevice struct {
+	address     string
+	name        string
+	rssi        *int
+	serviceData []byte
+	// Seen during this scan, devices BlueZ knew before may be gone
+	seen bool
+	// Last reported result
+	reported *models.CommissionableNodeData
+}
+
+// Scan runs discovery filtered to the Matter service
+func (bluezBackend) Scan(ctx context.Context, adapter string, timeout time.Duration, found func(models.CommissionableNodeData)) ([]models.CommissionableNodeData, error) {
+	adapterPath := objectPath("/org/bluez/" + adapter)
+
+	conn, err := dialBlueZ(scanMatchRules)
+	if err != nil {
+		return nil, err
+	}
+	// Discovery started by a client stops when it disconnects
+	defer conn.Close()
+
+	// Devices BlueZ knows already only report changed properties
+	devices := make(map[objectPath]*scannedDevice)
+	objects, err := managedObjects(conn)
+	if err != nil {
+		return nil, err
+	}
+	for _, object := range objects {
+		entry := object.([]interface{})
+		updateDevice(devices, entry[0].(objectPath), entry[1].([]interface{}), false)
+	}
+
+	filter := []interface{}{
+		[]interface{}{"UUIDs", variant{sig: "as", value: []interface{}{MatterServiceUUID}}},
+		[]interface{}{"Transport", variant{sig: "s", value: "le"}},
+	}
+	if _, err := conn.call(bluezService, adapterPath, bluezAdapter, "SetDiscoveryFilter", "a{sv}", filter); err != nil {
+		return nil, fmt.Errorf("failed to set discovery filter: %w", err)
+	}
+	if _, err := conn.call(bluezService, adapterPath, bluezAdapter, "StartDiscovery", ""); err != nil {
+		return nil, fmt.Errorf("failed to start discovery: %w", err)
+	}
+	defer conn.call(bluezService, adapterPath, bluezAdapter, "StopDiscovery", "")
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	var order []objectPath
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-conn.done:
+			return nil, conn.Err()
+		case <-timer.C:
+			results := []models.CommissionableNodeData{}
+			for _, path := range order {
+				results = append(results, *devices[path].reported)
+			}
+			return results, nil
+		case <-conn.signaled:
+		}
+
+		for _, signal := range conn.takeSignals() {
+			var path objectPath
+			switch {
+			case signal.member == "InterfacesAdded" && signal.sig == "oa{sa{sv}}":
+				path = signal.body[0].(objectPath)
+				updateDevice(devices, path, signal.body[1].([]interface{}), true)
+			case signal.member == "PropertiesChanged" && signal.sig == "sa{sv}as":
+				path = signal.path
+				iface := []interface{}{signal.body[0], signal.body[1]}
+				updateDevice(devices, path, []interface{}{iface}, true)
+			default:
+				continue
+			}
+
+			device, ok := devices[path]
+			if !ok || !device.seen {
+				continue
+			}
+			result, ok := commissionableNode(device.serviceData, device.address, device.name, device.rssi)
+			if !ok || device.reported != nil && sameResult(device.reported, &result) {
+				continue
+			}
+			if device.reported == nil {
+				order = append(order, path)
+			}
+			device.reported = &result
+			if found != nil {
+				found(result)
+			}
+		}
+	}
+}
+
+// updateDevice merges the Device1 properties of interfaces, a{sa{sv}}, into
+// the device at path
+func updateDevice(devices map[objectPath]*scannedDevice, path objectPath, interfaces []interface{}, seen bool) {
+	for _, i := range interfaces {
+		iface := i.([]interface{})
+		if iface[0] != bluezDevice {
+			continue
+		}
+		device, ok := devices[path]
+		if !ok {
+			device = &scannedDevice{}
+			devices[path] = device
+		}
+		device.seen = device.seen || seen
+
+		for _, p := range iface[1].([]interface{}) {
+			property := p.([]interface{})
+			value := property[1].(variant).value
+			switch property[0] {
+			case "Address":
+				device.address, _ = value.(string)
+			case "Name":
+				device.name, _ = value.(string)
+			case "RSSI":
+				if rssi, ok := value.(int16); ok {
+					v := int(rssi)
+					device.rssi = &v
+				}
+			case "ServiceData":
+				entries, _ := value.([]interface{})
+				for _, e := range entries {
+					entry := e.([]interface{})
+					if uuid, _ := entry[0].(string); strings.EqualFold(uuid, MatterServiceUUID) {
+						device.serviceData = byteArray(entry[1].(variant).value)
+					}
+				}
+			}
+		}
+	}
+}
+
+// byteArray converts a decoded "ay" value
+func byteArray(value interface{}) []byte {
+	elems, _ := value.([]interface{})
+	data := make([]byte, 0, len(elems))
+	for _, elem := range elems {
+		if b, ok := elem.(byte); ok {
+			data = append(data, b)
+		}
+	}
+	return data
+}
+
+// byteValue converts bytes to an "ay" value
+func byteValue(data []byte) []interface{} {
+	value := make([]interface{}, len(data))
+	for i, b := range data {
+		value[i] = b
+	}
+	return value
+}
+
+// sameResult reports whether a result tells nothing new. RSSI changes with
+// every advertisement and is left out.
+func sameResult(a, b *models.CommissionableNodeData) bool {
+	equal := func(x, y *int) bool { return (x == nil) == (y == nil) && (x == nil || *x == *y) }
+	equalString := func(x, y *string) bool { return (x == nil) == (y == nil) && (x == nil || *x == *y) }
+	return equal(a.LongDiscriminator, b.LongDiscriminator) &&
+		equal(a.VendorID, b.VendorID) &&
+		equal(a.ProductID, b.ProductID) &&
+		equalString(a.BLEAddress, b.BLEAddress) &&
+		equalString(a.DeviceName, b.DeviceName)
+}
+
+// bluezConn is a GATT connection through BlueZ
+type bluezConn struct {
+	conn   *busConn
+	device objectPath
+	c1, c2 objectPath
+	// Indications received but not returned yet
+	pending [][]byte
+}
+
+// Connect connects to a device and subscribes to indications of C2
+func (bluezBackend) Connect(ctx context.Context, adapter, address string) (Conn, error) {
+	device := objectPath("/org/bluez/" + adapter + "/dev_" + strings.ReplaceAll(strings.ToUpper(address), ":", "_"))
+	conn, err := dialBlueZ([]string{
+		"type='signal',sender='org.bluez',interface='org.freedesktop.DBus.Properties',member='PropertiesChanged',path_namespace='" + string(device) + "'",
+		"type='signal',sender='org.bluez',interface='org.freedesktop.DBus.ObjectManager',member='InterfacesAdded'",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c := &bluezConn{conn: conn, device: device}
+	if _, err := conn.call(bluezService, device, bluezDevice, "Connect", ""); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+	if err := c.resolve(ctx); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if _, err := conn.call(bluezService, c.c2, bluezCharacteristic, "StartNotify", ""); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("failed to subscribe to C2 of %s: %w", address, err)
+	}
+	return c, nil
+}
+
+// resolve waits for the services of the device to be resolved and finds C1
+// and C2
+func (c *bluezConn) resolve(ctx context.Context) error {
+	for {
+		objects, err := managedObjects(c.conn)
+		if err != nil {
+			return err
+		}
+		for _, object := range objects {
+			entry := object.([]interface{})
+			objPath := entry[0].(objectPath)
+			if !strings.HasPrefix(string(objPath), string(c.device)+"/") {
+				continue
+			}
+			for _, i := range entry[1].([]interface{}) {
+				iface := i.([]interface{})
+				if iface[0] != bluezCharacteristic {
+					continue
+				}
+				for _, p := range iface[1].([]interface{}) {
+					property := p.([]interface{})
+					if property[0] != "UUID" {
+						continue
+					}
+					uuid, _ := property[1].(variant).value.(string)
+					switch strings.ToLower(uuid) {
+					case btpC1UUID:
+						c.c1 = objPath
+					case btpC2UUID:
+						c.c2 = objPath
+					}
+				}
+			}
+		}
+		if c.c1 != "" && c.c2 != "" {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("Matter service of %s not found: %w", c.device, ctx.Err())
+		case <-c.conn.done:
+			return c.conn.Err()
+		case <-c.conn.signaled:
+			c.conn.takeSignals()
+		}
+	}
+}
+
+// Write writes a packet to C1 with a write request
+func (c *bluezConn) Write(ctx context.Context, packet []byte) error {
+	options := []interface{}{[]interface{}{"type", variant{sig: "s", value: "request"}}}
+	_, err := c.conn.call(bluezService, c.c1, bluezCharacteristic, "WriteValue", "aya{sv}", byteValue(packet), options)
+	return err
+}
+
+// Receive returns the next indication of C2
+func (c *bluezConn) Receive(ctx context.Context) ([]byte, error) {
+	for len(c.pending) == 0 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-c.conn.done:
+			return nil, c.conn.Err()
+		case <-c.conn.signaled:
+		}
+
+		for _, signal := range c.conn.takeSignals() {
+			if signal.member != "PropertiesChanged" || signal.sig != "sa{sv}as" || signal.path != c.c2 || signal.body[0] != bluezCharacteristic {
+				continue
+			}
+			for _, p := range signal.body[1].([]interface{}) {
+				property := p.([]interface{})
+				if property[0] == "Value" {
+					c.pending = append(c.pending, byteArray(property[1].(variant).value))
+				}
+			}
+		}
+	}
+
+	packet := c.pending[0]
+	c.pending = c.pending[1:]
+	return packet, nil
+}
+
+// Close unsubscribes and disconnects from the device
+func (c *bluezConn) Close() error {
+	if c.c2 != "" {
+		c.conn.call(bluezService, c.c2, bluezCharacteristic, "StopNotify", "")
+	}
+	_, err := c.conn.call(bluezService, c.device, bluezDevice, "Disconnect", "")
+	c.conn.Close()
+	return err
+}
@@ -0,0 +1,787 @@
+package bluetooth
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Minimal D-Bus client, enough to list the BlueZ adapters on the system bus
+// and follow the signals about them. See the D-Bus specification,
+// https://dbus.freedesktop.org/doc/dbus-specification.html
+
+const defaultSystemBusAddress = "unix:path=/var/run/dbus/system_bus_socket"
+
+// Message types
+const (
+	msgMethodCall   = 1
+	msgMethodReturn = 2
+	msgError        = 3
+	msgSignal       = 4
+)
+
+// Header fields
+const (
+	fieldPath        = 1
+	fieldInterface   = 2
+	fieldMember      = 3
+	fieldErrorName   = 4
+	fieldReplySerial = 5
+	fieldDestination = 6
+	fieldSender      = 7
+	fieldSignature   = 8
+)
+
+const (
+	// Limits of the specification
+	maxMessageSize = 128 << 20
+	maxArraySize   = 64 << 20
+	maxDepth       = 64
+
+	// Signals queued beyond this drop the oldest ones
+	maxQueuedSignals = 1024
+)
+
+// callTimeout bounds the wait for a method reply. Variable so tests can
+// shorten it.
+var callTimeout = 5 * time.Second
+
+// objectPath is a value of type "o"
+type objectPath string
+
+// variant is a value of type "v"
+type variant struct {
+	sig   string
+	value interface{}
+}
+
+// message is a D-Bus message. Arrays, structs and dict entries in the body
+// are []interface{}, dict entries holding key and value.
+type message struct {
+	typ         byte
+	flags       byte
+	serial      uint32
+	path        objectPath
+	iface       string
+	member      string
+	errorName   string
+	replySerial uint32
+	destination string
+	sender      string
+	sig         string
+	body        []interface{}
+}
+
+// dbusError is an error reply
+type dbusError struct {
+	name    string
+	message string
+}
+
+func (e *dbusError) Error() string {
+	if e.message == "" {
+		return e.name
+	}
+	return e.name + ": " + e.message
+}
+
+// splitType returns the first complete type of a signature and the rest
+func splitType(sig string) (string, string, error) {
+	if sig == "" {
+		return "", "", errors.New("empty signature")
+	}
+	switch sig[0] {
+	case 'y', 'b', 'n', 'q', 'i', 'u', 'x', 't', 'd', 'h', 's', 'o', 'g', 'v':
+		return sig[:1], sig[1:], nil
+	case 'a':
+		elem, rest, err := splitType(sig[1:])
+		if err != nil {
+			return "", "", err
+		}
+		return "a" + elem, rest, nil
+	case '(', '{':
+		closing := byte(')')
+		if sig[0] == '{' {
+			closing = '}'
+		}
+		rest := sig[1:]
+		for rest != "" && rest[0] != closing {
+			var err error
+			if _, rest, err = splitType(rest); err != nil {
+				return "", "", err
+			}
+		}
+		// Empty structs aren't allowed, arrays of them would never end
+		if rest == "" || len(rest) == len(sig)-1 {
+			return "", "", fmt.Errorf("invalid signature %q", sig)
+		}
+		return sig[:len(sig)-len(rest)+1], rest[1:], nil
+	}
+	return "", "", fmt.Errorf("invalid signature %q", sig)
+}
+
+// alignment returns the alignment of a type
+func alignment(sig string) int {
+	switch sig[0] {
+	case 'n', 'q':
+		return 2
+	case 'b', 'i', 'u', 'h', 's', 'o', 'a':
+		return 4
+	case 'x', 't', 'd', '(', '{':
+		return 8
+	}
+	return 1
+}
+
+// encoder marshals values in little endian
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) align(n int) {
+	for len(e.buf)%n != 0 {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *encoder) uint32(v uint32) {
+	e.align(4)
+	e.buf = binary.LittleEndian.AppendUint32(e.buf, v)
+}
+
+// writeAll writes the values of a signature
+func (e *encoder) writeAll(sig string, values []interface{}) error {
+	for i := 0; sig != ""; i++ {
+		var single string
+		var err error
+		if single, sig, err = splitType(sig); err != nil {
+			return err
+		}
+		if i >= len(values) {
+			return fmt.Errorf("missing value for %q", single)
+		}
+		if err := e.write(single, values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write writes a value of a single complete type
+func (e *encoder) write(sig string, v interface{}) error {
+	invalid := fmt.Errorf("invalid value %T for %q", v, sig)
+	e.align(alignment(sig))
+	switch sig[0] {
+	case 'y':
+		b, ok := v.(byte)
+		if !ok {
+			return invalid
+		}
+		e.buf = append(e.buf, b)
+	case 'b':
+		b, ok := v.(bool)
+		if !ok {
+			return invalid
+		}
+		if b {
+			e.uint32(1)
+		} else {
+			e.uint32(0)
+		}
+	case 'n', 'q':
+		var n uint16
+		switch v := v.(type) {
+		case int16:
+			n = uint16(v)
+		case uint16:
+			n = v
+		default:
+			return invalid
+		}
+		e.buf = binary.LittleEndian.AppendUint16(e.buf, n)
+	case 'i', 'u', 'h':
+		var n uint32
+		switch v := v.(type) {
+		case int32:
+			n = uint32(v)
+		case uint32:
+			n = v
+		default:
+			return invalid
+		}
+		e.uint32(n)
+	case 'x', 't', 'd':
+		var n uint64
+		switch v := v.(type) {
+		case int64:
+			n = uint64(v)
+		case uint64:
+			n = v
+		case float64:
+			n = math.Float64bits(v)
+		default:
+			return invalid
+		}
+		e.buf = binary.LittleEndian.AppendUint64(e.buf, n)
+	case 's', 'o':
+		var s string
+		switch v := v.(type) {
+		case string:
+			s = v
+		case objectPath:
+			s = string(v)
+		default:
+			return invalid
+		}
+		e.uint32(uint32(len(s)))
+		e.buf = append(append(e.buf, s...), 0)
+	case 'g':
+		s, ok := v.(string)
+		if !ok || len(s) > 255 {
+			return invalid
+		}
+		e.buf = append(append(append(e.buf, byte(len(s))), s...), 0)
+	case 'v':
+		vv, ok := v.(variant)
+		if !ok {
+			return invalid
+		}
+		if err := e.write("g", vv.sig); err != nil {
+			return err
+		}
+		return e.write(vv.sig, vv.value)
+	case 'a':
+		elems, ok := v.([]interface{})
+		if !ok {
+			return invalid
+		}
+		e.uint32(0)
+		lengthAt := len(e.buf) - 4
+		e.align(alignment(sig[1:]))
+		start := len(e.buf)
+		for _, elem := range elems {
+			if err := e.write(sig[1:], elem); err != nil {
+				return err
+			}
+		}
+		binary.LittleEndian.PutUint32(e.buf[lengthAt:], uint32(len(e.buf)-start))
+	case '(', '{':
+		fields, ok := v.([]interface{})
+		if !ok {
+			return invalid
+		}
+		return e.writeAll(sig[1:len(sig)-1], fields)
+	default:
+		return invalid
+	}
+	return nil
+}
+
+// decoder unmarshals values, offsets are relative to the message start
+type decoder struct {
+	buf   []byte
+	pos   int
+	order binary.ByteOrder
+	depth int
+}
+
+var errTruncated = errors.New("truncated D-Bus message")
+
+func (d *decoder) align(n int) error {
+	d.pos = (d.pos + n - 1) / n * n
+	if d.pos > len(d.buf) {
+		return errTruncated
+	}
+	return nil
+}
+
+func (d *decoder) next(n int) ([]byte, error) {
+	if n < 0 || d.pos+n > len(d.buf) {
+		return nil, errTruncated
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *decoder) uint32() (uint32, error) {
+	if err := d.align(4); err != nil {
+		return 0, err
+	}
+	b, err := d.next(4)
+	if err != nil {
+		return 0, err
+	}
+	return d.order.Uint32(b), nil
+}
+
+// readAll reads the values of a signature
+func (d *decoder) readAll(sig string) ([]interface{}, error) {
+	var values []interface{}
+	for sig != "" {
+		var single string
+		var err error
+		if single, sig, err = splitType(sig); err != nil {
+			return nil, err
+		}
+		v, err := d.read(single)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// read reads a value of a single complete type
+func (d *decoder) read(sig string) (interface{}, error) {
+	if d.depth++; d.depth > maxDepth {
+		return nil, errors.New("D-Bus value nested too deeply")
+	}
+	defer func() { d.depth-- }()
+
+	if err := d.align(alignment(sig)); err != nil {
+		return nil, err
+	}
+	switch sig[0] {
+	case 'y':
+		b, err := d.next(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0], nil
+	case 'b':
+		n, err := d.uint32()
+		return n != 0, err
+	case 'n', 'q':
+		b, err := d.next(2)
+		if err != nil {
+			return nil, err
+		}
+		if sig[0] == 'n' {
+			return int16(d.order.Uint16(b)), nil
+		}
+		return d.order.Uint16(b), nil
+	case 'i':
+		n, err := d.uint32()
+		return int32(n), err
+	case 'u', 'h':
+		return d.uint32()
+	case 'x', 't', 'd':
+		b, err := d.next(8)
+		if err != nil {
+			return nil, err
+		}
+		n := d.order.Uint64(b)
+		switch sig[0] {
+		case 'x':
+			return int64(n), nil
+		case 'd':
+			return math.Float64frombits(n), nil
+		}
+		return n, nil
+	case 's', 'o':
+		n, err := d.uint32()
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.next(int(n) + 1)
+		if err != nil {
+			return nil, err
+		}
+		if sig[0] == 'o' {
+			return objectPath(b[:n]), nil
+		}
+		return string(b[:n]), nil
+	case 'g':
+		n, err := d.next(1)
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.next(int(n[0]) + 1)
+		if err != nil {
+			return nil, err
+		}
+		return string(b[:n[0]]), nil
+	case 'v':
+		s, err := d.read("g")
+		if err != nil {
+			return nil, err
+		}
+		inner, rest, err := splitType(s.(string))
+		if err != nil || rest != "" {
+			return nil, fmt.Errorf("invalid variant signature %q", s)
+		}
+		v, err := d.read(inner)
+		return variant{sig: inner, value: v}, err
+	case 'a':
+		n, err := d.uint32()
+		if err != nil {
+			return nil, err
+		}
+		if n > maxArraySize {
+			return nil, fmt.Errorf("D-Bus array of %d bytes too large", n)
+		}
+		if err := d.align(alignment(sig[1:])); err != nil {
+			return nil, err
+		}
+		end := d.pos + int(n)
+		if end > len(d.buf) {
+			return nil, errTruncated
+		}
+		elems := []interface{}{}
+		for d.pos < end {
+			elem, err := d.read(sig[1:])
+			if err != nil {
+				return nil, err
+			}
+			elems = append(elems, elem)
+		}
+		return elems, nil
+	case '(', '{':
+		return d.readAll(sig[1 : len(sig)-1])
+	}
+	return nil, fmt.Errorf("invalid signature %q", sig)
+}
+
+// encodeMessage marshals a message with its serial
+func encodeMessage(msg *message) ([]byte, error) {
+	var body encoder
+	if err := body.writeAll(msg.sig, msg.body); err != nil {
+		return nil, err
+	}
+
+	var fields []interface{}
+	field := func(code byte, sig string, value interface{}) {
+		fields = append(fields, []interface{}{code, variant{sig: sig, value: value}})
+	}
+	if msg.path != "" {
+		field(fieldPath, "o", msg.path)
+	}
+	if msg.iface != "" {
+		field(fieldInterface, "s", msg.iface)
+	}
+	if msg.member != "" {
+		field(fieldMember, "s", msg.member)
+	}
+	if msg.errorName != "" {
+		field(fieldErrorName, "s", msg.errorName)
+	}
+	if msg.replySerial != 0 {
+		field(fieldReplySerial, "u", msg.replySerial)
+	}
+	if msg.destination != "" {
+		field(fieldDestination, "s", msg.destination)
+	}
+	if msg.sender != "" {
+		field(fieldSender, "s", msg.sender)
+	}
+	if msg.sig != "" {
+		field(fieldSignature, "g", msg.sig)
+	}
+
+	e := encoder{buf: []byte{'l', msg.typ, msg.flags, 1}}
+	e.uint32(uint32(len(body.buf)))
+	e.uint32(msg.serial)
+	if err := e.write("a(yv)", fields); err != nil {
+		return nil, err
+	}
+	e.align(8)
+	return append(e.buf, body.buf...), nil
+}
+
+// readMessage reads and unmarshals a message
+func readMessage(r io.Reader) (*message, error) {
+	fixed := make([]byte, 16)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return nil, err
+	}
+	var order binary.ByteOrder
+	switch fixed[0] {
+	case 'l':
+		order = binary.LittleEndian
+	case 'B':
+		order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("invalid D-Bus endianness %q", fixed[0])
+	}
+	bodyLength := int(order.Uint32(fixed[4:]))
+	fieldsLength := int(order.Uint32(fixed[12:]))
+	headerLength := (16 + fieldsLength + 7) / 8 * 8
+	if fieldsLength > maxArraySize || bodyLength > maxMessageSize-headerLength {
+		return nil, errors.New("D-Bus message too large")
+	}
+
+	buf := make([]byte, headerLength+bodyLength)
+	copy(buf, fixed)
+	if _, err := io.ReadFull(r, buf[16:]); err != nil {
+		return nil, err
+	}
+
+	d := &decoder{buf: buf[:16+fieldsLength], pos: 12, order: order}
+	raw, err := d.read("a(yv)")
+	if err != nil {
+		return nil, err
+	}
+	msg := &message{
+		typ:    fixed[1],
+		flags:  fixed[2],
+		serial: order.Uint32(fixed[8:]),
+	}
+	for _, f := range raw.([]interface{}) {
+		pair := f.([]interface{})
+		value := pair[1].(variant).value
+		switch pair[0].(byte) {
+		case fieldPath:
+			msg.path, _ = value.(objectPath)
+		case fieldInterface:
+			msg.iface, _ = value.(string)
+		case fieldMember:
+			msg.member, _ = value.(string)
+		case fieldErrorName:
+			msg.errorName, _ = value.(string)
+		case fieldReplySerial:
+			msg.replySerial, _ = value.(uint32)
+		case fieldDestination:
+			msg.destination, _ = value.(string)
+		case fieldSender:
+			msg.sender, _ = value.(string)
+		case fieldSignature:
+			msg.sig, _ = value.(string)
+		}
+	}
+
+	d = &decoder{buf: buf, pos: headerLength, order: order}
+	if msg.body, err = d.readAll(msg.sig); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// busConn is a connection to a message bus
+type busConn struct {
+	conn    net.Conn
+	writeMu sync.Mutex
+
+	mu     sync.Mutex
+	serial uint32
+	calls  map[uint32]chan *message
+
+	// Signals are queued until taken, signaled is notified when there are
+	// queued signals
+	queued   []*message
+	signaled chan struct{}
+	done     chan struct{}
+	err      error
+}
+
+// systemBusAddress returns the address of the system bus
+func systemBusAddress() string {
+	if address := os.Getenv("DBUS_SYSTEM_BUS_ADDRESS"); address != "" {
+		return address
+	}
+	return defaultSystemBusAddress
+}
+
+// dialBus connects to the first unix socket of a bus address, authenticates
+// and registers with the bus
+func dialBus(address string) (*busConn, error) {
+	var conn net.Conn
+	var err error = fmt.Errorf("no supported transport in D-Bus address %q", address)
+	for _, entry := range strings.Split(address, ";") {
+		transport, params, _ := strings.Cut(entry, ":")
+		if transport != "unix" {
+			continue
+		}
+		for _, param := range strings.Split(params, ",") {
+			key, value, _ := strings.Cut(param, "=")
+			value, unescapeErr := url.PathUnescape(value)
+			if unescapeErr != nil {
+				continue
+			}
+			switch key {
+			case "path":
+				conn, err = net.Dial("unix", value)
+			case "abstract":
+				conn, err = net.Dial("unix", "@"+value)
+			default:
+				continue
+			}
+			break
+		}
+		if conn != nil {
+			break
+		}
+	}
+	if conn == nil {
+		return nil, err
+	}
+
+	if err := authenticate(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	c := &busConn{
+		conn:     conn,
+		calls:    make(map[uint32]chan *message),
+		signaled: make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	go c.receive()
+
+	if _, err := c.call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "Hello", ""); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// authenticate runs the EXTERNAL authentication with the uid of the process
+func authenticate(conn net.Conn) error {
+	conn.SetDeadline(time.Now().Add(callTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	uid := hex.EncodeToString([]byte(strconv.Itoa(os.Getuid())))
+	if _, err := conn.Write([]byte("\x00AUTH EXTERNAL " + uid + "\r\n")); err != nil {
+		return err
+	}
+	// Read byte by byte, nothing of the messages after BEGIN may be consumed
+	var line []byte
+	b := make([]byte, 1)
+	for len(line) < 512 && !strings.HasSuffix(string(line), "\r\n") {
+		if _, err := conn.Read(b); err != nil {
+			return err
+		}
+		line = append(line, b[0])
+	}
+	if !strings.HasPrefix(string(line), "OK ") {
+		return fmt.Errorf("D-Bus authentication failed: %q", strings.TrimSpace(string(line)))
+	}
+	_, err := conn.Write([]byte("BEGIN\r\n"))
+	return err
+}
+
+// receive dispatches replies and signals until the connection fails
+func (c *busConn) receive() {
+	var err error
+	for {
+		var msg *message
+		if msg, err = readMessage(c.conn); err != nil {
+			break
+		}
+		switch msg.typ {
+		case msgMethodReturn, msgError:
+			c.mu.Lock()
+			reply, ok := c.calls[msg.replySerial]
+			delete(c.calls, msg.replySerial)
+			c.mu.Unlock()
+			if ok {
+				reply <- msg
+			}
+		case msgSignal:
+			c.mu.Lock()
+			if len(c.queued) == maxQueuedSignals {
+				c.queued = c.queued[1:]
+			}
+			c.queued = append(c.queued, msg)
+			c.mu.Unlock()
+			select {
+			case c.signaled <- struct{}{}:
+			default:
+			}
+		}
+	}
+
+	c.mu.Lock()
+	c.err = err
+	c.mu.Unlock()
+	close(c.done)
+}
+
+// call calls a method and waits for the reply
+func (c *busConn) call(destination string, path objectPath, iface, member, sig string, args ...interface{}) (*message, error) {
+	reply := make(chan *message, 1)
+	c.mu.Lock()
+	c.serial++
+	serial := c.serial
+	c.calls[serial] = reply
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.calls, serial)
+		c.mu.Unlock()
+	}()
+
+	buf, err := encodeMessage(&message{
+		typ:         msgMethodCall,
+		serial:      serial,
+		path:        path,
+		iface:       iface,
+		member:      member,
+		destination: destination,
+		sig:         sig,
+		body:        args,
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.writeMu.Lock()
+	_, err = c.conn.Write(buf)
+	c.writeMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(callTimeout)
+	defer timer.Stop()
+	select {
+	case msg := <-reply:
+		if msg.typ == msgError {
+			e := &dbusError{name: msg.errorName}
+			if len(msg.body) > 0 {
+				e.message, _ = msg.body[0].(string)
+			}
+			return nil, e
+		}
+		return msg, nil
+	case <-c.done:
+		return nil, c.Err()
+	case <-timer.C:
+		return nil, fmt.Errorf("D-Bus call %s.%s timed out", iface, member)
+	}
+}
+
+// takeSignals returns the queued signals and empties the queue
+func (c *busConn) takeSignals() []*message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	signals := c.queued
+	c.queued = nil
+	return signals
+}
+
+// Err returns why the connection failed
+func (c *busConn) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		return errors.New("D-Bus connection closed")
+	}
+	return c.err
+}
+
+// Close closes the connection
+func (c *busConn) Close() error {
+	return c.conn.Close()
+}
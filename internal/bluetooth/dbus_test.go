@@ -0,0 +1,69 @@
+package bluetooth
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestMessageRoundTrip(t *testing.T) {
+	body := []interface{}{
+		[]interface{}{
+			[]interface{}{objectPath("/org/bluez/hci0"), []interface{}{
+				[]interface{}{bluezAdapter, []interface{}{
+					[]interface{}{"Address", variant{sig: "s", value: "00:11:22:33:44:55"}},
+					[]interface{}{"Powered", variant{sig: "b", value: true}},
+					[]interface{}{"Class", variant{sig: "u", value: uint32(0x7c010c)}},
+					[]interface{}{"UUIDs", variant{sig: "as", value: []interface{}{"0000110e-0000-1000-8000-00805f9b34fb"}}},
+				}},
+			}},
+		},
+	}
+	msg := &message{
+		typ:         msgMethodReturn,
+		serial:      7,
+		replySerial: 3,
+		sender:      ":1.2",
+		sig:         "a{oa{sa{sv}}}",
+		body:        body,
+	}
+
+	buf, err := encodeMessage(msg)
+	if err != nil {
+		t.Fatalf("Failed to encode message: %v", err)
+	}
+	parsed, err := readMessage(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatalf("Failed to read message: %v", err)
+	}
+	if parsed.typ != msgMethodReturn || parsed.serial != 7 || parsed.replySerial != 3 || parsed.sender != ":1.2" || parsed.sig != msg.sig {
+		t.Errorf("Expected header of %+v, got %+v", msg, parsed)
+	}
+	if !reflect.DeepEqual(parsed.body, body) {
+		t.Errorf("Expected body %v, got %v", body, parsed.body)
+	}
+
+	if _, err := readMessage(bytes.NewReader(buf[:len(buf)-1])); err == nil {
+		t.Error("Expected truncated message to fail")
+	}
+}
+
+func TestSplitType(t *testing.T) {
+	tests := []struct {
+		sig, single, rest string
+		valid             bool
+	}{
+		{"su", "s", "u", true},
+		{"a{sv}as", "a{sv}", "as", true},
+		{"(ya(ii))o", "(ya(ii))", "o", true},
+		{"a(i", "", "", false},
+		{"a()", "", "", false},
+		{"z", "", "", false},
+	}
+	for _, tt := range tests {
+		single, rest, err := splitType(tt.sig)
+		if (err == nil) != tt.valid || single != tt.single || rest != tt.rest {
+			t.Errorf("splitType(%q) = %q, %q, %v", tt.sig, single, rest, err)
+		}
+	}
+}
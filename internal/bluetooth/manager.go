@@ -1,57 +1,334 @@
 package bluetooth
 
 import (
+	"cmp"
+	"context"
+	"fmt"
 	"log/slog"
-	
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/codefionn/go-matter-server/internal/models"
 )
 
+// AutoAdapters in Config.Adapters selects all adapters
+const AutoAdapters = "auto"
+
+// Backend names for ParseBackend
+const (
+	BackendBlueZ = "bluez"
+	BackendMock  = "mock"
+)
+
+// reconnectInterval is the wait before watching the adapters again after
+// the backend became unavailable. Variable so tests can shorten it.
+var reconnectInterval = 5 * time.Second
+
+// Backend gives the Manager access to the Bluetooth adapters: BlueZ on the
+// system bus, or a scripted mock for tests
+type Backend interface {
+	// Watch calls update with all present adapters whenever they change,
+	// with a reason from models if the backend itself is unavailable. It
+	// returns when ctx is done or the backend went away.
+	Watch(ctx context.Context, update func(adapters []models.BluetoothAdapter, reason string)) error
+
+	// Scan discovers devices advertising the Matter service with an adapter
+	// until the timeout passes, passing each to found when it's discovered
+	// or its advertisement changes
+	Scan(ctx context.Context, adapter string, timeout time.Duration, found func(models.CommissionableNodeData)) ([]models.CommissionableNodeData, error)
+
+	// Connect connects to the Matter service of a device for BTP
+	Connect(ctx context.Context, adapter, address string) (Conn, error)
+}
+
+// Conn is a GATT connection to the Matter service of a device, carrying BTP
+// packets: writes go to characteristic C1 and indications come from C2
+// (Matter Core Specification 4.18.3)
+type Conn interface {
+	// Write writes a packet to C1
+	Write(ctx context.Context, packet []byte) error
+	// Receive returns the next packet indicated on C2
+	Receive(ctx context.Context) ([]byte, error)
+	Close() error
+}
+
+// ParseBackend returns the backend of a name. The mock backend replays the
+// script at mockScript.
+func ParseBackend(name, mockScript string) (Backend, error) {
+	switch name {
+	case "", BackendBlueZ:
+		return NewBlueZBackend(), nil
+	case BackendMock:
+		script, err := LoadMockScript(mockScript)
+		if err != nil {
+			return nil, err
+		}
+		return NewMockBackend(script), nil
+	}
+	return nil, fmt.Errorf("invalid Bluetooth backend: %q", name)
+}
+
 // Config holds configuration for the Bluetooth manager
 type Config struct {
-	AdapterID     string
-	Enabled       bool
+	// Adapters to use in order of preference, e.g. "hci0", or AutoAdapters
+	Adapters []string
+	Enabled  bool
+	// Backend defaults to BlueZ
+	Backend       Backend
 	EventCallback func(models.EventType, interface{})
+	// StatusChanged is called when Bluetooth becomes available or
+	// unavailable, or adapters appear, disappear or are powered on or off
+	StatusChanged func(models.BluetoothStatus)
 	Logger        *slog.Logger
+
+	// Supervise runs the loop following the adapters until it returns nil
+	// or ctx is done, restarting it when it fails or panics. Without it the
+	// loop runs once.
+	Supervise func(ctx context.Context, name string, loop func(ctx context.Context) error)
 }
 
-// Manager manages Bluetooth operations
+// Manager manages Bluetooth operations. It follows the adapters of the
+// backend, so adapters can be plugged in and out at runtime.
 type Manager struct {
-	config Config
-	logger *slog.Logger
+	config  Config
+	backend Backend
+	logger  *slog.Logger
+
+	mu     sync.Mutex
+	status models.BluetoothStatus
+
+	cancel context.CancelFunc
+	done   chan struct{}
 }
 
 // NewManager creates a new Bluetooth manager
 func NewManager(config Config) (*Manager, error) {
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	backend := config.Backend
+	if backend == nil {
+		backend = NewBlueZBackend()
+	}
+	reason := models.BluetoothReasonDisabled
+	if config.Enabled {
+		reason = models.BluetoothReasonBlueZUnavailable
+	}
 	return &Manager{
-		config: config,
-		logger: config.Logger,
+		config:  config,
+		backend: backend,
+		logger:  logger,
+		status:  models.BluetoothStatus{Reason: reason, Adapters: []models.BluetoothAdapter{}},
 	}, nil
 }
 
-// IsAvailable returns whether Bluetooth is available
+// IsAvailable returns whether a configured adapter is present and powered
 func (m *Manager) IsAvailable() bool {
-	// For now, return false as this is a stub implementation
-	// In a real implementation, this would check BlueZ D-Bus availability
-	return false
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.status.Available
+}
+
+// Status returns the availability and the present configured adapters
+func (m *Manager) Status() models.BluetoothStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	status := m.status
+	status.Adapters = slices.Clone(status.Adapters)
+	return status
 }
 
 // IsEnabled returns whether Bluetooth is enabled
 func (m *Manager) IsEnabled() bool {
-	return m.config.Enabled && m.IsAvailable()
+	return m.config.Enabled
+}
+
+// Adapters returns the present configured adapters in order of preference
+func (m *Manager) Adapters() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ids []string
+	for _, adapter := range m.status.Adapters {
+		ids = append(ids, adapter.ID)
+	}
+	return ids
+}
+
+// Adapter returns the preferred powered adapter, empty if there is none
+func (m *Manager) Adapter() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, adapter := range m.status.Adapters {
+		if adapter.Powered {
+			return adapter.ID
+		}
+	}
+	return ""
+}
+
+// Scan discovers commissionable Matter devices advertising via BLE with the
+// preferred adapter until the timeout passes or ctx is done. Each device is
+// passed to found when it's discovered or its advertisement changes.
+func (m *Manager) Scan(ctx context.Context, timeout time.Duration, found func(models.CommissionableNodeData)) ([]models.CommissionableNodeData, error) {
+	adapter := m.Adapter()
+	if adapter == "" {
+		return nil, ErrUnavailable
+	}
+	m.logger.Info("BLE scan started", "adapter", adapter, "timeout", timeout)
+	devices, err := m.backend.Scan(ctx, adapter, timeout, found)
+	if err != nil {
+		return nil, err
+	}
+	m.logger.Info("BLE scan finished", "adapter", adapter, "devices", len(devices))
+	return devices, nil
+}
+
+// Connect connects to the Matter service of a device with the preferred
+// adapter
+func (m *Manager) Connect(ctx context.Context, address string) (Conn, error) {
+	adapter := m.Adapter()
+	if adapter == "" {
+		return nil, ErrUnavailable
+	}
+	return m.backend.Connect(ctx, adapter, address)
 }
 
-// Start starts the Bluetooth manager
+// Start starts following the adapters of the backend
 func (m *Manager) Start() error {
-	if m.logger != nil {
-		m.logger.Info("Bluetooth manager start requested (stub implementation)")
+	if !m.config.Enabled || m.cancel != nil {
+		return nil
 	}
+
+	m.logger.Info("Bluetooth manager started", "adapters", strings.Join(m.config.Adapters, ","))
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.done = make(chan struct{})
+	go func() {
+		defer close(m.done)
+		if m.config.Supervise != nil {
+			m.config.Supervise(ctx, "bluetooth_adapters", m.run)
+		} else {
+			m.run(ctx)
+		}
+	}()
 	return nil
 }
 
 // Stop stops the Bluetooth manager
 func (m *Manager) Stop() error {
-	if m.logger != nil {
-		m.logger.Info("Bluetooth manager stop requested (stub implementation)")
+	if m.cancel == nil {
+		return nil
 	}
+	m.cancel()
+	<-m.done
+	m.cancel = nil
+	m.logger.Info("Bluetooth manager stopped")
 	return nil
-}
\ No newline at end of file
+}
+
+// run watches the adapters again after the backend went away, until ctx is
+// done. Without the backend no adapter is available.
+func (m *Manager) run(ctx context.Context) error {
+	update := func(adapters []models.BluetoothAdapter, reason string) {
+		m.setAdapters(m.selectAdapters(adapters), reason)
+	}
+	failed := false
+	for {
+		err := m.backend.Watch(ctx, update)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		m.setAdapters(nil, models.BluetoothReasonBlueZUnavailable)
+		if !failed {
+			m.logger.Warn("BlueZ unavailable on the system bus, retrying", "error", err)
+		} else {
+			m.logger.Debug("BlueZ still unavailable on the system bus", "error", err)
+		}
+		failed = true
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(reconnectInterval):
+		}
+	}
+}
+
+// selectAdapters returns the configured adapters among the present ones in
+// order of preference. All adapters are sorted by number.
+func (m *Manager) selectAdapters(present []models.BluetoothAdapter) []models.BluetoothAdapter {
+	var selected []models.BluetoothAdapter
+	if slices.Contains(m.config.Adapters, AutoAdapters) {
+		selected = slices.Clone(present)
+		slices.SortFunc(selected, func(a, b models.BluetoothAdapter) int {
+			return cmp.Or(cmp.Compare(len(a.ID), len(b.ID)), strings.Compare(a.ID, b.ID))
+		})
+		return selected
+	}
+	for _, id := range m.config.Adapters {
+		for _, adapter := range present {
+			if adapter.ID == id {
+				selected = append(selected, adapter)
+			}
+		}
+	}
+	return selected
+}
+
+// setAdapters updates the status with the present configured adapters,
+// reason is set if the backend isn't available. Changes of availability and
+// of the adapters are reported.
+func (m *Manager) setAdapters(adapters []models.BluetoothAdapter, reason string) {
+	status := models.BluetoothStatus{Reason: reason, Adapters: adapters}
+	if status.Adapters == nil {
+		status.Adapters = []models.BluetoothAdapter{}
+	}
+	if reason == "" {
+		status.Reason = models.BluetoothReasonNoAdapter
+		for _, adapter := range adapters {
+			if adapter.Powered {
+				status.Available, status.Reason = true, ""
+				break
+			}
+			status.Reason = models.BluetoothReasonPoweredOff
+		}
+	}
+
+	m.mu.Lock()
+	previous := m.status
+	m.status = status
+	m.mu.Unlock()
+
+	// Names and discovering don't change the availability
+	changed := previous.Available != status.Available || previous.Reason != status.Reason ||
+		!slices.EqualFunc(previous.Adapters, status.Adapters, func(a, b models.BluetoothAdapter) bool {
+			return a.ID == b.ID && a.Powered == b.Powered
+		})
+	if !changed {
+		return
+	}
+
+	for _, adapter := range status.Adapters {
+		if !slices.ContainsFunc(previous.Adapters, func(a models.BluetoothAdapter) bool { return a.ID == adapter.ID }) {
+			m.logger.Info("Bluetooth adapter added", "adapter", adapter.ID, "address", adapter.Address, "powered", adapter.Powered)
+		}
+	}
+	for _, adapter := range previous.Adapters {
+		if !slices.ContainsFunc(status.Adapters, func(a models.BluetoothAdapter) bool { return a.ID == adapter.ID }) {
+			m.logger.Info("Bluetooth adapter removed", "adapter", adapter.ID)
+		}
+	}
+	if status.Available {
+		m.logger.Info("Bluetooth available", "adapter", m.Adapter())
+	} else {
+		m.logger.Warn("Bluetooth unavailable", "reason", status.Reason)
+	}
+
+	if m.config.StatusChanged != nil {
+		m.config.StatusChanged(m.Status())
+	}
+}
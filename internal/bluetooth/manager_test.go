@@ -0,0 +1,372 @@
+package bluetooth
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/supervisor"
+)
+
+// fakeBus is a system bus with BlueZ managing the adapters
+type fakeBus struct {
+	t        *testing.T
+	mu       sync.Mutex
+	serial   uint32
+	adapters []string
+	// Adapters powered off
+	off   map[string]bool
+	conns []net.Conn
+	// Members of the method calls received
+	calls []string
+}
+
+func newFakeBus(t *testing.T, adapters ...string) *fakeBus {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "bus")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	t.Setenv("DBUS_SYSTEM_BUS_ADDRESS", "unix:path="+path)
+
+	bus := &fakeBus{t: t, adapters: adapters, off: make(map[string]bool)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			bus.mu.Lock()
+			bus.conns = append(bus.conns, conn)
+			bus.mu.Unlock()
+			go bus.serve(conn)
+		}
+	}()
+	return bus
+}
+
+func (b *fakeBus) serve(conn net.Conn) {
+	defer conn.Close()
+	readLine := func() string {
+		var line []byte
+		buf := make([]byte, 1)
+		for !strings.HasSuffix(string(line), "\r\n") {
+			if _, err := conn.Read(buf); err != nil {
+				return ""
+			}
+			line = append(line, buf[0])
+		}
+		return string(line)
+	}
+	if !strings.HasPrefix(readLine(), "\x00AUTH EXTERNAL ") {
+		return
+	}
+	conn.Write([]byte("OK 0123456789abcdef\r\n"))
+	if readLine() != "BEGIN\r\n" {
+		return
+	}
+
+	for {
+		call, err := readMessage(conn)
+		if err != nil {
+			return
+		}
+		b.mu.Lock()
+		b.calls = append(b.calls, call.member)
+		b.mu.Unlock()
+		reply := &message{typ: msgMethodReturn, replySerial: call.serial}
+		switch call.member {
+		case "Hello":
+			reply.sig, reply.body = "s", []interface{}{":1.1"}
+		case "GetManagedObjects":
+			b.mu.Lock()
+			objects := []interface{}{}
+			for _, adapter := range b.adapters {
+				objects = append(objects, []interface{}{objectPath("/org/bluez/" + adapter), []interface{}{
+					[]interface{}{bluezAdapter, []interface{}{
+						[]interface{}{"Address", variant{sig: "s", value: "00:1A:7D:DA:71:0" + adapter[3:]}},
+						[]interface{}{"Alias", variant{sig: "s", value: "server #" + adapter[3:]}},
+						[]interface{}{"Powered", variant{sig: "b", value: !b.off[adapter]}},
+						[]interface{}{"Discovering", variant{sig: "b", value: false}},
+					}},
+				}})
+			}
+			b.mu.Unlock()
+			reply.sig, reply.body = "a{oa{sa{sv}}}", []interface{}{objects}
+		}
+		b.send(conn, reply)
+	}
+}
+
+func (b *fakeBus) send(conn net.Conn, msg *message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.serial++
+	msg.serial = b.serial
+	buf, err := encodeMessage(msg)
+	if err != nil {
+		b.t.Errorf("Failed to encode message: %v", err)
+		return
+	}
+	conn.Write(buf)
+}
+
+// plug sets the adapters and signals the change
+func (b *fakeBus) plug(adapters ...string) {
+	b.mu.Lock()
+	b.adapters = adapters
+	b.mu.Unlock()
+
+	b.signal(&message{
+		typ:    msgSignal,
+		path:   "/",
+		iface:  objectManager,
+		member: "InterfacesAdded",
+		sender: ":1.0",
+		sig:    "oa{sa{sv}}",
+		body:   []interface{}{objectPath("/org/bluez/hci1"), []interface{}{}},
+	})
+}
+
+// power powers an adapter on or off and signals the change
+func (b *fakeBus) power(adapter string, on bool) {
+	b.mu.Lock()
+	b.off[adapter] = !on
+	b.mu.Unlock()
+
+	b.signal(&message{
+		typ:    msgSignal,
+		path:   objectPath("/org/bluez/" + adapter),
+		iface:  "org.freedesktop.DBus.Properties",
+		member: "PropertiesChanged",
+		sender: ":1.0",
+		sig:    "sa{sv}as",
+		body: []interface{}{bluezAdapter, []interface{}{
+			[]interface{}{"Powered", variant{sig: "b", value: on}},
+		}, []interface{}{}},
+	})
+}
+
+// signal sends a signal to all connections
+func (b *fakeBus) signal(msg *message) {
+	b.mu.Lock()
+	conns := slices.Clone(b.conns)
+	b.mu.Unlock()
+
+	for _, conn := range conns {
+		b.send(conn, msg)
+	}
+}
+
+// called reports whether a method was called
+func (b *fakeBus) called(member string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return slices.Contains(b.calls, member)
+}
+
+// disconnect closes the connections to the bus
+func (b *fakeBus) disconnect() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, conn := range b.conns {
+		conn.Close()
+	}
+	b.conns = nil
+}
+
+// recordChanges returns a manager config recording the status changes
+func recordChanges(adapters ...string) (Config, func() []models.BluetoothStatus) {
+	var mu sync.Mutex
+	var changes []models.BluetoothStatus
+	config := Config{
+		Adapters: adapters,
+		Enabled:  true,
+		StatusChanged: func(status models.BluetoothStatus) {
+			mu.Lock()
+			defer mu.Unlock()
+			changes = append(changes, status)
+		},
+	}
+	return config, func() []models.BluetoothStatus {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(changes)
+	}
+}
+
+// adapterIDs returns the adapters of each status
+func adapterIDs(changes []models.BluetoothStatus) [][]string {
+	var ids [][]string
+	for _, status := range changes {
+		var adapters []string
+		for _, adapter := range status.Adapters {
+			adapters = append(adapters, adapter.ID)
+		}
+		ids = append(ids, adapters)
+	}
+	return ids
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestManagerFollowsAdapters(t *testing.T) {
+	bus := newFakeBus(t, "hci0", "hci2")
+	config, changes := recordChanges("hci1", "hci0")
+	m, _ := NewManager(config)
+	if err := m.Start(); err != nil {
+		t.Fatalf("Failed to start: %v", err)
+	}
+	defer m.Stop()
+
+	waitFor(t, "hci0", func() bool { return slices.Equal(m.Adapters(), []string{"hci0"}) })
+
+	// The preferred adapter takes over when it is plugged in and hands back
+	// when it is gone again
+	bus.plug("hci0", "hci1", "hci2")
+	waitFor(t, "hci1", func() bool { return slices.Equal(m.Adapters(), []string{"hci1", "hci0"}) })
+	if m.Adapter() != "hci1" {
+		t.Errorf("Expected hci1 as preferred adapter, got %q", m.Adapter())
+	}
+	bus.plug("hci0")
+	waitFor(t, "hci1 removal", func() bool { return m.Adapter() == "hci0" })
+
+	bus.plug()
+	waitFor(t, "no adapter", func() bool { return !m.IsAvailable() })
+
+	want := [][]string{{"hci0"}, {"hci1", "hci0"}, {"hci0"}, nil}
+	if got := adapterIDs(changes()); !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("Expected changes %v, got %v", want, got)
+	}
+}
+
+func TestManagerReconnects(t *testing.T) {
+	interval := reconnectInterval
+	reconnectInterval = 10 * time.Millisecond
+	defer func() { reconnectInterval = interval }()
+
+	bus := newFakeBus(t, "hci10", "hci2")
+	config, changes := recordChanges(AutoAdapters)
+	m, _ := NewManager(config)
+	m.Start()
+	defer m.Stop()
+
+	waitFor(t, "all adapters", func() bool { return slices.Equal(m.Adapters(), []string{"hci2", "hci10"}) })
+
+	// Losing the bus makes the adapters unavailable until it is back
+	bus.disconnect()
+	waitFor(t, "reconnect", func() bool { return len(changes()) == 3 })
+	want := [][]string{{"hci2", "hci10"}, nil, {"hci2", "hci10"}}
+	got := changes()
+	if ids := adapterIDs(got); !slices.EqualFunc(ids, want, slices.Equal) {
+		t.Errorf("Expected changes %v, got %v", want, ids)
+	}
+	if got[1].Available || got[1].Reason != models.BluetoothReasonBlueZUnavailable {
+		t.Errorf("Expected BlueZ to be unavailable without the bus, got %+v", got[1])
+	}
+}
+
+func TestManagerPoweredOff(t *testing.T) {
+	bus := newFakeBus(t, "hci0", "hci1")
+	config, changes := recordChanges("hci0", "hci1")
+	m, _ := NewManager(config)
+	m.Start()
+	defer m.Stop()
+
+	waitFor(t, "hci0", func() bool { return m.Adapter() == "hci0" })
+	status := m.Status()
+	want := models.BluetoothAdapter{ID: "hci0", Address: "00:1A:7D:DA:71:00", Name: "server #0", Powered: true}
+	if !status.Available || status.Reason != "" || len(status.Adapters) != 2 || status.Adapters[0] != want {
+		t.Errorf("Expected available status with %+v, got %+v", want, status)
+	}
+
+	// The next powered adapter takes over, without one Bluetooth is
+	// unavailable
+	bus.power("hci0", false)
+	waitFor(t, "hci1", func() bool { return m.Adapter() == "hci1" })
+	bus.power("hci1", false)
+	waitFor(t, "powered off", func() bool { return !m.IsAvailable() })
+	if status := m.Status(); status.Reason != models.BluetoothReasonPoweredOff || len(status.Adapters) != 2 {
+		t.Errorf("Expected powered off status, got %+v", status)
+	}
+	if m.Adapter() != "" {
+		t.Errorf("Expected no adapter to scan with, got %q", m.Adapter())
+	}
+
+	bus.plug()
+	waitFor(t, "no adapter", func() bool { return m.Status().Reason == models.BluetoothReasonNoAdapter })
+	if n := len(changes()); n != 4 {
+		t.Errorf("Expected 4 status changes, got %d", n)
+	}
+}
+
+func TestManagerDisabled(t *testing.T) {
+	m, _ := NewManager(Config{})
+	if err := m.Start(); err != nil {
+		t.Fatalf("Failed to start: %v", err)
+	}
+	if m.IsEnabled() || m.IsAvailable() || m.Adapter() != "" {
+		t.Error("Expected disabled manager without adapters")
+	}
+	if status := m.Status(); status.Reason != models.BluetoothReasonDisabled {
+		t.Errorf("Expected disabled status, got %+v", status)
+	}
+	if err := m.Stop(); err != nil {
+		t.Errorf("Failed to stop: %v", err)
+	}
+}
+
+// panickingBackend panics the first time the adapters are watched
+type panickingBackend struct {
+	MockBackend
+	watches atomic.Int32
+}
+
+func (b *panickingBackend) Watch(ctx context.Context, update func([]models.BluetoothAdapter, string)) error {
+	if b.watches.Add(1) == 1 {
+		panic("nil pointer dereference")
+	}
+	update([]models.BluetoothAdapter{{ID: "hci0", Powered: true}}, "")
+	<-ctx.Done()
+	return nil
+}
+
+func TestManagerSupervised(t *testing.T) {
+	backend := &panickingBackend{}
+	config, _ := recordChanges(AutoAdapters)
+	config.Backend = backend
+	config.Supervise = supervisor.New(supervisor.Config{
+		InitialBackoff: time.Millisecond,
+		Logger:         logger.NewConsoleLogger(logger.FatalLevel),
+	}).Run
+	m, _ := NewManager(config)
+	m.Start()
+
+	// The adapters are followed again after the panic
+	waitFor(t, "hci0", func() bool { return m.Adapter() == "hci0" })
+	if err := m.Stop(); err != nil {
+		t.Errorf("Failed to stop: %v", err)
+	}
+	if watches := backend.watches.Load(); watches != 2 {
+		t.Errorf("Expected the adapters to be watched twice, got %d", watches)
+	}
+}
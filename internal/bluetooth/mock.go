@@ -0,0 +1,285 @@
+package bluetooth
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// MockScript describes the adapters and devices of the mock backend. Byte
+// strings are hex encoded.
+type MockScript struct {
+	Adapters []MockAdapter `json:"adapters"`
+	Devices  []MockDevice  `json:"devices"`
+}
+
+// MockAdapter is an adapter of the mock backend
+type MockAdapter struct {
+	ID      string `json:"id"`
+	Address string `json:"address,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Powered bool   `json:"powered"`
+}
+
+// MockDevice is a device the mock backend finds in scans and connects to
+type MockDevice struct {
+	Address     string `json:"address"`
+	Name        string `json:"name,omitempty"`
+	RSSI        *int   `json:"rssi,omitempty"`
+	ServiceData string `json:"service_data"`
+	// Exchange is the BTP packets expected from and sent to the client over
+	// a connection, in order
+	Exchange []MockStep `json:"exchange,omitempty"`
+}
+
+// MockStep is a packet of an exchange: either one the client is expected to
+// write, or one the device indicates
+type MockStep struct {
+	Write    string `json:"write,omitempty"`
+	Indicate string `json:"indicate,omitempty"`
+}
+
+// LoadMockScript reads a mock script from a JSON file
+func LoadMockScript(path string) (*MockScript, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Bluetooth mock script: %w", err)
+	}
+	var script MockScript
+	if err := json.Unmarshal(data, &script); err != nil {
+		return nil, fmt.Errorf("failed to parse Bluetooth mock script: %w", err)
+	}
+	if err := script.validate(); err != nil {
+		return nil, fmt.Errorf("invalid Bluetooth mock script: %w", err)
+	}
+	return &script, nil
+}
+
+// validate checks the hex strings of the script
+func (s *MockScript) validate() error {
+	for _, device := range s.Devices {
+		if _, err := hex.DecodeString(device.ServiceData); err != nil {
+			return fmt.Errorf("service data of %s: %w", device.Address, err)
+		}
+		for i, step := range device.Exchange {
+			if (step.Write == "") == (step.Indicate == "") {
+				return fmt.Errorf("step %d of %s needs either write or indicate", i, device.Address)
+			}
+			if _, err := hex.DecodeString(step.Write + step.Indicate); err != nil {
+				return fmt.Errorf("step %d of %s: %w", i, device.Address, err)
+			}
+		}
+	}
+	return nil
+}
+
+// MockBackend replays a MockScript instead of accessing real adapters
+type MockBackend struct {
+	mu      sync.Mutex
+	script  MockScript
+	changed chan struct{}
+}
+
+// NewMockBackend creates a mock backend replaying script
+func NewMockBackend(script *MockScript) *MockBackend {
+	return &MockBackend{script: *script, changed: make(chan struct{})}
+}
+
+// SetAdapters replaces the adapters, as if they were plugged in or out
+func (b *MockBackend) SetAdapters(adapters []MockAdapter) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.script.Adapters = slices.Clone(adapters)
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// adapters returns the current adapters and a channel closed on changes
+func (b *MockBackend) adapters() ([]models.BluetoothAdapter, <-chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	adapters := make([]models.BluetoothAdapter, 0, len(b.script.Adapters))
+	for _, adapter := range b.script.Adapters {
+		adapters = append(adapters, models.BluetoothAdapter{
+			ID:      adapter.ID,
+			Address: adapter.Address,
+			Name:    adapter.Name,
+			Powered: adapter.Powered,
+		})
+	}
+	return adapters, b.changed
+}
+
+// Watch reports the adapters on each SetAdapters until ctx is done
+func (b *MockBackend) Watch(ctx context.Context, update func([]models.BluetoothAdapter, string)) error {
+	for {
+		adapters, changed := b.adapters()
+		update(adapters, "")
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-changed:
+		}
+	}
+}
+
+// hasAdapter reports whether a powered adapter of the id is present
+func (b *MockBackend) hasAdapter(id string) bool {
+	adapters, _ := b.adapters()
+	return slices.ContainsFunc(adapters, func(a models.BluetoothAdapter) bool { return a.ID == id && a.Powered })
+}
+
+// Scan finds all devices of the script at once and returns them after the
+// timeout
+func (b *MockBackend) Scan(ctx context.Context, adapter string, timeout time.Duration, found func(models.CommissionableNodeData)) ([]models.CommissionableNodeData, error) {
+	if !b.hasAdapter(adapter) {
+		return nil, ErrUnavailable
+	}
+
+	b.mu.Lock()
+	devices := slices.Clone(b.script.Devices)
+	b.mu.Unlock()
+
+	results := []models.CommissionableNodeData{}
+	for _, device := range devices {
+		serviceData, _ := hex.DecodeString(device.ServiceData)
+		result, ok := commissionableNode(serviceData, device.Address, device.Name, device.RSSI)
+		if !ok {
+			continue
+		}
+		results = append(results, result)
+		if found != nil {
+			found(result)
+		}
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+		return results, nil
+	}
+}
+
+// Connect returns a connection replaying the exchange of the device
+func (b *MockBackend) Connect(ctx context.Context, adapter, address string) (Conn, error) {
+	if !b.hasAdapter(adapter) {
+		return nil, ErrUnavailable
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, device := range b.script.Devices {
+		if device.Address == address {
+			conn := &mockConn{address: address, indicated: make(chan struct{}, 1)}
+			for _, step := range device.Exchange {
+				write, _ := hex.DecodeString(step.Write)
+				indicate, _ := hex.DecodeString(step.Indicate)
+				conn.steps = append(conn.steps, mockPacket{write: write, indicate: indicate})
+			}
+			conn.advance()
+			return conn, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to connect to %s: device not found", address)
+}
+
+// mockPacket is a decoded MockStep, exactly one of the fields is set
+type mockPacket struct {
+	write, indicate []byte
+}
+
+// mockConn replays an exchange. Writes must match the script, indications
+// become available once all writes before them were made.
+type mockConn struct {
+	address string
+
+	mu     sync.Mutex
+	steps  []mockPacket
+	queue  [][]byte
+	closed bool
+	// indicated is signaled when the queue grows or the connection closes
+	indicated chan struct{}
+}
+
+// advance queues the indications up to the next expected write. Callers
+// hold c.mu, except in Connect before the connection is shared.
+func (c *mockConn) advance() {
+	for len(c.steps) > 0 && len(c.steps[0].indicate) > 0 {
+		c.queue = append(c.queue, c.steps[0].indicate)
+		c.steps = c.steps[1:]
+	}
+	select {
+	case c.indicated <- struct{}{}:
+	default:
+	}
+}
+
+// Write checks the packet against the next expected write
+func (c *mockConn) Write(ctx context.Context, packet []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return io.ErrClosedPipe
+	}
+	if len(c.steps) == 0 {
+		return fmt.Errorf("unexpected write %x to %s: exchange finished", packet, c.address)
+	}
+	if !bytes.Equal(c.steps[0].write, packet) {
+		return fmt.Errorf("unexpected write %x to %s: expected %x", packet, c.address, c.steps[0].write)
+	}
+	c.steps = c.steps[1:]
+	c.advance()
+	return nil
+}
+
+// Receive returns the next indication. After the last step of the exchange
+// it returns io.EOF.
+func (c *mockConn) Receive(ctx context.Context) ([]byte, error) {
+	for {
+		c.mu.Lock()
+		switch {
+		case c.closed:
+			c.mu.Unlock()
+			return nil, io.ErrClosedPipe
+		case len(c.queue) > 0:
+			packet := c.queue[0]
+			c.queue = c.queue[1:]
+			c.mu.Unlock()
+			return packet, nil
+		case len(c.steps) == 0:
+			c.mu.Unlock()
+			return nil, io.EOF
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-c.indicated:
+		}
+	}
+}
+
+// Close ends the connection
+func (c *mockConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	select {
+	case c.indicated <- struct{}{}:
+	default:
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package bluetooth
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+const testMockScript = `{
+	"adapters": [{"id": "hci0", "address": "00:00:00:00:00:01", "powered": true}],
+	"devices": [
+		{
+			"address": "00:11:22:33:44:55",
+			"name": "MATTER-3840",
+			"rssi": -60,
+			"service_data": "00000ff1ff008000",
+			"exchange": [
+				{"write": "656c04000000f70006"},
+				{"indicate": "656c04f70006"},
+				{"indicate": "0500"},
+				{"write": "0601"}
+			]
+		},
+		{"address": "00:11:22:33:44:66", "service_data": "01"}
+	]
+}`
+
+func loadTestScript(t *testing.T) *MockScript {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "bluetooth.json")
+	if err := os.WriteFile(path, []byte(testMockScript), 0o600); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+	script, err := LoadMockScript(path)
+	if err != nil {
+		t.Fatalf("Failed to load script: %v", err)
+	}
+	return script
+}
+
+func startMock(t *testing.T, backend Backend) *Manager {
+	t.Helper()
+	m, _ := NewManager(Config{Adapters: []string{AutoAdapters}, Enabled: true, Backend: backend})
+	if err := m.Start(); err != nil {
+		t.Fatalf("Failed to start: %v", err)
+	}
+	t.Cleanup(func() { m.Stop() })
+	waitFor(t, "adapter", m.IsAvailable)
+	return m
+}
+
+func TestLoadMockScriptInvalid(t *testing.T) {
+	for name, script := range map[string]string{
+		"json":         `{"devices": [`,
+		"service data": `{"devices": [{"address": "a", "service_data": "zz"}]}`,
+		"empty step":   `{"devices": [{"address": "a", "exchange": [{}]}]}`,
+		"both":         `{"devices": [{"address": "a", "exchange": [{"write": "00", "indicate": "00"}]}]}`,
+	} {
+		path := filepath.Join(t.TempDir(), "bluetooth.json")
+		os.WriteFile(path, []byte(script), 0o600)
+		if _, err := LoadMockScript(path); err == nil {
+			t.Errorf("Expected error for %s", name)
+		}
+	}
+	if _, err := ParseBackend(BackendMock, filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Expected error for missing script")
+	}
+	if _, err := ParseBackend("hci", ""); err == nil {
+		t.Error("Expected error for unknown backend")
+	}
+}
+
+func TestMockBackendAdapters(t *testing.T) {
+	backend := NewMockBackend(loadTestScript(t))
+	m := startMock(t, backend)
+	if !slices.Equal(m.Adapters(), []string{"hci0"}) {
+		t.Fatalf("Expected hci0, got %v", m.Adapters())
+	}
+
+	backend.SetAdapters([]MockAdapter{{ID: "hci0"}})
+	waitFor(t, "powered off", func() bool { return !m.IsAvailable() })
+	backend.SetAdapters(nil)
+	waitFor(t, "removal", func() bool { return len(m.Adapters()) == 0 })
+	backend.SetAdapters([]MockAdapter{{ID: "hci1", Powered: true}})
+	waitFor(t, "hci1", func() bool { return m.Adapter() == "hci1" })
+}
+
+func TestMockBackendScan(t *testing.T) {
+	m := startMock(t, NewMockBackend(loadTestScript(t)))
+
+	var found []string
+	results, err := m.Scan(context.Background(), 10*time.Millisecond, func(node models.CommissionableNodeData) {
+		found = append(found, *node.BLEAddress)
+	})
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	// The second device advertises no valid Matter service data
+	if len(results) != 1 || !slices.Equal(found, []string{"00:11:22:33:44:55"}) {
+		t.Fatalf("Expected one device, got %d results and %v", len(results), found)
+	}
+	node := results[0]
+	if *node.LongDiscriminator != 3840 || *node.VendorID != 0xFFF1 || *node.ProductID != 0x8000 || *node.RSSI != -60 || *node.DeviceName != "MATTER-3840" {
+		t.Errorf("Unexpected result %+v", node)
+	}
+}
+
+func TestMockBackendExchange(t *testing.T) {
+	m := startMock(t, NewMockBackend(loadTestScript(t)))
+	ctx := context.Background()
+
+	if _, err := m.Connect(ctx, "00:11:22:33:44:77"); err == nil {
+		t.Error("Expected error for unknown device")
+	}
+
+	conn, err := m.Connect(ctx, "00:11:22:33:44:55")
+	if err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer conn.Close()
+
+	// Indications only follow the writes before them
+	short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := conn.Receive(short); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected no indication before the handshake, got %v", err)
+	}
+
+	if err := conn.Write(ctx, []byte{0x65, 0x6c, 0x05}); err == nil || !strings.Contains(err.Error(), "expected 656c04000000f70006") {
+		t.Errorf("Expected mismatch error, got %v", err)
+	}
+	if err := conn.Write(ctx, []byte{0x65, 0x6c, 0x04, 0, 0, 0, 0xf7, 0, 0x06}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	for _, want := range []string{"656c04f70006", "0500"} {
+		packet, err := conn.Receive(ctx)
+		if err != nil || hex.EncodeToString(packet) != want {
+			t.Fatalf("Expected %s, got %x (%v)", want, packet, err)
+		}
+	}
+	if err := conn.Write(ctx, []byte{0x06, 0x01}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if _, err := conn.Receive(ctx); err != io.EOF {
+		t.Errorf("Expected EOF after the exchange, got %v", err)
+	}
+	if err := conn.Write(ctx, []byte{0x00}); err == nil {
+		t.Error("Expected error for write after the exchange")
+	}
+}
@@ -0,0 +1,71 @@
+package bluetooth
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// MatterServiceUUID is the 16-bit UUID 0xFFF6 of the Matter BLE service, in
+// its 128-bit form
+const MatterServiceUUID = "0000fff6-0000-1000-8000-00805f9b34fb"
+
+// Opcode of the service data of commissionable devices
+const matterOpcodeCommissionable = 0x00
+
+// ErrUnavailable is returned for scans and connections without a powered
+// adapter
+var ErrUnavailable = errors.New("no Bluetooth adapter available")
+
+// AdvertisementData is the Matter service data of a commissionable device
+// (Matter Core Specification 5.4.2.5.6)
+type AdvertisementData struct {
+	Discriminator      int
+	Version            int
+	VendorID           int
+	ProductID          int
+	AdditionalDataFlag bool
+}
+
+// ParseAdvertisementData decodes the service data a device advertises for
+// MatterServiceUUID
+func ParseAdvertisementData(data []byte) (AdvertisementData, error) {
+	if len(data) < 8 {
+		return AdvertisementData{}, fmt.Errorf("Matter service data of %d bytes too short", len(data))
+	}
+	if data[0] != matterOpcodeCommissionable {
+		return AdvertisementData{}, fmt.Errorf("unknown Matter service data opcode %#x", data[0])
+	}
+	discriminator := binary.LittleEndian.Uint16(data[1:])
+	return AdvertisementData{
+		Discriminator:      int(discriminator & 0x0FFF),
+		Version:            int(discriminator >> 12),
+		VendorID:           int(binary.LittleEndian.Uint16(data[3:])),
+		ProductID:          int(binary.LittleEndian.Uint16(data[5:])),
+		AdditionalDataFlag: data[7]&0x01 != 0,
+	}, nil
+}
+
+// commissionableNode returns a device as commissionable node, if it
+// advertises Matter service data
+func commissionableNode(serviceData []byte, address, name string, rssi *int) (models.CommissionableNodeData, bool) {
+	adv, err := ParseAdvertisementData(serviceData)
+	if err != nil {
+		return models.CommissionableNodeData{}, false
+	}
+	result := models.CommissionableNodeData{
+		LongDiscriminator: &adv.Discriminator,
+		VendorID:          &adv.VendorID,
+		ProductID:         &adv.ProductID,
+		RSSI:              rssi,
+	}
+	if address != "" {
+		result.BLEAddress = &address
+	}
+	if name != "" {
+		result.DeviceName = &name
+	}
+	return result, true
+}
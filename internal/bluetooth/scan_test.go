@@ -0,0 +1,102 @@
+package bluetooth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+func TestParseAdvertisementData(t *testing.T) {
+	adv, err := ParseAdvertisementData([]byte{0x00, 0x00, 0x1F, 0xF1, 0xFF, 0x00, 0x80, 0x01})
+	if err != nil {
+		t.Fatalf("Failed to parse service data: %v", err)
+	}
+	want := AdvertisementData{Discriminator: 3840, Version: 1, VendorID: 0xFFF1, ProductID: 0x8000, AdditionalDataFlag: true}
+	if adv != want {
+		t.Errorf("Expected %+v, got %+v", want, adv)
+	}
+
+	if _, err := ParseAdvertisementData([]byte{0x00, 0x00, 0x0F}); err == nil {
+		t.Error("Expected short service data to fail")
+	}
+	if _, err := ParseAdvertisementData([]byte{0x01, 0x00, 0x0F, 0xF1, 0xFF, 0x00, 0x80, 0x00}); err == nil {
+		t.Error("Expected unknown opcode to fail")
+	}
+}
+
+// advertise signals a device found with the service data
+func advertise(bus *fakeBus, path objectPath, address string, uuid string, data ...byte) {
+	serviceData := []interface{}{}
+	for _, b := range data {
+		serviceData = append(serviceData, b)
+	}
+	bus.signal(&message{
+		typ:    msgSignal,
+		path:   "/",
+		iface:  objectManager,
+		member: "InterfacesAdded",
+		sender: ":1.0",
+		sig:    "oa{sa{sv}}",
+		body: []interface{}{path, []interface{}{
+			[]interface{}{bluezDevice, []interface{}{
+				[]interface{}{"Address", variant{sig: "s", value: address}},
+				[]interface{}{"RSSI", variant{sig: "n", value: int16(-60)}},
+				[]interface{}{"ServiceData", variant{sig: "a{sv}", value: []interface{}{
+					[]interface{}{uuid, variant{sig: "ay", value: serviceData}},
+				}}},
+			}},
+		}},
+	})
+}
+
+func TestScan(t *testing.T) {
+	bus := newFakeBus(t, "hci0")
+	config, _ := recordChanges(AutoAdapters)
+	m, _ := NewManager(config)
+	m.Start()
+	defer m.Stop()
+	waitFor(t, "hci0", m.IsAvailable)
+
+	var found []models.CommissionableNodeData
+	done := make(chan []models.CommissionableNodeData)
+	go func() {
+		results, err := m.Scan(context.Background(), 500*time.Millisecond, func(device models.CommissionableNodeData) {
+			found = append(found, device)
+		})
+		if err != nil {
+			t.Errorf("Scan failed: %v", err)
+		}
+		done <- results
+	}()
+
+	waitFor(t, "discovery", func() bool { return bus.called("StartDiscovery") })
+	advertise(bus, "/org/bluez/hci0/dev_11", "00:11:22:33:44:55", MatterServiceUUID, 0x00, 0x00, 0x0F, 0xF1, 0xFF, 0x00, 0x80, 0x00)
+	advertise(bus, "/org/bluez/hci0/dev_22", "00:11:22:33:44:66", "0000180f-0000-1000-8000-00805f9b34fb", 0x64)
+	// Advertisements without changes aren't reported again
+	advertise(bus, "/org/bluez/hci0/dev_11", "00:11:22:33:44:55", MatterServiceUUID, 0x00, 0x00, 0x0F, 0xF1, 0xFF, 0x00, 0x80, 0x00)
+
+	results := <-done
+	if len(results) != 1 || len(found) != 1 {
+		t.Fatalf("Expected 1 device found, got %d results and %d reports", len(results), len(found))
+	}
+	device := results[0]
+	if *device.LongDiscriminator != 3840 || *device.VendorID != 0xFFF1 || *device.ProductID != 0x8000 ||
+		*device.BLEAddress != "00:11:22:33:44:55" || *device.RSSI != -60 {
+		t.Errorf("Unexpected device %+v", device)
+	}
+	for _, member := range []string{"SetDiscoveryFilter", "StopDiscovery"} {
+		if !bus.called(member) {
+			t.Errorf("Expected %s to be called", member)
+		}
+	}
+}
+
+func TestScanWithoutAdapter(t *testing.T) {
+	m, _ := NewManager(Config{Adapters: []string{"hci0"}, Enabled: true})
+	if _, err := m.Scan(context.Background(), time.Second, nil); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Expected ErrUnavailable, got %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package clock
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// minValidTime is the earliest wall clock time considered plausible. Matter
+// certificates issued by the server are never valid before this date, so a
+// clock set earlier (e.g. an RTC-less board after power loss) breaks CASE.
+var minValidTime = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+const (
+	defaultInterval = time.Hour
+	defaultMaxSkew  = 5 * time.Second
+	ntpTimeout      = 5 * time.Second
+)
+
+// Config holds configuration for the clock checker
+type Config struct {
+	NTPServer     string
+	Interval      time.Duration
+	MaxSkew       time.Duration
+	Logger        *logger.Logger
+	EventCallback func(models.EventType, interface{})
+}
+
+// Checker periodically verifies that the system clock is sane
+type Checker struct {
+	config Config
+	logger *logger.Logger
+	now    func() time.Time
+
+	mu     sync.RWMutex
+	status models.ClockStatus
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewChecker creates a new clock checker
+func NewChecker(config Config) *Checker {
+	if config.Interval <= 0 {
+		config.Interval = defaultInterval
+	}
+	if config.MaxSkew <= 0 {
+		config.MaxSkew = defaultMaxSkew
+	}
+	if config.Logger == nil {
+		config.Logger = logger.NewConsoleLogger(logger.InfoLevel)
+	}
+
+	return &Checker{
+		config: config,
+		logger: config.Logger,
+		now:    time.Now,
+		status: models.ClockStatus{Healthy: true},
+	}
+}
+
+// Start begins periodic checking in the background, starting with an
+// initial check
+func (c *Checker) Start(ctx context.Context) {
+	ctx, c.cancel = context.WithCancel(ctx)
+	c.done = make(chan struct{})
+
+	go func() {
+		defer close(c.done)
+
+		// The initial check may wait for the NTP server, don't delay the
+		// caller's startup for it
+		c.Check(ctx)
+
+		ticker := time.NewTicker(c.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				c.Check(ctx)
+			}
+		}
+	}()
+}
+
+// Stop stops periodic checking
+func (c *Checker) Stop() {
+	if c.cancel == nil {
+		return
+	}
+	c.cancel()
+	<-c.done
+}
+
+// Status returns the result of the last check
+func (c *Checker) Status() models.ClockStatus {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.status
+}
+
+// Check runs a single clock check and records the result
+func (c *Checker) Check(ctx context.Context) models.ClockStatus {
+	now := c.now()
+	status := models.ClockStatus{
+		CheckedAt: now.UTC(),
+		Healthy:   true,
+		NTPServer: c.config.NTPServer,
+	}
+
+	if now.Before(minValidTime) {
+		status.Healthy = false
+		status.Reason = fmt.Sprintf("system time %s is before %s", now.UTC().Format(time.RFC3339), minValidTime.Format(time.RFC3339))
+	} else if c.config.NTPServer != "" {
+		offset, err := QueryNTP(ctx, c.config.NTPServer, ntpTimeout)
+		if err != nil {
+			c.logger.Warn("NTP query failed",
+				logger.String("server", c.config.NTPServer),
+				logger.ErrorField(err),
+			)
+			status.Reason = err.Error()
+		} else {
+			status.OffsetMs = offset.Milliseconds()
+			if offset > c.config.MaxSkew || -offset > c.config.MaxSkew {
+				status.Healthy = false
+				status.Reason = fmt.Sprintf("clock skew %s exceeds %s", offset, c.config.MaxSkew)
+			}
+		}
+	}
+
+	c.mu.Lock()
+	wasHealthy := c.status.Healthy
+	c.status = status
+	c.mu.Unlock()
+
+	if !status.Healthy {
+		c.logger.Warn("System clock skew detected, certificate validation may fail",
+			logger.String("reason", status.Reason),
+			logger.Int64("offset_ms", status.OffsetMs),
+		)
+		if wasHealthy && c.config.EventCallback != nil {
+			c.config.EventCallback(models.EventTypeClockSkewDetected, status)
+		}
+	} else {
+		c.logger.Debug("System clock check passed", logger.Int64("offset_ms", status.OffsetMs))
+	}
+
+	return status
+}
@@ -0,0 +1,137 @@
+package clock
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []models.EventType
+}
+
+func (r *eventRecorder) record(eventType models.EventType, data interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, eventType)
+}
+
+func (r *eventRecorder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.events)
+}
+
+func TestNewCheckerDefaults(t *testing.T) {
+	c := NewChecker(Config{})
+
+	if c.config.Interval != defaultInterval {
+		t.Errorf("Expected default interval %v, got %v", defaultInterval, c.config.Interval)
+	}
+	if c.config.MaxSkew != defaultMaxSkew {
+		t.Errorf("Expected default max skew %v, got %v", defaultMaxSkew, c.config.MaxSkew)
+	}
+	if !c.Status().Healthy {
+		t.Error("Expected checker to start healthy")
+	}
+}
+
+func TestCheckClockBeforeMinValidTime(t *testing.T) {
+	recorder := &eventRecorder{}
+	c := NewChecker(Config{
+		Logger:        logger.NewConsoleLogger(logger.FatalLevel),
+		EventCallback: recorder.record,
+	})
+	c.now = func() time.Time { return time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC) }
+
+	status := c.Check(context.Background())
+	if status.Healthy {
+		t.Error("Expected unhealthy status for 1970 clock")
+	}
+	if status.Reason == "" {
+		t.Error("Expected reason to be set")
+	}
+
+	// A second failing check must not emit another event
+	c.Check(context.Background())
+	if recorder.count() != 1 {
+		t.Errorf("Expected 1 skew event, got %d", recorder.count())
+	}
+}
+
+func TestCheckClockWithNTP(t *testing.T) {
+	tests := []struct {
+		name    string
+		skew    time.Duration
+		healthy bool
+	}{
+		{"In sync", 0, true},
+		{"Skewed", time.Minute, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := startFakeNTPServer(t, tt.skew)
+			c := NewChecker(Config{
+				NTPServer: addr,
+				MaxSkew:   5 * time.Second,
+				Logger:    logger.NewConsoleLogger(logger.FatalLevel),
+			})
+
+			status := c.Check(context.Background())
+			if status.Healthy != tt.healthy {
+				t.Errorf("Expected healthy=%v, got %v (%s)", tt.healthy, status.Healthy, status.Reason)
+			}
+			if status.NTPServer != addr {
+				t.Errorf("Expected NTP server %s, got %s", addr, status.NTPServer)
+			}
+		})
+	}
+}
+
+func TestCheckerStartStop(t *testing.T) {
+	c := NewChecker(Config{
+		Interval: 10 * time.Millisecond,
+		Logger:   logger.NewConsoleLogger(logger.FatalLevel),
+	})
+
+	c.Start(context.Background())
+	deadline := time.Now().Add(time.Second)
+	for c.Status().CheckedAt.IsZero() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if c.Status().CheckedAt.IsZero() {
+		t.Error("Expected initial check on start")
+	}
+	c.Stop()
+}
+
+func TestCheckerStartDoesNotWait(t *testing.T) {
+	// An NTP server that never answers
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer conn.Close()
+
+	c := NewChecker(Config{
+		NTPServer: conn.LocalAddr().String(),
+		Logger:    logger.NewConsoleLogger(logger.FatalLevel),
+	})
+
+	start := time.Now()
+	c.Start(context.Background())
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected Start not to wait for the NTP server, took %v", elapsed)
+	}
+	c.Stop()
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected Stop to cancel the pending NTP query, took %v", elapsed)
+	}
+}
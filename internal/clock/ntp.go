@@ -0,0 +1,99 @@
+package clock
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	ntpPort       = "123"
+	ntpPacketSize = 48
+
+	// Seconds between the NTP epoch (1900) and the Unix epoch (1970)
+	ntpEpochOffset = 2208988800
+)
+
+// QueryNTP performs a single SNTP (RFC 4330) request against server and
+// returns the offset of the local clock relative to the server. A positive
+// offset means the local clock is behind the server. Replies that don't
+// answer the request, e.g. stale or spoofed packets, are rejected.
+func QueryNTP(ctx context.Context, server string, timeout time.Duration) (time.Duration, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, ntpPort)
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", server)
+	if err != nil {
+		return 0, fmt.Errorf("failed to connect to NTP server %s: %w", server, err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	conn.SetDeadline(deadline)
+	// Unblock the read when ctx is canceled, e.g. by Checker.Stop
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	req := make([]byte, ntpPacketSize)
+	req[0] = 0x23 // LI = 0, VN = 4, Mode = 3 (client)
+
+	t1 := time.Now()
+	putNTPTime(req[40:], t1)
+
+	if _, err := conn.Write(req); err != nil {
+		return 0, fmt.Errorf("failed to send NTP request: %w", err)
+	}
+
+	resp := make([]byte, ntpPacketSize)
+	n, err := conn.Read(resp)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read NTP response: %w", err)
+	}
+	t4 := time.Now()
+
+	if n < ntpPacketSize {
+		return 0, fmt.Errorf("NTP response too short: %d bytes", n)
+	}
+	if mode := resp[0] & 0x07; mode != 4 {
+		return 0, fmt.Errorf("unexpected NTP mode: %d", mode)
+	}
+	if stratum := resp[1]; stratum == 0 {
+		return 0, fmt.Errorf("NTP server sent kiss-of-death")
+	} else if stratum > 15 {
+		return 0, fmt.Errorf("NTP server is unsynchronized (stratum %d)", stratum)
+	}
+	// The server copies the transmit timestamp of the request it answers
+	if !bytes.Equal(resp[24:32], req[40:48]) {
+		return 0, fmt.Errorf("NTP response does not match the request")
+	}
+
+	t2 := ntpTime(resp[32:])
+	t3 := ntpTime(resp[40:])
+
+	offset := (t2.Sub(t1) + t3.Sub(t4)) / 2
+	return offset, nil
+}
+
+func ntpTime(b []byte) time.Time {
+	secs := binary.BigEndian.Uint32(b[0:4])
+	frac := binary.BigEndian.Uint32(b[4:8])
+
+	nsec := (int64(frac) * 1e9) >> 32
+	return time.Unix(int64(secs)-ntpEpochOffset, nsec)
+}
+
+func putNTPTime(b []byte, t time.Time) {
+	secs := uint32(t.Unix() + ntpEpochOffset)
+	frac := uint32((int64(t.Nanosecond()) << 32) / 1e9)
+
+	binary.BigEndian.PutUint32(b[0:4], secs)
+	binary.BigEndian.PutUint32(b[4:8], frac)
+}
@@ -0,0 +1,133 @@
+package clock
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// startFakeNTPServer answers SNTP requests with a clock shifted by skew
+func startFakeNTPServer(t *testing.T, skew time.Duration) string {
+	t.Helper()
+	return startFakeNTPServerWith(t, skew, nil)
+}
+
+// startFakeNTPServerWith is startFakeNTPServer with tamper applied to each
+// response before it is sent
+func startFakeNTPServerWith(t *testing.T, skew time.Duration, tamper func(resp []byte)) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, ntpPacketSize)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n < ntpPacketSize {
+				continue
+			}
+
+			resp := make([]byte, ntpPacketSize)
+			resp[0] = 0x24 // LI = 0, VN = 4, Mode = 4 (server)
+			resp[1] = 1    // Stratum
+			copy(resp[24:32], buf[40:48])
+			now := time.Now().Add(skew)
+			putNTPTime(resp[32:], now)
+			putNTPTime(resp[40:], now)
+			if tamper != nil {
+				tamper(resp)
+			}
+
+			conn.WriteTo(resp, addr)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func TestNTPTimeRoundTrip(t *testing.T) {
+	now := time.Date(2025, time.March, 14, 15, 9, 26, 535897932, time.UTC)
+
+	buf := make([]byte, 8)
+	putNTPTime(buf, now)
+	got := ntpTime(buf)
+
+	if diff := got.Sub(now); diff > time.Microsecond || diff < -time.Microsecond {
+		t.Errorf("Expected %v, got %v", now, got)
+	}
+}
+
+func TestQueryNTP(t *testing.T) {
+	addr := startFakeNTPServer(t, 10*time.Second)
+
+	offset, err := QueryNTP(context.Background(), addr, time.Second)
+	if err != nil {
+		t.Fatalf("QueryNTP failed: %v", err)
+	}
+
+	if offset < 9*time.Second || offset > 11*time.Second {
+		t.Errorf("Expected offset around 10s, got %v", offset)
+	}
+}
+
+func TestQueryNTPTimeout(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer conn.Close()
+
+	_, err = QueryNTP(context.Background(), conn.LocalAddr().String(), 50*time.Millisecond)
+	if err == nil {
+		t.Error("Expected timeout error from silent NTP server")
+	}
+}
+
+func TestQueryNTPInvalidResponse(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(resp []byte)
+	}{
+		{"Client mode", func(resp []byte) { resp[0] = 0x23 }},
+		{"Kiss-of-death", func(resp []byte) { resp[1] = 0 }},
+		{"Unsynchronized", func(resp []byte) { resp[1] = 16 }},
+		{"Originate mismatch", func(resp []byte) { resp[31]++ }},
+		{"Zero originate", func(resp []byte) { clear(resp[24:32]) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := startFakeNTPServerWith(t, 0, tt.tamper)
+			if _, err := QueryNTP(context.Background(), addr, time.Second); err == nil {
+				t.Error("Expected invalid NTP response to be rejected")
+			}
+		})
+	}
+}
+
+func TestQueryNTPCanceled(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	if _, err := QueryNTP(ctx, conn.LocalAddr().String(), 10*time.Second); err == nil {
+		t.Error("Expected error from canceled query")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected cancellation to stop the query, took %v", elapsed)
+	}
+}
@@ -0,0 +1,143 @@
+package clusters
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// descriptor is the JSON form of a manufacturer-specific cluster. IDs are
+// given as numbers or as decimal or hex strings ("0x130AFC01").
+//
+//	{
+//	  "id": "0x130AFC01",
+//	  "name": "EveEnergy",
+//	  "attributes": {"0x130A000A": "Watt", "0x130A000B": "WattAccumulated"},
+//	  "commands": {"0x00": "ResetTotals"},
+//	  "enums": {"Mode": {"0": "Off", "1": "On"}}
+//	}
+type descriptor struct {
+	ID         json.RawMessage              `json:"id"`
+	Name       string                       `json:"name"`
+	Attributes map[string]string            `json:"attributes"`
+	Commands   map[string]string            `json:"commands"`
+	Enums      map[string]map[string]string `json:"enums"`
+}
+
+// ManufacturerSpecific reports whether a cluster ID is in the
+// manufacturer-specific range: a vendor prefix with a cluster suffix of
+// 0xFC00-0xFFFE
+func ManufacturerSpecific(id uint32) bool {
+	prefix, suffix := id>>16, id&0xFFFF
+	return prefix != 0 && prefix != 0xFFFF && suffix >= 0xFC00 && suffix <= 0xFFFE
+}
+
+// ParseDescriptor parses the JSON descriptor of a manufacturer-specific
+// cluster
+func ParseDescriptor(data []byte) (*Cluster, error) {
+	var d descriptor
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, err
+	}
+
+	var ref interface{}
+	if err := json.Unmarshal(d.ID, &ref); err != nil {
+		return nil, fmt.Errorf("invalid cluster ID: %w", err)
+	}
+	id, ok, err := numericID(ref)
+	if !ok || err != nil {
+		return nil, fmt.Errorf("invalid cluster ID %s", d.ID)
+	}
+	if !ManufacturerSpecific(id) {
+		return nil, fmt.Errorf("cluster ID %#08x is not manufacturer-specific", id)
+	}
+	if d.Name == "" {
+		return nil, fmt.Errorf("cluster %#08x has no name", id)
+	}
+
+	c := &Cluster{ID: id, Name: d.Name}
+	if c.Attributes, err = descriptorNames(d.Attributes, "attribute"); err != nil {
+		return nil, err
+	}
+	if c.Commands, err = descriptorNames(d.Commands, "command"); err != nil {
+		return nil, err
+	}
+	if len(d.Enums) > 0 {
+		c.Enums = make(map[string]map[uint64]string, len(d.Enums))
+		for enum, values := range d.Enums {
+			c.Enums[enum] = make(map[uint64]string, len(values))
+			for key, label := range values {
+				value, err := strconv.ParseUint(key, 0, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid value %q of enum %s", key, enum)
+				}
+				c.Enums[enum][value] = label
+			}
+		}
+	}
+	return c, nil
+}
+
+func descriptorNames(names map[string]string, kind string) (map[uint32]string, error) {
+	out := make(map[uint32]string, len(names))
+	for key, name := range names {
+		id, err := strconv.ParseUint(key, 0, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s ID %q", kind, key)
+		}
+		if name == "" {
+			return nil, fmt.Errorf("%s %q has no name", kind, key)
+		}
+		out[uint32(id)] = name
+	}
+	return out, nil
+}
+
+// LoadDescriptors registers the manufacturer-specific clusters described by
+// the *.json files in dir and returns them. Nothing is registered if any
+// descriptor is invalid or its name is taken by another cluster.
+func (r *Registry) LoadDescriptors(dir string) ([]*Cluster, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("failed to read cluster descriptors: %w", err)
+	}
+	sort.Strings(files)
+
+	loaded := make([]*Cluster, 0, len(files))
+	ids := make(map[uint32]string)
+	names := make(map[string]string)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cluster descriptor: %w", err)
+		}
+		c, err := ParseDescriptor(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cluster descriptor %s: %w", filepath.Base(file), err)
+		}
+
+		if other, ok := ids[c.ID]; ok {
+			return nil, fmt.Errorf("cluster %#08x is described by %s and %s", c.ID, other, filepath.Base(file))
+		}
+		if other, ok := names[normalize(c.Name)]; ok {
+			return nil, fmt.Errorf("cluster name %q is used by %s and %s", c.Name, other, filepath.Base(file))
+		}
+		if existing, ok := r.ClusterByName(c.Name); ok && existing.ID != c.ID {
+			return nil, fmt.Errorf("cluster name %q of %s is taken by cluster %#04x", c.Name, filepath.Base(file), existing.ID)
+		}
+		ids[c.ID] = filepath.Base(file)
+		names[normalize(c.Name)] = filepath.Base(file)
+		loaded = append(loaded, c)
+	}
+
+	for _, c := range loaded {
+		r.Register(c)
+	}
+	return loaded, nil
+}
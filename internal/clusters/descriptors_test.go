@@ -0,0 +1,91 @@
+package clusters
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const eveEnergyDescriptor = `{
+	"id": "0x130AFC01",
+	"name": "EveEnergy",
+	"attributes": {"0x130A000A": "Watt", "0x130A000B": "WattAccumulated"},
+	"commands": {"0x00": "ResetTotals"},
+	"enums": {"Unit": {"0": "W", "1": "kW"}}
+}`
+
+func TestParseDescriptor(t *testing.T) {
+	c, err := ParseDescriptor([]byte(eveEnergyDescriptor))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if c.ID != 0x130AFC01 || c.Name != "EveEnergy" {
+		t.Errorf("Unexpected cluster %+v", c)
+	}
+	if id, ok := c.AttributeID("watt_accumulated"); !ok || id != 0x130A000B {
+		t.Errorf("Expected attribute 0x130A000B, got %#x", id)
+	}
+	if id, ok := c.CommandID("ResetTotals"); !ok || id != 0 {
+		t.Errorf("Expected command 0, got %d", id)
+	}
+	if label, ok := c.EnumValue("Unit", 1); !ok || label != "kW" {
+		t.Errorf("Expected enum label kW, got %q", label)
+	}
+
+	for name, invalid := range map[string]string{
+		"standard cluster":  `{"id": 6, "name": "MyOnOff"}`,
+		"missing name":      `{"id": "0x130AFC02"}`,
+		"invalid attribute": `{"id": 319486978, "name": "Eve", "attributes": {"watt": "Watt"}}`,
+		"invalid ID":        `{"id": true, "name": "Eve"}`,
+		"malformed":         `{"id": `,
+	} {
+		if _, err := ParseDescriptor([]byte(invalid)); err == nil {
+			t.Errorf("Expected error for %s", name)
+		}
+	}
+}
+
+func TestLoadDescriptors(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "eve.json"), []byte(eveEnergyDescriptor), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.txt"), []byte("ignored"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := NewRegistry()
+	registry.Register(&Cluster{ID: OnOffClusterID, Name: "OnOff"})
+	loaded, err := registry.LoadDescriptors(dir)
+	if err != nil || len(loaded) != 1 {
+		t.Fatalf("Expected one descriptor, got %v (%v)", loaded, err)
+	}
+
+	id, c, err := registry.ResolveCluster("eve_energy")
+	if err != nil || id != 0x130AFC01 {
+		t.Fatalf("Expected EveEnergy to resolve, got %#x (%v)", id, err)
+	}
+	if attribute, err := ResolveAttribute(c, "Watt"); err != nil || attribute != 0x130A000A {
+		t.Errorf("Expected attribute 0x130A000A, got %#x (%v)", attribute, err)
+	}
+	if name, ok := registry.AttributePathName("1/319486977/319422474"); !ok || name != "EveEnergy.Watt" {
+		t.Errorf("Expected EveEnergy.Watt, got %q", name)
+	}
+
+	// A descriptor taking the name of another cluster registers nothing
+	if err := os.WriteFile(filepath.Join(dir, "onoff.json"), []byte(`{"id": "0x130AFC02", "name": "on_off"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	registry = NewRegistry()
+	registry.Register(&Cluster{ID: OnOffClusterID, Name: "OnOff"})
+	if _, err := registry.LoadDescriptors(dir); err == nil {
+		t.Error("Expected error for a taken cluster name")
+	}
+	if _, ok := registry.Cluster(0x130AFC01); ok {
+		t.Error("Expected no cluster registered after an error")
+	}
+
+	if _, err := NewRegistry().LoadDescriptors(filepath.Join(dir, "missing")); err == nil {
+		t.Error("Expected error for a missing directory")
+	}
+}
@@ -0,0 +1,281 @@
+// Package clusters provides metadata (IDs, attribute, command and enum
+// names) for Matter clusters so API consumers can use human-readable names.
+package clusters
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Cluster describes a Matter cluster
+type Cluster struct {
+	ID         uint32                       `json:"id"`
+	Name       string                       `json:"name"`
+	Attributes map[uint32]string            `json:"attributes"`
+	Commands   map[uint32]string            `json:"commands,omitempty"`
+	Enums      map[string]map[uint64]string `json:"enums,omitempty"`
+}
+
+// globalAttributes are present on every cluster
+var globalAttributes = map[uint32]string{
+	0xFFF8: "GeneratedCommandList",
+	0xFFF9: "AcceptedCommandList",
+	0xFFFA: "EventList",
+	0xFFFB: "AttributeList",
+	0xFFFC: "FeatureMap",
+	0xFFFD: "ClusterRevision",
+}
+
+// AttributeName returns the name of an attribute of the cluster
+func (c *Cluster) AttributeName(id uint32) (string, bool) {
+	if name, ok := c.Attributes[id]; ok {
+		return name, true
+	}
+	name, ok := globalAttributes[id]
+	return name, ok
+}
+
+// AttributeID returns the ID of the attribute with the given name
+func (c *Cluster) AttributeID(name string) (uint32, bool) {
+	key := normalize(name)
+	for id, n := range c.Attributes {
+		if normalize(n) == key {
+			return id, true
+		}
+	}
+	for id, n := range globalAttributes {
+		if normalize(n) == key {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// CommandName returns the name of a command of the cluster
+func (c *Cluster) CommandName(id uint32) (string, bool) {
+	name, ok := c.Commands[id]
+	return name, ok
+}
+
+// CommandID returns the ID of the command with the given name
+func (c *Cluster) CommandID(name string) (uint32, bool) {
+	key := normalize(name)
+	for id, n := range c.Commands {
+		if normalize(n) == key {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// EnumValue returns the label of an enum value
+func (c *Cluster) EnumValue(enum string, value uint64) (string, bool) {
+	values, ok := c.Enums[enum]
+	if !ok {
+		return "", false
+	}
+	label, ok := values[value]
+	return label, ok
+}
+
+// Registry holds cluster metadata indexed by ID and name
+type Registry struct {
+	mu     sync.RWMutex
+	byID   map[uint32]*Cluster
+	byName map[string]*Cluster
+}
+
+// NewRegistry creates an empty cluster registry
+func NewRegistry() *Registry {
+	return &Registry{
+		byID:   make(map[uint32]*Cluster),
+		byName: make(map[string]*Cluster),
+	}
+}
+
+var (
+	defaultRegistry     *Registry
+	defaultRegistryOnce sync.Once
+)
+
+// Default returns the registry containing all standard Matter clusters
+func Default() *Registry {
+	defaultRegistryOnce.Do(func() {
+		defaultRegistry = NewRegistry()
+		for _, c := range standardClusters {
+			defaultRegistry.Register(c)
+		}
+	})
+	return defaultRegistry
+}
+
+// Register adds or replaces a cluster in the registry
+func (r *Registry) Register(c *Cluster) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if old, ok := r.byID[c.ID]; ok {
+		delete(r.byName, normalize(old.Name))
+	}
+	r.byID[c.ID] = c
+	r.byName[normalize(c.Name)] = c
+}
+
+// Cluster returns the cluster with the given ID
+func (r *Registry) Cluster(id uint32) (*Cluster, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c, ok := r.byID[id]
+	return c, ok
+}
+
+// ClusterByName returns the cluster with the given name. Matching ignores
+// case, spaces and underscores ("OnOff", "on_off" and "on off" are equal).
+func (r *Registry) ClusterByName(name string) (*Cluster, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c, ok := r.byName[normalize(name)]
+	return c, ok
+}
+
+// Clusters returns all registered clusters
+func (r *Registry) Clusters() []*Cluster {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make([]*Cluster, 0, len(r.byID))
+	for _, c := range r.byID {
+		out = append(out, c)
+	}
+	return out
+}
+
+// ResolveCluster resolves a cluster reference given as numeric ID (number
+// or numeric string) or cluster name. Unknown numeric IDs are accepted and
+// returned with a nil cluster so raw IDs keep working.
+func (r *Registry) ResolveCluster(ref interface{}) (uint32, *Cluster, error) {
+	if id, ok, err := numericID(ref); ok || err != nil {
+		if err != nil {
+			return 0, nil, err
+		}
+		c, _ := r.Cluster(id)
+		return id, c, nil
+	}
+
+	name, _ := ref.(string)
+	c, ok := r.ClusterByName(name)
+	if !ok {
+		return 0, nil, fmt.Errorf("unknown cluster: %q", name)
+	}
+	return c.ID, c, nil
+}
+
+// ResolveCommand resolves a command reference (numeric ID or name) within
+// a cluster. Names require known cluster metadata.
+func ResolveCommand(c *Cluster, ref interface{}) (uint32, error) {
+	if id, ok, err := numericID(ref); ok || err != nil {
+		return id, err
+	}
+
+	name, _ := ref.(string)
+	if c == nil {
+		return 0, fmt.Errorf("cannot resolve command %q for unknown cluster", name)
+	}
+	id, ok := c.CommandID(name)
+	if !ok {
+		return 0, fmt.Errorf("unknown command %q for cluster %s", name, c.Name)
+	}
+	return id, nil
+}
+
+// ResolveAttribute resolves an attribute reference (numeric ID or name)
+// within a cluster. Names require known cluster metadata.
+func ResolveAttribute(c *Cluster, ref interface{}) (uint32, error) {
+	if id, ok, err := numericID(ref); ok || err != nil {
+		return id, err
+	}
+
+	name, _ := ref.(string)
+	if c == nil {
+		return 0, fmt.Errorf("cannot resolve attribute %q for unknown cluster", name)
+	}
+	id, ok := c.AttributeID(name)
+	if !ok {
+		return 0, fmt.Errorf("unknown attribute %q for cluster %s", name, c.Name)
+	}
+	return id, nil
+}
+
+// AttributePathName returns the human-readable name ("Cluster.Attribute")
+// for an attribute path key of the form "endpoint/cluster/attribute".
+func (r *Registry) AttributePathName(path string) (string, bool) {
+	parts := strings.Split(path, "/")
+	if len(parts) != 3 {
+		return "", false
+	}
+
+	clusterID, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return "", false
+	}
+	attributeID, err := strconv.ParseUint(parts[2], 10, 32)
+	if err != nil {
+		return "", false
+	}
+
+	c, ok := r.Cluster(uint32(clusterID))
+	if !ok {
+		return "", false
+	}
+	attr, ok := c.AttributeName(uint32(attributeID))
+	if !ok {
+		return "", false
+	}
+
+	return c.Name + "." + attr, true
+}
+
+// AnnotateAttributes returns names for all known attribute paths of an
+// attribute map keyed by "endpoint/cluster/attribute".
+func (r *Registry) AnnotateAttributes(attributes map[string]interface{}) map[string]string {
+	names := make(map[string]string)
+	for path := range attributes {
+		if name, ok := r.AttributePathName(path); ok {
+			names[path] = name
+		}
+	}
+	return names
+}
+
+func numericID(ref interface{}) (uint32, bool, error) {
+	switch v := ref.(type) {
+	case float64:
+		if v < 0 || v > 0xFFFFFFFF || v != float64(uint32(v)) {
+			return 0, true, fmt.Errorf("invalid ID: %v", v)
+		}
+		return uint32(v), true, nil
+	case int:
+		if v < 0 || int64(v) > 0xFFFFFFFF {
+			return 0, true, fmt.Errorf("invalid ID: %d", v)
+		}
+		return uint32(v), true, nil
+	case uint32:
+		return v, true, nil
+	case string:
+		if id, err := strconv.ParseUint(v, 0, 32); err == nil {
+			return uint32(id), true, nil
+		}
+		return 0, false, nil
+	case nil:
+		return 0, true, fmt.Errorf("missing ID")
+	default:
+		return 0, true, fmt.Errorf("invalid ID type %T", ref)
+	}
+}
+
+func normalize(name string) string {
+	name = strings.ToLower(name)
+	return strings.NewReplacer("_", "", " ", "", "-", "").Replace(name)
+}
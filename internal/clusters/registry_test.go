@@ -0,0 +1,164 @@
+package clusters
+
+import "testing"
+
+func TestDefaultRegistryLookup(t *testing.T) {
+	registry := Default()
+
+	c, ok := registry.Cluster(OnOffClusterID)
+	if !ok {
+		t.Fatal("Expected OnOff cluster to be registered")
+	}
+	if c.Name != "OnOff" {
+		t.Errorf("Expected name OnOff, got %s", c.Name)
+	}
+
+	for _, name := range []string{"OnOff", "onoff", "on_off", "On Off"} {
+		if c, ok := registry.ClusterByName(name); !ok || c.ID != OnOffClusterID {
+			t.Errorf("Expected %q to resolve to OnOff", name)
+		}
+	}
+
+	if _, ok := registry.ClusterByName("NoSuchCluster"); ok {
+		t.Error("Expected unknown cluster name to fail")
+	}
+}
+
+func TestClusterNames(t *testing.T) {
+	c, _ := Default().Cluster(LevelControlClusterID)
+
+	if name, ok := c.AttributeName(0x0000); !ok || name != "CurrentLevel" {
+		t.Errorf("Expected CurrentLevel, got %q", name)
+	}
+	if name, ok := c.AttributeName(0xFFFD); !ok || name != "ClusterRevision" {
+		t.Errorf("Expected global attribute ClusterRevision, got %q", name)
+	}
+	if id, ok := c.AttributeID("current_level"); !ok || id != 0x0000 {
+		t.Errorf("Expected attribute ID 0, got %d", id)
+	}
+	if id, ok := c.CommandID("MoveToLevelWithOnOff"); !ok || id != 0x04 {
+		t.Errorf("Expected command ID 4, got %d", id)
+	}
+
+	onOff, _ := Default().Cluster(OnOffClusterID)
+	if label, ok := onOff.EnumValue("StartUpOnOffEnum", 2); !ok || label != "Toggle" {
+		t.Errorf("Expected Toggle, got %q", label)
+	}
+}
+
+func TestResolveCluster(t *testing.T) {
+	registry := Default()
+
+	tests := []struct {
+		name    string
+		ref     interface{}
+		wantID  uint32
+		known   bool
+		wantErr bool
+	}{
+		{"json number", float64(6), OnOffClusterID, true, false},
+		{"int", 8, LevelControlClusterID, true, false},
+		{"name", "ColorControl", ColorControlClusterID, true, false},
+		{"hex string", "0x0300", ColorControlClusterID, true, false},
+		{"unknown numeric", float64(0xFC00), 0xFC00, false, false},
+		{"unknown name", "Bogus", 0, false, true},
+		{"negative", float64(-1), 0, false, true},
+		{"fraction", 1.5, 0, false, true},
+		{"missing", nil, 0, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, c, err := registry.ResolveCluster(tt.ref)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if id != tt.wantID {
+				t.Errorf("Expected ID 0x%04X, got 0x%04X", tt.wantID, id)
+			}
+			if (c != nil) != tt.known {
+				t.Errorf("Expected known=%v, got cluster %v", tt.known, c)
+			}
+		})
+	}
+}
+
+func TestResolveCommand(t *testing.T) {
+	c, _ := Default().Cluster(OnOffClusterID)
+
+	if id, err := ResolveCommand(c, "Toggle"); err != nil || id != 0x02 {
+		t.Errorf("Expected Toggle to resolve to 2, got %d (%v)", id, err)
+	}
+	if id, err := ResolveCommand(c, float64(1)); err != nil || id != 0x01 {
+		t.Errorf("Expected numeric command 1, got %d (%v)", id, err)
+	}
+	if _, err := ResolveCommand(c, "Explode"); err == nil {
+		t.Error("Expected error for unknown command")
+	}
+	if _, err := ResolveCommand(nil, "Toggle"); err == nil {
+		t.Error("Expected error for command name on unknown cluster")
+	}
+	if id, err := ResolveCommand(nil, float64(3)); err != nil || id != 3 {
+		t.Errorf("Expected numeric command on unknown cluster to pass, got %d (%v)", id, err)
+	}
+}
+
+func TestResolveAttribute(t *testing.T) {
+	c, _ := Default().Cluster(BasicInformationClusterID)
+
+	if id, err := ResolveAttribute(c, "VendorName"); err != nil || id != 0x0001 {
+		t.Errorf("Expected VendorName to resolve to 1, got %d (%v)", id, err)
+	}
+	if _, err := ResolveAttribute(c, "Nope"); err == nil {
+		t.Error("Expected error for unknown attribute")
+	}
+}
+
+func TestAnnotateAttributes(t *testing.T) {
+	attributes := map[string]interface{}{
+		"1/6/0":       true,
+		"0/40/1":      "ACME",
+		"1/6/65533":   4,
+		"1/64512/0":   1,
+		"invalid":     nil,
+		"1/notanid/0": nil,
+	}
+
+	names := Default().AnnotateAttributes(attributes)
+
+	expected := map[string]string{
+		"1/6/0":     "OnOff.OnOff",
+		"0/40/1":    "BasicInformation.VendorName",
+		"1/6/65533": "OnOff.ClusterRevision",
+	}
+	if len(names) != len(expected) {
+		t.Errorf("Expected %d names, got %d: %v", len(expected), len(names), names)
+	}
+	for path, want := range expected {
+		if names[path] != want {
+			t.Errorf("Expected %s for %s, got %q", want, path, names[path])
+		}
+	}
+}
+
+func TestRegisterCustomCluster(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(&Cluster{ID: 0xFFF1FC01, Name: "VendorThing", Attributes: map[uint32]string{0: "Value"}})
+	registry.Register(&Cluster{ID: 0xFFF1FC01, Name: "VendorThingV2", Attributes: map[uint32]string{0: "Value"}})
+
+	if _, ok := registry.ClusterByName("VendorThing"); ok {
+		t.Error("Expected replaced cluster name to be removed")
+	}
+	if c, ok := registry.ClusterByName("VendorThingV2"); !ok || c.ID != 0xFFF1FC01 {
+		t.Error("Expected VendorThingV2 to be registered")
+	}
+	if len(registry.Clusters()) != 1 {
+		t.Errorf("Expected 1 cluster, got %d", len(registry.Clusters()))
+	}
+}
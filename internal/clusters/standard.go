@@ -0,0 +1,575 @@
+package clusters
+
+// Well-known cluster IDs referenced by the server
+const (
+	IdentifyClusterID                      = 0x0003
+	GroupsClusterID                        = 0x0004
+	ScenesClusterID                        = 0x0005
+	OnOffClusterID                         = 0x0006
+	LevelControlClusterID                  = 0x0008
+	DescriptorClusterID                    = 0x001D
+	BindingClusterID                       = 0x001E
+	AccessControlClusterID                 = 0x001F
+	BasicInformationClusterID              = 0x0028
+	OTASoftwareUpdateRequestorClusterID    = 0x002A
+	PowerSourceClusterID                   = 0x002F
+	GeneralCommissioningClusterID          = 0x0030
+	NetworkCommissioningClusterID          = 0x0031
+	GeneralDiagnosticsClusterID            = 0x0033
+	BridgedDeviceBasicInformationClusterID = 0x0039
+	SwitchClusterID                        = 0x003B
+	OperationalCredentialsClusterID        = 0x003E
+	GroupKeyManagementClusterID            = 0x003F
+	FixedLabelClusterID                    = 0x0040
+	UserLabelClusterID                     = 0x0041
+	BooleanStateClusterID                  = 0x0045
+	ICDManagementClusterID                 = 0x0046
+	ElectricalPowerMeasurementClusterID    = 0x0090
+	ElectricalEnergyMeasurementClusterID   = 0x0091
+	DoorLockClusterID                      = 0x0101
+	WindowCoveringClusterID                = 0x0102
+	ThermostatClusterID                    = 0x0201
+	FanControlClusterID                    = 0x0202
+	ColorControlClusterID                  = 0x0300
+	IlluminanceMeasurementClusterID        = 0x0400
+	TemperatureMeasurementClusterID        = 0x0402
+	PressureMeasurementClusterID           = 0x0403
+	RelativeHumidityMeasurementClusterID   = 0x0405
+	OccupancySensingClusterID              = 0x0406
+)
+
+// standardClusters contains metadata for the standard Matter clusters
+// (Matter Application Cluster Specification 1.3)
+var standardClusters = []*Cluster{
+	{
+		ID:   IdentifyClusterID,
+		Name: "Identify",
+		Attributes: map[uint32]string{
+			0x0000: "IdentifyTime",
+			0x0001: "IdentifyType",
+		},
+		Commands: map[uint32]string{
+			0x00: "Identify",
+			0x40: "TriggerEffect",
+		},
+	},
+	{
+		ID:   GroupsClusterID,
+		Name: "Groups",
+		Attributes: map[uint32]string{
+			0x0000: "NameSupport",
+		},
+		Commands: map[uint32]string{
+			0x00: "AddGroup",
+			0x01: "ViewGroup",
+			0x02: "GetGroupMembership",
+			0x03: "RemoveGroup",
+			0x04: "RemoveAllGroups",
+			0x05: "AddGroupIfIdentifying",
+		},
+	},
+	{
+		ID:   ScenesClusterID,
+		Name: "ScenesManagement",
+		Attributes: map[uint32]string{
+			0x0001: "LastConfiguredBy",
+			0x0002: "SceneTableSize",
+			0x0003: "FabricSceneInfo",
+		},
+		Commands: map[uint32]string{
+			0x00: "AddScene",
+			0x01: "ViewScene",
+			0x02: "RemoveScene",
+			0x03: "RemoveAllScenes",
+			0x04: "StoreScene",
+			0x05: "RecallScene",
+			0x06: "GetSceneMembership",
+			0x40: "CopyScene",
+		},
+	},
+	{
+		ID:   OnOffClusterID,
+		Name: "OnOff",
+		Attributes: map[uint32]string{
+			0x0000: "OnOff",
+			0x4000: "GlobalSceneControl",
+			0x4001: "OnTime",
+			0x4002: "OffWaitTime",
+			0x4003: "StartUpOnOff",
+		},
+		Commands: map[uint32]string{
+			0x00: "Off",
+			0x01: "On",
+			0x02: "Toggle",
+			0x40: "OffWithEffect",
+			0x41: "OnWithRecallGlobalScene",
+			0x42: "OnWithTimedOff",
+		},
+		Enums: map[string]map[uint64]string{
+			"StartUpOnOffEnum": {0: "Off", 1: "On", 2: "Toggle"},
+		},
+	},
+	{
+		ID:   LevelControlClusterID,
+		Name: "LevelControl",
+		Attributes: map[uint32]string{
+			0x0000: "CurrentLevel",
+			0x0001: "RemainingTime",
+			0x0002: "MinLevel",
+			0x0003: "MaxLevel",
+			0x000F: "Options",
+			0x0010: "OnOffTransitionTime",
+			0x0011: "OnLevel",
+			0x0012: "OnTransitionTime",
+			0x0013: "OffTransitionTime",
+			0x0014: "DefaultMoveRate",
+			0x4000: "StartUpCurrentLevel",
+		},
+		Commands: map[uint32]string{
+			0x00: "MoveToLevel",
+			0x01: "Move",
+			0x02: "Step",
+			0x03: "Stop",
+			0x04: "MoveToLevelWithOnOff",
+			0x05: "MoveWithOnOff",
+			0x06: "StepWithOnOff",
+			0x07: "StopWithOnOff",
+		},
+	},
+	{
+		ID:   DescriptorClusterID,
+		Name: "Descriptor",
+		Attributes: map[uint32]string{
+			0x0000: "DeviceTypeList",
+			0x0001: "ServerList",
+			0x0002: "ClientList",
+			0x0003: "PartsList",
+			0x0004: "TagList",
+		},
+	},
+	{
+		ID:   BindingClusterID,
+		Name: "Binding",
+		Attributes: map[uint32]string{
+			0x0000: "Binding",
+		},
+	},
+	{
+		ID:   AccessControlClusterID,
+		Name: "AccessControl",
+		Attributes: map[uint32]string{
+			0x0000: "ACL",
+			0x0001: "Extension",
+			0x0002: "SubjectsPerAccessControlEntry",
+			0x0003: "TargetsPerAccessControlEntry",
+			0x0004: "AccessControlEntriesPerFabric",
+		},
+	},
+	{
+		ID:   BasicInformationClusterID,
+		Name: "BasicInformation",
+		Attributes: map[uint32]string{
+			0x0000: "DataModelRevision",
+			0x0001: "VendorName",
+			0x0002: "VendorID",
+			0x0003: "ProductName",
+			0x0004: "ProductID",
+			0x0005: "NodeLabel",
+			0x0006: "Location",
+			0x0007: "HardwareVersion",
+			0x0008: "HardwareVersionString",
+			0x0009: "SoftwareVersion",
+			0x000A: "SoftwareVersionString",
+			0x000B: "ManufacturingDate",
+			0x000C: "PartNumber",
+			0x000D: "ProductURL",
+			0x000E: "ProductLabel",
+			0x000F: "SerialNumber",
+			0x0010: "LocalConfigDisabled",
+			0x0011: "Reachable",
+			0x0012: "UniqueID",
+			0x0013: "CapabilityMinima",
+			0x0014: "ProductAppearance",
+			0x0015: "SpecificationVersion",
+			0x0016: "MaxPathsPerInvoke",
+		},
+	},
+	{
+		ID:   OTASoftwareUpdateRequestorClusterID,
+		Name: "OTASoftwareUpdateRequestor",
+		Attributes: map[uint32]string{
+			0x0000: "DefaultOTAProviders",
+			0x0001: "UpdatePossible",
+			0x0002: "UpdateState",
+			0x0003: "UpdateStateProgress",
+		},
+		Commands: map[uint32]string{
+			0x00: "AnnounceOTAProvider",
+		},
+		Enums: map[string]map[uint64]string{
+			"UpdateStateEnum": {
+				0: "Unknown", 1: "Idle", 2: "Querying", 3: "DelayedOnQuery", 4: "Downloading",
+				5: "Applying", 6: "DelayedOnApply", 7: "RollingBack", 8: "DelayedOnUserConsent",
+			},
+		},
+	},
+	{
+		ID:   PowerSourceClusterID,
+		Name: "PowerSource",
+		Attributes: map[uint32]string{
+			0x0000: "Status",
+			0x0001: "Order",
+			0x0002: "Description",
+			0x000B: "BatVoltage",
+			0x000C: "BatPercentRemaining",
+			0x000D: "BatTimeRemaining",
+			0x000E: "BatChargeLevel",
+			0x001A: "BatChargeState",
+		},
+		Enums: map[string]map[uint64]string{
+			"BatChargeLevelEnum": {0: "OK", 1: "Warning", 2: "Critical"},
+		},
+	},
+	{
+		ID:   GeneralCommissioningClusterID,
+		Name: "GeneralCommissioning",
+		Attributes: map[uint32]string{
+			0x0000: "Breadcrumb",
+			0x0001: "BasicCommissioningInfo",
+			0x0002: "RegulatoryConfig",
+			0x0003: "LocationCapability",
+			0x0004: "SupportsConcurrentConnection",
+		},
+		Commands: map[uint32]string{
+			0x00: "ArmFailSafe",
+			0x02: "SetRegulatoryConfig",
+			0x04: "CommissioningComplete",
+		},
+	},
+	{
+		ID:   NetworkCommissioningClusterID,
+		Name: "NetworkCommissioning",
+		Attributes: map[uint32]string{
+			0x0000: "MaxNetworks",
+			0x0001: "Networks",
+			0x0002: "ScanMaxTimeSeconds",
+			0x0003: "ConnectMaxTimeSeconds",
+			0x0004: "InterfaceEnabled",
+			0x0005: "LastNetworkingStatus",
+			0x0006: "LastNetworkID",
+			0x0007: "LastConnectErrorValue",
+		},
+		Commands: map[uint32]string{
+			0x00: "ScanNetworks",
+			0x02: "AddOrUpdateWiFiNetwork",
+			0x03: "AddOrUpdateThreadNetwork",
+			0x04: "RemoveNetwork",
+			0x06: "ConnectNetwork",
+			0x08: "ReorderNetwork",
+		},
+	},
+	{
+		ID:   GeneralDiagnosticsClusterID,
+		Name: "GeneralDiagnostics",
+		Attributes: map[uint32]string{
+			0x0000: "NetworkInterfaces",
+			0x0001: "RebootCount",
+			0x0002: "UpTime",
+			0x0003: "TotalOperationalHours",
+			0x0004: "BootReason",
+			0x0008: "TestEventTriggersEnabled",
+		},
+		Commands: map[uint32]string{
+			0x00: "TestEventTrigger",
+			0x01: "TimeSnapshot",
+		},
+	},
+	{
+		ID:   BridgedDeviceBasicInformationClusterID,
+		Name: "BridgedDeviceBasicInformation",
+		Attributes: map[uint32]string{
+			0x0001: "VendorName",
+			0x0002: "VendorID",
+			0x0003: "ProductName",
+			0x0005: "NodeLabel",
+			0x0007: "HardwareVersion",
+			0x0008: "HardwareVersionString",
+			0x0009: "SoftwareVersion",
+			0x000A: "SoftwareVersionString",
+			0x000F: "SerialNumber",
+			0x0011: "Reachable",
+			0x0012: "UniqueID",
+		},
+	},
+	{
+		ID:   SwitchClusterID,
+		Name: "Switch",
+		Attributes: map[uint32]string{
+			0x0000: "NumberOfPositions",
+			0x0001: "CurrentPosition",
+			0x0002: "MultiPressMax",
+		},
+	},
+	{
+		ID:   OperationalCredentialsClusterID,
+		Name: "OperationalCredentials",
+		Attributes: map[uint32]string{
+			0x0000: "NOCs",
+			0x0001: "Fabrics",
+			0x0002: "SupportedFabrics",
+			0x0003: "CommissionedFabrics",
+			0x0004: "TrustedRootCertificates",
+			0x0005: "CurrentFabricIndex",
+		},
+		Commands: map[uint32]string{
+			0x00: "AttestationRequest",
+			0x02: "CertificateChainRequest",
+			0x04: "CSRRequest",
+			0x06: "AddNOC",
+			0x07: "UpdateNOC",
+			0x09: "UpdateFabricLabel",
+			0x0A: "RemoveFabric",
+			0x0B: "AddTrustedRootCertificate",
+		},
+	},
+	{
+		ID:   GroupKeyManagementClusterID,
+		Name: "GroupKeyManagement",
+		Attributes: map[uint32]string{
+			0x0000: "GroupKeyMap",
+			0x0001: "GroupTable",
+			0x0002: "MaxGroupsPerFabric",
+			0x0003: "MaxGroupKeysPerFabric",
+		},
+		Commands: map[uint32]string{
+			0x00: "KeySetWrite",
+			0x01: "KeySetRead",
+			0x03: "KeySetRemove",
+			0x04: "KeySetReadAllIndices",
+		},
+	},
+	{
+		ID:   FixedLabelClusterID,
+		Name: "FixedLabel",
+		Attributes: map[uint32]string{
+			0x0000: "LabelList",
+		},
+	},
+	{
+		ID:   UserLabelClusterID,
+		Name: "UserLabel",
+		Attributes: map[uint32]string{
+			0x0000: "LabelList",
+		},
+	},
+	{
+		ID:   BooleanStateClusterID,
+		Name: "BooleanState",
+		Attributes: map[uint32]string{
+			0x0000: "StateValue",
+		},
+	},
+	{
+		ID:   ICDManagementClusterID,
+		Name: "ICDManagement",
+		Attributes: map[uint32]string{
+			0x0000: "IdleModeDuration",
+			0x0001: "ActiveModeDuration",
+			0x0002: "ActiveModeThreshold",
+			0x0003: "RegisteredClients",
+			0x0004: "ICDCounter",
+			0x0005: "ClientsSupportedPerFabric",
+			0x0006: "UserActiveModeTriggerHint",
+			0x0007: "UserActiveModeTriggerInstruction",
+			0x0008: "OperatingMode",
+		},
+		Commands: map[uint32]string{
+			0x00: "RegisterClient",
+			0x02: "UnregisterClient",
+			0x03: "StayActiveRequest",
+		},
+	},
+	{
+		ID:   ElectricalPowerMeasurementClusterID,
+		Name: "ElectricalPowerMeasurement",
+		Attributes: map[uint32]string{
+			0x0000: "PowerMode",
+			0x0001: "NumberOfMeasurementTypes",
+			0x0002: "Accuracy",
+			0x0004: "Voltage",
+			0x0005: "ActiveCurrent",
+			0x0008: "ActivePower",
+			0x000E: "Frequency",
+		},
+	},
+	{
+		ID:   ElectricalEnergyMeasurementClusterID,
+		Name: "ElectricalEnergyMeasurement",
+		Attributes: map[uint32]string{
+			0x0000: "Accuracy",
+			0x0001: "CumulativeEnergyImported",
+			0x0002: "CumulativeEnergyExported",
+			0x0003: "PeriodicEnergyImported",
+			0x0004: "PeriodicEnergyExported",
+		},
+	},
+	{
+		ID:   DoorLockClusterID,
+		Name: "DoorLock",
+		Attributes: map[uint32]string{
+			0x0000: "LockState",
+			0x0001: "LockType",
+			0x0002: "ActuatorEnabled",
+			0x0003: "DoorState",
+			0x0021: "Language",
+			0x0023: "AutoRelockTime",
+			0x0025: "OperatingMode",
+		},
+		Commands: map[uint32]string{
+			0x00: "LockDoor",
+			0x01: "UnlockDoor",
+			0x03: "UnlockWithTimeout",
+			0x1A: "SetUser",
+			0x1B: "GetUser",
+			0x1D: "ClearUser",
+			0x22: "SetCredential",
+			0x24: "GetCredentialStatus",
+			0x26: "ClearCredential",
+		},
+		Enums: map[string]map[uint64]string{
+			"DlLockState": {0: "NotFullyLocked", 1: "Locked", 2: "Unlocked", 3: "Unlatched"},
+		},
+	},
+	{
+		ID:   WindowCoveringClusterID,
+		Name: "WindowCovering",
+		Attributes: map[uint32]string{
+			0x0000: "Type",
+			0x0007: "ConfigStatus",
+			0x000A: "OperationalStatus",
+			0x000B: "TargetPositionLiftPercent100ths",
+			0x000C: "TargetPositionTiltPercent100ths",
+			0x000D: "EndProductType",
+			0x000E: "CurrentPositionLiftPercent100ths",
+			0x000F: "CurrentPositionTiltPercent100ths",
+			0x0017: "Mode",
+		},
+		Commands: map[uint32]string{
+			0x00: "UpOrOpen",
+			0x01: "DownOrClose",
+			0x02: "StopMotion",
+			0x05: "GoToLiftPercentage",
+			0x08: "GoToTiltPercentage",
+		},
+	},
+	{
+		ID:   ThermostatClusterID,
+		Name: "Thermostat",
+		Attributes: map[uint32]string{
+			0x0000: "LocalTemperature",
+			0x0003: "AbsMinHeatSetpointLimit",
+			0x0004: "AbsMaxHeatSetpointLimit",
+			0x0005: "AbsMinCoolSetpointLimit",
+			0x0006: "AbsMaxCoolSetpointLimit",
+			0x0011: "OccupiedCoolingSetpoint",
+			0x0012: "OccupiedHeatingSetpoint",
+			0x001B: "ControlSequenceOfOperation",
+			0x001C: "SystemMode",
+			0x0029: "ThermostatRunningState",
+		},
+		Commands: map[uint32]string{
+			0x00: "SetpointRaiseLower",
+		},
+		Enums: map[string]map[uint64]string{
+			"SystemModeEnum": {
+				0: "Off", 1: "Auto", 3: "Cool", 4: "Heat", 5: "EmergencyHeat",
+				6: "Precooling", 7: "FanOnly", 8: "Dry", 9: "Sleep",
+			},
+		},
+	},
+	{
+		ID:   FanControlClusterID,
+		Name: "FanControl",
+		Attributes: map[uint32]string{
+			0x0000: "FanMode",
+			0x0001: "FanModeSequence",
+			0x0002: "PercentSetting",
+			0x0003: "PercentCurrent",
+		},
+		Enums: map[string]map[uint64]string{
+			"FanModeEnum": {0: "Off", 1: "Low", 2: "Medium", 3: "High", 4: "On", 5: "Auto", 6: "Smart"},
+		},
+	},
+	{
+		ID:   ColorControlClusterID,
+		Name: "ColorControl",
+		Attributes: map[uint32]string{
+			0x0000: "CurrentHue",
+			0x0001: "CurrentSaturation",
+			0x0002: "RemainingTime",
+			0x0003: "CurrentX",
+			0x0004: "CurrentY",
+			0x0007: "ColorTemperatureMireds",
+			0x0008: "ColorMode",
+			0x000F: "Options",
+			0x4001: "EnhancedColorMode",
+			0x400A: "ColorCapabilities",
+			0x400B: "ColorTempPhysicalMinMireds",
+			0x400C: "ColorTempPhysicalMaxMireds",
+		},
+		Commands: map[uint32]string{
+			0x00: "MoveToHue",
+			0x03: "MoveToSaturation",
+			0x06: "MoveToHueAndSaturation",
+			0x07: "MoveToColor",
+			0x0A: "MoveToColorTemperature",
+			0x47: "StopMoveStep",
+		},
+	},
+	{
+		ID:   IlluminanceMeasurementClusterID,
+		Name: "IlluminanceMeasurement",
+		Attributes: map[uint32]string{
+			0x0000: "MeasuredValue",
+			0x0001: "MinMeasuredValue",
+			0x0002: "MaxMeasuredValue",
+		},
+	},
+	{
+		ID:   TemperatureMeasurementClusterID,
+		Name: "TemperatureMeasurement",
+		Attributes: map[uint32]string{
+			0x0000: "MeasuredValue",
+			0x0001: "MinMeasuredValue",
+			0x0002: "MaxMeasuredValue",
+			0x0003: "Tolerance",
+		},
+	},
+	{
+		ID:   PressureMeasurementClusterID,
+		Name: "PressureMeasurement",
+		Attributes: map[uint32]string{
+			0x0000: "MeasuredValue",
+			0x0001: "MinMeasuredValue",
+			0x0002: "MaxMeasuredValue",
+		},
+	},
+	{
+		ID:   RelativeHumidityMeasurementClusterID,
+		Name: "RelativeHumidityMeasurement",
+		Attributes: map[uint32]string{
+			0x0000: "MeasuredValue",
+			0x0001: "MinMeasuredValue",
+			0x0002: "MaxMeasuredValue",
+		},
+	},
+	{
+		ID:   OccupancySensingClusterID,
+		Name: "OccupancySensing",
+		Attributes: map[uint32]string{
+			0x0000: "Occupancy",
+			0x0001: "OccupancySensorType",
+			0x0002: "OccupancySensorTypeBitmap",
+		},
+	},
+}
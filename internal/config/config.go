@@ -2,33 +2,153 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
+// minReplicationTokenLength keeps the replication token from being guessed,
+// it grants access to the fabric credentials
+const minReplicationTokenLength = 16
+
+// minAdminTokenLength keeps the admin token from being guessed
+const minAdminTokenLength = 16
+
 type Config struct {
-	Server    ServerConfig    `mapstructure:"server"`
-	Storage   StorageConfig   `mapstructure:"storage"`
-	Matter    MatterConfig    `mapstructure:"matter"`
-	Network   NetworkConfig   `mapstructure:"network"`
-	Bluetooth BluetoothConfig `mapstructure:"bluetooth"`
-	OTA       OTAConfig       `mapstructure:"ota"`
-	MDNS      MDNSConfig      `mapstructure:"mdns"`
-	Log       LogConfig       `mapstructure:"log"`
+	Server        ServerConfig        `mapstructure:"server"`
+	Storage       StorageConfig       `mapstructure:"storage"`
+	Matter        MatterConfig        `mapstructure:"matter"`
+	Network       NetworkConfig       `mapstructure:"network"`
+	Bluetooth     BluetoothConfig     `mapstructure:"bluetooth"`
+	OTA           OTAConfig           `mapstructure:"ota"`
+	MDNS          MDNSConfig          `mapstructure:"mdns"`
+	SRP           SRPConfig           `mapstructure:"srp"`
+	MQTT          MQTTConfig          `mapstructure:"mqtt"`
+	Telemetry     TelemetryConfig     `mapstructure:"telemetry"`
+	Replication   ReplicationConfig   `mapstructure:"replication"`
+	Log           LogConfig           `mapstructure:"log"`
+	Clock         ClockConfig         `mapstructure:"clock"`
+	Availability  AvailabilityConfig  `mapstructure:"availability"`
+	Energy        EnergyConfig        `mapstructure:"energy"`
+	Interview     InterviewConfig     `mapstructure:"interview"`
+	Commissioning CommissioningConfig `mapstructure:"commissioning"`
+	Subscriptions SubscriptionsConfig `mapstructure:"subscriptions"`
+
+	// file is the config file read, empty if none was found
+	file string
+}
+
+// File returns the config file the configuration was read from, empty if
+// none was found
+func (c *Config) File() string {
+	return c.file
 }
 
 type ServerConfig struct {
 	Port            int      `mapstructure:"port"`
 	ListenAddresses []string `mapstructure:"listen_addresses"`
 	ServeStatic     bool     `mapstructure:"serve_static"`
+	// Directory whose files take precedence over the embedded dashboard
+	StaticDir      string   `mapstructure:"static_dir"`
+	ReadyFile      string   `mapstructure:"ready_file"`
+	AllowedOrigins []string `mapstructure:"allowed_origins"`
+
+	// Per-connection WebSocket send queue and what to do when it is full
+	// ("drop_oldest" or "disconnect")
+	WebSocketQueueSize      int    `mapstructure:"websocket_queue_size"`
+	WebSocketOverflowPolicy string `mapstructure:"websocket_overflow_policy"`
+
+	// Message shape on the WebSocket ("native" or "home_assistant")
+	WebSocketDialect string `mapstructure:"websocket_dialect"`
+
+	// Per-connection command limits, 0 disables a limit
+	WebSocketCommandsPerSecond float64 `mapstructure:"websocket_commands_per_second"`
+	WebSocketCommandBurst      int     `mapstructure:"websocket_command_burst"`
+	WebSocketMaxInFlight       int     `mapstructure:"websocket_max_in_flight"`
+
+	// permessage-deflate for clients offering it: deflate level (0 selects
+	// the default) and the message size below which nothing is compressed
+	WebSocketCompression          bool `mapstructure:"websocket_compression"`
+	WebSocketCompressionLevel     int  `mapstructure:"websocket_compression_level"`
+	WebSocketCompressionThreshold int  `mapstructure:"websocket_compression_threshold"`
+
+	// Keep the deflate window of the server's and the client's messages
+	// between messages instead of negotiating no context takeover
+	WebSocketServerContextTakeover bool `mapstructure:"websocket_server_context_takeover"`
+	WebSocketClientContextTakeover bool `mapstructure:"websocket_client_context_takeover"`
+
+	// Events buffered per internal event subscriber (WebSocket connection,
+	// MQTT bridge, telemetry exporter) before further events are dropped
+	EventQueueSize int `mapstructure:"event_queue_size"`
+
+	// How long commands in flight are given to finish on shutdown before
+	// storage is flushed and connections are closed, 0 doesn't wait
+	DrainTimeout time.Duration `mapstructure:"drain_timeout"`
+	// Upper bound of the whole shutdown, e.g. to finish within the stop
+	// timeout of a container runtime. The drain is cut short to fit. 0
+	// doesn't bound it.
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+
+	// Deadline of the commands without a built-in timeout, and timeouts by
+	// command name overriding the built-in ones. 0 doesn't limit a command.
+	CommandTimeout  time.Duration            `mapstructure:"command_timeout"`
+	CommandTimeouts map[string]time.Duration `mapstructure:"command_timeouts"`
+
+	// Loopback port serving pprof and expvar, 0 disables it
+	DebugPort int `mapstructure:"debug_port"`
+
+	// Port serving the gRPC API, 0 disables it
+	GRPCPort int `mapstructure:"grpc_port"`
+
+	// Whether the inject_faults command may delay or drop storage writes,
+	// fail device interactions and sever WebSocket connections. For
+	// resilience testing only.
+	FaultInjection bool `mapstructure:"fault_injection"`
+
+	// Bearer token required by set_log_level on every transport. Empty
+	// refuses it.
+	AdminToken string `mapstructure:"admin_token"`
 }
 
 type StorageConfig struct {
 	Path string `mapstructure:"path"`
+
+	// How often node and vendor updates are written to disk, 0 writes every
+	// update right away
+	FlushInterval time.Duration `mapstructure:"flush_interval"`
+
+	// Number of emitted events kept for get_event_history, 0 disables the
+	// history
+	EventHistorySize int `mapstructure:"event_history_size"`
+
+	// Attribute paths ("endpoint/cluster/attribute", each part may be "*")
+	// whose values are recorded for get_attribute_history. Samples are kept
+	// for the retention, values within one interval are merged.
+	AttributeHistoryPaths     []string      `mapstructure:"attribute_history_paths"`
+	AttributeHistoryRetention time.Duration `mapstructure:"attribute_history_retention"`
+	AttributeHistoryInterval  time.Duration `mapstructure:"attribute_history_interval"`
+
+	// Passphrase encrypting the storage files, or a command printing it
+	// (e.g. reading it from the OS keyring). Files encrypted with one of the
+	// previous keys are re-encrypted with the current one.
+	EncryptionKey          string   `mapstructure:"encryption_key"`
+	EncryptionKeyCommand   string   `mapstructure:"encryption_key_command"`
+	PreviousEncryptionKeys []string `mapstructure:"previous_encryption_keys"`
+
+	// What to do with files failing their integrity check ("recover" from
+	// a backup or "fail")
+	CorruptionPolicy string `mapstructure:"corruption_policy"`
+
+	// Whether state-changing commands are appended to audit.jsonl in the
+	// storage path for get_audit_log
+	AuditLog bool `mapstructure:"audit_log"`
 }
 
 type MatterConfig struct {
@@ -37,6 +157,40 @@ type MatterConfig struct {
 	PAARoot                   string `mapstructure:"paa_root_cert_dir"`
 	EnableTestNetDCL          bool   `mapstructure:"enable_test_net_dcl"`
 	DisableServerInteractions bool   `mapstructure:"disable_server_interactions"`
+	AllowUntrustedDevices     bool   `mapstructure:"allow_untrusted_devices"`
+
+	// Operational node ID of the server on its fabric, advertised via mDNS.
+	// 0 leaves out the operational advertisement.
+	ControllerNodeID uint64 `mapstructure:"controller_node_id"`
+
+	// Directory of JSON descriptors of manufacturer-specific clusters,
+	// making their clusters, attributes and commands addressable by name
+	ClusterDescriptorDir string `mapstructure:"cluster_descriptor_dir"`
+
+	// Further fabrics operated besides the default fabric of VendorID and
+	// FabricID
+	Fabrics []FabricConfig `mapstructure:"fabrics"`
+}
+
+// FabricConfig is an additional fabric the server commissions devices into
+type FabricConfig struct {
+	FabricID int `mapstructure:"fabric_id"`
+	// 0 uses the vendor ID of the default fabric
+	VendorID int    `mapstructure:"vendor_id"`
+	Label    string `mapstructure:"label"`
+}
+
+// OperatedFabrics returns the default fabric followed by the additional
+// fabrics, with their vendor IDs filled in
+func (c MatterConfig) OperatedFabrics() []FabricConfig {
+	fabrics := []FabricConfig{{FabricID: c.FabricID, VendorID: c.VendorID}}
+	for _, fabric := range c.Fabrics {
+		if fabric.VendorID == 0 {
+			fabric.VendorID = c.VendorID
+		}
+		fabrics = append(fabrics, fabric)
+	}
+	return fabrics
 }
 
 type NetworkConfig struct {
@@ -44,22 +198,196 @@ type NetworkConfig struct {
 }
 
 type BluetoothConfig struct {
-	AdapterID int  `mapstructure:"adapter_id"`
-	Enabled   bool `mapstructure:"enabled"`
+	AdapterID int `mapstructure:"adapter_id"`
+	// Adapters in order of preference, e.g. "hci0", or "auto" for all.
+	// Takes precedence over AdapterID.
+	Adapters []string `mapstructure:"adapters"`
+	Enabled  bool     `mapstructure:"enabled"`
+	// Backend is "bluez", or "mock" to replay MockScript instead of using
+	// real adapters
+	Backend    string `mapstructure:"backend"`
+	MockScript string `mapstructure:"mock_script"`
+}
+
+// AdapterNames returns the adapters to use, none if Bluetooth is disabled
+func (c BluetoothConfig) AdapterNames() []string {
+	if len(c.Adapters) > 0 {
+		return c.Adapters
+	}
+	if c.AdapterID >= 0 {
+		return []string{fmt.Sprintf("hci%d", c.AdapterID)}
+	}
+	return nil
 }
 
 type OTAConfig struct {
 	ProviderDir string `mapstructure:"provider_dir"`
+	// How often the DCL and provider_dir are checked for software updates
+	// of the nodes, 0 disables the periodic checks
+	CheckInterval time.Duration `mapstructure:"check_interval"`
 }
 
 type MDNSConfig struct {
-	Enabled  bool   `mapstructure:"enabled"`
-	Hostname string `mapstructure:"hostname"`
+	Enabled       bool     `mapstructure:"enabled"`
+	Hostname      string   `mapstructure:"hostname"`
+	Interfaces    []string `mapstructure:"interfaces"`
+	AddressFamily string   `mapstructure:"address_family"`
+}
+
+// SRPConfig configures the registration of the mDNS services with the SRP
+// server of a Thread border router
+type SRPConfig struct {
+	// SRP server address, host:port. Empty disables SRP.
+	Server   string        `mapstructure:"server"`
+	Lease    time.Duration `mapstructure:"lease"`
+	KeyLease time.Duration `mapstructure:"key_lease"`
+}
+
+// MQTTConfig configures the bridge publishing the nodes to an MQTT broker
+type MQTTConfig struct {
+	// Broker URL, e.g. tcp://localhost:1883 or mqtts://broker:8883. Empty
+	// disables the bridge.
+	Broker    string        `mapstructure:"broker"`
+	ClientID  string        `mapstructure:"client_id"`
+	Username  string        `mapstructure:"username"`
+	Password  string        `mapstructure:"password"`
+	KeepAlive time.Duration `mapstructure:"keep_alive"`
+	// First level of the topics of the bridge
+	TopicPrefix string `mapstructure:"topic_prefix"`
+	// Home Assistant MQTT discovery
+	Discovery       bool   `mapstructure:"discovery"`
+	DiscoveryPrefix string `mapstructure:"discovery_prefix"`
+}
+
+type TelemetryConfig struct {
+	// Line protocol write endpoint, e.g.
+	// http://influxdb:8086/api/v2/write?org=home&bucket=matter. Empty
+	// disables the exporter.
+	URL string `mapstructure:"url"`
+	// Sent as "Authorization: Token <token>" (InfluxDB 2)
+	Token string `mapstructure:"token"`
+	// Attributes exported, the built-in mapping of measurements when empty
+	Metrics []TelemetryMetric `mapstructure:"metrics"`
+	// Tags added to every point
+	Tags          map[string]string `mapstructure:"tags"`
+	BatchSize     int               `mapstructure:"batch_size"`
+	FlushInterval time.Duration     `mapstructure:"flush_interval"`
+	// Points kept while the endpoint is slow or down, the oldest are dropped
+	// beyond it
+	BufferSize int           `mapstructure:"buffer_size"`
+	Timeout    time.Duration `mapstructure:"timeout"`
+}
+
+// TelemetryMetric maps the values of an attribute path pattern like
+// "*/1026/0" to a field of a measurement
+type TelemetryMetric struct {
+	Attribute   string `mapstructure:"attribute"`
+	Measurement string `mapstructure:"measurement"`
+	// Defaults to "value"
+	Field string `mapstructure:"field"`
+	// Factor applied to the value, 0 means 1
+	Scale float64           `mapstructure:"scale"`
+	Tags  map[string]string `mapstructure:"tags"`
+}
+
+// ReplicationConfig configures mirroring the storage of a primary server to
+// a standby that takes over when the primary disappears
+type ReplicationConfig struct {
+	// primary, standby or empty to disable replication
+	Role string `mapstructure:"role"`
+	// Base URL of the primary, e.g. http://hub1:5580 (standby only)
+	PrimaryURL string `mapstructure:"primary_url"`
+	// Shared secret authenticating the standby
+	Token             string        `mapstructure:"token"`
+	HeartbeatInterval time.Duration `mapstructure:"heartbeat_interval"`
+	// The standby promotes itself after losing the primary for this long,
+	// 0 only promotes with promote_standby
+	PromoteAfter time.Duration `mapstructure:"promote_after"`
+	// Whether a standby may mirror a primary URL over plain http, receiving
+	// the token and the fabric credentials unencrypted
+	AllowPlaintext bool `mapstructure:"allow_plaintext"`
 }
 
 type LogConfig struct {
 	Level  string `mapstructure:"level"`
 	Format string `mapstructure:"format"`
+	// Output is stdout, file, syslog or journald. Empty means file if File
+	// is set and stdout otherwise.
+	Output string `mapstructure:"output"`
+	// File is written instead of stdout if set, rotated once it reaches
+	// MaxSizeMB
+	File       string `mapstructure:"file"`
+	MaxSizeMB  int    `mapstructure:"max_size_mb"`
+	MaxBackups int    `mapstructure:"max_backups"`
+	MaxAgeDays int    `mapstructure:"max_age_days"`
+	Compress   bool   `mapstructure:"compress"`
+	// HistorySize is the number of recent log entries kept in memory for
+	// get_logs and diagnostics bundles
+	HistorySize int `mapstructure:"history_size"`
+}
+
+type ClockConfig struct {
+	NTPServer     string        `mapstructure:"ntp_server"`
+	CheckInterval time.Duration `mapstructure:"check_interval"`
+	MaxSkew       time.Duration `mapstructure:"max_skew"`
+}
+
+type AvailabilityConfig struct {
+	MainsInterval   time.Duration `mapstructure:"mains_interval"`
+	BatteryInterval time.Duration `mapstructure:"battery_interval"`
+	Timeout         time.Duration `mapstructure:"timeout"`
+}
+
+// EnergyConfig configures the energy summary of the nodes with the
+// Electrical Power and Energy Measurement clusters
+type EnergyConfig struct {
+	// How often the power and energy attributes are read from the nodes in
+	// addition to their reports, 0 disables polling
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+	// Record the power and energy values for get_attribute_history
+	RecordHistory bool `mapstructure:"record_history"`
+}
+
+// InterviewConfig configures the automatic re-interviews of nodes whose
+// interview is outdated, whose firmware was updated or, periodically, of all
+// nodes
+type InterviewConfig struct {
+	// Re-interview nodes whose last interview is older, 0 disables periodic
+	// re-interviews
+	Interval time.Duration `mapstructure:"interval"`
+	// Nodes re-interviewed at the same time, 0 disables automatic
+	// re-interviews
+	Concurrency int `mapstructure:"concurrency"`
+	// Maximum random delay of a re-interview, spreading them out
+	Jitter time.Duration `mapstructure:"jitter"`
+}
+
+// CommissioningConfig configures the queue of commissioning requests
+type CommissioningConfig struct {
+	// Devices commissioned at the same time, 0 counts as 1
+	MaxConcurrent int `mapstructure:"max_concurrent"`
+	// Requests waiting for a commissioning slot, 0 doesn't limit them
+	MaxQueued int `mapstructure:"max_queued"`
+	// Timeout of a single commissioning attempt, 0 doesn't limit it
+	AttemptTimeout time.Duration `mapstructure:"attempt_timeout"`
+	// Attempts per request, 0 counts as 1
+	MaxAttempts int `mapstructure:"max_attempts"`
+	// Delay before a failed attempt is retried
+	RetryDelay time.Duration `mapstructure:"retry_delay"`
+}
+
+// SubscriptionsConfig configures the report intervals requested for the
+// subscription of each node. Battery powered nodes get a longer maximum
+// interval, so their keep-alive reports don't drain the battery.
+type SubscriptionsConfig struct {
+	// Minimum interval between reports
+	MinInterval time.Duration `mapstructure:"min_interval"`
+	// Maximum interval between reports of mains powered nodes, 0 leaves it
+	// to the controller
+	MaxInterval time.Duration `mapstructure:"max_interval"`
+	// Maximum interval between reports of battery powered nodes, 0 leaves
+	// it to the controller
+	BatteryMaxInterval time.Duration `mapstructure:"battery_max_interval"`
 }
 
 func Load(cmd *cobra.Command) (*Config, error) {
@@ -124,6 +452,8 @@ func Load(cmd *cobra.Command) (*Config, error) {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
+	cfg.file = v.ConfigFileUsed()
+
 	// Set default storage path if not provided
 	if cfg.Storage.Path == "" {
 		// Use current working directory as default instead of home directory
@@ -142,24 +472,142 @@ func Load(cmd *cobra.Command) (*Config, error) {
 	return &cfg, nil
 }
 
+// Defaults returns the configuration without config file, environment
+// variables and flags
+func Defaults() (*Config, error) {
+	v := viper.New()
+	setDefaults(v)
+
+	var cfg Config
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+	return &cfg, nil
+}
+
 func setDefaults(v *viper.Viper) {
 	v.SetDefault("server.port", 5580)
+	v.SetDefault("server.allowed_origins", []string{})
+	v.SetDefault("server.serve_static", false)
+	v.SetDefault("server.static_dir", "")
+	v.SetDefault("server.debug_port", 0)
+	v.SetDefault("server.grpc_port", 0)
+	v.SetDefault("server.websocket_queue_size", 256)
+	v.SetDefault("server.event_queue_size", 1024)
+	v.SetDefault("server.websocket_overflow_policy", "drop_oldest")
+	v.SetDefault("server.websocket_dialect", "native")
+	v.SetDefault("server.websocket_commands_per_second", 50.0)
+	v.SetDefault("server.websocket_command_burst", 100)
+	v.SetDefault("server.websocket_max_in_flight", 32)
+	v.SetDefault("server.websocket_compression", false)
+	v.SetDefault("server.websocket_compression_level", 1)
+	v.SetDefault("server.websocket_compression_threshold", 512)
+	v.SetDefault("server.websocket_server_context_takeover", false)
+	v.SetDefault("server.websocket_client_context_takeover", false)
+	v.SetDefault("server.drain_timeout", 10*time.Second)
+	v.SetDefault("server.shutdown_timeout", 8*time.Second)
+	v.SetDefault("server.command_timeout", time.Minute)
+	v.SetDefault("server.fault_injection", false)
+	v.SetDefault("server.admin_token", "")
+	v.SetDefault("storage.flush_interval", time.Duration(0))
+	v.SetDefault("storage.event_history_size", 1000)
+	v.SetDefault("storage.attribute_history_paths", []string{})
+	v.SetDefault("storage.attribute_history_retention", 7*24*time.Hour)
+	v.SetDefault("storage.attribute_history_interval", time.Minute)
+	v.SetDefault("storage.encryption_key", "")
+	v.SetDefault("storage.encryption_key_command", "")
+	v.SetDefault("storage.previous_encryption_keys", []string{})
+	v.SetDefault("storage.corruption_policy", "recover")
+	v.SetDefault("storage.audit_log", true)
 	v.SetDefault("matter.vendor_id", 0xFFF1)
 	v.SetDefault("matter.fabric_id", 1)
 	v.SetDefault("matter.enable_test_net_dcl", false)
 	v.SetDefault("matter.disable_server_interactions", false)
+	v.SetDefault("matter.allow_untrusted_devices", false)
+	v.SetDefault("matter.controller_node_id", 112233)
+	v.SetDefault("matter.cluster_descriptor_dir", "")
+	v.SetDefault("ota.check_interval", 24*time.Hour)
 	v.SetDefault("bluetooth.adapter_id", -1)
+	v.SetDefault("bluetooth.adapters", []string{})
 	v.SetDefault("bluetooth.enabled", false)
+	v.SetDefault("bluetooth.backend", "bluez")
+	v.SetDefault("bluetooth.mock_script", "")
 	v.SetDefault("mdns.enabled", true)
 	v.SetDefault("mdns.hostname", getDefaultHostname())
+	v.SetDefault("mdns.interfaces", []string{})
+	v.SetDefault("mdns.address_family", "both")
+	v.SetDefault("srp.server", "")
+	v.SetDefault("srp.lease", 2*time.Hour)
+	v.SetDefault("srp.key_lease", 14*24*time.Hour)
+	v.SetDefault("mqtt.broker", "")
+	v.SetDefault("mqtt.client_id", "matter-server")
+	v.SetDefault("mqtt.username", "")
+	v.SetDefault("mqtt.password", "")
+	v.SetDefault("mqtt.keep_alive", time.Minute)
+	v.SetDefault("mqtt.topic_prefix", "matter")
+	v.SetDefault("mqtt.discovery", true)
+	v.SetDefault("mqtt.discovery_prefix", "homeassistant")
+
+	// Telemetry defaults
+	v.SetDefault("telemetry.url", "")
+	v.SetDefault("telemetry.token", "")
+	v.SetDefault("telemetry.batch_size", 500)
+	v.SetDefault("telemetry.flush_interval", 10*time.Second)
+	v.SetDefault("telemetry.buffer_size", 10000)
+	v.SetDefault("telemetry.timeout", 10*time.Second)
+
+	// Replication defaults
+	v.SetDefault("replication.role", "")
+	v.SetDefault("replication.primary_url", "")
+	v.SetDefault("replication.token", "")
+	v.SetDefault("replication.heartbeat_interval", 5*time.Second)
+	v.SetDefault("replication.promote_after", time.Minute)
+	v.SetDefault("replication.allow_plaintext", false)
+
 	v.SetDefault("log.level", "info")
 	v.SetDefault("log.format", "console")
+	v.SetDefault("log.output", "")
+	v.SetDefault("log.file", "")
+	v.SetDefault("log.max_size_mb", 100)
+	v.SetDefault("log.max_backups", 5)
+	v.SetDefault("log.max_age_days", 0)
+	v.SetDefault("log.compress", true)
+	v.SetDefault("log.history_size", 1000)
+	v.SetDefault("clock.ntp_server", "")
+	v.SetDefault("clock.check_interval", time.Hour)
+	v.SetDefault("clock.max_skew", 5*time.Second)
+	v.SetDefault("availability.mains_interval", time.Minute)
+	v.SetDefault("availability.battery_interval", 30*time.Minute)
+	v.SetDefault("availability.timeout", 10*time.Second)
+
+	v.SetDefault("energy.poll_interval", time.Duration(0))
+	v.SetDefault("energy.record_history", false)
+
+	v.SetDefault("interview.interval", time.Duration(0))
+	v.SetDefault("interview.concurrency", 2)
+	v.SetDefault("interview.jitter", 5*time.Minute)
+
+	v.SetDefault("commissioning.max_concurrent", 1)
+	v.SetDefault("commissioning.max_queued", 16)
+	v.SetDefault("commissioning.attempt_timeout", 5*time.Minute)
+	v.SetDefault("commissioning.max_attempts", 2)
+	v.SetDefault("commissioning.retry_delay", 10*time.Second)
+
+	v.SetDefault("subscriptions.min_interval", time.Duration(0))
+	v.SetDefault("subscriptions.max_interval", time.Minute)
+	v.SetDefault("subscriptions.battery_max_interval", 10*time.Minute)
 }
 
 func bindFlags(cmd *cobra.Command, v *viper.Viper) error {
 	flags := map[string]string{
 		"port":                        "server.port",
 		"listen":                      "server.listen_addresses",
+		"ready-file":                  "server.ready_file",
+		"allowed-origins":             "server.allowed_origins",
+		"serve-static":                "server.serve_static",
+		"static-dir":                  "server.static_dir",
+		"debug-port":                  "server.debug_port",
+		"grpc-port":                   "server.grpc_port",
 		"storage-path":                "storage.path",
 		"vendor-id":                   "matter.vendor_id",
 		"fabric-id":                   "matter.fabric_id",
@@ -169,14 +617,23 @@ func bindFlags(cmd *cobra.Command, v *viper.Viper) error {
 		"bluetooth-adapter":           "bluetooth.adapter_id",
 		"ota-provider-dir":            "ota.provider_dir",
 		"disable-server-interactions": "matter.disable_server_interactions",
+		"allow-untrusted-devices":     "matter.allow_untrusted_devices",
 		"mdns-enabled":                "mdns.enabled",
 		"mdns-hostname":               "mdns.hostname",
 		"log-level":                   "log.level",
 		"log-format":                  "log.format",
+		"log-output":                  "log.output",
+		"log-file":                    "log.file",
+		"ntp-server":                  "clock.ntp_server",
 	}
 
 	for flag, key := range flags {
-		if err := v.BindPFlag(key, cmd.Flags().Lookup(flag)); err != nil {
+		// Subcommands only declare the flags they use
+		f := cmd.Flags().Lookup(flag)
+		if f == nil {
+			continue
+		}
+		if err := v.BindPFlag(key, f); err != nil {
 			return fmt.Errorf("failed to bind flag %s: %w", flag, err)
 		}
 	}
@@ -189,6 +646,101 @@ func validate(cfg *Config) error {
 		return fmt.Errorf("invalid port: %d", cfg.Server.Port)
 	}
 
+	if cfg.Server.DebugPort < 0 || cfg.Server.DebugPort > 65535 || cfg.Server.DebugPort == cfg.Server.Port {
+		return fmt.Errorf("invalid debug port: %d", cfg.Server.DebugPort)
+	}
+
+	if cfg.Server.GRPCPort < 0 || cfg.Server.GRPCPort > 65535 || cfg.Server.GRPCPort == cfg.Server.Port ||
+		(cfg.Server.GRPCPort != 0 && cfg.Server.GRPCPort == cfg.Server.DebugPort) {
+		return fmt.Errorf("invalid gRPC port: %d", cfg.Server.GRPCPort)
+	}
+
+	if cfg.Server.WebSocketQueueSize < 0 {
+		return fmt.Errorf("invalid WebSocket queue size: %d", cfg.Server.WebSocketQueueSize)
+	}
+	if cfg.Server.EventQueueSize < 0 {
+		return fmt.Errorf("invalid event queue size: %d", cfg.Server.EventQueueSize)
+	}
+
+	if cfg.Server.WebSocketCommandsPerSecond < 0 || cfg.Server.WebSocketCommandBurst < 0 || cfg.Server.WebSocketMaxInFlight < 0 {
+		return fmt.Errorf("invalid WebSocket command limits: %v/s, burst %d, %d in flight",
+			cfg.Server.WebSocketCommandsPerSecond, cfg.Server.WebSocketCommandBurst, cfg.Server.WebSocketMaxInFlight)
+	}
+
+	if cfg.Server.WebSocketCompressionLevel < 0 || cfg.Server.WebSocketCompressionLevel > 9 {
+		return fmt.Errorf("invalid WebSocket compression level: %d", cfg.Server.WebSocketCompressionLevel)
+	}
+	if cfg.Server.WebSocketCompressionThreshold < 0 {
+		return fmt.Errorf("invalid WebSocket compression threshold: %d", cfg.Server.WebSocketCompressionThreshold)
+	}
+	if (cfg.Server.WebSocketServerContextTakeover || cfg.Server.WebSocketClientContextTakeover) && !cfg.Server.WebSocketCompression {
+		return fmt.Errorf("WebSocket context takeover requires websocket_compression")
+	}
+
+	if cfg.Server.AdminToken != "" && len(cfg.Server.AdminToken) < minAdminTokenLength {
+		return fmt.Errorf("admin token must have at least %d characters", minAdminTokenLength)
+	}
+
+	if cfg.Server.DrainTimeout < 0 {
+		return fmt.Errorf("invalid drain timeout: %v", cfg.Server.DrainTimeout)
+	}
+	if cfg.Server.ShutdownTimeout < 0 {
+		return fmt.Errorf("invalid shutdown timeout: %v", cfg.Server.ShutdownTimeout)
+	}
+	if cfg.Server.CommandTimeout < 0 {
+		return fmt.Errorf("invalid command timeout: %v", cfg.Server.CommandTimeout)
+	}
+	for command, timeout := range cfg.Server.CommandTimeouts {
+		if timeout < 0 {
+			return fmt.Errorf("invalid timeout of command %s: %v", command, timeout)
+		}
+	}
+
+	switch cfg.Server.WebSocketOverflowPolicy {
+	case "", "drop_oldest", "disconnect":
+	default:
+		return fmt.Errorf("invalid WebSocket overflow policy: %q", cfg.Server.WebSocketOverflowPolicy)
+	}
+
+	switch cfg.Server.WebSocketDialect {
+	case "", "native", "home_assistant":
+	default:
+		return fmt.Errorf("invalid WebSocket dialect: %q", cfg.Server.WebSocketDialect)
+	}
+
+	if cfg.Storage.FlushInterval < 0 {
+		return fmt.Errorf("invalid storage flush interval: %s", cfg.Storage.FlushInterval)
+	}
+
+	if cfg.Storage.EventHistorySize < 0 {
+		return fmt.Errorf("invalid event history size: %d", cfg.Storage.EventHistorySize)
+	}
+
+	for _, path := range cfg.Storage.AttributeHistoryPaths {
+		if strings.Count(path, "/") != 2 {
+			return fmt.Errorf("invalid attribute history path %q: expected endpoint/cluster/attribute", path)
+		}
+	}
+	if cfg.Storage.AttributeHistoryRetention < 0 {
+		return fmt.Errorf("invalid attribute history retention: %s", cfg.Storage.AttributeHistoryRetention)
+	}
+	if cfg.Storage.AttributeHistoryInterval < 0 {
+		return fmt.Errorf("invalid attribute history interval: %s", cfg.Storage.AttributeHistoryInterval)
+	}
+
+	switch cfg.Storage.CorruptionPolicy {
+	case "", "recover", "fail":
+	default:
+		return fmt.Errorf("invalid storage corruption policy: %q", cfg.Storage.CorruptionPolicy)
+	}
+
+	if cfg.Storage.EncryptionKey != "" && cfg.Storage.EncryptionKeyCommand != "" {
+		return fmt.Errorf("storage encryption key and key command are mutually exclusive")
+	}
+	if cfg.Storage.EncryptionKeyCommand != "" && strings.TrimSpace(cfg.Storage.EncryptionKeyCommand) == "" {
+		return fmt.Errorf("storage encryption key command is blank")
+	}
+
 	if cfg.Matter.VendorID < 0 || cfg.Matter.VendorID > 0xFFFF {
 		return fmt.Errorf("invalid vendor ID: %d", cfg.Matter.VendorID)
 	}
@@ -197,6 +749,181 @@ func validate(cfg *Config) error {
 		return fmt.Errorf("invalid fabric ID: %d", cfg.Matter.FabricID)
 	}
 
+	fabricIDs := map[int]bool{cfg.Matter.FabricID: true}
+	for _, fabric := range cfg.Matter.Fabrics {
+		if fabric.FabricID <= 0 {
+			return fmt.Errorf("invalid fabric ID: %d", fabric.FabricID)
+		}
+		if fabricIDs[fabric.FabricID] {
+			return fmt.Errorf("fabric ID %d is configured twice", fabric.FabricID)
+		}
+		fabricIDs[fabric.FabricID] = true
+		if fabric.VendorID < 0 || fabric.VendorID > 0xFFFF {
+			return fmt.Errorf("invalid vendor ID of fabric %d: %d", fabric.FabricID, fabric.VendorID)
+		}
+	}
+
+	// Larger IDs are group, temporary and reserved node IDs
+	if cfg.Matter.ControllerNodeID > 0xFFFFFFEFFFFFFFFF {
+		return fmt.Errorf("invalid controller node ID: %#x", cfg.Matter.ControllerNodeID)
+	}
+
+	for _, adapter := range cfg.Bluetooth.Adapters {
+		number, ok := strings.CutPrefix(adapter, "hci")
+		_, err := strconv.ParseUint(number, 10, 16)
+		if adapter == "auto" && len(cfg.Bluetooth.Adapters) == 1 || ok && err == nil {
+			continue
+		}
+		return fmt.Errorf("invalid Bluetooth adapters %q: expected hci<N> adapters or auto", cfg.Bluetooth.Adapters)
+	}
+
+	switch cfg.Bluetooth.Backend {
+	case "", "bluez":
+	case "mock":
+		if cfg.Bluetooth.MockScript == "" {
+			return fmt.Errorf("Bluetooth mock backend requires a mock script")
+		}
+	default:
+		return fmt.Errorf("invalid Bluetooth backend: %q", cfg.Bluetooth.Backend)
+	}
+
+	switch cfg.MDNS.AddressFamily {
+	case "", "ipv4", "ipv6", "both":
+	default:
+		return fmt.Errorf("invalid mDNS address family: %q", cfg.MDNS.AddressFamily)
+	}
+
+	if cfg.SRP.Server != "" {
+		if _, _, err := net.SplitHostPort(cfg.SRP.Server); err != nil {
+			return fmt.Errorf("invalid SRP server address %q: %w", cfg.SRP.Server, err)
+		}
+		// The key has to outlive the services, keeping the name reserved
+		if cfg.SRP.Lease < 30*time.Second || cfg.SRP.KeyLease < cfg.SRP.Lease {
+			return fmt.Errorf("invalid SRP leases: lease %s must be at least 30s and key lease %s at least the lease",
+				cfg.SRP.Lease, cfg.SRP.KeyLease)
+		}
+	}
+
+	if cfg.MQTT.Broker != "" {
+		u, err := url.Parse(cfg.MQTT.Broker)
+		if err != nil {
+			return fmt.Errorf("invalid MQTT broker %q: %w", cfg.MQTT.Broker, err)
+		}
+		switch u.Scheme {
+		case "tcp", "mqtt", "ssl", "tls", "mqtts":
+		default:
+			return fmt.Errorf("invalid MQTT broker %q: scheme must be tcp, mqtt, ssl, tls or mqtts", cfg.MQTT.Broker)
+		}
+		if cfg.MQTT.ClientID == "" || cfg.MQTT.KeepAlive < time.Second {
+			return fmt.Errorf("invalid MQTT client: client ID %q, keep alive %s", cfg.MQTT.ClientID, cfg.MQTT.KeepAlive)
+		}
+		// The prefixes are topic levels the bridge subscribes below
+		for _, prefix := range []string{cfg.MQTT.TopicPrefix, cfg.MQTT.DiscoveryPrefix} {
+			if prefix == "" || strings.ContainsAny(prefix, "+#") || strings.HasPrefix(prefix, "/") || strings.HasSuffix(prefix, "/") {
+				return fmt.Errorf("invalid MQTT topic prefix: %q", prefix)
+			}
+		}
+	}
+
+	if cfg.Telemetry.URL != "" {
+		u, err := url.Parse(cfg.Telemetry.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid telemetry URL %q: expected an http or https URL", cfg.Telemetry.URL)
+		}
+		if cfg.Telemetry.BatchSize <= 0 || cfg.Telemetry.BufferSize < cfg.Telemetry.BatchSize ||
+			cfg.Telemetry.FlushInterval <= 0 || cfg.Telemetry.Timeout <= 0 {
+			return fmt.Errorf("invalid telemetry batching: batch size %d, buffer size %d, flush interval %s, timeout %s",
+				cfg.Telemetry.BatchSize, cfg.Telemetry.BufferSize, cfg.Telemetry.FlushInterval, cfg.Telemetry.Timeout)
+		}
+		for _, metric := range cfg.Telemetry.Metrics {
+			if strings.Count(metric.Attribute, "/") != 2 || metric.Measurement == "" {
+				return fmt.Errorf("invalid telemetry metric %q: expected endpoint/cluster/attribute and a measurement", metric.Attribute)
+			}
+		}
+	}
+
+	switch cfg.Replication.Role {
+	case "":
+	case "primary", "standby":
+		if len(cfg.Replication.Token) < minReplicationTokenLength {
+			return fmt.Errorf("replication requires a token of at least %d characters", minReplicationTokenLength)
+		}
+		if cfg.Replication.HeartbeatInterval < 100*time.Millisecond || cfg.Replication.PromoteAfter < 0 {
+			return fmt.Errorf("invalid replication timing: heartbeat interval %s, promote after %s",
+				cfg.Replication.HeartbeatInterval, cfg.Replication.PromoteAfter)
+		}
+		if cfg.Replication.Role == "standby" {
+			u, err := url.Parse(cfg.Replication.PrimaryURL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("invalid replication primary URL %q: expected an http or https URL", cfg.Replication.PrimaryURL)
+			}
+			if u.Scheme == "http" && !cfg.Replication.AllowPlaintext {
+				return fmt.Errorf("replication primary URL %q would send the fabric credentials unencrypted: use https or set replication.allow_plaintext", cfg.Replication.PrimaryURL)
+			}
+			// A standby taking over before a heartbeat could arrive would
+			// fight the primary over the devices
+			if cfg.Replication.PromoteAfter != 0 && cfg.Replication.PromoteAfter < 3*cfg.Replication.HeartbeatInterval {
+				return fmt.Errorf("replication promote after %s must be at least three heartbeat intervals", cfg.Replication.PromoteAfter)
+			}
+		}
+	default:
+		return fmt.Errorf("invalid replication role: %q", cfg.Replication.Role)
+	}
+
+	switch cfg.Log.Output {
+	case "", "stdout", "syslog", "journald":
+	case "file":
+		if cfg.Log.File == "" {
+			return fmt.Errorf("log output file requires a log file")
+		}
+	default:
+		return fmt.Errorf("invalid log output: %q", cfg.Log.Output)
+	}
+
+	if cfg.Log.MaxSizeMB < 0 || cfg.Log.MaxBackups < 0 || cfg.Log.MaxAgeDays < 0 {
+		return fmt.Errorf("invalid log rotation: %d MB, %d backups, %d days",
+			cfg.Log.MaxSizeMB, cfg.Log.MaxBackups, cfg.Log.MaxAgeDays)
+	}
+	if cfg.Log.HistorySize < 0 {
+		return fmt.Errorf("invalid log history size: %d", cfg.Log.HistorySize)
+	}
+
+	if cfg.Clock.CheckInterval <= 0 {
+		return fmt.Errorf("invalid clock check interval: %s (must be positive)", cfg.Clock.CheckInterval)
+	}
+	if cfg.Clock.MaxSkew < 0 {
+		return fmt.Errorf("invalid clock max skew: %s", cfg.Clock.MaxSkew)
+	}
+
+	if cfg.Availability.MainsInterval < 0 || cfg.Availability.BatteryInterval < 0 {
+		return fmt.Errorf("invalid availability interval: mains %s, battery %s",
+			cfg.Availability.MainsInterval, cfg.Availability.BatteryInterval)
+	}
+
+	if cfg.Energy.PollInterval < 0 || (cfg.Energy.PollInterval > 0 && cfg.Energy.PollInterval < time.Second) {
+		return fmt.Errorf("invalid energy poll interval: %s (0 or at least 1s)", cfg.Energy.PollInterval)
+	}
+
+	if cfg.OTA.CheckInterval < 0 || (cfg.OTA.CheckInterval > 0 && cfg.OTA.CheckInterval < time.Minute) {
+		return fmt.Errorf("invalid OTA check interval: %s (0 or at least 1m)", cfg.OTA.CheckInterval)
+	}
+
+	if cfg.Interview.Interval < 0 || cfg.Interview.Jitter < 0 || cfg.Interview.Concurrency < 0 {
+		return fmt.Errorf("invalid interview settings: interval %s, jitter %s, concurrency %d",
+			cfg.Interview.Interval, cfg.Interview.Jitter, cfg.Interview.Concurrency)
+	}
+
+	if c := cfg.Commissioning; c.MaxConcurrent < 0 || c.MaxQueued < 0 || c.AttemptTimeout < 0 || c.MaxAttempts < 0 || c.RetryDelay < 0 {
+		return fmt.Errorf("invalid commissioning queue: max concurrent %d, max queued %d, attempt timeout %s, max attempts %d, retry delay %s",
+			c.MaxConcurrent, c.MaxQueued, c.AttemptTimeout, c.MaxAttempts, c.RetryDelay)
+	}
+
+	if s := cfg.Subscriptions; s.MinInterval < 0 || s.MaxInterval < 0 || s.BatteryMaxInterval < 0 ||
+		(s.MaxInterval > 0 && s.MaxInterval < s.MinInterval) || (s.BatteryMaxInterval > 0 && s.BatteryMaxInterval < s.MinInterval) {
+		return fmt.Errorf("invalid subscription intervals: min %s, max %s, battery max %s",
+			s.MinInterval, s.MaxInterval, s.BatteryMaxInterval)
+	}
+
 	return nil
 }
 
@@ -3,8 +3,10 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -17,14 +19,89 @@ func TestSetDefaults(t *testing.T) {
 		expected interface{}
 	}{
 		{"Server Port", "server.port", 5580},
+		{"Serve Static", "server.serve_static", false},
+		{"Static Dir", "server.static_dir", ""},
+		{"Debug Port", "server.debug_port", 0},
+		{"gRPC Port", "server.grpc_port", 0},
+		{"WebSocket Queue Size", "server.websocket_queue_size", 256},
+		{"Event Queue Size", "server.event_queue_size", 1024},
+		{"WebSocket Overflow Policy", "server.websocket_overflow_policy", "drop_oldest"},
+		{"WebSocket Dialect", "server.websocket_dialect", "native"},
+		{"WebSocket Commands Per Second", "server.websocket_commands_per_second", 50.0},
+		{"WebSocket Max In Flight", "server.websocket_max_in_flight", 32},
+		{"WebSocket Compression", "server.websocket_compression", false},
+		{"WebSocket Compression Level", "server.websocket_compression_level", 1},
+		{"WebSocket Compression Threshold", "server.websocket_compression_threshold", 512},
+		{"WebSocket Server Context Takeover", "server.websocket_server_context_takeover", false},
+		{"WebSocket Client Context Takeover", "server.websocket_client_context_takeover", false},
+		{"Drain Timeout", "server.drain_timeout", 10 * time.Second},
+		{"Command Timeout", "server.command_timeout", time.Minute},
+		{"Shutdown Timeout", "server.shutdown_timeout", 8 * time.Second},
+		{"Fault Injection", "server.fault_injection", false},
+		{"Admin Token", "server.admin_token", ""},
+		{"Storage Flush Interval", "storage.flush_interval", time.Duration(0)},
+		{"Storage Event History Size", "storage.event_history_size", 1000},
+		{"Storage Attribute History Retention", "storage.attribute_history_retention", 7 * 24 * time.Hour},
+		{"Storage Attribute History Interval", "storage.attribute_history_interval", time.Minute},
+		{"Storage Encryption Key", "storage.encryption_key", ""},
+		{"Storage Encryption Key Command", "storage.encryption_key_command", ""},
+		{"Storage Corruption Policy", "storage.corruption_policy", "recover"},
+		{"Storage Audit Log", "storage.audit_log", true},
 		{"Vendor ID", "matter.vendor_id", 0xFFF1},
 		{"Fabric ID", "matter.fabric_id", 1},
+		{"Controller Node ID", "matter.controller_node_id", 112233},
+		{"Cluster Descriptor Dir", "matter.cluster_descriptor_dir", ""},
 		{"Enable Test Net DCL", "matter.enable_test_net_dcl", false},
 		{"Disable Server Interactions", "matter.disable_server_interactions", false},
+		{"OTA Check Interval", "ota.check_interval", 24 * time.Hour},
 		{"Bluetooth Adapter ID", "bluetooth.adapter_id", -1},
 		{"Bluetooth Enabled", "bluetooth.enabled", false},
+		{"Bluetooth Backend", "bluetooth.backend", "bluez"},
+		{"Bluetooth Mock Script", "bluetooth.mock_script", ""},
+		{"mDNS Address Family", "mdns.address_family", "both"},
+		{"SRP Server", "srp.server", ""},
+		{"SRP Lease", "srp.lease", 2 * time.Hour},
+		{"SRP Key Lease", "srp.key_lease", 14 * 24 * time.Hour},
+		{"MQTT Broker", "mqtt.broker", ""},
+		{"MQTT Client ID", "mqtt.client_id", "matter-server"},
+		{"MQTT Keep Alive", "mqtt.keep_alive", time.Minute},
+		{"MQTT Topic Prefix", "mqtt.topic_prefix", "matter"},
+		{"MQTT Discovery", "mqtt.discovery", true},
+		{"MQTT Discovery Prefix", "mqtt.discovery_prefix", "homeassistant"},
+		{"Telemetry URL", "telemetry.url", ""},
+		{"Telemetry Batch Size", "telemetry.batch_size", 500},
+		{"Telemetry Flush Interval", "telemetry.flush_interval", 10 * time.Second},
+		{"Telemetry Buffer Size", "telemetry.buffer_size", 10000},
+		{"Telemetry Timeout", "telemetry.timeout", 10 * time.Second},
+		{"Replication Role", "replication.role", ""},
+		{"Replication Heartbeat Interval", "replication.heartbeat_interval", 5 * time.Second},
+		{"Replication Promote After", "replication.promote_after", time.Minute},
+		{"Replication Allow Plaintext", "replication.allow_plaintext", false},
 		{"Log Level", "log.level", "info"},
 		{"Log Format", "log.format", "console"},
+		{"Log Output", "log.output", ""},
+		{"Log File", "log.file", ""},
+		{"Log Max Size", "log.max_size_mb", 100},
+		{"Log Max Backups", "log.max_backups", 5},
+		{"Log Max Age", "log.max_age_days", 0},
+		{"Log Compress", "log.compress", true},
+		{"Log History Size", "log.history_size", 1000},
+		{"NTP Server", "clock.ntp_server", ""},
+		{"Mains Availability Interval", "availability.mains_interval", time.Minute},
+		{"Battery Availability Interval", "availability.battery_interval", 30 * time.Minute},
+		{"Energy Poll Interval", "energy.poll_interval", time.Duration(0)},
+		{"Energy Record History", "energy.record_history", false},
+		{"Interview Interval", "interview.interval", time.Duration(0)},
+		{"Interview Concurrency", "interview.concurrency", 2},
+		{"Interview Jitter", "interview.jitter", 5 * time.Minute},
+		{"Commissioning Max Concurrent", "commissioning.max_concurrent", 1},
+		{"Commissioning Max Queued", "commissioning.max_queued", 16},
+		{"Commissioning Attempt Timeout", "commissioning.attempt_timeout", 5 * time.Minute},
+		{"Commissioning Max Attempts", "commissioning.max_attempts", 2},
+		{"Commissioning Retry Delay", "commissioning.retry_delay", 10 * time.Second},
+		{"Subscriptions Min Interval", "subscriptions.min_interval", time.Duration(0)},
+		{"Subscriptions Max Interval", "subscriptions.max_interval", time.Minute},
+		{"Subscriptions Battery Max Interval", "subscriptions.battery_max_interval", 10 * time.Minute},
 	}
 
 	// Create a viper instance and set defaults
@@ -57,70 +134,989 @@ func TestValidateConfig(t *testing.T) {
 					VendorID: 0xFFF1,
 					FabricID: 1,
 				},
+				Clock: ClockConfig{
+					CheckInterval: time.Hour,
+				},
+			},
+			expectErr: false,
+		},
+		{
+			name: "Invalid port - negative",
+			config: &Config{
+				Server: ServerConfig{
+					Port: -1,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid port - too high",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 70000,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Debug port same as API port",
+			config: &Config{
+				Server: ServerConfig{
+					Port:      5580,
+					DebugPort: 5580,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "gRPC port same as debug port",
+			config: &Config{
+				Server: ServerConfig{
+					Port:      5580,
+					DebugPort: 5590,
+					GRPCPort:  5590,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid vendor ID - negative",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Matter: MatterConfig{
+					VendorID: -1,
+					FabricID: 1,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid vendor ID - too high",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Matter: MatterConfig{
+					VendorID: 0x10000,
+					FabricID: 1,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid fabric ID - zero",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 0,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Valid additional fabrics",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+					Fabrics:  []FabricConfig{{FabricID: 2}, {FabricID: 3, VendorID: 0x1234, Label: "Lab"}},
+				},
+				Clock: ClockConfig{
+					CheckInterval: time.Hour,
+				},
+			},
+			expectErr: false,
+		},
+		{
+			name: "Invalid additional fabric - duplicate of the default fabric",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+					Fabrics:  []FabricConfig{{FabricID: 1}},
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid additional fabric - zero fabric ID",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+					Fabrics:  []FabricConfig{{FabricID: 0}},
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid additional fabric - vendor ID too high",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+					Fabrics:  []FabricConfig{{FabricID: 2, VendorID: 0x10000}},
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid controller node ID - group ID range",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Matter: MatterConfig{
+					VendorID:         0xFFF1,
+					FabricID:         1,
+					ControllerNodeID: 0xFFFFFFFFFFFF0001,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid availability interval - negative",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+				Availability: AvailabilityConfig{
+					BatteryInterval: -time.Minute,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid energy poll interval - too short",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+				Energy: EnergyConfig{
+					PollInterval: 100 * time.Millisecond,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Zero clock check interval",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Negative clock max skew",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+				Clock: ClockConfig{
+					CheckInterval: time.Hour,
+					MaxSkew:       -time.Second,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "OTA check interval too short",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+				OTA: OTAConfig{
+					CheckInterval: time.Second,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid interview concurrency",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+				Interview: InterviewConfig{
+					Concurrency: -1,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid commissioning retry delay",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+				Commissioning: CommissioningConfig{
+					RetryDelay: -time.Second,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Subscription max interval below min interval",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+				Subscriptions: SubscriptionsConfig{
+					MinInterval: time.Minute,
+					MaxInterval: 30 * time.Second,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid WebSocket overflow policy",
+			config: &Config{
+				Server: ServerConfig{
+					Port:                    5580,
+					WebSocketOverflowPolicy: "block",
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid event queue size",
+			config: &Config{
+				Server: ServerConfig{
+					Port:           5580,
+					EventQueueSize: -1,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid WebSocket dialect",
+			config: &Config{
+				Server: ServerConfig{
+					Port:             5580,
+					WebSocketDialect: "python",
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid admin token - too short",
+			config: &Config{
+				Server: ServerConfig{
+					Port:       5580,
+					AdminToken: "secret",
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid WebSocket compression level",
+			config: &Config{
+				Server: ServerConfig{
+					Port:                      5580,
+					WebSocketCompressionLevel: 10,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Valid WebSocket context takeover",
+			config: &Config{
+				Server: ServerConfig{
+					Port:                           5580,
+					WebSocketCompression:           true,
+					WebSocketServerContextTakeover: true,
+					WebSocketClientContextTakeover: true,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+				Clock: ClockConfig{
+					CheckInterval: time.Hour,
+				},
+			},
+			expectErr: false,
+		},
+		{
+			name: "WebSocket context takeover without compression",
+			config: &Config{
+				Server: ServerConfig{
+					Port:                           5580,
+					WebSocketServerContextTakeover: true,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid WebSocket command limit",
+			config: &Config{
+				Server: ServerConfig{
+					Port:                 5580,
+					WebSocketMaxInFlight: -1,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid shutdown timeout - negative",
+			config: &Config{
+				Server: ServerConfig{
+					Port:            5580,
+					ShutdownTimeout: -time.Second,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid command timeout - negative",
+			config: &Config{
+				Server: ServerConfig{
+					Port:            5580,
+					CommandTimeouts: map[string]time.Duration{"read_attribute": -time.Second},
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid drain timeout - negative",
+			config: &Config{
+				Server: ServerConfig{
+					Port:         5580,
+					DrainTimeout: -time.Second,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid storage flush interval - negative",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Storage: StorageConfig{
+					FlushInterval: -time.Second,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid event history size - negative",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Storage: StorageConfig{
+					EventHistorySize: -1,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid attribute history path",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Storage: StorageConfig{
+					AttributeHistoryPaths: []string{"1/1026"},
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid storage corruption policy",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Storage: StorageConfig{
+					CorruptionPolicy: "ignore",
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid mDNS address family",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+				MDNS: MDNSConfig{
+					AddressFamily: "ipv5",
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid log rotation - negative backups",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+				Log: LogConfig{
+					MaxSizeMB:  100,
+					MaxBackups: -1,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid log output",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+				Log: LogConfig{
+					Output: "eventlog",
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid log output - file without file",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+				Log: LogConfig{
+					Output: "file",
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid log history size - negative",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+				Log: LogConfig{
+					HistorySize: -1,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid Bluetooth adapter",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+				Bluetooth: BluetoothConfig{
+					Adapters: []string{"usb0"},
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid Bluetooth adapters - auto and adapter",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+				Bluetooth: BluetoothConfig{
+					Adapters: []string{"auto", "hci0"},
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Valid Bluetooth mock backend",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+				Bluetooth: BluetoothConfig{
+					Backend:    "mock",
+					MockScript: "bluetooth.json",
+				},
+				Clock: ClockConfig{
+					CheckInterval: time.Hour,
+				},
 			},
 			expectErr: false,
 		},
 		{
-			name: "Invalid port - negative",
+			name: "Invalid Bluetooth backend",
 			config: &Config{
 				Server: ServerConfig{
-					Port: -1,
+					Port: 5580,
 				},
 				Matter: MatterConfig{
 					VendorID: 0xFFF1,
 					FabricID: 1,
 				},
+				Bluetooth: BluetoothConfig{
+					Backend: "bluetoothctl",
+				},
 			},
 			expectErr: true,
 		},
 		{
-			name: "Invalid port - too high",
+			name: "Invalid Bluetooth mock backend without script",
 			config: &Config{
 				Server: ServerConfig{
-					Port: 70000,
+					Port: 5580,
 				},
 				Matter: MatterConfig{
 					VendorID: 0xFFF1,
 					FabricID: 1,
 				},
+				Bluetooth: BluetoothConfig{
+					Backend: "mock",
+				},
 			},
 			expectErr: true,
 		},
 		{
-			name: "Invalid vendor ID - negative",
+			name: "Invalid SRP server address",
 			config: &Config{
 				Server: ServerConfig{
 					Port: 5580,
 				},
 				Matter: MatterConfig{
-					VendorID: -1,
+					VendorID: 0xFFF1,
 					FabricID: 1,
 				},
+				SRP: SRPConfig{
+					Server:   "fd00::1",
+					Lease:    2 * time.Hour,
+					KeyLease: 14 * 24 * time.Hour,
+				},
 			},
 			expectErr: true,
 		},
 		{
-			name: "Invalid vendor ID - too high",
+			name: "Invalid SRP key lease",
 			config: &Config{
 				Server: ServerConfig{
 					Port: 5580,
 				},
 				Matter: MatterConfig{
-					VendorID: 0x10000,
+					VendorID: 0xFFF1,
 					FabricID: 1,
 				},
+				SRP: SRPConfig{
+					Server:   "[fd00::1]:53",
+					Lease:    2 * time.Hour,
+					KeyLease: time.Hour,
+				},
 			},
 			expectErr: true,
 		},
 		{
-			name: "Invalid fabric ID - zero",
+			name: "Invalid MQTT broker scheme",
 			config: &Config{
 				Server: ServerConfig{
 					Port: 5580,
 				},
 				Matter: MatterConfig{
 					VendorID: 0xFFF1,
-					FabricID: 0,
+					FabricID: 1,
+				},
+				MQTT: MQTTConfig{
+					Broker:          "http://localhost:1883",
+					ClientID:        "matter-server",
+					KeepAlive:       time.Minute,
+					TopicPrefix:     "matter",
+					DiscoveryPrefix: "homeassistant",
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid MQTT topic prefix",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+				MQTT: MQTTConfig{
+					Broker:          "tcp://localhost:1883",
+					ClientID:        "matter-server",
+					KeepAlive:       time.Minute,
+					TopicPrefix:     "matter/#",
+					DiscoveryPrefix: "homeassistant",
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Valid MQTT bridge",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+				MQTT: MQTTConfig{
+					Broker:          "mqtts://broker.local",
+					ClientID:        "matter-server",
+					KeepAlive:       time.Minute,
+					TopicPrefix:     "home/matter",
+					DiscoveryPrefix: "homeassistant",
+				},
+				Clock: ClockConfig{
+					CheckInterval: time.Hour,
+				},
+			},
+			expectErr: false,
+		},
+		{
+			name: "Invalid telemetry buffer smaller than a batch",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+				Telemetry: TelemetryConfig{
+					URL:           "http://influxdb:8086/write?db=matter",
+					BatchSize:     500,
+					BufferSize:    100,
+					FlushInterval: 10 * time.Second,
+					Timeout:       10 * time.Second,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid telemetry metric",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+				Telemetry: TelemetryConfig{
+					URL:           "http://influxdb:8086/write?db=matter",
+					Metrics:       []TelemetryMetric{{Attribute: "1026/0", Measurement: "temperature"}},
+					BatchSize:     500,
+					BufferSize:    10000,
+					FlushInterval: 10 * time.Second,
+					Timeout:       10 * time.Second,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Valid replication standby",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+				Replication: ReplicationConfig{
+					Role:              "standby",
+					PrimaryURL:        "https://hub1:5580",
+					Token:             "0123456789abcdef",
+					HeartbeatInterval: 5 * time.Second,
+					PromoteAfter:      time.Minute,
+				},
+				Clock: ClockConfig{
+					CheckInterval: time.Hour,
+				},
+			},
+			expectErr: false,
+		},
+		{
+			name: "Invalid replication - short token",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+				Replication: ReplicationConfig{
+					Role:              "primary",
+					Token:             "secret",
+					HeartbeatInterval: 5 * time.Second,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid replication - standby over plain http",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+				Replication: ReplicationConfig{
+					Role:              "standby",
+					PrimaryURL:        "http://hub1:5580",
+					Token:             "0123456789abcdef",
+					HeartbeatInterval: 5 * time.Second,
+					PromoteAfter:      time.Minute,
+				},
+				Clock: ClockConfig{
+					CheckInterval: time.Hour,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Valid replication standby over allowed plain http",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+				Replication: ReplicationConfig{
+					Role:              "standby",
+					PrimaryURL:        "http://hub1:5580",
+					Token:             "0123456789abcdef",
+					HeartbeatInterval: 5 * time.Second,
+					PromoteAfter:      time.Minute,
+					AllowPlaintext:    true,
+				},
+				Clock: ClockConfig{
+					CheckInterval: time.Hour,
+				},
+			},
+			expectErr: false,
+		},
+		{
+			name: "Invalid replication - standby without primary",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+				Replication: ReplicationConfig{
+					Role:              "standby",
+					Token:             "0123456789abcdef",
+					HeartbeatInterval: 5 * time.Second,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid replication - promoted before a heartbeat",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+				Replication: ReplicationConfig{
+					Role:              "standby",
+					PrimaryURL:        "https://hub1:5580",
+					Token:             "0123456789abcdef",
+					HeartbeatInterval: 5 * time.Second,
+					PromoteAfter:      10 * time.Second,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid storage encryption - key and command",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Storage: StorageConfig{
+					EncryptionKey:        "secret",
+					EncryptionKeyCommand: "secret-tool lookup service matter-server",
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid storage encryption - blank command",
+			config: &Config{
+				Server: ServerConfig{
+					Port: 5580,
+				},
+				Storage: StorageConfig{
+					EncryptionKeyCommand: "   ",
+				},
+				Matter: MatterConfig{
+					VendorID: 0xFFF1,
+					FabricID: 1,
 				},
 			},
 			expectErr: true,
@@ -163,6 +1159,11 @@ server:
   port: 8080
   listen_addresses: ["127.0.0.1", "::1"]
   serve_static: true
+  static_dir: "/test/dashboard"
+  allowed_origins: ["https://dashboard.local"]
+  command_timeouts:
+    read_attribute: 45s
+    update_node: 0
 
 storage:
   path: "/test/storage"
@@ -170,6 +1171,9 @@ storage:
 matter:
   vendor_id: 0x1234
   fabric_id: 5
+  fabrics:
+    - fabric_id: 6
+      label: Guests
   enable_test_net_dcl: true
   disable_server_interactions: true
 
@@ -183,6 +1187,17 @@ bluetooth:
 ota:
   provider_dir: "/test/ota"
 
+telemetry:
+  url: "http://influxdb:8086/api/v2/write?org=home&bucket=matter"
+  metrics:
+    - attribute: "*/1026/0"
+      measurement: temperature
+      scale: 0.01
+      tags:
+        unit: celsius
+  tags:
+    site: home
+
 log:
   level: "debug"
   format: "json"
@@ -208,9 +1223,21 @@ log:
 	if cfg.Server.Port != 8080 {
 		t.Errorf("Expected port 8080, got %d", cfg.Server.Port)
 	}
+	if cfg.File() != configFile {
+		t.Errorf("Expected config file %s, got %s", configFile, cfg.File())
+	}
 	if len(cfg.Server.ListenAddresses) != 2 {
 		t.Errorf("Expected 2 listen addresses, got %d", len(cfg.Server.ListenAddresses))
 	}
+	if !cfg.Server.ServeStatic || cfg.Server.StaticDir != "/test/dashboard" {
+		t.Errorf("Expected static serving from '/test/dashboard', got %v %q", cfg.Server.ServeStatic, cfg.Server.StaticDir)
+	}
+	if len(cfg.Server.AllowedOrigins) != 1 || cfg.Server.AllowedOrigins[0] != "https://dashboard.local" {
+		t.Errorf("Expected allowed origin 'https://dashboard.local', got %v", cfg.Server.AllowedOrigins)
+	}
+	if timeouts := cfg.Server.CommandTimeouts; len(timeouts) != 2 || timeouts["read_attribute"] != 45*time.Second || timeouts["update_node"] != 0 {
+		t.Errorf("Expected the read_attribute and update_node timeouts, got %v", timeouts)
+	}
 	if cfg.Storage.Path != "/test/storage" {
 		t.Errorf("Expected storage path '/test/storage', got %s", cfg.Storage.Path)
 	}
@@ -220,6 +1247,10 @@ log:
 	if cfg.Matter.FabricID != 5 {
 		t.Errorf("Expected fabric ID 5, got %d", cfg.Matter.FabricID)
 	}
+	fabrics := cfg.Matter.OperatedFabrics()
+	if len(fabrics) != 2 || fabrics[0].FabricID != 5 || fabrics[1].FabricID != 6 || fabrics[1].VendorID != 0x1234 || fabrics[1].Label != "Guests" {
+		t.Errorf("Expected the default fabric and fabric 6, got %+v", fabrics)
+	}
 	if !cfg.Matter.EnableTestNetDCL {
 		t.Error("Expected EnableTestNetDCL to be true")
 	}
@@ -241,6 +1272,13 @@ log:
 	if cfg.Log.Level != "debug" {
 		t.Errorf("Expected log level 'debug', got %s", cfg.Log.Level)
 	}
+	metrics := cfg.Telemetry.Metrics
+	if len(metrics) != 1 || metrics[0].Attribute != "*/1026/0" || metrics[0].Scale != 0.01 || metrics[0].Tags["unit"] != "celsius" {
+		t.Errorf("Expected the temperature metric, got %+v", metrics)
+	}
+	if cfg.Telemetry.Tags["site"] != "home" || cfg.Telemetry.BatchSize != 500 {
+		t.Errorf("Expected the telemetry tags and default batch size, got %+v", cfg.Telemetry)
+	}
 	if cfg.Log.Format != "json" {
 		t.Errorf("Expected log format 'json', got %s", cfg.Log.Format)
 	}
@@ -275,6 +1313,63 @@ func TestLoadConfigWithoutFile(t *testing.T) {
 	}
 }
 
+func TestDefaultsRoundTrip(t *testing.T) {
+	defaults, err := Defaults()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if defaults.Server.Port != 5580 || defaults.Server.ShutdownTimeout != 8*time.Second {
+		t.Errorf("Expected the default port and shutdown timeout, got %+v", defaults.Server)
+	}
+
+	// The printed defaults are a valid config file with the same values
+	data, err := defaults.RedactedYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := &cobra.Command{}
+	setupTestFlags(cmd)
+	cmd.Flags().Set("config", configFile)
+	loaded, err := Load(cmd)
+	if err != nil {
+		t.Fatalf("Failed to load the printed defaults: %v", err)
+	}
+	loaded.Storage.Path = ""
+	reloaded, err := loaded.RedactedYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(reloaded) != string(data) {
+		t.Errorf("Expected the defaults after loading them, got\n%s\nexpected\n%s", reloaded, data)
+	}
+}
+
+func TestLoadConfigWithFlagSubset(t *testing.T) {
+	// Subcommands declare only some of the flags
+	cmd := &cobra.Command{}
+	cmd.Flags().String("config", "", "config file")
+	cmd.Flags().String("env-file", "", "env file")
+	cmd.Flags().String("storage-path", "", "Storage path")
+	cmd.Flags().Int("fabric-id", 1, "Fabric ID")
+	cmd.Flags().Set("storage-path", "/test/storage")
+	cmd.Flags().Set("fabric-id", "3")
+
+	cfg, err := Load(cmd)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.Storage.Path != "/test/storage" || cfg.Matter.FabricID != 3 {
+		t.Errorf("Expected flag values, got %q and %d", cfg.Storage.Path, cfg.Matter.FabricID)
+	}
+	if cfg.Server.Port != 5580 {
+		t.Errorf("Expected default port 5580, got %d", cfg.Server.Port)
+	}
+}
+
 func TestLoadConfigWithCommandLineFlags(t *testing.T) {
 	// Create test command with flags set
 	cmd := &cobra.Command{}
@@ -335,9 +1430,10 @@ func TestDefaultStoragePath(t *testing.T) {
 func TestEnvironmentVariables(t *testing.T) {
 	// Set environment variables
 	envVars := map[string]string{
-		"MATTER_SERVER_PORT":      "7777",
-		"MATTER_MATTER_VENDOR_ID": "1111",
-		"MATTER_LOG_LEVEL":        "error",
+		"MATTER_SERVER_PORT":        "7777",
+		"MATTER_MATTER_VENDOR_ID":   "1111",
+		"MATTER_LOG_LEVEL":          "error",
+		"MATTER_BLUETOOTH_ADAPTERS": "hci1,hci0",
 	}
 
 	for key, value := range envVars {
@@ -365,6 +1461,28 @@ func TestEnvironmentVariables(t *testing.T) {
 	if cfg.Log.Level != "error" {
 		t.Errorf("Expected log level 'error' from env var, got %s", cfg.Log.Level)
 	}
+	if got := cfg.Bluetooth.AdapterNames(); len(got) != 2 || got[0] != "hci1" || got[1] != "hci0" {
+		t.Errorf("Expected Bluetooth adapters hci1,hci0 from env var, got %v", got)
+	}
+}
+
+func TestBluetoothAdapterNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   BluetoothConfig
+		expected []string
+	}{
+		{"Disabled", BluetoothConfig{AdapterID: -1}, nil},
+		{"Adapter ID", BluetoothConfig{AdapterID: 1}, []string{"hci1"}},
+		{"Adapters", BluetoothConfig{AdapterID: 1, Adapters: []string{"auto"}}, []string{"auto"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.AdapterNames(); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
 }
 
 func TestConfigPrecedence(t *testing.T) {
@@ -434,6 +1552,12 @@ func setupTestFlags(cmd *cobra.Command) {
 	cmd.Flags().String("log-format", "console", "log format")
 	cmd.Flags().IntP("port", "p", 5580, "WebSocket server port")
 	cmd.Flags().StringSlice("listen", []string{}, "Listen addresses")
+	cmd.Flags().String("ready-file", "", "Path of the JSON ready file")
+	cmd.Flags().StringSlice("allowed-origins", []string{}, "Allowed origins")
+	cmd.Flags().Bool("serve-static", false, "Serve the web dashboard")
+	cmd.Flags().String("static-dir", "", "Dashboard override directory")
+	cmd.Flags().Int("debug-port", 0, "Debug port")
+	cmd.Flags().Int("grpc-port", 0, "gRPC port")
 	cmd.Flags().String("storage-path", "", "Storage path for persistent data")
 	cmd.Flags().Int("vendor-id", 0xFFF1, "Vendor ID for the Fabric")
 	cmd.Flags().Int("fabric-id", 1, "Fabric ID for the Fabric")
@@ -443,6 +1567,8 @@ func setupTestFlags(cmd *cobra.Command) {
 	cmd.Flags().Int("bluetooth-adapter", -1, "Bluetooth adapter ID")
 	cmd.Flags().String("ota-provider-dir", "", "Directory for OTA Provider software updates")
 	cmd.Flags().Bool("disable-server-interactions", false, "Disable server cluster interactions")
+	cmd.Flags().Bool("allow-untrusted-devices", false, "Accept devices failing attestation")
 	cmd.Flags().Bool("mdns-enabled", true, "Enable mDNS hostname advertisement")
 	cmd.Flags().String("mdns-hostname", "", "Hostname to advertise via mDNS")
+	cmd.Flags().String("ntp-server", "", "NTP server to compare the system clock against")
 }
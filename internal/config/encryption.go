@@ -0,0 +1,60 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// keyCommandTimeout bounds how long the encryption key command may run, e.g.
+// while a keyring is unlocked
+const keyCommandTimeout = 30 * time.Second
+
+// EncryptionKeys returns the current storage encryption passphrase, running
+// the key command if one is configured, and the previous passphrases. An
+// empty current passphrase disables encryption.
+func (c StorageConfig) EncryptionKeys() (current string, previous []string, err error) {
+	current = c.EncryptionKey
+	if c.EncryptionKeyCommand != "" {
+		if current, err = runKeyCommand(c.EncryptionKeyCommand); err != nil {
+			return "", nil, err
+		}
+	}
+
+	for _, key := range c.PreviousEncryptionKeys {
+		if key != "" && key != current {
+			previous = append(previous, key)
+		}
+	}
+	return current, previous, nil
+}
+
+// runKeyCommand runs command without a shell and returns the first line of
+// its output
+func runKeyCommand(command string) (string, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return "", fmt.Errorf("storage encryption key command is blank")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), keyCommandTimeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("storage encryption key command failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	key, _, _ := strings.Cut(string(out), "\n")
+	key = strings.TrimSuffix(key, "\r")
+	if key == "" {
+		return "", fmt.Errorf("storage encryption key command printed no key")
+	}
+	return key, nil
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEncryptionKeys(t *testing.T) {
+	tests := []struct {
+		name         string
+		storage      StorageConfig
+		wantCurrent  string
+		wantPrevious []string
+		expectErr    bool
+	}{
+		{"Disabled", StorageConfig{}, "", nil, false},
+		{"Passphrase", StorageConfig{EncryptionKey: "secret"}, "secret", nil, false},
+		{
+			"Rotation",
+			StorageConfig{EncryptionKey: "new", PreviousEncryptionKeys: []string{"old", "", "new"}},
+			"new", []string{"old"}, false,
+		},
+		{"Command", StorageConfig{EncryptionKeyCommand: "echo from keyring"}, "from keyring", nil, false},
+		{"Failing command", StorageConfig{EncryptionKeyCommand: "false"}, "", nil, true},
+		{"Empty command output", StorageConfig{EncryptionKeyCommand: "true"}, "", nil, true},
+		{"Blank command", StorageConfig{EncryptionKeyCommand: " \t "}, "", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current, previous, err := tt.storage.EncryptionKeys()
+			if tt.expectErr {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if current != tt.wantCurrent || !reflect.DeepEqual(previous, tt.wantPrevious) {
+				t.Errorf("Expected %q and %v, got %q and %v", tt.wantCurrent, tt.wantPrevious, current, previous)
+			}
+		})
+	}
+}
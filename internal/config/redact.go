@@ -0,0 +1,77 @@
+package config
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RedactedValue replaces secret settings in Redacted
+const RedactedValue = "[REDACTED]"
+
+// secretKeyParts mark settings whose values must not leave the server
+var secretKeyParts = []string{"password", "secret", "token", "key", "credential"}
+
+// Redacted returns the configuration as a map keyed like the config file,
+// with the values of secret settings replaced by RedactedValue
+func (c *Config) Redacted() map[string]interface{} {
+	return redactStruct(reflect.ValueOf(*c))
+}
+
+// RedactedYAML returns Redacted in the format of the config file
+func (c *Config) RedactedYAML() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c.Redacted()); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func redactStruct(v reflect.Value) map[string]interface{} {
+	result := make(map[string]interface{}, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		name := field.Tag.Get("mapstructure")
+		if name == "" || !field.IsExported() {
+			continue
+		}
+
+		value := v.Field(i)
+		switch {
+		case isSecretKey(name) && isSet(value):
+			result[name] = RedactedValue
+		case value.Kind() == reflect.Struct:
+			result[name] = redactStruct(value)
+		default:
+			result[name] = value.Interface()
+		}
+	}
+	return result
+}
+
+// isSet reports whether a string setting holds a value. Secrets are strings,
+// so settings like srp.key_lease aren't hidden, and unset secrets are shown
+// as such.
+func isSet(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.String, reflect.Slice:
+		return value.Len() > 0
+	}
+	return false
+}
+
+func isSecretKey(name string) bool {
+	for _, part := range secretKeyParts {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
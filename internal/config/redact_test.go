@@ -0,0 +1,64 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedacted(t *testing.T) {
+	cfg := &Config{
+		Server:  ServerConfig{Port: 5580, AllowedOrigins: []string{"http://localhost"}},
+		Storage: StorageConfig{Path: "/data"},
+	}
+
+	redacted := cfg.Redacted()
+	server, ok := redacted["server"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected server section, got %v", redacted["server"])
+	}
+	if server["port"] != 5580 {
+		t.Errorf("Expected port 5580, got %v", server["port"])
+	}
+	if !reflect.DeepEqual(server["allowed_origins"], []string{"http://localhost"}) {
+		t.Errorf("Expected allowed origins, got %v", server["allowed_origins"])
+	}
+	if storage := redacted["storage"].(map[string]interface{}); storage["path"] != "/data" {
+		t.Errorf("Expected storage path, got %v", storage["path"])
+	}
+
+	type secrets struct {
+		Name     string `mapstructure:"name"`
+		Password string `mapstructure:"password"`
+		APIKey   string `mapstructure:"api_key"`
+	}
+	values := redactStruct(reflect.ValueOf(secrets{Name: "hub", Password: "hunter2"}))
+	want := map[string]interface{}{"name": "hub", "password": RedactedValue, "api_key": ""}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("Expected %v, got %v", want, values)
+	}
+
+	if srp := redacted["srp"].(map[string]interface{}); srp["key_lease"] != time.Duration(0) {
+		t.Errorf("Expected SRP key lease not to be redacted, got %v", srp["key_lease"])
+	}
+}
+
+func TestRedactedYAML(t *testing.T) {
+	cfg := &Config{
+		Storage: StorageConfig{Path: "/data", EncryptionKey: "hunter2"},
+		SRP:     SRPConfig{KeyLease: 14 * 24 * time.Hour},
+	}
+	data, err := cfg.RedactedYAML()
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	for _, want := range []string{"path: /data", "encryption_key: '" + RedactedValue + "'", "key_lease: 336h0m0s"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Expected %q in\n%s", want, data)
+		}
+	}
+	if strings.Contains(string(data), "hunter2") {
+		t.Errorf("Secret leaked in\n%s", data)
+	}
+}
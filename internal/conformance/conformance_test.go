@@ -0,0 +1,412 @@
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/codefionn/go-matter-server/internal/config"
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/server"
+	"github.com/codefionn/go-matter-server/internal/storage"
+	mattersocket "github.com/codefionn/go-matter-server/internal/websocket"
+)
+
+// exchange is a conversation of a client with python-matter-server. Each
+// step sends a client message, emits a server event or expects the next
+// message from the server.
+type exchange struct {
+	Description string `json:"description"`
+	Steps       []step `json:"steps"`
+}
+
+type step struct {
+	Client json.RawMessage `json:"client"`
+	Event  *struct {
+		Event models.EventType `json:"event"`
+		Data  json.RawMessage  `json:"data"`
+	} `json:"event"`
+
+	// Message python-matter-server sends
+	Server map[string]interface{} `json:"server"`
+	// Fields the native dialect sends differently, replacing those of
+	// Server
+	Native map[string]interface{} `json:"native"`
+	// Compare only the fields of Server, e.g. of the server info repeated
+	// at the start of every exchange
+	Partial bool `json:"partial"`
+}
+
+// Placeholders in expected messages match any value of their type, for
+// values differing between servers and runs
+const (
+	anyValue     = "$any"
+	anyString    = "$string"
+	anyNumber    = "$number"
+	anyBool      = "$bool"
+	anyTimestamp = "$timestamp"
+)
+
+// dialects are the WebSocket dialects every exchange runs in
+var dialects = []mattersocket.Dialect{mattersocket.DialectNative, mattersocket.DialectHomeAssistant}
+
+func TestPythonMatterServer(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "python-matter-server", "*.json"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("No exchanges found: %v", err)
+	}
+
+	for _, dialect := range dialects {
+		for _, file := range files {
+			name := strings.TrimSuffix(filepath.Base(file), ".json")
+			t.Run(string(dialect)+"/"+name, func(t *testing.T) {
+				content, err := os.ReadFile(file)
+				if err != nil {
+					t.Fatalf("Failed to read exchange: %v", err)
+				}
+				var ex exchange
+				if err := json.Unmarshal(content, &ex); err != nil {
+					t.Fatalf("Invalid exchange: %v", err)
+				}
+				replay(t, dialect, ex)
+			})
+		}
+	}
+}
+
+// replay runs an exchange against a new server
+func replay(t *testing.T, dialect mattersocket.Dialect, ex exchange) {
+	srv, url := startServer(t, dialect)
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	messages := &reader{conn: conn, split: dialect == mattersocket.DialectNative}
+
+	for i, step := range ex.Steps {
+		switch {
+		case step.Client != nil:
+			if err := conn.WriteMessage(websocket.TextMessage, step.Client); err != nil {
+				t.Fatalf("Step %d: failed to send: %v", i, err)
+			}
+		case step.Event != nil:
+			emit(t, srv, step.Event.Event, step.Event.Data)
+		default:
+			msg, err := messages.next()
+			if err != nil {
+				t.Fatalf("Step %d: expected %v, got %v", i, step.Server, err)
+			}
+			expected := step.Server
+			if dialect == mattersocket.DialectNative {
+				expected = merge(expected, step.Native)
+			}
+			strict := dialect == mattersocket.DialectHomeAssistant && !step.Partial
+			if diffs := compare("", msg, expected, strict); len(diffs) > 0 {
+				t.Errorf("Step %d: %s differs from python-matter-server:\n  %s\ngot %v", i, describe(expected), strings.Join(diffs, "\n  "), msg)
+			}
+		}
+	}
+
+	// Nothing else may arrive, e.g. events python-matter-server doesn't send
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, data, err := conn.ReadMessage(); len(messages.pending) > 0 || err == nil {
+		t.Errorf("Unexpected messages %v %s", messages.pending, data)
+	}
+}
+
+// startServer runs a server in the dialect on a free loopback port with the
+// nodes of testdata/nodes.json and returns the URL of its WebSocket API
+func startServer(t *testing.T, dialect mattersocket.Dialect) (*server.Server, string) {
+	t.Helper()
+
+	cfg, err := config.Defaults()
+	if err != nil {
+		t.Fatalf("Failed to get the default config: %v", err)
+	}
+	cfg.Storage.Path = t.TempDir()
+	cfg.Storage.AuditLog = false
+	cfg.Server.Port = freePort(t)
+	cfg.Server.WebSocketDialect = string(dialect)
+	cfg.MDNS.Enabled = false
+	cfg.Log.Level = "fatal"
+	storeNodes(t, cfg.Storage.Path)
+
+	srv, err := server.New(cfg, logger.NewConsoleLogger(logger.FatalLevel))
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	base := fmt.Sprintf("127.0.0.1:%d", cfg.Server.Port)
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		resp, err := http.Get("http://" + base + "/health/ready")
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return srv, "ws://" + base + "/ws"
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Server not ready: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// storeNodes writes the nodes of testdata/nodes.json to the storage
+func storeNodes(t *testing.T, path string) {
+	t.Helper()
+
+	content, err := os.ReadFile(filepath.Join("testdata", "nodes.json"))
+	if err != nil {
+		t.Fatalf("Failed to read nodes: %v", err)
+	}
+	var nodes []*models.MatterNodeData
+	if err := json.Unmarshal(content, &nodes); err != nil {
+		t.Fatalf("Invalid nodes: %v", err)
+	}
+
+	store := storage.NewJSONStorage(path, logger.NewConsoleLogger(logger.FatalLevel))
+	if err := store.Start(); err != nil {
+		t.Fatalf("Failed to start storage: %v", err)
+	}
+	if err := store.SaveNodes(nodes); err != nil {
+		t.Fatalf("Failed to store nodes: %v", err)
+	}
+	if err := store.Stop(); err != nil {
+		t.Fatalf("Failed to stop storage: %v", err)
+	}
+}
+
+func freePort(t *testing.T) int {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to find a free port: %v", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// emit emits an event of an exchange with the data type the server uses
+func emit(t *testing.T, srv *server.Server, event models.EventType, raw json.RawMessage) {
+	t.Helper()
+
+	var data interface{}
+	switch event {
+	case models.EventTypeNodeAdded, models.EventTypeNodeUpdated:
+		data = &models.MatterNodeData{}
+	case models.EventTypeNodeEvent:
+		data = &models.MatterNodeEvent{}
+	default:
+		data = new(interface{})
+	}
+	if err := json.Unmarshal(raw, data); err != nil {
+		t.Fatalf("Invalid %s data: %v", event, err)
+	}
+	if generic, ok := data.(*interface{}); ok {
+		data = *generic
+	}
+	srv.EmitEvent(event, data)
+}
+
+// reader reads the messages of the server. The native dialect combines
+// queued messages into one WebSocket message, separated by newlines, when
+// split is set. python-matter-server sends every message on its own, which
+// its client relies on.
+type reader struct {
+	conn    *websocket.Conn
+	split   bool
+	pending []map[string]interface{}
+}
+
+func (r *reader) next() (map[string]interface{}, error) {
+	if len(r.pending) == 0 {
+		r.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, data, err := r.conn.ReadMessage()
+		if err != nil {
+			return nil, err
+		}
+		lines := [][]byte{data}
+		if r.split {
+			lines = bytes.Split(data, []byte{'\n'})
+		}
+		for _, line := range lines {
+			var msg map[string]interface{}
+			if err := json.Unmarshal(line, &msg); err != nil {
+				return nil, fmt.Errorf("invalid message %s: %w", line, err)
+			}
+			r.pending = append(r.pending, msg)
+		}
+	}
+	msg := r.pending[0]
+	r.pending = r.pending[1:]
+	return msg, nil
+}
+
+// merge returns the expected message with the fields of override replaced,
+// recursing into objects and into arrays of the same length. A null
+// override removes the field.
+func merge(expected, override map[string]interface{}) map[string]interface{} {
+	if override == nil {
+		return expected
+	}
+	merged := make(map[string]interface{}, len(expected))
+	for key, value := range expected {
+		merged[key] = value
+	}
+	for key, value := range override {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+		merged[key] = mergeValue(merged[key], value)
+	}
+	return merged
+}
+
+func mergeValue(expected, override interface{}) interface{} {
+	switch override := override.(type) {
+	case map[string]interface{}:
+		if base, ok := expected.(map[string]interface{}); ok {
+			return merge(base, override)
+		}
+	case []interface{}:
+		if base, ok := expected.([]interface{}); ok && len(base) == len(override) {
+			merged := make([]interface{}, len(base))
+			for i := range base {
+				merged[i] = mergeValue(base[i], override[i])
+			}
+			return merged
+		}
+	}
+	return override
+}
+
+// compare returns the differences of a message to the expected one, by
+// field path. Fields beyond the expected ones are differences when strict.
+func compare(path string, actual, expected interface{}, strict bool) []string {
+	if placeholder, ok := expected.(string); ok && strings.HasPrefix(placeholder, "$") {
+		if !matchesPlaceholder(actual, placeholder) {
+			return []string{fmt.Sprintf("%s: expected %s, got %s", fieldPath(path), placeholder, encode(actual))}
+		}
+		return nil
+	}
+
+	switch expected := expected.(type) {
+	case map[string]interface{}:
+		actual, ok := actual.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected an object, got %s", fieldPath(path), encode(actual))}
+		}
+		var diffs []string
+		for _, key := range sortedKeys(expected) {
+			value, ok := actual[key]
+			if !ok {
+				diffs = append(diffs, fmt.Sprintf("%s: missing", path+"."+key))
+				continue
+			}
+			diffs = append(diffs, compare(path+"."+key, value, expected[key], strict)...)
+		}
+		if strict {
+			for _, key := range sortedKeys(actual) {
+				if _, ok := expected[key]; !ok {
+					diffs = append(diffs, fmt.Sprintf("%s: not sent by python-matter-server", path+"."+key))
+				}
+			}
+		}
+		return diffs
+	case []interface{}:
+		actual, ok := actual.([]interface{})
+		if !ok || len(actual) != len(expected) {
+			return []string{fmt.Sprintf("%s: expected %d elements, got %s", fieldPath(path), len(expected), encode(actual))}
+		}
+		var diffs []string
+		for i := range expected {
+			diffs = append(diffs, compare(fmt.Sprintf("%s[%d]", path, i), actual[i], expected[i], strict)...)
+		}
+		return diffs
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		return []string{fmt.Sprintf("%s: expected %s, got %s", fieldPath(path), encode(expected), encode(actual))}
+	}
+	return nil
+}
+
+func matchesPlaceholder(actual interface{}, placeholder string) bool {
+	switch placeholder {
+	case anyValue:
+		return true
+	case anyString:
+		_, ok := actual.(string)
+		return ok
+	case anyNumber:
+		_, ok := actual.(float64)
+		return ok
+	case anyBool:
+		_, ok := actual.(bool)
+		return ok
+	case anyTimestamp:
+		s, ok := actual.(string)
+		if !ok {
+			return false
+		}
+		_, err := time.Parse(time.RFC3339Nano, s)
+		return err == nil
+	}
+	return false
+}
+
+func fieldPath(path string) string {
+	if path == "" {
+		return "message"
+	}
+	return path
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func encode(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// describe names an expected message by its message ID or event
+func describe(msg map[string]interface{}) string {
+	if event, ok := msg["event"]; ok {
+		return fmt.Sprintf("event %v", event)
+	}
+	if id, ok := msg["message_id"]; ok {
+		return fmt.Sprintf("response %v", id)
+	}
+	return "server info"
+}
@@ -0,0 +1,10 @@
+// Package conformance checks the WebSocket API of the server against
+// python-matter-server. Its tests run the server and replay the exchanges
+// in testdata/python-matter-server in both WebSocket dialects, comparing
+// every message field by field with the message python-matter-server sends.
+//
+// The home_assistant dialect must send exactly those fields. The native
+// dialect may add fields, and differs only where an exchange declares it.
+// The expected messages are written after the message models of
+// python-matter-server, not captured from a running instance.
+package conformance
@@ -0,0 +1,238 @@
+// Package controller defines the interface between the server and the
+// Matter controller stack that talks to commissioned devices.
+package controller
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/credentials"
+)
+
+// ErrNotAvailable is returned when no Matter controller stack is available
+var ErrNotAvailable = errors.New("matter controller is not available")
+
+// ErrAttestationFailed is returned when a device fails attestation during
+// commissioning. Commissioning is not retried.
+var ErrAttestationFailed = errors.New("device attestation failed")
+
+// CommandRequest describes a cluster command invocation on a device
+type CommandRequest struct {
+	NodeID     int
+	EndpointID uint16
+	ClusterID  uint32
+	CommandID  uint32
+	Payload    map[string]interface{}
+
+	// RawPayload holds the TLV encoded command fields, sent instead of
+	// Payload when set
+	RawPayload []byte
+	// RawResponse returns the TLV encoded response fields as []byte instead
+	// of the decoded payload
+	RawResponse bool
+
+	// TimedRequestTimeout makes the invoke a timed interaction when set
+	TimedRequestTimeout time.Duration
+}
+
+// WriteRequest describes an attribute write on a device. Writes larger than
+// a single message are chunked by the controller.
+type WriteRequest struct {
+	NodeID int
+	Path   string
+	Value  interface{}
+
+	// RawValue holds the TLV encoded value, written instead of Value when
+	// set
+	RawValue []byte
+
+	// TimedRequestTimeout makes the write a timed interaction when set
+	TimedRequestTimeout time.Duration
+}
+
+// GroupCommandRequest describes a multicast cluster command sent to a group
+type GroupCommandRequest struct {
+	GroupID        uint16
+	ClusterID      uint32
+	CommandID      uint32
+	Payload        map[string]interface{}
+	Address        net.IP
+	SessionID      uint16
+	OperationalKey []byte
+}
+
+// EventReportHandler receives the TLV encoded ReportDataMessage of each
+// event report delivered by a subscription
+type EventReportHandler func(report []byte)
+
+// Controller performs interactions with commissioned Matter devices
+type Controller interface {
+	// SendCommand invokes a cluster command and returns the response payload
+	SendCommand(ctx context.Context, req CommandRequest) (interface{}, error)
+
+	// ReadAttribute reads an attribute path ("endpoint/cluster/attribute")
+	ReadAttribute(ctx context.Context, nodeID int, path string) (interface{}, error)
+
+	// WriteAttribute writes a value to an attribute path
+	WriteAttribute(ctx context.Context, req WriteRequest) error
+
+	// Interview reads all attributes of a node, keyed by attribute path
+	Interview(ctx context.Context, nodeID int) (map[string]interface{}, error)
+
+	// SendGroupCommand sends a command to all members of a group with one
+	// multicast message. Group commands are unacknowledged.
+	SendGroupCommand(ctx context.Context, req GroupCommandRequest) error
+
+	// SubscribeEvents subscribes to all events of a node starting at event
+	// number eventMin. Reports are passed to handler until ctx is cancelled.
+	SubscribeEvents(ctx context.Context, nodeID int, eventMin uint64, handler EventReportHandler) error
+}
+
+// CommissionRequest describes commissioning a device into one of the
+// fabrics of the server
+type CommissionRequest struct {
+	// Operational node ID assigned to the device
+	NodeID int
+	// QR code or manual pairing code (commission_with_code)
+	Code        string
+	NetworkOnly bool
+	// Setup PIN code and discovery filter of a device already on the
+	// network (commission_on_network)
+	SetupPINCode int
+	FilterType   int
+	Filter       interface{}
+	IPAddress    net.IP
+
+	// Fabric the device joins
+	Fabric   *credentials.Authority
+	VendorID uint16
+
+	// VerifyAttestation checks the attestation information the device
+	// returned against the PAA trust store. Commissioning is aborted if it
+	// fails.
+	VerifyAttestation func(DeviceAttestation) error
+	// IssueNOC issues the DER encoded operational certificate of the device
+	// for the NOCSR it returned, signed by Fabric for NodeID. It fails until
+	// the attestation was verified.
+	IssueNOC func(csr []byte) ([]byte, error)
+}
+
+// DeviceAttestation is the attestation information a device returns during
+// commissioning
+type DeviceAttestation struct {
+	// DER encoded Device Attestation and Product Attestation Intermediate
+	// certificates
+	DAC []byte
+	PAI []byte
+	// CMS signed Certification Declaration, nil if the device sent none
+	CertificationDeclaration []byte
+}
+
+// Commissioner is implemented by controllers that can commission devices
+type Commissioner interface {
+	// Commission adds a device to req.Fabric under req.NodeID. The
+	// attestation information of the device is passed to
+	// req.VerifyAttestation, then its operational certificate is requested
+	// from req.IssueNOC.
+	Commission(ctx context.Context, req CommissionRequest) error
+}
+
+// RawReader is implemented by controllers that can return attribute values
+// as received, e.g. of manufacturer-specific attributes without a known type
+type RawReader interface {
+	// ReadAttributeRaw reads an attribute path and returns its TLV encoded
+	// value
+	ReadAttributeRaw(ctx context.Context, nodeID int, path string) ([]byte, error)
+}
+
+// SubscribeRequest describes a wildcard subscription to all attributes and
+// events of a node
+type SubscribeRequest struct {
+	NodeID   int
+	EventMin uint64
+
+	// Requested floor and ceiling of the report interval. The device
+	// negotiates the final ceiling; zero leaves it to the controller.
+	MinInterval time.Duration
+	MaxInterval time.Duration
+
+	// Handler receives the TLV encoded ReportDataMessage of each report,
+	// including the empty keep-alive reports
+	Handler EventReportHandler
+}
+
+// Subscriber is implemented by controllers that subscribe to all attributes
+// and events of a node with a single subscription, keeping one session per
+// node instead of one per subscribed path
+type Subscriber interface {
+	// Subscribe subscribes until ctx is cancelled, resubscribing when the
+	// subscription is lost, and returns the negotiated maximum interval
+	Subscribe(ctx context.Context, req SubscribeRequest) (time.Duration, error)
+}
+
+// Resolver finds the current operational addresses of commissioned nodes
+type Resolver interface {
+	// ResolveNode returns the addresses and ports a node advertises, none
+	// if it isn't known
+	ResolveNode(nodeID int) []*net.UDPAddr
+}
+
+// ResolverUser is implemented by controllers that establish sessions to the
+// addresses found by a Resolver instead of resolving nodes themselves
+type ResolverUser interface {
+	SetResolver(resolver Resolver)
+}
+
+// CheckInListener receives the Check-In messages of intermittently
+// connected devices the server registered with
+type CheckInListener interface {
+	// CheckInKey returns the key a node encrypts its Check-In messages
+	// with, nil if the server isn't registered with it
+	CheckInKey(nodeID int) []byte
+
+	// CheckIn is called for each decrypted Check-In message. The node
+	// listens for its active mode threshold afterwards.
+	CheckIn(nodeID int, counter uint32)
+}
+
+// CheckInReceiver is implemented by controllers that receive Check-In
+// messages
+type CheckInReceiver interface {
+	SetCheckInListener(listener CheckInListener)
+}
+
+// Unavailable is a Controller that rejects all device interactions. It is
+// used when the server runs without a Matter controller stack.
+type Unavailable struct{}
+
+// SendCommand implements Controller
+func (Unavailable) SendCommand(ctx context.Context, req CommandRequest) (interface{}, error) {
+	return nil, ErrNotAvailable
+}
+
+// ReadAttribute implements Controller
+func (Unavailable) ReadAttribute(ctx context.Context, nodeID int, path string) (interface{}, error) {
+	return nil, ErrNotAvailable
+}
+
+// WriteAttribute implements Controller
+func (Unavailable) WriteAttribute(ctx context.Context, req WriteRequest) error {
+	return ErrNotAvailable
+}
+
+// Interview implements Controller
+func (Unavailable) Interview(ctx context.Context, nodeID int) (map[string]interface{}, error) {
+	return nil, ErrNotAvailable
+}
+
+// SendGroupCommand implements Controller
+func (Unavailable) SendGroupCommand(ctx context.Context, req GroupCommandRequest) error {
+	return ErrNotAvailable
+}
+
+// SubscribeEvents implements Controller
+func (Unavailable) SubscribeEvents(ctx context.Context, nodeID int, eventMin uint64, handler EventReportHandler) error {
+	return ErrNotAvailable
+}
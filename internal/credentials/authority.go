@@ -0,0 +1,435 @@
+package credentials
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/storage"
+)
+
+// Matter DN attribute OIDs (Matter Core Specification section 6.5.6.1)
+var (
+	oidMatterNodeID   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 37244, 1, 1}
+	oidMatterICACID   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 37244, 1, 3}
+	oidMatterRCACID   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 37244, 1, 4}
+	oidMatterFabricID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 37244, 1, 5}
+)
+
+const (
+	rootCAID         = 1
+	intermediateCAID = 2
+
+	// Largest operational node ID (Matter Core Specification section 2.5.5.1)
+	maxOperationalNodeID = 0xFFFFFFEFFFFFFFFF
+
+	credentialsDir = "credentials"
+)
+
+// notAfterNoExpiry is the GeneralizedTime Matter uses for certificates
+// without a well-defined expiration date.
+var notAfterNoExpiry = time.Date(9999, time.December, 31, 23, 59, 59, 0, time.UTC)
+
+// Authority is the fabric certificate authority. It owns the root (RCAC) and
+// intermediate (ICAC) certificates and issues Node Operational Certificates.
+type Authority struct {
+	basePath string
+	fabricID uint64
+	logger   *logger.Logger
+	mu       sync.Mutex
+
+	// Encrypts the CA keys, nil stores them unencrypted
+	cipher *storage.Cipher
+
+	rootCert *x509.Certificate
+	rootKey  *ecdsa.PrivateKey
+	icaCert  *x509.Certificate
+	icaKey   *ecdsa.PrivateKey
+
+	compressedFabricID uint64
+}
+
+// NewAuthority creates a new certificate authority rooted in basePath
+func NewAuthority(basePath string, fabricID uint64, log *logger.Logger) *Authority {
+	return &Authority{
+		basePath: filepath.Join(basePath, credentialsDir),
+		fabricID: fabricID,
+		logger:   log,
+	}
+}
+
+// SetCipher encrypts the CA keys. Must be called before Load.
+func (a *Authority) SetCipher(c *storage.Cipher) {
+	a.cipher = c
+}
+
+// Load reads the CA certificates and keys from disk, generating and
+// persisting a new root and intermediate CA if none exist yet.
+func (a *Authority) Load() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := os.MkdirAll(a.basePath, 0700); err != nil {
+		return fmt.Errorf("failed to create credentials directory: %w", err)
+	}
+
+	var err error
+	a.rootCert, a.rootKey, err = a.loadPair("rcac")
+	if err != nil {
+		return err
+	}
+
+	if a.rootCert == nil {
+		if a.rootCert, a.rootKey, err = a.generateRoot(); err != nil {
+			return fmt.Errorf("failed to generate root CA: %w", err)
+		}
+		if err := a.savePair("rcac", a.rootCert, a.rootKey); err != nil {
+			return err
+		}
+		a.logger.Info("Generated fabric root CA", logger.String("path", a.basePath))
+	}
+
+	a.icaCert, a.icaKey, err = a.loadPair("icac")
+	if err != nil {
+		return err
+	}
+
+	if a.icaCert == nil || a.icaCert.CheckSignatureFrom(a.rootCert) != nil {
+		if a.icaCert, a.icaKey, err = a.generateIntermediate(); err != nil {
+			return fmt.Errorf("failed to generate intermediate CA: %w", err)
+		}
+		if err := a.savePair("icac", a.icaCert, a.icaKey); err != nil {
+			return err
+		}
+		a.logger.Info("Generated fabric intermediate CA", logger.String("path", a.basePath))
+	}
+
+	rootPub, ok := a.rootCert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("root CA public key is not ECDSA")
+	}
+
+	a.compressedFabricID, err = CompressedFabricID(rootPub, a.fabricID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ImportRoot replaces the root CA with one created by another controller, so
+// devices it commissioned keep trusting this server. The intermediate CA is
+// regenerated under the imported root.
+func (a *Authority) ImportRoot(cert *x509.Certificate, key *ecdsa.PrivateKey) error {
+	if !cert.IsCA {
+		return fmt.Errorf("root certificate is not a CA certificate")
+	}
+	pub, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok || !pub.Equal(&key.PublicKey) {
+		return fmt.Errorf("root key does not match the root certificate")
+	}
+
+	a.mu.Lock()
+	err := os.MkdirAll(a.basePath, 0700)
+	if err == nil {
+		err = a.savePair("rcac", cert, key)
+	}
+	a.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to import root CA: %w", err)
+	}
+
+	return a.Load()
+}
+
+// CompressedFabricID returns the compressed fabric identifier of the fabric
+func (a *Authority) CompressedFabricID() uint64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.compressedFabricID
+}
+
+// FabricID returns the fabric ID the authority issues certificates for
+func (a *Authority) FabricID() uint64 {
+	return a.fabricID
+}
+
+// RootCertificate returns the root CA certificate (RCAC)
+func (a *Authority) RootCertificate() *x509.Certificate {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.rootCert
+}
+
+// IntermediateCertificate returns the intermediate CA certificate (ICAC)
+func (a *Authority) IntermediateCertificate() *x509.Certificate {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.icaCert
+}
+
+// IssueNOC issues a Node Operational Certificate for the given public key and
+// operational node ID, signed by the intermediate CA. The DER encoded
+// certificate is returned.
+func (a *Authority) IssueNOC(pub *ecdsa.PublicKey, nodeID uint64) ([]byte, error) {
+	if nodeID == 0 || nodeID > maxOperationalNodeID {
+		return nil, fmt.Errorf("node ID 0x%016X is not an operational node ID", nodeID)
+	}
+	if pub == nil || pub.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("node public key must use P-256")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.icaCert == nil {
+		return nil, fmt.Errorf("certificate authority not loaded")
+	}
+
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+
+	skid, err := subjectKeyID(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{ExtraNames: []pkix.AttributeTypeAndValue{
+			matterAttribute(oidMatterNodeID, nodeID),
+			matterAttribute(oidMatterFabricID, a.fabricID),
+		}},
+		NotBefore:             time.Now().UTC().Add(-time.Hour),
+		NotAfter:              notAfterNoExpiry,
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  false,
+		SubjectKeyId:          skid,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, a.icaCert, pub, a.icaKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue NOC: %w", err)
+	}
+
+	a.logger.Info("Issued node operational certificate",
+		logger.String("node_id", fmt.Sprintf("0x%016X", nodeID)),
+	)
+
+	return der, nil
+}
+
+// IssueNOCFromCSR validates a PKCS#10 certificate signing request (the
+// NOCSR sent by a device during commissioning) and issues a NOC for it.
+func (a *Authority) IssueNOCFromCSR(csrDER []byte, nodeID uint64) ([]byte, error) {
+	csr, err := x509.ParseCertificateRequest(csrDER)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CSR: %w", err)
+	}
+
+	if err := csr.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("invalid CSR signature: %w", err)
+	}
+
+	pub, ok := csr.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("CSR public key is not ECDSA")
+	}
+
+	return a.IssueNOC(pub, nodeID)
+}
+
+func (a *Authority) generateRoot() (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{ExtraNames: []pkix.AttributeTypeAndValue{
+			matterAttribute(oidMatterRCACID, rootCAID),
+		}},
+		NotBefore:             time.Now().UTC().Add(-time.Hour),
+		NotAfter:              notAfterNoExpiry,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cert, key, nil
+}
+
+func (a *Authority) generateIntermediate() (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{ExtraNames: []pkix.AttributeTypeAndValue{
+			matterAttribute(oidMatterICACID, intermediateCAID),
+		}},
+		NotBefore:             time.Now().UTC().Add(-time.Hour),
+		NotAfter:              notAfterNoExpiry,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, a.rootCert, &key.PublicKey, a.rootKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cert, key, nil
+}
+
+func (a *Authority) loadPair(name string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	certPath := filepath.Join(a.basePath, name+".pem")
+	keyPath := filepath.Join(a.basePath, name+"_key.pem")
+
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("failed to read %s: %w", certPath, err)
+	}
+
+	keyPEM, err := a.cipher.ReadFile(keyPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", keyPath, err)
+	}
+
+	certBlock, _ := pem.Decode(certPEM)
+	if certBlock == nil {
+		return nil, nil, fmt.Errorf("no PEM data in %s", certPath)
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse %s: %w", certPath, err)
+	}
+
+	keyBlock, _ := pem.Decode(keyPEM)
+	if keyBlock == nil {
+		return nil, nil, fmt.Errorf("no PEM data in %s", keyPath)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse %s: %w", keyPath, err)
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s does not contain an ECDSA key", keyPath)
+	}
+
+	return cert, key, nil
+}
+
+func (a *Authority) savePair(name string, cert *x509.Certificate, key *ecdsa.PrivateKey) error {
+	certPath := filepath.Join(a.basePath, name+".pem")
+	keyPath := filepath.Join(a.basePath, name+"_key.pem")
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s key: %w", name, err)
+	}
+
+	keyPEM, err := a.cipher.Seal(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt %s key: %w", name, err)
+	}
+	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", keyPath, err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	if err := os.WriteFile(certPath, certPEM, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", certPath, err)
+	}
+
+	return nil
+}
+
+// matterAttribute encodes a 64-bit Matter identifier as the 16 digit
+// uppercase hex UTF8String required for Matter DN attributes.
+func matterAttribute(oid asn1.ObjectIdentifier, value uint64) pkix.AttributeTypeAndValue {
+	return pkix.AttributeTypeAndValue{
+		Type: oid,
+		Value: asn1.RawValue{
+			Tag:   asn1.TagUTF8String,
+			Bytes: []byte(fmt.Sprintf("%016X", value)),
+		},
+	}
+}
+
+func randomSerial() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 63))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+	return serial, nil
+}
+
+func subjectKeyID(pub crypto.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal public key: %w", err)
+	}
+
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(der, &spki); err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+
+	sum := sha1.Sum(spki.PublicKey.Bytes)
+	return sum[:], nil
+}
@@ -0,0 +1,196 @@
+package credentials
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/storage"
+)
+
+func newTestAuthority(t *testing.T, dir string) *Authority {
+	t.Helper()
+
+	a := NewAuthority(dir, 1, logger.NewConsoleLogger(logger.ErrorLevel))
+	if err := a.Load(); err != nil {
+		t.Fatalf("Failed to load authority: %v", err)
+	}
+	return a
+}
+
+func TestAuthorityGeneratesAndPersists(t *testing.T) {
+	dir := t.TempDir()
+	a := newTestAuthority(t, dir)
+
+	for _, name := range []string{"rcac.pem", "rcac_key.pem", "icac.pem", "icac_key.pem"} {
+		if _, err := os.Stat(filepath.Join(dir, credentialsDir, name)); err != nil {
+			t.Errorf("Expected %s to be written: %v", name, err)
+		}
+	}
+
+	if a.CompressedFabricID() == 0 {
+		t.Error("Expected non-zero compressed fabric ID")
+	}
+
+	// Reloading must reuse the persisted root
+	b := newTestAuthority(t, dir)
+	if !a.RootCertificate().Equal(b.RootCertificate()) {
+		t.Error("Expected root certificate to be reused after reload")
+	}
+	if a.CompressedFabricID() != b.CompressedFabricID() {
+		t.Error("Expected stable compressed fabric ID after reload")
+	}
+}
+
+func TestAuthorityImportRoot(t *testing.T) {
+	source := newTestAuthority(t, t.TempDir())
+	dir := t.TempDir()
+	a := newTestAuthority(t, dir)
+
+	if err := a.ImportRoot(source.rootCert, source.icaKey); err == nil {
+		t.Error("Expected mismatching root key to be rejected")
+	}
+
+	if err := a.ImportRoot(source.rootCert, source.rootKey); err != nil {
+		t.Fatalf("Failed to import root: %v", err)
+	}
+	if !a.RootCertificate().Equal(source.RootCertificate()) {
+		t.Error("Expected imported root certificate")
+	}
+	if a.CompressedFabricID() != source.CompressedFabricID() {
+		t.Error("Expected compressed fabric ID of the imported root")
+	}
+	if err := a.IntermediateCertificate().CheckSignatureFrom(a.RootCertificate()); err != nil {
+		t.Errorf("Expected ICAC regenerated under the imported root: %v", err)
+	}
+
+	if b := newTestAuthority(t, dir); !b.RootCertificate().Equal(source.RootCertificate()) {
+		t.Error("Expected imported root to be persisted")
+	}
+}
+
+func TestAuthorityCertificateChain(t *testing.T) {
+	a := newTestAuthority(t, t.TempDir())
+
+	if err := a.IntermediateCertificate().CheckSignatureFrom(a.RootCertificate()); err != nil {
+		t.Errorf("ICAC not signed by RCAC: %v", err)
+	}
+	if !a.RootCertificate().IsCA || !a.IntermediateCertificate().IsCA {
+		t.Error("Expected RCAC and ICAC to be CA certificates")
+	}
+}
+
+func TestIssueNOC(t *testing.T) {
+	a := newTestAuthority(t, t.TempDir())
+
+	nodeKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	der, err := a.IssueNOC(&nodeKey.PublicKey, 0x1234)
+	if err != nil {
+		t.Fatalf("IssueNOC failed: %v", err)
+	}
+
+	noc, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse NOC: %v", err)
+	}
+
+	if err := noc.CheckSignatureFrom(a.IntermediateCertificate()); err != nil {
+		t.Errorf("NOC not signed by ICAC: %v", err)
+	}
+	if noc.IsCA {
+		t.Error("NOC must not be a CA certificate")
+	}
+
+	found := map[string]string{}
+	for _, name := range noc.Subject.Names {
+		if v, ok := name.Value.(string); ok {
+			found[name.Type.String()] = v
+		}
+	}
+	if found[oidMatterNodeID.String()] != "0000000000001234" {
+		t.Errorf("Expected node ID attribute 0000000000001234, got %q", found[oidMatterNodeID.String()])
+	}
+	if found[oidMatterFabricID.String()] != "0000000000000001" {
+		t.Errorf("Expected fabric ID attribute 0000000000000001, got %q", found[oidMatterFabricID.String()])
+	}
+}
+
+func TestIssueNOCInvalidNodeID(t *testing.T) {
+	a := newTestAuthority(t, t.TempDir())
+
+	nodeKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	for _, nodeID := range []uint64{0, 0xFFFFFFFF00000001} {
+		if _, err := a.IssueNOC(&nodeKey.PublicKey, nodeID); err == nil {
+			t.Errorf("Expected error for node ID 0x%X", nodeID)
+		}
+	}
+}
+
+func TestIssueNOCFromCSR(t *testing.T) {
+	a := newTestAuthority(t, t.TempDir())
+
+	nodeKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: "CSR"},
+	}, nodeKey)
+	if err != nil {
+		t.Fatalf("Failed to create CSR: %v", err)
+	}
+
+	if _, err := a.IssueNOCFromCSR(csr, 42); err != nil {
+		t.Errorf("IssueNOCFromCSR failed: %v", err)
+	}
+
+	csr[len(csr)-1] ^= 0xFF
+	if _, err := a.IssueNOCFromCSR(csr, 42); err == nil {
+		t.Error("Expected error for tampered CSR")
+	}
+}
+
+func TestAuthorityEncryptedKeys(t *testing.T) {
+	dir := t.TempDir()
+	cipher, err := storage.NewCipher("passphrase")
+	if err != nil {
+		t.Fatalf("Failed to create cipher: %v", err)
+	}
+
+	a := NewAuthority(dir, 1, logger.NewConsoleLogger(logger.ErrorLevel))
+	a.SetCipher(cipher)
+	if err := a.Load(); err != nil {
+		t.Fatalf("Failed to load authority: %v", err)
+	}
+
+	keyPEM, err := os.ReadFile(filepath.Join(dir, credentialsDir, "rcac_key.pem"))
+	if err != nil {
+		t.Fatalf("Failed to read key: %v", err)
+	}
+	if strings.Contains(string(keyPEM), "PRIVATE KEY") {
+		t.Error("Expected root key to be encrypted")
+	}
+
+	b := NewAuthority(dir, 1, logger.NewConsoleLogger(logger.ErrorLevel))
+	if err := b.Load(); !storage.IsKeyError(err) {
+		t.Errorf("Expected key error without cipher, got %v", err)
+	}
+
+	c := NewAuthority(dir, 1, logger.NewConsoleLogger(logger.ErrorLevel))
+	c.SetCipher(cipher)
+	if err := c.Load(); err != nil {
+		t.Fatalf("Failed to load encrypted authority: %v", err)
+	}
+	if !c.RootCertificate().Equal(a.RootCertificate()) {
+		t.Error("Expected encrypted root CA to be reused")
+	}
+}
@@ -0,0 +1,40 @@
+package credentials
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+)
+
+// compressedFabricInfo is the HKDF info string defined by the Matter
+// specification for compressed fabric identifier derivation.
+const compressedFabricInfo = "CompressedFabric"
+
+// CompressedFabricID derives the 64-bit compressed fabric identifier from the
+// root CA public key and the fabric ID as described in Matter Core
+// Specification section 4.3.2.2.
+func CompressedFabricID(rootPublicKey *ecdsa.PublicKey, fabricID uint64) (uint64, error) {
+	ecdhKey, err := rootPublicKey.ECDH()
+	if err != nil {
+		return 0, fmt.Errorf("invalid root public key: %w", err)
+	}
+	if ecdhKey.Curve() != ecdh.P256() {
+		return 0, fmt.Errorf("root public key must use P-256")
+	}
+
+	// Uncompressed point without the leading 0x04 format byte
+	keyBytes := ecdhKey.Bytes()[1:]
+
+	salt := make([]byte, 8)
+	binary.BigEndian.PutUint64(salt, fabricID)
+
+	out, err := hkdf.Key(sha256.New, keyBytes, salt, compressedFabricInfo, 8)
+	if err != nil {
+		return 0, fmt.Errorf("failed to derive compressed fabric ID: %w", err)
+	}
+
+	return binary.BigEndian.Uint64(out), nil
+}
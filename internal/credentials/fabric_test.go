@@ -0,0 +1,60 @@
+package credentials
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+func TestCompressedFabricIDSpecVector(t *testing.T) {
+	// Test vector from the Matter Core Specification
+	raw, _ := hex.DecodeString("044a9f42b1ca4840d37292bbc7f6a7e11e22200c976fc900dbc98a7a383a641cb8254a2e56d4e295a847943b4e3897c4a773e930277b4d9fbede8a052686bfacfa")
+	pub := &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(raw[1:33]),
+		Y:     new(big.Int).SetBytes(raw[33:65]),
+	}
+
+	got, err := CompressedFabricID(pub, 0x2906C908D115D362)
+	if err != nil {
+		t.Fatalf("CompressedFabricID failed: %v", err)
+	}
+
+	if got != 0x87E1B004E235A130 {
+		t.Errorf("Expected compressed fabric ID 0x87E1B004E235A130, got 0x%016X", got)
+	}
+}
+
+func TestCompressedFabricIDDependsOnFabric(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	a, err := CompressedFabricID(&key.PublicKey, 1)
+	if err != nil {
+		t.Fatalf("CompressedFabricID failed: %v", err)
+	}
+	b, err := CompressedFabricID(&key.PublicKey, 2)
+	if err != nil {
+		t.Fatalf("CompressedFabricID failed: %v", err)
+	}
+
+	if a == b {
+		t.Error("Expected different compressed fabric IDs for different fabric IDs")
+	}
+}
+
+func TestCompressedFabricIDRejectsOtherCurves(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	if _, err := CompressedFabricID(&key.PublicKey, 1); err == nil {
+		t.Error("Expected error for non P-256 key")
+	}
+}
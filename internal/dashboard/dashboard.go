@@ -0,0 +1,47 @@
+// Package dashboard serves the web dashboard. The dashboard is embedded in the
+// binary; files in an optional directory take precedence, so it can be
+// customized without rebuilding.
+package dashboard
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+)
+
+//go:embed static
+var embedded embed.FS
+
+// overlayFS opens files from the first file system containing them
+type overlayFS []fs.FS
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	var firstErr error
+	for _, fsys := range o {
+		f, err := fsys.Open(name)
+		if err == nil {
+			return f, nil
+		}
+		if firstErr == nil || !errors.Is(err, fs.ErrNotExist) {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+// FS returns the dashboard files. Files in dir, if not empty, take precedence
+// over the embedded ones.
+func FS(dir string) fs.FS {
+	static, _ := fs.Sub(embedded, "static")
+	if dir == "" {
+		return static
+	}
+	return overlayFS{os.DirFS(dir), static}
+}
+
+// Handler serves the dashboard files, see FS
+func Handler(dir string) http.Handler {
+	return http.FileServer(http.FS(FS(dir)))
+}
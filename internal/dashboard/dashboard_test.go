@@ -0,0 +1,69 @@
+package dashboard
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFS(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte("custom"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "extra.js"), []byte("extra"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		dir     string
+		file    string
+		want    string
+		missing bool
+	}{
+		{"Embedded", "", "index.html", "<!DOCTYPE html>", false},
+		{"Embedded asset", "", "app.js", `"use strict"`, false},
+		{"Override", dir, "index.html", "custom", false},
+		{"Fallback to embedded", dir, "app.js", `"use strict"`, false},
+		{"Override only", dir, "extra.js", "extra", false},
+		{"Missing", dir, "missing.js", "", true},
+		{"Missing override directory", filepath.Join(dir, "missing"), "index.html", "<!DOCTYPE html>", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := fs.ReadFile(FS(tt.dir), tt.file)
+			if tt.missing {
+				if !errors.Is(err, fs.ErrNotExist) {
+					t.Errorf("Expected not exist error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to read %s: %v", tt.file, err)
+			}
+			if !strings.HasPrefix(string(data), tt.want) {
+				t.Errorf("Expected %s to start with %q, got %q", tt.file, tt.want, data[:min(len(data), 40)])
+			}
+		})
+	}
+}
+
+func TestHandlerServesIndex(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	Handler("").ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "app.js") {
+		t.Error("Expected index page to load app.js")
+	}
+}
@@ -0,0 +1,170 @@
+"use strict";
+
+// Nodes by node ID, kept up to date by WebSocket events
+const nodes = new Map();
+let selectedNode = null;
+
+const wsEvents = new Set(["node_added", "node_updated", "node_removed", "attribute_updated", "firmware_update_available"]);
+
+async function loadNodes() {
+  const response = await fetch("api/nodes?annotate=true");
+  if (!response.ok) {
+    throw new Error(`failed to load nodes: ${response.status}`);
+  }
+  nodes.clear();
+  for (const node of await response.json()) {
+    nodes.set(node.node_id, node);
+  }
+  render();
+}
+
+// loadFirmware lists the software versions of the nodes with their updates
+async function loadFirmware() {
+  const response = await fetch("api/firmware");
+  if (!response.ok) {
+    throw new Error(`failed to load firmware: ${response.status}`);
+  }
+  const inventory = await response.json();
+  const tbody = document.getElementById("firmware");
+  tbody.replaceChildren();
+  for (const version of inventory.versions) {
+    const row = tbody.insertRow();
+    row.insertCell().textContent = [version.vendor_name, version.product_name].filter(Boolean).join(" ") ||
+      `${version.vendor_id}/${version.product_id}`;
+    row.insertCell().textContent = version.software_version_string || version.software_version;
+    row.insertCell().textContent = version.node_ids.join(", ");
+    row.insertCell().textContent = version.update ? version.update.software_version_string : "";
+  }
+}
+
+function render() {
+  const tbody = document.getElementById("nodes");
+  tbody.replaceChildren();
+  for (const node of [...nodes.values()].sort((a, b) => a.node_id - b.node_id)) {
+    const row = tbody.insertRow();
+    row.insertCell().textContent = node.node_id;
+    row.insertCell().textContent = node.available ? "yes" : "no";
+    row.insertCell().textContent = node.last_interview ? new Date(node.last_interview).toLocaleString() : "";
+    row.insertCell().textContent = Object.keys(node.attributes || {}).length;
+    row.addEventListener("click", () => {
+      selectedNode = node.node_id;
+      renderAttributes();
+    });
+  }
+  renderAttributes();
+}
+
+function renderAttributes() {
+  const section = document.getElementById("node-details");
+  const node = nodes.get(selectedNode);
+  section.hidden = !node;
+  if (!node) {
+    return;
+  }
+
+  document.getElementById("node-title").textContent = `Node ${node.node_id}`;
+  const tbody = document.getElementById("attributes");
+  tbody.replaceChildren();
+  const names = node.attribute_names || {};
+  for (const path of Object.keys(node.attributes || {}).sort(comparePaths)) {
+    const row = tbody.insertRow();
+    row.insertCell().textContent = path;
+    row.insertCell().textContent = names[path] || "";
+    const value = row.insertCell();
+    value.className = "value";
+    value.textContent = JSON.stringify(node.attributes[path]);
+  }
+}
+
+// comparePaths orders endpoint/cluster/attribute paths numerically
+function comparePaths(a, b) {
+  const pa = a.split("/").map(Number);
+  const pb = b.split("/").map(Number);
+  for (let i = 0; i < pa.length; i++) {
+    if (pa[i] !== pb[i]) {
+      return pa[i] - pb[i];
+    }
+  }
+  return 0;
+}
+
+function handleEvent(event, data) {
+  switch (event) {
+    case "node_removed":
+      nodes.delete(data.node_id ?? data);
+      render();
+      loadFirmware().catch(console.error);
+      break;
+    case "firmware_update_available":
+      loadFirmware().catch(console.error);
+      break;
+    case "attribute_updated": {
+      const [nodeID, path, value] = data;
+      const node = nodes.get(nodeID);
+      if (node) {
+        node.attributes[path] = value;
+        if (nodeID === selectedNode) {
+          renderAttributes();
+        }
+      }
+      break;
+    }
+    default:
+      // Reload to get attribute names for new and updated nodes
+      loadNodes().catch(console.error);
+      loadFirmware().catch(console.error);
+  }
+}
+
+function connect() {
+  const status = document.getElementById("status");
+  const url = new URL("ws", location.href);
+  url.protocol = location.protocol === "https:" ? "wss:" : "ws:";
+  const ws = new WebSocket(url);
+
+  ws.onopen = () => {
+    status.textContent = "Connected";
+    status.className = "status connected";
+    ws.send(JSON.stringify({message_id: "dashboard", command: "start_listening", args: {events: [...wsEvents]}}));
+    loadNodes().catch(console.error);
+    loadFirmware().catch(console.error);
+  };
+  ws.onmessage = (message) => {
+    for (const line of message.data.split("\n")) {
+      const msg = JSON.parse(line);
+      if (msg.event && wsEvents.has(msg.event)) {
+        handleEvent(msg.event, msg.data);
+      }
+    }
+  };
+  ws.onclose = () => {
+    status.textContent = "Disconnected, reconnecting…";
+    status.className = "status disconnected";
+    setTimeout(connect, 3000);
+  };
+}
+
+document.getElementById("commission").addEventListener("submit", async (event) => {
+  event.preventDefault();
+  const form = event.target;
+  const result = document.getElementById("commission-result");
+  result.className = "";
+  result.textContent = "Commissioning…";
+
+  const response = await fetch("api/nodes", {
+    method: "POST",
+    headers: {"Content-Type": "application/json"},
+    body: JSON.stringify({code: form.code.value, network_only: form.network_only.checked}),
+  });
+  const body = await response.json();
+  if (!response.ok) {
+    result.className = "error";
+    result.textContent = body.error;
+    return;
+  }
+  result.textContent = "Device commissioned";
+  form.reset();
+  loadNodes().catch(console.error);
+});
+
+connect();
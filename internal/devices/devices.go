@@ -0,0 +1,235 @@
+// Package devices maps the endpoints of interviewed nodes to standard Matter
+// device types. Every supported device type has a normalized state read from
+// the cached attributes and actions that resolve to a cluster command or an
+// attribute write, so API consumers don't need to know which clusters make up
+// a light, a sensor or a thermostat.
+package devices
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/clusters"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// Descriptor DeviceTypeList and Bridged Device Basic Information attributes
+const (
+	descriptorDeviceTypeList = 0x0000
+	bridgedNodeLabel         = 0x0005
+	bridgedReachable         = 0x0011
+)
+
+// Interaction is what an action does on a device: an invoke of CommandID
+// with Payload, or a write of Value to AttributeID when Write is set
+type Interaction struct {
+	EndpointID  int
+	ClusterID   uint32
+	CommandID   uint32
+	Payload     map[string]interface{}
+	Write       bool
+	AttributeID uint32
+	Value       interface{}
+
+	// TimedRequestTimeout makes the interaction timed when set
+	TimedRequestTimeout time.Duration
+}
+
+// deviceType is a supported Matter device type
+type deviceType struct {
+	id     int
+	name   string
+	traits []*trait
+}
+
+// trait is the state and actions a cluster contributes to a device type
+type trait struct {
+	state   func(e endpoint, state map[string]interface{})
+	actions map[string]action
+}
+
+// action resolves an action with its parameters on an endpoint. The action
+// is only offered if the endpoint has the requires attribute, if set.
+type action struct {
+	requires attribute
+	resolve  func(e endpoint, p params) (Interaction, error)
+}
+
+// deviceTypes are the supported device types by the device type IDs of the
+// Matter Device Library Specification
+var deviceTypes = []deviceType{
+	{0x0100, "on_off_light", []*trait{onOffTrait}},
+	{0x0101, "dimmable_light", []*trait{onOffTrait, levelTrait}},
+	{0x010C, "color_temperature_light", []*trait{onOffTrait, levelTrait, colorTemperatureTrait}},
+	{0x010D, "extended_color_light", []*trait{onOffTrait, levelTrait, colorTemperatureTrait, hueSaturationTrait}},
+	{0x010A, "on_off_plug_in_unit", []*trait{onOffTrait}},
+	{0x010B, "dimmable_plug_in_unit", []*trait{onOffTrait, levelTrait}},
+	{0x0015, "contact_sensor", []*trait{contactTrait}},
+	{0x0106, "light_sensor", []*trait{illuminanceTrait}},
+	{0x0107, "occupancy_sensor", []*trait{occupancyTrait}},
+	{0x0302, "temperature_sensor", []*trait{temperatureTrait}},
+	{0x0305, "pressure_sensor", []*trait{pressureTrait}},
+	{0x0307, "humidity_sensor", []*trait{humidityTrait}},
+	{0x0301, "thermostat", []*trait{thermostatTrait}},
+	{0x000A, "door_lock", []*trait{doorLockTrait}},
+	{0x0202, "window_covering", []*trait{windowCoveringTrait}},
+	{0x002B, "fan", []*trait{fanTrait}},
+}
+
+// Classify returns the endpoints of a node that are one of the supported
+// device types, ordered by endpoint. An endpoint listing several supported
+// device types is classified as the first of them.
+func Classify(node *models.MatterNodeData) []models.Device {
+	devices := []models.Device{}
+	for _, id := range endpointIDs(node.Attributes) {
+		e := endpoint{id: id, attributes: node.Attributes}
+		dt, ok := e.deviceType()
+		if !ok {
+			continue
+		}
+
+		device := models.Device{
+			NodeID:       node.NodeID,
+			EndpointID:   id,
+			DeviceType:   dt.name,
+			DeviceTypeID: dt.id,
+			Available:    node.Available,
+			State:        map[string]interface{}{},
+			Actions:      []string{},
+		}
+		if label, ok := e.value(clusters.BridgedDeviceBasicInformationClusterID, bridgedNodeLabel).(string); ok {
+			device.Name = label
+		}
+		if reachable, ok := e.value(clusters.BridgedDeviceBasicInformationClusterID, bridgedReachable).(bool); ok && !reachable {
+			device.Available = false
+		}
+		for _, t := range dt.traits {
+			t.state(e, device.State)
+			for name, a := range t.actions {
+				if a.requires == (attribute{}) || e.has(a.requires) {
+					device.Actions = append(device.Actions, name)
+				}
+			}
+		}
+		sort.Strings(device.Actions)
+		devices = append(devices, device)
+	}
+	return devices
+}
+
+// Resolve returns the interaction performing an action on an endpoint of a
+// node. Invalid endpoints, actions and parameters are argument errors.
+func Resolve(node *models.MatterNodeData, endpointID int, name string, parameters map[string]interface{}) (Interaction, error) {
+	e := endpoint{id: endpointID, attributes: node.Attributes}
+	dt, ok := e.deviceType()
+	if !ok {
+		return Interaction{}, &models.ArgumentError{
+			Field:  "endpoint_id",
+			Reason: fmt.Sprintf("endpoint %d of node %d is not a supported device type", endpointID, node.NodeID),
+		}
+	}
+
+	var names []string
+	for _, t := range dt.traits {
+		for actionName, a := range t.actions {
+			if a.requires != (attribute{}) && !e.has(a.requires) {
+				continue
+			}
+			if actionName == name {
+				interaction, err := a.resolve(e, params(parameters))
+				interaction.EndpointID = endpointID
+				return interaction, err
+			}
+			names = append(names, actionName)
+		}
+	}
+	sort.Strings(names)
+	return Interaction{}, &models.ArgumentError{
+		Field:  "action",
+		Reason: fmt.Sprintf("%s has no action %q, expected one of %s", dt.name, name, strings.Join(names, ", ")),
+	}
+}
+
+// endpointIDs returns the endpoints with a Descriptor DeviceTypeList in order
+func endpointIDs(attributes map[string]interface{}) []int {
+	suffix := fmt.Sprintf("/%d/%d", clusters.DescriptorClusterID, descriptorDeviceTypeList)
+	var ids []int
+	for path := range attributes {
+		if prefix, ok := strings.CutSuffix(path, suffix); ok {
+			if id, err := strconv.Atoi(prefix); err == nil {
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// attribute is an attribute of the endpoint a trait reads or writes
+type attribute struct {
+	cluster   uint32
+	attribute uint32
+}
+
+// endpoint reads the cached attributes of an endpoint of a node
+type endpoint struct {
+	id         int
+	attributes map[string]interface{}
+}
+
+func (e endpoint) deviceType() (deviceType, bool) {
+	list, _ := e.value(clusters.DescriptorClusterID, descriptorDeviceTypeList).([]interface{})
+	for _, entry := range list {
+		fields, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		value, ok := fields["0"]
+		if !ok {
+			value = fields["deviceType"]
+		}
+		id, ok := toFloat(value)
+		if !ok {
+			continue
+		}
+		for _, dt := range deviceTypes {
+			if dt.id == int(id) {
+				return dt, true
+			}
+		}
+	}
+	return deviceType{}, false
+}
+
+func (e endpoint) value(cluster, attributeID uint32) interface{} {
+	return e.attributes[fmt.Sprintf("%d/%d/%d", e.id, cluster, attributeID)]
+}
+
+// has reports whether the endpoint reported an attribute, null included
+func (e endpoint) has(a attribute) bool {
+	_, ok := e.attributes[fmt.Sprintf("%d/%d/%d", e.id, a.cluster, a.attribute)]
+	return ok
+}
+
+// number returns a numeric attribute, false if it is missing or null
+func (e endpoint) number(cluster, attributeID uint32) (float64, bool) {
+	return toFloat(e.value(cluster, attributeID))
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	default:
+		return 0, false
+	}
+}
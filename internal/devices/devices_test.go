@@ -0,0 +1,200 @@
+package devices
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+func testNode() *models.MatterNodeData {
+	return &models.MatterNodeData{
+		NodeID:    5,
+		Available: true,
+		Attributes: map[string]interface{}{
+			// Root node, not a supported device type
+			"0/29/0": []interface{}{map[string]interface{}{"0": float64(0x0016), "1": float64(1)}},
+			// Extended color light without hue and saturation
+			"1/29/0":      []interface{}{map[string]interface{}{"0": float64(0x010D), "1": float64(1)}},
+			"1/6/0":       true,
+			"1/8/0":       float64(127),
+			"1/768/7":     float64(370),
+			"1/768/16395": float64(153),
+			"1/768/16396": float64(454),
+			// Bridged contact sensor that is unreachable
+			"2/29/0":  []interface{}{map[string]interface{}{"deviceType": float64(0x0015)}, map[string]interface{}{"deviceType": float64(0x0013)}},
+			"2/69/0":  false,
+			"2/57/5":  "Front door",
+			"2/57/17": false,
+			// Thermostat that only heats
+			"3/29/0":   []interface{}{map[string]interface{}{"0": float64(0x0301)}},
+			"3/513/0":  nil,
+			"3/513/3":  float64(700),
+			"3/513/4":  float64(3000),
+			"3/513/18": float64(2050),
+			"3/513/28": float64(4),
+		},
+	}
+}
+
+func TestClassify(t *testing.T) {
+	expected := []models.Device{
+		{
+			NodeID:       5,
+			EndpointID:   1,
+			DeviceType:   "extended_color_light",
+			DeviceTypeID: 0x010D,
+			Available:    true,
+			State:        map[string]interface{}{"on": true, "brightness": float64(50), "color_temperature": float64(370)},
+			Actions:      []string{"set_brightness", "set_color_temperature", "toggle", "turn_off", "turn_on"},
+		},
+		{
+			NodeID:       5,
+			EndpointID:   2,
+			DeviceType:   "contact_sensor",
+			DeviceTypeID: 0x0015,
+			Name:         "Front door",
+			Available:    false,
+			State:        map[string]interface{}{"open": true},
+			Actions:      []string{},
+		},
+		{
+			NodeID:       5,
+			EndpointID:   3,
+			DeviceType:   "thermostat",
+			DeviceTypeID: 0x0301,
+			Available:    true,
+			State:        map[string]interface{}{"heating_setpoint": 20.5, "system_mode": "heat"},
+			Actions:      []string{"set_heating_setpoint", "set_system_mode"},
+		},
+	}
+
+	devices := Classify(testNode())
+	if !reflect.DeepEqual(devices, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, devices)
+	}
+
+	if devices := Classify(&models.MatterNodeData{NodeID: 6}); devices == nil || len(devices) != 0 {
+		t.Errorf("Expected no devices, got %#v", devices)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name       string
+		endpoint   int
+		action     string
+		params     map[string]interface{}
+		expected   Interaction
+		errorField string
+	}{
+		{
+			name:     "Turn on",
+			endpoint: 1,
+			action:   "turn_on",
+			expected: Interaction{EndpointID: 1, ClusterID: 0x0006, CommandID: 0x01},
+		},
+		{
+			name:     "Brightness",
+			endpoint: 1,
+			action:   "set_brightness",
+			params:   map[string]interface{}{"brightness": float64(100)},
+			expected: Interaction{EndpointID: 1, ClusterID: 0x0008, CommandID: 0x04, Payload: map[string]interface{}{
+				"level": float64(254), "transitionTime": float64(0), "optionsMask": float64(0), "optionsOverride": float64(0),
+			}},
+		},
+		{
+			name:       "Color temperature out of the physical range",
+			endpoint:   1,
+			action:     "set_color_temperature",
+			params:     map[string]interface{}{"color_temperature": float64(500)},
+			errorField: "params.color_temperature",
+		},
+		{
+			name:       "Hue without the attribute",
+			endpoint:   1,
+			action:     "set_hue_saturation",
+			params:     map[string]interface{}{"hue": float64(120), "saturation": float64(100)},
+			errorField: "action",
+		},
+		{
+			name:     "Heating setpoint",
+			endpoint: 3,
+			action:   "set_heating_setpoint",
+			params:   map[string]interface{}{"temperature": 21.5},
+			expected: Interaction{EndpointID: 3, ClusterID: 0x0201, Write: true, AttributeID: 0x0012, Value: float64(2150)},
+		},
+		{
+			name:       "Heating setpoint above the limit",
+			endpoint:   3,
+			action:     "set_heating_setpoint",
+			params:     map[string]interface{}{"temperature": float64(35)},
+			errorField: "params.temperature",
+		},
+		{
+			name:     "System mode",
+			endpoint: 3,
+			action:   "set_system_mode",
+			params:   map[string]interface{}{"system_mode": "off"},
+			expected: Interaction{EndpointID: 3, ClusterID: 0x0201, Write: true, AttributeID: 0x001C, Value: float64(0)},
+		},
+		{
+			name:       "Unknown system mode",
+			endpoint:   3,
+			action:     "set_system_mode",
+			params:     map[string]interface{}{"system_mode": "defrost"},
+			errorField: "params.system_mode",
+		},
+		{
+			name:       "Sensor without actions",
+			endpoint:   2,
+			action:     "turn_on",
+			errorField: "action",
+		},
+		{
+			name:       "Unsupported endpoint",
+			endpoint:   0,
+			action:     "turn_on",
+			errorField: "endpoint_id",
+		},
+	}
+
+	node := testNode()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interaction, err := Resolve(node, tt.endpoint, tt.action, tt.params)
+			if tt.errorField != "" {
+				var argErr *models.ArgumentError
+				if !errors.As(err, &argErr) || argErr.Field != tt.errorField {
+					t.Errorf("Expected an argument error for %s, got %v", tt.errorField, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve failed: %v", err)
+			}
+			if !reflect.DeepEqual(interaction, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, interaction)
+			}
+		})
+	}
+}
+
+func TestDoorLock(t *testing.T) {
+	node := &models.MatterNodeData{NodeID: 7, Attributes: map[string]interface{}{
+		"1/29/0":  []interface{}{map[string]interface{}{"0": float64(0x000A)}},
+		"1/257/0": float64(2),
+	}}
+
+	devices := Classify(node)
+	if len(devices) != 1 || devices[0].State["lock_state"] != "unlocked" || devices[0].State["locked"] != false {
+		t.Errorf("Expected an unlocked door lock, got %+v", devices)
+	}
+
+	interaction, err := Resolve(node, 1, "lock", nil)
+	if err != nil || interaction.CommandID != 0x00 || interaction.TimedRequestTimeout != time.Second {
+		t.Errorf("Expected a timed LockDoor, got %+v, %v", interaction, err)
+	}
+}
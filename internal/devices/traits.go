@@ -0,0 +1,387 @@
+package devices
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/clusters"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+const (
+	// maxLevel is the highest CurrentLevel of the Level Control cluster
+	maxLevel = 254
+	// maxHueSaturation is the highest CurrentHue and CurrentSaturation
+	maxHueSaturation = 254
+	// lockTimedRequestTimeout is the timeout of door lock commands, which
+	// require a timed invoke
+	lockTimedRequestTimeout = time.Second
+)
+
+// Thermostat SystemMode, Door Lock LockState and Fan Control FanMode values
+// by their index
+var (
+	systemModes = []string{"off", "auto", "", "cool", "heat", "emergency_heat", "precooling", "fan_only", "dry", "sleep"}
+	lockStates  = []string{"not_fully_locked", "locked", "unlocked", "unlatched"}
+	fanModes    = []string{"off", "low", "medium", "high", "on", "auto", "smart"}
+)
+
+var onOffTrait = &trait{
+	state: func(e endpoint, state map[string]interface{}) {
+		if on, ok := e.value(clusters.OnOffClusterID, 0x0000).(bool); ok {
+			state["on"] = on
+		}
+	},
+	actions: map[string]action{
+		"turn_off": {resolve: invoke(clusters.OnOffClusterID, 0x00)},
+		"turn_on":  {resolve: invoke(clusters.OnOffClusterID, 0x01)},
+		"toggle":   {resolve: invoke(clusters.OnOffClusterID, 0x02)},
+	},
+}
+
+// levelTrait reports and sets the level as brightness in percent
+var levelTrait = &trait{
+	state: func(e endpoint, state map[string]interface{}) {
+		if level, ok := e.number(clusters.LevelControlClusterID, 0x0000); ok {
+			state["brightness"] = math.Round(level * 100 / maxLevel)
+		}
+	},
+	actions: map[string]action{
+		"set_brightness": {resolve: func(e endpoint, p params) (Interaction, error) {
+			brightness, err := p.number("brightness", 0, 100)
+			if err != nil {
+				return Interaction{}, err
+			}
+			// MoveToLevelWithOnOff
+			return Interaction{ClusterID: clusters.LevelControlClusterID, CommandID: 0x04, Payload: map[string]interface{}{
+				"level":           math.Round(brightness * maxLevel / 100),
+				"transitionTime":  float64(0),
+				"optionsMask":     float64(0),
+				"optionsOverride": float64(0),
+			}}, nil
+		}},
+	},
+}
+
+// colorTemperatureTrait reports and sets the color temperature in mireds
+// within the physical limits of the light
+var colorTemperatureTrait = &trait{
+	state: func(e endpoint, state map[string]interface{}) {
+		if mireds, ok := e.number(clusters.ColorControlClusterID, 0x0007); ok {
+			state["color_temperature"] = mireds
+		}
+	},
+	actions: map[string]action{
+		"set_color_temperature": {
+			requires: attribute{clusters.ColorControlClusterID, 0x0007},
+			resolve: func(e endpoint, p params) (Interaction, error) {
+				low, ok := e.number(clusters.ColorControlClusterID, 0x400B)
+				if !ok {
+					low = 1
+				}
+				high, ok := e.number(clusters.ColorControlClusterID, 0x400C)
+				if !ok {
+					high = 0xFEFF
+				}
+				mireds, err := p.number("color_temperature", low, high)
+				if err != nil {
+					return Interaction{}, err
+				}
+				// MoveToColorTemperature
+				return Interaction{ClusterID: clusters.ColorControlClusterID, CommandID: 0x0A, Payload: map[string]interface{}{
+					"colorTemperatureMireds": math.Round(mireds),
+					"transitionTime":         float64(0),
+					"optionsMask":            float64(0),
+					"optionsOverride":        float64(0),
+				}}, nil
+			},
+		},
+	},
+}
+
+// hueSaturationTrait reports and sets the hue in degrees and the saturation
+// in percent
+var hueSaturationTrait = &trait{
+	state: func(e endpoint, state map[string]interface{}) {
+		if hue, ok := e.number(clusters.ColorControlClusterID, 0x0000); ok {
+			state["hue"] = math.Round(hue * 360 / maxHueSaturation)
+		}
+		if saturation, ok := e.number(clusters.ColorControlClusterID, 0x0001); ok {
+			state["saturation"] = math.Round(saturation * 100 / maxHueSaturation)
+		}
+	},
+	actions: map[string]action{
+		"set_hue_saturation": {
+			requires: attribute{clusters.ColorControlClusterID, 0x0000},
+			resolve: func(e endpoint, p params) (Interaction, error) {
+				hue, err := p.number("hue", 0, 360)
+				if err != nil {
+					return Interaction{}, err
+				}
+				saturation, err := p.number("saturation", 0, 100)
+				if err != nil {
+					return Interaction{}, err
+				}
+				// MoveToHueAndSaturation
+				return Interaction{ClusterID: clusters.ColorControlClusterID, CommandID: 0x06, Payload: map[string]interface{}{
+					"hue":             math.Round(hue * maxHueSaturation / 360),
+					"saturation":      math.Round(saturation * maxHueSaturation / 100),
+					"transitionTime":  float64(0),
+					"optionsMask":     float64(0),
+					"optionsOverride": float64(0),
+				}}, nil
+			},
+		},
+	},
+}
+
+// contactTrait reports whether a contact sensor is open, StateValue is true
+// while in contact
+var contactTrait = &trait{
+	state: func(e endpoint, state map[string]interface{}) {
+		if contact, ok := e.value(clusters.BooleanStateClusterID, 0x0000).(bool); ok {
+			state["open"] = !contact
+		}
+	},
+}
+
+// illuminanceTrait reports the illuminance in lux, MeasuredValue is
+// 10000 × log10(lux) + 1
+var illuminanceTrait = &trait{
+	state: func(e endpoint, state map[string]interface{}) {
+		if value, ok := e.number(clusters.IlluminanceMeasurementClusterID, 0x0000); ok {
+			lux := 0.0
+			if value > 0 {
+				lux = math.Round(math.Pow(10, (value-1)/10000)*10) / 10
+			}
+			state["illuminance"] = lux
+		}
+	},
+}
+
+var occupancyTrait = &trait{
+	state: func(e endpoint, state map[string]interface{}) {
+		if occupancy, ok := e.number(clusters.OccupancySensingClusterID, 0x0000); ok {
+			state["occupied"] = int(occupancy)&1 != 0
+		}
+	},
+}
+
+// temperatureTrait reports the temperature in °C
+var temperatureTrait = &trait{
+	state: func(e endpoint, state map[string]interface{}) {
+		if value, ok := e.number(clusters.TemperatureMeasurementClusterID, 0x0000); ok {
+			state["temperature"] = value / 100
+		}
+	},
+}
+
+// pressureTrait reports the pressure in hPa, MeasuredValue is in 0.1 kPa
+var pressureTrait = &trait{
+	state: func(e endpoint, state map[string]interface{}) {
+		if value, ok := e.number(clusters.PressureMeasurementClusterID, 0x0000); ok {
+			state["pressure"] = value
+		}
+	},
+}
+
+// humidityTrait reports the relative humidity in percent
+var humidityTrait = &trait{
+	state: func(e endpoint, state map[string]interface{}) {
+		if value, ok := e.number(clusters.RelativeHumidityMeasurementClusterID, 0x0000); ok {
+			state["humidity"] = value / 100
+		}
+	},
+}
+
+// thermostatTrait reports temperatures in °C. Setpoints are limited to the
+// absolute limits of the thermostat.
+var thermostatTrait = &trait{
+	state: func(e endpoint, state map[string]interface{}) {
+		if value, ok := e.number(clusters.ThermostatClusterID, 0x0000); ok {
+			state["temperature"] = value / 100
+		}
+		if value, ok := e.number(clusters.ThermostatClusterID, 0x0012); ok {
+			state["heating_setpoint"] = value / 100
+		}
+		if value, ok := e.number(clusters.ThermostatClusterID, 0x0011); ok {
+			state["cooling_setpoint"] = value / 100
+		}
+		if mode, ok := e.number(clusters.ThermostatClusterID, 0x001C); ok {
+			state["system_mode"] = enumName(systemModes, mode)
+		}
+	},
+	actions: map[string]action{
+		// OccupiedHeatingSetpoint within AbsMinHeatSetpointLimit and
+		// AbsMaxHeatSetpointLimit
+		"set_heating_setpoint": {
+			requires: attribute{clusters.ThermostatClusterID, 0x0012},
+			resolve:  writeSetpoint(0x0012, 0x0003, 0x0004),
+		},
+		// OccupiedCoolingSetpoint within AbsMinCoolSetpointLimit and
+		// AbsMaxCoolSetpointLimit
+		"set_cooling_setpoint": {
+			requires: attribute{clusters.ThermostatClusterID, 0x0011},
+			resolve:  writeSetpoint(0x0011, 0x0005, 0x0006),
+		},
+		"set_system_mode": {
+			requires: attribute{clusters.ThermostatClusterID, 0x001C},
+			resolve: func(e endpoint, p params) (Interaction, error) {
+				mode, err := p.choice("system_mode", systemModes)
+				if err != nil {
+					return Interaction{}, err
+				}
+				return Interaction{ClusterID: clusters.ThermostatClusterID, Write: true, AttributeID: 0x001C, Value: float64(mode)}, nil
+			},
+		},
+	},
+}
+
+var doorLockTrait = &trait{
+	state: func(e endpoint, state map[string]interface{}) {
+		if lockState, ok := e.number(clusters.DoorLockClusterID, 0x0000); ok {
+			state["lock_state"] = enumName(lockStates, lockState)
+			state["locked"] = lockState == 1
+		}
+	},
+	actions: map[string]action{
+		"lock":   {resolve: timed(invoke(clusters.DoorLockClusterID, 0x00), lockTimedRequestTimeout)},
+		"unlock": {resolve: timed(invoke(clusters.DoorLockClusterID, 0x01), lockTimedRequestTimeout)},
+	},
+}
+
+// windowCoveringTrait reports and sets the lift position as open percentage,
+// 100 is fully open. The cluster counts closed percent in hundredths.
+var windowCoveringTrait = &trait{
+	state: func(e endpoint, state map[string]interface{}) {
+		if closed, ok := e.number(clusters.WindowCoveringClusterID, 0x000E); ok {
+			state["position"] = math.Round(100 - closed/100)
+		}
+	},
+	actions: map[string]action{
+		"open":  {resolve: invoke(clusters.WindowCoveringClusterID, 0x00)},
+		"close": {resolve: invoke(clusters.WindowCoveringClusterID, 0x01)},
+		"stop":  {resolve: invoke(clusters.WindowCoveringClusterID, 0x02)},
+		"set_position": {
+			requires: attribute{clusters.WindowCoveringClusterID, 0x000E},
+			resolve: func(e endpoint, p params) (Interaction, error) {
+				position, err := p.number("position", 0, 100)
+				if err != nil {
+					return Interaction{}, err
+				}
+				// GoToLiftPercentage
+				return Interaction{ClusterID: clusters.WindowCoveringClusterID, CommandID: 0x05, Payload: map[string]interface{}{
+					"liftPercent100thsValue": math.Round((100 - position) * 100),
+				}}, nil
+			},
+		},
+	},
+}
+
+var fanTrait = &trait{
+	state: func(e endpoint, state map[string]interface{}) {
+		if mode, ok := e.number(clusters.FanControlClusterID, 0x0000); ok {
+			state["fan_mode"] = enumName(fanModes, mode)
+		}
+		if percent, ok := e.number(clusters.FanControlClusterID, 0x0003); ok {
+			state["percentage"] = percent
+		}
+	},
+	actions: map[string]action{
+		"set_fan_mode": {
+			requires: attribute{clusters.FanControlClusterID, 0x0000},
+			resolve: func(e endpoint, p params) (Interaction, error) {
+				mode, err := p.choice("fan_mode", fanModes)
+				if err != nil {
+					return Interaction{}, err
+				}
+				return Interaction{ClusterID: clusters.FanControlClusterID, Write: true, AttributeID: 0x0000, Value: float64(mode)}, nil
+			},
+		},
+		// PercentSetting
+		"set_percentage": {
+			requires: attribute{clusters.FanControlClusterID, 0x0002},
+			resolve: func(e endpoint, p params) (Interaction, error) {
+				percent, err := p.number("percentage", 0, 100)
+				if err != nil {
+					return Interaction{}, err
+				}
+				return Interaction{ClusterID: clusters.FanControlClusterID, Write: true, AttributeID: 0x0002, Value: math.Round(percent)}, nil
+			},
+		},
+	},
+}
+
+// invoke resolves to a command without fields
+func invoke(cluster, command uint32) func(endpoint, params) (Interaction, error) {
+	return func(endpoint, params) (Interaction, error) {
+		return Interaction{ClusterID: cluster, CommandID: command}, nil
+	}
+}
+
+// timed makes the interaction of an action timed
+func timed(resolve func(endpoint, params) (Interaction, error), timeout time.Duration) func(endpoint, params) (Interaction, error) {
+	return func(e endpoint, p params) (Interaction, error) {
+		interaction, err := resolve(e, p)
+		interaction.TimedRequestTimeout = timeout
+		return interaction, err
+	}
+}
+
+// writeSetpoint resolves to a write of a thermostat setpoint in 0.01 °C
+// from the temperature parameter in °C
+func writeSetpoint(setpoint, minLimit, maxLimit uint32) func(endpoint, params) (Interaction, error) {
+	return func(e endpoint, p params) (Interaction, error) {
+		low, ok := e.number(clusters.ThermostatClusterID, minLimit)
+		if !ok {
+			low = -27315
+		}
+		high, ok := e.number(clusters.ThermostatClusterID, maxLimit)
+		if !ok {
+			high = 32767
+		}
+		temperature, err := p.number("temperature", low/100, high/100)
+		if err != nil {
+			return Interaction{}, err
+		}
+		return Interaction{ClusterID: clusters.ThermostatClusterID, Write: true, AttributeID: setpoint, Value: math.Round(temperature * 100)}, nil
+	}
+}
+
+// enumName returns the name of an enum value, the number for unknown ones
+func enumName(names []string, value float64) interface{} {
+	if i := int(value); i >= 0 && i < len(names) && names[i] != "" {
+		return names[i]
+	}
+	return value
+}
+
+// params are the parameters of an action
+type params map[string]interface{}
+
+// number returns a required numeric parameter within [low, high]
+func (p params) number(name string, low, high float64) (float64, error) {
+	value, ok := toFloat(p[name])
+	if !ok {
+		return 0, &models.ArgumentError{Field: "params." + name, Reason: "expected a number"}
+	}
+	if value < low || value > high {
+		return 0, &models.ArgumentError{Field: "params." + name, Reason: fmt.Sprintf("must be between %g and %g", low, high)}
+	}
+	return value, nil
+}
+
+// choice returns the index of a required parameter naming one of names
+func (p params) choice(name string, names []string) (int, error) {
+	value, _ := p[name].(string)
+	if i := slices.Index(names, value); i >= 0 && value != "" {
+		return i, nil
+	}
+	return 0, &models.ArgumentError{
+		Field:  "params." + name,
+		Reason: "expected one of " + strings.Join(slices.DeleteFunc(slices.Clone(names), func(s string) bool { return s == "" }), ", "),
+	}
+}
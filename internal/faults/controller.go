@@ -0,0 +1,122 @@
+package faults
+
+import (
+	"context"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/controller"
+)
+
+// faultyController fails a percentage of the interactions with the wrapped
+// controller. The interactions of optional interfaces the wrapped controller
+// doesn't implement fail with controller.ErrNotAvailable, as the server does
+// without them.
+type faultyController struct {
+	controller.Controller
+	injector *Injector
+}
+
+// faultySubscriber is a faultyController of a controller.Subscriber. It is a
+// separate type so the server still falls back to SubscribeEvents for
+// controllers that aren't Subscribers.
+type faultySubscriber struct {
+	*faultyController
+}
+
+// Controller wraps a controller, failing its interactions as configured.
+// Resolvers and Check-In listeners must be set on the wrapped controller
+// before.
+func (i *Injector) Controller(inner controller.Controller) controller.Controller {
+	c := &faultyController{Controller: inner, injector: i}
+	_, subscriber := inner.(controller.Subscriber)
+	receiver, checkIns := inner.(controller.CheckInReceiver)
+	switch {
+	case subscriber && checkIns:
+		return struct {
+			faultySubscriber
+			controller.CheckInReceiver
+		}{faultySubscriber{c}, receiver}
+	case subscriber:
+		return faultySubscriber{c}
+	case checkIns:
+		return struct {
+			*faultyController
+			controller.CheckInReceiver
+		}{c, receiver}
+	}
+	return c
+}
+
+func (c *faultyController) SendCommand(ctx context.Context, req controller.CommandRequest) (interface{}, error) {
+	if err := c.injector.interaction(); err != nil {
+		return nil, err
+	}
+	return c.Controller.SendCommand(ctx, req)
+}
+
+func (c *faultyController) ReadAttribute(ctx context.Context, nodeID int, path string) (interface{}, error) {
+	if err := c.injector.interaction(); err != nil {
+		return nil, err
+	}
+	return c.Controller.ReadAttribute(ctx, nodeID, path)
+}
+
+func (c *faultyController) WriteAttribute(ctx context.Context, req controller.WriteRequest) error {
+	if err := c.injector.interaction(); err != nil {
+		return err
+	}
+	return c.Controller.WriteAttribute(ctx, req)
+}
+
+func (c *faultyController) Interview(ctx context.Context, nodeID int) (map[string]interface{}, error) {
+	if err := c.injector.interaction(); err != nil {
+		return nil, err
+	}
+	return c.Controller.Interview(ctx, nodeID)
+}
+
+func (c *faultyController) SendGroupCommand(ctx context.Context, req controller.GroupCommandRequest) error {
+	if err := c.injector.interaction(); err != nil {
+		return err
+	}
+	return c.Controller.SendGroupCommand(ctx, req)
+}
+
+func (c *faultyController) SubscribeEvents(ctx context.Context, nodeID int, eventMin uint64, handler controller.EventReportHandler) error {
+	if err := c.injector.interaction(); err != nil {
+		return err
+	}
+	return c.Controller.SubscribeEvents(ctx, nodeID, eventMin, handler)
+}
+
+// Commission implements controller.Commissioner
+func (c *faultyController) Commission(ctx context.Context, req controller.CommissionRequest) error {
+	commissioner, ok := c.Controller.(controller.Commissioner)
+	if !ok {
+		return controller.ErrNotAvailable
+	}
+	if err := c.injector.interaction(); err != nil {
+		return err
+	}
+	return commissioner.Commission(ctx, req)
+}
+
+// ReadAttributeRaw implements controller.RawReader
+func (c *faultyController) ReadAttributeRaw(ctx context.Context, nodeID int, path string) ([]byte, error) {
+	reader, ok := c.Controller.(controller.RawReader)
+	if !ok {
+		return nil, controller.ErrNotAvailable
+	}
+	if err := c.injector.interaction(); err != nil {
+		return nil, err
+	}
+	return reader.ReadAttributeRaw(ctx, nodeID, path)
+}
+
+// Subscribe implements controller.Subscriber
+func (c faultySubscriber) Subscribe(ctx context.Context, req controller.SubscribeRequest) (time.Duration, error) {
+	if err := c.injector.interaction(); err != nil {
+		return 0, err
+	}
+	return c.Controller.(controller.Subscriber).Subscribe(ctx, req)
+}
@@ -0,0 +1,99 @@
+// Package faults injects failures into the storage and the device
+// interactions of the server, so its retries, reconnects and error codes can
+// be tested. It is only used when server.fault_injection is enabled.
+package faults
+
+import (
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrInjected is returned by device interactions failed on purpose
+var ErrInjected = errors.New("injected fault")
+
+// Settings are the faults an Injector injects. The zero value injects none.
+type Settings struct {
+	// Delay added to every storage write
+	StorageWriteDelay time.Duration
+	// Percentage of storage writes silently discarded
+	StorageWriteDropPercent int
+	// Percentage of device interactions failing with ErrInjected
+	InteractionFailurePercent int
+}
+
+// Stats counts the injected faults
+type Stats struct {
+	DelayedWrites      uint64
+	DroppedWrites      uint64
+	FailedInteractions uint64
+}
+
+// Injector decides which storage writes and device interactions fail. The
+// settings can be changed while the server runs.
+type Injector struct {
+	mu       sync.RWMutex
+	settings Settings
+
+	delayedWrites      atomic.Uint64
+	droppedWrites      atomic.Uint64
+	failedInteractions atomic.Uint64
+}
+
+// NewInjector creates an Injector injecting no faults until Set is called
+func NewInjector() *Injector {
+	return &Injector{}
+}
+
+// Settings returns the current settings
+func (i *Injector) Settings() Settings {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.settings
+}
+
+// Set replaces the settings
+func (i *Injector) Set(settings Settings) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.settings = settings
+}
+
+// Stats returns the number of faults injected so far
+func (i *Injector) Stats() Stats {
+	return Stats{
+		DelayedWrites:      i.delayedWrites.Load(),
+		DroppedWrites:      i.droppedWrites.Load(),
+		FailedInteractions: i.failedInteractions.Load(),
+	}
+}
+
+// write delays a storage write and reports whether it is carried out
+func (i *Injector) write() bool {
+	settings := i.Settings()
+	if settings.StorageWriteDelay > 0 {
+		i.delayedWrites.Add(1)
+		time.Sleep(settings.StorageWriteDelay)
+	}
+	if chance(settings.StorageWriteDropPercent) {
+		i.droppedWrites.Add(1)
+		return false
+	}
+	return true
+}
+
+// interaction returns ErrInjected for the device interactions that fail
+func (i *Injector) interaction() error {
+	if chance(i.Settings().InteractionFailurePercent) {
+		i.failedInteractions.Add(1)
+		return ErrInjected
+	}
+	return nil
+}
+
+// chance returns true with the given percentage
+func chance(percent int) bool {
+	return percent > 0 && rand.IntN(100) < percent
+}
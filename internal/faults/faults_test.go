@@ -0,0 +1,132 @@
+package faults
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/controller"
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/storage"
+)
+
+func TestStorage(t *testing.T) {
+	inner := storage.NewJSONStorage(t.TempDir(), logger.NewConsoleLogger(logger.FatalLevel))
+	if err := inner.Start(); err != nil {
+		t.Fatalf("Failed to start storage: %v", err)
+	}
+	defer inner.Stop()
+
+	injector := NewInjector()
+	store := injector.Storage(inner)
+
+	if err := store.SaveNode(&models.MatterNodeData{NodeID: 1}); err != nil {
+		t.Fatalf("Failed to save node: %v", err)
+	}
+	if _, err := store.GetNode(1); err != nil {
+		t.Errorf("Expected node 1 to be saved without faults, got %v", err)
+	}
+
+	injector.Set(Settings{StorageWriteDelay: 20 * time.Millisecond, StorageWriteDropPercent: 100})
+	start := time.Now()
+	if err := store.SaveNode(&models.MatterNodeData{NodeID: 2}); err != nil {
+		t.Fatalf("Expected dropped writes to succeed, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Expected the write to be delayed, took %s", elapsed)
+	}
+	if _, err := store.GetNode(2); err == nil {
+		t.Error("Expected node 2 to be dropped")
+	}
+	if err := store.DeleteNode(1); err != nil {
+		t.Fatalf("Expected dropped deletes to succeed, got %v", err)
+	}
+	if _, err := store.GetNode(1); err != nil {
+		t.Errorf("Expected node 1 to survive the dropped delete, got %v", err)
+	}
+
+	stats := injector.Stats()
+	if stats.DelayedWrites != 2 || stats.DroppedWrites != 2 {
+		t.Errorf("Expected 2 delayed and 2 dropped writes, got %+v", stats)
+	}
+}
+
+// subscriber is a controller supporting subscriptions, commissioning and
+// Check-In messages
+type subscriber struct {
+	controller.Unavailable
+	subscribed  int
+	commissions int
+	listener    controller.CheckInListener
+}
+
+func (s *subscriber) Subscribe(ctx context.Context, req controller.SubscribeRequest) (time.Duration, error) {
+	s.subscribed++
+	return time.Minute, nil
+}
+
+func (s *subscriber) Commission(ctx context.Context, req controller.CommissionRequest) error {
+	s.commissions++
+	return nil
+}
+
+func (s *subscriber) SetCheckInListener(listener controller.CheckInListener) {
+	s.listener = listener
+}
+
+func TestController(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Keeps the optional interfaces", func(t *testing.T) {
+		wrapped := NewInjector().Controller(controller.Unavailable{})
+		if _, ok := wrapped.(controller.Subscriber); ok {
+			t.Error("Expected no Subscriber for a controller without subscriptions")
+		}
+		if _, ok := wrapped.(controller.CheckInReceiver); ok {
+			t.Error("Expected no CheckInReceiver for a controller without Check-In support")
+		}
+		if err := wrapped.(controller.Commissioner).Commission(ctx, controller.CommissionRequest{}); !errors.Is(err, controller.ErrNotAvailable) {
+			t.Errorf("Expected ErrNotAvailable, got %v", err)
+		}
+
+		inner := &subscriber{}
+		wrapped = NewInjector().Controller(inner)
+		if _, err := wrapped.(controller.Subscriber).Subscribe(ctx, controller.SubscribeRequest{}); err != nil || inner.subscribed != 1 {
+			t.Errorf("Expected the subscription to be passed through, got %v", err)
+		}
+		if err := wrapped.(controller.Commissioner).Commission(ctx, controller.CommissionRequest{}); err != nil || inner.commissions != 1 {
+			t.Errorf("Expected commissioning to be passed through, got %v", err)
+		}
+		if _, ok := wrapped.(controller.CheckInReceiver); !ok {
+			t.Error("Expected a CheckInReceiver")
+		}
+	})
+
+	t.Run("Fails interactions", func(t *testing.T) {
+		inner := &subscriber{}
+		injector := NewInjector()
+		wrapped := injector.Controller(inner)
+		injector.Set(Settings{InteractionFailurePercent: 100})
+
+		if _, err := wrapped.SendCommand(ctx, controller.CommandRequest{}); !errors.Is(err, ErrInjected) {
+			t.Errorf("Expected ErrInjected, got %v", err)
+		}
+		if _, err := wrapped.(controller.Subscriber).Subscribe(ctx, controller.SubscribeRequest{}); !errors.Is(err, ErrInjected) {
+			t.Errorf("Expected ErrInjected, got %v", err)
+		}
+		if inner.subscribed != 0 {
+			t.Error("Expected the failed subscription not to reach the controller")
+		}
+		if stats := injector.Stats(); stats.FailedInteractions != 2 {
+			t.Errorf("Expected 2 failed interactions, got %d", stats.FailedInteractions)
+		}
+
+		// The wrapped controller's own error shows once faults are off
+		injector.Set(Settings{})
+		if _, err := wrapped.SendCommand(ctx, controller.CommandRequest{}); !errors.Is(err, controller.ErrNotAvailable) {
+			t.Errorf("Expected ErrNotAvailable, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,83 @@
+package faults
+
+import (
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/storage"
+)
+
+// faultyStorage delays and drops the writes to the wrapped storage. Reads
+// and the lifecycle are passed through.
+type faultyStorage struct {
+	storage.Storage
+	injector *Injector
+}
+
+// Storage wraps a storage, delaying and dropping its writes as configured
+func (i *Injector) Storage(inner storage.Storage) storage.Storage {
+	return &faultyStorage{Storage: inner, injector: i}
+}
+
+func (s *faultyStorage) SaveNode(node *models.MatterNodeData) error {
+	if !s.injector.write() {
+		return nil
+	}
+	return s.Storage.SaveNode(node)
+}
+
+func (s *faultyStorage) SaveNodes(nodes []*models.MatterNodeData) error {
+	if !s.injector.write() {
+		return nil
+	}
+	return s.Storage.SaveNodes(nodes)
+}
+
+func (s *faultyStorage) DeleteNode(nodeID int) error {
+	if !s.injector.write() {
+		return nil
+	}
+	return s.Storage.DeleteNode(nodeID)
+}
+
+func (s *faultyStorage) SaveVendor(vendor *models.VendorInfo) error {
+	if !s.injector.write() {
+		return nil
+	}
+	return s.Storage.SaveVendor(vendor)
+}
+
+func (s *faultyStorage) SaveVendors(vendors []*models.VendorInfo) error {
+	if !s.injector.write() {
+		return nil
+	}
+	return s.Storage.SaveVendors(vendors)
+}
+
+func (s *faultyStorage) SaveSetting(key string, value interface{}) error {
+	if !s.injector.write() {
+		return nil
+	}
+	return s.Storage.SaveSetting(key, value)
+}
+
+func (s *faultyStorage) DeleteSetting(key string) error {
+	if !s.injector.write() {
+		return nil
+	}
+	return s.Storage.DeleteSetting(key)
+}
+
+func (s *faultyStorage) AddEvent(entry *models.EventHistoryEntry) error {
+	if !s.injector.write() {
+		return nil
+	}
+	return s.Storage.AddEvent(entry)
+}
+
+func (s *faultyStorage) RecordAttribute(nodeID int, path string, value interface{}, at time.Time) error {
+	if !s.injector.write() {
+		return nil
+	}
+	return s.Storage.RecordAttribute(nodeID, path, value, at)
+}
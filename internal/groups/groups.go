@@ -0,0 +1,338 @@
+// Package groups manages Matter groups, their group key sets and member
+// endpoints, persisted in the server storage directory.
+package groups
+
+import (
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/storage"
+)
+
+const (
+	groupsFile = "groups.json"
+
+	// Application group IDs (Matter Core Specification section 2.5.4)
+	minGroupID = 0x0001
+	maxGroupID = 0xFEFF
+
+	epochKeyLength = 16
+)
+
+// Key derivation info strings (Matter Core Specification section 4.17.2)
+const (
+	groupKeyInfo     = "GroupKey v1.0"
+	groupKeyHashInfo = "GroupKeyHash"
+)
+
+var (
+	// ErrGroupNotFound is returned for unknown group IDs
+	ErrGroupNotFound = errors.New("group not found")
+
+	// ErrNoFreeGroupID is returned when all application group IDs are in use
+	ErrNoFreeGroupID = errors.New("no free group ID")
+)
+
+// Member is a node endpoint belonging to a group
+type Member struct {
+	NodeID     int    `json:"node_id"`
+	EndpointID uint16 `json:"endpoint_id"`
+}
+
+// Group is a Matter group with its key set
+type Group struct {
+	GroupID        uint16   `json:"group_id"`
+	Name           string   `json:"name"`
+	KeySetID       uint16   `json:"key_set_id"`
+	EpochKey       []byte   `json:"epoch_key,omitempty"`
+	EpochStartTime uint64   `json:"epoch_start_time"`
+	Members        []Member `json:"members"`
+}
+
+// Manager holds all groups and persists them to disk
+type Manager struct {
+	path   string
+	logger *logger.Logger
+	mu     sync.RWMutex
+	groups map[uint16]*Group
+
+	// Encrypts the group keys, nil stores them unencrypted
+	cipher *storage.Cipher
+}
+
+// NewManager creates a group manager storing its state in basePath
+func NewManager(basePath string, log *logger.Logger) *Manager {
+	return &Manager{
+		path:   filepath.Join(basePath, groupsFile),
+		logger: log,
+		groups: make(map[uint16]*Group),
+	}
+}
+
+// SetCipher encrypts the persisted groups. Must be called before Load.
+func (m *Manager) SetCipher(c *storage.Cipher) {
+	m.cipher = c
+}
+
+// Load reads the persisted groups. A missing file is not an error.
+func (m *Manager) Load() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, err := m.cipher.ReadFile(m.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read groups: %w", err)
+	}
+
+	var groups []*Group
+	if err := json.Unmarshal(data, &groups); err != nil {
+		return fmt.Errorf("failed to parse groups: %w", err)
+	}
+
+	// Loading again replaces the groups, e.g. with ones replicated from a
+	// primary server
+	m.groups = make(map[uint16]*Group, len(groups))
+	for _, g := range groups {
+		m.groups[g.GroupID] = g
+	}
+
+	m.logger.Info("Loaded groups", logger.Int("count", len(groups)))
+	return nil
+}
+
+// Create creates a new group with a fresh epoch key. A groupID of zero
+// allocates the lowest free application group ID.
+func (m *Manager) Create(groupID uint16, name string) (*Group, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if groupID == 0 {
+		for id := minGroupID; id <= maxGroupID; id++ {
+			if _, exists := m.groups[uint16(id)]; !exists {
+				groupID = uint16(id)
+				break
+			}
+		}
+		if groupID == 0 {
+			return nil, ErrNoFreeGroupID
+		}
+	} else if groupID < minGroupID || groupID > maxGroupID {
+		return nil, fmt.Errorf("invalid group ID 0x%04X", groupID)
+	} else if _, exists := m.groups[groupID]; exists {
+		return nil, fmt.Errorf("group 0x%04X already exists", groupID)
+	}
+
+	epochKey := make([]byte, epochKeyLength)
+	if _, err := rand.Read(epochKey); err != nil {
+		return nil, fmt.Errorf("failed to generate epoch key: %w", err)
+	}
+
+	g := &Group{
+		GroupID:        groupID,
+		Name:           name,
+		KeySetID:       groupID,
+		EpochKey:       epochKey,
+		EpochStartTime: uint64(time.Now().UnixMicro()),
+		Members:        []Member{},
+	}
+	m.groups[groupID] = g
+
+	if err := m.save(); err != nil {
+		delete(m.groups, groupID)
+		return nil, err
+	}
+
+	return g.copy(), nil
+}
+
+// Remove deletes a group
+func (m *Manager) Remove(groupID uint16) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, exists := m.groups[groupID]
+	if !exists {
+		return ErrGroupNotFound
+	}
+
+	delete(m.groups, groupID)
+	if err := m.save(); err != nil {
+		m.groups[groupID] = g
+		return err
+	}
+	return nil
+}
+
+// Get returns a copy of a group
+func (m *Manager) Get(groupID uint16) (*Group, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, exists := m.groups[groupID]
+	if !exists {
+		return nil, ErrGroupNotFound
+	}
+	return g.copy(), nil
+}
+
+// List returns copies of all groups ordered by group ID
+func (m *Manager) List() []*Group {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	groups := make([]*Group, 0, len(m.groups))
+	for _, g := range m.groups {
+		groups = append(groups, g.copy())
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].GroupID < groups[j].GroupID
+	})
+	return groups
+}
+
+// AddMember adds a node endpoint to a group
+func (m *Manager) AddMember(groupID uint16, member Member) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, exists := m.groups[groupID]
+	if !exists {
+		return ErrGroupNotFound
+	}
+	if g.hasMember(member) {
+		return nil
+	}
+
+	g.Members = append(g.Members, member)
+	if err := m.save(); err != nil {
+		g.Members = g.Members[:len(g.Members)-1]
+		return err
+	}
+	return nil
+}
+
+// RemoveMember removes a node endpoint from a group
+func (m *Manager) RemoveMember(groupID uint16, member Member) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, exists := m.groups[groupID]
+	if !exists {
+		return ErrGroupNotFound
+	}
+
+	previous := g.Members
+	members := make([]Member, 0, len(g.Members))
+	for _, existing := range g.Members {
+		if existing != member {
+			members = append(members, existing)
+		}
+	}
+	g.Members = members
+
+	if err := m.save(); err != nil {
+		g.Members = previous
+		return err
+	}
+	return nil
+}
+
+func (m *Manager) save() error {
+	groups := make([]*Group, 0, len(m.groups))
+	for _, g := range m.groups {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].GroupID < groups[j].GroupID
+	})
+
+	data, err := json.MarshalIndent(groups, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal groups: %w", err)
+	}
+	if data, err = m.cipher.Seal(data); err != nil {
+		return fmt.Errorf("failed to encrypt groups: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(m.path), 0755); err != nil {
+		return fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	// Group keys are secrets, keep the file private
+	tmpPath := m.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write groups: %w", err)
+	}
+	if err := os.Rename(tmpPath, m.path); err != nil {
+		return fmt.Errorf("failed to write groups: %w", err)
+	}
+	return nil
+}
+
+func (g *Group) hasMember(member Member) bool {
+	for _, existing := range g.Members {
+		if existing == member {
+			return true
+		}
+	}
+	return false
+}
+
+// Public returns a copy of the group without its key material, suitable for
+// API responses
+func (g *Group) Public() *Group {
+	c := g.copy()
+	c.EpochKey = nil
+	return c
+}
+
+func (g *Group) copy() *Group {
+	c := *g
+	c.EpochKey = append([]byte(nil), g.EpochKey...)
+	c.Members = append([]Member{}, g.Members...)
+	return &c
+}
+
+// OperationalKey derives the operational group key from the group's epoch
+// key and the compressed fabric ID
+func (g *Group) OperationalKey(compressedFabricID uint64) ([]byte, error) {
+	salt := make([]byte, 8)
+	binary.BigEndian.PutUint64(salt, compressedFabricID)
+	return hkdf.Key(sha256.New, g.EpochKey, salt, groupKeyInfo, epochKeyLength)
+}
+
+// SessionID derives the group session ID carried in group messages from the
+// operational group key
+func SessionID(operationalKey []byte) (uint16, error) {
+	hash, err := hkdf.Key(sha256.New, operationalKey, nil, groupKeyHashInfo, 2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(hash), nil
+}
+
+// MulticastAddress returns the IPv6 multicast address for a group on a
+// fabric: FF35:0040:FD<fabric ID>00:<group ID>
+func MulticastAddress(fabricID uint64, groupID uint16) net.IP {
+	ip := make(net.IP, net.IPv6len)
+	ip[0], ip[1], ip[2], ip[3], ip[4] = 0xFF, 0x35, 0x00, 0x40, 0xFD
+	binary.BigEndian.PutUint64(ip[5:13], fabricID)
+	ip[13] = 0x00
+	binary.BigEndian.PutUint16(ip[14:16], groupID)
+	return ip
+}
@@ -0,0 +1,146 @@
+package groups
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+)
+
+func newTestManager(t *testing.T, dir string) *Manager {
+	m := NewManager(dir, logger.NewConsoleLogger(logger.FatalLevel))
+	if err := m.Load(); err != nil {
+		t.Fatalf("Failed to load groups: %v", err)
+	}
+	return m
+}
+
+func TestCreateGroup(t *testing.T) {
+	m := newTestManager(t, t.TempDir())
+
+	g, err := m.Create(0, "Living room")
+	if err != nil {
+		t.Fatalf("Failed to create group: %v", err)
+	}
+	if g.GroupID != minGroupID {
+		t.Errorf("Expected first group ID %d, got %d", minGroupID, g.GroupID)
+	}
+	if len(g.EpochKey) != epochKeyLength {
+		t.Errorf("Expected %d byte epoch key, got %d", epochKeyLength, len(g.EpochKey))
+	}
+
+	g2, err := m.Create(0, "Kitchen")
+	if err != nil || g2.GroupID != minGroupID+1 {
+		t.Errorf("Expected next free group ID, got %v (%v)", g2, err)
+	}
+
+	if _, err := m.Create(g.GroupID, "Duplicate"); err == nil {
+		t.Error("Expected error for existing group ID")
+	}
+	if _, err := m.Create(0xFF00, "Reserved"); err == nil {
+		t.Error("Expected error for non-application group ID")
+	}
+}
+
+func TestGroupPersistence(t *testing.T) {
+	dir := t.TempDir()
+	m := newTestManager(t, dir)
+
+	g, _ := m.Create(0x0100, "Lights")
+	if err := m.AddMember(g.GroupID, Member{NodeID: 5, EndpointID: 1}); err != nil {
+		t.Fatalf("Failed to add member: %v", err)
+	}
+	// Adding the same member again is a no-op
+	m.AddMember(g.GroupID, Member{NodeID: 5, EndpointID: 1})
+	m.AddMember(g.GroupID, Member{NodeID: 6, EndpointID: 2})
+
+	info, err := os.Stat(filepath.Join(dir, groupsFile))
+	if err != nil {
+		t.Fatalf("Expected groups file: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected groups file mode 0600, got %o", info.Mode().Perm())
+	}
+
+	reloaded := newTestManager(t, dir)
+	loaded, err := reloaded.Get(0x0100)
+	if err != nil {
+		t.Fatalf("Expected group after reload: %v", err)
+	}
+	if !bytes.Equal(loaded.EpochKey, g.EpochKey) {
+		t.Error("Expected epoch key to be persisted")
+	}
+	if len(loaded.Members) != 2 {
+		t.Errorf("Expected 2 members, got %v", loaded.Members)
+	}
+
+	if err := reloaded.RemoveMember(0x0100, Member{NodeID: 5, EndpointID: 1}); err != nil {
+		t.Fatalf("Failed to remove member: %v", err)
+	}
+	if err := reloaded.Remove(0x0100); err != nil {
+		t.Fatalf("Failed to remove group: %v", err)
+	}
+	if _, err := reloaded.Get(0x0100); err != ErrGroupNotFound {
+		t.Errorf("Expected ErrGroupNotFound, got %v", err)
+	}
+	if err := reloaded.AddMember(0x0100, Member{NodeID: 1}); err != ErrGroupNotFound {
+		t.Errorf("Expected ErrGroupNotFound, got %v", err)
+	}
+}
+
+func TestGetReturnsCopy(t *testing.T) {
+	m := newTestManager(t, t.TempDir())
+	g, _ := m.Create(0, "Test")
+
+	copy1, _ := m.Get(g.GroupID)
+	copy1.Members = append(copy1.Members, Member{NodeID: 99})
+	copy1.EpochKey[0] ^= 0xFF
+
+	copy2, _ := m.Get(g.GroupID)
+	if len(copy2.Members) != 0 || copy2.EpochKey[0] == copy1.EpochKey[0] {
+		t.Error("Expected Get to return independent copies")
+	}
+
+	if copy2.Public().EpochKey != nil {
+		t.Error("Expected public group view without key material")
+	}
+}
+
+func TestOperationalKey(t *testing.T) {
+	g := &Group{EpochKey: bytes.Repeat([]byte{0xA5}, epochKeyLength)}
+
+	key1, err := g.OperationalKey(0x87E1B004E235A130)
+	if err != nil {
+		t.Fatalf("Failed to derive key: %v", err)
+	}
+	key2, _ := g.OperationalKey(0x87E1B004E235A130)
+	key3, _ := g.OperationalKey(1)
+
+	if len(key1) != epochKeyLength {
+		t.Errorf("Expected %d byte key, got %d", epochKeyLength, len(key1))
+	}
+	if !bytes.Equal(key1, key2) {
+		t.Error("Expected key derivation to be deterministic")
+	}
+	if bytes.Equal(key1, key3) {
+		t.Error("Expected keys to differ per fabric")
+	}
+
+	if _, err := SessionID(key1); err != nil {
+		t.Errorf("Failed to derive session ID: %v", err)
+	}
+}
+
+func TestMulticastAddress(t *testing.T) {
+	ip := MulticastAddress(0x1122334455667788, 0x0102)
+
+	expected := "ff35:40:fd11:2233:4455:6677:8800:102"
+	if ip.String() != expected {
+		t.Errorf("Expected %s, got %s", expected, ip)
+	}
+	if !ip.IsMulticast() {
+		t.Error("Expected multicast address")
+	}
+}
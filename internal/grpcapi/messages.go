@@ -0,0 +1,384 @@
+package grpcapi
+
+import "sort"
+
+// message is a protobuf message of matter_server.proto
+type message interface {
+	marshal() []byte
+	unmarshal(buf []byte) error
+}
+
+type GetNodesRequest struct {
+	Available *bool
+}
+
+func (m *GetNodesRequest) marshal() []byte {
+	var e encoder
+	e.optionalBool(1, m.Available)
+	return e.buf
+}
+
+func (m *GetNodesRequest) unmarshal(buf []byte) error {
+	return decodeFields(buf, func(f field) error {
+		if f.number != 1 {
+			return nil
+		}
+		available, err := f.bool()
+		m.Available = &available
+		return err
+	})
+}
+
+type GetNodesResponse struct {
+	Nodes []*Node
+}
+
+func (m *GetNodesResponse) marshal() []byte {
+	var e encoder
+	for _, node := range m.Nodes {
+		e.bytes(1, node.marshal())
+	}
+	return e.buf
+}
+
+func (m *GetNodesResponse) unmarshal(buf []byte) error {
+	return decodeFields(buf, func(f field) error {
+		if f.number != 1 {
+			return nil
+		}
+		data, err := f.bytes()
+		if err != nil {
+			return err
+		}
+		node := &Node{}
+		m.Nodes = append(m.Nodes, node)
+		return node.unmarshal(data)
+	})
+}
+
+type GetNodeRequest struct {
+	NodeID int64
+}
+
+func (m *GetNodeRequest) marshal() []byte {
+	var e encoder
+	e.int64(1, m.NodeID)
+	return e.buf
+}
+
+func (m *GetNodeRequest) unmarshal(buf []byte) error {
+	return decodeFields(buf, func(f field) error {
+		var err error
+		if f.number == 1 {
+			m.NodeID, err = f.int64()
+		}
+		return err
+	})
+}
+
+type Node struct {
+	NodeID           int64
+	DateCommissioned string
+	LastInterview    string
+	InterviewVersion int32
+	Available        bool
+	IsBridge         bool
+	// JSON values keyed by attribute path
+	Attributes map[string]string
+	FabricID   int64
+	// User metadata, empty if unset
+	Name string
+	Room string
+	Tags []string
+}
+
+func (m *Node) marshal() []byte {
+	var e encoder
+	e.int64(1, m.NodeID)
+	e.string(2, m.DateCommissioned)
+	e.string(3, m.LastInterview)
+	e.int64(4, int64(m.InterviewVersion))
+	e.bool(5, m.Available)
+	e.bool(6, m.IsBridge)
+
+	// Sorted, so the encoding is deterministic
+	keys := make([]string, 0, len(m.Attributes))
+	for key := range m.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	e.stringMap(7, m.Attributes, keys)
+	e.int64(8, m.FabricID)
+	e.string(9, m.Name)
+	e.string(10, m.Room)
+	for _, tag := range m.Tags {
+		e.bytes(11, []byte(tag))
+	}
+	return e.buf
+}
+
+func (m *Node) unmarshal(buf []byte) error {
+	return decodeFields(buf, func(f field) error {
+		var err error
+		switch f.number {
+		case 1:
+			m.NodeID, err = f.int64()
+		case 2:
+			m.DateCommissioned, err = f.string()
+		case 3:
+			m.LastInterview, err = f.string()
+		case 4:
+			var v int64
+			v, err = f.int64()
+			m.InterviewVersion = int32(v)
+		case 5:
+			m.Available, err = f.bool()
+		case 6:
+			m.IsBridge, err = f.bool()
+		case 7:
+			var key, value string
+			key, value, err = f.stringMapEntry()
+			if m.Attributes == nil {
+				m.Attributes = make(map[string]string)
+			}
+			m.Attributes[key] = value
+		case 8:
+			m.FabricID, err = f.int64()
+		case 9:
+			m.Name, err = f.string()
+		case 10:
+			m.Room, err = f.string()
+		case 11:
+			var tag string
+			tag, err = f.string()
+			m.Tags = append(m.Tags, tag)
+		}
+		return err
+	})
+}
+
+type CommissionRequest struct {
+	Code        string
+	NetworkOnly bool
+	// 0 commissions into the default fabric
+	FabricID int64
+}
+
+func (m *CommissionRequest) marshal() []byte {
+	var e encoder
+	e.string(1, m.Code)
+	e.bool(2, m.NetworkOnly)
+	e.int64(3, m.FabricID)
+	return e.buf
+}
+
+func (m *CommissionRequest) unmarshal(buf []byte) error {
+	return decodeFields(buf, func(f field) error {
+		var err error
+		switch f.number {
+		case 1:
+			m.Code, err = f.string()
+		case 2:
+			m.NetworkOnly, err = f.bool()
+		case 3:
+			m.FabricID, err = f.int64()
+		}
+		return err
+	})
+}
+
+type DeviceCommandRequest struct {
+	NodeID      int64
+	EndpointID  uint32
+	Cluster     string
+	CommandName string
+	// JSON object, empty for none
+	Payload               string
+	TimedRequestTimeoutMs uint32
+}
+
+func (m *DeviceCommandRequest) marshal() []byte {
+	var e encoder
+	e.int64(1, m.NodeID)
+	e.uint64(2, uint64(m.EndpointID))
+	e.string(3, m.Cluster)
+	e.string(4, m.CommandName)
+	e.string(5, m.Payload)
+	e.uint64(6, uint64(m.TimedRequestTimeoutMs))
+	return e.buf
+}
+
+func (m *DeviceCommandRequest) unmarshal(buf []byte) error {
+	return decodeFields(buf, func(f field) error {
+		var err error
+		var v uint64
+		switch f.number {
+		case 1:
+			m.NodeID, err = f.int64()
+		case 2:
+			v, err = f.uint64()
+			m.EndpointID = uint32(v)
+		case 3:
+			m.Cluster, err = f.string()
+		case 4:
+			m.CommandName, err = f.string()
+		case 5:
+			m.Payload, err = f.string()
+		case 6:
+			v, err = f.uint64()
+			m.TimedRequestTimeoutMs = uint32(v)
+		}
+		return err
+	})
+}
+
+type WriteAttributeRequest struct {
+	NodeID        int64
+	AttributePath string
+	// JSON value
+	Value                 string
+	TimedRequestTimeoutMs uint32
+}
+
+func (m *WriteAttributeRequest) marshal() []byte {
+	var e encoder
+	e.int64(1, m.NodeID)
+	e.string(2, m.AttributePath)
+	e.string(3, m.Value)
+	e.uint64(4, uint64(m.TimedRequestTimeoutMs))
+	return e.buf
+}
+
+func (m *WriteAttributeRequest) unmarshal(buf []byte) error {
+	return decodeFields(buf, func(f field) error {
+		var err error
+		switch f.number {
+		case 1:
+			m.NodeID, err = f.int64()
+		case 2:
+			m.AttributePath, err = f.string()
+		case 3:
+			m.Value, err = f.string()
+		case 4:
+			var v uint64
+			v, err = f.uint64()
+			m.TimedRequestTimeoutMs = uint32(v)
+		}
+		return err
+	})
+}
+
+type CommandRequest struct {
+	Command string
+	// JSON object, empty for none
+	Args string
+}
+
+func (m *CommandRequest) marshal() []byte {
+	var e encoder
+	e.string(1, m.Command)
+	e.string(2, m.Args)
+	return e.buf
+}
+
+func (m *CommandRequest) unmarshal(buf []byte) error {
+	return decodeFields(buf, func(f field) error {
+		var err error
+		switch f.number {
+		case 1:
+			m.Command, err = f.string()
+		case 2:
+			m.Args, err = f.string()
+		}
+		return err
+	})
+}
+
+type CommandResponse struct {
+	// JSON result
+	Result string
+}
+
+func (m *CommandResponse) marshal() []byte {
+	var e encoder
+	e.string(1, m.Result)
+	return e.buf
+}
+
+func (m *CommandResponse) unmarshal(buf []byte) error {
+	return decodeFields(buf, func(f field) error {
+		var err error
+		if f.number == 1 {
+			m.Result, err = f.string()
+		}
+		return err
+	})
+}
+
+type EventsRequest struct {
+	Events         []string
+	NodeIDs        []int64
+	AttributePaths []string
+}
+
+func (m *EventsRequest) marshal() []byte {
+	var e encoder
+	for _, event := range m.Events {
+		e.bytes(1, []byte(event))
+	}
+	e.int64s(2, m.NodeIDs)
+	for _, path := range m.AttributePaths {
+		e.bytes(3, []byte(path))
+	}
+	return e.buf
+}
+
+func (m *EventsRequest) unmarshal(buf []byte) error {
+	return decodeFields(buf, func(f field) error {
+		switch f.number {
+		case 1, 3:
+			s, err := f.string()
+			if err != nil {
+				return err
+			}
+			if f.number == 1 {
+				m.Events = append(m.Events, s)
+			} else {
+				m.AttributePaths = append(m.AttributePaths, s)
+			}
+		case 2:
+			ids, err := f.int64s()
+			if err != nil {
+				return err
+			}
+			m.NodeIDs = append(m.NodeIDs, ids...)
+		}
+		return nil
+	})
+}
+
+type Event struct {
+	Event string
+	// JSON data
+	Data string
+}
+
+func (m *Event) marshal() []byte {
+	var e encoder
+	e.string(1, m.Event)
+	e.string(2, m.Data)
+	return e.buf
+}
+
+func (m *Event) unmarshal(buf []byte) error {
+	return decodeFields(buf, func(f field) error {
+		var err error
+		switch f.number {
+		case 1:
+			m.Event, err = f.string()
+		case 2:
+			m.Data, err = f.string()
+		}
+		return err
+	})
+}
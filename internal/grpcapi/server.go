@@ -0,0 +1,529 @@
+// Package grpcapi serves the gRPC API of matter_server.proto. It speaks
+// gRPC over unencrypted HTTP/2 with the standard library, and encodes the
+// few messages of the service by hand instead of generating code.
+package grpcapi
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/audit"
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/websocket"
+)
+
+// ServiceName is the full name of the service in matter_server.proto
+const ServiceName = "matter_server.v1.MatterServer"
+
+const (
+	// maxMessageSize is the largest request message accepted, the default
+	// of gRPC implementations
+	maxMessageSize = 4 << 20
+
+	// eventBuffer is how many events a stream holds for a slow client
+	// before the stream is ended
+	eventBuffer = 256
+)
+
+// CommandServer is the part of the Matter server the gRPC API uses
+type CommandServer interface {
+	HandleCommand(ctx context.Context, cmd models.CommandMessage) (interface{}, error)
+	Subscribe(callback models.EventCallback) func()
+}
+
+// unaryMethod handles an RPC with a single request and response message
+type unaryMethod func(s *Server, ctx context.Context, request []byte) (message, error)
+
+var unaryMethods = map[string]unaryMethod{
+	"GetNodes":       (*Server).getNodes,
+	"GetNode":        (*Server).getNode,
+	"Commission":     (*Server).commission,
+	"DeviceCommand":  (*Server).deviceCommand,
+	"WriteAttribute": (*Server).writeAttribute,
+	"Command":        (*Server).command,
+}
+
+// Server serves the gRPC API, running every RPC through the HandleCommand
+// of the Matter server like the WebSocket and REST APIs do
+type Server struct {
+	server CommandServer
+	logger *logger.Logger
+
+	mu         sync.Mutex
+	httpServer *http.Server
+	// done is closed on shutdown to end the event streams, which would
+	// otherwise keep the HTTP server from shutting down
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+// NewServer creates the gRPC API of a Matter server
+func NewServer(server CommandServer, log *logger.Logger) *Server {
+	if log == nil {
+		log = logger.NewConsoleLogger(logger.InfoLevel)
+	}
+	return &Server{
+		server: server,
+		logger: log,
+		done:   make(chan struct{}),
+	}
+}
+
+// Serve accepts gRPC connections on a listener until Shutdown is called, in
+// which case it returns http.ErrServerClosed
+func (s *Server) Serve(listener net.Listener) error {
+	// gRPC clients talk HTTP/2 with prior knowledge when not using TLS
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+
+	s.mu.Lock()
+	s.httpServer = &http.Server{
+		Handler:           s,
+		Protocols:         protocols,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       5 * time.Minute,
+	}
+	httpServer := s.httpServer
+	s.mu.Unlock()
+
+	return httpServer.Serve(listener)
+}
+
+// Shutdown ends the event streams and waits for the unary calls to finish
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.doneOnce.Do(func() { close(s.done) })
+
+	s.mu.Lock()
+	httpServer := s.httpServer
+	s.mu.Unlock()
+	if httpServer == nil {
+		return nil
+	}
+	return httpServer.Shutdown(ctx)
+}
+
+// ServeHTTP handles a gRPC call
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "gRPC calls must use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.ProtoMajor != 2 {
+		http.Error(w, "gRPC requires HTTP/2", http.StatusHTTPVersionNotSupported)
+		return
+	}
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "application/grpc" && !strings.HasPrefix(contentType, "application/grpc+proto") {
+		http.Error(w, "unsupported content type "+contentType, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+
+	ctx := r.Context()
+	if timeout := r.Header.Get("Grpc-Timeout"); timeout != "" {
+		d, err := parseTimeout(timeout)
+		if err != nil {
+			s.writeStatus(w, statusErrorf(codeInternal, "%v", err))
+			return
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+	ctx = audit.WithClient(ctx, audit.Client{ConnectionID: "grpc", RemoteAddress: r.RemoteAddr, BearerToken: bearerToken(r)})
+
+	s.writeStatus(w, s.handle(ctx, w, r))
+}
+
+// bearerToken returns the bearer token clients send as authorization
+// metadata
+func bearerToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
+	return ""
+}
+
+// handle runs the method of a call and writes its response messages
+func (s *Server) handle(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	service, method, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if !ok || service != ServiceName {
+		return statusErrorf(codeUnimplemented, "unknown service %s", service)
+	}
+
+	request, err := readMessage(r.Body)
+	if err != nil {
+		return err
+	}
+
+	if method == "Events" {
+		return s.events(ctx, w, request)
+	}
+	handler, ok := unaryMethods[method]
+	if !ok {
+		return statusErrorf(codeUnimplemented, "unknown method %s", method)
+	}
+	response, err := handler(s, ctx, request)
+	if err != nil {
+		return err
+	}
+	return writeMessage(w, response)
+}
+
+// writeStatus sets the trailers ending a call
+func (s *Server) writeStatus(w http.ResponseWriter, err error) {
+	c, msg := statusOf(err)
+	if c != codeOK {
+		s.logger.Debug("gRPC call failed", logger.Int("code", int(c)), logger.String("message", msg))
+	}
+	w.Header().Set("Grpc-Status", strconv.Itoa(int(c)))
+	w.Header().Set("Grpc-Message", encodeStatusMessage(msg))
+}
+
+// readMessage reads the single, length-prefixed request message of a call
+func readMessage(body io.Reader) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(body, prefix[:]); err != nil {
+		return nil, statusErrorf(codeInternal, "missing request message: %v", err)
+	}
+	if prefix[0] != 0 {
+		return nil, statusErrorf(codeUnimplemented, "compressed messages are not supported")
+	}
+	length := binary.BigEndian.Uint32(prefix[1:])
+	if length > maxMessageSize {
+		return nil, statusErrorf(codeResourceExhausted, "request message of %d bytes exceeds %d bytes", length, maxMessageSize)
+	}
+	request := make([]byte, length)
+	if _, err := io.ReadFull(body, request); err != nil {
+		return nil, statusErrorf(codeInternal, "truncated request message: %v", err)
+	}
+	return request, nil
+}
+
+// writeMessage writes a length-prefixed response message and flushes it, so
+// streamed messages reach the client right away
+func writeMessage(w http.ResponseWriter, m message) error {
+	data := m.marshal()
+	frame := make([]byte, 5, 5+len(data))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(data)))
+	if _, err := w.Write(append(frame, data...)); err != nil {
+		return err
+	}
+	return http.NewResponseController(w).Flush()
+}
+
+// parseTimeout parses the grpc-timeout header, e.g. "100m" for 100
+// milliseconds
+func parseTimeout(value string) (time.Duration, error) {
+	units := map[byte]time.Duration{
+		'H': time.Hour,
+		'M': time.Minute,
+		'S': time.Second,
+		'm': time.Millisecond,
+		'u': time.Microsecond,
+		'n': time.Nanosecond,
+	}
+	if len(value) < 2 || len(value) > 9 {
+		return 0, fmt.Errorf("invalid grpc-timeout %q", value)
+	}
+	unit, ok := units[value[len(value)-1]]
+	n, err := strconv.ParseUint(value[:len(value)-1], 10, 64)
+	if !ok || err != nil {
+		return 0, fmt.Errorf("invalid grpc-timeout %q", value)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// decodeRequest decodes the request message of an RPC
+func decodeRequest(request []byte, m message) error {
+	if err := m.unmarshal(request); err != nil {
+		return statusErrorf(codeInvalidArgument, "invalid request message: %v", err)
+	}
+	return nil
+}
+
+// run runs a command like a WebSocket client sending it would
+func (s *Server) run(ctx context.Context, command models.APICommand, args map[string]interface{}) (interface{}, error) {
+	return s.server.HandleCommand(ctx, models.CommandMessage{
+		MessageID: models.GenerateMessageID(),
+		Command:   string(command),
+		Args:      args,
+	})
+}
+
+// runJSON runs a command and returns its result as JSON
+func (s *Server) runJSON(ctx context.Context, command models.APICommand, args map[string]interface{}) (*CommandResponse, error) {
+	result, err := s.run(ctx, command, args)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode result: %w", err)
+	}
+	return &CommandResponse{Result: string(data)}, nil
+}
+
+// parseJSONArg decodes an argument given as JSON, leaving it out if empty
+func parseJSONArg(args map[string]interface{}, name, value string) error {
+	if value == "" {
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(value), &v); err != nil {
+		return statusErrorf(codeInvalidArgument, "invalid JSON in %s: %v", name, err)
+	}
+	args[name] = v
+	return nil
+}
+
+func (s *Server) getNodes(ctx context.Context, request []byte) (message, error) {
+	var req GetNodesRequest
+	if err := decodeRequest(request, &req); err != nil {
+		return nil, err
+	}
+	args := map[string]interface{}{}
+	if req.Available != nil {
+		args["available"] = *req.Available
+	}
+
+	result, err := s.run(ctx, models.APICommandGetNodes, args)
+	if err != nil {
+		return nil, err
+	}
+	var nodes []models.MatterNodeData
+	if err := remarshal(result, &nodes); err != nil {
+		return nil, err
+	}
+	response := &GetNodesResponse{Nodes: make([]*Node, 0, len(nodes))}
+	for i := range nodes {
+		response.Nodes = append(response.Nodes, newNode(&nodes[i]))
+	}
+	return response, nil
+}
+
+func (s *Server) getNode(ctx context.Context, request []byte) (message, error) {
+	var req GetNodeRequest
+	if err := decodeRequest(request, &req); err != nil {
+		return nil, err
+	}
+	return s.runNode(ctx, models.APICommandGetNode, map[string]interface{}{"node_id": req.NodeID})
+}
+
+func (s *Server) commission(ctx context.Context, request []byte) (message, error) {
+	var req CommissionRequest
+	if err := decodeRequest(request, &req); err != nil {
+		return nil, err
+	}
+	args := map[string]interface{}{
+		"code":         req.Code,
+		"network_only": req.NetworkOnly,
+	}
+	if req.FabricID != 0 {
+		args["fabric_id"] = req.FabricID
+	}
+	return s.runNode(ctx, models.APICommandCommissionWithCode, args)
+}
+
+func (s *Server) deviceCommand(ctx context.Context, request []byte) (message, error) {
+	var req DeviceCommandRequest
+	if err := decodeRequest(request, &req); err != nil {
+		return nil, err
+	}
+	args := map[string]interface{}{
+		"node_id":      req.NodeID,
+		"endpoint_id":  int64(req.EndpointID),
+		"cluster_id":   req.Cluster,
+		"command_name": req.CommandName,
+	}
+	if err := parseJSONArg(args, "payload", req.Payload); err != nil {
+		return nil, err
+	}
+	if req.TimedRequestTimeoutMs != 0 {
+		args["timed_request_timeout_ms"] = int64(req.TimedRequestTimeoutMs)
+	}
+	return s.runJSON(ctx, models.APICommandDeviceCommand, args)
+}
+
+func (s *Server) writeAttribute(ctx context.Context, request []byte) (message, error) {
+	var req WriteAttributeRequest
+	if err := decodeRequest(request, &req); err != nil {
+		return nil, err
+	}
+	args := map[string]interface{}{
+		"node_id":        req.NodeID,
+		"attribute_path": req.AttributePath,
+	}
+	if err := parseJSONArg(args, "value", req.Value); err != nil {
+		return nil, err
+	}
+	if req.TimedRequestTimeoutMs != 0 {
+		args["timed_request_timeout_ms"] = int64(req.TimedRequestTimeoutMs)
+	}
+	return s.runJSON(ctx, models.APICommandWriteAttribute, args)
+}
+
+func (s *Server) command(ctx context.Context, request []byte) (message, error) {
+	var req CommandRequest
+	if err := decodeRequest(request, &req); err != nil {
+		return nil, err
+	}
+	args := map[string]interface{}{}
+	if req.Args != "" {
+		if err := json.Unmarshal([]byte(req.Args), &args); err != nil {
+			return nil, statusErrorf(codeInvalidArgument, "invalid JSON in args: %v", err)
+		}
+	}
+	return s.runJSON(ctx, models.APICommand(req.Command), args)
+}
+
+// runNode runs a command resulting in a node
+func (s *Server) runNode(ctx context.Context, command models.APICommand, args map[string]interface{}) (message, error) {
+	result, err := s.run(ctx, command, args)
+	if err != nil {
+		return nil, err
+	}
+	var node models.MatterNodeData
+	if err := remarshal(result, &node); err != nil {
+		return nil, err
+	}
+	return newNode(&node), nil
+}
+
+// events streams the events passing the filter of the request until the
+// call is cancelled, the server shuts down or the client falls behind
+func (s *Server) events(ctx context.Context, w http.ResponseWriter, request []byte) error {
+	var req EventsRequest
+	if err := decodeRequest(request, &req); err != nil {
+		return err
+	}
+	matches, err := websocket.NewEventFilter(req.filterArgs())
+	if err != nil {
+		return statusErrorf(codeInvalidArgument, "%v", err)
+	}
+
+	events := make(chan *Event, eventBuffer)
+	overflow := make(chan struct{})
+	var overflowOnce sync.Once
+	unsubscribe := s.server.Subscribe(func(eventType models.EventType, data interface{}) {
+		if !matches(eventType, data) {
+			return
+		}
+		payload, err := json.Marshal(data)
+		if err != nil {
+			return
+		}
+		select {
+		case events <- &Event{Event: string(eventType), Data: string(payload)}:
+		default:
+			overflowOnce.Do(func() { close(overflow) })
+		}
+	})
+	defer unsubscribe()
+
+	// Send the headers, so the client knows the stream is established
+	w.WriteHeader(http.StatusOK)
+	if err := http.NewResponseController(w).Flush(); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case event := <-events:
+			if err := writeMessage(w, event); err != nil {
+				return err
+			}
+		case <-overflow:
+			return statusErrorf(codeResourceExhausted, "client fell behind by more than %d events", eventBuffer)
+		case <-s.done:
+			return statusErrorf(codeUnavailable, "server is shutting down")
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// filterArgs returns the request as start_listening arguments
+func (m *EventsRequest) filterArgs() map[string]interface{} {
+	args := map[string]interface{}{}
+	if len(m.Events) > 0 {
+		events := make([]interface{}, len(m.Events))
+		for i, event := range m.Events {
+			events[i] = event
+		}
+		args["events"] = events
+	}
+	if len(m.NodeIDs) > 0 {
+		nodeIDs := make([]interface{}, len(m.NodeIDs))
+		for i, id := range m.NodeIDs {
+			nodeIDs[i] = float64(id)
+		}
+		args["node_ids"] = nodeIDs
+	}
+	if len(m.AttributePaths) > 0 {
+		paths := make([]interface{}, len(m.AttributePaths))
+		for i, path := range m.AttributePaths {
+			paths[i] = path
+		}
+		args["attribute_paths"] = paths
+	}
+	return args
+}
+
+// newNode converts a node to its message, encoding the attribute values as
+// JSON
+func newNode(node *models.MatterNodeData) *Node {
+	m := &Node{
+		NodeID:           int64(node.NodeID),
+		InterviewVersion: int32(node.InterviewVersion),
+		Available:        node.Available,
+		IsBridge:         node.IsBridge,
+		Attributes:       make(map[string]string, len(node.Attributes)),
+		FabricID:         int64(node.FabricID),
+	}
+	if node.Metadata != nil {
+		m.Name = node.Metadata.Name
+		m.Room = node.Metadata.Room
+		m.Tags = node.Metadata.Tags
+	}
+	if !node.DateCommissioned.IsZero() {
+		m.DateCommissioned = node.DateCommissioned.Format(time.RFC3339Nano)
+	}
+	if !node.LastInterview.IsZero() {
+		m.LastInterview = node.LastInterview.Format(time.RFC3339Nano)
+	}
+	for path, value := range node.Attributes {
+		data, err := json.Marshal(value)
+		if err != nil {
+			continue
+		}
+		m.Attributes[path] = string(data)
+	}
+	return m
+}
+
+// remarshal converts a command result to the type the RPC returns, as the
+// result of some commands depends on their arguments
+func remarshal(result interface{}, v interface{}) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return errors.New("unexpected command result")
+	}
+	return nil
+}
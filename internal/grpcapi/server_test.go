@@ -0,0 +1,357 @@
+package grpcapi
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/audit"
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// grpcTestServer has an available light as node 5
+type grpcTestServer struct {
+	mu        sync.Mutex
+	callbacks []models.EventCallback
+	commands  chan models.CommandMessage
+}
+
+func (s *grpcTestServer) HandleCommand(ctx context.Context, cmd models.CommandMessage) (interface{}, error) {
+	s.commands <- cmd
+	switch models.APICommand(cmd.Command) {
+	case models.APICommandGetNodes:
+		return []*models.MatterNodeData{{
+			NodeID:           5,
+			DateCommissioned: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+			Available:        true,
+			Attributes:       map[string]interface{}{"1/6/0": true, "0/40/3": "Light"},
+		}}, nil
+	case models.APICommandGetNode:
+		return nil, &models.NodeNotFoundError{NodeID: 7}
+	case models.APICommandDeviceCommand:
+		return nil, nil
+	case models.APICommandPingNode:
+		return map[string]bool{"fd00::5": true}, nil
+	case models.APICommandWriteAttribute:
+		return nil, &models.ArgumentError{Field: "attribute_path", Reason: "invalid path"}
+	case models.APICommandSetLogLevel:
+		if audit.ClientFromContext(ctx).BearerToken != "0123456789abcdef" {
+			return nil, fmt.Errorf("%w: set_log_level", models.ErrUnauthorized)
+		}
+		return nil, nil
+	}
+	return nil, models.ErrUnknownCommand
+}
+
+func (s *grpcTestServer) Subscribe(callback models.EventCallback) func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.callbacks = append(s.callbacks, callback)
+	return func() {}
+}
+
+func (s *grpcTestServer) emit(eventType models.EventType, data interface{}) {
+	s.mu.Lock()
+	callbacks := s.callbacks
+	s.mu.Unlock()
+	for _, callback := range callbacks {
+		callback(eventType, data)
+	}
+}
+
+func (s *grpcTestServer) subscribers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.callbacks)
+}
+
+// grpcTestClient calls the server over unencrypted HTTP/2
+type grpcTestClient struct {
+	t       *testing.T
+	address string
+	client  *http.Client
+
+	// Bearer token sent as authorization metadata, if set
+	token string
+}
+
+func startTestServer(t *testing.T) (*Server, *grpcTestServer, *grpcTestClient) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	backend := &grpcTestServer{commands: make(chan models.CommandMessage, 10)}
+	server := NewServer(backend, logger.NewConsoleLogger(logger.FatalLevel))
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Shutdown(context.Background()) })
+
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: protocols}}
+	return server, backend, &grpcTestClient{t: t, address: listener.Addr().String(), client: client}
+}
+
+// start starts a call and returns its response, whose body holds the
+// response messages
+func (c *grpcTestClient) start(ctx context.Context, method string, req message) *http.Response {
+	c.t.Helper()
+	data := req.marshal()
+	frame := make([]byte, 5, 5+len(data))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(data)))
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		"http://"+c.address+"/"+ServiceName+"/"+method, bytes.NewReader(append(frame, data...)))
+	if err != nil {
+		c.t.Fatalf("Failed to create request: %v", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/grpc")
+	httpReq.Header.Set("TE", "trailers")
+	if c.token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		c.t.Fatalf("%s failed: %v", method, err)
+	}
+	if resp.ProtoMajor != 2 || resp.StatusCode != http.StatusOK {
+		c.t.Fatalf("%s: expected HTTP/2 200, got %s %s", method, resp.Proto, resp.Status)
+	}
+	return resp
+}
+
+// call runs a unary call, decoding the response into resp. It returns the
+// status code and message of the call.
+func (c *grpcTestClient) call(method string, req, resp message) (code, string) {
+	c.t.Helper()
+	httpResp := c.start(context.Background(), method, req)
+	defer httpResp.Body.Close()
+
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		c.t.Fatalf("%s: failed to read response: %v", method, err)
+	}
+	if len(body) > 0 {
+		if len(body) < 5 || int(binary.BigEndian.Uint32(body[1:5])) != len(body)-5 {
+			c.t.Fatalf("%s: expected a single response message, got % x", method, body)
+		}
+		if err := resp.unmarshal(body[5:]); err != nil {
+			c.t.Fatalf("%s: invalid response message: %v", method, err)
+		}
+	}
+	return trailerStatus(c.t, httpResp)
+}
+
+func trailerStatus(t *testing.T, resp *http.Response) (code, string) {
+	t.Helper()
+	status, err := strconv.Atoi(resp.Trailer.Get("Grpc-Status"))
+	if err != nil {
+		t.Fatalf("Missing grpc-status trailer, got %v", resp.Trailer)
+	}
+	return code(status), resp.Trailer.Get("Grpc-Message")
+}
+
+func TestUnaryMethods(t *testing.T) {
+	_, backend, client := startTestServer(t)
+
+	available := true
+	var nodes GetNodesResponse
+	if status, msg := client.call("GetNodes", &GetNodesRequest{Available: &available}, &nodes); status != codeOK {
+		t.Fatalf("GetNodes failed: %d %s", status, msg)
+	}
+	if cmd := <-backend.commands; cmd.Command != "get_nodes" || cmd.Args["available"] != true {
+		t.Errorf("Unexpected command %+v", cmd)
+	}
+	if len(nodes.Nodes) != 1 {
+		t.Fatalf("Expected one node, got %d", len(nodes.Nodes))
+	}
+	node := nodes.Nodes[0]
+	if node.NodeID != 5 || !node.Available || node.DateCommissioned != "2024-03-01T12:00:00Z" || node.LastInterview != "" ||
+		node.Attributes["1/6/0"] != "true" || node.Attributes["0/40/3"] != `"Light"` {
+		t.Errorf("Unexpected node %+v", node)
+	}
+
+	var response CommandResponse
+	if status, msg := client.call("DeviceCommand", &DeviceCommandRequest{
+		NodeID: 5, EndpointID: 1, Cluster: "OnOff", CommandName: "MoveToLevel",
+		Payload: `{"level": 10}`, TimedRequestTimeoutMs: 500,
+	}, &response); status != codeOK {
+		t.Fatalf("DeviceCommand failed: %d %s", status, msg)
+	}
+	cmd := <-backend.commands
+	payload, _ := cmd.Args["payload"].(map[string]interface{})
+	if cmd.Command != "device_command" || cmd.Args["node_id"] != int64(5) || cmd.Args["endpoint_id"] != int64(1) ||
+		cmd.Args["cluster_id"] != "OnOff" || cmd.Args["command_name"] != "MoveToLevel" ||
+		payload["level"] != float64(10) || cmd.Args["timed_request_timeout_ms"] != int64(500) {
+		t.Errorf("Unexpected command %+v", cmd)
+	}
+	if response.Result != "null" {
+		t.Errorf("Expected result null, got %q", response.Result)
+	}
+
+	if status, msg := client.call("Command", &CommandRequest{Command: "ping_node", Args: `{"node_id": 5}`}, &response); status != codeOK {
+		t.Fatalf("Command failed: %d %s", status, msg)
+	}
+	if cmd := <-backend.commands; cmd.Command != "ping_node" || cmd.Args["node_id"] != float64(5) {
+		t.Errorf("Unexpected command %+v", cmd)
+	}
+	if response.Result != `{"fd00::5":true}` {
+		t.Errorf("Unexpected result %q", response.Result)
+	}
+
+	// Errors of the command handler map to status codes
+	errorCalls := []struct {
+		method string
+		req    message
+		code   code
+		msg    string
+	}{
+		{"GetNode", &GetNodeRequest{NodeID: 7}, codeNotFound, "node 7 not found"},
+		{"WriteAttribute", &WriteAttributeRequest{NodeID: 5, AttributePath: "x", Value: "1"}, codeInvalidArgument,
+			"invalid argument attribute_path: invalid path"},
+		{"Commission", &CommissionRequest{Code: "MT:Y.K9042C00KA0648G00"}, codeUnimplemented, "unknown command"},
+		{"Command", &CommandRequest{Command: "ping_node", Args: "{"}, codeInvalidArgument,
+			"invalid JSON in args: unexpected end of JSON input"},
+		{"Reboot", &CommandRequest{}, codeUnimplemented, "unknown method Reboot"},
+	}
+	for _, tt := range errorCalls {
+		status, msg := client.call(tt.method, tt.req, &response)
+		if status != tt.code || msg != tt.msg {
+			t.Errorf("%s: expected %d %q, got %d %q", tt.method, tt.code, tt.msg, status, msg)
+		}
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	_, backend, client := startTestServer(t)
+
+	var response CommandResponse
+	req := &CommandRequest{Command: "set_log_level", Args: `{"level": "debug"}`}
+	if status, msg := client.call("Command", req, &response); status != codeUnauthenticated {
+		t.Errorf("Expected status %d without a token, got %d %s", codeUnauthenticated, status, msg)
+	}
+	<-backend.commands
+
+	client.token = "0123456789abcdef"
+	if status, msg := client.call("Command", req, &response); status != codeOK {
+		t.Errorf("Expected the token to authorize set_log_level, got %d %s", status, msg)
+	}
+	<-backend.commands
+}
+
+func TestEventsStream(t *testing.T) {
+	server, backend, client := startTestServer(t)
+
+	resp := client.start(context.Background(), "Events", &EventsRequest{
+		Events:  []string{"attribute_updated", "server_shutdown"},
+		NodeIDs: []int64{5},
+	})
+	defer resp.Body.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for backend.subscribers() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Events didn't subscribe")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	backend.emit(models.EventTypeNodeUpdated, &models.MatterNodeData{NodeID: 5})
+	backend.emit(models.EventTypeAttributeUpdated, []interface{}{6, "1/6/0", true})
+	backend.emit(models.EventTypeAttributeUpdated, []interface{}{5, "1/6/0", false})
+	backend.emit(models.EventTypeServerShutdown, nil)
+
+	reader := bufio.NewReader(resp.Body)
+	var received []string
+	for i := 0; i < 2; i++ {
+		var prefix [5]byte
+		if _, err := io.ReadFull(reader, prefix[:]); err != nil {
+			t.Fatalf("Failed to read event: %v", err)
+		}
+		data := make([]byte, binary.BigEndian.Uint32(prefix[1:]))
+		if _, err := io.ReadFull(reader, data); err != nil {
+			t.Fatalf("Failed to read event: %v", err)
+		}
+		var event Event
+		if err := event.unmarshal(data); err != nil {
+			t.Fatalf("Invalid event: %v", err)
+		}
+		received = append(received, event.Event+" "+event.Data)
+	}
+	if !slices.Equal(received, []string{`attribute_updated [5,"1/6/0",false]`, "server_shutdown null"}) {
+		t.Errorf("Unexpected events %q", received)
+	}
+
+	// Shutting down ends the stream
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if rest, err := io.ReadAll(reader); err != nil || len(rest) != 0 {
+		t.Fatalf("Expected the stream to end, got % x, %v", rest, err)
+	}
+	if status, msg := trailerStatus(t, resp); status != codeUnavailable {
+		t.Errorf("Expected UNAVAILABLE, got %d %s", status, msg)
+	}
+}
+
+func TestParseTimeout(t *testing.T) {
+	valid := map[string]time.Duration{
+		"100m": 100 * time.Millisecond,
+		"5S":   5 * time.Second,
+		"1H":   time.Hour,
+		"250u": 250 * time.Microsecond,
+	}
+	for value, expected := range valid {
+		if d, err := parseTimeout(value); err != nil || d != expected {
+			t.Errorf("parseTimeout(%q) = %v, %v, expected %v", value, d, err, expected)
+		}
+	}
+	for _, value := range []string{"", "m", "10", "10s", "123456789S", "-1S"} {
+		if _, err := parseTimeout(value); err == nil {
+			t.Errorf("parseTimeout(%q): expected an error", value)
+		}
+	}
+}
+
+func TestEncodeStatusMessage(t *testing.T) {
+	if got := encodeStatusMessage("node 5: 100% off\nline"); got != "node 5: 100%25 off%0Aline" {
+		t.Errorf("Unexpected encoding %q", got)
+	}
+}
+
+// TestProtoMethods keeps the RPCs of matter_server.proto and the handlers
+// in sync
+func TestProtoMethods(t *testing.T) {
+	proto, err := os.ReadFile("matter_server.proto")
+	if err != nil {
+		t.Fatalf("Failed to read proto: %v", err)
+	}
+	var declared []string
+	for _, match := range regexp.MustCompile(`rpc (\w+)\(`).FindAllSubmatch(proto, -1) {
+		declared = append(declared, string(match[1]))
+	}
+
+	served := []string{"Events"}
+	for name := range unaryMethods {
+		served = append(served, name)
+	}
+	slices.Sort(declared)
+	slices.Sort(served)
+	if !slices.Equal(declared, served) {
+		t.Errorf("The proto declares %v, the server serves %v", declared, served)
+	}
+}
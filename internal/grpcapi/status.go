@@ -0,0 +1,84 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// code is a gRPC status code
+type code int
+
+const (
+	codeOK                code = 0
+	codeCancelled         code = 1
+	codeUnknown           code = 2
+	codeInvalidArgument   code = 3
+	codeDeadlineExceeded  code = 4
+	codeNotFound          code = 5
+	codeResourceExhausted code = 8
+	codeUnimplemented     code = 12
+	codeInternal          code = 13
+	codeUnavailable       code = 14
+	codeUnauthenticated   code = 16
+)
+
+// statusError is an error with the gRPC status it is sent as
+type statusError struct {
+	code    code
+	message string
+}
+
+func (e *statusError) Error() string {
+	return e.message
+}
+
+func statusErrorf(c code, format string, args ...interface{}) error {
+	return &statusError{code: c, message: fmt.Sprintf(format, args...)}
+}
+
+// statusOf maps the errors of command handlers to gRPC status codes
+func statusOf(err error) (code, string) {
+	var status *statusError
+	var argErr *models.ArgumentError
+	var notFound *models.NodeNotFoundError
+	switch {
+	case err == nil:
+		return codeOK, ""
+	case errors.As(err, &status):
+		return status.code, status.message
+	case errors.As(err, &argErr):
+		return codeInvalidArgument, err.Error()
+	case errors.As(err, &notFound):
+		return codeNotFound, err.Error()
+	case errors.Is(err, models.ErrUnknownCommand):
+		return codeUnimplemented, err.Error()
+	case errors.Is(err, models.ErrUnauthorized):
+		return codeUnauthenticated, err.Error()
+	case errors.Is(err, models.ErrStandby), errors.Is(err, models.ErrShuttingDown):
+		return codeUnavailable, err.Error()
+	case errors.Is(err, models.ErrCommandTimeout), errors.Is(err, context.DeadlineExceeded):
+		return codeDeadlineExceeded, err.Error()
+	case errors.Is(err, context.Canceled):
+		return codeCancelled, err.Error()
+	}
+	return codeUnknown, err.Error()
+}
+
+// encodeStatusMessage percent-encodes the grpc-message trailer, which may
+// only hold printable ASCII
+func encodeStatusMessage(message string) string {
+	var b strings.Builder
+	for i := 0; i < len(message); i++ {
+		c := message[i]
+		if c < ' ' || c > '~' || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
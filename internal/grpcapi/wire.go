@@ -0,0 +1,236 @@
+package grpcapi
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// Wire types of the protobuf encoding. Groups (3 and 4) are deprecated and
+// not supported.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errMalformed = errors.New("malformed protobuf message")
+
+// encoder appends protobuf fields to a message. Like proto3, fields holding
+// their zero value are left out.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) tag(number, wireType int) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(number)<<3|uint64(wireType))
+}
+
+func (e *encoder) uint64(number int, v uint64) {
+	if v == 0 {
+		return
+	}
+	e.tag(number, wireVarint)
+	e.buf = binary.AppendUvarint(e.buf, v)
+}
+
+// int64 encodes int32 and int64 fields, negative values taking ten bytes
+func (e *encoder) int64(number int, v int64) {
+	e.uint64(number, uint64(v))
+}
+
+func (e *encoder) bool(number int, v bool) {
+	if v {
+		e.uint64(number, 1)
+	}
+}
+
+// optionalBool encodes a proto3 optional field, which is sent when set even
+// if false
+func (e *encoder) optionalBool(number int, v *bool) {
+	if v == nil {
+		return
+	}
+	e.tag(number, wireVarint)
+	if *v {
+		e.buf = append(e.buf, 1)
+	} else {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *encoder) string(number int, v string) {
+	if v == "" {
+		return
+	}
+	e.bytes(number, []byte(v))
+}
+
+// bytes encodes a length-delimited field, even if empty, as repeated fields
+// and embedded messages need
+func (e *encoder) bytes(number int, v []byte) {
+	e.tag(number, wireBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+// int64s encodes a repeated integer field packed, the proto3 default
+func (e *encoder) int64s(number int, v []int64) {
+	if len(v) == 0 {
+		return
+	}
+	var packed []byte
+	for _, n := range v {
+		packed = binary.AppendUvarint(packed, uint64(n))
+	}
+	e.bytes(number, packed)
+}
+
+// stringMap encodes a map<string, string> field as entries with the key as
+// field 1 and the value as field 2
+func (e *encoder) stringMap(number int, m map[string]string, keys []string) {
+	for _, key := range keys {
+		var entry encoder
+		entry.string(1, key)
+		entry.string(2, m[key])
+		e.bytes(number, entry.buf)
+	}
+}
+
+// field is a decoded protobuf field
+type field struct {
+	number   int
+	wireType int
+	varint   uint64
+	data     []byte
+}
+
+// decodeFields calls fn for the fields of a message in order. Callers skip
+// the fields they don't know, as protobuf requires.
+func decodeFields(buf []byte, fn func(f field) error) error {
+	for len(buf) > 0 {
+		key, n := binary.Uvarint(buf)
+		if n <= 0 || key>>3 == 0 || key>>3 > 1<<29-1 {
+			return errMalformed
+		}
+		buf = buf[n:]
+
+		f := field{number: int(key >> 3), wireType: int(key & 7)}
+		switch f.wireType {
+		case wireVarint:
+			f.varint, n = binary.Uvarint(buf)
+			if n <= 0 {
+				return errMalformed
+			}
+		case wireFixed64, wireFixed32:
+			n = 8
+			if f.wireType == wireFixed32 {
+				n = 4
+			}
+			if len(buf) < n {
+				return errMalformed
+			}
+			f.data = buf[:n]
+		case wireBytes:
+			length, m := binary.Uvarint(buf)
+			if m <= 0 || length > uint64(len(buf)-m) {
+				return errMalformed
+			}
+			f.data = buf[m : m+int(length)]
+			n = m + int(length)
+		default:
+			return fmt.Errorf("unsupported protobuf wire type %d", f.wireType)
+		}
+		buf = buf[n:]
+
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f field) wrongType() error {
+	return fmt.Errorf("protobuf field %d has wrong wire type %d", f.number, f.wireType)
+}
+
+func (f field) uint64() (uint64, error) {
+	if f.wireType != wireVarint {
+		return 0, f.wrongType()
+	}
+	return f.varint, nil
+}
+
+func (f field) int64() (int64, error) {
+	v, err := f.uint64()
+	return int64(v), err
+}
+
+func (f field) bool() (bool, error) {
+	v, err := f.uint64()
+	return v != 0, err
+}
+
+// bytes returns the content of a length-delimited field. It aliases the
+// decoded message.
+func (f field) bytes() ([]byte, error) {
+	if f.wireType != wireBytes {
+		return nil, f.wrongType()
+	}
+	return f.data, nil
+}
+
+func (f field) string() (string, error) {
+	b, err := f.bytes()
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(b) {
+		return "", fmt.Errorf("protobuf field %d is not valid UTF-8", f.number)
+	}
+	return string(b), nil
+}
+
+// int64s returns the values of a repeated integer field, which may be
+// packed or not
+func (f field) int64s() ([]int64, error) {
+	if f.wireType == wireVarint {
+		return []int64{int64(f.varint)}, nil
+	}
+	packed, err := f.bytes()
+	if err != nil {
+		return nil, err
+	}
+	var values []int64
+	for len(packed) > 0 {
+		v, n := binary.Uvarint(packed)
+		if n <= 0 {
+			return nil, errMalformed
+		}
+		values = append(values, int64(v))
+		packed = packed[n:]
+	}
+	return values, nil
+}
+
+// stringMapEntry decodes an entry of a map<string, string> field
+func (f field) stringMapEntry() (string, string, error) {
+	entry, err := f.bytes()
+	if err != nil {
+		return "", "", err
+	}
+	var key, value string
+	err = decodeFields(entry, func(f field) error {
+		var err error
+		switch f.number {
+		case 1:
+			key, err = f.string()
+		case 2:
+			value, err = f.string()
+		}
+		return err
+	})
+	return key, value, err
+}
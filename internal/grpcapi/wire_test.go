@@ -0,0 +1,90 @@
+package grpcapi
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestNodeEncoding(t *testing.T) {
+	node := &Node{NodeID: 5, Available: true, Attributes: map[string]string{"1/6/0": "true"}, Room: "Hall", Tags: []string{"a", "b"}}
+
+	// As encoded by protoc generated code
+	expected := []byte{
+		0x08, 0x05, // node_id
+		0x28, 0x01, // available
+		0x3a, 0x0d, 0x0a, 0x05, '1', '/', '6', '/', '0', 0x12, 0x04, 't', 'r', 'u', 'e', // attributes
+		0x52, 0x04, 'H', 'a', 'l', 'l', // room
+		0x5a, 0x01, 'a', 0x5a, 0x01, 'b', // tags
+	}
+	encoded := node.marshal()
+	if !bytes.Equal(encoded, expected) {
+		t.Fatalf("Expected % x, got % x", expected, encoded)
+	}
+
+	var decoded Node
+	if err := decoded.unmarshal(encoded); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(&decoded, node) {
+		t.Errorf("Expected %+v, got %+v", node, decoded)
+	}
+}
+
+func TestEventsRequestEncoding(t *testing.T) {
+	req := &EventsRequest{Events: []string{"node_added"}, NodeIDs: []int64{1, 300}}
+	expected := []byte{
+		0x0a, 0x0a, 'n', 'o', 'd', 'e', '_', 'a', 'd', 'd', 'e', 'd',
+		0x12, 0x03, 0x01, 0xac, 0x02, // packed node_ids
+	}
+	if encoded := req.marshal(); !bytes.Equal(encoded, expected) {
+		t.Fatalf("Expected % x, got % x", expected, encoded)
+	}
+
+	// Repeated integers may also be sent unpacked
+	var decoded EventsRequest
+	if err := decoded.unmarshal([]byte{0x10, 0x07, 0x10, 0x08}); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(decoded.NodeIDs, []int64{7, 8}) {
+		t.Errorf("Expected node IDs [7 8], got %v", decoded.NodeIDs)
+	}
+}
+
+func TestDecodeFields(t *testing.T) {
+	// Unknown fields of every wire type are skipped
+	var req GetNodeRequest
+	unknown := []byte{
+		0x10, 0x01, // varint field 2
+		0x19, 1, 2, 3, 4, 5, 6, 7, 8, // fixed64 field 3
+		0x22, 0x01, 'x', // bytes field 4
+		0x2d, 1, 2, 3, 4, // fixed32 field 5
+		0x08, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01, // node_id -1
+	}
+	if err := req.unmarshal(unknown); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if req.NodeID != -1 {
+		t.Errorf("Expected node ID -1, got %d", req.NodeID)
+	}
+
+	var getNodes GetNodesRequest
+	if err := getNodes.unmarshal([]byte{0x08, 0x00}); err != nil || getNodes.Available == nil || *getNodes.Available {
+		t.Errorf("Expected available to be set to false, got %v, %v", getNodes.Available, err)
+	}
+
+	malformed := map[string][]byte{
+		"truncated varint": {0x08, 0x80},
+		"truncated bytes":  {0x0a, 0x05, 'a'},
+		"field zero":       {0x00, 0x01},
+		"group":            {0x0b},
+		"wrong wire type":  {0x0d, 1, 2, 3, 4},
+		"invalid UTF-8":    {0x0a, 0x01, 0xff},
+	}
+	for name, data := range malformed {
+		var cmd CommandRequest
+		if err := cmd.unmarshal(data); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+// Package icd handles intermittently connected devices (ICDs): it reads their
+// ICD Management cluster and keeps the Check-In registrations of the server
+// with them, persisted in the server storage directory.
+package icd
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/clusters"
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/storage"
+)
+
+// ICD Management attribute IDs, on the root endpoint
+const (
+	AttributeIdleModeDuration    = 0x0000
+	AttributeActiveModeDuration  = 0x0001
+	AttributeActiveModeThreshold = 0x0002
+	AttributeOperatingMode       = 0x0008
+	attributeFeatureMap          = 0xFFFC
+
+	featureCheckInProtocol = 1 << 0
+
+	operatingModeLIT = 1
+)
+
+// ICD Management command IDs
+const (
+	CommandRegisterClient = 0x00
+	CommandStayActive     = 0x03
+)
+
+// Operating modes reported in models.ICDInfo
+const (
+	ModeSIT = "sit"
+	ModeLIT = "lit"
+)
+
+const (
+	registrationsFile = "icd.json"
+
+	// KeyLength is the length of the symmetric Check-In key
+	KeyLength = 16
+)
+
+// ErrNotRegistered is returned for nodes the server isn't registered with
+var ErrNotRegistered = errors.New("not registered for check-ins")
+
+// Parse reads the ICD Management cluster of a node's root endpoint, nil for
+// nodes that aren't ICDs
+func Parse(attributes map[string]interface{}) *models.ICDInfo {
+	value := func(attribute uint32) (int, bool) {
+		v, ok := attributes[fmt.Sprintf("0/%d/%d", clusters.ICDManagementClusterID, attribute)]
+		if !ok {
+			return 0, false
+		}
+		switch n := v.(type) {
+		case float64:
+			return int(n), true
+		case int:
+			return n, true
+		case uint64:
+			return int(n), true
+		default:
+			return 0, false
+		}
+	}
+
+	idle, ok := value(AttributeIdleModeDuration)
+	if !ok {
+		return nil
+	}
+	info := &models.ICDInfo{OperatingMode: ModeSIT, IdleModeDuration: idle}
+	info.ActiveModeDuration, _ = value(AttributeActiveModeDuration)
+	info.ActiveModeThreshold, _ = value(AttributeActiveModeThreshold)
+	if mode, _ := value(AttributeOperatingMode); mode == operatingModeLIT {
+		info.OperatingMode = ModeLIT
+	}
+	if features, _ := value(attributeFeatureMap); features&featureCheckInProtocol != 0 {
+		info.CheckIn = true
+	}
+	return info
+}
+
+// Registration is the Check-In registration of the server with a node
+type Registration struct {
+	NodeID int `json:"node_id"`
+	// Node ID the check-ins are sent to, also the monitored subject
+	CheckInNodeID uint64 `json:"check_in_node_id"`
+	// Key the node encrypts its Check-In messages with
+	Key []byte `json:"key"`
+	// Counter of the last Check-In message, later messages must exceed it
+	Counter      uint32    `json:"counter"`
+	RegisteredAt time.Time `json:"registered_at"`
+}
+
+// Registry holds the Check-In registrations and persists them to disk
+type Registry struct {
+	path          string
+	logger        *logger.Logger
+	mu            sync.RWMutex
+	registrations map[int]*Registration
+
+	// Encrypts the persisted registrations, nil stores them unencrypted
+	cipher *storage.Cipher
+}
+
+// NewRegistry creates a registry storing its state in basePath
+func NewRegistry(basePath string, log *logger.Logger) *Registry {
+	return &Registry{
+		path:          filepath.Join(basePath, registrationsFile),
+		logger:        log,
+		registrations: make(map[int]*Registration),
+	}
+}
+
+// SetCipher encrypts the persisted registrations. Must be called before Load.
+func (r *Registry) SetCipher(c *storage.Cipher) {
+	r.cipher = c
+}
+
+// Load reads the persisted registrations. A missing file is not an error.
+func (r *Registry) Load() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, err := r.cipher.ReadFile(r.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read ICD registrations: %w", err)
+	}
+
+	var registrations []*Registration
+	if err := json.Unmarshal(data, &registrations); err != nil {
+		return fmt.Errorf("failed to parse ICD registrations: %w", err)
+	}
+
+	// Loading again replaces the registrations, e.g. with ones replicated
+	// from a primary server
+	r.registrations = make(map[int]*Registration, len(registrations))
+	for _, registration := range registrations {
+		r.registrations[registration.NodeID] = registration
+	}
+
+	r.logger.Info("Loaded ICD registrations", logger.Int("count", len(registrations)))
+	return nil
+}
+
+// NewKey generates a random Check-In key
+func NewKey() ([]byte, error) {
+	key := make([]byte, KeyLength)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate check-in key: %w", err)
+	}
+	return key, nil
+}
+
+// Add stores the registration with a node, replacing an earlier one
+func (r *Registry) Add(registration Registration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous, existed := r.registrations[registration.NodeID]
+	r.registrations[registration.NodeID] = &registration
+	if err := r.save(); err != nil {
+		if existed {
+			r.registrations[registration.NodeID] = previous
+		} else {
+			delete(r.registrations, registration.NodeID)
+		}
+		return err
+	}
+	return nil
+}
+
+// Get returns a copy of the registration with a node
+func (r *Registry) Get(nodeID int) (Registration, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	registration, exists := r.registrations[nodeID]
+	if !exists {
+		return Registration{}, ErrNotRegistered
+	}
+	c := *registration
+	c.Key = append([]byte(nil), registration.Key...)
+	return c, nil
+}
+
+// CheckIn records a Check-In message of a node. Messages with a counter not
+// beyond the last one are replays and rejected.
+func (r *Registry) CheckIn(nodeID int, counter uint32) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	registration, exists := r.registrations[nodeID]
+	if !exists {
+		return ErrNotRegistered
+	}
+	// The counter wraps around, later values are within half its range
+	if delta := counter - registration.Counter; delta == 0 || delta >= 1<<31 {
+		return fmt.Errorf("check-in counter %d of node %d is not beyond %d", counter, nodeID, registration.Counter)
+	}
+
+	previous := registration.Counter
+	registration.Counter = counter
+	if err := r.save(); err != nil {
+		registration.Counter = previous
+		return err
+	}
+	return nil
+}
+
+func (r *Registry) save() error {
+	registrations := make([]*Registration, 0, len(r.registrations))
+	for _, registration := range r.registrations {
+		registrations = append(registrations, registration)
+	}
+	sort.Slice(registrations, func(i, j int) bool {
+		return registrations[i].NodeID < registrations[j].NodeID
+	})
+
+	data, err := json.MarshalIndent(registrations, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal ICD registrations: %w", err)
+	}
+	if data, err = r.cipher.Seal(data); err != nil {
+		return fmt.Errorf("failed to encrypt ICD registrations: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	// The file holds the Check-In keys
+	tmpPath := r.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write ICD registrations: %w", err)
+	}
+	if err := os.Rename(tmpPath, r.path); err != nil {
+		return fmt.Errorf("failed to write ICD registrations: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package icd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+)
+
+func TestParse(t *testing.T) {
+	if info := Parse(map[string]interface{}{"1/6/0": true}); info != nil {
+		t.Errorf("Expected no ICD info, got %+v", info)
+	}
+
+	info := Parse(map[string]interface{}{
+		"0/70/0":     float64(3600),
+		"0/70/1":     float64(300),
+		"0/70/2":     float64(5000),
+		"0/70/8":     float64(1),
+		"0/70/65532": float64(0x0D),
+	})
+	if info == nil || info.OperatingMode != ModeLIT || info.IdleModeDuration != 3600 ||
+		info.ActiveModeDuration != 300 || info.ActiveModeThreshold != 5000 || !info.CheckIn {
+		t.Errorf("Expected a LIT device with check-ins, got %+v", info)
+	}
+
+	info = Parse(map[string]interface{}{"0/70/0": float64(1)})
+	if info == nil || info.OperatingMode != ModeSIT || info.CheckIn {
+		t.Errorf("Expected a SIT device, got %+v", info)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	dir := t.TempDir()
+	registry := NewRegistry(dir, logger.NewConsoleLogger(logger.FatalLevel))
+
+	key, err := NewKey()
+	if err != nil || len(key) != KeyLength {
+		t.Fatalf("Expected a %d byte key, got %x (%v)", KeyLength, key, err)
+	}
+	if err := registry.Add(Registration{NodeID: 5, CheckInNodeID: 0x1000, Key: key, Counter: 10}); err != nil {
+		t.Fatalf("Failed to add registration: %v", err)
+	}
+
+	if err := registry.CheckIn(5, 11); err != nil {
+		t.Errorf("Expected check-in to be accepted: %v", err)
+	}
+	// Replayed and older counters are rejected
+	for _, counter := range []uint32{11, 3} {
+		if err := registry.CheckIn(5, counter); err == nil {
+			t.Errorf("Expected check-in with counter %d to be rejected", counter)
+		}
+	}
+	if err := registry.CheckIn(6, 1); !errors.Is(err, ErrNotRegistered) {
+		t.Errorf("Expected ErrNotRegistered, got %v", err)
+	}
+
+	reloaded := NewRegistry(dir, logger.NewConsoleLogger(logger.FatalLevel))
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Failed to load registrations: %v", err)
+	}
+	registration, err := reloaded.Get(5)
+	if err != nil || registration.Counter != 11 || string(registration.Key) != string(key) {
+		t.Errorf("Expected the registration to be persisted, got %+v (%v)", registration, err)
+	}
+}
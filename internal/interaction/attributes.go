@@ -0,0 +1,76 @@
+package interaction
+
+import (
+	"fmt"
+
+	"github.com/codefionn/go-matter-server/internal/tlv"
+)
+
+// ReportDataMessage and AttributeReportIB tags
+const (
+	reportDataAttributeReports = 1
+	attributeReportData        = 1
+)
+
+// DecodeAttributeReports decodes the attribute reports of a
+// ReportDataMessage into values keyed by attribute path
+// ("endpoint/cluster/attribute"). Attribute status entries are skipped.
+// Omitted path fields are taken from the previous report (tag compression)
+// and list items reported with a null list index are appended to the list
+// reported before them.
+func DecodeAttributeReports(data []byte) (map[string]interface{}, error) {
+	msg, err := tlv.Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode report: %w", err)
+	}
+
+	reports, ok := msg.Field(reportDataAttributeReports)
+	if !ok {
+		return nil, nil
+	}
+
+	values := make(map[string]interface{}, len(reports.Elements))
+	var last AttributePath
+	for _, report := range reports.Elements {
+		attributeData, ok := report.Field(attributeReportData)
+		if !ok {
+			continue
+		}
+		path, ok := attributeData.Field(attributeDataPath)
+		if !ok {
+			return nil, fmt.Errorf("attribute data without path")
+		}
+		if endpoint, ok := path.Field(attributePathEndpoint); ok {
+			v, _ := endpoint.Uint()
+			last.Endpoint = uint16(v)
+		}
+		if cluster, ok := path.Field(attributePathCluster); ok {
+			v, _ := cluster.Uint()
+			last.Cluster = uint32(v)
+		}
+		attribute, ok := path.Field(attributePathAttribute)
+		if !ok {
+			return nil, fmt.Errorf("attribute data without attribute ID")
+		}
+		id, _ := attribute.Uint()
+		last.Attribute = uint32(id)
+
+		data, ok := attributeData.Field(attributeDataData)
+		if !ok {
+			return nil, fmt.Errorf("attribute %s without data", last)
+		}
+		key := last.String()
+
+		if index, ok := path.Field(attributePathListIndex); ok {
+			if index.Type != tlv.TypeNull {
+				return nil, fmt.Errorf("attribute %s with unsupported list index", key)
+			}
+			list, _ := values[key].([]interface{})
+			values[key] = append(list, Value(data))
+			continue
+		}
+		values[key] = Value(data)
+	}
+
+	return values, nil
+}
@@ -0,0 +1,60 @@
+package interaction
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeAttributeReports(t *testing.T) {
+	data := []byte{
+		0x15,       // ReportDataMessage
+		0x36, 0x01, // context 1: AttributeReports
+		// OnOff.OnOff on endpoint 1
+		0x15, 0x35, 0x01, // AttributeReportIB, context 1: AttributeDataIB
+		0x24, 0x00, 0x01, // data version 1
+		0x37, 0x01, 0x24, 0x02, 0x01, 0x24, 0x03, 0x06, 0x24, 0x04, 0x00, 0x18, // path 1/6/0
+		0x29, 0x02, // data: true
+		0x18, 0x18,
+		// OnOff.StartUpOnOff with endpoint and cluster compressed
+		0x15, 0x35, 0x01,
+		0x37, 0x01, 0x25, 0x04, 0x03, 0x40, 0x18, // path -/-/0x4003
+		0x24, 0x02, 0x01,
+		0x18, 0x18,
+		// Descriptor.PartsList followed by an appended item
+		0x15, 0x35, 0x01,
+		0x37, 0x01, 0x24, 0x02, 0x00, 0x24, 0x03, 0x1D, 0x24, 0x04, 0x03, 0x18,
+		0x36, 0x02, 0x04, 0x02, 0x18, // data: [2]
+		0x18, 0x18,
+		0x15, 0x35, 0x01,
+		0x37, 0x01, 0x24, 0x04, 0x03, 0x34, 0x05, 0x18, // list index null
+		0x24, 0x02, 0x03,
+		0x18, 0x18,
+		// Attribute status entry
+		0x15, 0x35, 0x00, 0x18, 0x18,
+		0x18, // end of AttributeReports
+		0x18,
+	}
+
+	values, err := DecodeAttributeReports(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	expected := map[string]interface{}{
+		"1/6/0":     true,
+		"1/6/16387": uint64(1),
+		"0/29/3":    []interface{}{uint64(2), uint64(3)},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+
+	values, err = DecodeAttributeReports([]byte{0x15, 0x18})
+	if err != nil || len(values) != 0 {
+		t.Errorf("Expected no values for report without attribute reports, got %v (%v)", values, err)
+	}
+
+	// Missing path
+	if _, err := DecodeAttributeReports([]byte{0x15, 0x36, 0x01, 0x15, 0x35, 0x01, 0x24, 0x02, 0x01, 0x18, 0x18, 0x18, 0x18}); err == nil {
+		t.Error("Expected error")
+	}
+}
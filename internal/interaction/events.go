@@ -0,0 +1,177 @@
+// Package interaction decodes and encodes Matter Interaction Model messages
+// (Matter Core Specification chapter 8).
+package interaction
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/tlv"
+)
+
+// ReportDataMessage tags
+const (
+	reportDataEventReports = 2
+)
+
+// EventReportIB tags
+const (
+	eventReportStatus = 0
+	eventReportData   = 1
+)
+
+// EventDataIB tags
+const (
+	eventDataPath                 = 0
+	eventDataEventNumber          = 1
+	eventDataPriority             = 2
+	eventDataEpochTimestamp       = 3
+	eventDataSystemTimestamp      = 4
+	eventDataDeltaEpochTimestamp  = 5
+	eventDataDeltaSystemTimestamp = 6
+	eventDataData                 = 7
+)
+
+// EventPathIB tags
+const (
+	eventPathEndpoint = 1
+	eventPathCluster  = 2
+	eventPathEvent    = 3
+)
+
+// Event timestamp types as reported in MatterNodeEvent.TimestampType
+const (
+	TimestampSystem = 0
+	TimestampEpoch  = 1
+)
+
+// DecodeEventReports decodes the event reports of a ReportDataMessage into
+// node events. Event status entries (errors for subscribed paths) are
+// skipped. Delta timestamps are resolved against the previous event in the
+// same report.
+func DecodeEventReports(nodeID int, data []byte) ([]models.MatterNodeEvent, error) {
+	msg, err := tlv.Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode report: %w", err)
+	}
+
+	reports, ok := msg.Field(reportDataEventReports)
+	if !ok {
+		return nil, nil
+	}
+
+	var (
+		events        []models.MatterNodeEvent
+		lastTimestamp int64
+		lastType      = -1
+	)
+	for _, report := range reports.Elements {
+		if _, isStatus := report.Field(eventReportStatus); isStatus {
+			continue
+		}
+		eventData, ok := report.Field(eventReportData)
+		if !ok {
+			return nil, fmt.Errorf("event report without data")
+		}
+
+		event, err := decodeEventData(nodeID, eventData)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case hasField(eventData, eventDataEpochTimestamp):
+			event.Timestamp, _ = fieldInt(eventData, eventDataEpochTimestamp)
+			event.TimestampType = TimestampEpoch
+		case hasField(eventData, eventDataSystemTimestamp):
+			event.Timestamp, _ = fieldInt(eventData, eventDataSystemTimestamp)
+			event.TimestampType = TimestampSystem
+		case hasField(eventData, eventDataDeltaEpochTimestamp) && lastType == TimestampEpoch:
+			delta, _ := fieldInt(eventData, eventDataDeltaEpochTimestamp)
+			event.Timestamp = lastTimestamp + delta
+			event.TimestampType = TimestampEpoch
+		case hasField(eventData, eventDataDeltaSystemTimestamp) && lastType == TimestampSystem:
+			delta, _ := fieldInt(eventData, eventDataDeltaSystemTimestamp)
+			event.Timestamp = lastTimestamp + delta
+			event.TimestampType = TimestampSystem
+		default:
+			return nil, fmt.Errorf("event %d without usable timestamp", event.EventNumber)
+		}
+		lastTimestamp, lastType = event.Timestamp, event.TimestampType
+
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+func decodeEventData(nodeID int, eventData tlv.Element) (models.MatterNodeEvent, error) {
+	path, ok := eventData.Field(eventDataPath)
+	if !ok {
+		return models.MatterNodeEvent{}, fmt.Errorf("event data without path")
+	}
+
+	event := models.MatterNodeEvent{NodeID: nodeID}
+
+	endpoint, ok1 := fieldInt(path, eventPathEndpoint)
+	cluster, ok2 := fieldInt(path, eventPathCluster)
+	eventID, ok3 := fieldInt(path, eventPathEvent)
+	number, ok4 := fieldInt(eventData, eventDataEventNumber)
+	priority, ok5 := fieldInt(eventData, eventDataPriority)
+	if !ok1 || !ok2 || !ok3 || !ok4 || !ok5 {
+		return models.MatterNodeEvent{}, fmt.Errorf("incomplete event data")
+	}
+	event.EndpointID = int(endpoint)
+	event.ClusterID = int(cluster)
+	event.EventID = int(eventID)
+	event.EventNumber = int(number)
+	event.Priority = int(priority)
+
+	if payload, ok := eventData.Field(eventDataData); ok {
+		if fields, ok := Value(payload).(map[string]interface{}); ok {
+			event.Data = fields
+		}
+	}
+
+	return event, nil
+}
+
+// Value converts a TLV element into the JSON representation used for
+// attribute and event values: structures become maps keyed by context tag,
+// arrays and lists become slices and byte strings are base64 encoded.
+func Value(e tlv.Element) interface{} {
+	switch e.Type {
+	case tlv.TypeStructure:
+		fields := make(map[string]interface{}, len(e.Elements))
+		for _, member := range e.Elements {
+			fields[strconv.FormatUint(member.Tag, 10)] = Value(member)
+		}
+		return fields
+	case tlv.TypeArray, tlv.TypeList:
+		items := make([]interface{}, 0, len(e.Elements))
+		for _, member := range e.Elements {
+			items = append(items, Value(member))
+		}
+		return items
+	case tlv.TypeByteString:
+		b, _ := e.Bytes()
+		return base64.StdEncoding.EncodeToString(b)
+	default:
+		return e.Value
+	}
+}
+
+func hasField(e tlv.Element, tag uint8) bool {
+	_, ok := e.Field(tag)
+	return ok
+}
+
+func fieldInt(e tlv.Element, tag uint8) (int64, bool) {
+	field, ok := e.Field(tag)
+	if !ok {
+		return 0, false
+	}
+	return field.Int()
+}
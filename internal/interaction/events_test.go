@@ -0,0 +1,116 @@
+package interaction
+
+import (
+	"testing"
+
+	"github.com/codefionn/go-matter-server/internal/tlv"
+)
+
+func TestDecodeEventReports(t *testing.T) {
+	data := []byte{
+		0x15,       // ReportDataMessage
+		0x36, 0x02, // context 2: EventReports
+		// Switch.InitialPress with epoch timestamp
+		0x15, 0x35, 0x01, // EventReportIB, context 1: EventDataIB
+		0x37, 0x00, 0x24, 0x01, 0x01, 0x24, 0x02, 0x3B, 0x24, 0x03, 0x01, 0x18, // path 1/0x3B/1
+		0x24, 0x01, 0x05, // event number 5
+		0x24, 0x02, 0x01, // priority info
+		0x26, 0x03, 0x10, 0x27, 0x00, 0x00, // epoch timestamp 10000
+		0x35, 0x07, 0x24, 0x00, 0x01, 0x18, // data: newPosition 1
+		0x18, 0x18,
+		// Switch.ShortRelease with delta epoch timestamp
+		0x15, 0x35, 0x01,
+		0x37, 0x00, 0x24, 0x01, 0x01, 0x24, 0x02, 0x3B, 0x24, 0x03, 0x03, 0x18,
+		0x24, 0x01, 0x06,
+		0x24, 0x02, 0x01,
+		0x24, 0x05, 0x64, // delta epoch timestamp 100
+		0x18, 0x18,
+		// Event status entry
+		0x15, 0x35, 0x00, 0x18, 0x18,
+		0x18, // end of EventReports
+		0x18,
+	}
+
+	events, err := DecodeEventReports(7, data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+
+	first := events[0]
+	if first.NodeID != 7 || first.EndpointID != 1 || first.ClusterID != 0x3B || first.EventID != 1 {
+		t.Errorf("Unexpected event path %+v", first)
+	}
+	if first.EventNumber != 5 || first.Priority != 1 {
+		t.Errorf("Unexpected event number/priority %+v", first)
+	}
+	if first.Timestamp != 10000 || first.TimestampType != TimestampEpoch {
+		t.Errorf("Expected epoch timestamp 10000, got %d (type %d)", first.Timestamp, first.TimestampType)
+	}
+	if first.Data["0"] != uint64(1) {
+		t.Errorf("Expected data field 0 = 1, got %v", first.Data)
+	}
+
+	second := events[1]
+	if second.EventID != 3 || second.Timestamp != 10100 || second.TimestampType != TimestampEpoch {
+		t.Errorf("Expected delta timestamp 10100, got %+v", second)
+	}
+	if second.Data != nil {
+		t.Errorf("Expected no data, got %v", second.Data)
+	}
+}
+
+func TestDecodeEventReportsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"Truncated", []byte{0x15, 0x36, 0x02, 0x15}},
+		{"Missing path", []byte{0x15, 0x36, 0x02, 0x15, 0x35, 0x01, 0x24, 0x01, 0x01, 0x18, 0x18, 0x18, 0x18}},
+		{"Delta without base", []byte{
+			0x15, 0x36, 0x02, 0x15, 0x35, 0x01,
+			0x37, 0x00, 0x24, 0x01, 0x01, 0x24, 0x02, 0x3B, 0x24, 0x03, 0x01, 0x18,
+			0x24, 0x01, 0x01, 0x24, 0x02, 0x01, 0x24, 0x06, 0x01,
+			0x18, 0x18, 0x18, 0x18,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeEventReports(1, tt.data); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+
+	events, err := DecodeEventReports(1, []byte{0x15, 0x18})
+	if err != nil || len(events) != 0 {
+		t.Errorf("Expected no events for report without event reports, got %v (%v)", events, err)
+	}
+}
+
+func TestValue(t *testing.T) {
+	e, err := tlv.Decode([]byte{
+		0x15,
+		0x30, 0x01, 0x02, 0xCA, 0xFE, // context 1: bytes
+		0x36, 0x02, 0x04, 0x01, 0x14, 0x18, // context 2: [1, null]
+		0x18,
+	})
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	fields, ok := Value(e).(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected map, got %T", Value(e))
+	}
+	if fields["1"] != "yv4=" {
+		t.Errorf("Expected base64 bytes, got %v", fields["1"])
+	}
+	items, _ := fields["2"].([]interface{})
+	if len(items) != 2 || items[0] != uint64(1) || items[1] != nil {
+		t.Errorf("Expected [1 <nil>], got %v", fields["2"])
+	}
+}
@@ -0,0 +1,301 @@
+package interaction
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/tlv"
+)
+
+// InteractionModelRevision is the revision sent in Interaction Model messages
+const InteractionModelRevision = 11
+
+// DefaultMaxPayloadSize is the largest encoded WriteRequestMessage sent in a
+// single message; larger list writes are chunked. It leaves room for the
+// message, protocol and security headers within the 1280 byte IPv6 MTU.
+const DefaultMaxPayloadSize = 1024
+
+// MaxTimedRequestTimeout is the largest timeout a TimedRequestMessage can
+// carry (16 bit milliseconds)
+const MaxTimedRequestTimeout = math.MaxUint16 * time.Millisecond
+
+// WriteRequestMessage tags
+const (
+	writeRequestSuppressResponse    = 0
+	writeRequestTimedRequest        = 1
+	writeRequestWriteRequests       = 2
+	writeRequestMoreChunkedMessages = 3
+	messageRevision                 = 0xFF
+)
+
+// AttributeDataIB and AttributePathIB tags
+const (
+	attributeDataPath      = 1
+	attributeDataData      = 2
+	attributePathEndpoint  = 2
+	attributePathCluster   = 3
+	attributePathAttribute = 4
+	attributePathListIndex = 5
+)
+
+// TimedRequestMessage tags
+const timedRequestTimeout = 0
+
+// AttributePath identifies a concrete attribute
+type AttributePath struct {
+	Endpoint  uint16
+	Cluster   uint32
+	Attribute uint32
+}
+
+// ParseAttributePath parses an "endpoint/cluster/attribute" path
+func ParseAttributePath(path string) (AttributePath, error) {
+	parts := strings.Split(path, "/")
+	if len(parts) != 3 {
+		return AttributePath{}, fmt.Errorf("invalid attribute path %q", path)
+	}
+
+	endpoint, err1 := strconv.ParseUint(parts[0], 10, 16)
+	cluster, err2 := strconv.ParseUint(parts[1], 10, 32)
+	attribute, err3 := strconv.ParseUint(parts[2], 10, 32)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return AttributePath{}, fmt.Errorf("invalid attribute path %q", path)
+	}
+
+	return AttributePath{
+		Endpoint:  uint16(endpoint),
+		Cluster:   uint32(cluster),
+		Attribute: uint32(attribute),
+	}, nil
+}
+
+// String returns the path in "endpoint/cluster/attribute" form
+func (p AttributePath) String() string {
+	return fmt.Sprintf("%d/%d/%d", p.Endpoint, p.Cluster, p.Attribute)
+}
+
+// TimedRequest encodes a TimedRequestMessage announcing a timed write or
+// invoke that must follow within timeout
+func TimedRequest(timeout time.Duration) ([]byte, error) {
+	ms := timeout.Milliseconds()
+	if ms <= 0 || timeout > MaxTimedRequestTimeout {
+		return nil, fmt.Errorf("invalid timed request timeout %s", timeout)
+	}
+
+	var w tlv.Writer
+	w.StartStructure(tlv.AnonymousTag)
+	w.PutUint(timedRequestTimeout, uint64(ms))
+	w.PutUint(messageRevision, InteractionModelRevision)
+	w.EndContainer()
+	return w.Bytes(), nil
+}
+
+// WriteRequests encodes the WriteRequestMessages for writing value to path.
+// A write that exceeds maxPayloadSize is split into chunks when the value is
+// a list: the first message replaces the list with an empty one and the
+// following messages append the items, all but the last flagged with
+// MoreChunkedMessages. Timed writes set the TimedRequest flag on every chunk.
+func WriteRequests(path AttributePath, value interface{}, timed bool, maxPayloadSize int) ([][]byte, error) {
+	if maxPayloadSize <= 0 {
+		maxPayloadSize = DefaultMaxPayloadSize
+	}
+
+	single, err := writeRequest(timed, false, func(w *tlv.Writer) error {
+		return putAttributeData(w, path, false, value)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(single) <= maxPayloadSize {
+		return [][]byte{single}, nil
+	}
+
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("attribute value of %d bytes exceeds maximum payload size %d", len(single), maxPayloadSize)
+	}
+
+	// Encode each list item as its own append AttributeDataIB, then pack
+	// as many as fit into each message
+	var chunks [][]byte
+	var pending [][]byte
+	pendingSize := 0
+
+	first, err := encodeAttributeData(path, false, []interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	pending = append(pending, first)
+	pendingSize = len(first)
+
+	overhead := len(packWriteRequest(timed, true, nil))
+	for _, item := range items {
+		data, err := encodeAttributeData(path, true, item)
+		if err != nil {
+			return nil, err
+		}
+		if overhead+len(data) > maxPayloadSize {
+			return nil, fmt.Errorf("list item of %d bytes exceeds maximum payload size %d", len(data), maxPayloadSize)
+		}
+		if overhead+pendingSize+len(data) > maxPayloadSize {
+			chunks = append(chunks, packWriteRequest(timed, true, pending))
+			pending, pendingSize = nil, 0
+		}
+		pending = append(pending, data)
+		pendingSize += len(data)
+	}
+	chunks = append(chunks, packWriteRequest(timed, false, pending))
+
+	return chunks, nil
+}
+
+// packWriteRequest wraps already encoded AttributeDataIBs in a
+// WriteRequestMessage
+func packWriteRequest(timed, moreChunks bool, attributeData [][]byte) []byte {
+	msg, _ := writeRequest(timed, moreChunks, func(w *tlv.Writer) error {
+		for _, data := range attributeData {
+			w.PutRaw(data)
+		}
+		return nil
+	})
+	return msg
+}
+
+func writeRequest(timed, moreChunks bool, writeData func(w *tlv.Writer) error) ([]byte, error) {
+	var w tlv.Writer
+	w.StartStructure(tlv.AnonymousTag)
+	w.PutBool(writeRequestSuppressResponse, false)
+	w.PutBool(writeRequestTimedRequest, timed)
+	w.StartArray(writeRequestWriteRequests)
+	if err := writeData(&w); err != nil {
+		return nil, err
+	}
+	w.EndContainer()
+	if moreChunks {
+		w.PutBool(writeRequestMoreChunkedMessages, true)
+	}
+	w.PutUint(messageRevision, InteractionModelRevision)
+	w.EndContainer()
+	return w.Bytes(), nil
+}
+
+func encodeAttributeData(path AttributePath, appendItem bool, value interface{}) ([]byte, error) {
+	var w tlv.Writer
+	if err := putAttributeData(&w, path, appendItem, value); err != nil {
+		return nil, err
+	}
+	return w.Bytes(), nil
+}
+
+// putAttributeData encodes an AttributeDataIB. appendItem sets a null
+// ListIndex, appending value to the list attribute.
+func putAttributeData(w *tlv.Writer, path AttributePath, appendItem bool, value interface{}) error {
+	w.StartStructure(tlv.AnonymousTag)
+	w.StartList(attributeDataPath)
+	w.PutUint(attributePathEndpoint, uint64(path.Endpoint))
+	w.PutUint(attributePathCluster, uint64(path.Cluster))
+	w.PutUint(attributePathAttribute, uint64(path.Attribute))
+	if appendItem {
+		w.PutNull(attributePathListIndex)
+	}
+	w.EndContainer()
+	if err := PutValue(w, attributeDataData, value); err != nil {
+		return fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+	w.EndContainer()
+	return nil
+}
+
+// PutValue encodes a value in the JSON representation used for attribute
+// values (see Value). Maps must be keyed by context tag. Since octet strings
+// cannot be told apart from strings in JSON, they must be passed as []byte.
+func PutValue(w *tlv.Writer, tag int, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		w.PutNull(tag)
+	case bool:
+		w.PutBool(tag, v)
+	case float64:
+		switch {
+		case v >= 0 && v <= math.MaxUint64 && v == math.Trunc(v):
+			w.PutUint(tag, uint64(v))
+		case v < 0 && v >= math.MinInt64 && v == math.Trunc(v):
+			w.PutInt(tag, int64(v))
+		default:
+			w.PutFloat(tag, v)
+		}
+	case int:
+		if v < 0 {
+			w.PutInt(tag, int64(v))
+		} else {
+			w.PutUint(tag, uint64(v))
+		}
+	case int64:
+		if v < 0 {
+			w.PutInt(tag, v)
+		} else {
+			w.PutUint(tag, uint64(v))
+		}
+	case uint16:
+		w.PutUint(tag, uint64(v))
+	case uint32:
+		w.PutUint(tag, uint64(v))
+	case uint64:
+		w.PutUint(tag, v)
+	case string:
+		w.PutString(tag, v)
+	case []byte:
+		w.PutBytes(tag, v)
+	case []interface{}:
+		w.StartArray(tag)
+		for _, item := range v {
+			if err := PutValue(w, tlv.AnonymousTag, item); err != nil {
+				return err
+			}
+		}
+		w.EndContainer()
+	case map[string]interface{}:
+		w.StartStructure(tag)
+		for _, key := range sortedTags(v) {
+			fieldTag, err := strconv.ParseUint(key, 10, 8)
+			if err != nil {
+				return fmt.Errorf("structure field %q is not a context tag", key)
+			}
+			if err := PutValue(w, int(fieldTag), v[key]); err != nil {
+				return err
+			}
+		}
+		w.EndContainer()
+	default:
+		return fmt.Errorf("unsupported value type %T", value)
+	}
+	return nil
+}
+
+// sortedTags returns map keys in ascending numeric order (non-numeric keys
+// last) so structures are encoded in canonical field order
+func sortedTags(fields map[string]interface{}) []string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		na, errA := strconv.Atoi(a)
+		nb, errB := strconv.Atoi(b)
+		switch {
+		case errA != nil && errB != nil:
+			return strings.Compare(a, b)
+		case errA != nil:
+			return 1
+		case errB != nil:
+			return -1
+		default:
+			return na - nb
+		}
+	})
+	return keys
+}
@@ -0,0 +1,146 @@
+package interaction
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/tlv"
+)
+
+func TestParseAttributePath(t *testing.T) {
+	path, err := ParseAttributePath("0/63/0")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if path != (AttributePath{Endpoint: 0, Cluster: 63, Attribute: 0}) || path.String() != "0/63/0" {
+		t.Errorf("Unexpected path %+v", path)
+	}
+
+	for _, invalid := range []string{"", "1/6", "a/6/0", "70000/6/0", "1/6/0/1"} {
+		if _, err := ParseAttributePath(invalid); err == nil {
+			t.Errorf("Expected error for %q", invalid)
+		}
+	}
+}
+
+func TestTimedRequest(t *testing.T) {
+	data, err := TimedRequest(500 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	msg, err := tlv.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if timeout, _ := mustField(t, msg, timedRequestTimeout).Uint(); timeout != 500 {
+		t.Errorf("Expected timeout 500, got %d", timeout)
+	}
+
+	for _, invalid := range []time.Duration{0, time.Microsecond, 70 * time.Second} {
+		if _, err := TimedRequest(invalid); err == nil {
+			t.Errorf("Expected error for %s", invalid)
+		}
+	}
+}
+
+func TestWriteRequestsSingle(t *testing.T) {
+	path := AttributePath{Endpoint: 1, Cluster: 6, Attribute: 0x4003}
+	messages, err := WriteRequests(path, float64(1), true, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(messages))
+	}
+
+	msg, _ := tlv.Decode(messages[0])
+	if timed, _ := mustField(t, msg, writeRequestTimedRequest).Bool(); !timed {
+		t.Error("Expected TimedRequest flag")
+	}
+	if _, more := msg.Field(writeRequestMoreChunkedMessages); more {
+		t.Error("Expected no MoreChunkedMessages flag")
+	}
+
+	data := mustField(t, msg, writeRequestWriteRequests).Elements[0]
+	attrPath := mustField(t, data, attributeDataPath)
+	if attribute, _ := mustField(t, attrPath, attributePathAttribute).Uint(); attribute != 0x4003 {
+		t.Errorf("Expected attribute 0x4003, got 0x%X", attribute)
+	}
+	if v, _ := mustField(t, data, attributeDataData).Uint(); v != 1 {
+		t.Errorf("Expected value 1, got %d", v)
+	}
+}
+
+func TestWriteRequestsChunked(t *testing.T) {
+	path := AttributePath{Endpoint: 0, Cluster: 31, Attribute: 0}
+	items := make([]interface{}, 20)
+	for i := range items {
+		items[i] = map[string]interface{}{"1": float64(5), "2": float64(2), "3": []interface{}{float64(112233 + i)}, "254": float64(1)}
+	}
+
+	messages, err := WriteRequests(path, items, false, 200)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(messages) < 2 {
+		t.Fatalf("Expected chunked write, got %d message(s)", len(messages))
+	}
+
+	appended := 0
+	for i, data := range messages {
+		if len(data) > 200 {
+			t.Errorf("Message %d exceeds maximum size: %d bytes", i, len(data))
+		}
+		msg, err := tlv.Decode(data)
+		if err != nil {
+			t.Fatalf("Decode of message %d failed: %v", i, err)
+		}
+
+		_, more := msg.Field(writeRequestMoreChunkedMessages)
+		if more != (i < len(messages)-1) {
+			t.Errorf("Unexpected MoreChunkedMessages flag on message %d", i)
+		}
+
+		for j, attr := range mustField(t, msg, writeRequestWriteRequests).Elements {
+			listIndex, isAppend := mustField(t, attr, attributeDataPath).Field(attributePathListIndex)
+			if i == 0 && j == 0 {
+				// Replace the list with an empty one first
+				if isAppend || len(mustField(t, attr, attributeDataData).Elements) != 0 {
+					t.Error("Expected first chunk to replace the list with an empty list")
+				}
+				continue
+			}
+			if !isAppend || listIndex.Type != tlv.TypeNull {
+				t.Errorf("Expected append with null ListIndex in message %d", i)
+			}
+			appended++
+		}
+	}
+	if appended != len(items) {
+		t.Errorf("Expected %d appended items, got %d", len(items), appended)
+	}
+}
+
+func TestWriteRequestsErrors(t *testing.T) {
+	path := AttributePath{Endpoint: 1, Cluster: 0x28, Attribute: 5}
+
+	if _, err := WriteRequests(path, strings.Repeat("x", 300), false, 100); err == nil {
+		t.Error("Expected error for oversized non-list value")
+	}
+	if _, err := WriteRequests(path, []interface{}{strings.Repeat("x", 300)}, false, 100); err == nil {
+		t.Error("Expected error for oversized list item")
+	}
+	if _, err := WriteRequests(path, map[string]interface{}{"label": "x"}, false, 0); err == nil {
+		t.Error("Expected error for structure keyed by name")
+	}
+}
+
+func mustField(t *testing.T, e tlv.Element, tag uint8) tlv.Element {
+	t.Helper()
+	f, ok := e.Field(tag)
+	if !ok {
+		t.Fatalf("Missing field %d", tag)
+	}
+	return f
+}
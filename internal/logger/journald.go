@@ -0,0 +1,128 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// journalSocket is the socket of the native journald protocol. A variable
+// so tests can replace it.
+var journalSocket = "/run/systemd/journal/socket"
+
+// journalFields are set by JournalTarget itself. Entry fields with these
+// names get a FIELD_ prefix.
+var journalFields = map[string]bool{
+	"MESSAGE":           true,
+	"PRIORITY":          true,
+	"SYSLOG_IDENTIFIER": true,
+	"LOGGER":            true,
+	"CODE_FILE":         true,
+	"CODE_LINE":         true,
+	"CODE_FUNC":         true,
+}
+
+// JournalTarget sends entries to systemd-journald using its native
+// protocol. Entry fields become journal fields, e.g. node_id becomes
+// NODE_ID, so they can be matched with journalctl NODE_ID=5.
+type JournalTarget struct {
+	conn       net.Conn
+	identifier string
+}
+
+// NewJournalTarget connects to journald. identifier is the
+// SYSLOG_IDENTIFIER of the entries.
+func NewJournalTarget(identifier string) (*JournalTarget, error) {
+	conn, err := net.Dial("unixgram", journalSocket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to journald: %w", err)
+	}
+	return &JournalTarget{conn: conn, identifier: identifier}, nil
+}
+
+// WriteEntry sends an entry as one journal entry
+func (t *JournalTarget) WriteEntry(entry LogEntry) error {
+	var b bytes.Buffer
+	writeJournalField(&b, "MESSAGE", formatMessage(entry))
+	writeJournalField(&b, "PRIORITY", strconv.Itoa(syslogPriority(entry.Level)))
+	writeJournalField(&b, "SYSLOG_IDENTIFIER", t.identifier)
+	if entry.Logger != "" {
+		writeJournalField(&b, "LOGGER", entry.Logger)
+	}
+	if entry.Caller != nil {
+		writeJournalField(&b, "CODE_FILE", entry.Caller.File)
+		writeJournalField(&b, "CODE_LINE", strconv.Itoa(entry.Caller.Line))
+		writeJournalField(&b, "CODE_FUNC", entry.Caller.Function)
+	}
+	for _, field := range entry.Fields {
+		if name := journalFieldName(field.Key); name != "" {
+			writeJournalField(&b, name, fmt.Sprint(field.Value))
+		}
+	}
+
+	_, err := t.conn.Write(b.Bytes())
+	return err
+}
+
+// Close closes the connection to journald
+func (t *JournalTarget) Close() error {
+	return t.conn.Close()
+}
+
+// writeJournalField appends a field in the native protocol. Values with
+// newlines are length-prefixed.
+func writeJournalField(b *bytes.Buffer, name, value string) {
+	b.WriteString(name)
+	if !strings.Contains(value, "\n") {
+		b.WriteByte('=')
+		b.WriteString(value)
+		b.WriteByte('\n')
+		return
+	}
+	b.WriteByte('\n')
+	binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value)
+	b.WriteByte('\n')
+}
+
+// journalFieldName converts a field key to a journal field name: upper case
+// letters, digits and underscores, starting with a letter and at most 64
+// characters. It returns "" for keys without letters.
+func journalFieldName(key string) string {
+	name := []byte(strings.ToUpper(key))
+	for i, c := range name {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			name[i] = '_'
+		}
+	}
+	result := strings.TrimLeft(string(name), "_0123456789")
+	if result == "" {
+		return ""
+	}
+	if journalFields[result] {
+		result = "FIELD_" + result
+	}
+	if len(result) > 64 {
+		result = result[:64]
+	}
+	return result
+}
+
+// syslogPriority maps a level to a syslog priority, which journald uses too
+func syslogPriority(level LogLevel) int {
+	switch level {
+	case TraceLevel, DebugLevel:
+		return 7 // debug
+	case InfoLevel:
+		return 6 // info
+	case WarnLevel:
+		return 4 // warning
+	case ErrorLevel:
+		return 3 // err
+	default:
+		return 2 // crit
+	}
+}
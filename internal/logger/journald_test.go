@@ -0,0 +1,96 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJournalTarget(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "journal.socket")
+	listener, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("Unix datagram sockets unavailable: %v", err)
+	}
+	defer listener.Close()
+
+	journalSocket = socket
+	t.Cleanup(func() { journalSocket = "/run/systemd/journal/socket" })
+
+	target, err := NewJournalTarget("matter-server")
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer target.Close()
+
+	logger := New(Config{Level: InfoLevel, Target: target})
+	logger.WithName("availability").Warn("Node went offline", Int("node_id", 5), String("message", "line one\nline two"))
+
+	buf := make([]byte, 4096)
+	listener.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := listener.Read(buf)
+	if err != nil {
+		t.Fatalf("Failed to read entry: %v", err)
+	}
+	fields := parseJournalEntry(t, buf[:n])
+
+	want := map[string]string{
+		"PRIORITY":          "4",
+		"SYSLOG_IDENTIFIER": "matter-server",
+		"LOGGER":            "availability",
+		"NODE_ID":           "5",
+		"FIELD_MESSAGE":     "line one\nline two",
+	}
+	for name, value := range want {
+		if fields[name] != value {
+			t.Errorf("Expected %s=%q, got %q", name, value, fields[name])
+		}
+	}
+	// No timestamp or level, journald records those
+	if message := fields["MESSAGE"]; !strings.HasPrefix(message, "[availability] Node went offline {node_id=5") {
+		t.Errorf("Unexpected message %q", message)
+	}
+}
+
+func TestJournalFieldName(t *testing.T) {
+	tests := map[string]string{
+		"node_id":               "NODE_ID",
+		"attribute.path":        "ATTRIBUTE_PATH",
+		"_internal":             "INTERNAL",
+		"2fa":                   "FA",
+		"priority":              "FIELD_PRIORITY",
+		"--":                    "",
+		strings.Repeat("a", 70): strings.Repeat("A", 64),
+	}
+	for key, want := range tests {
+		if got := journalFieldName(key); got != want {
+			t.Errorf("Expected %q for %q, got %q", want, key, got)
+		}
+	}
+}
+
+// parseJournalEntry decodes an entry of the native journald protocol
+func parseJournalEntry(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	fields := make(map[string]string)
+	for len(data) > 0 {
+		end := bytes.IndexByte(data, '\n')
+		if end < 0 {
+			t.Fatalf("Truncated entry %q", data)
+		}
+		line := string(data[:end])
+		data = data[end+1:]
+		if name, value, ok := strings.Cut(line, "="); ok {
+			fields[name] = value
+			continue
+		}
+		size := binary.LittleEndian.Uint64(data[:8])
+		fields[line] = string(data[8 : 8+size])
+		data = data[8+size+1:]
+	}
+	return fields
+}
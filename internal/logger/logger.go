@@ -3,10 +3,12 @@ package logger
 import (
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -58,7 +60,8 @@ type Field struct {
 
 // Logger provides structured logging functionality
 type Logger struct {
-	level      LogLevel
+	// level is shared with the loggers derived with With and WithName
+	level      *atomic.Int32
 	format     LogFormat
 	writer     io.Writer
 	name       string
@@ -66,6 +69,16 @@ type Logger struct {
 	useColors  bool
 	mu         sync.Mutex
 	timeFormat string
+	history    *history
+	// handler and target receive the entries instead of writer if set
+	handler slog.Handler
+	target  Target
+}
+
+// Target receives log entries instead of an output writer. Targets like
+// syslog and journald record the time and level themselves.
+type Target interface {
+	WriteEntry(entry LogEntry) error
 }
 
 // Config holds logger configuration
@@ -75,6 +88,14 @@ type Config struct {
 	Output     io.Writer
 	UseColors  bool
 	TimeFormat string
+	// HistorySize is the number of recent log entries kept for Recent and
+	// Records, 0 keeps none
+	HistorySize int
+	// Handler receives the entries instead of Output, for code logging with
+	// this package to write to an slog pipeline
+	Handler slog.Handler
+	// Target receives the entries instead of Output
+	Target Target
 }
 
 // New creates a new logger instance
@@ -87,12 +108,17 @@ func New(config Config) *Logger {
 		config.TimeFormat = "2006-01-02 15:04:05.000"
 	}
 
+	level := new(atomic.Int32)
+	level.Store(int32(config.Level))
 	return &Logger{
-		level:      config.Level,
+		level:      level,
 		format:     config.Format,
 		writer:     config.Output,
 		useColors:  config.UseColors,
 		timeFormat: config.TimeFormat,
+		history:    newHistory(config.HistorySize),
+		handler:    config.Handler,
+		target:     config.Target,
 	}
 }
 
@@ -127,28 +153,37 @@ func (l *Logger) With(fields ...Field) *Logger {
 		fields:     newFields,
 		useColors:  l.useColors,
 		timeFormat: l.timeFormat,
+		history:    l.history,
+		handler:    l.handler,
+		target:     l.target,
 	}
 }
 
 // WithName creates a new logger with a name
 func (l *Logger) WithName(name string) *Logger {
-	newLogger := *l
-	newLogger.name = name
-	return &newLogger
+	return &Logger{
+		level:      l.level,
+		format:     l.format,
+		writer:     l.writer,
+		name:       name,
+		fields:     l.fields,
+		useColors:  l.useColors,
+		timeFormat: l.timeFormat,
+		history:    l.history,
+		handler:    l.handler,
+		target:     l.target,
+	}
 }
 
-// SetLevel sets the minimum log level
+// SetLevel sets the minimum log level, also of the logger this one was
+// derived from and the loggers derived from either
 func (l *Logger) SetLevel(level LogLevel) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	l.level = level
+	l.level.Store(int32(level))
 }
 
 // GetLevel returns the current log level
 func (l *Logger) GetLevel() LogLevel {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	return l.level
+	return LogLevel(l.level.Load())
 }
 
 // IsEnabled returns true if the given level would be logged
@@ -281,9 +316,6 @@ type CallerInfo struct {
 }
 
 func (l *Logger) writeEntry(entry LogEntry) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-
 	var output string
 
 	switch l.format {
@@ -295,7 +327,138 @@ func (l *Logger) writeEntry(entry LogEntry) {
 		output = l.formatConsole(entry)
 	}
 
-	fmt.Fprintln(l.writer, output)
+	switch {
+	case l.handler != nil:
+		l.handleEntry(entry)
+	case l.target != nil:
+		l.target.WriteEntry(entry)
+	default:
+		l.mu.Lock()
+		fmt.Fprintln(l.writer, output)
+		l.mu.Unlock()
+	}
+
+	// Subscribers may log themselves
+	l.history.add(Record{
+		Timestamp: entry.Timestamp,
+		Level:     entry.Level,
+		Logger:    entry.Logger,
+		Message:   entry.Message,
+		Fields:    entry.Fields,
+		Line:      colorReplacer.Replace(output),
+	})
+}
+
+// Recent returns the most recent log lines of this logger and the loggers
+// derived from it, oldest first and without colors
+func (l *Logger) Recent() []string {
+	records := l.history.records()
+	if records == nil {
+		return nil
+	}
+	lines := make([]string, len(records))
+	for i, record := range records {
+		lines[i] = record.Line
+	}
+	return lines
+}
+
+// Records returns the most recent log entries of this logger and the loggers
+// derived from it, oldest first
+func (l *Logger) Records() []Record {
+	return l.history.records()
+}
+
+// Subscribe calls fn with each entry logged by this logger and the loggers
+// derived from it, until the returned function is called. fn runs on the
+// logging goroutine and must not block.
+func (l *Logger) Subscribe(fn func(Record)) func() {
+	return l.history.subscribe(fn)
+}
+
+// colorReplacer strips the level colors from console output
+var colorReplacer = func() *strings.Replacer {
+	oldnew := []string{colorReset, ""}
+	for _, color := range levelColors {
+		oldnew = append(oldnew, color, "")
+	}
+	return strings.NewReplacer(oldnew...)
+}()
+
+// Record is a log entry as kept in the history
+type Record struct {
+	Timestamp time.Time
+	Level     LogLevel
+	Logger    string
+	Message   string
+	Fields    []Field
+	// Line is the formatted entry without colors
+	Line string
+}
+
+// history is a ring buffer of log entries shared by derived loggers, along
+// with their subscribers. Without a size no entries are kept.
+type history struct {
+	mu   sync.Mutex
+	buf  []Record
+	next int
+	full bool
+
+	listeners    map[int]func(Record)
+	nextListener int
+}
+
+func newHistory(size int) *history {
+	h := &history{listeners: make(map[int]func(Record))}
+	if size > 0 {
+		h.buf = make([]Record, size)
+	}
+	return h
+}
+
+func (h *history) add(record Record) {
+	h.mu.Lock()
+	if len(h.buf) > 0 {
+		h.buf[h.next] = record
+		h.next = (h.next + 1) % len(h.buf)
+		if h.next == 0 {
+			h.full = true
+		}
+	}
+	listeners := make([]func(Record), 0, len(h.listeners))
+	for _, fn := range h.listeners {
+		listeners = append(listeners, fn)
+	}
+	h.mu.Unlock()
+
+	for _, fn := range listeners {
+		fn(record)
+	}
+}
+
+func (h *history) records() []Record {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.buf) == 0 {
+		return nil
+	}
+	if !h.full {
+		return append([]Record(nil), h.buf[:h.next]...)
+	}
+	return append(append([]Record(nil), h.buf[h.next:]...), h.buf[:h.next]...)
+}
+
+func (h *history) subscribe(fn func(Record)) func() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	id := h.nextListener
+	h.nextListener++
+	h.listeners[id] = fn
+	return func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.listeners, id)
+	}
 }
 
 func (l *Logger) formatConsole(entry LogEntry) string {
@@ -317,6 +480,19 @@ func (l *Logger) formatConsole(entry LogEntry) string {
 	}
 	b.WriteString(" ")
 
+	writeMessage(&b, entry)
+	return b.String()
+}
+
+// formatMessage formats an entry like the console format without timestamp
+// and level, for targets recording those themselves
+func formatMessage(entry LogEntry) string {
+	var b strings.Builder
+	writeMessage(&b, entry)
+	return b.String()
+}
+
+func writeMessage(b *strings.Builder, entry LogEntry) {
 	// Logger name
 	if entry.Logger != "" {
 		b.WriteString("[")
@@ -345,8 +521,6 @@ func (l *Logger) formatConsole(entry LogEntry) string {
 		file := parts[len(parts)-1]
 		b.WriteString(fmt.Sprintf(" (%s:%d)", file, entry.Caller.Line))
 	}
-
-	return b.String()
 }
 
 func (l *Logger) formatJSON(entry LogEntry) string {
@@ -397,7 +571,7 @@ func formatJSONValue(v interface{}) string {
 	switch val := v.(type) {
 	case string:
 		return fmt.Sprintf(`"%s"`, escapeJSON(val))
-	case int, int32, int64, float32, float64:
+	case int, int32, int64, uint, uint32, uint64, float32, float64:
 		return fmt.Sprintf("%v", val)
 	case bool:
 		return fmt.Sprintf("%t", val)
@@ -438,6 +612,14 @@ func ErrorField(err error) Field {
 	return Field{Key: "error", Value: err.Error()}
 }
 
+// String returns the level as accepted by ParseLogLevel, e.g. "info"
+func (l LogLevel) String() string {
+	if name, ok := levelNames[l]; ok {
+		return strings.ToLower(name)
+	}
+	return fmt.Sprintf("level(%d)", int(l))
+}
+
 // ParseLogLevel parses a string log level
 func ParseLogLevel(level string) (LogLevel, error) {
 	switch strings.ToLower(level) {
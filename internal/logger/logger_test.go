@@ -77,8 +77,8 @@ func TestLoggerCreation(t *testing.T) {
 		Output: &buf,
 	})
 
-	if logger.level != DebugLevel {
-		t.Errorf("Expected level %v, got %v", DebugLevel, logger.level)
+	if logger.GetLevel() != DebugLevel {
+		t.Errorf("Expected level %v, got %v", DebugLevel, logger.GetLevel())
 	}
 	if logger.format != ConsoleFormat {
 		t.Errorf("Expected format %v, got %v", ConsoleFormat, logger.format)
@@ -213,6 +213,70 @@ func TestConsoleFormat(t *testing.T) {
 	}
 }
 
+func TestRecent(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := New(Config{
+		Level:       InfoLevel,
+		Format:      ConsoleFormat,
+		Output:      &buf,
+		UseColors:   true,
+		HistorySize: 2,
+	})
+
+	logger.Info("first")
+	logger.WithName("child").Warn("second")
+	logger.Debug("filtered")
+	logger.Error("third")
+
+	recent := logger.Recent()
+	if len(recent) != 2 {
+		t.Fatalf("Expected 2 recent lines, got %d", len(recent))
+	}
+	if !strings.Contains(recent[0], "[child] second") || !strings.Contains(recent[1], "third") {
+		t.Errorf("Expected last two lines oldest first, got %q", recent)
+	}
+	if strings.Contains(recent[0], "\033[") {
+		t.Errorf("Expected recent lines without colors, got %q", recent[0])
+	}
+
+	if New(Config{Output: &buf}).Recent() != nil {
+		t.Error("Expected no history without HistorySize")
+	}
+}
+
+func TestRecords(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(Config{Level: InfoLevel, Output: &buf, HistorySize: 2})
+
+	var received []Record
+	unsubscribe := logger.WithName("child").Subscribe(func(record Record) {
+		received = append(received, record)
+	})
+	logger.WithName("child").Info("first", Int("count", 1))
+	unsubscribe()
+	logger.Warn("second")
+
+	records := logger.Records()
+	if len(records) != 2 || records[0].Logger != "child" || records[0].Fields[0].Value != 1 || records[1].Level != WarnLevel {
+		t.Errorf("Unexpected records %+v", records)
+	}
+	if len(received) != 1 || received[0].Message != "first" {
+		t.Errorf("Expected only the entry logged while subscribed, got %+v", received)
+	}
+
+	// Subscribers get entries without a history too
+	logger = New(Config{Output: &buf})
+	logger.Subscribe(func(record Record) { received = append(received, record) })
+	logger.Info("third")
+	if logger.Records() != nil || len(received) != 2 {
+		t.Errorf("Expected an entry without history, got %+v", received)
+	}
+	if WarnLevel.String() != "warn" {
+		t.Errorf("Unexpected level name %q", WarnLevel.String())
+	}
+}
+
 func TestJSONFormat(t *testing.T) {
 	var buf bytes.Buffer
 
@@ -311,6 +375,13 @@ func TestSetLevel(t *testing.T) {
 	if logger.GetLevel() != DebugLevel {
 		t.Errorf("Level should be Debug after SetLevel, got %v", logger.GetLevel())
 	}
+
+	// Derived loggers share the level
+	child := logger.WithName("child").With(String("key", "value"))
+	child.SetLevel(WarnLevel)
+	if logger.GetLevel() != WarnLevel || child.GetLevel() != WarnLevel {
+		t.Errorf("Expected Warn for both loggers, got %v and %v", logger.GetLevel(), child.GetLevel())
+	}
 }
 
 func TestFormatJSONValue(t *testing.T) {
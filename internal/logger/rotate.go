@@ -0,0 +1,256 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is the rotation time in the names of backups, e.g.
+// server-2026-10-18T12-00-00.000.log
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// RotateConfig configures a log file rotated by size
+type RotateConfig struct {
+	Path string
+	// MaxSize is the size in bytes at which the file is rotated, 0 never
+	// rotates it
+	MaxSize int64
+	// MaxBackups is the number of rotated files kept, 0 keeps all
+	MaxBackups int
+	// MaxAge is how long rotated files are kept, 0 keeps them regardless
+	// of age
+	MaxAge time.Duration
+	// Compress gzips rotated files
+	Compress bool
+}
+
+// RotatingFile is a log file that is moved aside once it reaches its
+// maximum size. Old backups are compressed and removed in the background.
+type RotatingFile struct {
+	config RotateConfig
+	now    func() time.Time
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+
+	// millMu serializes compressing and removing backups
+	millMu  sync.Mutex
+	milling sync.WaitGroup
+}
+
+// OpenRotatingFile opens or creates the log file, creating its directory
+// if needed
+func OpenRotatingFile(config RotateConfig) (*RotatingFile, error) {
+	return openRotatingFile(config, time.Now)
+}
+
+func openRotatingFile(config RotateConfig, now func() time.Time) (*RotatingFile, error) {
+	f := &RotatingFile{config: config, now: now}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	// Backups left uncompressed or beyond the limits by a previous run
+	f.mill(now())
+	return f, nil
+}
+
+func (f *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.config.Path), 0o755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+	file, err := os.OpenFile(f.config.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// Write appends p to the log file, rotating it first if p doesn't fit
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.config.MaxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.config.MaxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Rotate moves the log file aside and starts a new one
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	return f.rotate()
+}
+
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+	f.file = nil
+
+	now := f.now()
+	if err := os.Rename(f.config.Path, f.backupName(now)); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	f.mill(now)
+	return nil
+}
+
+// Close closes the log file after compressing and removing backups
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.milling.Wait()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *RotatingFile) backupName(t time.Time) string {
+	ext := filepath.Ext(f.config.Path)
+	base := strings.TrimSuffix(f.config.Path, ext)
+	return base + "-" + t.Format(backupTimeFormat) + ext
+}
+
+type backup struct {
+	path string
+	time time.Time
+}
+
+// backups lists the rotated files, newest first
+func (f *RotatingFile) backups() ([]backup, error) {
+	dir := filepath.Dir(f.config.Path)
+	ext := filepath.Ext(f.config.Path)
+	prefix := strings.TrimSuffix(filepath.Base(f.config.Path), ext) + "-"
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var result []backup
+	for _, entry := range entries {
+		name, ok := strings.CutPrefix(entry.Name(), prefix)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		name = strings.TrimSuffix(name, ".gz")
+		stamp, ok := strings.CutSuffix(name, ext)
+		if !ok {
+			continue
+		}
+		t, err := time.Parse(backupTimeFormat, stamp)
+		if err != nil {
+			continue
+		}
+		result = append(result, backup{path: filepath.Join(dir, entry.Name()), time: t})
+	}
+	slices.SortFunc(result, func(a, b backup) int {
+		return b.time.Compare(a.time)
+	})
+	return result, nil
+}
+
+// mill compresses and removes backups in the background, expiring them
+// relative to now
+func (f *RotatingFile) mill(now time.Time) {
+	f.milling.Add(1)
+	go func() {
+		defer f.milling.Done()
+		f.millMu.Lock()
+		defer f.millMu.Unlock()
+
+		if err := f.millBackups(now); err != nil {
+			// The log file itself can't report it
+			fmt.Fprintf(os.Stderr, "failed to clean up log backups: %v\n", err)
+		}
+	}()
+}
+
+func (f *RotatingFile) millBackups(now time.Time) error {
+	backups, err := f.backups()
+	if err != nil {
+		return err
+	}
+
+	for i, b := range backups {
+		expired := f.config.MaxAge > 0 && now.Sub(b.time) > f.config.MaxAge
+		if expired || f.config.MaxBackups > 0 && i >= f.config.MaxBackups {
+			if err := os.Remove(b.path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		if f.config.Compress && !strings.HasSuffix(b.path, ".gz") {
+			if err := compressFile(b.path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// compressFile replaces a file by its gzipped version
+func compressFile(path string) (err error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			dst.Close()
+			os.Remove(path + ".gz")
+		}
+	}()
+
+	zw := gzip.NewWriter(dst)
+	if _, err = io.Copy(zw, src); err != nil {
+		return fmt.Errorf("failed to compress %s: %w", path, err)
+	}
+	if err = zw.Close(); err != nil {
+		return fmt.Errorf("failed to compress %s: %w", path, err)
+	}
+	if err = dst.Close(); err != nil {
+		return fmt.Errorf("failed to compress %s: %w", path, err)
+	}
+	return os.Remove(path)
+}
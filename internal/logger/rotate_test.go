@@ -0,0 +1,124 @@
+package logger
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "logs", "server.log")
+
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	file, err := openRotatingFile(RotateConfig{Path: path, MaxSize: 10, MaxBackups: 2, Compress: true}, func() time.Time {
+		return now
+	})
+	if err != nil {
+		t.Fatalf("Failed to open log file: %v", err)
+	}
+
+	// Each line but the first rotates the file
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		now = now.Add(time.Second)
+		if _, err := file.Write([]byte(line)); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+	}
+	if err := file.Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+
+	if data, _ := os.ReadFile(path); string(data) != "fourth\n" {
+		t.Errorf("Expected the last line in the log file, got %q", data)
+	}
+
+	backups, err := file.backups()
+	if err != nil {
+		t.Fatalf("Failed to list backups: %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 backups, got %v", backups)
+	}
+	for i, want := range []struct{ name, content string }{
+		{"server-2026-10-18T12-00-04.000.log.gz", "third\n"},
+		{"server-2026-10-18T12-00-03.000.log.gz", "second\n"},
+	} {
+		if filepath.Base(backups[i].path) != want.name {
+			t.Errorf("Expected backup %s, got %s", want.name, backups[i].path)
+		}
+		if got := readGzip(t, backups[i].path); got != want.content {
+			t.Errorf("Expected %q in %s, got %q", want.content, backups[i].path, got)
+		}
+	}
+}
+
+func TestRotatingFileMaxAge(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "server.log")
+	now := time.Now()
+
+	// A backup of a previous run, expired, and one still kept
+	old := filepath.Join(dir, "server-"+now.Add(-72*time.Hour).Format(backupTimeFormat)+".log")
+	recent := filepath.Join(dir, "server-"+now.Add(-time.Hour).Format(backupTimeFormat)+".log")
+	for _, backup := range []string{old, recent} {
+		os.WriteFile(backup, []byte("line\n"), 0o644)
+	}
+
+	file, err := OpenRotatingFile(RotateConfig{Path: path, MaxAge: 48 * time.Hour})
+	if err != nil {
+		t.Fatalf("Failed to open log file: %v", err)
+	}
+	file.Close()
+
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("Expected the expired backup to be removed, got %v", err)
+	}
+	if _, err := os.Stat(recent); err != nil {
+		t.Errorf("Expected the recent backup to be kept uncompressed: %v", err)
+	}
+}
+
+func TestRotatingFileLogger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+	os.WriteFile(path, []byte("existing\n"), 0o644)
+
+	file, err := OpenRotatingFile(RotateConfig{Path: path, MaxSize: 1 << 20})
+	if err != nil {
+		t.Fatalf("Failed to open log file: %v", err)
+	}
+	logger := New(Config{Level: InfoLevel, Format: JSONFormat, Output: file})
+	logger.WithName("test").Info("appended")
+	file.Close()
+
+	data, _ := os.ReadFile(path)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || lines[0] != "existing" || !strings.Contains(lines[1], `"appended"`) {
+		t.Errorf("Expected the entry appended to the log file, got %q", data)
+	}
+	if _, err := file.Write([]byte("closed\n")); err == nil {
+		t.Error("Expected an error writing to a closed file")
+	}
+}
+
+func readGzip(t *testing.T, path string) string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return string(data)
+}
@@ -0,0 +1,175 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"time"
+)
+
+// Levels of slog matching TraceLevel and FatalLevel, which slog lacks
+const (
+	slogLevelTrace = slog.LevelDebug - 4
+	slogLevelFatal = slog.LevelError + 4
+)
+
+// slogHandler is an slog.Handler writing through a Logger, so code using
+// log/slog shares its output, levels, history and subscribers
+type slogHandler struct {
+	logger *Logger
+	// prefix holds the open groups, e.g. "scan."
+	prefix string
+}
+
+// NewSlogHandler returns an slog.Handler writing through l. Attributes
+// become fields, with the keys of groups joined by dots.
+func NewSlogHandler(l *Logger) slog.Handler {
+	return &slogHandler{logger: l}
+}
+
+// Slog returns an slog.Logger writing through l
+func (l *Logger) Slog() *slog.Logger {
+	return slog.New(NewSlogHandler(l))
+}
+
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.logger.IsEnabled(fromSlogLevel(level))
+}
+
+func (h *slogHandler) Handle(_ context.Context, r slog.Record) error {
+	level := fromSlogLevel(r.Level)
+	fields := make([]Field, 0, len(h.logger.fields)+r.NumAttrs())
+	fields = append(fields, h.logger.fields...)
+	r.Attrs(func(attr slog.Attr) bool {
+		fields = appendAttr(fields, h.prefix, attr)
+		return true
+	})
+
+	entry := LogEntry{
+		Timestamp: r.Time,
+		Level:     level,
+		Message:   r.Message,
+		Logger:    h.logger.name,
+		Fields:    fields,
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	if level >= ErrorLevel && r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		entry.Caller = &CallerInfo{
+			PC:       r.PC,
+			File:     frame.File,
+			Line:     frame.Line,
+			Function: frame.Function,
+		}
+	}
+
+	h.logger.writeEntry(entry)
+	return nil
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := make([]Field, 0, len(attrs))
+	for _, attr := range attrs {
+		fields = appendAttr(fields, h.prefix, attr)
+	}
+	return &slogHandler{logger: h.logger.With(fields...), prefix: h.prefix}
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &slogHandler{logger: h.logger, prefix: h.prefix + name + "."}
+}
+
+// appendAttr adds an attribute as fields, flattening groups
+func appendAttr(fields []Field, prefix string, attr slog.Attr) []Field {
+	value := attr.Value.Resolve()
+	if value.Kind() == slog.KindGroup {
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, member := range value.Group() {
+			fields = appendAttr(fields, prefix, member)
+		}
+		return fields
+	}
+	if attr.Key == "" {
+		return fields
+	}
+	return append(fields, Field{Key: prefix + attr.Key, Value: fieldValue(value)})
+}
+
+// fieldValue converts an slog value to a field value like the field
+// constructors of this package produce
+func fieldValue(value slog.Value) interface{} {
+	switch value.Kind() {
+	case slog.KindDuration:
+		return value.Duration().String()
+	case slog.KindTime:
+		return value.Time().Format(time.RFC3339Nano)
+	case slog.KindAny:
+		if err, ok := value.Any().(error); ok {
+			return err.Error()
+		}
+	}
+	return value.Any()
+}
+
+func fromSlogLevel(level slog.Level) LogLevel {
+	switch {
+	case level < slog.LevelDebug:
+		return TraceLevel
+	case level < slog.LevelInfo:
+		return DebugLevel
+	case level < slog.LevelWarn:
+		return InfoLevel
+	case level < slog.LevelError:
+		return WarnLevel
+	default:
+		// slog has no fatal level, so nothing logged with it exits
+		return ErrorLevel
+	}
+}
+
+func toSlogLevel(level LogLevel) slog.Level {
+	switch level {
+	case TraceLevel:
+		return slogLevelTrace
+	case DebugLevel:
+		return slog.LevelDebug
+	case InfoLevel:
+		return slog.LevelInfo
+	case WarnLevel:
+		return slog.LevelWarn
+	case ErrorLevel:
+		return slog.LevelError
+	default:
+		return slogLevelFatal
+	}
+}
+
+// handleEntry passes an entry to the slog handler of the logger. The logger
+// name becomes the "logger" attribute.
+func (l *Logger) handleEntry(entry LogEntry) {
+	ctx := context.Background()
+	level := toSlogLevel(entry.Level)
+	if !l.handler.Enabled(ctx, level) {
+		return
+	}
+
+	var pc uintptr
+	if entry.Caller != nil {
+		pc = entry.Caller.PC
+	}
+	r := slog.NewRecord(entry.Timestamp, level, entry.Message, pc)
+	if entry.Logger != "" {
+		r.AddAttrs(slog.String("logger", entry.Logger))
+	}
+	for _, field := range entry.Fields {
+		r.AddAttrs(slog.Any(field.Key, field.Value))
+	}
+	l.handler.Handle(ctx, r)
+}
@@ -0,0 +1,91 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(Config{Level: DebugLevel, Format: JSONFormat, Output: &buf, HistorySize: 10})
+	log := logger.WithName("bluetooth").Slog()
+
+	log.Debug("BLE scan started", "adapter", "hci0", "timeout", 10*time.Second)
+	log.With("adapter", "hci1").WithGroup("scan").Info("BLE scan finished", "devices", 2, slog.Group("rssi", "min", -80))
+	log.Warn("BlueZ unavailable", "error", errors.New("no bus"))
+	log.Log(context.Background(), slog.LevelDebug-4, "filtered")
+	log.Error("Connect failed")
+
+	records := logger.Records()
+	if len(records) != 4 {
+		t.Fatalf("Expected 4 entries, got %+v", records)
+	}
+
+	want := []struct {
+		level  LogLevel
+		fields map[string]interface{}
+	}{
+		{DebugLevel, map[string]interface{}{"adapter": "hci0", "timeout": "10s"}},
+		{InfoLevel, map[string]interface{}{"adapter": "hci1", "scan.devices": int64(2), "scan.rssi.min": int64(-80)}},
+		{WarnLevel, map[string]interface{}{"error": "no bus"}},
+		{ErrorLevel, map[string]interface{}{}},
+	}
+	for i, record := range records {
+		if record.Logger != "bluetooth" || record.Level != want[i].level {
+			t.Errorf("Unexpected entry %+v", record)
+		}
+		fields := make(map[string]interface{})
+		for _, field := range record.Fields {
+			fields[field.Key] = field.Value
+		}
+		if len(fields) != len(want[i].fields) {
+			t.Errorf("Expected fields %v, got %v", want[i].fields, fields)
+		}
+		for key, value := range want[i].fields {
+			if fields[key] != value {
+				t.Errorf("Expected %s=%v, got %v", key, value, fields[key])
+			}
+		}
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if !strings.Contains(lines[1], `"scan.devices":2`) {
+		t.Errorf("Expected the fields in the output, got %s", lines[1])
+	}
+	if !strings.Contains(lines[3], `"caller":{"file":`) || !strings.Contains(lines[3], "slog_test.go") {
+		t.Errorf("Expected the caller of the error, got %s", lines[3])
+	}
+
+	logger.SetLevel(WarnLevel)
+	if log.Enabled(context.Background(), slog.LevelInfo) || !log.Enabled(context.Background(), slog.LevelWarn) {
+		t.Error("Expected the slog logger to follow the level")
+	}
+}
+
+func TestHandlerOutput(t *testing.T) {
+	var buf bytes.Buffer
+	handler := slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})
+	logger := New(Config{Level: TraceLevel, Handler: handler, HistorySize: 10})
+
+	logger.WithName("srp").Info("Registered", Int("services", 3))
+	logger.Debug("Below the handler's level")
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected one JSON entry, got %q: %v", buf.String(), err)
+	}
+	if entry["level"] != "INFO" || entry["msg"] != "Registered" || entry["logger"] != "srp" || entry["services"] != float64(3) {
+		t.Errorf("Unexpected entry %v", entry)
+	}
+
+	// The history doesn't depend on the handler
+	if recent := logger.Recent(); len(recent) != 2 || !strings.Contains(recent[0], "[srp] Registered") {
+		t.Errorf("Expected both entries in the history, got %q", recent)
+	}
+}
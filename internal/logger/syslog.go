@@ -0,0 +1,46 @@
+//go:build !windows && !plan9
+
+package logger
+
+import (
+	"fmt"
+	"log/syslog"
+)
+
+// SyslogTarget sends entries to the local syslog daemon with the daemon
+// facility and the priority of their level
+type SyslogTarget struct {
+	writer *syslog.Writer
+}
+
+// NewSyslogTarget connects to the local syslog daemon. tag is the program
+// name of the entries.
+func NewSyslogTarget(tag string) (*SyslogTarget, error) {
+	writer, err := syslog.New(syslog.LOG_DAEMON|syslog.LOG_INFO, tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to syslog: %w", err)
+	}
+	return &SyslogTarget{writer: writer}, nil
+}
+
+// WriteEntry sends an entry as one syslog message
+func (t *SyslogTarget) WriteEntry(entry LogEntry) error {
+	message := formatMessage(entry)
+	switch entry.Level {
+	case TraceLevel, DebugLevel:
+		return t.writer.Debug(message)
+	case InfoLevel:
+		return t.writer.Info(message)
+	case WarnLevel:
+		return t.writer.Warning(message)
+	case ErrorLevel:
+		return t.writer.Err(message)
+	default:
+		return t.writer.Crit(message)
+	}
+}
+
+// Close closes the connection to the syslog daemon
+func (t *SyslogTarget) Close() error {
+	return t.writer.Close()
+}
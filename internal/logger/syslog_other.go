@@ -0,0 +1,23 @@
+//go:build windows || plan9
+
+package logger
+
+import "errors"
+
+// SyslogTarget is not available on this platform
+type SyslogTarget struct{}
+
+// NewSyslogTarget fails, there is no syslog on this platform
+func NewSyslogTarget(tag string) (*SyslogTarget, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
+
+// WriteEntry does nothing
+func (t *SyslogTarget) WriteEntry(entry LogEntry) error {
+	return nil
+}
+
+// Close does nothing
+func (t *SyslogTarget) Close() error {
+	return nil
+}
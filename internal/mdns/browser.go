@@ -0,0 +1,165 @@
+package mdns
+
+import (
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+// Continuous querying for the browsed service types (RFC 6762 5.2): the
+// interval between queries starts at a second and doubles up to an hour.
+// Variables so tests can shorten them.
+var (
+	browseInitialInterval = time.Second
+	browseMaxInterval     = time.Hour
+	browseTick            = time.Second
+)
+
+// Refresh queries for records in use are sent at 80%, 85%, 90% and 95% of
+// their TTL (RFC 6762 5.2)
+var refreshPercents = []int{80, 85, 90, 95}
+
+// Cache returns the cache of records seen in mDNS responses
+func (s *Server) Cache() *Cache {
+	return s.cache
+}
+
+// startBrowsing starts querying for the service types of Config.Browse
+func (s *Server) startBrowsing() {
+	if len(s.config.Browse) == 0 {
+		return
+	}
+
+	s.browseStop = make(chan struct{})
+	s.browseDone = make(chan struct{})
+	go s.browse()
+}
+
+// stopBrowsing stops querying, it is safe to call more than once
+func (s *Server) stopBrowsing() {
+	if s.browseStop == nil {
+		return
+	}
+	close(s.browseStop)
+	<-s.browseDone
+	s.browseStop = nil
+}
+
+// browse queries the browsed service types with increasing intervals and
+// refreshes the cached records of their instances before they expire
+func (s *Server) browse() {
+	defer close(s.browseDone)
+
+	// The first query is delayed by 20-120ms (RFC 6762 5.2)
+	interval := browseInitialInterval
+	next := time.Now().Add(20*time.Millisecond + rand.N(100*time.Millisecond))
+
+	ticker := time.NewTicker(browseTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.browseStop:
+			return
+		case now := <-ticker.C:
+			browse := !now.Before(next)
+			if browse {
+				next = now.Add(interval)
+				interval = min(2*interval, browseMaxInterval)
+			}
+			if query := s.browseQuery(browse, now); query != nil {
+				s.multicast(query)
+			}
+		}
+	}
+}
+
+// browseQuery builds the query for the browsed service types, if browse is
+// set, and for the records due for a refresh. Nil is returned if there's
+// nothing to ask for.
+func (s *Server) browseQuery(browse bool, now time.Time) *dnsMessage {
+	c := s.cache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prune(now)
+
+	query := &dnsMessage{}
+	asked := make(map[dnsQuestion]bool)
+	ask := func(name string, rrtype uint16) {
+		q := dnsQuestion{Name: name, Type: rrtype, Class: 1}
+		if !asked[q] {
+			asked[q] = true
+			query.Questions = append(query.Questions, q)
+		}
+	}
+
+	for _, serviceType := range s.config.Browse {
+		name := serviceType + ".local"
+		if browse {
+			ask(name, dnsTypePTR)
+			// Known answers with more than half of their TTL left suppress
+			// responses that wouldn't tell anything new (7.1)
+			for _, entry := range c.lookup(name, dnsTypePTR, now) {
+				remaining := entry.expires.Sub(now)
+				if 2*remaining > time.Duration(entry.record.TTL)*time.Second {
+					known := entry.record
+					known.TTL = uint32(remaining / time.Second)
+					known.Data = encodeName(known.Value)
+					query.Answers = append(query.Answers, known)
+				}
+			}
+		}
+	}
+
+	for _, entry := range c.refreshDue(s.browsedNames(now), now) {
+		ask(entry.record.Name, entry.record.Type)
+	}
+
+	if len(query.Questions) == 0 {
+		return nil
+	}
+	return query
+}
+
+// browsedNames returns the names of the records in use for the browsed
+// service types: the PTR records, the SRV and TXT records of the instances
+// and the addresses of their hosts. The caller holds s.cache.mu.
+func (s *Server) browsedNames(now time.Time) map[string]bool {
+	c := s.cache
+	names := make(map[string]bool)
+	for _, serviceType := range s.config.Browse {
+		name := strings.ToLower(serviceType + ".local")
+		names[name] = true
+		for _, ptr := range c.lookup(name, dnsTypePTR, now) {
+			names[ptr.record.Value] = true
+			for _, srv := range c.lookup(ptr.record.Value, dnsTypeSRV, now) {
+				if _, target, err := parseSRVValue(srv.record.Value); err == nil {
+					names[target] = true
+				}
+			}
+		}
+	}
+	return names
+}
+
+// refreshDue returns the records of names reaching their next refresh point
+// and counts the refresh. The caller holds c.mu.
+func (c *Cache) refreshDue(names map[string]bool, now time.Time) []*cacheEntry {
+	var due []*cacheEntry
+	for key, entry := range c.records {
+		if !names[key.name] || entry.refreshes >= len(refreshPercents) {
+			continue
+		}
+		ttl := time.Duration(entry.record.TTL) * time.Second
+		refreshes := entry.refreshes
+		for entry.refreshes < len(refreshPercents) &&
+			!now.Before(entry.received.Add(ttl*time.Duration(refreshPercents[entry.refreshes])/100)) {
+			entry.refreshes++
+		}
+		if entry.refreshes == refreshes {
+			continue
+		}
+		due = append(due, entry)
+	}
+	return due
+}
@@ -0,0 +1,223 @@
+package mdns
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache holds the records of mDNS responses until their TTL expires (RFC
+// 6762 section 10) and resolves DNS-SD service instances from them
+type Cache struct {
+	mu      sync.Mutex
+	records map[cacheKey]*cacheEntry
+	now     func() time.Time
+}
+
+type cacheKey struct {
+	name   string
+	rrtype uint16
+	value  string
+}
+
+type cacheEntry struct {
+	record   dnsRecord
+	received time.Time
+	expires  time.Time
+
+	// Number of refresh queries sent for the record, see refreshDue
+	refreshes int
+}
+
+// Instance is a resolved DNS-SD service instance
+type Instance struct {
+	// Full service instance name, e.g.
+	// "2906C908D115D362-8FC7772401CD0696._matter._tcp.local"
+	Name      string
+	Host      string
+	Port      uint16
+	Addresses []net.IP
+	TXT       []string
+	// Expiry of the SRV record, unless it is refreshed
+	Expires time.Time
+}
+
+// Cached record types, other records aren't needed to resolve instances
+var cachedTypes = map[uint16]bool{
+	dnsTypeA:    true,
+	dnsTypeAAAA: true,
+	dnsTypePTR:  true,
+	dnsTypeSRV:  true,
+	dnsTypeTXT:  true,
+}
+
+func newCache() *Cache {
+	return &Cache{
+		records: make(map[cacheKey]*cacheEntry),
+		now:     time.Now,
+	}
+}
+
+// add caches the records of a response
+func (c *Cache) add(records []dnsRecord) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	c.prune(now)
+
+	for _, r := range records {
+		if !cachedTypes[r.Type] || r.Class&classMask != 1 {
+			continue
+		}
+		// The data of parsed records points into the receive buffer
+		r.Data = nil
+		key := cacheKey{name: strings.ToLower(r.Name), rrtype: r.Type, value: r.Value}
+
+		if r.TTL == 0 {
+			// Goodbye packets remove a record after a second (10.1)
+			if entry, ok := c.records[key]; ok {
+				entry.expires = now.Add(time.Second)
+			}
+			continue
+		}
+
+		if r.Class&classTopBit != 0 {
+			// Cache-flush: other records of the name and type received more
+			// than a second ago are outdated (10.2)
+			for k, entry := range c.records {
+				flush := k.name == key.name && k.rrtype == key.rrtype && k != key && now.Sub(entry.received) > time.Second
+				if flush && entry.expires.After(now.Add(time.Second)) {
+					entry.expires = now.Add(time.Second)
+				}
+			}
+		}
+
+		c.records[key] = &cacheEntry{
+			record:   r,
+			received: now,
+			expires:  now.Add(time.Duration(r.TTL) * time.Second),
+		}
+	}
+}
+
+// prune removes expired records. The caller holds c.mu.
+func (c *Cache) prune(now time.Time) {
+	for key, entry := range c.records {
+		if !now.Before(entry.expires) {
+			delete(c.records, key)
+		}
+	}
+}
+
+// lookup returns the unexpired records of a name and type, sorted by value.
+// The caller holds c.mu.
+func (c *Cache) lookup(name string, rrtype uint16, now time.Time) []*cacheEntry {
+	name = strings.ToLower(name)
+	var entries []*cacheEntry
+	for key, entry := range c.records {
+		if key.name == name && key.rrtype == rrtype && now.Before(entry.expires) {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].record.Value < entries[j].record.Value
+	})
+	return entries
+}
+
+// Instances returns the resolved instances of a service type, e.g.
+// "_matter._tcp"
+func (c *Cache) Instances(serviceType string) []Instance {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	var instances []Instance
+	for _, ptr := range c.lookup(serviceType+".local", dnsTypePTR, now) {
+		if instance, ok := c.resolve(ptr.record.Value, now); ok {
+			instances = append(instances, instance)
+		}
+	}
+	return instances
+}
+
+// Resolve resolves a service instance by its full name
+func (c *Cache) Resolve(name string) (Instance, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.resolve(name, c.now())
+}
+
+// ResolveNode resolves the operational instance of a node on the fabric with
+// the compressed fabric ID
+func (c *Cache) ResolveNode(compressedFabricID, nodeID uint64) (Instance, bool) {
+	return c.Resolve(OperationalService(compressedFabricID, nodeID, 0).name())
+}
+
+// resolve follows the SRV record of an instance to the host's addresses.
+// Instances without known addresses are not resolved. The caller holds c.mu.
+func (c *Cache) resolve(name string, now time.Time) (Instance, bool) {
+	srvs := c.lookup(name, dnsTypeSRV, now)
+	if len(srvs) == 0 {
+		return Instance{}, false
+	}
+	srv := srvs[0]
+	port, target, err := parseSRVValue(srv.record.Value)
+	if err != nil {
+		return Instance{}, false
+	}
+
+	instance := Instance{
+		Name:    name,
+		Host:    target,
+		Port:    port,
+		Expires: srv.expires,
+	}
+	for _, rrtype := range []uint16{dnsTypeAAAA, dnsTypeA} {
+		for _, entry := range c.lookup(target, rrtype, now) {
+			if ip := net.ParseIP(entry.record.Value); ip != nil {
+				instance.Addresses = append(instance.Addresses, ip)
+			}
+		}
+	}
+	if len(instance.Addresses) == 0 {
+		return Instance{}, false
+	}
+	if txts := c.lookup(name, dnsTypeTXT, now); len(txts) > 0 {
+		instance.TXT = parseTXT([]byte(txts[0].record.Value))
+	}
+	return instance, true
+}
+
+// parseSRVValue parses the port and target of an SRV record value as set by
+// parseRecord
+func parseSRVValue(value string) (uint16, string, error) {
+	fields := strings.Fields(value)
+	if len(fields) != 4 {
+		return 0, "", fmt.Errorf("invalid SRV record %q", value)
+	}
+	port, err := strconv.ParseUint(fields[2], 10, 16)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid SRV record %q", value)
+	}
+	return uint16(port), fields[3], nil
+}
+
+// parseTXT splits TXT record data into its strings
+func parseTXT(data []byte) []string {
+	var txt []string
+	for len(data) > 0 {
+		length := int(data[0])
+		if 1+length > len(data) {
+			break
+		}
+		txt = append(txt, string(data[1:1+length]))
+		data = data[1+length:]
+	}
+	return txt
+}
@@ -0,0 +1,160 @@
+package mdns
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+)
+
+// newBrowseTestServer returns a server browsing _matter._tcp with a fake
+// clock, which has seen the response of a node to a browse query
+func newBrowseTestServer(t *testing.T) (*Server, *time.Time) {
+	t.Helper()
+	node, _ := newProbeTestServer(t)
+	query := &dnsMessage{Questions: []dnsQuestion{{Name: "_matter._tcp.local", Type: dnsTypePTR, Class: 1}}}
+	buf, err := encodeDNSMessage(node.buildResponse(query, false))
+	if err != nil {
+		t.Fatalf("Failed to encode response: %v", err)
+	}
+	response, err := parseDNSMessage(buf)
+	if err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	server, err := NewServer(&Config{
+		Zone:   NewMockZone(),
+		Logger: logger.NewConsoleLogger(logger.ErrorLevel),
+		Browse: []string{OperationalServiceType},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	now := time.Now()
+	server.cache.now = func() time.Time { return now }
+
+	server.handleResponse(response, &net.UDPAddr{IP: net.ParseIP("192.168.1.100"), Port: mdnsPort})
+	return server, &now
+}
+
+func TestCacheResolvesOperationalNode(t *testing.T) {
+	server, now := newBrowseTestServer(t)
+	cache := server.Cache()
+
+	instance, ok := cache.ResolveNode(0x2906C908D115D362, 1)
+	if !ok {
+		t.Fatal("Expected the node to be resolved")
+	}
+	if instance.Host != "test.local" || instance.Port != MatterPort {
+		t.Errorf("Expected test.local:%d, got %s:%d", MatterPort, instance.Host, instance.Port)
+	}
+	if len(instance.Addresses) != 1 || !instance.Addresses[0].Equal(net.ParseIP("192.168.1.100")) {
+		t.Errorf("Expected address 192.168.1.100, got %v", instance.Addresses)
+	}
+	if !strings.Contains(strings.Join(instance.TXT, " "), "SII=500") {
+		t.Errorf("Expected session parameters in TXT, got %v", instance.TXT)
+	}
+	if instances := cache.Instances(OperationalServiceType); len(instances) != 1 {
+		t.Errorf("Expected 1 browsed instance, got %d", len(instances))
+	}
+	if _, ok := cache.ResolveNode(0x2906C908D115D362, 2); ok {
+		t.Error("Expected unknown node not to be resolved")
+	}
+
+	// SRV and address records expire with their TTL
+	*now = now.Add(hostRecordTTL * time.Second)
+	if _, ok := cache.ResolveNode(0x2906C908D115D362, 1); ok {
+		t.Error("Expected expired node not to be resolved")
+	}
+}
+
+func TestCacheFlushAndGoodbye(t *testing.T) {
+	cache := newCache()
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	record := func(ip string, ttl uint32, class uint16) dnsRecord {
+		return dnsRecord{Name: "Host.local", Type: dnsTypeA, Class: class, TTL: ttl, Value: ip}
+	}
+	addresses := func() []string {
+		cache.mu.Lock()
+		defer cache.mu.Unlock()
+		var values []string
+		for _, entry := range cache.lookup("host.local", dnsTypeA, now) {
+			values = append(values, entry.record.Value)
+		}
+		return values
+	}
+
+	// Records in the same response don't flush each other
+	cache.add([]dnsRecord{record("10.0.0.1", 120, 1|classTopBit), record("10.0.0.2", 120, 1|classTopBit)})
+	if got := addresses(); len(got) != 2 {
+		t.Fatalf("Expected 2 addresses, got %v", got)
+	}
+
+	now = now.Add(5 * time.Second)
+	cache.add([]dnsRecord{record("10.0.0.3", 120, 1|classTopBit)})
+	now = now.Add(time.Second)
+	if got := addresses(); len(got) != 1 || got[0] != "10.0.0.3" {
+		t.Errorf("Expected cache-flush to leave 10.0.0.3, got %v", got)
+	}
+
+	// Shared records are kept next to each other
+	cache.add([]dnsRecord{record("10.0.0.4", 120, 1)})
+	if got := addresses(); len(got) != 2 {
+		t.Errorf("Expected 2 addresses, got %v", got)
+	}
+
+	cache.add([]dnsRecord{record("10.0.0.3", 0, 1|classTopBit)})
+	if got := addresses(); len(got) != 2 {
+		t.Errorf("Expected goodbye to keep the record for a second, got %v", got)
+	}
+	now = now.Add(time.Second)
+	if got := addresses(); len(got) != 1 || got[0] != "10.0.0.4" {
+		t.Errorf("Expected goodbye to remove 10.0.0.3, got %v", got)
+	}
+}
+
+func TestBrowseQuery(t *testing.T) {
+	server, now := newBrowseTestServer(t)
+
+	query := server.browseQuery(true, *now)
+	if query == nil || len(query.Questions) != 1 || query.Questions[0].Name != "_matter._tcp.local" {
+		t.Fatalf("Expected a PTR query for _matter._tcp.local, got %+v", query)
+	}
+	if len(query.Answers) != 1 || query.Answers[0].TTL != otherRecordTTL {
+		t.Errorf("Expected the known PTR record as answer, got %+v", query.Answers)
+	}
+	if _, err := encodeDNSMessage(query); err != nil {
+		t.Errorf("Failed to encode query: %v", err)
+	}
+
+	if query := server.browseQuery(false, *now); query != nil {
+		t.Errorf("Expected nothing to ask for, got %+v", query)
+	}
+
+	// The SRV and address records are refreshed at 80% of their TTL, once
+	refreshAt := now.Add(hostRecordTTL * time.Second * 80 / 100)
+	query = server.browseQuery(false, refreshAt)
+	if query == nil {
+		t.Fatal("Expected refresh queries")
+	}
+	asked := make(map[string]bool)
+	for _, q := range query.Questions {
+		asked[strings.ToLower(q.Name)+"/"+dnsTypeToString(q.Type)] = true
+	}
+	if !asked["2906c908d115d362-0000000000000001._matter._tcp.local/SRV"] || !asked["test.local/A"] {
+		t.Errorf("Expected SRV and A refresh questions, got %+v", query.Questions)
+	}
+	if query := server.browseQuery(false, refreshAt); query != nil {
+		t.Errorf("Expected each refresh to be sent once, got %+v", query.Questions)
+	}
+
+	// Known answers need more than half of their TTL left
+	query = server.browseQuery(true, now.Add(otherRecordTTL*time.Second*6/10))
+	if query == nil || len(query.Answers) != 0 {
+		t.Errorf("Expected no known answers, got %+v", query)
+	}
+}
@@ -0,0 +1,57 @@
+package mdns
+
+import (
+	"net"
+	"testing"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+)
+
+// FuzzParseDNSMessage feeds packets to the parser and the browse cache, as
+// any host on the link can send them. Parsed messages must encode and parse
+// back to the same number of records.
+func FuzzParseDNSMessage(f *testing.F) {
+	f.Add(readOperationalResponse(f))
+	query, _ := encodeDNSMessage(&dnsMessage{Questions: []dnsQuestion{{Name: "_matter._tcp.local", Type: dnsTypePTR, Class: 1}}})
+	f.Add(query)
+	// A name pointing at itself, one looping through a pointer back to its
+	// start and one running past the packet
+	f.Add([]byte{0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0xc0, 12, 0, 1, 0, 1})
+	f.Add([]byte{0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 1, 'a', 0xc0, 12, 0, 1, 0, 1})
+	f.Add([]byte{0, 0, 0x84, 0, 0, 0, 0, 1, 0, 0, 0, 0, 4, 'n', 'o', 'd', 'e'})
+
+	server, err := NewServer(&Config{
+		Zone:   NewMockZone(),
+		Logger: logger.NewConsoleLogger(logger.ErrorLevel),
+		Browse: []string{OperationalServiceType, ServerServiceType},
+	})
+	if err != nil {
+		f.Fatalf("Failed to create server: %v", err)
+	}
+	from := &net.UDPAddr{IP: net.ParseIP("192.168.1.100"), Port: mdnsPort}
+
+	f.Fuzz(func(t *testing.T, packet []byte) {
+		msg, err := parseDNSMessage(packet)
+		if err != nil {
+			return
+		}
+		if msg.Response {
+			server.handleResponse(msg, from)
+			server.Cache().Instances(OperationalServiceType)
+			server.Cache().Instances(ServerServiceType)
+		}
+
+		buf, err := encodeDNSMessage(msg)
+		if err != nil {
+			return
+		}
+		again, err := parseDNSMessage(buf)
+		if err != nil {
+			t.Fatalf("Failed to parse the encoded message: %v", err)
+		}
+		if len(again.Questions) != len(msg.Questions) || len(again.Answers) != len(msg.Answers) ||
+			len(again.Authority) != len(msg.Authority) || len(again.Additional) != len(msg.Additional) {
+			t.Errorf("Expected the records to round-trip, got %+v, want %+v", again, msg)
+		}
+	})
+}
@@ -0,0 +1,82 @@
+package mdns
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"time"
+)
+
+// One-shot queries (RFC 6762 5.1) are sent from an ephemeral port, so
+// Lookup works next to a responder owning port 5353, e.g. Avahi, and are
+// answered directly by the responders. Variables so tests can redirect and
+// speed them up.
+var (
+	lookupGroup    = &net.UDPAddr{IP: net.ParseIP(mdnsGroupIPv4), Port: mdnsPort}
+	lookupInterval = time.Second
+)
+
+// Lookup queries the instances of a service type, e.g. "_matter._tcp", and
+// returns the ones resolved until ctx ends. The query is repeated every
+// second in case packets are lost. It is sent over IPv4 only; the responses
+// carry the IPv6 addresses of the hosts as well.
+func Lookup(ctx context.Context, serviceType string) ([]Instance, error) {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen: %w", err)
+	}
+	defer conn.Close()
+
+	id := uint16(rand.N(1 << 16))
+	query, err := encodeDNSMessage(&dnsMessage{
+		ID:        id,
+		Questions: []dnsQuestion{{Name: serviceType + ".local", Type: dnsTypePTR, Class: 1}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.WriteToUDP(query, lookupGroup); err != nil {
+		return nil, fmt.Errorf("failed to send query: %w", err)
+	}
+
+	// Repeat the query until ctx ends, which unblocks the reads below
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(lookupInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				conn.SetReadDeadline(time.Now())
+				return
+			case <-ticker.C:
+				conn.WriteToUDP(query, lookupGroup)
+			}
+		}
+	}()
+
+	cache := newCache()
+	buf := make([]byte, 65536)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			return nil, fmt.Errorf("failed to receive responses: %w", err)
+		}
+
+		msg, err := parseDNSMessage(buf[:n])
+		if err != nil || !msg.Response || msg.ID != id {
+			continue
+		}
+		cache.add(msg.Answers)
+		cache.add(msg.Additional)
+	}
+
+	return cache.Instances(serviceType), nil
+}
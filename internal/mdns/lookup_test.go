@@ -0,0 +1,65 @@
+package mdns
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLookup(t *testing.T) {
+	server, zone := newProbeTestServer(t)
+	zone.AddService(ServerService(0x2906C908D115D362, 5580, 5581, 11, 1, ServerFeatures{Bluetooth: true, OTA: true}))
+
+	// A responder on loopback stands in for the mDNS group
+	responder, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer responder.Close()
+	group, interval := lookupGroup, lookupInterval
+	lookupGroup, lookupInterval = responder.LocalAddr().(*net.UDPAddr), 50*time.Millisecond
+	t.Cleanup(func() { lookupGroup, lookupInterval = group, interval })
+
+	queries := make(chan *dnsMessage, 10)
+	go func() {
+		buf := make([]byte, 9000)
+		for {
+			n, from, err := responder.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			msg, err := parseDNSMessage(buf[:n])
+			if err != nil {
+				continue
+			}
+			queries <- msg
+			server.handleQuery(msg, from, responder, false)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	instances, err := Lookup(ctx, ServerServiceType)
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+
+	if len(queries) < 2 {
+		t.Errorf("Expected the query to be repeated, got %d queries", len(queries))
+	}
+	if len(instances) != 1 {
+		t.Fatalf("Expected one instance, got %+v", instances)
+	}
+	instance := instances[0]
+	if instance.Name != "2906c908d115d362._matter-server._tcp.local" || instance.Port != 5580 || instance.Host != "test.local" {
+		t.Errorf("Unexpected instance %+v", instance)
+	}
+	if len(instance.Addresses) != 1 || !instance.Addresses[0].Equal(net.ParseIP("192.168.1.100")) {
+		t.Errorf("Expected the host's address, got %v", instance.Addresses)
+	}
+	if txt := strings.Join(instance.TXT, " "); txt != "schema_version=11 min_schema_version=1 path=/ws port=5580 tls=0 grpc_port=5581 bluetooth=1 thread=0 ota=1" {
+		t.Errorf("Unexpected TXT %q", txt)
+	}
+}
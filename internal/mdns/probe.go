@@ -0,0 +1,307 @@
+package mdns
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+)
+
+// ProbedZone is a zone whose unique records are probed for on start and
+// announced once no other host claims them (RFC 6762 section 8). Answers
+// are only given after probing succeeded.
+type ProbedZone interface {
+	Zone
+	// AllRecords returns every record of the zone
+	AllRecords() []Record
+	// GetHostname returns the hostname the address records are for
+	GetHostname() string
+	// Rename picks a new hostname after a conflict and returns it
+	Rename() string
+}
+
+// Timing of probes and announcements (RFC 6762 8.1 and 8.3), variables so
+// tests can shorten them
+var (
+	probeWait        = 250 * time.Millisecond
+	probeInterval    = 250 * time.Millisecond
+	announceInterval = time.Second
+)
+
+const (
+	probeCount    = 3
+	announceCount = 2
+)
+
+// startAdvertising probes for and announces the records of zones
+// implementing ProbedZone
+func (s *Server) startAdvertising() {
+	zone, ok := s.config.Zone.(ProbedZone)
+	if !ok {
+		return
+	}
+
+	s.probing.Store(true)
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	s.conflicts = make(chan struct{}, 1)
+	go s.advertise(zone)
+}
+
+// stopAdvertising stops probing and announcing and sends goodbye packets
+// for the announced records
+func (s *Server) stopAdvertising() {
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+	s.stop = nil
+
+	s.announcedMu.Lock()
+	records := s.announced
+	s.announced = nil
+	s.announcedMu.Unlock()
+
+	if len(records) > 0 {
+		goodbye := s.announcement(records)
+		for i := range goodbye.Answers {
+			goodbye.Answers[i].TTL = 0
+		}
+		s.multicast(goodbye)
+	}
+}
+
+// advertise probes for the zone's records, renaming the host until no other
+// host claims its name, and announces them. Conflicts found later start
+// over with a new name.
+func (s *Server) advertise(zone ProbedZone) {
+	defer close(s.done)
+
+	// A random delay keeps hosts started together from probing in sync
+	if !s.wait(rand.N(probeWait)) {
+		return
+	}
+
+	for {
+		conflict, stopped := s.probe(zone)
+		if stopped {
+			return
+		}
+		if conflict {
+			s.rename(zone)
+			continue
+		}
+
+		s.probing.Store(false)
+		if !s.announce(zone) {
+			return
+		}
+
+		select {
+		case <-s.stop:
+			return
+		case <-s.conflicts:
+			s.probing.Store(true)
+			s.rename(zone)
+		}
+	}
+}
+
+// probe sends the probe queries and reports whether another host answered
+// for one of the records
+func (s *Server) probe(zone ProbedZone) (conflict, stopped bool) {
+	records := zone.AllRecords()
+	for i := 0; i < probeCount; i++ {
+		s.multicast(s.probeQuery(records, i == 0))
+
+		select {
+		case <-s.stop:
+			return false, true
+		case <-s.conflicts:
+			return true, false
+		case <-time.After(probeInterval):
+		}
+	}
+	return false, false
+}
+
+// announce sends unsolicited responses with all records. It returns false
+// when the server was stopped.
+func (s *Server) announce(zone ProbedZone) bool {
+	records := zone.AllRecords()
+
+	s.announcedMu.Lock()
+	s.announced = records
+	s.announcedMu.Unlock()
+
+	for i := 0; i < announceCount; i++ {
+		if i > 0 && !s.wait(announceInterval) {
+			return false
+		}
+		announcement := s.announcement(records)
+		s.multicast(announcement)
+		s.noteMulticast(announcement.Answers)
+	}
+
+	s.logger.Info("mDNS records announced", logger.Int("records", len(records)))
+	return true
+}
+
+func (s *Server) rename(zone ProbedZone) {
+	// Conflicts with the previous name don't matter anymore
+	select {
+	case <-s.conflicts:
+	default:
+	}
+
+	hostname := zone.Rename()
+	s.logger.Warn("mDNS hostname is used by another host, renamed", logger.String("hostname", hostname))
+}
+
+// wait sleeps for d and returns false if the server is stopped meanwhile
+func (s *Server) wait(d time.Duration) bool {
+	select {
+	case <-s.stop:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// probeQuery asks for any record of each unique name, with the proposed
+// records in the authority section for simultaneous probe tiebreaking. The
+// first probe asks for unicast responses.
+func (s *Server) probeQuery(records []Record, first bool) *dnsMessage {
+	msg := &dnsMessage{}
+	class := uint16(1)
+	if first {
+		class |= classTopBit
+	}
+
+	seen := make(map[string]bool)
+	for _, r := range records {
+		hdr := r.Header()
+		if hdr.Type == dnsTypePTR {
+			continue
+		}
+		if name := strings.ToLower(hdr.Name); !seen[name] {
+			seen[name] = true
+			msg.Questions = append(msg.Questions, dnsQuestion{Name: hdr.Name, Type: dnsTypeANY, Class: class})
+		}
+
+		// Probes never carry the cache-flush bit
+		record := s.encodeRecord(r, false)
+		record.Class &^= classTopBit
+		msg.Authority = append(msg.Authority, record)
+	}
+	return msg
+}
+
+// announcement returns an unsolicited response with records
+func (s *Server) announcement(records []Record) *dnsMessage {
+	msg := &dnsMessage{Response: true, Authoritative: true}
+	for _, r := range records {
+		msg.Answers = append(msg.Answers, s.encodeRecord(r, false))
+	}
+	return msg
+}
+
+// handleResponse caches the records of responses and checks them for
+// records conflicting with the zone's unique records, i.e. records of the
+// same name and type with other data
+func (s *Server) handleResponse(msg *dnsMessage, from *net.UDPAddr) {
+	if from.Port != mdnsPort {
+		// Responses not from port 5353 are ignored (RFC 6762 section 6)
+		return
+	}
+	s.cache.add(msg.Answers)
+	s.cache.add(msg.Additional)
+
+	zone, ok := s.config.Zone.(ProbedZone)
+	if !ok {
+		return
+	}
+
+	own := make(map[string]map[string]bool)
+	for _, r := range zone.AllRecords() {
+		hdr := r.Header()
+		if hdr.Type == dnsTypePTR {
+			continue
+		}
+		key := fmt.Sprintf("%s/%d", strings.ToLower(hdr.Name), hdr.Type)
+		if own[key] == nil {
+			own[key] = make(map[string]bool)
+		}
+		own[key][recordValue(r)] = true
+	}
+
+	hostname := strings.ToLower(zone.GetHostname())
+	for _, answer := range msg.Answers {
+		values, ours := own[fmt.Sprintf("%s/%d", strings.ToLower(answer.Name), answer.Type)]
+		if !ours || values[answer.Value] {
+			continue
+		}
+
+		if strings.ToLower(answer.Name) != hostname {
+			// Service instance names are derived from the fabric and node
+			// IDs, so they can't be renamed
+			s.logger.Warn("Another host advertises an mDNS service of ours",
+				logger.String("name", answer.Name),
+				logger.String("from", from.String()),
+			)
+			continue
+		}
+
+		s.logger.Debug("mDNS hostname conflict", logger.String("name", answer.Name), logger.String("from", from.String()))
+		select {
+		case s.conflicts <- struct{}{}:
+		default:
+		}
+		return
+	}
+}
+
+// multicast sends a message to the mDNS group on each listening connection
+func (s *Server) multicast(msg *dnsMessage) {
+	buf, err := encodeDNSMessage(msg)
+	if err != nil {
+		s.logger.Warn("Failed to encode mDNS message", logger.ErrorField(err))
+		return
+	}
+
+	for _, sock := range s.sockets {
+		group := mdnsGroupIPv4
+		if sock.ipv6 {
+			group = mdnsGroupIPv6
+		}
+		addr := &net.UDPAddr{IP: net.ParseIP(group), Port: mdnsPort}
+		if sock.iface != nil && sock.ipv6 {
+			addr.Zone = sock.iface.Name
+		}
+		if _, err := sock.conn.WriteToUDP(buf, addr); err != nil {
+			s.logger.Debug("Failed to send mDNS message", logger.String("group", group), logger.ErrorField(err))
+		}
+	}
+}
+
+// recordValue returns the comparable form of a record's data, matching
+// dnsRecord.Value of parsed records
+func recordValue(r Record) string {
+	switch rec := r.(type) {
+	case *A:
+		return rec.A.String()
+	case *AAAA:
+		return rec.AAAA.String()
+	case *PTR:
+		return strings.ToLower(rec.Ptr)
+	case *SRV:
+		return fmt.Sprintf("%d %d %d %s", rec.Priority, rec.Weight, rec.Port, strings.ToLower(rec.Target))
+	case *TXT:
+		return string(encodeTXT(rec.Txt))
+	}
+	return ""
+}
@@ -0,0 +1,141 @@
+package mdns
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+)
+
+// shortTimings speeds up probing and announcing for a test
+func shortTimings(t *testing.T) {
+	t.Helper()
+	wait, probe, announce := probeWait, probeInterval, announceInterval
+	probeWait, probeInterval, announceInterval = time.Millisecond, 20*time.Millisecond, time.Millisecond
+	t.Cleanup(func() {
+		probeWait, probeInterval, announceInterval = wait, probe, announce
+	})
+}
+
+func newProbeTestServer(t *testing.T) (*Server, *MatterZone) {
+	t.Helper()
+	log := logger.NewConsoleLogger(logger.ErrorLevel)
+	zone := NewMatterZone("test.local", log)
+	zone.ips = []net.IP{net.ParseIP("192.168.1.100")}
+	zone.AddService(OperationalService(0x2906C908D115D362, 1, MatterPort))
+
+	server, err := NewServer(&Config{Zone: zone, Logger: log})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	return server, zone
+}
+
+// waitFor polls cond until it holds or a second passed
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestProbeConflictRenames(t *testing.T) {
+	shortTimings(t)
+	server, zone := newProbeTestServer(t)
+	peer := &net.UDPAddr{IP: net.ParseIP("192.168.1.7"), Port: mdnsPort}
+
+	server.startAdvertising()
+	t.Cleanup(func() { server.Shutdown() })
+	if !server.probing.Load() {
+		t.Fatal("Expected the server to probe first")
+	}
+	query := &dnsMessage{Questions: []dnsQuestion{{Name: "test.local", Type: dnsTypeA, Class: 1}}}
+	if err := server.handleQuery(query, peer, nil, false); err != nil {
+		t.Fatalf("Expected queries to be ignored while probing, got %v", err)
+	}
+
+	// Another host answers for our hostname with its own address
+	claim := &dnsMessage{Response: true, Answers: []dnsRecord{{Name: "TEST.local", Type: dnsTypeA, Class: 1 | classTopBit, Value: "192.168.1.7"}}}
+	server.handleResponse(claim, peer)
+	waitFor(t, "the rename", func() bool { return zone.GetHostname() == "test-2.local" })
+	waitFor(t, "probing to finish", func() bool { return !server.probing.Load() })
+
+	// The SRV record points at the new name
+	records := zone.Records(Question{Name: "2906C908D115D362-0000000000000001._matter._tcp.local", Type: dnsTypeSRV})
+	if len(records) != 1 || records[0].(*SRV).Target != "test-2.local" {
+		t.Errorf("Expected SRV record for test-2.local, got %v", records)
+	}
+
+	// Our own announcements and responses from other ports aren't conflicts
+	buf, err := encodeDNSMessage(server.announcement(zone.AllRecords()))
+	if err != nil {
+		t.Fatalf("Failed to encode announcement: %v", err)
+	}
+	own, err := parseDNSMessage(buf)
+	if err != nil {
+		t.Fatalf("Failed to parse announcement: %v", err)
+	}
+	server.handleResponse(own, peer)
+	server.handleResponse(claim, &net.UDPAddr{IP: peer.IP, Port: 40000})
+	select {
+	case <-server.conflicts:
+		t.Error("Expected no conflict")
+	default:
+	}
+
+	// A later conflict starts over with another name
+	claim.Answers[0].Name = "test-2.local"
+	server.handleResponse(claim, peer)
+	waitFor(t, "the second rename", func() bool { return zone.GetHostname() == "test-3.local" })
+}
+
+func TestProbeAndAnnouncementMessages(t *testing.T) {
+	server, zone := newProbeTestServer(t)
+	records := zone.AllRecords()
+
+	probe := server.probeQuery(records, true)
+	// The hostname and the service instance are probed for
+	if len(probe.Questions) != 2 || probe.Questions[0].Type != dnsTypeANY || probe.Questions[0].Class != 1|classTopBit {
+		t.Errorf("Expected QU questions of type ANY for the unique names, got %+v", probe.Questions)
+	}
+	for _, r := range probe.Authority {
+		if r.Type == dnsTypePTR || r.Class&classTopBit != 0 {
+			t.Errorf("Expected unique authority records without cache-flush bit, got %+v", r)
+		}
+	}
+	if second := server.probeQuery(records, false); second.Questions[0].Class != 1 {
+		t.Errorf("Expected QM questions in later probes, got %+v", second.Questions)
+	}
+
+	// Announcements survive encoding and parsing, with comparable values
+	buf, err := encodeDNSMessage(server.announcement(records))
+	if err != nil {
+		t.Fatalf("Failed to encode announcement: %v", err)
+	}
+	msg, err := parseDNSMessage(buf)
+	if err != nil {
+		t.Fatalf("Failed to parse announcement: %v", err)
+	}
+	if !msg.Response || len(msg.Answers) != len(records) {
+		t.Fatalf("Expected %d answers, got %+v", len(records), msg)
+	}
+	for i, r := range records {
+		if msg.Answers[i].Value != recordValue(r) {
+			t.Errorf("Expected value %q, got %q", recordValue(r), msg.Answers[i].Value)
+		}
+	}
+}
+
+func TestParseNameRejectsPointerLoops(t *testing.T) {
+	// A pointer to itself
+	buf := make([]byte, 12, 14)
+	buf = append(buf, 0xc0, 12)
+	if _, _, err := parseName(buf, 12); err == nil {
+		t.Error("Expected error for a name pointing at itself")
+	}
+}
@@ -1,9 +1,11 @@
 package mdns
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -22,13 +24,55 @@ const (
 	dnsTypePTR  = 12
 	dnsTypeTXT  = 16
 	dnsTypeSRV  = 33
+	dnsTypeANY  = 255
+
+	// The top bit of the class is the cache-flush bit in records and the
+	// unicast-response (QU) bit in questions (RFC 6762 5.4 and 10.2)
+	classTopBit = 0x8000
+	classMask   = 0x7fff
+
+	// TTLs in responses to legacy unicast queries are capped (RFC 6762 6.7)
+	legacyUnicastTTL = 10
+)
+
+// AddressFamily selects the IP versions mDNS runs on
+type AddressFamily string
+
+const (
+	FamilyIPv4 AddressFamily = "ipv4"
+	FamilyIPv6 AddressFamily = "ipv6"
+	FamilyBoth AddressFamily = "both"
 )
 
+// ParseAddressFamily parses an address family name. An empty name selects
+// FamilyBoth.
+func ParseAddressFamily(name string) (AddressFamily, error) {
+	switch AddressFamily(name) {
+	case "", FamilyBoth:
+		return FamilyBoth, nil
+	case FamilyIPv4, FamilyIPv6:
+		return AddressFamily(name), nil
+	}
+	return "", fmt.Errorf("unknown address family %q", name)
+}
+
 // Config holds the configuration for the mDNS server
 type Config struct {
-	Interface *net.Interface
-	Logger    *logger.Logger
-	Zone      Zone
+	// Interfaces to run on with a socket each, so packets leave with the
+	// interface's source address. Without interfaces the system picks one.
+	Interfaces []*net.Interface
+	// Family selects IPv4, IPv6 or both, the default
+	Family AddressFamily
+	Logger *logger.Logger
+	Zone   Zone
+
+	// Service types, e.g. "_matter._tcp", queried continuously so the
+	// cache knows their instances
+	Browse []string
+
+	// Supervise runs the receive loops, restarting them when they fail or
+	// panic until ctx is done. Without it they run in plain goroutines.
+	Supervise func(ctx context.Context, name string, loop func(ctx context.Context) error)
 }
 
 // Zone defines the DNS records that the server will respond to
@@ -116,9 +160,29 @@ func (r *SRV) String() string {
 type Server struct {
 	config   *Config
 	shutdown atomic.Bool
-	ipv4conn *net.UDPConn
-	ipv6conn *net.UDPConn
+	sockets  []*socket
 	logger   *logger.Logger
+
+	// Ends the supervision of the receive loops on shutdown
+	stopRecv context.CancelFunc
+
+	// Probing and announcing of ProbedZone records. Queries are not
+	// answered while probing.
+	probing     atomic.Bool
+	conflicts   chan struct{}
+	stop        chan struct{}
+	done        chan struct{}
+	announced   []Record
+	announcedMu sync.Mutex
+
+	// Records of responses, kept up to date for the browsed service types
+	cache      *Cache
+	browseStop chan struct{}
+	browseDone chan struct{}
+
+	// When records were multicast last, see multicastRecently
+	multicastAt map[string]time.Time
+	multicastMu sync.Mutex
 }
 
 // NewServer creates a new mDNS server
@@ -134,49 +198,80 @@ func NewServer(config *Config) (*Server, error) {
 	return &Server{
 		config: config,
 		logger: config.Logger,
+		cache:  newCache(),
+
+		multicastAt: make(map[string]time.Time),
 	}, nil
 }
 
+// socket is a multicast socket joined on one interface, or on the one the
+// system picks if iface is nil
+type socket struct {
+	conn  *net.UDPConn
+	iface *net.Interface
+	ipv6  bool
+}
+
 // Start begins listening for mDNS queries
 func (s *Server) Start() error {
-	var err error
-
-	// Setup IPv4 listener
-	if s.ipv4conn, err = s.setupIPv4(); err != nil {
-		return fmt.Errorf("failed to setup IPv4: %w", err)
-	}
-
-	// Setup IPv6 listener
-	if s.ipv6conn, err = s.setupIPv6(); err != nil {
-		s.ipv4conn.Close()
-		return fmt.Errorf("failed to setup IPv6: %w", err)
+	families := []bool{false, true}
+	switch s.config.Family {
+	case FamilyIPv4:
+		families = []bool{false}
+	case FamilyIPv6:
+		families = []bool{true}
+	}
+	interfaces := s.config.Interfaces
+	if len(interfaces) == 0 {
+		interfaces = []*net.Interface{nil}
+	}
+
+	for _, ipv6 := range families {
+		for _, iface := range interfaces {
+			conn, err := listen(iface, ipv6)
+			if err != nil {
+				s.closeSockets()
+				return err
+			}
+			s.sockets = append(s.sockets, &socket{conn: conn, iface: iface, ipv6: ipv6})
+		}
 	}
 
 	// Start receiving goroutines
-	go s.recv(s.ipv4conn, false)
-	go s.recv(s.ipv6conn, true)
+	ctx, cancel := context.WithCancel(context.Background())
+	s.stopRecv = cancel
+	for _, sock := range s.sockets {
+		loop := func(ctx context.Context) error {
+			s.recv(sock)
+			return nil
+		}
+		if s.config.Supervise != nil {
+			go s.config.Supervise(ctx, "mdns_receive_"+sock.name(), loop)
+		} else {
+			go loop(ctx)
+		}
+	}
+	s.startAdvertising()
+	s.startBrowsing()
 
-	s.logger.Info("mDNS server started", logger.String("interface", s.interfaceName()))
+	s.logger.Info("mDNS server started",
+		logger.String("interface", s.interfaceName()),
+		logger.String("family", string(s.family())),
+	)
 	return nil
 }
 
-// Shutdown stops the mDNS server
+// Shutdown stops the mDNS server after sending goodbye packets for the
+// announced records
 func (s *Server) Shutdown() error {
+	s.stopBrowsing()
+	s.stopAdvertising()
 	s.shutdown.Store(true)
-
-	var errs []error
-
-	if s.ipv4conn != nil {
-		if err := s.ipv4conn.Close(); err != nil {
-			errs = append(errs, err)
-		}
+	if s.stopRecv != nil {
+		s.stopRecv()
 	}
 
-	if s.ipv6conn != nil {
-		if err := s.ipv6conn.Close(); err != nil {
-			errs = append(errs, err)
-		}
-	}
+	errs := s.closeSockets()
 
 	s.logger.Info("mDNS server shutdown")
 
@@ -187,41 +282,78 @@ func (s *Server) Shutdown() error {
 	return nil
 }
 
-func (s *Server) setupIPv4() (*net.UDPConn, error) {
-	addr, err := net.ResolveUDPAddr("udp4", fmt.Sprintf("%s:%d", mdnsGroupIPv4, mdnsPort))
-	if err != nil {
-		return nil, err
+func (s *Server) closeSockets() []error {
+	var errs []error
+	for _, sock := range s.sockets {
+		if err := sock.conn.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	s.sockets = nil
+	return errs
+}
+
+// listen joins the mDNS group of an address family on an interface
+func listen(iface *net.Interface, ipv6 bool) (*net.UDPConn, error) {
+	network, group := "udp4", mdnsGroupIPv4
+	if ipv6 {
+		network, group = "udp6", mdnsGroupIPv6
 	}
 
-	conn, err := net.ListenMulticastUDP("udp4", s.config.Interface, addr)
+	conn, err := net.ListenMulticastUDP(network, iface, &net.UDPAddr{IP: net.ParseIP(group), Port: mdnsPort})
 	if err != nil {
-		return nil, err
+		name := "default interface"
+		if iface != nil {
+			name = iface.Name
+		}
+		return nil, fmt.Errorf("failed to setup %s on %s: %w", network, name, err)
 	}
-
 	return conn, nil
 }
 
-func (s *Server) setupIPv6() (*net.UDPConn, error) {
-	addr, err := net.ResolveUDPAddr("udp6", fmt.Sprintf("[%s]:%d", mdnsGroupIPv6, mdnsPort))
-	if err != nil {
-		return nil, err
+// name identifies the socket by address family and interface
+func (sock *socket) name() string {
+	name := "udp4"
+	if sock.ipv6 {
+		name = "udp6"
 	}
+	if sock.iface != nil {
+		name += "_" + sock.iface.Name
+	}
+	return name
+}
 
-	conn, err := net.ListenMulticastUDP("udp6", s.config.Interface, addr)
-	if err != nil {
-		return nil, err
+// accepts reports whether a packet was sent on the socket's interface. All
+// sockets bound to the group receive the packets of every interface, so
+// the source address decides: IPv6 link-local sources carry the interface,
+// other sources have to be on one of the interface's networks.
+func (sock *socket) accepts(from *net.UDPAddr) bool {
+	if sock.iface == nil {
+		return true
+	}
+	if from.Zone != "" {
+		return from.Zone == sock.iface.Name
 	}
 
-	return conn, nil
+	addrs, err := sock.iface.Addrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if network, ok := addr.(*net.IPNet); ok && network.Contains(from.IP) {
+			return true
+		}
+	}
+	return false
 }
 
-func (s *Server) recv(conn *net.UDPConn, ipv6 bool) {
+func (s *Server) recv(sock *socket) {
 	buf := make([]byte, 65536)
 
 	for !s.shutdown.Load() {
-		conn.SetReadDeadline(time.Now().Add(1 * time.Second))
+		sock.conn.SetReadDeadline(time.Now().Add(1 * time.Second))
 
-		n, from, err := conn.ReadFromUDP(buf)
+		n, from, err := sock.conn.ReadFromUDP(buf)
 		if err != nil {
 			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
 				continue
@@ -232,7 +364,10 @@ func (s *Server) recv(conn *net.UDPConn, ipv6 bool) {
 			continue
 		}
 
-		if err := s.parsePacket(buf[:n], from, conn, ipv6); err != nil {
+		if !sock.accepts(from) {
+			continue
+		}
+		if err := s.parsePacket(buf[:n], from, sock.conn, sock.ipv6); err != nil {
 			s.logger.Debug("Failed to parse packet", logger.ErrorField(err))
 		}
 	}
@@ -244,8 +379,9 @@ func (s *Server) parsePacket(buf []byte, from *net.UDPAddr, conn *net.UDPConn, i
 		return err
 	}
 
-	// Only handle queries
+	// Responses of other hosts may conflict with our records
 	if msg.Response {
+		s.handleResponse(msg, from)
 		return nil
 	}
 
@@ -262,46 +398,171 @@ func (s *Server) parsePacket(buf []byte, from *net.UDPAddr, conn *net.UDPConn, i
 	return s.handleQuery(msg, from, conn, ipv6)
 }
 
+// handleQuery answers a query. Queries from a port other than 5353 are
+// legacy unicast queries (e.g. from dig) and are answered directly, as are
+// questions with the QU bit for records multicast recently. Other responses
+// are multicast, so all caches on the link learn the records.
 func (s *Server) handleQuery(msg *dnsMessage, from *net.UDPAddr, conn *net.UDPConn, ipv6 bool) error {
-	if len(msg.Questions) == 0 {
+	// Records are only answered for once they are known to be unique
+	if len(msg.Questions) == 0 || s.probing.Load() {
 		return nil
 	}
 
+	legacy := from.Port != mdnsPort
+	response := s.buildResponse(msg, legacy)
+	if len(response.Answers) == 0 {
+		return nil
+	}
+	if legacy {
+		return s.sendResponse(response, from, conn)
+	}
+
+	// Questions with the QU bit are answered directly, unless the answers
+	// weren't multicast within a quarter of their TTL (RFC 6762 5.4)
+	unicast := true
+	for _, q := range msg.Questions {
+		if q.Class&classTopBit == 0 {
+			unicast = false
+		}
+	}
+	if unicast && s.multicastRecently(response.Answers) {
+		return s.sendResponse(response, from, conn)
+	}
+
+	group := mdnsGroupIPv4
+	if ipv6 {
+		group = mdnsGroupIPv6
+	}
+	if err := s.sendResponse(response, &net.UDPAddr{IP: net.ParseIP(group), Port: mdnsPort, Zone: from.Zone}, conn); err != nil {
+		return err
+	}
+	s.noteMulticast(response.Answers)
+	return nil
+}
+
+// noteMulticast remembers when records were multicast last
+func (s *Server) noteMulticast(records []dnsRecord) {
+	s.multicastMu.Lock()
+	defer s.multicastMu.Unlock()
+
+	now := time.Now()
+	for key, at := range s.multicastAt {
+		if now.Sub(at) > time.Duration(otherRecordTTL)*time.Second {
+			delete(s.multicastAt, key)
+		}
+	}
+	for _, r := range records {
+		s.multicastAt[sentRecordKey(r)] = now
+	}
+}
+
+// multicastRecently reports whether all records were multicast within the
+// last quarter of their TTL
+func (s *Server) multicastRecently(records []dnsRecord) bool {
+	s.multicastMu.Lock()
+	defer s.multicastMu.Unlock()
+
+	now := time.Now()
+	for _, r := range records {
+		at, ok := s.multicastAt[sentRecordKey(r)]
+		if !ok || now.Sub(at) > time.Duration(r.TTL)*time.Second/4 {
+			return false
+		}
+	}
+	return true
+}
+
+func sentRecordKey(r dnsRecord) string {
+	return fmt.Sprintf("%s/%d/%x", strings.ToLower(r.Name), r.Type, r.Data)
+}
+
+// buildResponse collects the answers to the questions of a query. Records
+// that help resolving the answers, the SRV and TXT records of PTR targets
+// and the addresses of SRV targets, are added as additional records.
+// Records the asker listed as known answers with at least half of their TTL
+// left are left out (RFC 6762 7.1).
+func (s *Server) buildResponse(msg *dnsMessage, legacy bool) *dnsMessage {
 	response := &dnsMessage{
-		ID:                 msg.ID,
-		Response:           true,
-		Opcode:             0,
-		Authoritative:      true,
-		Truncated:          false,
-		RecursionDesired:   false,
-		RecursionAvailable: false,
-		Rcode:              0,
+		Response:      true,
+		Authoritative: true,
+	}
+	if legacy {
+		// Legacy resolvers match the response by its ID and question
+		response.ID = msg.ID
+		response.Questions = msg.Questions
+	}
+
+	known := make(map[string]uint32)
+	for _, r := range msg.Answers {
+		known[fmt.Sprintf("%s/%d/%s", strings.ToLower(r.Name), r.Type, r.Value)] = r.TTL
+	}
+
+	seen := make(map[string]bool)
+	var answers, additional []Record
+	collect := func(records *[]Record, q Question) {
+		for _, r := range s.config.Zone.Records(q) {
+			hdr := r.Header()
+			ttl, ok := known[fmt.Sprintf("%s/%d/%s", strings.ToLower(hdr.Name), hdr.Type, recordValue(r))]
+			if ok && 2*uint64(ttl) >= uint64(hdr.TTL) {
+				continue
+			}
+			if key := strings.ToLower(r.String()); !seen[key] {
+				seen[key] = true
+				*records = append(*records, r)
+			}
+		}
 	}
 
 	for _, q := range msg.Questions {
-		question := Question{
-			Name:  q.Name,
-			Type:  q.Type,
-			Class: q.Class,
+		collect(&answers, Question{Name: q.Name, Type: q.Type, Class: q.Class & classMask})
+	}
+
+	for i := 0; i < len(answers)+len(additional); i++ {
+		var r Record
+		if i < len(answers) {
+			r = answers[i]
+		} else {
+			r = additional[i-len(answers)]
 		}
 
-		records := s.config.Zone.Records(question)
-		for _, r := range records {
-			response.Answers = append(response.Answers, dnsRecord{
-				Name:  r.Header().Name,
-				Type:  r.Header().Type,
-				Class: r.Header().Class,
-				TTL:   r.Header().TTL,
-				Data:  s.encodeRecordData(r),
-			})
+		switch rec := r.(type) {
+		case *PTR:
+			collect(&additional, Question{Name: rec.Ptr, Type: dnsTypeSRV, Class: 1})
+			collect(&additional, Question{Name: rec.Ptr, Type: dnsTypeTXT, Class: 1})
+		case *SRV:
+			collect(&additional, Question{Name: rec.Target, Type: dnsTypeA, Class: 1})
+			collect(&additional, Question{Name: rec.Target, Type: dnsTypeAAAA, Class: 1})
 		}
 	}
 
-	if len(response.Answers) > 0 {
-		return s.sendResponse(response, from, conn)
+	for _, r := range answers {
+		response.Answers = append(response.Answers, s.encodeRecord(r, legacy))
+	}
+	for _, r := range additional {
+		response.Additional = append(response.Additional, s.encodeRecord(r, legacy))
 	}
+	return response
+}
 
-	return nil
+// encodeRecord converts a record for a response. Unique records carry the
+// cache-flush bit, so caches replace older records of the same name and
+// type; PTR records are shared by all responders of a service type.
+func (s *Server) encodeRecord(r Record, legacy bool) dnsRecord {
+	hdr := r.Header()
+	record := dnsRecord{
+		Name:  hdr.Name,
+		Type:  hdr.Type,
+		Class: hdr.Class,
+		TTL:   hdr.TTL,
+		Data:  s.encodeRecordData(r),
+	}
+
+	if legacy {
+		record.TTL = min(record.TTL, legacyUnicastTTL)
+	} else if hdr.Type != dnsTypePTR {
+		record.Class |= classTopBit
+	}
+	return record
 }
 
 func (s *Server) sendResponse(msg *dnsMessage, to *net.UDPAddr, conn *net.UDPConn) error {
@@ -336,10 +597,21 @@ func (s *Server) encodeRecordData(r Record) []byte {
 }
 
 func (s *Server) interfaceName() string {
-	if s.config.Interface == nil {
+	if len(s.config.Interfaces) == 0 {
 		return "all"
 	}
-	return s.config.Interface.Name
+	names := make([]string, len(s.config.Interfaces))
+	for i, iface := range s.config.Interfaces {
+		names[i] = iface.Name
+	}
+	return strings.Join(names, ",")
+}
+
+func (s *Server) family() AddressFamily {
+	if s.config.Family == "" {
+		return FamilyBoth
+	}
+	return s.config.Family
 }
 
 // Simple DNS message structure for parsing
@@ -354,6 +626,8 @@ type dnsMessage struct {
 	Rcode              uint8
 	Questions          []dnsQuestion
 	Answers            []dnsRecord
+	Authority          []dnsRecord
+	Additional         []dnsRecord
 }
 
 type dnsQuestion struct {
@@ -368,8 +642,14 @@ type dnsRecord struct {
 	Class uint16
 	TTL   uint32
 	Data  []byte
+
+	// Comparable form of the data of parsed records, see recordValue
+	Value string
 }
 
+// maxNameLength is the limit of names in wire format (RFC 1035 3.1)
+const maxNameLength = 255
+
 // Simplified DNS message parsing and encoding
 func parseDNSMessage(buf []byte) (*dnsMessage, error) {
 	if len(buf) < 12 {
@@ -410,9 +690,76 @@ func parseDNSMessage(buf []byte) (*dnsMessage, error) {
 		offset = newOffset + 4
 	}
 
+	for i, section := range []*[]dnsRecord{&msg.Answers, &msg.Authority, &msg.Additional} {
+		count := uint16(buf[6+2*i])<<8 | uint16(buf[7+2*i])
+		for j := uint16(0); j < count; j++ {
+			record, newOffset, err := parseRecord(buf, offset)
+			if err != nil {
+				return nil, err
+			}
+			*section = append(*section, record)
+			offset = newOffset
+		}
+	}
+
 	return msg, nil
 }
 
+// parseRecord parses a resource record. Compressed names in the data of PTR
+// and SRV records are expanded, so the data doesn't depend on the message.
+func parseRecord(buf []byte, offset int) (dnsRecord, int, error) {
+	name, offset, err := parseName(buf, offset)
+	if err != nil {
+		return dnsRecord{}, 0, err
+	}
+	if offset+10 > len(buf) {
+		return dnsRecord{}, 0, fmt.Errorf("record truncated")
+	}
+
+	record := dnsRecord{
+		Name:  name,
+		Type:  uint16(buf[offset])<<8 | uint16(buf[offset+1]),
+		Class: uint16(buf[offset+2])<<8 | uint16(buf[offset+3]),
+		TTL:   uint32(buf[offset+4])<<24 | uint32(buf[offset+5])<<16 | uint32(buf[offset+6])<<8 | uint32(buf[offset+7]),
+	}
+	length := int(buf[offset+8])<<8 | int(buf[offset+9])
+	start := offset + 10
+	if start+length > len(buf) {
+		return dnsRecord{}, 0, fmt.Errorf("record data truncated")
+	}
+	record.Data = buf[start : start+length]
+
+	switch record.Type {
+	case dnsTypeA, dnsTypeAAAA:
+		record.Value = net.IP(record.Data).String()
+	case dnsTypePTR:
+		// Names in the data end within the data
+		target, _, err := parseName(buf[:start+length], start)
+		if err != nil {
+			return dnsRecord{}, 0, err
+		}
+		record.Data = encodeName(target)
+		record.Value = strings.ToLower(target)
+	case dnsTypeSRV:
+		if length < 7 {
+			return dnsRecord{}, 0, fmt.Errorf("SRV record too short")
+		}
+		target, _, err := parseName(buf[:start+length], start+6)
+		if err != nil {
+			return dnsRecord{}, 0, err
+		}
+		d := append(append([]byte(nil), record.Data[:6]...), encodeName(target)...)
+		record.Data = d
+		record.Value = fmt.Sprintf("%d %d %d %s",
+			uint16(d[0])<<8|uint16(d[1]), uint16(d[2])<<8|uint16(d[3]), uint16(d[4])<<8|uint16(d[5]),
+			strings.ToLower(target))
+	default:
+		record.Value = string(record.Data)
+	}
+
+	return record, start + length, nil
+}
+
 func encodeDNSMessage(msg *dnsMessage) ([]byte, error) {
 	buf := make([]byte, 12)
 
@@ -441,48 +788,144 @@ func encodeDNSMessage(msg *dnsMessage) ([]byte, error) {
 	buf[5] = byte(len(msg.Questions))
 	buf[6] = byte(len(msg.Answers) >> 8)
 	buf[7] = byte(len(msg.Answers))
+	buf[8] = byte(len(msg.Authority) >> 8)
+	buf[9] = byte(len(msg.Authority))
+	buf[10] = byte(len(msg.Additional) >> 8)
+	buf[11] = byte(len(msg.Additional))
 
+	names := nameCompressor{offsets: make(map[string]int)}
+	var err error
 	for _, q := range msg.Questions {
-		nameBytes := encodeName(q.Name)
-		buf = append(buf, nameBytes...)
+		if buf, err = names.appendName(buf, q.Name); err != nil {
+			return nil, err
+		}
 		buf = append(buf, byte(q.Type>>8), byte(q.Type))
 		buf = append(buf, byte(q.Class>>8), byte(q.Class))
 	}
 
-	for _, r := range msg.Answers {
-		nameBytes := encodeName(r.Name)
-		buf = append(buf, nameBytes...)
-		buf = append(buf, byte(r.Type>>8), byte(r.Type))
-		buf = append(buf, byte(r.Class>>8), byte(r.Class))
-		buf = append(buf, byte(r.TTL>>24), byte(r.TTL>>16), byte(r.TTL>>8), byte(r.TTL))
-		buf = append(buf, byte(len(r.Data)>>8), byte(len(r.Data)))
-		buf = append(buf, r.Data...)
+	for _, section := range [][]dnsRecord{msg.Answers, msg.Authority, msg.Additional} {
+		for _, r := range section {
+			if buf, err = names.appendName(buf, r.Name); err != nil {
+				return nil, err
+			}
+			buf = append(buf, byte(r.Type>>8), byte(r.Type))
+			buf = append(buf, byte(r.Class>>8), byte(r.Class))
+			buf = append(buf, byte(r.TTL>>24), byte(r.TTL>>16), byte(r.TTL>>8), byte(r.TTL))
+
+			// The data length is known once the data is written
+			lengthAt := len(buf)
+			buf = append(buf, 0, 0)
+			if buf, err = names.appendData(buf, r); err != nil {
+				return nil, err
+			}
+			length := len(buf) - lengthAt - 2
+			buf[lengthAt] = byte(length >> 8)
+			buf[lengthAt+1] = byte(length)
+		}
 	}
 
 	return buf, nil
 }
 
+// nameCompressor writes names as pointers to earlier occurrences of their
+// suffixes in the message (RFC 1035 4.1.4)
+type nameCompressor struct {
+	// Offsets of the names written so far and of their suffixes
+	offsets map[string]int
+}
+
+// appendName appends a name to the message buf, compressing the longest
+// suffix already written. Matching is case-sensitive, so the names keep
+// their case.
+func (c *nameCompressor) appendName(buf []byte, name string) ([]byte, error) {
+	var labels []string
+	for _, label := range strings.Split(name, ".") {
+		if label != "" {
+			labels = append(labels, label)
+		}
+	}
+
+	for i, label := range labels {
+		suffix := strings.Join(labels[i:], ".")
+		if offset, ok := c.offsets[suffix]; ok {
+			return append(buf, 0xc0|byte(offset>>8), byte(offset)), nil
+		}
+		if len(label) > 63 {
+			return nil, fmt.Errorf("label %q of %s is longer than 63 bytes", label, name)
+		}
+		// Pointers have 14 bits for the offset
+		if len(buf) <= 0x3fff {
+			c.offsets[suffix] = len(buf)
+		}
+		buf = append(buf, byte(len(label)))
+		buf = append(buf, label...)
+	}
+	return append(buf, 0), nil
+}
+
+// appendData appends the data of a record, compressing the names in PTR and
+// SRV records (RFC 6762 18.14)
+func (c *nameCompressor) appendData(buf []byte, r dnsRecord) ([]byte, error) {
+	switch {
+	case r.Type == dnsTypePTR:
+		if name, _, err := parseName(r.Data, 0); err == nil {
+			return c.appendName(buf, name)
+		}
+	case r.Type == dnsTypeSRV && len(r.Data) > 6:
+		if target, _, err := parseName(r.Data, 6); err == nil {
+			return c.appendName(append(buf, r.Data[:6]...), target)
+		}
+	}
+	return append(buf, r.Data...), nil
+}
+
+// parseName parses the name at offset in buf, following compression
+// pointers. It returns the name and the offset following it.
 func parseName(buf []byte, offset int) (string, int, error) {
 	var name []string
 	original := offset
 	jumped := false
+	// Every pointer must point before the previous one's target, which rules
+	// out loops
+	limit := offset
+	size := 1
 
-	for offset < len(buf) {
+	for {
+		if offset >= len(buf) {
+			return "", 0, fmt.Errorf("name extends past buffer")
+		}
 		length := int(buf[offset])
 		if length == 0 {
 			offset++
 			break
 		}
 
-		if length&0xc0 == 0xc0 {
+		switch length & 0xc0 {
+		case 0xc0:
+			if offset+1 >= len(buf) {
+				return "", 0, fmt.Errorf("name pointer truncated")
+			}
+			target := int(buf[offset]&0x3f)<<8 | int(buf[offset+1])
+			if target >= limit {
+				return "", 0, fmt.Errorf("invalid name pointer")
+			}
 			if !jumped {
 				original = offset + 2
 			}
-			offset = int(buf[offset]&0x3f)<<8 | int(buf[offset+1])
+			offset = target
+			limit = target
 			jumped = true
 			continue
+		case 0:
+		default:
+			// 0x40 and 0x80 are reserved label types (RFC 6891 6.1.4)
+			return "", 0, fmt.Errorf("unsupported label type 0x%02x", length&0xc0)
 		}
 
+		size += 1 + length
+		if size > maxNameLength {
+			return "", 0, fmt.Errorf("name longer than %d bytes", maxNameLength)
+		}
 		if offset+1+length >= len(buf) {
 			return "", 0, fmt.Errorf("name extends past buffer")
 		}
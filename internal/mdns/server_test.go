@@ -1,8 +1,13 @@
 package mdns
 
 import (
+	"bytes"
 	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/codefionn/go-matter-server/internal/logger"
 )
@@ -380,11 +385,372 @@ func TestServerInterfaceName(t *testing.T) {
 	// Test with specific interface
 	iface, err := net.InterfaceByName("lo")
 	if err == nil { // Only test if loopback interface exists
-		config.Interface = iface
+		config.Interfaces = []*net.Interface{iface, iface}
 		server.config = config
 		name = server.interfaceName()
-		if name != "lo" {
-			t.Errorf("Expected interface name 'lo', got %s", name)
+		if name != "lo,lo" {
+			t.Errorf("Expected interface names 'lo,lo', got %s", name)
 		}
 	}
 }
+
+func TestBuildResponse(t *testing.T) {
+	log := logger.NewConsoleLogger(logger.ErrorLevel)
+	zone := NewMatterZone("test.local", log)
+	zone.ips = []net.IP{net.ParseIP("192.168.1.100"), net.ParseIP("2001:db8::1")}
+	zone.AddService(OperationalService(0x2906C908D115D362, 1, MatterPort))
+
+	server, err := NewServer(&Config{Zone: zone, Logger: log})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+
+	query := &dnsMessage{
+		ID:        42,
+		Questions: []dnsQuestion{{Name: "_matter._tcp.local", Type: dnsTypePTR, Class: 1}},
+	}
+
+	// A browsing resolver gets the instance's SRV and TXT and the host
+	// addresses along with the PTR record
+	response := server.buildResponse(query, false)
+	if response.ID != 0 || len(response.Questions) != 0 {
+		t.Errorf("Expected multicast response without ID and questions, got %+v", response)
+	}
+	if len(response.Answers) != 1 || response.Answers[0].Class != 1 {
+		t.Fatalf("Expected a shared PTR answer, got %+v", response.Answers)
+	}
+	types := make(map[uint16]uint16)
+	for _, r := range response.Additional {
+		types[r.Type] = r.Class
+	}
+	for _, rrType := range []uint16{dnsTypeSRV, dnsTypeTXT, dnsTypeA, dnsTypeAAAA} {
+		if class, ok := types[rrType]; !ok || class != 1|classTopBit {
+			t.Errorf("Expected unique additional %s record with cache-flush bit, got %+v", dnsTypeToString(rrType), response.Additional)
+		}
+	}
+
+	// Legacy unicast responses echo ID and question and cap the TTLs
+	response = server.buildResponse(query, true)
+	if response.ID != 42 || len(response.Questions) != 1 {
+		t.Errorf("Expected legacy response with ID and question, got %+v", response)
+	}
+	for _, r := range append(response.Answers, response.Additional...) {
+		if r.Class&classTopBit != 0 || r.TTL > legacyUnicastTTL {
+			t.Errorf("Expected legacy record without cache-flush bit and capped TTL, got %+v", r)
+		}
+	}
+
+	// Service type enumeration lists the service types
+	query.Questions = []dnsQuestion{{Name: "_services._dns-sd._udp.local", Type: dnsTypePTR, Class: 1 | classTopBit}}
+	response = server.buildResponse(query, false)
+	if len(response.Answers) != 1 || response.Answers[0].Name != "_services._dns-sd._udp.local" {
+		t.Errorf("Expected service type enumeration answer, got %+v", response.Answers)
+	}
+
+	buf, err := encodeDNSMessage(response)
+	if err != nil {
+		t.Fatalf("Failed to encode response: %v", err)
+	}
+	if arcount := int(buf[10])<<8 | int(buf[11]); arcount != len(response.Additional) {
+		t.Errorf("Expected %d additional records in the header, got %d", len(response.Additional), arcount)
+	}
+}
+
+func TestKnownAnswerSuppression(t *testing.T) {
+	log := logger.NewConsoleLogger(logger.ErrorLevel)
+	zone := NewMatterZone("test.local", log)
+	zone.ips = []net.IP{net.ParseIP("192.168.1.100")}
+	zone.AddService(OperationalService(0x2906C908D115D362, 1, MatterPort))
+
+	server, err := NewServer(&Config{Zone: zone, Logger: log})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+
+	instance := "2906C908D115D362-0000000000000001._matter._tcp.local"
+	query := func(ttl uint32) *dnsMessage {
+		return &dnsMessage{
+			Questions: []dnsQuestion{{Name: "_matter._tcp.local", Type: dnsTypePTR, Class: 1}},
+			Answers: []dnsRecord{{
+				Name:  "_matter._tcp.local",
+				Type:  dnsTypePTR,
+				Class: 1,
+				TTL:   ttl,
+				Value: strings.ToLower(instance),
+			}},
+		}
+	}
+
+	if response := server.buildResponse(query(otherRecordTTL/2), false); len(response.Answers) != 0 {
+		t.Errorf("Expected known answer to be suppressed, got %+v", response.Answers)
+	}
+	if response := server.buildResponse(query(otherRecordTTL/2-1), false); len(response.Answers) != 1 {
+		t.Errorf("Expected known answer with less than half its TTL to be answered, got %+v", response.Answers)
+	}
+}
+
+func TestQueryUnicastResponses(t *testing.T) {
+	server, _ := newProbeTestServer(t)
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer conn.Close()
+
+	receive := func(peer *net.UDPConn) *dnsMessage {
+		t.Helper()
+		buf := make([]byte, 9000)
+		peer.SetReadDeadline(time.Now().Add(time.Second))
+		n, err := peer.Read(buf)
+		if err != nil {
+			t.Fatalf("Expected a unicast response: %v", err)
+		}
+		msg, err := parseDNSMessage(buf[:n])
+		if err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		return msg
+	}
+
+	// Legacy queries get a direct response echoing the ID
+	legacy, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer legacy.Close()
+	query := &dnsMessage{ID: 7, Questions: []dnsQuestion{{Name: "test.local", Type: dnsTypeA, Class: 1}}}
+	if err := server.handleQuery(query, legacy.LocalAddr().(*net.UDPAddr), conn, false); err != nil {
+		t.Fatalf("Failed to answer legacy query: %v", err)
+	}
+	if msg := receive(legacy); msg.ID != 7 || len(msg.Answers) != 1 || msg.Answers[0].TTL != legacyUnicastTTL {
+		t.Errorf("Expected legacy response, got %+v", msg)
+	}
+
+	// QU questions for records multicast recently are answered directly
+	peer, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: mdnsPort})
+	if err != nil {
+		t.Skipf("mDNS port not available: %v", err)
+	}
+	defer peer.Close()
+
+	response := server.buildResponse(query, false)
+	if server.multicastRecently(response.Answers) {
+		t.Error("Expected records not to be multicast yet")
+	}
+	server.noteMulticast(response.Answers)
+	query = &dnsMessage{Questions: []dnsQuestion{{Name: "test.local", Type: dnsTypeA, Class: 1 | classTopBit}}}
+	if err := server.handleQuery(query, peer.LocalAddr().(*net.UDPAddr), conn, false); err != nil {
+		t.Fatalf("Failed to answer QU query: %v", err)
+	}
+	if msg := receive(peer); len(msg.Answers) != 1 || msg.Answers[0].Value != "192.168.1.100" {
+		t.Errorf("Expected unicast answer, got %+v", msg)
+	}
+
+	// A quarter of the TTL later the answers are multicast again
+	for key := range server.multicastAt {
+		server.multicastAt[key] = time.Now().Add(-hostRecordTTL * time.Second / 4).Add(-time.Second)
+	}
+	if server.multicastRecently(response.Answers) {
+		t.Error("Expected records to need a multicast")
+	}
+}
+
+// readOperationalResponse returns testdata/operational_response.bin, the
+// response to a _matter._tcp PTR query captured off the wire from a
+// grandcat/zeroconf responder advertising an operational instance. Its
+// miekg/dns packer points the record names at earlier names and the A
+// record's name into the SRV target.
+func readOperationalResponse(tb testing.TB) []byte {
+	tb.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", "operational_response.bin"))
+	if err != nil {
+		tb.Fatalf("Failed to read the captured response: %v", err)
+	}
+	return data
+}
+
+func TestNameCompression(t *testing.T) {
+	captured := readOperationalResponse(t)
+	msg, err := parseDNSMessage(captured)
+	if err != nil {
+		t.Fatalf("Failed to parse device response: %v", err)
+	}
+	if len(msg.Answers) != 1 || len(msg.Additional) != 3 {
+		t.Fatalf("Expected 1 answer and 3 additional records, got %+v", msg)
+	}
+
+	instance := "2906C908D115D362-0000000000000001._matter._tcp.local"
+	if ptr := msg.Answers[0]; ptr.Name != "_matter._tcp.local" || ptr.Value != strings.ToLower(instance) {
+		t.Errorf("Expected decompressed PTR record, got %+v", ptr)
+	}
+	srv := msg.Additional[0]
+	if srv.Name != instance || srv.Value != "0 0 5540 node.local" {
+		t.Errorf("Expected decompressed SRV record, got %+v", srv)
+	}
+	if txt := msg.Additional[1]; txt.Name != instance || strings.Join(parseTXT(txt.Data), " ") != "SII=5000 SAI=300 T=1" {
+		t.Errorf("Expected decompressed TXT record, got %+v", txt)
+	}
+	if a := msg.Additional[2]; a.Name != "node.local" || a.Value != "192.0.2.2" {
+		t.Errorf("Expected A record of node.local, got %+v", a)
+	}
+
+	// The data of parsed records is expanded, so they can be encoded into
+	// another message; compressing them again gives the same records in a
+	// smaller packet, as the SRV target is compressed too
+	buf, err := encodeDNSMessage(msg)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	if len(buf) >= len(captured) {
+		t.Errorf("Expected the device response to compress better, got %d of %d bytes", len(buf), len(captured))
+	}
+	reparsed, err := parseDNSMessage(buf)
+	if err != nil {
+		t.Fatalf("Failed to parse the encoded response: %v", err)
+	}
+	for i, r := range append(msg.Answers, msg.Additional...) {
+		got := append(reparsed.Answers, reparsed.Additional...)[i]
+		if got.Name != r.Name || got.Type != r.Type || got.TTL != r.TTL || string(got.Data) != string(r.Data) {
+			t.Errorf("Expected record %d to round-trip, got %+v, want %+v", i, got, r)
+		}
+	}
+
+	// Our responses compress as well and parse back to the same records
+	log := logger.NewConsoleLogger(logger.ErrorLevel)
+	zone := NewMatterZone("test.local", log)
+	zone.ips = []net.IP{net.ParseIP("192.168.1.100"), net.ParseIP("2001:db8::1")}
+	zone.AddService(OperationalService(0x2906C908D115D362, 1, MatterPort))
+	server, err := NewServer(&Config{Zone: zone, Logger: log})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	query := &dnsMessage{Questions: []dnsQuestion{{Name: "_matter._tcp.local", Type: dnsTypePTR, Class: 1}}}
+	response := server.buildResponse(query, false)
+
+	buf, err = encodeDNSMessage(response)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	uncompressed := 12
+	for _, r := range append(response.Answers, response.Additional...) {
+		uncompressed += len(encodeName(r.Name)) + 10 + len(r.Data)
+	}
+	if len(buf) >= uncompressed-100 {
+		t.Errorf("Expected compression to save at least 100 of %d bytes, got %d", uncompressed, len(buf))
+	}
+
+	parsed, err := parseDNSMessage(buf)
+	if err != nil {
+		t.Fatalf("Failed to parse own response: %v", err)
+	}
+	for i, r := range append(response.Answers, response.Additional...) {
+		got := append(parsed.Answers, parsed.Additional...)[i]
+		if got.Name != r.Name || string(got.Data) != string(r.Data) {
+			t.Errorf("Expected record %d to round-trip, got %+v, want %+v", i, got, r)
+		}
+	}
+}
+
+func TestParseNameErrors(t *testing.T) {
+	header := make([]byte, 12)
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"Empty", nil},
+		{"Unterminated", []byte{4, 'n', 'o', 'd', 'e'}},
+		{"Pointer to itself", []byte{0xc0, 12}},
+		{"Pointer loop", []byte{1, 'a', 0xc0, 12}},
+		{"Pointer forward", []byte{0xc0, 14, 1, 'a', 0}},
+		{"Reserved label type", []byte{0x41, 'a', 0}},
+		{"Too long", append(bytes.Repeat(append([]byte{63}, strings.Repeat("a", 63)...), 4), 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if name, _, err := parseName(append(header, tt.data...), 12); err == nil {
+				t.Errorf("Expected an error, got %q", name)
+			}
+		})
+	}
+
+	// The name in the data of a PTR record ends within the data
+	buf := []byte{
+		0, 0, 0x84, 0, 0, 0, 0, 1, 0, 0, 0, 0,
+		1, 'a', 0, // name
+		0, 12, 0, 1, 0, 0, 0, 120, // PTR, class and TTL
+		0, 2, 1, 'b', // data without the terminating label
+		0,
+	}
+	if _, err := parseDNSMessage(buf); err == nil {
+		t.Error("Expected a PTR name past its data to be rejected")
+	}
+}
+
+func TestEncodeRejectsLongLabels(t *testing.T) {
+	msg := &dnsMessage{Questions: []dnsQuestion{{Name: strings.Repeat("a", 64) + ".local", Type: dnsTypeA, Class: 1}}}
+	if _, err := encodeDNSMessage(msg); err == nil {
+		t.Error("Expected labels longer than 63 bytes to be rejected")
+	}
+}
+
+func TestParseAddressFamily(t *testing.T) {
+	for name, want := range map[string]AddressFamily{"": FamilyBoth, "both": FamilyBoth, "ipv4": FamilyIPv4, "ipv6": FamilyIPv6} {
+		if got, err := ParseAddressFamily(name); err != nil || got != want {
+			t.Errorf("Expected %q to parse as %q, got %q (%v)", name, want, got, err)
+		}
+	}
+	if _, err := ParseAddressFamily("ipv5"); err == nil {
+		t.Error("Expected unknown address family to be rejected")
+	}
+}
+
+func TestSocketAccepts(t *testing.T) {
+	lo, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skipf("No loopback interface: %v", err)
+	}
+
+	tests := []struct {
+		from   *net.UDPAddr
+		iface  *net.Interface
+		accept bool
+	}{
+		{&net.UDPAddr{IP: net.ParseIP("192.168.1.7")}, nil, true},
+		{&net.UDPAddr{IP: net.ParseIP("127.0.0.5")}, lo, true},
+		{&net.UDPAddr{IP: net.ParseIP("192.168.1.7")}, lo, false},
+		{&net.UDPAddr{IP: net.ParseIP("fe80::1"), Zone: "lo"}, lo, true},
+		{&net.UDPAddr{IP: net.ParseIP("fe80::1"), Zone: "eth0"}, lo, false},
+	}
+	for _, tt := range tests {
+		sock := &socket{iface: tt.iface}
+		if got := sock.accepts(tt.from); got != tt.accept {
+			t.Errorf("Expected accepts(%s) on %v to be %v", tt.from, tt.iface, tt.accept)
+		}
+	}
+}
+
+func TestStartPerInterfaceAndFamily(t *testing.T) {
+	lo, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skipf("No loopback interface: %v", err)
+	}
+
+	server, err := NewServer(&Config{
+		Zone:       NewMockZone(),
+		Logger:     logger.NewConsoleLogger(logger.ErrorLevel),
+		Interfaces: []*net.Interface{lo},
+		Family:     FamilyIPv4,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Skipf("Multicast not available: %v", err)
+	}
+	defer server.Shutdown()
+
+	if len(server.sockets) != 1 || server.sockets[0].ipv6 || server.sockets[0].iface != lo {
+		t.Errorf("Expected one IPv4 socket on lo, got %+v", server.sockets)
+	}
+}
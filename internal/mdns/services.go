@@ -0,0 +1,274 @@
+package mdns
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+const (
+	// MatterPort is the default UDP port of Matter operational messages
+	MatterPort = 5540
+
+	// Service types of operational nodes and of commissioners
+	OperationalServiceType  = "_matter._tcp"
+	CommissionerServiceType = "_matterd._udp"
+
+	// ServerServiceType is the service type of the server's WebSocket API
+	ServerServiceType = "_matter-server._tcp"
+
+	// ServerPath is the path of the WebSocket API, advertised in the path
+	// TXT key
+	ServerPath = "/ws"
+
+	// servicesMeta lists the service types for DNS-SD service enumeration
+	servicesMeta = "_services._dns-sd._udp.local"
+
+	// TTLs recommended by RFC 6762 for host and other records
+	hostRecordTTL  = 120
+	otherRecordTTL = 4500
+)
+
+// Default MRP parameters advertised in the SII, SAI and SAT TXT keys, in
+// milliseconds
+const (
+	sessionIdleInterval    = 500
+	sessionActiveInterval  = 300
+	sessionActiveThreshold = 4000
+)
+
+// Service is a DNS-SD service instance advertised by MatterZone
+type Service struct {
+	// Instance name, the first label of the service instance name
+	Instance string
+	// Service type, e.g. "_matter._tcp"
+	Type string
+	// Subtypes the instance is also found under, e.g. "_V65521"
+	Subtypes []string
+	Port     uint16
+	TXT      []string
+}
+
+// name returns the full service instance name
+func (s Service) name() string {
+	return s.Instance + "." + s.Type + ".local"
+}
+
+// OperationalService returns the _matter._tcp service instance of a node on
+// a fabric, named <compressed fabric ID>-<node ID> in upper case hex
+func OperationalService(compressedFabricID, nodeID uint64, port uint16) Service {
+	return Service{
+		Instance: fmt.Sprintf("%016X-%016X", compressedFabricID, nodeID),
+		Type:     OperationalServiceType,
+		Subtypes: []string{fmt.Sprintf("_I%016X", compressedFabricID)},
+		Port:     port,
+		TXT:      sessionTXT(),
+	}
+}
+
+// CommissionerService returns the _matterd._udp service of a commissioner
+// with a random instance name, as the specification asks for. A product ID
+// of 0 is left out of the VP key.
+func CommissionerService(vendorID, productID uint16, deviceName string, port uint16) (Service, error) {
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return Service{}, fmt.Errorf("failed to generate instance name: %w", err)
+	}
+
+	vp := fmt.Sprintf("VP=%d", vendorID)
+	if productID != 0 {
+		vp += fmt.Sprintf("+%d", productID)
+	}
+	txt := []string{vp}
+	if deviceName != "" {
+		// DN is limited to 32 bytes
+		if len(deviceName) > 32 {
+			deviceName = deviceName[:32]
+		}
+		txt = append(txt, "DN="+deviceName)
+	}
+
+	return Service{
+		Instance: fmt.Sprintf("%016X", binary.BigEndian.Uint64(id[:])),
+		Type:     CommissionerServiceType,
+		Subtypes: []string{fmt.Sprintf("_V%d", vendorID)},
+		Port:     port,
+		TXT:      append(txt, sessionTXT()...),
+	}, nil
+}
+
+// ServerFeatures are the optional capabilities of the server advertised in
+// the TXT record of its service
+type ServerFeatures struct {
+	Bluetooth bool
+	Thread    bool
+	OTA       bool
+}
+
+// ServerService returns the _matter-server._tcp service of the WebSocket
+// API, so clients find the server without knowing its hostname and port.
+// It is named after the compressed fabric ID like the operational service,
+// so servers of different fabrics don't conflict. The TXT keys carry the
+// supported schema versions, the path and port of the API, whether it uses
+// TLS, the gRPC port if the gRPC API is enabled and the features. The port is
+// repeated in the TXT record for clients whose DNS-SD APIs don't expose the
+// SRV record.
+func ServerService(compressedFabricID uint64, port, grpcPort uint16, schemaVersion, minSchemaVersion int, features ServerFeatures) Service {
+	txt := []string{
+		fmt.Sprintf("schema_version=%d", schemaVersion),
+		fmt.Sprintf("min_schema_version=%d", minSchemaVersion),
+		"path=" + ServerPath,
+		fmt.Sprintf("port=%d", port),
+		// The server itself speaks plain HTTP, so clients use ws://
+		"tls=0",
+	}
+	if grpcPort != 0 {
+		txt = append(txt, fmt.Sprintf("grpc_port=%d", grpcPort))
+	}
+	txt = append(txt,
+		"bluetooth="+txtFlag(features.Bluetooth),
+		"thread="+txtFlag(features.Thread),
+		"ota="+txtFlag(features.OTA),
+	)
+	return Service{
+		Instance: fmt.Sprintf("%016X", compressedFabricID),
+		Type:     ServerServiceType,
+		Port:     port,
+		TXT:      txt,
+	}
+}
+
+// sessionTXT returns the TXT entries with the session parameters. T=0 means
+// TCP is not supported.
+func sessionTXT() []string {
+	return []string{
+		fmt.Sprintf("SII=%d", sessionIdleInterval),
+		fmt.Sprintf("SAI=%d", sessionActiveInterval),
+		fmt.Sprintf("SAT=%d", sessionActiveThreshold),
+		"T=0",
+	}
+}
+
+// AddService advertises a service instance, replacing one with the same
+// name
+func (z *MatterZone) AddService(service Service) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	for i, existing := range z.services {
+		if strings.EqualFold(existing.name(), service.name()) {
+			z.services[i] = service
+			return
+		}
+	}
+	z.services = append(z.services, service)
+}
+
+// RemoveService stops advertising a service instance
+func (z *MatterZone) RemoveService(instance, serviceType string) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	for i, existing := range z.services {
+		if strings.EqualFold(existing.Instance, instance) && strings.EqualFold(existing.Type, serviceType) {
+			z.services = append(z.services[:i], z.services[i+1:]...)
+			return
+		}
+	}
+}
+
+// Services returns the advertised service instances
+func (z *MatterZone) Services() []Service {
+	z.mu.RLock()
+	defer z.mu.RUnlock()
+
+	return append([]Service(nil), z.services...)
+}
+
+// serviceNames returns the names queried for the records of the services:
+// the enumeration meta-query, service types, subtypes and instances
+func (z *MatterZone) serviceNames() []string {
+	names := []string{servicesMeta}
+	seen := map[string]bool{servicesMeta: true}
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	for _, service := range z.Services() {
+		serviceType := service.Type + ".local"
+		add(serviceType)
+		for _, subtype := range service.Subtypes {
+			add(subtype + "._sub." + serviceType)
+		}
+		add(service.name())
+	}
+	return names
+}
+
+// serviceRecords answers DNS-SD queries: service type enumeration, PTR
+// records of service types and subtypes, and SRV and TXT records of the
+// instances, which point at hostname
+func (z *MatterZone) serviceRecords(q Question, hostname string) []Record {
+	qname := strings.ToLower(q.Name)
+	var records []Record
+
+	ptr := func(name, target string) {
+		records = append(records, &PTR{
+			Hdr: RR_Header{Name: name, Type: dnsTypePTR, Class: 1, TTL: otherRecordTTL},
+			Ptr: target,
+		})
+	}
+
+	seenTypes := make(map[string]bool)
+	for _, service := range z.Services() {
+		serviceType := service.Type + ".local"
+		instance := service.name()
+
+		if q.Type == dnsTypePTR || q.Type == dnsTypeANY {
+			if qname == servicesMeta && !seenTypes[serviceType] {
+				seenTypes[serviceType] = true
+				ptr(servicesMeta, serviceType)
+			}
+			if qname == strings.ToLower(serviceType) {
+				ptr(serviceType, instance)
+			}
+			for _, subtype := range service.Subtypes {
+				subtypeName := subtype + "._sub." + serviceType
+				if qname == strings.ToLower(subtypeName) {
+					ptr(subtypeName, instance)
+				}
+			}
+		}
+
+		if qname != strings.ToLower(instance) {
+			continue
+		}
+		if q.Type == dnsTypeSRV || q.Type == dnsTypeANY {
+			records = append(records, &SRV{
+				Hdr:    RR_Header{Name: instance, Type: dnsTypeSRV, Class: 1, TTL: hostRecordTTL},
+				Port:   service.Port,
+				Target: hostname,
+			})
+		}
+		if q.Type == dnsTypeTXT || q.Type == dnsTypeANY {
+			records = append(records, &TXT{
+				Hdr: RR_Header{Name: instance, Type: dnsTypeTXT, Class: 1, TTL: otherRecordTTL},
+				Txt: service.TXT,
+			})
+		}
+	}
+
+	return records
+}
+
+// txtFlag is the value of a boolean TXT key
+func txtFlag(set bool) string {
+	if set {
+		return "1"
+	}
+	return "0"
+}
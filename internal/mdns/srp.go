@@ -0,0 +1,458 @@
+package mdns
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+)
+
+// SRP (Service Registration Protocol, RFC 9665) registers services with an
+// SRP server, e.g. the one of an OpenThread Border Router, which advertises
+// them on the Thread network and the adjacent infrastructure link. The
+// registrations are DNS updates (RFC 2136) signed with SIG(0) (RFC 2931) by
+// a key that owns the host name.
+
+const (
+	// Domain the SRP server advertises the registrations in
+	srpDomain = "default.service.arpa"
+
+	dnsOpcodeUpdate = 5
+	dnsTypeSOA      = 6
+	dnsTypeSIG      = 24
+	dnsTypeKEY      = 25
+	dnsTypeOPT      = 41
+	dnsClassIN      = 1
+	dnsClassNone    = 254
+	dnsClassAny     = 255
+
+	// EDNS(0) Update Lease option (RFC 9664)
+	ednsOptionUpdateLease = 2
+	ednsUDPSize           = 1232
+
+	// KEY record fields: a key of a non-zone entity for general signing,
+	// DNSSEC protocol, ECDSA P-256 with SHA-256 (RFC 2535 3.1 and RFC 6605)
+	keyFlags         = 0x0201
+	keyProtocol      = 3
+	keyAlgorithmP256 = 13
+
+	// Signatures are valid from five minutes ago to five minutes from now,
+	// tolerating clock differences
+	sigValidity = 5 * time.Minute
+)
+
+// Timing of SRP updates, variables so tests can shorten them
+var (
+	srpResponseTimeout = 2 * time.Second
+	srpAttempts        = 3
+	srpMinRetry        = time.Second
+	srpMaxRetry        = 5 * time.Minute
+)
+
+// ErrSRPNameConflict is returned when the host or a service instance name is
+// owned by another key
+var ErrSRPNameConflict = errors.New("name is registered with another key")
+
+// SRPConfig holds the configuration of an SRP client
+type SRPConfig struct {
+	// Address of the SRP server, host:port
+	Server string
+	// Zone providing the host name, its addresses and the services
+	Zone *MatterZone
+	// Key owning the host name, it has to stay the same across restarts
+	Key *ecdsa.PrivateKey
+	// Lease of the services and of the key, which keeps the name reserved
+	// after the services expired
+	Lease    time.Duration
+	KeyLease time.Duration
+	Logger   *logger.Logger
+}
+
+// SRPClient keeps the services of a zone registered with an SRP server
+type SRPClient struct {
+	config SRPConfig
+	logger *logger.Logger
+
+	stop chan struct{}
+	done chan struct{}
+
+	mu         sync.Mutex
+	registered bool
+	lastErr    error
+}
+
+// NewSRPClient creates an SRP client
+func NewSRPClient(config SRPConfig) (*SRPClient, error) {
+	if config.Server == "" {
+		return nil, fmt.Errorf("SRP server address is required")
+	}
+	if config.Zone == nil || config.Key == nil {
+		return nil, fmt.Errorf("zone and key are required")
+	}
+	if config.Key.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("SRP key must be a P-256 key")
+	}
+	if config.Logger == nil {
+		config.Logger = logger.NewConsoleLogger(logger.InfoLevel)
+	}
+
+	return &SRPClient{config: config, logger: config.Logger}, nil
+}
+
+// Start registers the services and renews the registration before the lease
+// ends
+func (c *SRPClient) Start() {
+	c.stop = make(chan struct{})
+	c.done = make(chan struct{})
+	go c.run()
+}
+
+// Shutdown stops renewing and removes the services from the SRP server. The
+// key stays registered for its lease, keeping the host name reserved.
+func (c *SRPClient) Shutdown() error {
+	if c.stop == nil {
+		return nil
+	}
+	close(c.stop)
+	<-c.done
+	c.stop = nil
+
+	c.mu.Lock()
+	registered := c.registered
+	c.registered = false
+	c.mu.Unlock()
+	if !registered {
+		return nil
+	}
+
+	// Only one attempt, so an unreachable server doesn't delay the shutdown
+	if _, err := c.send(c.removal(), 1); err != nil {
+		return fmt.Errorf("failed to remove SRP registration: %w", err)
+	}
+	c.logger.Info("SRP registration removed", logger.String("server", c.config.Server))
+	return nil
+}
+
+// Status reports whether the services are registered and the error of the
+// last failed update
+func (c *SRPClient) Status() (registered bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.registered, c.lastErr
+}
+
+func (c *SRPClient) run() {
+	defer close(c.done)
+
+	retry := srpMinRetry
+	for {
+		var wait time.Duration
+		lease, err := c.register()
+
+		c.mu.Lock()
+		c.registered = err == nil || c.registered
+		c.lastErr = err
+		c.mu.Unlock()
+
+		if err != nil {
+			c.logger.Warn("SRP registration failed",
+				logger.String("server", c.config.Server),
+				logger.ErrorField(err),
+			)
+			wait = retry
+			retry = min(2*retry, srpMaxRetry)
+		} else {
+			// Renew at 80% of the lease the server granted
+			wait = max(lease*8/10, srpMinRetry)
+			retry = srpMinRetry
+		}
+
+		select {
+		case <-c.stop:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// register sends the registration and returns the lease the server granted
+func (c *SRPClient) register() (time.Duration, error) {
+	update, err := c.registration()
+	if err != nil {
+		return 0, err
+	}
+	lease, err := c.send(update, srpAttempts)
+	if err != nil {
+		return 0, err
+	}
+
+	c.logger.Info("Services registered with SRP server",
+		logger.String("server", c.config.Server),
+		logger.String("host", c.hostName()),
+		logger.Int("services", len(c.config.Zone.Services())),
+		logger.String("lease", lease.String()),
+	)
+	return lease, nil
+}
+
+// send sends a signed update and waits for the response, sending it up to
+// attempts times when no response arrives. The granted lease is returned.
+func (c *SRPClient) send(update *dnsMessage, attempts int) (time.Duration, error) {
+	packet, err := c.sign(update, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	conn, err := net.Dial("udp", c.config.Server)
+	if err != nil {
+		return 0, fmt.Errorf("failed to connect to SRP server: %w", err)
+	}
+	defer conn.Close()
+
+	buf := make([]byte, 65536)
+	for attempt := 0; attempt < attempts; attempt++ {
+		if _, err := conn.Write(packet); err != nil {
+			return 0, fmt.Errorf("failed to send SRP update: %w", err)
+		}
+
+		deadline := time.Now().Add(srpResponseTimeout << attempt)
+		for {
+			conn.SetReadDeadline(deadline)
+			n, err := conn.Read(buf)
+			if err != nil {
+				var netErr net.Error
+				if errors.As(err, &netErr) && netErr.Timeout() {
+					break
+				}
+				return 0, fmt.Errorf("failed to receive SRP response: %w", err)
+			}
+
+			response, err := parseDNSMessage(buf[:n])
+			if err != nil || !response.Response || response.ID != update.ID {
+				continue
+			}
+			return c.result(response)
+		}
+	}
+	return 0, fmt.Errorf("no response from SRP server %s", c.config.Server)
+}
+
+// result checks the response code and returns the granted lease
+func (c *SRPClient) result(response *dnsMessage) (time.Duration, error) {
+	switch response.Rcode {
+	case 0:
+	case 6:
+		return 0, ErrSRPNameConflict
+	default:
+		return 0, fmt.Errorf("SRP server refused the update: %s", rcodeName(response.Rcode))
+	}
+
+	// Servers may grant a shorter lease than asked for
+	lease := c.config.Lease
+	for _, r := range response.Additional {
+		if r.Type != dnsTypeOPT {
+			continue
+		}
+		data := []byte(r.Value)
+		for len(data) >= 4 {
+			code := binary.BigEndian.Uint16(data)
+			length := int(binary.BigEndian.Uint16(data[2:]))
+			if 4+length > len(data) {
+				break
+			}
+			if code == ednsOptionUpdateLease && length >= 4 {
+				lease = time.Duration(binary.BigEndian.Uint32(data[4:])) * time.Second
+			}
+			data = data[4+length:]
+		}
+	}
+	return lease, nil
+}
+
+func rcodeName(rcode uint8) string {
+	names := map[uint8]string{1: "FORMERR", 2: "SERVFAIL", 3: "NXDOMAIN", 4: "NOTIMP", 5: "REFUSED", 8: "NXRRSET", 9: "NOTAUTH", 10: "NOTZONE"}
+	if name, ok := names[rcode]; ok {
+		return name
+	}
+	return fmt.Sprintf("RCODE%d", rcode)
+}
+
+// hostName returns the host name in the SRP domain
+func (c *SRPClient) hostName() string {
+	return strings.TrimSuffix(c.config.Zone.GetHostname(), ".local") + "." + srpDomain
+}
+
+// registration builds the update registering the host with its IPv6
+// addresses and key and all services of the zone (RFC 9665 3.3)
+func (c *SRPClient) registration() (*dnsMessage, error) {
+	var addrs []net.IP
+	for _, ip := range c.config.Zone.GetIPs() {
+		if ip.To4() == nil {
+			addrs = append(addrs, ip)
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no IPv6 address to register")
+	}
+
+	host := c.hostName()
+	update := c.newUpdate()
+	for _, service := range c.config.Zone.Services() {
+		instance := service.Instance + "." + service.Type + "." + srpDomain
+
+		// Service discovery instructions for the type and subtypes
+		update.add(service.Type+"."+srpDomain, dnsTypePTR, otherRecordTTL, encodeName(instance))
+		for _, subtype := range service.Subtypes {
+			update.add(subtype+"._sub."+service.Type+"."+srpDomain, dnsTypePTR, otherRecordTTL, encodeName(instance))
+		}
+
+		// Service description, replacing earlier registrations
+		update.deleteAll(instance)
+		update.add(instance, dnsTypeSRV, hostRecordTTL, encodeSRV(0, 0, service.Port, host))
+		txt := encodeTXT(service.TXT)
+		if len(txt) == 0 {
+			txt = []byte{0}
+		}
+		update.add(instance, dnsTypeTXT, otherRecordTTL, txt)
+	}
+
+	// Host description
+	update.deleteAll(host)
+	for _, ip := range addrs {
+		update.add(host, dnsTypeAAAA, hostRecordTTL, ip.To16())
+	}
+	update.add(host, dnsTypeKEY, hostRecordTTL, c.keyData())
+
+	update.lease(c.config.Lease, c.config.KeyLease)
+	return update.msg, nil
+}
+
+// removal builds the update removing the services and addresses. The lease
+// of 0 keeps only the key (RFC 9665 3.3.5).
+func (c *SRPClient) removal() *dnsMessage {
+	host := c.hostName()
+	update := c.newUpdate()
+	for _, service := range c.config.Zone.Services() {
+		instance := service.Instance + "." + service.Type + "." + srpDomain
+		update.remove(service.Type+"."+srpDomain, dnsTypePTR, encodeName(instance))
+		for _, subtype := range service.Subtypes {
+			update.remove(subtype+"._sub."+service.Type+"."+srpDomain, dnsTypePTR, encodeName(instance))
+		}
+		update.deleteAll(instance)
+	}
+	update.deleteAll(host)
+	update.add(host, dnsTypeKEY, hostRecordTTL, c.keyData())
+
+	update.lease(0, c.config.KeyLease)
+	return update.msg
+}
+
+// srpUpdate collects the instructions of an update
+type srpUpdate struct {
+	msg *dnsMessage
+}
+
+func (c *SRPClient) newUpdate() *srpUpdate {
+	var id [2]byte
+	rand.Read(id[:])
+	return &srpUpdate{msg: &dnsMessage{
+		ID:        binary.BigEndian.Uint16(id[:]),
+		Opcode:    dnsOpcodeUpdate,
+		Questions: []dnsQuestion{{Name: srpDomain, Type: dnsTypeSOA, Class: dnsClassIN}},
+	}}
+}
+
+// add adds a record
+func (u *srpUpdate) add(name string, rrtype uint16, ttl uint32, data []byte) {
+	u.msg.Authority = append(u.msg.Authority, dnsRecord{Name: name, Type: rrtype, Class: dnsClassIN, TTL: ttl, Data: data})
+}
+
+// remove deletes a single record
+func (u *srpUpdate) remove(name string, rrtype uint16, data []byte) {
+	u.msg.Authority = append(u.msg.Authority, dnsRecord{Name: name, Type: rrtype, Class: dnsClassNone, Data: data})
+}
+
+// deleteAll deletes all records of a name
+func (u *srpUpdate) deleteAll(name string) {
+	u.msg.Authority = append(u.msg.Authority, dnsRecord{Name: name, Type: dnsTypeANY, Class: dnsClassAny})
+}
+
+// lease adds the EDNS(0) Update Lease option
+func (u *srpUpdate) lease(lease, keyLease time.Duration) {
+	option := make([]byte, 12)
+	binary.BigEndian.PutUint16(option, ednsOptionUpdateLease)
+	binary.BigEndian.PutUint16(option[2:], 8)
+	binary.BigEndian.PutUint32(option[4:], uint32(lease/time.Second))
+	binary.BigEndian.PutUint32(option[8:], uint32(keyLease/time.Second))
+	u.msg.Additional = append(u.msg.Additional, dnsRecord{Name: ".", Type: dnsTypeOPT, Class: ednsUDPSize, Data: option})
+}
+
+// keyData returns the data of the KEY record with the public key
+func (c *SRPClient) keyData() []byte {
+	pub, _ := c.config.Key.PublicKey.ECDH()
+	point := pub.Bytes()
+	data := []byte{byte(keyFlags >> 8), byte(keyFlags & 0xff), keyProtocol, keyAlgorithmP256}
+	// The public key is X and Y without the uncompressed point prefix
+	return append(data, point[1:]...)
+}
+
+// sign encodes an update and appends its SIG(0) record. The signature
+// covers the SIG data without the signature and the message before the SIG
+// record was added (RFC 2931 3.1).
+func (c *SRPClient) sign(update *dnsMessage, now time.Time) ([]byte, error) {
+	unsigned, err := encodeDNSMessage(update)
+	if err != nil {
+		return nil, err
+	}
+
+	sig := make([]byte, 18)
+	// Type covered, labels and original TTL are 0
+	sig[2] = keyAlgorithmP256
+	binary.BigEndian.PutUint32(sig[8:], uint32(now.Add(sigValidity).Unix()))
+	binary.BigEndian.PutUint32(sig[12:], uint32(now.Add(-sigValidity).Unix()))
+	binary.BigEndian.PutUint16(sig[16:], keyTag(c.keyData()))
+	sig = append(sig, encodeName(c.hostName())...)
+
+	digest := sha256.Sum256(append(append([]byte(nil), sig...), unsigned...))
+	r, s, err := ecdsa.Sign(rand.Reader, c.config.Key, digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign SRP update: %w", err)
+	}
+	sig = append(sig, fixedBytes(r)...)
+	sig = append(sig, fixedBytes(s)...)
+
+	signed := *update
+	signed.Additional = append(append([]dnsRecord(nil), update.Additional...),
+		dnsRecord{Name: ".", Type: dnsTypeSIG, Class: dnsClassAny, Data: sig})
+	return encodeDNSMessage(&signed)
+}
+
+// fixedBytes returns a P-256 signature half as 32 bytes (RFC 6605 4)
+func fixedBytes(n *big.Int) []byte {
+	b := make([]byte, 32)
+	return n.FillBytes(b)
+}
+
+// keyTag computes the key tag of KEY record data (RFC 4034 appendix B)
+func keyTag(data []byte) uint16 {
+	var ac uint32
+	for i, b := range data {
+		if i&1 == 1 {
+			ac += uint32(b)
+		} else {
+			ac += uint32(b) << 8
+		}
+	}
+	ac += ac >> 16 & 0xffff
+	return uint16(ac)
+}
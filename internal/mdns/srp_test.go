@@ -0,0 +1,199 @@
+package mdns
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+)
+
+// fakeSRPServer answers updates with rcode and a lease option granting
+// lease seconds and passes the received packets on
+func fakeSRPServer(t *testing.T, rcode uint8, lease uint32) (string, chan []byte) {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	updates := make(chan []byte, 10)
+	go func() {
+		buf := make([]byte, 65536)
+		for {
+			n, from, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			packet := append([]byte(nil), buf[:n]...)
+			updates <- packet
+
+			update, err := parseDNSMessage(packet)
+			if err != nil {
+				continue
+			}
+			option := make([]byte, 12)
+			binary.BigEndian.PutUint16(option, ednsOptionUpdateLease)
+			binary.BigEndian.PutUint16(option[2:], 8)
+			binary.BigEndian.PutUint32(option[4:], lease)
+			binary.BigEndian.PutUint32(option[8:], lease)
+			response, _ := encodeDNSMessage(&dnsMessage{
+				ID:         update.ID,
+				Response:   true,
+				Opcode:     dnsOpcodeUpdate,
+				Rcode:      rcode,
+				Additional: []dnsRecord{{Name: ".", Type: dnsTypeOPT, Class: ednsUDPSize, Data: option}},
+			})
+			conn.WriteToUDP(response, from)
+		}
+	}()
+	return conn.LocalAddr().String(), updates
+}
+
+func newSRPTestClient(t *testing.T, server string) (*SRPClient, *ecdsa.PrivateKey) {
+	t.Helper()
+	log := logger.NewConsoleLogger(logger.ErrorLevel)
+	zone := NewMatterZone("test.local", log)
+	zone.ips = []net.IP{net.ParseIP("192.168.1.100"), net.ParseIP("2001:db8::1")}
+	zone.AddService(OperationalService(0x2906C908D115D362, 1, MatterPort))
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	client, err := NewSRPClient(SRPConfig{
+		Server:   server,
+		Zone:     zone,
+		Key:      key,
+		Lease:    2 * time.Hour,
+		KeyLease: 14 * 24 * time.Hour,
+		Logger:   log,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create SRP client: %v", err)
+	}
+	return client, key
+}
+
+// updateLease returns the lease and key lease of an update
+func updateLease(msg *dnsMessage) (uint32, uint32) {
+	for _, r := range msg.Additional {
+		if r.Type == dnsTypeOPT && len(r.Data) == 12 {
+			return binary.BigEndian.Uint32(r.Data[4:]), binary.BigEndian.Uint32(r.Data[8:])
+		}
+	}
+	return 0, 0
+}
+
+func TestSRPRegistration(t *testing.T) {
+	addr, updates := fakeSRPServer(t, 0, 3600)
+	client, key := newSRPTestClient(t, addr)
+
+	lease, err := client.register()
+	if err != nil {
+		t.Fatalf("Registration failed: %v", err)
+	}
+	if lease != time.Hour {
+		t.Errorf("Expected the granted lease of 1h, got %s", lease)
+	}
+
+	packet := <-updates
+	msg, err := parseDNSMessage(packet)
+	if err != nil {
+		t.Fatalf("Failed to parse update: %v", err)
+	}
+	if msg.Opcode != dnsOpcodeUpdate || len(msg.Questions) != 1 || msg.Questions[0].Name != srpDomain || msg.Questions[0].Type != dnsTypeSOA {
+		t.Errorf("Expected an update of %s, got %+v", srpDomain, msg)
+	}
+
+	instance := "2906c908d115d362-0000000000000001._matter._tcp." + srpDomain
+	records := make(map[string]string)
+	for _, r := range msg.Authority {
+		records[dnsTypeToString(r.Type)+" "+r.Name] = r.Value
+	}
+	if records["PTR _matter._tcp."+srpDomain] != instance || records["PTR _I2906C908D115D362._sub._matter._tcp."+srpDomain] != instance {
+		t.Errorf("Expected PTR records of the type and subtype, got %v", records)
+	}
+	if srv := records["SRV 2906C908D115D362-0000000000000001._matter._tcp."+srpDomain]; srv != "0 0 5540 test."+srpDomain {
+		t.Errorf("Expected SRV record pointing at the host, got %q", srv)
+	}
+	if aaaa := records["AAAA test."+srpDomain]; aaaa != "2001:db8::1" {
+		t.Errorf("Expected the IPv6 address only, got %v", records)
+	}
+	if lease, keyLease := updateLease(msg); lease != 7200 || keyLease != 14*24*3600 {
+		t.Errorf("Expected lease 7200s and key lease 14 days, got %d and %d", lease, keyLease)
+	}
+
+	// The SIG(0) record signs the message without it with the key of the
+	// KEY record
+	var keyData []byte
+	for _, r := range msg.Authority {
+		if r.Type == dnsTypeKEY {
+			keyData = r.Data
+		}
+	}
+	pub, _ := key.PublicKey.ECDH()
+	if len(keyData) != 68 || string(keyData[4:]) != string(pub.Bytes()[1:]) {
+		t.Fatalf("Expected KEY record with the public key, got %x", keyData)
+	}
+	sig := msg.Additional[len(msg.Additional)-1]
+	if sig.Type != dnsTypeSIG || binary.BigEndian.Uint16(sig.Data[16:]) != keyTag(keyData) {
+		t.Fatalf("Expected SIG record with the key tag last, got %+v", sig)
+	}
+	unsigned := append([]byte(nil), packet[:len(packet)-11-len(sig.Data)]...)
+	binary.BigEndian.PutUint16(unsigned[10:], uint16(len(msg.Additional)-1))
+	signature := sig.Data[len(sig.Data)-64:]
+	digest := sha256.Sum256(append(append([]byte(nil), sig.Data[:len(sig.Data)-64]...), unsigned...))
+	r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(&key.PublicKey, digest[:], r, s) {
+		t.Error("Expected a valid SIG(0) signature")
+	}
+}
+
+func TestSRPNameConflict(t *testing.T) {
+	addr, _ := fakeSRPServer(t, 6, 0)
+	client, _ := newSRPTestClient(t, addr)
+
+	if _, err := client.register(); !errors.Is(err, ErrSRPNameConflict) {
+		t.Errorf("Expected name conflict, got %v", err)
+	}
+}
+
+func TestSRPShutdownRemovesServices(t *testing.T) {
+	addr, updates := fakeSRPServer(t, 0, 3600)
+	client, _ := newSRPTestClient(t, addr)
+
+	client.Start()
+	<-updates
+	waitFor(t, "registration", func() bool {
+		registered, _ := client.Status()
+		return registered
+	})
+	if err := client.Shutdown(); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	msg, err := parseDNSMessage(<-updates)
+	if err != nil {
+		t.Fatalf("Failed to parse removal: %v", err)
+	}
+	if lease, keyLease := updateLease(msg); lease != 0 || keyLease == 0 {
+		t.Errorf("Expected lease 0 keeping the key, got %d and %d", lease, keyLease)
+	}
+	for _, r := range msg.Authority {
+		if r.Type == dnsTypePTR && r.Class != dnsClassNone {
+			t.Errorf("Expected PTR records to be deleted, got %+v", r)
+		}
+		if r.Type == dnsTypeSRV || r.Type == dnsTypeAAAA {
+			t.Errorf("Expected no records to be added, got %+v", r)
+		}
+	}
+}
@@ -1,17 +1,29 @@
 package mdns
 
 import (
+	"fmt"
 	"net"
 	"strings"
+	"sync"
 
 	"github.com/codefionn/go-matter-server/internal/logger"
 )
 
-// MatterZone implements a DNS zone for Matter server hostname advertisement
+// MatterZone implements a DNS zone advertising the Matter server's hostname
+// and its DNS-SD service instances
 type MatterZone struct {
+	logger *logger.Logger
+	ips    []net.IP
+	// Interfaces whose addresses are advertised, all if empty
+	interfaces []*net.Interface
+
+	mu       sync.RWMutex
 	hostname string
-	logger   *logger.Logger
-	ips      []net.IP
+	services []Service
+
+	// Hostname before renames after conflicts, and the number of renames
+	baseHostname string
+	renames      int
 }
 
 // NewMatterZone creates a new mDNS zone for the Matter server
@@ -26,8 +38,9 @@ func NewMatterZone(hostname string, log *logger.Logger) *MatterZone {
 	}
 
 	zone := &MatterZone{
-		hostname: hostname,
-		logger:   log,
+		hostname:     hostname,
+		baseHostname: strings.TrimSuffix(hostname, ".local"),
+		logger:       log,
 	}
 
 	// Get local IP addresses
@@ -40,7 +53,8 @@ func NewMatterZone(hostname string, log *logger.Logger) *MatterZone {
 func (z *MatterZone) Records(q Question) []Record {
 	// Normalize query name
 	qname := strings.ToLower(q.Name)
-	hostname := strings.ToLower(z.hostname)
+	name := z.GetHostname()
+	hostname := strings.ToLower(name)
 
 	z.logger.Debug("mDNS query",
 		logger.String("question", qname),
@@ -48,39 +62,45 @@ func (z *MatterZone) Records(q Question) []Record {
 		logger.String("hostname", hostname),
 	)
 
-	var records []Record
-
-	// Only respond to queries for our hostname
+	// Queries for anything but our hostname may be for a service
 	if qname != hostname {
+		records := z.serviceRecords(q, name)
+		if len(records) > 0 {
+			z.logger.Debug("mDNS service response",
+				logger.String("question", qname),
+				logger.Int("records", len(records)),
+			)
+		}
 		return records
 	}
 
-	switch q.Type {
-	case dnsTypeA:
+	var records []Record
+	if q.Type == dnsTypeA || q.Type == dnsTypeANY {
 		// Return IPv4 addresses
 		for _, ip := range z.ips {
 			if ip.To4() != nil {
 				records = append(records, &A{
 					Hdr: RR_Header{
-						Name:  z.hostname,
+						Name:  name,
 						Type:  dnsTypeA,
 						Class: 1, // IN
-						TTL:   120,
+						TTL:   hostRecordTTL,
 					},
 					A: ip,
 				})
 			}
 		}
-	case dnsTypeAAAA:
+	}
+	if q.Type == dnsTypeAAAA || q.Type == dnsTypeANY {
 		// Return IPv6 addresses
 		for _, ip := range z.ips {
 			if ip.To4() == nil && !ip.IsLoopback() {
 				records = append(records, &AAAA{
 					Hdr: RR_Header{
-						Name:  z.hostname,
+						Name:  name,
 						Type:  dnsTypeAAAA,
 						Class: 1, // IN
-						TTL:   120,
+						TTL:   hostRecordTTL,
 					},
 					AAAA: ip,
 				})
@@ -101,13 +121,27 @@ func (z *MatterZone) UpdateIPs() {
 	z.updateIPs()
 }
 
+// SetInterfaces limits the advertised addresses to the ones of interfaces,
+// matching the interfaces the server runs on. Without interfaces the
+// addresses of all interfaces are advertised.
+func (z *MatterZone) SetInterfaces(interfaces []*net.Interface) {
+	z.interfaces = interfaces
+	z.updateIPs()
+}
+
 func (z *MatterZone) updateIPs() {
 	var ips []net.IP
 
-	interfaces, err := net.Interfaces()
-	if err != nil {
-		z.logger.Error("Failed to get network interfaces", logger.ErrorField(err))
-		return
+	interfaces := z.interfaces
+	if len(interfaces) == 0 {
+		all, err := net.Interfaces()
+		if err != nil {
+			z.logger.Error("Failed to get network interfaces", logger.ErrorField(err))
+			return
+		}
+		for i := range all {
+			interfaces = append(interfaces, &all[i])
+		}
 	}
 
 	for _, iface := range interfaces {
@@ -145,9 +179,33 @@ func (z *MatterZone) updateIPs() {
 
 // GetHostname returns the advertised hostname
 func (z *MatterZone) GetHostname() string {
+	z.mu.RLock()
+	defer z.mu.RUnlock()
+	return z.hostname
+}
+
+// Rename picks the next hostname after another host claimed the current one,
+// appending -2, -3 and so on to the configured hostname
+func (z *MatterZone) Rename() string {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	z.renames++
+	z.hostname = fmt.Sprintf("%s-%d.local", z.baseHostname, z.renames+1)
 	return z.hostname
 }
 
+// AllRecords returns every record of the zone, for probing and announcing
+func (z *MatterZone) AllRecords() []Record {
+	hostname := z.GetHostname()
+	records := z.Records(Question{Name: hostname, Type: dnsTypeANY, Class: 1})
+
+	for _, name := range z.serviceNames() {
+		records = append(records, z.serviceRecords(Question{Name: name, Type: dnsTypeANY, Class: 1}, hostname)...)
+	}
+	return records
+}
+
 // GetIPs returns the current list of IP addresses
 func (z *MatterZone) GetIPs() []net.IP {
 	return z.ips
@@ -165,6 +223,8 @@ func dnsTypeToString(dnsType uint16) string {
 		return "TXT"
 	case dnsTypeSRV:
 		return "SRV"
+	case dnsTypeANY:
+		return "ANY"
 	default:
 		return "UNKNOWN"
 	}
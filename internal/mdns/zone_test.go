@@ -2,6 +2,7 @@ package mdns
 
 import (
 	"net"
+	"strings"
 	"testing"
 
 	"github.com/codefionn/go-matter-server/internal/logger"
@@ -269,3 +270,55 @@ func TestMatterZoneStringRepresentation(t *testing.T) {
 		t.Errorf("Expected record string '%s', got '%s'", expectedStr, recordStr)
 	}
 }
+
+func TestMatterZoneServices(t *testing.T) {
+	log := logger.NewConsoleLogger(logger.ErrorLevel)
+	zone := NewMatterZone("test.local", log)
+
+	zone.AddService(OperationalService(0x2906C908D115D362, 0x1B669, MatterPort))
+	commissioner, err := CommissionerService(0xFFF1, 0x8000, "test", MatterPort)
+	if err != nil {
+		t.Fatalf("Failed to create commissioner service: %v", err)
+	}
+	zone.AddService(commissioner)
+
+	operational := "2906C908D115D362-000000000001B669._matter._tcp.local"
+	tests := []struct {
+		name     string
+		question Question
+		want     []string
+	}{
+		{"Service enumeration", Question{Name: "_services._dns-sd._udp.local", Type: dnsTypePTR},
+			[]string{"_services._dns-sd._udp.local\tPTR\t_matter._tcp.local", "_services._dns-sd._udp.local\tPTR\t_matterd._udp.local"}},
+		{"Operational PTR", Question{Name: "_matter._tcp.local", Type: dnsTypePTR},
+			[]string{"_matter._tcp.local\tPTR\t" + operational}},
+		{"Fabric subtype", Question{Name: "_I2906C908D115D362._sub._matter._tcp.local", Type: dnsTypePTR},
+			[]string{"_I2906C908D115D362._sub._matter._tcp.local\tPTR\t" + operational}},
+		{"Other fabric", Question{Name: "_I0000000000000001._sub._matter._tcp.local", Type: dnsTypePTR}, nil},
+		{"Operational SRV", Question{Name: strings.ToLower(operational), Type: dnsTypeSRV},
+			[]string{operational + "\tSRV\t0 0 5540 test.local"}},
+		{"Operational TXT", Question{Name: operational, Type: dnsTypeTXT},
+			[]string{operational + "\tTXT\t[SII=500 SAI=300 SAT=4000 T=0]"}},
+		{"Vendor subtype", Question{Name: "_V65521._sub._matterd._udp.local", Type: dnsTypePTR},
+			[]string{"_V65521._sub._matterd._udp.local\tPTR\t" + commissioner.Instance + "._matterd._udp.local"}},
+		{"Commissioner TXT", Question{Name: commissioner.Instance + "._matterd._udp.local", Type: dnsTypeTXT},
+			[]string{commissioner.Instance + "._matterd._udp.local\tTXT\t[VP=65521+32768 DN=test SII=500 SAI=300 SAT=4000 T=0]"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, r := range zone.Records(tt.question) {
+				got = append(got, r.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	zone.RemoveService(commissioner.Instance, CommissionerServiceType)
+	if services := zone.Services(); len(services) != 1 || services[0].Type != OperationalServiceType {
+		t.Errorf("Expected only the operational service, got %+v", services)
+	}
+}
@@ -0,0 +1,424 @@
+// Package migrate imports the storage of python-matter-server, so existing
+// installations can switch servers without re-commissioning their devices.
+//
+// python-matter-server keeps two files in its storage directory: chip.json,
+// the Matter SDK's key/value store holding the fabric credentials, and
+// <compressed fabric ID>.json holding the node dumps and vendor info.
+package migrate
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/credentials"
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/storage"
+)
+
+// SDK storage keys of the example operational credentials issuer used by
+// python-matter-server, followed by the issuer index
+const (
+	caKeyPrefix  = "ExampleOpCredsCAKey"
+	rootCertKey  = "ExampleCARootCert"
+	chipFileName = "chip.json"
+)
+
+// Options configures an import
+type Options struct {
+	// Source is chip.json or the node storage file of python-matter-server.
+	// The other file is looked up in the same directory.
+	Source string
+
+	StoragePath string
+	FabricID    uint64
+
+	// Force overwrites existing nodes and fabric credentials
+	Force bool
+
+	// Cipher encrypts the imported data, nil stores it unencrypted
+	Cipher *storage.Cipher
+
+	Logger *logger.Logger
+}
+
+// Result summarizes an import
+type Result struct {
+	Nodes   int
+	Vendors int
+
+	// CredentialsImported is set when the fabric root CA was imported
+	CredentialsImported bool
+	CompressedFabricID  uint64
+
+	// Warnings lists data that could not be imported
+	Warnings []string
+}
+
+// pythonStorage is the node storage file of python-matter-server
+type pythonStorage struct {
+	Nodes      map[string]json.RawMessage   `json:"nodes"`
+	VendorInfo map[string]models.VendorInfo `json:"vendor_info"`
+}
+
+// pythonNode is a node dump of python-matter-server. Timestamps lack a time
+// zone and attribute subscriptions are [endpoint, cluster, attribute] lists.
+type pythonNode struct {
+	NodeID                 int                    `json:"node_id"`
+	DateCommissioned       string                 `json:"date_commissioned"`
+	LastInterview          string                 `json:"last_interview"`
+	InterviewVersion       int                    `json:"interview_version"`
+	Available              bool                   `json:"available"`
+	IsBridge               bool                   `json:"is_bridge"`
+	Attributes             map[string]interface{} `json:"attributes"`
+	AttributeSubscriptions []json.RawMessage      `json:"attribute_subscriptions"`
+}
+
+// chipStorage is the Matter SDK key/value store, values are base64 encoded
+type chipStorage struct {
+	SDKConfig map[string]string `json:"sdk-config"`
+}
+
+// FromPython imports python-matter-server storage into opts.StoragePath
+func FromPython(opts Options) (*Result, error) {
+	chipPath, nodesPath, err := locateFiles(opts.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	if !opts.Force {
+		if err := checkDestination(opts.StoragePath, opts.Cipher); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &Result{}
+	if nodesPath == "" {
+		result.Warnings = append(result.Warnings, "no node storage file found next to "+chipPath)
+	} else if err := importNodes(opts, nodesPath, result); err != nil {
+		return nil, err
+	}
+
+	if chipPath == "" {
+		result.Warnings = append(result.Warnings, "no chip.json found, devices must be re-commissioned")
+	} else if err := importCredentials(opts, chipPath, result); err != nil {
+		return nil, err
+	}
+
+	if nodesPath != "" && result.CredentialsImported {
+		checkCompressedFabricID(nodesPath, result)
+	}
+	return result, nil
+}
+
+// checkDestination refuses to overwrite a storage directory that is in use
+func checkDestination(storagePath string, cipher *storage.Cipher) error {
+	index, err := cipher.ReadFile(filepath.Join(storagePath, "nodes", "index.json"))
+	if storage.IsKeyError(err) {
+		return fmt.Errorf("failed to read storage %s: %w", storagePath, err)
+	}
+	var nodeIDs []int
+	if err == nil && json.Unmarshal(index, &nodeIDs) == nil && len(nodeIDs) > 0 {
+		return fmt.Errorf("storage %s already holds %d nodes, use --force to overwrite", storagePath, len(nodeIDs))
+	}
+	var legacy map[string]json.RawMessage
+	if err := readJSON(filepath.Join(storagePath, "nodes.json"), &legacy); err == nil && len(legacy) > 0 {
+		return fmt.Errorf("storage %s already holds %d nodes, use --force to overwrite", storagePath, len(legacy))
+	}
+	if _, err := os.Stat(filepath.Join(storagePath, "credentials", "rcac.pem")); err == nil {
+		return fmt.Errorf("storage %s already has fabric credentials, use --force to overwrite", storagePath)
+	}
+	return nil
+}
+
+// locateFiles returns the paths of chip.json and the node storage file given
+// either of them
+func locateFiles(source string) (chipPath, nodesPath string, err error) {
+	var probe struct {
+		SDKConfig json.RawMessage `json:"sdk-config"`
+		Nodes     json.RawMessage `json:"nodes"`
+	}
+	if err := readJSON(source, &probe); err != nil {
+		return "", "", err
+	}
+
+	switch {
+	case probe.SDKConfig != nil:
+		chipPath = source
+	case probe.Nodes != nil:
+		nodesPath = source
+	default:
+		return "", "", fmt.Errorf("%s is neither chip.json nor a python-matter-server node storage file", source)
+	}
+
+	dir := filepath.Dir(source)
+	if chipPath == "" {
+		if _, err := os.Stat(filepath.Join(dir, chipFileName)); err == nil {
+			chipPath = filepath.Join(dir, chipFileName)
+		}
+		return chipPath, nodesPath, nil
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return "", "", err
+	}
+	var candidates []string
+	for _, path := range matches {
+		if filepath.Base(path) == chipFileName {
+			continue
+		}
+		var data pythonStorage
+		if readJSON(path, &data) == nil && data.Nodes != nil {
+			candidates = append(candidates, path)
+		}
+	}
+	if len(candidates) > 1 {
+		return "", "", fmt.Errorf("found several node storage files (%s), pass the one to import",
+			strings.Join(candidates, ", "))
+	}
+	if len(candidates) == 1 {
+		nodesPath = candidates[0]
+	}
+	return chipPath, nodesPath, nil
+}
+
+func importNodes(opts Options, path string, result *Result) error {
+	var data pythonStorage
+	if err := readJSON(path, &data); err != nil {
+		return err
+	}
+
+	store := storage.NewJSONStorage(opts.StoragePath, opts.Logger)
+	store.SetCipher(opts.Cipher)
+	if err := store.Start(); err != nil {
+		return err
+	}
+
+	nodes := make([]*models.MatterNodeData, 0, len(data.Nodes))
+	for key, raw := range data.Nodes {
+		node, err := convertNode(raw)
+		if err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("skipped node %s: %v", key, err))
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	if err := store.SaveNodes(nodes); err != nil {
+		return fmt.Errorf("failed to save nodes: %w", err)
+	}
+	result.Nodes += len(nodes)
+
+	vendors := make([]*models.VendorInfo, 0, len(data.VendorInfo))
+	for key, vendor := range data.VendorInfo {
+		vendor := vendor
+		if vendor.VendorID == 0 {
+			vendor.VendorID, _ = strconv.Atoi(key)
+		}
+		vendors = append(vendors, &vendor)
+	}
+	if err := store.SaveVendors(vendors); err != nil {
+		return fmt.Errorf("failed to save vendors: %w", err)
+	}
+	result.Vendors += len(vendors)
+
+	return store.Stop()
+}
+
+// convertNode converts a python-matter-server node dump
+func convertNode(raw json.RawMessage) (*models.MatterNodeData, error) {
+	if string(raw) == "null" {
+		return nil, errors.New("no node data")
+	}
+	var node pythonNode
+	if err := json.Unmarshal(raw, &node); err != nil {
+		return nil, err
+	}
+
+	converted := &models.MatterNodeData{
+		NodeID:                 node.NodeID,
+		InterviewVersion:       node.InterviewVersion,
+		Available:              node.Available,
+		IsBridge:               node.IsBridge,
+		Attributes:             node.Attributes,
+		AttributeSubscriptions: []models.AttributeSubscription{},
+	}
+	if converted.Attributes == nil {
+		converted.Attributes = make(map[string]interface{})
+	}
+
+	var err error
+	if converted.DateCommissioned, err = parseTime(node.DateCommissioned); err != nil {
+		return nil, fmt.Errorf("invalid date_commissioned: %w", err)
+	}
+	if converted.LastInterview, err = parseTime(node.LastInterview); err != nil {
+		return nil, fmt.Errorf("invalid last_interview: %w", err)
+	}
+
+	for _, raw := range node.AttributeSubscriptions {
+		subscription, err := convertSubscription(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid attribute subscription %s: %w", raw, err)
+		}
+		converted.AttributeSubscriptions = append(converted.AttributeSubscriptions, subscription)
+	}
+	return converted, nil
+}
+
+// convertSubscription accepts [endpoint, cluster, attribute] lists, where
+// each part may be null, and this server's object form
+func convertSubscription(raw json.RawMessage) (models.AttributeSubscription, error) {
+	var subscription models.AttributeSubscription
+	var parts []*int
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		err = json.Unmarshal(raw, &subscription)
+		return subscription, err
+	}
+	if len(parts) != 3 {
+		return subscription, fmt.Errorf("expected 3 path parts, got %d", len(parts))
+	}
+	subscription.EndpointID, subscription.ClusterID, subscription.AttributeID = parts[0], parts[1], parts[2]
+	return subscription, nil
+}
+
+// parseTime parses Python isoformat timestamps. Timestamps without zone are
+// UTC, as python-matter-server stores them.
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02 15:04:05.999999999"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown time format %q", value)
+}
+
+func importCredentials(opts Options, path string, result *Result) error {
+	var data chipStorage
+	if err := readJSON(path, &data); err != nil {
+		return err
+	}
+
+	index, ok := issuerIndex(data.SDKConfig)
+	if !ok {
+		result.Warnings = append(result.Warnings, "no fabric root CA in "+path+", devices must be re-commissioned")
+		return nil
+	}
+	cert, key, err := decodeRoot(data.SDKConfig[caKeyPrefix+index], data.SDKConfig[rootCertKey+index])
+	if err != nil {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("fabric root CA not imported, devices must be re-commissioned: %v", err))
+		return nil
+	}
+
+	authority := credentials.NewAuthority(opts.StoragePath, opts.FabricID, opts.Logger)
+	authority.SetCipher(opts.Cipher)
+	if err := authority.ImportRoot(cert, key); err != nil {
+		return err
+	}
+
+	result.CredentialsImported = true
+	result.CompressedFabricID = authority.CompressedFabricID()
+	return nil
+}
+
+// issuerIndex returns the lowest issuer index with both a CA key and a root
+// certificate
+func issuerIndex(config map[string]string) (string, bool) {
+	var indexes []int
+	for key := range config {
+		if index, ok := strings.CutPrefix(key, caKeyPrefix); ok {
+			if n, err := strconv.Atoi(index); err == nil && config[rootCertKey+index] != "" {
+				indexes = append(indexes, n)
+			}
+		}
+	}
+	if len(indexes) == 0 {
+		return "", false
+	}
+	sort.Ints(indexes)
+	return strconv.Itoa(indexes[0]), true
+}
+
+// decodeRoot decodes the root CA stored by the SDK: the key pair as the
+// uncompressed public key followed by the private scalar, the certificate
+// as X.509 DER
+func decodeRoot(keyValue, certValue string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	keyPair, err := base64.StdEncoding.DecodeString(keyValue)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid CA key encoding: %w", err)
+	}
+	if len(keyPair) != 65+32 {
+		return nil, nil, fmt.Errorf("unexpected CA key length %d", len(keyPair))
+	}
+
+	private, err := ecdh.P256().NewPrivateKey(keyPair[65:])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid CA private key: %w", err)
+	}
+	public := private.PublicKey().Bytes()
+	if !bytes.Equal(public, keyPair[:65]) {
+		return nil, nil, errors.New("CA public key does not match the private key")
+	}
+	key := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(public[1:33]),
+			Y:     new(big.Int).SetBytes(public[33:]),
+		},
+		D: new(big.Int).SetBytes(keyPair[65:]),
+	}
+
+	der, err := base64.StdEncoding.DecodeString(certValue)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid root certificate encoding: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, fmt.Errorf("root certificate is not X.509: %w", err)
+	}
+	return cert, key, nil
+}
+
+// checkCompressedFabricID warns when the node storage file, named after the
+// compressed fabric ID, belongs to a different fabric than the imported
+// root and fabric ID
+func checkCompressedFabricID(nodesPath string, result *Result) {
+	name := strings.TrimSuffix(filepath.Base(nodesPath), ".json")
+	decimal, decErr := strconv.ParseUint(name, 10, 64)
+	hex, hexErr := strconv.ParseUint(name, 16, 64)
+	if decErr != nil && hexErr != nil {
+		return
+	}
+	if (decErr == nil && decimal == result.CompressedFabricID) || (hexErr == nil && hex == result.CompressedFabricID) {
+		return
+	}
+	result.Warnings = append(result.Warnings, fmt.Sprintf(
+		"compressed fabric ID %016X doesn't match %s, check the fabric ID", result.CompressedFabricID, filepath.Base(nodesPath)))
+}
+
+func readJSON(path string, target interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, target); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return nil
+}
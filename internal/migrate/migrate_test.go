@@ -0,0 +1,196 @@
+package migrate
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/credentials"
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/storage"
+)
+
+const pythonNodes = `{
+  "nodes": {
+    "1": {
+      "node_id": 1,
+      "date_commissioned": "2024-03-01T10:15:30.123456",
+      "last_interview": "2024-03-02T08:00:00.000001",
+      "interview_version": 6,
+      "available": true,
+      "is_bridge": false,
+      "attributes": {"0/40/1": "Acme", "1/6/0": true},
+      "attribute_subscriptions": [[1, 6, 0], [null, 8, 0]]
+    },
+    "2": null
+  },
+  "last_node_id": 2,
+  "vendor_info": {
+    "4937": {"vendor_id": 4937, "vendor_name": "Acme", "company_legal_name": "Acme Inc.",
+             "company_preferred_name": "Acme", "vendor_landing_page_url": "", "creator": "dcl"}
+  }
+}`
+
+// writePythonStorage writes a python-matter-server storage directory with a
+// root CA as stored by the SDK's example credentials issuer
+func writePythonStorage(t *testing.T, fabricID uint64) (dir string, root *x509.Certificate) {
+	t.Helper()
+	dir = t.TempDir()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "RCAC"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	root, _ = x509.ParseCertificate(der)
+
+	ecdhKey, err := key.ECDH()
+	if err != nil {
+		t.Fatalf("Failed to convert key: %v", err)
+	}
+	keyPair := append(ecdhKey.PublicKey().Bytes(), ecdhKey.Bytes()...)
+	chip := map[string]interface{}{
+		"sdk-config": map[string]string{
+			"ExampleOpCredsCAKey1": base64.StdEncoding.EncodeToString(keyPair),
+			"ExampleCARootCert1":   base64.StdEncoding.EncodeToString(der),
+			"f/1/n":                base64.StdEncoding.EncodeToString([]byte("noc")),
+		},
+	}
+	data, _ := json.Marshal(chip)
+	if err := os.WriteFile(filepath.Join(dir, chipFileName), data, 0600); err != nil {
+		t.Fatalf("Failed to write chip.json: %v", err)
+	}
+
+	compressed, err := credentials.CompressedFabricID(&key.PublicKey, fabricID)
+	if err != nil {
+		t.Fatalf("Failed to derive compressed fabric ID: %v", err)
+	}
+	nodesPath := filepath.Join(dir, fmt.Sprintf("%d.json", compressed))
+	if err := os.WriteFile(nodesPath, []byte(pythonNodes), 0600); err != nil {
+		t.Fatalf("Failed to write node storage: %v", err)
+	}
+	return dir, root
+}
+
+func TestFromPython(t *testing.T) {
+	source, root := writePythonStorage(t, 1)
+	dest := t.TempDir()
+	log := logger.NewConsoleLogger(logger.ErrorLevel)
+
+	result, err := FromPython(Options{
+		Source:      filepath.Join(source, chipFileName),
+		StoragePath: dest,
+		FabricID:    1,
+		Logger:      log,
+	})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if result.Nodes != 1 || result.Vendors != 1 || !result.CredentialsImported {
+		t.Errorf("Expected 1 node, 1 vendor and credentials, got %+v", result)
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "skipped node 2") {
+		t.Errorf("Expected warning for the empty node only, got %q", result.Warnings)
+	}
+
+	store := storage.NewJSONStorage(dest, log)
+	if err := store.Start(); err != nil {
+		t.Fatalf("Failed to open storage: %v", err)
+	}
+	node, err := store.GetNode(1)
+	if err != nil {
+		t.Fatalf("Expected imported node: %v", err)
+	}
+	if want := time.Date(2024, 3, 1, 10, 15, 30, 123456000, time.UTC); !node.DateCommissioned.Equal(want) {
+		t.Errorf("Expected commissioning date %s, got %s", want, node.DateCommissioned)
+	}
+	if node.Attributes["0/40/1"] != "Acme" || node.InterviewVersion != 6 {
+		t.Errorf("Expected node attributes to be kept, got %+v", node)
+	}
+	subs := node.AttributeSubscriptions
+	if len(subs) != 2 || *subs[0].EndpointID != 1 || subs[1].EndpointID != nil || *subs[1].ClusterID != 8 {
+		t.Errorf("Expected converted attribute subscriptions, got %+v", subs)
+	}
+	if vendor, err := store.GetVendor(4937); err != nil || vendor.VendorName != "Acme" {
+		t.Errorf("Expected imported vendor, got %+v (%v)", vendor, err)
+	}
+
+	authority := credentials.NewAuthority(dest, 1, log)
+	if err := authority.Load(); err != nil {
+		t.Fatalf("Failed to load credentials: %v", err)
+	}
+	if !authority.RootCertificate().Equal(root) {
+		t.Error("Expected the python root CA to be imported")
+	}
+	if authority.CompressedFabricID() != result.CompressedFabricID {
+		t.Error("Expected reported compressed fabric ID")
+	}
+
+	// A second import must not overwrite the migrated storage
+	_, err = FromPython(Options{Source: filepath.Join(source, chipFileName), StoragePath: dest, FabricID: 1, Logger: log})
+	if err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Errorf("Expected refusal to overwrite, got %v", err)
+	}
+}
+
+func TestFromPythonFabricMismatch(t *testing.T) {
+	source, _ := writePythonStorage(t, 1)
+	matches, _ := filepath.Glob(filepath.Join(source, "[0-9]*.json"))
+	if len(matches) != 1 {
+		t.Fatalf("Expected node storage file, got %v", matches)
+	}
+
+	// Given the node file, chip.json is found next to it
+	result, err := FromPython(Options{
+		Source:      matches[0],
+		StoragePath: t.TempDir(),
+		FabricID:    2,
+		Logger:      logger.NewConsoleLogger(logger.ErrorLevel),
+	})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if !result.CredentialsImported {
+		t.Fatal("Expected credentials to be imported")
+	}
+	if last := result.Warnings[len(result.Warnings)-1]; !strings.Contains(last, "check the fabric ID") {
+		t.Errorf("Expected fabric ID warning, got %q", result.Warnings)
+	}
+}
+
+func TestDecodeRootRejectsMismatchedKey(t *testing.T) {
+	a, _ := ecdh.P256().GenerateKey(rand.Reader)
+	b, _ := ecdh.P256().GenerateKey(rand.Reader)
+	keyPair := base64.StdEncoding.EncodeToString(append(a.PublicKey().Bytes(), b.Bytes()...))
+
+	if _, _, err := decodeRoot(keyPair, ""); err == nil {
+		t.Error("Expected mismatched key pair to be rejected")
+	}
+	if _, _, err := decodeRoot("AAAA", ""); err == nil {
+		t.Error("Expected short key to be rejected")
+	}
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"encoding/json"
+)
+
+// Codec encodes and decodes WebSocket API messages. Codecs are negotiated
+// via the Sec-WebSocket-Protocol header using their name.
+type Codec interface {
+	// Name is the WebSocket subprotocol selecting the codec
+	Name() string
+
+	// Binary reports whether messages are sent as binary frames
+	Binary() bool
+
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// Codec names used as WebSocket subprotocols
+const (
+	CodecNameJSON        = "json"
+	CodecNameMessagePack = "msgpack"
+)
+
+var (
+	// JSONCodec is the default codec used when no subprotocol is negotiated
+	JSONCodec Codec = jsonCodec{}
+
+	// MessagePackCodec encodes messages as MessagePack. Messages have the
+	// same structure and field names as their JSON form.
+	MessagePackCodec Codec = messagePackCodec{}
+)
+
+// Codecs lists the supported codecs in order of server preference
+var Codecs = []Codec{MessagePackCodec, JSONCodec}
+
+// CodecByName returns the codec for a subprotocol name
+func CodecByName(name string) (Codec, bool) {
+	for _, codec := range Codecs {
+		if codec.Name() == name {
+			return codec, true
+		}
+	}
+	return nil, false
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return CodecNameJSON }
+
+func (jsonCodec) Binary() bool { return false }
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type messagePackCodec struct{}
+
+func (messagePackCodec) Name() string { return CodecNameMessagePack }
+
+func (messagePackCodec) Binary() bool { return true }
+
+// Marshal encodes v via its JSON representation, so struct tags and custom
+// JSON marshalers apply unchanged
+func (messagePackCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return jsonToMessagePack(data)
+}
+
+func (messagePackCodec) Unmarshal(data []byte, v interface{}) error {
+	jsonData, err := messagePackToJSON(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(jsonData, v)
+}
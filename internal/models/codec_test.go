@@ -0,0 +1,117 @@
+package models
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestMessagePackEncoding(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  []byte
+	}{
+		{"Nil", nil, []byte{0xc0}},
+		{"True", true, []byte{0xc3}},
+		{"Positive fixint", 5, []byte{0x05}},
+		{"Negative fixint", -1, []byte{0xff}},
+		{"Uint8", 200, []byte{0xcc, 0xc8}},
+		{"Int16", -300, []byte{0xd1, 0xfe, 0xd4}},
+		{"Uint64", uint64(math.MaxUint64), []byte{0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{"Float", 1.5, []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{"Fixstr", "abc", []byte{0xa3, 'a', 'b', 'c'}},
+		{"Array", []int{1, 2}, []byte{0x92, 0x01, 0x02}},
+		{"Map with sorted keys", map[string]int{"b": 2, "a": 1}, []byte{0x82, 0xa1, 'a', 0x01, 0xa1, 'b', 0x02}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MessagePackCodec.Marshal(tt.value)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("Expected % x, got % x", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMessagePackRoundTrip(t *testing.T) {
+	details := "failed"
+	messages := []interface{}{
+		ServerInfoMessage{FabricID: 1, CompressedFabricID: 9790456428425683248, SchemaVersion: 11, SDKVersion: "test"},
+		ErrorResultMessage{
+			ResultMessageBase:       ResultMessageBase{MessageID: "1"},
+			ErrorCode:               ErrorCodeSchemaVersionMismatch,
+			Details:                 &details,
+			SupportedSchemaVersions: &SchemaVersionRange{Min: 1, Max: 11},
+		},
+		CommandMessage{MessageID: "2", Command: "device_command", Args: map[string]interface{}{
+			"node_id": float64(5),
+			"payload": map[string]interface{}{"level": float64(-12), "name": string(make([]byte, 300))},
+			"paths":   []interface{}{"1/6/0", nil, true, 0.25},
+		}},
+	}
+
+	for _, msg := range messages {
+		data, err := MessagePackCodec.Marshal(msg)
+		if err != nil {
+			t.Fatalf("Failed to marshal %T: %v", msg, err)
+		}
+
+		decoded := reflect.New(reflect.TypeOf(msg))
+		if err := MessagePackCodec.Unmarshal(data, decoded.Interface()); err != nil {
+			t.Fatalf("Failed to unmarshal %T: %v", msg, err)
+		}
+		if !reflect.DeepEqual(decoded.Elem().Interface(), msg) {
+			t.Errorf("Expected %+v, got %+v", msg, decoded.Elem().Interface())
+		}
+	}
+}
+
+func TestMessagePackDecodeBinary(t *testing.T) {
+	// Binary values decode like octet strings in JSON (base64)
+	var v map[string][]byte
+	data := []byte{0x81, 0xa1, 'k', 0xc4, 0x02, 0x01, 0x02}
+	if err := MessagePackCodec.Unmarshal(data, &v); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !bytes.Equal(v["k"], []byte{0x01, 0x02}) {
+		t.Errorf("Expected [1 2], got %v", v["k"])
+	}
+}
+
+func TestMessagePackDecodeErrors(t *testing.T) {
+	tests := map[string][]byte{
+		"Truncated string": {0xa3, 'a'},
+		"Truncated map":    {0x81, 0xa1, 'k'},
+		"Trailing bytes":   {0x01, 0x02},
+		"Unsupported type": {0xc1},
+		"Huge array":       {0xdd, 0xff, 0xff, 0xff, 0xff},
+		"Nested too deep":  bytes.Repeat([]byte{0x91}, maxMessagePackDepth+1),
+	}
+
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			var v interface{}
+			if err := MessagePackCodec.Unmarshal(data, &v); err == nil {
+				t.Errorf("Expected error, got %v", v)
+			}
+		})
+	}
+}
+
+func TestCodecByName(t *testing.T) {
+	if codec, ok := CodecByName("msgpack"); !ok || codec != MessagePackCodec {
+		t.Error("Expected msgpack codec")
+	}
+	if codec, ok := CodecByName("json"); !ok || codec != JSONCodec {
+		t.Error("Expected JSON codec")
+	}
+	if _, ok := CodecByName("cbor"); ok {
+		t.Error("Expected cbor to be unsupported")
+	}
+}
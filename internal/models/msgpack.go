@@ -0,0 +1,334 @@
+package models
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// jsonToMessagePack converts a JSON document to MessagePack. Integers use the
+// smallest encoding, other numbers become float64.
+func jsonToMessagePack(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	var buf []byte
+	return appendMessagePack(buf, value)
+}
+
+func appendMessagePack(buf []byte, value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return append(buf, 0xc0), nil
+	case bool:
+		if v {
+			return append(buf, 0xc3), nil
+		}
+		return append(buf, 0xc2), nil
+	case json.Number:
+		if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return appendMessagePackInt(buf, i), nil
+		}
+		if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			return appendMessagePackUint(buf, u), nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, 0xcb)
+		return binary.BigEndian.AppendUint64(buf, math.Float64bits(f)), nil
+	case string:
+		return appendMessagePackString(buf, v), nil
+	case []interface{}:
+		buf = appendMessagePackHeader(buf, len(v), 0x90, 0xdc)
+		var err error
+		for _, item := range v {
+			if buf, err = appendMessagePack(buf, item); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		buf = appendMessagePackHeader(buf, len(v), 0x80, 0xde)
+		var err error
+		for _, key := range keys {
+			buf = appendMessagePackString(buf, key)
+			if buf, err = appendMessagePack(buf, v[key]); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	}
+	return nil, fmt.Errorf("msgpack: unsupported value of type %T", value)
+}
+
+func appendMessagePackInt(buf []byte, v int64) []byte {
+	switch {
+	case v >= 0:
+		return appendMessagePackUint(buf, uint64(v))
+	case v >= -32:
+		return append(buf, byte(v))
+	case v >= math.MinInt8:
+		return append(buf, 0xd0, byte(v))
+	case v >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(buf, 0xd1), uint16(v))
+	case v >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(buf, 0xd2), uint32(v))
+	}
+	return binary.BigEndian.AppendUint64(append(buf, 0xd3), uint64(v))
+}
+
+func appendMessagePackUint(buf []byte, v uint64) []byte {
+	switch {
+	case v <= 0x7f:
+		return append(buf, byte(v))
+	case v <= math.MaxUint8:
+		return append(buf, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, 0xcd), uint16(v))
+	case v <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(buf, 0xce), uint32(v))
+	}
+	return binary.BigEndian.AppendUint64(append(buf, 0xcf), v)
+}
+
+func appendMessagePackString(buf []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		buf = append(buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		buf = append(buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		buf = binary.BigEndian.AppendUint16(append(buf, 0xda), uint16(n))
+	default:
+		buf = binary.BigEndian.AppendUint32(append(buf, 0xdb), uint32(n))
+	}
+	return append(buf, s...)
+}
+
+// appendMessagePackHeader writes an array or map header. The 16 and 32 bit
+// forms follow the given first long form marker.
+func appendMessagePackHeader(buf []byte, n int, fix, long byte) []byte {
+	switch {
+	case n < 16:
+		return append(buf, fix|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, long), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(buf, long+1), uint32(n))
+}
+
+// messagePackToJSON converts a MessagePack document to JSON. Binary values
+// become base64 strings, as []byte does in JSON.
+func messagePackToJSON(data []byte) ([]byte, error) {
+	d := messagePackDecoder{buf: data}
+	value, err := d.value()
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(data) {
+		return nil, fmt.Errorf("msgpack: %d trailing bytes", len(data)-d.pos)
+	}
+	return json.Marshal(value)
+}
+
+// maxMessagePackDepth limits the nesting of arrays and maps like
+// encoding/json does for JSON
+const maxMessagePackDepth = 10000
+
+type messagePackDecoder struct {
+	buf   []byte
+	pos   int
+	depth int
+}
+
+func (d *messagePackDecoder) read(n int) ([]byte, error) {
+	if n < 0 || n > len(d.buf)-d.pos {
+		return nil, fmt.Errorf("msgpack: unexpected end of data at offset %d", d.pos)
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *messagePackDecoder) uint(n int) (uint64, error) {
+	b, err := d.read(n)
+	if err != nil {
+		return 0, err
+	}
+	switch n {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	default:
+		return binary.BigEndian.Uint64(b), nil
+	}
+}
+
+func (d *messagePackDecoder) value() (interface{}, error) {
+	b, err := d.read(1)
+	if err != nil {
+		return nil, err
+	}
+	marker := b[0]
+
+	switch {
+	case marker <= 0x7f:
+		return int64(marker), nil
+	case marker >= 0xe0:
+		return int64(int8(marker)), nil
+	case marker >= 0x80 && marker <= 0x8f:
+		return d.mapValue(int(marker & 0x0f))
+	case marker >= 0x90 && marker <= 0x9f:
+		return d.array(int(marker & 0x0f))
+	case marker >= 0xa0 && marker <= 0xbf:
+		return d.str(int(marker & 0x1f))
+	}
+
+	switch marker {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.uint(1 << (marker - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.read(int(n))
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), b...), nil
+	case 0xca:
+		v, err := d.uint(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(uint32(v))), nil
+	case 0xcb:
+		v, err := d.uint(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(v), nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return d.uint(1 << (marker - 0xcc))
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (marker - 0xd0)
+		v, err := d.uint(size)
+		if err != nil {
+			return nil, err
+		}
+		shift := 64 - 8*size
+		return int64(v<<shift) >> shift, nil
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.uint(1 << (marker - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.str(int(n))
+	case 0xdc, 0xdd:
+		n, err := d.uint(2 << (marker - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.array(int(n))
+	case 0xde, 0xdf:
+		n, err := d.uint(2 << (marker - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.mapValue(int(n))
+	}
+	return nil, fmt.Errorf("msgpack: unsupported type 0x%02x at offset %d", marker, d.pos-1)
+}
+
+func (d *messagePackDecoder) str(n int) (string, error) {
+	b, err := d.read(n)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (d *messagePackDecoder) array(n int) ([]interface{}, error) {
+	if n > len(d.buf)-d.pos {
+		return nil, fmt.Errorf("msgpack: array length %d exceeds data", n)
+	}
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer d.leave()
+	items := make([]interface{}, n)
+	for i := range items {
+		item, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		items[i] = item
+	}
+	return items, nil
+}
+
+func (d *messagePackDecoder) mapValue(n int) (map[string]interface{}, error) {
+	if n > len(d.buf)-d.pos {
+		return nil, fmt.Errorf("msgpack: map length %d exceeds data", n)
+	}
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer d.leave()
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		key, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		value, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		// JSON only has string keys
+		switch k := key.(type) {
+		case string:
+			m[k] = value
+		default:
+			m[fmt.Sprint(k)] = value
+		}
+	}
+	return m, nil
+}
+
+// enter counts a nested array or map, leave ends it
+func (d *messagePackDecoder) enter() error {
+	if d.depth++; d.depth > maxMessagePackDepth {
+		return fmt.Errorf("msgpack: nested deeper than %d at offset %d", maxMessagePackDepth, d.pos)
+	}
+	return nil
+}
+
+func (d *messagePackDecoder) leave() {
+	d.depth--
+}
@@ -1,6 +1,9 @@
 package models
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -19,6 +22,25 @@ const (
 	EventTypeServerInfoUpdated EventType = "server_info_updated"
 	EventTypeEndpointAdded     EventType = "endpoint_added"
 	EventTypeEndpointRemoved   EventType = "endpoint_removed"
+	EventTypeClockSkewDetected EventType = "clock_skew_detected"
+	EventTypeCommandProgress   EventType = "command_progress"
+	EventTypeServerRestarting  EventType = "server_restarting"
+	EventTypeScanResult        EventType = "scan_result"
+
+	// EventTypeQueuedInteractionCompleted is emitted when a command or
+	// write queued for a sleeping node was sent after it checked in
+	EventTypeQueuedInteractionCompleted EventType = "queued_interaction_completed"
+	// Sent with BluetoothStatus when Bluetooth becomes available or
+	// unavailable or the adapters change
+	EventTypeBluetoothStatusChanged EventType = "bluetooth_status_changed"
+	// Sent with LogEntry for each log entry, only to clients listing it in
+	// the events filter of start_listening
+	EventTypeLogEntry EventType = "log_entry"
+	// Sent with SubsystemRestart when a subsystem failed and is restarted
+	EventTypeSubsystemRestarted EventType = "subsystem_restarted"
+	// Sent with NodeFirmware when a software update becomes available for a
+	// node, or a newer one than previously found
+	EventTypeFirmwareUpdateAvailable EventType = "firmware_update_available"
 )
 
 // APICommand represents different API commands available
@@ -50,6 +72,48 @@ const (
 	APICommandSetDefaultFabricLabel   APICommand = "set_default_fabric_label"
 	APICommandSetACLEntry             APICommand = "set_acl_entry"
 	APICommandSetNodeBinding          APICommand = "set_node_binding"
+	APICommandCreateGroup             APICommand = "create_group"
+	APICommandRemoveGroup             APICommand = "remove_group"
+	APICommandGetGroups               APICommand = "get_groups"
+	APICommandAddGroupMember          APICommand = "add_group_member"
+	APICommandRemoveGroupMember       APICommand = "remove_group_member"
+	APICommandGroupCommand            APICommand = "group_command"
+	APICommandGetNodeFabrics          APICommand = "get_node_fabrics"
+	APICommandRemoveNodeFabric        APICommand = "remove_node_fabric"
+	APICommandCancel                  APICommand = "cancel"
+	APICommandGetSessions             APICommand = "get_sessions"
+	APICommandDisconnectSession       APICommand = "disconnect_session"
+	APICommandGetEventHistory         APICommand = "get_event_history"
+	APICommandGetAttributeHistory     APICommand = "get_attribute_history"
+	APICommandExportSettings          APICommand = "export_settings"
+	APICommandImportSettings          APICommand = "import_settings"
+	APICommandDiscoverBLE             APICommand = "discover_ble"
+	APICommandGetNetworkInfo          APICommand = "get_network_info"
+	APICommandSetServerSetting        APICommand = "set_server_setting"
+	APICommandGetServerSettings       APICommand = "get_server_settings"
+	APICommandGetLogs                 APICommand = "get_logs"
+	APICommandSetLogLevel             APICommand = "set_log_level"
+	APICommandGetAuditLog             APICommand = "get_audit_log"
+	APICommandGetReplicationStatus    APICommand = "get_replication_status"
+	APICommandPromoteStandby          APICommand = "promote_standby"
+	APICommandSetNodeName             APICommand = "set_node_name"
+	APICommandSetNodeMetadata         APICommand = "set_node_metadata"
+	APICommandGetDevices              APICommand = "get_devices"
+	APICommandDeviceAction            APICommand = "device_action"
+	APICommandStoreDeviceScene        APICommand = "store_device_scene"
+	APICommandRecallDeviceScene       APICommand = "recall_device_scene"
+	APICommandRemoveDeviceScene       APICommand = "remove_device_scene"
+	APICommandCreateScene             APICommand = "create_scene"
+	APICommandGetScenes               APICommand = "get_scenes"
+	APICommandStoreScene              APICommand = "store_scene"
+	APICommandRecallScene             APICommand = "recall_scene"
+	APICommandRemoveScene             APICommand = "remove_scene"
+	APICommandGetEnergySummary        APICommand = "get_energy_summary"
+	APICommandParsePairingCode        APICommand = "parse_pairing_code"
+	APICommandGetCommissioningQueue   APICommand = "get_commissioning_queue"
+	APICommandCancelCommissioning     APICommand = "cancel_commissioning"
+	APICommandInjectFaults            APICommand = "inject_faults"
+	APICommandGetFirmwareInventory    APICommand = "get_firmware_inventory"
 )
 
 // VendorInfo contains vendor information from CSA
@@ -71,7 +135,176 @@ type MatterNodeData struct {
 	Available              bool                    `json:"available"`
 	IsBridge               bool                    `json:"is_bridge"`
 	Attributes             map[string]interface{}  `json:"attributes"`
+	AttributeNames         map[string]string       `json:"attribute_names,omitempty"`
 	AttributeSubscriptions []AttributeSubscription `json:"attribute_subscriptions"`
+	BridgedEndpoints       []BridgedEndpoint       `json:"bridged_endpoints,omitempty"`
+	// Fabric the node was commissioned into, one of ServerInfoMessage.Fabrics
+	FabricID int           `json:"fabric_id,omitempty"`
+	Metadata *NodeMetadata `json:"metadata,omitempty"`
+	// ICD Management info of intermittently connected devices
+	ICD *ICDInfo `json:"icd,omitempty"`
+	// Long idle time device that only listens after checking in. Sleepy
+	// nodes aren't probed and stay available while asleep.
+	Sleepy bool `json:"sleepy,omitempty"`
+}
+
+// ICDInfo describes an intermittently connected device
+type ICDInfo struct {
+	// "sit" (short idle time) or "lit" (long idle time)
+	OperatingMode       string `json:"operating_mode"`
+	IdleModeDuration    int    `json:"idle_mode_duration_s"`
+	ActiveModeDuration  int    `json:"active_mode_duration_ms"`
+	ActiveModeThreshold int    `json:"active_mode_threshold_ms"`
+	// Supports the Check-In protocol
+	CheckIn bool `json:"check_in"`
+	// The server is registered for the Check-In messages of the node
+	Registered  bool       `json:"registered"`
+	LastCheckIn *time.Time `json:"last_check_in,omitempty"`
+}
+
+// QueuedInteraction is a command or attribute write held back until a
+// sleeping node checks in. The result is reported by the
+// queued_interaction_completed event.
+type QueuedInteraction struct {
+	QueueID  string      `json:"queue_id"`
+	NodeID   int         `json:"node_id"`
+	Command  APICommand  `json:"command"`
+	QueuedAt time.Time   `json:"queued_at"`
+	Result   interface{} `json:"result,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+// States of a commissioning queue entry
+const (
+	CommissioningStateQueued        = "queued"
+	CommissioningStateCommissioning = "commissioning"
+	CommissioningStateRetrying      = "retrying"
+	CommissioningStateInterviewing  = "interviewing"
+	CommissioningStateCancelled     = "cancelled"
+)
+
+// CommissioningQueueEntry is a commissioning request waiting for or holding
+// one of the commissioning slots
+type CommissioningQueueEntry struct {
+	QueueID  string     `json:"queue_id"`
+	Command  APICommand `json:"command"`
+	FabricID int        `json:"fabric_id"`
+	State    string     `json:"state"`
+	// Position among the queued entries, starting at 1
+	Position int `json:"position,omitempty"`
+	// Node ID assigned once commissioning started
+	NodeID    int        `json:"node_id,omitempty"`
+	Attempt   int        `json:"attempt,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+	QueuedAt  time.Time  `json:"queued_at"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+}
+
+// NodeMetadata is what users assigned to a node, kept by the server
+type NodeMetadata struct {
+	// Friendly name, written to the NodeLabel of the device if it fits
+	Name string `json:"name,omitempty"`
+	// Area or room the node is in
+	Room string   `json:"room,omitempty"`
+	Tags []string `json:"tags,omitempty"`
+}
+
+// BridgedEndpoint represents a device exposed by a bridge (a child endpoint
+// listed in the PartsList of an Aggregator endpoint)
+type BridgedEndpoint struct {
+	EndpointID  int                    `json:"endpoint_id"`
+	DeviceTypes []int                  `json:"device_types"`
+	NodeLabel   string                 `json:"node_label,omitempty"`
+	Reachable   *bool                  `json:"reachable,omitempty"`
+	Attributes  map[string]interface{} `json:"attributes"`
+}
+
+// Device is an endpoint of a node classified as a standard Matter device
+// type, with a normalized state and the actions device_action accepts
+type Device struct {
+	NodeID       int    `json:"node_id"`
+	EndpointID   int    `json:"endpoint_id"`
+	DeviceType   string `json:"device_type"`
+	DeviceTypeID int    `json:"device_type_id"`
+	// Label of a bridged device
+	Name      string                 `json:"name,omitempty"`
+	Available bool                   `json:"available"`
+	State     map[string]interface{} `json:"state"`
+	Actions   []string               `json:"actions"`
+}
+
+// SceneMemberResult is the outcome of store_scene or recall_scene on a
+// member of a virtual scene
+type SceneMemberResult struct {
+	NodeID     int    `json:"node_id"`
+	EndpointID int    `json:"endpoint_id"`
+	Error      string `json:"error,omitempty"`
+}
+
+// EnergySummary is the power and energy of the nodes with the Electrical
+// Power and Energy Measurement clusters and their totals, see
+// get_energy_summary
+type EnergySummary struct {
+	Timestamp time.Time `json:"timestamp"`
+	// Active power of the available nodes in W
+	TotalPower float64 `json:"total_power_w"`
+	// Cumulative energy of all nodes in kWh
+	TotalEnergyImported float64      `json:"total_energy_imported_kwh"`
+	TotalEnergyExported float64      `json:"total_energy_exported_kwh"`
+	Nodes               []NodeEnergy `json:"nodes"`
+}
+
+// NodeEnergy is the power and energy of a node summed over its endpoints.
+// Values the node didn't report are null.
+type NodeEnergy struct {
+	NodeID         int      `json:"node_id"`
+	Available      bool     `json:"available"`
+	Power          *float64 `json:"power_w"`
+	EnergyImported *float64 `json:"energy_imported_kwh"`
+	EnergyExported *float64 `json:"energy_exported_kwh"`
+}
+
+// FirmwareInventory is the software versions running on the nodes and the
+// updates available for them, see get_firmware_inventory
+type FirmwareInventory struct {
+	Timestamp time.Time `json:"timestamp"`
+	// Number of nodes with an update available
+	UpdatesAvailable int               `json:"updates_available"`
+	Versions         []FirmwareVersion `json:"versions"`
+	Nodes            []NodeFirmware    `json:"nodes"`
+}
+
+// FirmwareVersion is a software version of a product and the nodes running it
+type FirmwareVersion struct {
+	VendorID              int    `json:"vendor_id"`
+	VendorName            string `json:"vendor_name"`
+	ProductID             int    `json:"product_id"`
+	ProductName           string `json:"product_name"`
+	SoftwareVersion       int    `json:"software_version"`
+	SoftwareVersionString string `json:"software_version_string"`
+	NodeIDs               []int  `json:"node_ids"`
+	// Newest update available for this version, null if there is none or
+	// the version wasn't checked yet
+	Update *MatterSoftwareVersion `json:"update"`
+}
+
+// NodeFirmware is the software version a node reports in its Basic
+// Information cluster and the update available for it
+type NodeFirmware struct {
+	NodeID                int    `json:"node_id"`
+	Available             bool   `json:"available"`
+	VendorID              int    `json:"vendor_id"`
+	VendorName            string `json:"vendor_name"`
+	ProductID             int    `json:"product_id"`
+	ProductName           string `json:"product_name"`
+	HardwareVersionString string `json:"hardware_version_string"`
+	SoftwareVersion       int    `json:"software_version"`
+	SoftwareVersionString string `json:"software_version_string"`
+	// Update is null if there is none or the node wasn't checked since it
+	// runs this version
+	UpdateAvailable bool                   `json:"update_available"`
+	Update          *MatterSoftwareVersion `json:"update"`
+	CheckedAt       *time.Time             `json:"checked_at"`
 }
 
 // AttributeSubscription represents an attribute subscription
@@ -81,6 +314,22 @@ type AttributeSubscription struct {
 	AttributeID *int `json:"attribute_id"`
 }
 
+// CommandProgress reports an intermediate stage of a long running command. It
+// is only sent to the connection that sent the command.
+type CommandProgress struct {
+	MessageID string `json:"message_id"`
+	Stage     string `json:"stage"`
+	Percent   int    `json:"percent"`
+}
+
+// ServerRestarting announces that the server is about to shut down. Commands
+// sent after it are rejected and connections are closed once in-flight
+// commands finished or the drain timeout passed.
+type ServerRestarting struct {
+	Reason         string `json:"reason"`
+	DrainTimeoutMs int64  `json:"drain_timeout_ms"`
+}
+
 // MatterNodeEvent represents a Matter node event
 type MatterNodeEvent struct {
 	NodeID        int                    `json:"node_id"`
@@ -96,14 +345,369 @@ type MatterNodeEvent struct {
 
 // ServerDiagnostics contains full server dump for diagnostics
 type ServerDiagnostics struct {
-	Info   ServerInfoMessage `json:"info"`
-	Nodes  []MatterNodeData  `json:"nodes"`
-	Events []interface{}     `json:"events"`
+	Info   ServerInfoMessage   `json:"info"`
+	Nodes  []*MatterNodeData   `json:"nodes"`
+	Events []EventHistoryEntry `json:"events"`
+	Clock  *ClockStatus        `json:"clock,omitempty"`
+
+	WebSocket *WebSocketStats  `json:"websocket,omitempty"`
+	Bluetooth *BluetoothStatus `json:"bluetooth,omitempty"`
+	Network   *NetworkInfo     `json:"network,omitempty"`
+
+	Subscriptions    []SubscriptionStatus   `json:"subscriptions"`
+	EventSubscribers []EventSubscriberStats `json:"event_subscribers"`
+	SupervisedTasks  []SupervisedTask       `json:"supervised_tasks"`
+	Panics           PanicStats             `json:"panics"`
+}
+
+// PanicStats counts the panics recovered by the server, which answered the
+// request or command with an internal error instead of crashing
+type PanicStats struct {
+	HTTP           uint64 `json:"http"`
+	WebSocket      uint64 `json:"websocket"`
+	EventCallbacks uint64 `json:"event_callbacks"`
+}
+
+// SupervisedTask is a long-running subsystem loop restarted by the server
+// when it fails or panics
+type SupervisedTask struct {
+	Name     string `json:"name"`
+	Running  bool   `json:"running"`
+	Restarts int    `json:"restarts"`
+
+	LastError     string     `json:"last_error,omitempty"`
+	LastFailureAt *time.Time `json:"last_failure_at,omitempty"`
+}
+
+// SubsystemRestart is sent with subsystem_restarted when a supervised task
+// failed and is restarted after the backoff
+type SubsystemRestart struct {
+	Name      string `json:"name"`
+	Error     string `json:"error"`
+	Restarts  int    `json:"restarts"`
+	BackoffMs int64  `json:"backoff_ms"`
+}
+
+// EventSubscriberStats is the event queue of an internal event subscriber,
+// e.g. the WebSocket handler or the MQTT bridge
+type EventSubscriberStats struct {
+	ID     string `json:"id"`
+	Queued int    `json:"queued"`
+	// Events dropped because the queue was full
+	Dropped uint64 `json:"dropped"`
+	// Panics of the callback, the event is skipped
+	Panics uint64 `json:"panics"`
+}
+
+// Power sources of nodes, selecting the report intervals of their
+// subscription
+const (
+	PowerSourceMains   = "mains"
+	PowerSourceBattery = "battery"
+)
+
+// SubscriptionStatus is the health of the subscription of a node
+type SubscriptionStatus struct {
+	NodeID      int    `json:"node_id"`
+	PowerSource string `json:"power_source"`
+	// EventsOnly is set when the controller can't subscribe to attributes
+	// and events at once, so only events are subscribed
+	EventsOnly bool `json:"events_only,omitempty"`
+
+	MinIntervalMs int64 `json:"min_interval_ms"`
+	// Maximum interval negotiated with the node
+	MaxIntervalMs int64 `json:"max_interval_ms"`
+
+	SubscribedAt time.Time  `json:"subscribed_at"`
+	LastReport   *time.Time `json:"last_report,omitempty"`
+	// Stale is set when no report, not even a keep-alive, arrived for twice
+	// the maximum interval
+	Stale bool `json:"stale"`
+}
+
+// EventHistoryEntry is an emitted event kept in the event history
+type EventHistoryEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Event     EventType `json:"event"`
+	// Node the event is about, 0 for server events
+	NodeID int             `json:"node_id,omitempty"`
+	Data   json.RawMessage `json:"data,omitempty"`
+}
+
+// LogEntry is a log entry of the server, as returned by get_logs and sent
+// with log_entry events
+type LogEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Level     string    `json:"level"`
+	// Module is the component that logged the entry, e.g. availability, or
+	// server for the server itself
+	Module  string                 `json:"module"`
+	Message string                 `json:"message"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+}
+
+// Outcomes of audited commands
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeError   = "error"
+)
+
+// AuditEntry records a state-changing command, as returned by get_audit_log
+type AuditEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Command   string    `json:"command"`
+	MessageID string    `json:"message_id,omitempty"`
+	// ConnectionID is the WebSocket connection, empty for REST requests
+	ConnectionID  string `json:"connection_id,omitempty"`
+	RemoteAddress string `json:"remote_address,omitempty"`
+	// Args are the command arguments with secrets such as setup codes and
+	// network credentials redacted
+	Args       map[string]interface{} `json:"args,omitempty"`
+	Outcome    string                 `json:"outcome"`
+	Error      string                 `json:"error,omitempty"`
+	DurationMs int64                  `json:"duration_ms"`
+}
+
+// AttributeSample is a recorded attribute value. Numeric values recorded
+// within one downsampling interval are averaged.
+type AttributeSample struct {
+	Timestamp time.Time   `json:"timestamp"`
+	Value     interface{} `json:"value"`
+	// Number of values averaged into the sample
+	Count int `json:"count"`
+}
+
+// AttributeHistory is the result of the get_attribute_history command
+type AttributeHistory struct {
+	NodeID        int               `json:"node_id"`
+	AttributePath string            `json:"attribute_path"`
+	Samples       []AttributeSample `json:"samples"`
+}
+
+// WebSocketStats contains backpressure counters of the WebSocket handler
+type WebSocketStats struct {
+	Connections int `json:"connections"`
+	// Connections whose send queue is at least half full
+	SlowConsumers           int    `json:"slow_consumers"`
+	QueuedMessages          int    `json:"queued_messages"`
+	CoalescedEvents         uint64 `json:"coalesced_events"`
+	DroppedEvents           uint64 `json:"dropped_events"`
+	SlowConsumerDisconnects uint64 `json:"slow_consumer_disconnects"`
+	RateLimitedCommands     uint64 `json:"rate_limited_commands"`
+	// Panics while reading messages or handling commands
+	Panics uint64 `json:"panics"`
+}
+
+// SessionInfo describes a connected WebSocket client
+type SessionInfo struct {
+	ID            string    `json:"id"`
+	RemoteAddress string    `json:"remote_address"`
+	ConnectedAt   time.Time `json:"connected_at"`
+	// Last pong received in response to the server's keepalive pings
+	LastPongAt    *time.Time     `json:"last_pong_at,omitempty"`
+	Codec         string         `json:"codec"`
+	Compressed    bool           `json:"compressed"`
+	SchemaVersion int            `json:"schema_version"`
+	Filter        *SessionFilter `json:"filter,omitempty"`
+
+	MessagesSent     uint64 `json:"messages_sent"`
+	MessagesDropped  uint64 `json:"messages_dropped"`
+	QueuedMessages   int    `json:"queued_messages"`
+	InFlightCommands int    `json:"in_flight_commands"`
+}
+
+// SessionFilter is the event filter a client set with start_listening
+type SessionFilter struct {
+	Events         []EventType `json:"events,omitempty"`
+	NodeIDs        []int       `json:"node_ids,omitempty"`
+	AttributePaths []string    `json:"attribute_paths,omitempty"`
+}
+
+// DisconnectSessionResult is the result of the disconnect_session command
+type DisconnectSessionResult struct {
+	SessionID    string `json:"session_id"`
+	Disconnected bool   `json:"disconnected"`
+}
+
+// FaultInjectionState is the result of the inject_faults command: the faults
+// injected from now on and the number injected so far
+type FaultInjectionState struct {
+	StorageWriteDelayMs       int64 `json:"storage_write_delay_ms"`
+	StorageWriteDropPercent   int   `json:"storage_write_drop_percent"`
+	InteractionFailurePercent int   `json:"interaction_failure_percent"`
+
+	DelayedWrites      uint64 `json:"delayed_writes"`
+	DroppedWrites      uint64 `json:"dropped_writes"`
+	FailedInteractions uint64 `json:"failed_interactions"`
+	// WebSocket connections severed by this command
+	SeveredConnections int `json:"severed_connections"`
+}
+
+// ClockStatus contains the result of the last system clock sanity check
+type ClockStatus struct {
+	CheckedAt time.Time `json:"checked_at"`
+	Healthy   bool      `json:"healthy"`
+	NTPServer string    `json:"ntp_server,omitempty"`
+	OffsetMs  int64     `json:"offset_ms"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+// Reasons Bluetooth is unavailable
+const (
+	BluetoothReasonDisabled         = "disabled"
+	BluetoothReasonBlueZUnavailable = "bluez_unavailable"
+	BluetoothReasonNoAdapter        = "no_adapter"
+	BluetoothReasonPoweredOff       = "powered_off"
+)
+
+// BluetoothStatus tells whether BLE commissioning is possible, and why not
+type BluetoothStatus struct {
+	Available bool `json:"available"`
+	// One of the BluetoothReason constants while unavailable
+	Reason string `json:"reason,omitempty"`
+	// Present configured adapters in order of preference
+	Adapters []BluetoothAdapter `json:"adapters"`
+}
+
+// BluetoothAdapter describes a Bluetooth adapter
+type BluetoothAdapter struct {
+	// ID is the adapter's interface name, e.g. "hci0"
+	ID          string `json:"id"`
+	Address     string `json:"address"`
+	Name        string `json:"name,omitempty"`
+	Powered     bool   `json:"powered"`
+	Discovering bool   `json:"discovering"`
+}
+
+// Sources of server settings
+const (
+	ServerSettingSourceConfig  = "config"
+	ServerSettingSourceRuntime = "runtime"
+)
+
+// ServerSetting is an option adjustable at runtime with set_server_setting
+type ServerSetting struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+	// Source is "runtime" for values set with set_server_setting, which take
+	// precedence over the configuration
+	Source string `json:"source"`
+	// ConfigValue applies again when the runtime value is reset
+	ConfigValue interface{} `json:"config_value"`
+}
+
+// LogLevelState is the log level after set_log_level
+type LogLevelState struct {
+	Level string `json:"level"`
+	// Until is when a temporary level reverts to RevertTo, the log.level
+	// setting
+	Until    *time.Time `json:"until,omitempty"`
+	RevertTo string     `json:"revert_to,omitempty"`
+}
+
+// Sources of the primary network interface
+const (
+	NetworkSourceConfig = "config"
+	NetworkSourceAuto   = "auto"
+	// No interface was usable, link-local traffic goes out on any interface
+	NetworkSourceNone = "none"
+)
+
+// NetworkInfo tells which interface link-local traffic and mDNS use, and why
+type NetworkInfo struct {
+	PrimaryInterface string `json:"primary_interface"`
+	// One of the NetworkSource constants
+	Source     string             `json:"source"`
+	Reason     string             `json:"reason"`
+	Interfaces []NetworkInterface `json:"interfaces"`
+}
+
+// NetworkInterface describes a network interface considered as primary
+// interface
+type NetworkInterface struct {
+	Name          string   `json:"name"`
+	Index         int      `json:"index"`
+	Addresses     []string `json:"addresses"`
+	DefaultRoute  bool     `json:"default_route"`
+	GlobalIPv6    bool     `json:"global_ipv6"`
+	LinkLocalIPv6 bool     `json:"link_local_ipv6"`
+	// Skipped tells why the interface can't be the primary one
+	Skipped string `json:"skipped,omitempty"`
+	// Score ranks the usable interfaces, the highest is chosen
+	Score int `json:"score"`
+}
+
+// HealthStatus is the body of the readiness probe
+type HealthStatus struct {
+	// Status is "ready", "not_ready" or "shutting_down"
+	Status     string                     `json:"status"`
+	Timestamp  time.Time                  `json:"timestamp"`
+	Subsystems map[string]SubsystemStatus `json:"subsystems"`
+}
+
+// SubsystemStatus is the readiness of a subsystem. Disabled subsystems are
+// always ready.
+type SubsystemStatus struct {
+	Enabled     bool       `json:"enabled"`
+	Ready       bool       `json:"ready"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+// SettingsExportVersion is the format version of SettingsExport
+const SettingsExportVersion = 1
+
+// SettingsExport is a portable dump of the stored nodes, vendors and
+// settings, returned by export_settings and accepted by import_settings
+type SettingsExport struct {
+	FormatVersion int                    `json:"format_version"`
+	ExportedAt    time.Time              `json:"exported_at"`
+	ServerVersion string                 `json:"server_version"`
+	Nodes         []*MatterNodeData      `json:"nodes"`
+	Vendors       []*VendorInfo          `json:"vendors"`
+	Settings      map[string]interface{} `json:"settings"`
+}
+
+// SettingsImportResult is the result of the import_settings command
+type SettingsImportResult struct {
+	Nodes        int   `json:"nodes"`
+	Vendors      int   `json:"vendors"`
+	Settings     int   `json:"settings"`
+	RemovedNodes []int `json:"removed_nodes"`
+}
+
+// ReplicationStatus is the result of the get_replication_status command
+type ReplicationStatus struct {
+	// primary, standby or disabled
+	Role string `json:"role"`
+	// Standbys connected to a primary
+	Standbys int `json:"standbys"`
+	// Primary URL of a standby
+	Primary string `json:"primary,omitempty"`
+	// Whether a standby is connected to the primary and received a
+	// snapshot since it started
+	Connected   bool       `json:"connected"`
+	Synced      bool       `json:"synced"`
+	LastContact *time.Time `json:"last_contact,omitempty"`
+	// Whether a standby took over from the primary
+	Promoted bool `json:"promoted"`
 }
 
 // NodePingResult contains ping results for a node
 type NodePingResult map[string]bool
 
+// NodeFabric describes a fabric a node is commissioned to, as reported by
+// the node's OperationalCredentials Fabrics attribute
+type NodeFabric struct {
+	FabricIndex   int    `json:"fabric_index"`
+	FabricID      uint64 `json:"fabric_id"`
+	VendorID      int    `json:"vendor_id"`
+	NodeID        uint64 `json:"node_id"`
+	Label         string `json:"label"`
+	RootPublicKey string `json:"root_public_key"`
+	IsOwnFabric   bool   `json:"is_own_fabric"`
+}
+
 // Message types for WebSocket communication
 
 // CommandMessage represents a command from client to server or vice versa
@@ -129,6 +733,93 @@ type ErrorResultMessage struct {
 	ResultMessageBase
 	ErrorCode int     `json:"error_code"`
 	Details   *string `json:"details,omitempty"`
+
+	// SupportedSchemaVersions is set when a schema version was rejected
+	SupportedSchemaVersions *SchemaVersionRange `json:"supported_schema_versions,omitempty"`
+
+	// CancelledBy is the message ID of the cancel command that cancelled
+	// the request
+	CancelledBy string `json:"cancelled_by,omitempty"`
+
+	// Field is the offending argument of an invalid arguments error
+	Field string `json:"field,omitempty"`
+}
+
+// ArgumentError is returned for command arguments that are missing or don't
+// match the command's schema
+type ArgumentError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ArgumentError) Error() string {
+	return fmt.Sprintf("invalid argument %s: %s", e.Field, e.Reason)
+}
+
+// ErrUnknownCommand is returned for commands the server doesn't handle
+var ErrUnknownCommand = errors.New("unknown command")
+
+// ErrStandby is returned for commands changing state on a standby, they have
+// to be sent to the primary
+var ErrStandby = errors.New("server is a replication standby")
+
+// ErrUnauthorized is returned for commands requiring a bearer token the
+// client didn't authenticate with
+var ErrUnauthorized = errors.New("invalid or missing bearer token")
+
+// ErrShuttingDown is returned for commands sent after the server started
+// shutting down
+var ErrShuttingDown = errors.New("server is shutting down")
+
+// ErrCommandTimeout is returned for commands that didn't finish within their
+// timeout, e.g. because a device didn't answer
+var ErrCommandTimeout = errors.New("command timed out")
+
+// NodeNotFoundError is returned for commands on nodes that don't exist
+type NodeNotFoundError struct {
+	NodeID int
+}
+
+func (e *NodeNotFoundError) Error() string {
+	return fmt.Sprintf("node %d not found", e.NodeID)
+}
+
+// Error codes sent in ErrorResultMessage
+const (
+	ErrorCodeInvalidMessage        = 400
+	ErrorCodeUnauthorized          = 401
+	ErrorCodeNotFound              = 404
+	ErrorCodeSchemaVersionMismatch = 406
+	ErrorCodeInvalidArguments      = 422
+	ErrorCodeRateLimited           = 429
+	ErrorCodeCancelled             = 499
+	ErrorCodeCommandFailed         = 500
+	ErrorCodeServerRestarting      = 503
+	ErrorCodeTimeout               = 504
+)
+
+// CancelResult is the result of the cancel command
+type CancelResult struct {
+	MessageID string `json:"message_id"`
+	Cancelled bool   `json:"cancelled"`
+}
+
+// Schema versions of the WebSocket API. Clients declare the version they
+// speak in start_listening; the server adapts messages to it.
+const (
+	SchemaVersion             = 11
+	MinSupportedSchemaVersion = 1
+)
+
+// CurrentInterviewVersion is the version of the node data an interview
+// produces, the data model schema version of python-matter-server. Nodes
+// with an older interview_version are re-interviewed.
+const CurrentInterviewVersion = 6
+
+// SchemaVersionRange is the range of schema versions the server supports
+type SchemaVersionRange struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
 }
 
 // EventMessage is sent for stateless events
@@ -140,13 +831,51 @@ type EventMessage struct {
 // ServerInfoMessage contains server information sent to clients
 type ServerInfoMessage struct {
 	FabricID                  int    `json:"fabric_id"`
-	CompressedFabricID        int64  `json:"compressed_fabric_id"`
+	CompressedFabricID        uint64 `json:"compressed_fabric_id"`
 	SchemaVersion             int    `json:"schema_version"`
 	MinSupportedSchemaVersion int    `json:"min_supported_schema_version"`
 	SDKVersion                string `json:"sdk_version"`
 	WiFiCredentialsSet        bool   `json:"wifi_credentials_set"`
 	ThreadCredentialsSet      bool   `json:"thread_credentials_set"`
 	BluetoothEnabled          bool   `json:"bluetooth_enabled"`
+	// Present Bluetooth adapters in order of preference, e.g. "hci0"
+	BluetoothAdapters []string `json:"bluetooth_adapters,omitempty"`
+	// Fabrics the server operates, the default fabric of FabricID first
+	Fabrics []ServerFabric `json:"fabrics,omitempty"`
+}
+
+// ServerFabric is a fabric the server commissions devices into
+type ServerFabric struct {
+	FabricID           int    `json:"fabric_id"`
+	VendorID           int    `json:"vendor_id"`
+	CompressedFabricID uint64 `json:"compressed_fabric_id"`
+	Label              string `json:"label,omitempty"`
+}
+
+// AddonInfo describes how to connect to the server and what it supports, so
+// supervisors and front-ends can adapt their UI without probing commands
+type AddonInfo struct {
+	SchemaVersion             int    `json:"schema_version"`
+	MinSupportedSchemaVersion int    `json:"min_supported_schema_version"`
+	SDKVersion                string `json:"sdk_version"`
+	// Path and port of the WebSocket API
+	WebSocketPath string `json:"websocket_path"`
+	Port          int    `json:"port"`
+	// Port of the gRPC API, 0 if disabled
+	GRPCPort int `json:"grpc_port,omitempty"`
+	// TLS tells whether clients must connect with TLS (wss://)
+	TLS      bool           `json:"tls"`
+	Features ServerFeatures `json:"features"`
+}
+
+// ServerFeatures are the optional capabilities of the server
+type ServerFeatures struct {
+	// Commissioning over Bluetooth LE is configured
+	Bluetooth bool `json:"bluetooth"`
+	// Thread credentials are set for commissioning Thread devices
+	Thread bool `json:"thread"`
+	// An OTA provider directory serves software updates
+	OTA bool `json:"ota"`
 }
 
 // CommissionableNodeData represents a discovered commissionable node
@@ -167,6 +896,9 @@ type CommissionableNodeData struct {
 	SupportsTCP            *bool    `json:"supports_tcp,omitempty"`
 	Addresses              []string `json:"addresses,omitempty"`
 	RotatingID             *string  `json:"rotating_id,omitempty"`
+	// Set for devices found with a BLE scan
+	BLEAddress *string `json:"ble_address,omitempty"`
+	RSSI       *int    `json:"rssi,omitempty"`
 }
 
 // CommissioningParameters contains commissioning parameters
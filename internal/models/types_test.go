@@ -21,6 +21,11 @@ func TestEventType(t *testing.T) {
 		{"ServerInfoUpdated", EventTypeServerInfoUpdated, "server_info_updated"},
 		{"EndpointAdded", EventTypeEndpointAdded, "endpoint_added"},
 		{"EndpointRemoved", EventTypeEndpointRemoved, "endpoint_removed"},
+		{"ServerRestarting", EventTypeServerRestarting, "server_restarting"},
+		{"ScanResult", EventTypeScanResult, "scan_result"},
+		{"BluetoothStatusChanged", EventTypeBluetoothStatusChanged, "bluetooth_status_changed"},
+		{"SubsystemRestarted", EventTypeSubsystemRestarted, "subsystem_restarted"},
+		{"LogEntry", EventTypeLogEntry, "log_entry"},
 	}
 
 	for _, tt := range tests {
@@ -52,6 +57,7 @@ func TestAPICommand(t *testing.T) {
 		{"InterviewNode", APICommandInterviewNode, "interview_node"},
 		{"DeviceCommand", APICommandDeviceCommand, "device_command"},
 		{"RemoveNode", APICommandRemoveNode, "remove_node"},
+		{"GetAuditLog", APICommandGetAuditLog, "get_audit_log"},
 		{"GetVendorNames", APICommandGetVendorNames, "get_vendor_names"},
 		{"ReadAttribute", APICommandReadAttribute, "read_attribute"},
 		{"WriteAttribute", APICommandWriteAttribute, "write_attribute"},
@@ -0,0 +1,520 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/audit"
+	"github.com/codefionn/go-matter-server/internal/clusters"
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// Payloads of the status and availability topics
+const (
+	payloadOnline  = "online"
+	payloadOffline = "offline"
+)
+
+// Topic levels of the Home Assistant friendly set topics of an endpoint
+const (
+	topicOnOff      = "on_off"
+	topicBrightness = "brightness"
+)
+
+// maxLevel is the largest CurrentLevel of LevelControl
+const maxLevel = 254
+
+// Server is the part of the Matter server the bridge uses
+type Server interface {
+	HandleCommand(ctx context.Context, cmd models.CommandMessage) (interface{}, error)
+	Subscribe(callback models.EventCallback) func()
+}
+
+// Config holds the configuration of the MQTT bridge
+type Config struct {
+	// Broker URL, see ClientConfig
+	Broker    string
+	ClientID  string
+	Username  string
+	Password  string
+	KeepAlive time.Duration
+	// TopicPrefix is the first level of the topics of the bridge
+	TopicPrefix string
+	// Discovery publishes Home Assistant MQTT discovery payloads under
+	// DiscoveryPrefix
+	Discovery       bool
+	DiscoveryPrefix string
+	Logger          *logger.Logger
+}
+
+// Bridge publishes the attributes, availability and events of the nodes to
+// an MQTT broker and runs the commands received on its command topics:
+//
+//	<prefix>/status                                       online or offline
+//	<prefix>/<node>/available                             online or offline
+//	<prefix>/<node>/<endpoint>/<cluster>/<attribute>      JSON value
+//	<prefix>/<node>/event                                 node_event data
+//	<prefix>/<node>/<endpoint>/<cluster>/command/<name>   device_command, JSON payload
+//	<prefix>/<node>/<endpoint>/<cluster>/<attribute>/set  write_attribute, JSON value
+//	<prefix>/<node>/<endpoint>/on_off/set                 ON, OFF or TOGGLE
+//	<prefix>/<node>/<endpoint>/brightness/set             level 0-254
+//
+// Status, availability and attributes are retained. The outcome of a
+// command is published to its topic with /result appended.
+type Bridge struct {
+	config Config
+	server Server
+	client *Client
+	logger *logger.Logger
+
+	ctx         context.Context
+	cancel      context.CancelFunc
+	unsubscribe func()
+	messageID   atomic.Uint64
+
+	// published holds the retained payloads published per node by topic
+	mu        sync.Mutex
+	published map[int]map[string]string
+}
+
+// NewBridge creates an MQTT bridge for a server
+func NewBridge(server Server, config Config) (*Bridge, error) {
+	if config.TopicPrefix == "" {
+		return nil, errors.New("MQTT topic prefix is required")
+	}
+	if config.Logger == nil {
+		config.Logger = logger.NewConsoleLogger(logger.InfoLevel)
+	}
+
+	b := &Bridge{
+		config:    config,
+		server:    server,
+		logger:    config.Logger,
+		published: make(map[int]map[string]string),
+	}
+	client, err := NewClient(ClientConfig{
+		Broker:      config.Broker,
+		ClientID:    config.ClientID,
+		Username:    config.Username,
+		Password:    config.Password,
+		KeepAlive:   config.KeepAlive,
+		WillTopic:   b.statusTopic(),
+		WillPayload: []byte(payloadOffline),
+		OnConnect:   b.resync,
+		Logger:      config.Logger,
+	})
+	if err != nil {
+		return nil, err
+	}
+	b.client = client
+
+	prefix := config.TopicPrefix
+	client.Subscribe(prefix+"/+/+/+/command/+", b.handleDeviceCommand)
+	client.Subscribe(prefix+"/+/+/+/+/set", b.handleWriteAttribute)
+	client.Subscribe(prefix+"/+/+/+/set", b.handleSet)
+	return b, nil
+}
+
+// Start connects to the broker and publishes the nodes and their changes
+func (b *Bridge) Start(ctx context.Context) {
+	b.ctx, b.cancel = context.WithCancel(ctx)
+	b.unsubscribe = b.server.Subscribe(b.handleEvent)
+	b.client.Start()
+}
+
+// Shutdown marks the server offline and disconnects from the broker
+func (b *Bridge) Shutdown() error {
+	if b.cancel == nil {
+		return nil
+	}
+	b.unsubscribe()
+	b.cancel()
+	if err := b.client.Publish(b.statusTopic(), []byte(payloadOffline), true); err != nil && !errors.Is(err, ErrNotConnected) {
+		b.logger.Warn("Failed to publish MQTT status", logger.ErrorField(err))
+	}
+	return b.client.Shutdown()
+}
+
+// Connected reports whether the bridge is connected to the broker
+func (b *Bridge) Connected() bool {
+	return b.client.Connected()
+}
+
+func (b *Bridge) statusTopic() string {
+	return b.config.TopicPrefix + "/status"
+}
+
+func (b *Bridge) availableTopic(nodeID int) string {
+	return fmt.Sprintf("%s/%d/available", b.config.TopicPrefix, nodeID)
+}
+
+func (b *Bridge) attributeTopic(nodeID int, path string) string {
+	return fmt.Sprintf("%s/%d/%s", b.config.TopicPrefix, nodeID, path)
+}
+
+func (b *Bridge) eventTopic(nodeID int) string {
+	return fmt.Sprintf("%s/%d/event", b.config.TopicPrefix, nodeID)
+}
+
+func (b *Bridge) setTopic(nodeID, endpoint int, name string) string {
+	return fmt.Sprintf("%s/%d/%d/%s/set", b.config.TopicPrefix, nodeID, endpoint, name)
+}
+
+// uniqueID identifies a node in Home Assistant, telling bridges with other
+// prefixes apart
+func (b *Bridge) uniqueID(nodeID int) string {
+	return fmt.Sprintf("%s_%d", strings.ReplaceAll(b.config.TopicPrefix, "/", "_"), nodeID)
+}
+
+// resync publishes the state of all nodes after connecting, as the broker
+// may have lost the retained messages
+func (b *Bridge) resync() {
+	if err := b.client.Publish(b.statusTopic(), []byte(payloadOnline), true); err != nil {
+		b.logger.Warn("Failed to publish MQTT status", logger.ErrorField(err))
+		return
+	}
+
+	result, err := b.server.HandleCommand(b.ctx, models.CommandMessage{
+		MessageID: b.nextMessageID(),
+		Command:   string(models.APICommandGetNodes),
+	})
+	var nodes []*models.MatterNodeData
+	if err == nil {
+		err = decode(result, &nodes)
+	}
+	if err != nil {
+		b.logger.Error("Failed to get nodes for MQTT", logger.ErrorField(err))
+		return
+	}
+
+	current := make(map[int]bool, len(nodes))
+	for _, node := range nodes {
+		current[node.NodeID] = true
+		b.publishNode(node, true)
+	}
+
+	b.mu.Lock()
+	var removed []int
+	for nodeID := range b.published {
+		if !current[nodeID] {
+			removed = append(removed, nodeID)
+		}
+	}
+	b.mu.Unlock()
+	for _, nodeID := range removed {
+		b.removeNode(nodeID)
+	}
+}
+
+// handleEvent publishes the changes of an event
+func (b *Bridge) handleEvent(eventType models.EventType, data interface{}) {
+	if !b.client.Connected() {
+		// Published by resync after connecting
+		return
+	}
+
+	switch eventType {
+	case models.EventTypeNodeAdded, models.EventTypeNodeUpdated:
+		var node models.MatterNodeData
+		if err := decode(data, &node); err == nil {
+			b.publishNode(&node, false)
+		}
+	case models.EventTypeNodeRemoved:
+		var nodeID int
+		if err := decode(data, &nodeID); err == nil {
+			b.removeNode(nodeID)
+		}
+	case models.EventTypeAttributeUpdated:
+		// [node_id, attribute_path, value]
+		var update []json.RawMessage
+		var nodeID int
+		var path string
+		if decode(data, &update) != nil || len(update) != 3 ||
+			json.Unmarshal(update[0], &nodeID) != nil || json.Unmarshal(update[1], &path) != nil {
+			return
+		}
+		b.publishAttribute(nodeID, path, string(update[2]))
+	case models.EventTypeNodeEvent:
+		var event models.MatterNodeEvent
+		if err := decode(data, &event); err != nil {
+			return
+		}
+		payload, _ := json.Marshal(event)
+		if err := b.client.Publish(b.eventTopic(event.NodeID), payload, false); err != nil {
+			b.logger.Debug("Failed to publish node event to MQTT", logger.ErrorField(err))
+		}
+	}
+}
+
+// publishNode publishes the retained topics of a node that changed, or all
+// of them if force is set, and clears those the node no longer has
+func (b *Bridge) publishNode(node *models.MatterNodeData, force bool) {
+	topics := make(map[string]string)
+	topics[b.availableTopic(node.NodeID)] = payloadOffline
+	if node.Available {
+		topics[b.availableTopic(node.NodeID)] = payloadOnline
+	}
+	for path, value := range node.Attributes {
+		payload, err := json.Marshal(value)
+		if err != nil {
+			continue
+		}
+		topics[b.attributeTopic(node.NodeID, path)] = string(payload)
+	}
+	if b.config.Discovery {
+		for _, entity := range b.discoveryEntities(node) {
+			payload, err := json.Marshal(entity.config)
+			if err != nil {
+				continue
+			}
+			topics[entity.topic] = string(payload)
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	published := b.published[node.NodeID]
+	if published == nil {
+		published = make(map[string]string)
+		b.published[node.NodeID] = published
+	}
+
+	// States go first, so entities find them when discovered
+	for _, topic := range sortedTopics(topics, b.config.DiscoveryPrefix) {
+		payload := topics[topic]
+		if previous, ok := published[topic]; ok && previous == payload && !force {
+			continue
+		}
+		if err := b.client.Publish(topic, []byte(payload), true); err != nil {
+			b.logger.Debug("Failed to publish node to MQTT", logger.Int("node_id", node.NodeID), logger.ErrorField(err))
+			return
+		}
+		published[topic] = payload
+	}
+	for topic := range published {
+		if _, ok := topics[topic]; ok {
+			continue
+		}
+		if err := b.client.Publish(topic, nil, true); err != nil {
+			return
+		}
+		delete(published, topic)
+	}
+}
+
+// publishAttribute publishes an updated attribute of a published node
+func (b *Bridge) publishAttribute(nodeID int, path, payload string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	published, ok := b.published[nodeID]
+	if !ok {
+		return
+	}
+	topic := b.attributeTopic(nodeID, path)
+	if published[topic] == payload {
+		return
+	}
+	if err := b.client.Publish(topic, []byte(payload), true); err != nil {
+		b.logger.Debug("Failed to publish attribute to MQTT", logger.Int("node_id", nodeID), logger.ErrorField(err))
+		return
+	}
+	published[topic] = payload
+}
+
+// removeNode clears the retained topics of a removed node, which also
+// removes its entities from Home Assistant
+func (b *Bridge) removeNode(nodeID int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	published := b.published[nodeID]
+	for topic := range published {
+		if err := b.client.Publish(topic, nil, true); err != nil {
+			return
+		}
+		delete(published, topic)
+	}
+	delete(b.published, nodeID)
+}
+
+// sortedTopics returns the topics in order, with discovery topics last
+func sortedTopics(topics map[string]string, discoveryPrefix string) []string {
+	sorted := make([]string, 0, len(topics))
+	for topic := range topics {
+		sorted = append(sorted, topic)
+	}
+	isDiscovery := func(topic string) bool {
+		return discoveryPrefix != "" && strings.HasPrefix(topic, discoveryPrefix+"/")
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if isDiscovery(sorted[i]) != isDiscovery(sorted[j]) {
+			return !isDiscovery(sorted[i])
+		}
+		return sorted[i] < sorted[j]
+	})
+	return sorted
+}
+
+// handleDeviceCommand runs device_command for
+// <prefix>/<node>/<endpoint>/<cluster>/command/<name>
+func (b *Bridge) handleDeviceCommand(topic string, payload []byte) {
+	levels := b.topicLevels(topic)
+	nodeID, errNode := strconv.Atoi(levels[0])
+	endpoint, errEndpoint := strconv.Atoi(levels[1])
+	if errNode != nil || errEndpoint != nil {
+		return
+	}
+	args := map[string]interface{}{
+		"node_id":      float64(nodeID),
+		"endpoint_id":  float64(endpoint),
+		"cluster_id":   idOrName(levels[2]),
+		"command_name": levels[4],
+	}
+	if len(payload) > 0 {
+		var fields map[string]interface{}
+		if err := json.Unmarshal(payload, &fields); err != nil {
+			b.publishResult(topic, nil, fmt.Errorf("payload must be a JSON object: %w", err))
+			return
+		}
+		args["payload"] = fields
+	}
+	go b.runCommand(topic, models.APICommandDeviceCommand, args)
+}
+
+// handleWriteAttribute runs write_attribute for
+// <prefix>/<node>/<endpoint>/<cluster>/<attribute>/set
+func (b *Bridge) handleWriteAttribute(topic string, payload []byte) {
+	levels := b.topicLevels(topic)
+	nodeID, err := strconv.Atoi(levels[0])
+	if err != nil || levels[3] == "command" {
+		// .../command/set is a command named set
+		return
+	}
+	// Plain text that isn't JSON is written as a string
+	var value interface{}
+	if err := json.Unmarshal(payload, &value); err != nil {
+		value = string(payload)
+	}
+	go b.runCommand(topic, models.APICommandWriteAttribute, map[string]interface{}{
+		"node_id":        float64(nodeID),
+		"attribute_path": strings.Join(levels[1:4], "/"),
+		"value":          value,
+	})
+}
+
+// handleSet runs the commands of the Home Assistant friendly topics
+// <prefix>/<node>/<endpoint>/on_off/set and .../brightness/set
+func (b *Bridge) handleSet(topic string, payload []byte) {
+	levels := b.topicLevels(topic)
+	nodeID, errNode := strconv.Atoi(levels[0])
+	endpoint, errEndpoint := strconv.Atoi(levels[1])
+	if errNode != nil || errEndpoint != nil {
+		return
+	}
+	args := map[string]interface{}{
+		"node_id":     float64(nodeID),
+		"endpoint_id": float64(endpoint),
+	}
+
+	value := strings.TrimSpace(string(payload))
+	switch levels[2] {
+	case topicOnOff:
+		args["cluster_id"] = float64(clusters.OnOffClusterID)
+		switch strings.ToUpper(value) {
+		case "ON":
+			args["command_name"] = "On"
+		case "OFF":
+			args["command_name"] = "Off"
+		case "TOGGLE":
+			args["command_name"] = "Toggle"
+		default:
+			b.publishResult(topic, nil, fmt.Errorf("invalid state %q, expected ON, OFF or TOGGLE", value))
+			return
+		}
+	case topicBrightness:
+		level, err := strconv.Atoi(value)
+		if err != nil || level < 0 || level > maxLevel {
+			b.publishResult(topic, nil, fmt.Errorf("invalid brightness %q, expected 0-%d", value, maxLevel))
+			return
+		}
+		args["cluster_id"] = float64(clusters.LevelControlClusterID)
+		args["command_name"] = "MoveToLevelWithOnOff"
+		args["payload"] = map[string]interface{}{
+			"level":           float64(level),
+			"transitionTime":  float64(0),
+			"optionsMask":     float64(0),
+			"optionsOverride": float64(0),
+		}
+	default:
+		return
+	}
+	go b.runCommand(topic, models.APICommandDeviceCommand, args)
+}
+
+// runCommand runs a command received on a topic and publishes its outcome
+func (b *Bridge) runCommand(topic string, command models.APICommand, args map[string]interface{}) {
+	ctx := audit.WithClient(b.ctx, audit.Client{ConnectionID: "mqtt", RemoteAddress: b.client.address})
+	result, err := b.server.HandleCommand(ctx, models.CommandMessage{
+		MessageID: b.nextMessageID(),
+		Command:   string(command),
+		Args:      args,
+	})
+	if err != nil {
+		b.logger.Warn("MQTT command failed",
+			logger.String("topic", topic),
+			logger.String("command", string(command)),
+			logger.ErrorField(err),
+		)
+	}
+	b.publishResult(topic, result, err)
+}
+
+// publishResult publishes the outcome of a command to <topic>/result
+func (b *Bridge) publishResult(topic string, result interface{}, err error) {
+	outcome := map[string]interface{}{"result": result}
+	if err != nil {
+		outcome = map[string]interface{}{"error": err.Error()}
+	}
+	payload, marshalErr := json.Marshal(outcome)
+	if marshalErr != nil {
+		payload, _ = json.Marshal(map[string]interface{}{"error": marshalErr.Error()})
+	}
+	if err := b.client.Publish(topic+"/result", payload, false); err != nil {
+		b.logger.Debug("Failed to publish MQTT command result", logger.ErrorField(err))
+	}
+}
+
+// topicLevels returns the levels of a topic after the prefix
+func (b *Bridge) topicLevels(topic string) []string {
+	return strings.Split(strings.TrimPrefix(topic, b.config.TopicPrefix+"/"), "/")
+}
+
+func (b *Bridge) nextMessageID() string {
+	return fmt.Sprintf("mqtt-%d", b.messageID.Add(1))
+}
+
+// idOrName passes numeric IDs as numbers and names as they are
+func idOrName(level string) interface{} {
+	if id, err := strconv.Atoi(level); err == nil {
+		return float64(id)
+	}
+	return level
+}
+
+// decode converts event data and command results, which the server passes
+// as its own types, by their JSON encoding
+func decode(data interface{}, v interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
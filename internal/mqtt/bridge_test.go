@@ -0,0 +1,167 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// bridgeTestServer has a dimmable light as node 5
+type bridgeTestServer struct {
+	mu        sync.Mutex
+	callbacks []models.EventCallback
+	commands  chan models.CommandMessage
+}
+
+func (s *bridgeTestServer) HandleCommand(ctx context.Context, cmd models.CommandMessage) (interface{}, error) {
+	if cmd.Command == string(models.APICommandGetNodes) {
+		return []*models.MatterNodeData{{
+			NodeID:    5,
+			Available: true,
+			Attributes: map[string]interface{}{
+				"0/40/1": "ACME",
+				"0/40/3": "Dimmer",
+				"1/6/0":  true,
+				"1/8/0":  float64(200),
+			},
+			Metadata: &models.NodeMetadata{Room: "Living room"},
+		}}, nil
+	}
+	s.commands <- cmd
+	return nil, nil
+}
+
+func (s *bridgeTestServer) Subscribe(callback models.EventCallback) func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.callbacks = append(s.callbacks, callback)
+	return func() {}
+}
+
+func (s *bridgeTestServer) emit(eventType models.EventType, data interface{}) {
+	s.mu.Lock()
+	callbacks := s.callbacks
+	s.mu.Unlock()
+	for _, callback := range callbacks {
+		callback(eventType, data)
+	}
+}
+
+func TestBridge(t *testing.T) {
+	broker := newTestBroker(t)
+	server := &bridgeTestServer{commands: make(chan models.CommandMessage, 10)}
+	bridge, err := NewBridge(server, Config{
+		Broker:          broker.url(),
+		ClientID:        "matter-server",
+		TopicPrefix:     "matter",
+		Discovery:       true,
+		DiscoveryPrefix: "homeassistant",
+		Logger:          logger.NewConsoleLogger(logger.FatalLevel),
+	})
+	if err != nil {
+		t.Fatalf("NewBridge failed: %v", err)
+	}
+	bridge.Start(context.Background())
+
+	// The node is published once connected, discovery last
+	config := broker.waitFor("homeassistant/light/matter_5/1_light/config")
+	for topic, expected := range map[string]string{
+		"matter/status":      "online",
+		"matter/5/available": "online",
+		"matter/5/1/6/0":     "true",
+		"matter/5/1/8/0":     "200",
+		"matter/5/0/40/3":    `"Dimmer"`,
+	} {
+		if payload, ok := broker.getRetained(topic); !ok || payload != expected {
+			t.Errorf("Expected %s to be %s, got %q", topic, expected, payload)
+		}
+	}
+
+	var light discoveryConfig
+	if err := json.Unmarshal(config.payload, &light); err != nil {
+		t.Fatalf("Invalid discovery config: %v", err)
+	}
+	if light.UniqueID != "matter_5_1_light" || light.Device.Name != "Dimmer" || light.Device.Manufacturer != "ACME" ||
+		light.Device.SuggestedArea != "Living room" {
+		t.Errorf("Unexpected entity %+v", light)
+	}
+	if light.StateTopic != "matter/5/1/6/0" || light.CommandTopic != "matter/5/1/on_off/set" ||
+		light.BrightnessCommandTopic != "matter/5/1/brightness/set" || light.BrightnessScale != 254 {
+		t.Errorf("Unexpected light topics %+v", light)
+	}
+	if len(light.Availability) != 2 || light.Availability[1].Topic != "matter/5/available" {
+		t.Errorf("Expected the node availability, got %+v", light.Availability)
+	}
+
+	// Only changed attributes are published
+	server.emit(models.EventTypeAttributeUpdated, []interface{}{5, "1/8/0", 200})
+	server.emit(models.EventTypeAttributeUpdated, []interface{}{5, "1/8/0", 100})
+	if msg := broker.waitFor("matter/5/1/8/0"); string(msg.payload) != "100" {
+		t.Errorf("Expected level 100, got %s", msg.payload)
+	}
+
+	// Commands
+	broker.send("matter/5/1/on_off/set", "OFF", 1)
+	cmd := <-server.commands
+	if cmd.Command != "device_command" || cmd.Args["command_name"] != "Off" || cmd.Args["cluster_id"] != float64(6) || cmd.Args["endpoint_id"] != float64(1) {
+		t.Errorf("Unexpected command %+v", cmd)
+	}
+	if msg := broker.waitFor("matter/5/1/on_off/set/result"); string(msg.payload) != `{"result":null}` {
+		t.Errorf("Unexpected result %s", msg.payload)
+	}
+
+	broker.send("matter/5/1/brightness/set", "128", 2)
+	cmd = <-server.commands
+	payload, _ := cmd.Args["payload"].(map[string]interface{})
+	if cmd.Args["command_name"] != "MoveToLevelWithOnOff" || payload["level"] != float64(128) {
+		t.Errorf("Unexpected command %+v", cmd)
+	}
+	broker.waitFor("matter/5/1/brightness/set/result")
+
+	broker.send("matter/5/1/LevelControl/command/Move", `{"moveMode": 0, "rate": 10}`, 3)
+	cmd = <-server.commands
+	payload, _ = cmd.Args["payload"].(map[string]interface{})
+	if cmd.Args["cluster_id"] != "LevelControl" || cmd.Args["command_name"] != "Move" || payload["rate"] != float64(10) {
+		t.Errorf("Unexpected command %+v", cmd)
+	}
+
+	broker.send("matter/5/1/6/16384/set", "5", 4)
+	cmd = <-server.commands
+	if cmd.Command != "write_attribute" || cmd.Args["attribute_path"] != "1/6/16384" || cmd.Args["value"] != float64(5) {
+		t.Errorf("Unexpected command %+v", cmd)
+	}
+
+	broker.send("matter/5/1/brightness/set", "300", 5)
+	if msg := broker.waitFor("matter/5/1/brightness/set/result"); string(msg.payload) != `{"error":"invalid brightness \"300\", expected 0-254"}` {
+		t.Errorf("Unexpected result %s", msg.payload)
+	}
+
+	// Removing the node clears its retained topics and entities
+	server.emit(models.EventTypeNodeRemoved, 5)
+	broker.waitFor("homeassistant/light/matter_5/1_light/config")
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		broker.mu.Lock()
+		remaining := len(broker.retained)
+		broker.mu.Unlock()
+		if remaining == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected only the status to be retained, got %d topics", remaining)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := bridge.Shutdown(); err != nil {
+		t.Errorf("Shutdown failed: %v", err)
+	}
+	if msg := broker.waitFor("matter/status"); string(msg.payload) != "offline" || !msg.retain {
+		t.Errorf("Expected status offline after shutdown, got %q", msg.payload)
+	}
+}
@@ -0,0 +1,392 @@
+package mqtt
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+)
+
+// Timing of the connection, variables so tests can shorten them
+var (
+	dialTimeout      = 10 * time.Second
+	connackTimeout   = 10 * time.Second
+	minReconnect     = time.Second
+	maxReconnect     = time.Minute
+	defaultKeepAlive = time.Minute
+)
+
+// ErrNotConnected is returned when publishing while the client is
+// disconnected from the broker
+var ErrNotConnected = errors.New("not connected to the MQTT broker")
+
+// Handler receives the messages of a subscription
+type Handler func(topic string, payload []byte)
+
+// ClientConfig holds the configuration of an MQTT client
+type ClientConfig struct {
+	// Broker URL, tcp:// or mqtt:// for plain connections and ssl://,
+	// tls:// or mqtts:// for TLS. The port defaults to 1883 or 8883.
+	Broker   string
+	ClientID string
+	Username string
+	Password string
+	// KeepAlive is the longest time without packets before the broker and
+	// the client consider the connection dead
+	KeepAlive time.Duration
+	// WillTopic receives the retained WillPayload from the broker when the
+	// connection is lost without a DISCONNECT
+	WillTopic   string
+	WillPayload []byte
+	// OnConnect is called after each (re)connection, once the subscriptions
+	// were requested
+	OnConnect func()
+	Logger    *logger.Logger
+}
+
+type subscription struct {
+	filter  string
+	handler Handler
+}
+
+// Client is an MQTT 3.1.1 client that stays connected to a broker,
+// reconnecting with backoff and renewing its subscriptions. Messages are
+// published with QoS 0, subscriptions use QoS 1.
+type Client struct {
+	config  ClientConfig
+	logger  *logger.Logger
+	address string
+	tls     *tls.Config
+
+	subMu         sync.Mutex
+	subscriptions []subscription
+
+	connMu    sync.Mutex
+	conn      net.Conn
+	writeMu   sync.Mutex
+	connected atomic.Bool
+	packetID  atomic.Uint32
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// ParseBroker returns the address and whether to use TLS for a broker URL
+func ParseBroker(broker string) (string, bool, error) {
+	u, err := url.Parse(broker)
+	if err != nil {
+		return "", false, err
+	}
+	var useTLS bool
+	port := "1883"
+	switch u.Scheme {
+	case "tcp", "mqtt":
+	case "ssl", "tls", "mqtts":
+		useTLS, port = true, "8883"
+	default:
+		return "", false, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return "", false, errors.New("missing host")
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	return net.JoinHostPort(u.Hostname(), port), useTLS, nil
+}
+
+// NewClient creates an MQTT client
+func NewClient(config ClientConfig) (*Client, error) {
+	address, useTLS, err := ParseBroker(config.Broker)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MQTT broker %q: %w", config.Broker, err)
+	}
+	if config.ClientID == "" {
+		return nil, errors.New("MQTT client ID is required")
+	}
+	if config.KeepAlive <= 0 {
+		config.KeepAlive = defaultKeepAlive
+	}
+	if config.Logger == nil {
+		config.Logger = logger.NewConsoleLogger(logger.InfoLevel)
+	}
+
+	c := &Client{config: config, logger: config.Logger, address: address}
+	if useTLS {
+		host, _, _ := net.SplitHostPort(address)
+		c.tls = &tls.Config{ServerName: host}
+	}
+	return c, nil
+}
+
+// Subscribe registers a handler for a topic filter. Subscriptions are made
+// on each connection, so they must be registered before Start. Handlers run
+// on the receiving goroutine and must not block.
+func (c *Client) Subscribe(filter string, handler Handler) {
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+	c.subscriptions = append(c.subscriptions, subscription{filter: filter, handler: handler})
+}
+
+// Start connects to the broker in the background
+func (c *Client) Start() {
+	c.stop = make(chan struct{})
+	c.done = make(chan struct{})
+	go c.run()
+}
+
+// Shutdown disconnects from the broker. The will isn't published.
+func (c *Client) Shutdown() error {
+	if c.stop == nil {
+		return nil
+	}
+	close(c.stop)
+
+	c.connMu.Lock()
+	var err error
+	if c.conn != nil {
+		err = c.write(packet{kind: packetDisconnect})
+		c.conn.Close()
+	}
+	c.connMu.Unlock()
+
+	<-c.done
+	c.stop = nil
+	return err
+}
+
+// Connected reports whether the client is connected to the broker
+func (c *Client) Connected() bool {
+	return c.connected.Load()
+}
+
+// Publish sends a message with QoS 0
+func (c *Client) Publish(topic string, payload []byte, retain bool) error {
+	if !c.connected.Load() {
+		return ErrNotConnected
+	}
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	if c.conn == nil {
+		return ErrNotConnected
+	}
+	return c.write(publishPacket(message{topic: topic, payload: payload, retain: retain}))
+}
+
+// write sends a packet, the caller holds connMu
+func (c *Client) write(p packet) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(c.config.KeepAlive))
+	_, err := c.conn.Write(p.encode())
+	return err
+}
+
+// writeConn sends a packet on the current connection
+func (c *Client) writeConn(p packet) error {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	if c.conn == nil {
+		return ErrNotConnected
+	}
+	return c.write(p)
+}
+
+func (c *Client) run() {
+	defer close(c.done)
+
+	backoff := minReconnect
+	for {
+		started := time.Now()
+		err := c.session()
+		select {
+		case <-c.stop:
+			return
+		default:
+		}
+
+		// A connection that lasted resets the backoff
+		if time.Since(started) > maxReconnect {
+			backoff = minReconnect
+		}
+		c.logger.Warn("MQTT connection lost, reconnecting",
+			logger.String("broker", c.address),
+			logger.Duration("retry_in", backoff),
+			logger.ErrorField(err),
+		)
+		select {
+		case <-c.stop:
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxReconnect)
+	}
+}
+
+// session connects to the broker and handles its packets until the
+// connection fails
+func (c *Client) session() error {
+	conn, reader, err := c.connect()
+	if err != nil {
+		return err
+	}
+	c.connMu.Lock()
+	select {
+	case <-c.stop:
+		c.connMu.Unlock()
+		conn.Close()
+		return nil
+	default:
+	}
+	c.conn = conn
+	c.connMu.Unlock()
+	defer func() {
+		c.connected.Store(false)
+		c.connMu.Lock()
+		c.conn.Close()
+		c.conn = nil
+		c.connMu.Unlock()
+	}()
+
+	filters := c.filters()
+	subscribeID := c.nextPacketID()
+	if len(filters) > 0 {
+		if err := c.writeConn(subscribePacket(subscribeID, filters)); err != nil {
+			return err
+		}
+	}
+	c.connected.Store(true)
+	c.logger.Info("Connected to MQTT broker", logger.String("broker", c.address))
+	if c.config.OnConnect != nil {
+		go c.config.OnConnect()
+	}
+
+	pings := make(chan struct{})
+	defer close(pings)
+	go c.keepAlive(pings)
+
+	for {
+		// The broker answers pings within the keep alive interval
+		conn.SetReadDeadline(time.Now().Add(c.config.KeepAlive * 3 / 2))
+		p, err := readPacket(reader)
+		if err != nil {
+			return err
+		}
+
+		switch p.kind {
+		case packetPublish:
+			msg, err := parsePublish(p)
+			if err != nil {
+				return err
+			}
+			if msg.qos == 1 {
+				if err := c.writeConn(pubackPacket(msg.packetID)); err != nil {
+					return err
+				}
+			}
+			c.deliver(msg)
+		case packetSuback:
+			for _, filter := range parseSuback(p, filters) {
+				c.logger.Error("MQTT broker refused subscription", logger.String("filter", filter))
+			}
+		case packetPingresp, packetPuback:
+		default:
+			return fmt.Errorf("unexpected packet type %d", p.kind)
+		}
+	}
+}
+
+// connect opens a connection and completes the CONNECT handshake
+func (c *Client) connect() (net.Conn, *bufio.Reader, error) {
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	var conn net.Conn
+	var err error
+	if c.tls != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", c.address, c.tls)
+	} else {
+		conn, err = dialer.Dial("tcp", c.address)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opts := connectOptions{
+		clientID:  c.config.ClientID,
+		username:  c.config.Username,
+		password:  c.config.Password,
+		keepAlive: uint16(min(c.config.KeepAlive/time.Second, 0xFFFF)),
+	}
+	if c.config.WillTopic != "" {
+		opts.will = &message{topic: c.config.WillTopic, payload: c.config.WillPayload, qos: 1, retain: true}
+	}
+	conn.SetDeadline(time.Now().Add(connackTimeout))
+	if _, err := conn.Write(connectPacket(opts).encode()); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	reader := bufio.NewReader(conn)
+	p, err := readPacket(reader)
+	if err == nil {
+		err = parseConnack(p)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, reader, nil
+}
+
+// keepAlive pings the broker until pings is closed
+func (c *Client) keepAlive(pings chan struct{}) {
+	ticker := time.NewTicker(c.config.KeepAlive / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-pings:
+			return
+		case <-ticker.C:
+			if err := c.writeConn(packet{kind: packetPingreq}); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (c *Client) filters() []string {
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+	filters := make([]string, len(c.subscriptions))
+	for i, sub := range c.subscriptions {
+		filters[i] = sub.filter
+	}
+	return filters
+}
+
+// deliver passes a message to the handlers of the matching subscriptions
+func (c *Client) deliver(msg message) {
+	c.subMu.Lock()
+	subscriptions := c.subscriptions
+	c.subMu.Unlock()
+	for _, sub := range subscriptions {
+		if matchTopic(sub.filter, msg.topic) {
+			sub.handler(msg.topic, msg.payload)
+		}
+	}
+}
+
+// nextPacketID returns a non-zero packet identifier
+func (c *Client) nextPacketID() uint16 {
+	for {
+		if id := uint16(c.packetID.Add(1)); id != 0 {
+			return id
+		}
+	}
+}
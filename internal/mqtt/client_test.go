@@ -0,0 +1,234 @@
+package mqtt
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+)
+
+// testBroker is a broker accepting one client at a time. It records the
+// subscriptions and retained messages and lets tests publish to the client.
+type testBroker struct {
+	t        *testing.T
+	listener net.Listener
+
+	mu            sync.Mutex
+	conn          net.Conn
+	retained      map[string]string
+	subscriptions []string
+
+	connects  chan string
+	published chan message
+	acked     chan uint16
+}
+
+func newTestBroker(t *testing.T) *testBroker {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	b := &testBroker{
+		t:         t,
+		listener:  listener,
+		retained:  make(map[string]string),
+		connects:  make(chan string, 10),
+		published: make(chan message, 1000),
+		acked:     make(chan uint16, 10),
+	}
+	t.Cleanup(func() {
+		listener.Close()
+		b.disconnect()
+	})
+	go b.accept()
+	return b
+}
+
+func (b *testBroker) url() string {
+	return "tcp://" + b.listener.Addr().String()
+}
+
+func (b *testBroker) accept() {
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		b.mu.Lock()
+		b.conn = conn
+		b.mu.Unlock()
+		go b.serve(conn)
+	}
+}
+
+func (b *testBroker) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		p, err := readPacket(reader)
+		if err != nil {
+			return
+		}
+		switch p.kind {
+		case packetConnect:
+			// The client ID follows the 10 byte variable header
+			clientID, _, _ := readString(p.body[10:])
+			conn.Write(packet{kind: packetConnack, body: []byte{0, connectAccepted}}.encode())
+			b.connects <- clientID
+		case packetSubscribe:
+			rest := p.body[2:]
+			var codes []byte
+			for len(rest) > 0 {
+				filter, next, _ := readString(rest)
+				rest = next[1:]
+				b.mu.Lock()
+				b.subscriptions = append(b.subscriptions, filter)
+				b.mu.Unlock()
+				codes = append(codes, 1)
+			}
+			conn.Write(packet{kind: packetSuback, body: append(p.body[:2:2], codes...)}.encode())
+		case packetPublish:
+			msg, _ := parsePublish(p)
+			if msg.retain {
+				b.mu.Lock()
+				if len(msg.payload) == 0 {
+					delete(b.retained, msg.topic)
+				} else {
+					b.retained[msg.topic] = string(msg.payload)
+				}
+				b.mu.Unlock()
+			}
+			b.published <- msg
+		case packetPuback:
+			b.acked <- uint16(p.body[0])<<8 | uint16(p.body[1])
+		case packetPingreq:
+			conn.Write(packet{kind: packetPingresp}.encode())
+		case packetDisconnect:
+			return
+		}
+	}
+}
+
+// send publishes a message to the client with QoS 1
+func (b *testBroker) send(topic, payload string, packetID uint16) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.conn.Write(publishPacket(message{topic: topic, payload: []byte(payload), qos: 1, packetID: packetID}).encode())
+}
+
+// disconnect drops the connection of the client
+func (b *testBroker) disconnect() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.conn != nil {
+		b.conn.Close()
+	}
+}
+
+func (b *testBroker) getRetained(topic string) (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	payload, ok := b.retained[topic]
+	return payload, ok
+}
+
+// waitFor waits for a message published to a topic
+func (b *testBroker) waitFor(topic string) message {
+	b.t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case msg := <-b.published:
+			if msg.topic == topic {
+				return msg
+			}
+		case <-timeout:
+			b.t.Fatalf("Timed out waiting for a message on %s", topic)
+		}
+	}
+}
+
+func TestClientReconnect(t *testing.T) {
+	broker := newTestBroker(t)
+	reconnect := minReconnect
+	minReconnect = 10 * time.Millisecond
+	t.Cleanup(func() { minReconnect = reconnect })
+
+	connected := make(chan struct{}, 10)
+	received := make(chan string, 10)
+	client, err := NewClient(ClientConfig{
+		Broker:    broker.url(),
+		ClientID:  "test-client",
+		OnConnect: func() { connected <- struct{}{} },
+		Logger:    logger.NewConsoleLogger(logger.FatalLevel),
+	})
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	client.Subscribe("matter/+/command", func(topic string, payload []byte) {
+		received <- topic + " " + string(payload)
+	})
+	client.Start()
+	defer client.Shutdown()
+
+	for round := 0; round < 2; round++ {
+		select {
+		case id := <-broker.connects:
+			if id != "test-client" {
+				t.Errorf("Expected client ID test-client, got %q", id)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Round %d: client didn't connect", round)
+		}
+		select {
+		case <-connected:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Round %d: OnConnect wasn't called", round)
+		}
+
+		broker.send("matter/5/command", "on", 7)
+		select {
+		case msg := <-received:
+			if msg != "matter/5/command on" {
+				t.Errorf("Unexpected message %q", msg)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Round %d: message wasn't delivered", round)
+		}
+		if id := <-broker.acked; id != 7 {
+			t.Errorf("Expected PUBACK for packet 7, got %d", id)
+		}
+
+		if err := client.Publish("matter/status", []byte("online"), true); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+		broker.waitFor("matter/status")
+
+		broker.disconnect()
+	}
+
+	broker.mu.Lock()
+	subscriptions := strings.Join(broker.subscriptions, " ")
+	broker.mu.Unlock()
+	if subscriptions != "matter/+/command matter/+/command" {
+		t.Errorf("Expected the subscription to be renewed, got %q", subscriptions)
+	}
+}
+
+func TestClientPublishDisconnected(t *testing.T) {
+	client, err := NewClient(ClientConfig{Broker: "tcp://127.0.0.1:1", ClientID: "test"})
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	if err := client.Publish("matter/status", nil, true); err != ErrNotConnected {
+		t.Errorf("Expected ErrNotConnected, got %v", err)
+	}
+	if _, err := NewClient(ClientConfig{Broker: "tcp://localhost"}); err == nil {
+		t.Error("Expected an error without client ID")
+	}
+}
@@ -0,0 +1,214 @@
+package mqtt
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/codefionn/go-matter-server/internal/clusters"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// Basic Information attributes describing the device in Home Assistant
+const (
+	basicInformationVendorName      = 1
+	basicInformationProductName     = 3
+	basicInformationNodeLabel       = 5
+	basicInformationSoftwareVersion = 10
+	basicInformationSerialNumber    = 15
+)
+
+// discoveryDevice is the device of Home Assistant MQTT discovery, grouping
+// the entities of a node
+type discoveryDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer,omitempty"`
+	Model        string   `json:"model,omitempty"`
+	SWVersion    string   `json:"sw_version,omitempty"`
+	SerialNumber string   `json:"serial_number,omitempty"`
+	// Room of the node metadata
+	SuggestedArea string `json:"suggested_area,omitempty"`
+}
+
+// discoveryAvailability is a topic telling whether an entity is available
+type discoveryAvailability struct {
+	Topic string `json:"topic"`
+}
+
+// discoveryConfig is the config payload of an entity in Home Assistant MQTT
+// discovery
+type discoveryConfig struct {
+	Name              string                  `json:"name"`
+	UniqueID          string                  `json:"unique_id"`
+	Device            discoveryDevice         `json:"device"`
+	Availability      []discoveryAvailability `json:"availability"`
+	AvailabilityMode  string                  `json:"availability_mode"`
+	DeviceClass       string                  `json:"device_class,omitempty"`
+	StateClass        string                  `json:"state_class,omitempty"`
+	UnitOfMeasurement string                  `json:"unit_of_measurement,omitempty"`
+
+	StateTopic         string `json:"state_topic,omitempty"`
+	ValueTemplate      string `json:"value_template,omitempty"`
+	StateValueTemplate string `json:"state_value_template,omitempty"`
+	CommandTopic       string `json:"command_topic,omitempty"`
+
+	BrightnessStateTopic    string `json:"brightness_state_topic,omitempty"`
+	BrightnessCommandTopic  string `json:"brightness_command_topic,omitempty"`
+	BrightnessScale         int    `json:"brightness_scale,omitempty"`
+	BrightnessValueTemplate string `json:"brightness_value_template,omitempty"`
+	OnCommandType           string `json:"on_command_type,omitempty"`
+}
+
+// discoveryEntity is an entity published for a node, keyed by the topic of
+// its config
+type discoveryEntity struct {
+	topic  string
+	config discoveryConfig
+}
+
+// sensorKind describes a measurement cluster exposed as a sensor
+type sensorKind struct {
+	cluster       int
+	component     string
+	key           string
+	name          string
+	deviceClass   string
+	unit          string
+	valueTemplate string
+}
+
+// sensorKinds are the clusters exposed as sensors and binary sensors,
+// reading their first attribute
+var sensorKinds = []sensorKind{
+	{clusters.TemperatureMeasurementClusterID, "sensor", "temperature", "Temperature", "temperature", "°C", "{{ value_json / 100 }}"},
+	{clusters.RelativeHumidityMeasurementClusterID, "sensor", "humidity", "Humidity", "humidity", "%", "{{ value_json / 100 }}"},
+	{clusters.PressureMeasurementClusterID, "sensor", "pressure", "Pressure", "pressure", "hPa", "{{ value_json }}"},
+	// MeasuredValue is 10000 * log10(lux) + 1
+	{clusters.IlluminanceMeasurementClusterID, "sensor", "illuminance", "Illuminance", "illuminance", "lx", "{{ (10 ** ((value_json - 1) / 10000)) | round(1) }}"},
+	{clusters.OccupancySensingClusterID, "binary_sensor", "occupancy", "Occupancy", "occupancy", "", "{{ 'ON' if value_json % 2 == 1 else 'OFF' }}"},
+	{clusters.BooleanStateClusterID, "binary_sensor", "state", "State", "", "", "{{ 'ON' if value_json else 'OFF' }}"},
+}
+
+// onOffTemplate turns the OnOff attribute into the states of Home Assistant
+const onOffTemplate = "{{ 'ON' if value_json else 'OFF' }}"
+
+// discoveryEntities returns the Home Assistant entities of the clusters of
+// a node that have a known representation: lights and switches for OnOff,
+// with brightness for LevelControl, and sensors for measurements
+func (b *Bridge) discoveryEntities(node *models.MatterNodeData) []discoveryEntity {
+	device := discoveryDevice{
+		Identifiers: []string{b.uniqueID(node.NodeID)},
+		Name:        nodeName(node),
+	}
+	device.Manufacturer, _ = node.Attributes[attributePath(0, clusters.BasicInformationClusterID, basicInformationVendorName)].(string)
+	device.Model, _ = node.Attributes[attributePath(0, clusters.BasicInformationClusterID, basicInformationProductName)].(string)
+	device.SWVersion, _ = node.Attributes[attributePath(0, clusters.BasicInformationClusterID, basicInformationSoftwareVersion)].(string)
+	device.SerialNumber, _ = node.Attributes[attributePath(0, clusters.BasicInformationClusterID, basicInformationSerialNumber)].(string)
+	if node.Metadata != nil {
+		device.SuggestedArea = node.Metadata.Room
+	}
+
+	availability := []discoveryAvailability{
+		{Topic: b.statusTopic()},
+		{Topic: b.availableTopic(node.NodeID)},
+	}
+	newEntity := func(component string, endpoint int, key, name string) discoveryEntity {
+		if endpoint != 1 {
+			name = fmt.Sprintf("%s %d", name, endpoint)
+		}
+		objectID := fmt.Sprintf("%d_%s", endpoint, key)
+		return discoveryEntity{
+			topic: fmt.Sprintf("%s/%s/%s/%s/config", b.config.DiscoveryPrefix, component, b.uniqueID(node.NodeID), objectID),
+			config: discoveryConfig{
+				Name:             name,
+				UniqueID:         b.uniqueID(node.NodeID) + "_" + objectID,
+				Device:           device,
+				Availability:     availability,
+				AvailabilityMode: "all",
+			},
+		}
+	}
+
+	var entities []discoveryEntity
+	for _, endpoint := range nodeEndpoints(node) {
+		onOff := attributePath(endpoint, clusters.OnOffClusterID, 0)
+		level := attributePath(endpoint, clusters.LevelControlClusterID, 0)
+		if _, ok := node.Attributes[onOff]; ok {
+			if _, dimmable := node.Attributes[level]; dimmable {
+				entity := newEntity("light", endpoint, "light", "Light")
+				entity.config.StateTopic = b.attributeTopic(node.NodeID, onOff)
+				entity.config.StateValueTemplate = onOffTemplate
+				entity.config.CommandTopic = b.setTopic(node.NodeID, endpoint, topicOnOff)
+				entity.config.BrightnessStateTopic = b.attributeTopic(node.NodeID, level)
+				entity.config.BrightnessCommandTopic = b.setTopic(node.NodeID, endpoint, topicBrightness)
+				entity.config.BrightnessScale = maxLevel
+				entity.config.BrightnessValueTemplate = "{{ value_json }}"
+				entity.config.OnCommandType = "brightness"
+				entities = append(entities, entity)
+			} else {
+				entity := newEntity("switch", endpoint, "switch", "Switch")
+				entity.config.StateTopic = b.attributeTopic(node.NodeID, onOff)
+				entity.config.ValueTemplate = onOffTemplate
+				entity.config.CommandTopic = b.setTopic(node.NodeID, endpoint, topicOnOff)
+				entities = append(entities, entity)
+			}
+		}
+
+		for _, kind := range sensorKinds {
+			path := attributePath(endpoint, kind.cluster, 0)
+			if _, ok := node.Attributes[path]; !ok {
+				continue
+			}
+			entity := newEntity(kind.component, endpoint, kind.key, kind.name)
+			entity.config.StateTopic = b.attributeTopic(node.NodeID, path)
+			entity.config.ValueTemplate = kind.valueTemplate
+			entity.config.DeviceClass = kind.deviceClass
+			entity.config.UnitOfMeasurement = kind.unit
+			if kind.component == "sensor" {
+				entity.config.StateClass = "measurement"
+			}
+			entities = append(entities, entity)
+		}
+	}
+	return entities
+}
+
+// nodeName returns the name the user gave a node, else its label, else its
+// product name
+func nodeName(node *models.MatterNodeData) string {
+	if node.Metadata != nil && node.Metadata.Name != "" {
+		return node.Metadata.Name
+	}
+	for _, id := range []int{basicInformationNodeLabel, basicInformationProductName} {
+		if name, _ := node.Attributes[attributePath(0, clusters.BasicInformationClusterID, id)].(string); name != "" {
+			return name
+		}
+	}
+	return fmt.Sprintf("Matter node %d", node.NodeID)
+}
+
+// nodeEndpoints returns the endpoints of a node's attributes in order
+func nodeEndpoints(node *models.MatterNodeData) []int {
+	seen := make(map[int]bool)
+	var endpoints []int
+	for path := range node.Attributes {
+		endpoint, _, ok := strings.Cut(path, "/")
+		if !ok {
+			continue
+		}
+		id, err := strconv.Atoi(endpoint)
+		if err != nil || seen[id] {
+			continue
+		}
+		seen[id] = true
+		endpoints = append(endpoints, id)
+	}
+	sort.Ints(endpoints)
+	return endpoints
+}
+
+func attributePath(endpoint, cluster, attribute int) string {
+	return fmt.Sprintf("%d/%d/%d", endpoint, cluster, attribute)
+}
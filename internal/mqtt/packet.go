@@ -0,0 +1,261 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// MQTT 3.1.1 control packet types
+const (
+	packetConnect    = 1
+	packetConnack    = 2
+	packetPublish    = 3
+	packetPuback     = 4
+	packetSubscribe  = 8
+	packetSuback     = 9
+	packetPingreq    = 12
+	packetPingresp   = 13
+	packetDisconnect = 14
+
+	protocolLevel311  = 4
+	connectAccepted   = 0
+	subscribeFailure  = 0x80
+	publishFlagRetain = 0x01
+)
+
+// CONNECT flags
+const (
+	connectCleanSession = 0x02
+	connectWill         = 0x04
+	connectWillQoS1     = 0x08
+	connectWillRetain   = 0x20
+	connectPassword     = 0x40
+	connectUsername     = 0x80
+)
+
+// connackReasons are the return codes of a refused connection
+var connackReasons = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "client identifier rejected",
+	3: "server unavailable",
+	4: "bad user name or password",
+	5: "not authorized",
+}
+
+// packet is a control packet with its fixed header split off
+type packet struct {
+	kind  byte
+	flags byte
+	body  []byte
+}
+
+// message is a PUBLISH packet
+type message struct {
+	topic    string
+	payload  []byte
+	qos      byte
+	retain   bool
+	packetID uint16
+}
+
+// connectOptions are the fields of a CONNECT packet
+type connectOptions struct {
+	clientID  string
+	username  string
+	password  string
+	keepAlive uint16
+	will      *message
+}
+
+// readPacket reads the next control packet
+func readPacket(r *bufio.Reader) (packet, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return packet{}, err
+	}
+
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return packet{}, err
+		}
+		length += int(b&0x7F) * multiplier
+		if b&0x80 == 0 {
+			break
+		}
+		if i == 3 {
+			return packet{}, errors.New("malformed remaining length")
+		}
+		multiplier *= 128
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return packet{}, err
+	}
+	return packet{kind: header >> 4, flags: header & 0x0F, body: body}, nil
+}
+
+// encode returns the packet with its fixed header
+func (p packet) encode() []byte {
+	buf := []byte{p.kind<<4 | p.flags}
+	length := len(p.body)
+	for {
+		b := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			b |= 0x80
+		}
+		buf = append(buf, b)
+		if length == 0 {
+			break
+		}
+	}
+	return append(buf, p.body...)
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(s)))
+	return append(buf, s...)
+}
+
+func readString(body []byte) (string, []byte, error) {
+	if len(body) < 2 {
+		return "", nil, errors.New("truncated string")
+	}
+	n := int(binary.BigEndian.Uint16(body))
+	if len(body) < 2+n {
+		return "", nil, errors.New("truncated string")
+	}
+	return string(body[2 : 2+n]), body[2+n:], nil
+}
+
+func connectPacket(opts connectOptions) packet {
+	flags := byte(connectCleanSession)
+	body := appendString(nil, "MQTT")
+	body = append(body, protocolLevel311, 0)
+	body = binary.BigEndian.AppendUint16(body, opts.keepAlive)
+
+	body = appendString(body, opts.clientID)
+	if opts.will != nil {
+		flags |= connectWill
+		if opts.will.qos == 1 {
+			flags |= connectWillQoS1
+		}
+		if opts.will.retain {
+			flags |= connectWillRetain
+		}
+		body = appendString(body, opts.will.topic)
+		body = appendString(body, string(opts.will.payload))
+	}
+	if opts.username != "" {
+		flags |= connectUsername
+		body = appendString(body, opts.username)
+	}
+	if opts.password != "" {
+		flags |= connectPassword
+		body = appendString(body, opts.password)
+	}
+	body[7] = flags
+	return packet{kind: packetConnect, body: body}
+}
+
+// parseConnack returns an error if the broker refused the connection
+func parseConnack(p packet) error {
+	if p.kind != packetConnack || len(p.body) != 2 {
+		return fmt.Errorf("expected CONNACK, got packet type %d", p.kind)
+	}
+	if code := p.body[1]; code != connectAccepted {
+		reason, ok := connackReasons[code]
+		if !ok {
+			reason = fmt.Sprintf("return code %d", code)
+		}
+		return fmt.Errorf("connection refused: %s", reason)
+	}
+	return nil
+}
+
+func publishPacket(msg message) packet {
+	flags := msg.qos << 1
+	if msg.retain {
+		flags |= publishFlagRetain
+	}
+	body := appendString(nil, msg.topic)
+	if msg.qos > 0 {
+		body = binary.BigEndian.AppendUint16(body, msg.packetID)
+	}
+	return packet{kind: packetPublish, flags: flags, body: append(body, msg.payload...)}
+}
+
+func parsePublish(p packet) (message, error) {
+	msg := message{qos: (p.flags >> 1) & 0x03, retain: p.flags&publishFlagRetain != 0}
+	if msg.qos > 1 {
+		return message{}, fmt.Errorf("unsupported QoS %d", msg.qos)
+	}
+	topic, rest, err := readString(p.body)
+	if err != nil {
+		return message{}, err
+	}
+	msg.topic = topic
+	if msg.qos > 0 {
+		if len(rest) < 2 {
+			return message{}, errors.New("truncated packet identifier")
+		}
+		msg.packetID = binary.BigEndian.Uint16(rest)
+		rest = rest[2:]
+	}
+	msg.payload = rest
+	return msg, nil
+}
+
+func pubackPacket(packetID uint16) packet {
+	return packet{kind: packetPuback, body: binary.BigEndian.AppendUint16(nil, packetID)}
+}
+
+// subscribePacket subscribes to topic filters with QoS 1
+func subscribePacket(packetID uint16, filters []string) packet {
+	body := binary.BigEndian.AppendUint16(nil, packetID)
+	for _, filter := range filters {
+		body = appendString(body, filter)
+		body = append(body, 1)
+	}
+	return packet{kind: packetSubscribe, flags: 0x02, body: body}
+}
+
+// parseSuback returns the filters the broker refused
+func parseSuback(p packet, filters []string) []string {
+	var refused []string
+	if len(p.body) < 2 {
+		return filters
+	}
+	for i, code := range p.body[2:] {
+		if code == subscribeFailure && i < len(filters) {
+			refused = append(refused, filters[i])
+		}
+	}
+	return refused
+}
+
+// matchTopic reports whether a topic matches a filter with the + and #
+// wildcards
+func matchTopic(filter, topic string) bool {
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+	for i, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+		if i >= len(topicLevels) {
+			return false
+		}
+		if level != "+" && level != topicLevels[i] {
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
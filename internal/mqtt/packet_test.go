@@ -0,0 +1,143 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+)
+
+func TestPacketRemainingLength(t *testing.T) {
+	for _, size := range []int{0, 127, 128, 16383, 16384, 2097152} {
+		p := packet{kind: packetPublish, flags: 0x03, body: bytes.Repeat([]byte{0xAB}, size)}
+		encoded := p.encode()
+
+		decoded, err := readPacket(bufio.NewReader(bytes.NewReader(encoded)))
+		if err != nil {
+			t.Fatalf("Size %d: %v", size, err)
+		}
+		if decoded.kind != p.kind || decoded.flags != p.flags || !bytes.Equal(decoded.body, p.body) {
+			t.Errorf("Size %d: round trip changed the packet", size)
+		}
+	}
+
+	malformed := []byte{packetPublish << 4, 0xFF, 0xFF, 0xFF, 0xFF, 0x01}
+	if _, err := readPacket(bufio.NewReader(bytes.NewReader(malformed))); err == nil {
+		t.Error("Expected an error for a remaining length of five bytes")
+	}
+}
+
+func TestPublishPacket(t *testing.T) {
+	tests := []message{
+		{topic: "matter/5/1/6/0", payload: []byte("true"), retain: true},
+		{topic: "matter/5/available", payload: nil},
+		{topic: "matter/5/1/6/command/On", payload: []byte("{}"), qos: 1, packetID: 42},
+	}
+	for _, msg := range tests {
+		parsed, err := parsePublish(publishPacket(msg))
+		if err != nil {
+			t.Fatalf("%s: %v", msg.topic, err)
+		}
+		if parsed.topic != msg.topic || !bytes.Equal(parsed.payload, msg.payload) ||
+			parsed.qos != msg.qos || parsed.retain != msg.retain || parsed.packetID != msg.packetID {
+			t.Errorf("Expected %+v, got %+v", msg, parsed)
+		}
+	}
+}
+
+func TestConnectPacket(t *testing.T) {
+	p := connectPacket(connectOptions{
+		clientID:  "matter-server",
+		username:  "user",
+		password:  "secret",
+		keepAlive: 60,
+		will:      &message{topic: "matter/status", payload: []byte("offline"), qos: 1, retain: true},
+	})
+
+	expected := []byte{0, 4, 'M', 'Q', 'T', 'T', protocolLevel311,
+		connectUsername | connectPassword | connectWillRetain | connectWillQoS1 | connectWill | connectCleanSession,
+		0, 60}
+	if !bytes.HasPrefix(p.body, expected) {
+		t.Fatalf("Unexpected variable header % x", p.body[:10])
+	}
+
+	var fields []string
+	rest := p.body[len(expected):]
+	for len(rest) > 0 {
+		field, next, err := readString(rest)
+		if err != nil {
+			t.Fatalf("Invalid payload: %v", err)
+		}
+		fields = append(fields, field)
+		rest = next
+	}
+	want := []string{"matter-server", "matter/status", "offline", "user", "secret"}
+	if len(fields) != len(want) {
+		t.Fatalf("Expected fields %q, got %q", want, fields)
+	}
+	for i := range want {
+		if fields[i] != want[i] {
+			t.Errorf("Expected fields %q, got %q", want, fields)
+			break
+		}
+	}
+}
+
+func TestParseConnack(t *testing.T) {
+	if err := parseConnack(packet{kind: packetConnack, body: []byte{0, 0}}); err != nil {
+		t.Errorf("Expected the connection to be accepted: %v", err)
+	}
+	err := parseConnack(packet{kind: packetConnack, body: []byte{0, 4}})
+	if err == nil || err.Error() != "connection refused: bad user name or password" {
+		t.Errorf("Expected bad credentials, got %v", err)
+	}
+	if err := parseConnack(packet{kind: packetSuback, body: []byte{0, 1, 1}}); err == nil {
+		t.Error("Expected an error for a packet other than CONNACK")
+	}
+}
+
+func TestMatchTopic(t *testing.T) {
+	tests := []struct {
+		filter, topic string
+		match         bool
+	}{
+		{"matter/+/+/+/command/+", "matter/5/1/6/command/On", true},
+		{"matter/+/+/+/command/+", "matter/5/1/6/command/On/result", false},
+		{"matter/+/+/+/set", "matter/5/1/on_off/set", true},
+		{"matter/+/+/+/set", "matter/5/1/6/0/set", false},
+		{"matter/#", "matter/5/available", true},
+		{"matter/#", "matter", true},
+		{"matter/status", "matter/status", true},
+		{"matter/status", "other/status", false},
+	}
+	for _, tt := range tests {
+		if match := matchTopic(tt.filter, tt.topic); match != tt.match {
+			t.Errorf("matchTopic(%q, %q) = %v, expected %v", tt.filter, tt.topic, match, tt.match)
+		}
+	}
+}
+
+func TestParseBroker(t *testing.T) {
+	tests := []struct {
+		broker  string
+		address string
+		tls     bool
+		valid   bool
+	}{
+		{"tcp://localhost", "localhost:1883", false, true},
+		{"mqtt://broker.local:1884", "broker.local:1884", false, true},
+		{"mqtts://broker.local", "broker.local:8883", true, true},
+		{"ssl://[fd00::1]:8884", "[fd00::1]:8884", true, true},
+		{"http://broker.local", "", false, false},
+		{"tcp://", "", false, false},
+	}
+	for _, tt := range tests {
+		address, useTLS, err := ParseBroker(tt.broker)
+		if (err == nil) != tt.valid {
+			t.Errorf("ParseBroker(%q) error = %v, expected valid %v", tt.broker, err, tt.valid)
+			continue
+		}
+		if address != tt.address || useTLS != tt.tls {
+			t.Errorf("ParseBroker(%q) = %q, %v, expected %q, %v", tt.broker, address, useTLS, tt.address, tt.tls)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+// Package netif picks the network interface for link-local IPv6 traffic and
+// mDNS when none is configured. Thread devices behind a border router are
+// often only reachable that way, and a link-local address without the right
+// interface doesn't reach them.
+package netif
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// Routing tables and interfaces of Linux. Variables so tests can replace
+// them.
+var (
+	ipv4RouteFile = "/proc/net/route"
+	ipv6RouteFile = "/proc/net/ipv6_route"
+	sysClassNet   = "/sys/class/net"
+)
+
+// virtualPrefixes mark interfaces of containers, VMs and VPNs, which don't
+// face the network the devices are on
+var virtualPrefixes = []string{
+	"docker", "veth", "br-", "virbr", "cni", "flannel", "cali", "podman",
+	"lxcbr", "lxdbr", "vmnet", "vboxnet", "tailscale", "zt", "wg", "tun",
+}
+
+// Scores of the properties of an interface. A default route outweighs a
+// global address.
+const (
+	scoreDefaultRoute = 4
+	scoreGlobalIPv6   = 2
+)
+
+// Interface is a network interface of the host
+type Interface struct {
+	Name      string
+	Index     int
+	Flags     net.Flags
+	Addresses []net.IP
+	// DefaultRoute is set if an IPv4 or IPv6 default route goes through the
+	// interface
+	DefaultRoute bool
+	Bridge       bool
+}
+
+// Gather lists the network interfaces with their addresses and routes
+func Gather() ([]Interface, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list network interfaces: %w", err)
+	}
+
+	// Without the routing tables, e.g. on other systems than Linux, no
+	// interface has a default route
+	routes := defaultRoutes()
+
+	result := make([]Interface, 0, len(ifaces))
+	for _, iface := range ifaces {
+		info := Interface{
+			Name:         iface.Name,
+			Index:        iface.Index,
+			Flags:        iface.Flags,
+			DefaultRoute: routes[iface.Name],
+		}
+		if _, err := os.Stat(filepath.Join(sysClassNet, iface.Name, "bridge")); err == nil {
+			info.Bridge = true
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list addresses of %s: %w", iface.Name, err)
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok {
+				info.Addresses = append(info.Addresses, ipNet.IP)
+			}
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+// defaultRoutes returns the interfaces with a default route
+func defaultRoutes() map[string]bool {
+	routes := make(map[string]bool)
+
+	// Iface Destination Gateway Flags RefCnt Use Metric Mask ...
+	readRoutes(ipv4RouteFile, func(fields []string) {
+		if len(fields) >= 8 && fields[1] == "00000000" && fields[7] == "00000000" {
+			routes[fields[0]] = true
+		}
+	})
+	// Destination PrefixLength Source SourcePrefixLength NextHop Metric
+	// RefCnt Use Flags Iface. Unreachable default routes are on lo.
+	readRoutes(ipv6RouteFile, func(fields []string) {
+		if len(fields) >= 10 && fields[0] == strings.Repeat("0", 32) && fields[1] == "00" && fields[9] != "lo" {
+			routes[fields[9]] = true
+		}
+	})
+	return routes
+}
+
+// readRoutes calls route with the fields of each line of a routing table
+func readRoutes(path string, route func(fields []string)) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		route(strings.Fields(scanner.Text()))
+	}
+}
+
+// Select chooses the primary interface among interfaces. A configured
+// interface is used as is, otherwise the usable interface with the highest
+// score is chosen, preferring the lowest index on ties. Without a usable
+// interface none is chosen.
+func Select(configured string, interfaces []Interface) models.NetworkInfo {
+	info := models.NetworkInfo{Interfaces: make([]models.NetworkInterface, 0, len(interfaces))}
+	for _, iface := range interfaces {
+		info.Interfaces = append(info.Interfaces, describe(iface))
+	}
+	slices.SortFunc(info.Interfaces, func(a, b models.NetworkInterface) int {
+		return cmp.Compare(a.Index, b.Index)
+	})
+
+	if configured != "" {
+		info.PrimaryInterface = configured
+		info.Source = models.NetworkSourceConfig
+		info.Reason = "configured by network.primary_interface"
+		return info
+	}
+
+	var best *models.NetworkInterface
+	for i := range info.Interfaces {
+		candidate := &info.Interfaces[i]
+		if candidate.Skipped == "" && (best == nil || candidate.Score > best.Score) {
+			best = candidate
+		}
+	}
+	if best == nil {
+		info.Source = models.NetworkSourceNone
+		info.Reason = "no usable interface, link-local traffic and mDNS use all interfaces"
+		return info
+	}
+
+	info.PrimaryInterface = best.Name
+	info.Source = models.NetworkSourceAuto
+	var reasons []string
+	if best.DefaultRoute {
+		reasons = append(reasons, "default route")
+	}
+	if best.GlobalIPv6 {
+		reasons = append(reasons, "global IPv6 address")
+	}
+	reasons = append(reasons, "IPv6 link-local address")
+	info.Reason = "best usable interface: " + strings.Join(reasons, ", ")
+	return info
+}
+
+// describe returns the properties and score of an interface, or why it can't
+// be the primary interface
+func describe(iface Interface) models.NetworkInterface {
+	result := models.NetworkInterface{
+		Name:         iface.Name,
+		Index:        iface.Index,
+		Addresses:    make([]string, 0, len(iface.Addresses)),
+		DefaultRoute: iface.DefaultRoute,
+	}
+	for _, ip := range iface.Addresses {
+		result.Addresses = append(result.Addresses, ip.String())
+		if ip.To4() != nil {
+			continue
+		}
+		switch {
+		case ip.IsLinkLocalUnicast():
+			result.LinkLocalIPv6 = true
+		case ip.IsGlobalUnicast():
+			result.GlobalIPv6 = true
+		}
+	}
+
+	switch {
+	case iface.Flags&net.FlagLoopback != 0:
+		result.Skipped = "loopback"
+	case iface.Flags&net.FlagUp == 0:
+		result.Skipped = "down"
+	case iface.Flags&net.FlagMulticast == 0:
+		result.Skipped = "no multicast"
+	case isVirtual(iface.Name):
+		result.Skipped = "container, VM or VPN interface"
+	case iface.Bridge && !iface.DefaultRoute:
+		// Bridges of hypervisors carrying the host's traffic have the
+		// default route
+		result.Skipped = "bridge without default route"
+	case !result.LinkLocalIPv6:
+		result.Skipped = "no IPv6 link-local address"
+	}
+
+	if result.DefaultRoute {
+		result.Score += scoreDefaultRoute
+	}
+	if result.GlobalIPv6 {
+		result.Score += scoreGlobalIPv6
+	}
+	return result
+}
+
+func isVirtual(name string) bool {
+	for _, prefix := range virtualPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
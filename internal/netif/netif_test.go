@@ -0,0 +1,96 @@
+package netif
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+const usable = net.FlagUp | net.FlagMulticast
+
+func ips(addrs ...string) []net.IP {
+	var result []net.IP
+	for _, addr := range addrs {
+		result = append(result, net.ParseIP(addr))
+	}
+	return result
+}
+
+func TestSelect(t *testing.T) {
+	lo := Interface{Name: "lo", Index: 1, Flags: usable | net.FlagLoopback, Addresses: ips("127.0.0.1", "::1")}
+	docker := Interface{Name: "docker0", Index: 3, Flags: usable, Addresses: ips("172.17.0.1", "fe80::42:acff:fe11:1")}
+	wlan := Interface{Name: "wlan0", Index: 4, Flags: usable, Addresses: ips("192.168.1.20", "fe80::2")}
+	eth := Interface{Name: "eth0", Index: 2, Flags: usable, DefaultRoute: true, Addresses: ips("192.168.1.10", "fe80::1")}
+	global := Interface{Name: "wlan1", Index: 5, Flags: usable, Addresses: ips("2001:db8::5", "fe80::5")}
+
+	tests := []struct {
+		name       string
+		configured string
+		interfaces []Interface
+		want       string
+		source     string
+	}{
+		{"Configured", "wlan0", []Interface{lo, eth, wlan}, "wlan0", models.NetworkSourceConfig},
+		{"Default route", "", []Interface{lo, docker, wlan, global, eth}, "eth0", models.NetworkSourceAuto},
+		{"Global IPv6", "", []Interface{lo, wlan, global}, "wlan1", models.NetworkSourceAuto},
+		{"Lowest index", "", []Interface{wlan, {Name: "eth1", Index: 9, Flags: usable, Addresses: ips("fe80::9")}}, "wlan0", models.NetworkSourceAuto},
+		{"Only virtual", "", []Interface{lo, docker}, "", models.NetworkSourceNone},
+		{"Host bridge", "", []Interface{wlan, {Name: "br0", Index: 6, Flags: usable, Bridge: true, DefaultRoute: true, Addresses: ips("fe80::6")}}, "br0", models.NetworkSourceAuto},
+		{"Container bridge", "", []Interface{wlan, {Name: "lan", Index: 1, Flags: usable, Bridge: true, Addresses: ips("fe80::7")}}, "wlan0", models.NetworkSourceAuto},
+		{"Without IPv6", "", []Interface{{Name: "eth0", Index: 2, Flags: usable, DefaultRoute: true, Addresses: ips("192.168.1.10")}}, "", models.NetworkSourceNone},
+		{"Down", "", []Interface{{Name: "eth0", Index: 2, Flags: net.FlagMulticast, Addresses: ips("fe80::1")}}, "", models.NetworkSourceNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := Select(tt.configured, tt.interfaces)
+			if info.PrimaryInterface != tt.want || info.Source != tt.source {
+				t.Errorf("Expected %q from %s, got %q from %s (%s)", tt.want, tt.source, info.PrimaryInterface, info.Source, info.Reason)
+			}
+			if len(info.Interfaces) != len(tt.interfaces) {
+				t.Errorf("Expected %d interfaces, got %d", len(tt.interfaces), len(info.Interfaces))
+			}
+		})
+	}
+
+	info := Select("", []Interface{lo, docker, eth})
+	if info.Reason != "best usable interface: default route, IPv6 link-local address" {
+		t.Errorf("Unexpected reason %q", info.Reason)
+	}
+	for _, iface := range info.Interfaces {
+		want := map[string]string{"lo": "loopback", "docker0": "container, VM or VPN interface", "eth0": ""}[iface.Name]
+		if iface.Skipped != want {
+			t.Errorf("Expected %s skipped for %q, got %q", iface.Name, want, iface.Skipped)
+		}
+	}
+	if info.Interfaces[1].Name != "eth0" || info.Interfaces[1].Score != scoreDefaultRoute || !info.Interfaces[1].LinkLocalIPv6 {
+		t.Errorf("Unexpected eth0 %+v", info.Interfaces[1])
+	}
+}
+
+func TestDefaultRoutes(t *testing.T) {
+	dir := t.TempDir()
+	ipv4RouteFile = filepath.Join(dir, "route")
+	ipv6RouteFile = filepath.Join(dir, "ipv6_route")
+	t.Cleanup(func() {
+		ipv4RouteFile = "/proc/net/route"
+		ipv6RouteFile = "/proc/net/ipv6_route"
+	})
+
+	os.WriteFile(ipv4RouteFile, []byte(
+		"Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n"+
+			"eth0\t00000000\t0101A8C0\t0003\t0\t0\t100\t00000000\t0\t0\t0\n"+
+			"eth0\t0001A8C0\t00000000\t0001\t0\t0\t100\t00FFFFFF\t0\t0\t0\n"+
+			"docker0\t000011AC\t00000000\t0001\t0\t0\t0\t0000FFFF\t0\t0\t0\n"), 0o644)
+	os.WriteFile(ipv6RouteFile, []byte(
+		"fe800000000000000000000000000000 40 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000001 00000000 00000001     wlan0\n"+
+			"00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe800000000000000000000000000001 00000400 00000001 00000000 00000003    wlan1\n"+
+			"00000000000000000000000000000000 00 00000000000000000000000000000000 00 00000000000000000000000000000000 ffffffff 00000001 00000000 00200200       lo\n"), 0o644)
+
+	routes := defaultRoutes()
+	if len(routes) != 2 || !routes["eth0"] || !routes["wlan1"] {
+		t.Errorf("Expected default routes via eth0 and wlan1, got %v", routes)
+	}
+}
@@ -0,0 +1,124 @@
+package onboarding
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	manualCodeLength       = 11
+	manualCodeLengthVIDPID = 21
+
+	// Flag in the first digit for codes carrying the vendor and product ID
+	manualCodeVIDPIDFlag = 1 << 2
+
+	// Commissioning flow of manual codes with vendor and product ID, which
+	// don't tell the user intent and custom flows apart
+	flowNonStandard = "non_standard"
+)
+
+// ParseManualCode decodes an 11 or 21 digit manual pairing code. Dashes and
+// spaces are ignored.
+func ParseManualCode(code string) (*Payload, error) {
+	digits := strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code))
+	if len(digits) != manualCodeLength && len(digits) != manualCodeLengthVIDPID {
+		return nil, fmt.Errorf("manual pairing code has %d digits, expected %d or %d", len(digits), manualCodeLength, manualCodeLengthVIDPID)
+	}
+	if strings.Trim(digits, "0123456789") != "" {
+		return nil, fmt.Errorf("manual pairing code must only contain digits")
+	}
+	if verhoeffCheckDigit(digits[:len(digits)-1]) != digits[len(digits)-1] {
+		return nil, fmt.Errorf("invalid check digit of manual pairing code")
+	}
+
+	first := int(digits[0] - '0')
+	vidPID := first&manualCodeVIDPIDFlag != 0
+	if first > 7 || vidPID != (len(digits) == manualCodeLengthVIDPID) {
+		return nil, fmt.Errorf("invalid first digit %d of manual pairing code", first)
+	}
+	number := func(from, to int) int {
+		n, _ := strconv.Atoi(digits[from:to])
+		return n
+	}
+	chunk2, chunk3 := number(1, 6), number(6, 10)
+	if chunk2 > 0xFFFF || chunk3 > 0x1FFF {
+		return nil, fmt.Errorf("invalid manual pairing code")
+	}
+
+	p := &Payload{
+		Source:                SourceManualCode,
+		CommissioningFlow:     commissioningFlows[0],
+		DiscoveryCapabilities: []string{},
+		Discriminator:         (first&0x3)<<2 | chunk2>>14,
+		HasShortDiscriminator: true,
+		Passcode:              chunk3<<14 | chunk2&0x3FFF,
+	}
+	if vidPID {
+		p.CommissioningFlow = flowNonStandard
+		p.VendorID = number(10, 15)
+		p.ProductID = number(15, 20)
+		if p.VendorID > 0xFFFF || p.ProductID > 0xFFFF {
+			return nil, fmt.Errorf("invalid vendor or product ID in manual pairing code")
+		}
+	}
+	return p, p.validate()
+}
+
+// manualCode encodes the manual pairing code of a payload, with the vendor
+// and product ID for non-standard commissioning flows
+func (p *Payload) manualCode() string {
+	short := p.Discriminator
+	if !p.HasShortDiscriminator {
+		short >>= 8
+	}
+	vidPID := p.CommissioningFlow != commissioningFlows[0]
+
+	first := short >> 2
+	if vidPID {
+		first |= manualCodeVIDPIDFlag
+	}
+	code := fmt.Sprintf("%d%05d%04d", first, (short&0x3)<<14|p.Passcode&0x3FFF, p.Passcode>>14)
+	if vidPID {
+		code += fmt.Sprintf("%05d%05d", p.VendorID, p.ProductID)
+	}
+	return code + string(verhoeffCheckDigit(code))
+}
+
+// Verhoeff tables: multiplication in the dihedral group D5 and the
+// permutation applied by position
+var (
+	verhoeffD = [10][10]int{
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		{1, 2, 3, 4, 0, 6, 7, 8, 9, 5},
+		{2, 3, 4, 0, 1, 7, 8, 9, 5, 6},
+		{3, 4, 0, 1, 2, 8, 9, 5, 6, 7},
+		{4, 0, 1, 2, 3, 9, 5, 6, 7, 8},
+		{5, 9, 8, 7, 6, 0, 4, 3, 2, 1},
+		{6, 5, 9, 8, 7, 1, 0, 4, 3, 2},
+		{7, 6, 5, 9, 8, 2, 1, 0, 4, 3},
+		{8, 7, 6, 5, 9, 3, 2, 1, 0, 4},
+		{9, 8, 7, 6, 5, 4, 3, 2, 1, 0},
+	}
+	verhoeffP = [8][10]int{
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		{1, 5, 7, 6, 2, 8, 3, 0, 9, 4},
+		{5, 8, 0, 3, 7, 9, 6, 1, 4, 2},
+		{8, 9, 1, 6, 0, 4, 3, 5, 2, 7},
+		{9, 4, 5, 3, 1, 2, 0, 7, 6, 8},
+		{4, 2, 8, 6, 5, 7, 3, 9, 0, 1},
+		{2, 7, 9, 3, 8, 0, 6, 4, 1, 5},
+		{7, 0, 4, 6, 9, 1, 3, 2, 5, 8},
+	}
+	verhoeffInv = [10]int{0, 4, 3, 2, 1, 5, 6, 7, 8, 9}
+)
+
+// verhoeffCheckDigit computes the Verhoeff check digit of a digit string
+func verhoeffCheckDigit(digits string) byte {
+	c := 0
+	for i := 0; i < len(digits); i++ {
+		digit := int(digits[len(digits)-1-i] - '0')
+		c = verhoeffD[c][verhoeffP[(i+1)%8][digit]]
+	}
+	return byte('0' + verhoeffInv[c])
+}
@@ -0,0 +1,94 @@
+package onboarding
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// NDEF record header flags (NFC Forum NDEF 1.0)
+const (
+	ndefFlagShortRecord = 0x10
+	ndefFlagIDLength    = 0x08
+	ndefTNFMask         = 0x07
+
+	ndefTNFWellKnown   = 0x01
+	ndefTNFAbsoluteURI = 0x03
+
+	// URI identifier code without prefix
+	ndefURIPrefixNone = 0x00
+)
+
+// ParseNDEF decodes the onboarding payload of an NFC tag: the first URI
+// record of the NDEF message holding a QR code payload
+func ParseNDEF(message []byte) (*Payload, error) {
+	for len(message) > 0 {
+		tnf, recordType, payload, rest, err := ndefRecord(message)
+		if err != nil {
+			return nil, err
+		}
+		message = rest
+
+		var uri string
+		switch {
+		case tnf == ndefTNFWellKnown && recordType == "U" && len(payload) > 0:
+			if payload[0] != ndefURIPrefixNone {
+				continue
+			}
+			uri = string(payload[1:])
+		case tnf == ndefTNFAbsoluteURI:
+			uri = recordType
+		default:
+			continue
+		}
+
+		if strings.HasPrefix(strings.ToUpper(uri), qrCodePrefix) {
+			p, err := ParseQRCode(uri)
+			if err != nil {
+				return nil, err
+			}
+			p.Source = SourceNFC
+			return p, nil
+		}
+	}
+	return nil, errors.New("NDEF message holds no onboarding payload")
+}
+
+// ndefRecord splits the first record off an NDEF message
+func ndefRecord(message []byte) (tnf byte, recordType string, payload, rest []byte, err error) {
+	truncated := errors.New("truncated NDEF record")
+	if len(message) < 3 {
+		return 0, "", nil, nil, truncated
+	}
+	header := message[0]
+	typeLength := int(message[1])
+	pos := 2
+
+	var payloadLength int
+	if header&ndefFlagShortRecord != 0 {
+		payloadLength = int(message[pos])
+		pos++
+	} else {
+		if len(message) < pos+4 {
+			return 0, "", nil, nil, truncated
+		}
+		payloadLength = int(message[pos])<<24 | int(message[pos+1])<<16 | int(message[pos+2])<<8 | int(message[pos+3])
+		pos += 4
+	}
+	idLength := 0
+	if header&ndefFlagIDLength != 0 {
+		if len(message) < pos+1 {
+			return 0, "", nil, nil, truncated
+		}
+		idLength = int(message[pos])
+		pos++
+	}
+
+	end := pos + typeLength + idLength + payloadLength
+	if payloadLength < 0 || end > len(message) {
+		return 0, "", nil, nil, fmt.Errorf("NDEF record of %d bytes exceeds the message", end-pos)
+	}
+	recordType = string(message[pos : pos+typeLength])
+	payload = message[pos+typeLength+idLength : end]
+	return header & ndefTNFMask, recordType, payload, message[end:], nil
+}
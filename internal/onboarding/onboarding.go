@@ -0,0 +1,88 @@
+// Package onboarding decodes Matter onboarding payloads: QR code payloads
+// ("MT:..."), manual pairing codes and NFC tags holding a QR code payload
+// (Matter Core Specification section 5.1).
+package onboarding
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Sources of a payload
+const (
+	SourceQRCode     = "qr_code"
+	SourceManualCode = "manual_code"
+	SourceNFC        = "nfc"
+)
+
+// Commissioning flows
+var commissioningFlows = []string{"standard", "user_intent", "custom"}
+
+// Discovery capabilities, by bit of the QR code payload
+var discoveryCapabilities = []string{"soft_ap", "ble", "on_network", "wifi_pub_sub"}
+
+const (
+	maxPasscode      = 99999998
+	discriminatorMax = 0x0FFF
+)
+
+// ErrUnknownFormat is returned for codes that are neither a QR code payload
+// nor a manual pairing code
+var ErrUnknownFormat = errors.New("not a QR code payload or manual pairing code")
+
+// Payload holds the commissioning parameters of an onboarding payload
+type Payload struct {
+	Source  string `json:"source"`
+	Version int    `json:"version"`
+	// Manual pairing codes only carry them with a non-standard flow
+	VendorID  int `json:"vendor_id,omitempty"`
+	ProductID int `json:"product_id,omitempty"`
+	// "standard", "user_intent" or "custom"
+	CommissioningFlow string `json:"commissioning_flow"`
+	// "soft_ap", "ble", "on_network" and "wifi_pub_sub", empty for manual
+	// pairing codes
+	DiscoveryCapabilities []string `json:"discovery_capabilities"`
+	// The 12-bit discriminator, or its upper 4 bits for manual pairing codes
+	Discriminator         int    `json:"discriminator"`
+	HasShortDiscriminator bool   `json:"has_short_discriminator"`
+	Passcode              int    `json:"passcode"`
+	SerialNumber          string `json:"serial_number,omitempty"`
+	// Manual pairing code of the payload, for display
+	ManualCode string `json:"manual_code"`
+}
+
+// Parse decodes a QR code payload or a manual pairing code
+func Parse(code string) (*Payload, error) {
+	code = strings.TrimSpace(code)
+	if strings.HasPrefix(strings.ToUpper(code), qrCodePrefix) {
+		return ParseQRCode(code)
+	}
+	if strings.Trim(code, "0123456789- ") == "" {
+		return ParseManualCode(code)
+	}
+	return nil, ErrUnknownFormat
+}
+
+// validate checks the ranges of the payload fields and fills in the manual
+// pairing code
+func (p *Payload) validate() error {
+	if p.Passcode < 1 || p.Passcode > maxPasscode || invalidPasscode(p.Passcode) {
+		return fmt.Errorf("invalid passcode %08d", p.Passcode)
+	}
+	if p.Discriminator > discriminatorMax {
+		return fmt.Errorf("invalid discriminator %d", p.Discriminator)
+	}
+	p.ManualCode = p.manualCode()
+	return nil
+}
+
+// invalidPasscode reports the passcodes the specification rules out for
+// being trivial
+func invalidPasscode(passcode int) bool {
+	switch passcode {
+	case 11111111, 22222222, 33333333, 44444444, 55555555, 66666666, 77777777, 88888888, 12345678, 87654321:
+		return true
+	}
+	return false
+}
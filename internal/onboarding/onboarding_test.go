@@ -0,0 +1,92 @@
+package onboarding
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Payload of the Matter SDK examples
+const testQRCode = "MT:Y.K9042C00KA0648G00"
+
+func TestParseQRCode(t *testing.T) {
+	expected := &Payload{
+		Source:                SourceQRCode,
+		VendorID:              0xFFF1,
+		ProductID:             0x8000,
+		CommissioningFlow:     "standard",
+		DiscoveryCapabilities: []string{"ble"},
+		Discriminator:         3840,
+		Passcode:              20202021,
+		ManualCode:            "34970112332",
+	}
+
+	p, err := Parse(testQRCode)
+	if err != nil {
+		t.Fatalf("Failed to parse QR code: %v", err)
+	}
+	if !reflect.DeepEqual(p, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, p)
+	}
+
+	for _, code := range []string{"MT:Y.K9042C00KA0648G0", "MT:Y.K9042C00KA0648G0!", "MT:", testQRCode + "*" + testQRCode} {
+		if _, err := Parse(code); err == nil {
+			t.Errorf("Expected an error for %q", code)
+		}
+	}
+}
+
+func TestParseManualCode(t *testing.T) {
+	for _, code := range []string{"34970112332", "3497-011-2332"} {
+		p, err := Parse(code)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", code, err)
+		}
+		if p.Source != SourceManualCode || p.Discriminator != 15 || !p.HasShortDiscriminator ||
+			p.Passcode != 20202021 || p.VendorID != 0 || p.ManualCode != "34970112332" {
+			t.Errorf("Unexpected payload of %s: %+v", code, p)
+		}
+	}
+
+	// Non-standard flows carry the vendor and product ID
+	custom := &Payload{CommissioningFlow: "custom", VendorID: 0xFFF1, ProductID: 0x8001, Discriminator: 0xA00, Passcode: 20202021}
+	code := custom.manualCode()
+	p, err := ParseManualCode(code)
+	if err != nil {
+		t.Fatalf("Failed to parse %s: %v", code, err)
+	}
+	if len(code) != 21 || p.VendorID != 0xFFF1 || p.ProductID != 0x8001 || p.Discriminator != 0xA || p.CommissioningFlow != flowNonStandard {
+		t.Errorf("Unexpected payload of %s: %+v", code, p)
+	}
+
+	trivial := (&Payload{Discriminator: 15, HasShortDiscriminator: true, Passcode: 12345678}).manualCode()
+	for _, code := range []string{"34970112331", "3497011233", "84970112332", trivial, "hello"} {
+		if _, err := Parse(code); err == nil {
+			t.Errorf("Expected an error for %q", code)
+		}
+	}
+}
+
+func TestParseNDEF(t *testing.T) {
+	uri := append([]byte{ndefURIPrefixNone}, testQRCode...)
+	message := append([]byte{
+		// Text record first
+		0x91, 0x01, 0x03, 'T', 0x02, 'e', 'n',
+		// URI record with the QR code payload
+		0x51, 0x01, byte(len(uri)), 'U',
+	}, uri...)
+
+	p, err := ParseNDEF(message)
+	if err != nil {
+		t.Fatalf("Failed to parse NDEF message: %v", err)
+	}
+	if p.Source != SourceNFC || p.Discriminator != 3840 || p.Passcode != 20202021 {
+		t.Errorf("Unexpected payload %+v", p)
+	}
+
+	if _, err := ParseNDEF(message[:len(message)-4]); err == nil {
+		t.Error("Expected an error for a truncated message")
+	}
+	if _, err := ParseNDEF(message[:7]); err == nil {
+		t.Error("Expected an error for a message without onboarding payload")
+	}
+}
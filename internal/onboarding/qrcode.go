@@ -0,0 +1,129 @@
+package onboarding
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/codefionn/go-matter-server/internal/tlv"
+)
+
+const (
+	qrCodePrefix = "MT:"
+	base38Chars  = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ-."
+
+	// Length of the bit-packed payload, optional TLV data follows
+	qrPayloadLength = 11
+
+	// Context tag of the serial number in the optional TLV data
+	qrTagSerialNumber = 0x00
+)
+
+// base38Chunks maps the characters of a base38 chunk to its bytes
+var base38Chunks = map[int]int{5: 3, 4: 2, 2: 1}
+
+// ParseQRCode decodes a QR code payload starting with "MT:"
+func ParseQRCode(code string) (*Payload, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if !strings.HasPrefix(code, qrCodePrefix) {
+		return nil, fmt.Errorf("QR code payload must start with %s", qrCodePrefix)
+	}
+	body := code[len(qrCodePrefix):]
+	if strings.Contains(body, "*") {
+		return nil, errors.New("concatenated QR code payloads are not supported")
+	}
+
+	data, err := base38Decode(body)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < qrPayloadLength {
+		return nil, fmt.Errorf("QR code payload is %d bytes, expected at least %d", len(data), qrPayloadLength)
+	}
+
+	r := bitReader{data: data}
+	p := &Payload{Source: SourceQRCode, DiscoveryCapabilities: []string{}}
+	p.Version = r.read(3)
+	p.VendorID = r.read(16)
+	p.ProductID = r.read(16)
+	flow := r.read(2)
+	capabilities := r.read(8)
+	p.Discriminator = r.read(12)
+	p.Passcode = r.read(27)
+
+	if p.Version != 0 {
+		return nil, fmt.Errorf("unsupported QR code payload version %d", p.Version)
+	}
+	if flow >= len(commissioningFlows) {
+		return nil, fmt.Errorf("invalid commissioning flow %d", flow)
+	}
+	p.CommissioningFlow = commissioningFlows[flow]
+	for bit, name := range discoveryCapabilities {
+		if capabilities&(1<<bit) != 0 {
+			p.DiscoveryCapabilities = append(p.DiscoveryCapabilities, name)
+		}
+	}
+
+	if len(data) > qrPayloadLength {
+		optional, err := tlv.Decode(data[qrPayloadLength:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid optional QR code data: %w", err)
+		}
+		if serial, ok := optional.Field(qrTagSerialNumber); ok {
+			if s, ok := serial.String(); ok {
+				p.SerialNumber = s
+			} else if n, ok := serial.Uint(); ok {
+				p.SerialNumber = fmt.Sprint(n)
+			}
+		}
+	}
+
+	return p, p.validate()
+}
+
+// base38Decode decodes base38, where chunks of 5, 4 and 2 characters hold
+// 3, 2 and 1 bytes with the least significant character first
+func base38Decode(s string) ([]byte, error) {
+	var out []byte
+	for len(s) > 0 {
+		n := min(5, len(s))
+		size, ok := base38Chunks[n]
+		if !ok {
+			return nil, fmt.Errorf("invalid base38 length %d", len(s))
+		}
+
+		value := 0
+		for i := n - 1; i >= 0; i-- {
+			digit := strings.IndexByte(base38Chars, s[i])
+			if digit < 0 {
+				return nil, fmt.Errorf("invalid base38 character %q", s[i])
+			}
+			value = value*38 + digit
+		}
+		if value >= 1<<(8*size) {
+			return nil, fmt.Errorf("invalid base38 chunk %q", s[:n])
+		}
+		for i := 0; i < size; i++ {
+			out = append(out, byte(value>>(8*i)))
+		}
+		s = s[n:]
+	}
+	return out, nil
+}
+
+// bitReader reads little-endian bit fields, least significant bit first
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) read(bits int) int {
+	value := 0
+	for i := 0; i < bits; i++ {
+		if r.data[r.pos/8]&(1<<(r.pos%8)) != 0 {
+			value |= 1 << i
+		}
+		r.pos++
+	}
+	return value
+}
@@ -0,0 +1,89 @@
+package openapi
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testBase struct {
+	ID string `json:"id"`
+}
+
+type testNode struct {
+	testBase
+	Name     string            `json:"name"`
+	Label    *string           `json:"label,omitempty"`
+	Seen     time.Time         `json:"seen"`
+	Tags     []string          `json:"tags"`
+	Values   map[string]int    `json:"values"`
+	Raw      []byte            `json:"raw,omitempty"`
+	Parent   *testNode         `json:"parent,omitempty"`
+	Any      interface{}       `json:"any"`
+	Internal string            `json:"-"`
+	Children []testNode        `json:"children,omitempty"`
+	Extra    map[string]string `json:"extra,omitempty"`
+
+	hidden string
+}
+
+func TestSchemaOf(t *testing.T) {
+	g := NewGenerator()
+	ref := g.SchemaOf(reflect.TypeOf([]testNode{}))
+
+	if ref.Type != "array" || ref.Items.Ref != "#/components/schemas/testNode" {
+		t.Fatalf("Expected array of testNode references, got %+v", ref)
+	}
+
+	schema, ok := g.Schemas()["testNode"]
+	if !ok {
+		t.Fatal("Expected testNode component schema")
+	}
+
+	tests := []struct {
+		field      string
+		wantType   string
+		wantFormat string
+	}{
+		{"id", "string", ""},
+		{"name", "string", ""},
+		{"label", "string", ""},
+		{"seen", "string", "date-time"},
+		{"tags", "array", ""},
+		{"values", "object", ""},
+		{"raw", "string", "byte"},
+		{"any", "", ""},
+	}
+	for _, tt := range tests {
+		property, ok := schema.Properties[tt.field]
+		if !ok {
+			t.Errorf("Expected property %s", tt.field)
+			continue
+		}
+		if property.Type != tt.wantType || property.Format != tt.wantFormat {
+			t.Errorf("Expected %s to be %s/%s, got %s/%s",
+				tt.field, tt.wantType, tt.wantFormat, property.Type, property.Format)
+		}
+	}
+
+	for _, name := range []string{"Internal", "hidden", "testBase"} {
+		if _, ok := schema.Properties[name]; ok {
+			t.Errorf("Expected no property %s", name)
+		}
+	}
+
+	if !schema.Properties["label"].Nullable {
+		t.Error("Expected pointer field to be nullable")
+	}
+	if parent := schema.Properties["parent"]; len(parent.OneOf) != 1 || parent.OneOf[0].Ref == "" || !parent.Nullable {
+		t.Errorf("Expected nullable reference for recursive field, got %+v", parent)
+	}
+	if values := schema.Properties["values"]; values.AdditionalProperties == nil || values.AdditionalProperties.Type != "integer" {
+		t.Errorf("Expected map of integers, got %+v", values)
+	}
+
+	wantRequired := []string{"id", "name", "seen", "tags", "values", "any"}
+	if !reflect.DeepEqual(schema.Required, wantRequired) {
+		t.Errorf("Expected required %v, got %v", wantRequired, schema.Required)
+	}
+}
@@ -0,0 +1,212 @@
+// Package ota looks up software updates of Matter devices in the
+// Distributed Compliance Ledger and in a directory of local updates.
+package ota
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// Source is a DCL instance software updates are looked up in
+type Source struct {
+	URL    string
+	Origin models.UpdateSource
+}
+
+// errNotFound is returned by getJSON when the DCL has no such entry
+var errNotFound = errors.New("not found")
+
+// dclModelVersions lists the software versions of a product
+type dclModelVersions struct {
+	ModelVersions struct {
+		SoftwareVersions []int `json:"softwareVersions"`
+	} `json:"modelVersions"`
+}
+
+// dclModelVersion is a software version of a product. Local updates are
+// JSON files in the same format.
+type dclModelVersion struct {
+	ModelVersion struct {
+		VID                          int    `json:"vid"`
+		PID                          int    `json:"pid"`
+		SoftwareVersion              int    `json:"softwareVersion"`
+		SoftwareVersionString        string `json:"softwareVersionString"`
+		FirmwareInformation          string `json:"firmwareInformation"`
+		SoftwareVersionValid         bool   `json:"softwareVersionValid"`
+		OTAURL                       string `json:"otaUrl"`
+		MinApplicableSoftwareVersion int    `json:"minApplicableSoftwareVersion"`
+		MaxApplicableSoftwareVersion int    `json:"maxApplicableSoftwareVersion"`
+		ReleaseNotesURL              string `json:"releaseNotesUrl"`
+	} `json:"modelVersion"`
+}
+
+// applicable reports whether the version is a valid update of a device
+// running current
+func (v *dclModelVersion) applicable(current int) bool {
+	m := v.ModelVersion
+	return m.SoftwareVersionValid && m.OTAURL != "" &&
+		m.SoftwareVersion > current &&
+		current >= m.MinApplicableSoftwareVersion && current <= m.MaxApplicableSoftwareVersion
+}
+
+// softwareVersion converts the version to the API model
+func (v *dclModelVersion) softwareVersion(origin models.UpdateSource) *models.MatterSoftwareVersion {
+	m := v.ModelVersion
+	version := &models.MatterSoftwareVersion{
+		VID:                          m.VID,
+		PID:                          m.PID,
+		SoftwareVersion:              m.SoftwareVersion,
+		SoftwareVersionString:        m.SoftwareVersionString,
+		MinApplicableSoftwareVersion: m.MinApplicableSoftwareVersion,
+		MaxApplicableSoftwareVersion: m.MaxApplicableSoftwareVersion,
+		UpdateSource:                 origin,
+	}
+	if m.FirmwareInformation != "" {
+		version.FirmwareInformation = &m.FirmwareInformation
+	}
+	if m.ReleaseNotesURL != "" {
+		version.ReleaseNotesURL = &m.ReleaseNotesURL
+	}
+	return version
+}
+
+// Checker finds the newest software update applicable to a device
+type Checker struct {
+	client  *http.Client
+	sources []Source
+}
+
+// NewChecker creates a checker looking up updates in the DCL sources in
+// order. A nil client uses http.DefaultClient.
+func NewChecker(client *http.Client, sources []Source) *Checker {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Checker{client: client, sources: sources}
+}
+
+// Check returns the newest update of the product vid/pid applicable to
+// softwareVersion, nil if there is none. Updates in localDir, if set, take
+// precedence over the DCL. A DCL that can't be reached only fails the check
+// if no other source has an update.
+func (c *Checker) Check(ctx context.Context, vid, pid, softwareVersion int, localDir string) (*models.MatterSoftwareVersion, error) {
+	if localDir != "" {
+		update, err := checkLocal(localDir, vid, pid, softwareVersion)
+		if err != nil {
+			return nil, err
+		}
+		if update != nil {
+			return update, nil
+		}
+	}
+
+	var errs []error
+	for _, source := range c.sources {
+		update, err := c.checkDCL(ctx, source, vid, pid, softwareVersion)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", source.Origin, err))
+			continue
+		}
+		if update != nil {
+			return update, nil
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// checkDCL returns the newest applicable version listed in a DCL
+func (c *Checker) checkDCL(ctx context.Context, source Source, vid, pid, softwareVersion int) (*models.MatterSoftwareVersion, error) {
+	base := fmt.Sprintf("%s/dcl/model/versions/%d/%d", source.URL, vid, pid)
+
+	var versions dclModelVersions
+	if err := c.getJSON(ctx, base, &versions); err != nil {
+		if errors.Is(err, errNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	candidates := versions.ModelVersions.SoftwareVersions
+	sort.Sort(sort.Reverse(sort.IntSlice(candidates)))
+	for _, candidate := range candidates {
+		if candidate <= softwareVersion {
+			break
+		}
+		var version dclModelVersion
+		if err := c.getJSON(ctx, fmt.Sprintf("%s/%d", base, candidate), &version); err != nil {
+			if errors.Is(err, errNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		if version.applicable(softwareVersion) {
+			return version.softwareVersion(source.Origin), nil
+		}
+	}
+	return nil, nil
+}
+
+// checkLocal returns the newest applicable version among the JSON files in
+// dir. Files that aren't software versions are skipped.
+func checkLocal(dir string, vid, pid, softwareVersion int) (*models.MatterSoftwareVersion, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read local updates: %w", err)
+	}
+
+	var newest *dclModelVersion
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		var version dclModelVersion
+		if json.Unmarshal(data, &version) != nil {
+			continue
+		}
+		if version.ModelVersion.VID != vid || version.ModelVersion.PID != pid || !version.applicable(softwareVersion) {
+			continue
+		}
+		if newest == nil || version.ModelVersion.SoftwareVersion > newest.ModelVersion.SoftwareVersion {
+			newest = &version
+		}
+	}
+	if newest == nil {
+		return nil, nil
+	}
+	return newest.softwareVersion(models.UpdateSourceLocal), nil
+}
+
+func (c *Checker) getJSON(ctx context.Context, url string, target interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return json.NewDecoder(resp.Body).Decode(target)
+	case http.StatusNotFound:
+		return errNotFound
+	}
+	return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+}
@@ -0,0 +1,146 @@
+package ota
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// modelVersion returns a DCL software version of product 0xFFF1/0x8000
+func modelVersion(version int, valid bool, minApplicable, maxApplicable int) map[string]interface{} {
+	return map[string]interface{}{
+		"modelVersion": map[string]interface{}{
+			"vid":                          0xFFF1,
+			"pid":                          0x8000,
+			"softwareVersion":              version,
+			"softwareVersionString":        fmt.Sprintf("v%d", version),
+			"softwareVersionValid":         valid,
+			"otaUrl":                       "https://example.com/update.ota",
+			"minApplicableSoftwareVersion": minApplicable,
+			"maxApplicableSoftwareVersion": maxApplicable,
+			"releaseNotesUrl":              "https://example.com/notes",
+		},
+	}
+}
+
+func newDCL(t *testing.T) *httptest.Server {
+	versions := map[string]map[string]interface{}{
+		"2": modelVersion(2, true, 0, 1),
+		"3": modelVersion(3, true, 2, 2),
+		"4": modelVersion(4, false, 0, 3),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/dcl/model/versions/65521/32768", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"modelVersions": map[string]interface{}{"softwareVersions": []int{1, 2, 3, 4}},
+		})
+	})
+	mux.HandleFunc("/dcl/model/versions/65521/32768/", func(w http.ResponseWriter, r *http.Request) {
+		version, ok := versions[filepath.Base(r.URL.Path)]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(version)
+	})
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+	ts := newDCL(t)
+	checker := NewChecker(ts.Client(), []Source{{URL: ts.URL, Origin: models.UpdateSourceMainNetDCL}})
+
+	tests := []struct {
+		name     string
+		current  int
+		expected int
+	}{
+		{"Skips invalid versions", 1, 2},
+		{"Respects the applicable range", 2, 3},
+		{"Up to date", 3, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			update, err := checker.Check(ctx, 0xFFF1, 0x8000, tt.current, "")
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if tt.expected == 0 {
+				if update != nil {
+					t.Errorf("Expected no update, got version %d", update.SoftwareVersion)
+				}
+				return
+			}
+			if update == nil || update.SoftwareVersion != tt.expected {
+				t.Fatalf("Expected version %d, got %+v", tt.expected, update)
+			}
+			if update.UpdateSource != models.UpdateSourceMainNetDCL || update.ReleaseNotesURL == nil {
+				t.Errorf("Expected the DCL details, got %+v", update)
+			}
+		})
+	}
+
+	t.Run("Unknown product", func(t *testing.T) {
+		update, err := checker.Check(ctx, 0xFFF1, 0x8001, 1, "")
+		if err != nil || update != nil {
+			t.Errorf("Expected no update and no error, got %+v, %v", update, err)
+		}
+	})
+
+	t.Run("DCL unavailable", func(t *testing.T) {
+		down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer down.Close()
+		checker := NewChecker(down.Client(), []Source{
+			{URL: down.URL, Origin: models.UpdateSourceMainNetDCL},
+			{URL: ts.URL, Origin: models.UpdateSourceTestNetDCL},
+		})
+		update, err := checker.Check(ctx, 0xFFF1, 0x8000, 1, "")
+		if err != nil || update == nil || update.UpdateSource != models.UpdateSourceTestNetDCL {
+			t.Errorf("Expected the update from the test net, got %+v, %v", update, err)
+		}
+		if _, err := NewChecker(down.Client(), []Source{{URL: down.URL}}).Check(ctx, 0xFFF1, 0x8000, 1, ""); err == nil {
+			t.Error("Expected an error without a reachable DCL")
+		}
+	})
+}
+
+func TestCheckLocal(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, content interface{}) {
+		data, _ := json.Marshal(content)
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	write("v5.json", modelVersion(5, true, 0, 4))
+	write("v6.json", modelVersion(6, true, 0, 4))
+	write("notes.txt", "not an update")
+	write("broken.json", "not a version")
+
+	ts := newDCL(t)
+	checker := NewChecker(ts.Client(), []Source{{URL: ts.URL, Origin: models.UpdateSourceMainNetDCL}})
+	update, err := checker.Check(context.Background(), 0xFFF1, 0x8000, 1, dir)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if update == nil || update.SoftwareVersion != 6 || update.UpdateSource != models.UpdateSourceLocal {
+		t.Errorf("Expected local version 6, got %+v", update)
+	}
+
+	if _, err := checker.Check(context.Background(), 0xFFF1, 0x8000, 1, filepath.Join(dir, "missing")); err == nil {
+		t.Error("Expected an error for a missing directory")
+	}
+}
@@ -0,0 +1,224 @@
+// Package ping probes the reachability of Matter node addresses using ICMP
+// echo, falling back to a Matter message over UDP when raw sockets are not
+// permitted.
+package ping
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// MatterPort is the default Matter operational UDP port
+const MatterPort = 5540
+
+// ICMP message types
+const (
+	icmpv4EchoRequest = 8
+	icmpv4EchoReply   = 0
+	icmpv6EchoRequest = 128
+	icmpv6EchoReply   = 129
+)
+
+// Matter message constants for the UDP probe
+const (
+	messageFlagSourceNodeID = 0x04
+	exchangeFlagInitiator   = 0x01
+	exchangeFlagReliable    = 0x04
+	protocolEcho            = 0x0002
+	opcodeEchoRequest       = 0x01
+)
+
+// ErrNotPermitted is returned when ICMP raw sockets are not permitted
+var ErrNotPermitted = errors.New("icmp not permitted")
+
+// Address probes a single address and reports whether it responded. ICMP is
+// tried first; if raw sockets are not permitted a Matter message is sent to
+// the given UDP port instead.
+func Address(ctx context.Context, ip net.IP, zone string, port int, timeout time.Duration) bool {
+	reachable, err := ICMP(ctx, ip, zone, timeout)
+	if err == nil {
+		return reachable
+	}
+	return UDP(ctx, ip, zone, port, timeout)
+}
+
+// Addresses probes all addresses concurrently and returns a reachability map
+// keyed by address string
+func Addresses(ctx context.Context, addrs []string, zone string, port int, timeout time.Duration) map[string]bool {
+	result := make(map[string]bool, len(addrs))
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, addr := range addrs {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			result[addr] = false
+			continue
+		}
+
+		wg.Add(1)
+		go func(addr string, ip net.IP) {
+			defer wg.Done()
+
+			addrZone := ""
+			if ip.IsLinkLocalUnicast() && ip.To4() == nil {
+				addrZone = zone
+			}
+			reachable := Address(ctx, ip, addrZone, port, timeout)
+
+			mu.Lock()
+			result[addr] = reachable
+			mu.Unlock()
+		}(addr, ip)
+	}
+	wg.Wait()
+
+	return result
+}
+
+// ICMP sends an ICMP echo request and waits for the matching reply. It
+// returns ErrNotPermitted if raw ICMP sockets cannot be opened.
+func ICMP(ctx context.Context, ip net.IP, zone string, timeout time.Duration) (bool, error) {
+	network, requestType, replyType := "ip4:icmp", byte(icmpv4EchoRequest), byte(icmpv4EchoReply)
+	if ip.To4() == nil {
+		network, requestType, replyType = "ip6:ipv6-icmp", icmpv6EchoRequest, icmpv6EchoReply
+	}
+
+	conn, err := net.ListenPacket(network, "")
+	if err != nil {
+		if errors.Is(err, os.ErrPermission) {
+			return false, ErrNotPermitted
+		}
+		return false, err
+	}
+	defer conn.Close()
+
+	id := uint16(os.Getpid())
+	seq := randomUint16()
+	packet := echoRequest(requestType, id, seq)
+
+	dst := &net.IPAddr{IP: ip, Zone: zone}
+	if _, err := conn.WriteTo(packet, dst); err != nil {
+		if errors.Is(err, os.ErrPermission) {
+			return false, ErrNotPermitted
+		}
+		return false, nil
+	}
+
+	conn.SetReadDeadline(deadline(ctx, timeout))
+
+	buf := make([]byte, 1500)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			return false, nil
+		}
+		src, ok := from.(*net.IPAddr)
+		if !ok || !src.IP.Equal(ip) || n < 8 {
+			continue
+		}
+		if buf[0] == replyType &&
+			binary.BigEndian.Uint16(buf[4:6]) == id &&
+			binary.BigEndian.Uint16(buf[6:8]) == seq {
+			return true, nil
+		}
+	}
+}
+
+// UDP sends a reliable unsecured Matter echo request to the node. Any reply
+// (echo response or standalone acknowledgement) means the node is reachable.
+func UDP(ctx context.Context, ip net.IP, zone string, port int, timeout time.Duration) bool {
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: ip, Port: port, Zone: zone})
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write(matterEchoRequest()); err != nil {
+		return false
+	}
+
+	conn.SetReadDeadline(deadline(ctx, timeout))
+
+	buf := make([]byte, 1280)
+	n, err := conn.Read(buf)
+	return err == nil && n > 0
+}
+
+func deadline(ctx context.Context, timeout time.Duration) time.Time {
+	d := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(d) {
+		return ctxDeadline
+	}
+	return d
+}
+
+// echoRequest builds an ICMP echo request. The checksum is computed for
+// ICMPv4 only; the kernel fills it in for ICMPv6.
+func echoRequest(msgType byte, id, seq uint16) []byte {
+	packet := make([]byte, 16)
+	packet[0] = msgType
+	binary.BigEndian.PutUint16(packet[4:], id)
+	binary.BigEndian.PutUint16(packet[6:], seq)
+	copy(packet[8:], "matter!!")
+
+	if msgType == icmpv4EchoRequest {
+		binary.BigEndian.PutUint16(packet[2:], checksum(packet))
+	}
+	return packet
+}
+
+func checksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum > 0xffff {
+		sum = (sum >> 16) + (sum & 0xffff)
+	}
+	return ^uint16(sum)
+}
+
+// matterEchoRequest builds an unsecured Matter message (session ID 0) with
+// the reliable flag set, so the receiver acknowledges it even if it does not
+// implement the echo protocol.
+func matterEchoRequest() []byte {
+	msg := make([]byte, 0, 32)
+
+	// Message header: flags, session ID, security flags, counter, source node ID
+	msg = append(msg, messageFlagSourceNodeID)
+	msg = binary.LittleEndian.AppendUint16(msg, 0)
+	msg = append(msg, 0)
+	msg = binary.LittleEndian.AppendUint32(msg, randomUint32())
+	var sourceNodeID [8]byte
+	rand.Read(sourceNodeID[:])
+	msg = append(msg, sourceNodeID[:]...)
+
+	// Protocol header: exchange flags, opcode, exchange ID, protocol ID
+	msg = append(msg, exchangeFlagInitiator|exchangeFlagReliable, opcodeEchoRequest)
+	msg = binary.LittleEndian.AppendUint16(msg, randomUint16())
+	msg = binary.LittleEndian.AppendUint16(msg, protocolEcho)
+
+	return append(msg, "ping"...)
+}
+
+func randomUint16() uint16 {
+	var b [2]byte
+	rand.Read(b[:])
+	return binary.BigEndian.Uint16(b[:])
+}
+
+func randomUint32() uint32 {
+	var b [4]byte
+	rand.Read(b[:])
+	return binary.BigEndian.Uint32(b[:])
+}
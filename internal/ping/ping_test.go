@@ -0,0 +1,96 @@
+package ping
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestChecksum(t *testing.T) {
+	packet := echoRequest(icmpv4EchoRequest, 0x1234, 1)
+
+	// A packet including its checksum must sum to zero
+	if sum := checksum(packet); sum != 0 {
+		t.Errorf("Expected checksum over full packet to be 0, got 0x%04x", sum)
+	}
+	if binary.BigEndian.Uint16(packet[4:6]) != 0x1234 || binary.BigEndian.Uint16(packet[6:8]) != 1 {
+		t.Error("Expected ID and sequence to be encoded")
+	}
+}
+
+func TestMatterEchoRequest(t *testing.T) {
+	msg := matterEchoRequest()
+
+	if msg[0] != messageFlagSourceNodeID {
+		t.Errorf("Expected message flags 0x%02x, got 0x%02x", messageFlagSourceNodeID, msg[0])
+	}
+	if binary.LittleEndian.Uint16(msg[1:3]) != 0 {
+		t.Error("Expected unsecured session ID 0")
+	}
+
+	// 16 byte message header (8 + source node ID) precedes the protocol header
+	if msg[16]&exchangeFlagReliable == 0 || msg[16]&exchangeFlagInitiator == 0 {
+		t.Errorf("Expected initiator and reliable exchange flags, got 0x%02x", msg[16])
+	}
+	if msg[17] != opcodeEchoRequest || binary.LittleEndian.Uint16(msg[20:22]) != protocolEcho {
+		t.Error("Expected echo request protocol header")
+	}
+}
+
+func TestUDPProbe(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer conn.Close()
+
+	go func() {
+		buf := make([]byte, 1280)
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil || n == 0 {
+			return
+		}
+		conn.WriteToUDP([]byte{0x00}, from)
+	}()
+
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	if !UDP(context.Background(), net.IPv4(127, 0, 0, 1), "", port, time.Second) {
+		t.Error("Expected responding UDP endpoint to be reachable")
+	}
+}
+
+func TestUDPProbeNoResponse(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	conn.Close()
+
+	if UDP(context.Background(), net.IPv4(127, 0, 0, 1), "", port, 200*time.Millisecond) {
+		t.Error("Expected closed port to be unreachable")
+	}
+}
+
+func TestICMPLoopback(t *testing.T) {
+	reachable, err := ICMP(context.Background(), net.IPv4(127, 0, 0, 1), "", time.Second)
+	if errors.Is(err, ErrNotPermitted) {
+		t.Skip("ICMP raw sockets not permitted")
+	}
+	if err != nil {
+		t.Skipf("ICMP unavailable: %v", err)
+	}
+	if !reachable {
+		t.Error("Expected loopback to answer ICMP echo")
+	}
+}
+
+func TestAddressesInvalid(t *testing.T) {
+	result := Addresses(context.Background(), []string{"not-an-ip"}, "", MatterPort, 100*time.Millisecond)
+	if reachable, ok := result["not-an-ip"]; !ok || reachable {
+		t.Errorf("Expected invalid address to be reported unreachable, got %v", result)
+	}
+}
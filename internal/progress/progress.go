@@ -0,0 +1,42 @@
+// Package progress passes intermediate status of long running commands from
+// command handlers back to the client that sent the command.
+package progress
+
+import (
+	"context"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// Func receives progress reports of a command
+type Func func(models.CommandProgress)
+
+type reporterKey struct{}
+
+type reporter struct {
+	messageID string
+	report    Func
+}
+
+// WithReporter returns a context whose progress reports are passed to report,
+// tagged with the message ID of the originating command
+func WithReporter(ctx context.Context, messageID string, report Func) context.Context {
+	return context.WithValue(ctx, reporterKey{}, reporter{messageID: messageID, report: report})
+}
+
+// Report reports that the command handled with ctx reached a stage. Percent
+// is the overall completion from 0 to 100. Reports without a reporter are
+// discarded.
+func Report(ctx context.Context, stage string, percent int) {
+	r, ok := ctx.Value(reporterKey{}).(reporter)
+	if !ok {
+		return
+	}
+
+	percent = max(0, min(percent, 100))
+	r.report(models.CommandProgress{
+		MessageID: r.messageID,
+		Stage:     stage,
+		Percent:   percent,
+	})
+}
@@ -0,0 +1,36 @@
+package progress
+
+import (
+	"context"
+	"testing"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+func TestReport(t *testing.T) {
+	var reports []models.CommandProgress
+	ctx := WithReporter(context.Background(), "42", func(p models.CommandProgress) {
+		reports = append(reports, p)
+	})
+
+	Report(ctx, "reading", 10)
+	Report(ctx, "done", 150)
+
+	want := []models.CommandProgress{
+		{MessageID: "42", Stage: "reading", Percent: 10},
+		{MessageID: "42", Stage: "done", Percent: 100},
+	}
+	if len(reports) != len(want) {
+		t.Fatalf("Expected %d reports, got %d", len(want), len(reports))
+	}
+	for i := range want {
+		if reports[i] != want[i] {
+			t.Errorf("Expected %+v, got %+v", want[i], reports[i])
+		}
+	}
+}
+
+func TestReportWithoutReporter(t *testing.T) {
+	// Must not panic
+	Report(context.Background(), "reading", 10)
+}
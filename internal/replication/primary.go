@@ -0,0 +1,325 @@
+package replication
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/storage"
+)
+
+const (
+	// streamBufferSize is the number of changes queued for a standby. A
+	// standby falling further behind is disconnected and resyncs with a
+	// snapshot when it reconnects.
+	streamBufferSize = 1024
+
+	// writeTimeout bounds writing a message to a standby that stopped
+	// reading
+	writeTimeout = 30 * time.Second
+)
+
+// PrimaryConfig configures the primary side of replication
+type PrimaryConfig struct {
+	// Shared secret the standbys authenticate with
+	Token string
+	// Storage path the replicated files are relative to
+	BasePath string
+	// Files and directories replicated besides the storage, e.g. the
+	// fabric credentials
+	Files             []string
+	HeartbeatInterval time.Duration
+	Logger            *logger.Logger
+}
+
+// Primary wraps the storage of the primary server and streams the changes
+// made through it to the connected standbys
+type Primary struct {
+	storage.Storage
+
+	config PrimaryConfig
+	logger *logger.Logger
+
+	mu      sync.Mutex
+	streams map[*stream]struct{}
+	closed  bool
+	done    chan struct{}
+}
+
+// stream is a connected standby
+type stream struct {
+	// Encoded message lines, closed when the standby fell behind
+	messages chan []byte
+}
+
+// NewPrimary wraps the storage of the primary server
+func NewPrimary(inner storage.Storage, config PrimaryConfig) (*Primary, error) {
+	if config.Token == "" {
+		return nil, fmt.Errorf("replication requires a token")
+	}
+	if config.HeartbeatInterval <= 0 {
+		return nil, fmt.Errorf("invalid replication heartbeat interval: %s", config.HeartbeatInterval)
+	}
+	return &Primary{
+		Storage: inner,
+		config:  config,
+		logger:  config.Logger,
+		streams: make(map[*stream]struct{}),
+		done:    make(chan struct{}),
+	}, nil
+}
+
+// SaveNode stores a node and streams it to the standbys
+func (p *Primary) SaveNode(node *models.MatterNodeData) error {
+	if err := p.Storage.SaveNode(node); err != nil {
+		return err
+	}
+	p.publish(Message{Type: MessageNodeSaved, Node: node})
+	return nil
+}
+
+// SaveNodes stores several nodes and streams them to the standbys in one
+// message
+func (p *Primary) SaveNodes(nodes []*models.MatterNodeData) error {
+	if err := p.Storage.SaveNodes(nodes); err != nil {
+		return err
+	}
+	p.publish(Message{Type: MessageNodesSaved, Nodes: nodes})
+	return nil
+}
+
+// DeleteNode removes a node on the primary and the standbys
+func (p *Primary) DeleteNode(nodeID int) error {
+	if err := p.Storage.DeleteNode(nodeID); err != nil {
+		return err
+	}
+	p.publish(Message{Type: MessageNodeDeleted, NodeID: nodeID})
+	return nil
+}
+
+// SaveVendor stores a vendor and streams it to the standbys
+func (p *Primary) SaveVendor(vendor *models.VendorInfo) error {
+	if err := p.Storage.SaveVendor(vendor); err != nil {
+		return err
+	}
+	p.publish(Message{Type: MessageVendorSaved, Vendor: vendor})
+	return nil
+}
+
+// SaveVendors stores several vendors and streams them to the standbys in one
+// message
+func (p *Primary) SaveVendors(vendors []*models.VendorInfo) error {
+	if err := p.Storage.SaveVendors(vendors); err != nil {
+		return err
+	}
+	p.publish(Message{Type: MessageVendorsSaved, Vendors: vendors})
+	return nil
+}
+
+// SaveSetting stores a setting and streams it to the standbys
+func (p *Primary) SaveSetting(key string, value interface{}) error {
+	if err := p.Storage.SaveSetting(key, value); err != nil {
+		return err
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode setting %s: %w", key, err)
+	}
+	p.publish(Message{Type: MessageSettingSaved, Key: key, Value: data})
+	return nil
+}
+
+// DeleteSetting removes a setting on the primary and the standbys
+func (p *Primary) DeleteSetting(key string) error {
+	if err := p.Storage.DeleteSetting(key); err != nil {
+		return err
+	}
+	p.publish(Message{Type: MessageSettingDeleted, Key: key})
+	return nil
+}
+
+// publish queues a change for every standby, disconnecting standbys that
+// fell behind
+func (p *Primary) publish(msg Message) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		p.logger.Error("Failed to encode replication message",
+			logger.String("type", string(msg.Type)),
+			logger.ErrorField(err),
+		)
+		return
+	}
+	line := append(data, '\n')
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for st := range p.streams {
+		select {
+		case st.messages <- line:
+		default:
+			delete(p.streams, st)
+			close(st.messages)
+		}
+	}
+}
+
+// Standbys returns the number of connected standbys
+func (p *Primary) Standbys() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.streams)
+}
+
+// ServeHTTP streams the snapshot and the following changes to a standby
+func (p *Primary) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !authorized(r, p.config.Token) {
+		p.logger.Warn("Rejected replication request", logger.String("remote_addr", r.RemoteAddr))
+		http.Error(w, "invalid replication token", http.StatusUnauthorized)
+		return
+	}
+
+	// The snapshot is taken with publishing blocked, so no change made
+	// after it is missed. Changes made while it is read may be sent twice,
+	// which is harmless.
+	st := &stream{messages: make(chan []byte, streamBufferSize)}
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		http.Error(w, "server shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	snapshot, err := p.snapshot()
+	if err != nil {
+		p.mu.Unlock()
+		p.logger.Error("Failed to take replication snapshot", logger.ErrorField(err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	p.streams[st] = struct{}{}
+	p.mu.Unlock()
+	defer p.remove(st)
+
+	p.logger.Info("Standby connected",
+		logger.String("remote_addr", r.RemoteAddr),
+		logger.Int("nodes", len(snapshot.Nodes)),
+	)
+	defer p.logger.Info("Standby disconnected", logger.String("remote_addr", r.RemoteAddr))
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	// The stream outlives the read timeout of the HTTP server, which would
+	// cancel the request
+	rc := http.NewResponseController(w)
+	if err := rc.SetReadDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return
+	}
+	digest := filesDigest(snapshot.Files)
+	if err := p.send(w, rc, Message{Type: MessageSnapshot, Snapshot: snapshot}); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(p.config.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		msg := Message{Type: MessageHeartbeat}
+		select {
+		case line, ok := <-st.messages:
+			if !ok {
+				p.logger.Warn("Standby fell behind, disconnecting", logger.String("remote_addr", r.RemoteAddr))
+				return
+			}
+			if err := p.write(w, rc, line); err != nil {
+				return
+			}
+			continue
+		case <-ticker.C:
+			// The replicated files change rarely and not through the
+			// storage, so they are compared on every heartbeat
+			files, err := readFiles(p.config.BasePath, p.config.Files)
+			if err != nil {
+				p.logger.Error("Failed to read replicated files", logger.ErrorField(err))
+			} else if current := filesDigest(files); current != digest {
+				digest = current
+				msg = Message{Type: MessageFiles, Files: files}
+			}
+		case <-r.Context().Done():
+			return
+		case <-p.done:
+			return
+		}
+		if err := p.send(w, rc, msg); err != nil {
+			return
+		}
+	}
+}
+
+// snapshot reads the replicated state
+func (p *Primary) snapshot() (*Snapshot, error) {
+	nodes, err := p.Storage.GetNodes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read nodes: %w", err)
+	}
+	vendors, err := p.Storage.GetVendors()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vendors: %w", err)
+	}
+	settings, err := p.Storage.GetSettings()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read settings: %w", err)
+	}
+	files, err := readFiles(p.config.BasePath, p.config.Files)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read replicated files: %w", err)
+	}
+	return &Snapshot{Nodes: nodes, Vendors: vendors, Settings: settings, Files: files}, nil
+}
+
+func (p *Primary) send(w http.ResponseWriter, rc *http.ResponseController, msg Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		p.logger.Error("Failed to encode replication message",
+			logger.String("type", string(msg.Type)),
+			logger.ErrorField(err),
+		)
+		return err
+	}
+	return p.write(w, rc, append(data, '\n'))
+}
+
+// write sends a line and flushes it, giving up on standbys that don't read
+func (p *Primary) write(w http.ResponseWriter, rc *http.ResponseController, line []byte) error {
+	if err := rc.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	if _, err := w.Write(line); err != nil {
+		return err
+	}
+	return rc.Flush()
+}
+
+func (p *Primary) remove(st *stream) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.streams[st]; ok {
+		delete(p.streams, st)
+		close(st.messages)
+	}
+}
+
+// Shutdown ends the streams to the standbys, which must happen before the
+// HTTP server waits for its connections to close
+func (p *Primary) Shutdown() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.closed {
+		p.closed = true
+		close(p.done)
+	}
+}
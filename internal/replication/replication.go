@@ -0,0 +1,174 @@
+// Package replication mirrors the storage of a primary server to a standby.
+//
+// The standby opens a long-lived HTTP request to the primary, authenticated
+// with a shared token. The primary answers with a stream of JSON messages,
+// one per line: a snapshot of the nodes, vendors, settings and replicated
+// files first, then every change made to its storage and a heartbeat while
+// nothing changes. A standby that lost the primary for too long is promoted
+// and takes over.
+package replication
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// Path is the HTTP path of the replication stream on the primary
+const Path = "/replication"
+
+// MessageType identifies a message of the replication stream
+type MessageType string
+
+const (
+	MessageSnapshot       MessageType = "snapshot"
+	MessageNodeSaved      MessageType = "node_saved"
+	MessageNodesSaved     MessageType = "nodes_saved"
+	MessageNodeDeleted    MessageType = "node_deleted"
+	MessageVendorSaved    MessageType = "vendor_saved"
+	MessageVendorsSaved   MessageType = "vendors_saved"
+	MessageSettingSaved   MessageType = "setting_saved"
+	MessageSettingDeleted MessageType = "setting_deleted"
+	MessageFiles          MessageType = "files"
+	MessageHeartbeat      MessageType = "heartbeat"
+)
+
+// Message is a line of the replication stream
+type Message struct {
+	Type     MessageType            `json:"type"`
+	Snapshot *Snapshot              `json:"snapshot,omitempty"`
+	Node     *models.MatterNodeData `json:"node,omitempty"`
+	NodeID   int                    `json:"node_id,omitempty"`
+	Vendor   *models.VendorInfo     `json:"vendor,omitempty"`
+	// Nodes and vendors saved together
+	Nodes   []*models.MatterNodeData `json:"nodes,omitempty"`
+	Vendors []*models.VendorInfo     `json:"vendors,omitempty"`
+	Key     string                   `json:"key,omitempty"`
+	// Value of a setting, kept encoded so false and null survive
+	Value json.RawMessage `json:"value,omitempty"`
+	// Replicated files by path relative to the storage path
+	Files map[string][]byte `json:"files,omitempty"`
+}
+
+// Snapshot is the complete replicated state of the primary
+type Snapshot struct {
+	Nodes    []*models.MatterNodeData `json:"nodes"`
+	Vendors  []*models.VendorInfo     `json:"vendors"`
+	Settings map[string]interface{}   `json:"settings"`
+	Files    map[string][]byte        `json:"files"`
+}
+
+// authorized checks the bearer token of a request in constant time
+func authorized(r *http.Request, token string) bool {
+	given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+// readFiles reads the replicated files. Names are files or directories
+// relative to basePath, the regular files directly in a directory are read.
+// Missing files and temporary files of interrupted writes are left out.
+func readFiles(basePath string, names []string) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	for _, name := range names {
+		full := filepath.Join(basePath, filepath.FromSlash(name))
+		info, err := os.Stat(full)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			data, err := os.ReadFile(full)
+			if err != nil {
+				return nil, err
+			}
+			files[name] = data
+			continue
+		}
+
+		entries, err := os.ReadDir(full)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.Type().IsRegular() || strings.HasSuffix(entry.Name(), ".tmp") {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(full, entry.Name()))
+			if err != nil {
+				return nil, err
+			}
+			files[name+"/"+entry.Name()] = data
+		}
+	}
+	return files, nil
+}
+
+// filesDigest identifies the contents of the replicated files
+func filesDigest(files map[string][]byte) [sha256.Size]byte {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s\x00%d\x00", name, len(files[name]))
+		h.Write(files[name])
+	}
+	var digest [sha256.Size]byte
+	copy(digest[:], h.Sum(nil))
+	return digest
+}
+
+// writeFiles stores replicated files, replacing each atomically. Only names
+// readFiles could have produced are accepted, so a primary can't write
+// outside the replicated files.
+func writeFiles(basePath string, names []string, files map[string][]byte) error {
+	for name, data := range files {
+		if !replicatedFile(names, name) {
+			return fmt.Errorf("file %q is not replicated", name)
+		}
+
+		full := filepath.Join(basePath, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(full), 0700); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", name, err)
+		}
+		tmpPath := full + ".tmp"
+		if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		if err := os.Rename(tmpPath, full); err != nil {
+			os.Remove(tmpPath)
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// replicatedFile reports whether name is one of names or a file directly in
+// one of them
+func replicatedFile(names []string, name string) bool {
+	if path.Clean(name) != name || path.IsAbs(name) || strings.HasSuffix(name, ".tmp") {
+		return false
+	}
+	dir, base := path.Split(name)
+	for _, allowed := range names {
+		if name == allowed || (strings.TrimSuffix(dir, "/") == allowed && base != ".." && base != ".") {
+			return true
+		}
+	}
+	return false
+}
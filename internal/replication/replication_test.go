@@ -0,0 +1,290 @@
+package replication
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/storage"
+)
+
+const testToken = "0123456789abcdef"
+
+var testFiles = []string{"credentials", "groups.json"}
+
+func newTestStorage(t *testing.T) (*storage.JSONStorage, string) {
+	t.Helper()
+	dir := t.TempDir()
+	s := storage.NewJSONStorage(dir, logger.NewConsoleLogger(logger.FatalLevel))
+	if err := s.Start(); err != nil {
+		t.Fatalf("Failed to start storage: %v", err)
+	}
+	t.Cleanup(func() { s.Stop() })
+	return s, dir
+}
+
+func newTestPrimary(t *testing.T) (*Primary, string, *httptest.Server) {
+	t.Helper()
+	inner, dir := newTestStorage(t)
+	primary, err := NewPrimary(inner, PrimaryConfig{
+		Token:             testToken,
+		BasePath:          dir,
+		Files:             testFiles,
+		HeartbeatInterval: 20 * time.Millisecond,
+		Logger:            logger.NewConsoleLogger(logger.FatalLevel),
+	})
+	if err != nil {
+		t.Fatalf("NewPrimary failed: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle(Path, primary)
+	// Streams outlive the timeouts of the server
+	server := httptest.NewUnstartedServer(mux)
+	server.Config.ReadTimeout = 100 * time.Millisecond
+	server.Config.WriteTimeout = 100 * time.Millisecond
+	// Standbys going away abort TLS handshakes
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	t.Cleanup(func() {
+		primary.Shutdown()
+		server.Close()
+	})
+	return primary, dir, server
+}
+
+// testStandby records the messages it applied
+type testStandby struct {
+	*Standby
+	store    *storage.JSONStorage
+	dir      string
+	applied  chan Message
+	promoted chan struct{}
+}
+
+// newTestStandby creates a standby of the primary served by server
+func newTestStandby(t *testing.T, server *httptest.Server, promoteAfter time.Duration) *testStandby {
+	t.Helper()
+	store, dir := newTestStorage(t)
+	ts := &testStandby{
+		store:    store,
+		dir:      dir,
+		applied:  make(chan Message, 100),
+		promoted: make(chan struct{}),
+	}
+	var err error
+	ts.Standby, err = NewStandby(StandbyConfig{
+		PrimaryURL:        server.URL,
+		Token:             testToken,
+		Storage:           store,
+		BasePath:          dir,
+		Files:             testFiles,
+		HeartbeatInterval: 20 * time.Millisecond,
+		PromoteAfter:      promoteAfter,
+		Applied:           func(msg Message) { ts.applied <- msg },
+		Promoted:          func(ctx context.Context) { close(ts.promoted) },
+		Logger:            logger.NewConsoleLogger(logger.FatalLevel),
+	})
+	if err != nil {
+		t.Fatalf("NewStandby failed: %v", err)
+	}
+	// Trusts the certificate of the test server
+	ts.client = server.Client()
+	t.Cleanup(ts.Shutdown)
+	return ts
+}
+
+// waitFor waits until a message of the type was applied
+func (ts *testStandby) waitFor(t *testing.T, msgType MessageType) Message {
+	t.Helper()
+	deadline := time.After(2 * time.Second)
+	for {
+		select {
+		case msg := <-ts.applied:
+			if msg.Type == msgType {
+				return msg
+			}
+		case <-deadline:
+			t.Fatalf("Timed out waiting for %s", msgType)
+		}
+	}
+}
+
+func TestReplication(t *testing.T) {
+	primary, primaryDir, server := newTestPrimary(t)
+	if err := primary.SaveNode(&models.MatterNodeData{NodeID: 1, Available: true}); err != nil {
+		t.Fatalf("SaveNode failed: %v", err)
+	}
+	if err := primary.SaveSetting("fabric_label", "Home"); err != nil {
+		t.Fatalf("SaveSetting failed: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(primaryDir, "credentials"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(primaryDir, "credentials", "rcac.pem"), []byte("root"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	standby := newTestStandby(t, server, 0)
+	// Mirrored state replaces what the standby had
+	standby.store.SaveNode(&models.MatterNodeData{NodeID: 99})
+	standby.store.SaveSetting("stale", true)
+	standby.Start(context.Background())
+
+	standby.waitFor(t, MessageSnapshot)
+	if nodes, _ := standby.store.GetNodes(); len(nodes) != 1 || nodes[0].NodeID != 1 || !nodes[0].Available {
+		t.Errorf("Expected node 1 only, got %+v", nodes)
+	}
+	if settings, _ := standby.store.GetSettings(); len(settings) != 1 || settings["fabric_label"] != "Home" {
+		t.Errorf("Expected the primary's settings, got %v", settings)
+	}
+	if data, err := os.ReadFile(filepath.Join(standby.dir, "credentials", "rcac.pem")); err != nil || string(data) != "root" {
+		t.Errorf("Expected the credentials to be copied, got %q, %v", data, err)
+	}
+	if status := standby.Status(); !status.Connected || !status.Synced || status.Promoted {
+		t.Errorf("Unexpected status %+v", status)
+	}
+	if primary.Standbys() != 1 {
+		t.Errorf("Expected one standby, got %d", primary.Standbys())
+	}
+
+	// Changes follow in order
+	primary.SaveNode(&models.MatterNodeData{NodeID: 2})
+	primary.DeleteNode(1)
+	primary.SaveVendors([]*models.VendorInfo{{VendorID: 0xFFF1}, {VendorID: 0xFFF2}})
+	primary.SaveSetting("enabled", false)
+	primary.DeleteSetting("fabric_label")
+	standby.waitFor(t, MessageSettingDeleted)
+	if nodes, _ := standby.store.GetNodes(); len(nodes) != 1 || nodes[0].NodeID != 2 {
+		t.Errorf("Expected node 2 only, got %+v", nodes)
+	}
+	if vendors, _ := standby.store.GetVendors(); len(vendors) != 2 {
+		t.Errorf("Expected the saved vendors, got %+v", vendors)
+	}
+	if settings, _ := standby.store.GetSettings(); len(settings) != 1 || settings["enabled"] != false {
+		t.Errorf("Expected the false setting to be kept, got %v", settings)
+	}
+
+	// Files are compared on heartbeats
+	time.Sleep(200 * time.Millisecond)
+	os.WriteFile(filepath.Join(primaryDir, "groups.json"), []byte("[]"), 0600)
+	standby.waitFor(t, MessageFiles)
+	if data, err := os.ReadFile(filepath.Join(standby.dir, "groups.json")); err != nil || string(data) != "[]" {
+		t.Errorf("Expected the groups to be copied, got %q, %v", data, err)
+	}
+}
+
+func TestPrimaryRejectsToken(t *testing.T) {
+	_, _, server := newTestPrimary(t)
+
+	for _, auth := range []string{"", "Bearer wrong", testToken} {
+		req, _ := http.NewRequest("GET", server.URL+Path, nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		resp, err := server.Client().Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for %q, got %d", auth, resp.StatusCode)
+		}
+	}
+}
+
+func TestStandbyRequiresTLS(t *testing.T) {
+	store, dir := newTestStorage(t)
+	config := StandbyConfig{
+		PrimaryURL:        "http://hub1:5580",
+		Token:             testToken,
+		Storage:           store,
+		BasePath:          dir,
+		HeartbeatInterval: time.Second,
+		Logger:            logger.NewConsoleLogger(logger.FatalLevel),
+	}
+	if _, err := NewStandby(config); err == nil {
+		t.Error("Expected a plain http primary URL to be refused")
+	}
+	config.AllowPlaintext = true
+	if _, err := NewStandby(config); err != nil {
+		t.Errorf("Expected a plain http primary URL to be allowed, got %v", err)
+	}
+}
+
+func TestStandbyPromotion(t *testing.T) {
+	retry := minRetry
+	minRetry = 10 * time.Millisecond
+	t.Cleanup(func() { minRetry = retry })
+
+	primary, _, server := newTestPrimary(t)
+	primary.SaveNode(&models.MatterNodeData{NodeID: 1})
+
+	standby := newTestStandby(t, server, 200*time.Millisecond)
+	standby.Start(context.Background())
+	standby.waitFor(t, MessageSnapshot)
+
+	// The primary disappears
+	primary.Shutdown()
+	server.Close()
+
+	select {
+	case <-standby.promoted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the standby to promote itself")
+	}
+	if status := standby.Status(); !status.Promoted || status.Connected {
+		t.Errorf("Unexpected status %+v", status)
+	}
+	if standby.Promote() {
+		t.Error("Expected a second promotion to be refused")
+	}
+	if nodes, _ := standby.store.GetNodes(); len(nodes) != 1 {
+		t.Errorf("Expected the mirrored node to be kept, got %+v", nodes)
+	}
+}
+
+func TestStandbyNotPromotedWithoutSnapshot(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	server.Close()
+
+	standby := newTestStandby(t, server, 60*time.Millisecond)
+	standby.Start(context.Background())
+	time.Sleep(200 * time.Millisecond)
+	if standby.Promoted() {
+		t.Fatal("Expected a standby that never synced not to promote itself")
+	}
+
+	// Promoting by hand is always possible
+	go standby.Promote()
+	select {
+	case <-standby.promoted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the manual promotion to complete")
+	}
+}
+
+func TestReplicatedFile(t *testing.T) {
+	tests := map[string]bool{
+		"groups.json":               true,
+		"credentials/rcac.pem":      true,
+		"credentials/../nodes.json": false,
+		"../groups.json":            false,
+		"/etc/passwd":               false,
+		"credentials/sub/key.pem":   false,
+		"credentials/rcac.pem.tmp":  false,
+		"settings.json":             false,
+	}
+	for name, expected := range tests {
+		if replicatedFile(testFiles, name) != expected {
+			t.Errorf("%s: expected %v", name, expected)
+		}
+	}
+}
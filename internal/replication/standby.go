@@ -0,0 +1,427 @@
+package replication
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/storage"
+)
+
+// Reconnect backoff, variables so tests can shorten them
+var (
+	minRetry = time.Second
+	maxRetry = 30 * time.Second
+)
+
+// staleHeartbeats is the number of heartbeat intervals without a message
+// after which the connection to the primary is considered dead
+const staleHeartbeats = 3
+
+// StandbyConfig configures the standby side of replication
+type StandbyConfig struct {
+	// Base URL of the primary's HTTP server, e.g. https://hub1:5580
+	PrimaryURL string
+	Token      string
+	// Storage the primary's state is mirrored to
+	Storage storage.Storage
+	// Storage path the replicated files are written to
+	BasePath string
+	// Files and directories accepted from the primary
+	Files             []string
+	HeartbeatInterval time.Duration
+	// The standby promotes itself after losing the primary for this long.
+	// 0 disables automatic promotion.
+	PromoteAfter time.Duration
+	// Whether PrimaryURL may be a plain http URL, sending the token and the
+	// replicated files unencrypted
+	AllowPlaintext bool
+	// Applied is called after a message of the primary was stored
+	Applied func(msg Message)
+	// Promoted is called once the standby stopped mirroring on promotion,
+	// with the context the standby was started with
+	Promoted func(ctx context.Context)
+	Logger   *logger.Logger
+}
+
+// Standby mirrors the storage of the primary until it is promoted
+type Standby struct {
+	config StandbyConfig
+	url    string
+	client *http.Client
+	logger *logger.Logger
+
+	mu          sync.Mutex
+	parent      context.Context
+	ctx         context.Context
+	cancel      context.CancelFunc
+	mirrorDone  chan struct{}
+	connected   bool
+	synced      bool
+	lastContact time.Time
+	promoted    bool
+	stopped     bool
+
+	wg sync.WaitGroup
+}
+
+// NewStandby creates a standby of the primary at config.PrimaryURL
+func NewStandby(config StandbyConfig) (*Standby, error) {
+	u, err := url.Parse(config.PrimaryURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid primary URL %q: expected an http or https URL", config.PrimaryURL)
+	}
+	if u.Scheme == "http" && !config.AllowPlaintext {
+		return nil, fmt.Errorf("primary URL %q would send the replicated credentials unencrypted: expected an https URL", config.PrimaryURL)
+	}
+	if config.Token == "" {
+		return nil, fmt.Errorf("replication requires a token")
+	}
+	if config.HeartbeatInterval <= 0 {
+		return nil, fmt.Errorf("invalid replication heartbeat interval: %s", config.HeartbeatInterval)
+	}
+
+	return &Standby{
+		config: config,
+		url:    strings.TrimSuffix(config.PrimaryURL, "/") + Path,
+		client: &http.Client{},
+		logger: config.Logger,
+	}, nil
+}
+
+// Start connects to the primary and mirrors its storage until the standby is
+// promoted or shut down
+func (s *Standby) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped || s.promoted {
+		return
+	}
+
+	s.parent = ctx
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	s.mirrorDone = make(chan struct{})
+	s.lastContact = time.Now()
+
+	s.wg.Add(1)
+	go s.run()
+	if s.config.PromoteAfter > 0 {
+		s.wg.Add(1)
+		go s.watch()
+	}
+}
+
+// run reconnects to the primary until mirroring stops
+func (s *Standby) run() {
+	defer s.wg.Done()
+	defer close(s.mirrorDone)
+
+	retry := minRetry
+	for {
+		received, err := s.mirror()
+		s.mu.Lock()
+		s.connected = false
+		s.mu.Unlock()
+		if s.ctx.Err() != nil {
+			return
+		}
+
+		if received {
+			retry = minRetry
+		}
+		s.logger.Warn("Lost connection to the primary",
+			logger.String("primary", s.config.PrimaryURL),
+			logger.Duration("retry", retry),
+			logger.ErrorField(err),
+		)
+
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-time.After(retry):
+		}
+		retry = min(2*retry, maxRetry)
+	}
+}
+
+// mirror applies the stream of the primary until it ends, reporting whether
+// any message was received
+func (s *Standby) mirror() (bool, error) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+	// A primary that went away without closing the connection sends no
+	// heartbeats
+	staleAfter := staleHeartbeats * s.config.HeartbeatInterval
+	idle := time.AfterFunc(staleAfter, cancel)
+	defer idle.Stop()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.config.Token)
+	req.Header.Set("Accept", "application/x-ndjson")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return false, fmt.Errorf("primary answered %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	s.mu.Lock()
+	s.connected = true
+	s.mu.Unlock()
+
+	received := false
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var msg Message
+		if err := decoder.Decode(&msg); err != nil {
+			if ctx.Err() != nil && s.ctx.Err() == nil {
+				return received, fmt.Errorf("no heartbeat for %s", staleAfter)
+			}
+			return received, err
+		}
+		idle.Reset(staleAfter)
+		received = true
+
+		if err := s.apply(msg); err != nil {
+			return received, fmt.Errorf("failed to apply %s: %w", msg.Type, err)
+		}
+
+		s.mu.Lock()
+		s.lastContact = time.Now()
+		if msg.Type == MessageSnapshot {
+			s.synced = true
+		}
+		s.mu.Unlock()
+
+		if msg.Type != MessageHeartbeat && s.config.Applied != nil {
+			s.config.Applied(msg)
+		}
+	}
+}
+
+// apply stores a message of the primary
+func (s *Standby) apply(msg Message) error {
+	store := s.config.Storage
+	switch msg.Type {
+	case MessageSnapshot:
+		if msg.Snapshot == nil {
+			return errors.New("missing snapshot")
+		}
+		return s.applySnapshot(msg.Snapshot)
+	case MessageNodeSaved:
+		if msg.Node == nil || msg.Node.NodeID <= 0 {
+			return errors.New("missing node")
+		}
+		return store.SaveNode(msg.Node)
+	case MessageNodesSaved:
+		for _, node := range msg.Nodes {
+			if node == nil || node.NodeID <= 0 {
+				return errors.New("missing node")
+			}
+		}
+		return store.SaveNodes(msg.Nodes)
+	case MessageNodeDeleted:
+		return store.DeleteNode(msg.NodeID)
+	case MessageVendorSaved:
+		if msg.Vendor == nil {
+			return errors.New("missing vendor")
+		}
+		return store.SaveVendor(msg.Vendor)
+	case MessageVendorsSaved:
+		for _, vendor := range msg.Vendors {
+			if vendor == nil {
+				return errors.New("missing vendor")
+			}
+		}
+		return store.SaveVendors(msg.Vendors)
+	case MessageSettingSaved:
+		var value interface{}
+		if err := json.Unmarshal(msg.Value, &value); err != nil {
+			return fmt.Errorf("invalid value of setting %s: %w", msg.Key, err)
+		}
+		return store.SaveSetting(msg.Key, value)
+	case MessageSettingDeleted:
+		return store.DeleteSetting(msg.Key)
+	case MessageFiles:
+		return writeFiles(s.config.BasePath, s.config.Files, msg.Files)
+	case MessageHeartbeat:
+		return nil
+	default:
+		// Sent by a newer primary
+		s.logger.Debug("Ignoring unknown replication message", logger.String("type", string(msg.Type)))
+		return nil
+	}
+}
+
+// applySnapshot replaces the mirrored state with the primary's
+func (s *Standby) applySnapshot(snapshot *Snapshot) error {
+	store := s.config.Storage
+
+	current, err := store.GetNodes()
+	if err != nil {
+		return err
+	}
+	keep := make(map[int]bool, len(snapshot.Nodes))
+	for _, node := range snapshot.Nodes {
+		if node == nil || node.NodeID <= 0 {
+			return errors.New("snapshot node without node_id")
+		}
+		keep[node.NodeID] = true
+	}
+	if err := store.SaveNodes(snapshot.Nodes); err != nil {
+		return err
+	}
+	for _, node := range current {
+		if !keep[node.NodeID] {
+			if err := store.DeleteNode(node.NodeID); err != nil {
+				return err
+			}
+		}
+	}
+
+	vendors := make([]*models.VendorInfo, 0, len(snapshot.Vendors))
+	for _, vendor := range snapshot.Vendors {
+		if vendor != nil {
+			vendors = append(vendors, vendor)
+		}
+	}
+	if err := store.SaveVendors(vendors); err != nil {
+		return err
+	}
+
+	settings, err := store.GetSettings()
+	if err != nil {
+		return err
+	}
+	for key, value := range snapshot.Settings {
+		if err := store.SaveSetting(key, value); err != nil {
+			return err
+		}
+	}
+	for key := range settings {
+		if _, ok := snapshot.Settings[key]; !ok {
+			if err := store.DeleteSetting(key); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := writeFiles(s.config.BasePath, s.config.Files, snapshot.Files); err != nil {
+		return err
+	}
+	return store.Sync()
+}
+
+// watch promotes the standby once the primary was gone for PromoteAfter.
+// A standby that never received a snapshot isn't promoted, it would take
+// over without the primary's nodes.
+func (s *Standby) watch() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(min(s.config.HeartbeatInterval, time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		s.mu.Lock()
+		gone := time.Since(s.lastContact)
+		due := s.synced && gone >= s.config.PromoteAfter
+		s.mu.Unlock()
+		if due {
+			s.logger.Warn("Primary unreachable, promoting the standby",
+				logger.String("primary", s.config.PrimaryURL),
+				logger.Duration("unreachable_for", gone),
+			)
+			s.Promote()
+			return
+		}
+	}
+}
+
+// Promote stops mirroring and hands over to the server. It reports false
+// if the standby was already promoted or shut down.
+func (s *Standby) Promote() bool {
+	s.mu.Lock()
+	if s.promoted || s.stopped {
+		s.mu.Unlock()
+		return false
+	}
+	s.promoted = true
+	parent, cancel, mirrorDone := s.parent, s.cancel, s.mirrorDone
+	s.wg.Add(1)
+	s.mu.Unlock()
+	defer s.wg.Done()
+
+	if cancel != nil {
+		cancel()
+		<-mirrorDone
+	}
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	s.logger.Warn("Standby promoted, taking over from the primary")
+	if s.config.Promoted != nil {
+		s.config.Promoted(parent)
+	}
+	return true
+}
+
+// Promoted reports whether the standby took over
+func (s *Standby) Promoted() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.promoted
+}
+
+// Status returns the state of the connection to the primary
+func (s *Standby) Status() models.ReplicationStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := models.ReplicationStatus{
+		Role:      "standby",
+		Primary:   s.config.PrimaryURL,
+		Connected: s.connected,
+		Synced:    s.synced,
+		Promoted:  s.promoted,
+	}
+	if !s.lastContact.IsZero() {
+		lastContact := s.lastContact.UTC()
+		status.LastContact = &lastContact
+	}
+	return status
+}
+
+// Shutdown stops mirroring and waits for a promotion in progress
+func (s *Standby) Shutdown() {
+	s.mu.Lock()
+	s.stopped = true
+	cancel := s.cancel
+	s.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	s.wg.Wait()
+}
@@ -0,0 +1,236 @@
+// Package scenes manages virtual scenes: server-side scenes that recall the
+// scenes stored on several node endpoints at once, persisted in the server
+// storage directory.
+package scenes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/storage"
+)
+
+const (
+	scenesFile = "scenes.json"
+
+	// Virtual scene IDs are valid device scene IDs, so members default to
+	// the ID of their virtual scene
+	minSceneID = 0x01
+	maxSceneID = 0xFE
+)
+
+var (
+	// ErrSceneNotFound is returned for unknown scene IDs
+	ErrSceneNotFound = errors.New("scene not found")
+
+	// ErrNoFreeSceneID is returned when all scene IDs are in use
+	ErrNoFreeSceneID = errors.New("no free scene ID")
+)
+
+// Member is a scene stored on a node endpoint, in the scene table of GroupID
+// (0 for scenes not bound to a group)
+type Member struct {
+	NodeID     int    `json:"node_id"`
+	EndpointID uint16 `json:"endpoint_id"`
+	GroupID    uint16 `json:"group_id"`
+	SceneID    uint8  `json:"scene_id"`
+}
+
+// Scene is a virtual scene recalling the scenes of its members
+type Scene struct {
+	SceneID uint8    `json:"scene_id"`
+	Name    string   `json:"name"`
+	Members []Member `json:"members"`
+}
+
+// Manager holds all virtual scenes and persists them to disk
+type Manager struct {
+	path   string
+	logger *logger.Logger
+	mu     sync.RWMutex
+	scenes map[uint8]*Scene
+
+	// Encrypts the persisted scenes, nil stores them unencrypted
+	cipher *storage.Cipher
+}
+
+// NewManager creates a scene manager storing its state in basePath
+func NewManager(basePath string, log *logger.Logger) *Manager {
+	return &Manager{
+		path:   filepath.Join(basePath, scenesFile),
+		logger: log,
+		scenes: make(map[uint8]*Scene),
+	}
+}
+
+// SetCipher encrypts the persisted scenes. Must be called before Load.
+func (m *Manager) SetCipher(c *storage.Cipher) {
+	m.cipher = c
+}
+
+// Load reads the persisted scenes. A missing file is not an error.
+func (m *Manager) Load() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, err := m.cipher.ReadFile(m.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read scenes: %w", err)
+	}
+
+	var scenes []*Scene
+	if err := json.Unmarshal(data, &scenes); err != nil {
+		return fmt.Errorf("failed to parse scenes: %w", err)
+	}
+
+	// Loading again replaces the scenes, e.g. with ones replicated from a
+	// primary server
+	m.scenes = make(map[uint8]*Scene, len(scenes))
+	for _, scene := range scenes {
+		m.scenes[scene.SceneID] = scene
+	}
+
+	m.logger.Info("Loaded scenes", logger.Int("count", len(scenes)))
+	return nil
+}
+
+// Create creates a virtual scene. A sceneID of zero allocates the lowest free
+// scene ID, and members without a scene ID use the one of the scene.
+func (m *Manager) Create(sceneID uint8, name string, members []Member) (*Scene, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if sceneID == 0 {
+		for id := minSceneID; id <= maxSceneID; id++ {
+			if _, exists := m.scenes[uint8(id)]; !exists {
+				sceneID = uint8(id)
+				break
+			}
+		}
+		if sceneID == 0 {
+			return nil, ErrNoFreeSceneID
+		}
+	} else if sceneID > maxSceneID {
+		return nil, fmt.Errorf("invalid scene ID 0x%02X", sceneID)
+	} else if _, exists := m.scenes[sceneID]; exists {
+		return nil, fmt.Errorf("scene %d already exists", sceneID)
+	}
+
+	scene := &Scene{SceneID: sceneID, Name: name, Members: make([]Member, 0, len(members))}
+	for _, member := range members {
+		if member.SceneID == 0 {
+			member.SceneID = sceneID
+		}
+		if !scene.hasMember(member) {
+			scene.Members = append(scene.Members, member)
+		}
+	}
+	m.scenes[sceneID] = scene
+
+	if err := m.save(); err != nil {
+		delete(m.scenes, sceneID)
+		return nil, err
+	}
+
+	return scene.copy(), nil
+}
+
+// Remove deletes a scene
+func (m *Manager) Remove(sceneID uint8) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	scene, exists := m.scenes[sceneID]
+	if !exists {
+		return ErrSceneNotFound
+	}
+
+	delete(m.scenes, sceneID)
+	if err := m.save(); err != nil {
+		m.scenes[sceneID] = scene
+		return err
+	}
+	return nil
+}
+
+// Get returns a copy of a scene
+func (m *Manager) Get(sceneID uint8) (*Scene, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	scene, exists := m.scenes[sceneID]
+	if !exists {
+		return nil, ErrSceneNotFound
+	}
+	return scene.copy(), nil
+}
+
+// List returns copies of all scenes ordered by scene ID
+func (m *Manager) List() []*Scene {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	scenes := make([]*Scene, 0, len(m.scenes))
+	for _, scene := range m.scenes {
+		scenes = append(scenes, scene.copy())
+	}
+	sort.Slice(scenes, func(i, j int) bool {
+		return scenes[i].SceneID < scenes[j].SceneID
+	})
+	return scenes
+}
+
+func (m *Manager) save() error {
+	scenes := make([]*Scene, 0, len(m.scenes))
+	for _, scene := range m.scenes {
+		scenes = append(scenes, scene)
+	}
+	sort.Slice(scenes, func(i, j int) bool {
+		return scenes[i].SceneID < scenes[j].SceneID
+	})
+
+	data, err := json.MarshalIndent(scenes, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal scenes: %w", err)
+	}
+	if data, err = m.cipher.Seal(data); err != nil {
+		return fmt.Errorf("failed to encrypt scenes: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(m.path), 0755); err != nil {
+		return fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	tmpPath := m.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write scenes: %w", err)
+	}
+	if err := os.Rename(tmpPath, m.path); err != nil {
+		return fmt.Errorf("failed to write scenes: %w", err)
+	}
+	return nil
+}
+
+func (s *Scene) hasMember(member Member) bool {
+	for _, existing := range s.Members {
+		if existing == member {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Scene) copy() *Scene {
+	c := *s
+	c.Members = append([]Member{}, s.Members...)
+	return &c
+}
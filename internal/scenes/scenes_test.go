@@ -0,0 +1,82 @@
+package scenes
+
+import (
+	"testing"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+)
+
+func newTestManager(t *testing.T, dir string) *Manager {
+	m := NewManager(dir, logger.NewConsoleLogger(logger.FatalLevel))
+	if err := m.Load(); err != nil {
+		t.Fatalf("Failed to load scenes: %v", err)
+	}
+	return m
+}
+
+func TestCreateScene(t *testing.T) {
+	m := newTestManager(t, t.TempDir())
+
+	scene, err := m.Create(0, "Evening", []Member{
+		{NodeID: 5, EndpointID: 1},
+		{NodeID: 5, EndpointID: 1},
+		{NodeID: 6, EndpointID: 2, GroupID: 0x0100, SceneID: 7},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create scene: %v", err)
+	}
+	if scene.SceneID != minSceneID {
+		t.Errorf("Expected first scene ID %d, got %d", minSceneID, scene.SceneID)
+	}
+	expected := []Member{{NodeID: 5, EndpointID: 1, SceneID: minSceneID}, {NodeID: 6, EndpointID: 2, GroupID: 0x0100, SceneID: 7}}
+	if len(scene.Members) != 2 || scene.Members[0] != expected[0] || scene.Members[1] != expected[1] {
+		t.Errorf("Expected members %v, got %v", expected, scene.Members)
+	}
+
+	if next, err := m.Create(0, "Morning", nil); err != nil || next.SceneID != minSceneID+1 {
+		t.Errorf("Expected next free scene ID, got %v (%v)", next, err)
+	}
+	if _, err := m.Create(scene.SceneID, "Duplicate", nil); err == nil {
+		t.Error("Expected error for existing scene ID")
+	}
+	if _, err := m.Create(0xFF, "Invalid", nil); err == nil {
+		t.Error("Expected error for an invalid scene ID")
+	}
+}
+
+func TestScenePersistence(t *testing.T) {
+	dir := t.TempDir()
+	m := newTestManager(t, dir)
+
+	if _, err := m.Create(0x10, "Movie", []Member{{NodeID: 5, EndpointID: 1}}); err != nil {
+		t.Fatalf("Failed to create scene: %v", err)
+	}
+
+	reloaded := newTestManager(t, dir)
+	scene, err := reloaded.Get(0x10)
+	if err != nil {
+		t.Fatalf("Expected scene after reload: %v", err)
+	}
+	if scene.Name != "Movie" || len(scene.Members) != 1 || scene.Members[0].SceneID != 0x10 {
+		t.Errorf("Expected the scene to be persisted, got %+v", scene)
+	}
+
+	// Get returns copies
+	scene.Members[0].NodeID = 99
+	if again, _ := reloaded.Get(0x10); again.Members[0].NodeID != 5 {
+		t.Error("Expected Get to return independent copies")
+	}
+
+	if err := reloaded.Remove(0x10); err != nil {
+		t.Fatalf("Failed to remove scene: %v", err)
+	}
+	if _, err := reloaded.Get(0x10); err != ErrSceneNotFound {
+		t.Errorf("Expected ErrSceneNotFound, got %v", err)
+	}
+	if err := reloaded.Remove(0x10); err != ErrSceneNotFound {
+		t.Errorf("Expected ErrSceneNotFound, got %v", err)
+	}
+	if scenes := newTestManager(t, dir).List(); len(scenes) != 0 {
+		t.Errorf("Expected the removal to be persisted, got %v", scenes)
+	}
+}
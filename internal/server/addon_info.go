@@ -0,0 +1,52 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/codefionn/go-matter-server/internal/mdns"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// features returns the optional capabilities of the server. Bluetooth counts
+// as supported when adapters are configured, whether or not one is present
+// right now; server_info reports the present adapters.
+func (s *Server) features() models.ServerFeatures {
+	features := models.ServerFeatures{
+		Bluetooth: s.bluetoothManager != nil && s.bluetoothManager.IsEnabled(),
+		Thread:    s.serverInfo.ThreadCredentialsSet,
+	}
+	if setting, ok := lookupServerSetting("ota.provider_dir"); ok {
+		features.OTA = s.serverSettingState(setting).Value != ""
+	}
+	return features
+}
+
+// mdnsFeatures returns the features advertised in the TXT record of the
+// server's mDNS service
+func (s *Server) mdnsFeatures() mdns.ServerFeatures {
+	features := s.features()
+	return mdns.ServerFeatures{
+		Bluetooth: features.Bluetooth,
+		Thread:    features.Thread,
+		OTA:       features.OTA,
+	}
+}
+
+// addonInfo returns the connection details and capabilities of the server
+func (s *Server) addonInfo() models.AddonInfo {
+	return models.AddonInfo{
+		SchemaVersion:             models.SchemaVersion,
+		MinSupportedSchemaVersion: models.MinSupportedSchemaVersion,
+		SDKVersion:                s.serverInfo.SDKVersion,
+		WebSocketPath:             mdns.ServerPath,
+		Port:                      s.config.Server.Port,
+		GRPCPort:                  s.config.Server.GRPCPort,
+		// The server itself speaks plain HTTP
+		TLS:      false,
+		Features: s.features(),
+	}
+}
+
+func (s *Server) handleAddonInfoHTTP(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, s.addonInfo())
+}
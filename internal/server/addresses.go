@@ -0,0 +1,139 @@
+package server
+
+import (
+	"encoding/base64"
+	"net"
+	"sort"
+
+	"github.com/codefionn/go-matter-server/internal/clusters"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// General Diagnostics NetworkInterface struct field tags
+const (
+	networkInterfaceIPv4Addresses = "5"
+	networkInterfaceIPv6Addresses = "6"
+)
+
+// ResolveNode implements controller.Resolver with the operational instances
+// the mDNS browser found on the fabric of the node
+func (s *Server) ResolveNode(nodeID int) []*net.UDPAddr {
+	fabric, ok := s.nodeFabric(nodeID)
+	if !ok {
+		return nil
+	}
+	return s.resolveOperational(fabric, nodeID)
+}
+
+// resolveOperational looks up the operational instance of a node on a fabric
+func (s *Server) resolveOperational(fabric *operatedFabric, nodeID int) []*net.UDPAddr {
+	if s.mdnsServer == nil {
+		return nil
+	}
+	instance, ok := s.mdnsServer.Cache().ResolveNode(fabric.credentials.CompressedFabricID(), uint64(nodeID))
+	if !ok {
+		return nil
+	}
+
+	addrs := make([]*net.UDPAddr, 0, len(instance.Addresses))
+	for _, ip := range instance.Addresses {
+		addr := &net.UDPAddr{IP: ip, Port: int(instance.Port)}
+		if ip.To4() == nil && ip.IsLinkLocalUnicast() {
+			addr.Zone = s.primaryInterface()
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+// nodeAddresses returns the addresses a node advertises via mDNS, followed
+// by the other addresses it reported in its General Diagnostics
+func (s *Server) nodeAddresses(node *models.MatterNodeData) []net.IP {
+	seen := make(map[string]bool)
+	var addrs []net.IP
+	add := func(ip net.IP) {
+		if !seen[ip.String()] {
+			seen[ip.String()] = true
+			addrs = append(addrs, ip)
+		}
+	}
+
+	var resolved []*net.UDPAddr
+	if fabric, ok := s.fabric(node.FabricID); ok {
+		resolved = s.resolveOperational(fabric, node.NodeID)
+	}
+	sort.Slice(resolved, func(i, j int) bool {
+		return resolved[i].IP.String() < resolved[j].IP.String()
+	})
+	for _, addr := range resolved {
+		add(addr.IP)
+	}
+	for _, ip := range nodeIPAddresses(node) {
+		add(ip)
+	}
+	return addrs
+}
+
+// nodeIPAddresses returns the IP addresses a node reported in its General
+// Diagnostics NetworkInterfaces attribute
+func nodeIPAddresses(node *models.MatterNodeData) []net.IP {
+	interfaces, _ := node.Attributes[attributePath(0, clusters.GeneralDiagnosticsClusterID, 0)].([]interface{})
+
+	seen := make(map[string]bool)
+	var addrs []net.IP
+	for _, entry := range interfaces {
+		fields, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		for _, key := range []string{networkInterfaceIPv4Addresses, "IPv4Addresses", networkInterfaceIPv6Addresses, "IPv6Addresses"} {
+			list, _ := fields[key].([]interface{})
+			for _, raw := range list {
+				ip := decodeIP(raw)
+				if ip == nil || ip.IsUnspecified() || seen[ip.String()] {
+					continue
+				}
+				seen[ip.String()] = true
+				addrs = append(addrs, ip)
+			}
+		}
+	}
+
+	sort.Slice(addrs, func(i, j int) bool {
+		return addrs[i].String() < addrs[j].String()
+	})
+	return addrs
+}
+
+// decodeIP decodes an octet string address, stored as base64 (JSON) or raw
+// bytes
+func decodeIP(v interface{}) net.IP {
+	var b []byte
+	switch t := v.(type) {
+	case []byte:
+		b = t
+	case string:
+		decoded, err := base64.StdEncoding.DecodeString(t)
+		if err != nil {
+			return nil
+		}
+		b = decoded
+	default:
+		return nil
+	}
+
+	if len(b) != net.IPv4len && len(b) != net.IPv6len {
+		return nil
+	}
+	return net.IP(b)
+}
+
+// formatIP formats an address, adding the interface scope to IPv6
+// link-local addresses when requested
+func formatIP(ip net.IP, scope string) string {
+	if scope != "" && ip.To4() == nil && ip.IsLinkLocalUnicast() {
+		return ip.String() + "%" + scope
+	}
+	return ip.String()
+}
@@ -0,0 +1,478 @@
+package server
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/clusters"
+	"github.com/codefionn/go-matter-server/internal/interaction"
+	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/tlv"
+)
+
+// argKind is the type a command argument must have
+type argKind int
+
+const (
+	// argInteger is an integral number, or a string holding one
+	argInteger argKind = iota
+	argString
+	// argBool is a boolean, or a string holding one
+	argBool
+	argObject
+	// argIDOrName is a numeric ID or a name resolved by the handler
+	argIDOrName
+	// argStringList is a list of strings, or a comma-separated string
+	argStringList
+	// argTime is an RFC 3339 timestamp
+	argTime
+	argAny
+)
+
+func (k argKind) String() string {
+	switch k {
+	case argInteger:
+		return "integer"
+	case argString:
+		return "string"
+	case argBool:
+		return "boolean"
+	case argObject:
+		return "object"
+	case argIDOrName:
+		return "ID or name"
+	case argStringList:
+		return "list of strings"
+	case argTime:
+		return "RFC 3339 timestamp"
+	}
+	return "any value"
+}
+
+// argSpec declares a single command argument
+type argSpec struct {
+	name     string
+	kind     argKind
+	required bool
+
+	// Inclusive range of integer arguments, when ranged is set
+	ranged   bool
+	min, max int64
+
+	// Alternative names accepted for the argument
+	aliases []string
+}
+
+func required(name string, kind argKind) argSpec {
+	return argSpec{name: name, kind: kind, required: true}
+}
+
+func optional(name string, kind argKind) argSpec {
+	return argSpec{name: name, kind: kind}
+}
+
+func (a argSpec) between(min, max int64) argSpec {
+	a.ranged, a.min, a.max = true, min, max
+	return a
+}
+
+func (a argSpec) alias(names ...string) argSpec {
+	a.aliases = names
+	return a
+}
+
+// Arguments shared by several commands
+var (
+	nodeIDArg      = required("node_id", argInteger).between(0, math.MaxInt64)
+	endpointIDArg  = required("endpoint_id", argInteger).between(0, math.MaxUint16)
+	groupIDArg     = required("group_id", argInteger).between(0x0001, 0xFEFF)
+	sceneIDArg     = required("scene_id", argInteger).between(0x01, 0xFE)
+	deviceSceneArg = required("scene_id", argInteger).between(0x00, 0xFE)
+	sceneGroupArg  = optional("group_id", argInteger).between(0x0000, 0xFEFF)
+	transitionArg  = optional("transition_time_ms", argInteger).between(0, 60000000)
+	clusterArg     = required("cluster_id", argIDOrName).alias("cluster")
+	commandArg     = required("command_name", argIDOrName).alias("command_id")
+	payloadArg     = optional("payload", argObject)
+	annotateArg    = optional("annotate", argBool)
+	fabricIDArg    = optional("fabric_id", argInteger).between(1, math.MaxInt64)
+	timedTimeoutMs = optional("timed_request_timeout_ms", argInteger).
+			between(1, int64(interaction.MaxTimedRequestTimeout/time.Millisecond))
+)
+
+// commandSchemas declares the arguments of each command. Commands not listed
+// take no arguments; unknown arguments are ignored.
+var commandSchemas = map[models.APICommand][]argSpec{
+	models.APICommandGetNodes: {
+		annotateArg,
+		optional("limit", argInteger).between(1, math.MaxInt32),
+		optional("offset", argInteger).between(0, math.MaxInt32),
+		optional("fields", argStringList),
+		optional("available", argBool),
+		optional("is_bridge", argBool),
+		fabricIDArg,
+	},
+	models.APICommandCommissionWithCode:  {required("code", argString), optional("network_only", argBool), fabricIDArg},
+	models.APICommandParsePairingCode:    {optional("code", argString), optional("ndef", argString)},
+	models.APICommandCancelCommissioning: {required("queue_id", argString)},
+	models.APICommandCommissionOnNetwork: {
+		required("setup_pin_code", argInteger).between(1, 99999998),
+		optional("filter_type", argInteger).between(0, 8),
+		optional("filter", argAny),
+		optional("ip_addr", argString),
+		fabricIDArg,
+	},
+	models.APICommandGetNode:            {nodeIDArg, annotateArg},
+	models.APICommandPingNode:           {nodeIDArg, optional("attempts", argInteger).between(1, 10)},
+	models.APICommandGetNodeIPAddresses: {nodeIDArg, optional("scoped", argBool)},
+	models.APICommandDeviceCommand: {
+		nodeIDArg, endpointIDArg, clusterArg, commandArg, payloadArg, timedTimeoutMs,
+		optional("payload_tlv", argString),
+		optional("raw_response", argBool),
+	},
+	models.APICommandReadAttribute: {nodeIDArg, required("attribute_path", argString), optional("raw", argBool)},
+	models.APICommandWriteAttribute: {
+		nodeIDArg, required("attribute_path", argString), optional("value", argAny), timedTimeoutMs,
+		optional("value_tlv", argString),
+	},
+	models.APICommandInterviewNode: {nodeIDArg},
+	models.APICommandSetNodeName:   {nodeIDArg, required("name", argString)},
+	models.APICommandSetNodeMetadata: {
+		nodeIDArg,
+		optional("name", argString),
+		optional("room", argString),
+		optional("tags", argStringList),
+	},
+	models.APICommandGetDevices: {optional("node_id", argInteger).between(0, math.MaxInt64)},
+	models.APICommandDeviceAction: {
+		nodeIDArg, endpointIDArg, required("action", argString), optional("params", argObject),
+	},
+	models.APICommandGetEnergySummary:     {optional("node_id", argInteger).between(0, math.MaxInt64)},
+	models.APICommandCheckNodeUpdate:      {nodeIDArg},
+	models.APICommandGetFirmwareInventory: {optional("refresh", argBool)},
+	models.APICommandCreateGroup:          {required("name", argString), optional("group_id", argInteger).between(0x0001, 0xFEFF)},
+	models.APICommandRemoveGroup:          {groupIDArg},
+	models.APICommandAddGroupMember:       {groupIDArg, nodeIDArg, endpointIDArg},
+	models.APICommandRemoveGroupMember:    {groupIDArg, nodeIDArg, endpointIDArg},
+	models.APICommandGroupCommand:         {groupIDArg, clusterArg, commandArg, payloadArg},
+	models.APICommandStoreDeviceScene:     {nodeIDArg, endpointIDArg, deviceSceneArg, sceneGroupArg},
+	models.APICommandRecallDeviceScene:    {nodeIDArg, endpointIDArg, deviceSceneArg, sceneGroupArg, transitionArg},
+	models.APICommandRemoveDeviceScene:    {nodeIDArg, endpointIDArg, deviceSceneArg, sceneGroupArg},
+	models.APICommandCreateScene: {
+		required("name", argString),
+		optional("scene_id", argInteger).between(0x01, 0xFE),
+		required("members", argAny),
+	},
+	models.APICommandStoreScene:        {sceneIDArg},
+	models.APICommandRecallScene:       {sceneIDArg, transitionArg},
+	models.APICommandRemoveScene:       {sceneIDArg},
+	models.APICommandGetNodeFabrics:    {nodeIDArg},
+	models.APICommandRemoveNodeFabric:  {nodeIDArg, required("fabric_index", argInteger).between(1, 254)},
+	models.APICommandDisconnectSession: {required("session_id", argString)},
+	models.APICommandInjectFaults: {
+		optional("storage_write_delay_ms", argInteger).between(0, 600000),
+		optional("storage_write_drop_percent", argInteger).between(0, 100),
+		optional("interaction_failure_percent", argInteger).between(0, 100),
+		optional("sever_websockets", argBool),
+		optional("reset", argBool),
+	},
+	models.APICommandGetEventHistory: {
+		optional("node_id", argInteger).between(0, math.MaxInt64),
+		optional("events", argStringList),
+		optional("since", argTime),
+		optional("until", argTime),
+		optional("limit", argInteger).between(1, math.MaxInt32),
+	},
+	models.APICommandGetAttributeHistory: {
+		nodeIDArg,
+		required("attribute_path", argString),
+		optional("since", argTime),
+		optional("until", argTime),
+	},
+	models.APICommandDiscoverBLE: {optional("timeout_ms", argInteger).between(1000, 60000)},
+	models.APICommandSetServerSetting: {
+		required("name", argString),
+		optional("value", argAny),
+	},
+	models.APICommandSetDefaultFabricLabel: {optional("label", argAny)},
+	models.APICommandSetLogLevel: {
+		required("level", argString),
+		optional("duration_s", argInteger).between(1, maxTemporaryLogLevel),
+	},
+	models.APICommandGetLogs: {
+		optional("level", argString),
+		optional("module", argStringList),
+		optional("since", argTime),
+		optional("limit", argInteger).between(1, math.MaxInt32),
+	},
+	models.APICommandGetAuditLog: {
+		optional("command", argStringList),
+		optional("connection_id", argString),
+		optional("since", argTime),
+		optional("limit", argInteger).between(1, math.MaxInt32),
+	},
+	models.APICommandImportSettings: {
+		required("format_version", argInteger).between(1, models.SettingsExportVersion),
+		optional("nodes", argAny),
+		optional("vendors", argAny),
+		optional("settings", argObject),
+		optional("replace", argBool),
+	},
+}
+
+// commandArgs holds validated command arguments. Integers are int64 and
+// aliased arguments are stored under their declared name.
+type commandArgs map[string]interface{}
+
+// validateArgs checks raw command arguments against the command's schema
+func validateArgs(command models.APICommand, raw map[string]interface{}) (commandArgs, error) {
+	specs := commandSchemas[command]
+	validated := make(commandArgs, len(specs))
+
+	for _, spec := range specs {
+		// Null counts as absent, except for arguments taking any value
+		name, value, present := spec.name, raw[spec.name], false
+		for _, candidate := range append([]string{spec.name}, spec.aliases...) {
+			if v, ok := raw[candidate]; ok && (v != nil || spec.kind == argAny) {
+				name, value, present = candidate, v, true
+				break
+			}
+		}
+		if present && spec.required && value == "" {
+			present = false
+		}
+
+		if !present {
+			if spec.required {
+				return nil, &models.ArgumentError{Field: spec.name, Reason: "missing required argument"}
+			}
+			continue
+		}
+
+		normalized, ok := normalizeArg(spec.kind, value)
+		if !ok {
+			return nil, &models.ArgumentError{Field: name, Reason: "expected " + spec.kind.String()}
+		}
+		if spec.ranged {
+			if n := normalized.(int64); n < spec.min || n > spec.max {
+				return nil, &models.ArgumentError{
+					Field:  name,
+					Reason: fmt.Sprintf("must be between %d and %d", spec.min, spec.max),
+				}
+			}
+		}
+		validated[spec.name] = normalized
+	}
+	return validated, nil
+}
+
+// normalizeArg converts a decoded JSON value to the representation of kind
+func normalizeArg(kind argKind, value interface{}) (interface{}, bool) {
+	switch kind {
+	case argInteger:
+		return toInteger(value)
+	case argString:
+		s, ok := value.(string)
+		return s, ok
+	case argBool:
+		switch v := value.(type) {
+		case bool:
+			return v, true
+		case string:
+			b, err := strconv.ParseBool(v)
+			return b, err == nil
+		}
+		return nil, false
+	case argObject:
+		m, ok := value.(map[string]interface{})
+		return m, ok
+	case argIDOrName:
+		switch value.(type) {
+		case string:
+			return value, true
+		}
+		n, ok := toInteger(value)
+		if !ok || n < 0 {
+			return nil, false
+		}
+		return float64(n), true
+	case argStringList:
+		return toStringList(value)
+	case argTime:
+		s, ok := value.(string)
+		if !ok {
+			return nil, false
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		return t, err == nil
+	}
+	return value, true
+}
+
+// toStringList converts JSON string arrays and comma-separated strings to a
+// list of strings, dropping empty entries
+func toStringList(value interface{}) ([]string, bool) {
+	var list []string
+	switch v := value.(type) {
+	case string:
+		list = strings.Split(v, ",")
+	case []string:
+		list = v
+	case []interface{}:
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			list = append(list, s)
+		}
+	default:
+		return nil, false
+	}
+
+	result := make([]string, 0, len(list))
+	for _, s := range list {
+		if s = strings.TrimSpace(s); s != "" {
+			result = append(result, s)
+		}
+	}
+	return result, true
+}
+
+// toInteger converts integral JSON numbers and numeric strings to int64
+func toInteger(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case float64:
+		if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+			return 0, false
+		}
+		return int64(v), true
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case json.Number:
+		n, err := v.Int64()
+		return n, err == nil
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func (a commandArgs) integer(name string) int64 {
+	n, _ := a[name].(int64)
+	return n
+}
+
+func (a commandArgs) str(name string) string {
+	s, _ := a[name].(string)
+	return s
+}
+
+func (a commandArgs) boolean(name string) bool {
+	b, _ := a[name].(bool)
+	return b
+}
+
+func (a commandArgs) stringList(name string) []string {
+	l, _ := a[name].([]string)
+	return l
+}
+
+func (a commandArgs) time(name string) time.Time {
+	t, _ := a[name].(time.Time)
+	return t
+}
+
+func (a commandArgs) object(name string) map[string]interface{} {
+	m, _ := a[name].(map[string]interface{})
+	return m
+}
+
+func (a commandArgs) has(name string) bool {
+	_, ok := a[name]
+	return ok
+}
+
+func (a commandArgs) uint16(name string) uint16 {
+	return uint16(a.integer(name))
+}
+
+// nodeID returns the validated node_id argument
+func (a commandArgs) nodeID() int {
+	return int(a.integer("node_id"))
+}
+
+// timedRequestTimeout returns the timed_request_timeout_ms argument. Zero
+// means the interaction is not timed.
+func (a commandArgs) timedRequestTimeout() time.Duration {
+	return time.Duration(a.integer("timed_request_timeout_ms")) * time.Millisecond
+}
+
+// transitionTime returns the transition_time_ms argument for a command
+// payload, nil if it wasn't given
+func (a commandArgs) transitionTime() interface{} {
+	if !a.has("transition_time_ms") {
+		return nil
+	}
+	return uint32(a.integer("transition_time_ms"))
+}
+
+// attributePath resolves an "endpoint/cluster/attribute" argument whose
+// cluster and attribute are numeric IDs or names
+func (a commandArgs) attributePath(name string) (interaction.AttributePath, error) {
+	parts := strings.Split(a.str(name), "/")
+	if len(parts) != 3 {
+		return interaction.AttributePath{}, &models.ArgumentError{Field: name, Reason: "expected endpoint/cluster/attribute"}
+	}
+
+	endpoint, err := strconv.ParseUint(parts[0], 10, 16)
+	if err != nil {
+		return interaction.AttributePath{}, &models.ArgumentError{Field: name, Reason: fmt.Sprintf("invalid endpoint %q", parts[0])}
+	}
+	clusterID, cluster, err := clusters.Default().ResolveCluster(parts[1])
+	if err != nil {
+		return interaction.AttributePath{}, &models.ArgumentError{Field: name, Reason: err.Error()}
+	}
+	attributeID, err := clusters.ResolveAttribute(cluster, parts[2])
+	if err != nil {
+		return interaction.AttributePath{}, &models.ArgumentError{Field: name, Reason: err.Error()}
+	}
+	return interaction.AttributePath{Endpoint: uint16(endpoint), Cluster: clusterID, Attribute: attributeID}, nil
+}
+
+// tlv decodes a hex encoded TLV argument, nil if it is missing
+func (a commandArgs) tlv(name string) ([]byte, error) {
+	if !a.has(name) {
+		return nil, nil
+	}
+	data, err := hex.DecodeString(strings.ReplaceAll(a.str(name), " ", ""))
+	if err != nil || len(data) == 0 {
+		return nil, &models.ArgumentError{Field: name, Reason: "expected hex encoded TLV"}
+	}
+	if _, err := tlv.Decode(data); err != nil {
+		return nil, &models.ArgumentError{Field: name, Reason: err.Error()}
+	}
+	return data, nil
+}
+
+// clusterCommand resolves the cluster_id and command_name arguments, given as
+// numeric IDs or names
+func (a commandArgs) clusterCommand() (uint32, uint32, error) {
+	clusterID, cluster, err := clusters.Default().ResolveCluster(a["cluster_id"])
+	if err != nil {
+		return 0, 0, &models.ArgumentError{Field: "cluster_id", Reason: err.Error()}
+	}
+
+	commandID, err := clusters.ResolveCommand(cluster, a["command_name"])
+	if err != nil {
+		return 0, 0, &models.ArgumentError{Field: "command_name", Reason: err.Error()}
+	}
+	return clusterID, commandID, nil
+}
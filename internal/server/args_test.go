@@ -0,0 +1,175 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+func TestValidateArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		command   models.APICommand
+		args      map[string]interface{}
+		wantField string
+		check     func(t *testing.T, args commandArgs)
+	}{
+		{
+			name:    "Node ID as number",
+			command: models.APICommandGetNode,
+			args:    map[string]interface{}{"node_id": float64(5)},
+			check: func(t *testing.T, args commandArgs) {
+				if args.nodeID() != 5 {
+					t.Errorf("Expected node ID 5, got %d", args.nodeID())
+				}
+			},
+		},
+		{
+			name:    "Node ID as string",
+			command: models.APICommandGetNode,
+			args:    map[string]interface{}{"node_id": "42"},
+			check: func(t *testing.T, args commandArgs) {
+				if args.nodeID() != 42 {
+					t.Errorf("Expected node ID 42, got %d", args.nodeID())
+				}
+			},
+		},
+		{
+			name:      "Missing node ID",
+			command:   models.APICommandGetNode,
+			args:      map[string]interface{}{},
+			wantField: "node_id",
+		},
+		{
+			name:      "Fractional node ID",
+			command:   models.APICommandGetNode,
+			args:      map[string]interface{}{"node_id": 1.5},
+			wantField: "node_id",
+		},
+		{
+			name:      "Wrong type",
+			command:   models.APICommandGetNode,
+			args:      map[string]interface{}{"node_id": float64(1), "annotate": "yes"},
+			wantField: "annotate",
+		},
+		{
+			name:    "Aliases",
+			command: models.APICommandDeviceCommand,
+			args: map[string]interface{}{
+				"node_id": float64(1), "endpoint_id": float64(1), "cluster": "OnOff", "command_id": float64(2),
+				"timed_request_timeout_ms": float64(500),
+			},
+			check: func(t *testing.T, args commandArgs) {
+				if args["cluster_id"] != "OnOff" || args["command_name"] != float64(2) {
+					t.Errorf("Expected aliases under declared names, got %v", args)
+				}
+				if args.timedRequestTimeout() != 500*time.Millisecond {
+					t.Errorf("Expected 500ms timeout, got %s", args.timedRequestTimeout())
+				}
+			},
+		},
+		{
+			name:    "Endpoint too large",
+			command: models.APICommandDeviceCommand,
+			args: map[string]interface{}{
+				"node_id": float64(1), "endpoint_id": float64(0x10000), "cluster_id": float64(6), "command_name": float64(2),
+			},
+			wantField: "endpoint_id",
+		},
+		{
+			name:    "Timed request timeout too large",
+			command: models.APICommandWriteAttribute,
+			args: map[string]interface{}{
+				"node_id": float64(1), "attribute_path": "1/6/0", "value": true, "timed_request_timeout_ms": float64(70000),
+			},
+			wantField: "timed_request_timeout_ms",
+		},
+		{
+			name:    "Null value",
+			command: models.APICommandWriteAttribute,
+			args:    map[string]interface{}{"node_id": float64(1), "attribute_path": "1/8/17", "value": nil},
+			check: func(t *testing.T, args commandArgs) {
+				if !args.has("value") || args["value"] != nil {
+					t.Errorf("Expected null value to be kept, got %v", args)
+				}
+			},
+		},
+		{
+			name:      "Empty required string",
+			command:   models.APICommandCreateGroup,
+			args:      map[string]interface{}{"name": ""},
+			wantField: "name",
+		},
+		{
+			name:    "Query strings",
+			command: models.APICommandGetNodes,
+			args:    map[string]interface{}{"available": "true", "limit": "10", "fields": "node_id, available,"},
+			check: func(t *testing.T, args commandArgs) {
+				if !args.boolean("available") || args.integer("limit") != 10 {
+					t.Errorf("Expected parsed query strings, got %v", args)
+				}
+				if fields := args.stringList("fields"); len(fields) != 2 || fields[1] != "available" {
+					t.Errorf("Expected two fields, got %q", fields)
+				}
+			},
+		},
+		{
+			name:      "Zero limit",
+			command:   models.APICommandGetNodes,
+			args:      map[string]interface{}{"limit": float64(0)},
+			wantField: "limit",
+		},
+		{
+			name:    "Unknown arguments are ignored",
+			command: models.APICommandServerInfo,
+			args:    map[string]interface{}{"verbose": true},
+			check: func(t *testing.T, args commandArgs) {
+				if len(args) != 0 {
+					t.Errorf("Expected no arguments, got %v", args)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := validateArgs(tt.command, tt.args)
+			if tt.wantField != "" {
+				var argErr *models.ArgumentError
+				if !errors.As(err, &argErr) {
+					t.Fatalf("Expected argument error, got %v", err)
+				}
+				if argErr.Field != tt.wantField {
+					t.Errorf("Expected field %s, got %s", tt.wantField, argErr.Field)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if tt.check != nil {
+				tt.check(t, args)
+			}
+		})
+	}
+}
+
+func TestHandleCommandRejectsInvalidArgs(t *testing.T) {
+	server := createTestServer(t)
+	server.nodes[5] = &models.MatterNodeData{NodeID: 5}
+
+	_, err := server.HandleCommand(context.Background(), models.CommandMessage{
+		Command: string(models.APICommandDeviceCommand),
+		Args: map[string]interface{}{
+			"node_id": float64(5), "endpoint_id": float64(1), "cluster_id": "OnOff", "command_name": "Blink",
+		},
+	})
+
+	var argErr *models.ArgumentError
+	if !errors.As(err, &argErr) || argErr.Field != "command_name" {
+		t.Errorf("Expected argument error for command_name, got %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/audit"
+	"github.com/codefionn/go-matter-server/internal/config"
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// auditLogFile is the audit log in the storage path
+const auditLogFile = "audit.jsonl"
+
+// tokenCommands are the commands requiring a bearer token on every
+// transport, by the configured token they require
+var tokenCommands = map[models.APICommand]func(cfg *config.Config) string{
+	models.APICommandSetLogLevel:    func(cfg *config.Config) string { return cfg.Server.AdminToken },
+	models.APICommandPromoteStandby: func(cfg *config.Config) string { return cfg.Replication.Token },
+}
+
+// tokenAuthorized checks the bearer token a command was sent with in
+// constant time. No token is accepted if the configured token is empty.
+func tokenAuthorized(given, token string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+// auditedCommands change the state of the server, its nodes or their
+// networks. They are recorded whether they succeed or not.
+var auditedCommands = map[models.APICommand]bool{
+	models.APICommandCommissionWithCode:      true,
+	models.APICommandCommissionOnNetwork:     true,
+	models.APICommandCancelCommissioning:     true,
+	models.APICommandSetWiFiCredentials:      true,
+	models.APICommandSetThreadDataset:        true,
+	models.APICommandOpenCommissioningWindow: true,
+	models.APICommandInterviewNode:           true,
+	models.APICommandDeviceCommand:           true,
+	models.APICommandRemoveNode:              true,
+	models.APICommandWriteAttribute:          true,
+	models.APICommandImportTestNode:          true,
+	models.APICommandUpdateNode:              true,
+	models.APICommandSetDefaultFabricLabel:   true,
+	models.APICommandSetACLEntry:             true,
+	models.APICommandSetNodeBinding:          true,
+	models.APICommandCreateGroup:             true,
+	models.APICommandRemoveGroup:             true,
+	models.APICommandAddGroupMember:          true,
+	models.APICommandRemoveGroupMember:       true,
+	models.APICommandGroupCommand:            true,
+	models.APICommandStoreDeviceScene:        true,
+	models.APICommandRecallDeviceScene:       true,
+	models.APICommandRemoveDeviceScene:       true,
+	models.APICommandCreateScene:             true,
+	models.APICommandStoreScene:              true,
+	models.APICommandRecallScene:             true,
+	models.APICommandRemoveScene:             true,
+	models.APICommandRemoveNodeFabric:        true,
+	models.APICommandDisconnectSession:       true,
+	models.APICommandImportSettings:          true,
+	models.APICommandSetServerSetting:        true,
+	models.APICommandSetLogLevel:             true,
+	models.APICommandPromoteStandby:          true,
+	models.APICommandSetNodeName:             true,
+	models.APICommandSetNodeMetadata:         true,
+	models.APICommandDeviceAction:            true,
+	models.APICommandInjectFaults:            true,
+}
+
+// secretArgs are arguments holding setup codes or network credentials,
+// which the audit log must not disclose
+var secretArgs = map[string]bool{
+	"code":           true,
+	"setup_pin_code": true,
+	"passcode":       true,
+	"credentials":    true,
+	"password":       true,
+	"dataset":        true,
+}
+
+// maxAuditArgSize is the largest argument recorded in JSON bytes. Larger
+// ones, e.g. the nodes of import_settings, are only recorded by size.
+const maxAuditArgSize = 1024
+
+// auditArgs returns the arguments of a command as recorded in the audit log
+func auditArgs(raw map[string]interface{}) map[string]interface{} {
+	if len(raw) == 0 {
+		return nil
+	}
+	args := make(map[string]interface{}, len(raw))
+	for name, value := range raw {
+		if secretArgs[name] {
+			args[name] = "<redacted>"
+			continue
+		}
+		data, err := json.Marshal(value)
+		switch {
+		case err != nil:
+			args[name] = fmt.Sprintf("<%T>", value)
+		case len(data) > maxAuditArgSize:
+			args[name] = fmt.Sprintf("<%d bytes omitted>", len(data))
+		default:
+			args[name] = value
+		}
+	}
+	return args
+}
+
+// recordAudit appends a command that started at start and ended with err to
+// the audit log
+func (s *Server) recordAudit(ctx context.Context, cmd models.CommandMessage, start time.Time, err error) {
+	if s.auditLog == nil {
+		return
+	}
+
+	client := audit.ClientFromContext(ctx)
+	entry := models.AuditEntry{
+		Timestamp:     start.UTC(),
+		Command:       cmd.Command,
+		MessageID:     cmd.MessageID,
+		ConnectionID:  client.ConnectionID,
+		RemoteAddress: client.RemoteAddress,
+		Args:          auditArgs(cmd.Args),
+		Outcome:       models.AuditOutcomeSuccess,
+		DurationMs:    time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		entry.Outcome = models.AuditOutcomeError
+		entry.Error = err.Error()
+	}
+
+	if err := s.auditLog.Record(entry); err != nil {
+		s.logger.Error("Failed to record audited command",
+			logger.String("command", cmd.Command),
+			logger.ErrorField(err),
+		)
+	}
+}
+
+// handleGetAuditLog returns the audited commands, optionally filtered by
+// command, connection and time and limited to the newest entries
+func (s *Server) handleGetAuditLog(args commandArgs) (interface{}, error) {
+	if s.auditLog == nil {
+		return nil, errors.New("audit log is disabled (storage.audit_log)")
+	}
+	return s.auditLog.Entries(audit.Filter{
+		Since:        args.time("since"),
+		Commands:     args.stringList("command"),
+		ConnectionID: args.str("connection_id"),
+		Limit:        int(args.integer("limit")),
+	})
+}
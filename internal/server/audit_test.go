@@ -0,0 +1,103 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/codefionn/go-matter-server/internal/audit"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+func createAuditTestServer(t *testing.T) *Server {
+	t.Helper()
+	server := createTestServer(t)
+	auditLog, err := audit.Open(filepath.Join(t.TempDir(), auditLogFile))
+	if err != nil {
+		t.Fatalf("Failed to open audit log: %v", err)
+	}
+	t.Cleanup(func() { auditLog.Close() })
+	server.auditLog = auditLog
+	return server
+}
+
+func TestAuditLog(t *testing.T) {
+	server := createAuditTestServer(t)
+	ctx := audit.WithClient(context.Background(), audit.Client{ConnectionID: "conn-a", RemoteAddress: "192.0.2.1:5000"})
+
+	commands := []models.CommandMessage{
+		{MessageID: "1", Command: string(models.APICommandCreateGroup), Args: map[string]interface{}{"name": "Kitchen"}},
+		{MessageID: "2", Command: string(models.APICommandGetGroups)},
+		{MessageID: "3", Command: string(models.APICommandCommissionWithCode), Args: map[string]interface{}{"code": "MT:Y.K9042C00KA0648G00"}},
+		{MessageID: "4", Command: string(models.APICommandImportSettings), Args: map[string]interface{}{"format_version": float64(1), "nodes": strings.Repeat("x", 2000)}},
+	}
+	for _, cmd := range commands {
+		server.HandleCommand(ctx, cmd)
+	}
+
+	result, err := server.HandleCommand(context.Background(), models.CommandMessage{Command: string(models.APICommandGetAuditLog)})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	entries := result.([]models.AuditEntry)
+	// Reading groups and the audit log isn't audited
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %+v", entries)
+	}
+
+	group := entries[0]
+	if group.Command != "create_group" || group.MessageID != "1" || group.ConnectionID != "conn-a" ||
+		group.RemoteAddress != "192.0.2.1:5000" || group.Outcome != models.AuditOutcomeSuccess || group.Args["name"] != "Kitchen" {
+		t.Errorf("Unexpected entry %+v", group)
+	}
+	// Not implemented, so it failed
+	commission := entries[1]
+	if commission.Outcome != models.AuditOutcomeError || commission.Error == "" || commission.Args["code"] != "<redacted>" {
+		t.Errorf("Expected a failure without the setup code, got %+v", commission)
+	}
+	if nodes := entries[2].Args["nodes"]; nodes != "<2002 bytes omitted>" {
+		t.Errorf("Expected the large argument to be omitted, got %v", nodes)
+	}
+
+	result, _ = server.HandleCommand(context.Background(), models.CommandMessage{
+		Command: string(models.APICommandGetAuditLog),
+		Args:    map[string]interface{}{"command": "create_group,remove_node", "connection_id": "conn-a"},
+	})
+	if entries := result.([]models.AuditEntry); len(entries) != 1 || entries[0].Command != "create_group" {
+		t.Errorf("Expected the create_group entry, got %+v", entries)
+	}
+}
+
+func TestAuditLogREST(t *testing.T) {
+	server := createAuditTestServer(t)
+
+	req := httptest.NewRequest("POST", "/api/groups", bytes.NewReader([]byte(`{"name": "Hall"}`)))
+	req.RemoteAddr = "198.51.100.7:4000"
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	server.setupRouter().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	entries, err := server.auditLog.Entries(audit.Filter{})
+	if err != nil {
+		t.Fatalf("Failed to read entries: %v", err)
+	}
+	if len(entries) != 1 || entries[0].RemoteAddress != "198.51.100.7:4000" || entries[0].ConnectionID != "" {
+		data, _ := json.Marshal(entries)
+		t.Errorf("Expected the REST client, got %s", data)
+	}
+}
+
+func TestAuditLogDisabled(t *testing.T) {
+	server := createTestServer(t)
+	if _, err := server.HandleCommand(context.Background(), models.CommandMessage{Command: string(models.APICommandGetAuditLog)}); err == nil {
+		t.Error("Expected an error without an audit log")
+	}
+}
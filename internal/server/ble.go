@@ -0,0 +1,37 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/bluetooth"
+	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/progress"
+)
+
+// defaultBLEScanTimeout is the duration of discover_ble scans without
+// timeout_ms
+const defaultBLEScanTimeout = 10 * time.Second
+
+// handleDiscoverBLE scans for commissionable devices via BLE. Devices are
+// sent as scan_result events as they are found, the result lists all of them.
+func (s *Server) handleDiscoverBLE(ctx context.Context, args commandArgs) (interface{}, error) {
+	if s.bluetoothManager == nil || !s.bluetoothManager.IsAvailable() {
+		return nil, bluetooth.ErrUnavailable
+	}
+
+	timeout := defaultBLEScanTimeout
+	if args.has("timeout_ms") {
+		timeout = time.Duration(args.integer("timeout_ms")) * time.Millisecond
+	}
+
+	progress.Report(ctx, "scanning", 0)
+	devices, err := s.bluetoothManager.Scan(ctx, timeout, func(device models.CommissionableNodeData) {
+		s.EmitEvent(models.EventTypeScanResult, device)
+	})
+	if err != nil {
+		return nil, err
+	}
+	progress.Report(ctx, "completed", 100)
+	return devices, nil
+}
@@ -0,0 +1,215 @@
+package server
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/codefionn/go-matter-server/internal/clusters"
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// deviceTypeAggregator is the Matter device type of a bridge's aggregator
+// endpoint
+const deviceTypeAggregator = 0x000E
+
+// Descriptor and Bridged Device Basic Information attribute IDs
+const (
+	descriptorDeviceTypeList = 0x0000
+	descriptorPartsList      = 0x0003
+	bridgedNodeLabel         = 0x0005
+	bridgedReachable         = 0x0011
+)
+
+// expandBridge detects Aggregator endpoints in the node's attributes and
+// rebuilds IsBridge and BridgedEndpoints from the aggregators' PartsLists
+func expandBridge(node *models.MatterNodeData) {
+	attrsByEndpoint := make(map[int]map[string]interface{})
+	for path, value := range node.Attributes {
+		endpoint, err := strconv.Atoi(strings.SplitN(path, "/", 2)[0])
+		if err != nil {
+			continue
+		}
+		if attrsByEndpoint[endpoint] == nil {
+			attrsByEndpoint[endpoint] = make(map[string]interface{})
+		}
+		attrsByEndpoint[endpoint][path] = value
+	}
+
+	bridged := make(map[int]bool)
+	isBridge := false
+	for endpoint := range attrsByEndpoint {
+		types := deviceTypes(node.Attributes, endpoint)
+		if !slices.Contains(types, deviceTypeAggregator) {
+			continue
+		}
+		isBridge = true
+		for _, part := range intList(node.Attributes[attributePath(endpoint, clusters.DescriptorClusterID, descriptorPartsList)]) {
+			bridged[part] = true
+		}
+	}
+
+	node.IsBridge = isBridge
+	node.BridgedEndpoints = nil
+	if !isBridge {
+		return
+	}
+
+	ids := make([]int, 0, len(bridged))
+	for id := range bridged {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		ep := models.BridgedEndpoint{
+			EndpointID:  id,
+			DeviceTypes: deviceTypes(node.Attributes, id),
+			Attributes:  attrsByEndpoint[id],
+		}
+		if ep.Attributes == nil {
+			ep.Attributes = make(map[string]interface{})
+		}
+		if label, ok := node.Attributes[attributePath(id, clusters.BridgedDeviceBasicInformationClusterID, bridgedNodeLabel)].(string); ok {
+			ep.NodeLabel = label
+		}
+		if reachable, ok := node.Attributes[attributePath(id, clusters.BridgedDeviceBasicInformationClusterID, bridgedReachable)].(bool); ok {
+			ep.Reachable = &reachable
+		}
+		node.BridgedEndpoints = append(node.BridgedEndpoints, ep)
+	}
+}
+
+// applyNodeUpdate stores new node data (e.g. the result of an interview),
+// expands bridged endpoints, persists the node, records attribute values and
+// emits node_added or node_updated plus endpoint_added/endpoint_removed for
+// PartsList changes
+func (s *Server) applyNodeUpdate(node *models.MatterNodeData) error {
+	s.tagFabric(node)
+	s.tagICD(node)
+	expandBridge(node)
+
+	s.nodesMu.Lock()
+	previous, existed := s.nodes[node.NodeID]
+	s.nodes[node.NodeID] = node
+	s.nodesMu.Unlock()
+
+	if err := s.storage.SaveNode(node); err != nil {
+		return fmt.Errorf("failed to persist node %d: %w", node.NodeID, err)
+	}
+	s.recordAttributes(node.NodeID, node.Attributes)
+
+	if !existed {
+		s.EmitEvent(models.EventTypeNodeAdded, node)
+		s.subscribeNode(node.NodeID)
+	} else {
+		s.EmitEvent(models.EventTypeNodeUpdated, node)
+	}
+
+	var oldEndpoints []models.BridgedEndpoint
+	if existed {
+		oldEndpoints = previous.BridgedEndpoints
+	}
+	s.emitEndpointChanges(node.NodeID, oldEndpoints, node.BridgedEndpoints)
+
+	return nil
+}
+
+// emitEndpointChanges emits endpoint_added and endpoint_removed for the
+// bridged endpoints that differ between two versions of a node
+func (s *Server) emitEndpointChanges(nodeID int, old, current []models.BridgedEndpoint) {
+	added, removed := diffEndpoints(old, current)
+
+	for _, id := range added {
+		s.logger.Info("Bridged endpoint added", logger.Int("node_id", nodeID), logger.Int("endpoint_id", id))
+		s.EmitEvent(models.EventTypeEndpointAdded, endpointEvent(nodeID, id))
+	}
+	for _, id := range removed {
+		s.logger.Info("Bridged endpoint removed", logger.Int("node_id", nodeID), logger.Int("endpoint_id", id))
+		s.EmitEvent(models.EventTypeEndpointRemoved, endpointEvent(nodeID, id))
+	}
+}
+
+func endpointEvent(nodeID, endpointID int) map[string]int {
+	return map[string]int{
+		"node_id":     nodeID,
+		"endpoint_id": endpointID,
+	}
+}
+
+// diffEndpoints returns the endpoint IDs added and removed between two
+// bridged endpoint lists
+func diffEndpoints(old, current []models.BridgedEndpoint) (added, removed []int) {
+	oldIDs := make(map[int]bool, len(old))
+	for _, ep := range old {
+		oldIDs[ep.EndpointID] = true
+	}
+	currentIDs := make(map[int]bool, len(current))
+	for _, ep := range current {
+		currentIDs[ep.EndpointID] = true
+		if !oldIDs[ep.EndpointID] {
+			added = append(added, ep.EndpointID)
+		}
+	}
+	for _, ep := range old {
+		if !currentIDs[ep.EndpointID] {
+			removed = append(removed, ep.EndpointID)
+		}
+	}
+	return added, removed
+}
+
+// deviceTypes returns the device types from an endpoint's Descriptor
+// DeviceTypeList. Entries are structs keyed by field tag ("0") or name.
+func deviceTypes(attributes map[string]interface{}, endpoint int) []int {
+	list, _ := attributes[attributePath(endpoint, clusters.DescriptorClusterID, descriptorDeviceTypeList)].([]interface{})
+
+	types := make([]int, 0, len(list))
+	for _, entry := range list {
+		fields, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		value, ok := fields["0"]
+		if !ok {
+			value = fields["deviceType"]
+		}
+		if id, ok := toInt(value); ok {
+			types = append(types, id)
+		}
+	}
+	return types
+}
+
+func attributePath(endpoint int, cluster, attribute uint32) string {
+	return fmt.Sprintf("%d/%d/%d", endpoint, cluster, attribute)
+}
+
+func intList(v interface{}) []int {
+	list, _ := v.([]interface{})
+	out := make([]int, 0, len(list))
+	for _, item := range list {
+		if n, ok := toInt(item); ok {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+func toInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case float64:
+		return int(n), true
+	case int:
+		return n, true
+	case int64:
+		return int(n), true
+	case uint64:
+		return int(n), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,262 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/progress"
+)
+
+func bridgeAttributes(parts ...interface{}) map[string]interface{} {
+	attrs := map[string]interface{}{
+		"0/29/0": []interface{}{map[string]interface{}{"0": float64(22), "1": float64(1)}},
+		"1/29/0": []interface{}{map[string]interface{}{"0": float64(deviceTypeAggregator), "1": float64(1)}},
+		"1/29/3": parts,
+	}
+	for _, p := range parts {
+		ep := int(p.(float64))
+		attrs[attributePath(ep, 29, 0)] = []interface{}{map[string]interface{}{"0": float64(0x0013)}, map[string]interface{}{"0": float64(0x0100)}}
+		attrs[attributePath(ep, 6, 0)] = true
+		attrs[attributePath(ep, 57, 5)] = "Lamp"
+		attrs[attributePath(ep, 57, 17)] = true
+	}
+	return attrs
+}
+
+func TestExpandBridge(t *testing.T) {
+	node := &models.MatterNodeData{NodeID: 1, Attributes: bridgeAttributes(float64(3), float64(2))}
+	expandBridge(node)
+
+	if !node.IsBridge {
+		t.Fatal("Expected node to be detected as bridge")
+	}
+	if len(node.BridgedEndpoints) != 2 {
+		t.Fatalf("Expected 2 bridged endpoints, got %d", len(node.BridgedEndpoints))
+	}
+
+	ep := node.BridgedEndpoints[0]
+	if ep.EndpointID != 2 {
+		t.Errorf("Expected endpoints sorted by ID, got %d first", ep.EndpointID)
+	}
+	if ep.NodeLabel != "Lamp" {
+		t.Errorf("Expected node label Lamp, got %q", ep.NodeLabel)
+	}
+	if ep.Reachable == nil || !*ep.Reachable {
+		t.Error("Expected bridged endpoint to be reachable")
+	}
+	if len(ep.DeviceTypes) != 2 || ep.DeviceTypes[1] != 0x0100 {
+		t.Errorf("Expected device types [19 256], got %v", ep.DeviceTypes)
+	}
+	if len(ep.Attributes) != 4 || ep.Attributes["2/6/0"] != true {
+		t.Errorf("Expected endpoint 2 attribute map, got %v", ep.Attributes)
+	}
+}
+
+func TestExpandBridgeNonBridge(t *testing.T) {
+	node := &models.MatterNodeData{
+		NodeID:     1,
+		IsBridge:   true,
+		Attributes: map[string]interface{}{"0/29/0": []interface{}{map[string]interface{}{"0": float64(22)}}},
+	}
+	expandBridge(node)
+
+	if node.IsBridge {
+		t.Error("Expected node without aggregator not to be a bridge")
+	}
+	if node.BridgedEndpoints != nil {
+		t.Errorf("Expected no bridged endpoints, got %v", node.BridgedEndpoints)
+	}
+}
+
+func TestApplyNodeUpdateEndpointEvents(t *testing.T) {
+	server := createTestServer(t)
+	if err := server.storage.Start(); err != nil {
+		t.Fatalf("Failed to start storage: %v", err)
+	}
+	defer server.storage.Stop()
+
+	var mu sync.Mutex
+	var events []models.EventType
+	var endpoints []int
+	server.Subscribe(func(eventType models.EventType, data interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, eventType)
+		if eventType == models.EventTypeEndpointAdded || eventType == models.EventTypeEndpointRemoved {
+			endpoints = append(endpoints, data.(map[string]int)["endpoint_id"])
+		}
+	})
+
+	if err := server.applyNodeUpdate(&models.MatterNodeData{NodeID: 7, Attributes: bridgeAttributes(float64(2), float64(3))}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := server.applyNodeUpdate(&models.MatterNodeData{NodeID: 7, Attributes: bridgeAttributes(float64(3), float64(4))}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Events are delivered asynchronously
+	time.Sleep(50 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	counts := make(map[models.EventType]int)
+	for _, e := range events {
+		counts[e]++
+	}
+	if counts[models.EventTypeNodeAdded] != 1 || counts[models.EventTypeNodeUpdated] != 1 {
+		t.Errorf("Expected one node_added and one node_updated, got %v", counts)
+	}
+	if counts[models.EventTypeEndpointAdded] != 3 {
+		t.Errorf("Expected 3 endpoint_added events, got %d", counts[models.EventTypeEndpointAdded])
+	}
+	if counts[models.EventTypeEndpointRemoved] != 1 {
+		t.Errorf("Expected 1 endpoint_removed event, got %d", counts[models.EventTypeEndpointRemoved])
+	}
+
+	stored, err := server.storage.GetNode(7)
+	if err != nil || !stored.IsBridge || len(stored.BridgedEndpoints) != 2 {
+		t.Errorf("Expected persisted bridge node with 2 endpoints, got %+v (%v)", stored, err)
+	}
+}
+
+func TestUpdateNodeAttributesEndpointEvents(t *testing.T) {
+	server := createTestServer(t)
+	if err := server.storage.Start(); err != nil {
+		t.Fatalf("Failed to start storage: %v", err)
+	}
+	defer server.storage.Stop()
+
+	if err := server.applyNodeUpdate(&models.MatterNodeData{NodeID: 7, Attributes: bridgeAttributes(float64(2), float64(3))}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var mu sync.Mutex
+	added := make(map[int]bool)
+	removed := make(map[int]bool)
+	server.Subscribe(func(eventType models.EventType, data interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch eventType {
+		case models.EventTypeEndpointAdded:
+			added[data.(map[string]int)["endpoint_id"]] = true
+		case models.EventTypeEndpointRemoved:
+			removed[data.(map[string]int)["endpoint_id"]] = true
+		}
+	})
+
+	// A reported PartsList change bridges endpoint 4 and drops endpoint 2
+	report := bridgeAttributes(float64(3), float64(4))
+	if err := server.updateNodeAttributes(7, report); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Events are delivered asynchronously
+	time.Sleep(50 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(added) != 1 || !added[4] {
+		t.Errorf("Expected endpoint_added for endpoint 4, got %v", added)
+	}
+	if len(removed) != 1 || !removed[2] {
+		t.Errorf("Expected endpoint_removed for endpoint 2, got %v", removed)
+	}
+}
+
+func TestUpdateNodeAttributesPersistsLatest(t *testing.T) {
+	server := createTestServer(t)
+	if err := server.storage.Start(); err != nil {
+		t.Fatalf("Failed to start storage: %v", err)
+	}
+	defer server.storage.Stop()
+	server.nodes[5] = &models.MatterNodeData{NodeID: 5, Attributes: map[string]interface{}{}}
+
+	// Concurrent reports must not persist an older node over a newer one
+	const reporters = 8
+	var wg sync.WaitGroup
+	for r := range reporters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 25 {
+				if err := server.updateNodeAttributes(5, map[string]interface{}{fmt.Sprintf("%d/6/%d", r+1, i): true}); err != nil {
+					t.Errorf("updateNodeAttributes failed: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	stored, err := server.storage.GetNode(5)
+	if err != nil {
+		t.Fatalf("Failed to load node: %v", err)
+	}
+	if len(stored.Attributes) != reporters*25 {
+		t.Errorf("Expected the latest node with %d attributes to be persisted, got %d", reporters*25, len(stored.Attributes))
+	}
+}
+
+func TestInterviewNodeExpandsBridge(t *testing.T) {
+	server := createTestServer(t)
+	server.nodes[7] = &models.MatterNodeData{NodeID: 7, Attributes: map[string]interface{}{}}
+	server.controller = &interviewController{attributes: bridgeAttributes(float64(2))}
+
+	_, err := server.HandleCommand(context.Background(), models.CommandMessage{
+		Command: string(models.APICommandInterviewNode),
+		Args:    map[string]interface{}{"node_id": float64(7)},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	node := server.nodes[7]
+	if !node.IsBridge || len(node.BridgedEndpoints) != 1 {
+		t.Errorf("Expected interviewed node to expose 1 bridged endpoint, got %+v", node)
+	}
+	if node.LastInterview.IsZero() {
+		t.Error("Expected LastInterview to be set")
+	}
+}
+
+func TestInterviewNodeReportsProgress(t *testing.T) {
+	server := createTestServer(t)
+	server.nodes[7] = &models.MatterNodeData{NodeID: 7, Attributes: map[string]interface{}{}}
+	server.controller = &interviewController{attributes: map[string]interface{}{}}
+
+	var stages []string
+	ctx := progress.WithReporter(context.Background(), "1", func(p models.CommandProgress) {
+		if p.MessageID != "1" {
+			t.Errorf("Expected message ID 1, got %s", p.MessageID)
+		}
+		stages = append(stages, fmt.Sprintf("%s:%d", p.Stage, p.Percent))
+	})
+
+	_, err := server.HandleCommand(ctx, models.CommandMessage{
+		MessageID: "1",
+		Command:   string(models.APICommandInterviewNode),
+		Args:      map[string]interface{}{"node_id": float64(7)},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := "reading_attributes:0 updating_node:90 completed:100"
+	if got := strings.Join(stages, " "); got != want {
+		t.Errorf("Expected progress %q, got %q", want, got)
+	}
+}
+
+type interviewController struct {
+	fakeController
+	attributes map[string]interface{}
+}
+
+func (c *interviewController) Interview(ctx context.Context, nodeID int) (map[string]interface{}, error) {
+	return c.attributes, nil
+}
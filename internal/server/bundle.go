@@ -0,0 +1,112 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// writeSupportBundle writes a zip archive for bug reports: server info, node
+// dumps, recent logs, the configuration with secrets redacted and checksums
+// of the storage files. Storage contents such as fabric keys are left out.
+func (s *Server) writeSupportBundle(w io.Writer) error {
+	nodes := s.nodeSnapshot()
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].NodeID < nodes[j].NodeID
+	})
+
+	checksums, err := storageChecksums(s.config.Storage.Path)
+	if err != nil {
+		return fmt.Errorf("failed to checksum storage: %w", err)
+	}
+
+	zw := zip.NewWriter(w)
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"info.json", s.GetServerInfo()},
+		{"nodes.json", nodes},
+		{"config.json", s.config.Redacted()},
+	}
+	for _, file := range files {
+		data, err := json.MarshalIndent(file.data, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", file.name, err)
+		}
+		if err := writeZipFile(zw, file.name, data); err != nil {
+			return err
+		}
+	}
+
+	var logs bytes.Buffer
+	for _, line := range s.logger.Recent() {
+		logs.WriteString(line)
+		logs.WriteByte('\n')
+	}
+	if err := writeZipFile(zw, "logs.txt", logs.Bytes()); err != nil {
+		return err
+	}
+	if err := writeZipFile(zw, "storage.sha256", []byte(checksums)); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+func writeZipFile(zw *zip.Writer, name string, data []byte) error {
+	f, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add %s: %w", name, err)
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+// storageChecksums lists the SHA-256 checksums of the files below dir in
+// sha256sum format. A missing directory has no files.
+func storageChecksums(dir string) (string, error) {
+	var b strings.Builder
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir && os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		hash := sha256.New()
+		if _, err := io.Copy(hash, f); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "%x  %s\n", hash.Sum(nil), filepath.ToSlash(rel))
+		return nil
+	})
+	return b.String(), err
+}
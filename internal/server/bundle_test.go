@@ -0,0 +1,89 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+func TestDiagnosticsBundle(t *testing.T) {
+	server := createTestServer(t)
+	server.logger = logger.New(logger.Config{Level: logger.InfoLevel, Output: io.Discard, HistorySize: 10})
+	server.logger.Info("bundle test line")
+	server.nodes[5] = &models.MatterNodeData{NodeID: 5, Available: true}
+
+	stored := []byte(`{"nodes": {}}`)
+	if err := os.WriteFile(filepath.Join(server.config.Storage.Path, "nodes.json"), stored, 0644); err != nil {
+		t.Fatalf("Failed to write storage file: %v", err)
+	}
+	router := server.setupRouter()
+
+	req := httptest.NewRequest("GET", "/api/diagnostics?format=bundle", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Content-Type") != "application/zip" {
+		t.Errorf("Expected zip content type, got %s", w.Header().Get("Content-Type"))
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Disposition"), "attachment; filename=") {
+		t.Errorf("Expected attachment, got %s", w.Header().Get("Content-Disposition"))
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("Failed to open bundle: %v", err)
+	}
+	files := make(map[string]string)
+	for _, f := range archive.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", f.Name, err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(data)
+	}
+
+	for _, name := range []string{"info.json", "nodes.json", "config.json", "logs.txt", "storage.sha256"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("Expected %s in bundle, got %v", name, files)
+		}
+	}
+
+	var nodes []models.MatterNodeData
+	if err := json.Unmarshal([]byte(files["nodes.json"]), &nodes); err != nil || len(nodes) != 1 || nodes[0].NodeID != 5 {
+		t.Errorf("Expected node 5 in nodes.json, got %s", files["nodes.json"])
+	}
+	if !strings.Contains(files["logs.txt"], "bundle test line") {
+		t.Errorf("Expected recent log line, got %q", files["logs.txt"])
+	}
+	if !strings.Contains(files["config.json"], `"storage"`) {
+		t.Errorf("Expected config sections, got %s", files["config.json"])
+	}
+	wantChecksum := fmt.Sprintf("%x  nodes.json\n", sha256.Sum256(stored))
+	if !strings.Contains(files["storage.sha256"], wantChecksum) {
+		t.Errorf("Expected checksum line %q, got %q", wantChecksum, files["storage.sha256"])
+	}
+
+	req = httptest.NewRequest("GET", "/api/diagnostics?format=tar", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for unknown format, got %d", w.Code)
+	}
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/controller"
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/onboarding"
+	"github.com/codefionn/go-matter-server/internal/progress"
+)
+
+// handleCommission commissions a device into the fabric selected by fabric_id
+// and interviews it. Requests wait in the commissioning queue for a slot;
+// node IDs are unique across all fabrics.
+func (s *Server) handleCommission(ctx context.Context, cmd models.APICommand, args commandArgs) (interface{}, error) {
+	fabric, err := s.fabricArg(args)
+	if err != nil {
+		return nil, err
+	}
+
+	req := controller.CommissionRequest{
+		Fabric:   fabric.credentials,
+		VendorID: uint16(fabric.vendorID),
+	}
+	if cmd == models.APICommandCommissionWithCode {
+		req.Code = args.str("code")
+		req.NetworkOnly = args.boolean("network_only")
+	} else {
+		req.SetupPINCode = int(args.integer("setup_pin_code"))
+		req.FilterType = int(args.integer("filter_type"))
+		req.Filter = args["filter"]
+		if args.has("ip_addr") {
+			if req.IPAddress = net.ParseIP(args.str("ip_addr")); req.IPAddress == nil {
+				return nil, &models.ArgumentError{Field: "ip_addr", Reason: "invalid IP address"}
+			}
+		}
+	}
+
+	commissioner, ok := s.controller.(controller.Commissioner)
+	if !ok {
+		return nil, controller.ErrNotAvailable
+	}
+
+	ctx, entry, err := s.enqueueCommissioning(ctx, cmd, fabric.fabricID)
+	if err != nil {
+		return nil, err
+	}
+	defer s.finishCommissioning(entry)
+
+	if req.NodeID, err = s.awaitCommissioning(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	progress.Report(ctx, "commissioning", 0)
+	if err := s.commissionWithRetries(ctx, commissioner, entry, req); err != nil {
+		return nil, fmt.Errorf("failed to commission node %d: %w", req.NodeID, err)
+	}
+	s.logger.Info("Node commissioned",
+		logger.Int("node_id", req.NodeID),
+		logger.Int("fabric_id", fabric.fabricID),
+	)
+
+	// The node is kept if the interview fails, interview_node retries it
+	s.updateCommissioning(entry, func(info *models.CommissioningQueueEntry) {
+		info.State = models.CommissioningStateInterviewing
+	})
+	progress.Report(ctx, "reading_attributes", 50)
+	now := time.Now().UTC()
+	node := &models.MatterNodeData{
+		NodeID:           req.NodeID,
+		FabricID:         fabric.fabricID,
+		DateCommissioned: now,
+		Available:        true,
+		Attributes:       map[string]interface{}{},
+	}
+	attributes, interviewErr := s.controller.Interview(ctx, req.NodeID)
+	if interviewErr == nil {
+		node.Attributes = attributes
+		node.LastInterview = now
+		node.InterviewVersion = models.CurrentInterviewVersion
+	}
+	if err := s.applyNodeUpdate(node); err != nil {
+		return nil, err
+	}
+	if interviewErr != nil {
+		return nil, fmt.Errorf("node %d was commissioned, but the interview failed: %w", req.NodeID, interviewErr)
+	}
+	s.registerICDClient(ctx, req.NodeID)
+
+	progress.Report(ctx, "completed", 100)
+	return node, nil
+}
+
+// nextNodeID returns the node ID following the highest one in use
+func (s *Server) nextNodeID() int {
+	s.nodesMu.RLock()
+	defer s.nodesMu.RUnlock()
+
+	next := 1
+	for nodeID := range s.nodes {
+		next = max(next, nodeID+1)
+	}
+	return next
+}
+
+// handleParsePairingCode decodes a QR code payload or manual pairing code,
+// or the hex encoded NDEF message of an NFC tag, without commissioning
+func (s *Server) handleParsePairingCode(args commandArgs) (interface{}, error) {
+	if args.has("code") == args.has("ndef") {
+		return nil, &models.ArgumentError{Field: "code", Reason: "expected either code or ndef"}
+	}
+
+	if args.has("code") {
+		payload, err := onboarding.Parse(args.str("code"))
+		if err != nil {
+			return nil, &models.ArgumentError{Field: "code", Reason: err.Error()}
+		}
+		return payload, nil
+	}
+
+	message, err := hex.DecodeString(strings.ReplaceAll(args.str("ndef"), " ", ""))
+	if err != nil {
+		return nil, &models.ArgumentError{Field: "ndef", Reason: "expected hex encoded bytes"}
+	}
+	payload, err := onboarding.ParseNDEF(message)
+	if err != nil {
+		return nil, &models.ArgumentError{Field: "ndef", Reason: err.Error()}
+	}
+	return payload, nil
+}
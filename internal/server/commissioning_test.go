@@ -0,0 +1,334 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/attestation"
+	"github.com/codefionn/go-matter-server/internal/controller"
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/onboarding"
+)
+
+func TestParsePairingCode(t *testing.T) {
+	server := createTestServer(t)
+
+	result := runCommand(t, server, models.APICommandParsePairingCode, map[string]interface{}{"code": "3497-011-2332"})
+	if payload := result.(*onboarding.Payload); payload.Passcode != 20202021 || payload.Discriminator != 15 {
+		t.Errorf("Expected the payload of the manual code, got %+v", payload)
+	}
+
+	ndef := hex.EncodeToString(append([]byte{0xD1, 0x01, 23, 'U', 0x00}, "MT:Y.K9042C00KA0648G00"...))
+	result = runCommand(t, server, models.APICommandParsePairingCode, map[string]interface{}{"ndef": ndef})
+	if payload := result.(*onboarding.Payload); payload.Source != onboarding.SourceNFC || payload.VendorID != 0xFFF1 {
+		t.Errorf("Expected the payload of the NFC tag, got %+v", payload)
+	}
+
+	for _, args := range []map[string]interface{}{
+		{"code": "34970112331"},
+		{"ndef": "zz"},
+		{},
+		{"code": "34970112332", "ndef": ndef},
+	} {
+		_, err := server.HandleCommand(context.Background(), models.CommandMessage{
+			Command: string(models.APICommandParsePairingCode),
+			Args:    args,
+		})
+		if _, ok := err.(*models.ArgumentError); !ok {
+			t.Errorf("Expected an argument error for %v, got %v", args, err)
+		}
+	}
+}
+
+// testDevice is a device of product 0xFFF1/0x8000 with its attestation
+// chain
+type testDevice struct {
+	paa         *x509.Certificate
+	attestation controller.DeviceAttestation
+}
+
+// newTestDevice creates a device whose PAA isn't trusted by any server
+func newTestDevice(t *testing.T) *testDevice {
+	t.Helper()
+	// Subjects carry the Matter vendor and product ID attributes
+	name := func(cn, vid, pid string) pkix.Name {
+		name := pkix.Name{CommonName: cn}
+		name.ExtraNames = append(name.ExtraNames, pkix.AttributeTypeAndValue{Type: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 37244, 2, 1}, Value: vid})
+		if pid != "" {
+			name.ExtraNames = append(name.ExtraNames, pkix.AttributeTypeAndValue{Type: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 37244, 2, 2}, Value: pid})
+		}
+		return name
+	}
+	issue := func(subject pkix.Name, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("Failed to generate key: %v", err)
+		}
+		template := &x509.Certificate{
+			SerialNumber:          big.NewInt(time.Now().UnixNano()),
+			Subject:               subject,
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(time.Hour),
+			BasicConstraintsValid: true,
+			IsCA:                  isCA,
+			KeyUsage:              x509.KeyUsageDigitalSignature,
+		}
+		if isCA {
+			template.KeyUsage = x509.KeyUsageCertSign
+		}
+		if parent == nil {
+			parent, parentKey = template, key
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+		if err != nil {
+			t.Fatalf("Failed to create certificate: %v", err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatalf("Failed to parse certificate: %v", err)
+		}
+		return cert, key
+	}
+
+	paa, paaKey := issue(name("Test PAA", "FFF1", ""), true, nil, nil)
+	pai, paiKey := issue(name("Test PAI", "FFF1", "8000"), true, paa, paaKey)
+	dac, _ := issue(name("Test DAC", "FFF1", "8000"), false, pai, paiKey)
+	return &testDevice{
+		paa:         paa,
+		attestation: controller.DeviceAttestation{DAC: dac.Raw, PAI: pai.Raw},
+	}
+}
+
+// trustedTestDevice creates a device whose PAA the server trusts
+func trustedTestDevice(t *testing.T, server *Server) *testDevice {
+	t.Helper()
+	device := newTestDevice(t)
+	if _, err := server.paaStore.Add(device.paa); err != nil {
+		t.Fatalf("Failed to trust the PAA: %v", err)
+	}
+	return device
+}
+
+// commission passes the attestation of the device to the server like a
+// controller commissioning it, then requests its operational certificate
+func (d *testDevice) commission(req controller.CommissionRequest) ([]byte, error) {
+	if err := req.VerifyAttestation(d.attestation); err != nil {
+		return nil, err
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{}, key)
+	if err != nil {
+		return nil, err
+	}
+	return req.IssueNOC(csr)
+}
+
+// queueController commissions a device once release receives, tracking how
+// many devices are commissioned at once. The first failures attempts fail.
+type queueController struct {
+	commissioningController
+	release           chan struct{}
+	failures          int
+	mu                sync.Mutex
+	active, maxActive int
+	attempts          int
+}
+
+func (c *queueController) Commission(ctx context.Context, req controller.CommissionRequest) error {
+	c.mu.Lock()
+	c.active++
+	c.maxActive = max(c.maxActive, c.active)
+	c.attempts++
+	fail := c.attempts <= c.failures
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.active--
+		c.mu.Unlock()
+	}()
+
+	if c.release != nil {
+		select {
+		case <-c.release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if fail {
+		return errors.New("device did not respond")
+	}
+	_, err := c.device.commission(req)
+	return err
+}
+
+func commissioningQueue(t *testing.T, server *Server) []models.CommissioningQueueEntry {
+	t.Helper()
+	return runCommand(t, server, models.APICommandGetCommissioningQueue, nil).([]models.CommissioningQueueEntry)
+}
+
+func TestCommissioningQueue(t *testing.T) {
+	server := createTestServer(t)
+	server.config.Commissioning.MaxConcurrent = 1
+	server.config.Commissioning.MaxQueued = 2
+	fake := &queueController{release: make(chan struct{})}
+	fake.device = trustedTestDevice(t, server)
+	server.controller = fake
+
+	type outcome struct {
+		node *models.MatterNodeData
+		err  error
+	}
+	results := make(chan outcome, 3)
+	commission := func() {
+		result, err := server.HandleCommand(context.Background(), models.CommandMessage{
+			Command: string(models.APICommandCommissionWithCode),
+			Args:    map[string]interface{}{"code": "MT:Y.K9042C00KA0648G00"},
+		})
+		node, _ := result.(*models.MatterNodeData)
+		results <- outcome{node, err}
+	}
+
+	// Queue requests one at a time to keep their order
+	var queue []models.CommissioningQueueEntry
+	for i := 1; i <= 3; i++ {
+		go commission()
+		deadline := time.Now().Add(5 * time.Second)
+		for queue = commissioningQueue(t, server); len(queue) < i; queue = commissioningQueue(t, server) {
+			if time.Now().After(deadline) {
+				t.Fatal("Timed out waiting for the commissioning queue")
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	if queue[0].State != models.CommissioningStateCommissioning || queue[0].NodeID != 1 || queue[0].Position != 0 {
+		t.Errorf("Expected the first request commissioning node 1, got %+v", queue[0])
+	}
+	if queue[1].State != models.CommissioningStateQueued || queue[1].Position != 1 || queue[2].Position != 2 {
+		t.Errorf("Expected two queued requests, got %+v", queue[1:])
+	}
+
+	// The queue is full
+	_, err := server.HandleCommand(context.Background(), models.CommandMessage{
+		Command: string(models.APICommandCommissionWithCode),
+		Args:    map[string]interface{}{"code": "MT:Y.K9042C00KA0648G00"},
+	})
+	if err == nil {
+		t.Error("Expected a full queue to reject the request")
+	}
+
+	// Running requests can't be cancelled, queued ones can
+	if _, err := server.HandleCommand(context.Background(), models.CommandMessage{
+		Command: string(models.APICommandCancelCommissioning),
+		Args:    map[string]interface{}{"queue_id": queue[0].QueueID},
+	}); err == nil {
+		t.Error("Expected an error cancelling a running commissioning")
+	}
+	cancelled := runCommand(t, server, models.APICommandCancelCommissioning, map[string]interface{}{"queue_id": queue[1].QueueID})
+	if cancelled.(*models.CommissioningQueueEntry).State != models.CommissioningStateCancelled {
+		t.Errorf("Expected the entry cancelled, got %+v", cancelled)
+	}
+	if result := <-results; !errors.Is(result.err, errCommissioningCancelled) {
+		t.Errorf("Expected the cancelled request to fail, got %+v", result)
+	}
+
+	fake.release <- struct{}{}
+	fake.release <- struct{}{}
+	nodeIDs := map[int]bool{}
+	for range 2 {
+		result := <-results
+		if result.err != nil {
+			t.Fatalf("Unexpected error: %v", result.err)
+		}
+		nodeIDs[result.node.NodeID] = true
+	}
+	if !nodeIDs[1] || !nodeIDs[2] {
+		t.Errorf("Expected nodes 1 and 2 commissioned, got %v", nodeIDs)
+	}
+	if fake.maxActive != 1 {
+		t.Errorf("Expected one commissioning at a time, got %d", fake.maxActive)
+	}
+	if queue := commissioningQueue(t, server); len(queue) != 0 {
+		t.Errorf("Expected an empty queue, got %+v", queue)
+	}
+}
+
+func TestCommissioningRetries(t *testing.T) {
+	server := createTestServer(t)
+	server.config.Commissioning.MaxAttempts = 2
+	fake := &queueController{failures: 1}
+	fake.device = trustedTestDevice(t, server)
+	server.controller = fake
+
+	result := runCommand(t, server, models.APICommandCommissionWithCode, map[string]interface{}{"code": "MT:Y.K9042C00KA0648G00"})
+	if node := result.(*models.MatterNodeData); node.NodeID != 1 || fake.attempts != 2 {
+		t.Errorf("Expected node 1 commissioned on the second attempt, got %+v after %d attempts", node, fake.attempts)
+	}
+
+	fake.failures = 4
+	_, err := server.HandleCommand(context.Background(), models.CommandMessage{
+		Command: string(models.APICommandCommissionWithCode),
+		Args:    map[string]interface{}{"code": "MT:Y.K9042C00KA0648G00"},
+	})
+	if err == nil || fake.attempts != 4 {
+		t.Errorf("Expected the request to fail after two attempts, got %v after %d attempts", err, fake.attempts)
+	}
+}
+
+// unattestedController commissions devices without verifying their
+// attestation
+type unattestedController struct {
+	commissioningController
+}
+
+func (c *unattestedController) Commission(ctx context.Context, req controller.CommissionRequest) error {
+	return nil
+}
+
+func TestCommissioningAttestation(t *testing.T) {
+	server := createTestServer(t)
+	server.config.Commissioning.MaxAttempts = 3
+	fake := &queueController{}
+	fake.device = newTestDevice(t)
+	server.controller = fake
+	commission := func() (interface{}, error) {
+		return server.HandleCommand(context.Background(), models.CommandMessage{
+			Command: string(models.APICommandCommissionWithCode),
+			Args:    map[string]interface{}{"code": "MT:Y.K9042C00KA0648G00"},
+		})
+	}
+
+	// Devices of an untrusted PAA are rejected without retries
+	if _, err := commission(); !errors.Is(err, controller.ErrAttestationFailed) {
+		t.Errorf("Expected the untrusted device to be rejected, got %v", err)
+	}
+	if fake.attempts != 1 || len(server.nodes) != 0 {
+		t.Errorf("Expected one attempt without a node, got %d attempts and %d nodes", fake.attempts, len(server.nodes))
+	}
+
+	// Controllers must verify the attestation
+	server.controller = &unattestedController{}
+	if _, err := commission(); !errors.Is(err, controller.ErrAttestationFailed) {
+		t.Errorf("Expected commissioning without attestation to fail, got %v", err)
+	}
+
+	server.controller = fake
+	server.attestation = attestation.NewVerifier(server.paaStore, nil, true, logger.NewConsoleLogger(logger.ErrorLevel))
+	if _, err := commission(); err != nil {
+		t.Errorf("Expected the untrusted device to be accepted with allow_untrusted_devices, got %v", err)
+	}
+}
@@ -0,0 +1,294 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/controller"
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/progress"
+)
+
+// errCommissioningCancelled is returned to requests cancelled by
+// cancel_commissioning
+var errCommissioningCancelled = errors.New("commissioning was cancelled")
+
+// commissioningEntry is a commissioning request in the queue
+type commissioningEntry struct {
+	info models.CommissioningQueueEntry
+
+	// started is closed when the entry gets a commissioning slot
+	started   chan struct{}
+	cancel    context.CancelFunc
+	cancelled bool
+}
+
+// enqueueCommissioning adds a commissioning request to the queue. The
+// returned context is cancelled by cancel_commissioning; finishCommissioning
+// must be called once the request is done.
+func (s *Server) enqueueCommissioning(ctx context.Context, command models.APICommand, fabricID int) (context.Context, *commissioningEntry, error) {
+	s.commissionMu.Lock()
+	defer s.commissionMu.Unlock()
+
+	queued := 0
+	for _, entry := range s.commissioningQueue {
+		if entry.info.State == models.CommissioningStateQueued {
+			queued++
+		}
+	}
+	if limit := s.config.Commissioning.MaxQueued; limit > 0 && queued >= limit {
+		return nil, nil, fmt.Errorf("commissioning queue is full (%d requests waiting)", queued)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	entry := &commissioningEntry{
+		info: models.CommissioningQueueEntry{
+			QueueID:  models.GenerateMessageID(),
+			Command:  command,
+			FabricID: fabricID,
+			State:    models.CommissioningStateQueued,
+			QueuedAt: time.Now().UTC(),
+		},
+		started: make(chan struct{}),
+		cancel:  cancel,
+	}
+	s.commissioningQueue = append(s.commissioningQueue, entry)
+	s.dispatchCommissioning()
+	return ctx, entry, nil
+}
+
+// dispatchCommissioning starts the queued entries in order while slots are
+// free and assigns their node IDs. Must be called with commissionMu held.
+func (s *Server) dispatchCommissioning() {
+	limit := max(1, s.config.Commissioning.MaxConcurrent)
+
+	active := 0
+	nextNodeID := s.nextNodeID()
+	for _, entry := range s.commissioningQueue {
+		if entry.info.State != models.CommissioningStateQueued {
+			active++
+		}
+		// Node IDs of commissionings in progress are taken
+		nextNodeID = max(nextNodeID, entry.info.NodeID+1)
+	}
+
+	for _, entry := range s.commissioningQueue {
+		if active >= limit {
+			return
+		}
+		if entry.info.State != models.CommissioningStateQueued || entry.cancelled {
+			continue
+		}
+		now := time.Now().UTC()
+		entry.info.State = models.CommissioningStateCommissioning
+		entry.info.StartedAt = &now
+		entry.info.NodeID = nextNodeID
+		nextNodeID++
+		close(entry.started)
+		active++
+	}
+}
+
+// awaitCommissioning waits until the entry gets a commissioning slot and
+// returns the node ID assigned to it
+func (s *Server) awaitCommissioning(ctx context.Context, entry *commissioningEntry) (int, error) {
+	select {
+	case <-entry.started:
+	default:
+		progress.Report(ctx, "queued", 0)
+		select {
+		case <-entry.started:
+		case <-ctx.Done():
+			return 0, s.commissioningErr(ctx, entry)
+		}
+	}
+
+	s.commissionMu.Lock()
+	defer s.commissionMu.Unlock()
+	return entry.info.NodeID, nil
+}
+
+// commissionWithRetries commissions the device of an entry, retrying failed
+// attempts up to commissioning.max_attempts. Devices failing attestation
+// aren't retried.
+func (s *Server) commissionWithRetries(ctx context.Context, commissioner controller.Commissioner, entry *commissioningEntry, req controller.CommissionRequest) error {
+	cfg := s.config.Commissioning
+	attempts := max(1, cfg.MaxAttempts)
+
+	for attempt := 1; ; attempt++ {
+		s.updateCommissioning(entry, func(info *models.CommissioningQueueEntry) {
+			info.State = models.CommissioningStateCommissioning
+			info.Attempt = attempt
+		})
+
+		var attested, issued atomic.Bool
+		nodeID, fabric := req.NodeID, req.Fabric
+		req.VerifyAttestation = func(device controller.DeviceAttestation) error {
+			if err := s.verifyCommissionedDevice(nodeID, device); err != nil {
+				return err
+			}
+			attested.Store(true)
+			return nil
+		}
+		req.IssueNOC = func(csr []byte) ([]byte, error) {
+			if !attested.Load() {
+				return nil, fmt.Errorf("%w: the operational certificate was requested before the device attestation was verified", controller.ErrAttestationFailed)
+			}
+			noc, err := fabric.IssueNOCFromCSR(csr, uint64(nodeID))
+			if err != nil {
+				return nil, err
+			}
+			issued.Store(true)
+			return noc, nil
+		}
+
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if cfg.AttemptTimeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, cfg.AttemptTimeout)
+		}
+		err := commissioner.Commission(attemptCtx, req)
+		cancel()
+		switch {
+		case err == nil && !attested.Load():
+			err = fmt.Errorf("%w: the controller did not verify the device attestation", controller.ErrAttestationFailed)
+		case err == nil && !issued.Load():
+			err = errors.New("the controller did not request an operational certificate for the device")
+		}
+
+		switch {
+		case err == nil:
+			return nil
+		case ctx.Err() != nil:
+			return s.commissioningErr(ctx, entry)
+		case attempt >= attempts || errors.Is(err, controller.ErrNotAvailable) || errors.Is(err, controller.ErrAttestationFailed):
+			return err
+		}
+
+		s.logger.Warn("Commissioning attempt failed, retrying",
+			logger.Int("node_id", req.NodeID),
+			logger.Int("attempt", attempt),
+			logger.ErrorField(err),
+		)
+		s.updateCommissioning(entry, func(info *models.CommissioningQueueEntry) {
+			info.State = models.CommissioningStateRetrying
+			info.LastError = err.Error()
+		})
+		progress.Report(ctx, "retrying", 0)
+
+		select {
+		case <-ctx.Done():
+			return s.commissioningErr(ctx, entry)
+		case <-time.After(cfg.RetryDelay):
+		}
+	}
+}
+
+// verifyCommissionedDevice verifies the attestation information of a device
+// being commissioned. Untrusted devices pass only with
+// matter.allow_untrusted_devices.
+func (s *Server) verifyCommissionedDevice(nodeID int, device controller.DeviceAttestation) error {
+	result, err := s.VerifyDeviceAttestation(device.DAC, device.PAI, device.CertificationDeclaration)
+	if err != nil {
+		s.logger.Warn("Rejecting device failing attestation",
+			logger.Int("node_id", nodeID),
+			logger.ErrorField(err),
+		)
+		return fmt.Errorf("%w: %v", controller.ErrAttestationFailed, err)
+	}
+	s.logger.Info("Device attestation verified",
+		logger.Int("node_id", nodeID),
+		logger.Int("vendor_id", int(result.VendorID)),
+		logger.Int("product_id", int(result.ProductID)),
+		logger.Bool("trusted", result.Trusted),
+	)
+	return nil
+}
+
+// commissioningErr returns why the context of an entry ended
+func (s *Server) commissioningErr(ctx context.Context, entry *commissioningEntry) error {
+	s.commissionMu.Lock()
+	defer s.commissionMu.Unlock()
+	if entry.cancelled {
+		return errCommissioningCancelled
+	}
+	return ctx.Err()
+}
+
+func (s *Server) updateCommissioning(entry *commissioningEntry, update func(info *models.CommissioningQueueEntry)) {
+	s.commissionMu.Lock()
+	defer s.commissionMu.Unlock()
+	update(&entry.info)
+}
+
+// finishCommissioning removes an entry from the queue, freeing its slot
+func (s *Server) finishCommissioning(entry *commissioningEntry) {
+	s.commissionMu.Lock()
+	defer s.commissionMu.Unlock()
+
+	for i, e := range s.commissioningQueue {
+		if e == entry {
+			s.commissioningQueue = append(s.commissioningQueue[:i], s.commissioningQueue[i+1:]...)
+			break
+		}
+	}
+	entry.cancel()
+	s.dispatchCommissioning()
+}
+
+// handleGetCommissioningQueue returns the queued and running commissioning
+// requests in order
+func (s *Server) handleGetCommissioningQueue() (interface{}, error) {
+	s.commissionMu.Lock()
+	defer s.commissionMu.Unlock()
+
+	entries := make([]models.CommissioningQueueEntry, 0, len(s.commissioningQueue))
+	position := 0
+	for _, entry := range s.commissioningQueue {
+		info := entry.info
+		if info.State == models.CommissioningStateQueued {
+			position++
+			info.Position = position
+		}
+		entries = append(entries, info)
+	}
+	return entries, nil
+}
+
+// handleCommissioningQueueHTTP returns the commissioning queue
+func (s *Server) handleCommissioningQueueHTTP(w http.ResponseWriter, r *http.Request) {
+	entries, _ := s.handleGetCommissioningQueue()
+	s.writeJSON(w, entries)
+}
+
+// handleCancelCommissioning cancels a queued commissioning request, or one
+// waiting to retry. Requests commissioning a device can be cancelled by
+// their client with cancel.
+func (s *Server) handleCancelCommissioning(args commandArgs) (interface{}, error) {
+	queueID := args.str("queue_id")
+
+	s.commissionMu.Lock()
+	defer s.commissionMu.Unlock()
+
+	for _, entry := range s.commissioningQueue {
+		if entry.info.QueueID != queueID {
+			continue
+		}
+		switch entry.info.State {
+		case models.CommissioningStateQueued, models.CommissioningStateRetrying:
+		default:
+			return nil, &models.ArgumentError{Field: "queue_id", Reason: fmt.Sprintf("commissioning is %s", entry.info.State)}
+		}
+		entry.cancelled = true
+		entry.cancel()
+
+		info := entry.info
+		info.State = models.CommissioningStateCancelled
+		return &info, nil
+	}
+	return nil, &models.ArgumentError{Field: "queue_id", Reason: "no such commissioning request"}
+}
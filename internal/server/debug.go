@@ -0,0 +1,85 @@
+package server
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+)
+
+var (
+	publishDebugVars sync.Once
+	// debugVarsServer is the server whose state /debug/vars reports
+	debugVarsServer atomic.Pointer[Server]
+)
+
+// debugHandler serves the pprof profiles under /debug/pprof/ and the expvar
+// variables at /debug/vars
+func (s *Server) debugHandler() http.Handler {
+	debugVarsServer.Store(s)
+	publishDebugVars.Do(func() {
+		expvar.Publish("matter_server", expvar.Func(func() interface{} {
+			return debugVarsServer.Load().debugVars()
+		}))
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	return mux
+}
+
+// debugVars are the server variables published at /debug/vars, next to the
+// runtime's memstats
+func (s *Server) debugVars() map[string]interface{} {
+	s.nodesMu.RLock()
+	nodeCount := len(s.nodes)
+	s.nodesMu.RUnlock()
+
+	vars := map[string]interface{}{
+		"goroutines": runtime.NumGoroutine(),
+		"nodes":      nodeCount,
+		"websocket":  s.wsHandler.Stats(),
+		"panics":     s.panicStats(),
+	}
+	if s.telemetry != nil {
+		vars["telemetry"] = s.telemetry.Stats()
+	}
+	return vars
+}
+
+// startDebugServer serves the debug endpoints on the loopback interface, so
+// profiles are only reachable from the host (or through a port forward)
+func (s *Server) startDebugServer() error {
+	listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(s.config.Server.DebugPort)))
+	if err != nil {
+		return fmt.Errorf("failed to listen on debug port: %w", err)
+	}
+
+	// No write timeout, CPU profiles and traces stream for their duration
+	s.debugServer = &http.Server{
+		Handler:           s.debugHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := s.debugServer.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("Debug server failed", logger.ErrorField(err))
+		}
+	}()
+
+	s.logger.Info("Debug endpoints enabled", logger.String("address", listener.Addr().String()))
+	return nil
+}
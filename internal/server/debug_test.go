@@ -0,0 +1,48 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDebugEndpoints(t *testing.T) {
+	server := createTestServer(t)
+	debug := server.debugHandler()
+
+	tests := []struct {
+		path       string
+		wantStatus int
+	}{
+		{"/debug/pprof/", http.StatusOK},
+		{"/debug/pprof/goroutine?debug=1", http.StatusOK},
+		{"/debug/vars", http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		debug.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+		if w.Code != tt.wantStatus {
+			t.Errorf("Expected status %d for %s, got %d", tt.wantStatus, tt.path, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	debug.ServeHTTP(w, httptest.NewRequest("GET", "/debug/vars", nil))
+	var vars map[string]json.RawMessage
+	if err := json.Unmarshal(w.Body.Bytes(), &vars); err != nil {
+		t.Fatalf("Failed to parse vars: %v", err)
+	}
+	for _, name := range []string{"matter_server", "memstats"} {
+		if _, ok := vars[name]; !ok {
+			t.Errorf("Expected %s variable", name)
+		}
+	}
+
+	// The API router doesn't expose the profiles
+	w = httptest.NewRecorder()
+	server.setupRouter().ServeHTTP(w, httptest.NewRequest("GET", "/debug/pprof/", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 on the API port, got %d", w.Code)
+	}
+}
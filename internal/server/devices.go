@@ -0,0 +1,109 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/codefionn/go-matter-server/internal/controller"
+	"github.com/codefionn/go-matter-server/internal/devices"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// handleGetDevices returns the endpoints of all nodes, or of node_id, that
+// are supported device types
+func (s *Server) handleGetDevices(args commandArgs) (interface{}, error) {
+	s.nodesMu.RLock()
+	defer s.nodesMu.RUnlock()
+
+	result := []models.Device{}
+	if args.has("node_id") {
+		node, exists := s.nodes[args.nodeID()]
+		if !exists {
+			return nil, &models.NodeNotFoundError{NodeID: args.nodeID()}
+		}
+		return append(result, devices.Classify(node)...), nil
+	}
+
+	nodeIDs := make([]int, 0, len(s.nodes))
+	for nodeID := range s.nodes {
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	sort.Ints(nodeIDs)
+	for _, nodeID := range nodeIDs {
+		result = append(result, devices.Classify(s.nodes[nodeID])...)
+	}
+	return result, nil
+}
+
+// handleDeviceAction performs an action of the device type of an endpoint
+func (s *Server) handleDeviceAction(ctx context.Context, args commandArgs) (interface{}, error) {
+	nodeID := args.nodeID()
+
+	s.nodesMu.RLock()
+	node, exists := s.nodes[nodeID]
+	var (
+		interaction devices.Interaction
+		err         error
+	)
+	if exists {
+		interaction, err = devices.Resolve(node, int(args.integer("endpoint_id")), args.str("action"), args.object("params"))
+	}
+	s.nodesMu.RUnlock()
+
+	if !exists {
+		return nil, &models.NodeNotFoundError{NodeID: nodeID}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if interaction.Write {
+		req := controller.WriteRequest{
+			NodeID:              nodeID,
+			Path:                attributePath(interaction.EndpointID, interaction.ClusterID, interaction.AttributeID),
+			Value:               interaction.Value,
+			TimedRequestTimeout: interaction.TimedRequestTimeout,
+		}
+		if queued, ok, err := s.queueForICD(models.APICommandDeviceAction, nodeID, nil, &req); ok || err != nil {
+			return queued, err
+		}
+		return nil, s.controller.WriteAttribute(ctx, req)
+	}
+
+	req := controller.CommandRequest{
+		NodeID:              nodeID,
+		EndpointID:          uint16(interaction.EndpointID),
+		ClusterID:           interaction.ClusterID,
+		CommandID:           interaction.CommandID,
+		Payload:             interaction.Payload,
+		TimedRequestTimeout: interaction.TimedRequestTimeout,
+	}
+	if queued, ok, err := s.queueForICD(models.APICommandDeviceAction, nodeID, &req, nil); ok || err != nil {
+		return queued, err
+	}
+	return s.controller.SendCommand(ctx, req)
+}
+
+// handleDevicesHTTP lists the devices, optionally of the node_id query
+// parameter
+func (s *Server) handleDevicesHTTP(w http.ResponseWriter, r *http.Request) {
+	raw := make(map[string]interface{})
+	for name, values := range r.URL.Query() {
+		raw[name] = values[len(values)-1]
+	}
+
+	args, err := validateArgs(models.APICommandGetDevices, raw)
+	if err != nil {
+		s.writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	result, err := s.handleGetDevices(args)
+	var notFound *models.NodeNotFoundError
+	if errors.As(err, &notFound) {
+		s.writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	s.writeJSON(w, result)
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+func TestDeviceAction(t *testing.T) {
+	server := createTestServer(t)
+	fake := &labelController{}
+	server.controller = fake
+	server.nodes[5] = &models.MatterNodeData{NodeID: 5, Available: true, Attributes: map[string]interface{}{
+		"1/29/0":   []interface{}{map[string]interface{}{"0": float64(0x0101)}},
+		"1/6/0":    false,
+		"1/8/0":    float64(254),
+		"2/29/0":   []interface{}{map[string]interface{}{"0": float64(0x0301)}},
+		"2/513/18": float64(2000),
+	}}
+	server.nodes[6] = &models.MatterNodeData{NodeID: 6, Attributes: map[string]interface{}{}}
+	ctx := context.Background()
+
+	run := func(command models.APICommand, args map[string]interface{}) (interface{}, error) {
+		return server.HandleCommand(ctx, models.CommandMessage{Command: string(command), Args: args})
+	}
+
+	result, err := run(models.APICommandGetDevices, nil)
+	if err != nil {
+		t.Fatalf("get_devices failed: %v", err)
+	}
+	devices := result.([]models.Device)
+	if len(devices) != 2 || devices[0].DeviceType != "dimmable_light" || devices[0].State["brightness"] != float64(100) ||
+		devices[1].DeviceType != "thermostat" {
+		t.Errorf("Expected a dimmable light and a thermostat, got %+v", devices)
+	}
+	if result, err := run(models.APICommandGetDevices, map[string]interface{}{"node_id": float64(6)}); err != nil || len(result.([]models.Device)) != 0 {
+		t.Errorf("Expected no devices for node 6, got %+v, %v", result, err)
+	}
+
+	_, err = run(models.APICommandDeviceAction, map[string]interface{}{
+		"node_id": float64(5), "endpoint_id": float64(1), "action": "turn_on",
+	})
+	if err != nil {
+		t.Fatalf("device_action failed: %v", err)
+	}
+	if len(fake.commands) != 1 || fake.commands[0].EndpointID != 1 || fake.commands[0].ClusterID != 0x0006 || fake.commands[0].CommandID != 0x01 {
+		t.Errorf("Expected On to be sent to endpoint 1, got %+v", fake.commands)
+	}
+
+	_, err = run(models.APICommandDeviceAction, map[string]interface{}{
+		"node_id": float64(5), "endpoint_id": float64(2), "action": "set_heating_setpoint",
+		"params": map[string]interface{}{"temperature": float64(22)},
+	})
+	if err != nil {
+		t.Fatalf("device_action failed: %v", err)
+	}
+	if len(fake.writes) != 1 || fake.writes[0].Path != "2/513/18" || fake.writes[0].Value != float64(2200) {
+		t.Errorf("Expected OccupiedHeatingSetpoint to be written, got %+v", fake.writes)
+	}
+
+	var argErr *models.ArgumentError
+	_, err = run(models.APICommandDeviceAction, map[string]interface{}{
+		"node_id": float64(5), "endpoint_id": float64(1), "action": "set_brightness",
+		"params": map[string]interface{}{"brightness": float64(150)},
+	})
+	if !errors.As(err, &argErr) || argErr.Field != "params.brightness" {
+		t.Errorf("Expected an argument error for the brightness, got %v", err)
+	}
+
+	var notFound *models.NodeNotFoundError
+	if _, err := run(models.APICommandDeviceAction, map[string]interface{}{
+		"node_id": float64(7), "endpoint_id": float64(1), "action": "turn_on",
+	}); !errors.As(err, &notFound) {
+		t.Errorf("Expected node not found, got %v", err)
+	}
+}
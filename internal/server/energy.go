@@ -0,0 +1,205 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/clusters"
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// Electrical Power and Energy Measurement attribute IDs
+const (
+	powerMeasurementActivePower        = 0x0008
+	energyMeasurementCumulativeImports = 0x0001
+	energyMeasurementCumulativeExports = 0x0002
+
+	// EnergyMeasurementStruct field holding the energy in mWh
+	energyMeasurementFieldEnergy = "0"
+)
+
+// Attribute path patterns of the energy attributes on any endpoint
+var (
+	activePowerPattern    = fmt.Sprintf("*/%d/%d", clusters.ElectricalPowerMeasurementClusterID, powerMeasurementActivePower)
+	energyImportedPattern = fmt.Sprintf("*/%d/%d", clusters.ElectricalEnergyMeasurementClusterID, energyMeasurementCumulativeImports)
+	energyExportedPattern = fmt.Sprintf("*/%d/%d", clusters.ElectricalEnergyMeasurementClusterID, energyMeasurementCumulativeExports)
+)
+
+// energyAttribute reports whether an attribute path is aggregated by
+// get_energy_summary
+func energyAttribute(path string) bool {
+	return matchAttributePath(activePowerPattern, path) ||
+		matchAttributePath(energyImportedPattern, path) ||
+		matchAttributePath(energyExportedPattern, path)
+}
+
+// nodeEnergy sums the power and energy of all endpoints of a node. ok is
+// false for nodes without any energy attribute.
+func nodeEnergy(node *models.MatterNodeData) (energy models.NodeEnergy, ok bool) {
+	energy = models.NodeEnergy{NodeID: node.NodeID, Available: node.Available}
+
+	add := func(total **float64, value float64) {
+		if *total == nil {
+			*total = new(float64)
+		}
+		**total += value
+	}
+
+	for path, value := range node.Attributes {
+		var total **float64
+		switch {
+		case matchAttributePath(activePowerPattern, path):
+			ok = true
+			// ActivePower in mW, null while unknown
+			if mw, isNumber := toInt(value); isNumber {
+				add(&energy.Power, float64(mw)/1000)
+			}
+			continue
+		case matchAttributePath(energyImportedPattern, path):
+			total = &energy.EnergyImported
+		case matchAttributePath(energyExportedPattern, path):
+			total = &energy.EnergyExported
+		default:
+			continue
+		}
+		ok = true
+
+		// EnergyMeasurementStruct with the energy in mWh, null while unknown
+		fields, isStruct := value.(map[string]interface{})
+		if !isStruct {
+			continue
+		}
+		if mwh, isNumber := toInt(structField(fields, energyMeasurementFieldEnergy, "energy")); isNumber {
+			add(total, float64(mwh)/1e6)
+		}
+	}
+	return energy, ok
+}
+
+// energySummary aggregates the nodes measuring power or energy. The power
+// of unavailable nodes is left out of the total as it is likely stale.
+func energySummary(nodes []*models.MatterNodeData) *models.EnergySummary {
+	summary := &models.EnergySummary{Timestamp: time.Now().UTC(), Nodes: []models.NodeEnergy{}}
+	for _, node := range nodes {
+		energy, ok := nodeEnergy(node)
+		if !ok {
+			continue
+		}
+		if energy.Power != nil && energy.Available {
+			summary.TotalPower += *energy.Power
+		}
+		if energy.EnergyImported != nil {
+			summary.TotalEnergyImported += *energy.EnergyImported
+		}
+		if energy.EnergyExported != nil {
+			summary.TotalEnergyExported += *energy.EnergyExported
+		}
+		summary.Nodes = append(summary.Nodes, energy)
+	}
+
+	sort.Slice(summary.Nodes, func(i, j int) bool {
+		return summary.Nodes[i].NodeID < summary.Nodes[j].NodeID
+	})
+	return summary
+}
+
+// handleGetEnergySummary returns the power and energy of all nodes, or of
+// node_id, with their totals
+func (s *Server) handleGetEnergySummary(args commandArgs) (interface{}, error) {
+	s.nodesMu.RLock()
+	defer s.nodesMu.RUnlock()
+
+	if args.has("node_id") {
+		node, exists := s.nodes[args.nodeID()]
+		if !exists {
+			return nil, &models.NodeNotFoundError{NodeID: args.nodeID()}
+		}
+		return energySummary([]*models.MatterNodeData{node}), nil
+	}
+
+	nodes := make([]*models.MatterNodeData, 0, len(s.nodes))
+	for _, node := range s.nodes {
+		nodes = append(nodes, node)
+	}
+	return energySummary(nodes), nil
+}
+
+// handleEnergyHTTP returns the energy summary, optionally of the node_id
+// query parameter
+func (s *Server) handleEnergyHTTP(w http.ResponseWriter, r *http.Request) {
+	raw := make(map[string]interface{})
+	for name, values := range r.URL.Query() {
+		raw[name] = values[len(values)-1]
+	}
+
+	args, err := validateArgs(models.APICommandGetEnergySummary, raw)
+	if err != nil {
+		s.writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	result, err := s.handleGetEnergySummary(args)
+	var notFound *models.NodeNotFoundError
+	if errors.As(err, &notFound) {
+		s.writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	s.writeJSON(w, result)
+}
+
+// pollEnergy reads the energy attributes of the available nodes every
+// energy.poll_interval, for nodes that don't report them by subscription
+func (s *Server) pollEnergy(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Energy.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.pollEnergyOnce(ctx)
+		}
+	}
+}
+
+// pollEnergyOnce reads the cached energy attributes of the available nodes
+// and updates the nodes whose values changed
+func (s *Server) pollEnergyOnce(ctx context.Context) {
+	s.nodesMu.RLock()
+	paths := make(map[int][]string)
+	for nodeID, node := range s.nodes {
+		if !node.Available {
+			continue
+		}
+		for path := range node.Attributes {
+			if energyAttribute(path) {
+				paths[nodeID] = append(paths[nodeID], path)
+			}
+		}
+	}
+	s.nodesMu.RUnlock()
+
+	for nodeID, nodePaths := range paths {
+		values := make(map[string]interface{}, len(nodePaths))
+		for _, path := range nodePaths {
+			value, err := s.controller.ReadAttribute(ctx, nodeID, path)
+			if err != nil {
+				s.logger.Debug("Failed to read energy attribute",
+					logger.Int("node_id", nodeID),
+					logger.String("attribute_path", path),
+					logger.ErrorField(err),
+				)
+				continue
+			}
+			values[path] = value
+		}
+		if err := s.updateNodeAttributes(nodeID, values); err != nil {
+			s.logger.Warn("Failed to update energy attributes", logger.Int("node_id", nodeID), logger.ErrorField(err))
+		}
+	}
+}
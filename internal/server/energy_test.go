@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// energyController serves the ActivePower of every node
+type energyController struct {
+	fakeController
+	power float64
+}
+
+func (c *energyController) ReadAttribute(ctx context.Context, nodeID int, path string) (interface{}, error) {
+	if path == "1/144/8" {
+		return c.power, nil
+	}
+	return map[string]interface{}{"0": float64(3000000)}, nil
+}
+
+func energyNodes() []*models.MatterNodeData {
+	return []*models.MatterNodeData{
+		// Plug with power and imported energy
+		{NodeID: 5, Available: true, Attributes: map[string]interface{}{
+			"1/144/8": float64(12500),
+			"1/145/1": map[string]interface{}{"0": float64(2500000), "1": nil},
+		}},
+		// Unreachable solar inverter
+		{NodeID: 6, Available: false, Attributes: map[string]interface{}{
+			"1/144/8": float64(-800000),
+			"1/145/2": map[string]interface{}{"energy": float64(1000000000)},
+		}},
+		// Meter that hasn't measured yet
+		{NodeID: 7, Available: true, Attributes: map[string]interface{}{"2/144/8": nil}},
+		// Light without energy measurement
+		{NodeID: 8, Available: true, Attributes: map[string]interface{}{"1/6/0": true}},
+	}
+}
+
+func TestEnergySummary(t *testing.T) {
+	summary := energySummary(energyNodes())
+
+	if summary.TotalPower != 12.5 || summary.TotalEnergyImported != 2.5 || summary.TotalEnergyExported != 1000 {
+		t.Errorf("Expected totals 12.5 W, 2.5 kWh and 1000 kWh, got %+v", summary)
+	}
+	if len(summary.Nodes) != 3 {
+		t.Fatalf("Expected nodes 5, 6 and 7, got %+v", summary.Nodes)
+	}
+	plug, inverter, meter := summary.Nodes[0], summary.Nodes[1], summary.Nodes[2]
+	if plug.NodeID != 5 || *plug.Power != 12.5 || *plug.EnergyImported != 2.5 || plug.EnergyExported != nil {
+		t.Errorf("Unexpected plug %+v", plug)
+	}
+	if inverter.NodeID != 6 || inverter.Available || *inverter.Power != -800 || *inverter.EnergyExported != 1000 {
+		t.Errorf("Unexpected inverter %+v", inverter)
+	}
+	if meter.NodeID != 7 || meter.Power != nil || meter.EnergyImported != nil {
+		t.Errorf("Expected a meter without values, got %+v", meter)
+	}
+}
+
+func TestGetEnergySummary(t *testing.T) {
+	server := createTestServer(t)
+	for _, node := range energyNodes() {
+		server.nodes[node.NodeID] = node
+	}
+
+	result := runCommand(t, server, models.APICommandGetEnergySummary, map[string]interface{}{"node_id": float64(5)})
+	summary := result.(*models.EnergySummary)
+	if len(summary.Nodes) != 1 || summary.TotalPower != 12.5 {
+		t.Errorf("Expected the summary of node 5, got %+v", summary)
+	}
+
+	rec := httptest.NewRecorder()
+	server.setupRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/energy", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body["total_energy_exported_kwh"] != float64(1000) {
+		t.Errorf("Expected the fleet summary, got %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	server.setupRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/energy?node_id=9", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown node, got %d", rec.Code)
+	}
+}
+
+func TestPollEnergy(t *testing.T) {
+	server := createTestServer(t)
+	server.config.Energy.RecordHistory = true
+	server.controller = &energyController{power: 15000}
+	for _, node := range energyNodes() {
+		server.nodes[node.NodeID] = node
+	}
+
+	server.pollEnergyOnce(context.Background())
+
+	summary := runCommand(t, server, models.APICommandGetEnergySummary, nil).(*models.EnergySummary)
+	if summary.TotalPower != 15 || summary.TotalEnergyImported != 3 {
+		t.Errorf("Expected the polled values of node 5, got %+v", summary)
+	}
+	// Unavailable nodes aren't polled
+	if *summary.Nodes[1].Power != -800 {
+		t.Errorf("Expected node 6 unchanged, got %+v", summary.Nodes[1])
+	}
+
+	samples, err := server.storage.GetAttributeHistory(5, "1/144/8")
+	if err != nil || len(samples) != 1 || samples[0].Value != float64(15000) {
+		t.Errorf("Expected the polled power in the history, got %+v (%v)", samples, err)
+	}
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// defaultEventQueueSize is the number of events buffered per subscriber
+// when server.event_queue_size is 0
+const defaultEventQueueSize = 1024
+
+// queuedEvent is an emitted event waiting for a subscriber
+type queuedEvent struct {
+	eventType models.EventType
+	data      interface{}
+}
+
+// eventSubscription is a subscriber of the server events. Its worker passes
+// the queued events to the callback one at a time, in the order they were
+// emitted; events emitted while the queue is full are dropped.
+type eventSubscription struct {
+	id     string
+	cb     models.EventCallback
+	queue  chan queuedEvent
+	stop   chan struct{}
+	logger *logger.Logger
+
+	dropped  atomic.Uint64
+	dropping atomic.Bool
+	// Events queued or being handled by the callback
+	pending atomic.Int64
+
+	// Panics of the callback, and the server's count of all subscribers
+	panics      atomic.Uint64
+	totalPanics *atomic.Uint64
+}
+
+func newEventSubscription(id string, cb models.EventCallback, size int, log *logger.Logger, totalPanics *atomic.Uint64) *eventSubscription {
+	if size <= 0 {
+		size = defaultEventQueueSize
+	}
+	sub := &eventSubscription{
+		id:          id,
+		cb:          cb,
+		queue:       make(chan queuedEvent, size),
+		stop:        make(chan struct{}),
+		logger:      log,
+		totalPanics: totalPanics,
+	}
+	go sub.run()
+	return sub
+}
+
+// run delivers the queued events until the subscription is stopped
+func (sub *eventSubscription) run() {
+	for {
+		select {
+		case <-sub.stop:
+			return
+		case event := <-sub.queue:
+			// Unsubscribed callbacks aren't called anymore
+			select {
+			case <-sub.stop:
+				return
+			default:
+			}
+			sub.deliver(event)
+			sub.pending.Add(-1)
+		}
+	}
+}
+
+// deliver passes an event to the callback. A panicking callback skips the
+// event instead of taking the server down.
+func (sub *eventSubscription) deliver(event queuedEvent) {
+	defer func() {
+		if value := recover(); value != nil {
+			sub.panics.Add(1)
+			sub.totalPanics.Add(1)
+			sub.logger.Error("Event subscriber panicked",
+				logger.String("subscriber", sub.id),
+				logger.String("event", string(event.eventType)),
+				logger.String("panic", fmt.Sprint(value)),
+				logger.String("stack", string(debug.Stack())),
+			)
+		}
+	}()
+	sub.cb(event.eventType, event.data)
+}
+
+// enqueue queues an event without blocking and reports whether the
+// subscriber started dropping events with it
+func (sub *eventSubscription) enqueue(event queuedEvent) bool {
+	sub.pending.Add(1)
+	select {
+	case sub.queue <- event:
+		sub.dropping.Store(false)
+		return false
+	default:
+		sub.pending.Add(-1)
+		sub.dropped.Add(1)
+		return !sub.dropping.Swap(true)
+	}
+}
+
+// dispatchEvent queues an event for all subscribers. A warning is logged
+// when a subscriber starts dropping events; it is logged after the
+// subscribers are released, since log entries are events too.
+func (s *Server) dispatchEvent(eventType models.EventType, data interface{}) {
+	var overflowing []string
+	s.eventMu.RLock()
+	for _, sub := range s.eventCallbacks {
+		if sub.enqueue(queuedEvent{eventType: eventType, data: data}) {
+			overflowing = append(overflowing, sub.id)
+		}
+	}
+	s.eventMu.RUnlock()
+
+	for _, id := range overflowing {
+		s.logger.Warn("Event subscriber falls behind, dropping events",
+			logger.String("subscriber", id),
+			logger.String("event", string(eventType)),
+		)
+	}
+}
+
+// eventSubscriberStats returns the queue state of each event subscriber in
+// the order they subscribed
+func (s *Server) eventSubscriberStats() []models.EventSubscriberStats {
+	s.eventMu.RLock()
+	defer s.eventMu.RUnlock()
+
+	stats := make([]models.EventSubscriberStats, 0, len(s.eventCallbacks))
+	for _, sub := range s.eventCallbacks {
+		stats = append(stats, models.EventSubscriberStats{
+			ID:      sub.id,
+			Queued:  len(sub.queue),
+			Dropped: sub.dropped.Load(),
+			Panics:  sub.panics.Load(),
+		})
+	}
+	return stats
+}
+
+// eventsDelivered reports whether all subscribers handled the events queued
+// for them
+func (s *Server) eventsDelivered() bool {
+	s.eventMu.RLock()
+	defer s.eventMu.RUnlock()
+
+	for _, sub := range s.eventCallbacks {
+		if sub.pending.Load() > 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+func TestEventDispatchOrderAndDrops(t *testing.T) {
+	server := createTestServer(t)
+	server.config.Server.EventQueueSize = 4
+	// The WebSocket handler is subscribed already
+	baseline := len(server.eventSubscriberStats())
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	received := make(chan int, 10)
+	unsubscribe := server.Subscribe(func(eventType models.EventType, data interface{}) {
+		if eventType != models.EventTypeAttributeUpdated {
+			return
+		}
+		n := data.([]interface{})[0].(int)
+		if n == 0 {
+			close(started)
+			<-release
+		}
+		received <- n
+	})
+
+	emit := func(n int) {
+		server.EmitEvent(models.EventTypeAttributeUpdated, []interface{}{n, "1/6/0", true})
+	}
+	emit(0)
+	<-started
+	// Events 1-4 fill the queue while the subscriber is busy, 5 and 6 are
+	// dropped
+	for n := 1; n <= 6; n++ {
+		emit(n)
+	}
+
+	stats := server.eventSubscriberStats()
+	if len(stats) != baseline+1 || stats[baseline].Queued != 4 || stats[baseline].Dropped != 2 {
+		t.Errorf("Expected 4 queued and 2 dropped events, got %+v", stats)
+	}
+
+	close(release)
+	for want := 0; want <= 4; want++ {
+		select {
+		case n := <-received:
+			if n != want {
+				t.Fatalf("Expected event %d, got %d", want, n)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected event %d", want)
+		}
+	}
+
+	unsubscribe()
+	emit(7)
+	select {
+	case n := <-received:
+		t.Errorf("Expected no events after unsubscribing, got %d", n)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if stats := server.eventSubscriberStats(); len(stats) != baseline {
+		t.Errorf("Expected the subscriber removed, got %+v", stats)
+	}
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"github.com/codefionn/go-matter-server/internal/interaction"
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// handleEventReport decodes an event report and broadcasts each new event
+// as node_event
+func (s *Server) handleEventReport(nodeID int, report []byte) {
+	events, err := interaction.DecodeEventReports(nodeID, report)
+	if err != nil {
+		s.logger.Warn("Failed to decode event report", logger.Int("node_id", nodeID), logger.ErrorField(err))
+		return
+	}
+
+	for _, event := range events {
+		if !s.markEventSeen(nodeID, event.EventNumber) {
+			continue
+		}
+
+		s.logger.Debug("Node event",
+			logger.Int("node_id", nodeID),
+			logger.Int("endpoint_id", event.EndpointID),
+			logger.Int("cluster_id", event.ClusterID),
+			logger.Int("event_id", event.EventID),
+			logger.Int("event_number", event.EventNumber),
+		)
+		s.EmitEvent(models.EventTypeNodeEvent, event)
+		s.reinterviewAfterEvent(event)
+	}
+}
+
+// markEventSeen records an event number and reports whether it is new
+func (s *Server) markEventSeen(nodeID, eventNumber int) bool {
+	subs := &s.subscriptions
+
+	subs.mu.Lock()
+	defer subs.mu.Unlock()
+
+	if subs.lastEventNumber == nil {
+		subs.lastEventNumber = make(map[int]int)
+	}
+	if last, seen := subs.lastEventNumber[nodeID]; seen && eventNumber <= last {
+		return false
+	}
+	subs.lastEventNumber[nodeID] = eventNumber
+	return true
+}
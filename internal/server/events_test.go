@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/controller"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// eventController records event subscriptions and their handlers
+type eventController struct {
+	fakeController
+	mu       sync.Mutex
+	handlers map[int]controller.EventReportHandler
+	eventMin map[int]uint64
+}
+
+func (c *eventController) SubscribeEvents(ctx context.Context, nodeID int, eventMin uint64, handler controller.EventReportHandler) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.handlers == nil {
+		c.handlers = make(map[int]controller.EventReportHandler)
+		c.eventMin = make(map[int]uint64)
+	}
+	c.handlers[nodeID] = handler
+	c.eventMin[nodeID] = eventMin
+	return nil
+}
+
+// switchPressReport is a ReportDataMessage with a Switch.InitialPress event
+// on endpoint 1 with the given event number
+func switchPressReport(eventNumber byte) []byte {
+	return []byte{
+		0x15, 0x36, 0x02,
+		0x15, 0x35, 0x01,
+		0x37, 0x00, 0x24, 0x01, 0x01, 0x24, 0x02, 0x3B, 0x24, 0x03, 0x01, 0x18,
+		0x24, 0x01, eventNumber,
+		0x24, 0x02, 0x01,
+		0x24, 0x04, 0x64,
+		0x35, 0x07, 0x24, 0x00, 0x01, 0x18,
+		0x18, 0x18,
+		0x18, 0x18,
+	}
+}
+
+func TestNodeEventSubscription(t *testing.T) {
+	server := createTestServer(t)
+	fake := &eventController{}
+	server.controller = fake
+	server.nodes[5] = &models.MatterNodeData{NodeID: 5, Attributes: map[string]interface{}{}}
+
+	events := make(chan models.MatterNodeEvent, 4)
+	server.Subscribe(func(eventType models.EventType, data interface{}) {
+		if eventType == models.EventTypeNodeEvent {
+			events <- data.(models.MatterNodeEvent)
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server.startSubscriptions(ctx)
+
+	handler := fake.handlers[5]
+	if handler == nil {
+		t.Fatal("Expected subscription for node 5")
+	}
+	if fake.eventMin[5] != 1 {
+		t.Errorf("Expected eventMin 1, got %d", fake.eventMin[5])
+	}
+
+	handler(switchPressReport(3))
+	// Replayed events are dropped
+	handler(switchPressReport(3))
+	handler(switchPressReport(4))
+
+	// Events are delivered asynchronously, in any order
+	seen := make(map[int]bool)
+	for i := 0; i < 2; i++ {
+		select {
+		case event := <-events:
+			if event.NodeID != 5 || event.ClusterID != 0x3B {
+				t.Errorf("Unexpected event %+v", event)
+			}
+			seen[event.EventNumber] = true
+		case <-time.After(time.Second):
+			t.Fatal("Expected 2 node_event messages")
+		}
+	}
+	if !seen[3] || !seen[4] {
+		t.Errorf("Expected events 3 and 4, got %v", seen)
+	}
+	select {
+	case event := <-events:
+		t.Errorf("Expected duplicate event to be dropped, got %+v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Nodes added while running are subscribed, resuming after seen events
+	if err := server.applyNodeUpdate(&models.MatterNodeData{NodeID: 6, Attributes: map[string]interface{}{}}); err != nil {
+		t.Fatalf("Failed to add node: %v", err)
+	}
+	if fake.handlers[6] == nil {
+		t.Error("Expected subscription for added node 6")
+	}
+
+	server.subscriptions.cancel[5]()
+	delete(server.subscriptions.cancel, 5)
+	server.subscribeNode(5)
+	if fake.eventMin[5] != 5 {
+		t.Errorf("Expected resubscription at eventMin 5, got %d", fake.eventMin[5])
+	}
+}
+
+func TestNodeEventSubscriptionUnavailable(t *testing.T) {
+	server := createTestServer(t)
+	server.nodes[5] = &models.MatterNodeData{NodeID: 5, Attributes: map[string]interface{}{}}
+
+	server.startSubscriptions(context.Background())
+	if len(server.subscriptions.cancel) != 0 {
+		t.Error("Expected no subscriptions without a controller")
+	}
+}
@@ -0,0 +1,227 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/codefionn/go-matter-server/internal/clusters"
+	"github.com/codefionn/go-matter-server/internal/controller"
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// OperationalCredentials attribute and command IDs
+const (
+	operationalCredentialsFabrics            = 0x0001
+	operationalCredentialsCurrentFabricIndex = 0x0005
+	operationalCredentialsRemoveFabric       = 0x0A
+)
+
+// FabricDescriptorStruct field tags
+const (
+	fabricFieldRootPublicKey = "1"
+	fabricFieldVendorID      = "2"
+	fabricFieldFabricID      = "3"
+	fabricFieldNodeID        = "4"
+	fabricFieldLabel         = "5"
+	fabricFieldFabricIndex   = "254"
+)
+
+func (s *Server) handleGetNodeFabrics(ctx context.Context, args commandArgs) (interface{}, error) {
+	return s.nodeFabrics(ctx, args.nodeID())
+}
+
+func (s *Server) handleRemoveNodeFabric(ctx context.Context, args commandArgs) (interface{}, error) {
+	nodeID := args.nodeID()
+	fabricIndex := args.uint16("fabric_index")
+
+	fabrics, err := s.nodeFabrics(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	var target *models.NodeFabric
+	for i := range fabrics {
+		if fabrics[i].FabricIndex == int(fabricIndex) {
+			target = &fabrics[i]
+			break
+		}
+	}
+	if target == nil {
+		return nil, fmt.Errorf("fabric index %d not found on node %d", fabricIndex, nodeID)
+	}
+	// Removing our own fabric would decommission the node behind our back
+	if target.IsOwnFabric {
+		return nil, fmt.Errorf("refusing to remove own fabric from node %d, use remove_node instead", nodeID)
+	}
+
+	response, err := s.controller.SendCommand(ctx, controller.CommandRequest{
+		NodeID:     nodeID,
+		EndpointID: 0,
+		ClusterID:  clusters.OperationalCredentialsClusterID,
+		CommandID:  operationalCredentialsRemoveFabric,
+		Payload:    map[string]interface{}{"fabricIndex": fabricIndex},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove fabric: %w", err)
+	}
+	if status, ok := nocResponseStatus(response); ok && status != 0 {
+		return nil, fmt.Errorf("failed to remove fabric: node returned status %d", status)
+	}
+
+	s.logger.Info("Removed fabric from node",
+		logger.Int("node_id", nodeID),
+		logger.Int("fabric_index", int(fabricIndex)),
+		logger.Int("vendor_id", target.VendorID),
+	)
+
+	// Refresh the cached Fabrics attribute; the removal already succeeded,
+	// so a failed refresh is not reported to the caller
+	if err := s.refreshNodeFabrics(ctx, nodeID); err != nil {
+		s.logger.Warn("Failed to refresh node fabrics", logger.Int("node_id", nodeID), logger.ErrorField(err))
+	}
+
+	return nil, nil
+}
+
+// nodeFabrics reads the Fabrics attribute of a node, falling back to the
+// cached attributes when no controller is available
+func (s *Server) nodeFabrics(ctx context.Context, nodeID int) ([]models.NodeFabric, error) {
+	s.nodesMu.RLock()
+	node, exists := s.nodes[nodeID]
+	var cachedFabrics, cachedIndex interface{}
+	fabricID := 0
+	if exists {
+		fabricID = node.FabricID
+		cachedFabrics = node.Attributes[attributePath(0, clusters.OperationalCredentialsClusterID, operationalCredentialsFabrics)]
+		cachedIndex = node.Attributes[attributePath(0, clusters.OperationalCredentialsClusterID, operationalCredentialsCurrentFabricIndex)]
+	}
+	s.nodesMu.RUnlock()
+
+	if !exists {
+		return nil, &models.NodeNotFoundError{NodeID: nodeID}
+	}
+
+	value, err := s.controller.ReadAttribute(ctx, nodeID, attributePath(0, clusters.OperationalCredentialsClusterID, operationalCredentialsFabrics))
+	if err != nil {
+		if !errors.Is(err, controller.ErrNotAvailable) {
+			return nil, fmt.Errorf("failed to read fabrics: %w", err)
+		}
+		value = cachedFabrics
+	}
+
+	currentIndex := -1
+	if index, ok := toInt(cachedIndex); ok {
+		currentIndex = index
+	}
+
+	fabric, _ := s.fabric(fabricID)
+	return parseFabrics(value, currentIndex, fabric), nil
+}
+
+// refreshNodeFabrics re-reads the Fabrics attribute and stores it on the
+// node. Only the attribute is replaced, so attribute reports arriving
+// meanwhile are kept.
+func (s *Server) refreshNodeFabrics(ctx context.Context, nodeID int) error {
+	path := attributePath(0, clusters.OperationalCredentialsClusterID, operationalCredentialsFabrics)
+	value, err := s.controller.ReadAttribute(ctx, nodeID, path)
+	if err != nil {
+		return err
+	}
+
+	s.nodesMu.RLock()
+	_, exists := s.nodes[nodeID]
+	s.nodesMu.RUnlock()
+	if !exists {
+		return &models.NodeNotFoundError{NodeID: nodeID}
+	}
+	return s.updateNodeAttributes(nodeID, map[string]interface{}{path: value})
+}
+
+// parseFabrics decodes a list of FabricDescriptorStruct values. A fabric is
+// our own if its index is the node's CurrentFabricIndex or its root public
+// key and fabric ID match the credentials of the node's fabric, nil if that
+// fabric is no longer operated.
+func parseFabrics(value interface{}, currentIndex int, own *operatedFabric) []models.NodeFabric {
+	entries, _ := value.([]interface{})
+
+	var ownRootKey []byte
+	var ownFabricID uint64
+	if own != nil {
+		ownFabricID = own.credentials.FabricID()
+		if root := own.credentials.RootCertificate(); root != nil {
+			if pub, ok := root.PublicKey.(*ecdsa.PublicKey); ok {
+				if key, err := pub.ECDH(); err == nil {
+					ownRootKey = key.Bytes()
+				}
+			}
+		}
+	}
+
+	fabrics := make([]models.NodeFabric, 0, len(entries))
+	for _, entry := range entries {
+		fields, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		fabric := models.NodeFabric{}
+		fabric.FabricIndex, _ = toInt(structField(fields, fabricFieldFabricIndex, "fabricIndex"))
+		fabric.VendorID, _ = toInt(structField(fields, fabricFieldVendorID, "vendorID"))
+		fabric.FabricID, _ = toUint64(structField(fields, fabricFieldFabricID, "fabricID"))
+		fabric.NodeID, _ = toUint64(structField(fields, fabricFieldNodeID, "nodeID"))
+		fabric.Label, _ = structField(fields, fabricFieldLabel, "label").(string)
+		fabric.RootPublicKey, _ = structField(fields, fabricFieldRootPublicKey, "rootPublicKey").(string)
+
+		if fabric.FabricIndex == currentIndex {
+			fabric.IsOwnFabric = true
+		} else if ownRootKey != nil && fabric.FabricID == ownFabricID {
+			rootKey, err := base64.StdEncoding.DecodeString(fabric.RootPublicKey)
+			fabric.IsOwnFabric = err == nil && bytes.Equal(rootKey, ownRootKey)
+		}
+
+		fabrics = append(fabrics, fabric)
+	}
+
+	sort.Slice(fabrics, func(i, j int) bool {
+		return fabrics[i].FabricIndex < fabrics[j].FabricIndex
+	})
+	return fabrics
+}
+
+// nocResponseStatus extracts the StatusCode field of an NOCResponse
+func nocResponseStatus(response interface{}) (int, bool) {
+	fields, ok := response.(map[string]interface{})
+	if !ok {
+		return 0, false
+	}
+	return toInt(structField(fields, "0", "statusCode"))
+}
+
+// structField returns a struct field by tag, falling back to its name
+func structField(fields map[string]interface{}, tag, name string) interface{} {
+	if v, ok := fields[tag]; ok {
+		return v
+	}
+	return fields[name]
+}
+
+func toUint64(v interface{}) (uint64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return uint64(n), true
+	case int:
+		return uint64(n), true
+	case int64:
+		return uint64(n), true
+	case uint64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
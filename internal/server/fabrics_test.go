@@ -0,0 +1,182 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/base64"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/codefionn/go-matter-server/internal/clusters"
+	"github.com/codefionn/go-matter-server/internal/controller"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// fabricController serves the Fabrics attribute and answers RemoveFabric
+// with the configured NOCResponse status
+type fabricController struct {
+	fakeController
+	fabrics []interface{}
+	status  int
+}
+
+func (c *fabricController) ReadAttribute(ctx context.Context, nodeID int, path string) (interface{}, error) {
+	return c.fabrics, nil
+}
+
+func (c *fabricController) SendCommand(ctx context.Context, req controller.CommandRequest) (interface{}, error) {
+	c.commands = append(c.commands, req)
+	return map[string]interface{}{"0": float64(c.status)}, nil
+}
+
+func testFabrics(ownRootKey string) []interface{} {
+	return []interface{}{
+		map[string]interface{}{"1": "Zm9yZWlnbg==", "2": float64(0x1217), "3": float64(99), "4": float64(1234), "5": "Other ecosystem", "254": float64(2)},
+		map[string]interface{}{"1": ownRootKey, "2": float64(0xFFF1), "3": float64(1), "4": float64(5), "5": "", "254": float64(1)},
+	}
+}
+
+func ownRootKey(t *testing.T, server *Server) string {
+	pub := server.credentials.RootCertificate().PublicKey.(*ecdsa.PublicKey)
+	key, err := pub.ECDH()
+	if err != nil {
+		t.Fatalf("Failed to encode root key: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(key.Bytes())
+}
+
+func TestGetNodeFabrics(t *testing.T) {
+	server := createTestServer(t)
+	server.controller = &fabricController{fabrics: testFabrics(ownRootKey(t, server))}
+	server.nodes[5] = &models.MatterNodeData{NodeID: 5, Attributes: map[string]interface{}{}}
+
+	result, err := server.HandleCommand(context.Background(), models.CommandMessage{
+		Command: string(models.APICommandGetNodeFabrics),
+		Args:    map[string]interface{}{"node_id": float64(5)},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	fabrics := result.([]models.NodeFabric)
+	if len(fabrics) != 2 {
+		t.Fatalf("Expected 2 fabrics, got %d", len(fabrics))
+	}
+	if fabrics[0].FabricIndex != 1 || !fabrics[0].IsOwnFabric {
+		t.Errorf("Expected fabric 1 to be our own, got %+v", fabrics[0])
+	}
+	if fabrics[1].IsOwnFabric || fabrics[1].VendorID != 0x1217 || fabrics[1].Label != "Other ecosystem" {
+		t.Errorf("Unexpected foreign fabric %+v", fabrics[1])
+	}
+}
+
+func TestGetNodeFabricsCached(t *testing.T) {
+	server := createTestServer(t)
+	server.nodes[5] = &models.MatterNodeData{
+		NodeID: 5,
+		Attributes: map[string]interface{}{
+			"0/62/1": testFabrics("AAAA"),
+			"0/62/5": float64(2),
+		},
+	}
+
+	fabrics, err := server.nodeFabrics(context.Background(), 5)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(fabrics) != 2 || fabrics[0].IsOwnFabric || !fabrics[1].IsOwnFabric {
+		t.Errorf("Expected CurrentFabricIndex to mark fabric 2 as own, got %+v", fabrics)
+	}
+
+	if _, err := server.nodeFabrics(context.Background(), 6); err == nil {
+		t.Error("Expected error for unknown node")
+	}
+}
+
+func TestRemoveNodeFabric(t *testing.T) {
+	tests := []struct {
+		name        string
+		fabricIndex float64
+		status      int
+		expectError bool
+	}{
+		{name: "Foreign fabric", fabricIndex: 2},
+		{name: "Own fabric", fabricIndex: 1, expectError: true},
+		{name: "Unknown fabric", fabricIndex: 7, expectError: true},
+		{name: "Node error status", fabricIndex: 2, status: 11, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := createTestServer(t)
+			fake := &fabricController{fabrics: testFabrics(ownRootKey(t, server)), status: tt.status}
+			server.controller = fake
+			server.nodes[5] = &models.MatterNodeData{NodeID: 5, Attributes: map[string]interface{}{}}
+
+			_, err := server.HandleCommand(context.Background(), models.CommandMessage{
+				Command: string(models.APICommandRemoveNodeFabric),
+				Args:    map[string]interface{}{"node_id": float64(5), "fabric_index": tt.fabricIndex},
+			})
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if len(fake.commands) != 1 {
+				t.Fatalf("Expected 1 command, got %d", len(fake.commands))
+			}
+			cmd := fake.commands[0]
+			if cmd.ClusterID != clusters.OperationalCredentialsClusterID || cmd.CommandID != operationalCredentialsRemoveFabric || cmd.EndpointID != 0 {
+				t.Errorf("Unexpected command %+v", cmd)
+			}
+			if _, ok := server.nodes[5].Attributes["0/62/1"]; !ok {
+				t.Error("Expected Fabrics attribute to be refreshed")
+			}
+		})
+	}
+}
+
+func TestRefreshNodeFabricsKeepsConcurrentUpdates(t *testing.T) {
+	server := createTestServer(t)
+	server.controller = &fabricController{fabrics: testFabrics(ownRootKey(t, server))}
+	server.nodes[5] = &models.MatterNodeData{NodeID: 5, Attributes: map[string]interface{}{}}
+
+	// Attribute reports arriving while the Fabrics attribute is refreshed
+	// must not be overwritten by it
+	const reports = 200
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := range reports {
+			if err := server.updateNodeAttributes(5, map[string]interface{}{fmt.Sprintf("1/6/%d", i): true}); err != nil {
+				t.Errorf("updateNodeAttributes failed: %v", err)
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range reports {
+			if err := server.refreshNodeFabrics(context.Background(), 5); err != nil {
+				t.Errorf("refreshNodeFabrics failed: %v", err)
+			}
+		}
+	}()
+	wg.Wait()
+
+	node := server.nodeSnapshot()[0]
+	for i := range reports {
+		if node.Attributes[fmt.Sprintf("1/6/%d", i)] != true {
+			t.Fatalf("Expected the report of 1/6/%d to be kept", i)
+		}
+	}
+	if _, ok := node.Attributes["0/62/1"]; !ok {
+		t.Error("Expected the Fabrics attribute to be refreshed")
+	}
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"errors"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/faults"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// setupFaultInjection routes the storage writes and device interactions
+// through a fault injector, which injects nothing until inject_faults is run
+func (s *Server) setupFaultInjection() {
+	s.faults = faults.NewInjector()
+	s.storage = s.faults.Storage(s.storage)
+	s.controller = s.faults.Controller(s.controller)
+	s.logger.Warn("Fault injection is enabled, inject_faults can delay and drop storage writes and fail device interactions")
+}
+
+// handleInjectFaults changes the injected faults. Arguments left out keep
+// their value unless reset is set. Severing the WebSocket connections
+// includes the one the command was sent on.
+func (s *Server) handleInjectFaults(args commandArgs) (interface{}, error) {
+	if s.faults == nil {
+		return nil, errors.New("fault injection is disabled (server.fault_injection)")
+	}
+
+	settings := s.faults.Settings()
+	if args.boolean("reset") {
+		settings = faults.Settings{}
+	}
+	if args.has("storage_write_delay_ms") {
+		settings.StorageWriteDelay = time.Duration(args.integer("storage_write_delay_ms")) * time.Millisecond
+	}
+	if args.has("storage_write_drop_percent") {
+		settings.StorageWriteDropPercent = int(args.integer("storage_write_drop_percent"))
+	}
+	if args.has("interaction_failure_percent") {
+		settings.InteractionFailurePercent = int(args.integer("interaction_failure_percent"))
+	}
+	s.faults.Set(settings)
+	s.logger.Warnf("Injecting faults: storage write delay %s, %d%% storage writes dropped, %d%% device interactions failed",
+		settings.StorageWriteDelay, settings.StorageWriteDropPercent, settings.InteractionFailurePercent)
+
+	stats := s.faults.Stats()
+	state := models.FaultInjectionState{
+		StorageWriteDelayMs:       settings.StorageWriteDelay.Milliseconds(),
+		StorageWriteDropPercent:   settings.StorageWriteDropPercent,
+		InteractionFailurePercent: settings.InteractionFailurePercent,
+		DelayedWrites:             stats.DelayedWrites,
+		DroppedWrites:             stats.DroppedWrites,
+		FailedInteractions:        stats.FailedInteractions,
+	}
+	if args.boolean("sever_websockets") {
+		state.SeveredConnections = s.wsHandler.SeverConnections()
+	}
+	return state, nil
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/codefionn/go-matter-server/internal/faults"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+func TestInjectFaults(t *testing.T) {
+	ctx := context.Background()
+	injectFaults := func(server *Server, args map[string]interface{}) (models.FaultInjectionState, error) {
+		result, err := server.HandleCommand(ctx, models.CommandMessage{
+			MessageID: "1",
+			Command:   string(models.APICommandInjectFaults),
+			Args:      args,
+		})
+		if err != nil {
+			return models.FaultInjectionState{}, err
+		}
+		return result.(models.FaultInjectionState), nil
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		server := createTestServer(t)
+		if _, err := injectFaults(server, map[string]interface{}{"interaction_failure_percent": float64(100)}); err == nil {
+			t.Error("Expected inject_faults to be rejected")
+		}
+	})
+
+	t.Run("Fails device interactions", func(t *testing.T) {
+		server := createTestServer(t)
+		fake := &fakeController{}
+		server.controller = fake
+		server.setupFaultInjection()
+		server.nodes[5] = &models.MatterNodeData{NodeID: 5, Attributes: map[string]interface{}{}}
+		deviceCommand := func() error {
+			_, err := server.HandleCommand(ctx, models.CommandMessage{
+				MessageID: "2",
+				Command:   string(models.APICommandDeviceCommand),
+				Args:      map[string]interface{}{"node_id": float64(5), "endpoint_id": float64(1), "cluster_id": float64(6), "command_name": "On"},
+			})
+			return err
+		}
+
+		state, err := injectFaults(server, map[string]interface{}{"interaction_failure_percent": float64(100), "storage_write_delay_ms": float64(1)})
+		if err != nil {
+			t.Fatalf("Failed to inject faults: %v", err)
+		}
+		if state.InteractionFailurePercent != 100 || state.StorageWriteDelayMs != 1 {
+			t.Errorf("Expected the faults to be set, got %+v", state)
+		}
+		if err := deviceCommand(); !errors.Is(err, faults.ErrInjected) {
+			t.Errorf("Expected an injected fault, got %v", err)
+		}
+		if len(fake.commands) != 0 {
+			t.Error("Expected the failed command not to reach the controller")
+		}
+
+		// Arguments left out are kept
+		state, err = injectFaults(server, map[string]interface{}{"storage_write_drop_percent": float64(50)})
+		if err != nil {
+			t.Fatalf("Failed to inject faults: %v", err)
+		}
+		if state.InteractionFailurePercent != 100 || state.StorageWriteDropPercent != 50 || state.FailedInteractions != 1 {
+			t.Errorf("Expected the previous faults to be kept, got %+v", state)
+		}
+
+		state, err = injectFaults(server, map[string]interface{}{"reset": true})
+		if err != nil {
+			t.Fatalf("Failed to reset faults: %v", err)
+		}
+		if state.InteractionFailurePercent != 0 || state.StorageWriteDropPercent != 0 || state.StorageWriteDelayMs != 0 {
+			t.Errorf("Expected the faults to be reset, got %+v", state)
+		}
+		if err := deviceCommand(); err != nil {
+			t.Errorf("Expected the command to succeed after the reset, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,317 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/clusters"
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// Basic Information attribute IDs identifying the firmware of a node
+const (
+	basicInformationVendorName            = 0x0001
+	basicInformationVendorID              = 0x0002
+	basicInformationProductName           = 0x0003
+	basicInformationProductID             = 0x0004
+	basicInformationHardwareVersionString = 0x0008
+	basicInformationSoftwareVersionString = 0x000A
+)
+
+const (
+	// firmwareCheckDelay postpones the first update check after startup,
+	// so restarts don't query the DCL right away
+	firmwareCheckDelay = time.Minute
+
+	// firmwareCheckTimeout limits checking all nodes for updates
+	firmwareCheckTimeout = 5 * time.Minute
+)
+
+// firmwareCheck is the outcome of the last update check of a node
+type firmwareCheck struct {
+	// Software version the node ran when it was checked
+	softwareVersion int
+	update          *models.MatterSoftwareVersion
+	checkedAt       time.Time
+}
+
+// firmwareChecks holds the last update check of each node
+type firmwareChecks struct {
+	mu    sync.Mutex
+	nodes map[int]firmwareCheck
+}
+
+// firmwareProduct identifies the firmware an update check applies to
+type firmwareProduct struct {
+	vendorID, productID, softwareVersion int
+}
+
+// nodeFirmware returns the firmware a node reports in its Basic Information
+// cluster, without update details
+func nodeFirmware(node *models.MatterNodeData) models.NodeFirmware {
+	attribute := func(id uint32) interface{} {
+		return node.Attributes[attributePath(0, clusters.BasicInformationClusterID, id)]
+	}
+	firmware := models.NodeFirmware{NodeID: node.NodeID, Available: node.Available}
+	firmware.VendorID, _ = toInt(attribute(basicInformationVendorID))
+	firmware.VendorName, _ = attribute(basicInformationVendorName).(string)
+	firmware.ProductID, _ = toInt(attribute(basicInformationProductID))
+	firmware.ProductName, _ = attribute(basicInformationProductName).(string)
+	firmware.HardwareVersionString, _ = attribute(basicInformationHardwareVersionString).(string)
+	firmware.SoftwareVersion, _ = toInt(attribute(basicInformationSoftwareVersion))
+	firmware.SoftwareVersionString, _ = attribute(basicInformationSoftwareVersionString).(string)
+	return firmware
+}
+
+// applyFirmwareCheck adds the update found for a node, unless the node was
+// checked while running a different version
+func applyFirmwareCheck(firmware *models.NodeFirmware, check firmwareCheck, ok bool) {
+	if !ok || check.softwareVersion != firmware.SoftwareVersion {
+		return
+	}
+	checkedAt := check.checkedAt
+	firmware.CheckedAt = &checkedAt
+	firmware.Update = check.update
+	firmware.UpdateAvailable = check.update != nil
+}
+
+// firmwareInventory summarizes the firmware of the nodes by product and
+// software version
+func firmwareInventory(nodes []*models.MatterNodeData, checks map[int]firmwareCheck) *models.FirmwareInventory {
+	inventory := &models.FirmwareInventory{
+		Timestamp: time.Now().UTC(),
+		Versions:  []models.FirmwareVersion{},
+		Nodes:     make([]models.NodeFirmware, 0, len(nodes)),
+	}
+	for _, node := range nodes {
+		firmware := nodeFirmware(node)
+		check, ok := checks[node.NodeID]
+		applyFirmwareCheck(&firmware, check, ok)
+		if firmware.UpdateAvailable {
+			inventory.UpdatesAvailable++
+		}
+		inventory.Nodes = append(inventory.Nodes, firmware)
+	}
+	sort.Slice(inventory.Nodes, func(i, j int) bool {
+		return inventory.Nodes[i].NodeID < inventory.Nodes[j].NodeID
+	})
+
+	versions := make(map[firmwareProduct]int)
+	for _, firmware := range inventory.Nodes {
+		key := firmwareProduct{firmware.VendorID, firmware.ProductID, firmware.SoftwareVersion}
+		i, exists := versions[key]
+		if !exists {
+			i = len(inventory.Versions)
+			versions[key] = i
+			inventory.Versions = append(inventory.Versions, models.FirmwareVersion{
+				VendorID:              firmware.VendorID,
+				VendorName:            firmware.VendorName,
+				ProductID:             firmware.ProductID,
+				ProductName:           firmware.ProductName,
+				SoftwareVersion:       firmware.SoftwareVersion,
+				SoftwareVersionString: firmware.SoftwareVersionString,
+			})
+		}
+		version := &inventory.Versions[i]
+		version.NodeIDs = append(version.NodeIDs, firmware.NodeID)
+		if version.Update == nil {
+			version.Update = firmware.Update
+		}
+	}
+	sort.Slice(inventory.Versions, func(i, j int) bool {
+		a, b := inventory.Versions[i], inventory.Versions[j]
+		if a.VendorID != b.VendorID {
+			return a.VendorID < b.VendorID
+		}
+		if a.ProductID != b.ProductID {
+			return a.ProductID < b.ProductID
+		}
+		return a.SoftwareVersion < b.SoftwareVersion
+	})
+	return inventory
+}
+
+// otaProviderDir returns the effective directory of local software updates
+func (s *Server) otaProviderDir() string {
+	setting, _ := lookupServerSetting("ota.provider_dir")
+	dir, _ := s.serverSettingState(setting).Value.(string)
+	return dir
+}
+
+// recordFirmwareCheck stores the update found for a node and emits
+// firmware_update_available if it is new: the node had no update, ran
+// another version or only an older update was known
+func (s *Server) recordFirmwareCheck(firmware models.NodeFirmware, update *models.MatterSoftwareVersion) {
+	check := firmwareCheck{softwareVersion: firmware.SoftwareVersion, update: update, checkedAt: time.Now().UTC()}
+
+	s.firmware.mu.Lock()
+	if s.firmware.nodes == nil {
+		s.firmware.nodes = make(map[int]firmwareCheck)
+	}
+	previous, checked := s.firmware.nodes[firmware.NodeID]
+	s.firmware.nodes[firmware.NodeID] = check
+	s.firmware.mu.Unlock()
+
+	if update == nil {
+		return
+	}
+	if checked && previous.softwareVersion == check.softwareVersion &&
+		previous.update != nil && previous.update.SoftwareVersion >= update.SoftwareVersion {
+		return
+	}
+
+	applyFirmwareCheck(&firmware, check, true)
+	s.logger.Info("Software update available",
+		logger.Int("node_id", firmware.NodeID),
+		logger.Int("software_version", firmware.SoftwareVersion),
+		logger.String("update", update.SoftwareVersionString),
+		logger.String("update_source", string(update.UpdateSource)),
+	)
+	s.EmitEvent(models.EventTypeFirmwareUpdateAvailable, firmware)
+}
+
+// checkFirmware checks all nodes for software updates. Nodes running the
+// same firmware share a lookup. Lookups that fail are logged and leave the
+// previous result of the nodes in place.
+func (s *Server) checkFirmware(ctx context.Context) {
+	s.nodesMu.RLock()
+	nodes := make([]models.NodeFirmware, 0, len(s.nodes))
+	for _, node := range s.nodes {
+		nodes = append(nodes, nodeFirmware(node))
+	}
+	s.nodesMu.RUnlock()
+
+	// Forget the checks of removed nodes
+	present := make(map[int]bool, len(nodes))
+	for _, firmware := range nodes {
+		present[firmware.NodeID] = true
+	}
+	s.firmware.mu.Lock()
+	for nodeID := range s.firmware.nodes {
+		if !present[nodeID] {
+			delete(s.firmware.nodes, nodeID)
+		}
+	}
+	s.firmware.mu.Unlock()
+
+	dir := s.otaProviderDir()
+	type lookup struct {
+		update *models.MatterSoftwareVersion
+		err    error
+	}
+	lookups := make(map[firmwareProduct]lookup)
+	for _, firmware := range nodes {
+		if firmware.VendorID == 0 || firmware.ProductID == 0 {
+			continue
+		}
+		key := firmwareProduct{firmware.VendorID, firmware.ProductID, firmware.SoftwareVersion}
+		result, done := lookups[key]
+		if !done {
+			result.update, result.err = s.otaChecker.Check(ctx, key.vendorID, key.productID, key.softwareVersion, dir)
+			lookups[key] = result
+			if result.err != nil {
+				s.logger.Warn("Failed to check for software updates",
+					logger.Int("vendor_id", key.vendorID),
+					logger.Int("product_id", key.productID),
+					logger.Int("software_version", key.softwareVersion),
+					logger.ErrorField(result.err),
+				)
+			}
+		}
+		if result.err == nil {
+			s.recordFirmwareCheck(firmware, result.update)
+		}
+	}
+}
+
+// runFirmwareChecks checks all nodes for software updates every
+// ota.check_interval, the first time shortly after startup
+func (s *Server) runFirmwareChecks(ctx context.Context) {
+	timer := time.NewTimer(firmwareCheckDelay)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			checkCtx, cancel := context.WithTimeout(ctx, firmwareCheckTimeout)
+			s.checkFirmware(checkCtx)
+			cancel()
+			timer.Reset(s.config.OTA.CheckInterval)
+		}
+	}
+}
+
+// handleCheckNodeUpdate returns the newest software update applicable to a
+// node, null if it is up to date
+func (s *Server) handleCheckNodeUpdate(ctx context.Context, args commandArgs) (interface{}, error) {
+	nodeID := args.nodeID()
+	s.nodesMu.RLock()
+	node, exists := s.nodes[nodeID]
+	s.nodesMu.RUnlock()
+	if !exists {
+		return nil, &models.NodeNotFoundError{NodeID: nodeID}
+	}
+
+	firmware := nodeFirmware(node)
+	if firmware.VendorID == 0 || firmware.ProductID == 0 {
+		return nil, fmt.Errorf("node %d reports no vendor and product ID", nodeID)
+	}
+	update, err := s.otaChecker.Check(ctx, firmware.VendorID, firmware.ProductID, firmware.SoftwareVersion, s.otaProviderDir())
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for software updates: %w", err)
+	}
+	s.recordFirmwareCheck(firmware, update)
+	return update, nil
+}
+
+// handleGetFirmwareInventory returns the firmware of all nodes with the
+// updates found by the last checks, checking all nodes first if refresh is
+// set
+func (s *Server) handleGetFirmwareInventory(ctx context.Context, args commandArgs) (interface{}, error) {
+	if args.boolean("refresh") {
+		s.checkFirmware(ctx)
+	}
+
+	s.firmware.mu.Lock()
+	checks := make(map[int]firmwareCheck, len(s.firmware.nodes))
+	for nodeID, check := range s.firmware.nodes {
+		checks[nodeID] = check
+	}
+	s.firmware.mu.Unlock()
+
+	s.nodesMu.RLock()
+	defer s.nodesMu.RUnlock()
+	nodes := make([]*models.MatterNodeData, 0, len(s.nodes))
+	for _, node := range s.nodes {
+		nodes = append(nodes, node)
+	}
+	return firmwareInventory(nodes, checks), nil
+}
+
+// handleFirmwareHTTP returns the firmware inventory, checking for updates
+// first with the refresh query parameter
+func (s *Server) handleFirmwareHTTP(w http.ResponseWriter, r *http.Request) {
+	raw := make(map[string]interface{})
+	for name, values := range r.URL.Query() {
+		raw[name] = values[len(values)-1]
+	}
+
+	args, err := validateArgs(models.APICommandGetFirmwareInventory, raw)
+	if err != nil {
+		s.writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	result, err := s.handleGetFirmwareInventory(r.Context(), args)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.writeJSON(w, result)
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/ota"
+)
+
+// firmwareNode returns a node running software version of product
+// 0xFFF1/0x8000
+func firmwareNode(nodeID, version int) *models.MatterNodeData {
+	return &models.MatterNodeData{
+		NodeID:    nodeID,
+		Available: true,
+		Attributes: map[string]interface{}{
+			"0/40/1":  "Nabu Casa",
+			"0/40/2":  float64(0xFFF1),
+			"0/40/3":  "Test Light",
+			"0/40/4":  float64(0x8000),
+			"0/40/8":  "rev1",
+			"0/40/9":  float64(version),
+			"0/40/10": fmt.Sprintf("1.%d", version),
+		},
+	}
+}
+
+// newFirmwareDCL serves versions 2 and 3 of product 0xFFF1/0x8000, both
+// applicable to version 1, and counts the version list lookups
+func newFirmwareDCL(t *testing.T, lookups *atomic.Int32) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/dcl/model/versions/65521/32768")
+		switch path {
+		case "":
+			lookups.Add(1)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"modelVersions": map[string]interface{}{"softwareVersions": []int{1, 2, 3}},
+			})
+		case "/2", "/3":
+			version := int(path[1] - '0')
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"modelVersion": map[string]interface{}{
+					"vid": 0xFFF1, "pid": 0x8000,
+					"softwareVersion":              version,
+					"softwareVersionString":        fmt.Sprintf("1.%d", version),
+					"softwareVersionValid":         true,
+					"otaUrl":                       "https://example.com/update.ota",
+					"minApplicableSoftwareVersion": 0,
+					"maxApplicableSoftwareVersion": version - 1,
+				},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestFirmwareInventory(t *testing.T) {
+	server := createTestServer(t)
+	var lookups atomic.Int32
+	ts := newFirmwareDCL(t, &lookups)
+	server.otaChecker = ota.NewChecker(ts.Client(), []ota.Source{{URL: ts.URL, Origin: models.UpdateSourceMainNetDCL}})
+
+	server.nodes[1] = firmwareNode(1, 1)
+	server.nodes[2] = firmwareNode(2, 1)
+	server.nodes[3] = firmwareNode(3, 3)
+	server.nodes[4] = &models.MatterNodeData{NodeID: 4, Attributes: map[string]interface{}{}}
+
+	updates := make(chan models.NodeFirmware, 10)
+	server.Subscribe(func(eventType models.EventType, data interface{}) {
+		if eventType == models.EventTypeFirmwareUpdateAvailable {
+			updates <- data.(models.NodeFirmware)
+		}
+	})
+
+	// Nothing is known before the first check
+	inventory := runCommand(t, server, models.APICommandGetFirmwareInventory, nil).(*models.FirmwareInventory)
+	if inventory.UpdatesAvailable != 0 || len(inventory.Nodes) != 4 || inventory.Nodes[0].CheckedAt != nil {
+		t.Errorf("Expected an unchecked inventory, got %+v", inventory)
+	}
+
+	inventory = runCommand(t, server, models.APICommandGetFirmwareInventory, map[string]interface{}{"refresh": true}).(*models.FirmwareInventory)
+	if inventory.UpdatesAvailable != 2 {
+		t.Errorf("Expected 2 updates available, got %d", inventory.UpdatesAvailable)
+	}
+	if lookups.Load() != 2 {
+		t.Errorf("Expected one lookup per software version, got %d", lookups.Load())
+	}
+	if len(inventory.Versions) != 3 {
+		t.Fatalf("Expected 3 versions, got %+v", inventory.Versions)
+	}
+	if v := inventory.Versions[1]; v.SoftwareVersion != 1 || len(v.NodeIDs) != 2 || v.Update == nil || v.Update.SoftwareVersion != 3 {
+		t.Errorf("Expected nodes 1 and 2 on version 1 with update 3, got %+v", v)
+	}
+	if v := inventory.Versions[2]; v.SoftwareVersion != 3 || v.Update != nil || v.ProductName != "Test Light" {
+		t.Errorf("Expected node 3 up to date, got %+v", v)
+	}
+	if node := inventory.Nodes[2]; node.UpdateAvailable || node.CheckedAt == nil {
+		t.Errorf("Expected node 3 to be checked without update, got %+v", node)
+	}
+
+	announced := make(map[int]bool)
+	for range 2 {
+		select {
+		case event := <-updates:
+			if !event.UpdateAvailable || event.Update.SoftwareVersionString != "1.3" {
+				t.Errorf("Expected update 1.3, got %+v", event)
+			}
+			announced[event.NodeID] = true
+		case <-time.After(time.Second):
+			t.Fatal("Expected firmware_update_available")
+		}
+	}
+	if !announced[1] || !announced[2] {
+		t.Errorf("Expected the updates of nodes 1 and 2, got %v", announced)
+	}
+
+	// Known updates aren't announced again
+	runCommand(t, server, models.APICommandGetFirmwareInventory, map[string]interface{}{"refresh": true})
+	if update := runCommand(t, server, models.APICommandCheckNodeUpdate, map[string]interface{}{"node_id": float64(1)}); update.(*models.MatterSoftwareVersion).SoftwareVersion != 3 {
+		t.Errorf("Expected update 3 for node 1, got %+v", update)
+	}
+	select {
+	case event := <-updates:
+		t.Errorf("Expected no further event, got %+v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// A node running another version than checked has no known update
+	server.nodes[1] = firmwareNode(1, 3)
+	inventory = runCommand(t, server, models.APICommandGetFirmwareInventory, nil).(*models.FirmwareInventory)
+	if node := inventory.Nodes[0]; node.UpdateAvailable || node.CheckedAt != nil || inventory.UpdatesAvailable != 1 {
+		t.Errorf("Expected node 1 to be unchecked after its update, got %+v", node)
+	}
+
+	rec := httptest.NewRecorder()
+	server.setupRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/firmware", nil))
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || rec.Code != http.StatusOK || body["updates_available"] != float64(1) {
+		t.Errorf("Expected the inventory, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestCheckNodeUpdate(t *testing.T) {
+	ctx := context.Background()
+	server := createTestServer(t)
+	var lookups atomic.Int32
+	ts := newFirmwareDCL(t, &lookups)
+	server.otaChecker = ota.NewChecker(ts.Client(), []ota.Source{{URL: ts.URL, Origin: models.UpdateSourceMainNetDCL}})
+	server.nodes[3] = firmwareNode(3, 3)
+	server.nodes[4] = &models.MatterNodeData{NodeID: 4, Attributes: map[string]interface{}{}}
+
+	checkNodeUpdate := func(nodeID int) (interface{}, error) {
+		return server.HandleCommand(ctx, models.CommandMessage{
+			MessageID: "1",
+			Command:   string(models.APICommandCheckNodeUpdate),
+			Args:      map[string]interface{}{"node_id": float64(nodeID)},
+		})
+	}
+
+	update, err := checkNodeUpdate(3)
+	if err != nil || update.(*models.MatterSoftwareVersion) != nil {
+		t.Errorf("Expected node 3 to be up to date, got %+v, %v", update, err)
+	}
+	if _, err := checkNodeUpdate(4); err == nil {
+		t.Error("Expected an error for a node without vendor and product ID")
+	}
+	if _, err := checkNodeUpdate(9); err == nil {
+		t.Error("Expected an error for an unknown node")
+	}
+}
@@ -0,0 +1,249 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/codefionn/go-matter-server/internal/clusters"
+	"github.com/codefionn/go-matter-server/internal/controller"
+	"github.com/codefionn/go-matter-server/internal/groups"
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/progress"
+)
+
+// Group related command and attribute IDs
+const (
+	groupsCommandAddGroup       = 0x00
+	groupsCommandRemoveGroup    = 0x03
+	groupKeyManagementKeySet    = 0x00
+	groupKeyManagementGroupMap  = 0x0000
+	groupKeySecurityTrustFirst  = 0
+	groupKeyMapFieldGroupID     = "1"
+	groupKeyMapFieldGroupIDName = "groupId"
+)
+
+func (s *Server) handleCreateGroup(args commandArgs) (interface{}, error) {
+	name := args.str("name")
+	group, err := s.groups.Create(args.uint16("group_id"), name)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Group created", logger.Int("group_id", int(group.GroupID)), logger.String("name", name))
+	return group.Public(), nil
+}
+
+func (s *Server) handleRemoveGroup(ctx context.Context, args commandArgs) (interface{}, error) {
+	groupID := args.uint16("group_id")
+	group, err := s.groups.Get(groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remove the group from member endpoints; unreachable members keep a
+	// stale entry that is harmless without the group key
+	for _, member := range group.Members {
+		if err := s.removeGroupFromEndpoint(ctx, group, member); err != nil {
+			s.logger.Warn("Failed to remove group from node",
+				logger.Int("group_id", int(groupID)),
+				logger.Int("node_id", member.NodeID),
+				logger.ErrorField(err),
+			)
+		}
+	}
+
+	if err := s.groups.Remove(groupID); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Group removed", logger.Int("group_id", int(groupID)))
+	return nil, nil
+}
+
+func (s *Server) handleGetGroups() (interface{}, error) {
+	list := s.groups.List()
+	for i, group := range list {
+		list[i] = group.Public()
+	}
+	return list, nil
+}
+
+func (s *Server) handleAddGroupMember(ctx context.Context, args commandArgs) (interface{}, error) {
+	group, member, err := s.parseGroupMember(args)
+	if err != nil {
+		return nil, err
+	}
+
+	// Provision the group key set on the node
+	progress.Report(ctx, "writing_key_set", 0)
+	_, err = s.controller.SendCommand(ctx, controller.CommandRequest{
+		NodeID:     member.NodeID,
+		EndpointID: 0,
+		ClusterID:  clusters.GroupKeyManagementClusterID,
+		CommandID:  groupKeyManagementKeySet,
+		Payload: map[string]interface{}{
+			"groupKeySet": map[string]interface{}{
+				"groupKeySetID":          group.KeySetID,
+				"groupKeySecurityPolicy": groupKeySecurityTrustFirst,
+				"epochKey0":              group.EpochKey,
+				"epochStartTime0":        group.EpochStartTime,
+				"epochKey1":              nil,
+				"epochStartTime1":        nil,
+				"epochKey2":              nil,
+				"epochStartTime2":        nil,
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to write group key set: %w", err)
+	}
+
+	// Map the group to the key set, keeping existing entries of other groups
+	progress.Report(ctx, "writing_key_map", 33)
+	keyMap := s.groupKeyMapWith(member.NodeID, group)
+	path := attributePath(0, clusters.GroupKeyManagementClusterID, groupKeyManagementGroupMap)
+	err = s.controller.WriteAttribute(ctx, controller.WriteRequest{
+		NodeID: member.NodeID,
+		Path:   path,
+		Value:  keyMap,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to write group key map: %w", err)
+	}
+
+	// Add the endpoint to the group
+	progress.Report(ctx, "adding_endpoint", 67)
+	_, err = s.controller.SendCommand(ctx, controller.CommandRequest{
+		NodeID:     member.NodeID,
+		EndpointID: member.EndpointID,
+		ClusterID:  clusters.GroupsClusterID,
+		CommandID:  groupsCommandAddGroup,
+		Payload: map[string]interface{}{
+			"groupID":   group.GroupID,
+			"groupName": group.Name,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to add endpoint to group: %w", err)
+	}
+
+	if err := s.groups.AddMember(group.GroupID, member); err != nil {
+		return nil, err
+	}
+	progress.Report(ctx, "completed", 100)
+	return nil, nil
+}
+
+func (s *Server) handleRemoveGroupMember(ctx context.Context, args commandArgs) (interface{}, error) {
+	group, member, err := s.parseGroupMember(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.removeGroupFromEndpoint(ctx, group, member); err != nil {
+		return nil, fmt.Errorf("failed to remove endpoint from group: %w", err)
+	}
+
+	if err := s.groups.RemoveMember(group.GroupID, member); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+func (s *Server) handleGroupCommand(ctx context.Context, args commandArgs) (interface{}, error) {
+	groupID := args.uint16("group_id")
+	clusterID, commandID, err := args.clusterCommand()
+	if err != nil {
+		return nil, err
+	}
+
+	group, err := s.groups.Get(groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	operationalKey, err := group.OperationalKey(s.credentials.CompressedFabricID())
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive group key: %w", err)
+	}
+	sessionID, err := groups.SessionID(operationalKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive group session ID: %w", err)
+	}
+
+	err = s.controller.SendGroupCommand(ctx, controller.GroupCommandRequest{
+		GroupID:        groupID,
+		ClusterID:      clusterID,
+		CommandID:      commandID,
+		Payload:        args.object("payload"),
+		Address:        groups.MulticastAddress(s.credentials.FabricID(), groupID),
+		SessionID:      sessionID,
+		OperationalKey: operationalKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// parseGroupMember resolves the group_id, node_id and endpoint_id arguments
+func (s *Server) parseGroupMember(args commandArgs) (*groups.Group, groups.Member, error) {
+	nodeID := args.nodeID()
+	endpointID := args.uint16("endpoint_id")
+
+	group, err := s.groups.Get(args.uint16("group_id"))
+	if err != nil {
+		return nil, groups.Member{}, err
+	}
+
+	s.nodesMu.RLock()
+	_, exists := s.nodes[nodeID]
+	s.nodesMu.RUnlock()
+
+	if !exists {
+		return nil, groups.Member{}, &models.NodeNotFoundError{NodeID: nodeID}
+	}
+
+	return group, groups.Member{NodeID: nodeID, EndpointID: endpointID}, nil
+}
+
+func (s *Server) removeGroupFromEndpoint(ctx context.Context, group *groups.Group, member groups.Member) error {
+	_, err := s.controller.SendCommand(ctx, controller.CommandRequest{
+		NodeID:     member.NodeID,
+		EndpointID: member.EndpointID,
+		ClusterID:  clusters.GroupsClusterID,
+		CommandID:  groupsCommandRemoveGroup,
+		Payload:    map[string]interface{}{"groupID": group.GroupID},
+	})
+	return err
+}
+
+// groupKeyMapWith returns the node's GroupKeyMap with an entry mapping the
+// group to its key set
+func (s *Server) groupKeyMapWith(nodeID int, group *groups.Group) []interface{} {
+	s.nodesMu.RLock()
+	existing, _ := s.nodes[nodeID].Attributes[attributePath(0, clusters.GroupKeyManagementClusterID, groupKeyManagementGroupMap)].([]interface{})
+	s.nodesMu.RUnlock()
+
+	keyMap := make([]interface{}, 0, len(existing)+1)
+	for _, entry := range existing {
+		fields, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		id, ok := toInt(fields[groupKeyMapFieldGroupID])
+		if !ok {
+			id, _ = toInt(fields[groupKeyMapFieldGroupIDName])
+		}
+		if id == int(group.GroupID) {
+			continue
+		}
+		keyMap = append(keyMap, entry)
+	}
+
+	return append(keyMap, map[string]interface{}{
+		groupKeyMapFieldGroupIDName: group.GroupID,
+		"groupKeySetID":             group.KeySetID,
+	})
+}
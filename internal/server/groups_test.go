@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/codefionn/go-matter-server/internal/clusters"
+	"github.com/codefionn/go-matter-server/internal/controller"
+	"github.com/codefionn/go-matter-server/internal/groups"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// groupController records attribute writes and group commands in addition
+// to unicast commands
+type groupController struct {
+	fakeController
+	writes        map[string]interface{}
+	groupCommands []controller.GroupCommandRequest
+}
+
+func (c *groupController) WriteAttribute(ctx context.Context, req controller.WriteRequest) error {
+	if c.writes == nil {
+		c.writes = make(map[string]interface{})
+	}
+	c.writes[req.Path] = req.Value
+	return nil
+}
+
+func (c *groupController) SendGroupCommand(ctx context.Context, req controller.GroupCommandRequest) error {
+	c.groupCommands = append(c.groupCommands, req)
+	return nil
+}
+
+func runCommand(t *testing.T, server *Server, command models.APICommand, args map[string]interface{}) interface{} {
+	t.Helper()
+	result, err := server.HandleCommand(context.Background(), models.CommandMessage{
+		Command: string(command),
+		Args:    args,
+	})
+	if err != nil {
+		t.Fatalf("%s failed: %v", command, err)
+	}
+	return result
+}
+
+func TestGroupLifecycle(t *testing.T) {
+	server := createTestServer(t)
+	fake := &groupController{}
+	server.controller = fake
+	server.nodes[5] = &models.MatterNodeData{
+		NodeID: 5,
+		Attributes: map[string]interface{}{
+			"0/63/0": []interface{}{
+				map[string]interface{}{"1": float64(0x0200), "2": float64(0x0200)},
+				map[string]interface{}{"1": float64(0x0100), "2": float64(0x0099)},
+			},
+		},
+	}
+
+	result := runCommand(t, server, models.APICommandCreateGroup, map[string]interface{}{"name": "Lights", "group_id": float64(0x0100)})
+	group, ok := result.(*groups.Group)
+	if !ok || group.GroupID != 0x0100 {
+		t.Fatalf("Expected group 0x0100, got %v", result)
+	}
+	if group.EpochKey != nil {
+		t.Error("Expected create_group response without epoch key")
+	}
+
+	member := map[string]interface{}{"group_id": float64(0x0100), "node_id": float64(5), "endpoint_id": float64(1)}
+	runCommand(t, server, models.APICommandAddGroupMember, member)
+
+	if len(fake.commands) != 2 {
+		t.Fatalf("Expected KeySetWrite and AddGroup commands, got %v", fake.commands)
+	}
+	if fake.commands[0].ClusterID != clusters.GroupKeyManagementClusterID || fake.commands[0].EndpointID != 0 {
+		t.Errorf("Expected KeySetWrite on endpoint 0, got %+v", fake.commands[0])
+	}
+	if fake.commands[1].ClusterID != clusters.GroupsClusterID || fake.commands[1].EndpointID != 1 {
+		t.Errorf("Expected AddGroup on endpoint 1, got %+v", fake.commands[1])
+	}
+
+	keyMap, _ := fake.writes["0/63/0"].([]interface{})
+	if len(keyMap) != 2 {
+		t.Errorf("Expected stale entry replaced and other group kept, got %v", keyMap)
+	}
+
+	stored, _ := server.groups.Get(0x0100)
+	if len(stored.Members) != 1 || stored.Members[0].NodeID != 5 {
+		t.Errorf("Expected node 5 as member, got %v", stored.Members)
+	}
+
+	runCommand(t, server, models.APICommandGroupCommand, map[string]interface{}{
+		"group_id": float64(0x0100), "cluster": "OnOff", "command_name": "Toggle",
+	})
+	if len(fake.groupCommands) != 1 {
+		t.Fatalf("Expected 1 group command, got %d", len(fake.groupCommands))
+	}
+	sent := fake.groupCommands[0]
+	if sent.ClusterID != 6 || sent.CommandID != 2 || !sent.Address.IsMulticast() || len(sent.OperationalKey) != 16 {
+		t.Errorf("Unexpected group command %+v", sent)
+	}
+
+	runCommand(t, server, models.APICommandRemoveGroup, map[string]interface{}{"group_id": float64(0x0100)})
+	if last := fake.commands[len(fake.commands)-1]; last.ClusterID != clusters.GroupsClusterID || last.CommandID != groupsCommandRemoveGroup {
+		t.Errorf("Expected RemoveGroup command for member, got %+v", last)
+	}
+
+	list, _ := runCommand(t, server, models.APICommandGetGroups, nil).([]*groups.Group)
+	if len(list) != 0 {
+		t.Errorf("Expected no groups, got %v", list)
+	}
+}
+
+func TestAddGroupMemberErrors(t *testing.T) {
+	server := createTestServer(t)
+	server.controller = &groupController{}
+	server.nodes[5] = &models.MatterNodeData{NodeID: 5, Attributes: map[string]interface{}{}}
+	server.groups.Create(0x0100, "Lights")
+
+	tests := []struct {
+		name string
+		args map[string]interface{}
+	}{
+		{"Unknown group", map[string]interface{}{"group_id": float64(0x0200), "node_id": float64(5), "endpoint_id": float64(1)}},
+		{"Unknown node", map[string]interface{}{"group_id": float64(0x0100), "node_id": float64(6), "endpoint_id": float64(1)}},
+		{"Missing endpoint", map[string]interface{}{"group_id": float64(0x0100), "node_id": float64(5)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := server.HandleCommand(context.Background(), models.CommandMessage{
+				Command: string(models.APICommandAddGroupMember),
+				Args:    tt.args,
+			})
+			if err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/codefionn/go-matter-server/internal/grpcapi"
+	"github.com/codefionn/go-matter-server/internal/logger"
+)
+
+// startGRPCServer serves the gRPC API, which runs its calls through
+// HandleCommand like the WebSocket
+func (s *Server) startGRPCServer() error {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", s.config.Server.GRPCPort))
+	if err != nil {
+		return fmt.Errorf("failed to listen on gRPC port: %w", err)
+	}
+
+	s.grpcServer = grpcapi.NewServer(s, s.logger)
+	go func() {
+		if err := s.grpcServer.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("gRPC server failed", logger.ErrorField(err))
+		}
+	}()
+
+	s.logger.Info("gRPC API enabled", logger.String("address", listener.Addr().String()))
+	return nil
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// Subsystems the server must have started to be ready
+const (
+	subsystemStorage = "storage"
+	subsystemMDNS    = "mdns"
+	// subsystemMatter covers the fabric state: commissioned nodes and PAA
+	// root certificates
+	subsystemMatter = "matter"
+)
+
+// healthTracker records the readiness of the server's subsystems
+type healthTracker struct {
+	mu         sync.RWMutex
+	subsystems map[string]models.SubsystemStatus
+	stopping   bool
+}
+
+// newHealthTracker tracks enabled subsystems that are not ready yet
+func newHealthTracker(names ...string) *healthTracker {
+	h := &healthTracker{subsystems: make(map[string]models.SubsystemStatus, len(names))}
+	for _, name := range names {
+		h.subsystems[name] = models.SubsystemStatus{Enabled: true}
+	}
+	return h
+}
+
+// disable marks a subsystem as turned off by configuration, so it doesn't
+// hold back readiness
+func (h *healthTracker) disable(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.subsystems[name] = models.SubsystemStatus{Enabled: false, Ready: true}
+}
+
+// setReady marks a subsystem as started, keeping its last error
+func (h *healthTracker) setReady(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	status := h.subsystems[name]
+	status.Ready = true
+	h.subsystems[name] = status
+}
+
+// setError marks a subsystem as failed
+func (h *healthTracker) setError(name string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now().UTC()
+	status := h.subsystems[name]
+	status.Ready = false
+	status.LastError = err.Error()
+	status.LastErrorAt = &now
+	h.subsystems[name] = status
+}
+
+// stop marks the server as shutting down, which makes it unready
+func (h *healthTracker) stop() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.stopping = true
+}
+
+// status returns the readiness of the server and its subsystems
+func (h *healthTracker) status() (models.HealthStatus, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	health := models.HealthStatus{
+		Status:     "ready",
+		Timestamp:  time.Now().UTC(),
+		Subsystems: make(map[string]models.SubsystemStatus, len(h.subsystems)),
+	}
+	ready := true
+	for name, status := range h.subsystems {
+		health.Subsystems[name] = status
+		ready = ready && status.Ready
+	}
+
+	switch {
+	case h.stopping:
+		health.Status = "shutting_down"
+		ready = false
+	case !ready:
+		health.Status = "not_ready"
+	}
+	return health, ready
+}
+
+// handleHealthLive is the liveness probe. It succeeds as long as the server
+// answers HTTP requests.
+func (s *Server) handleHealthLive(w http.ResponseWriter, r *http.Request) {
+	s.nodesMu.RLock()
+	nodeCount := len(s.nodes)
+	s.nodesMu.RUnlock()
+
+	s.writeJSON(w, map[string]interface{}{
+		"status":      "ok",
+		"timestamp":   time.Now().UTC(),
+		"connections": s.wsHandler.GetConnectionCount(),
+		"nodes":       nodeCount,
+	})
+}
+
+// handleHealthReady is the readiness probe. It returns 503 until storage,
+// mDNS and the Matter fabric state are up, and again while shutting down.
+func (s *Server) handleHealthReady(w http.ResponseWriter, r *http.Request) {
+	health, ready := s.health.status()
+
+	w.Header().Set("Content-Type", "application/json")
+	if !ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(health); err != nil {
+		s.logger.Error("Failed to encode health response", logger.ErrorField(err))
+	}
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+func TestHealthTracker(t *testing.T) {
+	h := newHealthTracker(subsystemStorage, subsystemMDNS, subsystemMatter)
+	h.disable(subsystemMDNS)
+
+	if health, ready := h.status(); ready || health.Status != "not_ready" {
+		t.Errorf("Expected not ready before start, got %s", health.Status)
+	}
+
+	h.setReady(subsystemStorage)
+	h.setError(subsystemMatter, errors.New("failed to load nodes"))
+	health, ready := h.status()
+	if ready {
+		t.Error("Expected not ready with failed subsystem")
+	}
+	matter := health.Subsystems[subsystemMatter]
+	if matter.Ready || matter.LastError != "failed to load nodes" || matter.LastErrorAt == nil {
+		t.Errorf("Expected failed matter subsystem, got %+v", matter)
+	}
+	if mdns := health.Subsystems[subsystemMDNS]; mdns.Enabled || !mdns.Ready {
+		t.Errorf("Expected disabled mDNS to be ready, got %+v", mdns)
+	}
+
+	// Recovery keeps the last error for troubleshooting
+	h.setReady(subsystemMatter)
+	health, ready = h.status()
+	if !ready || health.Status != "ready" {
+		t.Errorf("Expected ready, got %s", health.Status)
+	}
+	if health.Subsystems[subsystemMatter].LastError == "" {
+		t.Error("Expected last error to be kept")
+	}
+
+	h.stop()
+	if health, ready := h.status(); ready || health.Status != "shutting_down" {
+		t.Errorf("Expected shutting down, got %s", health.Status)
+	}
+}
+
+func TestHealthProbes(t *testing.T) {
+	server := createTestServer(t)
+	router := server.setupRouter()
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	if w := get("/health/live"); w.Code != http.StatusOK {
+		t.Errorf("Expected live status 200, got %d", w.Code)
+	}
+
+	// Run hasn't started the subsystems
+	w := get("/health/ready")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected ready status 503, got %d", w.Code)
+	}
+	var health models.HealthStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &health); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if _, ok := health.Subsystems[subsystemStorage]; !ok {
+		t.Errorf("Expected storage subsystem, got %+v", health.Subsystems)
+	}
+
+	for _, name := range []string{subsystemStorage, subsystemMDNS, subsystemMatter} {
+		server.health.setReady(name)
+	}
+	if w := get("/health/ready"); w.Code != http.StatusOK {
+		t.Errorf("Expected ready status 200, got %d: %s", w.Code, w.Body.String())
+	}
+}
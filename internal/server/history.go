@@ -0,0 +1,187 @@
+package server
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/websocket"
+)
+
+// historyEventTypes are the events kept in the event history
+var historyEventTypes = map[models.EventType]bool{
+	models.EventTypeNodeAdded:        true,
+	models.EventTypeAttributeUpdated: true,
+	models.EventTypeNodeEvent:        true,
+}
+
+// recordEvent adds an emitted event to the event history and attribute
+// updates to the attribute history
+func (s *Server) recordEvent(eventType models.EventType, data interface{}) {
+	if eventType == models.EventTypeAttributeUpdated {
+		s.recordAttributeUpdate(data)
+	}
+	if !historyEventTypes[eventType] {
+		return
+	}
+
+	// Marshal now, the data may be changed after the event was emitted
+	raw, err := json.Marshal(data)
+	if err != nil {
+		s.logger.Warn("Failed to encode event for the history", logger.String("event", string(eventType)), logger.ErrorField(err))
+		return
+	}
+	entry := &models.EventHistoryEntry{
+		Timestamp: time.Now().UTC(),
+		Event:     eventType,
+		Data:      raw,
+	}
+	if nodeID, ok := websocket.EventNodeID(data); ok {
+		entry.NodeID = nodeID
+	}
+
+	if err := s.storage.AddEvent(entry); err != nil {
+		s.logger.Warn("Failed to store event", logger.String("event", string(eventType)), logger.ErrorField(err))
+	}
+}
+
+// handleGetEventHistory returns the stored events matching the filters,
+// oldest first. With limit only the newest events are returned.
+func (s *Server) handleGetEventHistory(args commandArgs) (interface{}, error) {
+	events, err := s.storage.GetEvents()
+	if err != nil {
+		return nil, err
+	}
+
+	eventTypes := make(map[models.EventType]bool)
+	for _, name := range args.stringList("events") {
+		eventType := models.EventType(name)
+		if !historyEventTypes[eventType] {
+			return nil, &models.ArgumentError{Field: "events", Reason: "event " + name + " is not kept in the history"}
+		}
+		eventTypes[eventType] = true
+	}
+	since, until := args.time("since"), args.time("until")
+	if !since.IsZero() && !until.IsZero() && until.Before(since) {
+		return nil, &models.ArgumentError{Field: "until", Reason: "must not be before since"}
+	}
+
+	matching := make([]*models.EventHistoryEntry, 0, len(events))
+	for _, entry := range events {
+		switch {
+		case args.has("node_id") && entry.NodeID != args.nodeID():
+		case len(eventTypes) > 0 && !eventTypes[entry.Event]:
+		case !since.IsZero() && entry.Timestamp.Before(since):
+		case !until.IsZero() && entry.Timestamp.After(until):
+		default:
+			matching = append(matching, entry)
+		}
+	}
+
+	if limit := int(args.integer("limit")); limit > 0 && len(matching) > limit {
+		matching = matching[len(matching)-limit:]
+	}
+	return matching, nil
+}
+
+// recentEvents returns the event history for the diagnostics
+func (s *Server) recentEvents() []models.EventHistoryEntry {
+	events, err := s.storage.GetEvents()
+	if err != nil {
+		s.logger.Warn("Failed to read event history", logger.ErrorField(err))
+	}
+
+	entries := make([]models.EventHistoryEntry, len(events))
+	for i, entry := range events {
+		entries[i] = *entry
+	}
+	return entries
+}
+
+// recordAttributes adds the values of the attributes selected by
+// storage.attribute_history_paths, and the energy attributes with
+// energy.record_history, to the attribute history
+func (s *Server) recordAttributes(nodeID int, attributes map[string]interface{}) {
+	if len(s.config.Storage.AttributeHistoryPaths) == 0 && !s.config.Energy.RecordHistory {
+		return
+	}
+
+	now := time.Now()
+	for path, value := range attributes {
+		if !s.attributeRecorded(path) {
+			continue
+		}
+		if err := s.storage.RecordAttribute(nodeID, path, value, now); err != nil {
+			s.logger.Warn("Failed to record attribute value",
+				logger.Int("node_id", nodeID),
+				logger.String("attribute_path", path),
+				logger.ErrorField(err),
+			)
+		}
+	}
+}
+
+// attributeRecorded reports whether the values of an attribute path are
+// recorded
+func (s *Server) attributeRecorded(path string) bool {
+	if s.config.Energy.RecordHistory && energyAttribute(path) {
+		return true
+	}
+	for _, pattern := range s.config.Storage.AttributeHistoryPaths {
+		if matchAttributePath(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordAttributeUpdate records the value of an attribute_updated event,
+// which is [node_id, attribute_path, value]
+func (s *Server) recordAttributeUpdate(data interface{}) {
+	update, ok := data.([]interface{})
+	if !ok || len(update) != 3 {
+		return
+	}
+	nodeID, ok := websocket.EventNodeID(data)
+	path, isPath := update[1].(string)
+	if !ok || !isPath {
+		return
+	}
+	s.recordAttributes(nodeID, map[string]interface{}{path: update[2]})
+}
+
+// handleGetAttributeHistory returns the recorded values of an attribute,
+// optionally limited to a time range
+func (s *Server) handleGetAttributeHistory(args commandArgs) (interface{}, error) {
+	nodeID := args.nodeID()
+	path := args.str("attribute_path")
+	if strings.Count(path, "/") != 2 || strings.Contains(path, "*") {
+		return nil, &models.ArgumentError{Field: "attribute_path", Reason: "must be endpoint/cluster/attribute"}
+	}
+	if !s.attributeRecorded(path) {
+		return nil, &models.ArgumentError{Field: "attribute_path", Reason: "not recorded, add it to storage.attribute_history_paths"}
+	}
+
+	since, until := args.time("since"), args.time("until")
+	if !since.IsZero() && !until.IsZero() && until.Before(since) {
+		return nil, &models.ArgumentError{Field: "until", Reason: "must not be before since"}
+	}
+
+	samples, err := s.storage.GetAttributeHistory(nodeID, path)
+	if err != nil {
+		return nil, err
+	}
+	history := &models.AttributeHistory{
+		NodeID:        nodeID,
+		AttributePath: path,
+		Samples:       make([]models.AttributeSample, 0, len(samples)),
+	}
+	for _, sample := range samples {
+		if (since.IsZero() || !sample.Timestamp.Before(since)) && (until.IsZero() || !sample.Timestamp.After(until)) {
+			history.Samples = append(history.Samples, sample)
+		}
+	}
+	return history, nil
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/storage"
+)
+
+func TestGetEventHistory(t *testing.T) {
+	server := createTestServer(t)
+	// The test config disables the history
+	server.storage.(*storage.JSONStorage).SetEventHistorySize(10)
+
+	server.EmitEvent(models.EventTypeNodeAdded, &models.MatterNodeData{NodeID: 1})
+	server.EmitEvent(models.EventTypeAttributeUpdated, []interface{}{1, "1/6/0", true})
+	server.EmitEvent(models.EventTypeNodeEvent, models.MatterNodeEvent{NodeID: 2, EventID: 1})
+	// Not kept in the history
+	server.EmitEvent(models.EventTypeServerShutdown, nil)
+
+	history := func(args map[string]interface{}) []*models.EventHistoryEntry {
+		t.Helper()
+		result, err := server.HandleCommand(context.Background(), models.CommandMessage{
+			MessageID: "1",
+			Command:   string(models.APICommandGetEventHistory),
+			Args:      args,
+		})
+		if err != nil {
+			t.Fatalf("get_event_history failed: %v", err)
+		}
+		return result.([]*models.EventHistoryEntry)
+	}
+
+	if events := history(nil); len(events) != 3 || events[0].Event != models.EventTypeNodeAdded {
+		t.Errorf("Expected 3 events oldest first, got %+v", events)
+	}
+	if events := history(map[string]interface{}{"node_id": 1}); len(events) != 2 {
+		t.Errorf("Expected 2 events of node 1, got %+v", events)
+	}
+	events := history(map[string]interface{}{"events": "attribute_updated"})
+	if len(events) != 1 || string(events[0].Data) != `[1,"1/6/0",true]` {
+		t.Errorf("Expected the attribute update, got %+v", events)
+	}
+	if events := history(map[string]interface{}{"limit": 1}); len(events) != 1 || events[0].NodeID != 2 {
+		t.Errorf("Expected the newest event, got %+v", events)
+	}
+	future := time.Now().Add(time.Hour).Format(time.RFC3339)
+	if events := history(map[string]interface{}{"since": future}); len(events) != 0 {
+		t.Errorf("Expected no events after %s, got %+v", future, events)
+	}
+
+	for _, args := range []map[string]interface{}{
+		{"events": "server_shutdown"},
+		{"since": "yesterday"},
+		{"since": future, "until": "2020-01-01T00:00:00Z"},
+	} {
+		_, err := server.HandleCommand(context.Background(), models.CommandMessage{
+			MessageID: "2",
+			Command:   string(models.APICommandGetEventHistory),
+			Args:      args,
+		})
+		if _, ok := err.(*models.ArgumentError); !ok {
+			t.Errorf("Expected argument error for %v, got %v", args, err)
+		}
+	}
+
+	diagnostics, err := server.handleServerDiagnostics()
+	if err != nil {
+		t.Fatalf("Failed to get diagnostics: %v", err)
+	}
+	if events := diagnostics.(models.ServerDiagnostics).Events; len(events) != 3 {
+		t.Errorf("Expected the event history in the diagnostics, got %+v", events)
+	}
+}
+
+func TestGetAttributeHistory(t *testing.T) {
+	server := createTestServer(t)
+	server.config.Storage.AttributeHistoryPaths = []string{"*/1026/0"}
+
+	node := &models.MatterNodeData{NodeID: 5, Attributes: map[string]interface{}{
+		"1/1026/0": float64(2150),
+		"1/6/0":    true,
+	}}
+	if err := server.applyNodeUpdate(node); err != nil {
+		t.Fatalf("Failed to add node: %v", err)
+	}
+	server.EmitEvent(models.EventTypeAttributeUpdated, []interface{}{5, "1/1026/0", float64(2200)})
+
+	history := func(args map[string]interface{}) (*models.AttributeHistory, error) {
+		result, err := server.HandleCommand(context.Background(), models.CommandMessage{
+			MessageID: "1",
+			Command:   string(models.APICommandGetAttributeHistory),
+			Args:      args,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return result.(*models.AttributeHistory), nil
+	}
+
+	result, err := history(map[string]interface{}{"node_id": 5, "attribute_path": "1/1026/0"})
+	if err != nil {
+		t.Fatalf("get_attribute_history failed: %v", err)
+	}
+	// The test config doesn't downsample
+	if len(result.Samples) != 2 || result.Samples[1].Value != float64(2200) {
+		t.Errorf("Expected 2 samples, got %+v", result.Samples)
+	}
+
+	future := time.Now().Add(time.Hour).Format(time.RFC3339)
+	result, err = history(map[string]interface{}{"node_id": 5, "attribute_path": "1/1026/0", "since": future})
+	if err != nil || len(result.Samples) != 0 {
+		t.Errorf("Expected no samples after %s, got %+v (%v)", future, result, err)
+	}
+
+	for _, path := range []string{"1/6/0", "1/1026/*"} {
+		_, err := history(map[string]interface{}{"node_id": 5, "attribute_path": path})
+		if _, ok := err.(*models.ArgumentError); !ok {
+			t.Errorf("Expected argument error for %s, got %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/clusters"
+	"github.com/codefionn/go-matter-server/internal/controller"
+	"github.com/codefionn/go-matter-server/internal/icd"
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+const (
+	// maxQueuedInteractions limits the interactions queued per sleeping node
+	maxQueuedInteractions = 32
+
+	// icdStayActiveDuration is requested from a node that checked in while
+	// interactions are queued for it
+	icdStayActiveDuration = 30 * time.Second
+
+	// icdClientPermanent is the ClientType of a RegisterClient
+	icdClientPermanent = 0
+)
+
+// queuedInteraction is a command or write waiting for a sleeping node
+type queuedInteraction struct {
+	info    models.QueuedInteraction
+	command *controller.CommandRequest
+	write   *controller.WriteRequest
+}
+
+// icdQueues holds the interactions queued for sleeping nodes and until when
+// the nodes listen after their last check-in
+type icdQueues struct {
+	mu          sync.Mutex
+	pending     map[int][]*queuedInteraction
+	activeUntil map[int]time.Time
+}
+
+func newICDQueues() *icdQueues {
+	return &icdQueues{
+		pending:     make(map[int][]*queuedInteraction),
+		activeUntil: make(map[int]time.Time),
+	}
+}
+
+// tagICD sets the ICD info of a node from its ICD Management cluster, keeping
+// the check-in state of the known node
+func (s *Server) tagICD(node *models.MatterNodeData) {
+	info := icd.Parse(node.Attributes)
+	node.ICD = info
+	node.Sleepy = info != nil && info.OperatingMode == icd.ModeLIT
+	if info == nil {
+		return
+	}
+
+	_, err := s.icd.Get(node.NodeID)
+	info.Registered = err == nil
+
+	s.nodesMu.RLock()
+	if previous, exists := s.nodes[node.NodeID]; exists && previous.ICD != nil {
+		info.LastCheckIn = previous.ICD.LastCheckIn
+	}
+	s.nodesMu.RUnlock()
+}
+
+// registerICDClient registers the server for the Check-In messages of a node
+// that supports them. Without a controller receiving check-ins or a
+// matter.controller_node_id to send them to, there is nothing to register.
+func (s *Server) registerICDClient(ctx context.Context, nodeID int) {
+	checkInNodeID := s.config.Matter.ControllerNodeID
+	if _, ok := s.controller.(controller.CheckInReceiver); !ok || checkInNodeID == 0 {
+		return
+	}
+
+	s.nodesMu.RLock()
+	node, exists := s.nodes[nodeID]
+	s.nodesMu.RUnlock()
+	if !exists || node.ICD == nil || !node.ICD.CheckIn || node.ICD.Registered {
+		return
+	}
+
+	if err := s.sendRegisterClient(ctx, nodeID, checkInNodeID); err != nil {
+		s.logger.Warn("Failed to register for ICD check-ins", logger.Int("node_id", nodeID), logger.ErrorField(err))
+		return
+	}
+	s.logger.Info("Registered for ICD check-ins", logger.Int("node_id", nodeID))
+
+	if err := s.updateICDNode(nodeID, func(node *models.MatterNodeData) {
+		node.ICD.Registered = true
+	}); err != nil {
+		s.logger.Error("Failed to update node", logger.Int("node_id", nodeID), logger.ErrorField(err))
+	}
+}
+
+func (s *Server) sendRegisterClient(ctx context.Context, nodeID int, checkInNodeID uint64) error {
+	key, err := icd.NewKey()
+	if err != nil {
+		return err
+	}
+
+	response, err := s.controller.SendCommand(ctx, controller.CommandRequest{
+		NodeID:     nodeID,
+		EndpointID: 0,
+		ClusterID:  clusters.ICDManagementClusterID,
+		CommandID:  icd.CommandRegisterClient,
+		Payload: map[string]interface{}{
+			"checkInNodeID":    checkInNodeID,
+			"monitoredSubject": checkInNodeID,
+			"key":              key,
+			"clientType":       icdClientPermanent,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// RegisterClientResponse holds the current ICDCounter, check-ins count
+	// up from it
+	var counter int
+	if fields, ok := response.(map[string]interface{}); ok {
+		counter, _ = toInt(structField(fields, "0", "icdCounter"))
+	}
+
+	return s.icd.Add(icd.Registration{
+		NodeID:        nodeID,
+		CheckInNodeID: checkInNodeID,
+		Key:           key,
+		Counter:       uint32(counter),
+		RegisteredAt:  time.Now().UTC(),
+	})
+}
+
+// CheckInKey implements controller.CheckInListener
+func (s *Server) CheckInKey(nodeID int) []byte {
+	registration, err := s.icd.Get(nodeID)
+	if err != nil {
+		return nil
+	}
+	return registration.Key
+}
+
+// CheckIn implements controller.CheckInListener. The node is available and
+// listening, so the interactions queued for it are sent.
+func (s *Server) CheckIn(nodeID int, counter uint32) {
+	if err := s.icd.CheckIn(nodeID, counter); err != nil {
+		s.logger.Warn("Rejected ICD check-in", logger.Int("node_id", nodeID), logger.ErrorField(err))
+		return
+	}
+
+	now := time.Now().UTC()
+	var threshold time.Duration
+	err := s.updateICDNode(nodeID, func(node *models.MatterNodeData) {
+		node.ICD.LastCheckIn = &now
+		node.Available = true
+		threshold = time.Duration(node.ICD.ActiveModeThreshold) * time.Millisecond
+	})
+	if err != nil {
+		s.logger.Error("Failed to update node", logger.Int("node_id", nodeID), logger.ErrorField(err))
+		return
+	}
+
+	s.icdQueues.mu.Lock()
+	s.icdQueues.activeUntil[nodeID] = now.Add(threshold)
+	queued := len(s.icdQueues.pending[nodeID]) > 0
+	s.icdQueues.mu.Unlock()
+
+	if queued {
+		go s.flushICDQueue(nodeID)
+	}
+}
+
+// flushICDQueue asks a node that checked in to stay active and sends the
+// interactions queued for it
+func (s *Server) flushICDQueue(nodeID int) {
+	ctx, cancel := context.WithTimeout(context.Background(), icdStayActiveDuration)
+	defer cancel()
+
+	response, err := s.controller.SendCommand(ctx, controller.CommandRequest{
+		NodeID:     nodeID,
+		EndpointID: 0,
+		ClusterID:  clusters.ICDManagementClusterID,
+		CommandID:  icd.CommandStayActive,
+		Payload:    map[string]interface{}{"stayActiveDuration": uint32(icdStayActiveDuration.Milliseconds())},
+	})
+	if err != nil {
+		// The node still listens for its active mode threshold
+		s.logger.Debug("ICD stay active request failed", logger.Int("node_id", nodeID), logger.ErrorField(err))
+	} else if fields, ok := response.(map[string]interface{}); ok {
+		if promised, ok := toInt(structField(fields, "0", "promisedActiveDuration")); ok {
+			s.icdQueues.mu.Lock()
+			s.icdQueues.activeUntil[nodeID] = time.Now().Add(time.Duration(promised) * time.Millisecond)
+			s.icdQueues.mu.Unlock()
+		}
+	}
+
+	s.icdQueues.mu.Lock()
+	pending := s.icdQueues.pending[nodeID]
+	delete(s.icdQueues.pending, nodeID)
+	s.icdQueues.mu.Unlock()
+
+	for _, queued := range pending {
+		var result interface{}
+		if queued.command != nil {
+			result, err = s.controller.SendCommand(ctx, *queued.command)
+		} else {
+			err = s.controller.WriteAttribute(ctx, *queued.write)
+		}
+
+		completed := queued.info
+		completed.Result = result
+		if err != nil {
+			completed.Error = err.Error()
+		}
+		s.EmitEvent(models.EventTypeQueuedInteractionCompleted, &completed)
+	}
+}
+
+// queueForICD queues a command or write for a sleeping node the server is
+// registered with until it checks in. ok is false if the node listens and
+// the interaction is to be sent right away.
+func (s *Server) queueForICD(command models.APICommand, nodeID int, req *controller.CommandRequest, write *controller.WriteRequest) (*models.QueuedInteraction, bool, error) {
+	s.nodesMu.RLock()
+	node, exists := s.nodes[nodeID]
+	sleepy := exists && node.Sleepy && node.ICD.Registered
+	s.nodesMu.RUnlock()
+	if !sleepy {
+		return nil, false, nil
+	}
+
+	s.icdQueues.mu.Lock()
+	defer s.icdQueues.mu.Unlock()
+
+	if time.Now().Before(s.icdQueues.activeUntil[nodeID]) {
+		return nil, false, nil
+	}
+	if len(s.icdQueues.pending[nodeID]) >= maxQueuedInteractions {
+		return nil, false, fmt.Errorf("node %d is asleep and already has %d queued interactions", nodeID, maxQueuedInteractions)
+	}
+
+	queued := &queuedInteraction{
+		info: models.QueuedInteraction{
+			QueueID:  models.GenerateMessageID(),
+			NodeID:   nodeID,
+			Command:  command,
+			QueuedAt: time.Now().UTC(),
+		},
+		command: req,
+		write:   write,
+	}
+	s.icdQueues.pending[nodeID] = append(s.icdQueues.pending[nodeID], queued)
+
+	info := queued.info
+	return &info, true, nil
+}
+
+// updateICDNode changes the ICD state of a node and persists it
+func (s *Server) updateICDNode(nodeID int, update func(node *models.MatterNodeData)) error {
+	s.nodesMu.Lock()
+	existing, exists := s.nodes[nodeID]
+	if !exists || existing.ICD == nil {
+		s.nodesMu.Unlock()
+		return &models.NodeNotFoundError{NodeID: nodeID}
+	}
+	node := *existing
+	info := *existing.ICD
+	node.ICD = &info
+	update(&node)
+	s.nodes[nodeID] = &node
+	s.nodesMu.Unlock()
+
+	if err := s.storage.SaveNode(&node); err != nil {
+		return fmt.Errorf("failed to persist node %d: %w", nodeID, err)
+	}
+	s.EmitEvent(models.EventTypeNodeUpdated, &node)
+	return nil
+}
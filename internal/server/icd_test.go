@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/clusters"
+	"github.com/codefionn/go-matter-server/internal/controller"
+	"github.com/codefionn/go-matter-server/internal/icd"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// icdController receives check-ins and answers RegisterClient
+type icdController struct {
+	fakeController
+	mu       sync.Mutex
+	listener controller.CheckInListener
+}
+
+func (c *icdController) SetCheckInListener(listener controller.CheckInListener) {
+	c.listener = listener
+}
+
+func (c *icdController) SendCommand(ctx context.Context, req controller.CommandRequest) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.commands = append(c.commands, req)
+	if req.CommandID == icd.CommandRegisterClient {
+		return map[string]interface{}{"0": float64(100)}, nil
+	}
+	return map[string]interface{}{"status": float64(0)}, nil
+}
+
+func TestICDQueue(t *testing.T) {
+	server := createTestServer(t)
+	server.config.Matter.ControllerNodeID = 0x1000
+	fake := &icdController{}
+	server.controller = fake
+
+	node := &models.MatterNodeData{NodeID: 5, Available: true, Attributes: map[string]interface{}{
+		"0/70/0":     float64(3600),
+		"0/70/2":     float64(5000),
+		"0/70/8":     float64(1),
+		"0/70/65532": float64(0x05),
+	}}
+	if err := server.applyNodeUpdate(node); err != nil {
+		t.Fatalf("Failed to add node: %v", err)
+	}
+	server.registerICDClient(context.Background(), 5)
+
+	node = server.nodes[5]
+	if !node.Sleepy || node.ICD == nil || !node.ICD.Registered {
+		t.Fatalf("Expected a registered sleepy node, got %+v", node)
+	}
+	register := fake.commands[0]
+	if register.ClusterID != clusters.ICDManagementClusterID || register.Payload["checkInNodeID"] != uint64(0x1000) {
+		t.Errorf("Expected RegisterClient for node 0x1000, got %+v", register)
+	}
+	if key := server.CheckInKey(5); len(key) != icd.KeyLength {
+		t.Errorf("Expected the check-in key, got %x", key)
+	}
+
+	// Commands for the sleeping node wait for its check-in
+	fake.commands = nil
+	result := runCommand(t, server, models.APICommandDeviceCommand, map[string]interface{}{
+		"node_id": 5, "endpoint_id": 1, "cluster_id": 6, "command_name": "On",
+	})
+	queued, ok := result.(*models.QueuedInteraction)
+	if !ok || queued.NodeID != 5 || queued.QueueID == "" {
+		t.Fatalf("Expected a queued interaction, got %+v", result)
+	}
+	if len(fake.commands) != 0 {
+		t.Errorf("Expected no command before the check-in, got %+v", fake.commands)
+	}
+
+	completed := make(chan *models.QueuedInteraction, 1)
+	server.Subscribe(func(eventType models.EventType, data interface{}) {
+		if eventType == models.EventTypeQueuedInteractionCompleted {
+			completed <- data.(*models.QueuedInteraction)
+		}
+	})
+
+	// Replayed counters are ignored
+	server.CheckIn(5, 100)
+	server.CheckIn(5, 101)
+	select {
+	case event := <-completed:
+		if event.QueueID != queued.QueueID || event.Error != "" {
+			t.Errorf("Expected the queued command to succeed, got %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected queued_interaction_completed")
+	}
+
+	fake.mu.Lock()
+	if len(fake.commands) != 2 || fake.commands[0].CommandID != icd.CommandStayActive || fake.commands[1].ClusterID != clusters.OnOffClusterID {
+		t.Errorf("Expected StayActiveRequest and On, got %+v", fake.commands)
+	}
+	fake.mu.Unlock()
+	if server.nodes[5].ICD.LastCheckIn == nil {
+		t.Error("Expected the check-in to be recorded")
+	}
+
+	// The node listens after its check-in
+	result = runCommand(t, server, models.APICommandDeviceCommand, map[string]interface{}{
+		"node_id": 5, "endpoint_id": 1, "cluster_id": 6, "command_name": "Off",
+	})
+	if _, queued := result.(*models.QueuedInteraction); queued {
+		t.Error("Expected the command to be sent while the node is active")
+	}
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// logModuleServer is the module of entries logged by the server itself
+const logModuleServer = "server"
+
+// logEntry converts a log record for the API
+func logEntry(record logger.Record) models.LogEntry {
+	entry := models.LogEntry{
+		Timestamp: record.Timestamp.UTC(),
+		Level:     record.Level.String(),
+		Module:    record.Logger,
+		Message:   record.Message,
+	}
+	if entry.Module == "" {
+		entry.Module = logModuleServer
+	}
+	if len(record.Fields) > 0 {
+		entry.Fields = make(map[string]interface{}, len(record.Fields))
+		for _, field := range record.Fields {
+			entry.Fields[field.Key] = field.Value
+		}
+	}
+	return entry
+}
+
+// emitLogEntries sends log entries as log_entry events until the returned
+// function is called
+func (s *Server) emitLogEntries() func() {
+	return s.logger.Subscribe(func(record logger.Record) {
+		s.EmitEvent(models.EventTypeLogEntry, logEntry(record))
+	})
+}
+
+// handleGetLogs returns the recent log entries matching the filters, oldest
+// first. With limit only the newest entries are returned.
+func (s *Server) handleGetLogs(args commandArgs) (interface{}, error) {
+	minLevel := logger.TraceLevel
+	if args.has("level") {
+		level, err := logger.ParseLogLevel(args.str("level"))
+		if err != nil {
+			return nil, &models.ArgumentError{Field: "level", Reason: err.Error()}
+		}
+		minLevel = level
+	}
+	modules := make(map[string]bool)
+	for _, module := range args.stringList("module") {
+		modules[module] = true
+	}
+	since := args.time("since")
+
+	records := s.logger.Records()
+	entries := make([]models.LogEntry, 0, len(records))
+	for _, record := range records {
+		entry := logEntry(record)
+		switch {
+		case record.Level < minLevel:
+		case len(modules) > 0 && !modules[entry.Module]:
+		case !since.IsZero() && record.Timestamp.Before(since):
+		default:
+			entries = append(entries, entry)
+		}
+	}
+
+	if limit := int(args.integer("limit")); limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	return entries, nil
+}
+
+// handleLogsHTTP returns the recent log entries, taking the arguments of
+// get_logs as query parameters
+func (s *Server) handleLogsHTTP(w http.ResponseWriter, r *http.Request) {
+	raw := make(map[string]interface{})
+	for name, values := range r.URL.Query() {
+		raw[name] = values[len(values)-1]
+	}
+
+	args, err := validateArgs(models.APICommandGetLogs, raw)
+	if err != nil {
+		s.writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	entries, err := s.handleGetLogs(args)
+	if err != nil {
+		s.writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	s.writeJSON(w, entries)
+}
+
+// maxTemporaryLogLevel is the longest a temporary log level lasts, in
+// seconds
+const maxTemporaryLogLevel = 24 * 60 * 60
+
+// handleSetLogLevel changes the log level. Without duration_s it changes
+// the log.level setting, so the level survives restarts. With duration_s
+// the level is temporary and reverts to the log.level setting, e.g. to
+// debug an intermittent failure without leaving debug logging on.
+func (s *Server) handleSetLogLevel(args commandArgs) (interface{}, error) {
+	name := args.str("level")
+	level, err := logger.ParseLogLevel(name)
+	if err != nil {
+		return nil, &models.ArgumentError{Field: "level", Reason: err.Error()}
+	}
+
+	if !args.has("duration_s") {
+		s.serverSettingsMu.Lock()
+		s.stopTemporaryLogLevel()
+		s.serverSettingsMu.Unlock()
+
+		if _, err := s.setServerSetting("log.level", &name); err != nil {
+			return nil, err
+		}
+		return models.LogLevelState{Level: level.String()}, nil
+	}
+
+	duration := time.Duration(args.integer("duration_s")) * time.Second
+	setting, _ := lookupServerSetting("log.level")
+
+	s.serverSettingsMu.Lock()
+	defer s.serverSettingsMu.Unlock()
+
+	s.stopTemporaryLogLevel()
+	s.logger.SetLevel(level)
+	var timer *time.Timer
+	timer = time.AfterFunc(duration, func() {
+		s.serverSettingsMu.Lock()
+		defer s.serverSettingsMu.Unlock()
+		// Replaced by a later call
+		if s.logLevelTimer != timer {
+			return
+		}
+		s.logLevelTimer = nil
+		s.applyServerSetting(setting)
+		s.logger.Info("Temporary log level expired", logger.String("level", s.logger.GetLevel().String()))
+	})
+	s.logLevelTimer = timer
+	s.logLevelUntil = time.Now().Add(duration).UTC()
+
+	revertTo := s.serverSettingState(setting).Value.(string)
+	s.logger.Info("Temporary log level set",
+		logger.String("level", level.String()),
+		logger.Duration("duration", duration),
+		logger.String("revert_to", revertTo),
+	)
+	return models.LogLevelState{Level: level.String(), Until: &s.logLevelUntil, RevertTo: revertTo}, nil
+}
+
+// stopTemporaryLogLevel cancels reverting a temporary log level. It must be
+// called with serverSettingsMu held.
+func (s *Server) stopTemporaryLogLevel() {
+	if s.logLevelTimer != nil {
+		s.logLevelTimer.Stop()
+		s.logLevelTimer = nil
+	}
+}
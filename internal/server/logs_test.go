@@ -0,0 +1,286 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/audit"
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+func createLoggingTestServer(t *testing.T) *Server {
+	t.Helper()
+	server := createTestServer(t)
+	// Above debug, which would record the commands of the tests
+	server.logger = logger.New(logger.Config{Level: logger.InfoLevel, Output: io.Discard, HistorySize: 10})
+
+	server.logger.Info("Starting")
+	server.logger.WithName("availability").Warn("Node went offline", logger.Int("node_id", 5))
+	server.logger.Error("Stopping")
+	return server
+}
+
+func TestGetLogs(t *testing.T) {
+	server := createLoggingTestServer(t)
+
+	tests := []struct {
+		name string
+		args map[string]interface{}
+		want []string
+	}{
+		{"All", nil, []string{"Starting", "Node went offline", "Stopping"}},
+		{"Level", map[string]interface{}{"level": "warn"}, []string{"Node went offline", "Stopping"}},
+		{"Module", map[string]interface{}{"module": []interface{}{"availability"}}, []string{"Node went offline"}},
+		{"Server module", map[string]interface{}{"module": "server", "limit": float64(1)}, []string{"Stopping"}},
+		{"Since", map[string]interface{}{"since": time.Now().Add(time.Minute).Format(time.RFC3339)}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := server.HandleCommand(context.Background(), models.CommandMessage{
+				Command: string(models.APICommandGetLogs),
+				Args:    tt.args,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			entries := result.([]models.LogEntry)
+			if len(entries) != len(tt.want) {
+				t.Fatalf("Expected %d entries, got %+v", len(tt.want), entries)
+			}
+			for i, message := range tt.want {
+				if entries[i].Message != message {
+					t.Errorf("Expected %q at %d, got %q", message, i, entries[i].Message)
+				}
+			}
+		})
+	}
+
+	result, _ := server.HandleCommand(context.Background(), models.CommandMessage{
+		Command: string(models.APICommandGetLogs),
+		Args:    map[string]interface{}{"module": "availability"},
+	})
+	entry := result.([]models.LogEntry)[0]
+	if entry.Level != "warn" || entry.Fields["node_id"] != 5 {
+		t.Errorf("Unexpected entry %+v", entry)
+	}
+
+	if _, err := server.HandleCommand(context.Background(), models.CommandMessage{
+		Command: string(models.APICommandGetLogs),
+		Args:    map[string]interface{}{"level": "loud"},
+	}); err == nil {
+		t.Error("Expected an error for an invalid level")
+	}
+}
+
+func TestLogsHTTP(t *testing.T) {
+	server := createLoggingTestServer(t)
+	router := server.setupRouter()
+
+	req := httptest.NewRequest("GET", "/api/logs?level=warn&module=availability", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var entries []models.LogEntry
+	if err := json.Unmarshal(w.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Module != "availability" {
+		t.Errorf("Expected the warning, got %+v", entries)
+	}
+
+	req = httptest.NewRequest("GET", "/api/logs?limit=0", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422, got %d", w.Code)
+	}
+}
+
+func TestLogEntryEvents(t *testing.T) {
+	server := createLoggingTestServer(t)
+
+	events := make(chan models.LogEntry, 1)
+	defer server.Subscribe(func(eventType models.EventType, data interface{}) {
+		if eventType == models.EventTypeLogEntry {
+			events <- data.(models.LogEntry)
+		}
+	})()
+	stop := server.emitLogEntries()
+
+	server.logger.WithName("srp").Info("Registered")
+	select {
+	case entry := <-events:
+		if entry.Module != "srp" || entry.Message != "Registered" || entry.Level != "info" {
+			t.Errorf("Unexpected entry %+v", entry)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected a log_entry event")
+	}
+
+	stop()
+	server.logger.Info("Not sent")
+	select {
+	case entry := <-events:
+		t.Errorf("Unexpected event after unsubscribing %+v", entry)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSetLogLevel(t *testing.T) {
+	server := createLoggingTestServer(t)
+	server.config.Log.Level = "info"
+	server.config.Server.AdminToken = "0123456789abcdef"
+
+	admin := audit.WithClient(context.Background(), audit.Client{BearerToken: "0123456789abcdef"})
+	setLogLevel := func(args map[string]interface{}) (models.LogLevelState, error) {
+		result, err := server.HandleCommand(admin, models.CommandMessage{
+			Command: string(models.APICommandSetLogLevel),
+			Args:    args,
+		})
+		if err != nil {
+			return models.LogLevelState{}, err
+		}
+		return result.(models.LogLevelState), nil
+	}
+
+	// Every transport needs the admin token
+	for _, ctx := range []context.Context{
+		context.Background(),
+		audit.WithClient(context.Background(), audit.Client{ConnectionID: "conn-a", BearerToken: "wrong"}),
+	} {
+		_, err := server.HandleCommand(ctx, models.CommandMessage{
+			Command: string(models.APICommandSetLogLevel),
+			Args:    map[string]interface{}{"level": "warn"},
+		})
+		if !errors.Is(err, models.ErrUnauthorized) {
+			t.Errorf("Expected ErrUnauthorized, got %v", err)
+		}
+	}
+	if server.logger.GetLevel() == logger.WarnLevel {
+		t.Fatal("Expected unauthorized commands to leave the level")
+	}
+
+	state, err := setLogLevel(map[string]interface{}{"level": "warn"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if state.Level != "warn" || state.Until != nil || server.logger.GetLevel() != logger.WarnLevel {
+		t.Errorf("Expected the warn level, got %+v", state)
+	}
+	setting, _ := lookupServerSetting("log.level")
+	if state := server.serverSettingState(setting); state.Value != "warn" {
+		t.Errorf("Expected the level to be stored, got %+v", state)
+	}
+
+	state, err = setLogLevel(map[string]interface{}{"level": "debug", "duration_s": float64(1)})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if state.Level != "debug" || state.Until == nil || state.RevertTo != "warn" {
+		t.Errorf("Expected a temporary debug level, got %+v", state)
+	}
+	if server.logger.GetLevel() != logger.DebugLevel {
+		t.Errorf("Expected the debug level, got %v", server.logger.GetLevel())
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for server.logger.GetLevel() != logger.WarnLevel && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if server.logger.GetLevel() != logger.WarnLevel {
+		t.Errorf("Expected the level to revert to warn, got %v", server.logger.GetLevel())
+	}
+
+	for _, args := range []map[string]interface{}{
+		{"level": "loud"},
+		{"level": "debug", "duration_s": float64(0)},
+		{},
+	} {
+		if _, err := setLogLevel(args); err == nil {
+			t.Errorf("Expected an error for %v", args)
+		}
+	}
+}
+
+func TestSetLogLevelReplacesTemporary(t *testing.T) {
+	server := createLoggingTestServer(t)
+	server.config.Log.Level = "info"
+	server.config.Server.AdminToken = "0123456789abcdef"
+	router := server.setupRouter()
+
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/log-level", bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer 0123456789abcdef")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := post(`{"level": "trace", "duration_s": 60}`); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if server.logger.GetLevel() != logger.TraceLevel {
+		t.Errorf("Expected the trace level, got %v", server.logger.GetLevel())
+	}
+
+	// A permanent level cancels the revert
+	if w := post(`{"level": "error"}`); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	server.serverSettingsMu.Lock()
+	timer := server.logLevelTimer
+	server.serverSettingsMu.Unlock()
+	if timer != nil || server.logger.GetLevel() != logger.ErrorLevel {
+		t.Errorf("Expected the error level without a revert, got %v", server.logger.GetLevel())
+	}
+
+	if w := post(`{"level": "debug", "duration_s": 100000}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422, got %d", w.Code)
+	}
+}
+
+func TestSetLogLevelRequiresAdminToken(t *testing.T) {
+	server := createLoggingTestServer(t)
+	server.config.Log.Level = "info"
+	router := server.setupRouter()
+
+	post := func(authorization string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/log-level", bytes.NewReader([]byte(`{"level": "trace"}`)))
+		req.Header.Set("Content-Type", "application/json")
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Without a configured token the route is refused
+	if code := post("Bearer "); code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without an admin token, got %d", code)
+	}
+
+	server.config.Server.AdminToken = "0123456789abcdef"
+	for _, authorization := range []string{"", "Bearer wrong", "0123456789abcdef"} {
+		if code := post(authorization); code != http.StatusUnauthorized {
+			t.Errorf("Expected status 401 for %q, got %d", authorization, code)
+		}
+	}
+	if server.logger.GetLevel() == logger.TraceLevel {
+		t.Error("Expected unauthenticated requests to leave the level")
+	}
+	if code := post("Bearer 0123456789abcdef"); code != http.StatusOK {
+		t.Errorf("Expected status 200 with the admin token, got %d", code)
+	}
+}
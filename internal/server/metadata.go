@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/codefionn/go-matter-server/internal/clusters"
+	"github.com/codefionn/go-matter-server/internal/controller"
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+const (
+	// Basic Information NodeLabel attribute
+	basicInformationNodeLabel = 0x0005
+	// maxNodeLabelLength is the length limit of NodeLabel in bytes
+	maxNodeLabelLength = 32
+)
+
+// handleSetNodeName sets the friendly name of a node
+func (s *Server) handleSetNodeName(ctx context.Context, args commandArgs) (interface{}, error) {
+	name := strings.TrimSpace(args.str("name"))
+	return s.updateNodeMetadata(ctx, args.nodeID(), func(metadata *models.NodeMetadata) bool {
+		metadata.Name = name
+		return true
+	})
+}
+
+// handleSetNodeMetadata changes the given metadata fields of a node, empty
+// values clear them
+func (s *Server) handleSetNodeMetadata(ctx context.Context, args commandArgs) (interface{}, error) {
+	return s.updateNodeMetadata(ctx, args.nodeID(), func(metadata *models.NodeMetadata) bool {
+		if args.has("room") {
+			metadata.Room = strings.TrimSpace(args.str("room"))
+		}
+		if args.has("tags") {
+			metadata.Tags = normalizeTags(args.stringList("tags"))
+		}
+		if args.has("name") {
+			metadata.Name = strings.TrimSpace(args.str("name"))
+			return true
+		}
+		return false
+	})
+}
+
+// updateNodeMetadata applies update to the metadata of a node, stores the
+// node and emits node_updated. If update changed the name, it is written to
+// the NodeLabel of the device when the device has one and the name fits.
+func (s *Server) updateNodeMetadata(ctx context.Context, nodeID int, update func(*models.NodeMetadata) bool) (interface{}, error) {
+	s.nodesMu.RLock()
+	existing, exists := s.nodes[nodeID]
+	var metadata models.NodeMetadata
+	if exists && existing.Metadata != nil {
+		metadata = *existing.Metadata
+		metadata.Tags = slices.Clone(metadata.Tags)
+	}
+	s.nodesMu.RUnlock()
+
+	if !exists {
+		return nil, &models.NodeNotFoundError{NodeID: nodeID}
+	}
+
+	nameChanged := update(&metadata)
+	labelWritten := nameChanged && s.writeNodeLabel(ctx, existing, metadata.Name)
+
+	s.nodesMu.Lock()
+	current, exists := s.nodes[nodeID]
+	if !exists {
+		s.nodesMu.Unlock()
+		return nil, &models.NodeNotFoundError{NodeID: nodeID}
+	}
+	node := *current
+	node.Metadata = nil
+	if metadata.Name != "" || metadata.Room != "" || len(metadata.Tags) > 0 {
+		node.Metadata = &metadata
+	}
+	if labelWritten {
+		node.Attributes = make(map[string]interface{}, len(current.Attributes))
+		for path, value := range current.Attributes {
+			node.Attributes[path] = value
+		}
+		node.Attributes[attributePath(0, clusters.BasicInformationClusterID, basicInformationNodeLabel)] = metadata.Name
+	}
+	s.nodes[nodeID] = &node
+	s.nodesMu.Unlock()
+
+	if err := s.storage.SaveNode(&node); err != nil {
+		return nil, err
+	}
+	s.EmitEvent(models.EventTypeNodeUpdated, &node)
+
+	nodeCopy := node
+	return &nodeCopy, nil
+}
+
+// writeNodeLabel writes a name to the NodeLabel attribute of a node,
+// reporting whether it was written. Nodes that didn't report a NodeLabel
+// don't support it.
+func (s *Server) writeNodeLabel(ctx context.Context, node *models.MatterNodeData, name string) bool {
+	path := attributePath(0, clusters.BasicInformationClusterID, basicInformationNodeLabel)
+	s.nodesMu.RLock()
+	_, supported := node.Attributes[path]
+	s.nodesMu.RUnlock()
+	if !supported {
+		return false
+	}
+	if len(name) > maxNodeLabelLength {
+		s.logger.Info("Name too long for the NodeLabel, keeping it on the server only",
+			logger.Int("node_id", node.NodeID),
+		)
+		return false
+	}
+
+	err := s.controller.WriteAttribute(ctx, controller.WriteRequest{NodeID: node.NodeID, Path: path, Value: name})
+	if err != nil {
+		if !errors.Is(err, controller.ErrNotAvailable) {
+			s.logger.Warn("Failed to write the NodeLabel",
+				logger.Int("node_id", node.NodeID),
+				logger.ErrorField(err),
+			)
+		}
+		return false
+	}
+	return true
+}
+
+// normalizeTags trims the tags and drops empty and repeated ones
+func normalizeTags(tags []string) []string {
+	var normalized []string
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
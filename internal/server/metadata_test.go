@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/codefionn/go-matter-server/internal/controller"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// labelController records attribute writes
+type labelController struct {
+	fakeController
+	writes []controller.WriteRequest
+}
+
+func (c *labelController) WriteAttribute(ctx context.Context, req controller.WriteRequest) error {
+	c.writes = append(c.writes, req)
+	return nil
+}
+
+func TestSetNodeMetadata(t *testing.T) {
+	server := createTestServer(t)
+	fake := &labelController{}
+	server.controller = fake
+	server.nodes[5] = &models.MatterNodeData{NodeID: 5, Attributes: map[string]interface{}{"0/40/5": ""}}
+	server.nodes[6] = &models.MatterNodeData{NodeID: 6, Attributes: map[string]interface{}{}}
+	ctx := context.Background()
+
+	run := func(command models.APICommand, args map[string]interface{}) (*models.MatterNodeData, error) {
+		result, err := server.HandleCommand(ctx, models.CommandMessage{Command: string(command), Args: args})
+		if err != nil {
+			return nil, err
+		}
+		return result.(*models.MatterNodeData), nil
+	}
+
+	node, err := run(models.APICommandSetNodeName, map[string]interface{}{"node_id": float64(5), "name": " Kitchen lamp "})
+	if err != nil {
+		t.Fatalf("set_node_name failed: %v", err)
+	}
+	if node.Metadata == nil || node.Metadata.Name != "Kitchen lamp" || node.Attributes["0/40/5"] != "Kitchen lamp" {
+		t.Errorf("Expected the name and NodeLabel to be set, got %+v", node)
+	}
+	if len(fake.writes) != 1 || fake.writes[0].Path != "0/40/5" || fake.writes[0].Value != "Kitchen lamp" {
+		t.Errorf("Expected the NodeLabel to be written, got %+v", fake.writes)
+	}
+
+	// Only the given fields change
+	node, err = run(models.APICommandSetNodeMetadata, map[string]interface{}{
+		"node_id": float64(5),
+		"room":    "Kitchen",
+		"tags":    []interface{}{"light", " ceiling", "light", ""},
+	})
+	if err != nil {
+		t.Fatalf("set_node_metadata failed: %v", err)
+	}
+	expected := &models.NodeMetadata{Name: "Kitchen lamp", Room: "Kitchen", Tags: []string{"light", "ceiling"}}
+	if !reflect.DeepEqual(node.Metadata, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, node.Metadata)
+	}
+	if len(fake.writes) != 1 {
+		t.Errorf("Expected no NodeLabel write without a name, got %+v", fake.writes)
+	}
+	stored, err := server.storage.GetNode(5)
+	if err != nil || !reflect.DeepEqual(stored.Metadata, expected) {
+		t.Errorf("Expected the metadata to be stored, got %+v, %v", stored, err)
+	}
+
+	// Names not fitting the NodeLabel and nodes without one stay on the server
+	if _, err := run(models.APICommandSetNodeName, map[string]interface{}{"node_id": float64(5), "name": strings.Repeat("x", 33)}); err != nil {
+		t.Fatalf("set_node_name failed: %v", err)
+	}
+	if node, err = run(models.APICommandSetNodeName, map[string]interface{}{"node_id": float64(6), "name": "Sensor"}); err != nil {
+		t.Fatalf("set_node_name failed: %v", err)
+	}
+	if len(fake.writes) != 1 || node.Metadata.Name != "Sensor" {
+		t.Errorf("Expected the name to be kept on the server only, got %+v, %+v", fake.writes, node)
+	}
+
+	// Clearing all fields removes the metadata
+	node, err = run(models.APICommandSetNodeMetadata, map[string]interface{}{"node_id": float64(6), "name": ""})
+	if err != nil || node.Metadata != nil {
+		t.Errorf("Expected the metadata to be removed, got %+v, %v", node, err)
+	}
+
+	var notFound *models.NodeNotFoundError
+	if _, err := run(models.APICommandSetNodeName, map[string]interface{}{"node_id": float64(7), "name": "x"}); !errors.As(err, &notFound) {
+		t.Errorf("Expected node not found, got %v", err)
+	}
+}
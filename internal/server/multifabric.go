@@ -0,0 +1,137 @@
+package server
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"github.com/codefionn/go-matter-server/internal/config"
+	"github.com/codefionn/go-matter-server/internal/credentials"
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/mdns"
+	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/storage"
+)
+
+// operatedFabric is a fabric the server commissions devices into
+type operatedFabric struct {
+	fabricID    int
+	vendorID    int
+	label       string
+	credentials *credentials.Authority
+}
+
+// fabricDir is the storage directory of an additional fabric. The default
+// fabric keeps its credentials in the storage path itself.
+func fabricDir(fabricID int) string {
+	return filepath.Join("fabrics", strconv.Itoa(fabricID))
+}
+
+// loadFabrics loads or creates the certificate authorities of the configured
+// fabrics, the default fabric first
+func loadFabrics(cfg *config.Config, cipher *storage.Cipher, log *logger.Logger) ([]*operatedFabric, error) {
+	var fabrics []*operatedFabric
+	for i, fc := range cfg.Matter.OperatedFabrics() {
+		basePath := cfg.Storage.Path
+		if i > 0 {
+			basePath = filepath.Join(basePath, fabricDir(fc.FabricID))
+		}
+		authority := credentials.NewAuthority(basePath, uint64(fc.FabricID), log)
+		authority.SetCipher(cipher)
+		if err := authority.Load(); err != nil {
+			return nil, fmt.Errorf("failed to load credentials of fabric %d: %w", fc.FabricID, err)
+		}
+		fabrics = append(fabrics, &operatedFabric{
+			fabricID:    fc.FabricID,
+			vendorID:    fc.VendorID,
+			label:       fc.Label,
+			credentials: authority,
+		})
+	}
+	return fabrics, nil
+}
+
+// fabric returns the operated fabric with the ID, the default fabric for 0
+func (s *Server) fabric(fabricID int) (*operatedFabric, bool) {
+	if fabricID == 0 {
+		return s.fabrics[0], true
+	}
+	for _, fabric := range s.fabrics {
+		if fabric.fabricID == fabricID {
+			return fabric, true
+		}
+	}
+	return nil, false
+}
+
+// fabricArg resolves the optional fabric_id argument of a command
+func (s *Server) fabricArg(args commandArgs) (*operatedFabric, error) {
+	fabric, ok := s.fabric(int(args.integer("fabric_id")))
+	if !ok {
+		return nil, &models.ArgumentError{
+			Field:  "fabric_id",
+			Reason: fmt.Sprintf("fabric %d is not operated by the server", args.integer("fabric_id")),
+		}
+	}
+	return fabric, nil
+}
+
+// tagFabric assigns a node stored before fabrics were tracked to the default
+// fabric
+func (s *Server) tagFabric(node *models.MatterNodeData) {
+	if node.FabricID == 0 {
+		node.FabricID = s.fabrics[0].fabricID
+	}
+}
+
+// nodeFabric returns the fabric of a node, false if the fabric is no longer
+// operated
+func (s *Server) nodeFabric(nodeID int) (*operatedFabric, bool) {
+	s.nodesMu.RLock()
+	node, exists := s.nodes[nodeID]
+	fabricID := 0
+	if exists {
+		fabricID = node.FabricID
+	}
+	s.nodesMu.RUnlock()
+	return s.fabric(fabricID)
+}
+
+// serverFabrics lists the operated fabrics for server_info
+func (s *Server) serverFabrics() []models.ServerFabric {
+	fabrics := make([]models.ServerFabric, 0, len(s.fabrics))
+	for _, fabric := range s.fabrics {
+		fabrics = append(fabrics, models.ServerFabric{
+			FabricID:           fabric.fabricID,
+			VendorID:           fabric.vendorID,
+			CompressedFabricID: fabric.credentials.CompressedFabricID(),
+			Label:              fabric.label,
+		})
+	}
+	return fabrics
+}
+
+// compressedFabricIDs returns the compressed IDs of the operated fabrics in
+// order
+func (s *Server) compressedFabricIDs() []uint64 {
+	ids := make([]uint64, 0, len(s.fabrics))
+	for _, fabric := range s.fabrics {
+		ids = append(ids, fabric.credentials.CompressedFabricID())
+	}
+	return ids
+}
+
+// fabricServices are the mDNS services named after the compressed fabric ID:
+// the operational instance of the controller on every fabric, and the
+// WebSocket API on the default fabric
+func (s *Server) fabricServices(fabric *operatedFabric, compressedFabricID uint64) []mdns.Service {
+	var services []mdns.Service
+	if s.config.Matter.ControllerNodeID != 0 {
+		services = append(services, mdns.OperationalService(compressedFabricID, s.config.Matter.ControllerNodeID, mdns.MatterPort))
+	}
+	if fabric == s.fabrics[0] {
+		services = append(services, mdns.ServerService(compressedFabricID, uint16(s.config.Server.Port),
+			uint16(s.config.Server.GRPCPort), models.SchemaVersion, models.MinSupportedSchemaVersion, s.mdnsFeatures()))
+	}
+	return services
+}
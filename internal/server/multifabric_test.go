@@ -0,0 +1,164 @@
+package server
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/codefionn/go-matter-server/internal/config"
+	"github.com/codefionn/go-matter-server/internal/controller"
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+func createMultiFabricServer(t *testing.T) *Server {
+	t.Helper()
+	cfg := &config.Config{
+		Server:  config.ServerConfig{ListenAddresses: []string{"127.0.0.1"}},
+		Storage: config.StorageConfig{Path: t.TempDir()},
+		Matter: config.MatterConfig{
+			VendorID: 0xFFF1,
+			FabricID: 1,
+			Fabrics:  []config.FabricConfig{{FabricID: 2, Label: "Guests"}},
+		},
+	}
+	server, err := New(cfg, logger.NewConsoleLogger(logger.ErrorLevel))
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	return server
+}
+
+// commissioningController commissions device, passing its attestation to
+// the server and keeping the issued NOCs, and interviews it with a fixed
+// attribute set
+type commissioningController struct {
+	fakeController
+	device   *testDevice
+	requests []controller.CommissionRequest
+	nocs     [][]byte
+}
+
+func (c *commissioningController) Commission(ctx context.Context, req controller.CommissionRequest) error {
+	c.requests = append(c.requests, req)
+	noc, err := c.device.commission(req)
+	c.nocs = append(c.nocs, noc)
+	return err
+}
+
+func (c *commissioningController) Interview(ctx context.Context, nodeID int) (map[string]interface{}, error) {
+	return map[string]interface{}{"0/40/5": "Lamp"}, nil
+}
+
+func TestOperatedFabrics(t *testing.T) {
+	server := createMultiFabricServer(t)
+
+	fabrics := server.GetServerInfo().Fabrics
+	if len(fabrics) != 2 || fabrics[0].FabricID != 1 || fabrics[1].FabricID != 2 {
+		t.Fatalf("Expected fabrics 1 and 2, got %+v", fabrics)
+	}
+	if fabrics[1].VendorID != 0xFFF1 || fabrics[1].Label != "Guests" {
+		t.Errorf("Expected the default vendor ID and the label, got %+v", fabrics[1])
+	}
+	if fabrics[0].CompressedFabricID == fabrics[1].CompressedFabricID || fabrics[1].CompressedFabricID == 0 {
+		t.Errorf("Expected separate fabric roots, got %+v", fabrics)
+	}
+	if fabrics[0].CompressedFabricID != server.GetServerInfo().CompressedFabricID {
+		t.Errorf("Expected the default fabric first, got %+v", fabrics)
+	}
+
+	dir := filepath.Join(server.config.Storage.Path, "fabrics", "2", "credentials")
+	if _, err := os.Stat(dir); err != nil {
+		t.Errorf("Expected the credentials of fabric 2 in %s: %v", dir, err)
+	}
+	if !slices.Contains(server.replicatedFiles(), filepath.Join("fabrics", "2", "credentials")) {
+		t.Errorf("Expected the credentials of fabric 2 to be replicated, got %v", server.replicatedFiles())
+	}
+}
+
+func TestCommissionOnFabric(t *testing.T) {
+	server := createMultiFabricServer(t)
+	ctx := context.Background()
+
+	commission := func(args map[string]interface{}) (*models.MatterNodeData, error) {
+		result, err := server.HandleCommand(ctx, models.CommandMessage{
+			Command: string(models.APICommandCommissionWithCode),
+			Args:    args,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return result.(*models.MatterNodeData), nil
+	}
+
+	if _, err := commission(map[string]interface{}{"code": "MT:Y.K9042C00KA0648G00"}); !errors.Is(err, controller.ErrNotAvailable) {
+		t.Fatalf("Expected ErrNotAvailable without a commissioner, got %v", err)
+	}
+
+	fake := &commissioningController{device: trustedTestDevice(t, server)}
+	server.controller = fake
+	node, err := commission(map[string]interface{}{"code": "MT:Y.K9042C00KA0648G00", "fabric_id": float64(2)})
+	if err != nil {
+		t.Fatalf("Commissioning failed: %v", err)
+	}
+	if node.NodeID != 1 || node.FabricID != 2 || node.Attributes["0/40/5"] != "Lamp" {
+		t.Errorf("Expected node 1 on fabric 2, got %+v", node)
+	}
+	if req := fake.requests[0]; req.Fabric != server.fabrics[1].credentials || req.VendorID != 0xFFF1 || req.NodeID != 1 {
+		t.Errorf("Expected the request for fabric 2, got %+v", req)
+	}
+	noc, err := x509.ParseCertificate(fake.nocs[0])
+	if err != nil {
+		t.Fatalf("Expected a NOC, got %v", err)
+	}
+	if err := noc.CheckSignatureFrom(server.fabrics[1].credentials.IntermediateCertificate()); err != nil {
+		t.Errorf("Expected the NOC to be issued by fabric 2: %v", err)
+	}
+
+	node, err = commission(map[string]interface{}{"code": "34970112332"})
+	if err != nil {
+		t.Fatalf("Commissioning failed: %v", err)
+	}
+	if node.NodeID != 2 || node.FabricID != 1 {
+		t.Errorf("Expected node 2 on the default fabric, got %+v", node)
+	}
+
+	var argErr *models.ArgumentError
+	if _, err := commission(map[string]interface{}{"code": "34970112332", "fabric_id": float64(3)}); !errors.As(err, &argErr) || argErr.Field != "fabric_id" {
+		t.Errorf("Expected an argument error for fabric 3, got %v", err)
+	}
+
+	result, err := server.HandleCommand(ctx, models.CommandMessage{
+		Command: string(models.APICommandGetNodes),
+		Args:    map[string]interface{}{"fabric_id": float64(2)},
+	})
+	if err != nil {
+		t.Fatalf("get_nodes failed: %v", err)
+	}
+	if nodes := result.([]*models.MatterNodeData); len(nodes) != 1 || nodes[0].NodeID != 1 {
+		t.Errorf("Expected node 1 only, got %+v", nodes)
+	}
+}
+
+func TestImportSettingsUnknownFabric(t *testing.T) {
+	server := createMultiFabricServer(t)
+
+	_, err := server.HandleCommand(context.Background(), models.CommandMessage{
+		Command: string(models.APICommandImportSettings),
+		Args: map[string]interface{}{
+			"format_version": float64(models.SettingsExportVersion),
+			"nodes":          []interface{}{map[string]interface{}{"node_id": float64(4), "fabric_id": float64(7)}},
+		},
+	})
+	var argErr *models.ArgumentError
+	if !errors.As(err, &argErr) || argErr.Field != "nodes" {
+		t.Fatalf("Expected an argument error, got %v", err)
+	}
+	if nodes := server.nodeSnapshot(); len(nodes) != 0 {
+		t.Errorf("Expected nothing to be imported, got %+v", nodes)
+	}
+}
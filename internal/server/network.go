@@ -0,0 +1,42 @@
+package server
+
+import (
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/netif"
+)
+
+// selectNetwork chooses the primary interface, unless one is configured
+func selectNetwork(configured string, log *logger.Logger) models.NetworkInfo {
+	interfaces, err := netif.Gather()
+	if err != nil {
+		log.Warn("Failed to list network interfaces", logger.ErrorField(err))
+	}
+	info := netif.Select(configured, interfaces)
+
+	switch info.Source {
+	case models.NetworkSourceAuto:
+		log.Info("Primary network interface selected",
+			logger.String("interface", info.PrimaryInterface),
+			logger.String("reason", info.Reason),
+		)
+	case models.NetworkSourceNone:
+		log.Warn("No primary network interface found, set network.primary_interface",
+			logger.String("reason", info.Reason),
+		)
+	}
+	return info
+}
+
+// primaryInterface returns the interface for link-local addresses, empty for
+// any
+func (s *Server) primaryInterface() string {
+	if s.config.Network.PrimaryInterface != "" {
+		return s.config.Network.PrimaryInterface
+	}
+	return s.network.PrimaryInterface
+}
+
+func (s *Server) handleGetNetworkInfo() (interface{}, error) {
+	return s.network, nil
+}
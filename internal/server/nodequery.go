@@ -0,0 +1,146 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/codefionn/go-matter-server/internal/clusters"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// nodeFields are the JSON names of the node fields that can be selected
+var nodeFields = jsonFieldNames(reflect.TypeOf(models.MatterNodeData{}))
+
+// nodeQuery filters, pages and projects the node list. It implements the
+// arguments of get_nodes, which GET /api/nodes takes as query parameters.
+type nodeQuery struct {
+	annotate  bool
+	available *bool
+	isBridge  *bool
+	// fabricID selects the nodes of one fabric, zero for all
+	fabricID int
+	offset   int
+	// limit is the maximum number of nodes returned, zero for no limit
+	limit int
+	// fields selects the returned node fields, all fields if empty
+	fields []string
+}
+
+// newNodeQuery builds a query from validated get_nodes arguments
+func newNodeQuery(args commandArgs) (nodeQuery, error) {
+	q := nodeQuery{
+		annotate: args.boolean("annotate"),
+		offset:   int(args.integer("offset")),
+		limit:    int(args.integer("limit")),
+		fields:   args.stringList("fields"),
+		fabricID: int(args.integer("fabric_id")),
+	}
+	if args.has("available") {
+		available := args.boolean("available")
+		q.available = &available
+	}
+	if args.has("is_bridge") {
+		isBridge := args.boolean("is_bridge")
+		q.isBridge = &isBridge
+	}
+
+	for _, field := range q.fields {
+		if !nodeFields[field] {
+			return nodeQuery{}, &models.ArgumentError{Field: "fields", Reason: fmt.Sprintf("unknown field %q", field)}
+		}
+	}
+	return q, nil
+}
+
+// run applies the query to nodes, returning the selected page ordered by node
+// ID and the number of nodes matching the filters. The page is a list of
+// nodes, or of objects holding the selected fields.
+func (q nodeQuery) run(nodes []*models.MatterNodeData) (interface{}, int, error) {
+	matching := make([]*models.MatterNodeData, 0, len(nodes))
+	for _, node := range nodes {
+		if q.available != nil && node.Available != *q.available {
+			continue
+		}
+		if q.isBridge != nil && node.IsBridge != *q.isBridge {
+			continue
+		}
+		if q.fabricID != 0 && node.FabricID != q.fabricID {
+			continue
+		}
+		matching = append(matching, node)
+	}
+	sort.Slice(matching, func(i, j int) bool {
+		return matching[i].NodeID < matching[j].NodeID
+	})
+
+	page := matching[min(q.offset, len(matching)):]
+	if q.limit > 0 && len(page) > q.limit {
+		page = page[:q.limit]
+	}
+
+	if q.annotate {
+		registry := clusters.Default()
+		annotated := make([]*models.MatterNodeData, len(page))
+		for i, node := range page {
+			nodeCopy := *node
+			nodeCopy.AttributeNames = registry.AnnotateAttributes(node.Attributes)
+			annotated[i] = &nodeCopy
+		}
+		page = annotated
+	}
+
+	if len(q.fields) == 0 {
+		return page, len(matching), nil
+	}
+
+	projected := make([]map[string]json.RawMessage, 0, len(page))
+	for _, node := range page {
+		selected, err := selectFields(node, q.fields)
+		if err != nil {
+			return nil, 0, err
+		}
+		projected = append(projected, selected)
+	}
+	return projected, len(matching), nil
+}
+
+// selectFields returns the named JSON fields of v. Fields omitted from v's
+// encoding are left out.
+func selectFields(v interface{}, fields []string) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+
+	selected := make(map[string]json.RawMessage, len(fields))
+	for _, field := range fields {
+		if value, ok := all[field]; ok {
+			selected[field] = value
+		}
+	}
+	return selected, nil
+}
+
+// jsonFieldNames returns the JSON names of a struct's exported fields
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		names[name] = true
+	}
+	return names
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+func TestNodeQuery(t *testing.T) {
+	nodes := func() []*models.MatterNodeData {
+		return []*models.MatterNodeData{
+			{NodeID: 4, Available: true},
+			{NodeID: 1, Available: true, IsBridge: true},
+			{NodeID: 3, Available: false},
+			{NodeID: 2, Available: true},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		args      map[string]interface{}
+		wantIDs   []int
+		wantTotal int
+	}{
+		{"All nodes ordered", nil, []int{1, 2, 3, 4}, 4},
+		{"Available", map[string]interface{}{"available": true}, []int{1, 2, 4}, 3},
+		{"Filters combined", map[string]interface{}{"available": "true", "is_bridge": "false"}, []int{2, 4}, 2},
+		{"Limit", map[string]interface{}{"limit": float64(2)}, []int{1, 2}, 4},
+		{"Offset", map[string]interface{}{"offset": float64(1), "limit": float64(2)}, []int{2, 3}, 4},
+		{"Offset past end", map[string]interface{}{"offset": float64(10)}, []int{}, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := validateArgs(models.APICommandGetNodes, tt.args)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			q, err := newNodeQuery(args)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			result, total, err := q.run(nodes())
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			page := result.([]*models.MatterNodeData)
+			ids := make([]int, 0, len(page))
+			for _, node := range page {
+				ids = append(ids, node.NodeID)
+			}
+			if len(ids) != len(tt.wantIDs) {
+				t.Fatalf("Expected nodes %v, got %v", tt.wantIDs, ids)
+			}
+			for i := range ids {
+				if ids[i] != tt.wantIDs[i] {
+					t.Errorf("Expected nodes %v, got %v", tt.wantIDs, ids)
+					break
+				}
+			}
+			if total != tt.wantTotal {
+				t.Errorf("Expected total %d, got %d", tt.wantTotal, total)
+			}
+		})
+	}
+}
+
+func TestNodeQueryFields(t *testing.T) {
+	args, err := validateArgs(models.APICommandGetNodes, map[string]interface{}{
+		"fields": []interface{}{"node_id", "available"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	q, err := newNodeQuery(args)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	result, _, err := q.run([]*models.MatterNodeData{{NodeID: 7, Available: true, Attributes: map[string]interface{}{}}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("Failed to encode result: %v", err)
+	}
+	if string(data) != `[{"available":true,"node_id":7}]` {
+		t.Errorf("Expected selected fields only, got %s", data)
+	}
+
+	args, _ = validateArgs(models.APICommandGetNodes, map[string]interface{}{"fields": "node_id,secret"})
+	_, err = newNodeQuery(args)
+	var argErr *models.ArgumentError
+	if !errors.As(err, &argErr) || argErr.Field != "fields" {
+		t.Errorf("Expected argument error for fields, got %v", err)
+	}
+}
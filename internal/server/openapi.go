@@ -0,0 +1,373 @@
+package server
+
+import (
+	"embed"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/openapi"
+)
+
+// docsPage is the Swagger UI page served at /api/docs
+//
+//go:embed docs.html
+var docsPage []byte
+
+// swaggerUI holds the assets of swagger-ui-dist 5.18.2 the docs page loads,
+// so it works offline
+//
+//go:embed swagger-ui
+var swaggerUI embed.FS
+
+// routeDoc documents an HTTP route that doesn't run a command
+type routeDoc struct {
+	summary string
+	// response is a value of the response body's type, nil for no body
+	response interface{}
+	// status is the success status code, 200 if unset
+	status int
+	// query is the command whose arguments the route takes as query
+	// parameters, if any
+	query models.APICommand
+	// params are further query parameters
+	params []openapi.Parameter
+	html   bool
+}
+
+// routeDocs documents the routes that aren't command routes, keyed by method
+// and path template
+var routeDocs = map[string]routeDoc{
+	"GET /api/info":             {summary: "Server information", response: models.ServerInfoMessage{}},
+	"GET /api/addon-info":       {summary: "Connection details and features of the server", response: models.AddonInfo{}},
+	"GET /api/nodes":            {summary: "List all nodes", response: []models.MatterNodeData{}, query: models.APICommandGetNodes},
+	"GET /api/nodes/{node_id}":  {summary: "Get a single node", response: models.MatterNodeData{}, query: models.APICommandGetNode},
+	"GET /api/devices":          {summary: "List the nodes' endpoints of supported device types", response: []models.Device{}, query: models.APICommandGetDevices},
+	"GET /api/energy":           {summary: "Power and energy of the nodes with their totals", response: models.EnergySummary{}, query: models.APICommandGetEnergySummary},
+	"GET /api/firmware":         {summary: "Software versions of the nodes and the updates available", response: models.FirmwareInventory{}, query: models.APICommandGetFirmwareInventory},
+	"GET /api/sessions":         {summary: "List connected WebSocket clients", response: []models.SessionInfo{}},
+	"DELETE /api/sessions/{id}": {summary: "Disconnect a WebSocket client", status: http.StatusNoContent},
+	"GET /api/openapi.json":     {summary: "This OpenAPI document", response: map[string]interface{}{}},
+	"GET /api/docs":             {summary: "Swagger UI for this API", html: true},
+	"GET /api/docs/{asset}":     {summary: "Script and stylesheet of the Swagger UI"},
+	"GET /health":               {summary: "Liveness probe (alias of /health/live)", response: map[string]interface{}{}},
+	"GET /health/live":          {summary: "Liveness probe", response: map[string]interface{}{}},
+	"GET /health/ready":         {summary: "Readiness probe, 503 while not ready", response: models.HealthStatus{}},
+	"GET /replication":          {summary: "Replication stream for standby servers, newline-delimited JSON (primary only)"},
+	"GET /api/commissioning/queue": {
+		summary:  "Queued and running commissioning requests in order",
+		response: []models.CommissioningQueueEntry{},
+	},
+	"GET /api/nodes/{node_id}/attributes/{attribute_path}": {
+		summary:  "Get cached attribute values keyed by attribute path",
+		response: map[string]interface{}{},
+	},
+	"GET /api/logs": {
+		summary:  "Recent log entries, oldest first",
+		response: []models.LogEntry{},
+		query:    models.APICommandGetLogs,
+	},
+	"GET /api/settings/export": {
+		summary:  "Export nodes, vendors and settings for import_settings",
+		response: models.SettingsExport{},
+	},
+	"GET /api/diagnostics": {
+		summary:  "Server diagnostics",
+		response: models.ServerDiagnostics{},
+		params: []openapi.Parameter{{
+			Name:        "format",
+			In:          "query",
+			Description: "bundle returns a zip archive with info, nodes, recent logs, redacted config and storage checksums",
+			Schema:      &openapi.Schema{Type: "string", Enum: []string{"json", "bundle"}},
+		}},
+	},
+}
+
+var (
+	routePatternRegexp = regexp.MustCompile(`\{([^}:]+):[^}]*\}`)
+	routeVarRegexp     = regexp.MustCompile(`\{([^}]+)\}`)
+)
+
+// handleOpenAPIHTTP serves the OpenAPI document of router. The document is
+// generated from the registered routes on first use.
+func (s *Server) handleOpenAPIHTTP(router *mux.Router) http.HandlerFunc {
+	var (
+		once sync.Once
+		doc  *openapi.Document
+		err  error
+	)
+	return func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			doc, err = openAPIDocument(router)
+		})
+		if err != nil {
+			s.writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		s.writeJSON(w, doc)
+	}
+}
+
+func (s *Server) handleDocsHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(docsPage)
+}
+
+// handleDocsAssetHTTP serves the embedded Swagger UI assets
+func (s *Server) handleDocsAssetHTTP(w http.ResponseWriter, r *http.Request) {
+	http.ServeFileFS(w, r, swaggerUI, "swagger-ui/"+mux.Vars(r)["asset"])
+}
+
+// openAPIDocument describes the HTTP routes of router. Command routes are
+// described by their argument schemas, other routes by routeDocs and the
+// models they return.
+func openAPIDocument(router *mux.Router) (*openapi.Document, error) {
+	g := openapi.NewGenerator()
+	errorResponse := openapi.Response{
+		Description: "Error",
+		Content:     openapi.JSON(g.SchemaOf(reflect.TypeOf(httpError{}))),
+	}
+
+	commands := make(map[string]models.APICommand, len(commandRoutes))
+	for _, route := range commandRoutes {
+		commands[route.method+" /api"+stripRoutePatterns(route.path)] = route.command
+	}
+
+	doc := &openapi.Document{
+		OpenAPI: openapi.Version,
+		Info: openapi.Info{
+			Title:       "Go Matter Server",
+			Version:     fmt.Sprint(models.SchemaVersion),
+			Description: "HTTP API of the Matter server. Real-time access is available over the WebSocket at /ws.",
+		},
+		Paths: make(map[string]openapi.PathItem),
+	}
+
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		// Routes without methods are subrouters and the WebSocket endpoint
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+
+		path := stripRoutePatterns(template)
+		for _, method := range methods {
+			key := method + " " + path
+			var op *openapi.Operation
+			if command, ok := commands[key]; ok {
+				op = commandOperation(g, path, command)
+				op.Responses["403"] = openapi.Response{
+					Description: "Origin not allowed",
+					Content:     errorResponse.Content,
+				}
+				op.Responses["415"] = openapi.Response{
+					Description: "Body not declared as application/json",
+					Content:     errorResponse.Content,
+				}
+				if _, ok := tokenCommands[command]; ok {
+					op.Responses["401"] = openapi.Response{
+						Description: "Missing or invalid bearer token",
+						Content:     errorResponse.Content,
+					}
+				}
+			} else {
+				op = routeOperation(g, path, routeDocs[key])
+			}
+			op.Responses["default"] = errorResponse
+
+			if doc.Paths[path] == nil {
+				doc.Paths[path] = make(openapi.PathItem)
+			}
+			doc.Paths[path][strings.ToLower(method)] = op
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	doc.Components.Schemas = g.Schemas()
+	return doc, nil
+}
+
+// routeOperation describes a route from its documentation
+func routeOperation(g *openapi.Generator, path string, route routeDoc) *openapi.Operation {
+	op := &openapi.Operation{
+		Summary:    route.summary,
+		Parameters: pathParameters(path),
+		Responses:  make(map[string]openapi.Response),
+	}
+	op.Parameters = append(op.Parameters, queryParameters(op.Parameters, route.query)...)
+	op.Parameters = append(op.Parameters, route.params...)
+
+	status := route.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	response := openapi.Response{Description: http.StatusText(status)}
+	switch {
+	case route.html:
+		response.Content = map[string]openapi.MediaType{"text/html": {Schema: &openapi.Schema{Type: "string"}}}
+	case route.response != nil:
+		response.Content = openapi.JSON(g.SchemaOf(reflect.TypeOf(route.response)))
+	}
+	op.Responses[fmt.Sprint(status)] = response
+	return op
+}
+
+// commandOperation describes a command route. Arguments not given by path
+// variables form the request body.
+func commandOperation(g *openapi.Generator, path string, command models.APICommand) *openapi.Operation {
+	op := &openapi.Operation{
+		Summary:     fmt.Sprintf("Run the %s command", command),
+		OperationID: string(command),
+		Tags:        []string{"commands"},
+		Parameters:  pathParameters(path),
+		Responses: map[string]openapi.Response{
+			"200": {Description: "Command result", Content: openapi.JSON(&openapi.Schema{})},
+		},
+	}
+
+	inPath := make(map[string]bool)
+	for _, param := range op.Parameters {
+		inPath[param.Name] = true
+	}
+
+	body := &openapi.Schema{Type: "object", Properties: make(map[string]*openapi.Schema)}
+	for _, spec := range commandSchemas[command] {
+		if inPath[spec.name] {
+			continue
+		}
+		body.Properties[spec.name] = argSchema(spec)
+		if spec.required {
+			body.Required = append(body.Required, spec.name)
+		}
+	}
+	if len(body.Properties) > 0 {
+		op.RequestBody = &openapi.RequestBody{
+			Required: len(body.Required) > 0,
+			Content:  openapi.JSON(body),
+		}
+	}
+	return op
+}
+
+// pathParameters describes the variables of a path template. Variables named
+// after command arguments share their schema.
+func pathParameters(path string) []openapi.Parameter {
+	var params []openapi.Parameter
+	for _, match := range routeVarRegexp.FindAllStringSubmatch(path, -1) {
+		param := openapi.Parameter{
+			Name:     match[1],
+			In:       "path",
+			Required: true,
+			Schema:   &openapi.Schema{Type: "string"},
+		}
+		if spec, ok := argSpecByName(match[1]); ok {
+			param.Schema = argSchema(spec)
+		}
+		if param.Name == "attribute_path" {
+			param.Description = "endpoint/cluster/attribute, each part may be *"
+		}
+		params = append(params, param)
+	}
+	return params
+}
+
+// queryParameters describes the arguments of command not given by path
+// parameters as query parameters
+func queryParameters(pathParams []openapi.Parameter, command models.APICommand) []openapi.Parameter {
+	inPath := make(map[string]bool)
+	for _, param := range pathParams {
+		inPath[param.Name] = true
+	}
+
+	var params []openapi.Parameter
+	for _, spec := range commandSchemas[command] {
+		if inPath[spec.name] {
+			continue
+		}
+		param := openapi.Parameter{
+			Name:     spec.name,
+			In:       "query",
+			Required: spec.required,
+			Schema:   argSchema(spec),
+		}
+		if spec.kind == argStringList {
+			explode := false
+			param.Explode = &explode
+		}
+		params = append(params, param)
+	}
+	return params
+}
+
+// argSpecByName returns the declaration of a command argument
+func argSpecByName(name string) (argSpec, bool) {
+	// Sorted so arguments declared by several commands resolve the same way
+	commands := make([]string, 0, len(commandSchemas))
+	for command := range commandSchemas {
+		commands = append(commands, string(command))
+	}
+	sort.Strings(commands)
+
+	for _, command := range commands {
+		for _, spec := range commandSchemas[models.APICommand(command)] {
+			if spec.name == name {
+				return spec, true
+			}
+		}
+	}
+	return argSpec{}, false
+}
+
+// argSchema returns the schema of a command argument
+func argSchema(spec argSpec) *openapi.Schema {
+	var schema *openapi.Schema
+	switch spec.kind {
+	case argInteger:
+		schema = &openapi.Schema{Type: "integer", Format: "int64"}
+		if spec.ranged {
+			schema.Minimum, schema.Maximum = &spec.min, &spec.max
+		}
+	case argString:
+		schema = &openapi.Schema{Type: "string"}
+	case argBool:
+		schema = &openapi.Schema{Type: "boolean"}
+	case argObject:
+		schema = &openapi.Schema{Type: "object"}
+	case argStringList:
+		schema = &openapi.Schema{Type: "array", Items: &openapi.Schema{Type: "string"}}
+	case argTime:
+		schema = &openapi.Schema{Type: "string", Format: "date-time"}
+	case argIDOrName:
+		schema = &openapi.Schema{OneOf: []*openapi.Schema{
+			{Type: "integer", Format: "int64"},
+			{Type: "string"},
+		}}
+	default:
+		schema = &openapi.Schema{}
+	}
+
+	if len(spec.aliases) > 0 {
+		schema.Description = "Also accepted as " + strings.Join(spec.aliases, ", ")
+	}
+	return schema
+}
+
+// stripRoutePatterns removes the patterns of route variables, turning
+// {node_id:[0-9]+} into {node_id}
+func stripRoutePatterns(template string) string {
+	return routePatternRegexp.ReplaceAllString(template, "{$1}")
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/codefionn/go-matter-server/internal/openapi"
+)
+
+func TestOpenAPIDocument(t *testing.T) {
+	server := createTestServer(t)
+	router := server.setupRouter()
+
+	req := httptest.NewRequest("GET", "/api/openapi.json", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var doc openapi.Document
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	// Every route must be documented, so the document can't drift
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			if op.Summary == "" {
+				t.Errorf("Route %s %s is not documented", strings.ToUpper(method), path)
+			}
+		}
+	}
+	if _, ok := doc.Paths["/ws"]; ok {
+		t.Error("Expected WebSocket endpoint not to be listed")
+	}
+
+	command := doc.Paths["/api/nodes/{node_id}/command"]["post"]
+	if command == nil || command.OperationID != "device_command" {
+		t.Fatalf("Expected device_command operation, got %+v", command)
+	}
+	if len(command.Parameters) != 1 || command.Parameters[0].Name != "node_id" || command.Parameters[0].Schema.Type != "integer" {
+		t.Errorf("Expected integer node_id path parameter, got %+v", command.Parameters)
+	}
+	body := command.RequestBody.Content["application/json"].Schema
+	if _, ok := body.Properties["node_id"]; ok {
+		t.Error("Expected node_id to be taken from the path only")
+	}
+	wantRequired := []string{"endpoint_id", "cluster_id", "command_name"}
+	if !reflect.DeepEqual(body.Required, wantRequired) {
+		t.Errorf("Expected required %v, got %v", wantRequired, body.Required)
+	}
+	if endpoint := body.Properties["endpoint_id"]; endpoint.Maximum == nil || *endpoint.Maximum != 65535 {
+		t.Errorf("Expected endpoint_id range, got %+v", endpoint)
+	}
+
+	node := doc.Paths["/api/nodes/{node_id}"]["get"]
+	if node == nil || node.Responses["200"].Content["application/json"].Schema.Ref != "#/components/schemas/MatterNodeData" {
+		t.Errorf("Expected MatterNodeData response, got %+v", node)
+	}
+	if _, ok := doc.Components.Schemas["MatterNodeData"]; !ok {
+		t.Error("Expected MatterNodeData component schema")
+	}
+	if len(node.Parameters) != 2 || node.Parameters[1].Name != "annotate" || node.Parameters[1].In != "query" {
+		t.Errorf("Expected node_id and annotate parameters, got %+v", node.Parameters)
+	}
+
+	query := make(map[string]openapi.Parameter)
+	for _, param := range doc.Paths["/api/nodes"]["get"].Parameters {
+		query[param.Name] = param
+	}
+	for _, name := range []string{"annotate", "limit", "offset", "fields", "available", "is_bridge"} {
+		if param, ok := query[name]; !ok || param.In != "query" {
+			t.Errorf("Expected %s query parameter, got %+v", name, param)
+		}
+	}
+	if fields := query["fields"]; fields.Explode == nil || *fields.Explode {
+		t.Errorf("Expected comma-separated fields parameter, got %+v", fields)
+	}
+}
+
+func TestDocsPage(t *testing.T) {
+	server := createTestServer(t)
+	router := server.setupRouter()
+
+	req := httptest.NewRequest("GET", "/api/docs", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Expected HTML, got %s", ct)
+	}
+	if !strings.Contains(w.Body.String(), "openapi.json") {
+		t.Error("Expected docs page to load the OpenAPI document")
+	}
+	if strings.Contains(w.Body.String(), "https://") {
+		t.Error("Expected docs page to load only embedded assets")
+	}
+
+	// The assets are served from the binary
+	for asset, contentType := range map[string]string{
+		"swagger-ui-bundle.js": "text/javascript",
+		"swagger-ui.css":       "text/css",
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/api/docs/"+asset, nil))
+		if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), contentType) || w.Body.Len() == 0 {
+			t.Errorf("Expected %s as %s, got %d with %q", asset, contentType, w.Code, w.Header().Get("Content-Type"))
+		}
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/docs/missing.js", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing asset, got %d", w.Code)
+	}
+}
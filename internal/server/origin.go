@@ -0,0 +1,52 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// originPolicy decides which cross-origin requests are allowed, for both
+// CORS and WebSocket upgrades
+type originPolicy struct {
+	any     bool
+	allowed map[string]bool
+}
+
+func newOriginPolicy(origins []string) *originPolicy {
+	p := &originPolicy{allowed: make(map[string]bool)}
+	for _, origin := range origins {
+		origin = strings.TrimSpace(origin)
+		switch origin {
+		case "":
+		case "*":
+			p.any = true
+		default:
+			p.allowed[normalizeOrigin(origin)] = true
+		}
+	}
+	return p
+}
+
+// allows reports whether a cross-origin request from origin is allowed
+func (p *originPolicy) allows(origin string) bool {
+	return p.any || p.allowed[normalizeOrigin(origin)]
+}
+
+// checkOrigin allows WebSocket upgrades and REST commands without an Origin
+// header (non-browser clients), from the server's own origin and from
+// allowed origins
+func (p *originPolicy) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return p.allows(origin)
+}
+
+func normalizeOrigin(origin string) string {
+	return strings.ToLower(strings.TrimSuffix(origin, "/"))
+}
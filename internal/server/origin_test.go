@@ -0,0 +1,56 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOriginPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		origin  string
+		want    bool
+	}{
+		{"Empty policy", nil, "https://example.com", false},
+		{"Exact match", []string{"https://example.com"}, "https://example.com", true},
+		{"Case and trailing slash", []string{"https://Example.com/"}, "https://example.com", true},
+		{"Different port", []string{"https://example.com"}, "https://example.com:8443", false},
+		{"Wildcard", []string{"*"}, "https://anything.example", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newOriginPolicy(tt.allowed).allows(tt.origin); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCheckWebSocketOrigin(t *testing.T) {
+	policy := newOriginPolicy([]string{"https://dashboard.local"})
+
+	tests := []struct {
+		name   string
+		origin string
+		want   bool
+	}{
+		{"No origin", "", true},
+		{"Same origin", "http://matter.local:5580", true},
+		{"Allowed origin", "https://dashboard.local", true},
+		{"Foreign origin", "https://evil.example", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://matter.local:5580/ws", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if got := policy.checkOrigin(req); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"sync/atomic"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// panicCounters counts the panics recovered by the server itself, the
+// WebSocket handler counts its own
+type panicCounters struct {
+	http   atomic.Uint64
+	events atomic.Uint64
+}
+
+// panicStats returns the panics recovered so far
+func (s *Server) panicStats() models.PanicStats {
+	return models.PanicStats{
+		HTTP:           s.panics.http.Load(),
+		WebSocket:      s.wsHandler.Stats().Panics,
+		EventCallbacks: s.panics.events.Load(),
+	}
+}
+
+// headerRecorder notes whether a handler already wrote the response header
+type headerRecorder struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (r *headerRecorder) WriteHeader(code int) {
+	r.wroteHeader = true
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *headerRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (r *headerRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Hijack forwards the WebSocket upgrade, the connection is the handler's
+// from then on
+func (r *headerRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer doesn't support hijacking")
+	}
+	r.wroteHeader = true
+	return hijacker.Hijack()
+}
+
+// Flush forwards flushes of streaming handlers
+func (r *headerRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		r.wroteHeader = true
+		flusher.Flush()
+	}
+}
+
+// recoveryMiddleware answers requests whose handler panicked with a 500
+// response, unless the handler already started the response, and logs the
+// stack with the request
+func (s *Server) recoveryMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := &headerRecorder{ResponseWriter: w}
+		defer func() {
+			value := recover()
+			if value == nil {
+				return
+			}
+			// Handlers abort streamed responses on purpose
+			if value == http.ErrAbortHandler {
+				panic(value)
+			}
+
+			s.panics.http.Add(1)
+			s.logger.Error("HTTP handler panicked",
+				logger.String("method", r.Method),
+				logger.String("path", r.URL.Path),
+				logger.String("remote_addr", r.RemoteAddr),
+				logger.String("panic", fmt.Sprint(value)),
+				logger.String("stack", string(debug.Stack())),
+			)
+			if !recorder.wroteHeader {
+				s.writeError(w, http.StatusInternalServerError, "internal server error")
+			}
+		}()
+		next.ServeHTTP(recorder, r)
+	})
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+func TestRecoveryMiddleware(t *testing.T) {
+	server := createTestServer(t)
+	handler := server.recoveryMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/streaming" {
+			w.WriteHeader(http.StatusOK)
+		}
+		panic("handler bug")
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/nodes", nil))
+	var body httpError
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusInternalServerError || body.Error != "internal server error" {
+		t.Errorf("Expected a 500 response, got %d %+v", w.Code, body)
+	}
+
+	// A response already started is left as it is
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/streaming", nil))
+	if w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Errorf("Expected the started response untouched, got %d %q", w.Code, w.Body.String())
+	}
+
+	if panics := server.panicStats().HTTP; panics != 2 {
+		t.Errorf("Expected 2 HTTP panics counted, got %d", panics)
+	}
+}
+
+func TestEventSubscriberPanicRecovered(t *testing.T) {
+	server := createTestServer(t)
+	baseline := len(server.eventSubscriberStats())
+
+	received := make(chan int, 2)
+	unsubscribe := server.Subscribe(func(eventType models.EventType, data interface{}) {
+		if eventType != models.EventTypeAttributeUpdated {
+			return
+		}
+		n := data.([]interface{})[0].(int)
+		if n == 1 {
+			panic("subscriber bug")
+		}
+		received <- n
+	})
+	defer unsubscribe()
+
+	// The subscriber keeps receiving events after a panic
+	for n := 1; n <= 2; n++ {
+		server.EmitEvent(models.EventTypeAttributeUpdated, []interface{}{n, "1/6/0", true})
+	}
+	select {
+	case n := <-received:
+		if n != 2 {
+			t.Errorf("Expected event 2, got %d", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the event after the panic")
+	}
+
+	if stats := server.eventSubscriberStats(); stats[baseline].Panics != 1 {
+		t.Errorf("Expected 1 panic of the subscriber, got %+v", stats[baseline])
+	}
+	if panics := server.panicStats().EventCallbacks; panics != 1 {
+		t.Errorf("Expected 1 event callback panic counted, got %d", panics)
+	}
+}
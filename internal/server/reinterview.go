@@ -0,0 +1,266 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/clusters"
+	"github.com/codefionn/go-matter-server/internal/controller"
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// Reasons a node is re-interviewed automatically
+const (
+	reinterviewOutdated = "outdated_interview"
+	reinterviewFirmware = "firmware_update"
+	reinterviewPeriodic = "periodic"
+)
+
+const (
+	// reinterviewSweepInterval is how often the nodes are checked for an
+	// outdated or periodic re-interview
+	reinterviewSweepInterval = time.Minute
+
+	// reinterviewRetryDelay postpones the re-interview of a node that was
+	// unavailable or failed to be interviewed
+	reinterviewRetryDelay = 15 * time.Minute
+
+	// reinterviewTimeout limits a single re-interview
+	reinterviewTimeout = 5 * time.Minute
+)
+
+// Events indicating a firmware update of a node
+const (
+	otaRequestorEventVersionApplied = 0x01
+	basicInformationEventStartUp    = 0x00
+
+	basicInformationSoftwareVersion = 0x0009
+)
+
+// scheduledInterview is a pending re-interview of a node
+type scheduledInterview struct {
+	reason string
+	due    time.Time
+}
+
+// reinterviewScheduler holds the pending and running re-interviews
+type reinterviewScheduler struct {
+	mu      sync.Mutex
+	pending map[int]scheduledInterview
+	running map[int]bool
+	wake    chan struct{}
+}
+
+// init creates the maps and wake channel. Must be called with mu held.
+func (r *reinterviewScheduler) init() {
+	if r.pending == nil {
+		r.pending = make(map[int]scheduledInterview)
+		r.running = make(map[int]bool)
+		r.wake = make(chan struct{}, 1)
+	}
+}
+
+// signal wakes the scheduler loop. Must be called with mu held.
+func (r *reinterviewScheduler) signal() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// scheduleReinterview re-interviews a node after delay plus a random jitter.
+// A node already pending keeps the earlier of both times.
+func (s *Server) scheduleReinterview(nodeID int, reason string, delay time.Duration) {
+	if jitter := s.config.Interview.Jitter; jitter > 0 {
+		delay += rand.N(jitter)
+	}
+	due := time.Now().Add(delay)
+
+	r := &s.reinterviews
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.init()
+
+	if scheduled, exists := r.pending[nodeID]; exists && !due.Before(scheduled.due) {
+		return
+	}
+	r.pending[nodeID] = scheduledInterview{reason: reason, due: due}
+	r.signal()
+}
+
+// sweepReinterviews schedules the nodes with an interview of an older data
+// model version or, with interview.interval, an interview older than that
+func (s *Server) sweepReinterviews() {
+	s.reinterviews.mu.Lock()
+	s.reinterviews.init()
+	skip := make(map[int]bool, len(s.reinterviews.pending)+len(s.reinterviews.running))
+	for nodeID := range s.reinterviews.pending {
+		skip[nodeID] = true
+	}
+	for nodeID := range s.reinterviews.running {
+		skip[nodeID] = true
+	}
+	s.reinterviews.mu.Unlock()
+
+	interval := s.config.Interview.Interval
+	for _, node := range s.nodeSnapshot() {
+		if skip[node.NodeID] {
+			continue
+		}
+		switch {
+		case node.InterviewVersion < models.CurrentInterviewVersion:
+			s.scheduleReinterview(node.NodeID, reinterviewOutdated, 0)
+		case interval > 0 && time.Since(node.LastInterview) >= interval:
+			s.scheduleReinterview(node.NodeID, reinterviewPeriodic, 0)
+		}
+	}
+}
+
+// runReinterviews starts the due re-interviews, at most
+// interview.concurrency at a time, until ctx is cancelled
+func (s *Server) runReinterviews(ctx context.Context) {
+	sweep := time.NewTicker(reinterviewSweepInterval)
+	defer sweep.Stop()
+
+	s.reinterviews.mu.Lock()
+	s.reinterviews.init()
+	wake := s.reinterviews.wake
+	s.reinterviews.mu.Unlock()
+
+	s.sweepReinterviews()
+	for {
+		wait := s.startDueReinterviews(ctx)
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-sweep.C:
+			s.sweepReinterviews()
+		case <-wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// startDueReinterviews starts the due re-interviews, the earliest first, and
+// returns how long until the next one is due
+func (s *Server) startDueReinterviews(ctx context.Context) time.Duration {
+	r := &s.reinterviews
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	due := make([]int, 0, len(r.pending))
+	for nodeID := range r.pending {
+		due = append(due, nodeID)
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return r.pending[due[i]].due.Before(r.pending[due[j]].due)
+	})
+
+	now := time.Now()
+	wait := reinterviewSweepInterval
+	for _, nodeID := range due {
+		scheduled := r.pending[nodeID]
+		if scheduled.due.After(now) {
+			wait = min(wait, scheduled.due.Sub(now))
+			break
+		}
+		// Wait for a running re-interview to finish
+		if r.running[nodeID] || len(r.running) >= s.config.Interview.Concurrency {
+			continue
+		}
+
+		delete(r.pending, nodeID)
+		r.running[nodeID] = true
+		go s.reinterview(ctx, nodeID, scheduled.reason)
+	}
+	return wait
+}
+
+// reinterview interviews a node on behalf of the scheduler. Unavailable nodes
+// and failed interviews are retried later.
+func (s *Server) reinterview(ctx context.Context, nodeID int, reason string) {
+	defer func() {
+		s.reinterviews.mu.Lock()
+		delete(s.reinterviews.running, nodeID)
+		s.reinterviews.signal()
+		s.reinterviews.mu.Unlock()
+	}()
+
+	s.nodesMu.RLock()
+	node, exists := s.nodes[nodeID]
+	reachable := exists && node.Available && !node.Sleepy
+	s.nodesMu.RUnlock()
+	if !exists {
+		return
+	}
+	if !reachable {
+		s.scheduleReinterview(nodeID, reason, reinterviewRetryDelay)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, reinterviewTimeout)
+	defer cancel()
+
+	err := s.interviewNode(ctx, nodeID)
+	var notFound *models.NodeNotFoundError
+	switch {
+	case err == nil:
+		s.logger.Info("Re-interviewed node", logger.Int("node_id", nodeID), logger.String("reason", reason))
+	case errors.As(err, &notFound):
+	case errors.Is(err, controller.ErrNotAvailable):
+		s.logger.Debug("Skipping re-interview", logger.Int("node_id", nodeID), logger.ErrorField(err))
+		s.scheduleReinterview(nodeID, reason, reinterviewRetryDelay)
+	default:
+		s.logger.Warn("Failed to re-interview node",
+			logger.Int("node_id", nodeID),
+			logger.String("reason", reason),
+			logger.ErrorField(err),
+		)
+		// Unless the server is shutting down
+		if !errors.Is(ctx.Err(), context.Canceled) {
+			s.scheduleReinterview(nodeID, reason, reinterviewRetryDelay)
+		}
+	}
+}
+
+// reinterviewAfterEvent schedules a re-interview of a node whose firmware
+// was updated: the OTA Requestor reports the applied version, or the node
+// starts up with a software version other than the cached one
+func (s *Server) reinterviewAfterEvent(event models.MatterNodeEvent) {
+	if s.config.Interview.Concurrency == 0 {
+		return
+	}
+
+	switch {
+	case event.ClusterID == clusters.OTASoftwareUpdateRequestorClusterID && event.EventID == otaRequestorEventVersionApplied:
+	case event.ClusterID == clusters.BasicInformationClusterID && event.EventID == basicInformationEventStartUp:
+		version, ok := toInt(structField(event.Data, "0", "softwareVersion"))
+		if !ok {
+			return
+		}
+		s.nodesMu.RLock()
+		node, exists := s.nodes[event.NodeID]
+		var cached interface{}
+		if exists {
+			cached = node.Attributes[attributePath(0, clusters.BasicInformationClusterID, basicInformationSoftwareVersion)]
+		}
+		s.nodesMu.RUnlock()
+		if previous, known := toInt(cached); !known || previous == version {
+			return
+		}
+	default:
+		return
+	}
+
+	s.logger.Info("Node firmware updated, scheduling re-interview", logger.Int("node_id", event.NodeID))
+	s.scheduleReinterview(event.NodeID, reinterviewFirmware, 0)
+}
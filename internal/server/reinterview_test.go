@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/clusters"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// slowInterviewController interviews nodes slowly, tracking how many at once
+type slowInterviewController struct {
+	fakeController
+	mu                sync.Mutex
+	active, maxActive int
+	interviewed       []int
+}
+
+func (c *slowInterviewController) Interview(ctx context.Context, nodeID int) (map[string]interface{}, error) {
+	c.mu.Lock()
+	c.active++
+	c.maxActive = max(c.maxActive, c.active)
+	c.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	c.mu.Lock()
+	c.active--
+	c.interviewed = append(c.interviewed, nodeID)
+	c.mu.Unlock()
+	return map[string]interface{}{"0/40/9": float64(2)}, nil
+}
+
+func TestSweepReinterviews(t *testing.T) {
+	server := createTestServer(t)
+	server.config.Interview.Concurrency = 1
+	server.config.Interview.Interval = time.Hour
+	now := time.Now()
+	server.nodes[1] = &models.MatterNodeData{NodeID: 1, LastInterview: now}
+	server.nodes[2] = &models.MatterNodeData{NodeID: 2, LastInterview: now, InterviewVersion: models.CurrentInterviewVersion}
+	server.nodes[3] = &models.MatterNodeData{NodeID: 3, LastInterview: now.Add(-2 * time.Hour), InterviewVersion: models.CurrentInterviewVersion}
+
+	server.sweepReinterviews()
+
+	pending := server.reinterviews.pending
+	if len(pending) != 2 || pending[1].reason != reinterviewOutdated || pending[3].reason != reinterviewPeriodic {
+		t.Errorf("Expected nodes 1 and 3 scheduled, got %+v", pending)
+	}
+
+	// An earlier re-interview replaces a later one, not the other way round
+	server.scheduleReinterview(1, reinterviewFirmware, time.Hour)
+	if server.reinterviews.pending[1].reason != reinterviewOutdated {
+		t.Errorf("Expected the earlier re-interview kept, got %+v", server.reinterviews.pending[1])
+	}
+}
+
+func TestRunReinterviews(t *testing.T) {
+	server := createTestServer(t)
+	server.config.Interview.Concurrency = 2
+	fake := &slowInterviewController{}
+	server.controller = fake
+	for nodeID := 1; nodeID <= 5; nodeID++ {
+		server.nodes[nodeID] = &models.MatterNodeData{NodeID: nodeID, Available: true, Attributes: map[string]interface{}{}}
+	}
+	server.nodes[6] = &models.MatterNodeData{NodeID: 6, Available: false, Attributes: map[string]interface{}{}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.runReinterviews(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		fake.mu.Lock()
+		done := len(fake.interviewed) == 5
+		fake.mu.Unlock()
+		if done {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the re-interviews")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+
+	fake.mu.Lock()
+	if fake.maxActive > 2 {
+		t.Errorf("Expected at most 2 interviews at once, got %d", fake.maxActive)
+	}
+	fake.mu.Unlock()
+
+	for _, node := range server.nodeSnapshot() {
+		if node.NodeID == 6 {
+			continue
+		}
+		if node.InterviewVersion != models.CurrentInterviewVersion || node.Attributes["0/40/9"] != float64(2) {
+			t.Errorf("Expected node %d re-interviewed, got %+v", node.NodeID, node)
+		}
+	}
+
+	// The unavailable node is retried later
+	server.reinterviews.mu.Lock()
+	retry, pending := server.reinterviews.pending[6]
+	server.reinterviews.mu.Unlock()
+	if !pending || time.Until(retry.due) < reinterviewRetryDelay-time.Minute {
+		t.Errorf("Expected a retry of node 6, got %+v", retry)
+	}
+}
+
+func TestReinterviewAfterEvent(t *testing.T) {
+	server := createTestServer(t)
+	server.config.Interview.Concurrency = 1
+	server.nodes[1] = &models.MatterNodeData{NodeID: 1, Attributes: map[string]interface{}{"0/40/9": float64(1)}}
+
+	startUp := func(version float64) models.MatterNodeEvent {
+		return models.MatterNodeEvent{
+			NodeID:    1,
+			ClusterID: clusters.BasicInformationClusterID,
+			EventID:   basicInformationEventStartUp,
+			Data:      map[string]interface{}{"0": version},
+		}
+	}
+
+	server.reinterviewAfterEvent(startUp(1))
+	if len(server.reinterviews.pending) != 0 {
+		t.Errorf("Expected no re-interview for an unchanged version, got %+v", server.reinterviews.pending)
+	}
+
+	server.reinterviewAfterEvent(startUp(2))
+	if server.reinterviews.pending[1].reason != reinterviewFirmware {
+		t.Errorf("Expected a firmware re-interview, got %+v", server.reinterviews.pending)
+	}
+
+	server.reinterviewAfterEvent(models.MatterNodeEvent{
+		NodeID:    2,
+		ClusterID: clusters.OTASoftwareUpdateRequestorClusterID,
+		EventID:   otaRequestorEventVersionApplied,
+	})
+	if server.reinterviews.pending[2].reason != reinterviewFirmware {
+		t.Errorf("Expected a re-interview after VersionApplied, got %+v", server.reinterviews.pending)
+	}
+}
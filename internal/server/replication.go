@@ -0,0 +1,219 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/replication"
+	"github.com/codefionn/go-matter-server/internal/storage"
+)
+
+// replicatedFiles are mirrored to standbys besides the storage: the fabric
+// credentials, the group keys, the virtual scenes, the ICD Check-In keys and
+// the key of the SRP registration. They are copied encrypted, so the standby needs the same storage encryption key.
+func (s *Server) replicatedFiles() []string {
+	files := []string{"credentials", "groups.json", "scenes.json", "icd.json", srpKeyFile}
+	for _, fabric := range s.fabrics[1:] {
+		files = append(files, filepath.Join(fabricDir(fabric.fabricID), "credentials"))
+	}
+	return files
+}
+
+// setupReplication makes the server a primary streaming its storage to
+// standbys, or a standby mirroring a primary
+func (s *Server) setupReplication(jsonStorage *storage.JSONStorage) error {
+	cfg := s.config.Replication
+	var err error
+	switch cfg.Role {
+	case "primary":
+		s.replicationPrimary, err = replication.NewPrimary(jsonStorage, replication.PrimaryConfig{
+			Token:             cfg.Token,
+			BasePath:          s.config.Storage.Path,
+			Files:             s.replicatedFiles(),
+			HeartbeatInterval: cfg.HeartbeatInterval,
+			Logger:            s.logger.WithName("replication"),
+		})
+		if err != nil {
+			return err
+		}
+		s.storage = s.replicationPrimary
+	case "standby":
+		s.standby, err = replication.NewStandby(replication.StandbyConfig{
+			PrimaryURL:        cfg.PrimaryURL,
+			Token:             cfg.Token,
+			Storage:           jsonStorage,
+			BasePath:          s.config.Storage.Path,
+			Files:             s.replicatedFiles(),
+			HeartbeatInterval: cfg.HeartbeatInterval,
+			PromoteAfter:      cfg.PromoteAfter,
+			AllowPlaintext:    cfg.AllowPlaintext,
+			Applied:           s.applyReplicated,
+			Promoted:          s.promote,
+			Logger:            s.logger.WithName("replication"),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isStandby reports whether the server mirrors a primary and leaves the
+// devices to it
+func (s *Server) isStandby() bool {
+	return s.standby != nil && !s.standby.Promoted()
+}
+
+// applyReplicated updates the nodes clients see after the standby stored a
+// change of the primary
+func (s *Server) applyReplicated(msg replication.Message) {
+	switch msg.Type {
+	case replication.MessageSnapshot:
+		s.reloadReplicatedNodes()
+		s.applyServerSettings()
+		s.reloadCredentials()
+	case replication.MessageNodeSaved:
+		node := msg.Node
+		s.tagFabric(node)
+		expandBridge(node)
+		s.nodesMu.Lock()
+		_, exists := s.nodes[node.NodeID]
+		s.nodes[node.NodeID] = node
+		s.nodesMu.Unlock()
+		if exists {
+			s.EmitEvent(models.EventTypeNodeUpdated, node)
+		} else {
+			s.EmitEvent(models.EventTypeNodeAdded, node)
+		}
+	case replication.MessageNodeDeleted:
+		s.nodesMu.Lock()
+		_, exists := s.nodes[msg.NodeID]
+		delete(s.nodes, msg.NodeID)
+		s.nodesMu.Unlock()
+		if exists {
+			s.EmitEvent(models.EventTypeNodeRemoved, msg.NodeID)
+		}
+	case replication.MessageSettingSaved, replication.MessageSettingDeleted:
+		s.applyServerSettings()
+	case replication.MessageFiles:
+		s.reloadCredentials()
+	}
+}
+
+// reloadReplicatedNodes replaces the nodes with the stored snapshot of the
+// primary, emitting events for the differences
+func (s *Server) reloadReplicatedNodes() {
+	nodes, err := s.storage.GetNodes()
+	if err != nil {
+		s.logger.Error("Failed to load replicated nodes", logger.ErrorField(err))
+		return
+	}
+
+	s.nodesMu.Lock()
+	previous := s.nodes
+	s.nodes = make(map[int]*models.MatterNodeData, len(nodes))
+	for _, node := range nodes {
+		s.tagFabric(node)
+		expandBridge(node)
+		s.nodes[node.NodeID] = node
+	}
+	s.nodesMu.Unlock()
+
+	for _, node := range nodes {
+		if _, exists := previous[node.NodeID]; exists {
+			s.EmitEvent(models.EventTypeNodeUpdated, node)
+		} else {
+			s.EmitEvent(models.EventTypeNodeAdded, node)
+		}
+	}
+	for nodeID := range previous {
+		if _, exists := s.nodes[nodeID]; !exists {
+			s.EmitEvent(models.EventTypeNodeRemoved, nodeID)
+		}
+	}
+}
+
+// reloadCredentials reads the fabric credentials, groups and scenes replicated from
+// the primary. A standby started without credentials generated its own
+// fabrics, the services named after them are replaced.
+func (s *Server) reloadCredentials() {
+	previous := s.compressedFabricIDs()
+	for _, fabric := range s.fabrics {
+		if err := fabric.credentials.Load(); err != nil {
+			s.logger.Error("Failed to load replicated fabric credentials",
+				logger.Int("fabric_id", fabric.fabricID),
+				logger.ErrorField(err),
+			)
+			return
+		}
+	}
+	if err := s.groups.Load(); err != nil {
+		s.logger.Error("Failed to load replicated groups", logger.ErrorField(err))
+	}
+	if err := s.scenes.Load(); err != nil {
+		s.logger.Error("Failed to load replicated scenes", logger.ErrorField(err))
+	}
+	if err := s.icd.Load(); err != nil {
+		s.logger.Error("Failed to load replicated ICD registrations", logger.ErrorField(err))
+	}
+
+	current := s.compressedFabricIDs()
+	if slices.Equal(current, previous) {
+		return
+	}
+	for i, fabric := range s.fabrics {
+		if current[i] == previous[i] {
+			continue
+		}
+		if s.mdnsZone != nil {
+			for _, service := range s.fabricServices(fabric, previous[i]) {
+				s.mdnsZone.RemoveService(service.Instance, service.Type)
+			}
+			for _, service := range s.fabricServices(fabric, current[i]) {
+				s.mdnsZone.AddService(service)
+			}
+		}
+		s.logger.Info("Loaded replicated fabric",
+			logger.Int("fabric_id", fabric.fabricID),
+			logger.String("compressed_fabric_id", fmt.Sprintf("%016X", current[i])),
+		)
+	}
+	s.EmitEvent(models.EventTypeServerInfoUpdated, s.GetServerInfo())
+}
+
+// promote takes over from the primary: the subsystems talking to the devices
+// are started with the mirrored state
+func (s *Server) promote(ctx context.Context) {
+	s.reloadCredentials()
+	s.activate(ctx)
+	s.EmitEvent(models.EventTypeServerInfoUpdated, s.GetServerInfo())
+}
+
+// handleGetReplicationStatus reports the role of the server and the state of
+// replication
+func (s *Server) handleGetReplicationStatus() (interface{}, error) {
+	switch {
+	case s.replicationPrimary != nil:
+		return models.ReplicationStatus{Role: "primary", Standbys: s.replicationPrimary.Standbys()}, nil
+	case s.standby != nil:
+		return s.standby.Status(), nil
+	}
+	return models.ReplicationStatus{Role: "disabled"}, nil
+}
+
+// handlePromoteStandby makes a standby take over without waiting for
+// promote_after
+func (s *Server) handlePromoteStandby() (interface{}, error) {
+	if s.standby == nil {
+		return nil, errors.New("server is not a replication standby")
+	}
+	if !s.standby.Promote() {
+		return nil, errors.New("standby was already promoted")
+	}
+	return s.standby.Status(), nil
+}
@@ -0,0 +1,186 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/audit"
+	"github.com/codefionn/go-matter-server/internal/config"
+	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/replication"
+	"github.com/codefionn/go-matter-server/internal/storage"
+)
+
+func createReplicationServer(t *testing.T, cfg config.ReplicationConfig) *Server {
+	t.Helper()
+	server := createTestServer(t)
+	server.config.Replication = cfg
+	if err := server.setupReplication(server.storage.(*storage.JSONStorage)); err != nil {
+		t.Fatalf("Failed to set up replication: %v", err)
+	}
+	return server
+}
+
+func TestStandbyCommands(t *testing.T) {
+	server := createReplicationServer(t, config.ReplicationConfig{
+		Role:              "standby",
+		PrimaryURL:        "http://127.0.0.1:1",
+		Token:             "0123456789abcdef",
+		HeartbeatInterval: time.Second,
+		AllowPlaintext:    true,
+	})
+	defer server.availabilityMonitor.Stop()
+	server.config.Server.AdminToken = "fedcba9876543210"
+	ctx := context.Background()
+	admin := audit.WithClient(ctx, audit.Client{BearerToken: "fedcba9876543210"})
+	replica := audit.WithClient(ctx, audit.Client{BearerToken: "0123456789abcdef"})
+
+	setLogLevel := models.CommandMessage{
+		MessageID: "1",
+		Command:   string(models.APICommandSetLogLevel),
+		Args:      map[string]interface{}{"level": "info"},
+	}
+	if _, err := server.HandleCommand(admin, setLogLevel); !errors.Is(err, models.ErrStandby) {
+		t.Fatalf("Expected state changes to be refused on a standby, got %v", err)
+	}
+	// Reading works
+	if _, err := server.HandleCommand(ctx, models.CommandMessage{MessageID: "2", Command: string(models.APICommandGetNodes)}); err != nil {
+		t.Fatalf("get_nodes failed: %v", err)
+	}
+
+	result, err := server.HandleCommand(ctx, models.CommandMessage{MessageID: "3", Command: string(models.APICommandGetReplicationStatus)})
+	if err != nil {
+		t.Fatalf("get_replication_status failed: %v", err)
+	}
+	if status := result.(models.ReplicationStatus); status.Role != "standby" || status.Promoted || status.Synced {
+		t.Errorf("Unexpected status %+v", status)
+	}
+
+	// Only the replication token promotes, whichever transport sent it
+	promote := models.CommandMessage{MessageID: "4", Command: string(models.APICommandPromoteStandby)}
+	for _, ctx := range []context.Context{
+		ctx,
+		admin,
+		audit.WithClient(ctx, audit.Client{ConnectionID: "conn-a", RemoteAddress: "192.0.2.1:5000"}),
+		audit.WithClient(ctx, audit.Client{ConnectionID: "grpc", BearerToken: "0123456789abcdeF"}),
+	} {
+		if _, err := server.HandleCommand(ctx, promote); !errors.Is(err, models.ErrUnauthorized) {
+			t.Errorf("Expected promote_standby to be refused without the replication token, got %v", err)
+		}
+	}
+	if server.standby.Status().Promoted {
+		t.Fatal("Expected the standby not to be promoted")
+	}
+
+	result, err = server.HandleCommand(replica, promote)
+	if err != nil {
+		t.Fatalf("promote_standby failed: %v", err)
+	}
+	if status := result.(models.ReplicationStatus); !status.Promoted {
+		t.Errorf("Expected the standby to be promoted, got %+v", status)
+	}
+	if _, err := server.HandleCommand(admin, setLogLevel); err != nil {
+		t.Errorf("Expected state changes after promotion, got %v", err)
+	}
+	if _, err := server.HandleCommand(replica, models.CommandMessage{MessageID: "5", Command: string(models.APICommandPromoteStandby)}); err == nil {
+		t.Error("Expected a second promotion to fail")
+	}
+}
+
+func TestApplyReplicated(t *testing.T) {
+	server := createTestServer(t)
+	events := make(chan models.EventType, 10)
+	server.Subscribe(func(eventType models.EventType, data interface{}) {
+		events <- eventType
+	})
+	receive := func(count int) []string {
+		t.Helper()
+		var received []string
+		for len(received) < count {
+			select {
+			case event := <-events:
+				received = append(received, string(event))
+			case <-time.After(time.Second):
+				t.Fatalf("Expected %d events, got %v", count, received)
+			}
+		}
+		sort.Strings(received)
+		return received
+	}
+
+	server.applyReplicated(replication.Message{Type: replication.MessageNodeSaved, Node: &models.MatterNodeData{NodeID: 5}})
+	server.applyReplicated(replication.Message{Type: replication.MessageNodeSaved, Node: &models.MatterNodeData{NodeID: 5, Available: true}})
+	if received := receive(2); received[0] != "node_added" || received[1] != "node_updated" {
+		t.Errorf("Expected node_added and node_updated, got %v", received)
+	}
+	if nodes := server.nodeSnapshot(); len(nodes) != 1 || !nodes[0].Available {
+		t.Errorf("Expected the updated node, got %+v", nodes)
+	}
+
+	// A snapshot stored by the standby replaces the nodes
+	server.storage.SaveNode(&models.MatterNodeData{NodeID: 6})
+	server.applyReplicated(replication.Message{Type: replication.MessageSnapshot, Snapshot: &replication.Snapshot{}})
+	if received := receive(2); received[0] != "node_added" || received[1] != "node_removed" {
+		t.Errorf("Expected node_added and node_removed, got %v", received)
+	}
+	if nodes := server.nodeSnapshot(); len(nodes) != 1 || nodes[0].NodeID != 6 {
+		t.Errorf("Expected node 6 only, got %+v", nodes)
+	}
+}
+
+func TestReplicationRoute(t *testing.T) {
+	server := createReplicationServer(t, config.ReplicationConfig{
+		Role:              "primary",
+		Token:             "0123456789abcdef",
+		HeartbeatInterval: time.Second,
+	})
+	if _, ok := server.storage.(*replication.Primary); !ok {
+		t.Fatal("Expected the storage to be replicated")
+	}
+
+	req := httptest.NewRequest("GET", replication.Path, nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	w := httptest.NewRecorder()
+	server.setupRouter().ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401, got %d", w.Code)
+	}
+}
+
+func TestPromoteRouteRequiresToken(t *testing.T) {
+	server := createReplicationServer(t, config.ReplicationConfig{
+		Role:              "standby",
+		PrimaryURL:        "https://127.0.0.1:1",
+		Token:             "0123456789abcdef",
+		HeartbeatInterval: time.Second,
+	})
+	defer server.availabilityMonitor.Stop()
+	router := server.setupRouter()
+
+	promote := func(authorization string) int {
+		req := httptest.NewRequest("POST", "/api/replication/promote", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for _, authorization := range []string{"", "Bearer wrong"} {
+		if code := promote(authorization); code != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for %q, got %d", authorization, code)
+		}
+	}
+	if server.standby.Promoted() {
+		t.Fatal("Expected unauthenticated requests not to promote the standby")
+	}
+	if code := promote("Bearer 0123456789abcdef"); code != http.StatusOK || !server.standby.Promoted() {
+		t.Errorf("Expected the replication token to promote the standby, got %d", code)
+	}
+}
@@ -0,0 +1,267 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/codefionn/go-matter-server/internal/audit"
+	"github.com/codefionn/go-matter-server/internal/clusters"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// maxRequestBodySize limits the JSON body of REST command requests
+const maxRequestBodySize = 1024 * 1024 // 1MB
+
+// maxImportBodySize limits the body of settings imports, which carry all
+// nodes
+const maxImportBodySize = 64 * 1024 * 1024 // 64MB
+
+// httpWriteTimeout limits writing HTTP responses. Command routes extend it
+// by the timeout of their command.
+const httpWriteTimeout = 15 * time.Second
+
+// commandRoute exposes a command over HTTP. Path variables are named after
+// the command arguments they provide.
+type commandRoute struct {
+	method  string
+	path    string
+	command models.APICommand
+}
+
+// commandRoutes are the REST equivalents of the write commands. They go
+// through HandleCommand, so arguments are validated like WebSocket commands.
+var commandRoutes = []commandRoute{
+	{"POST", "/nodes", models.APICommandCommissionWithCode},
+	{"POST", "/nodes/commission_on_network", models.APICommandCommissionOnNetwork},
+	{"DELETE", "/commissioning/queue/{queue_id}", models.APICommandCancelCommissioning},
+	{"DELETE", "/nodes/{node_id}", models.APICommandRemoveNode},
+	{"POST", "/nodes/{node_id}/command", models.APICommandDeviceCommand},
+	{"POST", "/nodes/{node_id}/endpoints/{endpoint_id}/action", models.APICommandDeviceAction},
+	{"PUT", "/nodes/{node_id}/attributes/{attribute_path:[0-9]+/[^/]+/[^/]+}", models.APICommandWriteAttribute},
+	{"POST", "/nodes/{node_id}/interview", models.APICommandInterviewNode},
+	{"PATCH", "/nodes/{node_id}/metadata", models.APICommandSetNodeMetadata},
+	{"DELETE", "/nodes/{node_id}/fabrics/{fabric_index}", models.APICommandRemoveNodeFabric},
+	{"POST", "/groups", models.APICommandCreateGroup},
+	{"DELETE", "/groups/{group_id}", models.APICommandRemoveGroup},
+	{"POST", "/groups/{group_id}/members", models.APICommandAddGroupMember},
+	{"DELETE", "/groups/{group_id}/members/{node_id}/{endpoint_id}", models.APICommandRemoveGroupMember},
+	{"POST", "/groups/{group_id}/command", models.APICommandGroupCommand},
+	{"POST", "/nodes/{node_id}/endpoints/{endpoint_id}/scenes/{scene_id}/store", models.APICommandStoreDeviceScene},
+	{"POST", "/nodes/{node_id}/endpoints/{endpoint_id}/scenes/{scene_id}/recall", models.APICommandRecallDeviceScene},
+	{"DELETE", "/nodes/{node_id}/endpoints/{endpoint_id}/scenes/{scene_id}", models.APICommandRemoveDeviceScene},
+	{"POST", "/scenes", models.APICommandCreateScene},
+	{"DELETE", "/scenes/{scene_id}", models.APICommandRemoveScene},
+	{"POST", "/scenes/{scene_id}/store", models.APICommandStoreScene},
+	{"POST", "/scenes/{scene_id}/recall", models.APICommandRecallScene},
+	{"POST", "/settings/import", models.APICommandImportSettings},
+	{"POST", "/log-level", models.APICommandSetLogLevel},
+	{"POST", "/replication/promote", models.APICommandPromoteStandby},
+}
+
+// bearerToken returns the bearer token of a request's Authorization header
+func bearerToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
+	return ""
+}
+
+// isJSONRequest reports whether a request declares a JSON body
+func isJSONRequest(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+// handleCommandHTTP runs a command with the request's JSON body as arguments.
+// Path variables take precedence over body fields. Browsers send POST
+// requests of other origins without a preflight, so those are refused like
+// WebSocket upgrades, and bodies must be declared as JSON.
+func (s *Server) handleCommandHTTP(command models.APICommand) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.origins.checkOrigin(r) {
+			s.writeError(w, http.StatusForbidden, "origin not allowed")
+			return
+		}
+		limit := int64(maxRequestBodySize)
+		if command == models.APICommandImportSettings {
+			limit = maxImportBodySize
+		}
+
+		args := make(map[string]interface{})
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+		if err != nil {
+			s.writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+			return
+		}
+		if len(body) > 0 {
+			if !isJSONRequest(r) {
+				s.writeError(w, http.StatusUnsupportedMediaType, "request body must be application/json")
+				return
+			}
+			if err := json.Unmarshal(body, &args); err != nil {
+				s.writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON body: %v", err))
+				return
+			}
+		}
+		for name, value := range mux.Vars(r) {
+			args[name] = value
+		}
+
+		// Commands like commissioning run longer than the write timeout of
+		// the server. Recorders don't support deadlines, which is fine.
+		var deadline time.Time
+		if timeout := s.commandTimeout(command); timeout > 0 {
+			deadline = time.Now().Add(timeout + httpWriteTimeout)
+		}
+		_ = http.NewResponseController(w).SetWriteDeadline(deadline)
+
+		ctx := audit.WithClient(r.Context(), audit.Client{RemoteAddress: r.RemoteAddr, BearerToken: bearerToken(r)})
+		result, err := s.HandleCommand(ctx, models.CommandMessage{
+			MessageID: models.GenerateMessageID(),
+			Command:   string(command),
+			Args:      args,
+		})
+		if err != nil {
+			var argErr *models.ArgumentError
+			switch {
+			case errors.As(err, &argErr):
+				s.writeError(w, http.StatusUnprocessableEntity, err.Error())
+			case errors.Is(err, models.ErrUnauthorized):
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				s.writeError(w, http.StatusUnauthorized, err.Error())
+			case errors.Is(err, models.ErrUnknownCommand):
+				s.writeError(w, http.StatusNotImplemented, err.Error())
+			case errors.Is(err, models.ErrStandby), errors.Is(err, models.ErrShuttingDown):
+				s.writeError(w, http.StatusServiceUnavailable, err.Error())
+			case errors.Is(err, models.ErrCommandTimeout):
+				s.writeError(w, http.StatusGatewayTimeout, err.Error())
+			default:
+				s.writeError(w, http.StatusInternalServerError, err.Error())
+			}
+			return
+		}
+
+		s.writeJSON(w, result)
+	}
+}
+
+// handleNodeHTTP returns a single node. It supports conditional requests with
+// an ETag derived from the node's last interview.
+func (s *Server) handleNodeHTTP(w http.ResponseWriter, r *http.Request) {
+	node, ok := s.nodeFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if r.URL.Query().Get("annotate") == "true" {
+		node.AttributeNames = clusters.Default().AnnotateAttributes(node.Attributes)
+	}
+	s.writeJSONWithETag(w, r, node.NodeID, node.LastInterview, node)
+}
+
+// handleNodeAttributesHTTP returns the cached values of a node's attributes
+// matching an endpoint/cluster/attribute path, where each part may be "*",
+// keyed by attribute path
+func (s *Server) handleNodeAttributesHTTP(w http.ResponseWriter, r *http.Request) {
+	node, ok := s.nodeFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	pattern := mux.Vars(r)["attribute_path"]
+	values := make(map[string]interface{})
+	for path, value := range node.Attributes {
+		if matchAttributePath(pattern, path) {
+			values[path] = value
+		}
+	}
+	if len(values) == 0 {
+		s.writeError(w, http.StatusNotFound, fmt.Sprintf("node %d has no attribute %s", node.NodeID, pattern))
+		return
+	}
+
+	s.writeJSONWithETag(w, r, node.NodeID, node.LastInterview, values)
+}
+
+// nodeFromPath returns a copy of the node named by the node_id path variable,
+// writing an error response if there is no such node
+func (s *Server) nodeFromPath(w http.ResponseWriter, r *http.Request) (models.MatterNodeData, bool) {
+	nodeID, err := strconv.Atoi(mux.Vars(r)["node_id"])
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid node ID %q", mux.Vars(r)["node_id"]))
+		return models.MatterNodeData{}, false
+	}
+
+	s.nodesMu.RLock()
+	node, exists := s.nodes[nodeID]
+	s.nodesMu.RUnlock()
+
+	if !exists {
+		s.writeError(w, http.StatusNotFound, fmt.Sprintf("node %d not found", nodeID))
+		return models.MatterNodeData{}, false
+	}
+	return *node, true
+}
+
+// writeJSONWithETag writes data with an ETag made of the node's last
+// interview and a hash of the response, since attributes and availability
+// also change between interviews. Requests whose If-None-Match matches get
+// 304 Not Modified.
+func (s *Server) writeJSONWithETag(w http.ResponseWriter, r *http.Request, nodeID int, lastInterview time.Time, data interface{}) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	hash := fnv.New64a()
+	hash.Write(body)
+	etag := fmt.Sprintf(`"%d-%d-%x"`, nodeID, lastInterview.UnixNano(), hash.Sum64())
+
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(body, '\n'))
+}
+
+// etagMatches reports whether an If-None-Match header lists etag. Weak
+// validators match their strong counterpart.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAttributePath reports whether an attribute path matches a pattern in
+// which each part may be "*"
+func matchAttributePath(pattern, path string) bool {
+	patternParts := strings.Split(pattern, "/")
+	pathParts := strings.Split(path, "/")
+	if len(patternParts) != len(pathParts) {
+		return false
+	}
+	for i, part := range patternParts {
+		if part != "*" && part != pathParts[i] {
+			return false
+		}
+	}
+	return true
+}
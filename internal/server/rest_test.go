@@ -0,0 +1,251 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+func TestRESTCommands(t *testing.T) {
+	server := createTestServer(t)
+	fake := &writeController{}
+	server.controller = fake
+	server.nodes[5] = &models.MatterNodeData{NodeID: 5, Attributes: map[string]interface{}{}}
+	router := server.setupRouter()
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"Device command", "POST", "/api/nodes/5/command", `{"endpoint_id": 1, "cluster_id": "OnOff", "command_name": "On"}`, http.StatusOK},
+		{"Write attribute", "PUT", "/api/nodes/5/attributes/1/6/16387", `{"value": 1}`, http.StatusOK},
+		{"Create group", "POST", "/api/groups", `{"name": "Kitchen"}`, http.StatusOK},
+		{"Invalid argument", "POST", "/api/nodes/5/command", `{"endpoint_id": -1, "cluster_id": "OnOff", "command_name": "On"}`, http.StatusUnprocessableEntity},
+		{"Invalid JSON", "POST", "/api/nodes/5/command", `{"endpoint_id":`, http.StatusBadRequest},
+		{"Unknown node", "POST", "/api/nodes/6/command", `{"endpoint_id": 1, "cluster_id": "OnOff", "command_name": "On"}`, http.StatusInternalServerError},
+		{"Not implemented", "DELETE", "/api/nodes/5", "", http.StatusNotImplemented},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	if len(fake.commands) != 1 || fake.commands[0].NodeID != 5 || fake.commands[0].ClusterID != 6 {
+		t.Errorf("Expected OnOff command to node 5, got %+v", fake.commands)
+	}
+	if len(fake.writes) != 1 || fake.writes[0].Path != "1/6/16387" {
+		t.Errorf("Expected write to 1/6/16387, got %+v", fake.writes)
+	}
+}
+
+func TestRESTCommandCrossSite(t *testing.T) {
+	server := createTestServer(t)
+	fake := &writeController{}
+	server.controller = fake
+	server.nodes[5] = &models.MatterNodeData{NodeID: 5, Attributes: map[string]interface{}{}}
+	server.origins = newOriginPolicy([]string{"https://dashboard.example"})
+	router := server.setupRouter()
+
+	post := func(origin, contentType string) int {
+		req := httptest.NewRequest("POST", "/api/nodes/5/command", strings.NewReader(`{"endpoint_id": 1, "cluster_id": "OnOff", "command_name": "On"}`))
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Simple requests a page of another origin can send without a preflight
+	if code := post("https://evil.example", "text/plain"); code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for another origin, got %d", code)
+	}
+	if code := post("https://evil.example", "application/json"); code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for another origin, got %d", code)
+	}
+	if code := post("", "text/plain"); code != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected status 415 for a text/plain body, got %d", code)
+	}
+	if code := post("", "application/x-www-form-urlencoded"); code != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected status 415 for a form body, got %d", code)
+	}
+	if len(fake.commands) != 0 {
+		t.Fatalf("Expected no command to run, got %+v", fake.commands)
+	}
+
+	for _, origin := range []string{"", "http://example.com", "https://dashboard.example"} {
+		if code := post(origin, "application/json; charset=utf-8"); code != http.StatusOK {
+			t.Errorf("Expected status 200 from origin %q, got %d", origin, code)
+		}
+	}
+}
+
+func TestRESTNode(t *testing.T) {
+	server := createTestServer(t)
+	server.nodes[5] = &models.MatterNodeData{
+		NodeID:        5,
+		LastInterview: time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC),
+		Attributes: map[string]interface{}{
+			"1/6/0":     true,
+			"1/6/16387": float64(1),
+			"1/8/0":     float64(128),
+		},
+	}
+	router := server.setupRouter()
+
+	get := func(path, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/api/nodes/5", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var node models.MatterNodeData
+	if err := json.Unmarshal(w.Body.Bytes(), &node); err != nil || node.NodeID != 5 {
+		t.Errorf("Expected node 5, got %s", w.Body.String())
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("Expected ETag header")
+	}
+
+	if w := get("/api/nodes/5", etag); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("Expected 304 without body, got %d", w.Code)
+	}
+	if w := get("/api/nodes/5", `"other", W/`+etag); w.Code != http.StatusNotModified {
+		t.Errorf("Expected weak ETag in list to match, got %d", w.Code)
+	}
+
+	// Changes between interviews produce a new ETag
+	server.nodes[5].Available = true
+	if w := get("/api/nodes/5", etag); w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Errorf("Expected changed node with new ETag, got %d", w.Code)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantPaths  []string
+	}{
+		{"Attribute", "/api/nodes/5/attributes/1/6/0", http.StatusOK, []string{"1/6/0"}},
+		{"Wildcard", "/api/nodes/5/attributes/1/6/*", http.StatusOK, []string{"1/6/0", "1/6/16387"}},
+		{"Unknown attribute", "/api/nodes/5/attributes/2/6/0", http.StatusNotFound, nil},
+		{"Unknown node", "/api/nodes/6", http.StatusNotFound, nil},
+		{"Unknown node attribute", "/api/nodes/6/attributes/1/6/0", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(tt.path, "")
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if tt.wantPaths == nil {
+				return
+			}
+
+			var values map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &values); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+			if len(values) != len(tt.wantPaths) {
+				t.Errorf("Expected %d attributes, got %v", len(tt.wantPaths), values)
+			}
+			for _, path := range tt.wantPaths {
+				if _, ok := values[path]; !ok {
+					t.Errorf("Expected attribute %s, got %v", path, values)
+				}
+			}
+		})
+	}
+}
+
+func TestRESTNodesQuery(t *testing.T) {
+	server := createTestServer(t)
+	server.nodes[1] = &models.MatterNodeData{NodeID: 1, Available: true, IsBridge: true}
+	server.nodes[2] = &models.MatterNodeData{NodeID: 2, Available: true}
+	server.nodes[3] = &models.MatterNodeData{NodeID: 3}
+	router := server.setupRouter()
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantBody   string
+		wantTotal  string
+	}{
+		{"Filtered fields", "?available=true&is_bridge=false&fields=node_id", http.StatusOK, `[{"node_id":2}]`, "1"},
+		{"Paged", "?limit=1&offset=2&fields=node_id,available", http.StatusOK, `[{"available":false,"node_id":3}]`, "3"},
+		{"Invalid limit", "?limit=0", http.StatusUnprocessableEntity, "", ""},
+		{"Invalid filter", "?available=maybe", http.StatusUnprocessableEntity, "", ""},
+		{"Unknown field", "?fields=password", http.StatusUnprocessableEntity, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/nodes"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if tt.wantBody != "" && strings.TrimSpace(w.Body.String()) != tt.wantBody {
+				t.Errorf("Expected body %s, got %s", tt.wantBody, w.Body.String())
+			}
+			if total := w.Header().Get("X-Total-Count"); total != tt.wantTotal {
+				t.Errorf("Expected X-Total-Count %q, got %q", tt.wantTotal, total)
+			}
+		})
+	}
+}
+
+func TestRESTCommandWriteDeadline(t *testing.T) {
+	server := createTestServer(t)
+	fake := &queueController{release: make(chan struct{})}
+	fake.device = trustedTestDevice(t, server)
+	server.controller = fake
+
+	ts := httptest.NewUnstartedServer(server.setupRouter())
+	ts.Config.WriteTimeout = 100 * time.Millisecond
+	ts.Start()
+	defer ts.Close()
+
+	// Commissioning outlasts the write timeout of the server
+	time.AfterFunc(300*time.Millisecond, func() { close(fake.release) })
+	resp, err := ts.Client().Post(ts.URL+"/api/nodes", "application/json", strings.NewReader(`{"code": "MT:Y.K9042C00KA0648G00"}`))
+	if err != nil {
+		t.Fatalf("Expected the response after the write timeout, got %v", err)
+	}
+	defer resp.Body.Close()
+	var node models.MatterNodeData
+	if err := json.NewDecoder(resp.Body).Decode(&node); err != nil || resp.StatusCode != http.StatusOK || node.NodeID != 1 {
+		t.Errorf("Expected node 1, got %d: %+v, %v", resp.StatusCode, node, err)
+	}
+}
@@ -0,0 +1,216 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/codefionn/go-matter-server/internal/clusters"
+	"github.com/codefionn/go-matter-server/internal/controller"
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/scenes"
+)
+
+// Scenes Management command IDs
+const (
+	scenesCommandRemoveScene = 0x02
+	scenesCommandStoreScene  = 0x04
+	scenesCommandRecallScene = 0x05
+)
+
+// deviceSceneCommands are the Scenes Management commands of the device
+// scene commands
+var deviceSceneCommands = map[models.APICommand]uint32{
+	models.APICommandStoreDeviceScene:  scenesCommandStoreScene,
+	models.APICommandRecallDeviceScene: scenesCommandRecallScene,
+	models.APICommandRemoveDeviceScene: scenesCommandRemoveScene,
+}
+
+// handleDeviceScene stores, recalls or removes a scene on a node endpoint
+func (s *Server) handleDeviceScene(ctx context.Context, command models.APICommand, args commandArgs) (interface{}, error) {
+	member := scenes.Member{
+		NodeID:     args.nodeID(),
+		EndpointID: args.uint16("endpoint_id"),
+		GroupID:    args.uint16("group_id"),
+		SceneID:    uint8(args.integer("scene_id")),
+	}
+
+	s.nodesMu.RLock()
+	_, exists := s.nodes[member.NodeID]
+	s.nodesMu.RUnlock()
+
+	if !exists {
+		return nil, &models.NodeNotFoundError{NodeID: member.NodeID}
+	}
+
+	return nil, s.sendSceneCommand(ctx, member, deviceSceneCommands[command], args.transitionTime())
+}
+
+func (s *Server) handleCreateScene(args commandArgs) (interface{}, error) {
+	members, err := s.parseSceneMembers(args["members"])
+	if err != nil {
+		return nil, err
+	}
+
+	name := args.str("name")
+	scene, err := s.scenes.Create(uint8(args.integer("scene_id")), name, members)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Scene created", logger.Int("scene_id", int(scene.SceneID)), logger.String("name", name))
+	return scene, nil
+}
+
+func (s *Server) handleGetScenes() (interface{}, error) {
+	return s.scenes.List(), nil
+}
+
+// handleStoreScene stores the current state of every member of a virtual
+// scene in its scene
+func (s *Server) handleStoreScene(ctx context.Context, args commandArgs) (interface{}, error) {
+	return s.sceneFanOut(ctx, uint8(args.integer("scene_id")), scenesCommandStoreScene, nil)
+}
+
+// handleRecallScene recalls the scenes of all members of a virtual scene
+func (s *Server) handleRecallScene(ctx context.Context, args commandArgs) (interface{}, error) {
+	return s.sceneFanOut(ctx, uint8(args.integer("scene_id")), scenesCommandRecallScene, args.transitionTime())
+}
+
+func (s *Server) handleRemoveScene(ctx context.Context, args commandArgs) (interface{}, error) {
+	sceneID := uint8(args.integer("scene_id"))
+	scene, err := s.scenes.Get(sceneID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unreachable members keep their scene, which is harmless as nothing
+	// recalls it anymore
+	for _, member := range scene.Members {
+		if err := s.sendSceneCommand(ctx, member, scenesCommandRemoveScene, nil); err != nil {
+			s.logger.Warn("Failed to remove scene from node",
+				logger.Int("scene_id", int(sceneID)),
+				logger.Int("node_id", member.NodeID),
+				logger.ErrorField(err),
+			)
+		}
+	}
+
+	if err := s.scenes.Remove(sceneID); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Scene removed", logger.Int("scene_id", int(sceneID)))
+	return nil, nil
+}
+
+// sceneFanOut sends a scene command to all members of a virtual scene at
+// once and reports the outcome per member. It fails only if no member
+// succeeded.
+func (s *Server) sceneFanOut(ctx context.Context, sceneID uint8, commandID uint32, transitionTime interface{}) (interface{}, error) {
+	scene, err := s.scenes.Get(sceneID)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]models.SceneMemberResult, len(scene.Members))
+	errs := make([]error, len(scene.Members))
+	var wg sync.WaitGroup
+	for i, member := range scene.Members {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = s.sendSceneCommand(ctx, member, commandID, transitionTime)
+		}()
+	}
+	wg.Wait()
+
+	failed := 0
+	for i, member := range scene.Members {
+		results[i] = models.SceneMemberResult{NodeID: member.NodeID, EndpointID: int(member.EndpointID)}
+		if errs[i] != nil {
+			results[i].Error = errs[i].Error()
+			failed++
+		}
+	}
+	if failed > 0 && failed == len(results) {
+		return nil, fmt.Errorf("scene %d failed on all members: %w", sceneID, errs[0])
+	}
+	return results, nil
+}
+
+// sendSceneCommand sends a Scenes Management command for the scene of a
+// member. transitionTime is only sent with RecallScene, nil recalls with the
+// transition time stored in the scene.
+func (s *Server) sendSceneCommand(ctx context.Context, member scenes.Member, commandID uint32, transitionTime interface{}) error {
+	payload := map[string]interface{}{
+		"groupID": member.GroupID,
+		"sceneID": member.SceneID,
+	}
+	if commandID == scenesCommandRecallScene {
+		payload["transitionTime"] = transitionTime
+	}
+
+	response, err := s.controller.SendCommand(ctx, controller.CommandRequest{
+		NodeID:     member.NodeID,
+		EndpointID: member.EndpointID,
+		ClusterID:  clusters.ScenesClusterID,
+		CommandID:  commandID,
+		Payload:    payload,
+	})
+	if err != nil {
+		return err
+	}
+	// RecallScene has no response, the others report a status
+	if fields, ok := response.(map[string]interface{}); ok {
+		if status, ok := toInt(structField(fields, "0", "status")); ok && status != 0 {
+			return fmt.Errorf("node %d endpoint %d returned status 0x%02X", member.NodeID, member.EndpointID, status)
+		}
+	}
+	return nil
+}
+
+// parseSceneMembers decodes the members argument of create_scene, every
+// member needs a known node_id and an endpoint_id
+func (s *Server) parseSceneMembers(value interface{}) ([]scenes.Member, error) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var entries []struct {
+		NodeID     *int   `json:"node_id"`
+		EndpointID *int   `json:"endpoint_id"`
+		GroupID    uint16 `json:"group_id"`
+		SceneID    uint8  `json:"scene_id"`
+	}
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil, &models.ArgumentError{Field: "members", Reason: err.Error()}
+	}
+
+	members := make([]scenes.Member, 0, len(entries))
+	for _, entry := range entries {
+		if entry.NodeID == nil || entry.EndpointID == nil || *entry.EndpointID < 0 || *entry.EndpointID > 0xFFFF {
+			return nil, &models.ArgumentError{Field: "members", Reason: "every member needs a node_id and an endpoint_id"}
+		}
+		if entry.GroupID > 0xFEFF || entry.SceneID > 0xFE {
+			return nil, &models.ArgumentError{Field: "members", Reason: "group_id must be at most 0xFEFF and scene_id at most 0xFE"}
+		}
+
+		s.nodesMu.RLock()
+		_, exists := s.nodes[*entry.NodeID]
+		s.nodesMu.RUnlock()
+		if !exists {
+			return nil, &models.NodeNotFoundError{NodeID: *entry.NodeID}
+		}
+
+		members = append(members, scenes.Member{
+			NodeID:     *entry.NodeID,
+			EndpointID: uint16(*entry.EndpointID),
+			GroupID:    entry.GroupID,
+			SceneID:    entry.SceneID,
+		})
+	}
+	return members, nil
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/codefionn/go-matter-server/internal/clusters"
+	"github.com/codefionn/go-matter-server/internal/controller"
+	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/scenes"
+)
+
+// sceneController fails the scene commands sent to node 6. Virtual scenes
+// send their commands concurrently.
+type sceneController struct {
+	fakeController
+	mu sync.Mutex
+}
+
+func (c *sceneController) SendCommand(ctx context.Context, req controller.CommandRequest) (interface{}, error) {
+	c.mu.Lock()
+	c.fakeController.SendCommand(ctx, req)
+	c.mu.Unlock()
+	if req.NodeID == 6 {
+		return nil, errors.New("node unreachable")
+	}
+	return map[string]interface{}{"status": float64(0)}, nil
+}
+
+func TestDeviceScene(t *testing.T) {
+	server := createTestServer(t)
+	fake := &fakeController{}
+	server.controller = fake
+	server.nodes[5] = &models.MatterNodeData{NodeID: 5, Attributes: map[string]interface{}{}}
+
+	runCommand(t, server, models.APICommandRecallDeviceScene, map[string]interface{}{
+		"node_id": float64(5), "endpoint_id": float64(1), "scene_id": float64(3), "transition_time_ms": float64(500),
+	})
+	if len(fake.commands) != 1 {
+		t.Fatalf("Expected RecallScene, got %+v", fake.commands)
+	}
+	cmd := fake.commands[0]
+	if cmd.ClusterID != clusters.ScenesClusterID || cmd.CommandID != scenesCommandRecallScene || cmd.EndpointID != 1 ||
+		cmd.Payload["sceneID"] != uint8(3) || cmd.Payload["groupID"] != uint16(0) || cmd.Payload["transitionTime"] != uint32(500) {
+		t.Errorf("Expected RecallScene of scene 3, got %+v", cmd)
+	}
+
+	_, err := server.HandleCommand(context.Background(), models.CommandMessage{
+		Command: string(models.APICommandStoreDeviceScene),
+		Args:    map[string]interface{}{"node_id": float64(7), "endpoint_id": float64(1), "scene_id": float64(3)},
+	})
+	var notFound *models.NodeNotFoundError
+	if !errors.As(err, &notFound) {
+		t.Errorf("Expected node not found, got %v", err)
+	}
+}
+
+func TestVirtualScene(t *testing.T) {
+	server := createTestServer(t)
+	fake := &sceneController{}
+	server.controller = fake
+	server.nodes[5] = &models.MatterNodeData{NodeID: 5, Attributes: map[string]interface{}{}}
+	server.nodes[6] = &models.MatterNodeData{NodeID: 6, Attributes: map[string]interface{}{}}
+
+	result := runCommand(t, server, models.APICommandCreateScene, map[string]interface{}{
+		"name": "Evening",
+		"members": []interface{}{
+			map[string]interface{}{"node_id": float64(5), "endpoint_id": float64(1)},
+			map[string]interface{}{"node_id": float64(6), "endpoint_id": float64(2), "scene_id": float64(9)},
+		},
+	})
+	scene, ok := result.(*scenes.Scene)
+	if !ok || scene.SceneID != 1 || len(scene.Members) != 2 {
+		t.Fatalf("Expected scene 1 with two members, got %+v", result)
+	}
+
+	// Recalling reports the members that failed
+	result = runCommand(t, server, models.APICommandRecallScene, map[string]interface{}{"scene_id": float64(1)})
+	expected := []models.SceneMemberResult{
+		{NodeID: 5, EndpointID: 1},
+		{NodeID: 6, EndpointID: 2, Error: "node unreachable"},
+	}
+	results := result.([]models.SceneMemberResult)
+	if len(results) != 2 || results[0] != expected[0] || results[1] != expected[1] {
+		t.Errorf("Expected %+v, got %+v", expected, results)
+	}
+	if len(fake.commands) != 2 {
+		t.Fatalf("Expected RecallScene on both members, got %+v", fake.commands)
+	}
+	for _, cmd := range fake.commands {
+		want := uint8(1)
+		if cmd.NodeID == 6 {
+			want = 9
+		}
+		if cmd.CommandID != scenesCommandRecallScene || cmd.Payload["sceneID"] != want || cmd.Payload["transitionTime"] != nil {
+			t.Errorf("Expected RecallScene of scene %d, got %+v", want, cmd)
+		}
+	}
+
+	if _, err := server.HandleCommand(context.Background(), models.CommandMessage{
+		Command: string(models.APICommandCreateScene),
+		Args: map[string]interface{}{
+			"name":    "Broken",
+			"members": []interface{}{map[string]interface{}{"node_id": float64(5)}},
+		},
+	}); err == nil {
+		t.Error("Expected an error for a member without endpoint")
+	}
+
+	// Removing the scene removes it from the reachable members
+	fake.commands = nil
+	runCommand(t, server, models.APICommandRemoveScene, map[string]interface{}{"scene_id": float64(1)})
+	if len(fake.commands) != 2 || fake.commands[0].CommandID != scenesCommandRemoveScene {
+		t.Errorf("Expected RemoveScene on the members, got %+v", fake.commands)
+	}
+	if list := runCommand(t, server, models.APICommandGetScenes, nil).([]*scenes.Scene); len(list) != 0 {
+		t.Errorf("Expected no scenes, got %+v", list)
+	}
+}
@@ -1,28 +1,58 @@
 package server
 
 import (
+	"bytes"
 	"context"
+	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log/slog"
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/mux"
 
+	"github.com/codefionn/go-matter-server/internal/attestation"
+	"github.com/codefionn/go-matter-server/internal/audit"
+	"github.com/codefionn/go-matter-server/internal/availability"
 	"github.com/codefionn/go-matter-server/internal/bluetooth"
+	"github.com/codefionn/go-matter-server/internal/clock"
+	"github.com/codefionn/go-matter-server/internal/clusters"
 	"github.com/codefionn/go-matter-server/internal/config"
+	"github.com/codefionn/go-matter-server/internal/controller"
+	"github.com/codefionn/go-matter-server/internal/credentials"
+	"github.com/codefionn/go-matter-server/internal/dashboard"
+	"github.com/codefionn/go-matter-server/internal/faults"
+	"github.com/codefionn/go-matter-server/internal/groups"
+	"github.com/codefionn/go-matter-server/internal/grpcapi"
+	"github.com/codefionn/go-matter-server/internal/icd"
 	"github.com/codefionn/go-matter-server/internal/logger"
 	"github.com/codefionn/go-matter-server/internal/mdns"
 	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/mqtt"
+	"github.com/codefionn/go-matter-server/internal/ota"
+	"github.com/codefionn/go-matter-server/internal/ping"
+	"github.com/codefionn/go-matter-server/internal/progress"
+	"github.com/codefionn/go-matter-server/internal/replication"
+	"github.com/codefionn/go-matter-server/internal/scenes"
 	"github.com/codefionn/go-matter-server/internal/storage"
+	"github.com/codefionn/go-matter-server/internal/supervisor"
+	"github.com/codefionn/go-matter-server/internal/systemd"
+	"github.com/codefionn/go-matter-server/internal/telemetry"
 	"github.com/codefionn/go-matter-server/internal/websocket"
 )
 
+// pingTimeout is the timeout for a single ping of a node address
+const pingTimeout = 3 * time.Second
+
 // Server represents the main Matter server
 type Server struct {
 	config    *config.Config
@@ -31,48 +61,203 @@ type Server struct {
 	wsHandler *websocket.Handler
 
 	// Event system
-	eventCallbacks []eventSubscription
+	eventCallbacks []*eventSubscription
 	eventMu        sync.RWMutex
 
+	// Set on shutdown, new commands are rejected while the ones in flight
+	// are waited for
+	shuttingDown     atomic.Bool
+	commandsInFlight atomic.Int64
+
+	// Panics recovered from HTTP handlers and event callbacks
+	panics panicCounters
+
 	// HTTP server
 	httpServer *http.Server
 
+	// pprof and expvar server on the debug port, nil when disabled
+	debugServer *http.Server
+
+	// gRPC API on its own port, nil when disabled
+	grpcServer *grpcapi.Server
+
 	// mDNS server
 	mdnsServer *mdns.Server
 	mdnsZone   *mdns.MatterZone
+	srpClient  *mdns.SRPClient
+
+	// Bridge to an MQTT broker, nil when disabled
+	mqttBridge *mqtt.Bridge
+
+	// Exporter of attribute values to a line protocol endpoint, nil when
+	// disabled
+	telemetry *telemetry.Exporter
+
+	// Replication stream to standbys when this server is the primary, nil
+	// otherwise
+	replicationPrimary *replication.Primary
+	// Mirror of the primary when this server is a standby, nil otherwise
+	standby *replication.Standby
+
+	// Audit trail of state-changing commands, nil if disabled
+	auditLog *audit.Log
+
+	// Serializes changes of runtime settings and the temporary log level
+	serverSettingsMu sync.Mutex
+	// Reverts a temporary log level set with set_log_level, nil if none
+	logLevelTimer *time.Timer
+	logLevelUntil time.Time
+
+	// Primary network interface and how it was chosen
+	network models.NetworkInfo
 
 	// Bluetooth manager (internal only)
 	bluetoothManager *bluetooth.Manager
 
-	// Matter-specific components
+	// System clock sanity checker
+	clockChecker *clock.Checker
+
+	// Node availability monitor
+	availabilityMonitor *availability.Monitor
+
+	// Fabric certificate authority (RCAC/ICAC, NOC issuance)
+	credentials *credentials.Authority
+	// Fabrics the server operates, the default fabric of credentials first
+	fabrics []*operatedFabric
+
+	// PAA trust store and device attestation verifier
+	paaStore    *attestation.Store
+	attestation *attestation.Verifier
+
+	// Matter controller used for device interactions
+	controller controller.Controller
+
+	// Faults injected into the storage and the controller, nil unless
+	// server.fault_injection is enabled
+	faults *faults.Injector
+
+	// Matter groups and group keys
+	groups *groups.Manager
+	// Virtual scenes spanning several nodes
+	scenes *scenes.Manager
+	// Check-In registrations with intermittently connected devices and the
+	// interactions queued for sleeping ones
+	icd       *icd.Registry
+	icdQueues *icdQueues
+
+	// Attribute and event subscriptions of the nodes
+	subscriptions nodeSubscriptions
+
+	// Automatic re-interviews of outdated and updated nodes
+	reinterviews reinterviewScheduler
+
+	// Software update lookups and the updates found for the nodes
+	otaChecker *ota.Checker
+	firmware   firmwareChecks
+
+	// Matter-specific components. Stored nodes are immutable: updates
+	// replace them with a changed copy, so readers share them without
+	// copying.
 	nodes   map[int]*models.MatterNodeData
 	nodesMu sync.RWMutex
+	// Commissioning requests waiting for or holding a commissioning slot,
+	// in order. The mutex also guards picking the next free node ID.
+	commissionMu       sync.Mutex
+	commissioningQueue []*commissioningEntry
 
 	// Server info
 	serverInfo models.ServerInfoMessage
-}
 
-// eventSubscription tracks a callback with an ID for safe unsubscribe
-type eventSubscription struct {
-	id string
-	cb models.EventCallback
+	// Origins allowed for CORS and WebSocket connections
+	origins *originPolicy
+
+	// Subsystem readiness reported by /health/ready
+	health *healthTracker
+
+	// Restarts the long-running subsystem loops when they fail or panic
+	supervisor *supervisor.Supervisor
 }
 
 // New creates a new Matter server instance
 func New(cfg *config.Config, log *logger.Logger) (*Server, error) {
+	// Storage encryption
+	currentKey, previousKeys, err := cfg.Storage.EncryptionKeys()
+	if err != nil {
+		return nil, err
+	}
+	cipher, err := storage.NewCipher(currentKey, previousKeys...)
+	if err != nil {
+		return nil, err
+	}
+
 	// Initialize storage
 	jsonStorage := storage.NewJSONStorage(cfg.Storage.Path, log)
+	jsonStorage.SetFlushInterval(cfg.Storage.FlushInterval)
+	jsonStorage.SetEventHistorySize(cfg.Storage.EventHistorySize)
+	jsonStorage.SetAttributeHistory(cfg.Storage.AttributeHistoryRetention, cfg.Storage.AttributeHistoryInterval)
+	jsonStorage.SetCipher(cipher)
+	corruptionPolicy, err := storage.ParseCorruptionPolicy(cfg.Storage.CorruptionPolicy)
+	if err != nil {
+		return nil, err
+	}
+	jsonStorage.SetCorruptionPolicy(corruptionPolicy)
+
+	// Load or create the certificate authorities of the fabrics
+	fabrics, err := loadFabrics(cfg, cipher, log.WithName("credentials"))
+	if err != nil {
+		return nil, err
+	}
+	authority := fabrics[0].credentials
+
+	// Load Matter groups and their key sets
+	groupManager := groups.NewManager(cfg.Storage.Path, log.WithName("groups"))
+	groupManager.SetCipher(cipher)
+	if err := groupManager.Load(); err != nil {
+		return nil, fmt.Errorf("failed to load groups: %w", err)
+	}
+
+	// Load the virtual scenes
+	sceneManager := scenes.NewManager(cfg.Storage.Path, log.WithName("scenes"))
+	sceneManager.SetCipher(cipher)
+	if err := sceneManager.Load(); err != nil {
+		return nil, fmt.Errorf("failed to load scenes: %w", err)
+	}
+
+	// Register the manufacturer-specific clusters described by the user
+	if dir := cfg.Matter.ClusterDescriptorDir; dir != "" {
+		loaded, err := clusters.Default().LoadDescriptors(dir)
+		if err != nil {
+			return nil, err
+		}
+		log.Info("Loaded cluster descriptors", logger.String("dir", dir), logger.Int("count", len(loaded)))
+	}
+
+	// Load the ICD Check-In registrations
+	icdRegistry := icd.NewRegistry(cfg.Storage.Path, log.WithName("icd"))
+	icdRegistry.SetCipher(cipher)
+	if err := icdRegistry.Load(); err != nil {
+		return nil, fmt.Errorf("failed to load ICD registrations: %w", err)
+	}
 
 	s := &Server{
-		config:  cfg,
-		logger:  log,
-		storage: jsonStorage,
-		nodes:   make(map[int]*models.MatterNodeData),
+		config:      cfg,
+		logger:      log,
+		storage:     jsonStorage,
+		credentials: authority,
+		fabrics:     fabrics,
+		controller:  controller.Unavailable{},
+		groups:      groupManager,
+		scenes:      sceneManager,
+		icd:         icdRegistry,
+		icdQueues:   newICDQueues(),
+		origins:     newOriginPolicy(cfg.Server.AllowedOrigins),
+		health:      newHealthTracker(subsystemStorage, subsystemMDNS, subsystemMatter),
+		nodes:       make(map[int]*models.MatterNodeData),
 		serverInfo: models.ServerInfoMessage{
 			FabricID:                  cfg.Matter.FabricID,
-			CompressedFabricID:        int64(cfg.Matter.FabricID), // Simplified for demo
-			SchemaVersion:             11,                         // Current schema version
-			MinSupportedSchemaVersion: 1,
+			CompressedFabricID:        authority.CompressedFabricID(),
+			SchemaVersion:             models.SchemaVersion,
+			MinSupportedSchemaVersion: models.MinSupportedSchemaVersion,
 			SDKVersion:                "go-matter-server-1.0.0",
 			WiFiCredentialsSet:        false,
 			ThreadCredentialsSet:      false,
@@ -80,109 +265,359 @@ func New(cfg *config.Config, log *logger.Logger) (*Server, error) {
 		},
 	}
 
+	s.supervisor = supervisor.New(supervisor.Config{
+		Restarted: func(restart models.SubsystemRestart) {
+			s.EmitEvent(models.EventTypeSubsystemRestarted, restart)
+		},
+		Logger: log.WithName("supervisor"),
+	})
+
 	// Initialize WebSocket handler
 	s.wsHandler = websocket.NewHandler(s, log)
+	s.wsHandler.SetCheckOrigin(s.origins.checkOrigin)
+	overflowPolicy, err := websocket.ParseOverflowPolicy(cfg.Server.WebSocketOverflowPolicy)
+	if err != nil {
+		return nil, err
+	}
+	s.wsHandler.SetQueueOptions(cfg.Server.WebSocketQueueSize, overflowPolicy)
+	dialect, err := websocket.ParseDialect(cfg.Server.WebSocketDialect)
+	if err != nil {
+		return nil, err
+	}
+	s.wsHandler.SetDialect(dialect)
+	s.wsHandler.SetLimits(websocket.Limits{
+		CommandsPerSecond: cfg.Server.WebSocketCommandsPerSecond,
+		Burst:             cfg.Server.WebSocketCommandBurst,
+		MaxInFlight:       cfg.Server.WebSocketMaxInFlight,
+	})
+	s.wsHandler.SetCompression(websocket.Compression{
+		Enabled:               cfg.Server.WebSocketCompression,
+		Level:                 cfg.Server.WebSocketCompressionLevel,
+		Threshold:             cfg.Server.WebSocketCompressionThreshold,
+		ServerContextTakeover: cfg.Server.WebSocketServerContextTakeover,
+		ClientContextTakeover: cfg.Server.WebSocketClientContextTakeover,
+	})
 
 	// Initialize Bluetooth manager
-	bluetoothLogger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
-	// Enable Bluetooth only when an adapter ID is provided (>= 0), mirroring python-matter-server.
-	btEnabled := cfg.Bluetooth.AdapterID >= 0
+	// The manager logs with log/slog, bridged to the server's logger
+	bluetoothLogger := log.WithName("bluetooth").Slog()
+	// Enable Bluetooth only when adapters are configured, mirroring python-matter-server.
+	// Clients learn about adapters being plugged in and out or powered off from
+	// the status event and server info.
+	adapters := cfg.Bluetooth.AdapterNames()
+	bluetoothBackend, err := bluetooth.ParseBackend(cfg.Bluetooth.Backend, cfg.Bluetooth.MockScript)
+	if err != nil {
+		return nil, err
+	}
 	bluetoothConfig := bluetooth.Config{
-		AdapterID:     fmt.Sprintf("hci%d", cfg.Bluetooth.AdapterID),
-		Enabled:       btEnabled,
+		Adapters:      adapters,
+		Enabled:       len(adapters) > 0,
+		Backend:       bluetoothBackend,
 		EventCallback: s.EmitEvent,
-		Logger:        bluetoothLogger,
-	}
-
-	if cfg.Bluetooth.AdapterID < 0 {
-		bluetoothConfig.AdapterID = ""
+		StatusChanged: func(status models.BluetoothStatus) {
+			s.EmitEvent(models.EventTypeBluetoothStatusChanged, status)
+			s.EmitEvent(models.EventTypeServerInfoUpdated, s.GetServerInfo())
+		},
+		Logger:    bluetoothLogger,
+		Supervise: s.supervisor.Run,
 	}
 
-	var err error
 	s.bluetoothManager, err = bluetooth.NewManager(bluetoothConfig)
 	if err != nil {
 		log.Warn("Failed to initialize Bluetooth manager", logger.ErrorField(err))
 	}
 
-	// Reflect actual Bluetooth availability in server info
-	if s.bluetoothManager != nil && s.bluetoothManager.IsAvailable() {
-		s.serverInfo.BluetoothEnabled = true
-	} else {
-		s.serverInfo.BluetoothEnabled = false
+	// Initialize PAA trust store and device attestation
+	attestationLogger := log.WithName("attestation")
+	s.paaStore = attestation.NewStore(cfg.Matter.PAARoot, attestationLogger)
+	var cdSigners []*x509.Certificate
+	if cfg.Matter.PAARoot != "" {
+		cdSigners, err = attestation.LoadCertificates(filepath.Join(cfg.Matter.PAARoot, "cd_signers"))
+		if err != nil {
+			log.Warn("Failed to load certification declaration signers", logger.ErrorField(err))
+		}
 	}
+	s.attestation = attestation.NewVerifier(s.paaStore, cdSigners, cfg.Matter.AllowUntrustedDevices, attestationLogger)
 
-	// Initialize mDNS if enabled
-	if cfg.MDNS.Enabled {
+	// Look up software updates of the nodes in the same DCLs
+	otaSources := []ota.Source{{URL: attestation.MainNetDCLURL, Origin: models.UpdateSourceMainNetDCL}}
+	if cfg.Matter.EnableTestNetDCL {
+		otaSources = append(otaSources, ota.Source{URL: attestation.TestNetDCLURL, Origin: models.UpdateSourceTestNetDCL})
+	}
+	s.otaChecker = ota.NewChecker(&http.Client{Timeout: 30 * time.Second}, otaSources)
+
+	// Initialize clock checker
+	s.clockChecker = clock.NewChecker(clock.Config{
+		NTPServer:     cfg.Clock.NTPServer,
+		Interval:      cfg.Clock.CheckInterval,
+		MaxSkew:       cfg.Clock.MaxSkew,
+		Logger:        log.WithName("clock"),
+		EventCallback: s.EmitEvent,
+	})
+
+	// Initialize node availability monitor
+	s.availabilityMonitor = availability.NewMonitor(availability.Config{
+		MainsInterval:   cfg.Availability.MainsInterval,
+		BatteryInterval: cfg.Availability.BatteryInterval,
+		Timeout:         cfg.Availability.Timeout,
+		ListNodes:       s.nodeSnapshot,
+		SetAvailable:    s.setNodeAvailable,
+		Probe:           s.probeNode,
+		Logger:          log.WithName("availability"),
+	})
+
+	// Link-local addresses and mDNS need the interface facing the devices
+	s.network = selectNetwork(cfg.Network.PrimaryInterface, log.WithName("network"))
+
+	// The services are advertised via mDNS and registered with the SRP
+	// server of a Thread border router
+	var interfaces []*net.Interface
+	if cfg.MDNS.Enabled || cfg.SRP.Server != "" {
 		s.mdnsZone = mdns.NewMatterZone(cfg.MDNS.Hostname, log)
 
-		// Try to determine primary interface
-		var iface *net.Interface
-		if cfg.Network.PrimaryInterface != "" {
-			if i, err := net.InterfaceByName(cfg.Network.PrimaryInterface); err == nil {
-				iface = i
-			} else {
-				log.Warn("Primary interface not found, using all interfaces",
-					logger.String("interface", cfg.Network.PrimaryInterface),
+		// Advertise the controller as operational node on our fabric and as
+		// commissioner, and the WebSocket API for clients on the network
+		for _, fabric := range fabrics {
+			for _, service := range s.fabricServices(fabric, fabric.credentials.CompressedFabricID()) {
+				s.mdnsZone.AddService(service)
+			}
+		}
+		deviceName := strings.TrimSuffix(s.mdnsZone.GetHostname(), ".local")
+		if commissioner, err := mdns.CommissionerService(uint16(cfg.Matter.VendorID), 0, deviceName, mdns.MatterPort); err != nil {
+			log.Warn("Failed to create commissioner service", logger.ErrorField(err))
+		} else {
+			s.mdnsZone.AddService(commissioner)
+		}
+
+		// Run on the configured interfaces, or on the primary interface
+		names := cfg.MDNS.Interfaces
+		if len(names) == 0 && s.network.PrimaryInterface != "" {
+			names = []string{s.network.PrimaryInterface}
+		}
+		for _, name := range names {
+			iface, err := net.InterfaceByName(name)
+			if err != nil {
+				log.Warn("mDNS interface not found",
+					logger.String("interface", name),
 					logger.ErrorField(err),
 				)
+				continue
 			}
+			interfaces = append(interfaces, iface)
+		}
+		if len(names) > 0 && len(interfaces) == 0 {
+			log.Warn("No mDNS interface found, using all interfaces")
+		}
+		s.mdnsZone.SetInterfaces(interfaces)
+	}
+
+	// Initialize mDNS if enabled
+	if !cfg.MDNS.Enabled {
+		s.health.disable(subsystemMDNS)
+	} else {
+		family, err := mdns.ParseAddressFamily(cfg.MDNS.AddressFamily)
+		if err != nil {
+			return nil, err
 		}
 
+		// Browsing keeps the addresses of the nodes on the network cached
 		mdnsConfig := &mdns.Config{
-			Interface: iface,
-			Logger:    log,
-			Zone:      s.mdnsZone,
+			Interfaces: interfaces,
+			Family:     family,
+			Logger:     log,
+			Zone:       s.mdnsZone,
+			Browse:     []string{mdns.OperationalServiceType},
+			Supervise:  s.supervisor.Run,
 		}
 
-		var err error
 		s.mdnsServer, err = mdns.NewServer(mdnsConfig)
 		if err != nil {
 			log.Warn("Failed to create mDNS server", logger.ErrorField(err))
+			s.health.setError(subsystemMDNS, err)
 		} else {
-			log.Info("mDNS hostname advertisement enabled",
+			log.Info("mDNS advertisement enabled",
 				logger.String("hostname", s.mdnsZone.GetHostname()),
+				logger.Int("services", len(s.mdnsZone.Services())),
 			)
 		}
 	}
 
+	if cfg.SRP.Server != "" {
+		key, err := loadSRPKey(cfg.Storage.Path, cipher)
+		if err != nil {
+			return nil, err
+		}
+		s.srpClient, err = mdns.NewSRPClient(mdns.SRPConfig{
+			Server:   cfg.SRP.Server,
+			Zone:     s.mdnsZone,
+			Key:      key,
+			Lease:    cfg.SRP.Lease,
+			KeyLease: cfg.SRP.KeyLease,
+			Logger:   log.WithName("srp"),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.MQTT.Broker != "" {
+		s.mqttBridge, err = mqtt.NewBridge(s, mqtt.Config{
+			Broker:          cfg.MQTT.Broker,
+			ClientID:        cfg.MQTT.ClientID,
+			Username:        cfg.MQTT.Username,
+			Password:        cfg.MQTT.Password,
+			KeepAlive:       cfg.MQTT.KeepAlive,
+			TopicPrefix:     cfg.MQTT.TopicPrefix,
+			Discovery:       cfg.MQTT.Discovery,
+			DiscoveryPrefix: cfg.MQTT.DiscoveryPrefix,
+			Logger:          log.WithName("mqtt"),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Telemetry.URL != "" {
+		metrics := make([]telemetry.Metric, 0, len(cfg.Telemetry.Metrics))
+		for _, m := range cfg.Telemetry.Metrics {
+			metrics = append(metrics, telemetry.Metric{
+				Attribute:   m.Attribute,
+				Measurement: m.Measurement,
+				Field:       m.Field,
+				Scale:       m.Scale,
+				Tags:        m.Tags,
+			})
+		}
+		s.telemetry, err = telemetry.NewExporter(s, telemetry.Config{
+			URL:           cfg.Telemetry.URL,
+			Token:         cfg.Telemetry.Token,
+			Metrics:       metrics,
+			Tags:          cfg.Telemetry.Tags,
+			BatchSize:     cfg.Telemetry.BatchSize,
+			FlushInterval: cfg.Telemetry.FlushInterval,
+			BufferSize:    cfg.Telemetry.BufferSize,
+			Timeout:       cfg.Telemetry.Timeout,
+			Logger:        log.WithName("telemetry"),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.setupReplication(jsonStorage); err != nil {
+		return nil, err
+	}
+
+	// Sessions are established to the addresses the mDNS browser found
+	if user, ok := s.controller.(controller.ResolverUser); ok {
+		user.SetResolver(s)
+	}
+	if receiver, ok := s.controller.(controller.CheckInReceiver); ok {
+		receiver.SetCheckInListener(s)
+	}
+
+	if cfg.Server.FaultInjection {
+		s.setupFaultInjection()
+	}
+
 	return s, nil
 }
 
 // Run starts the server and blocks until shutdown
 func (s *Server) Run(ctx context.Context) error {
-	s.logger.Info("Starting Matter server",
+	s.logger.Debug("Starting Matter server",
 		logger.Int("port", s.config.Server.Port),
 		logger.String("listen", strings.Join(s.config.Server.ListenAddresses, ", ")),
 	)
 
 	// Start storage
 	if err := s.storage.Start(); err != nil {
+		s.health.setError(subsystemStorage, err)
 		return fmt.Errorf("failed to start storage: %w", err)
 	}
 	defer s.storage.Stop()
+	s.health.setReady(subsystemStorage)
 
-	// Load existing nodes
-	if err := s.loadNodes(); err != nil {
-		s.logger.Error("Failed to load nodes", logger.ErrorField(err))
-	}
+	// Runtime settings take precedence over the configuration
+	s.applyServerSettings()
 
-	// Start mDNS server if enabled
-	if s.mdnsServer != nil {
-		if err := s.mdnsServer.Start(); err != nil {
-			s.logger.Error("Failed to start mDNS server", logger.ErrorField(err))
+	if s.config.Storage.AuditLog {
+		auditLog, err := audit.Open(filepath.Join(s.config.Storage.Path, auditLogFile))
+		if err != nil {
+			s.logger.Error("Failed to open audit log", logger.ErrorField(err))
 		} else {
-			s.logger.Info("mDNS server started",
-				logger.String("hostname", s.mdnsZone.GetHostname()),
-			)
+			s.auditLog = auditLog
+			defer auditLog.Close()
 		}
 	}
+	defer func() {
+		s.serverSettingsMu.Lock()
+		s.stopTemporaryLogLevel()
+		s.serverSettingsMu.Unlock()
+	}()
+
+	// Load existing nodes. Corrupted node files are only reported here when
+	// the corruption policy says to fail.
+	matterErr := s.loadNodes()
+	if errors.Is(matterErr, storage.ErrCorrupted) {
+		s.health.setError(subsystemStorage, matterErr)
+		return matterErr
+	}
+	if matterErr != nil {
+		s.logger.Error("Failed to load nodes", logger.ErrorField(matterErr))
+	}
+
+	// Stream log entries to clients listening for log_entry
+	defer s.emitLogEntries()()
+
+	// Check system clock sanity (certificates fail with a wrong time)
+	s.clockChecker.Start(ctx)
+	defer s.clockChecker.Stop()
+
+	defer s.availabilityMonitor.Stop()
+
+	// Load PAA root certificates and refresh them from the DCL
+	if err := s.paaStore.Load(); err != nil {
+		s.logger.Error("Failed to load PAA root certificates", logger.ErrorField(err))
+		matterErr = err
+	}
+	if matterErr != nil {
+		s.health.setError(subsystemMatter, matterErr)
+	} else {
+		s.health.setReady(subsystemMatter)
+	}
+	if s.config.Matter.PAARoot != "" {
+		go s.fetchPAACertificates(ctx)
+	}
+
+	// A standby leaves the devices to the primary until it is promoted
+	mdnsStarted := false
+	if s.standby != nil {
+		s.standby.Start(ctx)
+	} else {
+		mdnsStarted = s.activate(ctx)
+	}
 
 	// Start Bluetooth manager if enabled
+	bluetoothStarted := false
 	if s.bluetoothManager != nil && s.bluetoothManager.IsEnabled() {
 		if err := s.bluetoothManager.Start(); err != nil {
 			s.logger.Error("Failed to start Bluetooth manager", logger.ErrorField(err))
 		} else {
-			s.logger.Info("Bluetooth manager started")
+			bluetoothStarted = true
+		}
+	}
+
+	// Serve profiling endpoints apart from the API
+	if s.config.Server.DebugPort != 0 {
+		if err := s.startDebugServer(); err != nil {
+			s.logger.Error("Failed to start debug server", logger.ErrorField(err))
+		}
+	}
+
+	if s.config.Server.GRPCPort != 0 {
+		if err := s.startGRPCServer(); err != nil {
+			s.logger.Error("Failed to start gRPC server", logger.ErrorField(err))
 		}
 	}
 
@@ -195,20 +630,51 @@ func (s *Server) Run(ctx context.Context) error {
 		Addr:         addr,
 		Handler:      router,
 		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
+		WriteTimeout: httpWriteTimeout,
 		IdleTimeout:  60 * time.Second,
 	}
 
+	// Bind the listener before announcing readiness
+	listener, err := s.listenAPI(addr)
+	if err != nil {
+		return fmt.Errorf("server error: %w", err)
+	}
+
 	// Start server in goroutine
 	serverErr := make(chan error, 1)
 	go func() {
-		s.logger.Info("HTTP server listening", logger.String("addr", addr))
-		if err := s.httpServer.ListenAndServe(); err != http.ErrServerClosed {
+		if err := s.httpServer.Serve(listener); err != http.ErrServerClosed {
 			serverErr <- err
 		}
 		close(serverErr)
 	}()
 
+	summary := s.startupSummary([]string{listener.Addr().String()}, map[string]bool{
+		"mdns":        mdnsStarted,
+		"srp":         s.srpClient != nil,
+		"mqtt":        s.mqttBridge != nil,
+		"telemetry":   s.telemetry != nil,
+		"replication": s.replicationPrimary != nil || s.standby != nil,
+		"bluetooth":   bluetoothStarted,
+		"ntp":         s.config.Clock.NTPServer != "",
+		"dashboard":   s.config.Server.ServeStatic,
+		"debug":       s.debugServer != nil,
+		"grpc":        s.grpcServer != nil,
+	})
+	s.logStartupSummary(summary)
+
+	if s.config.Server.ReadyFile != "" {
+		if err := writeReadyFile(s.config.Server.ReadyFile, summary); err != nil {
+			s.logger.Error("Failed to write ready file", logger.ErrorField(err))
+		} else {
+			defer removeReadyFile(s.config.Server.ReadyFile, s.logger)
+		}
+	}
+	s.notifySystemd(systemd.Ready, systemd.Status("Listening on "+listener.Addr().String()))
+	if interval := systemd.WatchdogInterval(); interval > 0 {
+		go s.runWatchdog(ctx, interval)
+	}
+
 	// Wait for context cancellation or server error
 	select {
 	case <-ctx.Done():
@@ -219,6 +685,62 @@ func (s *Server) Run(ctx context.Context) error {
 	}
 }
 
+// activate starts the subsystems talking to the devices and announcing the
+// server, on a standby once it is promoted. It reports whether mDNS started.
+func (s *Server) activate(ctx context.Context) bool {
+	// Subscribe to node events
+	s.startSubscriptions(ctx)
+
+	// Monitor node availability
+	s.availabilityMonitor.Start(ctx)
+
+	// Start mDNS server if enabled
+	mdnsStarted := false
+	if s.mdnsServer != nil {
+		if err := s.mdnsServer.Start(); err != nil {
+			s.logger.Error("Failed to start mDNS server", logger.ErrorField(err))
+			s.health.setError(subsystemMDNS, err)
+		} else {
+			mdnsStarted = true
+			s.health.setReady(subsystemMDNS)
+		}
+	}
+
+	if s.srpClient != nil {
+		s.srpClient.Start()
+	}
+
+	if s.mqttBridge != nil {
+		s.mqttBridge.Start(ctx)
+	}
+
+	if s.telemetry != nil {
+		s.telemetry.Start()
+	}
+
+	if s.config.Energy.PollInterval > 0 {
+		s.supervisor.Go(ctx, "energy_poll", func(ctx context.Context) error {
+			s.pollEnergy(ctx)
+			return nil
+		})
+	}
+
+	if s.config.Interview.Concurrency > 0 {
+		s.supervisor.Go(ctx, "reinterviews", func(ctx context.Context) error {
+			s.runReinterviews(ctx)
+			return nil
+		})
+	}
+
+	if s.config.OTA.CheckInterval > 0 {
+		s.supervisor.Go(ctx, "firmware_checks", func(ctx context.Context) error {
+			s.runFirmwareChecks(ctx)
+			return nil
+		})
+	}
+	return mdnsStarted
+}
+
 // HandleCommand processes WebSocket commands
 func (s *Server) HandleCommand(ctx context.Context, cmd models.CommandMessage) (interface{}, error) {
 	s.logger.Debug("Handling command",
@@ -226,31 +748,154 @@ func (s *Server) HandleCommand(ctx context.Context, cmd models.CommandMessage) (
 		logger.String("message_id", cmd.MessageID),
 	)
 
-	switch models.APICommand(cmd.Command) {
+	s.commandsInFlight.Add(1)
+	defer s.commandsInFlight.Add(-1)
+	if s.shuttingDown.Load() {
+		return nil, fmt.Errorf("%w: %s rejected", models.ErrShuttingDown, cmd.Command)
+	}
+
+	start := time.Now()
+	result, err := s.runCommandWithTimeout(ctx, cmd)
+	if auditedCommands[models.APICommand(cmd.Command)] {
+		s.recordAudit(ctx, cmd, start, err)
+	}
+	return result, err
+}
+
+// runCommand validates the arguments of a command and runs its handler
+func (s *Server) runCommand(ctx context.Context, cmd models.CommandMessage) (interface{}, error) {
+	command := models.APICommand(cmd.Command)
+	if token, ok := tokenCommands[command]; ok && !tokenAuthorized(audit.ClientFromContext(ctx).BearerToken, token(s.config)) {
+		return nil, fmt.Errorf("%w: %s", models.ErrUnauthorized, cmd.Command)
+	}
+	args, err := validateArgs(command, cmd.Args)
+	if err != nil {
+		return nil, err
+	}
+	if auditedCommands[command] && command != models.APICommandPromoteStandby && s.isStandby() {
+		return nil, fmt.Errorf("%w: send %s to the primary", models.ErrStandby, cmd.Command)
+	}
+
+	switch command {
 	case models.APICommandServerInfo:
 		return s.handleServerInfo()
 	case models.APICommandGetNodes:
-		return s.handleGetNodes()
+		return s.handleGetNodesQuery(args)
 	case models.APICommandGetNode:
-		return s.handleGetNode(cmd.Args)
+		return s.handleGetNode(args)
 	case models.APICommandServerDiagnostics:
 		return s.handleServerDiagnostics()
 	case models.APICommandStartListening:
 		return s.handleStartListening()
 	case models.APICommandPingNode:
-		return s.handlePingNode(cmd.Args)
+		return s.handlePingNode(ctx, args)
+	case models.APICommandGetNodeIPAddresses:
+		return s.handleGetNodeIPAddresses(args)
+	case models.APICommandDeviceCommand:
+		return s.handleDeviceCommand(ctx, args)
+	case models.APICommandReadAttribute:
+		return s.handleReadAttribute(ctx, args)
+	case models.APICommandWriteAttribute:
+		return s.handleWriteAttribute(ctx, args)
+	case models.APICommandCommissionWithCode, models.APICommandCommissionOnNetwork:
+		return s.handleCommission(ctx, command, args)
+	case models.APICommandGetCommissioningQueue:
+		return s.handleGetCommissioningQueue()
+	case models.APICommandCancelCommissioning:
+		return s.handleCancelCommissioning(args)
+	case models.APICommandInterviewNode:
+		return s.handleInterviewNode(ctx, args)
+	case models.APICommandSetNodeName:
+		return s.handleSetNodeName(ctx, args)
+	case models.APICommandSetNodeMetadata:
+		return s.handleSetNodeMetadata(ctx, args)
+	case models.APICommandGetDevices:
+		return s.handleGetDevices(args)
+	case models.APICommandDeviceAction:
+		return s.handleDeviceAction(ctx, args)
+	case models.APICommandGetEnergySummary:
+		return s.handleGetEnergySummary(args)
+	case models.APICommandCheckNodeUpdate:
+		return s.handleCheckNodeUpdate(ctx, args)
+	case models.APICommandGetFirmwareInventory:
+		return s.handleGetFirmwareInventory(ctx, args)
+	case models.APICommandParsePairingCode:
+		return s.handleParsePairingCode(args)
+	case models.APICommandCreateGroup:
+		return s.handleCreateGroup(args)
+	case models.APICommandRemoveGroup:
+		return s.handleRemoveGroup(ctx, args)
+	case models.APICommandGetGroups:
+		return s.handleGetGroups()
+	case models.APICommandAddGroupMember:
+		return s.handleAddGroupMember(ctx, args)
+	case models.APICommandRemoveGroupMember:
+		return s.handleRemoveGroupMember(ctx, args)
+	case models.APICommandGroupCommand:
+		return s.handleGroupCommand(ctx, args)
+	case models.APICommandStoreDeviceScene, models.APICommandRecallDeviceScene, models.APICommandRemoveDeviceScene:
+		return s.handleDeviceScene(ctx, command, args)
+	case models.APICommandCreateScene:
+		return s.handleCreateScene(args)
+	case models.APICommandGetScenes:
+		return s.handleGetScenes()
+	case models.APICommandStoreScene:
+		return s.handleStoreScene(ctx, args)
+	case models.APICommandRecallScene:
+		return s.handleRecallScene(ctx, args)
+	case models.APICommandRemoveScene:
+		return s.handleRemoveScene(ctx, args)
+	case models.APICommandGetNodeFabrics:
+		return s.handleGetNodeFabrics(ctx, args)
+	case models.APICommandRemoveNodeFabric:
+		return s.handleRemoveNodeFabric(ctx, args)
+	case models.APICommandGetSessions:
+		return s.handleGetSessions()
+	case models.APICommandDisconnectSession:
+		return s.handleDisconnectSession(args)
+	case models.APICommandInjectFaults:
+		return s.handleInjectFaults(args)
+	case models.APICommandGetEventHistory:
+		return s.handleGetEventHistory(args)
+	case models.APICommandGetAttributeHistory:
+		return s.handleGetAttributeHistory(args)
+	case models.APICommandExportSettings:
+		return s.exportSettings()
+	case models.APICommandImportSettings:
+		return s.handleImportSettings(args)
+	case models.APICommandDiscoverBLE:
+		return s.handleDiscoverBLE(ctx, args)
+	case models.APICommandGetNetworkInfo:
+		return s.handleGetNetworkInfo()
+	case models.APICommandGetServerSettings:
+		return s.handleGetServerSettings()
+	case models.APICommandSetServerSetting:
+		return s.handleSetServerSetting(args)
+	case models.APICommandSetDefaultFabricLabel:
+		return s.handleSetDefaultFabricLabel(args)
+	case models.APICommandGetLogs:
+		return s.handleGetLogs(args)
+	case models.APICommandSetLogLevel:
+		return s.handleSetLogLevel(args)
+	case models.APICommandGetAuditLog:
+		return s.handleGetAuditLog(args)
+	case models.APICommandGetReplicationStatus:
+		return s.handleGetReplicationStatus()
+	case models.APICommandPromoteStandby:
+		return s.handlePromoteStandby()
 	default:
-		return nil, fmt.Errorf("unknown command: %s", cmd.Command)
+		return nil, fmt.Errorf("%w: %s", models.ErrUnknownCommand, cmd.Command)
 	}
 }
 
-// Subscribe adds an event callback
+// Subscribe adds an event callback. Events are passed to it in the order
+// they were emitted, from a goroutine of its own.
 func (s *Server) Subscribe(callback models.EventCallback) func() {
 	s.eventMu.Lock()
 	defer s.eventMu.Unlock()
 
 	id := models.GenerateMessageID()
-	s.eventCallbacks = append(s.eventCallbacks, eventSubscription{id: id, cb: callback})
+	s.eventCallbacks = append(s.eventCallbacks, newEventSubscription(id, callback, s.config.Server.EventQueueSize, s.logger, &s.panics.events))
 
 	// Return unsubscribe function (removes by ID)
 	return func() {
@@ -259,6 +904,7 @@ func (s *Server) Subscribe(callback models.EventCallback) func() {
 
 		for i := range s.eventCallbacks {
 			if s.eventCallbacks[i].id == id {
+				close(s.eventCallbacks[i].stop)
 				s.eventCallbacks = append(s.eventCallbacks[:i], s.eventCallbacks[i+1:]...)
 				break
 			}
@@ -266,105 +912,364 @@ func (s *Server) Subscribe(callback models.EventCallback) func() {
 	}
 }
 
-// GetServerInfo returns server information
+// GetServerInfo returns server information, with the Bluetooth adapters
+// present right now
 func (s *Server) GetServerInfo() models.ServerInfoMessage {
-	return s.serverInfo
+	info := s.serverInfo
+	// Changes when a standby loads the credentials of the primary
+	info.CompressedFabricID = s.credentials.CompressedFabricID()
+	info.Fabrics = s.serverFabrics()
+	if s.bluetoothManager != nil {
+		info.BluetoothEnabled = s.bluetoothManager.IsAvailable()
+		info.BluetoothAdapters = s.bluetoothManager.Adapters()
+	}
+	return info
 }
 
-// EmitEvent sends an event to all subscribers
+// EmitEvent sends an event to all subscribers without waiting for them
 func (s *Server) EmitEvent(eventType models.EventType, data interface{}) {
-	s.eventMu.RLock()
-	callbacks := make([]eventSubscription, len(s.eventCallbacks))
-	copy(callbacks, s.eventCallbacks)
-	s.eventMu.RUnlock()
-
-	for _, sub := range callbacks {
-		// Run callbacks asynchronously to avoid blocking
-		go sub.cb(eventType, data)
-	}
+	s.recordEvent(eventType, data)
+	s.dispatchEvent(eventType, data)
 }
 
 // Command handlers
 
 func (s *Server) handleServerInfo() (interface{}, error) {
-	return s.serverInfo, nil
+	return s.GetServerInfo(), nil
 }
 
 func (s *Server) handleGetNodes() (interface{}, error) {
+	return s.nodeSnapshot(), nil
+}
+
+func (s *Server) handleGetNode(args commandArgs) (interface{}, error) {
+	nodeID := args.nodeID()
+
 	s.nodesMu.RLock()
-	defer s.nodesMu.RUnlock()
+	node, exists := s.nodes[nodeID]
+	s.nodesMu.RUnlock()
 
-	nodes := make([]*models.MatterNodeData, 0, len(s.nodes))
-	for _, node := range s.nodes {
-		nodeCopy := *node
-		nodes = append(nodes, &nodeCopy)
+	if !exists {
+		return nil, &models.NodeNotFoundError{NodeID: nodeID}
 	}
 
-	return nodes, nil
+	if args.boolean("annotate") {
+		annotated := *node
+		annotated.AttributeNames = clusters.Default().AnnotateAttributes(node.Attributes)
+		return &annotated, nil
+	}
+	return node, nil
 }
 
-func (s *Server) handleGetNode(args map[string]interface{}) (interface{}, error) {
-	nodeID, err := parseNodeID(args)
+// handleGetNodesQuery returns the nodes selected by the get_nodes arguments
+func (s *Server) handleGetNodesQuery(args commandArgs) (interface{}, error) {
+	q, err := newNodeQuery(args)
 	if err != nil {
 		return nil, err
 	}
+	nodes, _, err := q.run(s.nodeSnapshot())
+	return nodes, err
+}
+
+func (s *Server) handleServerDiagnostics() (interface{}, error) {
+	clockStatus := s.clockChecker.Status()
+	wsStats := s.wsHandler.Stats()
+	bluetoothStatus := models.BluetoothStatus{
+		Reason:   models.BluetoothReasonDisabled,
+		Adapters: []models.BluetoothAdapter{},
+	}
+	if s.bluetoothManager != nil {
+		bluetoothStatus = s.bluetoothManager.Status()
+	}
+
+	return models.ServerDiagnostics{
+		Info:      s.GetServerInfo(),
+		Nodes:     s.nodeSnapshot(),
+		Events:    s.recentEvents(),
+		Clock:     &clockStatus,
+		WebSocket: &wsStats,
+		Bluetooth: &bluetoothStatus,
+		Network:   &s.network,
+
+		Subscriptions:    s.subscriptionStatus(),
+		EventSubscribers: s.eventSubscriberStats(),
+		SupervisedTasks:  s.supervisor.Status(),
+		Panics:           s.panicStats(),
+	}, nil
+}
+
+func (s *Server) handleGetSessions() (interface{}, error) {
+	return s.wsHandler.Sessions(), nil
+}
+
+func (s *Server) handleDisconnectSession(args commandArgs) (interface{}, error) {
+	sessionID := args.str("session_id")
+	if !s.wsHandler.DisconnectSession(sessionID) {
+		return nil, fmt.Errorf("session %s not found", sessionID)
+	}
+	return models.DisconnectSessionResult{SessionID: sessionID, Disconnected: true}, nil
+}
+
+func (s *Server) handleStartListening() (interface{}, error) {
+	// Return all nodes for initial state
+	return s.handleGetNodes()
+}
+
+func (s *Server) handlePingNode(ctx context.Context, args commandArgs) (interface{}, error) {
+	nodeID := args.nodeID()
 
 	s.nodesMu.RLock()
 	node, exists := s.nodes[nodeID]
 	s.nodesMu.RUnlock()
 
 	if !exists {
-		return nil, fmt.Errorf("node %d not found", nodeID)
+		return nil, &models.NodeNotFoundError{NodeID: nodeID}
 	}
 
-	nodeCopy := *node
-	return &nodeCopy, nil
+	attempts := 1
+	if args.has("attempts") {
+		attempts = int(args.integer("attempts"))
+	}
+
+	return s.pingAddresses(ctx, s.nodeAddresses(node), attempts), nil
 }
 
-func (s *Server) handleServerDiagnostics() (interface{}, error) {
-	nodes, err := s.handleGetNodes()
+// pingAddresses probes all addresses, retrying unreachable ones up to
+// attempts times, and returns the reachability per address
+func (s *Server) pingAddresses(ctx context.Context, ips []net.IP, attempts int) models.NodePingResult {
+	result := make(models.NodePingResult, len(ips))
+
+	pending := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		pending = append(pending, ip.String())
+		result[ip.String()] = false
+	}
+
+	for i := 0; i < attempts && len(pending) > 0; i++ {
+		reachability := ping.Addresses(ctx, pending, s.primaryInterface(), ping.MatterPort, pingTimeout)
+
+		pending = pending[:0]
+		for addr, reachable := range reachability {
+			result[addr] = reachable
+			if !reachable {
+				pending = append(pending, addr)
+			}
+		}
+	}
+
+	return result
+}
+
+func (s *Server) handleGetNodeIPAddresses(args commandArgs) (interface{}, error) {
+	nodeID := args.nodeID()
+
+	s.nodesMu.RLock()
+	node, exists := s.nodes[nodeID]
+	s.nodesMu.RUnlock()
+
+	if !exists {
+		return nil, &models.NodeNotFoundError{NodeID: nodeID}
+	}
+
+	scope := ""
+	if args.boolean("scoped") {
+		scope = s.primaryInterface()
+	}
+
+	addrs := make([]string, 0)
+	for _, ip := range s.nodeAddresses(node) {
+		addrs = append(addrs, formatIP(ip, scope))
+	}
+	return addrs, nil
+}
+
+func (s *Server) handleDeviceCommand(ctx context.Context, args commandArgs) (interface{}, error) {
+	nodeID := args.nodeID()
+
+	endpointID := args.uint16("endpoint_id")
+
+	clusterID, commandID, err := args.clusterCommand()
 	if err != nil {
 		return nil, err
 	}
 
-	nodeList := nodes.([]*models.MatterNodeData)
-	nodeSlice := make([]models.MatterNodeData, len(nodeList))
-	for i, node := range nodeList {
-		nodeSlice[i] = *node
+	// Fields of manufacturer-specific commands can be passed TLV encoded
+	rawPayload, err := args.tlv("payload_tlv")
+	if err != nil {
+		return nil, err
+	}
+	if rawPayload != nil && args.has("payload") {
+		return nil, &models.ArgumentError{Field: "payload_tlv", Reason: "cannot be combined with payload"}
 	}
 
-	return models.ServerDiagnostics{
-		Info:   s.serverInfo,
-		Nodes:  nodeSlice,
-		Events: []interface{}{}, // Empty for now
-	}, nil
+	s.nodesMu.RLock()
+	_, exists := s.nodes[nodeID]
+	s.nodesMu.RUnlock()
+
+	if !exists {
+		return nil, &models.NodeNotFoundError{NodeID: nodeID}
+	}
+
+	req := controller.CommandRequest{
+		NodeID:              nodeID,
+		EndpointID:          endpointID,
+		ClusterID:           clusterID,
+		CommandID:           commandID,
+		Payload:             args.object("payload"),
+		RawPayload:          rawPayload,
+		RawResponse:         args.boolean("raw_response"),
+		TimedRequestTimeout: args.timedRequestTimeout(),
+	}
+	if queued, ok, err := s.queueForICD(models.APICommandDeviceCommand, nodeID, &req, nil); ok || err != nil {
+		return queued, err
+	}
+	response, err := s.controller.SendCommand(ctx, req)
+	if raw, ok := response.([]byte); ok && err == nil {
+		return hex.EncodeToString(raw), nil
+	}
+	return response, err
 }
 
-func (s *Server) handleStartListening() (interface{}, error) {
-	// Return all nodes for initial state
-	return s.handleGetNodes()
+// handleReadAttribute reads an attribute from the node, optionally returning
+// its TLV encoded value for attributes without known type
+func (s *Server) handleReadAttribute(ctx context.Context, args commandArgs) (interface{}, error) {
+	nodeID := args.nodeID()
+
+	path, err := args.attributePath("attribute_path")
+	if err != nil {
+		return nil, err
+	}
+
+	s.nodesMu.RLock()
+	_, exists := s.nodes[nodeID]
+	s.nodesMu.RUnlock()
+
+	if !exists {
+		return nil, &models.NodeNotFoundError{NodeID: nodeID}
+	}
+
+	if !args.boolean("raw") {
+		return s.controller.ReadAttribute(ctx, nodeID, path.String())
+	}
+	reader, ok := s.controller.(controller.RawReader)
+	if !ok {
+		return nil, controller.ErrNotAvailable
+	}
+	raw, err := reader.ReadAttributeRaw(ctx, nodeID, path.String())
+	if err != nil {
+		return nil, err
+	}
+	return hex.EncodeToString(raw), nil
 }
 
-func (s *Server) handlePingNode(args map[string]interface{}) (interface{}, error) {
-	nodeID, err := parseNodeID(args)
+func (s *Server) handleWriteAttribute(ctx context.Context, args commandArgs) (interface{}, error) {
+	nodeID := args.nodeID()
+
+	path, err := args.attributePath("attribute_path")
 	if err != nil {
 		return nil, err
 	}
 
-	// Simple ping implementation - in real implementation this would ping the actual device
+	// Values of manufacturer-specific attributes can be passed TLV encoded
+	rawValue, err := args.tlv("value_tlv")
+	if err != nil {
+		return nil, err
+	}
+	if rawValue == nil && !args.has("value") {
+		return nil, &models.ArgumentError{Field: "value", Reason: "value or value_tlv is required"}
+	}
+	if rawValue != nil && args.has("value") {
+		return nil, &models.ArgumentError{Field: "value_tlv", Reason: "cannot be combined with value"}
+	}
+
 	s.nodesMu.RLock()
 	_, exists := s.nodes[nodeID]
 	s.nodesMu.RUnlock()
 
 	if !exists {
-		return nil, fmt.Errorf("node %d not found", nodeID)
+		return nil, &models.NodeNotFoundError{NodeID: nodeID}
+	}
+
+	req := controller.WriteRequest{
+		NodeID:              nodeID,
+		Path:                path.String(),
+		Value:               args["value"],
+		RawValue:            rawValue,
+		TimedRequestTimeout: args.timedRequestTimeout(),
+	}
+	if queued, ok, err := s.queueForICD(models.APICommandWriteAttribute, nodeID, nil, &req); ok || err != nil {
+		return queued, err
+	}
+	if err := s.controller.WriteAttribute(ctx, req); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+func (s *Server) handleInterviewNode(ctx context.Context, args commandArgs) (interface{}, error) {
+	return nil, s.interviewNode(ctx, args.nodeID())
+}
+
+// interviewNode re-reads all attributes of a node and stores them
+func (s *Server) interviewNode(ctx context.Context, nodeID int) error {
+	s.nodesMu.RLock()
+	existing, exists := s.nodes[nodeID]
+	s.nodesMu.RUnlock()
+
+	if !exists {
+		return &models.NodeNotFoundError{NodeID: nodeID}
+	}
+
+	progress.Report(ctx, "reading_attributes", 0)
+	attributes, err := s.controller.Interview(ctx, nodeID)
+	if err != nil {
+		return fmt.Errorf("failed to interview node %d: %w", nodeID, err)
+	}
+
+	progress.Report(ctx, "updating_node", 90)
+	// The node may have changed or been removed during the interview
+	s.nodesMu.RLock()
+	existing, exists = s.nodes[nodeID]
+	s.nodesMu.RUnlock()
+	if !exists {
+		return &models.NodeNotFoundError{NodeID: nodeID}
+	}
+	node := *existing
+	node.Attributes = attributes
+	node.LastInterview = time.Now().UTC()
+	node.InterviewVersion = models.CurrentInterviewVersion
+	if err := s.applyNodeUpdate(&node); err != nil {
+		return err
+	}
+	s.registerICDClient(ctx, nodeID)
+
+	progress.Report(ctx, "completed", 100)
+	return nil
+}
+
+// fetchPAACertificates downloads PAA root certificates from the main-net DCL
+// and, if enabled, the test-net DCL into the PAA store
+func (s *Server) fetchPAACertificates(ctx context.Context) {
+	urls := []string{attestation.MainNetDCLURL}
+	if s.config.Matter.EnableTestNetDCL {
+		urls = append(urls, attestation.TestNetDCLURL)
 	}
 
-	result := models.NodePingResult{
-		"reachable": true,
+	client := &http.Client{Timeout: 30 * time.Second}
+	for _, url := range urls {
+		if _, err := s.paaStore.FetchFromDCL(ctx, client, url); err != nil {
+			s.logger.Warn("Failed to fetch PAA certificates from DCL",
+				logger.String("url", url),
+				logger.ErrorField(err),
+			)
+		}
 	}
+}
 
-	return result, nil
+// VerifyDeviceAttestation validates the attestation information a device
+// returns during commissioning against the PAA trust store
+func (s *Server) VerifyDeviceAttestation(dac, pai, certificationDeclaration []byte) (*attestation.Result, error) {
+	return s.attestation.Verify(dac, pai, certificationDeclaration)
 }
 
 // Bluetooth command handlers removed: the Go server does not expose
@@ -381,37 +1286,109 @@ func (s *Server) setupRouter() *mux.Router {
 	// HTTP API endpoints
 	api := router.PathPrefix("/api").Subrouter()
 	api.HandleFunc("/info", s.handleInfoHTTP).Methods("GET")
+	api.HandleFunc("/addon-info", s.handleAddonInfoHTTP).Methods("GET")
 	api.HandleFunc("/nodes", s.handleNodesHTTP).Methods("GET")
+	api.HandleFunc("/nodes/{node_id:[0-9]+}", s.handleNodeHTTP).Methods("GET")
+	api.HandleFunc("/nodes/{node_id:[0-9]+}/attributes/{attribute_path:[0-9*]+/[0-9*]+/[0-9*]+}", s.handleNodeAttributesHTTP).Methods("GET")
+	api.HandleFunc("/devices", s.handleDevicesHTTP).Methods("GET")
+	api.HandleFunc("/energy", s.handleEnergyHTTP).Methods("GET")
+	api.HandleFunc("/firmware", s.handleFirmwareHTTP).Methods("GET")
+	api.HandleFunc("/commissioning/queue", s.handleCommissioningQueueHTTP).Methods("GET")
 	api.HandleFunc("/diagnostics", s.handleDiagnosticsHTTP).Methods("GET")
+	api.HandleFunc("/logs", s.handleLogsHTTP).Methods("GET")
+	api.HandleFunc("/settings/export", s.handleSettingsExportHTTP).Methods("GET")
+	api.HandleFunc("/sessions", s.handleSessionsHTTP).Methods("GET")
+	api.HandleFunc("/sessions/{id}", s.handleDisconnectSessionHTTP).Methods("DELETE")
+	for _, route := range commandRoutes {
+		api.HandleFunc(route.path, s.handleCommandHTTP(route.command)).Methods(route.method)
+	}
+	api.HandleFunc("/openapi.json", s.handleOpenAPIHTTP(router)).Methods("GET")
+	api.HandleFunc("/docs", s.handleDocsHTTP).Methods("GET")
+	api.HandleFunc("/docs/{asset}", s.handleDocsAssetHTTP).Methods("GET")
+
+	// Health checks, /health is kept as an alias of the liveness probe
+	router.HandleFunc("/health", s.handleHealthLive).Methods("GET")
+	router.HandleFunc("/health/live", s.handleHealthLive).Methods("GET")
+	router.HandleFunc("/health/ready", s.handleHealthReady).Methods("GET")
+
+	// Replication stream for standbys
+	if s.replicationPrimary != nil {
+		router.Handle(replication.Path, s.replicationPrimary).Methods("GET")
+	}
 
-	// Health check
-	router.HandleFunc("/health", s.handleHealth).Methods("GET")
-
-	// Serve static files if available - removed for now as ServeStatic field doesn't exist
-	// TODO: Add static file serving configuration
+	// Web dashboard, registered last so it doesn't shadow other routes
+	if s.config.Server.ServeStatic {
+		if dir := s.config.Server.StaticDir; dir != "" {
+			if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+				s.logger.Warn("Dashboard directory not found, serving the embedded dashboard",
+					logger.String("dir", dir),
+				)
+			}
+		}
+		router.PathPrefix("/").Handler(dashboard.Handler(s.config.Server.StaticDir))
+	}
 
 	// Add middleware
 	router.Use(s.loggingMiddleware)
+	router.Use(s.recoveryMiddleware)
 	router.Use(s.corsMiddleware)
 
 	return router
 }
 
 func (s *Server) handleInfoHTTP(w http.ResponseWriter, r *http.Request) {
-	s.writeJSON(w, s.serverInfo)
+	s.writeJSON(w, s.GetServerInfo())
 }
 
+// handleNodesHTTP lists nodes. The query parameters are the arguments of
+// get_nodes; X-Total-Count holds the number of nodes matching the filters.
 func (s *Server) handleNodesHTTP(w http.ResponseWriter, r *http.Request) {
-	nodes, err := s.handleGetNodes()
+	raw := make(map[string]interface{})
+	for name, values := range r.URL.Query() {
+		raw[name] = values[len(values)-1]
+	}
+
+	args, err := validateArgs(models.APICommandGetNodes, raw)
+	if err != nil {
+		s.writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	q, err := newNodeQuery(args)
+	if err != nil {
+		s.writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	nodes, total, err := q.run(s.nodeSnapshot())
 	if err != nil {
 		s.writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
 	s.writeJSON(w, nodes)
 }
 
+// handleDiagnosticsHTTP returns the server diagnostics, or with
+// ?format=bundle a zip archive to attach to bug reports
 func (s *Server) handleDiagnosticsHTTP(w http.ResponseWriter, r *http.Request) {
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json":
+	case "bundle":
+		var bundle bytes.Buffer
+		if err := s.writeSupportBundle(&bundle); err != nil {
+			s.writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		filename := "matter-server-diagnostics-" + time.Now().UTC().Format("20060102T150405Z") + ".zip"
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		w.Write(bundle.Bytes())
+		return
+	default:
+		s.writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown format %q", format))
+		return
+	}
+
 	diagnostics, err := s.handleServerDiagnostics()
 	if err != nil {
 		s.writeError(w, http.StatusInternalServerError, err.Error())
@@ -421,15 +1398,18 @@ func (s *Server) handleDiagnosticsHTTP(w http.ResponseWriter, r *http.Request) {
 	s.writeJSON(w, diagnostics)
 }
 
-func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
-	health := map[string]interface{}{
-		"status":      "ok",
-		"timestamp":   time.Now().UTC(),
-		"connections": s.wsHandler.GetConnectionCount(),
-		"nodes":       len(s.nodes),
+func (s *Server) handleSessionsHTTP(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, s.wsHandler.Sessions())
+}
+
+func (s *Server) handleDisconnectSessionHTTP(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["id"]
+	if !s.wsHandler.DisconnectSession(sessionID) {
+		s.writeError(w, http.StatusNotFound, fmt.Sprintf("session %s not found", sessionID))
+		return
 	}
 
-	s.writeJSON(w, health)
+	w.WriteHeader(http.StatusNoContent)
 }
 
 // Helper methods
@@ -444,6 +1424,8 @@ func (s *Server) loadNodes() error {
 	defer s.nodesMu.Unlock()
 
 	for _, node := range nodes {
+		s.tagFabric(node)
+		expandBridge(node)
 		s.nodes[node.NodeID] = node
 	}
 
@@ -451,37 +1433,72 @@ func (s *Server) loadNodes() error {
 	return nil
 }
 
-func (s *Server) shutdown() error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// nodeSnapshot returns all known nodes. The nodes are shared, callers must
+// not change them.
+func (s *Server) nodeSnapshot() []*models.MatterNodeData {
+	s.nodesMu.RLock()
+	defer s.nodesMu.RUnlock()
 
-	// Shutdown HTTP server
-	if err := s.httpServer.Shutdown(ctx); err != nil {
-		s.logger.Error("Failed to shutdown HTTP server", logger.ErrorField(err))
+	nodes := make([]*models.MatterNodeData, 0, len(s.nodes))
+	for _, node := range s.nodes {
+		nodes = append(nodes, node)
 	}
+	return nodes
+}
 
-	// Shutdown WebSocket handler
-	s.wsHandler.Shutdown()
+// setNodeAvailable updates and persists a node's availability and emits
+// node_updated when it changed
+func (s *Server) setNodeAvailable(nodeID int, available bool) error {
+	s.nodesMu.Lock()
+	node, exists := s.nodes[nodeID]
+	if !exists {
+		s.nodesMu.Unlock()
+		return &models.NodeNotFoundError{NodeID: nodeID}
+	}
+	if node.Available == available {
+		s.nodesMu.Unlock()
+		return nil
+	}
+	updated := *node
+	updated.Available = available
+	s.nodes[nodeID] = &updated
+	s.nodesMu.Unlock()
 
-	// Shutdown Bluetooth manager
-	if s.bluetoothManager != nil {
-		if err := s.bluetoothManager.Stop(); err != nil {
-			s.logger.Error("Failed to shutdown Bluetooth manager", logger.ErrorField(err))
-		}
+	if err := s.storage.SaveNode(&updated); err != nil {
+		return fmt.Errorf("failed to persist node %d: %w", nodeID, err)
 	}
 
-	// Shutdown mDNS server
-	if s.mdnsServer != nil {
-		if err := s.mdnsServer.Shutdown(); err != nil {
-			s.logger.Error("Failed to shutdown mDNS server", logger.ErrorField(err))
-		}
+	s.EmitEvent(models.EventTypeNodeUpdated, &updated)
+	return nil
+}
+
+// probeNode checks whether a node responds by reading its Basic Information
+// DataModelRevision attribute. Without a controller the node's known
+// addresses are pinged instead.
+func (s *Server) probeNode(ctx context.Context, nodeID int) (bool, error) {
+	_, err := s.controller.ReadAttribute(ctx, nodeID, attributePath(0, clusters.BasicInformationClusterID, 0))
+	if !errors.Is(err, controller.ErrNotAvailable) {
+		return err == nil, nil
 	}
 
-	// Emit shutdown event
-	s.EmitEvent(models.EventTypeServerShutdown, nil)
+	s.nodesMu.RLock()
+	node, exists := s.nodes[nodeID]
+	s.nodesMu.RUnlock()
+
+	var ips []net.IP
+	if exists {
+		ips = s.nodeAddresses(node)
+	}
+	if len(ips) == 0 {
+		return false, err
+	}
 
-	s.logger.Info("Server shutdown complete")
-	return nil
+	for _, reachable := range s.pingAddresses(ctx, ips, 1) {
+		if reachable {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
 // Middleware and utilities will go in separate files
@@ -502,8 +1519,15 @@ func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 
 func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		origin := r.Header.Get("Origin")
+		switch {
+		case s.origins.any:
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		case origin != "" && s.origins.allows(origin):
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
+		}
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 		if r.Method == "OPTIONS" {
@@ -522,14 +1546,21 @@ func (s *Server) writeJSON(w http.ResponseWriter, data interface{}) {
 	}
 }
 
+// httpError is the body of HTTP error responses
+type httpError struct {
+	Error     string    `json:"error"`
+	Code      int       `json:"code"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
 func (s *Server) writeError(w http.ResponseWriter, code int, message string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
 
-	errorResponse := map[string]interface{}{
-		"error":     message,
-		"code":      code,
-		"timestamp": time.Now().UTC(),
+	errorResponse := httpError{
+		Error:     message,
+		Code:      code,
+		Timestamp: time.Now().UTC(),
 	}
 
 	if err := json.NewEncoder(w).Encode(errorResponse); err != nil {
@@ -541,36 +1572,3 @@ func (s *Server) writeError(w http.ResponseWriter, code int, message string) {
 		)
 	}
 }
-
-// parseNodeID extracts a node_id from args, accepting number or string
-func parseNodeID(args map[string]interface{}) (int, error) {
-	v, ok := args["node_id"]
-	if !ok {
-		return 0, fmt.Errorf("missing required parameter: node_id")
-	}
-	switch t := v.(type) {
-	case float64:
-		return int(t), nil
-	case int:
-		return t, nil
-	case int64:
-		return int(t), nil
-	case json.Number:
-		// If caller used Decoder.UseNumber
-		i, err := t.Int64()
-		if err != nil {
-			return 0, fmt.Errorf("invalid node_id: %v", err)
-		}
-		return int(i), nil
-	case string:
-		// Try to parse string number
-		var n json.Number = json.Number(t)
-		i, err := n.Int64()
-		if err != nil {
-			return 0, fmt.Errorf("invalid node_id: expected number, got %q", t)
-		}
-		return int(i), nil
-	default:
-		return 0, fmt.Errorf("invalid node_id: unsupported type")
-	}
-}
@@ -0,0 +1,235 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/config"
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// serverSettingPrefix namespaces the runtime settings among the stored
+// settings, so export_settings and import_settings carry them along
+const serverSettingPrefix = "server_settings."
+
+// maxFabricLabelLength is the length limit of fabric labels (Matter Core
+// Specification 11.18.4.5)
+const maxFabricLabelLength = 32
+
+// serverSetting is an option adjustable at runtime. Values are strings in the
+// format of the config file.
+type serverSetting struct {
+	name string
+	// config returns the value of the configuration
+	config   func(cfg *config.Config) string
+	validate func(value string) error
+	// apply makes the effective value take effect, nil for settings read
+	// where they are used
+	apply func(s *Server, value string)
+}
+
+var serverSettings = []serverSetting{
+	{
+		name:   "log.level",
+		config: func(cfg *config.Config) string { return cfg.Log.Level },
+		validate: func(value string) error {
+			_, err := logger.ParseLogLevel(value)
+			return err
+		},
+		apply: func(s *Server, value string) {
+			level, _ := logger.ParseLogLevel(value)
+			s.logger.SetLevel(level)
+		},
+	},
+	{
+		name:     "availability.mains_interval",
+		config:   func(cfg *config.Config) string { return cfg.Availability.MainsInterval.String() },
+		validate: validateInterval,
+		apply: func(s *Server, value string) {
+			mains, _ := time.ParseDuration(value)
+			_, battery := s.availabilityMonitor.Intervals()
+			s.availabilityMonitor.SetIntervals(mains, battery)
+		},
+	},
+	{
+		name:     "availability.battery_interval",
+		config:   func(cfg *config.Config) string { return cfg.Availability.BatteryInterval.String() },
+		validate: validateInterval,
+		apply: func(s *Server, value string) {
+			battery, _ := time.ParseDuration(value)
+			mains, _ := s.availabilityMonitor.Intervals()
+			s.availabilityMonitor.SetIntervals(mains, battery)
+		},
+	},
+	{
+		name:   "ota.provider_dir",
+		config: func(cfg *config.Config) string { return cfg.OTA.ProviderDir },
+		validate: func(value string) error {
+			if value == "" {
+				return nil
+			}
+			info, err := os.Stat(value)
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() {
+				return fmt.Errorf("%s is not a directory", value)
+			}
+			return nil
+		},
+	},
+	{
+		name:   "matter.default_fabric_label",
+		config: func(cfg *config.Config) string { return "" },
+		validate: func(value string) error {
+			if len(value) > maxFabricLabelLength {
+				return fmt.Errorf("fabric label longer than %d bytes", maxFabricLabelLength)
+			}
+			return nil
+		},
+	},
+}
+
+// validateInterval accepts durations like "1m", 0 disables the probes
+func validateInterval(value string) error {
+	interval, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	if interval < 0 {
+		return fmt.Errorf("negative interval %s", value)
+	}
+	return nil
+}
+
+func lookupServerSetting(name string) (*serverSetting, bool) {
+	for i := range serverSettings {
+		if serverSettings[i].name == name {
+			return &serverSettings[i], true
+		}
+	}
+	return nil, false
+}
+
+// serverSettingState returns the effective value of a setting. A stored
+// runtime value takes precedence over the configuration.
+func (s *Server) serverSettingState(setting *serverSetting) models.ServerSetting {
+	state := models.ServerSetting{
+		Name:        setting.name,
+		Value:       setting.config(s.config),
+		Source:      models.ServerSettingSourceConfig,
+		ConfigValue: setting.config(s.config),
+	}
+	stored, err := s.storage.GetSetting(serverSettingPrefix + setting.name)
+	if err != nil {
+		return state
+	}
+	// Imported values may be invalid
+	if value, ok := stored.(string); ok && setting.validate(value) == nil {
+		state.Value = value
+		state.Source = models.ServerSettingSourceRuntime
+	}
+	return state
+}
+
+// applyServerSetting makes the effective value of a setting take effect
+func (s *Server) applyServerSetting(setting *serverSetting) {
+	if setting.apply != nil {
+		setting.apply(s, s.serverSettingState(setting).Value.(string))
+	}
+}
+
+// applyServerSettings makes the effective values of all settings take
+// effect, after loading the storage and importing settings
+func (s *Server) applyServerSettings() {
+	s.serverSettingsMu.Lock()
+	defer s.serverSettingsMu.Unlock()
+
+	for i := range serverSettings {
+		setting := &serverSettings[i]
+		if stored, err := s.storage.GetSetting(serverSettingPrefix + setting.name); err == nil {
+			if state := s.serverSettingState(setting); state.Source != models.ServerSettingSourceRuntime {
+				s.logger.Warn("Ignoring invalid stored server setting",
+					logger.String("setting", setting.name),
+					logger.String("value", fmt.Sprint(stored)),
+				)
+			}
+		}
+		s.applyServerSetting(setting)
+	}
+}
+
+// setServerSetting stores a runtime value of a setting and applies it. A nil
+// value resets the setting to the configuration.
+func (s *Server) setServerSetting(name string, value *string) (models.ServerSetting, error) {
+	setting, ok := lookupServerSetting(name)
+	if !ok {
+		return models.ServerSetting{}, &models.ArgumentError{Field: "name", Reason: "unknown setting " + name}
+	}
+
+	s.serverSettingsMu.Lock()
+	defer s.serverSettingsMu.Unlock()
+
+	key := serverSettingPrefix + setting.name
+	if value == nil {
+		if err := s.storage.DeleteSetting(key); err != nil {
+			return models.ServerSetting{}, fmt.Errorf("failed to reset setting %s: %w", name, err)
+		}
+	} else {
+		if err := setting.validate(*value); err != nil {
+			return models.ServerSetting{}, &models.ArgumentError{Field: "value", Reason: err.Error()}
+		}
+		if err := s.storage.SaveSetting(key, *value); err != nil {
+			return models.ServerSetting{}, fmt.Errorf("failed to store setting %s: %w", name, err)
+		}
+	}
+	s.applyServerSetting(setting)
+
+	state := s.serverSettingState(setting)
+	s.logger.Info("Server setting changed",
+		logger.String("setting", name),
+		logger.String("value", state.Value.(string)),
+		logger.String("source", state.Source),
+	)
+	return state, nil
+}
+
+func (s *Server) handleGetServerSettings() (interface{}, error) {
+	result := make([]models.ServerSetting, 0, len(serverSettings))
+	for i := range serverSettings {
+		result = append(result, s.serverSettingState(&serverSettings[i]))
+	}
+	return result, nil
+}
+
+func (s *Server) handleSetServerSetting(args commandArgs) (interface{}, error) {
+	var value *string
+	switch v := args["value"].(type) {
+	case nil:
+	case string:
+		value = &v
+	default:
+		return nil, &models.ArgumentError{Field: "value", Reason: "expected string or null"}
+	}
+	return s.setServerSetting(args.str("name"), value)
+}
+
+// handleSetDefaultFabricLabel sets the label of the fabric given to
+// commissioned nodes, like python-matter-server. A null or empty label
+// resets it.
+func (s *Server) handleSetDefaultFabricLabel(args commandArgs) (interface{}, error) {
+	label, ok := args["label"].(string)
+	if !ok && args["label"] != nil {
+		return nil, &models.ArgumentError{Field: "label", Reason: "expected string or null"}
+	}
+	var value *string
+	if label != "" {
+		value = &label
+	}
+	if _, err := s.setServerSetting("matter.default_fabric_label", value); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
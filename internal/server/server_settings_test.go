@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// getServerSetting returns a setting as listed by get_server_settings
+func getServerSetting(t *testing.T, server *Server, name string) models.ServerSetting {
+	t.Helper()
+	result, err := server.HandleCommand(context.Background(), models.CommandMessage{
+		Command: string(models.APICommandGetServerSettings),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, setting := range result.([]models.ServerSetting) {
+		if setting.Name == name {
+			return setting
+		}
+	}
+	t.Fatalf("Setting %s not listed", name)
+	return models.ServerSetting{}
+}
+
+func setServerSetting(server *Server, name string, value interface{}) (interface{}, error) {
+	return server.HandleCommand(context.Background(), models.CommandMessage{
+		Command: string(models.APICommandSetServerSetting),
+		Args:    map[string]interface{}{"name": name, "value": value},
+	})
+}
+
+func TestSetServerSetting(t *testing.T) {
+	server := createTestServer(t)
+	server.config.Log.Level = "error"
+
+	if setting := getServerSetting(t, server, "log.level"); setting.Value != "error" || setting.Source != models.ServerSettingSourceConfig {
+		t.Errorf("Expected the configured level, got %+v", setting)
+	}
+
+	result, err := setServerSetting(server, "log.level", "debug")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if setting := result.(models.ServerSetting); setting.Value != "debug" || setting.Source != models.ServerSettingSourceRuntime || setting.ConfigValue != "error" {
+		t.Errorf("Expected the runtime level, got %+v", setting)
+	}
+	if server.logger.GetLevel() != logger.DebugLevel {
+		t.Errorf("Expected debug logging, got %v", server.logger.GetLevel())
+	}
+	if stored, _ := server.storage.GetSetting("server_settings.log.level"); stored != "debug" {
+		t.Errorf("Expected the level to be stored, got %v", stored)
+	}
+
+	if _, err := setServerSetting(server, "availability.battery_interval", "2h"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, battery := server.availabilityMonitor.Intervals(); battery != 2*time.Hour {
+		t.Errorf("Expected battery interval 2h, got %v", battery)
+	}
+
+	// Null resets to the configuration
+	if _, err := setServerSetting(server, "log.level", nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if setting := getServerSetting(t, server, "log.level"); setting.Value != "error" || setting.Source != models.ServerSettingSourceConfig {
+		t.Errorf("Expected the configured level again, got %+v", setting)
+	}
+	if server.logger.GetLevel() != logger.ErrorLevel {
+		t.Errorf("Expected error logging, got %v", server.logger.GetLevel())
+	}
+
+	for _, tt := range []struct {
+		name  string
+		value interface{}
+		field string
+	}{
+		{"log.colors", "on", "name"},
+		{"log.level", "loud", "value"},
+		{"log.level", float64(1), "value"},
+		{"availability.mains_interval", "-1m", "value"},
+		{"ota.provider_dir", "/nonexistent/ota", "value"},
+		{"matter.default_fabric_label", "a label longer than thirty-two bytes", "value"},
+	} {
+		_, err := setServerSetting(server, tt.name, tt.value)
+		var argErr *models.ArgumentError
+		if !errors.As(err, &argErr) || argErr.Field != tt.field {
+			t.Errorf("Expected argument error for %s of %s = %v, got %v", tt.field, tt.name, tt.value, err)
+		}
+	}
+}
+
+func TestSetDefaultFabricLabel(t *testing.T) {
+	server := createTestServer(t)
+
+	if _, err := server.HandleCommand(context.Background(), models.CommandMessage{
+		Command: string(models.APICommandSetDefaultFabricLabel),
+		Args:    map[string]interface{}{"label": "Home"},
+	}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if setting := getServerSetting(t, server, "matter.default_fabric_label"); setting.Value != "Home" {
+		t.Errorf("Expected label Home, got %+v", setting)
+	}
+
+	if _, err := server.HandleCommand(context.Background(), models.CommandMessage{
+		Command: string(models.APICommandSetDefaultFabricLabel),
+		Args:    map[string]interface{}{"label": nil},
+	}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if setting := getServerSetting(t, server, "matter.default_fabric_label"); setting.Value != "" || setting.Source != models.ServerSettingSourceConfig {
+		t.Errorf("Expected label reset, got %+v", setting)
+	}
+}
+
+func TestApplyStoredServerSettings(t *testing.T) {
+	server := createTestServer(t)
+	server.storage.SaveSetting("server_settings.log.level", "trace")
+	server.storage.SaveSetting("server_settings.availability.mains_interval", "1 minute")
+
+	server.applyServerSettings()
+
+	if server.logger.GetLevel() != logger.TraceLevel {
+		t.Errorf("Expected the stored level, got %v", server.logger.GetLevel())
+	}
+	// Invalid stored values are ignored
+	if setting := getServerSetting(t, server, "availability.mains_interval"); setting.Source != models.ServerSettingSourceConfig {
+		t.Errorf("Expected the configured interval, got %+v", setting)
+	}
+}
@@ -3,12 +3,17 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/codefionn/go-matter-server/internal/config"
+	"github.com/codefionn/go-matter-server/internal/controller"
 	"github.com/codefionn/go-matter-server/internal/logger"
 	"github.com/codefionn/go-matter-server/internal/models"
 )
@@ -79,6 +84,9 @@ func TestServerInfo(t *testing.T) {
 	if info.SchemaVersion == 0 {
 		t.Error("SchemaVersion should not be zero")
 	}
+	if info.CompressedFabricID != server.credentials.CompressedFabricID() {
+		t.Errorf("Expected CompressedFabricID %d, got %d", server.credentials.CompressedFabricID(), info.CompressedFabricID)
+	}
 	// In tests we disable Bluetooth via config, so reported availability must be false
 	if info.BluetoothEnabled {
 		t.Errorf("Expected BluetoothEnabled false when disabled in config, got true")
@@ -148,9 +156,9 @@ func TestHTTPHealthEndpoint(t *testing.T) {
 		t.Errorf("Expected status 'ok', got %v", response["status"])
 	}
 
-	// Check CORS headers
-	if w.Header().Get("Access-Control-Allow-Origin") != "*" {
-		t.Error("CORS header not set correctly")
+	// No origins are allowed by default
+	if w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Error("Expected no CORS origin header by default")
 	}
 }
 
@@ -177,6 +185,38 @@ func TestHTTPInfoEndpoint(t *testing.T) {
 	}
 }
 
+func TestHTTPAddonInfoEndpoint(t *testing.T) {
+	server := createTestServer(t)
+	router := server.setupRouter()
+
+	req := httptest.NewRequest("GET", "/api/addon-info", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var info models.AddonInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if info.SchemaVersion != models.SchemaVersion || info.WebSocketPath != "/ws" || info.Port != server.config.Server.Port {
+		t.Errorf("Unexpected connection details %+v", info)
+	}
+	if info.TLS || info.Features.OTA || info.Features.Thread {
+		t.Errorf("Expected no TLS, OTA or Thread, got %+v", info)
+	}
+
+	// The runtime setting of the OTA provider directory counts
+	dir := t.TempDir()
+	if _, err := server.setServerSetting("ota.provider_dir", &dir); err != nil {
+		t.Fatal(err)
+	}
+	if !server.addonInfo().Features.OTA {
+		t.Error("Expected OTA with a provider directory")
+	}
+}
+
 func TestHTTPNodesEndpoint(t *testing.T) {
 	server := createTestServer(t)
 	router := server.setupRouter()
@@ -223,26 +263,74 @@ func TestHTTPDiagnosticsEndpoint(t *testing.T) {
 		t.Errorf("Expected FabricID %d in diagnostics, got %d",
 			server.config.Matter.FabricID, diagnostics.Info.FabricID)
 	}
+
+	if diagnostics.WebSocket == nil || diagnostics.WebSocket.Connections != 0 {
+		t.Errorf("Expected WebSocket stats without connections, got %+v", diagnostics.WebSocket)
+	}
+
+	// There's no BlueZ in tests
+	if diagnostics.Bluetooth == nil || diagnostics.Bluetooth.Available || diagnostics.Bluetooth.Reason == "" {
+		t.Errorf("Expected unavailable Bluetooth with a reason, got %+v", diagnostics.Bluetooth)
+	}
 }
 
-func TestCORSHeaders(t *testing.T) {
+func TestHTTPSessionsEndpoint(t *testing.T) {
 	server := createTestServer(t)
 	router := server.setupRouter()
 
-	// Test CORS headers are set on regular requests
-	req := httptest.NewRequest("GET", "/api/info", nil)
-	req.Header.Set("Origin", "https://example.com")
-
+	req := httptest.NewRequest("GET", "/api/sessions", nil)
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
 	if w.Code != http.StatusOK {
 		t.Errorf("Expected status 200, got %d", w.Code)
 	}
+	var sessions []models.SessionInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &sessions); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if sessions == nil || len(sessions) != 0 {
+		t.Errorf("Expected empty session list, got %s", w.Body.String())
+	}
 
-	// Check CORS headers
-	if w.Header().Get("Access-Control-Allow-Origin") != "*" {
-		t.Error("CORS origin header not set correctly")
+	req = httptest.NewRequest("DELETE", "/api/sessions/unknown", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestCORSHeaders(t *testing.T) {
+	server := createTestServer(t)
+	server.origins = newOriginPolicy([]string{"https://example.com"})
+	router := server.setupRouter()
+
+	tests := []struct {
+		origin string
+		want   string
+	}{
+		{origin: "https://example.com", want: "https://example.com"},
+		{origin: "https://evil.example", want: ""},
+		{origin: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/info", nil)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", w.Code)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.want {
+			t.Errorf("Origin %q: expected CORS origin %q, got %q", tt.origin, tt.want, got)
+		}
 	}
 }
 
@@ -329,6 +417,7 @@ func TestInternalNodeStorage(t *testing.T) {
 
 func TestCORSMiddleware(t *testing.T) {
 	server := createTestServer(t)
+	server.origins = newOriginPolicy([]string{"*"})
 
 	// Test that CORS middleware is applied
 	handler := server.corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -418,3 +507,538 @@ func TestWebSocketEndpoint(t *testing.T) {
 		t.Error("WebSocket endpoint should exist at /ws")
 	}
 }
+
+// fakeController records device interactions for tests
+type fakeController struct {
+	controller.Unavailable
+	commands []controller.CommandRequest
+}
+
+func (f *fakeController) SendCommand(ctx context.Context, req controller.CommandRequest) (interface{}, error) {
+	f.commands = append(f.commands, req)
+	return map[string]interface{}{"status": 0}, nil
+}
+
+func TestDeviceCommand(t *testing.T) {
+	server := createTestServer(t)
+	fake := &fakeController{}
+	server.controller = fake
+	server.nodes[5] = &models.MatterNodeData{NodeID: 5, Attributes: map[string]interface{}{}}
+
+	tests := []struct {
+		name        string
+		args        map[string]interface{}
+		wantCluster uint32
+		wantCommand uint32
+		wantTimed   time.Duration
+		expectError bool
+	}{
+		{
+			name:        "Numeric IDs",
+			args:        map[string]interface{}{"node_id": float64(5), "endpoint_id": float64(1), "cluster_id": float64(6), "command_name": float64(2)},
+			wantCluster: 6,
+			wantCommand: 2,
+		},
+		{
+			name:        "Names",
+			args:        map[string]interface{}{"node_id": float64(5), "endpoint_id": float64(1), "cluster": "LevelControl", "command_name": "MoveToLevelWithOnOff", "payload": map[string]interface{}{"level": float64(128)}},
+			wantCluster: 8,
+			wantCommand: 4,
+		},
+		{
+			name:        "Unknown command name",
+			args:        map[string]interface{}{"node_id": float64(5), "endpoint_id": float64(1), "cluster_id": "OnOff", "command_name": "Explode"},
+			expectError: true,
+		},
+		{
+			name:        "Unknown node",
+			args:        map[string]interface{}{"node_id": float64(9), "endpoint_id": float64(1), "cluster_id": float64(6), "command_name": "On"},
+			expectError: true,
+		},
+		{
+			name:        "Missing endpoint",
+			args:        map[string]interface{}{"node_id": float64(5), "cluster_id": float64(6), "command_name": "On"},
+			expectError: true,
+		},
+		{
+			name:        "Timed request",
+			args:        map[string]interface{}{"node_id": float64(5), "endpoint_id": float64(1), "cluster": "DoorLock", "command_name": "UnlockDoor", "timed_request_timeout_ms": float64(1000)},
+			wantCluster: 0x0101,
+			wantCommand: 1,
+			wantTimed:   time.Second,
+		},
+		{
+			name:        "Invalid timed request timeout",
+			args:        map[string]interface{}{"node_id": float64(5), "endpoint_id": float64(1), "cluster": "DoorLock", "command_name": "UnlockDoor", "timed_request_timeout_ms": float64(70000)},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake.commands = nil
+			_, err := server.HandleCommand(context.Background(), models.CommandMessage{
+				MessageID: "cmd",
+				Command:   string(models.APICommandDeviceCommand),
+				Args:      tt.args,
+			})
+
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(fake.commands) != 1 {
+				t.Fatalf("Expected 1 command sent, got %d", len(fake.commands))
+			}
+			req := fake.commands[0]
+			if req.ClusterID != tt.wantCluster || req.CommandID != tt.wantCommand {
+				t.Errorf("Expected cluster %d command %d, got cluster %d command %d",
+					tt.wantCluster, tt.wantCommand, req.ClusterID, req.CommandID)
+			}
+			if req.TimedRequestTimeout != tt.wantTimed {
+				t.Errorf("Expected timed request timeout %s, got %s", tt.wantTimed, req.TimedRequestTimeout)
+			}
+		})
+	}
+}
+
+// writeController records attribute writes
+type writeController struct {
+	fakeController
+	writes []controller.WriteRequest
+}
+
+func (c *writeController) WriteAttribute(ctx context.Context, req controller.WriteRequest) error {
+	c.writes = append(c.writes, req)
+	return nil
+}
+
+func TestWriteAttribute(t *testing.T) {
+	server := createTestServer(t)
+	fake := &writeController{}
+	server.controller = fake
+	server.nodes[5] = &models.MatterNodeData{NodeID: 5, Attributes: map[string]interface{}{}}
+
+	tests := []struct {
+		name        string
+		args        map[string]interface{}
+		wantTimed   time.Duration
+		expectError bool
+	}{
+		{
+			name: "Plain write",
+			args: map[string]interface{}{"node_id": float64(5), "attribute_path": "1/6/16387", "value": float64(1)},
+		},
+		{
+			name:      "Timed write",
+			args:      map[string]interface{}{"node_id": float64(5), "attribute_path": "1/257/35", "value": float64(10), "timed_request_timeout_ms": float64(250)},
+			wantTimed: 250 * time.Millisecond,
+		},
+		{
+			name:        "Invalid path",
+			args:        map[string]interface{}{"node_id": float64(5), "attribute_path": "1/6", "value": float64(1)},
+			expectError: true,
+		},
+		{
+			name:        "Missing value",
+			args:        map[string]interface{}{"node_id": float64(5), "attribute_path": "1/6/16387"},
+			expectError: true,
+		},
+		{
+			name:        "Unknown node",
+			args:        map[string]interface{}{"node_id": float64(9), "attribute_path": "1/6/16387", "value": float64(1)},
+			expectError: true,
+		},
+		{
+			name:        "Fractional timeout",
+			args:        map[string]interface{}{"node_id": float64(5), "attribute_path": "1/6/16387", "value": float64(1), "timed_request_timeout_ms": 1.5},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake.writes = nil
+			_, err := server.HandleCommand(context.Background(), models.CommandMessage{
+				Command: string(models.APICommandWriteAttribute),
+				Args:    tt.args,
+			})
+
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(fake.writes) != 1 {
+				t.Fatalf("Expected 1 write, got %d", len(fake.writes))
+			}
+			if fake.writes[0].Path != tt.args["attribute_path"] || fake.writes[0].TimedRequestTimeout != tt.wantTimed {
+				t.Errorf("Unexpected write %+v", fake.writes[0])
+			}
+		})
+	}
+}
+
+// rawController exchanges TLV encoded values of manufacturer-specific
+// clusters
+type rawController struct {
+	writeController
+}
+
+func (c *rawController) SendCommand(ctx context.Context, req controller.CommandRequest) (interface{}, error) {
+	c.commands = append(c.commands, req)
+	if req.RawResponse {
+		return []byte{0x15, 0x24, 0x00, 0x01, 0x18}, nil
+	}
+	return map[string]interface{}{"0": float64(1)}, nil
+}
+
+func (c *rawController) ReadAttributeRaw(ctx context.Context, nodeID int, path string) ([]byte, error) {
+	if path != "1/4294048800/4293984257" {
+		return nil, errors.New("unexpected path " + path)
+	}
+	return []byte{0x24, 0x00, 0x2A}, nil
+}
+
+func TestManufacturerSpecificCluster(t *testing.T) {
+	dir := t.TempDir()
+	descriptor := `{"id": "0xFFF1FC20", "name": "TestVendorMeter", "attributes": {"0xFFF10001": "Power"}, "commands": {"0x01": "Reset"}}`
+	if err := os.WriteFile(filepath.Join(dir, "meter.json"), []byte(descriptor), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := *createTestServer(t).config
+	cfg.Matter.ClusterDescriptorDir = dir
+	server, err := New(&cfg, logger.NewConsoleLogger(logger.ErrorLevel))
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	fake := &rawController{}
+	server.controller = fake
+	server.nodes[5] = &models.MatterNodeData{NodeID: 5, Attributes: map[string]interface{}{}}
+
+	// Command by name with TLV fields and response
+	result := runCommand(t, server, models.APICommandDeviceCommand, map[string]interface{}{
+		"node_id": float64(5), "endpoint_id": float64(1), "cluster_id": "test_vendor_meter", "command_name": "Reset",
+		"payload_tlv": "15 24 00 01 18", "raw_response": true,
+	})
+	if result != "1524000118" {
+		t.Errorf("Expected the hex encoded response, got %v", result)
+	}
+	req := fake.commands[0]
+	if req.ClusterID != 0xFFF1FC20 || req.CommandID != 0x01 || len(req.RawPayload) != 5 || !req.RawResponse {
+		t.Errorf("Unexpected command %+v", req)
+	}
+
+	result = runCommand(t, server, models.APICommandReadAttribute, map[string]interface{}{
+		"node_id": float64(5), "attribute_path": "1/TestVendorMeter/Power", "raw": true,
+	})
+	if result != "24002a" {
+		t.Errorf("Expected the hex encoded value, got %v", result)
+	}
+
+	runCommand(t, server, models.APICommandWriteAttribute, map[string]interface{}{
+		"node_id": float64(5), "attribute_path": "1/0xFFF1FC20/0xFFF10001", "value_tlv": "24002a",
+	})
+	if len(fake.writes) != 1 || fake.writes[0].Path != "1/4294048800/4293984257" || len(fake.writes[0].RawValue) != 3 {
+		t.Errorf("Unexpected writes %+v", fake.writes)
+	}
+
+	for name, args := range map[string]map[string]interface{}{
+		"Invalid TLV":      {"node_id": float64(5), "attribute_path": "1/TestVendorMeter/Power", "value_tlv": "2a"},
+		"Value and TLV":    {"node_id": float64(5), "attribute_path": "1/TestVendorMeter/Power", "value": float64(1), "value_tlv": "24002a"},
+		"Unknown name":     {"node_id": float64(5), "attribute_path": "1/TestVendorMeter/Voltage", "value": float64(1)},
+		"Missing value":    {"node_id": float64(5), "attribute_path": "1/TestVendorMeter/Power"},
+		"Not hex":          {"node_id": float64(5), "attribute_path": "1/TestVendorMeter/Power", "value_tlv": "zz"},
+		"Invalid endpoint": {"node_id": float64(5), "attribute_path": "x/TestVendorMeter/Power", "value": float64(1)},
+	} {
+		_, err := server.HandleCommand(context.Background(), models.CommandMessage{
+			Command: string(models.APICommandWriteAttribute),
+			Args:    args,
+		})
+		var argErr *models.ArgumentError
+		if !errors.As(err, &argErr) {
+			t.Errorf("%s: expected an argument error, got %v", name, err)
+		}
+	}
+
+	// Raw reads need a controller returning values as received
+	server.controller = &fakeController{}
+	_, err = server.HandleCommand(context.Background(), models.CommandMessage{
+		Command: string(models.APICommandReadAttribute),
+		Args:    map[string]interface{}{"node_id": float64(5), "attribute_path": "1/TestVendorMeter/Power", "raw": true},
+	})
+	if !errors.Is(err, controller.ErrNotAvailable) {
+		t.Errorf("Expected ErrNotAvailable, got %v", err)
+	}
+
+	cfg.Matter.ClusterDescriptorDir = filepath.Join(dir, "missing")
+	if _, err := New(&cfg, logger.NewConsoleLogger(logger.ErrorLevel)); err == nil {
+		t.Error("Expected error for a missing descriptor directory")
+	}
+}
+
+func TestDeviceCommandWithoutController(t *testing.T) {
+	server := createTestServer(t)
+	server.nodes[5] = &models.MatterNodeData{NodeID: 5}
+
+	_, err := server.HandleCommand(context.Background(), models.CommandMessage{
+		Command: string(models.APICommandDeviceCommand),
+		Args:    map[string]interface{}{"node_id": float64(5), "endpoint_id": float64(1), "cluster_id": "OnOff", "command_name": "On"},
+	})
+	if !errors.Is(err, controller.ErrNotAvailable) {
+		t.Errorf("Expected ErrNotAvailable, got %v", err)
+	}
+}
+
+func TestGetNodeAnnotated(t *testing.T) {
+	server := createTestServer(t)
+	server.nodes[5] = &models.MatterNodeData{
+		NodeID:     5,
+		Attributes: map[string]interface{}{"1/6/0": true},
+	}
+
+	result, err := server.HandleCommand(context.Background(), models.CommandMessage{
+		Command: string(models.APICommandGetNode),
+		Args:    map[string]interface{}{"node_id": float64(5), "annotate": true},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	node := result.(*models.MatterNodeData)
+	if node.AttributeNames["1/6/0"] != "OnOff.OnOff" {
+		t.Errorf("Expected OnOff.OnOff, got %q", node.AttributeNames["1/6/0"])
+	}
+	if server.nodes[5].AttributeNames != nil {
+		t.Error("Expected stored node to remain unannotated")
+	}
+
+	req := httptest.NewRequest("GET", "/api/nodes?annotate=true", nil)
+	w := httptest.NewRecorder()
+	server.setupRouter().ServeHTTP(w, req)
+
+	var nodes []*models.MatterNodeData
+	if err := json.Unmarshal(w.Body.Bytes(), &nodes); err != nil {
+		t.Fatalf("Failed to parse nodes response: %v", err)
+	}
+	if len(nodes) != 1 || nodes[0].AttributeNames["1/6/0"] != "OnOff.OnOff" {
+		t.Errorf("Expected annotated node over HTTP, got %+v", nodes)
+	}
+	if server.nodes[5].AttributeNames != nil {
+		t.Error("Expected stored node to remain unannotated after get_nodes")
+	}
+}
+
+func TestNodeSnapshotShared(t *testing.T) {
+	server := createTestServer(t)
+	server.nodes[1] = &models.MatterNodeData{NodeID: 1, Attributes: map[string]interface{}{"1/6/0": false}}
+	server.nodes[2] = &models.MatterNodeData{NodeID: 2, Attributes: map[string]interface{}{}}
+	unchanged := server.nodes[2]
+
+	before := runCommand(t, server, models.APICommandGetNodes, nil).([]*models.MatterNodeData)
+	if err := server.updateNodeAttributes(1, map[string]interface{}{"1/6/0": true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Updates replace the node instead of changing it
+	for _, node := range before {
+		if node.NodeID == 1 && node.Attributes["1/6/0"] != false {
+			t.Errorf("Expected the earlier snapshot unchanged, got %v", node.Attributes)
+		}
+		if node.NodeID == 2 && node != unchanged {
+			t.Error("Expected the snapshot to share the stored node")
+		}
+	}
+	if server.nodes[1].Attributes["1/6/0"] != true {
+		t.Errorf("Expected the update stored, got %v", server.nodes[1].Attributes)
+	}
+}
+
+func TestSetNodeAvailable(t *testing.T) {
+	server := createTestServer(t)
+	server.nodes[3] = &models.MatterNodeData{NodeID: 3, Available: true}
+
+	updates := make(chan *models.MatterNodeData, 2)
+	server.Subscribe(func(eventType models.EventType, data interface{}) {
+		if eventType == models.EventTypeNodeUpdated {
+			updates <- data.(*models.MatterNodeData)
+		}
+	})
+
+	if err := server.setNodeAvailable(3, false); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if server.nodes[3].Available {
+		t.Error("Expected node to be unavailable")
+	}
+
+	select {
+	case node := <-updates:
+		if node.Available {
+			t.Error("Expected node_updated with available=false")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected node_updated event")
+	}
+
+	stored, err := server.storage.GetNode(3)
+	if err != nil || stored.Available {
+		t.Errorf("Expected persisted unavailable node, got %+v (%v)", stored, err)
+	}
+
+	if err := server.setNodeAvailable(42, true); err == nil {
+		t.Error("Expected error for unknown node")
+	}
+}
+
+func TestProbeNodeWithoutController(t *testing.T) {
+	server := createTestServer(t)
+
+	if _, err := server.probeNode(context.Background(), 1); !errors.Is(err, controller.ErrNotAvailable) {
+		t.Errorf("Expected ErrNotAvailable, got %v", err)
+	}
+}
+
+func networkInterfacesNode(nodeID int) *models.MatterNodeData {
+	return &models.MatterNodeData{
+		NodeID: nodeID,
+		Attributes: map[string]interface{}{
+			"0/51/0": []interface{}{
+				map[string]interface{}{
+					"0": "eth0",
+					"5": []interface{}{"wKgBCg==", "fwAAAQ=="},                // 192.168.1.10, 127.0.0.1
+					"6": []interface{}{"/oAAAAAAAAACEjT//lZ4mg==", "invalid"}, // fe80::212:34ff:fe56:789a
+				},
+			},
+		},
+	}
+}
+
+func TestGetNodeIPAddresses(t *testing.T) {
+	server := createTestServer(t)
+	server.config.Network.PrimaryInterface = "eth0"
+	server.nodes[4] = networkInterfacesNode(4)
+
+	result, err := server.HandleCommand(context.Background(), models.CommandMessage{
+		Command: string(models.APICommandGetNodeIPAddresses),
+		Args:    map[string]interface{}{"node_id": float64(4), "scoped": true},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	addrs := result.([]string)
+	expected := []string{"127.0.0.1", "192.168.1.10", "fe80::212:34ff:fe56:789a%eth0"}
+	if len(addrs) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, addrs)
+	}
+	for i := range expected {
+		if addrs[i] != expected[i] {
+			t.Errorf("Expected %s, got %s", expected[i], addrs[i])
+		}
+	}
+}
+
+func TestGetNetworkInfo(t *testing.T) {
+	server := createTestServer(t)
+
+	result, err := server.HandleCommand(context.Background(), models.CommandMessage{
+		Command: string(models.APICommandGetNetworkInfo),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The chosen interface depends on the environment, loopback is never
+	// chosen
+	info := result.(models.NetworkInfo)
+	if info.Source != models.NetworkSourceAuto && info.Source != models.NetworkSourceNone || info.Reason == "" {
+		t.Errorf("Expected an automatic selection with reason, got %+v", info)
+	}
+	for _, iface := range info.Interfaces {
+		if iface.Name == info.PrimaryInterface && iface.Skipped != "" {
+			t.Errorf("Expected a usable interface, got %+v", iface)
+		}
+	}
+	if server.primaryInterface() != info.PrimaryInterface {
+		t.Errorf("Expected primary interface %q, got %q", info.PrimaryInterface, server.primaryInterface())
+	}
+
+	// A configured interface takes precedence
+	server.config.Network.PrimaryInterface = "eth7"
+	if server.primaryInterface() != "eth7" {
+		t.Errorf("Expected configured interface, got %q", server.primaryInterface())
+	}
+}
+
+func TestPingNodeReportsPerAddress(t *testing.T) {
+	server := createTestServer(t)
+	server.nodes[4] = networkInterfacesNode(4)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	result, err := server.HandleCommand(ctx, models.CommandMessage{
+		Command: string(models.APICommandPingNode),
+		Args:    map[string]interface{}{"node_id": float64(4)},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Reachability depends on the environment, only the reported addresses
+	// are deterministic
+	pingResult := result.(models.NodePingResult)
+	for _, addr := range []string{"127.0.0.1", "192.168.1.10", "fe80::212:34ff:fe56:789a"} {
+		if _, ok := pingResult[addr]; !ok {
+			t.Errorf("Expected ping result for %s, got %v", addr, pingResult)
+		}
+	}
+	if len(pingResult) != 3 {
+		t.Errorf("Expected 3 addresses, got %d", len(pingResult))
+	}
+
+	if _, err := server.HandleCommand(ctx, models.CommandMessage{
+		Command: string(models.APICommandPingNode),
+		Args:    map[string]interface{}{"node_id": float64(99)},
+	}); err == nil {
+		t.Error("Expected error for unknown node")
+	}
+}
+
+func TestDashboardRoute(t *testing.T) {
+	server := createTestServer(t)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	server.setupRouter().ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected dashboard to be disabled by default, got %d", w.Code)
+	}
+
+	server.config.Server.ServeStatic = true
+	router := server.setupRouter()
+
+	req = httptest.NewRequest("GET", "/", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Errorf("Expected dashboard page, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	// API routes take precedence over the dashboard
+	req = httptest.NewRequest("GET", "/api/info", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected server info, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+}
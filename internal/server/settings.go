@@ -0,0 +1,172 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// exportSettings dumps the stored nodes, vendors and settings
+func (s *Server) exportSettings() (*models.SettingsExport, error) {
+	nodes, err := s.storage.GetNodes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read nodes: %w", err)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].NodeID < nodes[j].NodeID
+	})
+
+	vendors, err := s.storage.GetVendors()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vendors: %w", err)
+	}
+	sort.Slice(vendors, func(i, j int) bool {
+		return vendors[i].VendorID < vendors[j].VendorID
+	})
+
+	settings, err := s.storage.GetSettings()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read settings: %w", err)
+	}
+
+	return &models.SettingsExport{
+		FormatVersion: models.SettingsExportVersion,
+		ExportedAt:    time.Now().UTC(),
+		ServerVersion: Version,
+		Nodes:         nodes,
+		Vendors:       vendors,
+		Settings:      settings,
+	}, nil
+}
+
+// handleImportSettings stores the contents of an export. Existing entries
+// are overwritten; with replace, nodes missing from the export are removed.
+func (s *Server) handleImportSettings(args commandArgs) (interface{}, error) {
+	data, err := decodeSettingsExport(args)
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range data.Nodes {
+		if _, ok := s.fabric(node.FabricID); !ok {
+			return nil, &models.ArgumentError{
+				Field:  "nodes",
+				Reason: fmt.Sprintf("node %d belongs to fabric %d, which is not operated by the server", node.NodeID, node.FabricID),
+			}
+		}
+	}
+
+	for key, value := range data.Settings {
+		if err := s.storage.SaveSetting(key, value); err != nil {
+			return nil, fmt.Errorf("failed to import setting %s: %w", key, err)
+		}
+	}
+	if err := s.storage.SaveVendors(data.Vendors); err != nil {
+		return nil, fmt.Errorf("failed to import vendors: %w", err)
+	}
+
+	imported := make(map[int]bool, len(data.Nodes))
+	for _, node := range data.Nodes {
+		// Bridged endpoints are derived from the attributes
+		node.BridgedEndpoints = nil
+		if err := s.applyNodeUpdate(node); err != nil {
+			return nil, err
+		}
+		imported[node.NodeID] = true
+	}
+
+	result := &models.SettingsImportResult{
+		Nodes:        len(data.Nodes),
+		Vendors:      len(data.Vendors),
+		Settings:     len(data.Settings),
+		RemovedNodes: []int{},
+	}
+	if args.boolean("replace") {
+		for _, node := range s.nodeSnapshot() {
+			if imported[node.NodeID] {
+				continue
+			}
+			if err := s.removeNode(node.NodeID); err != nil {
+				return nil, err
+			}
+			result.RemovedNodes = append(result.RemovedNodes, node.NodeID)
+		}
+		sort.Ints(result.RemovedNodes)
+	}
+
+	if err := s.storage.Sync(); err != nil {
+		return nil, fmt.Errorf("failed to sync storage: %w", err)
+	}
+	s.applyServerSettings()
+
+	s.logger.Info("Settings imported",
+		logger.Int("nodes", result.Nodes),
+		logger.Int("vendors", result.Vendors),
+		logger.Int("settings", result.Settings),
+		logger.Int("removed_nodes", len(result.RemovedNodes)),
+	)
+	return result, nil
+}
+
+// decodeSettingsExport converts validated import_settings arguments into an
+// export document, reporting malformed entries as argument errors
+func decodeSettingsExport(args commandArgs) (*models.SettingsExport, error) {
+	raw, err := json.Marshal(args)
+	if err != nil {
+		return nil, err
+	}
+
+	var data models.SettingsExport
+	if err := json.Unmarshal(raw, &data); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, &models.ArgumentError{Field: typeErr.Field, Reason: "expected " + typeErr.Type.String()}
+		}
+		return nil, &models.ArgumentError{Field: "nodes", Reason: err.Error()}
+	}
+
+	for _, node := range data.Nodes {
+		if node == nil || node.NodeID <= 0 {
+			return nil, &models.ArgumentError{Field: "nodes", Reason: "every node needs a positive node_id"}
+		}
+	}
+	for _, vendor := range data.Vendors {
+		if vendor == nil || vendor.VendorID <= 0 {
+			return nil, &models.ArgumentError{Field: "vendors", Reason: "every vendor needs a positive vendor_id"}
+		}
+	}
+	return &data, nil
+}
+
+// removeNode forgets a node and emits node_removed
+func (s *Server) removeNode(nodeID int) error {
+	s.nodesMu.Lock()
+	delete(s.nodes, nodeID)
+	s.nodesMu.Unlock()
+	s.unsubscribeNode(nodeID)
+
+	if err := s.storage.DeleteNode(nodeID); err != nil {
+		return fmt.Errorf("failed to remove node %d: %w", nodeID, err)
+	}
+
+	s.EmitEvent(models.EventTypeNodeRemoved, nodeID)
+	return nil
+}
+
+// handleSettingsExportHTTP downloads the export as a file
+func (s *Server) handleSettingsExportHTTP(w http.ResponseWriter, r *http.Request) {
+	data, err := s.exportSettings()
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	filename := fmt.Sprintf("matter-server-settings-%s.json", data.ExportedAt.Format("20060102-150405"))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	s.writeJSON(w, data)
+}
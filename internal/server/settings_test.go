@@ -0,0 +1,123 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+func TestSettingsExportImport(t *testing.T) {
+	source := createTestServer(t)
+	for _, id := range []int{2, 1} {
+		node := &models.MatterNodeData{NodeID: id, Attributes: map[string]interface{}{"0/40/5": "Lamp"}}
+		source.nodes[id] = node
+		if err := source.storage.SaveNode(node); err != nil {
+			t.Fatalf("Failed to save node: %v", err)
+		}
+	}
+	if err := source.storage.SaveVendor(&models.VendorInfo{VendorID: 4937, VendorName: "Acme"}); err != nil {
+		t.Fatalf("Failed to save vendor: %v", err)
+	}
+	if err := source.storage.SaveSetting("last_node_id", float64(2)); err != nil {
+		t.Fatalf("Failed to save setting: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	source.setupRouter().ServeHTTP(w, httptest.NewRequest("GET", "/api/settings/export", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if disposition := w.Header().Get("Content-Disposition"); !strings.HasPrefix(disposition, "attachment") {
+		t.Errorf("Expected attachment, got %q", disposition)
+	}
+
+	var export models.SettingsExport
+	if err := json.Unmarshal(w.Body.Bytes(), &export); err != nil {
+		t.Fatalf("Failed to decode export: %v", err)
+	}
+	if export.FormatVersion != models.SettingsExportVersion || len(export.Nodes) != 2 || export.Nodes[0].NodeID != 1 {
+		t.Errorf("Expected both nodes sorted by ID, got %+v", export)
+	}
+
+	// Import into a server knowing another node, replacing it
+	dest := createTestServer(t)
+	stale := &models.MatterNodeData{NodeID: 3, Attributes: map[string]interface{}{}}
+	dest.nodes[3] = stale
+	if err := dest.storage.SaveNode(stale); err != nil {
+		t.Fatalf("Failed to save node: %v", err)
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode export: %v", err)
+	}
+	body["replace"] = true
+	raw, _ := json.Marshal(body)
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/settings/import", bytes.NewReader(raw))
+	req.Header.Set("Content-Type", "application/json")
+	dest.setupRouter().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var result models.SettingsImportResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to decode result: %v", err)
+	}
+	if result.Nodes != 2 || result.Vendors != 1 || result.Settings != 1 {
+		t.Errorf("Expected 2 nodes, 1 vendor and 1 setting, got %+v", result)
+	}
+	if len(result.RemovedNodes) != 1 || result.RemovedNodes[0] != 3 {
+		t.Errorf("Expected node 3 to be removed, got %v", result.RemovedNodes)
+	}
+
+	if _, ok := dest.nodes[3]; ok {
+		t.Error("Expected node 3 to be forgotten")
+	}
+	if node, err := dest.storage.GetNode(2); err != nil || node.Attributes["0/40/5"] != "Lamp" {
+		t.Errorf("Expected imported node 2, got %+v (%v)", node, err)
+	}
+	if vendor, err := dest.storage.GetVendor(4937); err != nil || vendor.VendorName != "Acme" {
+		t.Errorf("Expected imported vendor, got %+v (%v)", vendor, err)
+	}
+	if value, err := dest.storage.GetSetting("last_node_id"); err != nil || value != float64(2) {
+		t.Errorf("Expected imported setting, got %v (%v)", value, err)
+	}
+}
+
+func TestImportSettingsInvalid(t *testing.T) {
+	server := createTestServer(t)
+
+	tests := []struct {
+		name  string
+		args  map[string]interface{}
+		field string
+	}{
+		{"Missing version", map[string]interface{}{"nodes": []interface{}{}}, "format_version"},
+		{"Newer version", map[string]interface{}{"format_version": 99}, "format_version"},
+		{"Node without ID", map[string]interface{}{"format_version": 1, "nodes": []interface{}{map[string]interface{}{}}}, "nodes"},
+		{"Malformed node", map[string]interface{}{"format_version": 1, "nodes": []interface{}{map[string]interface{}{"node_id": "one"}}}, "nodes."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := server.HandleCommand(context.Background(), models.CommandMessage{
+				MessageID: "1",
+				Command:   string(models.APICommandImportSettings),
+				Args:      tt.args,
+			})
+			argErr, ok := err.(*models.ArgumentError)
+			if !ok || !strings.HasPrefix(argErr.Field, tt.field) {
+				t.Errorf("Expected argument error for %s, got %v", tt.field, err)
+			}
+		})
+	}
+}
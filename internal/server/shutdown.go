@@ -0,0 +1,189 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/systemd"
+)
+
+const (
+	// eventDeliveryTimeout bounds waiting for the subscribers to receive the
+	// server_shutdown event
+	eventDeliveryTimeout = 2 * time.Second
+
+	// shutdownPollInterval is how often shutdown checks whether events were
+	// delivered and commands finished
+	shutdownPollInterval = 10 * time.Millisecond
+
+	// closeTimeout bounds closing the listeners and subsystems
+	closeTimeout = 10 * time.Second
+
+	// shutdownReserve is kept of server.shutdown_timeout for flushing
+	// storage and closing connections, at most a quarter of it
+	shutdownReserve = 2 * time.Second
+)
+
+// shutdownBudget cuts the waits of shutdown short to finish within
+// server.shutdown_timeout
+type shutdownBudget struct {
+	deadline time.Time
+	reserve  time.Duration
+}
+
+func newShutdownBudget(timeout time.Duration) shutdownBudget {
+	if timeout <= 0 {
+		return shutdownBudget{}
+	}
+	return shutdownBudget{
+		deadline: time.Now().Add(timeout),
+		reserve:  min(shutdownReserve, timeout/4),
+	}
+}
+
+// wait returns how long a step may wait: d, or less so the reserve is left
+func (b shutdownBudget) wait(d time.Duration) time.Duration {
+	if b.deadline.IsZero() {
+		return d
+	}
+	return max(0, min(d, time.Until(b.deadline)-b.reserve))
+}
+
+// close returns how long closing may take, up to the deadline
+func (b shutdownBudget) close(d time.Duration) time.Duration {
+	if b.deadline.IsZero() {
+		return d
+	}
+	return max(0, min(d, time.Until(b.deadline)))
+}
+
+// shutdown stops the server in an order that lets clients notice it:
+//
+//  1. server_shutdown is emitted and delivered to the subscribers, while
+//     the connections are still open
+//  2. new commands are rejected and the ones in flight are given
+//     server.drain_timeout to finish
+//  3. storage is flushed, so no finished command's changes are lost
+//  4. the WebSocket connections are closed, then the listeners and the
+//     subsystems
+//
+// All of it finishes within server.shutdown_timeout, e.g. the stop timeout
+// of a container runtime, cutting the drain short if necessary.
+func (s *Server) shutdown() error {
+	budget := newShutdownBudget(s.config.Server.ShutdownTimeout)
+
+	// Take the server out of load balancing first
+	s.health.stop()
+	s.notifySystemd(systemd.Stopping, systemd.Status("Shutting down"))
+
+	s.shuttingDown.Store(true)
+	s.EmitEvent(models.EventTypeServerShutdown, nil)
+	eventCtx, cancelEvents := context.WithTimeout(context.Background(), budget.wait(eventDeliveryTimeout))
+	if !waitUntil(eventCtx, s.eventsDelivered) {
+		s.logger.Warn("Not all subscribers received the shutdown event")
+	}
+	cancelEvents()
+
+	drainTimeout := budget.wait(s.config.Server.DrainTimeout)
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancelDrain()
+	if !waitUntil(drainCtx, func() bool { return s.commandsInFlight.Load() == 0 }) {
+		s.logger.Warn("Drain timeout passed, shutting down with commands in flight",
+			logger.Int("commands", int(s.commandsInFlight.Load())),
+		)
+	}
+
+	if err := s.storage.Sync(); err != nil {
+		s.logger.Error("Failed to flush storage", logger.ErrorField(err))
+	}
+
+	// Queued events are written before the close frames
+	s.wsHandler.Drain(drainCtx, models.ServerRestarting{
+		Reason:         "server shutting down",
+		DrainTimeoutMs: drainTimeout.Milliseconds(),
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), budget.close(closeTimeout))
+	defer cancel()
+
+	// End the replication streams, the HTTP server waits for them, and stop
+	// a promotion in progress
+	if s.replicationPrimary != nil {
+		s.replicationPrimary.Shutdown()
+	}
+	if s.standby != nil {
+		s.standby.Shutdown()
+	}
+
+	// Shutdown HTTP server
+	if err := s.httpServer.Shutdown(ctx); err != nil {
+		s.logger.Error("Failed to shutdown HTTP server", logger.ErrorField(err))
+	}
+
+	// Shutdown WebSocket handler
+	s.wsHandler.Shutdown()
+
+	if s.grpcServer != nil {
+		if err := s.grpcServer.Shutdown(ctx); err != nil {
+			s.logger.Error("Failed to shutdown gRPC server", logger.ErrorField(err))
+		}
+	}
+
+	// Mark the server offline on the MQTT broker
+	if s.mqttBridge != nil {
+		if err := s.mqttBridge.Shutdown(); err != nil {
+			s.logger.Error("Failed to disconnect from MQTT broker", logger.ErrorField(err))
+		}
+	}
+
+	// Write the points buffered for the telemetry endpoint
+	if s.telemetry != nil {
+		if err := s.telemetry.Shutdown(ctx); err != nil {
+			s.logger.Error("Failed to flush telemetry", logger.ErrorField(err))
+		}
+	}
+
+	if s.debugServer != nil {
+		s.debugServer.Close()
+	}
+
+	// Shutdown Bluetooth manager
+	if s.bluetoothManager != nil {
+		if err := s.bluetoothManager.Stop(); err != nil {
+			s.logger.Error("Failed to shutdown Bluetooth manager", logger.ErrorField(err))
+		}
+	}
+
+	// Remove the SRP registration and shutdown mDNS server
+	if s.srpClient != nil {
+		if err := s.srpClient.Shutdown(); err != nil {
+			s.logger.Error("Failed to remove SRP registration", logger.ErrorField(err))
+		}
+	}
+	if s.mdnsServer != nil {
+		if err := s.mdnsServer.Shutdown(); err != nil {
+			s.logger.Error("Failed to shutdown mDNS server", logger.ErrorField(err))
+		}
+	}
+
+	s.logger.Info("Server shutdown complete")
+	return nil
+}
+
+// waitUntil polls done until it reports true, returning false if ctx is done
+// first
+func waitUntil(ctx context.Context, done func() bool) bool {
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+
+	for !done() {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+	return true
+}
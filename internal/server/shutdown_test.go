@@ -0,0 +1,110 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/storage"
+)
+
+// syncRecorder records the commands in flight when storage is flushed
+type syncRecorder struct {
+	storage.Storage
+	server   *Server
+	inFlight atomic.Int64
+	synced   atomic.Bool
+}
+
+func (r *syncRecorder) Sync() error {
+	r.inFlight.Store(r.server.commandsInFlight.Load())
+	r.synced.Store(true)
+	return nil
+}
+
+func TestShutdownOrder(t *testing.T) {
+	server := createTestServer(t)
+	server.config.Server.DrainTimeout = 2 * time.Second
+	server.httpServer = &http.Server{}
+	recorder := &syncRecorder{Storage: server.storage, server: server}
+	server.storage = recorder
+
+	var shutdownSeen atomic.Bool
+	server.Subscribe(func(eventType models.EventType, data interface{}) {
+		if eventType == models.EventTypeServerShutdown {
+			// Give shutdown a chance to move on if it doesn't wait
+			time.Sleep(20 * time.Millisecond)
+			shutdownSeen.Store(true)
+		}
+	})
+
+	// A command in flight finishes after shutdown started
+	server.commandsInFlight.Add(1)
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		time.Sleep(100 * time.Millisecond)
+		if _, err := server.HandleCommand(context.Background(), models.CommandMessage{
+			MessageID: "1",
+			Command:   string(models.APICommandServerInfo),
+		}); !errors.Is(err, models.ErrShuttingDown) {
+			t.Errorf("Expected new commands to be rejected, got %v", err)
+		}
+		if recorder.synced.Load() {
+			t.Error("Expected storage to be flushed after the commands in flight")
+		}
+		server.commandsInFlight.Add(-1)
+	}()
+
+	if err := server.shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	<-finished
+
+	if !shutdownSeen.Load() {
+		t.Error("Expected server_shutdown to be delivered during shutdown")
+	}
+	if !recorder.synced.Load() || recorder.inFlight.Load() != 0 {
+		t.Errorf("Expected storage flushed without commands in flight, %d were", recorder.inFlight.Load())
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	server := createTestServer(t)
+	server.config.Server.DrainTimeout = 10 * time.Second
+	server.config.Server.ShutdownTimeout = 400 * time.Millisecond
+	server.httpServer = &http.Server{}
+
+	// A command that never finishes doesn't hold up shutdown beyond the
+	// shutdown timeout
+	server.commandsInFlight.Add(1)
+	start := time.Now()
+	if err := server.shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected shutdown within its timeout, took %v", elapsed)
+	}
+}
+
+func TestShutdownBudget(t *testing.T) {
+	unbounded := newShutdownBudget(0)
+	if wait := unbounded.wait(time.Hour); wait != time.Hour {
+		t.Errorf("Expected waits unbounded without a timeout, got %v", wait)
+	}
+
+	budget := newShutdownBudget(8 * time.Second)
+	if wait := budget.wait(10 * time.Second); wait > 6*time.Second || wait < 5*time.Second {
+		t.Errorf("Expected the drain cut to 6s leaving the reserve, got %v", wait)
+	}
+	if wait := budget.wait(time.Second); wait != time.Second {
+		t.Errorf("Expected short waits unchanged, got %v", wait)
+	}
+	if closing := budget.close(10 * time.Second); closing > 8*time.Second || closing < 7*time.Second {
+		t.Errorf("Expected closing bounded by the deadline, got %v", closing)
+	}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/codefionn/go-matter-server/internal/storage"
+)
+
+// srpKeyFile holds the key the SRP registrations are signed with. The SRP
+// server binds the registered names to it, so it's kept across restarts.
+const srpKeyFile = "srp_key.pem"
+
+// loadSRPKey loads the SRP key from the storage path, creating it on first
+// use
+func loadSRPKey(storagePath string, cipher *storage.Cipher) (*ecdsa.PrivateKey, error) {
+	path := filepath.Join(storagePath, srpKeyFile)
+
+	keyPEM, err := cipher.ReadFile(path)
+	if err == nil {
+		block, _ := pem.Decode(keyPEM)
+		if block == nil {
+			return nil, fmt.Errorf("no PEM data in %s", path)
+		}
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		key, ok := parsed.(*ecdsa.PrivateKey)
+		if !ok || key.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("%s does not contain a P-256 key", path)
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate SRP key: %w", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal SRP key: %w", err)
+	}
+	keyPEM, err = cipher.Seal(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt SRP key: %w", err)
+	}
+	if err := os.MkdirAll(storagePath, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", storagePath, err)
+	}
+	if err := os.WriteFile(path, keyPEM, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return key, nil
+}
@@ -0,0 +1,34 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/codefionn/go-matter-server/internal/storage"
+)
+
+func TestLoadSRPKeyPersists(t *testing.T) {
+	dir := t.TempDir()
+	cipher, err := storage.NewCipher("passphrase")
+	if err != nil {
+		t.Fatalf("Failed to create cipher: %v", err)
+	}
+
+	key, err := loadSRPKey(dir, cipher)
+	if err != nil {
+		t.Fatalf("Failed to create SRP key: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(dir, srpKeyFile))
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("Expected key file with mode 0600, got %v, %v", info, err)
+	}
+
+	loaded, err := loadSRPKey(dir, cipher)
+	if err != nil {
+		t.Fatalf("Failed to load SRP key: %v", err)
+	}
+	if !loaded.Equal(key) {
+		t.Error("Expected the stored key to be loaded")
+	}
+}
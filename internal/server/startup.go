@@ -0,0 +1,96 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+)
+
+// Version is the server build version reported in the startup summary. It is
+// set by the main package from its linker-provided version information.
+var Version = "dev"
+
+// StartupSummary describes the running server once it accepts connections.
+// It is logged at startup and written to the ready file if configured.
+type StartupSummary struct {
+	Version            string          `json:"version"`
+	PID                int             `json:"pid"`
+	StartedAt          time.Time       `json:"started_at"`
+	FabricID           int             `json:"fabric_id"`
+	CompressedFabricID uint64          `json:"compressed_fabric_id"`
+	Listeners          []string        `json:"listeners"`
+	StoragePath        string          `json:"storage_path"`
+	Subsystems         map[string]bool `json:"subsystems"`
+}
+
+func (s *Server) startupSummary(listeners []string, subsystems map[string]bool) StartupSummary {
+	return StartupSummary{
+		Version:            Version,
+		PID:                os.Getpid(),
+		StartedAt:          time.Now().UTC(),
+		FabricID:           s.serverInfo.FabricID,
+		CompressedFabricID: s.serverInfo.CompressedFabricID,
+		Listeners:          listeners,
+		StoragePath:        s.config.Storage.Path,
+		Subsystems:         subsystems,
+	}
+}
+
+func (s *Server) logStartupSummary(summary StartupSummary) {
+	names := make([]string, 0, len(summary.Subsystems))
+	for name := range summary.Subsystems {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fields := []logger.Field{
+		logger.String("version", summary.Version),
+		logger.Int("fabric_id", summary.FabricID),
+		logger.String("compressed_fabric_id", fmt.Sprintf("%016X", summary.CompressedFabricID)),
+		logger.String("listeners", strings.Join(summary.Listeners, ", ")),
+		logger.String("storage", summary.StoragePath),
+	}
+	for _, name := range names {
+		fields = append(fields, logger.Bool(name, summary.Subsystems[name]))
+	}
+
+	s.logger.Info("Matter server ready", fields...)
+}
+
+// writeReadyFile atomically writes the startup summary as JSON to path
+func writeReadyFile(path string, summary StartupSummary) error {
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal ready file: %w", err)
+	}
+
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create ready file directory: %w", err)
+		}
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write temporary file %s: %w", tmpPath, err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename temporary file: %w", err)
+	}
+
+	return nil
+}
+
+func removeReadyFile(path string, log *logger.Logger) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Warn("Failed to remove ready file", logger.String("path", path), logger.ErrorField(err))
+	}
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteReadyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run", "ready.json")
+	summary := StartupSummary{
+		Version:    "test",
+		Listeners:  []string{"[::]:5580"},
+		Subsystems: map[string]bool{"mdns": true},
+	}
+
+	if err := writeReadyFile(path, summary); err != nil {
+		t.Fatalf("writeReadyFile failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read ready file: %v", err)
+	}
+
+	var got StartupSummary
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Failed to parse ready file: %v", err)
+	}
+	if got.Version != "test" || len(got.Listeners) != 1 || !got.Subsystems["mdns"] {
+		t.Errorf("Unexpected ready file contents: %+v", got)
+	}
+}
+
+func TestReadyFileLifecycle(t *testing.T) {
+	server := createTestServer(t)
+	readyFile := filepath.Join(t.TempDir(), "ready.json")
+	server.config.Server.ReadyFile = readyFile
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Run(ctx)
+	}()
+
+	var summary StartupSummary
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		data, err := os.ReadFile(readyFile)
+		if err == nil && json.Unmarshal(data, &summary) == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			cancel()
+			t.Fatal("Ready file was not written")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if summary.PID != os.Getpid() {
+		t.Errorf("Expected PID %d, got %d", os.Getpid(), summary.PID)
+	}
+	if summary.StoragePath != server.config.Storage.Path {
+		t.Errorf("Expected storage path %s, got %s", server.config.Storage.Path, summary.StoragePath)
+	}
+	if len(summary.Listeners) == 0 {
+		t.Error("Expected at least one listener")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Server did not stop")
+	}
+
+	if _, err := os.Stat(readyFile); !os.IsNotExist(err) {
+		t.Error("Expected ready file to be removed on shutdown")
+	}
+}
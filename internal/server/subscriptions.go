@@ -0,0 +1,254 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/clusters"
+	"github.com/codefionn/go-matter-server/internal/controller"
+	"github.com/codefionn/go-matter-server/internal/interaction"
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// Power Source FeatureMap bits
+const (
+	powerSourceFeatureMap     = 0xFFFC
+	powerSourceFeatureWired   = 1 << 0
+	powerSourceFeatureBattery = 1 << 1
+)
+
+// nodeSubscriptions tracks the subscription of each node, its health and
+// the last event number seen, so resubscriptions don't replay old events
+type nodeSubscriptions struct {
+	mu              sync.Mutex
+	ctx             context.Context
+	cancel          map[int]context.CancelFunc
+	status          map[int]*models.SubscriptionStatus
+	lastEventNumber map[int]int
+}
+
+// startSubscriptions subscribes to all known nodes. Nodes added later are
+// subscribed when they are stored. Subscriptions end when ctx is cancelled.
+func (s *Server) startSubscriptions(ctx context.Context) {
+	s.subscriptions.mu.Lock()
+	s.subscriptions.ctx = ctx
+	s.subscriptions.cancel = make(map[int]context.CancelFunc)
+	s.subscriptions.status = make(map[int]*models.SubscriptionStatus)
+	if s.subscriptions.lastEventNumber == nil {
+		s.subscriptions.lastEventNumber = make(map[int]int)
+	}
+	s.subscriptions.mu.Unlock()
+
+	for _, node := range s.nodeSnapshot() {
+		s.subscribeNode(node.NodeID)
+	}
+}
+
+// subscribeNode subscribes to the attributes and events of a node with a
+// single wildcard subscription, or to its events only if the controller
+// can't, unless it is already subscribed or the server is not running.
+// The report intervals depend on how the node is powered.
+func (s *Server) subscribeNode(nodeID int) {
+	s.nodesMu.RLock()
+	node, exists := s.nodes[nodeID]
+	powerSource := models.PowerSourceMains
+	if exists {
+		powerSource = nodePowerSource(node)
+	}
+	s.nodesMu.RUnlock()
+
+	subs := &s.subscriptions
+	subs.mu.Lock()
+	defer subs.mu.Unlock()
+
+	if subs.ctx == nil || subs.ctx.Err() != nil {
+		return
+	}
+	if _, subscribed := subs.cancel[nodeID]; subscribed {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(subs.ctx)
+	eventMin := uint64(subs.lastEventNumber[nodeID] + 1)
+	handler := func(report []byte) {
+		s.handleReport(nodeID, report)
+	}
+	status := &models.SubscriptionStatus{
+		NodeID:       nodeID,
+		PowerSource:  powerSource,
+		SubscribedAt: time.Now().UTC(),
+	}
+
+	var err error
+	if subscriber, ok := s.controller.(controller.Subscriber); ok {
+		req := controller.SubscribeRequest{
+			NodeID:      nodeID,
+			EventMin:    eventMin,
+			MinInterval: s.config.Subscriptions.MinInterval,
+			MaxInterval: s.config.Subscriptions.MaxInterval,
+			Handler:     handler,
+		}
+		if powerSource == models.PowerSourceBattery {
+			req.MaxInterval = s.config.Subscriptions.BatteryMaxInterval
+		}
+		var maxInterval time.Duration
+		maxInterval, err = subscriber.Subscribe(ctx, req)
+		status.MinIntervalMs = req.MinInterval.Milliseconds()
+		status.MaxIntervalMs = maxInterval.Milliseconds()
+	} else {
+		err = s.controller.SubscribeEvents(ctx, nodeID, eventMin, handler)
+		status.EventsOnly = true
+	}
+	if err != nil {
+		cancel()
+		if errors.Is(err, controller.ErrNotAvailable) {
+			s.logger.Debug("Skipping node subscription", logger.Int("node_id", nodeID), logger.ErrorField(err))
+		} else {
+			s.logger.Warn("Failed to subscribe to node", logger.Int("node_id", nodeID), logger.ErrorField(err))
+		}
+		return
+	}
+
+	subs.cancel[nodeID] = cancel
+	subs.status[nodeID] = status
+}
+
+// unsubscribeNode ends the subscription of a removed node
+func (s *Server) unsubscribeNode(nodeID int) {
+	subs := &s.subscriptions
+	subs.mu.Lock()
+	defer subs.mu.Unlock()
+
+	if cancel, subscribed := subs.cancel[nodeID]; subscribed {
+		cancel()
+		delete(subs.cancel, nodeID)
+		delete(subs.status, nodeID)
+	}
+}
+
+// handleReport applies the attribute values and events of a report.
+// Keep-alive reports without data only mark the subscription alive.
+func (s *Server) handleReport(nodeID int, report []byte) {
+	s.subscriptions.mu.Lock()
+	if status, ok := s.subscriptions.status[nodeID]; ok {
+		now := time.Now().UTC()
+		status.LastReport = &now
+	}
+	s.subscriptions.mu.Unlock()
+
+	values, err := interaction.DecodeAttributeReports(report)
+	if err != nil {
+		s.logger.Warn("Failed to decode attribute report", logger.Int("node_id", nodeID), logger.ErrorField(err))
+	} else if len(values) > 0 {
+		if err := s.updateNodeAttributes(nodeID, values); err != nil {
+			s.logger.Warn("Failed to update reported attributes", logger.Int("node_id", nodeID), logger.ErrorField(err))
+		}
+	}
+
+	s.handleEventReport(nodeID, report)
+}
+
+// subscriptionStatus returns the health of the node subscriptions, ordered
+// by node ID. Subscriptions without a report for twice their maximum
+// interval are stale.
+func (s *Server) subscriptionStatus() []models.SubscriptionStatus {
+	subs := &s.subscriptions
+	subs.mu.Lock()
+	defer subs.mu.Unlock()
+
+	now := time.Now()
+	statuses := make([]models.SubscriptionStatus, 0, len(subs.status))
+	for _, status := range subs.status {
+		entry := *status
+		if entry.MaxIntervalMs > 0 {
+			last := entry.SubscribedAt
+			if entry.LastReport != nil {
+				last = *entry.LastReport
+			}
+			entry.Stale = now.Sub(last) > 2*time.Duration(entry.MaxIntervalMs)*time.Millisecond
+		}
+		statuses = append(statuses, entry)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].NodeID < statuses[j].NodeID
+	})
+	return statuses
+}
+
+// nodePowerSource classifies a node as battery powered if it is a sleepy
+// device or all its power sources are batteries
+func nodePowerSource(node *models.MatterNodeData) string {
+	if node.Sleepy {
+		return models.PowerSourceBattery
+	}
+
+	endpoints := append([]int{0}, intList(node.Attributes[attributePath(0, clusters.DescriptorClusterID, descriptorPartsList)])...)
+	battery := false
+	for _, endpoint := range endpoints {
+		features, ok := toInt(node.Attributes[attributePath(endpoint, clusters.PowerSourceClusterID, powerSourceFeatureMap)])
+		if !ok {
+			continue
+		}
+		if features&powerSourceFeatureWired != 0 {
+			return models.PowerSourceMains
+		}
+		if features&powerSourceFeatureBattery != 0 {
+			battery = true
+		}
+	}
+	if battery {
+		return models.PowerSourceBattery
+	}
+	return models.PowerSourceMains
+}
+
+// updateNodeAttributes stores the reported or polled values that changed
+// and emits attribute_updated for them. A changed bridge PartsList also
+// emits endpoint_added and endpoint_removed. The node is persisted while
+// nodesMu is held so concurrent reports cannot save an older version over
+// a newer one.
+func (s *Server) updateNodeAttributes(nodeID int, values map[string]interface{}) error {
+	s.nodesMu.Lock()
+	existing, exists := s.nodes[nodeID]
+	if !exists {
+		s.nodesMu.Unlock()
+		return nil
+	}
+	changed := make(map[string]interface{})
+	for path, value := range values {
+		if !reflect.DeepEqual(existing.Attributes[path], value) {
+			changed[path] = value
+		}
+	}
+	if len(changed) == 0 {
+		s.nodesMu.Unlock()
+		return nil
+	}
+
+	node := *existing
+	node.Attributes = make(map[string]interface{}, len(existing.Attributes))
+	for k, v := range existing.Attributes {
+		node.Attributes[k] = v
+	}
+	for path, value := range changed {
+		node.Attributes[path] = value
+	}
+	expandBridge(&node)
+	s.nodes[nodeID] = &node
+	err := s.storage.SaveNode(&node)
+	s.nodesMu.Unlock()
+
+	if err != nil {
+		return err
+	}
+	for path, value := range changed {
+		s.EmitEvent(models.EventTypeAttributeUpdated, []interface{}{nodeID, path, value})
+	}
+	s.emitEndpointChanges(nodeID, existing.BridgedEndpoints, node.BridgedEndpoints)
+	return nil
+}
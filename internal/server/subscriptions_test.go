@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/controller"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// subscriberController records wildcard subscriptions, negotiating the
+// requested maximum interval
+type subscriberController struct {
+	fakeController
+	mu       sync.Mutex
+	requests map[int]controller.SubscribeRequest
+	contexts map[int]context.Context
+}
+
+func (c *subscriberController) Subscribe(ctx context.Context, req controller.SubscribeRequest) (time.Duration, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.requests == nil {
+		c.requests = make(map[int]controller.SubscribeRequest)
+		c.contexts = make(map[int]context.Context)
+	}
+	c.requests[req.NodeID] = req
+	c.contexts[req.NodeID] = ctx
+	return req.MaxInterval, nil
+}
+
+// onOffReport is a ReportDataMessage with OnOff.OnOff of endpoint 1
+var onOffReport = []byte{
+	0x15, 0x36, 0x01,
+	0x15, 0x35, 0x01,
+	0x24, 0x00, 0x01,
+	0x37, 0x01, 0x24, 0x02, 0x01, 0x24, 0x03, 0x06, 0x24, 0x04, 0x00, 0x18,
+	0x29, 0x02,
+	0x18, 0x18,
+	0x18, 0x18,
+}
+
+func TestNodeSubscriptions(t *testing.T) {
+	server := createTestServer(t)
+	server.config.Subscriptions.MaxInterval = time.Minute
+	server.config.Subscriptions.BatteryMaxInterval = 10 * time.Minute
+	fake := &subscriberController{}
+	server.controller = fake
+
+	server.nodes[1] = &models.MatterNodeData{NodeID: 1, Attributes: map[string]interface{}{"1/6/0": false}}
+	server.nodes[2] = &models.MatterNodeData{NodeID: 2, Attributes: map[string]interface{}{
+		"0/29/3":     []interface{}{float64(1)},
+		"1/47/65532": float64(powerSourceFeatureBattery),
+	}}
+	server.nodes[3] = &models.MatterNodeData{NodeID: 3, Sleepy: true, Attributes: map[string]interface{}{}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server.startSubscriptions(ctx)
+
+	if req := fake.requests[1]; req.MaxInterval != time.Minute || req.EventMin != 1 {
+		t.Errorf("Expected the mains interval for node 1, got %+v", req)
+	}
+	for _, nodeID := range []int{2, 3} {
+		if req := fake.requests[nodeID]; req.MaxInterval != 10*time.Minute {
+			t.Errorf("Expected the battery interval for node %d, got %+v", nodeID, req)
+		}
+	}
+
+	updates := make(chan []interface{}, 1)
+	server.Subscribe(func(eventType models.EventType, data interface{}) {
+		if eventType == models.EventTypeAttributeUpdated {
+			updates <- data.([]interface{})
+		}
+	})
+	fake.requests[1].Handler(onOffReport)
+	select {
+	case update := <-updates:
+		if update[0] != 1 || update[1] != "1/6/0" || update[2] != true {
+			t.Errorf("Unexpected attribute update %v", update)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected an attribute_updated event")
+	}
+	if server.nodes[1].Attributes["1/6/0"] != true {
+		t.Errorf("Expected the reported value stored, got %v", server.nodes[1].Attributes)
+	}
+
+	// Node 2 didn't report for twice its maximum interval
+	server.subscriptions.status[2].SubscribedAt = time.Now().Add(-30 * time.Minute)
+	statuses := server.subscriptionStatus()
+	if len(statuses) != 3 {
+		t.Fatalf("Expected 3 subscriptions, got %+v", statuses)
+	}
+	if statuses[0].LastReport == nil || statuses[0].Stale || statuses[0].PowerSource != models.PowerSourceMains {
+		t.Errorf("Expected a healthy subscription of node 1, got %+v", statuses[0])
+	}
+	if !statuses[1].Stale || statuses[1].MaxIntervalMs != 600000 || statuses[1].PowerSource != models.PowerSourceBattery {
+		t.Errorf("Expected a stale subscription of node 2, got %+v", statuses[1])
+	}
+
+	if err := server.removeNode(1); err != nil {
+		t.Fatal(err)
+	}
+	if fake.contexts[1].Err() == nil || len(server.subscriptionStatus()) != 2 {
+		t.Error("Expected the subscription of the removed node to end")
+	}
+}
+
+func TestNodePowerSource(t *testing.T) {
+	wiredAndBattery := &models.MatterNodeData{Attributes: map[string]interface{}{
+		"0/29/3":     []interface{}{float64(1), float64(2)},
+		"1/47/65532": float64(powerSourceFeatureBattery),
+		"2/47/65532": float64(powerSourceFeatureWired),
+	}}
+	if source := nodePowerSource(wiredAndBattery); source != models.PowerSourceMains {
+		t.Errorf("Expected a node with a wired power source to be mains powered, got %s", source)
+	}
+	if source := nodePowerSource(&models.MatterNodeData{}); source != models.PowerSourceMains {
+		t.Errorf("Expected nodes without power source to be mains powered, got %s", source)
+	}
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/systemd"
+)
+
+// apiSocketName is the FileDescriptorName= of the API socket when a socket
+// unit passes several sockets
+const apiSocketName = "api"
+
+// listenAPI returns the listener of the HTTP and WebSocket API: the socket
+// passed by systemd socket activation, or a new one on addr
+func (s *Server) listenAPI(addr string) (net.Listener, error) {
+	listeners, err := systemd.Listeners()
+	if err != nil {
+		return nil, fmt.Errorf("socket activation failed: %w", err)
+	}
+
+	var activated net.Listener
+	for name, listener := range listeners {
+		if len(listeners) == 1 || name == apiSocketName {
+			activated = listener
+			continue
+		}
+		s.logger.Warn("Closing unused socket passed by systemd", logger.String("name", name))
+		listener.Close()
+	}
+	if activated != nil {
+		s.logger.Info("Using the API socket passed by systemd",
+			logger.String("address", activated.Addr().String()),
+		)
+		return activated, nil
+	}
+	if len(listeners) > 0 {
+		return nil, fmt.Errorf("socket activation passed %d sockets, none named %q", len(listeners), apiSocketName)
+	}
+	return net.Listen("tcp", addr)
+}
+
+// notifySystemd sends states to systemd when running as a Type=notify
+// service
+func (s *Server) notifySystemd(states ...string) {
+	if _, err := systemd.Notify(states...); err != nil {
+		s.logger.Warn("Failed to notify systemd", logger.ErrorField(err))
+	}
+}
+
+// runWatchdog sends the systemd watchdog notifications at half the
+// WatchdogSec= of the service, as long as the server passes its liveness
+// check. A server that is stuck stops notifying and is restarted by systemd.
+func (s *Server) runWatchdog(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !s.alive(interval / 2) {
+				s.logger.Error("Liveness check failed, not notifying the systemd watchdog")
+				continue
+			}
+			s.notifySystemd(systemd.Watchdog)
+		}
+	}
+}
+
+// alive reports whether the server's shared state can be taken within
+// timeout, i.e. no lock is held by a stuck goroutine
+func (s *Server) alive(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.nodesMu.RLock()
+		s.nodesMu.RUnlock()
+		s.eventMu.RLock()
+		s.eventMu.RUnlock()
+		s.health.status()
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
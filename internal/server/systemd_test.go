@@ -0,0 +1,47 @@
+package server
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchdog(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "notify.socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("Unix datagram sockets unavailable: %v", err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", socket)
+
+	server := createTestServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.runWatchdog(ctx, 40*time.Millisecond)
+
+	read := func() string {
+		buf := make([]byte, 64)
+		conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		n, err := conn.Read(buf)
+		if err != nil {
+			return ""
+		}
+		return string(buf[:n])
+	}
+	if got := read(); got != "WATCHDOG=1" {
+		t.Fatalf("Expected a watchdog notification, got %q", got)
+	}
+
+	// A stuck server stops notifying
+	server.nodesMu.Lock()
+	time.Sleep(30 * time.Millisecond)
+	// Sent before the lock was taken
+	read()
+	if got := read(); got != "" {
+		t.Errorf("Expected no notification while the nodes are locked, got %q", got)
+	}
+	server.nodesMu.Unlock()
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// defaultCommandTimeouts are the built-in deadlines of commands that talk to
+// devices. server.command_timeouts overrides them, the other commands get
+// server.command_timeout. A zero timeout doesn't limit a command.
+var defaultCommandTimeouts = map[models.APICommand]time.Duration{
+	models.APICommandReadAttribute:       30 * time.Second,
+	models.APICommandWriteAttribute:      30 * time.Second,
+	models.APICommandDeviceCommand:       30 * time.Second,
+	models.APICommandDeviceAction:        30 * time.Second,
+	models.APICommandPingNode:            30 * time.Second,
+	models.APICommandInterviewNode:       reinterviewTimeout,
+	models.APICommandCommissionWithCode:  10 * time.Minute,
+	models.APICommandCommissionOnNetwork: 10 * time.Minute,
+	models.APICommandUpdateNode:          30 * time.Minute,
+	models.APICommandCheckNodeUpdate:     2 * time.Minute,
+	// Checks all nodes with the refresh argument
+	models.APICommandGetFirmwareInventory: firmwareCheckTimeout,
+	// Bounded by its timeout_ms argument
+	models.APICommandDiscoverBLE: 0,
+}
+
+// commandTimeout returns the deadline of a command, 0 if it isn't limited
+func (s *Server) commandTimeout(command models.APICommand) time.Duration {
+	if timeout, ok := s.config.Server.CommandTimeouts[string(command)]; ok {
+		return timeout
+	}
+	if timeout, ok := defaultCommandTimeouts[command]; ok {
+		return timeout
+	}
+	return s.config.Server.CommandTimeout
+}
+
+// runCommandWithTimeout runs a command with its deadline. A command failing
+// because its deadline passed returns models.ErrCommandTimeout; a deadline
+// or cancellation of the caller is returned as it is.
+func (s *Server) runCommandWithTimeout(ctx context.Context, cmd models.CommandMessage) (interface{}, error) {
+	timeout := s.commandTimeout(models.APICommand(cmd.Command))
+	if timeout <= 0 {
+		return s.runCommand(ctx, cmd)
+	}
+
+	ctx, cancel := context.WithTimeoutCause(ctx, timeout, models.ErrCommandTimeout)
+	defer cancel()
+	result, err := s.runCommand(ctx, cmd)
+	if err != nil && errors.Is(context.Cause(ctx), models.ErrCommandTimeout) {
+		return nil, fmt.Errorf("%w: %s took longer than %v", models.ErrCommandTimeout, cmd.Command, timeout)
+	}
+	return result, err
+}
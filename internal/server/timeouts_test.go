@@ -0,0 +1,70 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/controller"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// hangingController never gets an answer from the devices
+type hangingController struct {
+	controller.Unavailable
+}
+
+func (hangingController) SendCommand(ctx context.Context, req controller.CommandRequest) (interface{}, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestCommandTimeout(t *testing.T) {
+	server := createTestServer(t)
+	server.config.Server.CommandTimeout = time.Minute
+	server.config.Server.CommandTimeouts = map[string]time.Duration{
+		"device_command": 20 * time.Millisecond,
+		"read_attribute": 0,
+	}
+
+	tests := []struct {
+		command models.APICommand
+		want    time.Duration
+	}{
+		{models.APICommandDeviceCommand, 20 * time.Millisecond},
+		{models.APICommandReadAttribute, 0},
+		{models.APICommandWriteAttribute, 30 * time.Second},
+		{models.APICommandCommissionWithCode, 10 * time.Minute},
+		{models.APICommandGetNodes, time.Minute},
+	}
+	for _, tt := range tests {
+		if got := server.commandTimeout(tt.command); got != tt.want {
+			t.Errorf("Expected timeout %v for %s, got %v", tt.want, tt.command, got)
+		}
+	}
+
+	server.controller = hangingController{}
+	server.nodes[5] = &models.MatterNodeData{NodeID: 5, Attributes: map[string]interface{}{}}
+	cmd := models.CommandMessage{
+		MessageID: "1",
+		Command:   string(models.APICommandDeviceCommand),
+		Args:      map[string]interface{}{"node_id": 5, "endpoint_id": 1, "cluster_id": 6, "command_name": 2},
+	}
+
+	start := time.Now()
+	_, err := server.HandleCommand(context.Background(), cmd)
+	if !errors.Is(err, models.ErrCommandTimeout) {
+		t.Fatalf("Expected the command to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the command to fail after its timeout, took %v", elapsed)
+	}
+
+	// Cancellation by the client isn't a timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := server.HandleCommand(ctx, cmd); !errors.Is(err, context.Canceled) || errors.Is(err, models.ErrCommandTimeout) {
+		t.Errorf("Expected the cancellation, got %v", err)
+	}
+}
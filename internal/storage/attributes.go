@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// The recorded values of each node's attributes are stored in
+// attribute_history/<node_id>.json, keyed by attribute path
+const attributeHistoryDir = "attribute_history"
+
+// nodeAttributeHistory holds the samples of a node's attributes by path
+type nodeAttributeHistory map[string][]models.AttributeSample
+
+// SetAttributeHistory sets how long recorded attribute values are kept and
+// the downsampling interval. Values recorded within one interval are merged
+// into a single sample, 0 keeps every value. Must be called before Start.
+func (s *JSONStorage) SetAttributeHistory(retention, interval time.Duration) {
+	s.historyRetention = retention
+	s.historyInterval = interval
+}
+
+// RecordAttribute adds an attribute value to the attribute history. Numeric
+// values within the same downsampling interval are averaged, for other
+// values the last one is kept.
+func (s *JSONStorage) RecordAttribute(nodeID int, path string, value interface{}, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history, err := s.attributeHistory(nodeID)
+	if err != nil {
+		return err
+	}
+
+	at = at.UTC()
+	if s.historyInterval > 0 {
+		at = at.Truncate(s.historyInterval)
+	}
+	samples := history[path]
+	if n := len(samples); n > 0 && samples[n-1].Timestamp.Equal(at) {
+		samples[n-1] = mergeSample(samples[n-1], value)
+	} else {
+		samples = append(samples, models.AttributeSample{Timestamp: at, Value: value, Count: 1})
+	}
+	history[path] = s.pruneSamples(samples)
+
+	s.dirtyHistory[nodeID] = struct{}{}
+	if s.flushInterval > 0 {
+		return nil
+	}
+	return s.flushAttributeHistory()
+}
+
+// GetAttributeHistory returns the recorded samples of an attribute, oldest
+// first
+func (s *JSONStorage) GetAttributeHistory(nodeID int, path string) ([]models.AttributeSample, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history, err := s.attributeHistory(nodeID)
+	if err != nil {
+		return nil, err
+	}
+	samples := s.pruneSamples(history[path])
+	return append([]models.AttributeSample(nil), samples...), nil
+}
+
+// mergeSample merges a value into the sample of its downsampling interval
+func mergeSample(sample models.AttributeSample, value interface{}) models.AttributeSample {
+	mean, ok := toFloat(sample.Value)
+	v, isNumber := toFloat(value)
+	if !ok || !isNumber {
+		return models.AttributeSample{Timestamp: sample.Timestamp, Value: value, Count: 1}
+	}
+
+	count := max(sample.Count, 1)
+	sample.Value = (mean*float64(count) + v) / float64(count+1)
+	sample.Count = count + 1
+	return sample
+}
+
+// toFloat converts the numeric types of decoded attribute values to float64
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// pruneSamples drops samples older than the retention
+func (s *JSONStorage) pruneSamples(samples []models.AttributeSample) []models.AttributeSample {
+	if s.historyRetention <= 0 {
+		return samples
+	}
+	cutoff := time.Now().Add(-s.historyRetention)
+	for len(samples) > 0 && samples[0].Timestamp.Before(cutoff) {
+		samples = samples[1:]
+	}
+	return samples
+}
+
+// attributeHistory returns the attribute history of a node, reading its file
+// on first access. Must be called with the write lock held.
+func (s *JSONStorage) attributeHistory(nodeID int) (nodeAttributeHistory, error) {
+	if history, loaded := s.history[nodeID]; loaded {
+		return history, nil
+	}
+
+	history := make(nodeAttributeHistory)
+	name := filepath.Join(attributeHistoryDir, strconv.Itoa(nodeID)+".json")
+	if err := s.loadVerified(name, &history); err != nil {
+		return nil, fmt.Errorf("failed to load attribute history of node %d: %w", nodeID, err)
+	}
+
+	s.history[nodeID] = history
+	return history, nil
+}
+
+func (s *JSONStorage) attributeHistoryPath(nodeID int) string {
+	return filepath.Join(s.basePath, attributeHistoryDir, strconv.Itoa(nodeID)+".json")
+}
+
+// flushAttributeHistory writes the attribute history of nodes with new
+// samples
+func (s *JSONStorage) flushAttributeHistory() error {
+	for nodeID := range s.dirtyHistory {
+		if err := s.saveJSONFile(s.attributeHistoryPath(nodeID), s.history[nodeID]); err != nil {
+			return fmt.Errorf("failed to save attribute history of node %d: %w", nodeID, err)
+		}
+		delete(s.dirtyHistory, nodeID)
+	}
+	return nil
+}
+
+// deleteAttributeHistory removes the attribute history of a node. Must be
+// called with the write lock held.
+func (s *JSONStorage) deleteAttributeHistory(nodeID int) error {
+	delete(s.history, nodeID)
+	delete(s.dirtyHistory, nodeID)
+
+	path := s.attributeHistoryPath(nodeID)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove attribute history of node %d: %w", nodeID, err)
+	}
+	os.Remove(path + checksumSuffix)
+	return nil
+}
@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Encrypted files start with encryptedMagic, followed by the salt the key
+// was derived with, the GCM nonce and the sealed data
+var encryptedMagic = []byte("MSENC\x01")
+
+const (
+	saltSize  = 16
+	nonceSize = 12
+)
+
+// kdfIterations is the PBKDF2-SHA256 work factor for passphrases
+var kdfIterations = 600000
+
+var (
+	// ErrWrongKey is returned for files none of the configured keys decrypt
+	ErrWrongKey = errors.New("wrong storage encryption key or corrupted file")
+	// ErrNoKey is returned for encrypted files when no key is configured
+	ErrNoKey = errors.New("file is encrypted but no storage encryption key is configured")
+)
+
+// Cipher encrypts storage files with AES-256-GCM, keyed by a passphrase.
+// Files encrypted with a previous passphrase and unencrypted files are
+// re-encrypted with the current passphrase when they are read, which
+// rotates the key once every file was read. A nil Cipher reads and writes
+// unencrypted files.
+type Cipher struct {
+	// The current passphrase first, then previous ones. An empty current
+	// passphrase decrypts files and writes them unencrypted.
+	passphrases []string
+	salt        []byte
+
+	mu   sync.Mutex
+	keys map[derivedKey]cipher.AEAD
+}
+
+type derivedKey struct {
+	passphrase int
+	salt       string
+}
+
+// NewCipher creates a cipher encrypting with passphrase and also decrypting
+// files encrypted with any of the previous passphrases. Without any
+// passphrase no cipher is needed and nil is returned.
+func NewCipher(passphrase string, previous ...string) (*Cipher, error) {
+	if passphrase == "" && len(previous) == 0 {
+		return nil, nil
+	}
+
+	c := &Cipher{
+		passphrases: append([]string{passphrase}, previous...),
+		salt:        make([]byte, saltSize),
+		keys:        make(map[derivedKey]cipher.AEAD),
+	}
+	if _, err := rand.Read(c.salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	return c, nil
+}
+
+// Seal returns data as it is written to disk
+func (c *Cipher) Seal(data []byte) ([]byte, error) {
+	if c == nil || c.passphrases[0] == "" {
+		return data, nil
+	}
+
+	aead, err := c.aead(0, c.salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := make([]byte, 0, len(encryptedMagic)+saltSize+nonceSize+len(data)+aead.Overhead())
+	sealed = append(sealed, encryptedMagic...)
+	sealed = append(sealed, c.salt...)
+	sealed = append(sealed, nonce...)
+	return aead.Seal(sealed, nonce, data, nil), nil
+}
+
+// ReadFile reads and decrypts a file. Errors of os.ReadFile are returned
+// unwrapped, so os.IsNotExist works on them.
+func (c *Cipher) ReadFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	plain, stale, err := c.Open(data)
+	if err != nil {
+		return nil, err
+	}
+	if stale {
+		return plain, c.rewrite(path, plain)
+	}
+	return plain, nil
+}
+
+// Open decrypts data read from disk. Stale is true for data that isn't
+// encrypted with the current passphrase and should be written again.
+func (c *Cipher) Open(data []byte) (plain []byte, stale bool, err error) {
+	if !bytes.HasPrefix(data, encryptedMagic) {
+		return data, c != nil && c.passphrases[0] != "" && len(data) > 0, nil
+	}
+
+	if c == nil {
+		return nil, false, ErrNoKey
+	}
+	header := len(encryptedMagic) + saltSize + nonceSize
+	if len(data) < header {
+		return nil, false, ErrWrongKey
+	}
+	salt := data[len(encryptedMagic) : len(encryptedMagic)+saltSize]
+	nonce := data[len(encryptedMagic)+saltSize : header]
+
+	for i, passphrase := range c.passphrases {
+		if passphrase == "" {
+			continue
+		}
+		aead, err := c.aead(i, salt)
+		if err != nil {
+			return nil, false, err
+		}
+		plain, err := aead.Open(nil, nonce, data[header:], nil)
+		if err != nil {
+			continue
+		}
+		return plain, i != 0 || c.passphrases[0] == "", nil
+	}
+
+	return nil, false, ErrWrongKey
+}
+
+// rewrite replaces a file not encrypted with the current passphrase
+func (c *Cipher) rewrite(path string, plain []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	sealed, err := c.Seal(plain)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, sealed, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to re-encrypt: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to re-encrypt: %w", err)
+	}
+	return nil
+}
+
+// aead returns the AES-GCM cipher for a passphrase and salt. Deriving a key
+// is slow on purpose, so keys are cached.
+func (c *Cipher) aead(passphrase int, salt []byte) (cipher.AEAD, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := derivedKey{passphrase: passphrase, salt: string(salt)}
+	if aead, ok := c.keys[id]; ok {
+		return aead, nil
+	}
+
+	key, err := pbkdf2.Key(sha256.New, c.passphrases[passphrase], salt, kdfIterations, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive storage key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	c.keys[id] = aead
+	return aead, nil
+}
+
+// IsKeyError reports whether err is caused by a missing or wrong storage
+// encryption key
+func IsKeyError(err error) bool {
+	return errors.Is(err, ErrWrongKey) || errors.Is(err, ErrNoKey)
+}
@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// fastKDF lowers the key derivation work factor for the test
+func fastKDF(t *testing.T) {
+	t.Helper()
+	iterations := kdfIterations
+	kdfIterations = 1000
+	t.Cleanup(func() { kdfIterations = iterations })
+}
+
+func newTestCipher(t *testing.T, passphrase string, previous ...string) *Cipher {
+	t.Helper()
+	c, err := NewCipher(passphrase, previous...)
+	if err != nil {
+		t.Fatalf("Failed to create cipher: %v", err)
+	}
+	return c
+}
+
+func writeSealed(t *testing.T, c *Cipher, path string, data []byte) {
+	t.Helper()
+	sealed, err := c.Seal(data)
+	if err != nil {
+		t.Fatalf("Failed to seal: %v", err)
+	}
+	if err := os.WriteFile(path, sealed, 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+}
+
+func TestCipherRoundTrip(t *testing.T) {
+	fastKDF(t)
+	path := filepath.Join(t.TempDir(), "secret.json")
+	plain := []byte(`{"ssid": "home", "password": "hunter2"}`)
+
+	writeSealed(t, newTestCipher(t, "passphrase"), path, plain)
+	onDisk, _ := os.ReadFile(path)
+	if bytes.Contains(onDisk, []byte("hunter2")) {
+		t.Error("Expected file to be encrypted")
+	}
+
+	// A new cipher uses a new salt but the same passphrase
+	data, err := newTestCipher(t, "passphrase").ReadFile(path)
+	if err != nil || !bytes.Equal(data, plain) {
+		t.Errorf("Expected decrypted data, got %q (%v)", data, err)
+	}
+}
+
+func TestCipherKeyErrors(t *testing.T) {
+	fastKDF(t)
+	path := filepath.Join(t.TempDir(), "secret.json")
+	writeSealed(t, newTestCipher(t, "passphrase"), path, []byte("{}"))
+
+	if _, err := newTestCipher(t, "wrong").ReadFile(path); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("Expected ErrWrongKey, got %v", err)
+	}
+	var none *Cipher
+	if _, err := none.ReadFile(path); !errors.Is(err, ErrNoKey) {
+		t.Errorf("Expected ErrNoKey, got %v", err)
+	}
+	if _, err := none.ReadFile(filepath.Join(t.TempDir(), "missing")); !os.IsNotExist(err) {
+		t.Errorf("Expected not exist error, got %v", err)
+	}
+}
+
+func TestCipherRotation(t *testing.T) {
+	fastKDF(t)
+	dir := t.TempDir()
+	encrypted := filepath.Join(dir, "encrypted.json")
+	plain := filepath.Join(dir, "plain.json")
+	writeSealed(t, newTestCipher(t, "old"), encrypted, []byte("a"))
+	if err := os.WriteFile(plain, []byte("b"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	// Reading re-encrypts files with the current key
+	rotating := newTestCipher(t, "new", "old")
+	for path, want := range map[string]string{encrypted: "a", plain: "b"} {
+		if data, err := rotating.ReadFile(path); err != nil || string(data) != want {
+			t.Errorf("Expected %q from %s, got %q (%v)", want, path, data, err)
+		}
+	}
+
+	current := newTestCipher(t, "new")
+	for path := range map[string]string{encrypted: "a", plain: "b"} {
+		if _, err := current.ReadFile(path); err != nil {
+			t.Errorf("Expected %s to be re-encrypted with the new key: %v", path, err)
+		}
+	}
+
+	// Without a current key files are decrypted
+	if _, err := newTestCipher(t, "", "new").ReadFile(plain); err != nil {
+		t.Fatalf("Failed to decrypt: %v", err)
+	}
+	if data, _ := os.ReadFile(plain); string(data) != "b" {
+		t.Errorf("Expected unencrypted file, got %q", data)
+	}
+}
+
+func TestJSONStorageEncrypted(t *testing.T) {
+	fastKDF(t)
+	tempDir := t.TempDir()
+	log := logger.NewConsoleLogger(logger.ErrorLevel)
+
+	storage := NewJSONStorage(tempDir, log)
+	storage.SetCipher(newTestCipher(t, "passphrase"))
+	if err := storage.Start(); err != nil {
+		t.Fatalf("Failed to start storage: %v", err)
+	}
+	if err := storage.SaveNode(&models.MatterNodeData{NodeID: 1, Attributes: map[string]interface{}{"0/40/5": "Kitchen"}}); err != nil {
+		t.Fatalf("Failed to save node: %v", err)
+	}
+	if err := storage.SaveSetting("wifi_password", "hunter2"); err != nil {
+		t.Fatalf("Failed to save setting: %v", err)
+	}
+	storage.Stop()
+
+	for _, name := range []string{"nodes/1.json", "settings.json"} {
+		data, err := os.ReadFile(filepath.Join(tempDir, name))
+		if err != nil || !bytes.HasPrefix(data, encryptedMagic) {
+			t.Errorf("Expected %s to be encrypted (%v)", name, err)
+		}
+	}
+
+	wrong := NewJSONStorage(tempDir, log)
+	wrong.SetCipher(newTestCipher(t, "wrong"))
+	if err := wrong.Start(); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("Expected start to fail with ErrWrongKey, got %v", err)
+	}
+
+	reopened := NewJSONStorage(tempDir, log)
+	reopened.SetCipher(newTestCipher(t, "passphrase"))
+	if err := reopened.Start(); err != nil {
+		t.Fatalf("Failed to start storage: %v", err)
+	}
+	defer reopened.Stop()
+	if node, err := reopened.GetNode(1); err != nil || node.Attributes["0/40/5"] != "Kitchen" {
+		t.Errorf("Expected decrypted node, got %+v (%v)", node, err)
+	}
+}
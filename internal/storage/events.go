@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"path/filepath"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+const (
+	eventsFile = "events.json"
+
+	// DefaultEventHistorySize is the number of events kept by default
+	DefaultEventHistorySize = 1000
+)
+
+// SetEventHistorySize sets the number of events kept by AddEvent, 0
+// disables the event history. Must be called before Start.
+func (s *JSONStorage) SetEventHistorySize(size int) {
+	s.eventHistorySize = size
+}
+
+// AddEvent appends an event to the history, dropping the oldest events
+// beyond the history size. Like node updates, the history is written every
+// flush interval when write-behind is enabled.
+func (s *JSONStorage) AddEvent(entry *models.EventHistoryEntry) error {
+	if s.eventHistorySize <= 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entryCopy := *entry
+	s.events = append(s.events, &entryCopy)
+	if excess := len(s.events) - s.eventHistorySize; excess > 0 {
+		// Copy, so the dropped events don't stay in the backing array
+		s.events = append([]*models.EventHistoryEntry(nil), s.events[excess:]...)
+	}
+	s.eventsDirty = true
+
+	if s.flushInterval > 0 {
+		return nil
+	}
+	return s.flushEvents()
+}
+
+// GetEvents returns the event history, oldest first
+func (s *JSONStorage) GetEvents() ([]*models.EventHistoryEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := make([]*models.EventHistoryEntry, len(s.events))
+	for i, entry := range s.events {
+		entryCopy := *entry
+		events[i] = &entryCopy
+	}
+	return events, nil
+}
+
+func (s *JSONStorage) loadEvents() error {
+	if err := s.loadVerified(eventsFile, &s.events); err != nil {
+		return err
+	}
+
+	// The history size may have been lowered since the events were saved
+	if excess := len(s.events) - s.eventHistorySize; excess > 0 {
+		s.events = s.events[excess:]
+		s.eventsDirty = true
+	}
+	return nil
+}
+
+// flushEvents writes the event history if it changed
+func (s *JSONStorage) flushEvents() error {
+	if !s.eventsDirty {
+		return nil
+	}
+
+	path := filepath.Join(s.basePath, eventsFile)
+	if err := s.saveJSONFile(path, s.events); err != nil {
+		return err
+	}
+	s.eventsDirty = false
+	return nil
+}
@@ -0,0 +1,239 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+)
+
+// CorruptionPolicy decides what happens when a storage file fails its
+// integrity check while loading
+type CorruptionPolicy string
+
+const (
+	// CorruptionRecover moves the corrupted file aside and restores it from
+	// the newest backup passing the integrity check
+	CorruptionRecover CorruptionPolicy = "recover"
+
+	// CorruptionFail refuses to load corrupted files, leaving the recovery
+	// to the user
+	CorruptionFail CorruptionPolicy = "fail"
+)
+
+// ParseCorruptionPolicy parses a corruption policy name. An empty name
+// selects CorruptionRecover.
+func ParseCorruptionPolicy(name string) (CorruptionPolicy, error) {
+	switch CorruptionPolicy(name) {
+	case "", CorruptionRecover:
+		return CorruptionRecover, nil
+	case CorruptionFail:
+		return CorruptionFail, nil
+	}
+	return "", fmt.Errorf("unknown corruption policy %q", name)
+}
+
+// ErrCorrupted is returned for storage files failing their integrity check
+var ErrCorrupted = errors.New("storage file is corrupted")
+
+const (
+	// Every storage file has a checksum file in sha256sum format next to it
+	checksumSuffix = ".sha256"
+	corruptSuffix  = ".corrupt"
+
+	backupPrefix = "backup_"
+	// Number of backups taken on start that are kept
+	backupsKept = 5
+)
+
+// SetCorruptionPolicy sets how corrupted files are handled. Must be called
+// before Start.
+func (s *JSONStorage) SetCorruptionPolicy(policy CorruptionPolicy) {
+	s.corruptionPolicy = policy
+}
+
+// readFile reads a storage file, verifying its checksum and decrypting it.
+// Errors of os.ReadFile are returned unwrapped.
+func (s *JSONStorage) readFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyChecksum(path, data); err != nil {
+		return nil, err
+	}
+
+	plain, stale, err := s.cipher.Open(data)
+	if err != nil {
+		return nil, err
+	}
+	if stale {
+		// Re-encrypt with the current key
+		if err := s.writeFile(path, plain); err != nil {
+			return nil, err
+		}
+	}
+	return plain, nil
+}
+
+// writeFile encrypts data and writes it with its checksum
+func (s *JSONStorage) writeFile(path string, data []byte) error {
+	sealed, err := s.cipher.Seal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt %s: %w", path, err)
+	}
+	return writeWithChecksum(path, sealed)
+}
+
+// writeWithChecksum atomically replaces a file and its checksum file
+func writeWithChecksum(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+
+	sum := sha256.Sum256(data)
+	checksum := fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]), filepath.Base(path))
+
+	// Write to temporary files first
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write temporary file %s: %w", tmpPath, err)
+	}
+	tmpChecksumPath := path + checksumSuffix + ".tmp"
+	if err := os.WriteFile(tmpChecksumPath, []byte(checksum), 0644); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write temporary file %s: %w", tmpChecksumPath, err)
+	}
+
+	// Atomic rename
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		os.Remove(tmpChecksumPath)
+		return fmt.Errorf("failed to rename temporary file: %w", err)
+	}
+	if err := os.Rename(tmpChecksumPath, path+checksumSuffix); err != nil {
+		os.Remove(tmpChecksumPath)
+		return fmt.Errorf("failed to rename temporary file: %w", err)
+	}
+
+	return nil
+}
+
+// verifyChecksum compares data with the checksum file of path. Files of
+// older versions have no checksum file.
+func verifyChecksum(path string, data []byte) error {
+	checksum, err := os.ReadFile(path + checksumSuffix)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read checksum of %s: %w", path, err)
+	}
+
+	want, _, _ := strings.Cut(string(checksum), " ")
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != want {
+		return fmt.Errorf("%w: checksum mismatch", ErrCorrupted)
+	}
+	return nil
+}
+
+// loadVerified loads a JSON file below the storage directory, handling
+// corruption as the corruption policy says. Unrecoverable files are moved
+// aside so they are never overwritten, and the error is returned.
+func (s *JSONStorage) loadVerified(name string, target interface{}) error {
+	path := filepath.Join(s.basePath, name)
+	err := s.loadJSONFile(path, target)
+	if !errors.Is(err, ErrCorrupted) {
+		return err
+	}
+
+	s.corrupted = true
+	if s.corruptionPolicy == CorruptionFail {
+		return fmt.Errorf("%w, restore it from a %s* directory or remove it", err, backupPrefix)
+	}
+
+	s.logger.Error("Storage file is corrupted", logger.String("file", name), logger.ErrorField(err))
+	if err := os.Rename(path, path+corruptSuffix); err != nil {
+		return fmt.Errorf("failed to move corrupted file aside: %w", err)
+	}
+	os.Remove(path + checksumSuffix)
+
+	backup, restoreErr := s.restoreFromBackup(name)
+	if restoreErr != nil {
+		return fmt.Errorf("%w: %v", err, restoreErr)
+	}
+	s.logger.Warn("Restored storage file from backup",
+		logger.String("file", name),
+		logger.String("backup", backup),
+	)
+	return s.loadJSONFile(path, target)
+}
+
+// restoreFromBackup copies the newest intact copy of a storage file from the
+// backups and returns the backup directory it was taken from
+func (s *JSONStorage) restoreFromBackup(name string) (string, error) {
+	backups, err := s.backups()
+	if err != nil {
+		return "", err
+	}
+
+	for i := len(backups) - 1; i >= 0; i-- {
+		src := filepath.Join(s.basePath, backups[i], name)
+		data, err := os.ReadFile(src)
+		if err != nil || verifyChecksum(src, data) != nil {
+			continue
+		}
+		if plain, _, err := s.cipher.Open(data); err != nil || !json.Valid(plain) {
+			continue
+		}
+
+		if err := writeWithChecksum(filepath.Join(s.basePath, name), data); err != nil {
+			return "", err
+		}
+		return backups[i], nil
+	}
+
+	return "", fmt.Errorf("no intact backup of %s", name)
+}
+
+// backups lists the backup directories, oldest first
+func (s *JSONStorage) backups() ([]string, error) {
+	entries, err := os.ReadDir(s.basePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	var backups []string
+	for _, entry := range entries {
+		if entry.IsDir() && strings.HasPrefix(entry.Name(), backupPrefix) {
+			backups = append(backups, entry.Name())
+		}
+	}
+	// The timestamp format sorts chronologically
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// pruneBackups removes all but the newest backupsKept backups
+func (s *JSONStorage) pruneBackups() {
+	backups, err := s.backups()
+	if err != nil {
+		s.logger.Warn("Failed to prune backups", logger.ErrorField(err))
+		return
+	}
+
+	for len(backups) > backupsKept {
+		if err := os.RemoveAll(filepath.Join(s.basePath, backups[0])); err != nil {
+			s.logger.Warn("Failed to remove backup", logger.String("backup", backups[0]), logger.ErrorField(err))
+		}
+		backups = backups[1:]
+	}
+}
@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// newBackedUpStorage stores a node and a vendor, then restarts the storage
+// so a backup is taken
+func newBackedUpStorage(t *testing.T) string {
+	t.Helper()
+	tempDir := t.TempDir()
+	log := logger.NewConsoleLogger(logger.FatalLevel)
+
+	storage := NewJSONStorage(tempDir, log)
+	if err := storage.Start(); err != nil {
+		t.Fatalf("Failed to start storage: %v", err)
+	}
+	if err := storage.SaveNode(&models.MatterNodeData{NodeID: 1, InterviewVersion: 3}); err != nil {
+		t.Fatalf("Failed to save node: %v", err)
+	}
+	if err := storage.SaveVendor(&models.VendorInfo{VendorID: 4937, VendorName: "Acme"}); err != nil {
+		t.Fatalf("Failed to save vendor: %v", err)
+	}
+	storage.Stop()
+
+	restarted := NewJSONStorage(tempDir, log)
+	if err := restarted.Start(); err != nil {
+		t.Fatalf("Failed to restart storage: %v", err)
+	}
+	restarted.Stop()
+	return tempDir
+}
+
+func startStorage(t *testing.T, dir string, policy CorruptionPolicy) (*JSONStorage, error) {
+	t.Helper()
+	storage := NewJSONStorage(dir, logger.NewConsoleLogger(logger.FatalLevel))
+	storage.SetCorruptionPolicy(policy)
+	err := storage.Start()
+	if err == nil {
+		t.Cleanup(func() { storage.Stop() })
+	}
+	return storage, err
+}
+
+func countBackups(t *testing.T, dir string) int {
+	t.Helper()
+	matches, _ := filepath.Glob(filepath.Join(dir, backupPrefix+"*"))
+	return len(matches)
+}
+
+func TestChecksumFiles(t *testing.T) {
+	dir := newBackedUpStorage(t)
+
+	checksum, err := os.ReadFile(filepath.Join(dir, "vendors.json.sha256"))
+	if err != nil {
+		t.Fatalf("Expected checksum file: %v", err)
+	}
+	if !strings.HasSuffix(string(checksum), "  vendors.json\n") {
+		t.Errorf("Expected sha256sum format, got %q", checksum)
+	}
+	if countBackups(t, dir) != 1 {
+		t.Errorf("Expected a backup on start, got %d", countBackups(t, dir))
+	}
+}
+
+func TestRecoverCorruptedFile(t *testing.T) {
+	dir := newBackedUpStorage(t)
+
+	// Truncated JSON without a checksum file, as written by older versions
+	vendorsPath := filepath.Join(dir, "vendors.json")
+	os.Remove(vendorsPath + checksumSuffix)
+	if err := os.WriteFile(vendorsPath, []byte(`{"4937": {"vendor_id"`), 0644); err != nil {
+		t.Fatalf("Failed to corrupt file: %v", err)
+	}
+	// Valid JSON failing its checksum
+	nodePath := filepath.Join(dir, "nodes", "1.json")
+	if err := os.WriteFile(nodePath, []byte(`{"node_id": 1}`), 0644); err != nil {
+		t.Fatalf("Failed to corrupt file: %v", err)
+	}
+
+	storage, err := startStorage(t, dir, CorruptionRecover)
+	if err != nil {
+		t.Fatalf("Expected recovery, got %v", err)
+	}
+	if vendor, err := storage.GetVendor(4937); err != nil || vendor.VendorName != "Acme" {
+		t.Errorf("Expected vendor restored from backup, got %+v (%v)", vendor, err)
+	}
+	if node, err := storage.GetNode(1); err != nil || node.InterviewVersion != 3 {
+		t.Errorf("Expected node restored from backup, got %+v (%v)", node, err)
+	}
+	for _, path := range []string{vendorsPath, nodePath} {
+		if _, err := os.Stat(path + corruptSuffix); err != nil {
+			t.Errorf("Expected corrupted file to be kept: %v", err)
+		}
+	}
+	if countBackups(t, dir) != 1 {
+		t.Error("Expected no backup of corrupted data")
+	}
+}
+
+func TestCorruptedFileWithoutBackup(t *testing.T) {
+	dir := newBackedUpStorage(t)
+	backups, _ := filepath.Glob(filepath.Join(dir, backupPrefix+"*"))
+	for _, backup := range backups {
+		os.RemoveAll(backup)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "vendors.json"), []byte("{"), 0644); err != nil {
+		t.Fatalf("Failed to corrupt file: %v", err)
+	}
+
+	storage, err := startStorage(t, dir, CorruptionRecover)
+	if err != nil {
+		t.Fatalf("Expected start without the corrupted data, got %v", err)
+	}
+	if vendors, _ := storage.GetVendors(); len(vendors) != 0 {
+		t.Errorf("Expected no vendors, got %d", len(vendors))
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "vendors.json"+corruptSuffix)); err != nil || string(data) != "{" {
+		t.Errorf("Expected corrupted file to be kept, got %q (%v)", data, err)
+	}
+}
+
+func TestCorruptionPolicyFail(t *testing.T) {
+	dir := newBackedUpStorage(t)
+	vendorsPath := filepath.Join(dir, "vendors.json")
+	if err := os.WriteFile(vendorsPath, []byte("{}"), 0644); err != nil {
+		t.Fatalf("Failed to corrupt file: %v", err)
+	}
+
+	if _, err := startStorage(t, dir, CorruptionFail); !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("Expected ErrCorrupted, got %v", err)
+	}
+	if _, err := os.Stat(vendorsPath + corruptSuffix); !os.IsNotExist(err) {
+		t.Error("Expected corrupted file to be left alone")
+	}
+
+	// Corrupted node files are reported when the nodes are read
+	if err := writeWithChecksum(vendorsPath, []byte("{}")); err != nil {
+		t.Fatalf("Failed to repair file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "nodes", "1.json"), nil, 0644); err != nil {
+		t.Fatalf("Failed to corrupt file: %v", err)
+	}
+	storage, err := startStorage(t, dir, CorruptionFail)
+	if err != nil {
+		t.Fatalf("Failed to start storage: %v", err)
+	}
+	if _, err := storage.GetNodes(); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("Expected ErrCorrupted from GetNodes, got %v", err)
+	}
+}
+
+func TestPruneBackups(t *testing.T) {
+	tempDir := t.TempDir()
+	for _, name := range []string{"2024-01-01_00-00-00", "2024-01-02_00-00-00", "2024-01-03_00-00-00",
+		"2024-01-04_00-00-00", "2024-01-05_00-00-00", "2024-01-06_00-00-00"} {
+		os.MkdirAll(filepath.Join(tempDir, backupPrefix+name), 0755)
+	}
+
+	storage := NewJSONStorage(tempDir, logger.NewConsoleLogger(logger.FatalLevel))
+	storage.pruneBackups()
+
+	backups, _ := storage.backups()
+	if len(backups) != backupsKept || backups[0] != backupPrefix+"2024-01-02_00-00-00" {
+		t.Errorf("Expected the %d newest backups, got %v", backupsKept, backups)
+	}
+}
+
+func TestParseCorruptionPolicy(t *testing.T) {
+	for name, want := range map[string]CorruptionPolicy{"": CorruptionRecover, "recover": CorruptionRecover, "fail": CorruptionFail} {
+		if got, err := ParseCorruptionPolicy(name); err != nil || got != want {
+			t.Errorf("Expected %q for %q, got %q (%v)", want, name, got, err)
+		}
+	}
+	if _, err := ParseCorruptionPolicy("ignore"); err == nil {
+		t.Error("Expected error for unknown policy")
+	}
+}
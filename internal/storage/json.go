@@ -2,9 +2,13 @@ package storage
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,16 +16,52 @@ import (
 	"github.com/codefionn/go-matter-server/internal/models"
 )
 
+const (
+	// Each node is stored in nodes/<node_id>.json, listed in nodes/index.json
+	nodesDir       = "nodes"
+	nodeIndexFile  = "index.json"
+	legacyNodeFile = "nodes.json"
+)
+
 // JSONStorage implements storage using JSON files
 type JSONStorage struct {
 	basePath string
 	logger   *logger.Logger
 	mu       sync.RWMutex
 
-	// In-memory cache
+	// In-memory cache, nodes are read from their files on first access
 	nodes    map[int]*models.MatterNodeData
+	nodeIDs  map[int]struct{}
 	vendors  map[int]*models.VendorInfo
 	settings map[string]interface{}
+
+	// Node files, index and vendors not written yet
+	dirtyNodes   map[int]struct{}
+	indexDirty   bool
+	vendorsDirty bool
+
+	// Bounded history of emitted events, oldest first
+	events           []*models.EventHistoryEntry
+	eventHistorySize int
+	eventsDirty      bool
+
+	// Recorded attribute values, read per node on first access
+	history          map[int]nodeAttributeHistory
+	dirtyHistory     map[int]struct{}
+	historyRetention time.Duration
+	historyInterval  time.Duration
+
+	// Encrypts the files, nil stores them unencrypted
+	cipher *Cipher
+
+	corruptionPolicy CorruptionPolicy
+	// A corrupted file was found, so no backup is taken on start
+	corrupted bool
+
+	// Write-behind of node updates, disabled when flushInterval is 0
+	flushInterval time.Duration
+	stopFlush     chan struct{}
+	flushDone     chan struct{}
 }
 
 // Storage interface defines storage operations
@@ -30,18 +70,31 @@ type Storage interface {
 	GetNode(nodeID int) (*models.MatterNodeData, error)
 	GetNodes() ([]*models.MatterNodeData, error)
 	SaveNode(node *models.MatterNodeData) error
+	// SaveNodes stores several nodes with a single write of the index
+	SaveNodes(nodes []*models.MatterNodeData) error
 	DeleteNode(nodeID int) error
 
 	// Vendor operations
 	GetVendor(vendorID int) (*models.VendorInfo, error)
 	GetVendors() ([]*models.VendorInfo, error)
 	SaveVendor(vendor *models.VendorInfo) error
+	// SaveVendors stores several vendors with a single write
+	SaveVendors(vendors []*models.VendorInfo) error
 
 	// Settings operations
 	GetSetting(key string) (interface{}, error)
+	GetSettings() (map[string]interface{}, error)
 	SaveSetting(key string, value interface{}) error
 	DeleteSetting(key string) error
 
+	// Event history operations
+	AddEvent(entry *models.EventHistoryEntry) error
+	GetEvents() ([]*models.EventHistoryEntry, error)
+
+	// Attribute history operations
+	RecordAttribute(nodeID int, path string, value interface{}, at time.Time) error
+	GetAttributeHistory(nodeID int, path string) ([]models.AttributeSample, error)
+
 	// Lifecycle
 	Start() error
 	Stop() error
@@ -51,14 +104,35 @@ type Storage interface {
 // NewJSONStorage creates a new JSON storage instance
 func NewJSONStorage(basePath string, log *logger.Logger) *JSONStorage {
 	return &JSONStorage{
-		basePath: basePath,
-		logger:   log,
-		nodes:    make(map[int]*models.MatterNodeData),
-		vendors:  make(map[int]*models.VendorInfo),
-		settings: make(map[string]interface{}),
+		basePath:   basePath,
+		logger:     log,
+		nodes:      make(map[int]*models.MatterNodeData),
+		nodeIDs:    make(map[int]struct{}),
+		vendors:    make(map[int]*models.VendorInfo),
+		settings:   make(map[string]interface{}),
+		dirtyNodes: make(map[int]struct{}),
+
+		history:      make(map[int]nodeAttributeHistory),
+		dirtyHistory: make(map[int]struct{}),
+
+		corruptionPolicy: CorruptionRecover,
+		eventHistorySize: DefaultEventHistorySize,
 	}
 }
 
+// SetFlushInterval enables write-behind of node and vendor updates and the
+// event history. SaveNode and SaveVendor then only update the in-memory cache
+// and the files are written at most once per interval, on Sync and on Stop.
+// Must be called before Start.
+func (s *JSONStorage) SetFlushInterval(interval time.Duration) {
+	s.flushInterval = interval
+}
+
+// SetCipher encrypts the storage files. Must be called before Start.
+func (s *JSONStorage) SetCipher(c *Cipher) {
+	s.cipher = c
+}
+
 // Start initializes the storage and loads existing data
 func (s *JSONStorage) Start() error {
 	s.mu.Lock()
@@ -69,30 +143,103 @@ func (s *JSONStorage) Start() error {
 		return fmt.Errorf("failed to create storage directory: %w", err)
 	}
 
-	// Load existing data
+	// Load existing data. Unreadable files are skipped, unless the key is
+	// wrong or the corruption policy says to fail.
 	if err := s.loadNodes(); err != nil {
+		if s.isFatal(err) {
+			return err
+		}
 		s.logger.Warn("Failed to load nodes", logger.ErrorField(err))
 	}
 
 	if err := s.loadVendors(); err != nil {
+		if s.isFatal(err) {
+			return err
+		}
 		s.logger.Warn("Failed to load vendors", logger.ErrorField(err))
 	}
 
 	if err := s.loadSettings(); err != nil {
+		if s.isFatal(err) {
+			return err
+		}
 		s.logger.Warn("Failed to load settings", logger.ErrorField(err))
 	}
 
+	if err := s.loadEvents(); err != nil {
+		if s.isFatal(err) {
+			return err
+		}
+		s.logger.Warn("Failed to load event history", logger.ErrorField(err))
+	}
+
+	// Back up intact data, so corrupted files can be restored later
+	if !s.corrupted && len(s.nodeIDs)+len(s.vendors)+len(s.settings) > 0 {
+		if _, err := s.backup(); err != nil {
+			s.logger.Warn("Failed to back up storage", logger.ErrorField(err))
+		}
+		s.pruneBackups()
+	}
+
 	s.logger.Info("JSON storage started",
 		logger.String("path", s.basePath),
-		logger.Int("nodes", len(s.nodes)),
+		logger.Int("nodes", len(s.nodeIDs)),
 		logger.Int("vendors", len(s.vendors)),
 	)
 
+	if s.flushInterval > 0 {
+		s.stopFlush = make(chan struct{})
+		s.flushDone = make(chan struct{})
+		go s.flushLoop(s.stopFlush, s.flushDone)
+	}
+
 	return nil
 }
 
+// isFatal reports whether a load error must stop the storage from starting
+func (s *JSONStorage) isFatal(err error) bool {
+	return IsKeyError(err) || (s.corruptionPolicy == CorruptionFail && errors.Is(err, ErrCorrupted))
+}
+
+// flushLoop writes pending node updates and events every flush interval
+func (s *JSONStorage) flushLoop(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			err := s.flushNodes()
+			if err == nil {
+				err = s.flushVendors()
+			}
+			if err == nil {
+				err = s.flushEvents()
+			}
+			if err == nil {
+				err = s.flushAttributeHistory()
+			}
+			s.mu.Unlock()
+			if err != nil {
+				s.logger.Warn("Failed to flush storage", logger.ErrorField(err))
+			}
+		}
+	}
+}
+
 // Stop saves all data and closes storage
 func (s *JSONStorage) Stop() error {
+	if s.stopFlush != nil {
+		close(s.stopFlush)
+		<-s.flushDone
+		s.stopFlush = nil
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -104,7 +251,7 @@ func (s *JSONStorage) Stop() error {
 	return nil
 }
 
-// Sync writes all in-memory data to disk
+// Sync writes all in-memory data to disk, including pending node updates
 func (s *JSONStorage) Sync() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -113,7 +260,7 @@ func (s *JSONStorage) Sync() error {
 }
 
 func (s *JSONStorage) sync() error {
-	if err := s.saveNodes(); err != nil {
+	if err := s.flushNodes(); err != nil {
 		return fmt.Errorf("failed to save nodes: %w", err)
 	}
 
@@ -125,18 +272,26 @@ func (s *JSONStorage) sync() error {
 		return fmt.Errorf("failed to save settings: %w", err)
 	}
 
+	if err := s.flushEvents(); err != nil {
+		return fmt.Errorf("failed to save event history: %w", err)
+	}
+
+	if err := s.flushAttributeHistory(); err != nil {
+		return fmt.Errorf("failed to save attribute history: %w", err)
+	}
+
 	return nil
 }
 
 // Node operations
 
 func (s *JSONStorage) GetNode(nodeID int) (*models.MatterNodeData, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	node, exists := s.nodes[nodeID]
-	if !exists {
-		return nil, fmt.Errorf("node %d not found", nodeID)
+	node, err := s.node(nodeID)
+	if err != nil {
+		return nil, err
 	}
 
 	// Return a copy to prevent external modification
@@ -145,11 +300,19 @@ func (s *JSONStorage) GetNode(nodeID int) (*models.MatterNodeData, error) {
 }
 
 func (s *JSONStorage) GetNodes() ([]*models.MatterNodeData, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	nodes := make([]*models.MatterNodeData, 0, len(s.nodes))
-	for _, node := range s.nodes {
+	nodes := make([]*models.MatterNodeData, 0, len(s.nodeIDs))
+	for nodeID := range s.nodeIDs {
+		node, err := s.node(nodeID)
+		if err != nil {
+			if s.isFatal(err) {
+				return nil, err
+			}
+			s.logger.Warn("Failed to load node", logger.Int("node_id", nodeID), logger.ErrorField(err))
+			continue
+		}
 		nodeCopy := *node
 		nodes = append(nodes, &nodeCopy)
 	}
@@ -158,14 +321,28 @@ func (s *JSONStorage) GetNodes() ([]*models.MatterNodeData, error) {
 }
 
 func (s *JSONStorage) SaveNode(node *models.MatterNodeData) error {
+	return s.SaveNodes([]*models.MatterNodeData{node})
+}
+
+func (s *JSONStorage) SaveNodes(nodes []*models.MatterNodeData) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Store a copy to prevent external modification
-	nodeCopy := *node
-	s.nodes[node.NodeID] = &nodeCopy
+	for _, node := range nodes {
+		// Store a copy to prevent external modification
+		nodeCopy := *node
+		s.nodes[node.NodeID] = &nodeCopy
+		s.dirtyNodes[node.NodeID] = struct{}{}
+		if _, exists := s.nodeIDs[node.NodeID]; !exists {
+			s.nodeIDs[node.NodeID] = struct{}{}
+			s.indexDirty = true
+		}
+	}
 
-	return s.saveNodes()
+	if s.flushInterval > 0 {
+		return nil
+	}
+	return s.flushNodes()
 }
 
 func (s *JSONStorage) DeleteNode(nodeID int) error {
@@ -173,7 +350,47 @@ func (s *JSONStorage) DeleteNode(nodeID int) error {
 	defer s.mu.Unlock()
 
 	delete(s.nodes, nodeID)
-	return s.saveNodes()
+	delete(s.dirtyNodes, nodeID)
+	if err := s.deleteAttributeHistory(nodeID); err != nil {
+		return err
+	}
+	if _, exists := s.nodeIDs[nodeID]; !exists {
+		return nil
+	}
+	delete(s.nodeIDs, nodeID)
+
+	// Drop the node from the index first, so the index never lists a node
+	// without a file
+	if err := s.saveNodeIndex(); err != nil {
+		return err
+	}
+	if err := os.Remove(s.nodePath(nodeID)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove node %d: %w", nodeID, err)
+	}
+	os.Remove(s.nodePath(nodeID) + checksumSuffix)
+	return nil
+}
+
+// node returns a stored node, reading its file on first access. Must be
+// called with the write lock held.
+func (s *JSONStorage) node(nodeID int) (*models.MatterNodeData, error) {
+	if node, loaded := s.nodes[nodeID]; loaded {
+		return node, nil
+	}
+	if _, exists := s.nodeIDs[nodeID]; !exists {
+		return nil, fmt.Errorf("node %d not found", nodeID)
+	}
+
+	if _, err := os.Stat(s.nodePath(nodeID)); err != nil {
+		return nil, fmt.Errorf("failed to read node %d: %w", nodeID, err)
+	}
+	var node models.MatterNodeData
+	if err := s.loadVerified(filepath.Join(nodesDir, strconv.Itoa(nodeID)+".json"), &node); err != nil {
+		return nil, fmt.Errorf("failed to load node %d: %w", nodeID, err)
+	}
+
+	s.nodes[nodeID] = &node
+	return &node, nil
 }
 
 // Vendor operations
@@ -205,13 +422,23 @@ func (s *JSONStorage) GetVendors() ([]*models.VendorInfo, error) {
 }
 
 func (s *JSONStorage) SaveVendor(vendor *models.VendorInfo) error {
+	return s.SaveVendors([]*models.VendorInfo{vendor})
+}
+
+func (s *JSONStorage) SaveVendors(vendors []*models.VendorInfo) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	vendorCopy := *vendor
-	s.vendors[vendor.VendorID] = &vendorCopy
+	for _, vendor := range vendors {
+		vendorCopy := *vendor
+		s.vendors[vendor.VendorID] = &vendorCopy
+	}
+	s.vendorsDirty = true
 
-	return s.saveVendors()
+	if s.flushInterval > 0 {
+		return nil
+	}
+	return s.flushVendors()
 }
 
 // Settings operations
@@ -228,6 +455,18 @@ func (s *JSONStorage) GetSetting(key string) (interface{}, error) {
 	return value, nil
 }
 
+func (s *JSONStorage) GetSettings() (map[string]interface{}, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	settings := make(map[string]interface{}, len(s.settings))
+	for key, value := range s.settings {
+		settings[key] = value
+	}
+
+	return settings, nil
+}
+
 func (s *JSONStorage) SaveSetting(key string, value interface{}) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -246,29 +485,133 @@ func (s *JSONStorage) DeleteSetting(key string) error {
 
 // File operations
 
+func (s *JSONStorage) nodePath(nodeID int) string {
+	return filepath.Join(s.basePath, nodesDir, strconv.Itoa(nodeID)+".json")
+}
+
+// loadNodes reads the node index. Node files are read on first access.
 func (s *JSONStorage) loadNodes() error {
-	path := filepath.Join(s.basePath, "nodes.json")
-	return s.loadJSONFile(path, &s.nodes)
+	if err := os.MkdirAll(filepath.Join(s.basePath, nodesDir), 0755); err != nil {
+		return fmt.Errorf("failed to create nodes directory: %w", err)
+	}
+
+	path := filepath.Join(s.basePath, nodesDir, nodeIndexFile)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return s.rebuildNodeIndex()
+	}
+
+	var nodeIDs []int
+	if err := s.loadJSONFile(path, &nodeIDs); err != nil {
+		if IsKeyError(err) {
+			return err
+		}
+		s.logger.Warn("Failed to load node index, rebuilding it", logger.ErrorField(err))
+		return s.rebuildNodeIndex()
+	}
+	for _, nodeID := range nodeIDs {
+		s.nodeIDs[nodeID] = struct{}{}
+	}
+	return nil
 }
 
-func (s *JSONStorage) saveNodes() error {
-	path := filepath.Join(s.basePath, "nodes.json")
-	return s.saveJSONFile(path, s.nodes)
+// rebuildNodeIndex recreates a missing node index from the node files and
+// moves nodes from the nodes.json of older versions into their own files
+func (s *JSONStorage) rebuildNodeIndex() error {
+	entries, err := os.ReadDir(filepath.Join(s.basePath, nodesDir))
+	if err != nil {
+		return fmt.Errorf("failed to read nodes directory: %w", err)
+	}
+	for _, entry := range entries {
+		name, isJSON := strings.CutSuffix(entry.Name(), ".json")
+		if nodeID, err := strconv.Atoi(name); isJSON && err == nil {
+			s.nodeIDs[nodeID] = struct{}{}
+		}
+	}
+
+	legacyPath := filepath.Join(s.basePath, legacyNodeFile)
+	legacy := make(map[int]*models.MatterNodeData)
+	if err := s.loadVerified(legacyNodeFile, &legacy); err != nil {
+		return err
+	}
+	for nodeID, node := range legacy {
+		// A node file is newer than nodes.json
+		if _, exists := s.nodeIDs[nodeID]; exists || node == nil {
+			continue
+		}
+		s.nodes[nodeID] = node
+		s.nodeIDs[nodeID] = struct{}{}
+		s.dirtyNodes[nodeID] = struct{}{}
+	}
+
+	s.indexDirty = true
+	if err := s.flushNodes(); err != nil {
+		return err
+	}
+
+	if len(legacy) > 0 {
+		if err := os.Rename(legacyPath, legacyPath+".migrated"); err != nil {
+			return fmt.Errorf("failed to rename %s: %w", legacyPath, err)
+		}
+		os.Remove(legacyPath + checksumSuffix)
+		s.logger.Info("Moved nodes into per-node files", logger.Int("nodes", len(legacy)))
+	}
+	return nil
+}
+
+func (s *JSONStorage) saveNodeIndex() error {
+	nodeIDs := make([]int, 0, len(s.nodeIDs))
+	for nodeID := range s.nodeIDs {
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	sort.Ints(nodeIDs)
+
+	path := filepath.Join(s.basePath, nodesDir, nodeIndexFile)
+	if err := s.saveJSONFile(path, nodeIDs); err != nil {
+		return err
+	}
+	s.indexDirty = false
+	return nil
+}
+
+// flushNodes writes the files of changed nodes, then the index if nodes were
+// added
+func (s *JSONStorage) flushNodes() error {
+	for nodeID := range s.dirtyNodes {
+		if err := s.saveJSONFile(s.nodePath(nodeID), s.nodes[nodeID]); err != nil {
+			return fmt.Errorf("failed to save node %d: %w", nodeID, err)
+		}
+		delete(s.dirtyNodes, nodeID)
+	}
+
+	if s.indexDirty {
+		return s.saveNodeIndex()
+	}
+	return nil
 }
 
 func (s *JSONStorage) loadVendors() error {
-	path := filepath.Join(s.basePath, "vendors.json")
-	return s.loadJSONFile(path, &s.vendors)
+	return s.loadVerified("vendors.json", &s.vendors)
 }
 
 func (s *JSONStorage) saveVendors() error {
 	path := filepath.Join(s.basePath, "vendors.json")
-	return s.saveJSONFile(path, s.vendors)
+	if err := s.saveJSONFile(path, s.vendors); err != nil {
+		return err
+	}
+	s.vendorsDirty = false
+	return nil
+}
+
+// flushVendors writes the vendors if they changed
+func (s *JSONStorage) flushVendors() error {
+	if !s.vendorsDirty {
+		return nil
+	}
+	return s.saveVendors()
 }
 
 func (s *JSONStorage) loadSettings() error {
-	path := filepath.Join(s.basePath, "settings.json")
-	return s.loadJSONFile(path, &s.settings)
+	return s.loadVerified("settings.json", &s.settings)
 }
 
 func (s *JSONStorage) saveSettings() error {
@@ -277,7 +620,7 @@ func (s *JSONStorage) saveSettings() error {
 }
 
 func (s *JSONStorage) loadJSONFile(path string, target interface{}) error {
-	data, err := os.ReadFile(path)
+	data, err := s.readFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil // File doesn't exist, that's OK
@@ -290,7 +633,7 @@ func (s *JSONStorage) loadJSONFile(path string, target interface{}) error {
 	}
 
 	if err := json.Unmarshal(data, target); err != nil {
-		return fmt.Errorf("failed to unmarshal JSON from %s: %w", path, err)
+		return fmt.Errorf("failed to unmarshal JSON from %s: %w: %v", path, ErrCorrupted, err)
 	}
 
 	return nil
@@ -302,46 +645,65 @@ func (s *JSONStorage) saveJSONFile(path string, data interface{}) error {
 		return fmt.Errorf("failed to marshal JSON: %w", err)
 	}
 
-	// Write to temporary file first
-	tmpPath := path + ".tmp"
-	if err := os.WriteFile(tmpPath, jsonData, 0644); err != nil {
-		return fmt.Errorf("failed to write temporary file %s: %w", tmpPath, err)
-	}
-
-	// Atomic rename
-	if err := os.Rename(tmpPath, path); err != nil {
-		os.Remove(tmpPath)
-		return fmt.Errorf("failed to rename temporary file: %w", err)
-	}
-
-	return nil
+	return s.writeFile(path, jsonData)
 }
 
 // BackupData creates a backup of all stored data
 func (s *JSONStorage) BackupData() error {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The backup must include pending node updates
+	if err := s.flushNodes(); err != nil {
+		return fmt.Errorf("failed to flush nodes: %w", err)
+	}
+	if err := s.flushVendors(); err != nil {
+		return fmt.Errorf("failed to flush vendors: %w", err)
+	}
+	if err := s.flushEvents(); err != nil {
+		return fmt.Errorf("failed to flush event history: %w", err)
+	}
+	if err := s.flushAttributeHistory(); err != nil {
+		return fmt.Errorf("failed to flush attribute history: %w", err)
+	}
 
+	_, err := s.backup()
+	return err
+}
+
+// backup copies the data files and their checksums into a new backup
+// directory. Must be called with the write lock held.
+func (s *JSONStorage) backup() (string, error) {
 	timestamp := time.Now().Format("2006-01-02_15-04-05")
-	backupPath := filepath.Join(s.basePath, "backup_"+timestamp)
+	backupPath := filepath.Join(s.basePath, backupPrefix+timestamp)
 
-	if err := os.MkdirAll(backupPath, 0755); err != nil {
-		return fmt.Errorf("failed to create backup directory: %w", err)
+	for _, dir := range []string{nodesDir, attributeHistoryDir} {
+		if err := os.MkdirAll(filepath.Join(backupPath, dir), 0755); err != nil {
+			return "", fmt.Errorf("failed to create backup directory: %w", err)
+		}
 	}
 
 	// Copy all data files
-	files := []string{"nodes.json", "vendors.json", "settings.json"}
+	files := []string{"vendors.json", "settings.json", eventsFile, filepath.Join(nodesDir, nodeIndexFile)}
+	for nodeID := range s.nodeIDs {
+		files = append(files,
+			filepath.Join(nodesDir, strconv.Itoa(nodeID)+".json"),
+			filepath.Join(attributeHistoryDir, strconv.Itoa(nodeID)+".json"),
+		)
+	}
 	for _, file := range files {
-		src := filepath.Join(s.basePath, file)
-		dst := filepath.Join(backupPath, file)
+		for _, name := range []string{file, file + checksumSuffix} {
+			src := filepath.Join(s.basePath, name)
+			dst := filepath.Join(backupPath, name)
 
-		if err := s.copyFile(src, dst); err != nil {
-			s.logger.Warn("Failed to backup file", logger.String("file", file), logger.ErrorField(err))
+			if err := s.copyFile(src, dst); err != nil {
+				s.logger.Warn("Failed to backup file", logger.String("file", name), logger.ErrorField(err))
+			}
 		}
 	}
 
 	s.logger.Info("Data backup created", logger.String("path", backupPath))
-	return nil
+	return backupPath, nil
 }
 
 func (s *JSONStorage) copyFile(src, dst string) error {
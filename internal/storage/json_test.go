@@ -1,8 +1,10 @@
 package storage
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -362,7 +364,7 @@ func TestStorageSync(t *testing.T) {
 	}
 
 	// Verify files exist
-	nodesFile := filepath.Join(tempDir, "nodes.json")
+	nodesFile := filepath.Join(tempDir, "nodes", "789.json")
 	if _, err := os.Stat(nodesFile); os.IsNotExist(err) {
 		t.Error("Nodes file not created after sync")
 	}
@@ -409,6 +411,9 @@ func TestBackupData(t *testing.T) {
 	for _, entry := range entries {
 		if entry.IsDir() && strings.Contains(entry.Name(), "backup_") {
 			backupFound = true
+			if _, err := os.Stat(filepath.Join(tempDir, entry.Name(), "nodes", "999.json")); err != nil {
+				t.Errorf("Expected node file in backup: %v", err)
+			}
 			break
 		}
 	}
@@ -453,3 +458,375 @@ func TestLoadNonExistentFiles(t *testing.T) {
 func intPtr(i int) *int {
 	return &i
 }
+
+func TestWriteBehind(t *testing.T) {
+	tempDir := t.TempDir()
+	log := logger.NewConsoleLogger(logger.ErrorLevel)
+	nodesFile := filepath.Join(tempDir, "nodes", "1.json")
+
+	storage := NewJSONStorage(tempDir, log)
+	storage.SetFlushInterval(time.Hour)
+	if err := storage.Start(); err != nil {
+		t.Fatalf("Failed to start storage: %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		node := &models.MatterNodeData{NodeID: 1, InterviewVersion: i}
+		if err := storage.SaveNode(node); err != nil {
+			t.Fatalf("Failed to save node: %v", err)
+		}
+	}
+
+	if _, err := os.Stat(nodesFile); !os.IsNotExist(err) {
+		t.Error("Expected node updates not to be written before a flush")
+	}
+	if node, err := storage.GetNode(1); err != nil || node.InterviewVersion != 9 {
+		t.Errorf("Expected pending update to be readable, got %+v (%v)", node, err)
+	}
+
+	if err := storage.Sync(); err != nil {
+		t.Fatalf("Failed to sync storage: %v", err)
+	}
+	if _, err := os.Stat(nodesFile); err != nil {
+		t.Errorf("Expected nodes file after sync: %v", err)
+	}
+
+	// Pending updates are written on stop
+	if err := storage.SaveNode(&models.MatterNodeData{NodeID: 2}); err != nil {
+		t.Fatalf("Failed to save node: %v", err)
+	}
+	if err := storage.Stop(); err != nil {
+		t.Fatalf("Failed to stop storage: %v", err)
+	}
+
+	reloaded := NewJSONStorage(tempDir, log)
+	if err := reloaded.Start(); err != nil {
+		t.Fatalf("Failed to start storage: %v", err)
+	}
+	defer reloaded.Stop()
+	if nodes, _ := reloaded.GetNodes(); len(nodes) != 2 {
+		t.Errorf("Expected 2 persisted nodes, got %d", len(nodes))
+	}
+}
+
+func TestWriteBehindFlushesOnInterval(t *testing.T) {
+	tempDir := t.TempDir()
+
+	storage := NewJSONStorage(tempDir, logger.NewConsoleLogger(logger.ErrorLevel))
+	storage.SetFlushInterval(10 * time.Millisecond)
+	if err := storage.Start(); err != nil {
+		t.Fatalf("Failed to start storage: %v", err)
+	}
+	defer storage.Stop()
+
+	if err := storage.SaveNode(&models.MatterNodeData{NodeID: 1}); err != nil {
+		t.Fatalf("Failed to save node: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := os.Stat(filepath.Join(tempDir, "nodes", "1.json")); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected nodes to be flushed on the interval")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPerNodeFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	log := logger.NewConsoleLogger(logger.ErrorLevel)
+
+	storage := NewJSONStorage(tempDir, log)
+	if err := storage.Start(); err != nil {
+		t.Fatalf("Failed to start storage: %v", err)
+	}
+	for _, nodeID := range []int{3, 1, 2} {
+		if err := storage.SaveNode(&models.MatterNodeData{NodeID: nodeID}); err != nil {
+			t.Fatalf("Failed to save node: %v", err)
+		}
+	}
+	if err := storage.DeleteNode(2); err != nil {
+		t.Fatalf("Failed to delete node: %v", err)
+	}
+	storage.Stop()
+
+	index, err := os.ReadFile(filepath.Join(tempDir, "nodes", "index.json"))
+	if err != nil {
+		t.Fatalf("Failed to read node index: %v", err)
+	}
+	var nodeIDs []int
+	if err := json.Unmarshal(index, &nodeIDs); err != nil || len(nodeIDs) != 2 || nodeIDs[0] != 1 || nodeIDs[1] != 3 {
+		t.Errorf("Expected index [1 3], got %s", index)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "nodes", "2.json")); !os.IsNotExist(err) {
+		t.Error("Expected file of deleted node to be removed")
+	}
+
+	// Nodes are read on first access
+	reloaded := NewJSONStorage(tempDir, log)
+	if err := reloaded.Start(); err != nil {
+		t.Fatalf("Failed to start storage: %v", err)
+	}
+	defer reloaded.Stop()
+	if len(reloaded.nodes) != 0 {
+		t.Errorf("Expected no nodes loaded at start, got %d", len(reloaded.nodes))
+	}
+	if _, err := reloaded.GetNode(3); err != nil {
+		t.Errorf("Expected node 3: %v", err)
+	}
+	if len(reloaded.nodes) != 1 {
+		t.Errorf("Expected only node 3 to be loaded, got %d", len(reloaded.nodes))
+	}
+	if _, err := reloaded.GetNode(2); err == nil {
+		t.Error("Expected deleted node to be gone")
+	}
+}
+
+func TestLegacyNodesMigration(t *testing.T) {
+	tempDir := t.TempDir()
+	legacy := `{"1": {"node_id": 1, "interview_version": 4}, "2": {"node_id": 2}}`
+	if err := os.WriteFile(filepath.Join(tempDir, "nodes.json"), []byte(legacy), 0644); err != nil {
+		t.Fatalf("Failed to write nodes.json: %v", err)
+	}
+
+	storage := NewJSONStorage(tempDir, logger.NewConsoleLogger(logger.ErrorLevel))
+	if err := storage.Start(); err != nil {
+		t.Fatalf("Failed to start storage: %v", err)
+	}
+	defer storage.Stop()
+
+	for _, name := range []string{"nodes/index.json", "nodes/1.json", "nodes/2.json", "nodes.json.migrated"} {
+		if _, err := os.Stat(filepath.Join(tempDir, name)); err != nil {
+			t.Errorf("Expected %s after migration: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "nodes.json")); !os.IsNotExist(err) {
+		t.Error("Expected nodes.json to be moved aside")
+	}
+	if node, err := storage.GetNode(1); err != nil || node.InterviewVersion != 4 {
+		t.Errorf("Expected migrated node 1, got %+v (%v)", node, err)
+	}
+}
+
+func TestEventHistory(t *testing.T) {
+	tempDir := t.TempDir()
+	log := logger.NewConsoleLogger(logger.ErrorLevel)
+
+	storage := NewJSONStorage(tempDir, log)
+	storage.SetEventHistorySize(3)
+	if err := storage.Start(); err != nil {
+		t.Fatalf("Failed to start storage: %v", err)
+	}
+	for nodeID := 1; nodeID <= 5; nodeID++ {
+		entry := &models.EventHistoryEntry{Event: models.EventTypeNodeAdded, NodeID: nodeID}
+		if err := storage.AddEvent(entry); err != nil {
+			t.Fatalf("Failed to add event: %v", err)
+		}
+	}
+	if err := storage.Stop(); err != nil {
+		t.Fatalf("Failed to stop storage: %v", err)
+	}
+
+	// Only the newest events are kept, also when the history size shrinks
+	reopened := NewJSONStorage(tempDir, log)
+	reopened.SetEventHistorySize(2)
+	if err := reopened.Start(); err != nil {
+		t.Fatalf("Failed to start storage: %v", err)
+	}
+	events, err := reopened.GetEvents()
+	if err != nil {
+		t.Fatalf("Failed to get events: %v", err)
+	}
+	if len(events) != 2 || events[0].NodeID != 4 || events[1].NodeID != 5 {
+		t.Errorf("Expected events of nodes 4 and 5, got %+v", events)
+	}
+}
+
+func TestAttributeHistory(t *testing.T) {
+	tempDir := t.TempDir()
+	log := logger.NewConsoleLogger(logger.ErrorLevel)
+
+	storage := NewJSONStorage(tempDir, log)
+	storage.SetAttributeHistory(24*time.Hour, time.Minute)
+	if err := storage.Start(); err != nil {
+		t.Fatalf("Failed to start storage: %v", err)
+	}
+
+	minute := time.Now().Truncate(time.Minute)
+	records := []struct {
+		path  string
+		value interface{}
+		at    time.Time
+	}{
+		{"1/1026/0", int64(2000), minute.Add(-48 * time.Hour)}, // beyond the retention
+		{"1/1026/0", int64(2100), minute},
+		{"1/1026/0", float64(2200), minute.Add(30 * time.Second)},
+		{"1/1026/0", uint16(2300), minute.Add(time.Minute)},
+		{"1/6/0", true, minute},
+		{"1/6/0", false, minute.Add(10 * time.Second)},
+	}
+	for _, r := range records {
+		if err := storage.RecordAttribute(5, r.path, r.value, r.at); err != nil {
+			t.Fatalf("Failed to record attribute: %v", err)
+		}
+	}
+	if err := storage.Stop(); err != nil {
+		t.Fatalf("Failed to stop storage: %v", err)
+	}
+
+	reopened := NewJSONStorage(tempDir, log)
+	reopened.SetAttributeHistory(24*time.Hour, time.Minute)
+	if err := reopened.Start(); err != nil {
+		t.Fatalf("Failed to start storage: %v", err)
+	}
+	samples, err := reopened.GetAttributeHistory(5, "1/1026/0")
+	if err != nil {
+		t.Fatalf("Failed to get attribute history: %v", err)
+	}
+	if len(samples) != 2 {
+		t.Fatalf("Expected 2 samples, got %+v", samples)
+	}
+	if samples[0].Value != float64(2150) || samples[0].Count != 2 || !samples[0].Timestamp.Equal(minute) {
+		t.Errorf("Expected the average 2150 of 2 values, got %+v", samples[0])
+	}
+	if samples[1].Value != float64(2300) {
+		t.Errorf("Expected 2300, got %+v", samples[1])
+	}
+
+	samples, _ = reopened.GetAttributeHistory(5, "1/6/0")
+	if len(samples) != 1 || samples[0].Value != false {
+		t.Errorf("Expected the last non-numeric value, got %+v", samples)
+	}
+
+	// Deleting the node removes its attribute history
+	if err := reopened.DeleteNode(5); err != nil {
+		t.Fatalf("Failed to delete node: %v", err)
+	}
+	if samples, _ := reopened.GetAttributeHistory(5, "1/1026/0"); len(samples) != 0 {
+		t.Errorf("Expected no samples after deleting the node, got %+v", samples)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, attributeHistoryDir, "5.json")); !os.IsNotExist(err) {
+		t.Errorf("Expected the history file to be removed, got %v", err)
+	}
+}
+
+func TestBulkSave(t *testing.T) {
+	tempDir := t.TempDir()
+	log := logger.NewConsoleLogger(logger.ErrorLevel)
+
+	storage := NewJSONStorage(tempDir, log)
+	storage.SetFlushInterval(time.Hour)
+	if err := storage.Start(); err != nil {
+		t.Fatalf("Failed to start storage: %v", err)
+	}
+
+	if err := storage.SaveNodes([]*models.MatterNodeData{{NodeID: 1}, {NodeID: 2}}); err != nil {
+		t.Fatalf("Failed to save nodes: %v", err)
+	}
+	if err := storage.SaveVendors(testVendors(3)); err != nil {
+		t.Fatalf("Failed to save vendors: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "vendors.json")); !os.IsNotExist(err) {
+		t.Error("Expected vendor updates not to be written before a flush")
+	}
+	if err := storage.Stop(); err != nil {
+		t.Fatalf("Failed to stop storage: %v", err)
+	}
+
+	reloaded := NewJSONStorage(tempDir, log)
+	if err := reloaded.Start(); err != nil {
+		t.Fatalf("Failed to start storage: %v", err)
+	}
+	defer reloaded.Stop()
+	if nodes, _ := reloaded.GetNodes(); len(nodes) != 2 {
+		t.Errorf("Expected 2 persisted nodes, got %d", len(nodes))
+	}
+	if vendors, _ := reloaded.GetVendors(); len(vendors) != 3 {
+		t.Errorf("Expected 3 persisted vendors, got %d", len(vendors))
+	}
+}
+
+// testVendors returns n vendors, about the size of CSA vendor database
+// entries
+func testVendors(n int) []*models.VendorInfo {
+	vendors := make([]*models.VendorInfo, n)
+	for i := range vendors {
+		vendors[i] = &models.VendorInfo{
+			VendorID:             i + 1,
+			VendorName:           "Vendor " + strconv.Itoa(i+1),
+			CompanyLegalName:     "Vendor " + strconv.Itoa(i+1) + " Inc.",
+			CompanyPreferredName: "Vendor " + strconv.Itoa(i+1),
+			VendorLandingPageURL: "https://example.com/" + strconv.Itoa(i+1),
+			Creator:              "CSA",
+		}
+	}
+	return vendors
+}
+
+// The vendor database has about 500 entries. Saving them one at a time
+// rewrites vendors.json for each, saving them together writes it once.
+
+func BenchmarkSaveVendorOneByOne(b *testing.B) {
+	vendors := testVendors(500)
+	for i := 0; i < b.N; i++ {
+		storage := NewJSONStorage(b.TempDir(), logger.NewConsoleLogger(logger.ErrorLevel))
+		if err := storage.Start(); err != nil {
+			b.Fatal(err)
+		}
+		for _, vendor := range vendors {
+			if err := storage.SaveVendor(vendor); err != nil {
+				b.Fatal(err)
+			}
+		}
+		storage.Stop()
+	}
+}
+
+func BenchmarkSaveVendors(b *testing.B) {
+	vendors := testVendors(500)
+	for i := 0; i < b.N; i++ {
+		storage := NewJSONStorage(b.TempDir(), logger.NewConsoleLogger(logger.ErrorLevel))
+		if err := storage.Start(); err != nil {
+			b.Fatal(err)
+		}
+		if err := storage.SaveVendors(vendors); err != nil {
+			b.Fatal(err)
+		}
+		storage.Stop()
+	}
+}
+
+func BenchmarkSaveNodesOneByOne(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		storage := NewJSONStorage(b.TempDir(), logger.NewConsoleLogger(logger.ErrorLevel))
+		if err := storage.Start(); err != nil {
+			b.Fatal(err)
+		}
+		for nodeID := 1; nodeID <= 100; nodeID++ {
+			if err := storage.SaveNode(&models.MatterNodeData{NodeID: nodeID}); err != nil {
+				b.Fatal(err)
+			}
+		}
+		storage.Stop()
+	}
+}
+
+func BenchmarkSaveNodes(b *testing.B) {
+	nodes := make([]*models.MatterNodeData, 100)
+	for i := range nodes {
+		nodes[i] = &models.MatterNodeData{NodeID: i + 1}
+	}
+	for i := 0; i < b.N; i++ {
+		storage := NewJSONStorage(b.TempDir(), logger.NewConsoleLogger(logger.ErrorLevel))
+		if err := storage.Start(); err != nil {
+			b.Fatal(err)
+		}
+		if err := storage.SaveNodes(nodes); err != nil {
+			b.Fatal(err)
+		}
+		storage.Stop()
+	}
+}
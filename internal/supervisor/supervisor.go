@@ -0,0 +1,173 @@
+// Package supervisor runs the long-running loops of the server's subsystems
+// and restarts them with backoff when they fail or panic, so a bug in one
+// receive loop doesn't silently disable a subsystem until the next restart.
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// Backoff before restarting a failed task. It doubles with every failure in
+// a row and is reset once a task ran for MaxBackoff.
+const (
+	DefaultInitialBackoff = time.Second
+	DefaultMaxBackoff     = time.Minute
+)
+
+// PanicError is the failure of a task that panicked
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Config configures a Supervisor
+type Config struct {
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Restarted is called when a task failed, before waiting for the
+	// backoff and restarting it
+	Restarted func(models.SubsystemRestart)
+	Logger    *logger.Logger
+}
+
+// Supervisor restarts failed tasks and keeps their status
+type Supervisor struct {
+	config Config
+	logger *logger.Logger
+
+	mu    sync.Mutex
+	tasks map[string]*models.SupervisedTask
+}
+
+// New creates a supervisor
+func New(config Config) *Supervisor {
+	if config.InitialBackoff <= 0 {
+		config.InitialBackoff = DefaultInitialBackoff
+	}
+	if config.MaxBackoff < config.InitialBackoff {
+		config.MaxBackoff = max(DefaultMaxBackoff, config.InitialBackoff)
+	}
+	if config.Logger == nil {
+		config.Logger = logger.NewConsoleLogger(logger.InfoLevel)
+	}
+	return &Supervisor{
+		config: config,
+		logger: config.Logger,
+		tasks:  make(map[string]*models.SupervisedTask),
+	}
+}
+
+// Go runs a task in a goroutine of its own, see Run
+func (s *Supervisor) Go(ctx context.Context, name string, task func(ctx context.Context) error) {
+	go s.Run(ctx, name, task)
+}
+
+// Run runs a task, a long-running loop, until it returns nil or ctx is done.
+// A task returning an error or panicking is restarted after the backoff. A
+// nil Supervisor runs the task once.
+func (s *Supervisor) Run(ctx context.Context, name string, task func(ctx context.Context) error) {
+	if s == nil {
+		task(ctx)
+		return
+	}
+
+	backoff := s.config.InitialBackoff
+	for {
+		s.setRunning(name, true)
+		started := time.Now()
+		err := runTask(ctx, task)
+		s.setRunning(name, false)
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+
+		if time.Since(started) >= s.config.MaxBackoff {
+			backoff = s.config.InitialBackoff
+		}
+		restart := s.recordFailure(name, err, backoff)
+		fields := []logger.Field{
+			logger.String("task", name),
+			logger.ErrorField(err),
+			logger.Int("restarts", restart.Restarts),
+			logger.Duration("backoff", backoff),
+		}
+		if panicErr, ok := err.(*PanicError); ok {
+			fields = append(fields, logger.String("stack", string(panicErr.Stack)))
+		}
+		s.logger.Error("Subsystem failed, restarting", fields...)
+		if s.config.Restarted != nil {
+			s.config.Restarted(restart)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, s.config.MaxBackoff)
+	}
+}
+
+// runTask runs a task once, turning a panic into a PanicError
+func runTask(ctx context.Context, task func(ctx context.Context) error) (err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			err = &PanicError{Value: value, Stack: debug.Stack()}
+		}
+	}()
+	return task(ctx)
+}
+
+func (s *Supervisor) setRunning(name string, running bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task, ok := s.tasks[name]
+	if !ok {
+		task = &models.SupervisedTask{Name: name}
+		s.tasks[name] = task
+	}
+	task.Running = running
+}
+
+// recordFailure counts the restart of a failed task
+func (s *Supervisor) recordFailure(name string, err error, backoff time.Duration) models.SubsystemRestart {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	task := s.tasks[name]
+	task.Restarts++
+	task.LastError = err.Error()
+	task.LastFailureAt = &now
+	return models.SubsystemRestart{
+		Name:      name,
+		Error:     task.LastError,
+		Restarts:  task.Restarts,
+		BackoffMs: backoff.Milliseconds(),
+	}
+}
+
+// Status returns the supervised tasks ordered by name
+func (s *Supervisor) Status() []models.SupervisedTask {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tasks := make([]models.SupervisedTask, 0, len(s.tasks))
+	for _, task := range s.tasks {
+		tasks = append(tasks, *task)
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].Name < tasks[j].Name
+	})
+	return tasks
+}
@@ -0,0 +1,97 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+func newTestSupervisor(restarts chan<- models.SubsystemRestart) *Supervisor {
+	return New(Config{
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     4 * time.Millisecond,
+		Restarted:      func(restart models.SubsystemRestart) { restarts <- restart },
+		Logger:         logger.NewConsoleLogger(logger.FatalLevel),
+	})
+}
+
+func TestRunRestartsFailedTasks(t *testing.T) {
+	restarts := make(chan models.SubsystemRestart, 10)
+	s := newTestSupervisor(restarts)
+
+	runs := 0
+	s.Run(context.Background(), "loop", func(ctx context.Context) error {
+		runs++
+		switch runs {
+		case 1:
+			panic("nil map")
+		case 2, 3:
+			return errors.New("socket closed")
+		}
+		return nil
+	})
+
+	if runs != 4 {
+		t.Fatalf("Expected the task to run until it returned nil, ran %d times", runs)
+	}
+	close(restarts)
+	var backoffs []int64
+	for restart := range restarts {
+		backoffs = append(backoffs, restart.BackoffMs)
+	}
+	// The backoff doubles up to the maximum
+	if !reflect.DeepEqual(backoffs, []int64{1, 2, 4}) {
+		t.Errorf("Expected 3 restarts with doubling backoff, got %v", backoffs)
+	}
+
+	status := s.Status()
+	if len(status) != 1 || status[0].Running || status[0].Restarts != 3 || status[0].LastError != "socket closed" || status[0].LastFailureAt == nil {
+		t.Errorf("Unexpected status %+v", status)
+	}
+}
+
+func TestRunPanicError(t *testing.T) {
+	restarts := make(chan models.SubsystemRestart, 1)
+	s := newTestSupervisor(restarts)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Run(ctx, "loop", func(ctx context.Context) error {
+			var m map[string]int
+			m["x"] = 1
+			return nil
+		})
+	}()
+
+	restart := <-restarts
+	if restart.Name != "loop" || restart.Restarts != 1 || restart.Error == "" {
+		t.Errorf("Unexpected restart %+v", restart)
+	}
+
+	// No restarts once ctx is done
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected Run to return once ctx is done")
+	}
+}
+
+func TestRunWithoutSupervisor(t *testing.T) {
+	var s *Supervisor
+	runs := 0
+	s.Run(context.Background(), "loop", func(ctx context.Context) error {
+		runs++
+		return errors.New("failed")
+	})
+	if runs != 1 {
+		t.Errorf("Expected a single run without a supervisor, got %d", runs)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build windows || plan9
+
+package systemd
+
+import "net"
+
+// Listeners returns none, there is no socket activation on this platform
+func Listeners() (map[string]net.Listener, error) {
+	return nil, nil
+}
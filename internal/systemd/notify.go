@@ -0,0 +1,68 @@
+// Package systemd implements the parts of the systemd service protocol the
+// server uses, without linking libsystemd: readiness and watchdog
+// notifications (sd_notify) and socket activation (sd_listen_fds).
+//
+// Outside of a systemd service the environment variables are missing and
+// everything here does nothing.
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Notification states, see sd_notify(3)
+const (
+	Ready    = "READY=1"
+	Stopping = "STOPPING=1"
+	Watchdog = "WATCHDOG=1"
+)
+
+// Status is the state describing the service in systemctl status
+func Status(status string) string {
+	return "STATUS=" + status
+}
+
+// Notify sends notification states, one per line, to the service manager.
+// It reports false without an error if the service manager doesn't expect
+// notifications (Type=notify unset).
+func Notify(states ...string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+	// Abstract socket namespace
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to the notify socket: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(strings.Join(states, "\n"))); err != nil {
+		return false, fmt.Errorf("failed to notify the service manager: %w", err)
+	}
+	return true, nil
+}
+
+// WatchdogInterval returns the WatchdogSec= of the service, 0 if the
+// watchdog isn't enabled for this process. Watchdog notifications are
+// expected at least every interval; sd_watchdog_enabled(3) recommends
+// sending them at half of it.
+func WatchdogInterval() time.Duration {
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
@@ -0,0 +1,422 @@
+// Package telemetry exports numeric attribute values to InfluxDB, or any
+// other endpoint accepting line protocol, turning the server into a metrics
+// source.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/clusters"
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// Backoff of writes after the endpoint failed, vars so tests can shorten
+// them
+var (
+	minRetry = time.Second
+	maxRetry = time.Minute
+)
+
+// Subscriber is the part of the Matter server the exporter uses
+type Subscriber interface {
+	Subscribe(callback models.EventCallback) func()
+}
+
+// Metric maps the values of attributes to a field of a measurement
+type Metric struct {
+	// Attribute is an "endpoint/cluster/attribute" pattern where each
+	// segment may be "*"
+	Attribute   string
+	Measurement string
+	// Field defaults to "value"
+	Field string
+	// Scale multiplies the attribute value, 0 means 1
+	Scale float64
+	// Tags are added to the points of the metric
+	Tags map[string]string
+}
+
+// DefaultMetrics are exported if no metrics are configured, converting the
+// measurements to °C, %, hPa, W, V and A
+var DefaultMetrics = []Metric{
+	{Attribute: attributePattern(clusters.TemperatureMeasurementClusterID, 0), Measurement: "temperature", Scale: 0.01},
+	{Attribute: attributePattern(clusters.RelativeHumidityMeasurementClusterID, 0), Measurement: "humidity", Scale: 0.01},
+	{Attribute: attributePattern(clusters.PressureMeasurementClusterID, 0), Measurement: "pressure"},
+	{Attribute: attributePattern(clusters.ElectricalPowerMeasurementClusterID, 8), Measurement: "power", Scale: 0.001},
+	{Attribute: attributePattern(clusters.ElectricalPowerMeasurementClusterID, 4), Measurement: "voltage", Scale: 0.001},
+	{Attribute: attributePattern(clusters.ElectricalPowerMeasurementClusterID, 5), Measurement: "current", Scale: 0.001},
+}
+
+func attributePattern(cluster, attribute int) string {
+	return fmt.Sprintf("*/%d/%d", cluster, attribute)
+}
+
+// Config holds the configuration of the exporter
+type Config struct {
+	// URL of the write endpoint, e.g.
+	// http://influxdb:8086/api/v2/write?org=home&bucket=matter
+	URL string
+	// Token is sent as "Authorization: Token <token>" if set, as InfluxDB 2
+	// expects
+	Token   string
+	Metrics []Metric
+	// Tags are added to every point
+	Tags map[string]string
+	// BatchSize is the largest number of points per write, a full batch is
+	// written right away
+	BatchSize int
+	// FlushInterval is how often buffered points are written
+	FlushInterval time.Duration
+	// BufferSize is the number of points kept while the endpoint is slow or
+	// down, the oldest are dropped beyond it
+	BufferSize int
+	// Timeout of a write
+	Timeout time.Duration
+	Logger  *logger.Logger
+}
+
+// Stats are the counters of the exporter
+type Stats struct {
+	// Buffered points not written yet
+	Buffered int `json:"buffered"`
+	// Written points
+	Written uint64 `json:"written"`
+	// Dropped points, because the buffer was full or the endpoint rejected
+	// them
+	Dropped uint64 `json:"dropped"`
+	// Failed writes
+	Failed uint64 `json:"failed"`
+}
+
+// metric is a Metric with its pattern split into segments
+type metric struct {
+	Metric
+	pattern []string
+}
+
+// Exporter writes numeric attribute_updated values to a line protocol
+// endpoint in batches. Events never wait for the endpoint: points are
+// buffered and the oldest dropped if the endpoint can't keep up.
+type Exporter struct {
+	config  Config
+	server  Subscriber
+	logger  *logger.Logger
+	client  *http.Client
+	metrics []metric
+
+	mu          sync.Mutex
+	buffer      []string
+	stats       Stats
+	started     bool
+	unsubscribe func()
+
+	// flush wakes the writer once a batch is full
+	flush chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// NewExporter creates an exporter for the events of a server
+func NewExporter(server Subscriber, config Config) (*Exporter, error) {
+	u, err := url.Parse(config.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid telemetry URL %q", config.URL)
+	}
+	if config.BatchSize <= 0 || config.BufferSize < config.BatchSize || config.FlushInterval <= 0 {
+		return nil, fmt.Errorf("invalid telemetry batching: batch size %d, buffer size %d, flush interval %s",
+			config.BatchSize, config.BufferSize, config.FlushInterval)
+	}
+	if config.Logger == nil {
+		config.Logger = logger.NewConsoleLogger(logger.InfoLevel)
+	}
+	if len(config.Metrics) == 0 {
+		config.Metrics = DefaultMetrics
+	}
+
+	e := &Exporter{
+		config: config,
+		server: server,
+		logger: config.Logger,
+		client: &http.Client{Timeout: config.Timeout},
+		flush:  make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	for _, m := range config.Metrics {
+		pattern := strings.Split(m.Attribute, "/")
+		if len(pattern) != 3 || m.Measurement == "" {
+			return nil, fmt.Errorf("invalid telemetry metric %q: expected an endpoint/cluster/attribute pattern and a measurement", m.Attribute)
+		}
+		if m.Field == "" {
+			m.Field = "value"
+		}
+		if m.Scale == 0 {
+			m.Scale = 1
+		}
+		e.metrics = append(e.metrics, metric{Metric: m, pattern: pattern})
+	}
+	return e, nil
+}
+
+// Start subscribes to the attribute updates and starts writing them
+func (e *Exporter) Start() {
+	e.mu.Lock()
+	e.unsubscribe = e.server.Subscribe(e.handleEvent)
+	e.started = true
+	e.mu.Unlock()
+	go e.run()
+}
+
+// Shutdown stops exporting and writes the buffered points, until ctx is
+// done
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	if e.unsubscribe != nil {
+		e.unsubscribe()
+		e.unsubscribe = nil
+	}
+	started := e.started
+	e.mu.Unlock()
+
+	select {
+	case <-e.stop:
+		return nil
+	default:
+	}
+	close(e.stop)
+	if started {
+		<-e.done
+	}
+
+	if err := e.writeAll(ctx); err != nil {
+		return fmt.Errorf("%d telemetry points not written: %w", e.Stats().Buffered, err)
+	}
+	return nil
+}
+
+// Stats returns the counters of the exporter
+func (e *Exporter) Stats() Stats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	stats := e.stats
+	stats.Buffered = len(e.buffer)
+	return stats
+}
+
+// handleEvent buffers the points of an attribute_updated event, which is
+// [node_id, attribute_path, value]
+func (e *Exporter) handleEvent(eventType models.EventType, data interface{}) {
+	if eventType != models.EventTypeAttributeUpdated {
+		return
+	}
+	update, ok := data.([]interface{})
+	if !ok || len(update) != 3 {
+		return
+	}
+	nodeID, ok := number(update[0])
+	path, isPath := update[1].(string)
+	value, isNumber := number(update[2])
+	if !ok || !isPath || !isNumber {
+		return
+	}
+
+	now := time.Now()
+	parts := strings.Split(path, "/")
+	for _, m := range e.metrics {
+		if !m.matches(parts) {
+			continue
+		}
+		tags := make(map[string]string, len(e.config.Tags)+len(m.Tags)+2)
+		for key, value := range e.config.Tags {
+			tags[key] = value
+		}
+		for key, value := range m.Tags {
+			tags[key] = value
+		}
+		tags["node_id"] = strconv.FormatInt(int64(nodeID), 10)
+		tags["endpoint_id"] = parts[0]
+
+		line, ok := point{measurement: m.Measurement, tags: tags, field: m.Field, value: value * m.Scale, time: now}.line()
+		if ok {
+			e.add(line)
+		}
+	}
+}
+
+func (m metric) matches(path []string) bool {
+	if len(path) != len(m.pattern) {
+		return false
+	}
+	for i, segment := range m.pattern {
+		if segment != "*" && segment != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// number converts the numeric values of events, which are float64 once
+// decoded from JSON but may be Go integers when emitted by the server
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// add buffers a line, dropping the oldest one if the buffer is full
+func (e *Exporter) add(line string) {
+	e.mu.Lock()
+	if len(e.buffer) >= e.config.BufferSize {
+		e.buffer = e.buffer[1:]
+		e.stats.Dropped++
+	}
+	e.buffer = append(e.buffer, line)
+	full := len(e.buffer) >= e.config.BatchSize
+	e.mu.Unlock()
+
+	if full {
+		select {
+		case e.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// run writes the buffer every flush interval and whenever a batch is full,
+// backing off while the endpoint fails
+func (e *Exporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(e.config.FlushInterval)
+	defer ticker.Stop()
+
+	var backoff time.Duration
+	var retryAt time.Time
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-ticker.C:
+		case <-e.flush:
+		}
+		if time.Now().Before(retryAt) {
+			continue
+		}
+
+		if err := e.writeAll(context.Background()); err != nil {
+			backoff = min(max(2*backoff, minRetry), maxRetry)
+			retryAt = time.Now().Add(backoff)
+			e.logger.Warn("Failed to write telemetry",
+				logger.ErrorField(err),
+				logger.String("retry_in", backoff.String()),
+			)
+		} else {
+			backoff = 0
+		}
+	}
+}
+
+// writeAll writes the buffer in batches until it is empty or a write fails
+func (e *Exporter) writeAll(ctx context.Context) error {
+	for {
+		e.mu.Lock()
+		n := min(len(e.buffer), e.config.BatchSize)
+		batch := e.buffer[:n:n]
+		e.buffer = e.buffer[n:]
+		e.mu.Unlock()
+		if n == 0 {
+			return nil
+		}
+
+		err := e.write(ctx, batch)
+		e.mu.Lock()
+		var rejected *rejectedError
+		switch {
+		case err == nil:
+			e.stats.Written += uint64(n)
+		case errors.As(err, &rejected):
+			// Sending the points again wouldn't help
+			e.stats.Failed++
+			e.stats.Dropped += uint64(n)
+			e.logger.Error("Telemetry endpoint rejected points", logger.ErrorField(err), logger.Int("points", n))
+		default:
+			// Put the batch back in front of the points buffered since,
+			// dropping the oldest if they don't fit anymore
+			e.stats.Failed++
+			buffer := append(batch, e.buffer...)
+			if overflow := len(buffer) - e.config.BufferSize; overflow > 0 {
+				buffer = buffer[overflow:]
+				e.stats.Dropped += uint64(overflow)
+			}
+			e.buffer = buffer
+		}
+		e.mu.Unlock()
+		if err != nil && rejected == nil {
+			return err
+		}
+	}
+}
+
+// rejectedError is returned for writes the endpoint won't accept if retried
+type rejectedError struct {
+	status int
+	body   string
+}
+
+func (e *rejectedError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.status, e.body)
+}
+
+// write posts a batch of lines to the endpoint
+func (e *Exporter) write(ctx context.Context, lines []string) error {
+	body := strings.Join(lines, "\n") + "\n"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.config.URL, bytes.NewReader([]byte(body)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if e.config.Token != "" {
+		req.Header.Set("Authorization", "Token "+e.config.Token)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return &rejectedError{status: resp.StatusCode, body: strings.TrimSpace(string(message))}
+}
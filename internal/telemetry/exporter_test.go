@@ -0,0 +1,251 @@
+package telemetry
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+type testSubscriber struct {
+	mu       sync.Mutex
+	callback models.EventCallback
+}
+
+func (s *testSubscriber) Subscribe(callback models.EventCallback) func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.callback = callback
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.callback = nil
+	}
+}
+
+func (s *testSubscriber) update(nodeID int, path string, value interface{}) {
+	s.mu.Lock()
+	callback := s.callback
+	s.mu.Unlock()
+	if callback != nil {
+		callback(models.EventTypeAttributeUpdated, []interface{}{nodeID, path, value})
+	}
+}
+
+// testEndpoint records the lines written to it. Its status is returned
+// until cleared.
+type testEndpoint struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	lines   []string
+	auth    string
+	status  int
+	written chan struct{}
+}
+
+func newTestEndpoint(t *testing.T) *testEndpoint {
+	e := &testEndpoint{written: make(chan struct{}, 100)}
+	e.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		e.mu.Lock()
+		status := e.status
+		e.auth = r.Header.Get("Authorization")
+		if status == 0 {
+			e.lines = append(e.lines, strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")...)
+		}
+		e.mu.Unlock()
+		if status != 0 {
+			http.Error(w, "unavailable", status)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		e.written <- struct{}{}
+	}))
+	t.Cleanup(e.Close)
+	return e
+}
+
+func (e *testEndpoint) setStatus(status int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.status = status
+}
+
+func (e *testEndpoint) received() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.lines...)
+}
+
+func (e *testEndpoint) waitWrite(t *testing.T) {
+	t.Helper()
+	select {
+	case <-e.written:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for a write")
+	}
+}
+
+func newTestExporter(t *testing.T, endpoint *testEndpoint, config Config) (*Exporter, *testSubscriber) {
+	t.Helper()
+	server := &testSubscriber{}
+	config.URL = endpoint.URL + "/api/v2/write?org=home&bucket=matter"
+	config.Logger = logger.NewConsoleLogger(logger.FatalLevel)
+	config.Timeout = time.Second
+	exporter, err := NewExporter(server, config)
+	if err != nil {
+		t.Fatalf("NewExporter failed: %v", err)
+	}
+	exporter.Start()
+	return exporter, server
+}
+
+// withoutTimestamp strips the timestamp of a line
+func withoutTimestamp(line string) string {
+	return line[:strings.LastIndexByte(line, ' ')]
+}
+
+func TestExporter(t *testing.T) {
+	endpoint := newTestEndpoint(t)
+	exporter, server := newTestExporter(t, endpoint, Config{
+		Token: "secret",
+		Metrics: append([]Metric{
+			{Attribute: "*/6/0", Measurement: "on_off", Field: "state", Tags: map[string]string{"kind": "light"}},
+		}, DefaultMetrics...),
+		Tags:          map[string]string{"site": "home"},
+		BatchSize:     3,
+		FlushInterval: time.Hour,
+		BufferSize:    10,
+	})
+
+	server.update(5, "1/1026/0", float64(2150))
+	server.update(5, "1/6/0", true)           // not numeric
+	server.update(5, "1/1029/1", 10)          // no metric
+	server.update(5, "2/144/8", int64(12500)) // mW
+	server.update(6, "1/6/0", 1)
+
+	// The third point fills the batch
+	endpoint.waitWrite(t)
+	expected := []string{
+		"temperature,endpoint_id=1,node_id=5,site=home value=21.5",
+		"power,endpoint_id=2,node_id=5,site=home value=12.5",
+		"on_off,endpoint_id=1,kind=light,node_id=6,site=home state=1",
+	}
+	lines := endpoint.received()
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %q, got %q", expected, lines)
+	}
+	for i := range expected {
+		if withoutTimestamp(lines[i]) != expected[i] {
+			t.Errorf("Expected %q, got %q", expected[i], lines[i])
+		}
+	}
+	endpoint.mu.Lock()
+	auth := endpoint.auth
+	endpoint.mu.Unlock()
+	if auth != "Token secret" {
+		t.Errorf("Expected the token to be sent, got %q", auth)
+	}
+
+	// Shutting down writes the rest
+	server.update(7, "0/1027/0", 1013)
+	if err := exporter.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if lines := endpoint.received(); len(lines) != 4 || withoutTimestamp(lines[3]) != "pressure,endpoint_id=0,node_id=7,site=home value=1013" {
+		t.Errorf("Expected the pressure to be written on shutdown, got %q", lines)
+	}
+	if stats := exporter.Stats(); stats.Written != 4 || stats.Buffered != 0 || stats.Dropped != 0 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestExporterBackpressure(t *testing.T) {
+	retry := minRetry
+	minRetry = 20 * time.Millisecond
+	t.Cleanup(func() { minRetry = retry })
+
+	endpoint := newTestEndpoint(t)
+	endpoint.setStatus(http.StatusServiceUnavailable)
+	exporter, server := newTestExporter(t, endpoint, Config{
+		BatchSize:     2,
+		FlushInterval: 10 * time.Millisecond,
+		BufferSize:    4,
+	})
+	defer exporter.Shutdown(context.Background())
+
+	// The buffer keeps the four newest points while the endpoint fails
+	for i := 0; i < 6; i++ {
+		server.update(5, "1/1026/0", i*100)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for exporter.Stats().Failed < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected writes to fail, got %+v", exporter.Stats())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if stats := exporter.Stats(); stats.Buffered != 4 || stats.Dropped != 2 {
+		t.Errorf("Expected 4 buffered and 2 dropped points, got %+v", stats)
+	}
+
+	endpoint.setStatus(0)
+	endpoint.waitWrite(t)
+	endpoint.waitWrite(t)
+	var values []string
+	for _, line := range endpoint.received() {
+		values = append(values, strings.TrimPrefix(withoutTimestamp(line), "temperature,endpoint_id=1,node_id=5 value="))
+	}
+	if strings.Join(values, " ") != "2 3 4 5" {
+		t.Errorf("Expected the newest points in order, got %q", values)
+	}
+}
+
+func TestExporterRejected(t *testing.T) {
+	endpoint := newTestEndpoint(t)
+	endpoint.setStatus(http.StatusBadRequest)
+	exporter, server := newTestExporter(t, endpoint, Config{
+		BatchSize:     1,
+		FlushInterval: time.Hour,
+		BufferSize:    1,
+	})
+
+	server.update(5, "1/1026/0", 100)
+	// Points the endpoint rejects aren't retried
+	if err := exporter.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if stats := exporter.Stats(); stats.Dropped != 1 || stats.Buffered != 0 || stats.Failed != 1 {
+		t.Errorf("Expected the point to be dropped, got %+v", stats)
+	}
+}
+
+func TestNewExporterValidation(t *testing.T) {
+	valid := Config{URL: "http://localhost:8086/write?db=matter", BatchSize: 10, BufferSize: 100, FlushInterval: time.Second}
+	if _, err := NewExporter(&testSubscriber{}, valid); err != nil {
+		t.Fatalf("Expected a valid config: %v", err)
+	}
+
+	invalid := []func(c *Config){
+		func(c *Config) { c.URL = "udp://localhost:8089" },
+		func(c *Config) { c.BatchSize = 0 },
+		func(c *Config) { c.BufferSize = 5 },
+		func(c *Config) { c.Metrics = []Metric{{Attribute: "1026/0", Measurement: "temperature"}} },
+		func(c *Config) { c.Metrics = []Metric{{Attribute: "*/1026/0"}} },
+	}
+	for i, modify := range invalid {
+		config := valid
+		modify(&config)
+		if _, err := NewExporter(&testSubscriber{}, config); err == nil {
+			t.Errorf("Case %d: expected an error", i)
+		}
+	}
+}
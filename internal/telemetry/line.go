@@ -0,0 +1,59 @@
+package telemetry
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// Measurements escape commas and spaces, tag keys, tag values and field
+	// keys also equal signs. Newlines can't be escaped in line protocol.
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\ `)
+	keyEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\ `)
+)
+
+// point is a sample of a single field in a measurement
+type point struct {
+	measurement string
+	tags        map[string]string
+	field       string
+	value       float64
+	time        time.Time
+}
+
+// line encodes the point in InfluxDB line protocol with a nanosecond
+// timestamp, e.g. "temperature,node_id=5 value=21.5 1700000000000000000".
+// Tags are sorted by key, which InfluxDB recommends, and empty tags are
+// left out since line protocol doesn't allow them.
+func (p point) line() (string, bool) {
+	if math.IsNaN(p.value) || math.IsInf(p.value, 0) {
+		return "", false
+	}
+
+	keys := make([]string, 0, len(p.tags))
+	for key, value := range p.tags {
+		if key != "" && value != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(measurementEscaper.Replace(p.measurement))
+	for _, key := range keys {
+		b.WriteByte(',')
+		b.WriteString(keyEscaper.Replace(key))
+		b.WriteByte('=')
+		b.WriteString(keyEscaper.Replace(p.tags[key]))
+	}
+	b.WriteByte(' ')
+	b.WriteString(keyEscaper.Replace(p.field))
+	b.WriteByte('=')
+	b.WriteString(strconv.FormatFloat(p.value, 'f', -1, 64))
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatInt(p.time.UnixNano(), 10))
+	return b.String(), true
+}
@@ -0,0 +1,42 @@
+package telemetry
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPointLine(t *testing.T) {
+	ts := time.Unix(1700000000, 5)
+	tests := []struct {
+		name     string
+		point    point
+		expected string
+	}{
+		{
+			"sorted tags",
+			point{measurement: "temperature", tags: map[string]string{"node_id": "5", "endpoint_id": "1"}, field: "value", value: 21.5, time: ts},
+			"temperature,endpoint_id=1,node_id=5 value=21.5 1700000000000000005",
+		},
+		{
+			"escaping",
+			point{measurement: "room temp,c", tags: map[string]string{"room name": "living=room", "empty": ""}, field: "deg c", value: -3, time: ts},
+			`room\ temp\,c,room\ name=living\=room deg\ c=-3 1700000000000000005`,
+		},
+		{
+			"no tags",
+			point{measurement: "power", field: "value", value: 1234567.125, time: ts},
+			"power value=1234567.125 1700000000000000005",
+		},
+	}
+	for _, tt := range tests {
+		line, ok := tt.point.line()
+		if !ok || line != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, line)
+		}
+	}
+
+	if _, ok := (point{measurement: "x", field: "value", value: math.NaN(), time: ts}).line(); ok {
+		t.Error("Expected NaN not to be encoded")
+	}
+}
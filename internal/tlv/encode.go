@@ -0,0 +1,140 @@
+package tlv
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// AnonymousTag is passed to Writer methods for elements without a tag
+const AnonymousTag = -1
+
+// Writer encodes TLV elements. Tags are context tags (0-255) or
+// AnonymousTag. Integers use the smallest encoding that holds the value.
+type Writer struct {
+	buf []byte
+}
+
+// Bytes returns the encoded data
+func (w *Writer) Bytes() []byte {
+	return w.buf
+}
+
+// Len returns the number of bytes encoded so far
+func (w *Writer) Len() int {
+	return len(w.buf)
+}
+
+// PutUint encodes an unsigned integer
+func (w *Writer) PutUint(tag int, v uint64) {
+	switch {
+	case v <= math.MaxUint8:
+		w.control(tag, 0x04)
+		w.buf = append(w.buf, uint8(v))
+	case v <= math.MaxUint16:
+		w.control(tag, 0x05)
+		w.buf = binary.LittleEndian.AppendUint16(w.buf, uint16(v))
+	case v <= math.MaxUint32:
+		w.control(tag, 0x06)
+		w.buf = binary.LittleEndian.AppendUint32(w.buf, uint32(v))
+	default:
+		w.control(tag, 0x07)
+		w.buf = binary.LittleEndian.AppendUint64(w.buf, v)
+	}
+}
+
+// PutInt encodes a signed integer
+func (w *Writer) PutInt(tag int, v int64) {
+	switch {
+	case v >= math.MinInt8 && v <= math.MaxInt8:
+		w.control(tag, 0x00)
+		w.buf = append(w.buf, uint8(v))
+	case v >= math.MinInt16 && v <= math.MaxInt16:
+		w.control(tag, 0x01)
+		w.buf = binary.LittleEndian.AppendUint16(w.buf, uint16(v))
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		w.control(tag, 0x02)
+		w.buf = binary.LittleEndian.AppendUint32(w.buf, uint32(v))
+	default:
+		w.control(tag, 0x03)
+		w.buf = binary.LittleEndian.AppendUint64(w.buf, uint64(v))
+	}
+}
+
+// PutBool encodes a boolean
+func (w *Writer) PutBool(tag int, v bool) {
+	if v {
+		w.control(tag, 0x09)
+	} else {
+		w.control(tag, 0x08)
+	}
+}
+
+// PutFloat encodes a double precision float
+func (w *Writer) PutFloat(tag int, v float64) {
+	w.control(tag, 0x0B)
+	w.buf = binary.LittleEndian.AppendUint64(w.buf, math.Float64bits(v))
+}
+
+// PutString encodes a UTF-8 string
+func (w *Writer) PutString(tag int, v string) {
+	w.putLength(tag, 0x0C, len(v))
+	w.buf = append(w.buf, v...)
+}
+
+// PutBytes encodes a byte string
+func (w *Writer) PutBytes(tag int, v []byte) {
+	w.putLength(tag, 0x10, len(v))
+	w.buf = append(w.buf, v...)
+}
+
+// PutNull encodes a null value
+func (w *Writer) PutNull(tag int) {
+	w.control(tag, 0x14)
+}
+
+// StartStructure opens a structure; close it with EndContainer
+func (w *Writer) StartStructure(tag int) {
+	w.control(tag, 0x15)
+}
+
+// StartArray opens an array; close it with EndContainer
+func (w *Writer) StartArray(tag int) {
+	w.control(tag, 0x16)
+}
+
+// StartList opens a list; close it with EndContainer
+func (w *Writer) StartList(tag int) {
+	w.control(tag, 0x17)
+}
+
+// EndContainer closes the innermost open container
+func (w *Writer) EndContainer() {
+	w.buf = append(w.buf, endOfContainer)
+}
+
+func (w *Writer) control(tag int, elemType byte) {
+	if tag == AnonymousTag {
+		w.buf = append(w.buf, elemType)
+		return
+	}
+	w.buf = append(w.buf, 0x20|elemType, uint8(tag))
+}
+
+func (w *Writer) putLength(tag int, base byte, n int) {
+	switch {
+	case n <= math.MaxUint8:
+		w.control(tag, base)
+		w.buf = append(w.buf, uint8(n))
+	case n <= math.MaxUint16:
+		w.control(tag, base+1)
+		w.buf = binary.LittleEndian.AppendUint16(w.buf, uint16(n))
+	default:
+		w.control(tag, base+2)
+		w.buf = binary.LittleEndian.AppendUint32(w.buf, uint32(n))
+	}
+}
+
+// PutRaw appends already encoded TLV elements
+func (w *Writer) PutRaw(data []byte) {
+	w.buf = append(w.buf, data...)
+}
@@ -0,0 +1,94 @@
+package tlv
+
+import (
+	"bytes"
+	"testing"
+)
+
+// FuzzDecode decodes arbitrary data, as received from devices. Elements that
+// the Writer can encode must encode to the same bytes after a round trip.
+func FuzzDecode(f *testing.F) {
+	var w Writer
+	w.StartStructure(AnonymousTag)
+	w.PutUint(0, 0xFFF1)
+	w.PutInt(1, -2)
+	w.PutString(2, "abc")
+	w.PutBytes(3, []byte{1, 2})
+	w.PutFloat(4, 1.5)
+	w.PutNull(5)
+	w.StartArray(6)
+	w.PutBool(AnonymousTag, true)
+	w.EndContainer()
+	w.StartList(7)
+	w.EndContainer()
+	w.EndContainer()
+	f.Add(w.Bytes())
+	// Profile tags and a single precision float
+	f.Add([]byte{0x55, 0x01, 0x00, 0x0A, 0x00, 0x00, 0xC0, 0x3F, 0x18})
+	f.Add([]byte{0xF5, 0xF1, 0xFF, 0x01, 0x00, 0x02, 0x00, 0x00, 0x00, 0x18})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		e, err := Decode(data)
+		if err != nil {
+			return
+		}
+		var first Writer
+		if !encodeElement(&first, e) {
+			return
+		}
+		again, err := Decode(first.Bytes())
+		if err != nil {
+			t.Fatalf("Failed to decode the encoded element: %v", err)
+		}
+		var second Writer
+		encodeElement(&second, again)
+		if !bytes.Equal(first.Bytes(), second.Bytes()) {
+			t.Errorf("Expected a stable encoding, got %x then %x", first.Bytes(), second.Bytes())
+		}
+	})
+}
+
+// encodeElement encodes e with the Writer, false if it has profile tags the
+// Writer doesn't support
+func encodeElement(w *Writer, e Element) bool {
+	tag := AnonymousTag
+	switch e.TagKind {
+	case TagContext:
+		tag = int(e.Tag)
+	case TagProfile:
+		return false
+	}
+
+	switch e.Type {
+	case TypeSignedInt:
+		w.PutInt(tag, e.Value.(int64))
+	case TypeUnsignedInt:
+		w.PutUint(tag, e.Value.(uint64))
+	case TypeBool:
+		w.PutBool(tag, e.Value.(bool))
+	case TypeFloat:
+		w.PutFloat(tag, e.Value.(float64))
+	case TypeUTF8String:
+		w.PutString(tag, e.Value.(string))
+	case TypeByteString:
+		w.PutBytes(tag, e.Value.([]byte))
+	case TypeNull:
+		w.PutNull(tag)
+	default:
+		switch e.Type {
+		case TypeStructure:
+			w.StartStructure(tag)
+		case TypeArray:
+			w.StartArray(tag)
+		default:
+			w.StartList(tag)
+		}
+		for _, member := range e.Elements {
+			if !encodeElement(w, member) {
+				return false
+			}
+		}
+		w.EndContainer()
+	}
+	return true
+}
@@ -0,0 +1,269 @@
+// Package tlv implements encoding and decoding of the Matter Tag-Length-Value
+// format (Matter Core Specification appendix A).
+package tlv
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Type identifies the kind of value held by an Element
+type Type uint8
+
+const (
+	TypeSignedInt Type = iota
+	TypeUnsignedInt
+	TypeBool
+	TypeFloat
+	TypeUTF8String
+	TypeByteString
+	TypeNull
+	TypeStructure
+	TypeArray
+	TypeList
+)
+
+// TagKind identifies the tag form of an Element
+type TagKind uint8
+
+const (
+	TagAnonymous TagKind = iota
+	TagContext
+	TagProfile
+)
+
+// Element is a single decoded TLV element
+type Element struct {
+	TagKind TagKind
+	Tag     uint64
+	Type    Type
+
+	// Value holds int64, uint64, bool, float64, string, []byte or nil for
+	// primitive types. Containers keep their members in Elements.
+	Value    interface{}
+	Elements []Element
+}
+
+const endOfContainer = 0x18
+
+// maxDepth limits the nesting of containers, far beyond what Matter messages
+// use, so malformed data can't exhaust the stack
+const maxDepth = 32
+
+// Decode decodes a single TLV element (usually an anonymous structure)
+func Decode(data []byte) (Element, error) {
+	d := decoder{buf: data}
+	e, err := d.element()
+	if err != nil {
+		return Element{}, err
+	}
+	return e, nil
+}
+
+// Field returns the member of a container with the given context tag
+func (e Element) Field(tag uint8) (Element, bool) {
+	for _, member := range e.Elements {
+		if member.TagKind == TagContext && member.Tag == uint64(tag) {
+			return member, true
+		}
+	}
+	return Element{}, false
+}
+
+// Uint returns the value as an unsigned integer
+func (e Element) Uint() (uint64, bool) {
+	switch v := e.Value.(type) {
+	case uint64:
+		return v, true
+	case int64:
+		if v >= 0 {
+			return uint64(v), true
+		}
+	}
+	return 0, false
+}
+
+// Int returns the value as a signed integer
+func (e Element) Int() (int64, bool) {
+	switch v := e.Value.(type) {
+	case int64:
+		return v, true
+	case uint64:
+		if v <= math.MaxInt64 {
+			return int64(v), true
+		}
+	}
+	return 0, false
+}
+
+// String returns the value as a UTF-8 string
+func (e Element) String() (string, bool) {
+	v, ok := e.Value.(string)
+	return v, ok
+}
+
+// Bytes returns the value as a byte string
+func (e Element) Bytes() ([]byte, bool) {
+	v, ok := e.Value.([]byte)
+	return v, ok
+}
+
+// Bool returns the value as a boolean
+func (e Element) Bool() (bool, bool) {
+	v, ok := e.Value.(bool)
+	return v, ok
+}
+
+type decoder struct {
+	buf   []byte
+	pos   int
+	depth int
+}
+
+func (d *decoder) read(n int) ([]byte, error) {
+	// Lengths are up to 64 bits, d.pos+n may overflow
+	if n < 0 || n > len(d.buf)-d.pos {
+		return nil, fmt.Errorf("tlv: unexpected end of data at offset %d", d.pos)
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *decoder) uint(n int) (uint64, error) {
+	b, err := d.read(n)
+	if err != nil {
+		return 0, err
+	}
+	switch n {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.LittleEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.LittleEndian.Uint32(b)), nil
+	default:
+		return binary.LittleEndian.Uint64(b), nil
+	}
+}
+
+func (d *decoder) element() (Element, error) {
+	ctrl, err := d.read(1)
+	if err != nil {
+		return Element{}, err
+	}
+
+	var e Element
+	tagControl := ctrl[0] >> 5
+	elemType := ctrl[0] & 0x1f
+
+	switch tagControl {
+	case 0:
+		e.TagKind = TagAnonymous
+	case 1:
+		e.TagKind = TagContext
+		e.Tag, err = d.uint(1)
+	case 2, 4:
+		e.TagKind = TagProfile
+		e.Tag, err = d.uint(2)
+	case 3, 5:
+		e.TagKind = TagProfile
+		e.Tag, err = d.uint(4)
+	case 6:
+		e.TagKind = TagProfile
+		var vendorProfile uint64
+		if vendorProfile, err = d.uint(4); err == nil {
+			e.Tag, err = d.uint(2)
+			e.Tag |= vendorProfile << 32
+		}
+	case 7:
+		e.TagKind = TagProfile
+		var vendorProfile uint64
+		if vendorProfile, err = d.uint(4); err == nil {
+			e.Tag, err = d.uint(4)
+			e.Tag |= vendorProfile << 32
+		}
+	}
+	if err != nil {
+		return Element{}, err
+	}
+
+	switch {
+	case elemType <= 0x03:
+		size := 1 << elemType
+		v, err := d.uint(size)
+		if err != nil {
+			return Element{}, err
+		}
+		e.Type = TypeSignedInt
+		shift := 64 - 8*size
+		e.Value = int64(v<<shift) >> shift
+	case elemType <= 0x07:
+		v, err := d.uint(1 << (elemType - 0x04))
+		if err != nil {
+			return Element{}, err
+		}
+		e.Type = TypeUnsignedInt
+		e.Value = v
+	case elemType == 0x08 || elemType == 0x09:
+		e.Type = TypeBool
+		e.Value = elemType == 0x09
+	case elemType == 0x0A:
+		v, err := d.uint(4)
+		if err != nil {
+			return Element{}, err
+		}
+		e.Type = TypeFloat
+		e.Value = float64(math.Float32frombits(uint32(v)))
+	case elemType == 0x0B:
+		v, err := d.uint(8)
+		if err != nil {
+			return Element{}, err
+		}
+		e.Type = TypeFloat
+		e.Value = math.Float64frombits(v)
+	case elemType >= 0x0C && elemType <= 0x13:
+		length, err := d.uint(1 << ((elemType - 0x0C) & 0x03))
+		if err != nil {
+			return Element{}, err
+		}
+		b, err := d.read(int(length))
+		if err != nil {
+			return Element{}, err
+		}
+		if elemType <= 0x0F {
+			e.Type = TypeUTF8String
+			e.Value = string(b)
+		} else {
+			e.Type = TypeByteString
+			e.Value = append([]byte(nil), b...)
+		}
+	case elemType == 0x14:
+		e.Type = TypeNull
+	case elemType >= 0x15 && elemType <= 0x17:
+		e.Type = Type(uint8(TypeStructure) + elemType - 0x15)
+		if d.depth++; d.depth > maxDepth {
+			return Element{}, fmt.Errorf("tlv: containers nested deeper than %d at offset %d", maxDepth, d.pos-1)
+		}
+		for {
+			if d.pos >= len(d.buf) {
+				return Element{}, fmt.Errorf("tlv: unterminated container")
+			}
+			if d.buf[d.pos] == endOfContainer {
+				d.pos++
+				break
+			}
+			member, err := d.element()
+			if err != nil {
+				return Element{}, err
+			}
+			e.Elements = append(e.Elements, member)
+		}
+		d.depth--
+	default:
+		return Element{}, fmt.Errorf("tlv: invalid element type 0x%02x at offset %d", elemType, d.pos-1)
+	}
+
+	return e, nil
+}
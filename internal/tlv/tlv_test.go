@@ -0,0 +1,155 @@
+package tlv
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecodeStructure(t *testing.T) {
+	data := []byte{
+		0x15,             // anonymous structure
+		0x24, 0x00, 0x01, // context 0: uint8 1
+		0x25, 0x01, 0xF1, 0xFF, // context 1: uint16 0xFFF1
+		0x36, 0x02, // context 2: array
+		0x05, 0x00, 0x80, // uint16 0x8000
+		0x05, 0x01, 0x80, // uint16 0x8001
+		0x18,                            // end of array
+		0x2C, 0x04, 0x03, 'a', 'b', 'c', // context 4: string "abc"
+		0x20, 0x05, 0xFF, // context 5: int8 -1
+		0x29, 0x06, // context 6: true
+		0x18, // end of structure
+	}
+
+	e, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	if e.Type != TypeStructure || e.TagKind != TagAnonymous {
+		t.Fatalf("Expected anonymous structure, got type %d tag kind %d", e.Type, e.TagKind)
+	}
+
+	if v, _ := mustField(t, e, 0).Uint(); v != 1 {
+		t.Errorf("Expected field 0 = 1, got %d", v)
+	}
+	if v, _ := mustField(t, e, 1).Uint(); v != 0xFFF1 {
+		t.Errorf("Expected field 1 = 0xFFF1, got 0x%X", v)
+	}
+
+	arr := mustField(t, e, 2)
+	if arr.Type != TypeArray || len(arr.Elements) != 2 {
+		t.Fatalf("Expected array with 2 elements, got %+v", arr)
+	}
+	if v, _ := arr.Elements[1].Uint(); v != 0x8001 {
+		t.Errorf("Expected 0x8001, got 0x%X", v)
+	}
+
+	if v, _ := mustField(t, e, 4).String(); v != "abc" {
+		t.Errorf("Expected \"abc\", got %q", v)
+	}
+	if v, _ := mustField(t, e, 5).Int(); v != -1 {
+		t.Errorf("Expected -1, got %d", v)
+	}
+	if v, _ := mustField(t, e, 6).Bool(); !v {
+		t.Error("Expected true")
+	}
+
+	if _, ok := e.Field(9); ok {
+		t.Error("Expected missing field 9")
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"Empty", []byte{}},
+		{"Truncated integer", []byte{0x05, 0x01}},
+		{"Unterminated structure", []byte{0x15, 0x24, 0x00, 0x01}},
+		{"Truncated string", []byte{0x0C, 0x05, 'a'}},
+		{"Invalid type", []byte{0x1F}},
+		// A length whose end offset overflows
+		{"Huge length", []byte{0x13, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x7F, 'a'}},
+		{"Nested too deep", bytes.Repeat([]byte{0x16}, maxDepth+1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(tt.data); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func mustField(t *testing.T, e Element, tag uint8) Element {
+	t.Helper()
+	f, ok := e.Field(tag)
+	if !ok {
+		t.Fatalf("Missing field %d", tag)
+	}
+	return f
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	var w Writer
+	w.StartStructure(AnonymousTag)
+	w.PutUint(0, 1)
+	w.PutUint(1, 0xFFF1)
+	w.PutUint(2, 0x1_0000_0000)
+	w.PutInt(3, -200)
+	w.PutBool(4, true)
+	w.PutFloat(5, 1.5)
+	w.PutString(6, "abc")
+	w.PutBytes(7, make([]byte, 300))
+	w.PutNull(8)
+	w.StartArray(9)
+	w.PutUint(AnonymousTag, 7)
+	w.EndContainer()
+	w.EndContainer()
+
+	e, err := Decode(w.Bytes())
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	if v, _ := mustField(t, e, 1).Uint(); v != 0xFFF1 {
+		t.Errorf("Expected 0xFFF1, got 0x%X", v)
+	}
+	if v, _ := mustField(t, e, 2).Uint(); v != 0x1_0000_0000 {
+		t.Errorf("Expected 0x100000000, got 0x%X", v)
+	}
+	if v, _ := mustField(t, e, 3).Int(); v != -200 {
+		t.Errorf("Expected -200, got %d", v)
+	}
+	if v, _ := mustField(t, e, 4).Bool(); !v {
+		t.Error("Expected true")
+	}
+	if v := mustField(t, e, 5).Value; v != 1.5 {
+		t.Errorf("Expected 1.5, got %v", v)
+	}
+	if v, _ := mustField(t, e, 6).String(); v != "abc" {
+		t.Errorf("Expected \"abc\", got %q", v)
+	}
+	if v, _ := mustField(t, e, 7).Bytes(); len(v) != 300 {
+		t.Errorf("Expected 300 bytes, got %d", len(v))
+	}
+	if mustField(t, e, 8).Type != TypeNull {
+		t.Error("Expected null")
+	}
+	if arr := mustField(t, e, 9); arr.Type != TypeArray || len(arr.Elements) != 1 {
+		t.Errorf("Expected array with 1 element, got %+v", arr)
+	}
+}
+
+func TestEncodeSmallestInteger(t *testing.T) {
+	var w Writer
+	w.PutUint(AnonymousTag, 200)
+	w.PutInt(0, -1)
+
+	expected := []byte{0x04, 0xC8, 0x20, 0x00, 0xFF}
+	if string(w.Bytes()) != string(expected) {
+		t.Errorf("Expected % X, got % X", expected, w.Bytes())
+	}
+}
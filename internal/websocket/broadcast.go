@@ -0,0 +1,154 @@
+package websocket
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// eventEncoding is a form an event is sent in: connections with the same
+// codec and schema version receive the same bytes
+type eventEncoding struct {
+	codec   models.Codec
+	version int
+}
+
+// handleEvent sends a server event to the connections whose schema version
+// and filter accept it
+func (h *Handler) handleEvent(eventType models.EventType, data interface{}) {
+	h.BroadcastEvent(models.EventMessage{Event: eventType, Data: data})
+}
+
+// BroadcastEvent sends an event to all connected clients whose schema
+// version and filter accept it. The event is encoded once per codec and
+// schema version in use, and the same bytes are queued for every connection.
+func (h *Handler) BroadcastEvent(event models.EventMessage) {
+	if !h.dialect.sendsEvent(event.Event) {
+		return
+	}
+
+	var (
+		key     string
+		encoded map[eventEncoding][]byte
+	)
+	for _, conn := range h.snapshotConnections() {
+		version := conn.getSchemaVersion()
+		if !eventSupported(version, event.Event) || !conn.getFilter().matches(event.Event, event.Data) {
+			continue
+		}
+
+		if encoded == nil {
+			key = coalesceKey(event.Event, event.Data)
+			encoded = make(map[eventEncoding][]byte, 1)
+		}
+		form := eventEncoding{codec: conn.codec, version: version}
+		data, ok := encoded[form]
+		if !ok {
+			var err error
+			if data, err = h.encodeEvent(form, event); err != nil {
+				// Logging failures to send log entries would produce more
+				// of them
+				if event.Event != models.EventTypeLogEntry {
+					h.logger.Error("Failed to marshal event",
+						logger.String("event", string(event.Event)),
+						logger.ErrorField(err),
+					)
+				}
+				return
+			}
+			encoded[form] = data
+		}
+		conn.enqueueEvent(&queuedMessage{data: data, event: true, key: key})
+	}
+}
+
+// encodeEvent encodes an event for connections of the given codec and
+// schema version in the handler's dialect
+func (h *Handler) encodeEvent(form eventEncoding, event models.EventMessage) ([]byte, error) {
+	if form.codec == models.JSONCodec && event.Event == models.EventTypeAttributeUpdated {
+		if data, ok := appendAttributeUpdated(nil, event.Data); ok {
+			return data, nil
+		}
+	}
+
+	event.Data = h.dialect.adaptData(adaptData(form.version, event.Data))
+	return form.codec.Marshal(event)
+}
+
+// attributeUpdatedPrefix starts the JSON encoding of attribute_updated
+const attributeUpdatedPrefix = `{"event":"attribute_updated","data":[`
+
+// appendAttributeUpdated appends the JSON encoding of an attribute_updated
+// event with the data [node_id, path, value] to buf, without the reflection
+// and intermediate allocations of encoding/json. The output is identical to
+// json.Marshal of the EventMessage. It reports false for values other than
+// null, booleans, numbers and plain ASCII strings, which are left to
+// encoding/json.
+func appendAttributeUpdated(buf []byte, data interface{}) ([]byte, bool) {
+	update, ok := data.([]interface{})
+	if !ok || len(update) != 3 {
+		return buf, false
+	}
+	nodeID, ok := update[0].(int)
+	if !ok {
+		return buf, false
+	}
+	path, ok := update[1].(string)
+	if !ok || !plainJSONString(path) {
+		return buf, false
+	}
+
+	if buf == nil {
+		buf = make([]byte, 0, len(attributeUpdatedPrefix)+len(path)+48)
+	}
+	start := len(buf)
+	buf = append(buf, attributeUpdatedPrefix...)
+	buf = strconv.AppendInt(buf, int64(nodeID), 10)
+	buf = append(buf, ',', '"')
+	buf = append(buf, path...)
+	buf = append(buf, '"', ',')
+
+	switch v := update[2].(type) {
+	case nil:
+		buf = append(buf, "null"...)
+	case bool:
+		buf = strconv.AppendBool(buf, v)
+	case int:
+		buf = strconv.AppendInt(buf, int64(v), 10)
+	case int64:
+		buf = strconv.AppendInt(buf, v, 10)
+	case uint64:
+		buf = strconv.AppendUint(buf, v, 10)
+	case float64:
+		// encoding/json switches to exponent notation outside this range
+		if abs := math.Abs(v); v != 0 && (abs < 1e-6 || abs >= 1e21) || math.IsNaN(v) || math.IsInf(v, 0) {
+			return buf[:start], false
+		}
+		buf = strconv.AppendFloat(buf, v, 'f', -1, 64)
+	case string:
+		if !plainJSONString(v) {
+			return buf[:start], false
+		}
+		buf = append(buf, '"')
+		buf = append(buf, v...)
+		buf = append(buf, '"')
+	default:
+		return buf[:start], false
+	}
+	return append(buf, ']', '}'), true
+}
+
+// plainJSONString reports whether s is encoded by encoding/json as is, in
+// quotes: printable ASCII without quotes, backslashes and the characters
+// escaped for HTML
+func plainJSONString(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c >= 0x7F || c == '"' || c == '\\' || c == '<' || c == '>' || c == '&' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,98 @@
+package websocket
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+func TestAppendAttributeUpdated(t *testing.T) {
+	values := []interface{}{
+		nil, true, false, 0, -12, int64(1) << 40, uint64(1) << 63,
+		0.0, 21.5, -0.125, 1e20, 3.0, "", "Kitchen light",
+	}
+	for _, value := range values {
+		data := []interface{}{5, "1/6/0", value}
+		encoded, ok := appendAttributeUpdated(nil, data)
+		if !ok {
+			t.Errorf("Expected the fast path for %#v", value)
+			continue
+		}
+		expected, err := json.Marshal(models.EventMessage{Event: models.EventTypeAttributeUpdated, Data: data})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(encoded) != string(expected) {
+			t.Errorf("Expected %s for %#v, got %s", expected, value, encoded)
+		}
+	}
+
+	fallbacks := []interface{}{
+		[]interface{}{5, "1/6/0", 1e21},
+		[]interface{}{5, "1/6/0", 1e-7},
+		[]interface{}{5, "1/6/0", "café"},
+		[]interface{}{5, "1/6/0", "<b>"},
+		[]interface{}{5, "1/6/0", []interface{}{1, 2}},
+		[]interface{}{5, "1/6/0", map[string]interface{}{"a": 1}},
+		[]interface{}{"5", "1/6/0", true},
+		[]interface{}{5, "1/6/0"},
+		map[string]interface{}{"node_id": 5},
+	}
+	for _, data := range fallbacks {
+		buf := []byte("prefix")
+		if encoded, ok := appendAttributeUpdated(buf, data); ok || string(encoded) != "prefix" {
+			t.Errorf("Expected no fast path for %#v, got %q", data, encoded)
+		}
+	}
+}
+
+func TestBroadcastEventEncodesOnce(t *testing.T) {
+	mockServer := NewMockServer()
+	handler := NewHandler(mockServer, logger.NewConsoleLogger(logger.FatalLevel))
+	defer handler.Shutdown()
+
+	if count := mockServer.GetCallbackCount(); count != 1 {
+		t.Fatalf("Expected the handler to subscribe once, got %d subscriptions", count)
+	}
+
+	first, _ := dialTestHandler(t, handler)
+	second, _ := dialTestHandler(t, handler)
+	startListening(t, first)
+	startListening(t, second)
+
+	handler.BroadcastEvent(models.EventMessage{
+		Event: models.EventTypeAttributeUpdated,
+		Data:  []interface{}{5, "1/6/0", true},
+	})
+	for _, conn := range []*websocket.Conn{first, second} {
+		msg := readMessages(t, conn)[0]
+		if msg["event"] != string(models.EventTypeAttributeUpdated) || !reflect.DeepEqual(msg["data"], []interface{}{float64(5), "1/6/0", true}) {
+			t.Errorf("Unexpected event %v", msg)
+		}
+	}
+}
+
+var benchmarkUpdate = []interface{}{5, "1/1026/0", 21.5}
+
+func BenchmarkAttributeUpdatedFastPath(b *testing.B) {
+	b.ReportAllocs()
+	buf := make([]byte, 0, 128)
+	for i := 0; i < b.N; i++ {
+		buf, _ = appendAttributeUpdated(buf[:0], benchmarkUpdate)
+	}
+}
+
+func BenchmarkAttributeUpdatedMarshal(b *testing.B) {
+	b.ReportAllocs()
+	event := models.EventMessage{Event: models.EventTypeAttributeUpdated, Data: benchmarkUpdate}
+	for i := 0; i < b.N; i++ {
+		if _, err := json.Marshal(event); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// pendingCommand is a command still being handled by the server
+type pendingCommand struct {
+	cancel    context.CancelFunc
+	cancelled bool
+}
+
+// startCommand registers a command so it can be cancelled by message ID. The
+// returned function must be called once the command finished and reports
+// whether the command was cancelled in the meantime.
+func (c *Connection) startCommand(messageID string) (context.Context, func() bool) {
+	ctx, cancel := context.WithCancel(c.ctx)
+
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+
+	// Commands without a unique message ID can't be cancelled
+	if _, exists := c.pending[messageID]; messageID == "" || exists {
+		return ctx, func() bool {
+			cancel()
+			return false
+		}
+	}
+
+	pending := &pendingCommand{cancel: cancel}
+	c.pending[messageID] = pending
+	return ctx, func() bool {
+		cancel()
+
+		c.pendingMu.Lock()
+		defer c.pendingMu.Unlock()
+		if c.pending[messageID] == pending {
+			delete(c.pending, messageID)
+		}
+		return pending.cancelled
+	}
+}
+
+// cancelCommand cancels a pending command. The cancelled command is answered
+// right away, a result it produces later is dropped.
+func (c *Connection) cancelCommand(messageID string) bool {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+
+	pending, ok := c.pending[messageID]
+	if !ok {
+		return false
+	}
+	delete(c.pending, messageID)
+	pending.cancelled = true
+	pending.cancel()
+	return true
+}
+
+// handleCancel handles the cancel command, which takes the message_id of a
+// previously sent command
+func (c *Connection) handleCancel(cmd models.CommandMessage) {
+	target, ok := cmd.Args["message_id"].(string)
+	if !ok || target == "" {
+		c.sendError(cmd.MessageID, models.ErrorCodeInvalidMessage, "message_id is required")
+		return
+	}
+
+	if !c.cancelCommand(target) {
+		c.sendError(cmd.MessageID, models.ErrorCodeNotFound,
+			fmt.Sprintf("no pending request with message_id %s", target))
+		return
+	}
+
+	details := "request cancelled"
+	cancelled := models.ErrorResultMessage{
+		ResultMessageBase: models.ResultMessageBase{
+			MessageID: target,
+		},
+		ErrorCode:   c.handler.dialect.errorCode(models.ErrorCodeCancelled),
+		Details:     &details,
+		CancelledBy: cmd.MessageID,
+	}
+	if err := c.sendMessage(cancelled); err != nil {
+		c.logger.Error("Failed to send cancellation result", logger.ErrorField(err))
+	}
+
+	response := models.SuccessResultMessage{
+		ResultMessageBase: models.ResultMessageBase{
+			MessageID: cmd.MessageID,
+		},
+		Result: models.CancelResult{MessageID: target, Cancelled: true},
+	}
+	if err := c.sendMessage(response); err != nil {
+		c.logger.Error("Failed to send command response", logger.ErrorField(err))
+	}
+}
@@ -0,0 +1,70 @@
+package websocket
+
+import (
+	"net/http"
+	"strings"
+)
+
+// DefaultCompressionLevel is the deflate level used when Compression.Level
+// is 0: the fastest, which already shrinks repetitive JSON severalfold
+const DefaultCompressionLevel = 1
+
+// Compression configures permessage-deflate (RFC 7692). By default the
+// compression context isn't taken over between messages: the server
+// negotiates server_no_context_takeover and client_no_context_takeover, so
+// idle connections hold no deflate state. Taking a context over compresses
+// small similar messages better at the cost of 32KB per connection and
+// side.
+type Compression struct {
+	// Enabled negotiates compression with clients offering it
+	Enabled bool
+
+	// Level is the deflate level from 1 (fastest) to 9 (smallest), 0
+	// selects DefaultCompressionLevel
+	Level int
+
+	// Threshold is the size in bytes below which messages are sent
+	// uncompressed, as deflating them costs more than it saves
+	Threshold int
+
+	// ServerContextTakeover keeps the context compressing the server's
+	// messages unless the client offers server_no_context_takeover
+	ServerContextTakeover bool
+
+	// ClientContextTakeover lets clients keep the context compressing
+	// their messages
+	ClientContextTakeover bool
+}
+
+// SetCompression sets the permessage-deflate options of new connections. It
+// must be called before serving requests.
+func (h *Handler) SetCompression(compression Compression) {
+	if compression.Level == 0 {
+		compression.Level = DefaultCompressionLevel
+	}
+	h.compression = compression
+	h.upgrader.EnableCompression = compression.Enabled && !compression.takesOverContext()
+}
+
+// offersDeflate reports whether a WebSocket upgrade request offers the
+// permessage-deflate extension
+func offersDeflate(r *http.Request) bool {
+	for _, header := range r.Header.Values("Sec-WebSocket-Extensions") {
+		for _, extension := range strings.Split(header, ",") {
+			name, _, _ := strings.Cut(extension, ";")
+			if strings.EqualFold(strings.TrimSpace(name), "permessage-deflate") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// setWriteCompression compresses the next message written to the connection
+// if compression was negotiated and the message is large enough. Connections
+// taking a context over compress below gorilla, see deflateConn.
+func (c *Connection) setWriteCompression(size int) {
+	if c.compressed && !c.handler.compression.takesOverContext() {
+		c.conn.EnableWriteCompression(size >= c.handler.compression.Threshold)
+	}
+}
@@ -0,0 +1,276 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+func TestOffersDeflate(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{"permessage-deflate", true},
+		{"permessage-deflate; client_max_window_bits", true},
+		{"x-webkit-deflate-frame, Permessage-Deflate; server_no_context_takeover", true},
+		{"x-webkit-deflate-frame", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+		if tt.header != "" {
+			r.Header.Set("Sec-WebSocket-Extensions", tt.header)
+		}
+		if got := offersDeflate(r); got != tt.want {
+			t.Errorf("offersDeflate(%q) = %v, expected %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestCompression(t *testing.T) {
+	handler := NewHandler(NewMockServer(), logger.NewConsoleLogger(logger.FatalLevel))
+	defer handler.Shutdown()
+	handler.SetCompression(Compression{Enabled: true, Threshold: 64})
+
+	srv := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	dialer := *websocket.DefaultDialer
+	dialer.EnableCompression = true
+	compressed, _, err := dialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer compressed.Close()
+	plain, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plain.Close()
+
+	for _, conn := range []*websocket.Conn{compressed, plain} {
+		var info models.ServerInfoMessage
+		if err := conn.ReadJSON(&info); err != nil || info.SchemaVersion != 11 {
+			t.Fatalf("Expected the server info, got %+v (%v)", info, err)
+		}
+		startListening(t, conn)
+	}
+
+	sessions := handler.Sessions()
+	if len(sessions) != 2 || !sessions[0].Compressed || sessions[1].Compressed {
+		t.Fatalf("Expected only the first session compressed, got %+v", sessions)
+	}
+
+	// Messages above and below the threshold arrive intact
+	label := strings.Repeat("Living room ", 100)
+	for _, value := range []string{label, "on"} {
+		handler.BroadcastEvent(models.EventMessage{
+			Event: models.EventTypeAttributeUpdated,
+			Data:  []interface{}{5, "1/40/5", value},
+		})
+		for _, conn := range []*websocket.Conn{compressed, plain} {
+			msg := readMessages(t, conn)[0]
+			if data, _ := msg["data"].([]interface{}); len(data) != 3 || data[2] != value {
+				t.Errorf("Expected the update %q, got %v", value, msg)
+			}
+		}
+	}
+}
+
+func TestNegotiateDeflate(t *testing.T) {
+	tests := []struct {
+		name        string
+		compression Compression
+		header      string
+		want        string
+	}{
+		{"no offer", Compression{}, "", ""},
+		{"no takeover", Compression{}, "permessage-deflate", "permessage-deflate; server_no_context_takeover; client_no_context_takeover"},
+		{"server takeover", Compression{ServerContextTakeover: true}, "permessage-deflate; client_max_window_bits", "permessage-deflate; client_no_context_takeover"},
+		{"client takeover", Compression{ClientContextTakeover: true}, "permessage-deflate", "permessage-deflate; server_no_context_takeover"},
+		{"both", Compression{ServerContextTakeover: true, ClientContextTakeover: true}, "permessage-deflate", "permessage-deflate"},
+		{"client declines", Compression{ServerContextTakeover: true, ClientContextTakeover: true},
+			"permessage-deflate; server_no_context_takeover; client_no_context_takeover",
+			"permessage-deflate; server_no_context_takeover; client_no_context_takeover"},
+		{"full window", Compression{ServerContextTakeover: true}, "permessage-deflate; server_max_window_bits=15", "permessage-deflate; client_no_context_takeover; server_max_window_bits=15"},
+		{"smaller window", Compression{ServerContextTakeover: true}, "permessage-deflate; server_max_window_bits=10", ""},
+		{"fallback", Compression{ServerContextTakeover: true}, "permessage-deflate; server_max_window_bits=10, permessage-deflate", "permessage-deflate; client_no_context_takeover"},
+		{"unknown parameter", Compression{}, "permessage-deflate; x-foo", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+			if tt.header != "" {
+				r.Header.Set("Sec-WebSocket-Extensions", tt.header)
+			}
+			params, ok := negotiateDeflate(r, tt.compression)
+			if got := params.extension(); ok != (tt.want != "") || ok && got != tt.want {
+				t.Errorf("Expected %q, got %q (%v)", tt.want, got, ok)
+			}
+		})
+	}
+}
+
+func TestCompressionContextTakeover(t *testing.T) {
+	handler := NewHandler(NewMockServer(), logger.NewConsoleLogger(logger.FatalLevel))
+	defer handler.Shutdown()
+	handler.SetCompression(Compression{Enabled: true, Level: 9, Threshold: 16, ServerContextTakeover: true, ClientContextTakeover: true})
+
+	srv := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	fmt.Fprintf(conn, "GET /ws HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n"+
+		"Sec-WebSocket-Extensions: permessage-deflate; client_max_window_bits\r\n\r\n", srv.Listener.Addr())
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Extensions") != "permessage-deflate" {
+		t.Fatalf("Expected permessage-deflate with context takeover, got %d %v", resp.StatusCode, resp.Header)
+	}
+
+	// The client keeps a single deflate stream for all its messages, and
+	// decompresses the server's messages as one stream
+	var sent bytes.Buffer
+	deflater, _ := flate.NewWriter(&sent, flate.BestCompression)
+	var received []byte
+	inflated := 0
+	readMessage := func() ([]byte, int) {
+		t.Helper()
+		f, err := readFrame(br, maxMessageSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !f.fin || f.rsv != deflateRSV1 || f.opcode != websocket.TextMessage {
+			t.Fatalf("Expected a compressed text message, got %+v", f)
+		}
+		received = append(append(received, f.payload...), deflateTail[:4]...)
+		data, err := io.ReadAll(flate.NewReader(io.MultiReader(bytes.NewReader(received), bytes.NewReader(deflateTail[4:]))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		message := data[inflated:]
+		inflated = len(data)
+		return message, len(f.payload)
+	}
+
+	if message, _ := readMessage(); !bytes.Contains(message, []byte(`"schema_version":11`)) {
+		t.Fatalf("Expected the server info, got %s", message)
+	}
+
+	sendCommand := func(id string, command models.APICommand) {
+		t.Helper()
+		sent.Reset()
+		deflater.Write([]byte(`{"message_id":"` + id + `","command":"` + string(command) + `"}`))
+		deflater.Flush()
+		payload := bytes.TrimSuffix(sent.Bytes(), deflateTail[:4])
+		frame := appendFrame(nil, wsFrame{fin: true, rsv: deflateRSV1, opcode: websocket.TextMessage, payload: payload}, true)
+		if _, err := conn.Write(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var sizes []int
+	for _, id := range []string{"first", "second"} {
+		sendCommand(id, models.APICommandServerInfo)
+		message, size := readMessage()
+		var result map[string]interface{}
+		if err := json.Unmarshal(message, &result); err != nil || result["message_id"] != id {
+			t.Fatalf("Expected the %s server_info result, got %s (%v)", id, message, err)
+		}
+		sizes = append(sizes, size)
+	}
+
+	// The second result mostly refers to the first
+	if sizes[1] >= sizes[0]/2 {
+		t.Errorf("Expected the second result compressed against the first, got %v bytes", sizes)
+	}
+
+	// Messages larger than the write buffer are written in several frames
+	// but sent as a single compressed one
+	sendCommand("listen", models.APICommandStartListening)
+	readMessage()
+	label := strings.Repeat("Living room ", 1000)
+	handler.BroadcastEvent(models.EventMessage{
+		Event: models.EventTypeAttributeUpdated,
+		Data:  []interface{}{5, "1/40/5", label},
+	})
+	if message, _ := readMessage(); !bytes.Contains(message, []byte(label)) {
+		t.Errorf("Expected the update, got %s", message)
+	}
+}
+
+func TestDeflateReusesWriter(t *testing.T) {
+	message := []byte(strings.Repeat(`{"event":"attribute_updated","data":[1,"1/6/0",true]}`, 4))
+
+	for _, takeover := range []bool{true, false} {
+		t.Run(fmt.Sprintf("takeover=%v", takeover), func(t *testing.T) {
+			c := newDeflateConn(nil, deflateParams{serverTakeover: takeover}, Compression{Level: 9}, 1<<20)
+
+			// The client inflates each message with the previous ones as its
+			// context when the server takes it over
+			var window []byte
+			var sizes []int
+			for i := range 3 {
+				compressed, err := c.deflate(message)
+				if err != nil {
+					t.Fatalf("Message %d: %v", i, err)
+				}
+				sizes = append(sizes, len(compressed))
+
+				r := flate.NewReaderDict(io.MultiReader(bytes.NewReader(compressed), bytes.NewReader(deflateTail)), window)
+				data, err := io.ReadAll(r)
+				if err != nil || !bytes.Equal(data, message) {
+					t.Fatalf("Message %d inflated to %q (%v)", i, data, err)
+				}
+				if takeover {
+					window = appendWindow(window, data)
+				}
+			}
+
+			if takeover {
+				if c.writer == nil {
+					t.Fatal("Expected the connection to keep its writer")
+				}
+				if sizes[1] >= sizes[0] {
+					t.Errorf("Expected later messages to use the taken over context, got sizes %v", sizes)
+				}
+
+				before := c.writer
+				if allocs := testing.AllocsPerRun(10, func() { c.deflate(message) }); allocs > 0 {
+					t.Errorf("Expected the writer to be reused, got %v allocations per message", allocs)
+				}
+				if c.writer != before {
+					t.Error("Expected the same writer for every message")
+				}
+			} else if c.writer != nil || sizes[1] != sizes[0] {
+				t.Errorf("Expected independent messages from pooled writers, got sizes %v", sizes)
+			}
+		})
+	}
+}
@@ -0,0 +1,500 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// gorilla/websocket only implements permessage-deflate without context
+// takeover. Connections negotiating a context takeover are compressed here
+// instead, below gorilla: deflateConn rewrites the frames gorilla writes and
+// reads, so gorilla only ever sees uncompressed messages.
+
+const (
+	// deflateWindowSize is the LZ77 window kept between messages when the
+	// compression context is taken over
+	deflateWindowSize = 32 * 1024
+
+	// deflateRSV1 marks the first frame of a compressed message
+	deflateRSV1 = 0x40
+)
+
+// deflateTail completes the compressed data of a message: the empty stored
+// block the sender removed (RFC 7692 section 7.2.1) and a final empty block
+var deflateTail = []byte{0x00, 0x00, 0xff, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff}
+
+// deflateWriters pools the writers of connections compressing each message
+// on its own, by level. A flate.Writer takes hundreds of KiB, so they are
+// shared rather than allocated per message or kept per connection.
+var deflateWriters [flate.BestCompression + 1]sync.Pool
+
+// deflateParams are the negotiated permessage-deflate parameters
+type deflateParams struct {
+	// serverTakeover and clientTakeover tell whether the server and the
+	// client keep their compression context between messages
+	serverTakeover bool
+	clientTakeover bool
+
+	// serverMaxWindowBits echoes the server_max_window_bits the client
+	// offered, the server always uses the full window
+	serverMaxWindowBits bool
+}
+
+// takesOverContext reports whether connections negotiating compression keep
+// a compression context between messages on either side
+func (c Compression) takesOverContext() bool {
+	return c.ServerContextTakeover || c.ClientContextTakeover
+}
+
+// negotiateDeflate picks the first permessage-deflate offer of a WebSocket
+// upgrade request whose parameters are supported
+func negotiateDeflate(r *http.Request, compression Compression) (deflateParams, bool) {
+	for _, header := range r.Header.Values("Sec-WebSocket-Extensions") {
+		for _, offer := range strings.Split(header, ",") {
+			if params, ok := parseDeflateOffer(offer, compression); ok {
+				return params, true
+			}
+		}
+	}
+	return deflateParams{}, false
+}
+
+// parseDeflateOffer parses a single extension offer, declining other
+// extensions and permessage-deflate offers the server can't honor
+func parseDeflateOffer(offer string, compression Compression) (deflateParams, bool) {
+	fields := strings.Split(offer, ";")
+	if !strings.EqualFold(strings.TrimSpace(fields[0]), "permessage-deflate") {
+		return deflateParams{}, false
+	}
+
+	params := deflateParams{
+		serverTakeover: compression.ServerContextTakeover,
+		clientTakeover: compression.ClientContextTakeover,
+	}
+	seen := make(map[string]bool)
+	for _, field := range fields[1:] {
+		name, value, hasValue := strings.Cut(field, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+		if seen[name] {
+			return deflateParams{}, false
+		}
+		seen[name] = true
+
+		switch name {
+		case "server_no_context_takeover":
+			if hasValue {
+				return deflateParams{}, false
+			}
+			params.serverTakeover = false
+		case "client_no_context_takeover":
+			if hasValue {
+				return deflateParams{}, false
+			}
+			params.clientTakeover = false
+		case "server_max_window_bits":
+			// Only the full window is supported
+			if value != "15" {
+				return deflateParams{}, false
+			}
+			params.serverMaxWindowBits = true
+		case "client_max_window_bits":
+			// A hint the server may ignore, it decompresses any window size
+		default:
+			return deflateParams{}, false
+		}
+	}
+	return params, true
+}
+
+// extension returns the Sec-WebSocket-Extensions response accepting an offer
+func (p deflateParams) extension() string {
+	extension := "permessage-deflate"
+	if !p.serverTakeover {
+		extension += "; server_no_context_takeover"
+	}
+	if !p.clientTakeover {
+		extension += "; client_no_context_takeover"
+	}
+	if p.serverMaxWindowBits {
+		extension += "; server_max_window_bits=15"
+	}
+	return extension
+}
+
+// deflateResponseWriter hands a deflateConn to the upgrader hijacking the
+// connection
+type deflateResponseWriter struct {
+	http.ResponseWriter
+	newConn func(net.Conn) *deflateConn
+}
+
+// Hijack implements http.Hijacker
+func (w *deflateResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	// The buffered bytes would bypass the deflateConn
+	if brw.Reader.Buffered() > 0 {
+		conn.Close()
+		return nil, nil, errors.New("client sent data before the handshake completed")
+	}
+	dc := w.newConn(conn)
+	return dc, bufio.NewReadWriter(bufio.NewReader(dc), bufio.NewWriter(dc)), nil
+}
+
+// wsFrame is a WebSocket frame with an unmasked payload
+type wsFrame struct {
+	fin     bool
+	rsv     byte
+	opcode  byte
+	payload []byte
+}
+
+// control reports whether the frame is a control frame (close, ping, pong)
+func (f wsFrame) control() bool {
+	return f.opcode&0x8 != 0
+}
+
+// readFrame reads a frame from r, rejecting payloads larger than limit
+func readFrame(r io.Reader, limit int64) (wsFrame, error) {
+	var header [14]byte
+	if _, err := io.ReadFull(r, header[:2]); err != nil {
+		return wsFrame{}, err
+	}
+	f := wsFrame{
+		fin:    header[0]&0x80 != 0,
+		rsv:    header[0] & 0x70,
+		opcode: header[0] & 0x0f,
+	}
+
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		if _, err := io.ReadFull(r, header[2:4]); err != nil {
+			return wsFrame{}, unexpectedEOF(err)
+		}
+		length = uint64(binary.BigEndian.Uint16(header[2:4]))
+	case 127:
+		if _, err := io.ReadFull(r, header[2:10]); err != nil {
+			return wsFrame{}, unexpectedEOF(err)
+		}
+		length = binary.BigEndian.Uint64(header[2:10])
+	}
+	if length > uint64(limit) {
+		return wsFrame{}, fmt.Errorf("frame of %d bytes exceeds the %d bytes limit", length, limit)
+	}
+
+	var key [4]byte
+	masked := header[1]&0x80 != 0
+	if masked {
+		if _, err := io.ReadFull(r, key[:]); err != nil {
+			return wsFrame{}, unexpectedEOF(err)
+		}
+	}
+
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(r, f.payload); err != nil {
+		return wsFrame{}, unexpectedEOF(err)
+	}
+	if masked {
+		for i := range f.payload {
+			f.payload[i] ^= key[i%4]
+		}
+	}
+	return f, nil
+}
+
+// unexpectedEOF turns the end of the input in the middle of a frame into
+// io.ErrUnexpectedEOF
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// appendFrame appends the encoding of a frame to dst. Masked frames use a
+// zero key, which leaves the payload as is.
+func appendFrame(dst []byte, f wsFrame, masked bool) []byte {
+	b0 := f.rsv | f.opcode
+	if f.fin {
+		b0 |= 0x80
+	}
+	var b1 byte
+	if masked {
+		b1 = 0x80
+	}
+
+	switch n := len(f.payload); {
+	case n < 126:
+		dst = append(dst, b0, b1|byte(n))
+	case n <= math.MaxUint16:
+		dst = append(dst, b0, b1|126)
+		dst = binary.BigEndian.AppendUint16(dst, uint16(n))
+	default:
+		dst = append(dst, b0, b1|127)
+		dst = binary.BigEndian.AppendUint64(dst, uint64(n))
+	}
+	if masked {
+		dst = append(dst, 0, 0, 0, 0)
+	}
+	return append(dst, f.payload...)
+}
+
+// appendWindow appends data to a compression context, keeping its last
+// deflateWindowSize bytes
+func appendWindow(window, data []byte) []byte {
+	if len(data) >= deflateWindowSize {
+		return append([]byte(nil), data[len(data)-deflateWindowSize:]...)
+	}
+	if len(window)+len(data) > deflateWindowSize {
+		window = append([]byte(nil), window[len(window)+len(data)-deflateWindowSize:]...)
+	}
+	return append(window, data...)
+}
+
+// deflateConn compresses the messages the server writes and decompresses
+// the messages the client sends, keeping the compression contexts the
+// negotiation allows
+type deflateConn struct {
+	net.Conn
+	params    deflateParams
+	extension string
+	level     int
+	threshold int
+	readLimit int64
+
+	// Read side: the client frames rewritten for gorilla but not read yet
+	// and the compressed message being received
+	reader      *bufio.Reader
+	readBuf     []byte
+	inbound     []byte
+	inboundOp   byte
+	inflating   bool
+	readContext []byte
+
+	// Write side: the bytes written not forming a complete frame yet, the
+	// message being written and, with server context takeover, the writer
+	// keeping the compression context
+	writeMu    sync.Mutex
+	handshaken bool
+	pending    []byte
+	outbound   []byte
+	outboundOp byte
+	writer     *flate.Writer
+	deflated   bytes.Buffer
+}
+
+// newDeflateConn wraps a hijacked connection negotiating compression
+func newDeflateConn(conn net.Conn, params deflateParams, compression Compression, readLimit int64) *deflateConn {
+	return &deflateConn{
+		Conn:      conn,
+		params:    params,
+		extension: params.extension(),
+		level:     compression.Level,
+		threshold: compression.Threshold,
+		readLimit: readLimit,
+		reader:    bufio.NewReader(conn),
+	}
+}
+
+// Read returns the client frames with compressed messages decompressed
+func (c *deflateConn) Read(p []byte) (int, error) {
+	for len(c.readBuf) == 0 {
+		if err := c.readClientFrame(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, c.readBuf)
+	c.readBuf = c.readBuf[n:]
+	return n, nil
+}
+
+// readClientFrame reads a client frame, passing it on unless it belongs to
+// a compressed message, which is passed on as a single frame once complete
+func (c *deflateConn) readClientFrame() error {
+	f, err := readFrame(c.reader, c.readLimit)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case f.control():
+	case f.opcode != 0 && f.rsv&deflateRSV1 != 0:
+		f.rsv &^= deflateRSV1
+		if !f.fin {
+			c.inflating = true
+			c.inboundOp = f.opcode
+			c.inbound = f.payload
+			return nil
+		}
+		if f.payload, err = c.inflate(f.payload); err != nil {
+			return err
+		}
+	case f.opcode == 0 && c.inflating:
+		if f.rsv&deflateRSV1 != 0 {
+			return errors.New("compressed continuation frame")
+		}
+		if int64(len(c.inbound)+len(f.payload)) > c.readLimit {
+			return fmt.Errorf("compressed message exceeds the %d bytes limit", c.readLimit)
+		}
+		c.inbound = append(c.inbound, f.payload...)
+		if !f.fin {
+			return nil
+		}
+		c.inflating = false
+		f.opcode = c.inboundOp
+		if f.payload, err = c.inflate(c.inbound); err != nil {
+			return err
+		}
+		c.inbound = nil
+	}
+
+	// gorilla requires the server to receive masked frames
+	c.readBuf = appendFrame(nil, f, true)
+	return nil
+}
+
+// inflate decompresses the payload of a client message
+func (c *deflateConn) inflate(payload []byte) ([]byte, error) {
+	r := flate.NewReaderDict(io.MultiReader(bytes.NewReader(payload), bytes.NewReader(deflateTail)), c.readContext)
+	defer r.Close()
+
+	data, err := io.ReadAll(io.LimitReader(r, c.readLimit+1))
+	if err != nil {
+		return nil, fmt.Errorf("decompress message: %w", err)
+	}
+	if int64(len(data)) > c.readLimit {
+		return nil, fmt.Errorf("decompressed message exceeds the %d bytes limit", c.readLimit)
+	}
+	if c.params.clientTakeover {
+		c.readContext = appendWindow(c.readContext, data)
+	}
+	return data, nil
+}
+
+// Write passes the handshake response on with the negotiated extension and
+// compresses the messages in the frames following it
+func (c *deflateConn) Write(p []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.pending = append(c.pending, p...)
+	var out []byte
+	if !c.handshaken {
+		end := bytes.Index(c.pending, []byte("\r\n\r\n"))
+		if end < 0 {
+			return len(p), nil
+		}
+		out = append(out, c.pending[:end+2]...)
+		out = append(out, "Sec-WebSocket-Extensions: "+c.extension+"\r\n\r\n"...)
+		c.pending = c.pending[end+4:]
+		c.handshaken = true
+	}
+
+	for {
+		r := bytes.NewReader(c.pending)
+		f, err := readFrame(r, math.MaxInt64)
+		if err != nil {
+			// The rest of the frame is written later
+			break
+		}
+		c.pending = c.pending[len(c.pending)-r.Len():]
+		if out, err = c.writeServerFrame(out, f); err != nil {
+			return 0, err
+		}
+	}
+	// Don't keep the consumed bytes alive
+	c.pending = append([]byte(nil), c.pending...)
+
+	if len(out) > 0 {
+		if _, err := c.Conn.Write(out); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// writeServerFrame appends a server frame to out, holding the frames of a
+// data message back until it is complete to compress it as a whole
+func (c *deflateConn) writeServerFrame(out []byte, f wsFrame) ([]byte, error) {
+	if f.control() {
+		return appendFrame(out, f, false), nil
+	}
+	if f.opcode != 0 {
+		c.outboundOp = f.opcode
+		c.outbound = f.payload
+	} else {
+		c.outbound = append(c.outbound, f.payload...)
+	}
+	if !f.fin {
+		return out, nil
+	}
+
+	message := wsFrame{fin: true, opcode: c.outboundOp, payload: c.outbound}
+	c.outbound = nil
+	if len(message.payload) >= c.threshold {
+		compressed, err := c.deflate(message.payload)
+		if err != nil {
+			return nil, err
+		}
+		message.rsv = deflateRSV1
+		message.payload = compressed
+	}
+	return appendFrame(out, message, false), nil
+}
+
+// deflate compresses the payload of a server message. The result is valid
+// until the next call.
+func (c *deflateConn) deflate(data []byte) ([]byte, error) {
+	c.deflated.Reset()
+
+	w := c.writer
+	if w == nil {
+		var err error
+		if w, err = c.newWriter(); err != nil {
+			return nil, err
+		}
+	}
+	_, err := w.Write(data)
+	if err == nil {
+		// Flushing ends the message but keeps the writer's window, the
+		// context taken over by the next message
+		err = w.Flush()
+	}
+	if c.params.serverTakeover {
+		c.writer = w
+	} else {
+		deflateWriters[c.level].Put(w)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The client adds the empty stored block ending the flush back
+	return bytes.TrimSuffix(c.deflated.Bytes(), deflateTail[:4]), nil
+}
+
+// newWriter returns a writer with an empty context compressing into
+// c.deflated, reusing a pooled one if possible
+func (c *deflateConn) newWriter() (*flate.Writer, error) {
+	if c.level < 0 || c.level >= len(deflateWriters) {
+		return nil, fmt.Errorf("invalid compression level: %d", c.level)
+	}
+	if w, ok := deflateWriters[c.level].Get().(*flate.Writer); ok {
+		w.Reset(&c.deflated)
+		return w, nil
+	}
+	return flate.NewWriter(&c.deflated, c.level)
+}
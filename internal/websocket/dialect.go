@@ -0,0 +1,210 @@
+package websocket
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// Dialect selects the shape of the messages on the WebSocket
+type Dialect string
+
+const (
+	// DialectNative sends the messages of this server, a superset of
+	// python-matter-server's
+	DialectNative Dialect = "native"
+	// DialectHomeAssistant sends only what the python-matter-server client
+	// used by the Home Assistant Matter integration parses: its error
+	// codes, events and message fields
+	DialectHomeAssistant Dialect = "home_assistant"
+)
+
+// ParseDialect parses a dialect name. An empty name selects DialectNative.
+func ParseDialect(name string) (Dialect, error) {
+	switch Dialect(name) {
+	case "", DialectNative:
+		return DialectNative, nil
+	case DialectHomeAssistant:
+		return DialectHomeAssistant, nil
+	}
+	return "", fmt.Errorf("unknown WebSocket dialect %q", name)
+}
+
+// Error codes of python-matter-server (matter_server/common/errors.py)
+const (
+	pythonErrorUnknown         = 0
+	pythonErrorNodeNotExists   = 5
+	pythonErrorVersionMismatch = 6
+	pythonErrorInvalidArgs     = 8
+	pythonErrorInvalidCommand  = 9
+)
+
+// pythonEvents are the event types of python-matter-server. Its client
+// fails on messages with other event types.
+var pythonEvents = map[models.EventType]bool{
+	models.EventTypeNodeAdded:         true,
+	models.EventTypeNodeUpdated:       true,
+	models.EventTypeNodeRemoved:       true,
+	models.EventTypeNodeEvent:         true,
+	models.EventTypeAttributeUpdated:  true,
+	models.EventTypeServerShutdown:    true,
+	models.EventTypeServerInfoUpdated: true,
+	models.EventTypeEndpointAdded:     true,
+	models.EventTypeEndpointRemoved:   true,
+}
+
+// sendsEvent reports whether an event type exists in the dialect
+func (d Dialect) sendsEvent(eventType models.EventType) bool {
+	return d != DialectHomeAssistant || pythonEvents[eventType]
+}
+
+// errorCode converts an error code of the native dialect
+func (d Dialect) errorCode(code int) int {
+	if d != DialectHomeAssistant {
+		return code
+	}
+	switch code {
+	case models.ErrorCodeInvalidMessage:
+		return pythonErrorInvalidCommand
+	case models.ErrorCodeInvalidArguments:
+		return pythonErrorInvalidArgs
+	case models.ErrorCodeSchemaVersionMismatch:
+		return pythonErrorVersionMismatch
+	}
+	return pythonErrorUnknown
+}
+
+// commandErrorCode returns the error code of a failed command.
+// python-matter-server tells unknown nodes and commands apart.
+func (d Dialect) commandErrorCode(err error) int {
+	if errors.Is(err, models.ErrShuttingDown) {
+		return d.errorCode(models.ErrorCodeServerRestarting)
+	}
+	if errors.Is(err, models.ErrCommandTimeout) {
+		return d.errorCode(models.ErrorCodeTimeout)
+	}
+	if errors.Is(err, models.ErrUnauthorized) {
+		return d.errorCode(models.ErrorCodeUnauthorized)
+	}
+	if d == DialectHomeAssistant {
+		var notFound *models.NodeNotFoundError
+		switch {
+		case errors.As(err, &notFound):
+			return pythonErrorNodeNotExists
+		case errors.Is(err, models.ErrUnknownCommand):
+			return pythonErrorInvalidCommand
+		}
+	}
+	return d.errorCode(models.ErrorCodeCommandFailed)
+}
+
+// adaptMessage leaves out the fields of error messages the dialect doesn't
+// have
+func (d Dialect) adaptMessage(msg interface{}) interface{} {
+	if errMsg, ok := msg.(models.ErrorResultMessage); ok && d == DialectHomeAssistant {
+		errMsg.SupportedSchemaVersions = nil
+		errMsg.CancelledBy = ""
+		errMsg.Field = ""
+		return errMsg
+	}
+	return msg
+}
+
+// pythonNode is a node as python-matter-server sends it, with the
+// attribute subscriptions as (endpoint, cluster, attribute) tuples
+type pythonNode struct {
+	NodeID                 int                    `json:"node_id"`
+	DateCommissioned       time.Time              `json:"date_commissioned"`
+	LastInterview          time.Time              `json:"last_interview"`
+	InterviewVersion       int                    `json:"interview_version"`
+	Available              bool                   `json:"available"`
+	IsBridge               bool                   `json:"is_bridge"`
+	Attributes             map[string]interface{} `json:"attributes"`
+	AttributeSubscriptions [][3]*int              `json:"attribute_subscriptions"`
+}
+
+// pythonNodeEvent is a node event, whose data python-matter-server always
+// sends
+type pythonNodeEvent struct {
+	models.MatterNodeEvent
+	Data map[string]interface{} `json:"data"`
+}
+
+// pythonDiagnostics is the result of diagnostics in python-matter-server
+type pythonDiagnostics struct {
+	Info   models.ServerInfoMessage `json:"info"`
+	Nodes  []*pythonNode            `json:"nodes"`
+	Events []models.EventMessage    `json:"events"`
+}
+
+// adaptData converts command results and event data to the dialect
+func (d Dialect) adaptData(data interface{}) interface{} {
+	if d != DialectHomeAssistant {
+		return data
+	}
+
+	switch v := data.(type) {
+	case *models.MatterNodeData:
+		return newPythonNode(v)
+	case []*models.MatterNodeData:
+		nodes := make([]*pythonNode, len(v))
+		for i, node := range v {
+			nodes[i] = newPythonNode(node)
+		}
+		return nodes
+	case models.MatterNodeEvent:
+		return pythonNodeEvent{MatterNodeEvent: v, Data: v.Data}
+	case *models.MatterNodeEvent:
+		return pythonNodeEvent{MatterNodeEvent: *v, Data: v.Data}
+	case models.ServerInfoMessage:
+		return pythonServerInfo(v)
+	case *models.ServerInfoMessage:
+		return pythonServerInfo(*v)
+	case models.ServerDiagnostics:
+		diagnostics := pythonDiagnostics{
+			Info:   pythonServerInfo(v.Info),
+			Nodes:  make([]*pythonNode, len(v.Nodes)),
+			Events: []models.EventMessage{},
+		}
+		for i := range v.Nodes {
+			diagnostics.Nodes[i] = newPythonNode(v.Nodes[i])
+		}
+		for _, event := range v.Events {
+			if pythonEvents[event.Event] {
+				diagnostics.Events = append(diagnostics.Events, models.EventMessage{Event: event.Event, Data: event.Data})
+			}
+		}
+		return diagnostics
+	}
+	return data
+}
+
+func newPythonNode(node *models.MatterNodeData) *pythonNode {
+	subscriptions := make([][3]*int, len(node.AttributeSubscriptions))
+	for i, sub := range node.AttributeSubscriptions {
+		subscriptions[i] = [3]*int{sub.EndpointID, sub.ClusterID, sub.AttributeID}
+	}
+	attributes := node.Attributes
+	if attributes == nil {
+		attributes = map[string]interface{}{}
+	}
+	return &pythonNode{
+		NodeID:                 node.NodeID,
+		DateCommissioned:       node.DateCommissioned,
+		LastInterview:          node.LastInterview,
+		InterviewVersion:       node.InterviewVersion,
+		Available:              node.Available,
+		IsBridge:               node.IsBridge,
+		Attributes:             attributes,
+		AttributeSubscriptions: subscriptions,
+	}
+}
+
+// pythonServerInfo leaves out the fields python-matter-server doesn't send
+func pythonServerInfo(info models.ServerInfoMessage) models.ServerInfoMessage {
+	info.BluetoothAdapters = nil
+	info.Fabrics = nil
+	return info
+}
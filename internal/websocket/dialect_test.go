@@ -0,0 +1,263 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/progress"
+)
+
+func TestParseDialect(t *testing.T) {
+	tests := map[string]Dialect{
+		"":               DialectNative,
+		"native":         DialectNative,
+		"home_assistant": DialectHomeAssistant,
+	}
+	for name, expected := range tests {
+		if dialect, err := ParseDialect(name); err != nil || dialect != expected {
+			t.Errorf("ParseDialect(%q) = %q, %v, expected %q", name, dialect, err, expected)
+		}
+	}
+	if _, err := ParseDialect("python"); err == nil {
+		t.Error("Expected an error for an unknown dialect")
+	}
+}
+
+func TestDialectCommandErrorCode(t *testing.T) {
+	tests := []struct {
+		err      error
+		native   int
+		expected int
+	}{
+		{&models.NodeNotFoundError{NodeID: 5}, models.ErrorCodeCommandFailed, pythonErrorNodeNotExists},
+		{fmt.Errorf("failed: %w", &models.NodeNotFoundError{NodeID: 5}), models.ErrorCodeCommandFailed, pythonErrorNodeNotExists},
+		{fmt.Errorf("%w: foo", models.ErrUnknownCommand), models.ErrorCodeCommandFailed, pythonErrorInvalidCommand},
+		{errors.New("timeout"), models.ErrorCodeCommandFailed, pythonErrorUnknown},
+		{fmt.Errorf("%w: read_attribute", models.ErrCommandTimeout), models.ErrorCodeTimeout, pythonErrorUnknown},
+	}
+	for _, tt := range tests {
+		if code := DialectNative.commandErrorCode(tt.err); code != tt.native {
+			t.Errorf("Native code for %v = %d, expected %d", tt.err, code, tt.native)
+		}
+		if code := DialectHomeAssistant.commandErrorCode(tt.err); code != tt.expected {
+			t.Errorf("Home Assistant code for %v = %d, expected %d", tt.err, code, tt.expected)
+		}
+	}
+}
+
+// transcriptServer answers the commands of the Home Assistant transcripts
+// like the server does, with a single node 1
+type transcriptServer struct {
+	mu        sync.Mutex
+	callbacks []models.EventCallback
+}
+
+func (ts *transcriptServer) node() *models.MatterNodeData {
+	endpoint, cluster := 1, 6
+	return &models.MatterNodeData{
+		NodeID:                 1,
+		Available:              true,
+		Attributes:             map[string]interface{}{"1/6/0": true},
+		AttributeSubscriptions: []models.AttributeSubscription{{EndpointID: &endpoint, ClusterID: &cluster}},
+	}
+}
+
+func (ts *transcriptServer) HandleCommand(ctx context.Context, cmd models.CommandMessage) (interface{}, error) {
+	nodeID, _ := cmd.Args["node_id"].(float64)
+	switch models.APICommand(cmd.Command) {
+	case models.APICommandStartListening, models.APICommandGetNodes:
+		return []*models.MatterNodeData{ts.node()}, nil
+	case models.APICommandGetNode, models.APICommandRemoveNode, models.APICommandInterviewNode:
+		if nodeID != 1 {
+			return nil, &models.NodeNotFoundError{NodeID: int(nodeID)}
+		}
+		if cmd.Command == string(models.APICommandGetNode) {
+			return ts.node(), nil
+		}
+		progress.Report(ctx, "interviewing", 50)
+		return nil, nil
+	case models.APICommandDeviceCommand:
+		if _, ok := cmd.Args["node_id"]; !ok {
+			return nil, &models.ArgumentError{Field: "node_id", Reason: "missing required argument"}
+		}
+		return nil, nil
+	case models.APICommandCommissionWithCode:
+		return nil, errors.New("commissioning failed")
+	}
+	return nil, fmt.Errorf("%w: %s", models.ErrUnknownCommand, cmd.Command)
+}
+
+func (ts *transcriptServer) Subscribe(callback models.EventCallback) func() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.callbacks = append(ts.callbacks, callback)
+	return func() {}
+}
+
+func (ts *transcriptServer) GetServerInfo() models.ServerInfoMessage {
+	return models.ServerInfoMessage{
+		FabricID:          1,
+		SchemaVersion:     11,
+		SDKVersion:        "test-1.0.0",
+		BluetoothAdapters: []string{"hci0"},
+		Fabrics:           []models.ServerFabric{{FabricID: 1, VendorID: 0xFFF1}},
+	}
+}
+
+// emit sends an event of a transcript with the data type the server uses
+func (ts *transcriptServer) emit(t *testing.T, event models.EventType, raw json.RawMessage) {
+	t.Helper()
+
+	var data interface{}
+	switch event {
+	case models.EventTypeNodeAdded, models.EventTypeNodeUpdated:
+		data = &models.MatterNodeData{}
+	case models.EventTypeNodeEvent:
+		data = &models.MatterNodeEvent{}
+	default:
+		data = new(interface{})
+	}
+	if err := json.Unmarshal(raw, data); err != nil {
+		t.Fatalf("Invalid %s data: %v", event, err)
+	}
+	if generic, ok := data.(*interface{}); ok {
+		data = *generic
+	}
+
+	ts.mu.Lock()
+	callbacks := ts.callbacks
+	ts.mu.Unlock()
+	for _, callback := range callbacks {
+		callback(event, data)
+	}
+}
+
+// transcript is a conversation of the python-matter-server client with the
+// server. Each step sends a client message, emits a server event or expects
+// the next message from the server, whose fields must include those given
+// and leave out the absent ones.
+type transcript struct {
+	Description string `json:"description"`
+	Steps       []struct {
+		Client json.RawMessage `json:"client"`
+		Event  *struct {
+			Event models.EventType `json:"event"`
+			Data  json.RawMessage  `json:"data"`
+		} `json:"event"`
+		Server map[string]interface{} `json:"server"`
+		Absent []string               `json:"absent"`
+	} `json:"steps"`
+}
+
+// TestHomeAssistantTranscripts replays the transcripts in
+// testdata/home_assistant. They are written after the messages the
+// python-matter-server client sends and the fields it parses, not captured
+// from a live Home Assistant.
+func TestHomeAssistantTranscripts(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "home_assistant", "*.json"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("No transcripts found: %v", err)
+	}
+
+	for _, file := range files {
+		t.Run(strings.TrimSuffix(filepath.Base(file), ".json"), func(t *testing.T) {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatalf("Failed to read transcript: %v", err)
+			}
+			var tr transcript
+			if err := json.Unmarshal(content, &tr); err != nil {
+				t.Fatalf("Invalid transcript: %v", err)
+			}
+
+			server := &transcriptServer{}
+			handler := NewHandler(server, logger.NewConsoleLogger(logger.FatalLevel))
+			handler.SetDialect(DialectHomeAssistant)
+			srv := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+			defer srv.Close()
+			conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+			if err != nil {
+				t.Fatalf("Failed to connect: %v", err)
+			}
+			defer conn.Close()
+
+			var pending []map[string]interface{}
+			for i, step := range tr.Steps {
+				switch {
+				case step.Client != nil:
+					if err := conn.WriteMessage(websocket.TextMessage, step.Client); err != nil {
+						t.Fatalf("Step %d: failed to send: %v", i, err)
+					}
+				case step.Event != nil:
+					server.emit(t, step.Event.Event, step.Event.Data)
+				default:
+					if len(pending) == 0 {
+						pending = readMessages(t, conn)
+					}
+					msg := pending[0]
+					pending = pending[1:]
+					if !containsFields(msg, step.Server) {
+						t.Fatalf("Step %d: expected %v, got %v", i, step.Server, msg)
+					}
+					for _, field := range step.Absent {
+						if _, ok := msg[field]; ok {
+							t.Errorf("Step %d: expected no %s, got %v", i, field, msg)
+						}
+					}
+				}
+			}
+
+			// Nothing else may arrive, e.g. dropped events
+			conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+			if _, data, err := conn.ReadMessage(); len(pending) > 0 || err == nil {
+				t.Errorf("Unexpected messages %v %s", pending, data)
+			}
+		})
+	}
+}
+
+// containsFields reports whether actual has the expected fields, comparing
+// objects by the expected keys only and everything else exactly
+func containsFields(actual, expected interface{}) bool {
+	switch expected := expected.(type) {
+	case map[string]interface{}:
+		actual, ok := actual.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, value := range expected {
+			field, ok := actual[key]
+			if !ok || !containsFields(field, value) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		actual, ok := actual.([]interface{})
+		if !ok || len(actual) != len(expected) {
+			return false
+		}
+		for i := range expected {
+			if !containsFields(actual[i], expected[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(actual, expected)
+}
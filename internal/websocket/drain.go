@@ -0,0 +1,94 @@
+package websocket
+
+import (
+	"context"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// drainPollInterval is how often Drain checks for in-flight commands
+const drainPollInterval = 20 * time.Millisecond
+
+// Drain prepares the handler for shutdown. It announces the shutdown to all
+// clients with a server_restarting event, rejects new connections and
+// commands, and waits until in-flight commands were answered or ctx is done.
+// Connections are then closed with a service restart close frame carrying
+// the notice's reason.
+func (h *Handler) Drain(ctx context.Context, notice models.ServerRestarting) {
+	if !h.draining.CompareAndSwap(false, true) {
+		return
+	}
+	h.logger.Info("Draining WebSocket connections",
+		logger.Int("connections", h.GetConnectionCount()),
+		logger.String("reason", notice.Reason),
+	)
+
+	event := models.EventMessage{Event: models.EventTypeServerRestarting, Data: notice}
+	for _, conn := range h.snapshotConnections() {
+		if !eventSupported(conn.getSchemaVersion(), event.Event) {
+			continue
+		}
+		if err := conn.sendMessage(event); err != nil {
+			conn.logger.Error("Failed to send restart notice", logger.ErrorField(err))
+		}
+	}
+
+	if !h.waitFor(ctx, h.idle) {
+		h.logger.Warn("Drain timeout passed, closing connections with commands in flight")
+	}
+
+	// The write pumps flush queued messages before the close frame
+	for _, conn := range h.snapshotConnections() {
+		select {
+		case conn.closing <- notice.Reason:
+		default:
+		}
+	}
+	closeCtx, cancel := context.WithTimeout(context.Background(), writeWait)
+	defer cancel()
+	h.waitFor(closeCtx, func() bool { return h.GetConnectionCount() == 0 })
+}
+
+// idle reports whether no connection has commands in flight
+func (h *Handler) idle() bool {
+	for _, conn := range h.snapshotConnections() {
+		if conn.inFlight.Load() > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// waitFor polls done until it reports true, returning false if ctx is done
+// first
+func (h *Handler) waitFor(ctx context.Context, done func() bool) bool {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for !done() {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+	return true
+}
+
+// writeClose flushes the send queue and writes a service restart close frame
+func (c *Connection) writeClose(reason string) error {
+	c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	if batch := c.queue.drain(); len(batch) > 0 {
+		if err := c.writeBatch(batch); err != nil {
+			return err
+		}
+		c.sent.Add(uint64(len(batch)))
+	}
+
+	message := websocket.FormatCloseMessage(websocket.CloseServiceRestart, reason)
+	return c.conn.WriteMessage(websocket.CloseMessage, message)
+}
@@ -0,0 +1,231 @@
+package websocket
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// optInEvents are only delivered to connections listing them in the events
+// filter, since they are frequent and few clients want them
+var optInEvents = map[models.EventType]bool{
+	models.EventTypeLogEntry: true,
+}
+
+// eventFilter restricts the events delivered to a connection. Empty fields
+// don't restrict anything.
+type eventFilter struct {
+	events         map[models.EventType]bool
+	nodeIDs        map[int]bool
+	attributePaths []string
+}
+
+// parseEventFilter reads the events, node_ids and attribute_paths arguments
+// of start_listening. It returns nil if no filter was requested.
+func parseEventFilter(args map[string]interface{}) (*eventFilter, error) {
+	filter := &eventFilter{}
+
+	events, err := stringList(args, "events")
+	if err != nil {
+		return nil, err
+	}
+	if len(events) > 0 {
+		filter.events = make(map[models.EventType]bool, len(events))
+		for _, event := range events {
+			filter.events[models.EventType(event)] = true
+		}
+	}
+
+	if v, ok := args["node_ids"]; ok && v != nil {
+		items, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid node_ids: expected list")
+		}
+		filter.nodeIDs = make(map[int]bool, len(items))
+		for _, item := range items {
+			id, ok := item.(float64)
+			if !ok || id != float64(int(id)) {
+				return nil, fmt.Errorf("invalid node_ids: %v is not a node ID", item)
+			}
+			filter.nodeIDs[int(id)] = true
+		}
+	}
+
+	paths, err := stringList(args, "attribute_paths")
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		if len(strings.Split(path, "/")) != 3 {
+			return nil, fmt.Errorf("invalid attribute path %q", path)
+		}
+	}
+	if len(paths) > 0 {
+		filter.attributePaths = paths
+	}
+
+	if filter.events == nil && filter.nodeIDs == nil && filter.attributePaths == nil {
+		return nil, nil
+	}
+	return filter, nil
+}
+
+// NewEventFilter returns a predicate selecting the events like the events,
+// node_ids and attribute_paths arguments of start_listening do, for other
+// APIs streaming events
+func NewEventFilter(args map[string]interface{}) (func(models.EventType, interface{}) bool, error) {
+	filter, err := parseEventFilter(args)
+	if err != nil {
+		return nil, err
+	}
+	return filter.matches, nil
+}
+
+// matches reports whether an event passes the filter. Events that don't
+// refer to a node (e.g. server_shutdown) pass the node filter and only
+// attribute_updated events are subject to the attribute path filter.
+// Opt-in events only pass if the events filter lists them.
+func (f *eventFilter) matches(eventType models.EventType, data interface{}) bool {
+	if optInEvents[eventType] && (f == nil || !f.events[eventType]) {
+		return false
+	}
+	if f == nil {
+		return true
+	}
+
+	if f.events != nil && !f.events[eventType] {
+		return false
+	}
+
+	if f.nodeIDs != nil {
+		if nodeID, ok := EventNodeID(data); ok && !f.nodeIDs[nodeID] {
+			return false
+		}
+	}
+
+	if len(f.attributePaths) > 0 && eventType == models.EventTypeAttributeUpdated {
+		path, ok := eventAttributePath(data)
+		if !ok {
+			return false
+		}
+		for _, pattern := range f.attributePaths {
+			if matchAttributePath(pattern, path) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// includesNode reports whether a node passes the node filter
+func (f *eventFilter) includesNode(nodeID int) bool {
+	return f == nil || f.nodeIDs == nil || f.nodeIDs[nodeID]
+}
+
+// info returns the filter as listed by get_sessions, or nil for no filter
+func (f *eventFilter) info() *models.SessionFilter {
+	if f == nil {
+		return nil
+	}
+
+	info := &models.SessionFilter{AttributePaths: f.attributePaths}
+	for event := range f.events {
+		info.Events = append(info.Events, event)
+	}
+	for nodeID := range f.nodeIDs {
+		info.NodeIDs = append(info.NodeIDs, nodeID)
+	}
+	slices.Sort(info.Events)
+	slices.Sort(info.NodeIDs)
+	return info
+}
+
+// EventNodeID extracts the node an event refers to, if any
+func EventNodeID(data interface{}) (int, bool) {
+	switch d := data.(type) {
+	case *models.MatterNodeData:
+		return d.NodeID, true
+	case models.MatterNodeEvent:
+		return d.NodeID, true
+	case map[string]int:
+		id, ok := d["node_id"]
+		return id, ok
+	case map[string]interface{}:
+		return toInt(d["node_id"])
+	case []interface{}:
+		// attribute_updated: [node_id, attribute_path, value]
+		if len(d) > 0 {
+			return toInt(d[0])
+		}
+	case int:
+		return d, true
+	}
+	return 0, false
+}
+
+// eventAttributePath extracts the attribute path of an attribute_updated
+// event
+func eventAttributePath(data interface{}) (string, bool) {
+	switch d := data.(type) {
+	case []interface{}:
+		if len(d) > 1 {
+			path, ok := d[1].(string)
+			return path, ok
+		}
+	case map[string]interface{}:
+		path, ok := d["attribute_path"].(string)
+		return path, ok
+	}
+	return "", false
+}
+
+// matchAttributePath matches an "endpoint/cluster/attribute" path against a
+// pattern where each segment may be "*"
+func matchAttributePath(pattern, path string) bool {
+	patternParts := strings.Split(pattern, "/")
+	pathParts := strings.Split(path, "/")
+	if len(patternParts) != len(pathParts) {
+		return false
+	}
+	for i, part := range patternParts {
+		if part != "*" && part != pathParts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func stringList(args map[string]interface{}, name string) ([]string, error) {
+	v, ok := args[name]
+	if !ok || v == nil {
+		return nil, nil
+	}
+	items, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid %s: expected list", name)
+	}
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		s, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid %s: %v is not a string", name, item)
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+func toInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case float64:
+		return int(n), true
+	case int:
+		return n, true
+	default:
+		return 0, false
+	}
+}
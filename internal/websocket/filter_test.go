@@ -0,0 +1,92 @@
+package websocket
+
+import (
+	"testing"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+func TestParseEventFilter(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        map[string]interface{}
+		wantNil     bool
+		expectError bool
+	}{
+		{name: "No filter", args: nil, wantNil: true},
+		{name: "Empty lists", args: map[string]interface{}{"events": []interface{}{}, "attribute_paths": []interface{}{}}, wantNil: true},
+		{name: "Events", args: map[string]interface{}{"events": []interface{}{"node_event"}}},
+		{name: "Node IDs", args: map[string]interface{}{"node_ids": []interface{}{float64(1), float64(2)}}},
+		{name: "Attribute paths", args: map[string]interface{}{"attribute_paths": []interface{}{"1/6/*"}}},
+		{name: "Invalid events", args: map[string]interface{}{"events": "node_event"}, expectError: true},
+		{name: "Invalid node ID", args: map[string]interface{}{"node_ids": []interface{}{"one"}}, expectError: true},
+		{name: "Invalid attribute path", args: map[string]interface{}{"attribute_paths": []interface{}{"1/6"}}, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := parseEventFilter(tt.args)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if (filter == nil) != tt.wantNil {
+				t.Errorf("Expected nil filter %v, got %+v", tt.wantNil, filter)
+			}
+		})
+	}
+}
+
+func TestEventFilterMatches(t *testing.T) {
+	filter, err := parseEventFilter(map[string]interface{}{
+		"events":          []interface{}{"node_updated", "attribute_updated", "server_shutdown"},
+		"node_ids":        []interface{}{float64(5)},
+		"attribute_paths": []interface{}{"1/6/*", "*/8/0"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		eventType models.EventType
+		data      interface{}
+		want      bool
+	}{
+		{"Subscribed node", models.EventTypeNodeUpdated, &models.MatterNodeData{NodeID: 5}, true},
+		{"Other node", models.EventTypeNodeUpdated, &models.MatterNodeData{NodeID: 6}, false},
+		{"Other event type", models.EventTypeNodeEvent, models.MatterNodeEvent{NodeID: 5}, false},
+		{"Event without node", models.EventTypeServerShutdown, nil, true},
+		{"Matching attribute", models.EventTypeAttributeUpdated, []interface{}{float64(5), "1/6/0", true}, true},
+		{"Endpoint wildcard", models.EventTypeAttributeUpdated, []interface{}{float64(5), "2/8/0", float64(10)}, true},
+		{"Other attribute", models.EventTypeAttributeUpdated, []interface{}{float64(5), "1/8/1", float64(10)}, false},
+		{"Attribute of other node", models.EventTypeAttributeUpdated, []interface{}{float64(6), "1/6/0", true}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := filter.matches(tt.eventType, tt.data); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	var none *eventFilter
+	if !none.matches(models.EventTypeNodeAdded, &models.MatterNodeData{NodeID: 1}) || !none.includesNode(1) {
+		t.Error("Expected nil filter to match everything")
+	}
+
+	// Log entries are only sent when asked for
+	if none.matches(models.EventTypeLogEntry, models.LogEntry{}) || filter.matches(models.EventTypeLogEntry, models.LogEntry{}) {
+		t.Error("Expected log entries to be opt-in")
+	}
+	logs, _ := parseEventFilter(map[string]interface{}{"events": []interface{}{"log_entry"}})
+	if !logs.matches(models.EventTypeLogEntry, models.LogEntry{}) {
+		t.Error("Expected log entries when listed")
+	}
+}
@@ -0,0 +1,42 @@
+package websocket
+
+import (
+	"testing"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// FuzzDecodeCommand decodes client messages in both codecs and parses the
+// start_listening arguments the connection handles itself
+func FuzzDecodeCommand(f *testing.F) {
+	f.Add([]byte(`{"message_id":"1","command":"start_listening","args":{"schema_version":11,"events":["attribute_updated"],"node_ids":[5],"attribute_paths":["1/6/*"]}}`), false)
+	f.Add([]byte(`{"message_id":"2","command":"get_node","args":{"node_id":1}}`), false)
+	f.Add([]byte(`{"message_id":"3","command":"cancel","args":{"message_id":"2"}}`), false)
+	start, _ := models.MessagePackCodec.Marshal(models.CommandMessage{
+		MessageID: "4",
+		Command:   string(models.APICommandStartListening),
+		Args:      map[string]interface{}{"node_ids": []int{1, 2}},
+	})
+	f.Add(start, true)
+
+	info := models.ServerInfoMessage{SchemaVersion: models.SchemaVersion, MinSupportedSchemaVersion: models.MinSupportedSchemaVersion}
+	f.Fuzz(func(t *testing.T, message []byte, binary bool) {
+		codec := models.JSONCodec
+		if binary {
+			codec = models.MessagePackCodec
+		}
+		cmd, err := decodeCommand(codec, message)
+		if err != nil {
+			return
+		}
+
+		negotiateSchemaVersion(cmd.Args, info)
+		filter, err := parseEventFilter(cmd.Args)
+		if err != nil || filter == nil {
+			return
+		}
+		filter.matches(models.EventTypeAttributeUpdated, []interface{}{float64(5), "1/6/0", true})
+		filter.matches(models.EventTypeNodeUpdated, &models.MatterNodeData{NodeID: 5})
+		filter.info()
+	})
+}
@@ -2,16 +2,23 @@ package websocket
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"runtime/debug"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 
+	"github.com/codefionn/go-matter-server/internal/audit"
 	"github.com/codefionn/go-matter-server/internal/logger"
 	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/progress"
 )
 
 const (
@@ -22,20 +29,44 @@ const (
 	maxMessageSize = 1024 * 1024 // 1MB
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow connections from any origin
-	},
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-}
+// errCommandPanicked answers commands whose handling panicked. The panic
+// itself is only logged.
+var errCommandPanicked = errors.New("internal server error")
 
 // Handler manages WebSocket connections and message routing
 type Handler struct {
 	server        Server
 	logger        *logger.Logger
+	upgrader      websocket.Upgrader
 	connections   map[string]*Connection
 	connectionsMu sync.RWMutex
+
+	// Send queue size and policy for connections that fall behind
+	queueSize int
+	overflow  OverflowPolicy
+	limits    Limits
+	stats     handlerStats
+	dialect   Dialect
+
+	// permessage-deflate options of new connections
+	compression Compression
+
+	// Set by Drain, new connections and commands are rejected
+	draining atomic.Bool
+
+	// Ends the subscription to the server events, which are fanned out to
+	// the connections
+	unsubscribe func()
+}
+
+// handlerStats counts backpressure handling and rejected commands across all
+// connections
+type handlerStats struct {
+	coalesced          atomic.Uint64
+	dropped            atomic.Uint64
+	slowConsumerClosed atomic.Uint64
+	rateLimited        atomic.Uint64
+	panics             atomic.Uint64
 }
 
 // Server interface defines the methods the WebSocket handler needs
@@ -47,59 +78,197 @@ type Server interface {
 
 // Connection represents a WebSocket client connection
 type Connection struct {
-	id          string
-	conn        *websocket.Conn
-	handler     *Handler
-	send        chan []byte
-	ctx         context.Context
-	cancel      context.CancelFunc
-	logger      *logger.Logger
-	unsubscribe func()
+	id        string
+	conn      *websocket.Conn
+	handler   *Handler
+	codec     models.Codec
+	queue     *sendQueue
+	ctx       context.Context
+	cancel    context.CancelFunc
+	closeOnce sync.Once
+	logger    *logger.Logger
+
+	// Set once events were dropped, to warn only once per connection
+	dropping atomic.Bool
+
+	// Receives the close reason when the handler is drained
+	closing chan string
+
+	// Commands being handled, by message ID, for the cancel command
+	pendingMu sync.Mutex
+	pending   map[string]*pendingCommand
+
+	// Command rate and concurrency limits
+	limiter  *rateLimiter
+	inFlight atomic.Int32
+
+	// Bearer token of the upgrade request, authorizing commands requiring
+	// a token
+	bearerToken string
+
+	// Session details listed by get_sessions
+	remoteAddr  string
+	connectedAt time.Time
+	compressed  bool
+	lastPong    atomic.Int64 // Unix nanoseconds, zero before the first pong
+	sent        atomic.Uint64
+	dropped     atomic.Uint64
+
+	// Schema version and event filter set by start_listening
+	listenMu      sync.RWMutex
+	schemaVersion int
+	filter        *eventFilter
+	// Events are sent once start_listening succeeded. Those broadcast
+	// while it runs are held until its result was queued, as clients take
+	// the first message after start_listening for its result.
+	listening listenState
+	held      []*queuedMessage
 }
 
+// listenState tells whether a connection receives events
+type listenState int
+
+const (
+	listenNone listenState = iota
+	listenPending
+	listenActive
+)
+
 // NewHandler creates a new WebSocket handler
 func NewHandler(server Server, log *logger.Logger) *Handler {
-	return &Handler{
-		server:      server,
-		logger:      log,
+	h := &Handler{
+		server: server,
+		logger: log,
+		upgrader: websocket.Upgrader{
+			// Same origin only unless SetCheckOrigin is called
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			Subprotocols:    codecNames(),
+		},
 		connections: make(map[string]*Connection),
+		queueSize:   DefaultQueueSize,
+		overflow:    OverflowDropOldest,
+		dialect:     DialectNative,
 	}
+	h.unsubscribe = server.Subscribe(h.handleEvent)
+	return h
+}
+
+// SetCheckOrigin sets the function deciding whether a WebSocket upgrade
+// request's Origin is allowed. It must be called before serving requests.
+func (h *Handler) SetCheckOrigin(check func(r *http.Request) bool) {
+	h.upgrader.CheckOrigin = check
+}
+
+// SetQueueOptions sets the per-connection send queue size and what happens
+// when a client doesn't keep up. Non-positive sizes select DefaultQueueSize.
+// It must be called before serving requests.
+func (h *Handler) SetQueueOptions(size int, policy OverflowPolicy) {
+	if size <= 0 {
+		size = DefaultQueueSize
+	}
+	h.queueSize = size
+	h.overflow = policy
+}
+
+// SetLimits sets the command limits applied to each connection. It must be
+// called before serving requests.
+func (h *Handler) SetLimits(limits Limits) {
+	h.limits = limits
+}
+
+// SetDialect sets the shape of the messages sent to the clients. It must be
+// called before serving requests.
+func (h *Handler) SetDialect(dialect Dialect) {
+	h.dialect = dialect
+}
+
+// bearerToken returns the bearer token of an upgrade request's Authorization
+// header
+func bearerToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
+	return ""
 }
 
 // HandleWebSocket handles WebSocket upgrade requests
 func (h *Handler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+	if h.draining.Load() {
+		http.Error(w, "server is restarting", http.StatusServiceUnavailable)
+		return
+	}
+
+	// The upgrader accepts any deflate offer when compression is enabled
+	// without context takeover
+	compressed := h.compression.Enabled && offersDeflate(r)
+	if h.compression.Enabled && h.compression.takesOverContext() {
+		var params deflateParams
+		if params, compressed = negotiateDeflate(r, h.compression); compressed {
+			w = &deflateResponseWriter{
+				ResponseWriter: w,
+				newConn: func(conn net.Conn) *deflateConn {
+					return newDeflateConn(conn, params, h.compression, maxMessageSize)
+				},
+			}
+		}
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		h.logger.Error("Failed to upgrade WebSocket connection", logger.ErrorField(err))
 		return
 	}
 
 	connID := models.GenerateMessageID()
-	ctx, cancel := context.WithCancel(r.Context())
+	// The request context is cancelled once this handler returns, but the
+	// connection outlives it
+	ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+
+	// Clients not requesting a known subprotocol use JSON
+	codec, ok := models.CodecByName(conn.Subprotocol())
+	if !ok {
+		codec = models.JSONCodec
+	}
 
-	client := &Connection{
-		id:      connID,
-		conn:    conn,
-		handler: h,
-		send:    make(chan []byte, 256),
-		ctx:     ctx,
-		cancel:  cancel,
-		logger:  h.logger.With(logger.String("connection", connID)),
+	if compressed && !h.compression.takesOverContext() {
+		if err := conn.SetCompressionLevel(h.compression.Level); err != nil {
+			h.logger.Warn("Invalid WebSocket compression level", logger.ErrorField(err))
+		}
 	}
 
-	// Subscribe to server events
-	client.unsubscribe = h.server.Subscribe(client.handleEvent)
+	client := &Connection{
+		id:            connID,
+		conn:          conn,
+		handler:       h,
+		codec:         codec,
+		queue:         newSendQueue(h.queueSize, h.overflow),
+		closing:       make(chan string, 1),
+		pending:       make(map[string]*pendingCommand),
+		limiter:       newRateLimiter(h.limits.CommandsPerSecond, h.limits.Burst),
+		bearerToken:   bearerToken(r),
+		remoteAddr:    r.RemoteAddr,
+		connectedAt:   time.Now(),
+		compressed:    compressed,
+		ctx:           ctx,
+		cancel:        cancel,
+		logger:        h.logger.With(logger.String("connection", connID)),
+		schemaVersion: h.server.GetServerInfo().SchemaVersion,
+	}
 
 	// Register connection
 	h.connectionsMu.Lock()
 	h.connections[connID] = client
 	h.connectionsMu.Unlock()
 
-	client.logger.Info("WebSocket connection established")
+	client.logger.Info("WebSocket connection established",
+		logger.String("codec", codec.Name()),
+		logger.Bool("compressed", compressed),
+	)
 
 	// Send server info immediately via direct WebSocket write
-	serverInfo := h.server.GetServerInfo()
-	data, err := json.Marshal(serverInfo)
+	serverInfo := h.dialect.adaptData(h.server.GetServerInfo())
+	data, err := codec.Marshal(serverInfo)
 	if err != nil {
 		client.logger.Error("Failed to marshal server info", logger.ErrorField(err))
 		client.close()
@@ -107,7 +276,8 @@ func (h *Handler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 
 	conn.SetWriteDeadline(time.Now().Add(writeWait))
-	if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+	client.setWriteCompression(len(data))
+	if err := conn.WriteMessage(client.frameType(), data); err != nil {
 		client.logger.Error("Failed to send server info", logger.ErrorField(err))
 		client.close()
 		return
@@ -118,25 +288,78 @@ func (h *Handler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	go client.readPump()
 }
 
-// BroadcastEvent sends an event to all connected clients
-func (h *Handler) BroadcastEvent(event models.EventMessage) {
-	data, err := json.Marshal(event)
-	if err != nil {
-		h.logger.Error("Failed to marshal event", logger.ErrorField(err))
-		return
+// Stats returns the backpressure counters of all connections
+func (h *Handler) Stats() models.WebSocketStats {
+	stats := models.WebSocketStats{
+		CoalescedEvents:         h.stats.coalesced.Load(),
+		DroppedEvents:           h.stats.dropped.Load(),
+		SlowConsumerDisconnects: h.stats.slowConsumerClosed.Load(),
+		RateLimitedCommands:     h.stats.rateLimited.Load(),
+		Panics:                  h.stats.panics.Load(),
+	}
+
+	for _, conn := range h.snapshotConnections() {
+		queued := conn.queue.len()
+		stats.Connections++
+		stats.QueuedMessages += queued
+		if queued*2 >= conn.queue.limit {
+			stats.SlowConsumers++
+		}
+	}
+	return stats
+}
+
+// Sessions lists the connected clients, oldest connection first
+func (h *Handler) Sessions() []models.SessionInfo {
+	conns := h.snapshotConnections()
+	sort.Slice(conns, func(i, j int) bool {
+		return conns[i].connectedAt.Before(conns[j].connectedAt)
+	})
+
+	sessions := make([]models.SessionInfo, len(conns))
+	for i, conn := range conns {
+		sessions[i] = conn.info()
 	}
+	return sessions
+}
 
+// DisconnectSession closes the connection with the given ID, reporting false
+// if there is no such connection
+func (h *Handler) DisconnectSession(id string) bool {
+	h.connectionsMu.RLock()
+	conn, ok := h.connections[id]
+	h.connectionsMu.RUnlock()
+	if !ok {
+		return false
+	}
+
+	conn.logger.Info("Disconnecting WebSocket session")
+	conn.close()
+	return true
+}
+
+// SeverConnections drops all connections without a close frame, as a lost
+// network would, and returns their number
+func (h *Handler) SeverConnections() int {
+	conns := h.snapshotConnections()
+	for _, conn := range conns {
+		conn.logger.Warn("Severing WebSocket connection")
+		conn.close()
+	}
+	return len(conns)
+}
+
+// snapshotConnections returns the current connections, so they can be closed
+// without holding connectionsMu
+func (h *Handler) snapshotConnections() []*Connection {
 	h.connectionsMu.RLock()
 	defer h.connectionsMu.RUnlock()
 
+	conns := make([]*Connection, 0, len(h.connections))
 	for _, conn := range h.connections {
-		select {
-		case conn.send <- data:
-		default:
-			// Connection is busy, close it
-			conn.close()
-		}
+		conns = append(conns, conn)
 	}
+	return conns
 }
 
 // GetConnectionCount returns the number of active connections
@@ -148,14 +371,14 @@ func (h *Handler) GetConnectionCount() int {
 
 // Shutdown closes all connections
 func (h *Handler) Shutdown() {
-	h.connectionsMu.Lock()
-	defer h.connectionsMu.Unlock()
-
-	for _, conn := range h.connections {
+	h.unsubscribe()
+	for _, conn := range h.snapshotConnections() {
 		conn.close()
 	}
 
+	h.connectionsMu.Lock()
 	h.connections = make(map[string]*Connection)
+	h.connectionsMu.Unlock()
 	h.logger.Info("WebSocket handler shutdown")
 }
 
@@ -169,6 +392,7 @@ func (c *Connection) readPump() {
 	c.conn.SetReadLimit(maxMessageSize)
 	c.conn.SetReadDeadline(time.Now().Add(pongWait))
 	c.conn.SetPongHandler(func(string) error {
+		c.lastPong.Store(time.Now().UnixNano())
 		c.conn.SetReadDeadline(time.Now().Add(pongWait))
 		return nil
 	})
@@ -187,16 +411,75 @@ func (c *Connection) readPump() {
 			}
 			return
 		}
+		c.handleMessage(message)
+	}
+}
 
-		var cmd models.CommandMessage
-		if err := json.Unmarshal(message, &cmd); err != nil {
-			c.logger.Error("Failed to unmarshal command", logger.ErrorField(err))
-			c.sendError(models.GenerateMessageID(), 400, "Invalid message format")
-			continue
+// handleMessage decodes a command and handles it in a goroutine of its own.
+// A panic while decoding is answered with an error, the connection stays
+// open.
+func (c *Connection) handleMessage(message []byte) {
+	defer func() {
+		if value := recover(); value != nil {
+			c.recovered(value, models.CommandMessage{})
+			c.sendError(models.GenerateMessageID(), models.ErrorCodeCommandFailed, errCommandPanicked.Error())
 		}
+	}()
 
-		go c.handleCommand(cmd)
+	cmd, err := decodeCommand(c.codec, message)
+	if err != nil {
+		c.logger.Error("Failed to unmarshal command", logger.ErrorField(err))
+		c.sendError(models.GenerateMessageID(), models.ErrorCodeInvalidMessage, "Invalid message format")
+		return
+	}
+
+	if !c.admitCommand(cmd) {
+		return
 	}
+	go func() {
+		defer c.inFlight.Add(-1)
+		defer func() {
+			// Panics of the server's command handlers are answered in
+			// handleCommand, this covers the connection's own handling
+			if value := recover(); value != nil {
+				c.recovered(value, cmd)
+				c.sendError(cmd.MessageID, models.ErrorCodeCommandFailed, errCommandPanicked.Error())
+			}
+		}()
+		c.handleCommand(cmd)
+	}()
+}
+
+// decodeCommand decodes a command message received in the connection's codec
+func decodeCommand(codec models.Codec, message []byte) (models.CommandMessage, error) {
+	var cmd models.CommandMessage
+	err := codec.Unmarshal(message, &cmd)
+	return cmd, err
+}
+
+// recovered counts and logs a panic with the connection and command it
+// happened for
+func (c *Connection) recovered(value interface{}, cmd models.CommandMessage) {
+	c.handler.stats.panics.Add(1)
+	c.logger.Error("WebSocket handler panicked",
+		logger.String("remote_address", c.remoteAddr),
+		logger.String("command", cmd.Command),
+		logger.String("message_id", cmd.MessageID),
+		logger.String("panic", fmt.Sprint(value)),
+		logger.String("stack", string(debug.Stack())),
+	)
+}
+
+// callServer runs a command on the server, turning a panic into an error so
+// the command is answered and its cancellation registration is removed
+func (c *Connection) callServer(ctx context.Context, cmd models.CommandMessage) (result interface{}, err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			c.recovered(value, cmd)
+			result, err = nil, errCommandPanicked
+		}
+	}()
+	return c.handler.server.HandleCommand(ctx, cmd)
 }
 
 func (c *Connection) writePump() {
@@ -210,29 +493,22 @@ func (c *Connection) writePump() {
 		select {
 		case <-c.ctx.Done():
 			return
-		case message, ok := <-c.send:
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if !ok {
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
-				return
+		case <-c.queue.ready:
+			batch := c.queue.drain()
+			if len(batch) == 0 {
+				continue
 			}
 
-			w, err := c.conn.NextWriter(websocket.TextMessage)
-			if err != nil {
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.writeBatch(batch); err != nil {
 				return
 			}
-			w.Write(message)
-
-			// Add queued messages to the current WebSocket message
-			n := len(c.send)
-			for i := 0; i < n; i++ {
-				w.Write([]byte{'\n'})
-				w.Write(<-c.send)
-			}
-
-			if err := w.Close(); err != nil {
-				return
+			c.sent.Add(uint64(len(batch)))
+		case reason := <-c.closing:
+			if err := c.writeClose(reason); err != nil {
+				c.logger.Debug("Failed to write close frame", logger.ErrorField(err))
 			}
+			return
 		case <-ticker.C:
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
@@ -242,45 +518,178 @@ func (c *Connection) writePump() {
 	}
 }
 
+// admitCommand applies the connection's command limits. Rejected commands are
+// answered with a rate limited error. Admitted commands count as in flight
+// until handled. Cancel commands are exempt from the in-flight limit, so
+// pending commands can be cancelled at any time. While draining, all other
+// commands are rejected.
+func (c *Connection) admitCommand(cmd models.CommandMessage) bool {
+	reason := ""
+	switch {
+	case !c.limiter.allow():
+		reason = "rate limit exceeded"
+	case !c.acquireInFlight(cmd):
+		reason = "too many commands in flight"
+	case c.handler.draining.Load() && models.APICommand(cmd.Command) != models.APICommandCancel:
+		// Checked after counting the command as in flight, so Drain either
+		// waits for it or the command sees the flag
+		c.inFlight.Add(-1)
+		c.sendError(cmd.MessageID, models.ErrorCodeServerRestarting, "server is restarting")
+		return false
+	default:
+		return true
+	}
+
+	c.handler.stats.rateLimited.Add(1)
+	c.logger.Debug("Rejecting command",
+		logger.String("command", cmd.Command),
+		logger.String("reason", reason),
+	)
+	c.sendError(cmd.MessageID, models.ErrorCodeRateLimited, reason)
+	return false
+}
+
+// acquireInFlight counts a command as in flight unless the limit is reached
+func (c *Connection) acquireInFlight(cmd models.CommandMessage) bool {
+	n := int(c.inFlight.Add(1))
+	limit := c.handler.limits.MaxInFlight
+	if limit <= 0 || n <= limit || models.APICommand(cmd.Command) == models.APICommandCancel {
+		return true
+	}
+	c.inFlight.Add(-1)
+	return false
+}
+
+// writeBatch writes queued messages. JSON messages are combined into a single
+// WebSocket message separated by newlines, binary messages and the messages
+// of the home_assistant dialect, whose client parses a WebSocket message as
+// one JSON message, are written as one WebSocket message each.
+func (c *Connection) writeBatch(batch [][]byte) error {
+	if c.codec.Binary() || c.handler.dialect == DialectHomeAssistant {
+		for _, message := range batch {
+			c.setWriteCompression(len(message))
+			if err := c.conn.WriteMessage(c.frameType(), message); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	size := len(batch) - 1
+	for _, message := range batch {
+		size += len(message)
+	}
+	c.setWriteCompression(size)
+	w, err := c.conn.NextWriter(websocket.TextMessage)
+	if err != nil {
+		return err
+	}
+	for i, message := range batch {
+		if i > 0 {
+			w.Write([]byte{'\n'})
+		}
+		w.Write(message)
+	}
+	return w.Close()
+}
+
+// frameType returns the WebSocket message type used by the connection's codec
+func (c *Connection) frameType() int {
+	if c.codec.Binary() {
+		return websocket.BinaryMessage
+	}
+	return websocket.TextMessage
+}
+
 func (c *Connection) handleCommand(cmd models.CommandMessage) {
 	c.logger.Debug("Handling command",
 		logger.String("command", cmd.Command),
 		logger.String("message_id", cmd.MessageID),
 	)
 
-	result, err := c.handler.server.HandleCommand(c.ctx, cmd)
+	if models.APICommand(cmd.Command) == models.APICommandCancel {
+		c.handleCancel(cmd)
+		return
+	}
+
+	startListening := models.APICommand(cmd.Command) == models.APICommandStartListening
+	if startListening {
+		version, err := negotiateSchemaVersion(cmd.Args, c.handler.server.GetServerInfo())
+		if err != nil {
+			c.logger.Warn("Schema negotiation failed", logger.ErrorField(err))
+			c.sendStartListeningError(cmd.MessageID, err)
+			return
+		}
+		filter, err := parseEventFilter(cmd.Args)
+		if err != nil {
+			c.sendStartListeningError(cmd.MessageID, err)
+			return
+		}
+		c.setListenOptions(version, filter)
+	}
+	answered := false
+	if startListening {
+		defer func() { c.finishListening(answered) }()
+	}
+
+	ctx, finish := c.startCommand(cmd.MessageID)
+	ctx = progress.WithReporter(ctx, cmd.MessageID, c.sendProgress)
+	ctx = audit.WithClient(ctx, audit.Client{ConnectionID: c.id, RemoteAddress: c.remoteAddr, BearerToken: c.bearerToken})
+	result, err := c.callServer(ctx, cmd)
+	if finish() {
+		// Already answered by the cancel command
+		c.logger.Debug("Dropping result of cancelled command",
+			logger.String("command", cmd.Command),
+			logger.String("message_id", cmd.MessageID),
+		)
+		return
+	}
 	if err != nil {
+		var argErr *models.ArgumentError
+		if errors.As(err, &argErr) {
+			c.sendArgumentError(cmd.MessageID, argErr)
+			return
+		}
+
 		c.logger.Error("Command failed",
 			logger.String("command", cmd.Command),
 			logger.ErrorField(err),
 		)
-		c.sendError(cmd.MessageID, 500, err.Error())
+		c.sendCommandError(cmd.MessageID, err)
 		return
 	}
 
+	// The initial node list only includes nodes the client listens to
+	if nodes, ok := result.([]*models.MatterNodeData); ok && startListening {
+		result = c.filterNodes(nodes)
+	}
+
 	response := models.SuccessResultMessage{
 		ResultMessageBase: models.ResultMessageBase{
 			MessageID: cmd.MessageID,
 		},
-		Result: result,
+		Result: c.handler.dialect.adaptData(adaptData(c.getSchemaVersion(), result)),
 	}
 
 	if err := c.sendMessage(response); err != nil {
 		c.logger.Error("Failed to send command response", logger.ErrorField(err))
 	}
+	answered = true
 }
 
-func (c *Connection) handleEvent(eventType models.EventType, data interface{}) {
-	event := models.EventMessage{
-		Event: eventType,
-		Data:  data,
+// sendProgress sends progress of a command to this connection only. Progress
+// isn't subject to the event filter since the client asked for the command.
+func (c *Connection) sendProgress(p models.CommandProgress) {
+	if !eventSupported(c.getSchemaVersion(), models.EventTypeCommandProgress) || !c.handler.dialect.sendsEvent(models.EventTypeCommandProgress) {
+		return
 	}
 
+	event := models.EventMessage{
+		Event: models.EventTypeCommandProgress,
+		Data:  p,
+	}
 	if err := c.sendMessage(event); err != nil {
-		c.logger.Error("Failed to send event",
-			logger.String("event", string(eventType)),
-			logger.ErrorField(err),
-		)
+		c.logger.Debug("Failed to send command progress", logger.ErrorField(err))
 	}
 }
 
@@ -292,20 +701,51 @@ func (c *Connection) sendMessage(msg interface{}) error {
 	default:
 	}
 
-	data, err := json.Marshal(msg)
+	data, err := c.codec.Marshal(c.handler.dialect.adaptMessage(msg))
 	if err != nil {
 		return fmt.Errorf("failed to marshal message: %w", err)
 	}
 
-	// Try to send with timeout
+	queued := &queuedMessage{data: data}
+	if event, ok := msg.(models.EventMessage); ok {
+		queued.event = true
+		queued.key = coalesceKey(event.Event, event.Data)
+	}
+	return c.enqueue(queued)
+}
+
+// enqueue queues a marshalled message, applying the overflow policy when the
+// client falls behind
+func (c *Connection) enqueue(msg *queuedMessage) error {
 	select {
-	case c.send <- data:
-		return nil
 	case <-c.ctx.Done():
 		return fmt.Errorf("connection closed")
-	case <-time.After(1 * time.Second):
-		return fmt.Errorf("send timeout")
+	default:
+	}
+
+	result := c.queue.push(msg)
+	if result.coalesced {
+		c.handler.stats.coalesced.Add(1)
 	}
+	if result.dropped > 0 {
+		c.handler.stats.dropped.Add(uint64(result.dropped))
+		c.dropped.Add(uint64(result.dropped))
+		if c.dropping.CompareAndSwap(false, true) {
+			c.logger.Warn("Slow WebSocket consumer, dropping oldest events",
+				logger.Int("queue_size", c.queue.limit),
+			)
+		}
+	}
+	if result.overflow {
+		c.handler.stats.slowConsumerClosed.Add(1)
+		c.logger.Warn("Closing slow WebSocket consumer",
+			logger.Int("queue_size", c.queue.limit),
+			logger.String("policy", string(c.queue.policy)),
+		)
+		c.close()
+		return fmt.Errorf("send queue full")
+	}
+	return nil
 }
 
 func (c *Connection) sendError(messageID string, code int, details string) {
@@ -313,7 +753,7 @@ func (c *Connection) sendError(messageID string, code int, details string) {
 		ResultMessageBase: models.ResultMessageBase{
 			MessageID: messageID,
 		},
-		ErrorCode: code,
+		ErrorCode: c.handler.dialect.errorCode(code),
 		Details:   &details,
 	}
 
@@ -322,37 +762,186 @@ func (c *Connection) sendError(messageID string, code int, details string) {
 	}
 }
 
-func (c *Connection) close() {
-	// Only close once
-	select {
-	case <-c.ctx.Done():
-		return // Already closed
-	default:
+// sendCommandError reports a command that failed
+func (c *Connection) sendCommandError(messageID string, err error) {
+	details := err.Error()
+	errorMsg := models.ErrorResultMessage{
+		ResultMessageBase: models.ResultMessageBase{
+			MessageID: messageID,
+		},
+		ErrorCode: c.handler.dialect.commandErrorCode(err),
+		Details:   &details,
 	}
 
-	if c.unsubscribe != nil {
-		c.unsubscribe()
-		c.unsubscribe = nil
+	if err := c.sendMessage(errorMsg); err != nil {
+		c.logger.Error("Failed to send error message", logger.ErrorField(err))
 	}
+}
 
-	c.cancel()
+// sendArgumentError rejects a command whose arguments don't match its schema
+func (c *Connection) sendArgumentError(messageID string, argErr *models.ArgumentError) {
+	details := argErr.Error()
+	errorMsg := models.ErrorResultMessage{
+		ResultMessageBase: models.ResultMessageBase{
+			MessageID: messageID,
+		},
+		ErrorCode: c.handler.dialect.errorCode(models.ErrorCodeInvalidArguments),
+		Details:   &details,
+		Field:     argErr.Field,
+	}
 
-	// Remove from handler's connection map
-	c.handler.connectionsMu.Lock()
-	delete(c.handler.connections, c.id)
-	c.handler.connectionsMu.Unlock()
+	if err := c.sendMessage(errorMsg); err != nil {
+		c.logger.Error("Failed to send error message", logger.ErrorField(err))
+	}
+}
 
-	// Close send channel safely
-	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				// Channel already closed, ignore
-			}
-		}()
-		close(c.send)
-	}()
+// sendStartListeningError rejects start_listening arguments, including the
+// supported range when the schema version was out of range
+func (c *Connection) sendStartListeningError(messageID string, err error) {
+	details := err.Error()
+	errorMsg := models.ErrorResultMessage{
+		ResultMessageBase: models.ResultMessageBase{
+			MessageID: messageID,
+		},
+		ErrorCode: models.ErrorCodeInvalidMessage,
+		Details:   &details,
+	}
+
+	var versionErr *schemaVersionError
+	if errors.As(err, &versionErr) {
+		errorMsg.ErrorCode = models.ErrorCodeSchemaVersionMismatch
+		errorMsg.SupportedSchemaVersions = &versionErr.supported
+	}
+	errorMsg.ErrorCode = c.handler.dialect.errorCode(errorMsg.ErrorCode)
+
+	if err := c.sendMessage(errorMsg); err != nil {
+		c.logger.Error("Failed to send error message", logger.ErrorField(err))
+	}
+}
+
+func (c *Connection) setListenOptions(version int, filter *eventFilter) {
+	c.listenMu.Lock()
+	defer c.listenMu.Unlock()
+	c.schemaVersion = version
+	c.filter = filter
+	if c.listening == listenNone {
+		c.listening = listenPending
+	}
+}
+
+// finishListening ends a start_listening command. The events held while it
+// ran are queued after its result if it succeeded, i.e. the result was
+// queued, and dropped otherwise.
+func (c *Connection) finishListening(succeeded bool) {
+	c.listenMu.Lock()
+	defer c.listenMu.Unlock()
+	if c.listening != listenPending {
+		return
+	}
+
+	held := c.held
+	c.held = nil
+	if !succeeded {
+		c.listening = listenNone
+		return
+	}
+	c.listening = listenActive
+	// Queued under listenMu so later events can't overtake them
+	for _, msg := range held {
+		c.enqueue(msg)
+	}
+}
+
+// enqueueEvent queues a broadcast event, holds it while start_listening
+// runs, or drops it if the client doesn't listen
+func (c *Connection) enqueueEvent(msg *queuedMessage) {
+	c.listenMu.RLock()
+	state := c.listening
+	c.listenMu.RUnlock()
+	switch state {
+	case listenActive:
+		c.enqueue(msg)
+		return
+	case listenNone:
+		return
+	}
+
+	c.listenMu.Lock()
+	defer c.listenMu.Unlock()
+	switch c.listening {
+	case listenPending:
+		c.held = append(c.held, msg)
+	case listenActive:
+		c.enqueue(msg)
+	}
+}
+
+func (c *Connection) getSchemaVersion() int {
+	c.listenMu.RLock()
+	defer c.listenMu.RUnlock()
+	return c.schemaVersion
+}
+
+func (c *Connection) getFilter() *eventFilter {
+	c.listenMu.RLock()
+	defer c.listenMu.RUnlock()
+	return c.filter
+}
 
-	c.conn.Close()
+// filterNodes returns the nodes passing the connection's node filter
+func (c *Connection) filterNodes(nodes []*models.MatterNodeData) []*models.MatterNodeData {
+	filter := c.getFilter()
+	filtered := make([]*models.MatterNodeData, 0, len(nodes))
+	for _, node := range nodes {
+		if filter.includesNode(node.NodeID) {
+			filtered = append(filtered, node)
+		}
+	}
+	return filtered
+}
+
+// info returns the connection's session details
+func (c *Connection) info() models.SessionInfo {
+	info := models.SessionInfo{
+		ID:               c.id,
+		RemoteAddress:    c.remoteAddr,
+		ConnectedAt:      c.connectedAt,
+		Codec:            c.codec.Name(),
+		Compressed:       c.compressed,
+		SchemaVersion:    c.getSchemaVersion(),
+		Filter:           c.getFilter().info(),
+		MessagesSent:     c.sent.Load(),
+		MessagesDropped:  c.dropped.Load(),
+		QueuedMessages:   c.queue.len(),
+		InFlightCommands: int(c.inFlight.Load()),
+	}
+	if nanos := c.lastPong.Load(); nanos != 0 {
+		lastPong := time.Unix(0, nanos)
+		info.LastPongAt = &lastPong
+	}
+	return info
+}
 
-	c.logger.Info("WebSocket connection closed")
+func (c *Connection) close() {
+	c.closeOnce.Do(func() {
+		c.cancel()
+
+		// Remove from handler's connection map
+		c.handler.connectionsMu.Lock()
+		delete(c.handler.connections, c.id)
+		c.handler.connectionsMu.Unlock()
+
+		c.conn.Close()
+
+		c.logger.Info("WebSocket connection closed")
+	})
+}
+
+// codecNames returns the subprotocols offered to clients
+func codecNames() []string {
+	names := make([]string, len(models.Codecs))
+	for i, codec := range models.Codecs {
+		names[i] = codec.Name()
+	}
+	return names
 }
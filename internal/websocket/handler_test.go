@@ -1,23 +1,49 @@
 package websocket
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/gorilla/websocket"
+
+	"github.com/codefionn/go-matter-server/internal/audit"
 	"github.com/codefionn/go-matter-server/internal/logger"
 	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/progress"
 )
 
 // MockServer implements the Server interface for testing
 type MockServer struct {
 	commands      []models.CommandMessage
+	clients       []audit.Client
 	callbacks     []models.EventCallback
 	serverInfo    models.ServerInfoMessage
 	commandError  error
 	commandResult interface{}
 	mu            sync.Mutex
+
+	// Commands block until their context is cancelled when set
+	waitForCancel bool
+	cancelled     chan error
+
+	// Stages reported as progress by each command
+	progressStages []string
+
+	// Command whose handling panics
+	panicCommand string
+
+	// Called while handling each command
+	duringCommand func(cmd models.CommandMessage)
 }
 
 func NewMockServer() *MockServer {
@@ -34,10 +60,26 @@ func NewMockServer() *MockServer {
 }
 
 func (ms *MockServer) HandleCommand(ctx context.Context, cmd models.CommandMessage) (interface{}, error) {
+	if ms.waitForCancel {
+		<-ctx.Done()
+		ms.cancelled <- ctx.Err()
+		return nil, ctx.Err()
+	}
+
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
 	ms.commands = append(ms.commands, cmd)
+	ms.clients = append(ms.clients, audit.ClientFromContext(ctx))
+	if cmd.Command == ms.panicCommand {
+		panic("handler bug")
+	}
+	for i, stage := range ms.progressStages {
+		progress.Report(ctx, stage, 100*i/len(ms.progressStages))
+	}
+	if ms.duringCommand != nil {
+		ms.duringCommand(cmd)
+	}
 	if ms.commandError != nil {
 		return nil, ms.commandError
 	}
@@ -183,3 +225,743 @@ func TestMockServerFunctionality(t *testing.T) {
 	}
 	unsubscribe() // Should not panic
 }
+
+// dialTestHandler serves handler over HTTP and connects a client, returning
+// the connection and the initial server info message
+func dialTestHandler(t *testing.T, handler *Handler) (*websocket.Conn, models.ServerInfoMessage) {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	t.Cleanup(srv.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	var info models.ServerInfoMessage
+	if err := conn.ReadJSON(&info); err != nil {
+		t.Fatalf("Failed to read server info: %v", err)
+	}
+	return conn, info
+}
+
+// startListening sends start_listening, after which the connection receives
+// events, and reads its response
+func startListening(t *testing.T, conn *websocket.Conn) {
+	t.Helper()
+
+	conn.WriteJSON(models.CommandMessage{MessageID: "listen", Command: string(models.APICommandStartListening)})
+	if msg := readMessages(t, conn)[0]; msg["message_id"] != "listen" {
+		t.Fatalf("Expected the start_listening response, got %v", msg)
+	}
+}
+
+// readMessages reads the next WebSocket frame, which may hold several
+// newline separated messages
+func readMessages(t *testing.T, conn *websocket.Conn) []map[string]interface{} {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("Failed to read message: %v", err)
+	}
+
+	var messages []map[string]interface{}
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		var msg map[string]interface{}
+		if err := json.Unmarshal(line, &msg); err != nil {
+			t.Fatalf("Failed to parse message %q: %v", line, err)
+		}
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+func TestStartListeningSchemaNegotiation(t *testing.T) {
+	mockServer := NewMockServer()
+	mockServer.serverInfo.MinSupportedSchemaVersion = 5
+	handler := NewHandler(mockServer, logger.NewConsoleLogger(logger.FatalLevel))
+
+	conn, info := dialTestHandler(t, handler)
+	if info.SchemaVersion != 11 {
+		t.Fatalf("Expected schema version 11, got %d", info.SchemaVersion)
+	}
+
+	// Unsupported version is rejected with the supported range
+	conn.WriteJSON(models.CommandMessage{
+		MessageID: "1",
+		Command:   string(models.APICommandStartListening),
+		Args:      map[string]interface{}{"schema_version": 3},
+	})
+	msg := readMessages(t, conn)[0]
+	if msg["message_id"] != "1" || msg["error_code"] != float64(models.ErrorCodeSchemaVersionMismatch) {
+		t.Fatalf("Expected schema version mismatch error, got %v", msg)
+	}
+	supported, _ := msg["supported_schema_versions"].(map[string]interface{})
+	if supported["min"] != float64(5) || supported["max"] != float64(11) {
+		t.Errorf("Expected supported range 5-11, got %v", msg["supported_schema_versions"])
+	}
+	if len(mockServer.GetCommands()) != 0 {
+		t.Error("Expected rejected start_listening not to reach the server")
+	}
+
+	// Older supported version is accepted and events are adapted
+	conn.WriteJSON(models.CommandMessage{
+		MessageID: "2",
+		Command:   string(models.APICommandStartListening),
+		Args:      map[string]interface{}{"schema_version": 10},
+	})
+	if msg := readMessages(t, conn)[0]; msg["message_id"] != "2" || msg["error_code"] != nil {
+		t.Fatalf("Expected start_listening success, got %v", msg)
+	}
+
+	mockServer.mu.Lock()
+	callback := mockServer.callbacks[0]
+	mockServer.mu.Unlock()
+
+	callback(models.EventTypeEndpointAdded, map[string]int{"node_id": 1, "endpoint_id": 3})
+	callback(models.EventTypeNodeUpdated, &models.MatterNodeData{
+		NodeID:           1,
+		BridgedEndpoints: []models.BridgedEndpoint{{EndpointID: 3}},
+	})
+
+	msg = readMessages(t, conn)[0]
+	if msg["event"] != string(models.EventTypeNodeUpdated) {
+		t.Fatalf("Expected endpoint_added to be filtered, got %v", msg)
+	}
+	if data, _ := msg["data"].(map[string]interface{}); data["bridged_endpoints"] != nil {
+		t.Errorf("Expected bridged_endpoints to be removed for schema 10, got %v", data)
+	}
+}
+
+func TestStartListeningEventFilter(t *testing.T) {
+	mockServer := NewMockServer()
+	mockServer.commandResult = []*models.MatterNodeData{{NodeID: 1}, {NodeID: 2}}
+	handler := NewHandler(mockServer, logger.NewConsoleLogger(logger.FatalLevel))
+
+	conn, _ := dialTestHandler(t, handler)
+
+	conn.WriteJSON(models.CommandMessage{
+		MessageID: "1",
+		Command:   string(models.APICommandStartListening),
+		Args:      map[string]interface{}{"node_ids": []int{2}},
+	})
+	msg := readMessages(t, conn)[0]
+	nodes, _ := msg["result"].([]interface{})
+	if len(nodes) != 1 || nodes[0].(map[string]interface{})["node_id"] != float64(2) {
+		t.Fatalf("Expected only node 2 in the initial state, got %v", msg["result"])
+	}
+
+	// Events of other nodes are neither delivered via the subscription
+	// nor via BroadcastEvent
+	mockServer.mu.Lock()
+	callback := mockServer.callbacks[0]
+	mockServer.mu.Unlock()
+
+	callback(models.EventTypeNodeUpdated, &models.MatterNodeData{NodeID: 1})
+	handler.BroadcastEvent(models.EventMessage{Event: models.EventTypeNodeUpdated, Data: &models.MatterNodeData{NodeID: 1}})
+	handler.BroadcastEvent(models.EventMessage{Event: models.EventTypeNodeUpdated, Data: &models.MatterNodeData{NodeID: 2}})
+
+	msg = readMessages(t, conn)[0]
+	data, _ := msg["data"].(map[string]interface{})
+	if data["node_id"] != float64(2) {
+		t.Errorf("Expected only the event of node 2, got %v", msg)
+	}
+
+	// Invalid filters are rejected
+	conn.WriteJSON(models.CommandMessage{
+		MessageID: "2",
+		Command:   string(models.APICommandStartListening),
+		Args:      map[string]interface{}{"attribute_paths": []string{"1/6"}},
+	})
+	if msg := readMessages(t, conn)[0]; msg["error_code"] != float64(models.ErrorCodeInvalidMessage) {
+		t.Errorf("Expected invalid message error, got %v", msg)
+	}
+}
+
+func TestEventsAfterStartListening(t *testing.T) {
+	mockServer := NewMockServer()
+	handler := NewHandler(mockServer, logger.NewConsoleLogger(logger.FatalLevel))
+	conn, _ := dialTestHandler(t, handler)
+	update := func(value int) {
+		handler.BroadcastEvent(models.EventMessage{
+			Event: models.EventTypeAttributeUpdated,
+			Data:  []interface{}{5, "1/8/0", value},
+		})
+	}
+	listen := func(id string) []map[string]interface{} {
+		conn.WriteJSON(models.CommandMessage{MessageID: id, Command: string(models.APICommandStartListening)})
+		return readMessages(t, conn)
+	}
+
+	// Not listening yet, and a failed start_listening drops the events
+	// broadcast while it ran
+	update(1)
+	mockServer.duringCommand = func(models.CommandMessage) { update(2) }
+	mockServer.SetCommandError(errors.New("failed"))
+	if messages := listen("1"); len(messages) != 1 || messages[0]["error_code"] == nil {
+		t.Fatalf("Expected only the error, got %v", messages)
+	}
+
+	// Events broadcast while start_listening runs follow its result
+	mockServer.SetCommandError(nil)
+	mockServer.duringCommand = func(models.CommandMessage) { update(3) }
+	messages := listen("2")
+	if len(messages) == 1 {
+		messages = append(messages, readMessages(t, conn)...)
+	}
+	if len(messages) != 2 || messages[0]["message_id"] != "2" {
+		t.Fatalf("Expected the result first, got %v", messages)
+	}
+	if data := messages[1]["data"].([]interface{}); data[2] != float64(3) {
+		t.Errorf("Expected the held update, got %v", messages[1])
+	}
+
+	mockServer.duringCommand = nil
+	update(4)
+	if data := readMessages(t, conn)[0]["data"].([]interface{}); data[2] != float64(4) {
+		t.Errorf("Expected the next update, got %v", data)
+	}
+}
+
+func TestMessagePackSubprotocol(t *testing.T) {
+	mockServer := NewMockServer()
+	handler := NewHandler(mockServer, logger.NewConsoleLogger(logger.FatalLevel))
+
+	srv := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	defer srv.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{models.CodecNameMessagePack}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	if conn.Subprotocol() != models.CodecNameMessagePack {
+		t.Fatalf("Expected subprotocol %s, got %q", models.CodecNameMessagePack, conn.Subprotocol())
+	}
+
+	readMessagePack := func(v interface{}) {
+		t.Helper()
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("Failed to read message: %v", err)
+		}
+		if messageType != websocket.BinaryMessage {
+			t.Fatalf("Expected binary message, got type %d", messageType)
+		}
+		if err := models.MessagePackCodec.Unmarshal(data, v); err != nil {
+			t.Fatalf("Failed to decode message: %v", err)
+		}
+	}
+
+	var info models.ServerInfoMessage
+	readMessagePack(&info)
+	if info.SDKVersion != "test-1.0.0" {
+		t.Errorf("Expected SDK version test-1.0.0, got %s", info.SDKVersion)
+	}
+
+	data, err := models.MessagePackCodec.Marshal(models.CommandMessage{
+		MessageID: "1",
+		Command:   string(models.APICommandServerInfo),
+	})
+	if err != nil {
+		t.Fatalf("Failed to encode command: %v", err)
+	}
+	conn.WriteMessage(websocket.BinaryMessage, data)
+
+	var response map[string]interface{}
+	readMessagePack(&response)
+	if response["message_id"] != "1" {
+		t.Errorf("Expected response to message 1, got %v", response)
+	}
+
+	mockServer.mu.Lock()
+	commands := len(mockServer.commands)
+	mockServer.mu.Unlock()
+	if commands != 1 {
+		t.Errorf("Expected 1 command, got %d", commands)
+	}
+}
+
+func TestUnknownSubprotocolFallsBackToJSON(t *testing.T) {
+	handler := NewHandler(NewMockServer(), logger.NewConsoleLogger(logger.FatalLevel))
+
+	srv := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	defer srv.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{"cbor"}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	var info models.ServerInfoMessage
+	if err := conn.ReadJSON(&info); err != nil {
+		t.Errorf("Expected JSON server info, got error: %v", err)
+	}
+}
+
+func TestCancelCommand(t *testing.T) {
+	mockServer := NewMockServer()
+	mockServer.waitForCancel = true
+	mockServer.cancelled = make(chan error, 1)
+	handler := NewHandler(mockServer, logger.NewConsoleLogger(logger.FatalLevel))
+
+	conn, _ := dialTestHandler(t, handler)
+
+	conn.WriteJSON(models.CommandMessage{
+		MessageID: "interview",
+		Command:   string(models.APICommandInterviewNode),
+		Args:      map[string]interface{}{"node_id": 5},
+	})
+
+	// Wait until the command is pending
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		conns := handler.snapshotConnections()
+		if len(conns) == 1 {
+			conns[0].pendingMu.Lock()
+			pending := len(conns[0].pending)
+			conns[0].pendingMu.Unlock()
+			if pending == 1 {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Command never became pending")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	conn.WriteJSON(models.CommandMessage{
+		MessageID: "cancel",
+		Command:   string(models.APICommandCancel),
+		Args:      map[string]interface{}{"message_id": "interview"},
+	})
+
+	responses := make(map[string]map[string]interface{})
+	for len(responses) < 2 {
+		for _, msg := range readMessages(t, conn) {
+			responses[msg["message_id"].(string)] = msg
+		}
+	}
+
+	cancelled := responses["interview"]
+	if cancelled["error_code"] != float64(models.ErrorCodeCancelled) || cancelled["cancelled_by"] != "cancel" {
+		t.Errorf("Expected cancellation result for the interview, got %v", cancelled)
+	}
+	result, _ := responses["cancel"]["result"].(map[string]interface{})
+	if result["message_id"] != "interview" || result["cancelled"] != true {
+		t.Errorf("Expected cancel result, got %v", responses["cancel"])
+	}
+
+	select {
+	case err := <-mockServer.cancelled:
+		if err != context.Canceled {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Command context was not cancelled")
+	}
+
+	// The request is no longer pending
+	conn.WriteJSON(models.CommandMessage{
+		MessageID: "cancel-again",
+		Command:   string(models.APICommandCancel),
+		Args:      map[string]interface{}{"message_id": "interview"},
+	})
+	if msg := readMessages(t, conn)[0]; msg["error_code"] != float64(models.ErrorCodeNotFound) {
+		t.Errorf("Expected not found error, got %v", msg)
+	}
+}
+
+func TestCommandProgressRoutedToRequester(t *testing.T) {
+	mockServer := NewMockServer()
+	mockServer.progressStages = []string{"reading_attributes"}
+	handler := NewHandler(mockServer, logger.NewConsoleLogger(logger.FatalLevel))
+
+	requester, _ := dialTestHandler(t, handler)
+	other, _ := dialTestHandler(t, handler)
+
+	requester.WriteJSON(models.CommandMessage{
+		MessageID: "interview",
+		Command:   string(models.APICommandInterviewNode),
+	})
+
+	// Progress is queued before the result, both may share a frame
+	var messages []map[string]interface{}
+	for len(messages) < 2 {
+		messages = append(messages, readMessages(t, requester)...)
+	}
+
+	if messages[0]["event"] != string(models.EventTypeCommandProgress) {
+		t.Fatalf("Expected command_progress event first, got %v", messages[0])
+	}
+	data, _ := messages[0]["data"].(map[string]interface{})
+	if data["message_id"] != "interview" || data["stage"] != "reading_attributes" || data["percent"] != float64(0) {
+		t.Errorf("Unexpected progress %v", data)
+	}
+	if messages[1]["message_id"] != "interview" {
+		t.Errorf("Expected command result after progress, got %v", messages[1])
+	}
+
+	// Other connections don't receive the progress
+	other.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, data, err := other.ReadMessage(); err == nil {
+		t.Errorf("Expected no message on other connection, got %s", data)
+	}
+}
+
+func TestInvalidArgumentsError(t *testing.T) {
+	mockServer := NewMockServer()
+	mockServer.commandError = &models.ArgumentError{Field: "node_id", Reason: "missing required argument"}
+	handler := NewHandler(mockServer, logger.NewConsoleLogger(logger.FatalLevel))
+
+	conn, _ := dialTestHandler(t, handler)
+	conn.WriteJSON(models.CommandMessage{
+		MessageID: "1",
+		Command:   string(models.APICommandGetNode),
+	})
+
+	msg := readMessages(t, conn)[0]
+	if msg["error_code"] != float64(models.ErrorCodeInvalidArguments) || msg["field"] != "node_id" {
+		t.Errorf("Expected invalid arguments error for node_id, got %v", msg)
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	mockServer := NewMockServer()
+	mockServer.commandError = fmt.Errorf("%w: set_log_level", models.ErrUnauthorized)
+	handler := NewHandler(mockServer, logger.NewConsoleLogger(logger.FatalLevel))
+
+	srv := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	defer srv.Close()
+	header := http.Header{"Authorization": []string{"Bearer 0123456789abcdef"}}
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), header)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	readMessages(t, conn)
+
+	conn.WriteJSON(models.CommandMessage{MessageID: "1", Command: string(models.APICommandSetLogLevel)})
+	msg := readMessages(t, conn)[0]
+	if msg["error_code"] != float64(models.ErrorCodeUnauthorized) {
+		t.Errorf("Expected an unauthorized error, got %v", msg)
+	}
+
+	// The token of the upgrade request comes with every command
+	mockServer.mu.Lock()
+	defer mockServer.mu.Unlock()
+	if len(mockServer.clients) != 1 || mockServer.clients[0].BearerToken != "0123456789abcdef" {
+		t.Errorf("Expected the bearer token of the upgrade request, got %+v", mockServer.clients)
+	}
+}
+
+func TestCommandPanicRecovered(t *testing.T) {
+	mockServer := NewMockServer()
+	mockServer.panicCommand = string(models.APICommandGetNode)
+	handler := NewHandler(mockServer, logger.NewConsoleLogger(logger.FatalLevel))
+
+	conn, _ := dialTestHandler(t, handler)
+	conn.WriteJSON(models.CommandMessage{
+		MessageID: "1",
+		Command:   string(models.APICommandGetNode),
+	})
+	msg := readMessages(t, conn)[0]
+	if msg["message_id"] != "1" || msg["error_code"] != float64(models.ErrorCodeCommandFailed) || msg["details"] != "internal server error" {
+		t.Errorf("Expected an internal error result, got %v", msg)
+	}
+
+	// The connection keeps working
+	conn.WriteJSON(models.CommandMessage{
+		MessageID: "2",
+		Command:   string(models.APICommandServerInfo),
+	})
+	msg = readMessages(t, conn)[0]
+	if msg["message_id"] != "2" || msg["result"] == nil {
+		t.Errorf("Expected a result after the panic, got %v", msg)
+	}
+	if panics := handler.Stats().Panics; panics != 1 {
+		t.Errorf("Expected 1 panic counted, got %d", panics)
+	}
+}
+
+func TestCommandLimits(t *testing.T) {
+	t.Run("Rate", func(t *testing.T) {
+		handler := NewHandler(NewMockServer(), logger.NewConsoleLogger(logger.FatalLevel))
+		handler.SetLimits(Limits{CommandsPerSecond: 0.001, Burst: 1})
+
+		conn, _ := dialTestHandler(t, handler)
+		for _, id := range []string{"1", "2"} {
+			conn.WriteJSON(models.CommandMessage{MessageID: id, Command: string(models.APICommandServerInfo)})
+		}
+
+		responses := make(map[string]map[string]interface{})
+		for len(responses) < 2 {
+			for _, msg := range readMessages(t, conn) {
+				responses[msg["message_id"].(string)] = msg
+			}
+		}
+		if _, failed := responses["1"]["error_code"]; failed {
+			t.Errorf("Expected first command to succeed, got %v", responses["1"])
+		}
+		if responses["2"]["error_code"] != float64(models.ErrorCodeRateLimited) {
+			t.Errorf("Expected second command to be rate limited, got %v", responses["2"])
+		}
+		if stats := handler.Stats(); stats.RateLimitedCommands != 1 {
+			t.Errorf("Expected 1 rate limited command, got %d", stats.RateLimitedCommands)
+		}
+	})
+
+	t.Run("In flight", func(t *testing.T) {
+		mockServer := NewMockServer()
+		mockServer.waitForCancel = true
+		mockServer.cancelled = make(chan error, 1)
+		handler := NewHandler(mockServer, logger.NewConsoleLogger(logger.FatalLevel))
+		handler.SetLimits(Limits{MaxInFlight: 1})
+
+		conn, _ := dialTestHandler(t, handler)
+		conn.WriteJSON(models.CommandMessage{MessageID: "slow", Command: string(models.APICommandInterviewNode)})
+		conn.WriteJSON(models.CommandMessage{MessageID: "2", Command: string(models.APICommandServerInfo)})
+
+		if msg := readMessages(t, conn)[0]; msg["message_id"] != "2" || msg["error_code"] != float64(models.ErrorCodeRateLimited) {
+			t.Fatalf("Expected second command to be rejected, got %v", msg)
+		}
+
+		// Cancel isn't subject to the in-flight limit
+		conn.WriteJSON(models.CommandMessage{
+			MessageID: "cancel",
+			Command:   string(models.APICommandCancel),
+			Args:      map[string]interface{}{"message_id": "slow"},
+		})
+		responses := make(map[string]map[string]interface{})
+		for len(responses) < 2 {
+			for _, msg := range readMessages(t, conn) {
+				responses[msg["message_id"].(string)] = msg
+			}
+		}
+		if responses["slow"]["error_code"] != float64(models.ErrorCodeCancelled) {
+			t.Errorf("Expected slow command to be cancelled, got %v", responses["slow"])
+		}
+	})
+}
+
+func TestSessions(t *testing.T) {
+	handler := NewHandler(NewMockServer(), logger.NewConsoleLogger(logger.FatalLevel))
+	conn, _ := dialTestHandler(t, handler)
+
+	conn.WriteJSON(models.CommandMessage{
+		MessageID: "1",
+		Command:   string(models.APICommandStartListening),
+		Args: map[string]interface{}{
+			"events":   []string{"node_updated", "node_added"},
+			"node_ids": []int{7, 5},
+		},
+	})
+	readMessages(t, conn)
+
+	// The sent counter is updated once the write returned
+	var sessions []models.SessionInfo
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		sessions = handler.Sessions()
+		if len(sessions) != 1 {
+			t.Fatalf("Expected 1 session, got %d", len(sessions))
+		}
+		if sessions[0].MessagesSent > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	session := sessions[0]
+	if session.MessagesSent != 1 {
+		t.Errorf("Expected 1 message sent, got %d", session.MessagesSent)
+	}
+	if session.RemoteAddress == "" || session.ConnectedAt.IsZero() {
+		t.Errorf("Expected remote address and connection time, got %+v", session)
+	}
+	if session.Codec != models.CodecNameJSON {
+		t.Errorf("Expected codec %s, got %s", models.CodecNameJSON, session.Codec)
+	}
+	want := &models.SessionFilter{
+		Events:  []models.EventType{models.EventTypeNodeAdded, models.EventTypeNodeUpdated},
+		NodeIDs: []int{5, 7},
+	}
+	if !reflect.DeepEqual(session.Filter, want) {
+		t.Errorf("Expected filter %+v, got %+v", want, session.Filter)
+	}
+
+	if handler.DisconnectSession("unknown") {
+		t.Error("Expected unknown session not to be disconnected")
+	}
+	if !handler.DisconnectSession(session.ID) {
+		t.Fatal("Expected session to be disconnected")
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Error("Expected connection to be closed")
+	}
+	if count := handler.GetConnectionCount(); count != 0 {
+		t.Errorf("Expected 0 connections, got %d", count)
+	}
+}
+
+func TestSeverConnections(t *testing.T) {
+	handler := NewHandler(NewMockServer(), logger.NewConsoleLogger(logger.FatalLevel))
+	first, _ := dialTestHandler(t, handler)
+	second, _ := dialTestHandler(t, handler)
+
+	if severed := handler.SeverConnections(); severed != 2 {
+		t.Fatalf("Expected 2 connections severed, got %d", severed)
+	}
+	for _, conn := range []*websocket.Conn{first, second} {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, _, err := conn.ReadMessage()
+		// No close frame is sent
+		if !websocket.IsCloseError(err, websocket.CloseAbnormalClosure) {
+			t.Errorf("Expected an abnormal closure, got %v", err)
+		}
+	}
+	if count := handler.GetConnectionCount(); count != 0 {
+		t.Errorf("Expected 0 connections, got %d", count)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	notice := models.ServerRestarting{Reason: "upgrade", DrainTimeoutMs: 2000}
+
+	t.Run("Waits for in-flight commands", func(t *testing.T) {
+		mockServer := NewMockServer()
+		mockServer.waitForCancel = true
+		mockServer.cancelled = make(chan error, 1)
+		handler := NewHandler(mockServer, logger.NewConsoleLogger(logger.FatalLevel))
+
+		srv := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+		t.Cleanup(srv.Close)
+		url := "ws" + strings.TrimPrefix(srv.URL, "http")
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		var info models.ServerInfoMessage
+		conn.ReadJSON(&info)
+
+		conn.WriteJSON(models.CommandMessage{MessageID: "slow", Command: string(models.APICommandInterviewNode)})
+		waitForInFlight(t, handler, 1)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		drained := make(chan struct{})
+		go func() {
+			handler.Drain(ctx, notice)
+			close(drained)
+		}()
+
+		msg := readMessages(t, conn)[0]
+		if msg["event"] != string(models.EventTypeServerRestarting) {
+			t.Fatalf("Expected server_restarting event, got %v", msg)
+		}
+		if data := msg["data"].(map[string]interface{}); data["reason"] != "upgrade" || data["drain_timeout_ms"] != float64(2000) {
+			t.Errorf("Expected restart notice, got %v", data)
+		}
+
+		conn.WriteJSON(models.CommandMessage{MessageID: "2", Command: string(models.APICommandServerInfo)})
+		if msg := readMessages(t, conn)[0]; msg["error_code"] != float64(models.ErrorCodeServerRestarting) {
+			t.Errorf("Expected command to be rejected while draining, got %v", msg)
+		}
+
+		if _, resp, err := websocket.DefaultDialer.Dial(url, nil); err == nil || resp.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("Expected new connections to be rejected while draining, got %v", err)
+		}
+
+		select {
+		case <-drained:
+			t.Fatal("Expected drain to wait for the in-flight command")
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		// Cancelling answers the in-flight command, which ends the drain
+		conn.WriteJSON(models.CommandMessage{
+			MessageID: "cancel",
+			Command:   string(models.APICommandCancel),
+			Args:      map[string]interface{}{"message_id": "slow"},
+		})
+		responses := 0
+		for responses < 2 {
+			responses += len(readMessages(t, conn))
+		}
+
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, _, err = conn.ReadMessage()
+		if !websocket.IsCloseError(err, websocket.CloseServiceRestart) {
+			t.Errorf("Expected service restart close frame, got %v", err)
+		}
+		if closeErr, ok := err.(*websocket.CloseError); ok && closeErr.Text != "upgrade" {
+			t.Errorf("Expected close reason upgrade, got %q", closeErr.Text)
+		}
+
+		select {
+		case <-drained:
+		case <-time.After(2 * time.Second):
+			t.Fatal("Expected drain to finish")
+		}
+		if ctx.Err() != nil {
+			t.Error("Expected drain to finish before the timeout")
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		mockServer := NewMockServer()
+		mockServer.waitForCancel = true
+		mockServer.cancelled = make(chan error, 1)
+		handler := NewHandler(mockServer, logger.NewConsoleLogger(logger.FatalLevel))
+
+		conn, _ := dialTestHandler(t, handler)
+		conn.WriteJSON(models.CommandMessage{MessageID: "slow", Command: string(models.APICommandInterviewNode)})
+		waitForInFlight(t, handler, 1)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		handler.Drain(ctx, notice)
+
+		if count := handler.GetConnectionCount(); count != 0 {
+			t.Errorf("Expected 0 connections after drain, got %d", count)
+		}
+		select {
+		case <-mockServer.cancelled:
+		case <-time.After(2 * time.Second):
+			t.Error("Expected in-flight command to be cancelled")
+		}
+	})
+}
+
+// waitForInFlight waits until the handler's connections have n commands in
+// flight
+func waitForInFlight(t *testing.T, handler *Handler, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		inFlight := 0
+		for _, session := range handler.Sessions() {
+			inFlight += session.InFlightCommands
+		}
+		if inFlight == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d commands in flight, got %d", n, inFlight)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
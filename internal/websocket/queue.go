@@ -0,0 +1,179 @@
+package websocket
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// DefaultQueueSize is the number of messages buffered per connection
+const DefaultQueueSize = 256
+
+// OverflowPolicy decides what happens when a connection's send queue is full
+type OverflowPolicy string
+
+const (
+	// OverflowDropOldest drops the oldest queued events to make room. The
+	// connection is only closed when the queue is full of command responses.
+	OverflowDropOldest OverflowPolicy = "drop_oldest"
+
+	// OverflowDisconnect closes the connection as soon as its queue is full
+	OverflowDisconnect OverflowPolicy = "disconnect"
+)
+
+// ParseOverflowPolicy parses an overflow policy name. An empty name selects
+// OverflowDropOldest.
+func ParseOverflowPolicy(name string) (OverflowPolicy, error) {
+	switch OverflowPolicy(name) {
+	case "", OverflowDropOldest:
+		return OverflowDropOldest, nil
+	case OverflowDisconnect:
+		return OverflowDisconnect, nil
+	}
+	return "", fmt.Errorf("unknown overflow policy %q", name)
+}
+
+// queuedMessage is a marshalled message waiting to be written. Events may be
+// dropped under backpressure, command responses never are.
+type queuedMessage struct {
+	data  []byte
+	event bool
+	key   string
+}
+
+// pushResult reports what happened to a message passed to sendQueue.push
+type pushResult struct {
+	coalesced bool
+	dropped   int
+	overflow  bool
+}
+
+// sendQueue is a bounded FIFO of outgoing messages. Queued attribute_updated
+// events for the same node and path are coalesced so only the latest value is
+// written, in the place of the latest event so it stays after the node events
+// queued in between.
+type sendQueue struct {
+	mu       sync.Mutex
+	messages []*queuedMessage
+	keys     map[string]*queuedMessage
+	limit    int
+	policy   OverflowPolicy
+
+	// ready is signalled whenever messages are queued
+	ready chan struct{}
+}
+
+func newSendQueue(limit int, policy OverflowPolicy) *sendQueue {
+	return &sendQueue{
+		keys:   make(map[string]*queuedMessage),
+		limit:  limit,
+		policy: policy,
+		ready:  make(chan struct{}, 1),
+	}
+}
+
+// push queues a message. Messages with a non-empty key replace a queued
+// message with the same key, which is removed from its place.
+func (q *sendQueue) push(msg *queuedMessage) pushResult {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var result pushResult
+	if msg.key != "" {
+		if queued, ok := q.keys[msg.key]; ok {
+			q.remove(queued)
+			result.coalesced = true
+		}
+	}
+
+	for len(q.messages) >= q.limit {
+		if q.policy != OverflowDropOldest || !q.dropOldestEvent() {
+			result.overflow = true
+			return result
+		}
+		result.dropped++
+	}
+
+	q.messages = append(q.messages, msg)
+	if msg.key != "" {
+		q.keys[msg.key] = msg
+	}
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+	return result
+}
+
+// remove removes a queued message
+func (q *sendQueue) remove(msg *queuedMessage) {
+	for i, queued := range q.messages {
+		if queued == msg {
+			q.messages = append(q.messages[:i], q.messages[i+1:]...)
+			break
+		}
+	}
+	if msg.key != "" {
+		delete(q.keys, msg.key)
+	}
+}
+
+// dropOldestEvent removes the oldest queued event, reporting false when only
+// command responses are queued
+func (q *sendQueue) dropOldestEvent() bool {
+	for i, msg := range q.messages {
+		if !msg.event {
+			continue
+		}
+		q.messages = append(q.messages[:i], q.messages[i+1:]...)
+		if msg.key != "" {
+			delete(q.keys, msg.key)
+		}
+		return true
+	}
+	return false
+}
+
+// drain removes and returns all queued messages in order
+func (q *sendQueue) drain() [][]byte {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	batch := make([][]byte, len(q.messages))
+	for i, msg := range q.messages {
+		batch[i] = msg.data
+	}
+	q.messages = nil
+	clear(q.keys)
+	return batch
+}
+
+// len returns the number of queued messages
+func (q *sendQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.messages)
+}
+
+// coalesceKey returns the key under which queued events replace each other,
+// or "" for events that must all be delivered. Only the latest attribute value
+// and progress of a command are kept.
+func coalesceKey(eventType models.EventType, data interface{}) string {
+	if p, ok := data.(models.CommandProgress); ok && eventType == models.EventTypeCommandProgress {
+		return "progress/" + p.MessageID
+	}
+	if eventType != models.EventTypeAttributeUpdated {
+		return ""
+	}
+	nodeID, ok := EventNodeID(data)
+	if !ok {
+		return ""
+	}
+	path, ok := eventAttributePath(data)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%d/%s", nodeID, path)
+}
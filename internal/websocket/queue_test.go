@@ -0,0 +1,149 @@
+package websocket
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+func TestSendQueueCoalescesAttributeUpdates(t *testing.T) {
+	q := newSendQueue(10, OverflowDropOldest)
+
+	q.push(&queuedMessage{data: []byte("a1"), event: true, key: "5/1/6/0"})
+	q.push(&queuedMessage{data: []byte("b"), event: true})
+	if result := q.push(&queuedMessage{data: []byte("a2"), event: true, key: "5/1/6/0"}); !result.coalesced {
+		t.Error("Expected second update of the same path to be coalesced")
+	}
+
+	batch := q.drain()
+	if len(batch) != 2 || string(batch[0]) != "b" || string(batch[1]) != "a2" {
+		t.Errorf("Expected [b a2], got %q", batch)
+	}
+
+	// Drained keys don't coalesce with new messages
+	if result := q.push(&queuedMessage{data: []byte("a3"), event: true, key: "5/1/6/0"}); result.coalesced {
+		t.Error("Expected update after drain not to be coalesced")
+	}
+}
+
+func TestSendQueueCoalescingKeepsNodeEventOrder(t *testing.T) {
+	q := newSendQueue(10, OverflowDropOldest)
+	push := func(eventType models.EventType, data interface{}, name string) {
+		q.push(&queuedMessage{data: []byte(name), event: true, key: coalesceKey(eventType, data)})
+	}
+
+	// The node is updated, its value changes, it is removed and re-added
+	// with another value
+	push(models.EventTypeAttributeUpdated, []interface{}{5, "1/6/0", false}, "off")
+	push(models.EventTypeAttributeUpdated, []interface{}{5, "1/8/0", 10}, "level")
+	push(models.EventTypeNodeUpdated, &models.MatterNodeData{NodeID: 5}, "node_updated")
+	push(models.EventTypeAttributeUpdated, []interface{}{5, "1/6/0", true}, "on")
+	push(models.EventTypeNodeRemoved, 5, "node_removed")
+	push(models.EventTypeNodeAdded, &models.MatterNodeData{NodeID: 5}, "node_added")
+	push(models.EventTypeAttributeUpdated, []interface{}{5, "1/6/0", false}, "off again")
+
+	var got []string
+	for _, data := range q.drain() {
+		got = append(got, string(data))
+	}
+	want := []string{"level", "node_updated", "node_removed", "node_added", "off again"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestSendQueueOverflow(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       OverflowPolicy
+		queued       []*queuedMessage
+		wantDropped  int
+		wantOverflow bool
+		wantBatch    []string
+	}{
+		{
+			name:   "Drop oldest event",
+			policy: OverflowDropOldest,
+			queued: []*queuedMessage{
+				{data: []byte("response")},
+				{data: []byte("event1"), event: true},
+			},
+			wantDropped: 1,
+			wantBatch:   []string{"response", "new"},
+		},
+		{
+			name:   "Only responses queued",
+			policy: OverflowDropOldest,
+			queued: []*queuedMessage{
+				{data: []byte("response1")},
+				{data: []byte("response2")},
+			},
+			wantOverflow: true,
+			wantBatch:    []string{"response1", "response2"},
+		},
+		{
+			name:   "Disconnect",
+			policy: OverflowDisconnect,
+			queued: []*queuedMessage{
+				{data: []byte("event1"), event: true},
+				{data: []byte("event2"), event: true},
+			},
+			wantOverflow: true,
+			wantBatch:    []string{"event1", "event2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newSendQueue(2, tt.policy)
+			for _, msg := range tt.queued {
+				q.push(msg)
+			}
+
+			result := q.push(&queuedMessage{data: []byte("new"), event: true})
+			if result.dropped != tt.wantDropped {
+				t.Errorf("Expected %d dropped, got %d", tt.wantDropped, result.dropped)
+			}
+			if result.overflow != tt.wantOverflow {
+				t.Errorf("Expected overflow %v, got %v", tt.wantOverflow, result.overflow)
+			}
+
+			batch := q.drain()
+			if len(batch) != len(tt.wantBatch) {
+				t.Fatalf("Expected %d messages, got %q", len(tt.wantBatch), batch)
+			}
+			for i, want := range tt.wantBatch {
+				if string(batch[i]) != want {
+					t.Errorf("Expected message %d to be %s, got %s", i, want, batch[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCoalesceKey(t *testing.T) {
+	update := []interface{}{5, "1/6/0", true}
+	if key := coalesceKey(models.EventTypeAttributeUpdated, update); key != "5/1/6/0" {
+		t.Errorf("Expected 5/1/6/0, got %q", key)
+	}
+	if key := coalesceKey(models.EventTypeNodeEvent, models.MatterNodeEvent{NodeID: 5}); key != "" {
+		t.Errorf("Expected node events not to be coalesced, got %q", key)
+	}
+}
+
+func TestParseOverflowPolicy(t *testing.T) {
+	for name, want := range map[string]OverflowPolicy{
+		"":            OverflowDropOldest,
+		"drop_oldest": OverflowDropOldest,
+		"disconnect":  OverflowDisconnect,
+	} {
+		if got, err := ParseOverflowPolicy(name); err != nil || got != want {
+			t.Errorf("Expected %q for %q, got %q (%v)", want, name, got, err)
+		}
+	}
+
+	if _, err := ParseOverflowPolicy("block"); err == nil {
+		t.Error("Expected error for unknown policy")
+	}
+}
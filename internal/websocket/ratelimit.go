@@ -0,0 +1,67 @@
+package websocket
+
+import (
+	"sync"
+	"time"
+)
+
+// Limits bounds the commands a single connection may send. Zero values
+// disable the respective limit.
+type Limits struct {
+	// CommandsPerSecond is the sustained command rate
+	CommandsPerSecond float64
+
+	// Burst is the number of commands that may be sent at once before the
+	// rate applies
+	Burst int
+
+	// MaxInFlight is the number of commands handled concurrently
+	MaxInFlight int
+}
+
+// rateLimiter is a token bucket. A nil rateLimiter allows everything.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		now:    time.Now,
+	}
+}
+
+// allow takes a token, reporting false when the bucket is empty
+func (l *rateLimiter) allow() bool {
+	if l == nil {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if !l.last.IsZero() {
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
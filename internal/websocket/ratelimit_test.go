@@ -0,0 +1,48 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	limiter := newRateLimiter(2, 3)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if !limiter.allow() {
+			t.Fatalf("Expected burst command %d to be allowed", i)
+		}
+	}
+	if limiter.allow() {
+		t.Error("Expected command beyond burst to be rejected")
+	}
+
+	// Two commands per second refill one token every 500ms
+	now = now.Add(500 * time.Millisecond)
+	if !limiter.allow() {
+		t.Error("Expected command after refill to be allowed")
+	}
+	if limiter.allow() {
+		t.Error("Expected second command after refill to be rejected")
+	}
+
+	// Tokens don't accumulate beyond the burst
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		limiter.allow()
+	}
+	if limiter.allow() {
+		t.Error("Expected tokens to be capped at the burst")
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	limiter := newRateLimiter(0, 10)
+	for i := 0; i < 1000; i++ {
+		if !limiter.allow() {
+			t.Fatal("Expected disabled limiter to allow everything")
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package websocket
+
+import (
+	"fmt"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// schemaVersionNodeExtensions is the schema version that added the
+// bridged_endpoints and attribute_names node fields
+const schemaVersionNodeExtensions = 11
+
+// eventSchemaVersions lists events introduced after the first schema
+// version. Clients that negotiated an older schema don't receive them.
+var eventSchemaVersions = map[models.EventType]int{
+	models.EventTypeEndpointAdded:              11,
+	models.EventTypeEndpointRemoved:            11,
+	models.EventTypeClockSkewDetected:          11,
+	models.EventTypeCommandProgress:            11,
+	models.EventTypeServerRestarting:           11,
+	models.EventTypeScanResult:                 11,
+	models.EventTypeBluetoothStatusChanged:     11,
+	models.EventTypeLogEntry:                   11,
+	models.EventTypeQueuedInteractionCompleted: 11,
+	models.EventTypeSubsystemRestarted:         11,
+	models.EventTypeFirmwareUpdateAvailable:    11,
+}
+
+// schemaVersionError is returned when a client requests a schema version
+// outside the supported range
+type schemaVersionError struct {
+	requested int
+	supported models.SchemaVersionRange
+}
+
+func (e *schemaVersionError) Error() string {
+	return fmt.Sprintf("schema version %d is not supported (supported: %d-%d)",
+		e.requested, e.supported.Min, e.supported.Max)
+}
+
+// negotiateSchemaVersion returns the schema version requested by the
+// schema_version argument of start_listening, defaulting to the server's
+// current version
+func negotiateSchemaVersion(args map[string]interface{}, info models.ServerInfoMessage) (int, error) {
+	v, ok := args["schema_version"]
+	if !ok || v == nil {
+		return info.SchemaVersion, nil
+	}
+
+	f, ok := v.(float64)
+	if !ok || f != float64(int(f)) {
+		return 0, fmt.Errorf("invalid schema_version: %v", v)
+	}
+
+	version := int(f)
+	if version < info.MinSupportedSchemaVersion || version > info.SchemaVersion {
+		return 0, &schemaVersionError{
+			requested: version,
+			supported: models.SchemaVersionRange{
+				Min: info.MinSupportedSchemaVersion,
+				Max: info.SchemaVersion,
+			},
+		}
+	}
+	return version, nil
+}
+
+// eventSupported reports whether an event exists in a schema version
+func eventSupported(version int, eventType models.EventType) bool {
+	introduced, ok := eventSchemaVersions[eventType]
+	return !ok || version >= introduced
+}
+
+// adaptData converts command results and event data to the shape of an
+// older schema version
+func adaptData(version int, data interface{}) interface{} {
+	if version >= schemaVersionNodeExtensions {
+		return data
+	}
+
+	switch d := data.(type) {
+	case *models.MatterNodeData:
+		return legacyNode(d)
+	case []*models.MatterNodeData:
+		nodes := make([]*models.MatterNodeData, len(d))
+		for i, node := range d {
+			nodes[i] = legacyNode(node)
+		}
+		return nodes
+	default:
+		return data
+	}
+}
+
+func legacyNode(node *models.MatterNodeData) *models.MatterNodeData {
+	nodeCopy := *node
+	nodeCopy.BridgedEndpoints = nil
+	nodeCopy.AttributeNames = nil
+	return &nodeCopy
+}
@@ -0,0 +1,79 @@
+package websocket
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+func TestNegotiateSchemaVersion(t *testing.T) {
+	info := models.ServerInfoMessage{SchemaVersion: 11, MinSupportedSchemaVersion: 5}
+
+	tests := []struct {
+		name        string
+		args        map[string]interface{}
+		want        int
+		wantRange   bool
+		expectError bool
+	}{
+		{name: "Default", args: nil, want: 11},
+		{name: "Older", args: map[string]interface{}{"schema_version": float64(5)}, want: 5},
+		{name: "Too old", args: map[string]interface{}{"schema_version": float64(4)}, expectError: true, wantRange: true},
+		{name: "Too new", args: map[string]interface{}{"schema_version": float64(12)}, expectError: true, wantRange: true},
+		{name: "Invalid", args: map[string]interface{}{"schema_version": "eleven"}, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, err := negotiateSchemaVersion(tt.args, info)
+			if tt.expectError {
+				var versionErr *schemaVersionError
+				if err == nil {
+					t.Fatal("Expected error")
+				}
+				if errors.As(err, &versionErr) != tt.wantRange {
+					t.Errorf("Unexpected error type: %v", err)
+				}
+				if tt.wantRange && (versionErr.supported.Min != 5 || versionErr.supported.Max != 11) {
+					t.Errorf("Expected supported range 5-11, got %+v", versionErr.supported)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if version != tt.want {
+				t.Errorf("Expected version %d, got %d", tt.want, version)
+			}
+		})
+	}
+}
+
+func TestAdaptData(t *testing.T) {
+	node := &models.MatterNodeData{
+		NodeID:           1,
+		AttributeNames:   map[string]string{"0/40/0": "BasicInformation.DataModelRevision"},
+		BridgedEndpoints: []models.BridgedEndpoint{{EndpointID: 3}},
+	}
+
+	current := adaptData(11, node).(*models.MatterNodeData)
+	if current.BridgedEndpoints == nil || current.AttributeNames == nil {
+		t.Error("Expected current schema to keep node extensions")
+	}
+
+	legacy := adaptData(10, []*models.MatterNodeData{node}).([]*models.MatterNodeData)
+	if legacy[0].BridgedEndpoints != nil || legacy[0].AttributeNames != nil {
+		t.Error("Expected older schema to drop node extensions")
+	}
+	if node.BridgedEndpoints == nil {
+		t.Error("Expected original node to be unchanged")
+	}
+
+	if !eventSupported(10, models.EventTypeNodeAdded) || eventSupported(10, models.EventTypeEndpointAdded) {
+		t.Error("Expected endpoint_added to be filtered for schema 10 only")
+	}
+	if !eventSupported(11, models.EventTypeEndpointAdded) {
+		t.Error("Expected endpoint_added for schema 11")
+	}
+}
@@ -0,0 +1,204 @@
+package client
+
+import (
+	"encoding/json"
+	"maps"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// AttributeChange is a changed attribute value of a cached node
+type AttributeChange struct {
+	NodeID int
+	// Path is the attribute path "endpoint/cluster/attribute"
+	Path string
+	// OldValue is nil for attributes that weren't known, Value is nil for
+	// attributes a node no longer has
+	OldValue interface{}
+	Value    interface{}
+}
+
+// nodeCache mirrors the nodes of start_listening and keeps them up to date
+// with the events. Cached nodes are replaced instead of modified, so
+// readers get consistent snapshots without copying.
+type nodeCache struct {
+	mu    sync.RWMutex
+	nodes map[int]*Node
+}
+
+func newNodeCache() *nodeCache {
+	return &nodeCache{nodes: make(map[int]*Node)}
+}
+
+// reset replaces the nodes with those of a start_listening response and
+// returns the attribute changes of the nodes cached before, e.g. those
+// missed while disconnected
+func (nc *nodeCache) reset(result json.RawMessage) []AttributeChange {
+	if nc == nil {
+		return nil
+	}
+	var nodes []*Node
+	if err := json.Unmarshal(result, &nodes); err != nil {
+		return nil
+	}
+
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	old := nc.nodes
+	nc.nodes = make(map[int]*Node, len(nodes))
+	var changes []AttributeChange
+	for _, node := range nodes {
+		nc.nodes[node.NodeID] = node
+		if previous, ok := old[node.NodeID]; ok {
+			changes = append(changes, attributeChanges(previous, node)...)
+		}
+	}
+	return changes
+}
+
+// apply updates the cache with an event and returns the attribute changes
+func (nc *nodeCache) apply(event Event) []AttributeChange {
+	if nc == nil {
+		return nil
+	}
+
+	switch event.Type {
+	case models.EventTypeNodeAdded, models.EventTypeNodeUpdated:
+		var node Node
+		if err := json.Unmarshal(event.Data, &node); err != nil {
+			return nil
+		}
+		nc.mu.Lock()
+		defer nc.mu.Unlock()
+		previous, ok := nc.nodes[node.NodeID]
+		nc.nodes[node.NodeID] = &node
+		if ok {
+			return attributeChanges(previous, &node)
+		}
+
+	case models.EventTypeNodeRemoved:
+		var nodeID int
+		if err := json.Unmarshal(event.Data, &nodeID); err != nil {
+			return nil
+		}
+		nc.mu.Lock()
+		delete(nc.nodes, nodeID)
+		nc.mu.Unlock()
+
+	case models.EventTypeAttributeUpdated:
+		var update AttributeUpdate
+		if err := json.Unmarshal(event.Data, &update); err != nil {
+			return nil
+		}
+		nc.mu.Lock()
+		defer nc.mu.Unlock()
+		previous, ok := nc.nodes[update.NodeID]
+		if !ok {
+			return nil
+		}
+		oldValue, known := previous.Attributes[update.Path]
+		if known && reflect.DeepEqual(oldValue, update.Value) {
+			return nil
+		}
+		node := *previous
+		node.Attributes = maps.Clone(previous.Attributes)
+		if node.Attributes == nil {
+			node.Attributes = make(map[string]interface{})
+		}
+		node.Attributes[update.Path] = update.Value
+		nc.nodes[node.NodeID] = &node
+		return []AttributeChange{{NodeID: node.NodeID, Path: update.Path, OldValue: oldValue, Value: update.Value}}
+	}
+	return nil
+}
+
+// attributeChanges compares the attributes of two versions of a node
+func attributeChanges(previous, node *Node) []AttributeChange {
+	var changes []AttributeChange
+	for path, value := range node.Attributes {
+		if oldValue, ok := previous.Attributes[path]; !ok || !reflect.DeepEqual(oldValue, value) {
+			changes = append(changes, AttributeChange{NodeID: node.NodeID, Path: path, OldValue: oldValue, Value: value})
+		}
+	}
+	for path, oldValue := range previous.Attributes {
+		if _, ok := node.Attributes[path]; !ok {
+			changes = append(changes, AttributeChange{NodeID: node.NodeID, Path: path, OldValue: oldValue})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// WithCache keeps a local mirror of the nodes received by Subscribe,
+// updated by the events before they are passed to the callbacks. The
+// cached nodes are read with GetCachedNode, CachedNodes and
+// GetCachedAttribute, and changed values are passed to OnAttributeChange,
+// so integrations don't need to ask the server for the state of a node.
+// The cache follows the filters of Subscribe. With WithReconnect it is
+// replaced by the nodes received after reconnecting.
+func WithCache() Option {
+	return func(o *options) { o.cache = true }
+}
+
+// GetCachedNode returns the cached data of a node without asking the
+// server. It needs WithCache and Subscribe. The node is a snapshot shared
+// with other callers and must not be modified.
+func (c *Client) GetCachedNode(nodeID int) (*Node, bool) {
+	if c.cache == nil {
+		return nil, false
+	}
+	c.cache.mu.RLock()
+	defer c.cache.mu.RUnlock()
+	node, ok := c.cache.nodes[nodeID]
+	return node, ok
+}
+
+// CachedNodes returns the cached nodes sorted by ID, like GetCachedNode
+func (c *Client) CachedNodes() []*Node {
+	if c.cache == nil {
+		return nil
+	}
+	c.cache.mu.RLock()
+	nodes := make([]*Node, 0, len(c.cache.nodes))
+	for _, node := range c.cache.nodes {
+		nodes = append(nodes, node)
+	}
+	c.cache.mu.RUnlock()
+
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].NodeID < nodes[j].NodeID })
+	return nodes
+}
+
+// GetCachedAttribute returns the cached value of an attribute path
+// ("endpoint/cluster/attribute") of a node
+func (c *Client) GetCachedAttribute(nodeID int, path string) (interface{}, bool) {
+	node, ok := c.GetCachedNode(nodeID)
+	if !ok {
+		return nil, false
+	}
+	value, ok := node.Attributes[path]
+	return value, ok
+}
+
+// OnAttributeChange registers a callback for attribute values of cached
+// nodes that changed, whether by attribute_updated or node_updated events
+// or while disconnected. Updates repeating the cached value are skipped.
+// It needs WithCache.
+func (c *Client) OnAttributeChange(fn func(AttributeChange)) func() {
+	return c.attributeChangeCallbacks.add(fn)
+}
+
+// pushChanges queues the attribute changes for their callbacks
+func (c *Client) pushChanges(changes []AttributeChange) {
+	if len(changes) == 0 {
+		return
+	}
+	c.events.push(func() {
+		for _, change := range changes {
+			c.attributeChangeCallbacks.call(change)
+		}
+	})
+}
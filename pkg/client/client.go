@@ -0,0 +1,693 @@
+// Package client is a Go client for the WebSocket API of the Matter server.
+//
+// A Client correlates responses with their commands by message ID, so
+// commands may be sent concurrently from several goroutines. Events are
+// passed to the callbacks registered with the On methods after Subscribe
+// starts listening:
+//
+//	c, err := client.Dial(ctx, "ws://localhost:5580/ws")
+//	if err != nil {
+//		return err
+//	}
+//	defer c.Close()
+//
+//	c.OnAttributeUpdated(func(update client.AttributeUpdate) {
+//		log.Printf("Node %d: %s = %v", update.NodeID, update.Path, update.Value)
+//	})
+//	nodes, err := c.Subscribe(ctx, client.SubscribeOptions{})
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/onboarding"
+)
+
+// Types of the API, aliased so they can be used outside this module
+type (
+	Node       = models.MatterNodeData
+	Device     = models.Device
+	NodeEvent  = models.MatterNodeEvent
+	ServerInfo = models.ServerInfoMessage
+	EventType  = models.EventType
+	Codec      = models.Codec
+
+	SchemaVersionRange = models.SchemaVersionRange
+	EnergySummary      = models.EnergySummary
+	FirmwareInventory  = models.FirmwareInventory
+	NodeFirmware       = models.NodeFirmware
+	CommissioningEntry = models.CommissioningQueueEntry
+	PairingPayload     = onboarding.Payload
+)
+
+// Codecs of the WebSocket messages
+var (
+	JSON        Codec = models.JSONCodec
+	MessagePack Codec = models.MessagePackCodec
+)
+
+// SchemaVersion is the schema version the types of the client follow.
+// Servers whose minimum supported version is newer can't be talked to.
+const SchemaVersion = models.SchemaVersion
+
+// Error codes of Error
+const (
+	ErrorCodeInvalidMessage        = models.ErrorCodeInvalidMessage
+	ErrorCodeNotFound              = models.ErrorCodeNotFound
+	ErrorCodeSchemaVersionMismatch = models.ErrorCodeSchemaVersionMismatch
+	ErrorCodeInvalidArguments      = models.ErrorCodeInvalidArguments
+	ErrorCodeRateLimited           = models.ErrorCodeRateLimited
+	ErrorCodeCancelled             = models.ErrorCodeCancelled
+	ErrorCodeCommandFailed         = models.ErrorCodeCommandFailed
+	ErrorCodeServerRestarting      = models.ErrorCodeServerRestarting
+)
+
+// ErrClosed is returned for commands on a closed connection
+var ErrClosed = errors.New("connection closed")
+
+// Error is a command failure reported by the server
+type Error struct {
+	Code    int
+	Details string
+	// Field is the offending argument of ErrorCodeInvalidArguments
+	Field string
+	// SupportedSchemaVersions is set for ErrorCodeSchemaVersionMismatch
+	SupportedSchemaVersions *SchemaVersionRange
+	// CancelledBy is the message ID of the cancel command of
+	// ErrorCodeCancelled
+	CancelledBy string
+}
+
+func (e *Error) Error() string {
+	if e.Details == "" {
+		return fmt.Sprintf("command failed with error %d", e.Code)
+	}
+	return fmt.Sprintf("command failed with error %d: %s", e.Code, e.Details)
+}
+
+// ErrDisconnected is returned for commands whose connection was lost before
+// their response arrived. They may or may not have run.
+var ErrDisconnected = errors.New("connection lost")
+
+// Option configures Dial
+type Option func(*options)
+
+type options struct {
+	codec     Codec
+	header    http.Header
+	timeout   time.Duration
+	reconnect *ReconnectOptions
+	cache     bool
+}
+
+// WithCodec prefers codec for the messages. The client falls back to JSON
+// if the server doesn't support it.
+func WithCodec(codec Codec) Option {
+	return func(o *options) { o.codec = codec }
+}
+
+// WithHeader sets headers of the WebSocket handshake, e.g. the Origin
+// allowed by the server
+func WithHeader(header http.Header) Option {
+	return func(o *options) { o.header = header }
+}
+
+// WithTimeout limits how long commands wait for their response, unless
+// their context ends earlier. Commands timing out are cancelled on the
+// server and fail with context.DeadlineExceeded.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) { o.timeout = timeout }
+}
+
+// ReconnectOptions configures reconnecting after losing the connection
+type ReconnectOptions struct {
+	// MinDelay is the delay before the first attempt, doubled after each
+	// failed one up to MaxDelay. Zero values default to 1 and 30 seconds.
+	MinDelay time.Duration
+	MaxDelay time.Duration
+}
+
+// WithReconnect reconnects after losing the connection, until Close is
+// called. Commands sent meanwhile wait for the connection. After
+// reconnecting, the filters of Subscribe are sent again and the changes of
+// the nodes are passed to the callbacks as node_added, node_updated and
+// node_removed events.
+func WithReconnect(opts ReconnectOptions) Option {
+	if opts.MinDelay <= 0 {
+		opts.MinDelay = time.Second
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = 30 * time.Second
+	}
+	opts.MaxDelay = max(opts.MaxDelay, opts.MinDelay)
+	return func(o *options) { o.reconnect = &opts }
+}
+
+// message is any message of the server. Both codecs decode into JSON
+// values, so results and event data are kept raw until their type is known.
+type message struct {
+	MessageID string          `json:"message_id"`
+	Result    json.RawMessage `json:"result"`
+	ErrorCode int             `json:"error_code"`
+	Details   *string         `json:"details"`
+	Field     string          `json:"field"`
+
+	SupportedSchemaVersions *SchemaVersionRange `json:"supported_schema_versions"`
+	CancelledBy             string              `json:"cancelled_by"`
+
+	Event EventType       `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// error maps an error result to an Error
+func (msg message) error() *Error {
+	err := &Error{
+		Code:                    msg.ErrorCode,
+		Field:                   msg.Field,
+		SupportedSchemaVersions: msg.SupportedSchemaVersions,
+		CancelledBy:             msg.CancelledBy,
+	}
+	if msg.Details != nil {
+		err.Details = *msg.Details
+	}
+	return err
+}
+
+// response is the response to a command, or why there is none
+type response struct {
+	msg message
+	err error
+}
+
+// listenRequest is a start_listening command waiting for its response
+type listenRequest struct {
+	messageID string
+	opts      SubscribeOptions
+	// replay is set for the command sent after reconnecting
+	replay bool
+}
+
+// Client is a connection to the WebSocket API
+type Client struct {
+	url  string
+	opts options
+
+	writeMu sync.Mutex
+
+	mu         sync.Mutex
+	conn       *websocket.Conn
+	codec      Codec
+	serverInfo ServerInfo
+	// ready is closed while connected
+	ready   chan struct{}
+	pending map[string]chan response
+	err     error
+	closing bool
+	// subscription holds the filters of Subscribe, nil before
+	subscription *SubscribeOptions
+	listen       listenRequest
+	// nodes holds the IDs of the nodes known from start_listening and
+	// events, to resynchronize them after reconnecting
+	nodes map[int]bool
+	// cache mirrors the nodes with WithCache, nil otherwise
+	cache *nodeCache
+
+	stop chan struct{}
+	done chan struct{}
+
+	events              *eventQueue
+	eventCallbacks      callbacks[Event]
+	disconnectCallbacks callbacks[error]
+	reconnectCallbacks  callbacks[struct{}]
+
+	attributeChangeCallbacks callbacks[AttributeChange]
+}
+
+// Dial connects to the WebSocket API at url, e.g. ws://localhost:5580/ws,
+// and reads the server info sent on connect. Failing to connect is
+// returned, WithReconnect only applies to connections lost later.
+func Dial(ctx context.Context, url string, opts ...Option) (*Client, error) {
+	o := options{codec: JSON}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	c := &Client{
+		url:     url,
+		opts:    o,
+		ready:   make(chan struct{}),
+		pending: make(map[string]chan response),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+		events:  newEventQueue(),
+	}
+	if o.cache {
+		c.cache = newNodeCache()
+	}
+	conn, err := c.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	close(c.ready)
+
+	go c.run(conn)
+	go c.events.run()
+	return c, nil
+}
+
+// connect opens a connection and reads the server info
+func (c *Client) connect(ctx context.Context) (*websocket.Conn, error) {
+	dialer := *websocket.DefaultDialer
+	dialer.Subprotocols = []string{c.opts.codec.Name()}
+	conn, _, err := dialer.DialContext(ctx, c.url, c.opts.header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+
+	// Servers without support for the codec don't select a subprotocol
+	codec := c.opts.codec
+	if conn.Subprotocol() != codec.Name() {
+		codec = JSON
+	}
+
+	var serverInfo ServerInfo
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetReadDeadline(deadline)
+	}
+	_, data, err := conn.ReadMessage()
+	if err == nil {
+		err = codec.Unmarshal(data, &serverInfo)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to read server info: %w", err)
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	c.mu.Lock()
+	c.conn, c.codec, c.serverInfo = conn, codec, serverInfo
+	c.mu.Unlock()
+	return conn, nil
+}
+
+// ServerInfo returns the server info sent on connect
+func (c *Client) ServerInfo() ServerInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.serverInfo
+}
+
+// Done is closed when the connection is closed for good
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns why the connection was closed for good, nil before
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Close closes the connection and stops reconnecting. Pending commands
+// fail with ErrClosed.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.closing {
+		c.mu.Unlock()
+		<-c.done
+		return nil
+	}
+	c.closing = true
+	close(c.stop)
+	conn := c.conn
+	c.mu.Unlock()
+
+	var err error
+	if conn != nil {
+		err = conn.Close()
+	}
+	<-c.done
+	return err
+}
+
+// SendCommand sends a command and returns its result decoded into T, e.g. a
+// struct or map[string]interface{}. Failures reported by the server are
+// returned as *Error.
+func SendCommand[T any](ctx context.Context, c *Client, command string, args map[string]interface{}) (T, error) {
+	var result T
+	err := c.Call(ctx, command, args, &result)
+	return result, err
+}
+
+// Call sends a command and decodes its result into result, unless nil. If
+// ctx ends first, the command is cancelled on the server and ctx.Err() is
+// returned.
+func (c *Client) Call(ctx context.Context, command string, args map[string]interface{}, result interface{}) error {
+	return c.call(ctx, command, args, result, nil)
+}
+
+// call sends a command. listen is set for start_listening, whose response
+// is handled by the reader too.
+func (c *Client) call(ctx context.Context, command string, args map[string]interface{}, result interface{}, listen *SubscribeOptions) error {
+	if c.opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.opts.timeout)
+		defer cancel()
+	}
+
+	id := uuid.New().String()
+	responses := make(chan response, 1)
+
+	// Wait while reconnecting
+	c.mu.Lock()
+	for c.err == nil && c.conn == nil {
+		ready := c.ready
+		c.mu.Unlock()
+		select {
+		case <-ready:
+		case <-c.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		c.mu.Lock()
+	}
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.pending[id] = responses
+	if listen != nil {
+		c.listen = listenRequest{messageID: id, opts: *listen}
+	}
+	conn, codec := c.conn, c.codec
+	c.mu.Unlock()
+
+	if err := c.send(conn, codec, models.CommandMessage{MessageID: id, Command: command, Args: args}); err != nil {
+		c.forget(id)
+		return err
+	}
+
+	select {
+	case resp := <-responses:
+		if resp.err != nil {
+			return resp.err
+		}
+		if resp.msg.ErrorCode != 0 {
+			return resp.msg.error()
+		}
+		if result == nil || len(resp.msg.Result) == 0 {
+			return nil
+		}
+		if err := json.Unmarshal(resp.msg.Result, result); err != nil {
+			return fmt.Errorf("failed to decode result of %s: %w", command, err)
+		}
+		return nil
+	case <-ctx.Done():
+		c.forget(id)
+		// The response of the cancel command is dropped like the
+		// cancelled one
+		c.send(conn, codec, models.CommandMessage{
+			MessageID: uuid.New().String(),
+			Command:   string(models.APICommandCancel),
+			Args:      map[string]interface{}{"message_id": id},
+		})
+		return ctx.Err()
+	}
+}
+
+func (c *Client) forget(id string) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+func (c *Client) send(conn *websocket.Conn, codec Codec, cmd models.CommandMessage) error {
+	data, err := codec.Marshal(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to encode command: %w", err)
+	}
+
+	messageType := websocket.TextMessage
+	if codec.Binary() {
+		messageType = websocket.BinaryMessage
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := conn.WriteMessage(messageType, data); err != nil {
+		return fmt.Errorf("failed to send command: %w", err)
+	}
+	return nil
+}
+
+// run reads the messages of conn and of the connections replacing it
+func (c *Client) run(conn *websocket.Conn) {
+	for conn != nil {
+		err := c.readMessages(conn)
+		conn = c.reconnect(err)
+	}
+	c.events.close()
+	close(c.done)
+}
+
+// readMessages handles the messages of conn until reading fails
+func (c *Client) readMessages(conn *websocket.Conn) error {
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			conn.Close()
+			return err
+		}
+
+		// JSON frames may contain several messages separated by newlines
+		if messageType == websocket.TextMessage {
+			for _, raw := range bytes.Split(data, []byte{'\n'}) {
+				c.handleMessage(raw)
+			}
+		} else {
+			c.handleMessage(data)
+		}
+	}
+}
+
+// reconnect fails the pending commands of a lost connection and returns a
+// new connection, nil if the client is closed for good
+func (c *Client) reconnect(cause error) *websocket.Conn {
+	c.mu.Lock()
+	closing := c.closing
+	pendingErr := fmt.Errorf("%w: %w", ErrDisconnected, cause)
+	if closing || c.opts.reconnect == nil {
+		c.err = ErrClosed
+		if !closing && !websocket.IsCloseError(cause, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+			c.err = fmt.Errorf("%w: %w", ErrClosed, cause)
+		}
+		pendingErr = c.err
+	}
+	for id, responses := range c.pending {
+		responses <- response{err: pendingErr}
+		delete(c.pending, id)
+	}
+	if c.err != nil {
+		c.mu.Unlock()
+		return nil
+	}
+	c.conn = nil
+	c.ready = make(chan struct{})
+	c.mu.Unlock()
+
+	c.events.push(func() { c.disconnectCallbacks.call(cause) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-c.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	delay := c.opts.reconnect.MinDelay
+	for {
+		select {
+		case <-c.stop:
+			return c.closed()
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, c.opts.reconnect.MaxDelay)
+
+		conn, err := c.connect(ctx)
+		if err != nil {
+			continue
+		}
+
+		c.mu.Lock()
+		if c.closing {
+			c.mu.Unlock()
+			conn.Close()
+			return c.closed()
+		}
+		close(c.ready)
+		var replay *listenRequest
+		if c.subscription != nil {
+			c.listen = listenRequest{messageID: uuid.New().String(), opts: *c.subscription, replay: true}
+			replay = &c.listen
+		}
+		codec := c.codec
+		c.mu.Unlock()
+
+		c.events.push(func() { c.reconnectCallbacks.call(struct{}{}) })
+		if replay != nil {
+			// A failure is noticed by reading
+			c.send(conn, codec, models.CommandMessage{
+				MessageID: replay.messageID,
+				Command:   string(models.APICommandStartListening),
+				Args:      replay.opts.args(),
+			})
+		}
+		return conn
+	}
+}
+
+// closed marks the client closed by Close while reconnecting
+func (c *Client) closed() *websocket.Conn {
+	c.mu.Lock()
+	c.err = ErrClosed
+	c.conn = nil
+	close(c.ready)
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *Client) handleMessage(raw []byte) {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return
+	}
+	c.mu.Lock()
+	codec := c.codec
+	c.mu.Unlock()
+
+	var msg message
+	if err := codec.Unmarshal(raw, &msg); err != nil {
+		return
+	}
+
+	if msg.Event != "" {
+		c.trackNode(msg)
+		event := Event{Type: msg.Event, Data: msg.Data}
+		changes := c.cache.apply(event)
+		c.pushEvent(event)
+		c.pushChanges(changes)
+		return
+	}
+
+	var resync []Event
+	listening := false
+	c.mu.Lock()
+	if msg.MessageID == c.listen.messageID && msg.ErrorCode == 0 {
+		listening = true
+		resync = c.listening(msg)
+	}
+	responses, ok := c.pending[msg.MessageID]
+	delete(c.pending, msg.MessageID)
+	c.mu.Unlock()
+
+	// The cache is filled before Subscribe returns
+	if listening {
+		c.pushChanges(c.cache.reset(msg.Result))
+	}
+	for _, event := range resync {
+		c.pushEvent(event)
+	}
+	// Responses of cancelled commands have no waiter
+	if ok {
+		responses <- response{msg: msg}
+	}
+}
+
+// nodeID is the ID in the payload of node events
+type nodeID struct {
+	NodeID int `json:"node_id"`
+}
+
+// trackNode keeps the known nodes up to date with node events
+func (c *Client) trackNode(msg message) {
+	var id nodeID
+	switch msg.Event {
+	case models.EventTypeNodeAdded:
+		if json.Unmarshal(msg.Data, &id) != nil {
+			return
+		}
+	case models.EventTypeNodeRemoved:
+		if json.Unmarshal(msg.Data, &id.NodeID) != nil {
+			return
+		}
+	default:
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.nodes == nil {
+		return
+	}
+	if msg.Event == models.EventTypeNodeAdded {
+		c.nodes[id.NodeID] = true
+	} else {
+		delete(c.nodes, id.NodeID)
+	}
+}
+
+// listening handles the successful response of start_listening. After
+// reconnecting, it returns the node events resynchronizing the nodes
+// received before. It must be called with mu held.
+func (c *Client) listening(msg message) []Event {
+	listen := c.listen
+	c.listen = listenRequest{}
+	c.subscription = &listen.opts
+
+	var nodes []json.RawMessage
+	if err := json.Unmarshal(msg.Result, &nodes); err != nil {
+		return nil
+	}
+	known := c.nodes
+	c.nodes = make(map[int]bool, len(nodes))
+
+	var events []Event
+	for _, node := range nodes {
+		var id nodeID
+		if json.Unmarshal(node, &id) != nil {
+			continue
+		}
+		c.nodes[id.NodeID] = true
+		if !listen.replay {
+			continue
+		}
+		eventType := models.EventTypeNodeAdded
+		if known[id.NodeID] {
+			eventType = models.EventTypeNodeUpdated
+		}
+		events = append(events, Event{Type: eventType, Data: node})
+	}
+	if listen.replay {
+		for id := range known {
+			if !c.nodes[id] {
+				data, _ := json.Marshal(id)
+				events = append(events, Event{Type: models.EventTypeNodeRemoved, Data: data})
+			}
+		}
+	}
+	return events
+}
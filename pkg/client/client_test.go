@@ -0,0 +1,497 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/logger"
+	"github.com/codefionn/go-matter-server/internal/mdns"
+	"github.com/codefionn/go-matter-server/internal/models"
+	"github.com/codefionn/go-matter-server/internal/websocket"
+)
+
+// fakeServer answers commands behind the WebSocket handler of the server
+type fakeServer struct {
+	mu        sync.Mutex
+	nodeIDs   []int
+	commands  []models.CommandMessage
+	callbacks []models.EventCallback
+	cancelled chan struct{}
+	// conns are the connections of the clients, to drop them
+	conns []net.Conn
+}
+
+func (f *fakeServer) HandleCommand(ctx context.Context, cmd models.CommandMessage) (interface{}, error) {
+	f.mu.Lock()
+	f.commands = append(f.commands, cmd)
+	nodes := make([]*models.MatterNodeData, len(f.nodeIDs))
+	for i, nodeID := range f.nodeIDs {
+		nodes[i] = &models.MatterNodeData{NodeID: nodeID, Available: true, Attributes: map[string]interface{}{"1/6/0": false}}
+	}
+	f.mu.Unlock()
+
+	node := nodes[0]
+	switch models.APICommand(cmd.Command) {
+	case models.APICommandStartListening, models.APICommandGetNodes:
+		return nodes, nil
+	case models.APICommandGetNode:
+		if cmd.Args["node_id"] != float64(5) {
+			return nil, &models.ArgumentError{Field: "node_id", Reason: "node not found"}
+		}
+		return node, nil
+	case models.APICommandDeviceCommand:
+		return map[string]interface{}{"status": 0}, nil
+	case models.APICommandInterviewNode:
+		<-ctx.Done()
+		close(f.cancelled)
+		return nil, ctx.Err()
+	}
+	return nil, errors.New("unknown command")
+}
+
+func (f *fakeServer) Subscribe(callback models.EventCallback) func() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.callbacks = append(f.callbacks, callback)
+	return func() {}
+}
+
+func (f *fakeServer) GetServerInfo() models.ServerInfoMessage {
+	return models.ServerInfoMessage{FabricID: 1, SchemaVersion: 11, SDKVersion: "test"}
+}
+
+func (f *fakeServer) emit(eventType models.EventType, data interface{}) {
+	f.mu.Lock()
+	callbacks := f.callbacks
+	f.mu.Unlock()
+	for _, callback := range callbacks {
+		callback(eventType, data)
+	}
+}
+
+func (f *fakeServer) lastCommand() models.CommandMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.commands[len(f.commands)-1]
+}
+
+func (f *fakeServer) commandCount(command models.APICommand) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	count := 0
+	for _, cmd := range f.commands {
+		if cmd.Command == string(command) {
+			count++
+		}
+	}
+	return count
+}
+
+// dropConnections closes the connections of the clients without a close
+// message, like a restarting server or a lost network
+func (f *fakeServer) dropConnections() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, conn := range f.conns {
+		conn.Close()
+	}
+	f.conns = nil
+}
+
+// hijacker records the connections taken over by the WebSocket handler
+type hijacker struct {
+	http.ResponseWriter
+	server *fakeServer
+}
+
+func (h hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := h.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil {
+		h.server.mu.Lock()
+		h.server.conns = append(h.server.conns, conn)
+		h.server.mu.Unlock()
+	}
+	return conn, rw, err
+}
+
+func dialTestServer(t *testing.T, opts ...Option) (*Client, *fakeServer) {
+	t.Helper()
+	server := &fakeServer{nodeIDs: []int{5}, cancelled: make(chan struct{})}
+	handler := websocket.NewHandler(server, logger.NewConsoleLogger(logger.FatalLevel))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.HandleWebSocket(hijacker{ResponseWriter: w, server: server}, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", opts...)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c, server
+}
+
+func TestCommands(t *testing.T) {
+	for _, codec := range []Codec{JSON, MessagePack} {
+		t.Run(codec.Name(), func(t *testing.T) {
+			c, server := dialTestServer(t, WithCodec(codec))
+			ctx := context.Background()
+
+			if info := c.ServerInfo(); info.SDKVersion != "test" || info.SchemaVersion != 11 {
+				t.Errorf("Unexpected server info %+v", info)
+			}
+
+			nodes, err := c.GetNodes(ctx)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(nodes) != 1 || nodes[0].NodeID != 5 || nodes[0].Attributes["1/6/0"] != false {
+				t.Errorf("Unexpected nodes %+v", nodes)
+			}
+
+			_, err = c.GetNode(ctx, 6)
+			var apiErr *Error
+			if !errors.As(err, &apiErr) || apiErr.Code != ErrorCodeInvalidArguments || apiErr.Field != "node_id" {
+				t.Errorf("Expected an invalid argument error, got %v", err)
+			}
+
+			result, err := c.DeviceCommand(ctx, DeviceCommand{
+				NodeID: 5, EndpointID: 1, Cluster: "OnOff", Command: "On",
+				TimedRequestTimeout: time.Second,
+			})
+			if err != nil || string(result) != `{"status":0}` {
+				t.Errorf("Unexpected result %s: %v", result, err)
+			}
+			args := server.lastCommand().Args
+			if args["cluster_id"] != "OnOff" || args["command_name"] != "On" || args["timed_request_timeout_ms"] != float64(1000) {
+				t.Errorf("Unexpected arguments %v", args)
+			}
+		})
+	}
+}
+
+func TestConcurrentCommands(t *testing.T) {
+	c, _ := dialTestServer(t)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.GetNode(context.Background(), 5); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	c, server := dialTestServer(t)
+
+	updates := make(chan AttributeUpdate, 1)
+	removed := make(chan int, 1)
+	c.OnAttributeUpdated(func(update AttributeUpdate) { updates <- update })
+	stop := c.OnNodeRemoved(func(nodeID int) { removed <- nodeID })
+
+	nodes, err := c.Subscribe(context.Background(), SubscribeOptions{})
+	if err != nil || len(nodes) != 1 {
+		t.Fatalf("Expected the nodes, got %+v: %v", nodes, err)
+	}
+
+	server.emit(models.EventTypeAttributeUpdated, []interface{}{5, "1/6/0", true})
+	select {
+	case update := <-updates:
+		if update.NodeID != 5 || update.Path != "1/6/0" || update.Value != true {
+			t.Errorf("Unexpected update %+v", update)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected an attribute update")
+	}
+
+	server.emit(models.EventTypeNodeRemoved, 5)
+	select {
+	case nodeID := <-removed:
+		if nodeID != 5 {
+			t.Errorf("Expected node 5, got %d", nodeID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected a removed node")
+	}
+
+	stop()
+	server.emit(models.EventTypeNodeRemoved, 5)
+	server.emit(models.EventTypeAttributeUpdated, []interface{}{5, "1/6/0", false})
+	<-updates
+	select {
+	case nodeID := <-removed:
+		t.Errorf("Unexpected callback after removing it for node %d", nodeID)
+	default:
+	}
+}
+
+func TestCancelAndClose(t *testing.T) {
+	c, server := dialTestServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := c.Call(ctx, string(models.APICommandInterviewNode), map[string]interface{}{"node_id": 5}, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline to pass, got %v", err)
+	}
+	select {
+	case <-server.cancelled:
+	case <-time.After(time.Second):
+		t.Error("Expected the command to be cancelled on the server")
+	}
+
+	c.Close()
+	if _, err := c.GetNodes(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+	select {
+	case <-c.Done():
+	default:
+		t.Error("Expected Done to be closed")
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	c, server := dialTestServer(t, WithReconnect(ReconnectOptions{MinDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}))
+	server.mu.Lock()
+	server.nodeIDs = []int{5, 6}
+	server.mu.Unlock()
+
+	changes := make(chan string, 10)
+	c.OnDisconnect(func(error) { changes <- "disconnected" })
+	c.OnReconnect(func() { changes <- "reconnected" })
+	c.OnNodeAdded(func(node *Node) { changes <- fmt.Sprintf("added %d", node.NodeID) })
+	c.OnNodeUpdated(func(node *Node) { changes <- fmt.Sprintf("updated %d", node.NodeID) })
+	c.OnNodeRemoved(func(nodeID int) { changes <- fmt.Sprintf("removed %d", nodeID) })
+
+	opts := SubscribeOptions{AttributePaths: []string{"*/6/*"}}
+	if _, err := c.Subscribe(context.Background(), opts); err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	// A command in flight when the connection is lost
+	interview := make(chan error, 1)
+	go func() {
+		interview <- c.Call(context.Background(), string(models.APICommandInterviewNode), map[string]interface{}{"node_id": 5}, nil)
+	}()
+	for server.commandCount(models.APICommandInterviewNode) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Node 6 was removed and node 7 added while disconnected
+	server.mu.Lock()
+	server.nodeIDs = []int{5, 7}
+	server.mu.Unlock()
+	server.dropConnections()
+
+	select {
+	case err := <-interview:
+		if !errors.Is(err, ErrDisconnected) {
+			t.Errorf("Expected ErrDisconnected, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the command to fail")
+	}
+
+	// Commands wait for the connection
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := c.GetNodes(ctx); err != nil {
+		t.Fatalf("Expected the command to succeed after reconnecting: %v", err)
+	}
+
+	for _, want := range []string{"disconnected", "reconnected", "updated 5", "added 7", "removed 6"} {
+		select {
+		case change := <-changes:
+			if change != want {
+				t.Errorf("Expected %q, got %q", want, change)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected %q", want)
+		}
+	}
+
+	if count := server.commandCount(models.APICommandStartListening); count != 2 {
+		t.Fatalf("Expected start_listening to be sent again, got %d", count)
+	}
+	server.mu.Lock()
+	var replay models.CommandMessage
+	for _, cmd := range server.commands {
+		if cmd.Command == string(models.APICommandStartListening) {
+			replay = cmd
+		}
+	}
+	server.mu.Unlock()
+	if paths, _ := replay.Args["attribute_paths"].([]interface{}); len(paths) != 1 || paths[0] != "*/6/*" {
+		t.Errorf("Expected the filters to be sent again, got %v", replay.Args)
+	}
+
+	// Closing stops reconnecting
+	c.Close()
+	if err := c.Err(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+}
+
+func TestWithoutReconnect(t *testing.T) {
+	c, server := dialTestServer(t)
+	server.dropConnections()
+
+	select {
+	case <-c.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Expected the client to be closed")
+	}
+	if _, err := c.GetNodes(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+}
+
+func TestTypedResults(t *testing.T) {
+	c, _ := dialTestServer(t)
+	ctx := context.Background()
+
+	node, err := SendCommand[Node](ctx, c, string(models.APICommandGetNode), map[string]interface{}{"node_id": 5})
+	if err != nil || node.NodeID != 5 {
+		t.Errorf("Expected node 5, got %+v: %v", node, err)
+	}
+	result, err := SendCommand[map[string]interface{}](ctx, c, string(models.APICommandDeviceCommand), nil)
+	if err != nil || result["status"] != float64(0) {
+		t.Errorf("Unexpected result %v: %v", result, err)
+	}
+
+	_, err = SendCommand[[]*Node](ctx, c, string(models.APICommandStartListening), map[string]interface{}{"schema_version": 99})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Code != ErrorCodeSchemaVersionMismatch ||
+		apiErr.SupportedSchemaVersions == nil || apiErr.SupportedSchemaVersions.Max != 11 {
+		t.Errorf("Expected a schema version mismatch, got %#v", err)
+	}
+
+	_, err = SendCommand[bool](ctx, c, string(models.APICommandGetNodes), nil)
+	if err == nil || errors.As(err, &apiErr) {
+		t.Errorf("Expected a decoding error, got %v", err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	c, server := dialTestServer(t, WithTimeout(50*time.Millisecond))
+
+	err := c.Call(context.Background(), string(models.APICommandInterviewNode), map[string]interface{}{"node_id": 5}, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the timeout to pass, got %v", err)
+	}
+	select {
+	case <-server.cancelled:
+	case <-time.After(time.Second):
+		t.Error("Expected the command to be cancelled on the server")
+	}
+
+	// Faster commands aren't affected
+	if _, err := c.GetNodes(context.Background()); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestCache(t *testing.T) {
+	c, server := dialTestServer(t, WithCache())
+
+	changes := make(chan AttributeChange, 10)
+	removed := make(chan int, 1)
+	c.OnAttributeChange(func(change AttributeChange) { changes <- change })
+	c.OnNodeRemoved(func(nodeID int) { removed <- nodeID })
+	nextChange := func() AttributeChange {
+		t.Helper()
+		select {
+		case change := <-changes:
+			return change
+		case <-time.After(time.Second):
+			t.Fatal("Expected an attribute change")
+			return AttributeChange{}
+		}
+	}
+
+	if _, ok := c.GetCachedNode(5); ok {
+		t.Error("Expected no cached node before Subscribe")
+	}
+	if _, err := c.Subscribe(context.Background(), SubscribeOptions{}); err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	if value, ok := c.GetCachedAttribute(5, "1/6/0"); !ok || value != false {
+		t.Errorf("Expected the cached attribute once subscribed, got %v", value)
+	}
+	snapshot, _ := c.GetCachedNode(5)
+
+	server.emit(models.EventTypeAttributeUpdated, []interface{}{5, "1/6/0", true})
+	if change := nextChange(); change != (AttributeChange{NodeID: 5, Path: "1/6/0", OldValue: false, Value: true}) {
+		t.Errorf("Unexpected change %+v", change)
+	}
+	if value, _ := c.GetCachedAttribute(5, "1/6/0"); value != true {
+		t.Errorf("Expected the updated value, got %v", value)
+	}
+	if snapshot.Attributes["1/6/0"] != false {
+		t.Error("Expected snapshots not to change")
+	}
+
+	// Repeated values are no change, new attributes of updated nodes are
+	server.emit(models.EventTypeAttributeUpdated, []interface{}{5, "1/6/0", true})
+	server.emit(models.EventTypeNodeUpdated, &models.MatterNodeData{
+		NodeID:     5,
+		Attributes: map[string]interface{}{"1/6/0": true, "1/8/0": 254},
+	})
+	if change := nextChange(); change != (AttributeChange{NodeID: 5, Path: "1/8/0", Value: float64(254)}) {
+		t.Errorf("Unexpected change %+v", change)
+	}
+
+	server.emit(models.EventTypeNodeRemoved, 5)
+	<-removed
+	if nodes := c.CachedNodes(); len(nodes) != 0 {
+		t.Errorf("Expected the node to be removed, got %+v", nodes)
+	}
+	select {
+	case change := <-changes:
+		t.Errorf("Unexpected change %+v", change)
+	default:
+	}
+}
+
+func TestNewDiscoveredServer(t *testing.T) {
+	service := mdns.ServerService(0x2906C908D115D362, 5580, 5581, 11, 1, mdns.ServerFeatures{Bluetooth: true})
+	server := newDiscoveredServer(mdns.Instance{
+		Name:      "2906C908D115D362._matter-server._tcp.local",
+		Host:      "matter-server.local",
+		Port:      service.Port,
+		Addresses: []net.IP{net.ParseIP("fe80::1"), net.ParseIP("192.168.1.100")},
+		TXT:       service.TXT,
+	})
+	if server.Name != "2906C908D115D362" || server.SchemaVersion != 11 || server.MinSchemaVersion != 1 ||
+		server.TLS || server.GRPCPort != 5581 || server.URL != "ws://192.168.1.100:5580/ws" ||
+		server.Features != (models.ServerFeatures{Bluetooth: true}) {
+		t.Errorf("Unexpected server %+v", server)
+	}
+
+	// Without an SRV port the TXT keys are used
+	server = newDiscoveredServer(mdns.Instance{Host: "matter-server.local", TXT: []string{"port=8443", "tls=1", "path=/api/ws"}})
+	if !server.TLS || server.URL != "wss://matter-server.local:8443/api/ws" {
+		t.Errorf("Expected a TLS URL, got %+v", server)
+	}
+}
@@ -0,0 +1,269 @@
+package client
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// SubscribeOptions selects the events sent after Subscribe. Empty fields
+// select everything.
+type SubscribeOptions struct {
+	// Events lists the event types, e.g. attribute_updated. Opt-in events
+	// such as log_entry are only sent when listed.
+	Events []EventType
+	// NodeIDs limits node events to these nodes
+	NodeIDs []int
+	// AttributePaths limits attribute_updated events to these paths
+	// ("endpoint/cluster/attribute", each part may be "*")
+	AttributePaths []string
+	// SchemaVersion requests the message format of an older schema version,
+	// 0 uses the server's. Unsupported versions fail with
+	// ErrorCodeSchemaVersionMismatch.
+	SchemaVersion int
+}
+
+// Subscribe starts listening for events, which are passed to the callbacks
+// registered with the On methods, and returns the current nodes. Register
+// the callbacks first to not miss events. With WithReconnect, the filters
+// are sent again after reconnecting.
+func (c *Client) Subscribe(ctx context.Context, opts SubscribeOptions) ([]*Node, error) {
+	var nodes []*Node
+	err := c.call(ctx, string(models.APICommandStartListening), opts.args(), &nodes, &opts)
+	return nodes, err
+}
+
+// args returns the arguments of start_listening
+func (opts SubscribeOptions) args() map[string]interface{} {
+	args := make(map[string]interface{})
+	if len(opts.Events) > 0 {
+		args["events"] = opts.Events
+	}
+	if len(opts.NodeIDs) > 0 {
+		args["node_ids"] = opts.NodeIDs
+	}
+	if len(opts.AttributePaths) > 0 {
+		args["attribute_paths"] = opts.AttributePaths
+	}
+	if opts.SchemaVersion > 0 {
+		args["schema_version"] = opts.SchemaVersion
+	}
+	return args
+}
+
+// GetNodes returns all commissioned nodes
+func (c *Client) GetNodes(ctx context.Context) ([]*Node, error) {
+	var nodes []*Node
+	err := c.Call(ctx, string(models.APICommandGetNodes), nil, &nodes)
+	return nodes, err
+}
+
+// GetNode returns a node, failing with ErrorCodeNotFound for unknown ones
+func (c *Client) GetNode(ctx context.Context, nodeID int) (*Node, error) {
+	var node Node
+	err := c.Call(ctx, string(models.APICommandGetNode), map[string]interface{}{"node_id": nodeID}, &node)
+	if err != nil {
+		return nil, err
+	}
+	return &node, nil
+}
+
+// Commission commissions a device with its setup code (the QR code's
+// MT:... payload or the manual pairing code) and returns the new node
+func (c *Client) Commission(ctx context.Context, code string) (*Node, error) {
+	return c.CommissionOnFabric(ctx, code, 0)
+}
+
+// CommissionOnFabric commissions a device into one of the fabrics listed in
+// ServerInfo().Fabrics, 0 selecting the default fabric
+func (c *Client) CommissionOnFabric(ctx context.Context, code string, fabricID int) (*Node, error) {
+	args := map[string]interface{}{"code": code}
+	if fabricID != 0 {
+		args["fabric_id"] = fabricID
+	}
+	var node Node
+	err := c.Call(ctx, string(models.APICommandCommissionWithCode), args, &node)
+	if err != nil {
+		return nil, err
+	}
+	return &node, nil
+}
+
+// SetNodeName sets the friendly name of a node, which is also written to its
+// NodeLabel if the device has one. An empty name clears it.
+func (c *Client) SetNodeName(ctx context.Context, nodeID int, name string) (*Node, error) {
+	var node Node
+	err := c.Call(ctx, string(models.APICommandSetNodeName), map[string]interface{}{"node_id": nodeID, "name": name}, &node)
+	if err != nil {
+		return nil, err
+	}
+	return &node, nil
+}
+
+// RemoveNode decommissions a node
+func (c *Client) RemoveNode(ctx context.Context, nodeID int) error {
+	return c.Call(ctx, string(models.APICommandRemoveNode), map[string]interface{}{"node_id": nodeID}, nil)
+}
+
+// DeviceCommand is a cluster command sent to an endpoint of a node
+type DeviceCommand struct {
+	NodeID     int
+	EndpointID int
+	// Cluster and Command are IDs or names, e.g. "OnOff" and "Toggle"
+	Cluster string
+	Command string
+	Payload map[string]interface{}
+	// PayloadTLV holds the TLV encoded command fields instead of Payload,
+	// e.g. for manufacturer-specific commands
+	PayloadTLV []byte
+	// RawResponse returns the response fields as hex encoded TLV
+	RawResponse bool
+	// TimedRequestTimeout sends the command as a timed interaction, which
+	// some commands such as unlocking a door require
+	TimedRequestTimeout time.Duration
+}
+
+// DeviceCommand sends a cluster command and returns its response payload
+func (c *Client) DeviceCommand(ctx context.Context, cmd DeviceCommand) (json.RawMessage, error) {
+	args := map[string]interface{}{
+		"node_id":      cmd.NodeID,
+		"endpoint_id":  cmd.EndpointID,
+		"cluster_id":   cmd.Cluster,
+		"command_name": cmd.Command,
+	}
+	if cmd.Payload != nil {
+		args["payload"] = cmd.Payload
+	}
+	if cmd.PayloadTLV != nil {
+		args["payload_tlv"] = hex.EncodeToString(cmd.PayloadTLV)
+	}
+	if cmd.RawResponse {
+		args["raw_response"] = true
+	}
+	if cmd.TimedRequestTimeout > 0 {
+		args["timed_request_timeout_ms"] = cmd.TimedRequestTimeout.Milliseconds()
+	}
+
+	var result json.RawMessage
+	err := c.Call(ctx, string(models.APICommandDeviceCommand), args, &result)
+	return result, err
+}
+
+// ParsePairingCode decodes a QR code payload or manual pairing code without
+// commissioning, e.g. to validate it
+func (c *Client) ParsePairingCode(ctx context.Context, code string) (*PairingPayload, error) {
+	var payload PairingPayload
+	err := c.Call(ctx, string(models.APICommandParsePairingCode), map[string]interface{}{"code": code}, &payload)
+	if err != nil {
+		return nil, err
+	}
+	return &payload, nil
+}
+
+// GetDevices returns the endpoints of all nodes that are supported device
+// types, with their normalized state and actions
+func (c *Client) GetDevices(ctx context.Context) ([]Device, error) {
+	var devices []Device
+	err := c.Call(ctx, string(models.APICommandGetDevices), nil, &devices)
+	return devices, err
+}
+
+// GetEnergySummary returns the power and energy of all nodes measuring them
+// with their totals
+func (c *Client) GetEnergySummary(ctx context.Context) (*EnergySummary, error) {
+	var summary EnergySummary
+	if err := c.Call(ctx, string(models.APICommandGetEnergySummary), nil, &summary); err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// GetFirmwareInventory returns the software versions of the nodes with the
+// updates available, checking all nodes for updates first if refresh is set
+func (c *Client) GetFirmwareInventory(ctx context.Context, refresh bool) (*FirmwareInventory, error) {
+	var inventory FirmwareInventory
+	if err := c.Call(ctx, string(models.APICommandGetFirmwareInventory), map[string]interface{}{"refresh": refresh}, &inventory); err != nil {
+		return nil, err
+	}
+	return &inventory, nil
+}
+
+// DeviceAction performs one of the Actions of a device, e.g. set_brightness
+// with {"brightness": 50}, and returns the response payload of the command
+// it resolves to
+func (c *Client) DeviceAction(ctx context.Context, nodeID, endpointID int, action string, params map[string]interface{}) (json.RawMessage, error) {
+	args := map[string]interface{}{
+		"node_id":     nodeID,
+		"endpoint_id": endpointID,
+		"action":      action,
+	}
+	if params != nil {
+		args["params"] = params
+	}
+
+	var result json.RawMessage
+	err := c.Call(ctx, string(models.APICommandDeviceAction), args, &result)
+	return result, err
+}
+
+// ReadAttribute reads an attribute path ("endpoint/cluster/attribute") from
+// a node. Clusters and attributes are IDs or names.
+func (c *Client) ReadAttribute(ctx context.Context, nodeID int, path string) (json.RawMessage, error) {
+	var result json.RawMessage
+	err := c.Call(ctx, string(models.APICommandReadAttribute), map[string]interface{}{
+		"node_id":        nodeID,
+		"attribute_path": path,
+	}, &result)
+	return result, err
+}
+
+// ReadAttributeTLV reads the TLV encoded value of an attribute path, e.g. of
+// a manufacturer-specific attribute
+func (c *Client) ReadAttributeTLV(ctx context.Context, nodeID int, path string) ([]byte, error) {
+	var result string
+	err := c.Call(ctx, string(models.APICommandReadAttribute), map[string]interface{}{
+		"node_id":        nodeID,
+		"attribute_path": path,
+		"raw":            true,
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(result)
+}
+
+// WriteAttributeTLV writes a TLV encoded value to an attribute path
+func (c *Client) WriteAttributeTLV(ctx context.Context, nodeID int, path string, value []byte) error {
+	return c.Call(ctx, string(models.APICommandWriteAttribute), map[string]interface{}{
+		"node_id":        nodeID,
+		"attribute_path": path,
+		"value_tlv":      hex.EncodeToString(value),
+	}, nil)
+}
+
+// GetCommissioningQueue returns the queued and running commissioning
+// requests in order
+func (c *Client) GetCommissioningQueue(ctx context.Context) ([]CommissioningEntry, error) {
+	var entries []CommissioningEntry
+	err := c.Call(ctx, string(models.APICommandGetCommissioningQueue), nil, &entries)
+	return entries, err
+}
+
+// CancelCommissioning cancels a queued commissioning request, or one waiting
+// to retry. The request fails with ErrorCodeCommandFailed.
+func (c *Client) CancelCommissioning(ctx context.Context, queueID string) error {
+	return c.Call(ctx, string(models.APICommandCancelCommissioning), map[string]interface{}{"queue_id": queueID}, nil)
+}
+
+// WriteAttribute writes value to an attribute path
+// ("endpoint/cluster/attribute") of a node
+func (c *Client) WriteAttribute(ctx context.Context, nodeID int, path string, value interface{}) error {
+	return c.Call(ctx, string(models.APICommandWriteAttribute), map[string]interface{}{
+		"node_id":        nodeID,
+		"attribute_path": path,
+		"value":          value,
+	}, nil)
+}
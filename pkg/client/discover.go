@@ -0,0 +1,119 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/codefionn/go-matter-server/internal/mdns"
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// DiscoveredServer is a server advertising its WebSocket API on the local
+// network
+type DiscoveredServer struct {
+	// Name is the DNS-SD instance name, the compressed fabric ID of the
+	// server
+	Name string
+	// Host is the mDNS hostname, e.g. "matter-server.local"
+	Host      string
+	Port      int
+	Addresses []net.IP
+	// SchemaVersion and MinSchemaVersion are the supported schema versions,
+	// 0 if the server doesn't advertise them
+	SchemaVersion    int
+	MinSchemaVersion int
+	// TLS is set if the API is served over TLS
+	TLS bool
+	// GRPCPort is the port of the gRPC API, 0 if it is disabled
+	GRPCPort int
+	// Features are the advertised optional capabilities
+	Features models.ServerFeatures
+	// URL is the WebSocket URL to pass to Dial
+	URL string
+}
+
+// Discover browses the local network via mDNS for servers advertising the
+// _matter-server._tcp service until ctx ends, so give it a deadline of a
+// few seconds. The servers are sorted by host name.
+func Discover(ctx context.Context) ([]DiscoveredServer, error) {
+	instances, err := mdns.Lookup(ctx, mdns.ServerServiceType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover servers: %w", err)
+	}
+
+	servers := make([]DiscoveredServer, 0, len(instances))
+	for _, instance := range instances {
+		servers = append(servers, newDiscoveredServer(instance))
+	}
+	sort.Slice(servers, func(i, j int) bool { return servers[i].Host < servers[j].Host })
+	return servers, nil
+}
+
+// newDiscoveredServer reads a resolved _matter-server._tcp instance. The
+// port of the SRV record takes precedence over the port TXT key, which is
+// only there for clients that can't read SRV records.
+func newDiscoveredServer(instance mdns.Instance) DiscoveredServer {
+	server := DiscoveredServer{
+		Name:      strings.TrimSuffix(instance.Name, "."+mdns.ServerServiceType+".local"),
+		Host:      instance.Host,
+		Port:      int(instance.Port),
+		Addresses: instance.Addresses,
+	}
+	path := mdns.ServerPath
+	for _, entry := range instance.TXT {
+		key, value, _ := strings.Cut(entry, "=")
+		switch key {
+		case "schema_version":
+			server.SchemaVersion, _ = strconv.Atoi(value)
+		case "min_schema_version":
+			server.MinSchemaVersion, _ = strconv.Atoi(value)
+		case "path":
+			path = value
+		case "port":
+			if server.Port == 0 {
+				server.Port, _ = strconv.Atoi(value)
+			}
+		case "tls":
+			server.TLS = value == "1"
+		case "grpc_port":
+			server.GRPCPort, _ = strconv.Atoi(value)
+		case "bluetooth":
+			server.Features.Bluetooth = value == "1"
+		case "thread":
+			server.Features.Thread = value == "1"
+		case "ota":
+			server.Features.OTA = value == "1"
+		}
+	}
+
+	scheme := "ws://"
+	if server.TLS {
+		scheme = "wss://"
+	}
+	server.URL = scheme + net.JoinHostPort(server.dialHost(), strconv.Itoa(server.Port)) + path
+	return server
+}
+
+// dialHost returns the address to connect to: an IPv4 address, else a
+// routable IPv6 address, as link-local ones need the interface. The mDNS
+// hostname is the last resort, it only resolves where the system supports
+// mDNS.
+func (s DiscoveredServer) dialHost() string {
+	var ipv6 net.IP
+	for _, ip := range s.Addresses {
+		if ip.To4() != nil {
+			return ip.String()
+		}
+		if ipv6 == nil && !ip.IsLinkLocalUnicast() {
+			ipv6 = ip
+		}
+	}
+	if ipv6 != nil {
+		return ipv6.String()
+	}
+	return s.Host
+}
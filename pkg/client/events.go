@@ -0,0 +1,184 @@
+package client
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/codefionn/go-matter-server/internal/models"
+)
+
+// Event is an event sent by the server. Data is its JSON payload.
+type Event struct {
+	Type EventType
+	Data json.RawMessage
+}
+
+// AttributeUpdate is the payload of an attribute_updated event
+type AttributeUpdate struct {
+	NodeID int
+	// Path is the attribute path "endpoint/cluster/attribute"
+	Path  string
+	Value interface{}
+}
+
+// UnmarshalJSON decodes the [node_id, attribute_path, value] form of the
+// server
+func (u *AttributeUpdate) UnmarshalJSON(data []byte) error {
+	fields := []interface{}{&u.NodeID, &u.Path, &u.Value}
+	return json.Unmarshal(data, &fields)
+}
+
+// callbacks is a set of registered callbacks
+type callbacks[T any] struct {
+	mu     sync.Mutex
+	nextID int
+	fns    map[int]func(T)
+}
+
+func (cs *callbacks[T]) add(fn func(T)) func() {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.fns == nil {
+		cs.fns = make(map[int]func(T))
+	}
+	id := cs.nextID
+	cs.nextID++
+	cs.fns[id] = fn
+	return func() {
+		cs.mu.Lock()
+		delete(cs.fns, id)
+		cs.mu.Unlock()
+	}
+}
+
+func (cs *callbacks[T]) call(value T) {
+	cs.mu.Lock()
+	fns := make([]func(T), 0, len(cs.fns))
+	for _, fn := range cs.fns {
+		fns = append(fns, fn)
+	}
+	cs.mu.Unlock()
+
+	for _, fn := range fns {
+		fn(value)
+	}
+}
+
+// eventQueue runs the callbacks of events in order on its own goroutine,
+// so callbacks may send commands without blocking the reading of their
+// responses
+type eventQueue struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []func()
+	closed bool
+}
+
+func newEventQueue() *eventQueue {
+	q := &eventQueue{}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+func (q *eventQueue) push(fn func()) {
+	q.mu.Lock()
+	q.queue = append(q.queue, fn)
+	q.mu.Unlock()
+	q.cond.Signal()
+}
+
+func (q *eventQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.cond.Signal()
+}
+
+// run calls the queued functions until the queue is closed and drained
+func (q *eventQueue) run() {
+	for {
+		q.mu.Lock()
+		for len(q.queue) == 0 && !q.closed {
+			q.cond.Wait()
+		}
+		if len(q.queue) == 0 {
+			q.mu.Unlock()
+			return
+		}
+		fn := q.queue[0]
+		q.queue = q.queue[1:]
+		q.mu.Unlock()
+
+		fn()
+	}
+}
+
+func (c *Client) pushEvent(event Event) {
+	c.events.push(func() { c.eventCallbacks.call(event) })
+}
+
+// OnEvent registers a callback for all events and returns a function
+// removing it. Callbacks run one event at a time on a goroutine of the
+// client.
+func (c *Client) OnEvent(fn func(Event)) func() {
+	return c.eventCallbacks.add(fn)
+}
+
+// onEvent registers a callback for events of one type, whose payload is
+// decoded into T. Events with a payload not matching T are skipped.
+func onEvent[T any](c *Client, eventType EventType, fn func(T)) func() {
+	return c.OnEvent(func(event Event) {
+		if event.Type != eventType {
+			return
+		}
+		var data T
+		if err := json.Unmarshal(event.Data, &data); err == nil {
+			fn(data)
+		}
+	})
+}
+
+// OnNodeAdded registers a callback for commissioned nodes
+func (c *Client) OnNodeAdded(fn func(*Node)) func() {
+	return onEvent(c, models.EventTypeNodeAdded, fn)
+}
+
+// OnNodeUpdated registers a callback for nodes whose data changed, e.g.
+// after an interview
+func (c *Client) OnNodeUpdated(fn func(*Node)) func() {
+	return onEvent(c, models.EventTypeNodeUpdated, fn)
+}
+
+// OnNodeRemoved registers a callback for the IDs of removed nodes
+func (c *Client) OnNodeRemoved(fn func(nodeID int)) func() {
+	return onEvent(c, models.EventTypeNodeRemoved, fn)
+}
+
+// OnAttributeUpdated registers a callback for changed attribute values
+func (c *Client) OnAttributeUpdated(fn func(AttributeUpdate)) func() {
+	return onEvent(c, models.EventTypeAttributeUpdated, fn)
+}
+
+// OnNodeEvent registers a callback for events reported by nodes, e.g. a
+// switch being pressed
+func (c *Client) OnNodeEvent(fn func(NodeEvent)) func() {
+	return onEvent(c, models.EventTypeNodeEvent, fn)
+}
+
+// OnFirmwareUpdateAvailable registers a callback for software updates found
+// for nodes
+func (c *Client) OnFirmwareUpdateAvailable(fn func(NodeFirmware)) func() {
+	return onEvent(c, models.EventTypeFirmwareUpdateAvailable, fn)
+}
+
+// OnDisconnect registers a callback for losing the connection while
+// reconnecting is enabled, with the reason
+func (c *Client) OnDisconnect(fn func(error)) func() {
+	return c.disconnectCallbacks.add(fn)
+}
+
+// OnReconnect registers a callback for reconnecting. It runs before the
+// events resynchronizing the nodes.
+func (c *Client) OnReconnect(fn func()) func() {
+	return c.reconnectCallbacks.add(func(struct{}) { fn() })
+}